@@ -52,7 +52,9 @@ val targets = arrayOf(
         "mac/amd64/tip",
 
         "mac/arm64/1.18",
-        "mac/arm64/tip"
+        "mac/arm64/tip",
+
+        "openbsd/amd64/1.18"
 )
 
 project {
@@ -211,6 +213,13 @@ class TestBuild(val os: String, val arch: String, version: String, buildId: Abso
                     arguments = "${"go$version"} $arch %system.teamcity.build.tempDir%"
                 }
             }
+            "openbsd" -> {
+                exec {
+                    name = "Test"
+                    path = "_scripts/test_openbsd.sh"
+                    arguments = "${"go$version"} $arch %system.teamcity.build.tempDir%"
+                }
+            }
         }
     }
 
@@ -229,6 +238,9 @@ class TestBuild(val os: String, val arch: String, version: String, buildId: Abso
             "mac" -> {
                 matches("teamcity.agent.jvm.os.family", "Mac OS")
             }
+            "openbsd" -> {
+                matches("teamcity.agent.jvm.os.family", "OpenBSD")
+            }
         }
     }
 
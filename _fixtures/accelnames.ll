@@ -0,0 +1,48 @@
+; LLVM IR of the following C program, used to test the accelerator tables
+; (.debug_names and .gdb_index) produced by llc and the linker.
+;
+;	struct point { int x, y; };
+;	struct point origin = {1, 2};
+;	int add(int a, int b) { return a + b; }
+;	int main() { return add(origin.x, 3); }
+
+target triple = "x86_64-pc-linux-gnu"
+
+%struct.point = type { i32, i32 }
+
+@origin = dso_local global %struct.point { i32 1, i32 2 }, align 4, !dbg !0
+
+define dso_local i32 @add(i32 %a, i32 %b) !dbg !20 {
+  %s = add i32 %a, %b, !dbg !25
+  ret i32 %s, !dbg !25
+}
+
+define dso_local i32 @main() !dbg !30 {
+  %x = load i32, i32* getelementptr (%struct.point, %struct.point* @origin, i32 0, i32 0), align 4, !dbg !33
+  %r = call i32 @add(i32 %x, i32 3), !dbg !33
+  ret i32 %r, !dbg !33
+}
+
+!llvm.dbg.cu = !{!2}
+!llvm.module.flags = !{!15, !16}
+
+!0 = !DIGlobalVariableExpression(var: !1, expr: !DIExpression())
+!1 = distinct !DIGlobalVariable(name: "origin", scope: !2, file: !3, line: 3, type: !6, isLocal: false, isDefinition: true)
+!2 = distinct !DICompileUnit(language: DW_LANG_C99, file: !3, producer: "handwritten", isOptimized: false, runtimeVersion: 0, emissionKind: FullDebug, globals: !5, nameTableKind: Default)
+!3 = !DIFile(filename: "a.c", directory: "/tmp")
+!5 = !{!0}
+!6 = distinct !DICompositeType(tag: DW_TAG_structure_type, name: "point", file: !3, line: 2, size: 64, elements: !7)
+!7 = !{!8, !10}
+!8 = !DIDerivedType(tag: DW_TAG_member, name: "x", scope: !6, file: !3, line: 2, baseType: !9, size: 32)
+!9 = !DIBasicType(name: "int", size: 32, encoding: DW_ATE_signed)
+!10 = !DIDerivedType(tag: DW_TAG_member, name: "y", scope: !6, file: !3, line: 2, baseType: !9, size: 32, offset: 32)
+!15 = !{i32 7, !"Dwarf Version", i32 4}
+!16 = !{i32 2, !"Debug Info Version", i32 3}
+!20 = distinct !DISubprogram(name: "add", scope: !3, file: !3, line: 4, type: !21, scopeLine: 4, spFlags: DISPFlagDefinition, unit: !2)
+!21 = !DISubroutineType(types: !22)
+!22 = !{!9, !9, !9}
+!25 = !DILocation(line: 4, column: 1, scope: !20)
+!30 = distinct !DISubprogram(name: "main", scope: !3, file: !3, line: 5, type: !31, scopeLine: 5, spFlags: DISPFlagDefinition, unit: !2)
+!31 = !DISubroutineType(types: !32)
+!32 = !{!9}
+!33 = !DILocation(line: 6, column: 1, scope: !30)
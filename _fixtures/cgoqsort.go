@@ -0,0 +1,41 @@
+package main
+
+/*
+#include <stdlib.h>
+
+extern int goCompare(void *, void *);
+
+static int compare(const void *a, const void *b) {
+	return goCompare((void *)a, (void *)b);
+}
+
+static void sortInts(int *v, int n) {
+	qsort(v, n, sizeof(int), compare);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+//export goCompare
+func goCompare(a, b unsafe.Pointer) C.int {
+	x, y := *(*C.int)(a), *(*C.int)(b)
+	runtime.Breakpoint()
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func main() {
+	v := []C.int{3, 1, 2}
+	C.sortInts(&v[0], C.int(len(v)))
+	fmt.Println(v)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+func send(c chan int, n int) {
+	c <- n
+}
+
+func recv(c chan string) {
+	<-c
+}
+
+func main() {
+	buffered := make(chan int, 4)
+	for i := 1; i <= 4; i++ {
+		buffered <- i
+	}
+	<-buffered
+	buffered <- 5 // the buffer wraps around
+	go send(buffered, 6)
+
+	unbuffered := make(chan string)
+	go recv(unbuffered)
+
+	closed := make(chan int, 1)
+	close(closed)
+
+	time.Sleep(200 * time.Millisecond)
+	runtime.Breakpoint()
+	fmt.Println(len(buffered), unbuffered, closed)
+}
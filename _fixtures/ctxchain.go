@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+type ctxKey string
+
+func main() {
+	base := context.WithValue(context.Background(), ctxKey("user"), "alice")
+	deadline, cancel1 := context.WithTimeout(base, time.Hour)
+	defer cancel1()
+	canceled, cancel2 := context.WithCancelCause(deadline)
+	cancel2(errors.New("shutting down"))
+	ctx := context.WithValue(canceled, ctxKey("request"), 42)
+	runtime.Breakpoint()
+	fmt.Println(ctx, canceled)
+}
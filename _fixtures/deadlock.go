@@ -0,0 +1,34 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+func relay(in, out chan int) {
+	<-in
+	out <- 1
+}
+
+func lockBoth(a, b *sync.Mutex) {
+	a.Lock()
+	time.Sleep(50 * time.Millisecond)
+	b.Lock()
+}
+
+func setup() {
+	c1, c2 := make(chan int), make(chan int)
+	go relay(c1, c2)
+	go relay(c2, c1)
+
+	mu1, mu2 := new(sync.Mutex), new(sync.Mutex)
+	go lockBoth(mu1, mu2)
+	go lockBoth(mu2, mu1)
+}
+
+func main() {
+	setup()
+	time.Sleep(500 * time.Millisecond)
+	runtime.Breakpoint()
+}
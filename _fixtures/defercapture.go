@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func main() {
+	n, s := 1, "hello"
+	defer func() {
+		fmt.Println(n, s)
+	}()
+	n++
+	runtime.Breakpoint()
+}
@@ -0,0 +1,26 @@
+package main
+
+import "runtime"
+
+func loopDefers() {
+	for i := 0; i < 1; i++ {
+		// deferred calls in a loop are not open-coded and are executed by
+		// runtime.deferreturn
+		defer func() {
+			runtime.Breakpoint()
+		}()
+	}
+}
+
+func panicDefers() {
+	defer func() {
+		runtime.Breakpoint()
+		recover()
+	}()
+	panic("boom")
+}
+
+func main() {
+	loopDefers()
+	panicDefers()
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func child() {
+	fmt.Println("child")
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "child" {
+		child()
+		os.Exit(2)
+	}
+	argv := []string{"/bin/sh", "-c", "exit 3"}
+	if len(os.Args) > 1 && os.Args[1] == "self" {
+		argv = []string{os.Args[0], "child"}
+	}
+	err := syscall.Exec(argv[0], argv, os.Environ())
+	fmt.Println("exec failed", err)
+	os.Exit(1)
+}
@@ -0,0 +1,16 @@
+package fuzztest
+
+import (
+	"strconv"
+	"testing"
+)
+
+func FuzzParse(f *testing.F) {
+	f.Add("1")
+	f.Fuzz(func(t *testing.T, s string) {
+		n, err := strconv.Atoi(s)
+		if err == nil && n == 42 {
+			t.Fatalf("parsed %d", n)
+		}
+	})
+}
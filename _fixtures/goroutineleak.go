@@ -0,0 +1,23 @@
+package main
+
+import (
+	"runtime"
+	"time"
+)
+
+func leaky(ch chan int) {
+	<-ch
+}
+
+func main() {
+	ch := make(chan int)
+	for i := 0; i < 10; i++ {
+		go leaky(ch)
+	}
+	time.Sleep(100 * time.Millisecond)
+	runtime.GC() // records the time the goroutines started waiting
+	time.Sleep(2 * time.Second)
+	runtime.GC()
+	runtime.Breakpoint()
+	close(ch)
+}
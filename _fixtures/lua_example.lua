@@ -0,0 +1,20 @@
+-- Values returned by API calls have the same structure they have in the
+-- JSON-RPC API.
+
+function command_luaecho(args)
+	local out = dlv.eval{Scope=dlv.cur_scope(), Expr=args, Cfg=dlv.default_load_config()}
+	print(args .. " = " .. out.Variable.value)
+end
+
+function command_luafuncs(args)
+	local n = 0
+	for _, f in ipairs(dlv.functions(args).Funcs) do
+		n = n + 1
+	end
+	print(n .. " functions")
+end
+
+function main()
+	dlv.create_breakpoint({FunctionName="main.afunc1", Line=-1})
+	dlv.command("breakpoints")
+end
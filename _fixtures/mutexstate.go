@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+type store struct {
+	mu   sync.Mutex
+	data map[string]int
+}
+
+func (s *store) hold(locked, release chan struct{}) {
+	s.mu.Lock()
+	close(locked)
+	<-release
+	s.mu.Unlock()
+}
+
+func (s *store) get(k string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[k]
+}
+
+func readLock(rw *sync.RWMutex, release chan struct{}) {
+	rw.RLock()
+	<-release
+	rw.RUnlock()
+}
+
+func writeLock(rw *sync.RWMutex) {
+	rw.Lock()
+	rw.Unlock()
+}
+
+func main() {
+	s := &store{data: map[string]int{}}
+	locked, release := make(chan struct{}), make(chan struct{})
+	go s.hold(locked, release)
+	<-locked
+	go s.get("a")
+	go s.get("b")
+
+	rw := new(sync.RWMutex)
+	go readLock(rw, release)
+	time.Sleep(50 * time.Millisecond)
+	go writeLock(rw)
+
+	var free sync.Mutex
+
+	time.Sleep(200 * time.Millisecond)
+	runtime.Breakpoint()
+	close(release)
+	free.Lock()
+	fmt.Println(s.get("a"), rw)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+var counter uint64
+
+func spin() {
+	runtime.LockOSThread()
+	for {
+		atomic.AddUint64(&counter, 1)
+	}
+}
+
+func tick(i int) {
+	time.Sleep(10 * time.Millisecond)
+}
+
+func main() {
+	go spin()
+	for atomic.LoadUint64(&counter) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		tick(i)
+	}
+}
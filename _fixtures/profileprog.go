@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// referenced so that the linker keeps the functions used to start and stop
+// the profilers.
+var profilers = []interface{}{os.CreateTemp, os.ReadFile, os.Remove, pprof.StartCPUProfile, pprof.StopCPUProfile, trace.Start, trace.Stop}
+
+func spin(n int) int {
+	s := 0
+	for i := 0; i < n; i++ {
+		s += i * i
+	}
+	return s
+}
+
+func main() {
+	fmt.Println(len(profilers))
+	for {
+		spin(1000000)
+	}
+}
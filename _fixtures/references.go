@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+type node struct {
+	name string
+	next *node
+}
+
+type holder struct {
+	items [4]*node
+}
+
+var global *node
+
+func main() {
+	target := &node{name: "target"}
+	a := &node{name: "a", next: target}
+	h := &holder{}
+	h.items[2] = target
+	global = target
+	runtime.Breakpoint()
+	fmt.Println(a, h, global)
+}
@@ -0,0 +1,16 @@
+package main
+
+import "fmt"
+
+type panicError struct {
+	code int
+	msg  string
+}
+
+func main() {
+	defer func() {
+		r := recover()
+		panic(fmt.Sprintf("panic while recovering: %v", r))
+	}()
+	panic(panicError{code: 42, msg: "BOOM!"})
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+func main() {
+	wd, _ := os.Getwd()
+	env := os.Getenv("DLV_RESTART_ENV")
+	runtime.Breakpoint()
+	fmt.Println(wd, env)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+func child() {
+	fmt.Println("child")
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "child" {
+		child()
+		os.Exit(2)
+	}
+	cmd := exec.Command(os.Args[0], "child")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	fmt.Println("parent", err)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func child() {
+	fmt.Println("child")
+}
+
+func main() {
+	// fork(2) without exec, clone with only the exit signal set is
+	// equivalent to it and also available on arm64.
+	pid, _, errno := syscall.RawSyscall6(syscall.SYS_CLONE, uintptr(syscall.SIGCHLD), 0, 0, 0, 0, 0)
+	if errno != 0 {
+		fmt.Println("fork", errno)
+		os.Exit(1)
+	}
+	if pid == 0 {
+		child()
+		os.Exit(2)
+	}
+	var ws syscall.WaitStatus
+	_, err := syscall.Wait4(int(pid), &ws, 0, nil)
+	fmt.Println("parent", ws.ExitStatus(), err)
+}
@@ -71,6 +71,7 @@ func fieldsOfStruct(typ types.Type) (fieldNames, fieldTypes []string) {
 	return fieldNames, fieldTypes
 }
 
+// camelToDash must be kept in sync with pkg/terminal/scriptbind.bindingName.
 func camelToDash(in string) string {
 	out := []rune{}
 	for i, ch := range in {
@@ -229,6 +230,7 @@ func genDocs(bindings []binding) []byte {
 	}
 
 	fmt.Fprintf(&buf, "dlv_command(command) | Executes the specified command as if typed at the dlv_prompt\n")
+	fmt.Fprintf(&buf, "register_command(name, fn, args, help, completer) | Registers fn as a command line command, see [Commands with arguments](#commands-with-arguments)\n")
 	fmt.Fprintf(&buf, "read_file(path) | Reads the file as a string\n")
 	fmt.Fprintf(&buf, "write_file(path, contents) | Writes string to a file\n")
 	fmt.Fprintf(&buf, "cur_scope() | Returns the current evaluation scope\n")
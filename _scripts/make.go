@@ -376,6 +376,10 @@ func testCmd(cmd *cobra.Command, args []string) {
 func testStandard() {
 	fmt.Println("Testing default backend")
 	testCmdIntl("all", "", "default", "normal")
+	if runtime.GOOS == "linux" && runtime.GOARCH == "amd64" {
+		fmt.Println("\nTesting concurrent stack unwinding with the race detector")
+		execute("go", "test", testFlags(), buildFlags(), "-race", "-run=TestGoroutineStacktraces", "github.com/go-delve/delve/pkg/proc")
+	}
 	if inpath("lldb-server") && !goversion.VersionAfterOrEqual(runtime.Version(), 1, 14) {
 		fmt.Println("\nTesting LLDB backend")
 		testCmdIntl("basic", "", "lldb", "normal")
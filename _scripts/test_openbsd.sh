@@ -0,0 +1,52 @@
+#!/bin/sh
+
+set -x
+set -e
+
+# There are no binary distributions of Go for OpenBSD, the requested
+# version is built from source using the go package installed on the
+# agent (pkg_add go) to bootstrap it.
+
+GOVERSION=$1
+ARCH=$2
+TMPDIR=$3
+
+export GOROOT_BOOTSTRAP=$(/usr/local/bin/go env GOROOT)
+
+if [ "$GOVERSION" = "gotip" ]; then
+    if [ -x $TMPDIR/go-tip ]; then
+    	cd $TMPDIR/go-tip
+    	git pull origin
+    else
+    	git clone https://go.googlesource.com/go $TMPDIR/go-tip
+    fi
+    export GOROOT=$TMPDIR/go-tip
+else
+    echo Finding latest patch version for $GOVERSION
+    GOVERSION=$(python _scripts/latestver.py $GOVERSION)
+    echo Go $GOVERSION on $ARCH
+    rm -rf $TMPDIR/go
+    ftp -o - "https://go.dev/dl/$GOVERSION.src.tar.gz" | tar -C $TMPDIR -xz
+    export GOROOT=$TMPDIR/go
+fi
+
+cd $GOROOT/src
+./make.bash
+cd -
+
+mkdir -p $TMPDIR/gopath
+
+export GOPATH="$TMPDIR/gopath"
+export GOARCH="$ARCH"
+export PATH="$GOROOT/bin:$PATH"
+go version
+go env
+
+set +e
+make test
+x=$?
+if [ "$GOVERSION" = "gotip" ]; then
+	exit 0
+else
+	exit $x
+fi
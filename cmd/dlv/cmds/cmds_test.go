@@ -1,7 +1,16 @@
 package cmds
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/go-delve/delve/pkg/proc"
+	"github.com/go-delve/delve/service/api"
 )
 
 func TestParseRedirects(t *testing.T) {
@@ -57,3 +66,125 @@ func TestParseRedirects(t *testing.T) {
 		}
 	}
 }
+
+func TestDiffCoreSnapshots(t *testing.T) {
+	a := &coreSnapshot{
+		goroutines: map[string]int{"main.worker main.go:10 (started by main.worker)": 2, "main.main main.go:20 (started by main.main)": 1},
+		heap:       map[string]proc.HeapTypeUsage{"main.T": {Type: "main.T", Count: 2, Bytes: 32}, "main.U": {Type: "main.U", Count: 1, Bytes: 8}},
+		globals:    map[string]string{"main.counter": "1", "main.name": `"a"`},
+		exprs:      map[string]string{"len(main.cache)": "3"},
+	}
+	b := &coreSnapshot{
+		goroutines: map[string]int{"main.worker main.go:10 (started by main.worker)": 5, "main.main main.go:20 (started by main.main)": 1},
+		heap:       map[string]proc.HeapTypeUsage{"main.T": {Type: "main.T", Count: 10, Bytes: 160}, "main.U": {Type: "main.U", Count: 1, Bytes: 8}},
+		globals:    map[string]string{"main.counter": "7", "main.name": `"a"`},
+		exprs:      map[string]string{"len(main.cache)": "3"},
+	}
+	var buf strings.Builder
+	diffCoreSnapshots(&buf, a, b, 0)
+	const tgt = `Goroutines: 3 -> 6 (+3)
+	+3 main.worker main.go:10 (started by main.worker)
+Heap objects: 3 -> 11 (+8), 40 -> 168 bytes (+128)
+	+8 objects +128 bytes main.T
+Package variables:
+	main.counter: 1 -> 7
+`
+	if out := buf.String(); out != tgt {
+		t.Errorf("wrong output, expected:\n%s\ngot:\n%s", tgt, out)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		out int64
+	}{
+		{"1024", 1024},
+		{"10B", 10},
+		{"512K", 512 << 10},
+		{"100MB", 100 << 20},
+		{"100mb", 100 << 20},
+		{"2 GB", 2 << 30},
+	} {
+		n, err := parseByteSize(tc.in)
+		if err != nil {
+			t.Errorf("%q: %v", tc.in, err)
+			continue
+		}
+		if n != tc.out {
+			t.Errorf("%q: expected %d got %d", tc.in, tc.out, n)
+		}
+	}
+	for _, in := range []string{"", "MB", "-1MB", "0", "10XB"} {
+		if _, err := parseByteSize(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.log")
+	rf, err := newRotatingFile(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A line written in several pieces must not be split between files.
+	for _, s := range []string{"aaaa", "aaaa", "aaaa\n", "bbbb\n", "cccc\n", "dd", "dd\n"} {
+		if _, err := rf.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		path, contents string
+	}{
+		{path + ".1", "aaaaaaaaaaaa\n"},
+		{path + ".2", "bbbb\ncccc\n"},
+		{path, "dddd\n"},
+	} {
+		buf, err := os.ReadFile(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != tc.contents {
+			t.Errorf("%s: expected %q got %q", tc.path, tc.contents, buf)
+		}
+	}
+}
+
+func TestEBPFTracePrinter(t *testing.T) {
+	var buf bytes.Buffer
+	p := newEBPFTracePrinter(&buf)
+	start := time.Now()
+	for _, tp := range []api.TracepointResult{
+		{FunctionName: "main.outer", GoroutineID: 1, Time: start, InputParams: []api.Variable{{Kind: reflect.String, Value: "x"}}},
+		{FunctionName: "main.inner", GoroutineID: 1, Time: start.Add(time.Microsecond)},
+		{FunctionName: "main.inner", GoroutineID: 2, Time: start.Add(2 * time.Microsecond)},
+		{FunctionName: "main.inner", GoroutineID: 1, IsRet: true, Time: start.Add(4 * time.Microsecond), ReturnParams: []api.Variable{{Kind: reflect.Int, Value: "2"}}},
+		{FunctionName: "main.inner", GoroutineID: 2, IsRet: true, Time: start.Add(102 * time.Microsecond)},
+		{FunctionName: "main.outer", GoroutineID: 1, IsRet: true, Time: start.Add(10 * time.Microsecond)},
+	} {
+		tp := tp
+		p.print(&tp)
+	}
+	p.printLatencies()
+	out := buf.String()
+	t.Log(out)
+	for _, tgt := range []string{
+		"> (1) main.outer(\"x\")\n",
+		"< (1) main.inner => (2) 3µs\n",
+		"< (2) main.inner => () 100µs\n",
+		"< (1) main.outer => () 10µs\n",
+		"main.inner: 2 calls, min 3µs, p50 3µs, p90 3µs, p99 3µs, max 100µs\n",
+		"main.outer: 1 calls, min 10µs, p50 10µs, p90 10µs, p99 10µs, max 10µs\n",
+	} {
+		if !strings.Contains(out, tgt) {
+			t.Errorf("output does not contain %q", tgt)
+		}
+	}
+	if len(p.calls) != 0 {
+		t.Errorf("calls left on the stack: %v", p.calls)
+	}
+}
@@ -4,27 +4,30 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
-	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
 	"syscall"
 
 	"github.com/go-delve/delve/pkg/config"
+	"github.com/go-delve/delve/pkg/container"
 	"github.com/go-delve/delve/pkg/gobuild"
 	"github.com/go-delve/delve/pkg/goversion"
 	"github.com/go-delve/delve/pkg/logflags"
+	"github.com/go-delve/delve/pkg/pidof"
 	"github.com/go-delve/delve/pkg/terminal"
 	"github.com/go-delve/delve/pkg/version"
 	"github.com/go-delve/delve/service"
 	"github.com/go-delve/delve/service/api"
 	"github.com/go-delve/delve/service/dap"
 	"github.com/go-delve/delve/service/debugger"
+	"github.com/go-delve/delve/service/gdbstub"
 	"github.com/go-delve/delve/service/rpc2"
 	"github.com/go-delve/delve/service/rpccommon"
 	"github.com/mattn/go-isatty"
@@ -46,12 +49,19 @@ var (
 	apiVersion int
 	// acceptMulti allows multiple clients to connect to the same server
 	acceptMulti bool
+	// haltOnDisconnect halts the target when the connection of a client
+	// with a resumable session is lost.
+	haltOnDisconnect bool
 	// addr is the debugging server listen address.
 	addr string
 	// initFile is the path to initialization file.
 	initFile string
 	// buildFlags is the flags passed during compiler invocation.
 	buildFlags string
+	// buildCmd and buildOutput specify a command used instead of 'go build'
+	// and the path of the executable it produces, see gobuild.CustomBuild.
+	buildCmd    string
+	buildOutput string
 	// workingDir is the working directory for running the program.
 	workingDir string
 	// checkLocalConnUser is true if the debugger should check that local
@@ -59,14 +69,54 @@ var (
 	checkLocalConnUser bool
 	// tty is used to provide an alternate TTY for the program you wish to debug.
 	tty string
+	// allocatePTY allocates a pseudo-terminal for the program you wish to debug.
+	allocatePTY bool
 	// disableASLR is used to disable ASLR
 	disableASLR bool
+	// followExec, followExecRegex and followExecExclude configure
+	// following the children of the target process.
+	followExec        bool
+	followExecRegex   string
+	followExecExclude string
+	// nonStop enables non-stop mode.
+	nonStop bool
+	// detachOnExec detaches from the target when it executes a new program.
+	detachOnExec bool
+	// containerID is attach subcommand's flag that specifies the container
+	// of the process to attach to.
+	containerID string
+	// attachName and attachPort are attach subcommand's flags that specify
+	// the process to attach to by name or by the TCP port it listens on.
+	attachName string
+	attachPort int
+	// fuzzTarget and fuzzInput are test subcommand's flags that specify a
+	// fuzz target to debug and the corpus entry to run it with.
+	fuzzTarget string
+	fuzzInput  string
+	// watch is debug and test subcommands' flag that rebuilds and restarts
+	// the program when its source files change.
+	watch bool
+	// sourceRoot is the directory where the terminal client searches source
+	// files it can not find, see terminal.Term.SourceRoot.
+	sourceRoot string
+	// tlsConfig configures TLS and token authentication for the headless
+	// server and for the connect command.
+	tlsConfig service.TLSConfig
+	// allowedOrigins are the origins of the web pages allowed to connect to
+	// the headless server with WebSocket.
+	allowedOrigins []string
+	// connectTLS is connect subcommand's flag that enables TLS even if no
+	// certificate is specified.
+	connectTLS bool
 
 	// dapClientAddr is dap subcommand's flag that specifies the address of a DAP client.
 	// If it is specified, the dap server starts a debug session by dialing to the client.
 	// The dap server will serve only for the debug session.
 	dapClientAddr string
 
+	// gdbserveAttachPid is the pid of the process gdbserve attaches to.
+	gdbserveAttachPid int
+
 	// backend selection
 	backend string
 
@@ -83,6 +133,11 @@ var (
 	traceTestBinary bool
 	traceStackDepth int
 	traceUseEBPF    bool
+	traceNoAttach   bool
+	traceOutputFmt  string
+	traceOutFile    string
+	traceRotate     string
+	traceOTLP       string
 
 	// redirect specifications for target process
 	redirects []string
@@ -133,8 +188,9 @@ func New(docCall bool) *cobra.Command {
 	rootCommand.PersistentFlags().StringVarP(&logOutput, "log-output", "", "", `Comma separated list of components that should produce debug output (see 'dlv help log')`)
 	rootCommand.PersistentFlags().StringVarP(&logDest, "log-dest", "", "", "Writes logs to the specified file or file descriptor (see 'dlv help log').")
 
-	rootCommand.PersistentFlags().BoolVarP(&headless, "headless", "", false, "Run debug server only, in headless mode. Server will accept both JSON-RPC or DAP client connections.")
+	rootCommand.PersistentFlags().BoolVarP(&headless, "headless", "", false, "Run debug server only, in headless mode. Server will accept both JSON-RPC or DAP client connections, JSON-RPC also over WebSocket.")
 	rootCommand.PersistentFlags().BoolVarP(&acceptMulti, "accept-multiclient", "", false, "Allows a headless server to accept multiple client connections via JSON-RPC or DAP.")
+	rootCommand.PersistentFlags().BoolVarP(&haltOnDisconnect, "halt-on-disconnect", "", false, "With --accept-multiclient, halts the target if the connection of a client is lost while it is running, so that the client can reconnect and resume its session ('dlv connect' reconnects automatically).")
 	rootCommand.PersistentFlags().IntVar(&apiVersion, "api-version", 1, "Selects JSON-RPC API version when headless. New clients should use v2. Can be reset via RPCServer.SetApiVersion. See Documentation/api/json-rpc/README.md.")
 	rootCommand.PersistentFlags().StringVar(&initFile, "init", "", "Init file, executed by the terminal client.")
 	rootCommand.PersistentFlags().StringVar(&buildFlags, "build-flags", buildFlagsDefault, "Build flags, to be passed to the compiler. For example: --build-flags=\"-tags=integration -mod=vendor -cover -v\"")
@@ -145,6 +201,11 @@ func New(docCall bool) *cobra.Command {
 	rootCommand.PersistentFlags().StringArrayVarP(&redirects, "redirect", "r", []string{}, "Specifies redirect rules for target process (see 'dlv help redirect')")
 	rootCommand.PersistentFlags().BoolVar(&allowNonTerminalInteractive, "allow-non-terminal-interactive", false, "Allows interactive sessions of Delve that don't have a terminal as stdin, stdout and stderr")
 	rootCommand.PersistentFlags().BoolVar(&disableASLR, "disable-aslr", false, "Disables address space randomization")
+	rootCommand.PersistentFlags().BoolVar(&followExec, "follow-exec", false, "Attaches to the children of the target process that fork or execute a program (only linux, native backend). See the 'targets' command.")
+	rootCommand.PersistentFlags().StringVar(&followExecRegex, "follow-exec-regex", "", "With --follow-exec, only attaches to children executing a program whose path matches this regular expression, children that fork without executing a program are matched by the program of their parent.")
+	rootCommand.PersistentFlags().StringVar(&followExecExclude, "follow-exec-exclude", "", "With --follow-exec, does not attach to children executing a program whose path matches this regular expression.")
+	rootCommand.PersistentFlags().BoolVar(&detachOnExec, "detach-on-exec", false, "Detaches from the target process when it executes a new program, instead of debugging the new program (only linux, native backend).")
+	rootCommand.PersistentFlags().BoolVar(&nonStop, "non-stop", false, "Only stops the thread that hit a breakpoint, other threads keep running (only linux, native backend).")
 
 	// 'attach' subcommand.
 	attachCommand := &cobra.Command{
@@ -155,9 +216,40 @@ func New(docCall bool) *cobra.Command {
 This command will cause Delve to take control of an already running process, and
 begin a new debug session.  When exiting the debug session you will have the
 option to let the process continue or kill it.
+
+With --container the process is looked up in a container, by ID or name:
+
+	dlv attach --container <id> [pid] [executable]
+
+The optional pid is the pid of the process inside the PID namespace of the
+container, by default the init process of the container is used. The
+container is found by asking the container runtime (the Docker Engine API
+of docker or podman, on the socket specified by DOCKER_HOST or the default
+one) or, if it can not be reached, by searching the cgroups of the processes
+for the container ID. Delve must run on the host, or in a container sharing
+its PID namespace, with the privileges to trace the process.
+
+The file system of the container is accessed through /proc/<pid>/root:
+shared libraries, separate debug info and the executable path, if specified,
+are resolved in the container and source files that can not be found on the
+host are searched in it.
+
+Instead of a pid the process can be specified with --name, the name of its
+executable, or --port, a TCP port it is listening on:
+
+	dlv attach --name <name> [executable]
+	dlv attach --port <port> [executable]
+
+If more than one process matches the candidates are listed.
 `,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) == 0 {
+			if attachName != "" && attachPort != 0 {
+				return errors.New("--name and --port can not be used together")
+			}
+			if (attachName != "" || attachPort != 0) && containerID != "" {
+				return errors.New("--name and --port can not be used with --container")
+			}
+			if len(args) == 0 && containerID == "" && attachName == "" && attachPort == 0 {
 				return errors.New("you must provide a PID")
 			}
 			return nil
@@ -165,6 +257,11 @@ option to let the process continue or kill it.
 		Run: attachCmd,
 	}
 	attachCommand.Flags().BoolVar(&continueOnStart, "continue", false, "Continue the debugged process on start.")
+	attachCommand.Flags().StringVar(&containerID, "container", "", "ID or name of the container of the process to attach to.")
+	attachCommand.Flags().StringVar(&attachName, "name", "", "Name of the process to attach to.")
+	attachCommand.Flags().IntVar(&attachPort, "port", 0, "TCP port the process to attach to is listening on.")
+	addServerTLSFlags(attachCommand)
+	addAllowOriginFlag(attachCommand)
 	rootCommand.AddCommand(attachCommand)
 
 	// 'connect' subcommand.
@@ -180,6 +277,11 @@ option to let the process continue or kill it.
 		},
 		Run: connectCmd,
 	}
+	connectCommand.Flags().BoolVar(&connectTLS, "tls", false, "Connect to the server using TLS, verifying its certificate with the system roots unless --tls-ca is specified.")
+	connectCommand.Flags().StringVar(&tlsConfig.CertFile, "tls-cert", "", "Client certificate file (PEM), enables TLS.")
+	connectCommand.Flags().StringVar(&tlsConfig.KeyFile, "tls-key", "", "Private key file (PEM) of the certificate specified with --tls-cert.")
+	connectCommand.Flags().StringVar(&tlsConfig.CAFile, "tls-ca", "", "CA certificate file (PEM) used to verify the certificate of the server, enables TLS.")
+	connectCommand.Flags().StringVar(&tlsConfig.Token, "auth-token", "", "Token sent to the server to authenticate, enables TLS.")
 	rootCommand.AddCommand(connectCommand)
 
 	// 'dap' subcommand.
@@ -206,14 +308,51 @@ execution is resumed at the start of the debug session.
 
 The --client-addr flag is a special flag that makes the server initiate a debug session
 by dialing in to the host:port where a DAP client is waiting. This server process
-will exit when the debug session ends.`,
+will exit when the debug session ends.
+
+The --tls-cert and --tls-key flags make the server accept TLS connections. With --tls-ca
+clients must present a certificate signed by the given CA, with --auth-token they must send
+the header line 'Authorization: Bearer <token>' before the first DAP message.`,
 		Run: dapCmd,
 	}
 	dapCommand.Flags().StringVar(&dapClientAddr, "client-addr", "", "host:port where the DAP client is waiting for the DAP server to dial in")
 
 	// TODO(polina): support --tty when dlv dap allows to launch a program from command-line
+	addServerTLSFlags(dapCommand)
 	rootCommand.AddCommand(dapCommand)
 
+	// 'gdbserve' subcommand.
+	gdbserveCommand := &cobra.Command{
+		Use:   "gdbserve [<path/to/binary>]",
+		Short: "Starts a headless TCP server communicating via the GDB Remote Serial Protocol.",
+		Long: `Starts a headless TCP server communicating via the GDB Remote Serial Protocol.
+
+The server launches the specified precompiled binary, or attaches to the
+process specified with --pid, and lets gdb, lldb, IDA, Ghidra or any other
+debugger that can connect to a remote stub debug it through Delve:
+
+	dlv gdbserve --listen=127.0.0.1:2345 ./hello -- arg1 arg2
+	gdb -ex 'target remote 127.0.0.1:2345' ./hello
+
+Goroutine aware functionality is available through monitor commands, use
+'monitor help' from the client to list them.
+
+Only amd64 and arm64 targets are supported. The server exits when the client
+disconnects, unless --accept-multiclient is specified.`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && gdbserveAttachPid == 0 {
+				return errors.New("you must provide a path to a binary or a pid")
+			}
+			if len(args) > 0 && gdbserveAttachPid != 0 {
+				return errors.New("cannot specify both a binary and a pid")
+			}
+			return nil
+		},
+		Run: gdbserveCmd,
+	}
+	gdbserveCommand.Flags().IntVarP(&gdbserveAttachPid, "pid", "p", 0, "Pid to attach to.")
+	rootCommand.AddCommand(gdbserveCommand)
+
 	// 'debug' subcommand.
 	debugCommand := &cobra.Command{
 		Use:   "debug [package]",
@@ -223,12 +362,23 @@ will exit when the debug session ends.`,
 By default, with no arguments, Delve will compile the 'main' package in the
 current directory, and begin to debug it. Alternatively you can specify a
 package name and Delve will compile that package instead, and begin a new debug
-session.`,
+session.
+
+With --watch Delve monitors the source files of the program and, when they
+change, rebuilds it and restarts it, stopped at the entry point, with the same
+breakpoints. The terminal client, and any client subscribed to the events of
+the debugger, is notified of every reload. If the build fails the program
+keeps running.`,
 		Run: debugCmd,
 	}
 	debugCommand.Flags().String("output", "./__debug_bin", "Output path for the binary.")
+	addBuildCmdFlags(debugCommand)
 	debugCommand.Flags().BoolVar(&continueOnStart, "continue", false, "Continue the debugged process on start.")
 	debugCommand.Flags().StringVar(&tty, "tty", "", "TTY to use for the target program")
+	debugCommand.Flags().BoolVar(&allocatePTY, "pty", false, "Allocate a pseudo-terminal for the target program, see 'dlv help redirect'.")
+	debugCommand.Flags().BoolVar(&watch, "watch", false, "Rebuild and restart the program when its source files change.")
+	addServerTLSFlags(debugCommand)
+	addAllowOriginFlag(debugCommand)
 	rootCommand.AddCommand(debugCommand)
 
 	// 'exec' subcommand.
@@ -253,7 +403,10 @@ or later, -gcflags="-N -l" on earlier versions of Go.`,
 		},
 	}
 	execCommand.Flags().StringVar(&tty, "tty", "", "TTY to use for the target program")
+	execCommand.Flags().BoolVar(&allocatePTY, "pty", false, "Allocate a pseudo-terminal for the target program, see 'dlv help redirect'.")
 	execCommand.Flags().BoolVar(&continueOnStart, "continue", false, "Continue the debugged process on start.")
+	addServerTLSFlags(execCommand)
+	addAllowOriginFlag(execCommand)
 	rootCommand.AddCommand(execCommand)
 
 	// Deprecated 'run' subcommand.
@@ -280,10 +433,24 @@ that package instead. Double-dashes ` + "`--`" + ` can be used to pass arguments
 
 dlv test [package] -- -test.v -other-argument
 
+With --fuzz the test binary only runs the seed corpus of a fuzz target and
+stops at the body of the target (the function passed to (*testing.F).Fuzz).
+A crashing input of a fuzzing run, for example downloaded from CI, can be
+replayed with --fuzz-input, it is copied in the seed corpus of the target
+(testdata/fuzz/<target>) for the duration of the session if it isn't there:
+
+dlv test [package] --fuzz FuzzParse --fuzz-input path/to/corpus/entry
+
 See also: 'go help testflag'.`,
 		Run: testCmd,
 	}
 	testCommand.Flags().String("output", "debug.test", "Output path for the binary.")
+	addBuildCmdFlags(testCommand)
+	testCommand.Flags().StringVar(&fuzzTarget, "fuzz", "", "Fuzz target to debug: runs its seed corpus, or only the entry specified by --fuzz-input, and stops at the body of the fuzz target.")
+	testCommand.Flags().StringVar(&fuzzInput, "fuzz-input", "", "Corpus entry (for example a file of testdata/fuzz/<target> produced by a failed fuzzing run) to run with the fuzz target specified by --fuzz.")
+	testCommand.Flags().BoolVar(&watch, "watch", false, "Rebuild and restart the test binary when its source files change (see 'dlv help debug').")
+	addServerTLSFlags(testCommand)
+	addAllowOriginFlag(testCommand)
 	rootCommand.AddCommand(testCommand)
 
 	// 'trace' subcommand.
@@ -298,15 +465,34 @@ is useful if you do not want to begin an entire debug session, but merely want
 to know what functions your process is executing.
 
 The output of the trace sub command is printed to stderr, so if you would like to
-only see the output of the trace operations you can redirect stdout.`,
+only see the output of the trace operations you can redirect stdout. Use
+--output-file to write it to a file instead, optionally rotated with --rotate.`,
 		Run: traceCmd,
 	}
 	traceCommand.Flags().IntVarP(&traceAttachPid, "pid", "p", 0, "Pid to attach to.")
 	traceCommand.Flags().StringVarP(&traceExecFile, "exec", "e", "", "Binary file to exec and trace.")
 	traceCommand.Flags().BoolVarP(&traceTestBinary, "test", "t", false, "Trace a test binary.")
-	traceCommand.Flags().BoolVarP(&traceUseEBPF, "ebpf", "", false, "Trace using eBPF (experimental).")
+	traceCommand.Flags().BoolVarP(&traceUseEBPF, "ebpf", "", false, "Trace using eBPF (experimental). The latency of each call is printed with its return values and a latency histogram of each traced function is printed when tracing ends.")
+	traceCommand.Flags().BoolVarP(&traceNoAttach, "no-attach", "", false, `Trace the process specified by --pid without attaching to it, only uprobes
+are used and the process is never stopped. Requires --ebpf, tracing stops when
+the process exits or when dlv is interrupted.`)
 	traceCommand.Flags().IntVarP(&traceStackDepth, "stack", "s", 0, "Show stack trace with given depth. (Ignored with -ebpf)")
 	traceCommand.Flags().String("output", "debug", "Output path for the binary.")
+	addBuildCmdFlags(traceCommand)
+	traceCommand.Flags().StringVarP(&traceOutputFmt, "output-format", "", terminal.TraceOutputText, `Format of the trace output, one of 'text', 'json', 'csv' or 'chrometrace'.
+The json (one object per line) and csv formats record the time, goroutine,
+arguments and return values of each call and return, return records also
+include the duration of the call. The chrometrace format writes the file
+specified by --output-file in the Chrome trace event format, where each call is
+an event on the track of its goroutine, and can be opened with Perfetto or
+chrome://tracing. (Ignored with -ebpf)`)
+	traceCommand.Flags().StringVarP(&traceOutFile, "output-file", "", "", "Write the trace output to the given file instead of stderr.")
+	traceCommand.Flags().StringVarP(&traceRotate, "rotate", "", "", `Rotate the file specified by --output-file when it grows beyond the given
+size (for example 100MB), the previous contents are moved to <file>.1, <file>.2, ...`)
+	traceCommand.Flags().StringVarP(&traceOTLP, "otlp-endpoint", "", "", `Export the traced calls as OpenTelemetry spans to the collector at the
+given OTLP/HTTP endpoint, for example http://localhost:4318. Calls traced on the
+same goroutine while another traced call is executing are exported as its
+children. (Ignored with -ebpf)`)
 	rootCommand.AddCommand(traceCommand)
 
 	coreCommand := &cobra.Command{
@@ -327,8 +513,51 @@ Currently supports linux/amd64 and linux/arm64 core files, windows/amd64 minidum
 		},
 		Run: coreCmd,
 	}
+	addServerTLSFlags(coreCommand)
+	addAllowOriginFlag(coreCommand)
 	rootCommand.AddCommand(coreCommand)
 
+	coreDiffCommand := &cobra.Command{
+		Use:   "core-diff <core1> <core2> <executable>",
+		Short: "Compare two core dumps of the same executable.",
+		Long: `Compare two core dumps of the same executable.
+
+The core-diff command opens both core files and reports what changed
+between the first and the second one:
+
+	- the number of goroutines, grouped by the location they are stopped
+	  at and the function they started from
+	- the number of live heap objects of each type and the memory they use
+	- the value of package variables
+	- the value of the expressions specified with --expr
+
+Heap objects are counted by type like the 'objects' command of the
+terminal client does: the type of objects only reachable through
+unsafe.Pointer values, maps or channels is unknown and they are not
+counted.
+
+By default all package variables except the ones of the runtime are
+compared, use --globals to compare only the package variables whose name
+matches a regular expression.
+
+For example, to look for a slow leak take two core dumps some time apart
+and run:
+
+	dlv core-diff first.core second.core ./myprogram --expr 'len(main.cache)'
+`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 3 {
+				return errors.New("you must provide two core files and an executable")
+			}
+			return nil
+		},
+		Run: coreDiffCmd,
+	}
+	coreDiffCommand.Flags().StringArrayVar(&coreDiffExprs, "expr", nil, "Expression to evaluate in both core files, can be specified multiple times.")
+	coreDiffCommand.Flags().StringVar(&coreDiffGlobals, "globals", "", "Only compare package variables whose name matches this regular expression.")
+	coreDiffCommand.Flags().IntVar(&coreDiffTop, "top", 20, "Maximum number of goroutine groups and heap types to report, 0 reports all of them.")
+	rootCommand.AddCommand(coreDiffCommand)
+
 	// 'version' subcommand.
 	var versionVerbose = false
 	versionCommand := &cobra.Command{
@@ -364,6 +593,8 @@ https://github.com/mozilla/rr
 				os.Exit(execute(0, []string{}, conf, args[0], debugger.ExecutingOther, args, buildFlags))
 			},
 		}
+		addServerTLSFlags(replayCommand)
+		addAllowOriginFlag(replayCommand)
 		rootCommand.AddCommand(replayCommand)
 	}
 
@@ -377,6 +608,11 @@ are:
 	native		Native backend.
 	lldb		Uses lldb-server or debugserver.
 	rr		Uses mozilla rr (https://github.com/mozilla/rr).
+	qemu		Runs the target under qemu-user, the target can be compiled for
+			an architecture different from the one of the host
+			(amd64, arm64 and riscv64 are supported).
+	qemu:<addr>	Connects to the gdbstub of a qemu-user instance started
+			with -g, listening at <addr>, that is running the target.
 
 `})
 
@@ -391,7 +627,7 @@ names selected from this list:
 
 
 	debugger	Log debugger commands
-	gdbwire		Log connection to gdbserial backend
+	gdbwire		Log connection to gdbserial backend and gdbserve clients
 	lldbout		Copy output from debugserver/lldb to standard output
 	debuglineerr	Log recoverable errors reading .debug_line
 	rpc		Log all RPC messages
@@ -416,6 +652,8 @@ and dap modes.
 
 The --tty argument allows redirecting all standard descriptors to a terminal, specified as an argument to --tty.
 
+The --pty argument allocates a pseudo-terminal and redirects all standard descriptors to it, for programs that expect to run in a terminal. The output of the program is shown by the terminal client, also when connecting to a headless instance, and the size of the pseudo-terminal follows the size of the client's terminal. Clients can write to the input of the program with the WriteTTY API call.
+
 The syntax for '-r' argument is:
 
 		-r [source:]destination
@@ -486,13 +724,16 @@ func dapCmd(cmd *cobra.Command, args []string) {
 		}
 		var conn net.Conn
 		if dapClientAddr == "" {
-			listener, err := net.Listen("tcp", addr)
+			listener, err := listen(addr)
 			if err != nil {
 				fmt.Printf("couldn't start listener: %s\n", err)
 				return 1
 			}
 			config.Listener = listener
 		} else { // with a predetermined client.
+			if tlsEnabled() {
+				fmt.Fprintf(os.Stderr, "Warning: TLS and authentication flags ignored with --client-addr\n")
+			}
 			var err error
 			conn, err = net.Dial("tcp", dapClientAddr)
 			if err != nil {
@@ -521,7 +762,9 @@ func buildBinary(cmd *cobra.Command, args []string, isTest bool) (string, bool)
 		return "", false
 	}
 
-	if isTest {
+	if buildCmd != "" {
+		err = gobuild.CustomBuild(debugname, args, buildCmd, buildOutput)
+	} else if isTest {
 		err = gobuild.GoTestBuild(debugname, args, buildFlags)
 	} else {
 		err = gobuild.GoBuild(debugname, args, buildFlags)
@@ -566,6 +809,33 @@ func traceCmd(cmd *cobra.Command, args []string) {
 			fmt.Fprintf(os.Stderr, "Warning: accept multiclient mode not supported with trace")
 		}
 
+		if traceOutputFmt == terminal.TraceOutputChromeTrace && (traceOutFile == "" || traceRotate != "") {
+			fmt.Fprintln(os.Stderr, "--output-format chrometrace requires --output-file and can not be used with --rotate")
+			return 1
+		}
+
+		var traceOut io.Writer = os.Stderr
+		if traceOutFile != "" {
+			var maxSize int64
+			if traceRotate != "" {
+				maxSize, err = parseByteSize(traceRotate)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "--rotate: %v\n", err)
+					return 1
+				}
+			}
+			rf, err := newRotatingFile(traceOutFile, maxSize)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			defer rf.Close()
+			traceOut = rf
+		} else if traceRotate != "" {
+			fmt.Fprintln(os.Stderr, "--rotate requires --output-file")
+			return 1
+		}
+
 		var regexp string
 		var processArgs []string
 
@@ -583,6 +853,14 @@ func traceCmd(cmd *cobra.Command, args []string) {
 			dlvArgs = dlvArgs[:dlvArgsLen-1]
 		}
 
+		if traceNoAttach {
+			if !traceUseEBPF || traceAttachPid == 0 {
+				fmt.Fprintln(os.Stderr, "--no-attach requires --ebpf and --pid")
+				return 1
+			}
+			return traceUProbes(traceAttachPid, regexp, traceOut)
+		}
+
 		var debugname string
 		if traceAttachPid == 0 {
 			if dlvArgsLen >= 2 && traceExecFile != "" {
@@ -671,13 +949,47 @@ func traceCmd(cmd *cobra.Command, args []string) {
 		}
 		cmds := terminal.DebugCommands(client)
 		t := terminal.New(client, nil)
-		t.RedirectTo(os.Stderr)
+		var structuredOut io.Writer
+		if traceOutputFmt == terminal.TraceOutputChromeTrace {
+			// The trace file must only contain the events, the rest of the
+			// output of the terminal goes to stderr.
+			structuredOut = traceOut
+			t.RedirectTo(os.Stderr)
+		} else {
+			t.RedirectTo(traceOut)
+		}
 		defer t.Close()
+		if err := t.SetTraceOutputFormat(traceOutputFmt, structuredOut); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if traceOTLP != "" {
+			serviceName := ""
+			if debugname != "" {
+				serviceName = filepath.Base(debugname)
+			}
+			if err := t.SetTraceExporter(traceOTLP, serviceName); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+		}
 		if traceUseEBPF {
+			printer := newEBPFTracePrinter(traceOut)
 			done := make(chan struct{})
-			defer close(done)
+			stopped := make(chan struct{})
+			defer func() {
+				close(done)
+				<-stopped
+				// Print the events received after the target stopped.
+				if tracepoints, err := client.GetBufferedTracepoints(); err == nil {
+					for i := range tracepoints {
+						printer.print(&tracepoints[i])
+					}
+				}
+				printer.printLatencies()
+			}()
 			go func() {
-				gFnEntrySeen := map[int]struct{}{}
+				defer close(stopped)
 				for {
 					select {
 					case <-done:
@@ -687,28 +999,8 @@ func traceCmd(cmd *cobra.Command, args []string) {
 						if err != nil {
 							panic(err)
 						}
-						for _, t := range tracepoints {
-							var params strings.Builder
-							for _, p := range t.InputParams {
-								if params.Len() > 0 {
-									params.WriteString(", ")
-								}
-								if p.Kind == reflect.String {
-									params.WriteString(fmt.Sprintf("%q", p.Value))
-								} else {
-									params.WriteString(p.Value)
-								}
-							}
-							_, seen := gFnEntrySeen[t.GoroutineID]
-							if seen {
-								for _, p := range t.ReturnParams {
-									fmt.Fprintf(os.Stderr, "=> %#v\n", p.Value)
-								}
-								delete(gFnEntrySeen, t.GoroutineID)
-							} else {
-								gFnEntrySeen[t.GoroutineID] = struct{}{}
-								fmt.Fprintf(os.Stderr, "> (%d) %s(%s)\n", t.GoroutineID, t.FunctionName, params.String())
-							}
+						for i := range tracepoints {
+							printer.print(&tracepoints[i])
 						}
 					}
 				}
@@ -742,6 +1034,25 @@ func testCmd(cmd *cobra.Command, args []string) {
 			}
 		}
 
+		if fuzzInput != "" && fuzzTarget == "" {
+			fmt.Fprintln(os.Stderr, "--fuzz-input requires --fuzz")
+			return 1
+		}
+		if fuzzTarget != "" {
+			entry := ""
+			if fuzzInput != "" {
+				var cleanup func()
+				var err error
+				entry, cleanup, err = seedFuzzInput(workingDir)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					return 1
+				}
+				defer cleanup()
+			}
+			processArgs = append(processArgs, fuzzRunFlag(entry))
+		}
+
 		return execute(0, processArgs, conf, "", debugger.ExecutingGeneratedTest, dlvArgs, buildFlags)
 	}()
 	os.Exit(status)
@@ -763,7 +1074,89 @@ func getPackageDir(pkg string) string {
 	return listout.Dir
 }
 
+func gdbserveCmd(cmd *cobra.Command, args []string) {
+	status := func() int {
+		if err := logflags.Setup(log, logOutput, logDest); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		defer logflags.Close()
+
+		if loadConfErr != nil {
+			logflags.DebuggerLogger().Errorf("%v", loadConfErr)
+		}
+
+		if cmd.Flag("headless").Changed {
+			fmt.Fprintf(os.Stderr, "Warning: gdbserve mode is always headless\n")
+		}
+
+		redirects, err := parseRedirects(redirects)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+
+		listener, err := listen(addr)
+		if err != nil {
+			fmt.Printf("couldn't start listener: %s\n", err)
+			return 1
+		}
+		defer listener.Close()
+
+		if workingDir == "" {
+			workingDir = "."
+		}
+
+		kind := debugger.ExecutingExistingFile
+		if gdbserveAttachPid != 0 {
+			kind = debugger.ExecutingOther
+		}
+
+		disconnectChan := make(chan struct{})
+		server := gdbstub.NewServer(&service.Config{
+			Listener:           listener,
+			ProcessArgs:        args,
+			AcceptMulti:        acceptMulti,
+			CheckLocalConnUser: checkLocalConnUser,
+			DisconnectChan:     disconnectChan,
+			Debugger: debugger.Config{
+				AttachPid:            gdbserveAttachPid,
+				WorkingDir:           workingDir,
+				Backend:              backend,
+				Foreground:           true, // server always runs without terminal client
+				ExecuteKind:          kind,
+				DebugInfoDirectories: conf.DebugInfoDirectories,
+				CheckGoVersion:       checkGoVersion,
+				Redirects:            redirects,
+				DisableASLR:          disableASLR,
+			},
+		})
+
+		if err := server.Run(); err != nil {
+			if err == api.ErrNotExecutable {
+				fmt.Fprintf(os.Stderr, "%s is not executable\n", args[0])
+				return 1
+			}
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		waitForDisconnectSignal(disconnectChan)
+		if err := server.Stop(); err != nil {
+			fmt.Println(err)
+		}
+		return 0
+	}()
+	os.Exit(status)
+}
+
 func attachCmd(cmd *cobra.Command, args []string) {
+	if containerID != "" {
+		os.Exit(attachContainer(args))
+	}
+	if attachName != "" || attachPort != 0 {
+		os.Exit(attachFind(args))
+	}
 	pid, err := strconv.Atoi(args[0])
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Invalid pid: %s\n", args[0])
@@ -772,6 +1165,48 @@ func attachCmd(cmd *cobra.Command, args []string) {
 	os.Exit(execute(pid, args[1:], conf, "", debugger.ExecutingOther, args, buildFlags))
 }
 
+// attachContainer attaches to a process of the container specified by
+// --container, args are the optional pid of the process in the container
+// and the path of its executable in the container.
+func attachContainer(args []string) int {
+	processArgs := args
+	nsPid := 0
+	if len(processArgs) > 0 {
+		if n, err := strconv.Atoi(processArgs[0]); err == nil {
+			nsPid = n
+			processArgs = processArgs[1:]
+		}
+	}
+	pid, err := container.Pid(containerID, nsPid)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	root := container.Root(pid)
+	if len(processArgs) > 0 {
+		processArgs = append([]string{filepath.Join(root, processArgs[0])}, processArgs[1:]...)
+	}
+	sourceRoot = root
+	return execute(pid, processArgs, conf, "", debugger.ExecutingOther, args, buildFlags)
+}
+
+// attachFind attaches to the process specified by --name or --port, args
+// are the optional path of its executable.
+func attachFind(args []string) int {
+	var pid int
+	var err error
+	if attachName != "" {
+		pid, err = pidof.Name(attachName)
+	} else {
+		pid, err = pidof.Port(attachPort)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return execute(pid, args, conf, "", debugger.ExecutingOther, args, buildFlags)
+}
+
 func coreCmd(cmd *cobra.Command, args []string) {
 	os.Exit(execute(0, []string{args[0]}, conf, args[1], debugger.ExecutingOther, args, buildFlags))
 }
@@ -820,6 +1255,49 @@ func waitForDisconnectSignal(disconnectChan chan struct{}) {
 	}
 }
 
+// addBuildCmdFlags adds the flags that replace 'go build' with a custom
+// build command to cmd.
+func addBuildCmdFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&buildCmd, "build-cmd", "", "Command used to build the program instead of 'go build', --build-flags is ignored. It can refer to {{.Output}}, {{.Package}} and {{.Packages}}, for example: --build-cmd=\"bazel build //cmd/app --config=dbg\". See also --build-output.")
+	cmd.Flags().StringVar(&buildOutput, "build-output", "", "Path of the executable produced by --build-cmd, it can refer to the same fields. For example: --build-output=bazel-bin/cmd/app/app_/app. Defaults to {{.Output}}, the path specified by --output.")
+}
+
+// addAllowOriginFlag adds the flag that allows web pages to connect to the
+// headless server with WebSocket to cmd.
+func addAllowOriginFlag(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&allowedOrigins, "allow-origin", []string{}, "Origin (scheme://host:port) of a web page allowed to connect to the headless server with WebSocket, '*' allows any origin. Can be specified multiple times.")
+}
+
+// addServerTLSFlags adds the flags that configure TLS and token
+// authentication of the headless server to cmd.
+func addServerTLSFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&tlsConfig.CertFile, "tls-cert", "", "Certificate file (PEM) of the headless server, enables TLS.")
+	cmd.Flags().StringVar(&tlsConfig.KeyFile, "tls-key", "", "Private key file (PEM) of the certificate specified with --tls-cert.")
+	cmd.Flags().StringVar(&tlsConfig.CAFile, "tls-ca", "", "CA certificate file (PEM) used by the headless server to require and verify client certificates.")
+	cmd.Flags().StringVar(&tlsConfig.Token, "auth-token", "", "Token that clients of the headless server must send to authenticate, requires TLS.")
+}
+
+// tlsEnabled returns true if any of the TLS or authentication flags was
+// specified.
+func tlsEnabled() bool {
+	return tlsConfig.CertFile != "" || tlsConfig.KeyFile != "" || tlsConfig.CAFile != "" || tlsConfig.Token != ""
+}
+
+// listen returns the listener of the headless server, accepting TLS
+// connections if TLS is enabled.
+func listen(addr string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil || !tlsEnabled() {
+		return listener, err
+	}
+	tlsListener, err := service.NewTLSListener(listener, &tlsConfig)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return tlsListener, nil
+}
+
 func splitArgs(cmd *cobra.Command, args []string) ([]string, []string) {
 	if cmd.ArgsLenAtDash() >= 0 {
 		return args[:cmd.ArgsLenAtDash()], args[cmd.ArgsLenAtDash():]
@@ -830,9 +1308,24 @@ func splitArgs(cmd *cobra.Command, args []string) ([]string, []string) {
 func connect(addr string, clientConn net.Conn, conf *config.Config, kind debugger.ExecuteKind) int {
 	// Create and start a terminal - attach to running instance
 	var client *rpc2.RPCClient
-	if clientConn != nil {
+	var redial func() (net.Conn, error)
+	switch {
+	case clientConn != nil:
 		client = rpc2.NewClientFromConn(clientConn)
-	} else {
+	case connectTLS || tlsEnabled():
+		redial = func() (net.Conn, error) {
+			return service.DialTLS(addr, &tlsConfig)
+		}
+		conn, err := redial()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not connect: %v\n", err)
+			return 1
+		}
+		client = rpc2.NewClientFromConn(conn)
+	default:
+		redial = func() (net.Conn, error) {
+			return net.Dial("tcp", addr)
+		}
 		client = rpc2.NewClient(addr)
 	}
 	if client.IsMulticlient() {
@@ -851,6 +1344,15 @@ func connect(addr string, clientConn net.Conn, conf *config.Config, kind debugge
 	}
 	term := terminal.New(client, conf)
 	term.InitFile = initFile
+	term.SourceRoot = sourceRoot
+	term.Redial = redial
+	if fuzzTarget != "" {
+		if err := createFuzzBreakpoint(client); err != nil {
+			fmt.Fprintf(os.Stderr, "could not set breakpoint on fuzz target: %v\n", err)
+		} else {
+			term.InitCommands = []string{"continue"}
+		}
+	}
 	status, err := term.Run()
 	if err != nil {
 		fmt.Println(err)
@@ -880,6 +1382,20 @@ func execute(attachPid int, processArgs []string, conf *config.Config, coreFile
 			fmt.Fprint(os.Stderr, "Error: --continue requires --accept-multiclient\n")
 			return 1
 		}
+		if tlsEnabled() {
+			fmt.Fprint(os.Stderr, "Error: --continue can not be used with TLS\n")
+			return 1
+		}
+	}
+
+	if haltOnDisconnect && (!headless || !acceptMulti) {
+		fmt.Fprint(os.Stderr, "Error: --halt-on-disconnect requires --headless and --accept-multiclient\n")
+		return 1
+	}
+
+	if fuzzTarget != "" && headless && (!acceptMulti || tlsEnabled()) {
+		fmt.Fprint(os.Stderr, "Error: --fuzz with --headless requires --accept-multiclient and can not be used with TLS\n")
+		return 1
 	}
 
 	if !headless && acceptMulti {
@@ -909,6 +1425,11 @@ func execute(attachPid int, processArgs []string, conf *config.Config, coreFile
 		return 1
 	}
 
+	if allocatePTY && (tty != "" || len(redirects) > 0) {
+		fmt.Fprintf(os.Stderr, "Can not use --pty with -r or --tty\n")
+		return 1
+	}
+
 	redirects, err := parseRedirects(redirects)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
@@ -920,7 +1441,7 @@ func execute(attachPid int, processArgs []string, conf *config.Config, coreFile
 
 	// Make a TCP listener
 	if headless {
-		listener, err = net.Listen("tcp", addr)
+		listener, err = listen(addr)
 	} else {
 		listener, clientConn = service.ListenerPipe()
 	}
@@ -947,21 +1468,32 @@ func execute(attachPid int, processArgs []string, conf *config.Config, coreFile
 			AcceptMulti:        acceptMulti,
 			APIVersion:         apiVersion,
 			CheckLocalConnUser: checkLocalConnUser,
+			AllowedOrigins:     allowedOrigins,
 			DisconnectChan:     disconnectChan,
+			HaltOnDisconnect:   haltOnDisconnect,
 			Debugger: debugger.Config{
 				AttachPid:            attachPid,
 				WorkingDir:           workingDir,
 				Backend:              backend,
 				CoreFile:             coreFile,
-				Foreground:           headless && tty == "",
+				Foreground:           headless && tty == "" && !allocatePTY,
 				Packages:             dlvArgs,
 				BuildFlags:           buildFlags,
+				BuildCommand:         buildCmd,
+				BuildOutput:          buildOutput,
 				ExecuteKind:          kind,
 				DebugInfoDirectories: conf.DebugInfoDirectories,
 				CheckGoVersion:       checkGoVersion,
 				TTY:                  tty,
+				PTY:                  allocatePTY,
 				Redirects:            redirects,
 				DisableASLR:          disableASLR,
+				FollowExec:           followExec,
+				FollowExecRegex:      followExecRegex,
+				FollowExecExclude:    followExecExclude,
+				NonStop:              nonStop,
+				DetachOnExec:         detachOnExec,
+				Watch:                watch,
 			},
 		})
 	default:
@@ -988,9 +1520,14 @@ func execute(attachPid int, processArgs []string, conf *config.Config, coreFile
 
 	var status int
 	if headless {
-		if continueOnStart {
+		if continueOnStart || fuzzTarget != "" {
 			client := rpc2.NewClient(listener.Addr().String())
-			client.Disconnect(true) // true = continue after disconnect
+			if fuzzTarget != "" {
+				if err := createFuzzBreakpoint(client); err != nil {
+					fmt.Fprintf(os.Stderr, "could not set breakpoint on fuzz target: %v\n", err)
+				}
+			}
+			client.Disconnect(continueOnStart) // true = continue after disconnect
 		}
 		waitForDisconnectSignal(disconnectChan)
 		err = server.Stop()
@@ -0,0 +1,303 @@
+package cmds
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-delve/delve/pkg/logflags"
+	"github.com/go-delve/delve/pkg/proc"
+	"github.com/go-delve/delve/pkg/proc/core"
+	"github.com/go-delve/delve/service/api"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// coreDiffExprs, coreDiffGlobals and coreDiffTop are core-diff
+	// subcommand's flags that specify the expressions to compare, the
+	// package variables to compare and the maximum number of goroutine
+	// groups and heap types to report.
+	coreDiffExprs   []string
+	coreDiffGlobals string
+	coreDiffTop     int
+)
+
+// coreDiffLoadConfig is the configuration used to load the package
+// variables and the expressions compared by core-diff.
+var coreDiffLoadConfig = proc.LoadConfig{
+	FollowPointers:     true,
+	MaxVariableRecurse: 1,
+	MaxStringLen:       64,
+	MaxArrayValues:     64,
+	MaxStructFields:    -1,
+}
+
+// coreSnapshot is the state of the process saved in a core file, as
+// compared by core-diff.
+type coreSnapshot struct {
+	goroutines map[string]int                // number of goroutines of each group, see goroutineGroupKey
+	heap       map[string]proc.HeapTypeUsage // heap usage by type, nil if the heap could not be read
+	globals    map[string]string             // value of package variables
+	exprs      map[string]string             // value of the expressions specified with --expr
+}
+
+func coreDiffCmd(cmd *cobra.Command, args []string) {
+	os.Exit(func() int {
+		err := logflags.Setup(log, logOutput, logDest)
+		defer logflags.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		if loadConfErr != nil {
+			logflags.DebuggerLogger().Errorf("%v", loadConfErr)
+		}
+
+		var globalsRx *regexp.Regexp
+		if coreDiffGlobals != "" {
+			globalsRx, err = regexp.Compile(coreDiffGlobals)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid --globals regular expression: %v\n", err)
+				return 1
+			}
+		}
+
+		var snaps [2]*coreSnapshot
+		for i, corePath := range args[:2] {
+			snaps[i], err = loadCoreSnapshot(corePath, args[2], conf.DebugInfoDirectories, globalsRx, coreDiffExprs)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "could not open %s: %v\n", corePath, err)
+				return 1
+			}
+		}
+		diffCoreSnapshots(os.Stdout, snaps[0], snaps[1], coreDiffTop)
+		return 0
+	}())
+}
+
+// loadCoreSnapshot opens the core file at corePath, of the executable at
+// exePath, and collects the state compared by core-diff. Package variables
+// are only collected if their name matches globalsRx or, if globalsRx is
+// nil, if they do not belong to the runtime.
+func loadCoreSnapshot(corePath, exePath string, debugInfoDirs []string, globalsRx *regexp.Regexp, exprs []string) (*coreSnapshot, error) {
+	t, err := core.OpenCore(corePath, exePath, debugInfoDirs)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Detach(false)
+
+	snap := &coreSnapshot{
+		goroutines: make(map[string]int),
+		globals:    make(map[string]string),
+		exprs:      make(map[string]string),
+	}
+
+	gs, _, err := proc.GoroutinesInfo(t, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range gs {
+		snap.goroutines[goroutineGroupKey(t, g)]++
+	}
+
+	if usage, err := t.HeapUsage(); err != nil {
+		fmt.Fprintf(os.Stderr, "could not read the heap of %s: %v\n", corePath, err)
+	} else {
+		snap.heap = make(map[string]proc.HeapTypeUsage, len(usage))
+		for _, u := range usage {
+			snap.heap[u.Type] = u
+		}
+	}
+
+	scope, err := proc.GoroutineScope(t, t.CurrentThread())
+	if err != nil {
+		// package variables can be evaluated without a goroutine
+		scope, err = proc.ThreadScope(t, t.CurrentThread())
+		if err != nil {
+			return nil, err
+		}
+	}
+	vars, err := scope.PackageVariables(coreDiffLoadConfig)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vars {
+		if globalsRx != nil && !globalsRx.MatchString(v.Name) {
+			continue
+		}
+		if globalsRx == nil && isRuntimeVariable(v.Name) {
+			continue
+		}
+		snap.globals[v.Name] = api.ConvertVar(v).SinglelineString()
+	}
+
+	for _, expr := range exprs {
+		v, err := scope.EvalExpression(expr, coreDiffLoadConfig)
+		if err != nil {
+			snap.exprs[expr] = fmt.Sprintf("error: %v", err)
+			continue
+		}
+		snap.exprs[expr] = api.ConvertVar(v).SinglelineString()
+	}
+	return snap, nil
+}
+
+// goroutineGroupKey describes where goroutine g is, by the user location
+// it is stopped at and the function it started from, goroutines with the
+// same key are counted together.
+func goroutineGroupKey(t *proc.Target, g *proc.G) string {
+	locString := func(loc proc.Location) string {
+		fn := "?"
+		if loc.Fn != nil {
+			fn = loc.Fn.Name
+		}
+		if loc.File == "" {
+			return fmt.Sprintf("%s %#x", fn, loc.PC)
+		}
+		return fmt.Sprintf("%s %s:%d", fn, loc.File, loc.Line)
+	}
+	start := g.StartLoc(t)
+	startFn := "?"
+	if start.Fn != nil {
+		startFn = start.Fn.Name
+	}
+	return fmt.Sprintf("%s (started by %s)", locString(g.UserCurrent()), startFn)
+}
+
+func isRuntimeVariable(name string) bool {
+	return strings.HasPrefix(name, "runtime.") || strings.HasPrefix(name, "runtime/") || strings.HasPrefix(name, "internal/")
+}
+
+// diffCoreSnapshots writes to w the differences between a and b. At most
+// top goroutine groups and heap types are reported, the ones that changed
+// the most, all of them if top is not greater than zero.
+func diffCoreSnapshots(w io.Writer, a, b *coreSnapshot, top int) {
+	total := func(m map[string]int) int {
+		n := 0
+		for _, cnt := range m {
+			n += cnt
+		}
+		return n
+	}
+	ga, gb := total(a.goroutines), total(b.goroutines)
+	fmt.Fprintf(w, "Goroutines: %d -> %d (%+d)\n", ga, gb, gb-ga)
+	type groupDelta struct {
+		key   string
+		delta int
+	}
+	groups := []groupDelta{}
+	for _, key := range unionKeys(a.goroutines, b.goroutines) {
+		if d := b.goroutines[key] - a.goroutines[key]; d != 0 {
+			groups = append(groups, groupDelta{key, d})
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return abs(groups[i].delta) > abs(groups[j].delta) })
+	for i, g := range groups {
+		if top > 0 && i >= top {
+			fmt.Fprintf(w, "\t...%d more groups changed\n", len(groups)-top)
+			break
+		}
+		fmt.Fprintf(w, "\t%+d %s\n", g.delta, g.key)
+	}
+
+	if a.heap != nil && b.heap != nil {
+		type heapDelta struct {
+			typ          string
+			count, bytes int64
+		}
+		var ca, cb, ba, bb int64
+		heap := []heapDelta{}
+		for _, typ := range unionKeys(a.heap, b.heap) {
+			ua, ub := a.heap[typ], b.heap[typ]
+			ca += int64(ua.Count)
+			cb += int64(ub.Count)
+			ba += int64(ua.Bytes)
+			bb += int64(ub.Bytes)
+			d := heapDelta{typ, int64(ub.Count - ua.Count), int64(ub.Bytes) - int64(ua.Bytes)}
+			if d.count != 0 || d.bytes != 0 {
+				heap = append(heap, d)
+			}
+		}
+		sort.SliceStable(heap, func(i, j int) bool { return abs64(heap[i].bytes) > abs64(heap[j].bytes) })
+		fmt.Fprintf(w, "Heap objects: %d -> %d (%+d), %d -> %d bytes (%+d)\n", ca, cb, cb-ca, ba, bb, bb-ba)
+		for i, d := range heap {
+			if top > 0 && i >= top {
+				fmt.Fprintf(w, "\t...%d more types changed\n", len(heap)-top)
+				break
+			}
+			fmt.Fprintf(w, "\t%+d objects %+d bytes %s\n", d.count, d.bytes, d.typ)
+		}
+	}
+
+	printValues := func(title string, va, vb map[string]string) {
+		first := true
+		for _, name := range unionKeys(va, vb) {
+			x, inA := va[name]
+			y, inB := vb[name]
+			if inA && inB && x == y {
+				continue
+			}
+			if first {
+				fmt.Fprintf(w, "%s:\n", title)
+				first = false
+			}
+			if !inA {
+				x = "<missing>"
+			}
+			if !inB {
+				y = "<missing>"
+			}
+			fmt.Fprintf(w, "\t%s: %s -> %s\n", name, x, y)
+		}
+	}
+	printValues("Package variables", a.globals, b.globals)
+	printValues("Expressions", a.exprs, b.exprs)
+}
+
+// unionKeys returns the sorted keys of a and b, a and b must be maps with
+// string keys.
+func unionKeys(a, b interface{}) []string {
+	seen := make(map[string]bool)
+	r := []string{}
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			r = append(r, key)
+		}
+	}
+	for _, m := range []interface{}{a, b} {
+		switch m := m.(type) {
+		case map[string]int:
+			for key := range m {
+				add(key)
+			}
+		case map[string]string:
+			for key := range m {
+				add(key)
+			}
+		case map[string]proc.HeapTypeUsage:
+			for key := range m {
+				add(key)
+			}
+		}
+	}
+	sort.Strings(r)
+	return r
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
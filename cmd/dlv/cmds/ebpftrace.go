@@ -0,0 +1,201 @@
+package cmds
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-delve/delve/pkg/proc/native"
+	"github.com/go-delve/delve/service/api"
+)
+
+const (
+	ebpfHistogramWidth = 40
+	ebpfPollInterval   = 50 * time.Millisecond
+)
+
+// traceUProbes traces the functions matching regexp in the process pid
+// using only eBPF uprobes: the process is never attached to or stopped.
+// Tracing ends when the process exits or when dlv receives SIGINT or
+// SIGTERM.
+func traceUProbes(pid int, regexp string, out io.Writer) int {
+	tracer, err := native.TraceUProbes(pid, conf.DebugInfoDirectories)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer tracer.Close()
+
+	funcs, err := tracer.Functions(regexp)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, fn := range funcs {
+		if err := tracer.SetTracepoint(fn); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to set tracepoint on function %s: %#v\n", fn, err)
+		}
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(ch)
+	ticker := time.NewTicker(ebpfPollInterval)
+	defer ticker.Stop()
+
+	printer := newEBPFTracePrinter(out)
+	printBuffered := func() {
+		tracepoints := api.ConvertTracepointResults(tracer.BinInfo(), tracer.GetBufferedTracepoints())
+		for i := range tracepoints {
+			printer.print(&tracepoints[i])
+		}
+	}
+	for {
+		select {
+		case <-ch:
+		case <-ticker.C:
+			printBuffered()
+			if _, err := os.Stat(fmt.Sprintf("/proc/%d", pid)); err == nil {
+				continue
+			}
+		}
+		printBuffered()
+		printer.printLatencies()
+		return 0
+	}
+}
+
+// ebpfTracePrinter prints the events received from eBPF tracepoints. The
+// return of each traced call is paired with its entry to report its
+// latency, latencies are collected for each function and printed as
+// histograms once tracing ends.
+type ebpfTracePrinter struct {
+	w io.Writer
+	// calls contains, for each goroutine, the stack of traced calls that
+	// haven't returned yet.
+	calls     map[int][]ebpfCall
+	latencies map[string][]time.Duration
+}
+
+type ebpfCall struct {
+	fn    string
+	start time.Time
+}
+
+func newEBPFTracePrinter(w io.Writer) *ebpfTracePrinter {
+	return &ebpfTracePrinter{w: w, calls: make(map[int][]ebpfCall), latencies: make(map[string][]time.Duration)}
+}
+
+func (p *ebpfTracePrinter) print(tp *api.TracepointResult) {
+	if !tp.IsRet {
+		p.calls[tp.GoroutineID] = append(p.calls[tp.GoroutineID], ebpfCall{fn: tp.FunctionName, start: tp.Time})
+		fmt.Fprintf(p.w, "> (%d) %s(%s)\n", tp.GoroutineID, tp.FunctionName, formatEBPFParams(tp.InputParams))
+		return
+	}
+	latency := ""
+	if start, ok := p.popCall(tp.GoroutineID, tp.FunctionName); ok {
+		d := tp.Time.Sub(start)
+		p.latencies[tp.FunctionName] = append(p.latencies[tp.FunctionName], d)
+		latency = " " + d.String()
+	}
+	fmt.Fprintf(p.w, "< (%d) %s => (%s)%s\n", tp.GoroutineID, tp.FunctionName, formatEBPFParams(tp.ReturnParams), latency)
+}
+
+// popCall removes the innermost call to fn on goroutine goid from the stack
+// of calls and returns its start time. Calls above it never returned (for
+// example because of a panic) and are discarded.
+func (p *ebpfTracePrinter) popCall(goid int, fn string) (time.Time, bool) {
+	stack := p.calls[goid]
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].fn != fn {
+			continue
+		}
+		start := stack[i].start
+		if i == 0 {
+			delete(p.calls, goid)
+		} else {
+			p.calls[goid] = stack[:i]
+		}
+		return start, true
+	}
+	return time.Time{}, false
+}
+
+func formatEBPFParams(params []api.Variable) string {
+	var buf strings.Builder
+	for _, v := range params {
+		if buf.Len() > 0 {
+			buf.WriteString(", ")
+		}
+		if v.Kind == reflect.String {
+			fmt.Fprintf(&buf, "%q", v.Value)
+		} else {
+			buf.WriteString(v.Value)
+		}
+	}
+	return buf.String()
+}
+
+// printLatencies prints a summary and a histogram, with power of two
+// buckets, of the latencies of each traced function.
+func (p *ebpfTracePrinter) printLatencies() {
+	fns := make([]string, 0, len(p.latencies))
+	for fn := range p.latencies {
+		fns = append(fns, fn)
+	}
+	sort.Strings(fns)
+	for _, fn := range fns {
+		durs := p.latencies[fn]
+		sort.Slice(durs, func(i, j int) bool { return durs[i] < durs[j] })
+		fmt.Fprintf(p.w, "\n%s: %d calls, min %v, p50 %v, p90 %v, p99 %v, max %v\n", fn, len(durs), durs[0], percentile(durs, 50), percentile(durs, 90), percentile(durs, 99), durs[len(durs)-1])
+		printHistogram(p.w, durs)
+	}
+}
+
+// percentile returns the p-th percentile of durs, which must be sorted.
+func percentile(durs []time.Duration, p int) time.Duration {
+	return durs[(len(durs)-1)*p/100]
+}
+
+// printHistogram prints the histogram of durs, bucket i contains the
+// durations in [2^(i-1), 2^i) nanoseconds.
+func printHistogram(w io.Writer, durs []time.Duration) {
+	var buckets [65]int
+	first, last, most := len(buckets), 0, 0
+	for _, d := range durs {
+		if d < 0 {
+			d = 0
+		}
+		i := bits.Len64(uint64(d))
+		buckets[i]++
+		if i < first {
+			first = i
+		}
+		if i > last {
+			last = i
+		}
+		if buckets[i] > most {
+			most = buckets[i]
+		}
+	}
+	if most == 0 {
+		return
+	}
+	bound := func(i int) time.Duration {
+		if i == 0 {
+			return 0
+		}
+		return time.Duration(1) << uint(i-1)
+	}
+	for i := first; i <= last; i++ {
+		label := fmt.Sprintf("[%v, %v)", bound(i), bound(i+1))
+		fmt.Fprintf(w, "%24s %8d |%-*s|\n", label, buckets[i], ebpfHistogramWidth, strings.Repeat("@", buckets[i]*ebpfHistogramWidth/most))
+	}
+}
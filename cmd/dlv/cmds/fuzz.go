@@ -0,0 +1,111 @@
+package cmds
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/go-delve/delve/service/rpc2"
+)
+
+// fuzzCorpusHeader is the first line of the files of the corpus of a fuzz
+// target.
+const fuzzCorpusHeader = "go test fuzz v1"
+
+// fuzzRunFlag returns the -test.run flag that runs the seed corpus of
+// fuzzTarget, only entry if it is not empty.
+func fuzzRunFlag(entry string) string {
+	run := "-test.run=^" + regexp.QuoteMeta(fuzzTarget) + "$"
+	if entry != "" {
+		run += "/^" + regexp.QuoteMeta(entry) + "$"
+	}
+	return run
+}
+
+// seedFuzzInput adds the corpus entry fuzzInput to the seed corpus of
+// fuzzTarget, in wd/testdata/fuzz/<fuzzTarget> where wd is the working
+// directory of the test binary, so that the test binary runs it, and
+// returns its name and a function that removes it.
+func seedFuzzInput(wd string) (string, func(), error) {
+	nop := func() {}
+	buf, err := ioutil.ReadFile(fuzzInput)
+	if err != nil {
+		return "", nop, err
+	}
+	if !bytes.HasPrefix(buf, []byte(fuzzCorpusHeader+"\n")) {
+		return "", nop, fmt.Errorf("%s is not a corpus entry, the first line should be %q", fuzzInput, fuzzCorpusHeader)
+	}
+	name := filepath.Base(fuzzInput)
+	wd = filepath.Clean(wd)
+	corpusDir := filepath.Join(wd, "testdata", "fuzz", fuzzTarget)
+	dst := filepath.Join(corpusDir, name)
+	if old, err := ioutil.ReadFile(dst); err == nil {
+		if !bytes.Equal(old, buf) {
+			return "", nop, fmt.Errorf("a different corpus entry named %s already exists in %s", name, corpusDir)
+		}
+		return name, nop, nil
+	}
+
+	// remember the first directory that has to be created, to remove it
+	// afterwards.
+	created := ""
+	for dir := corpusDir; dir != wd; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		created = dir
+	}
+	if err := os.MkdirAll(corpusDir, 0755); err != nil {
+		return "", nop, err
+	}
+	cleanup := func() {
+		os.Remove(dst)
+		if created != "" {
+			os.RemoveAll(created)
+		}
+	}
+	if err := ioutil.WriteFile(dst, buf, 0644); err != nil {
+		cleanup()
+		return "", nop, err
+	}
+	return name, cleanup, nil
+}
+
+var fuzzClosureRx = regexp.MustCompile(`\.func(\d+)$`)
+
+// createFuzzBreakpoint sets a breakpoint on the body of fuzzTarget, the
+// function passed to (*testing.F).Fuzz. If fuzzTarget has more than one
+// closure the first one is assumed to be the body, if it has none the
+// breakpoint is set on fuzzTarget itself.
+func createFuzzBreakpoint(client *rpc2.RPCClient) error {
+	fns, err := client.ListFunctions(`\.` + regexp.QuoteMeta(fuzzTarget) + `\.func\d+$`)
+	if err != nil {
+		return err
+	}
+	if len(fns) == 0 {
+		fns, err = client.ListFunctions(`\.` + regexp.QuoteMeta(fuzzTarget) + `$`)
+		if err != nil {
+			return err
+		}
+		if len(fns) == 0 {
+			return fmt.Errorf("could not find fuzz target %s", fuzzTarget)
+		}
+	}
+	closureNum := func(fn string) int {
+		m := fuzzClosureRx.FindStringSubmatch(fn)
+		if m == nil {
+			return 0
+		}
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	sort.Slice(fns, func(i, j int) bool { return closureNum(fns[i]) < closureNum(fns[j]) })
+	_, err = client.CreateBreakpoint(&api.Breakpoint{FunctionName: fns[0]})
+	return err
+}
@@ -0,0 +1,99 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// rotatingFile is an io.Writer that writes to a file and, once the file
+// grows beyond maxSize, renames it to path.1, path.2, ... and starts a new
+// one.
+// Files are only rotated at the start of a line so that a single line of
+// output is never split between two files.
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+
+	fh   *os.File
+	size int64
+	n    int  // number of rotations so far
+	bol  bool // the last write ended at the beginning of a line
+}
+
+func newRotatingFile(path string, maxSize int64) (*rotatingFile, error) {
+	fh, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &rotatingFile{path: path, maxSize: maxSize, fh: fh, bol: true}, nil
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.maxSize > 0 && rf.bol && rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.fh.Write(p)
+	rf.size += int64(n)
+	if n > 0 {
+		rf.bol = p[n-1] == '\n'
+	}
+	return n, err
+}
+
+func (rf *rotatingFile) rotate() error {
+	if err := rf.fh.Close(); err != nil {
+		return err
+	}
+	rf.n++
+	if err := os.Rename(rf.path, fmt.Sprintf("%s.%d", rf.path, rf.n)); err != nil {
+		return err
+	}
+	fh, err := os.Create(rf.path)
+	if err != nil {
+		return err
+	}
+	rf.fh = fh
+	rf.size = 0
+	return nil
+}
+
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.fh.Close()
+}
+
+// parseByteSize parses a size like "100MB", "512K" or "1024".
+func parseByteSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		mult   int64
+	}{
+		{"GB", 1 << 30}, {"G", 1 << 30},
+		{"MB", 1 << 20}, {"M", 1 << 20},
+		{"KB", 1 << 10}, {"K", 1 << 10},
+		{"B", 1},
+	}
+	str := strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(str, unit.suffix) {
+			str = strings.TrimSpace(str[:len(str)-len(unit.suffix)])
+			mult = unit.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
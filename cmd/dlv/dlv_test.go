@@ -283,9 +283,10 @@ func TestContinue(t *testing.T) {
 // TestChildProcessExitWhenNoDebugInfo verifies that the child process exits when dlv launch the binary without debug info
 func TestChildProcessExitWhenNoDebugInfo(t *testing.T) {
 	noDebugFlags := protest.LinkStrip
-	// -s doesn't strip symbols on Mac, use -w instead
+	// -s doesn't strip debug info on Mac, use -w as well (-w alone is not
+	// enough, the functions would be loaded from the symbol table)
 	if runtime.GOOS == "darwin" {
-		noDebugFlags = protest.LinkDisableDWARF
+		noDebugFlags |= protest.LinkDisableDWARF
 	}
 
 	if _, err := exec.LookPath("ps"); err != nil {
@@ -734,9 +735,10 @@ func TestDAPCmdWithNoDebugBinary(t *testing.T) {
 
 	// Exec the stripped debuggee and expect things to fail
 	noDebugFlags := protest.LinkStrip
-	// -s doesn't strip symbols on Mac, use -w instead
+	// -s doesn't strip debug info on Mac, use -w as well (-w alone is not
+	// enough, the functions would be loaded from the symbol table)
 	if runtime.GOOS == "darwin" {
-		noDebugFlags = protest.LinkDisableDWARF
+		noDebugFlags |= protest.LinkDisableDWARF
 	}
 	fixture := protest.BuildFixture("increment", noDebugFlags)
 	go func() {
@@ -1125,7 +1127,7 @@ func TestTraceEBPF(t *testing.T) {
 	dlvbin, tmpdir := getDlvBinEBPF(t)
 	defer os.RemoveAll(tmpdir)
 
-	expected := []byte("> (1) main.foo(99, 9801)\n=> \"9900\"")
+	expected := []byte("> (1) main.foo(99, 9801)\n< (1) main.foo => (9900) ")
 
 	fixtures := protest.FindFixturesDir()
 	cmd := exec.Command(dlvbin, "trace", "--ebpf", "--output", filepath.Join(tmpdir, "__debug"), filepath.Join(fixtures, "issue573.go"), "foo")
@@ -1141,6 +1143,9 @@ func TestTraceEBPF(t *testing.T) {
 	if !bytes.Contains(output, expected) {
 		t.Fatalf("expected:\n%s\ngot:\n%s", string(expected), string(output))
 	}
+	if !bytes.Contains(output, []byte("\nmain.foo: 1 calls, min ")) {
+		t.Fatalf("latency histogram not printed:\n%s", string(output))
+	}
 	cmd.Wait()
 }
 
@@ -1164,6 +1169,32 @@ func TestDlvTestChdir(t *testing.T) {
 	}
 }
 
+func TestDlvTestFuzz(t *testing.T) {
+	if !goversion.VersionAfterOrEqual(runtime.Version(), 1, 18) {
+		t.Skip("fuzzing requires go1.18")
+	}
+	dlvbin, tmpdir := getDlvBin(t)
+	defer os.RemoveAll(tmpdir)
+
+	input := filepath.Join(tmpdir, "crash42")
+	assertNoError(ioutil.WriteFile(input, []byte("go test fuzz v1\nstring(\"42\")\n"), 0600), t, "write corpus entry")
+
+	fuzztestdir := filepath.Join(protest.FindFixturesDir(), "fuzztest")
+	cmd := exec.Command(dlvbin, "--allow-non-terminal-interactive=true", "test", fuzztestdir, "--fuzz", "FuzzParse", "--fuzz-input", input)
+	cmd.Stdin = strings.NewReader("print s\nexit\n")
+	out, err := cmd.CombinedOutput()
+	t.Logf("output: %q", out)
+	if err != nil {
+		t.Fatalf("error executing Delve: %v", err)
+	}
+	if !strings.Contains(string(out), "FuzzParse.func1") || !strings.Contains(string(out), `"42"`) {
+		t.Errorf("did not stop in the fuzz target with the corpus entry")
+	}
+	if _, err := os.Stat(filepath.Join(fuzztestdir, "testdata")); !os.IsNotExist(err) {
+		t.Errorf("seed corpus not removed: %v", err)
+	}
+}
+
 func TestVersion(t *testing.T) {
 	dlvbin, tmpdir := getDlvBin(t)
 	defer os.RemoveAll(tmpdir)
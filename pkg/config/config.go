@@ -33,6 +33,9 @@ type SubstitutePathRules []SubstitutePathRule
 type Config struct {
 	// Commands aliases.
 	Aliases map[string][]string `yaml:"aliases"`
+	// Parametrized aliases, each one is expanded to a command line by
+	// substituting its arguments.
+	ParametrizedAliases map[string]string `yaml:"parametrized-aliases"`
 	// Source code path substitution rules.
 	SubstitutePath SubstitutePathRules `yaml:"substitute-path"`
 
@@ -79,6 +82,23 @@ type Config struct {
 	// DebugFileDirectories is the list of directories Delve will use
 	// in order to resolve external debug info files.
 	DebugInfoDirectories []string `yaml:"debug-info-directories"`
+
+	// StringerTypes is the list of types whose Error or String method is
+	// called by the print command, as if -S was specified.
+	StringerTypes []string `yaml:"stringer-types"`
+
+	// StackUserFrames, if true, makes the stack command hide the frames of
+	// the runtime, as if -user was specified.
+	StackUserFrames bool `yaml:"stack-user-frames"`
+
+	// OnAttach is a list of commands executed when the terminal starts,
+	// after connecting to the target and before the init file.
+	OnAttach []string `yaml:"on-attach"`
+	// OnStop is a list of commands executed every time the target stops.
+	OnStop []string `yaml:"on-stop"`
+	// OnDetach is a list of commands executed when the terminal exits,
+	// before detaching from the target.
+	OnDetach []string `yaml:"on-detach"`
 }
 
 func (c *Config) GetSourceListLineCount() int {
@@ -232,6 +252,12 @@ func writeDefaultConfig(f *os.File) error {
 aliases:
   # command: ["alias1", "alias2"]
 
+# Parametrized aliases are expanded to the specified command line, $1 ... $9
+# are replaced by the arguments of the alias, ${1:default} by the first
+# argument or "default" if it isn't specified and $* by all the arguments.
+parametrized-aliases:
+  # pbt: "goroutine $1 stack -full"
+
 # Define sources path substitution rules. Can be used to rewrite a source path stored
 # in program's debug information, if the sources were moved to a different place
 # between compilation and debugging.
@@ -255,6 +281,19 @@ substitute-path:
 # Allow user to specify output syntax flavor of assembly, one of this list "intel"(default), "gnu", "go".
 # disassemble-flavor: intel
 
+# Uncomment the following line to make the print command call the Error or String
+# method of values of the listed types.
+# stringer-types: ["time.Time", "net.IP"]
+
+# Uncomment the following line to make the stack command hide runtime frames, as if -user was specified.
+# stack-user-frames: true
+
+# Commands executed when the terminal starts (on-attach), every time the
+# target stops (on-stop) and before detaching from the target (on-detach).
+# on-attach: ["break main.main", "display -a len(os.Args)"]
+# on-stop: ["goroutines -n 10"]
+# on-detach: ["clearall"]
+
 # List of directories to use when searching for separate debug info files.
 debug-info-directories: ["/usr/lib/debug/.build-id"]
 `)
@@ -0,0 +1,9 @@
+// Package container finds the processes running inside a container, so
+// that they can be attached to by ID of the container instead of pid.
+package container
+
+import "errors"
+
+// ErrNotSupported is returned on operating systems where containers are
+// not supported.
+var ErrNotSupported = errors.New("containers are only supported on linux")
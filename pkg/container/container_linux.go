@@ -0,0 +1,219 @@
+package container
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-delve/delve/pkg/logflags"
+)
+
+// procfs is the mount point of the proc file system, changed by tests.
+var procfs = "/proc"
+
+// runtimeSockets are the sockets where container runtimes implementing the
+// Docker Engine API (docker, podman) listen by default. DOCKER_HOST takes
+// precedence over them.
+var runtimeSockets = []string{"/var/run/docker.sock", "/run/podman/podman.sock"}
+
+// runtimeTimeout is the timeout of requests to the container runtime.
+const runtimeTimeout = 5 * time.Second
+
+var containerIDRx = regexp.MustCompile(`^[0-9a-f]{12,64}$`)
+
+// Pid returns the host pid of a process running in container id. If nsPid
+// is zero the pid of the init process of the container is returned,
+// otherwise the process with pid nsPid in the PID namespace of the
+// container.
+//
+// The container runtime (through the Docker Engine API) is asked first, if
+// it can't be reached and id is a container ID (not a name) the cgroups of
+// the processes in /proc are searched instead.
+func Pid(id string, nsPid int) (int, error) {
+	if id == "" {
+		return 0, errors.New("empty container id")
+	}
+	initPid, err := runtimePid(id)
+	if err != nil {
+		logflags.DebuggerLogger().Debugf("could not get pid of container %s from the container runtime: %v", id, err)
+		if !containerIDRx.MatchString(id) {
+			return 0, fmt.Errorf("could not find container %s: %v", id, err)
+		}
+		initPid, err = cgroupPid(id)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if nsPid == 0 || nsPid == 1 {
+		return initPid, nil
+	}
+	return namespacePid(initPid, nsPid)
+}
+
+// Root returns the directory where the root file system of process pid is
+// visible.
+func Root(pid int) string {
+	return filepath.Join(procfs, strconv.Itoa(pid), "root")
+}
+
+// runtimePid asks the container runtime for the pid of the init process of
+// container id.
+func runtimePid(id string) (int, error) {
+	sockets := runtimeSockets
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		if !strings.HasPrefix(host, "unix://") {
+			return 0, fmt.Errorf("unsupported DOCKER_HOST %q", host)
+		}
+		sockets = []string{strings.TrimPrefix(host, "unix://")}
+	}
+	var errs []string
+	for _, socket := range sockets {
+		pid, err := inspectPid(socket, id)
+		if err == nil {
+			return pid, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return 0, errors.New(strings.Join(errs, ", "))
+}
+
+// inspectPid returns the pid of container id using the Docker Engine API
+// served on socket.
+func inspectPid(socket, id string) (int, error) {
+	client := &http.Client{
+		Timeout: runtimeTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+	resp, err := client.Get("http://localhost/containers/" + url.PathEscape(id) + "/json")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%s: container %s: %s", socket, id, resp.Status)
+	}
+	var info struct {
+		State struct {
+			Running bool
+			Pid     int
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return 0, fmt.Errorf("%s: %v", socket, err)
+	}
+	if !info.State.Running || info.State.Pid == 0 {
+		return 0, fmt.Errorf("container %s is not running", id)
+	}
+	return info.State.Pid, nil
+}
+
+// cgroupPid returns the pid of the init process of container id by
+// searching the cgroups of all processes for the ID of the container.
+func cgroupPid(id string) (int, error) {
+	pids, err := pids()
+	if err != nil {
+		return 0, err
+	}
+	found := -1
+	for _, pid := range pids {
+		cgroup, err := ioutil.ReadFile(filepath.Join(procfs, strconv.Itoa(pid), "cgroup"))
+		if err != nil || !strings.Contains(string(cgroup), id) {
+			continue
+		}
+		if nspids, err := nsPids(pid); err == nil && nspids[len(nspids)-1] == 1 {
+			return pid, nil
+		}
+		if found < 0 {
+			found = pid
+		}
+	}
+	if found < 0 {
+		return 0, fmt.Errorf("could not find container %s", id)
+	}
+	return found, nil
+}
+
+// namespacePid returns the host pid of the process that has pid nsPid in
+// the PID namespace of initPid.
+func namespacePid(initPid, nsPid int) (int, error) {
+	ns, err := os.Readlink(filepath.Join(procfs, strconv.Itoa(initPid), "ns", "pid"))
+	if err != nil {
+		return 0, fmt.Errorf("could not read PID namespace of process %d: %v", initPid, err)
+	}
+	pids, err := pids()
+	if err != nil {
+		return 0, err
+	}
+	for _, pid := range pids {
+		nspids, err := nsPids(pid)
+		if err != nil || nspids[len(nspids)-1] != nsPid {
+			continue
+		}
+		if ns2, err := os.Readlink(filepath.Join(procfs, strconv.Itoa(pid), "ns", "pid")); err == nil && ns2 == ns {
+			return pid, nil
+		}
+	}
+	return 0, fmt.Errorf("could not find process %d in the PID namespace of process %d", nsPid, initPid)
+}
+
+// pids returns the pids of all processes, in ascending order.
+func pids() ([]int, error) {
+	fis, err := ioutil.ReadDir(procfs)
+	if err != nil {
+		return nil, err
+	}
+	var r []int
+	for _, fi := range fis {
+		if pid, err := strconv.Atoi(fi.Name()); err == nil {
+			r = append(r, pid)
+		}
+	}
+	sort.Ints(r)
+	return r, nil
+}
+
+// nsPids returns the pids of process pid in each of the PID namespaces it
+// belongs to, from the outermost to the innermost, as listed by the NSpid
+// field of /proc/<pid>/status.
+func nsPids(pid int) ([]int, error) {
+	fh, err := os.Open(filepath.Join(procfs, strconv.Itoa(pid), "status"))
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	s := bufio.NewScanner(fh)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 || fields[0] != "NSpid:" {
+			continue
+		}
+		r := make([]int, 0, len(fields)-1)
+		for _, field := range fields[1:] {
+			n, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("malformed NSpid field of process %d", pid)
+			}
+			r = append(r, n)
+		}
+		return r, nil
+	}
+	return nil, fmt.Errorf("no NSpid field for process %d", pid)
+}
@@ -0,0 +1,142 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+const testID = "3f4e1b2c9a8d7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f"
+
+// fakeProc creates a fake proc file system containing procs, with their
+// NSpid, cgroup and PID namespace, and installs it as procfs.
+func fakeProc(t *testing.T, procs map[int]struct {
+	nspid, cgroup, ns string
+}) {
+	dir, err := ioutil.TempDir("", "fakeproc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for pid, p := range procs {
+		pdir := filepath.Join(dir, strconv.Itoa(pid))
+		must(t, os.MkdirAll(filepath.Join(pdir, "ns"), 0700))
+		must(t, ioutil.WriteFile(filepath.Join(pdir, "status"), []byte(fmt.Sprintf("Name:\tproc\nPid:\t%d\nNSpid:\t%s\n", pid, p.nspid)), 0600))
+		must(t, ioutil.WriteFile(filepath.Join(pdir, "cgroup"), []byte(p.cgroup), 0600))
+		must(t, os.Symlink(p.ns, filepath.Join(pdir, "ns", "pid")))
+	}
+	old := procfs
+	procfs = dir
+	t.Cleanup(func() { procfs = old })
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// noRuntime makes sure that the container runtime can not be reached.
+func noRuntime(t *testing.T) {
+	old := os.Getenv("DOCKER_HOST")
+	os.Setenv("DOCKER_HOST", "unix:///nonexistent/docker.sock")
+	t.Cleanup(func() { os.Setenv("DOCKER_HOST", old) })
+}
+
+func TestPidCgroup(t *testing.T) {
+	noRuntime(t)
+	fakeProc(t, map[int]struct{ nspid, cgroup, ns string }{
+		1:    {"1", "0::/init.scope\n", "pid:[4026531836]"},
+		1200: {"1200", "0::/system.slice/docker.service\n", "pid:[4026531836]"},
+		1301: {"1301\t7", "0::/system.slice/docker-" + testID + ".scope\n", "pid:[4026532500]"},
+		1300: {"1300\t1", "0::/system.slice/docker-" + testID + ".scope\n", "pid:[4026532500]"},
+		1400: {"1400\t7", "0::/system.slice/docker-0123456789abcdef.scope\n", "pid:[4026532600]"},
+	})
+
+	for _, tc := range []struct {
+		id     string
+		nsPid  int
+		tgtPid int
+	}{
+		{testID, 0, 1300},
+		{testID[:12], 0, 1300},
+		{testID, 1, 1300},
+		{testID, 7, 1301},
+	} {
+		pid, err := Pid(tc.id, tc.nsPid)
+		if err != nil {
+			t.Errorf("Pid(%s, %d): %v", tc.id, tc.nsPid, err)
+			continue
+		}
+		if pid != tc.tgtPid {
+			t.Errorf("Pid(%s, %d) = %d, expected %d", tc.id, tc.nsPid, pid, tc.tgtPid)
+		}
+	}
+
+	for _, tc := range []struct {
+		id    string
+		nsPid int
+	}{
+		{"abcdef0123456789", 0}, // no such container
+		{testID, 8},             // no such process in the container
+		{"mycontainer", 0},      // names can only be resolved by the container runtime
+	} {
+		if pid, err := Pid(tc.id, tc.nsPid); err == nil {
+			t.Errorf("Pid(%s, %d) = %d, expected error", tc.id, tc.nsPid, pid)
+		}
+	}
+}
+
+func TestPidRuntime(t *testing.T) {
+	fakeProc(t, map[int]struct{ nspid, cgroup, ns string }{
+		1:    {"1", "0::/init.scope\n", "pid:[4026531836]"},
+		2000: {"2000\t1", "0::/\n", "pid:[4026532700]"},
+		2001: {"2001\t5", "0::/\n", "pid:[4026532700]"},
+	})
+
+	dir, err := ioutil.TempDir("", "dockersock")
+	must(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	must(t, err)
+	defer listener.Close()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/containers/mycontainer/json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"Id":%q,"State":{"Running":true,"Pid":2000}}`, testID)
+	})
+	mux.HandleFunc("/containers/stopped/json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"State":{"Running":false,"Pid":0}}`)
+	})
+	go http.Serve(listener, mux)
+
+	old := os.Getenv("DOCKER_HOST")
+	os.Setenv("DOCKER_HOST", "unix://"+socket)
+	defer os.Setenv("DOCKER_HOST", old)
+
+	pid, err := Pid("mycontainer", 0)
+	must(t, err)
+	if pid != 2000 {
+		t.Errorf("Pid(mycontainer, 0) = %d, expected 2000", pid)
+	}
+	pid, err = Pid("mycontainer", 5)
+	must(t, err)
+	if pid != 2001 {
+		t.Errorf("Pid(mycontainer, 5) = %d, expected 2001", pid)
+	}
+	for _, id := range []string{"stopped", "missing"} {
+		if pid, err := Pid(id, 0); err == nil {
+			t.Errorf("Pid(%s, 0) = %d, expected error", id, pid)
+		}
+	}
+
+	if root := Root(2000); root != filepath.Join(procfs, "2000", "root") {
+		t.Errorf("wrong root directory %q", root)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package container
+
+// Pid returns the host pid of a process running in container id.
+func Pid(id string, nsPid int) (int, error) {
+	return 0, ErrNotSupported
+}
+
+// Root returns the directory where the root file system of process pid is
+// visible.
+func Root(pid int) string {
+	return ""
+}
@@ -0,0 +1,220 @@
+// Package debugger is the API to embed Delve in other programs: it
+// launches or attaches to a Go program and controls it with breakpoints,
+// stepping and expression evaluation.
+//
+// Unlike the other packages of this module, which are Delve's internals
+// and change from release to release, this package follows semantic
+// versioning, see APIVersion: within a major version exported identifiers
+// are not removed, their behavior does not change in incompatible ways and
+// new fields are only added to structs whose zero value is meaningful.
+//
+// A Debugger is safe for concurrent use, Halt can be called while another
+// goroutine is waiting for Continue or one of the stepping methods to
+// return.
+package debugger
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-delve/delve/service/api"
+	svc "github.com/go-delve/delve/service/debugger"
+)
+
+// APIVersion is the semantic version of the API of this package.
+const APIVersion = "1.0.0"
+
+// Config configures how a Debugger starts the target.
+type Config struct {
+	// WorkingDir is the working directory of the launched program, the
+	// current directory if empty.
+	WorkingDir string
+
+	// Backend is the backend used to control the target: "native", "lldb"
+	// or "rr". The default backend of the operating system is used if
+	// empty.
+	Backend string
+
+	// CheckGoVersion, if true, makes Launch and Attach fail if the target
+	// was built with a version of Go that Delve does not support.
+	CheckGoVersion bool
+
+	// DebugInfoDirectories is the list of directories searched for
+	// separate debug information files.
+	DebugInfoDirectories []string
+
+	// Redirects are the paths of the files used as standard input, output
+	// and error of the launched program, an empty path means that the
+	// corresponding file of the current process is used.
+	Redirects [3]string
+}
+
+// Debugger controls a program being debugged.
+type Debugger struct {
+	d *svc.Debugger
+}
+
+// Launch starts the program described by cmd, an executable file followed
+// by its arguments, stopped before the execution of its first instruction.
+// The executable must have been built with debug information, for example
+// with go build -gcflags='all=-N -l'.
+func Launch(cmd []string, cfg Config) (*Debugger, error) {
+	if len(cmd) == 0 {
+		return nil, errors.New("no executable")
+	}
+	return newDebugger(cfg, 0, cmd)
+}
+
+// Attach stops the running process pid and attaches to it.
+func Attach(pid int, cfg Config) (*Debugger, error) {
+	if pid <= 0 {
+		return nil, fmt.Errorf("invalid pid %d", pid)
+	}
+	return newDebugger(cfg, pid, nil)
+}
+
+func newDebugger(cfg Config, pid int, cmd []string) (*Debugger, error) {
+	backend := cfg.Backend
+	if backend == "" {
+		backend = "default"
+	}
+	d, err := svc.New(&svc.Config{
+		WorkingDir:           cfg.WorkingDir,
+		AttachPid:            pid,
+		Backend:              backend,
+		CheckGoVersion:       cfg.CheckGoVersion,
+		DebugInfoDirectories: cfg.DebugInfoDirectories,
+		Redirects:            cfg.Redirects,
+	}, cmd)
+	if err != nil {
+		return nil, err
+	}
+	return &Debugger{d: d}, nil
+}
+
+// Pid returns the process ID of the target.
+func (d *Debugger) Pid() int {
+	return d.d.ProcessPid()
+}
+
+// Close detaches from the target, which is killed if kill is true and
+// resumed otherwise. The Debugger can not be used after Close returns.
+func (d *Debugger) Close(kill bool) error {
+	return d.d.Detach(kill)
+}
+
+// SetBreakpoint sets a breakpoint at the location described by loc, which
+// is a location expression like the ones accepted by the break command of
+// Delve's command line client, for example "main.main", "file.go:10" or
+// "*0x4a0b20". If loc refers to several locations, for example a line of a
+// function that is inlined in several places, the breakpoint is set on
+// all of them.
+func (d *Debugger) SetBreakpoint(loc string) (*Breakpoint, error) {
+	locs, err := d.d.FindLocation(-1, 0, 0, loc, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(locs) != 1 {
+		return nil, fmt.Errorf("location %q is ambiguous: %d locations found", loc, len(locs))
+	}
+	bp, err := d.d.CreateBreakpoint(&api.Breakpoint{Addr: locs[0].PC, Addrs: locs[0].PCs})
+	if err != nil {
+		return nil, err
+	}
+	return convertBreakpoint(bp), nil
+}
+
+// ClearBreakpoint removes the breakpoint with the specified ID.
+func (d *Debugger) ClearBreakpoint(id int) error {
+	bp := d.d.FindBreakpoint(id)
+	if bp == nil {
+		return fmt.Errorf("no breakpoint with id %d", id)
+	}
+	_, err := d.d.ClearBreakpoint(bp)
+	return err
+}
+
+// Breakpoints returns the breakpoints set with SetBreakpoint, sorted by ID.
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	var r []*Breakpoint
+	for _, bp := range d.d.Breakpoints(false) {
+		if bp.ID > 0 {
+			r = append(r, convertBreakpoint(bp))
+		}
+	}
+	return r
+}
+
+// Continue resumes the target until it hits a breakpoint, it is stopped
+// with Halt or it exits.
+func (d *Debugger) Continue() (*State, error) {
+	return d.command(api.Continue)
+}
+
+// Next resumes the target until it reaches the next line of the current
+// function, stepping over function calls.
+func (d *Debugger) Next() (*State, error) {
+	return d.command(api.Next)
+}
+
+// Step resumes the target until it reaches the next line, stepping into
+// function calls.
+func (d *Debugger) Step() (*State, error) {
+	return d.command(api.Step)
+}
+
+// StepOut resumes the target until the current function returns.
+func (d *Debugger) StepOut() (*State, error) {
+	return d.command(api.StepOut)
+}
+
+// Halt stops the target while Continue or one of the stepping methods is
+// running, which then return.
+func (d *Debugger) Halt() error {
+	_, err := d.d.Command(&api.DebuggerCommand{Name: api.Halt}, nil)
+	return err
+}
+
+func (d *Debugger) command(name string) (*State, error) {
+	state, err := d.d.Command(&api.DebuggerCommand{Name: name}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return convertState(state), nil
+}
+
+// Eval evaluates the Go expression expr in the specified scope.
+func (d *Debugger) Eval(scope Scope, expr string) (*Variable, error) {
+	v, err := d.d.EvalVariableInScope(scope.GoroutineID, scope.Frame, 0, expr, *api.LoadConfigToProc(&evalLoadConfig))
+	if err != nil {
+		return nil, err
+	}
+	return convertVariable(api.ConvertVar(v)), nil
+}
+
+// evalLoadConfig is the load configuration of Eval, the same one used by
+// the print command of Delve's command line client.
+var evalLoadConfig = api.LoadConfig{FollowPointers: true, MaxVariableRecurse: 1, MaxStringLen: 64, MaxArrayValues: 64, MaxStructFields: -1}
+
+// Stacktrace returns the call stack of the goroutine goroutineID, -1 for
+// the selected goroutine, up to depth frames. The first location is the
+// innermost frame.
+func (d *Debugger) Stacktrace(goroutineID, depth int) ([]Location, error) {
+	frames, err := d.d.Stacktrace(goroutineID, depth, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) > depth {
+		// the depth of proc stacktraces does not count the innermost frame
+		frames = frames[:depth]
+	}
+	r := make([]Location, len(frames))
+	for i := range frames {
+		loc := &frames[i].Call
+		r[i] = Location{PC: loc.PC, File: loc.File, Line: loc.Line}
+		if loc.Fn != nil {
+			r[i].Function = loc.Fn.Name
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,127 @@
+package debugger_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/go-delve/delve/pkg/debugger"
+	protest "github.com/go-delve/delve/pkg/proc/test"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(protest.RunTestsWithFixtures(m))
+}
+
+// launchIncrement launches the increment fixture stopped at the first call
+// of main.Increment.
+func launchIncrement() (*debugger.Debugger, error) {
+	fixture := protest.BuildFixture("increment", 0)
+	d, err := debugger.Launch([]string{fixture.Path}, debugger.Config{Redirects: [3]string{"", os.DevNull, ""}})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.SetBreakpoint("main.Increment"); err != nil {
+		d.Close(true)
+		return nil, err
+	}
+	if _, err := d.Continue(); err != nil {
+		d.Close(true)
+		return nil, err
+	}
+	return d, nil
+}
+
+func ExampleLaunch() {
+	fixture := protest.BuildFixture("increment", 0)
+	d, err := debugger.Launch([]string{fixture.Path}, debugger.Config{})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer d.Close(true)
+
+	bp, err := d.SetBreakpoint("main.Increment")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for i := 0; i < 2; i++ {
+		state, err := d.Continue()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		y, err := d.Eval(debugger.CurrentScope, "y")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("%s: breakpoint %d, %s %s = %s\n", state.Location.Function, state.Breakpoint.ID-bp.ID, y.Name, y.Type, y.Value)
+	}
+	// Output:
+	// main.Increment: breakpoint 0, y uint = 3
+	// main.Increment: breakpoint 0, y uint = 1
+}
+
+func ExampleDebugger_Stacktrace() {
+	d, err := launchIncrement()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer d.Close(true)
+	if _, err := d.Continue(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	stack, err := d.Stacktrace(-1, 3)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for i := range stack {
+		y, err := d.Eval(debugger.Scope{GoroutineID: -1, Frame: i}, "y")
+		if err != nil {
+			fmt.Println(stack[i].Function)
+			continue
+		}
+		fmt.Println(stack[i].Function, y.Value)
+	}
+	// Output:
+	// main.Increment 1
+	// main.Increment 3
+	// main.main
+}
+
+func ExampleDebugger_StepOut() {
+	d, err := launchIncrement()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer d.Close(true)
+	for _, bp := range d.Breakpoints() {
+		if err := d.ClearBreakpoint(bp.ID); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	state, err := d.StepOut()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(state.Location.Function, state.Breakpoint == nil)
+	state, err = d.Continue()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(state.Exited, state.ExitStatus)
+	// Output:
+	// main.main true
+	// true 0
+}
@@ -0,0 +1,121 @@
+package debugger
+
+import "github.com/go-delve/delve/service/api"
+
+// Location is a position in the target program.
+type Location struct {
+	PC       uint64
+	File     string
+	Line     int
+	Function string // fully qualified name, for example "main.main"
+}
+
+// Breakpoint is a breakpoint set with SetBreakpoint.
+type Breakpoint struct {
+	ID       int
+	File     string
+	Line     int
+	Function string
+	// Addrs are the addresses of the breakpoint, there can be more than one
+	// if the location of the breakpoint is inlined.
+	Addrs []uint64
+	// HitCount is the number of times the breakpoint was hit.
+	HitCount uint64
+}
+
+// State is the state of the target after it stops.
+type State struct {
+	// Exited is true if the target exited, the other fields are not set.
+	Exited     bool
+	ExitStatus int
+
+	// GoroutineID is the ID of the selected goroutine, 0 if the thread
+	// that stopped is not running a goroutine.
+	GoroutineID int
+	// Location is the location of the thread that stopped.
+	Location Location
+	// Breakpoint is the breakpoint hit by the thread that stopped, nil if
+	// it did not stop at a breakpoint.
+	Breakpoint *Breakpoint
+}
+
+// Scope is the scope in which an expression is evaluated.
+type Scope struct {
+	// GoroutineID is the ID of the goroutine, -1 for the selected
+	// goroutine.
+	GoroutineID int
+	// Frame is the index of the frame in the stacktrace of the goroutine, 0
+	// is the innermost frame.
+	Frame int
+}
+
+// CurrentScope is the innermost frame of the selected goroutine.
+var CurrentScope = Scope{GoroutineID: -1}
+
+// Variable is the result of evaluating an expression.
+type Variable struct {
+	Name string
+	Type string
+	// Value is the value of the variable formatted on a single line, like
+	// the print command of Delve's command line client does.
+	Value string
+	// Children are the fields of structs, the elements of arrays and
+	// slices, the keys and values of maps, in this order, and the value
+	// pointed to by pointers. Only the children that were loaded are
+	// returned.
+	Children []Variable
+	// Unreadable is the reason why the value could not be read, empty if
+	// it was.
+	Unreadable string
+}
+
+func convertBreakpoint(bp *api.Breakpoint) *Breakpoint {
+	r := &Breakpoint{
+		ID:       bp.ID,
+		File:     bp.File,
+		Line:     bp.Line,
+		Function: bp.FunctionName,
+		Addrs:    append([]uint64(nil), bp.Addrs...),
+		HitCount: bp.TotalHitCount,
+	}
+	if len(r.Addrs) == 0 {
+		r.Addrs = []uint64{bp.Addr}
+	}
+	return r
+}
+
+func convertState(state *api.DebuggerState) *State {
+	if state.Exited {
+		return &State{Exited: true, ExitStatus: state.ExitStatus}
+	}
+	r := &State{}
+	if state.SelectedGoroutine != nil {
+		r.GoroutineID = state.SelectedGoroutine.ID
+	}
+	if th := state.CurrentThread; th != nil {
+		r.Location = Location{PC: th.PC, File: th.File, Line: th.Line}
+		if th.Function != nil {
+			r.Location.Function = th.Function.Name()
+		}
+		if th.Breakpoint != nil && th.Breakpoint.ID > 0 {
+			r.Breakpoint = convertBreakpoint(th.Breakpoint)
+		}
+	}
+	return r
+}
+
+func convertVariable(v *api.Variable) *Variable {
+	r := &Variable{
+		Name:       v.Name,
+		Type:       v.Type,
+		Value:      v.SinglelineString(),
+		Unreadable: v.Unreadable,
+	}
+	if len(v.Children) > 0 {
+		r.Children = make([]Variable, len(v.Children))
+		for i := range v.Children {
+			r.Children[i] = *convertVariable(&v.Children[i])
+		}
+	}
+	return r
+}
@@ -0,0 +1,210 @@
+// Package dwo links split DWARF debug information.
+//
+// When a program is compiled with -gsplit-dwarf the compile units in the
+// executable are skeletons, containing little more than the address ranges
+// and the line table of the unit and the name of a .dwo file where the
+// full compile unit is stored. The .dwo files can also be collected in a
+// single .dwp package.
+//
+// Link reads the full compile units of the skeleton units of an
+// executable and converts them into ordinary compile units that can be
+// appended to its debug_info section: references to the string and address
+// index sections are replaced by their values and location and range lists
+// are copied to the sections of the executable.
+package dwo
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-delve/delve/pkg/dwarf/godwarf"
+)
+
+// File is a .dwo file or a .dwp package.
+type File struct {
+	info, abbrev, str, strOffsets, loc, loclists, rnglists []byte
+
+	index *unitIndex // index of the units of a .dwp package, nil for .dwo files
+}
+
+// Open opens the .dwo file or .dwp package at path.
+func Open(path string) (*File, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	f, err := elf.NewFile(fh)
+	if err != nil {
+		return nil, err
+	}
+	section := func(name string) []byte {
+		data, _ := godwarf.GetDebugSectionElfReader(f, fh, name)
+		return data
+	}
+	r := &File{
+		info:       section("info.dwo"),
+		abbrev:     section("abbrev.dwo"),
+		str:        section("str.dwo"),
+		strOffsets: section("str_offsets.dwo"),
+		loc:        section("loc.dwo"),
+		loclists:   section("loclists.dwo"),
+		rnglists:   section("rnglists.dwo"),
+	}
+	if len(r.info) == 0 || len(r.abbrev) == 0 {
+		return nil, fmt.Errorf("%s: no split debug info", path)
+	}
+	if index := section("cu_index"); index != nil {
+		r.index, err = parseUnitIndex(index)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return r, nil
+}
+
+// Contains returns true if f is a .dwp package containing the split
+// compile unit with the specified id.
+func (f *File) Contains(id uint64) bool {
+	return f.index != nil && f.index.find(id) >= 0
+}
+
+// contribution is the part of the sections of a File that belongs to one
+// split compile unit.
+type contribution struct {
+	info, abbrev, strOffsets, loc, loclists, rnglists []byte
+	str                                               []byte // shared by all units
+}
+
+// unit returns the contribution of the split compile unit with the
+// specified id. For .dwo files, which contain a single compile unit, id is
+// ignored.
+func (f *File) unit(id uint64) (*contribution, error) {
+	if f.index == nil {
+		return &contribution{info: f.info, abbrev: f.abbrev, str: f.str, strOffsets: f.strOffsets, loc: f.loc, loclists: f.loclists, rnglists: f.rnglists}, nil
+	}
+	row := f.index.find(id)
+	if row < 0 {
+		return nil, fmt.Errorf("unit %#x not found in package", id)
+	}
+	c := &contribution{str: f.str}
+	for col, sec := range f.index.sections {
+		off, size := f.index.offsets[row][col], f.index.sizes[row][col]
+		var dst *[]byte
+		var src []byte
+		switch sec {
+		case sectInfo:
+			dst, src = &c.info, f.info
+		case sectAbbrev:
+			dst, src = &c.abbrev, f.abbrev
+		case sectStrOffsets:
+			dst, src = &c.strOffsets, f.strOffsets
+		case sectLoc:
+			if f.index.version >= 5 {
+				dst, src = &c.loclists, f.loclists
+			} else {
+				dst, src = &c.loc, f.loc
+			}
+		case sectRnglists:
+			if f.index.version >= 5 {
+				dst, src = &c.rnglists, f.rnglists
+			}
+		}
+		if dst == nil {
+			continue
+		}
+		if uint64(off)+uint64(size) > uint64(len(src)) {
+			return nil, errors.New("malformed package index")
+		}
+		*dst = src[off : off+size]
+	}
+	return c, nil
+}
+
+// Section identifiers used by the index of .dwp packages, the ones that
+// differ between the GNU extension to DWARFv4 and DWARFv5 are named after
+// their DWARFv5 meaning.
+const (
+	sectInfo       = 1
+	sectAbbrev     = 3
+	sectStrOffsets = 6
+	sectLoc        = 5 // DW_SECT_LOCLISTS in DWARFv5, DW_SECT_LOC before
+	sectRnglists   = 8 // DW_SECT_RNGLISTS in DWARFv5, DW_SECT_MACRO before
+)
+
+// unitIndex is the contents of the debug_cu_index section of a .dwp
+// package. See DWARFv5 section 7.3.5 page 190 and following.
+type unitIndex struct {
+	version  int
+	sections []uint32 // section identifier of each column
+	ids      []uint64 // unit id of each row
+	offsets  [][]uint32
+	sizes    [][]uint32
+}
+
+func parseUnitIndex(data []byte) (*unitIndex, error) {
+	errMalformed := errors.New("malformed debug_cu_index section")
+	if len(data) < 16 {
+		return nil, errMalformed
+	}
+	order := binary.LittleEndian
+	idx := &unitIndex{version: int(order.Uint32(data))}
+	if idx.version > 0xffff {
+		// DWARFv5 has a 2 bytes version followed by 2 bytes of padding
+		idx.version = int(order.Uint16(data))
+	}
+	ncols := int(order.Uint32(data[4:]))
+	nunits := int(order.Uint32(data[8:]))
+	nslots := int(order.Uint32(data[12:]))
+	if 16+nslots*12+ncols*4+2*nunits*ncols*4 > len(data) {
+		return nil, errMalformed
+	}
+	data = data[16:]
+	sigs, rows := data[:nslots*8], data[nslots*8:nslots*12]
+	data = data[nslots*12:]
+
+	idx.ids = make([]uint64, nunits)
+	for i := 0; i < nslots; i++ {
+		row := int(order.Uint32(rows[i*4:]))
+		if row == 0 {
+			continue
+		}
+		if row > nunits {
+			return nil, errMalformed
+		}
+		idx.ids[row-1] = order.Uint64(sigs[i*8:])
+	}
+
+	idx.sections = make([]uint32, ncols)
+	for i := range idx.sections {
+		idx.sections[i] = order.Uint32(data[i*4:])
+	}
+	data = data[ncols*4:]
+	readTable := func() [][]uint32 {
+		r := make([][]uint32, nunits)
+		for i := range r {
+			r[i] = make([]uint32, ncols)
+			for j := range r[i] {
+				r[i][j] = order.Uint32(data[(i*ncols+j)*4:])
+			}
+		}
+		data = data[nunits*ncols*4:]
+		return r
+	}
+	idx.offsets = readTable()
+	idx.sizes = readTable()
+	return idx, nil
+}
+
+// find returns the row of the unit with the specified id, or -1.
+func (idx *unitIndex) find(id uint64) int {
+	for i := range idx.ids {
+		if idx.ids[i] == id {
+			return i
+		}
+	}
+	return -1
+}
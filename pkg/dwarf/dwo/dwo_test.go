@@ -0,0 +1,272 @@
+package dwo
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/go-delve/delve/pkg/dwarf/godwarf"
+	"github.com/go-delve/delve/pkg/dwarf/loclist"
+	"github.com/go-delve/delve/pkg/dwarf/op"
+)
+
+const testSource = `#include <stdio.h>
+struct point { int x, y; };
+struct point origin = {1, 2};
+__attribute__((noinline)) int add(int a, int b) { int s = a + b; return s; }
+int main(int argc, char **argv) {
+	struct point p = {argc, 3};
+	printf("%d\n", add(p.x, p.y) + origin.x);
+	return 0;
+}
+`
+
+// buildSplit compiles testSource with -gsplit-dwarf and the specified
+// DWARF version, returning the path of the executable.
+func buildSplit(t *testing.T, version string, dwp bool) string {
+	if runtime.GOOS != "linux" {
+		t.Skip("split DWARF is only produced on linux")
+	}
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "split.c"), []byte(testSource), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	run := func(name string, args ...string) {
+		cmd := exec.Command(name, args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Skipf("%s failed: %v\n%s", name, err, out)
+		}
+	}
+	run("gcc", "-gdwarf-"+version, "-gsplit-dwarf", "-O1", "-c", "split.c")
+	run("gcc", "-o", "split", "split.o")
+	if dwp {
+		if _, err := exec.LookPath("dwp"); err != nil {
+			t.Skip("dwp not found")
+		}
+		run("dwp", "-e", "split", "-o", "split.dwp")
+		os.Remove(filepath.Join(dir, "split.dwo"))
+	}
+	return filepath.Join(dir, "split")
+}
+
+func linkSplit(t *testing.T, exe string, dwp bool) (*dwarf.Data, *Sections) {
+	f, err := elf.Open(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	section := func(name string) []byte {
+		data, _ := godwarf.GetDebugSectionElf(f, name)
+		return data
+	}
+	sec := &Sections{
+		Info: section("info"), Abbrev: section("abbrev"),
+		Str: section("str"), StrOffsets: section("str_offsets"), LineStr: section("line_str"),
+		Addr:   section("addr"),
+		Ranges: section("ranges"), Rnglists: section("rnglists"),
+		Loc: section("loc"), Loclists: section("loclists"),
+	}
+	var pkg *File
+	if dwp {
+		pkg, err = Open(exe + ".dwp")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, linked, errs := Link(sec, 8, func(name, compDir string, id uint64) (*File, error) {
+		if pkg != nil {
+			return pkg, nil
+		}
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(compDir, name)
+		}
+		return Open(name)
+	})
+	if len(errs) > 0 {
+		t.Fatalf("link errors: %v", errs)
+	}
+	if linked != 1 {
+		t.Fatalf("linked %d units, expected 1", linked)
+	}
+	d, err := dwarf.New(out.Abbrev, nil, nil, out.Info, section("line"), nil, out.Ranges, out.Str)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []struct {
+		name string
+		data []byte
+	}{{".debug_addr", out.Addr}, {".debug_line_str", out.LineStr}, {".debug_str_offsets", out.StrOffsets}, {".debug_rnglists", out.Rnglists}} {
+		if err := d.AddSection(s.name, s.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return d, out
+}
+
+func testLink(t *testing.T, version string, dwp bool) {
+	exe := buildSplit(t, version, dwp)
+	d, sec := linkSplit(t, exe, dwp)
+	var locs loclist.Reader
+	if version == "5" {
+		locs = loclist.NewDwarf5Reader(sec.Loclists)
+	} else {
+		locs = loclist.NewDwarf2Reader(sec.Loc, 8)
+	}
+
+	f, err := elf.Open(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	symAddr := map[string]uint64{}
+	for _, sym := range syms {
+		symAddr[sym.Name] = sym.Value
+	}
+
+	found := map[string]bool{}
+	cus, loclists := 0, 0
+	var cuBase uint64
+	var fnRange [2]uint64
+	rdr := d.Reader()
+	for {
+		e, err := rdr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e == nil {
+			break
+		}
+		switch e.Tag {
+		case dwarf.TagCompileUnit:
+			cus++
+			cuBase, _ = e.Val(dwarf.AttrLowpc).(uint64)
+			if name, _ := e.Val(dwarf.AttrName).(string); name != "split.c" {
+				t.Errorf("compile unit name %q", name)
+			}
+			rngs, err := d.Ranges(e)
+			if err != nil || len(rngs) == 0 {
+				t.Errorf("compile unit ranges %v %v", rngs, err)
+			}
+			lr, err := d.LineReader(e)
+			if err != nil || lr == nil {
+				t.Errorf("compile unit has no line table: %v", err)
+			}
+		case dwarf.TagSubprogram:
+			name, _ := e.Val(dwarf.AttrName).(string)
+			if name != "add" && name != "main" {
+				break
+			}
+			rngs, err := d.Ranges(e)
+			if err != nil || len(rngs) == 0 {
+				t.Errorf("%s: ranges %v %v", name, rngs, err)
+				break
+			}
+			fnRange = rngs[0]
+			if rngs[0][0] != symAddr[name] {
+				t.Errorf("%s: entry point %#x, expected %#x", name, rngs[0][0], symAddr[name])
+			}
+			found[name] = true
+		case dwarf.TagFormalParameter:
+			f := e.AttrField(dwarf.AttrLocation)
+			if f == nil || f.Class != dwarf.ClassLocListPtr {
+				break
+			}
+			loclists++
+			covered := false
+			for pc := fnRange[0]; pc < fnRange[1]; pc++ {
+				le, err := locs.Find(int(f.Val.(int64)), 0, cuBase, pc, nil)
+				if err != nil {
+					t.Fatalf("location list at %#x: %v", f.Val, err)
+				}
+				if le != nil && len(le.Instr) > 0 {
+					covered = true
+				}
+			}
+			if !covered {
+				t.Errorf("location list at %#x of %v does not cover its function", f.Val, e.Val(dwarf.AttrName))
+			}
+		case dwarf.TagVariable:
+			name, _ := e.Val(dwarf.AttrName).(string)
+			if name != "origin" {
+				break
+			}
+			loc, _ := e.Val(dwarf.AttrLocation).([]byte)
+			addr, _, err := op.ExecuteStackProgram(op.DwarfRegisters{}, loc, 8, nil)
+			if err != nil || uint64(addr) != symAddr[name] {
+				t.Errorf("origin: address %#x %v, expected %#x", addr, err, symAddr[name])
+			}
+			if typ, err := d.Type(e.Val(dwarf.AttrType).(dwarf.Offset)); err != nil || typ.String() != "struct point" {
+				t.Errorf("origin: type %v %v", typ, err)
+			}
+			found[name] = true
+		}
+	}
+	if loclists == 0 {
+		t.Errorf("no location lists found")
+	}
+	if cus != 1 {
+		t.Errorf("found %d compile units, expected 1", cus)
+	}
+	for _, name := range []string{"add", "main", "origin"} {
+		if !found[name] {
+			t.Errorf("%s not found", name)
+		}
+	}
+}
+
+func TestLinkDWARF4(t *testing.T)    { testLink(t, "4", false) }
+func TestLinkDWARF5(t *testing.T)    { testLink(t, "5", false) }
+func TestLinkDWARF4Dwp(t *testing.T) { testLink(t, "4", true) }
+func TestLinkDWARF5Dwp(t *testing.T) { testLink(t, "5", true) }
+
+func TestParseUnitIndex(t *testing.T) {
+	// index with one unit and two columns (info and abbrev) in a table with
+	// two slots.
+	var data []byte
+	u32 := func(x uint32) {
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], x)
+		data = append(data, b[:]...)
+	}
+	u64 := func(x uint64) {
+		u32(uint32(x))
+		u32(uint32(x >> 32))
+	}
+	u32(5)
+	u32(2)
+	u32(1)
+	u32(2)
+	u64(0)
+	u64(0x1234)
+	u32(0)
+	u32(1)
+	u32(sectInfo)
+	u32(sectAbbrev)
+	u32(10)
+	u32(20)
+	u32(30)
+	u32(40)
+	idx, err := parseUnitIndex(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.version != 5 || idx.find(0x1234) != 0 || idx.find(1) != -1 {
+		t.Fatalf("wrong index %#v", idx)
+	}
+	if idx.offsets[0][1] != 20 || idx.sizes[0][0] != 30 {
+		t.Fatalf("wrong tables %#v", idx)
+	}
+}
@@ -0,0 +1,120 @@
+package dwo
+
+import "bytes"
+
+// DWARF expression opcodes that are rewritten or need special handling.
+const (
+	opAddr          = 0x03
+	opConst4u       = 0x0c
+	opConst8u       = 0x0e
+	opBra           = 0x28
+	opSkip          = 0x2f
+	opAddrx         = 0xa1
+	opConstx        = 0xa2
+	opGNUAddrIndex  = 0xfb
+	opGNUConstIndex = 0xfc
+)
+
+// opcodeArgs describes the operands of the opcodes of DWARF expressions
+// that have operands: 'a' is an address, 'o' a 4 bytes section offset,
+// '1', '2', '4' and '8' are fixed size constants, 'u' and 's' are LEB128
+// numbers, 'B' is a block preceded by its LEB128 encoded size and 'b' a
+// block preceded by its 1 byte size.
+var opcodeArgs = map[byte]string{
+	opAddr: "a",
+	0x08:   "1", 0x09: "1", 0x0a: "2", 0x0b: "2", 0x0c: "4", 0x0d: "4", 0x0e: "8", 0x0f: "8",
+	0x10: "u", 0x11: "s", 0x15: "1", 0x23: "u", opBra: "2", opSkip: "2",
+	0x90: "u", 0x91: "s", 0x92: "us", 0x93: "u", 0x94: "1", 0x95: "1",
+	0x98: "2", 0x99: "4", 0x9a: "o", 0x9d: "uu", 0x9e: "B",
+	0xa0: "os", opAddrx: "u", opConstx: "u", 0xa3: "B", 0xa4: "ub", 0xa5: "uu",
+	0xa6: "1u", 0xa7: "1u", 0xa8: "u", 0xa9: "u",
+	0xf2: "os", 0xf3: "B", 0xf4: "ub", 0xf5: "uu", 0xf6: "1u", 0xf7: "u", 0xf9: "u",
+	0xfa: "4", opGNUAddrIndex: "u", opGNUConstIndex: "u", 0xfd: "o",
+}
+
+// hasNoArgs returns true if op is a known opcode without operands.
+func hasNoArgs(op byte) bool {
+	switch {
+	case op == 0x06, op >= 0x12 && op <= 0x27 && op != 0x15 && op != 0x23,
+		op >= 0x29 && op <= 0x2e, op >= 0x30 && op <= 0x6f,
+		op == 0x96, op == 0x97, op == 0x9b, op == 0x9c, op == 0x9f,
+		op == 0xe0, op == 0xf0:
+		return true
+	}
+	return false
+}
+
+// rewriteExpr returns a copy of the DWARF expression expr where the
+// opcodes referring to the debug_addr section by index are replaced with
+// equivalent opcodes containing the address, as returned by addr.
+// Expressions containing branches, or opcodes that can not be decoded,
+// are returned unchanged.
+func rewriteExpr(expr []byte, addr func(uint64) (uint64, error), ptrSize int) ([]byte, error) {
+	var out bytes.Buffer
+	b := &buf{data: expr}
+	changed := false
+	for b.off < len(expr) {
+		start := b.off
+		op := b.u8()
+		switch {
+		case op == opAddrx || op == opGNUAddrIndex || op == opConstx || op == opGNUConstIndex:
+			a, err := addr(b.uleb())
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case op == opAddrx || op == opGNUAddrIndex:
+				out.WriteByte(opAddr)
+			case ptrSize == 4:
+				out.WriteByte(opConst4u)
+			default:
+				out.WriteByte(opConst8u)
+			}
+			var p [8]byte
+			putAddr(p[:ptrSize], a)
+			out.Write(p[:ptrSize])
+			changed = true
+			continue
+		case op == opBra || op == opSkip:
+			return expr, nil
+		case op >= 0x70 && op <= 0x8f: // DW_OP_bregN
+			b.sleb()
+		case hasNoArgs(op):
+		default:
+			args, ok := opcodeArgs[op]
+			if !ok {
+				return expr, nil
+			}
+			for _, arg := range args {
+				switch arg {
+				case 'a':
+					b.bytes(ptrSize)
+				case 'o', '4':
+					b.bytes(4)
+				case '1':
+					b.bytes(1)
+				case '2':
+					b.bytes(2)
+				case '8':
+					b.bytes(8)
+				case 'u':
+					b.uleb()
+				case 's':
+					b.sleb()
+				case 'B':
+					b.bytes(int(b.uleb()))
+				case 'b':
+					b.bytes(int(b.u8()))
+				}
+			}
+		}
+		if b.err != nil {
+			return expr, nil
+		}
+		out.Write(expr[start:b.off])
+	}
+	if !changed {
+		return expr, nil
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,1319 @@
+package dwo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/go-delve/delve/pkg/dwarf/util"
+)
+
+// Sections are the DWARF sections of an executable used by Link.
+type Sections struct {
+	Info, Abbrev             []byte
+	Str, StrOffsets, LineStr []byte
+	Addr                     []byte
+	Ranges, Rnglists         []byte
+	Loc, Loclists            []byte
+}
+
+// Finder returns the File containing the split compile unit of a skeleton
+// unit, given the values of the DW_AT_dwo_name and DW_AT_comp_dir
+// attributes of the skeleton unit and its id.
+type Finder func(dwoName, compDir string, id uint64) (*File, error)
+
+// Link returns a copy of sec where the full compile units of the skeleton
+// units in sec.Info are appended to Info, with their abbreviations,
+// location lists and range lists appended to the corresponding sections.
+// The existing contents of all sections are left at the same offsets, the
+// skeleton units that are linked are changed into DW_TAG_skeleton_unit
+// entries (which is already their tag in DWARFv5) so that they can be told
+// apart from ordinary compile units.
+// Returns the number of units linked and an error for each skeleton unit
+// that could not be linked.
+func Link(sec *Sections, ptrSize int, find Finder) (*Sections, int, []error) {
+	out := *sec
+	out.Info = append([]byte(nil), sec.Info...)
+	out.Abbrev = append([]byte(nil), sec.Abbrev...)
+	out.Loc = append([]byte(nil), sec.Loc...)
+
+	l := &linker{sec: sec, out: &out, ptrSize: ptrSize, abbrevs: make(map[uint64]map[uint64]*abbrev)}
+	l.rnglistsBase = uint64(len(sec.Rnglists)) + listsHeaderSize
+	l.loclistsBase = uint64(len(sec.Loclists)) + listsHeaderSize
+
+	var errs []error
+	linked := 0
+	for off := 0; off < len(sec.Info); {
+		u, err := parseUnitHeader(sec.Info, off)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		next := u.end
+		sk, err := l.parseSkeleton(u)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("unit at %#x: %v", off, err))
+		case sk != nil:
+			if err := l.link(sk, find); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", sk.dwoName, err))
+			} else {
+				linked++
+			}
+		}
+		off = next
+	}
+
+	if len(l.rnglists) > 0 {
+		out.Rnglists = appendListsContribution(append([]byte(nil), sec.Rnglists...), l.rnglists, ptrSize)
+	}
+	if len(l.loclists) > 0 {
+		out.Loclists = appendListsContribution(append([]byte(nil), sec.Loclists...), l.loclists, ptrSize)
+	}
+	return &out, linked, errs
+}
+
+// listsHeaderSize is the size of the header of a contribution to the
+// debug_rnglists or debug_loclists sections, in 32-bit DWARF.
+const listsHeaderSize = 12
+
+// appendListsContribution appends to section a contribution to the
+// debug_rnglists or debug_loclists section with the specified body and no
+// offsets table.
+func appendListsContribution(section, body []byte, ptrSize int) []byte {
+	var hdr [listsHeaderSize]byte
+	binary.LittleEndian.PutUint32(hdr[0:], uint32(len(body)+listsHeaderSize-4))
+	binary.LittleEndian.PutUint16(hdr[4:], 5)
+	hdr[6] = byte(ptrSize)
+	return append(append(section, hdr[:]...), body...)
+}
+
+type linker struct {
+	sec     *Sections
+	out     *Sections
+	ptrSize int
+
+	abbrevs map[uint64]map[uint64]*abbrev // abbreviation tables of sec.Abbrev, by offset
+
+	// bodies of the contributions appended to debug_rnglists and
+	// debug_loclists and the offsets where they start.
+	rnglists, loclists         []byte
+	rnglistsBase, loclistsBase uint64
+}
+
+// DWARF constants not defined by debug/dwarf.
+const (
+	utCompile      = 0x01
+	utType         = 0x02
+	utSkeleton     = 0x04
+	utSplitCompile = 0x05
+	utSplitType    = 0x06
+
+	tagSkeletonUnit = 0x4a
+
+	attrLocation       = 0x02
+	attrName           = 0x03
+	attrStmtList       = 0x10
+	attrLowpc          = 0x11
+	attrHighpc         = 0x12
+	attrLanguage       = 0x13
+	attrCompDir        = 0x1b
+	attrStartScope     = 0x2c
+	attrMacroInfo      = 0x43
+	attrRanges         = 0x55
+	attrProducer       = 0x25
+	attrStrOffsetsBase = 0x72
+	attrAddrBase       = 0x73
+	attrRnglistsBase   = 0x74
+	attrDwoName        = 0x76
+	attrMacros         = 0x79
+	attrLoclistsBase   = 0x8c
+	attrGNUMacros      = 0x2119
+	attrGNUDwoName     = 0x2130
+	attrGNUDwoID       = 0x2131
+	attrGNURangesBase  = 0x2132
+	attrGNUAddrBase    = 0x2133
+	attrGNUPubnames    = 0x2134
+	attrGNUPubtypes    = 0x2135
+	attrGNULocviews    = 0x2137
+
+	formAddr          = 0x01
+	formBlock2        = 0x03
+	formBlock4        = 0x04
+	formData2         = 0x05
+	formData4         = 0x06
+	formData8         = 0x07
+	formString        = 0x08
+	formBlock         = 0x09
+	formBlock1        = 0x0a
+	formData1         = 0x0b
+	formFlag          = 0x0c
+	formSdata         = 0x0d
+	formStrp          = 0x0e
+	formUdata         = 0x0f
+	formRefAddr       = 0x10
+	formRef1          = 0x11
+	formRef2          = 0x12
+	formRef4          = 0x13
+	formRef8          = 0x14
+	formRefUdata      = 0x15
+	formIndirect      = 0x16
+	formSecOffset     = 0x17
+	formExprloc       = 0x18
+	formFlagPresent   = 0x19
+	formStrx          = 0x1a
+	formAddrx         = 0x1b
+	formRefSup4       = 0x1c
+	formStrpSup       = 0x1d
+	formData16        = 0x1e
+	formLineStrp      = 0x1f
+	formRefSig8       = 0x20
+	formImplicitConst = 0x21
+	formLoclistx      = 0x22
+	formRnglistx      = 0x23
+	formRefSup8       = 0x24
+	formStrx1         = 0x25
+	formStrx2         = 0x26
+	formStrx3         = 0x27
+	formStrx4         = 0x28
+	formAddrx1        = 0x29
+	formAddrx2        = 0x2a
+	formAddrx3        = 0x2b
+	formAddrx4        = 0x2c
+	formGNUAddrIndex  = 0x1f01
+	formGNUStrIndex   = 0x1f02
+	formGNURefAlt     = 0x1f20
+	formGNUStrpAlt    = 0x1f21
+)
+
+// unitHeader is the header of a unit in a debug_info section.
+type unitHeader struct {
+	data      []byte // the section containing the unit
+	off       int    // offset of the unit in data
+	end       int    // offset of the end of the unit in data
+	dieOff    int    // offset of the first entry of the unit in data
+	is64      bool
+	version   int
+	unitType  int
+	asize     int
+	abbrevOff uint64
+	id        uint64 // DWARFv5 unit id of skeleton and split compile units
+}
+
+func parseUnitHeader(data []byte, off int) (*unitHeader, error) {
+	u := &unitHeader{data: data, off: off}
+	b := &buf{data: data, off: off}
+	length := uint64(b.u32())
+	if length == 0xffffffff {
+		u.is64 = true
+		length = b.u64()
+	}
+	if b.err != nil || length > uint64(len(data)-b.off) {
+		return nil, fmt.Errorf("malformed unit header at %#x", off)
+	}
+	u.end = b.off + int(length)
+	u.version = int(b.u16())
+	switch {
+	case u.version >= 5:
+		u.unitType = int(b.u8())
+		u.asize = int(b.u8())
+		u.abbrevOff = b.offset(u.is64)
+		switch u.unitType {
+		case utSkeleton, utSplitCompile:
+			u.id = b.u64()
+		case utType, utSplitType:
+			b.u64()
+			b.offset(u.is64)
+		}
+	case u.version >= 2:
+		u.unitType = utCompile
+		u.abbrevOff = b.offset(u.is64)
+		u.asize = int(b.u8())
+	default:
+		return nil, fmt.Errorf("unsupported DWARF version %d at %#x", u.version, off)
+	}
+	u.dieOff = b.off
+	if b.err != nil {
+		return nil, fmt.Errorf("malformed unit header at %#x", off)
+	}
+	return u, nil
+}
+
+// offsetSize returns the size of section offsets in u.
+func (u *unitHeader) offsetSize() int {
+	if u.is64 {
+		return 8
+	}
+	return 4
+}
+
+type abbrev struct {
+	tag      uint64
+	children bool
+	fields   []abbrevField
+}
+
+type abbrevField struct {
+	attr, form uint64
+	implicit   int64 // value of DW_FORM_implicit_const fields
+}
+
+// parseAbbrevs parses the abbreviation table at off in data.
+func parseAbbrevs(data []byte, off uint64) (map[uint64]*abbrev, error) {
+	if off >= uint64(len(data)) {
+		return nil, errors.New("abbreviation table offset out of range")
+	}
+	r := make(map[uint64]*abbrev)
+	b := &buf{data: data, off: int(off)}
+	for {
+		code := b.uleb()
+		if code == 0 || b.err != nil {
+			break
+		}
+		a := &abbrev{tag: b.uleb(), children: b.u8() != 0}
+		for {
+			f := abbrevField{attr: b.uleb(), form: b.uleb()}
+			if f.form == formImplicitConst {
+				f.implicit = b.sleb()
+			}
+			if (f.attr == 0 && f.form == 0) || b.err != nil {
+				break
+			}
+			a.fields = append(a.fields, f)
+		}
+		r[code] = a
+	}
+	return r, b.err
+}
+
+// attrValue is the value of an attribute of an entry.
+type attrValue struct {
+	attr, form uint64
+	u          uint64 // integer value, address, offset or index
+	b          []byte // contents of blocks, expressions and strings and raw encoding of data forms
+}
+
+// entry is a debugging information entry.
+type entry struct {
+	off      int // offset of the entry in the section
+	code     uint64
+	tag      uint64
+	children bool
+	attrs    []attrValue
+}
+
+func (e *entry) val(attr uint64) (attrValue, bool) {
+	for _, a := range e.attrs {
+		if a.attr == attr {
+			return a, true
+		}
+	}
+	return attrValue{}, false
+}
+
+// readEntry reads the entry at b, or a null entry if its abbreviation code
+// is zero.
+func readEntry(b *buf, u *unitHeader, abbrevs map[uint64]*abbrev) (*entry, error) {
+	e := &entry{off: b.off}
+	e.code = b.uleb()
+	if e.code == 0 {
+		return e, b.err
+	}
+	a := abbrevs[e.code]
+	if a == nil {
+		return nil, fmt.Errorf("unknown abbreviation code %d at %#x", e.code, e.off)
+	}
+	e.tag, e.children = a.tag, a.children
+	for _, f := range a.fields {
+		v, err := readValue(b, u, f)
+		if err != nil {
+			return nil, err
+		}
+		e.attrs = append(e.attrs, v)
+	}
+	return e, b.err
+}
+
+func readValue(b *buf, u *unitHeader, f abbrevField) (attrValue, error) {
+	v := attrValue{attr: f.attr, form: f.form}
+	if v.form == formIndirect {
+		v.form = b.uleb()
+	}
+	start := b.off
+	switch v.form {
+	case formAddr:
+		v.u = b.addr(u.asize)
+	case formBlock1:
+		v.b = b.bytes(int(b.u8()))
+	case formBlock2:
+		v.b = b.bytes(int(b.u16()))
+	case formBlock4:
+		v.b = b.bytes(int(b.u32()))
+	case formBlock, formExprloc:
+		v.b = b.bytes(int(b.uleb()))
+	case formData1, formFlag, formRef1, formStrx1, formAddrx1:
+		v.u = uint64(b.u8())
+	case formData2, formRef2, formStrx2, formAddrx2:
+		v.u = uint64(b.u16())
+	case formStrx3, formAddrx3:
+		p := b.bytes(3)
+		if len(p) == 3 {
+			v.u = uint64(p[0]) | uint64(p[1])<<8 | uint64(p[2])<<16
+		}
+	case formData4, formRef4, formStrx4, formAddrx4, formRefSup4:
+		v.u = uint64(b.u32())
+	case formData8, formRef8, formRefSig8, formRefSup8:
+		v.u = b.u64()
+	case formData16:
+		b.bytes(16)
+	case formSdata:
+		v.u = uint64(b.sleb())
+	case formUdata, formRefUdata, formStrx, formAddrx, formLoclistx, formRnglistx, formGNUAddrIndex, formGNUStrIndex:
+		v.u = b.uleb()
+	case formString:
+		v.b = b.cstring()
+	case formStrp, formLineStrp, formSecOffset, formStrpSup, formGNURefAlt, formGNUStrpAlt:
+		v.u = b.offset(u.is64)
+	case formRefAddr:
+		if u.version <= 2 {
+			v.u = b.addr(u.asize)
+		} else {
+			v.u = b.offset(u.is64)
+		}
+	case formFlagPresent:
+		v.u = 1
+	case formImplicitConst:
+		v.u = uint64(f.implicit)
+	default:
+		return v, fmt.Errorf("unknown attribute form %#x", v.form)
+	}
+	if v.b == nil {
+		v.b = b.data[start:b.off]
+	}
+	return v, b.err
+}
+
+// skeleton is a skeleton unit of the executable.
+type skeleton struct {
+	unit    *unitHeader
+	root    *entry
+	dwoName string
+	compDir string
+	id      uint64
+	hasID   bool
+
+	strOffsetsBase, addrBase, rnglistsBase, rangesBase uint64
+}
+
+// parseSkeleton returns the skeleton unit described by u, or nil if u is
+// not a skeleton unit.
+func (l *linker) parseSkeleton(u *unitHeader) (*skeleton, error) {
+	if u.unitType != utSkeleton && u.unitType != utCompile {
+		return nil, nil
+	}
+	abbrevs := l.abbrevs[u.abbrevOff]
+	if abbrevs == nil {
+		var err error
+		abbrevs, err = parseAbbrevs(l.sec.Abbrev, u.abbrevOff)
+		if err != nil {
+			return nil, err
+		}
+		l.abbrevs[u.abbrevOff] = abbrevs
+	}
+	root, err := readEntry(&buf{data: u.data, off: u.dieOff}, u, abbrevs)
+	if err != nil || root.code == 0 {
+		return nil, err
+	}
+	sk := &skeleton{unit: u, root: root, id: u.id, hasID: u.unitType == utSkeleton}
+	if a, ok := root.val(attrStrOffsetsBase); ok {
+		sk.strOffsetsBase = a.u
+	}
+	if a, ok := root.val(attrAddrBase); ok {
+		sk.addrBase = a.u
+	} else if a, ok := root.val(attrGNUAddrBase); ok {
+		sk.addrBase = a.u
+	}
+	if a, ok := root.val(attrRnglistsBase); ok {
+		sk.rnglistsBase = a.u
+	}
+	if a, ok := root.val(attrGNURangesBase); ok {
+		sk.rangesBase = a.u
+	}
+	if a, ok := root.val(attrGNUDwoID); ok {
+		sk.id, sk.hasID = a.u, true
+	}
+	str := func(attr uint64) (string, error) {
+		a, ok := root.val(attr)
+		if !ok {
+			return "", nil
+		}
+		return l.skeletonString(sk, a)
+	}
+	for _, attr := range []uint64{attrDwoName, attrGNUDwoName} {
+		if sk.dwoName, err = str(attr); err != nil || sk.dwoName != "" {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sk.dwoName == "" {
+		if u.unitType == utSkeleton {
+			return nil, errors.New("skeleton unit without a dwo name")
+		}
+		return nil, nil
+	}
+	if sk.compDir, err = str(attrCompDir); err != nil {
+		return nil, err
+	}
+	return sk, nil
+}
+
+// skeletonString returns the value of the string attribute a of the root
+// entry of sk.
+func (l *linker) skeletonString(sk *skeleton, a attrValue) (string, error) {
+	switch a.form {
+	case formString:
+		return string(a.b), nil
+	case formStrp:
+		return cstringAt(l.sec.Str, a.u)
+	case formLineStrp:
+		return cstringAt(l.sec.LineStr, a.u)
+	case formStrx, formStrx1, formStrx2, formStrx3, formStrx4, formGNUStrIndex:
+		off, err := readOffsetAt(l.sec.StrOffsets, sk.strOffsetsBase+a.u*uint64(sk.unit.offsetSize()), sk.unit.is64)
+		if err != nil {
+			return "", err
+		}
+		return cstringAt(l.sec.Str, off)
+	}
+	return "", fmt.Errorf("unsupported string form %#x", a.form)
+}
+
+// skeletonAddr returns the value of the address attribute a of the root
+// entry of sk.
+func (l *linker) skeletonAddr(sk *skeleton, a attrValue) (uint64, error) {
+	switch a.form {
+	case formAddr:
+		return a.u, nil
+	case formAddrx, formAddrx1, formAddrx2, formAddrx3, formAddrx4, formGNUAddrIndex:
+		return l.addr(sk, a.u)
+	}
+	return 0, fmt.Errorf("unsupported address form %#x", a.form)
+}
+
+// addr returns the address at index idx of the debug_addr section of the
+// executable, for the split unit of sk.
+func (l *linker) addr(sk *skeleton, idx uint64) (uint64, error) {
+	off := sk.addrBase + idx*uint64(l.ptrSize)
+	if off+uint64(l.ptrSize) > uint64(len(l.sec.Addr)) {
+		return 0, fmt.Errorf("address index %d out of range", idx)
+	}
+	b := &buf{data: l.sec.Addr, off: int(off)}
+	return b.addr(l.ptrSize), nil
+}
+
+// splitUnit is the split compile unit of a skeleton being linked.
+type splitUnit struct {
+	sk      *skeleton
+	c       *contribution
+	unit    *unitHeader
+	abbrevs map[uint64]*abbrev
+
+	// outAbbrevs are the abbreviations of the linked unit, indexed by their
+	// encoding without the code.
+	outAbbrevs   map[string]uint64
+	outAbbrevBuf bytes.Buffer
+	loc          *bytes.Buffer // section the location lists are appended to
+}
+
+// outEntry is an entry of the linked unit.
+type outEntry struct {
+	oldOff int // offset of the entry in the split unit
+	code   uint64
+	attrs  []outAttr
+	size   int
+}
+
+// outAttr is an attribute of an entry of the linked unit. References to
+// other entries are always encoded as DW_FORM_ref4.
+type outAttr struct {
+	attr, form uint64
+	data       []byte
+	ref        int // offset of the referenced entry in the split unit, for DW_FORM_ref4
+}
+
+// link appends the linked split unit of sk to l.out.
+func (l *linker) link(sk *skeleton, find Finder) error {
+	f, err := find(sk.dwoName, sk.compDir, sk.id)
+	if err != nil {
+		return err
+	}
+	id := sk.id
+	if !sk.hasID && f.index != nil {
+		return errors.New("skeleton unit without an id")
+	}
+	c, err := f.unit(id)
+	if err != nil {
+		return err
+	}
+
+	su := &splitUnit{sk: sk, c: c, outAbbrevs: make(map[string]uint64)}
+	for off := 0; off < len(c.info); {
+		u, err := parseUnitHeader(c.info, off)
+		if err != nil {
+			return err
+		}
+		if u.unitType == utSplitCompile || (u.version < 5 && u.unitType == utCompile) {
+			su.unit = u
+			break
+		}
+		off = u.end
+	}
+	if su.unit == nil {
+		return errors.New("no split compile unit found")
+	}
+	if su.unit.is64 {
+		return errors.New("64-bit DWARF split units are not supported")
+	}
+	if su.abbrevs, err = parseAbbrevs(c.abbrev, su.unit.abbrevOff); err != nil {
+		return err
+	}
+
+	// Read the entries of the split unit and convert their attributes.
+	var entries []*outEntry
+	b := &buf{data: c.info, off: su.unit.dieOff}
+	for b.off < su.unit.end {
+		e, err := readEntry(b, su.unit, su.abbrevs)
+		if err != nil {
+			return err
+		}
+		oe := &outEntry{oldOff: e.off - su.unit.off}
+		if e.code != 0 {
+			if len(entries) == 0 {
+				if sk.hasID {
+					if a, ok := e.val(attrGNUDwoID); (ok && a.u != sk.id) || (su.unit.version >= 5 && su.unit.id != sk.id) {
+						return errors.New("unit id mismatch, the file was built separately from the executable")
+					}
+				}
+				if err := l.addSkeletonAttrs(su, e); err != nil {
+					return err
+				}
+			}
+			for _, a := range e.attrs {
+				oa, ok, err := l.convertAttr(su, a)
+				if err != nil {
+					return fmt.Errorf("entry at %#x: %v", e.off, err)
+				}
+				if ok {
+					oe.attrs = append(oe.attrs, oa)
+				}
+			}
+			oe.code = su.abbrevCode(e.tag, e.children, oe.attrs)
+		}
+		entries = append(entries, oe)
+	}
+
+	// Lay out the entries and write the linked unit.
+	hdrSize := 11
+	if su.unit.version >= 5 {
+		hdrSize = 12
+	}
+	newOff := make(map[int]int, len(entries))
+	off := hdrSize
+	for _, oe := range entries {
+		newOff[oe.oldOff] = off
+		oe.size = ulebSize(oe.code)
+		for _, a := range oe.attrs {
+			if a.form == formRef4 {
+				oe.size += 4
+			} else {
+				oe.size += len(a.data)
+			}
+		}
+		off += oe.size
+	}
+
+	var info bytes.Buffer
+	abbrevOff := uint32(len(l.out.Abbrev))
+	w32 := func(x uint32) { binary.Write(&info, binary.LittleEndian, x) }
+	w32(uint32(off - 4))
+	binary.Write(&info, binary.LittleEndian, uint16(su.unit.version))
+	if su.unit.version >= 5 {
+		info.WriteByte(utCompile)
+		info.WriteByte(byte(l.ptrSize))
+		w32(abbrevOff)
+	} else {
+		w32(abbrevOff)
+		info.WriteByte(byte(l.ptrSize))
+	}
+	for _, oe := range entries {
+		util.EncodeULEB128(&info, oe.code)
+		for _, a := range oe.attrs {
+			if a.form == formRef4 {
+				w32(uint32(newOff[a.ref]))
+				continue
+			}
+			info.Write(a.data)
+		}
+	}
+
+	su.outAbbrevBuf.WriteByte(0)
+	l.out.Abbrev = append(l.out.Abbrev, su.outAbbrevBuf.Bytes()...)
+	l.out.Info = append(l.out.Info, info.Bytes()...)
+	return l.hideSkeleton(sk)
+}
+
+// addSkeletonAttrs adds to the root entry e of su the attributes of the
+// skeleton unit that describe the code of the unit.
+func (l *linker) addSkeletonAttrs(su *splitUnit, e *entry) error {
+	sk := su.sk
+	var attrs []attrValue
+	for _, a := range sk.root.attrs {
+		switch a.attr {
+		case attrStmtList:
+			attrs = append(attrs, attrValue{attr: a.attr, form: formSecOffset, u: a.u})
+		case attrLowpc:
+			addr, err := l.skeletonAddr(sk, a)
+			if err != nil {
+				return err
+			}
+			attrs = append(attrs, attrValue{attr: a.attr, form: formAddr, u: addr})
+		case attrHighpc:
+			if a.form == formAddr || a.form == formAddrx || a.form == formGNUAddrIndex || (a.form >= formAddrx1 && a.form <= formAddrx4) {
+				addr, err := l.skeletonAddr(sk, a)
+				if err != nil {
+					return err
+				}
+				attrs = append(attrs, attrValue{attr: a.attr, form: formAddr, u: addr})
+			} else {
+				attrs = append(attrs, attrValue{attr: a.attr, form: formUdata, u: a.u})
+			}
+		case attrRanges:
+			off := a.u
+			if a.form == formRnglistx {
+				var err error
+				off, err = readOffsetAt(l.sec.Rnglists, sk.rnglistsBase+a.u*uint64(sk.unit.offsetSize()), sk.unit.is64)
+				if err != nil {
+					return err
+				}
+				off += sk.rnglistsBase
+			}
+			attrs = append(attrs, attrValue{attr: a.attr, form: formSecOffset, u: off})
+		case attrCompDir, attrName, attrProducer:
+			if _, ok := e.val(a.attr); ok {
+				continue
+			}
+			s, err := l.skeletonString(sk, a)
+			if err != nil {
+				return err
+			}
+			attrs = append(attrs, attrValue{attr: a.attr, form: formString, b: append([]byte(s), 0)})
+		case attrLanguage:
+			if _, ok := e.val(a.attr); !ok {
+				attrs = append(attrs, attrValue{attr: a.attr, form: formUdata, u: a.u})
+			}
+		}
+	}
+	for i := range attrs {
+		a := &attrs[i]
+		switch a.form {
+		case formSecOffset:
+			a.b = make([]byte, 4)
+			binary.LittleEndian.PutUint32(a.b, uint32(a.u))
+		case formAddr:
+			a.b = make([]byte, l.ptrSize)
+			putAddr(a.b, a.u)
+		case formUdata:
+			var b bytes.Buffer
+			util.EncodeULEB128(&b, a.u)
+			a.b = b.Bytes()
+		}
+	}
+	// the attributes of the skeleton are marked with a zero form, so that
+	// convertAttr copies them unchanged.
+	for _, a := range attrs {
+		e.attrs = append(e.attrs, attrValue{attr: a.attr, form: 0, u: a.form, b: a.b})
+	}
+	return nil
+}
+
+// convertAttr converts attribute a of an entry of su to an attribute of
+// the linked unit. Returns false if the attribute should be dropped.
+func (l *linker) convertAttr(su *splitUnit, a attrValue) (outAttr, bool, error) {
+	if a.form == 0 {
+		// attribute of the skeleton unit, already converted
+		return outAttr{attr: a.attr, form: a.u, data: a.b}, true, nil
+	}
+	switch a.attr {
+	case attrStrOffsetsBase, attrAddrBase, attrRnglistsBase, attrLoclistsBase,
+		attrDwoName, attrGNUDwoName, attrGNUDwoID, attrGNURangesBase, attrGNUAddrBase, attrGNUPubnames, attrGNUPubtypes,
+		attrGNULocviews, attrStmtList, attrMacroInfo, attrMacros, attrGNUMacros:
+		return outAttr{}, false, nil
+	}
+	oa := outAttr{attr: a.attr, form: a.form, data: a.b}
+	switch a.form {
+	case formAddrx, formAddrx1, formAddrx2, formAddrx3, formAddrx4, formGNUAddrIndex:
+		addr, err := l.addr(su.sk, a.u)
+		if err != nil {
+			return oa, false, err
+		}
+		oa.form, oa.data = formAddr, make([]byte, l.ptrSize)
+		putAddr(oa.data, addr)
+
+	case formStrp, formStrx, formStrx1, formStrx2, formStrx3, formStrx4, formGNUStrIndex:
+		s, err := su.string(a)
+		if err != nil {
+			return oa, false, err
+		}
+		oa.form, oa.data = formString, append([]byte(s), 0)
+
+	case formString:
+		oa.data = append(append([]byte(nil), a.b...), 0)
+
+	case formBlock1, formBlock2, formBlock4, formBlock:
+		oa.data = encodeBlock(a.form, a.b)
+
+	case formExprloc:
+		expr, err := rewriteExpr(a.b, func(idx uint64) (uint64, error) { return l.addr(su.sk, idx) }, l.ptrSize)
+		if err != nil {
+			return oa, false, err
+		}
+		oa.data = encodeBlock(formExprloc, expr)
+
+	case formImplicitConst:
+		var b bytes.Buffer
+		util.EncodeSLEB128(&b, int64(a.u))
+		oa.form, oa.data = formSdata, b.Bytes()
+
+	case formRef1, formRef2, formRef4, formRef8, formRefUdata:
+		oa.form, oa.ref = formRef4, int(a.u)
+
+	case formRefAddr:
+		ref := int(a.u) - su.unit.off
+		if ref < 0 || ref >= su.unit.end-su.unit.off {
+			return oa, false, nil
+		}
+		oa.form, oa.ref = formRef4, ref
+
+	case formSecOffset, formLoclistx, formRnglistx:
+		off, ok, err := l.convertSectionOffset(su, a)
+		if err != nil || !ok {
+			return oa, false, err
+		}
+		oa.form, oa.data = formSecOffset, make([]byte, 4)
+		binary.LittleEndian.PutUint32(oa.data, uint32(off))
+
+	case formLineStrp, formStrpSup, formGNURefAlt, formGNUStrpAlt, formRefSup4, formRefSup8:
+		// references to sections that are not available
+		return oa, false, nil
+	}
+	return oa, true, nil
+}
+
+// convertSectionOffset converts an attribute of su referring to a range
+// list or to a location list to an offset in the sections of the linked
+// executable.
+func (l *linker) convertSectionOffset(su *splitUnit, a attrValue) (uint64, bool, error) {
+	isRanges := a.attr == attrRanges || a.attr == attrStartScope
+	if su.unit.version < 5 {
+		if isRanges {
+			// DW_AT_ranges of GNU split units refer to the debug_ranges section
+			// of the executable, relative to the DW_AT_GNU_ranges_base attribute
+			// of the skeleton.
+			return su.sk.rangesBase + a.u, true, nil
+		}
+		if a.form != formSecOffset {
+			return 0, false, nil
+		}
+		off, err := l.convertLoc(su, a.u)
+		return off, err == nil, err
+	}
+
+	section := su.c.loclists
+	if isRanges {
+		section = su.c.rnglists
+	}
+	off := a.u
+	if a.form != formSecOffset {
+		// the offsets table of the split unit starts right after the header
+		// of its contribution
+		o, err := readOffsetAt(section, listsHeaderSize+a.u*4, false)
+		if err != nil {
+			return 0, false, err
+		}
+		off = listsHeaderSize + o
+	}
+	if isRanges {
+		o, err := l.convertLists(su, section, off, false)
+		return o, err == nil, err
+	}
+	o, err := l.convertLists(su, section, off, true)
+	return o, err == nil, err
+}
+
+// DWARFv5 range list and location list entries, see DWARFv5 sections 7.25
+// and 7.29. Location lists have default_location at 5, which shifts the
+// following entries by one.
+const (
+	lleEndOfList    = 0x00
+	lleBaseAddressx = 0x01
+	lleStartxEndx   = 0x02
+	lleStartxLength = 0x03
+	lleOffsetPair   = 0x04
+	lleDefaultLoc   = 0x05
+	lleBaseAddress  = 0x06
+	lleStartEnd     = 0x07
+	lleStartLength  = 0x08
+	lleGNUViewPair  = 0x09
+
+	// rleGap is the difference between the DW_LLE_* and DW_RLE_* values of
+	// the entries following DW_LLE_default_location.
+	rleGap = 1
+)
+
+// convertLists copies the range list (or location list, if isLoc is set)
+// at off in section, which belongs to su, to the rnglists (or loclists)
+// contribution of the linked executable, replacing indexes into the
+// debug_addr section with addresses. Returns the offset of the copy.
+func (l *linker) convertLists(su *splitUnit, section []byte, off uint64, isLoc bool) (uint64, error) {
+	if off >= uint64(len(section)) {
+		return 0, errors.New("list offset out of range")
+	}
+	out := &l.rnglists
+	newOff := l.rnglistsBase + uint64(len(l.rnglists))
+	if isLoc {
+		out = &l.loclists
+		newOff = l.loclistsBase + uint64(len(l.loclists))
+	}
+	gap := uint8(rleGap)
+	if isLoc {
+		gap = 0
+	}
+	var w bytes.Buffer
+	addr := func(idx uint64) error {
+		a, err := l.addr(su.sk, idx)
+		if err != nil {
+			return err
+		}
+		var b [8]byte
+		putAddr(b[:l.ptrSize], a)
+		w.Write(b[:l.ptrSize])
+		return nil
+	}
+	expr := func(b *buf) error {
+		e, err := rewriteExpr(b.bytes(int(b.uleb())), func(idx uint64) (uint64, error) { return l.addr(su.sk, idx) }, l.ptrSize)
+		if err != nil {
+			return err
+		}
+		util.EncodeULEB128(&w, uint64(len(e)))
+		w.Write(e)
+		return nil
+	}
+
+	b := &buf{data: section, off: int(off)}
+	for b.err == nil {
+		kind := b.u8()
+		hasExpr := isLoc
+		switch {
+		case kind == lleEndOfList:
+			w.WriteByte(kind)
+			*out = append(*out, w.Bytes()...)
+			return newOff, nil
+		case kind == lleBaseAddressx:
+			w.WriteByte(lleBaseAddress - gap)
+			if err := addr(b.uleb()); err != nil {
+				return 0, err
+			}
+			hasExpr = false
+		case kind == lleStartxEndx:
+			w.WriteByte(lleStartEnd - gap)
+			if err := addr(b.uleb()); err != nil {
+				return 0, err
+			}
+			if err := addr(b.uleb()); err != nil {
+				return 0, err
+			}
+		case kind == lleStartxLength:
+			w.WriteByte(lleStartLength - gap)
+			if err := addr(b.uleb()); err != nil {
+				return 0, err
+			}
+			util.EncodeULEB128(&w, b.uleb())
+		case kind == lleOffsetPair:
+			w.WriteByte(kind)
+			util.EncodeULEB128(&w, b.uleb())
+			util.EncodeULEB128(&w, b.uleb())
+		case isLoc && kind == lleDefaultLoc:
+			w.WriteByte(kind)
+		case kind == lleBaseAddress-gap:
+			w.WriteByte(kind)
+			w.Write(b.bytes(l.ptrSize))
+			hasExpr = false
+		case kind == lleStartEnd-gap:
+			w.WriteByte(kind)
+			w.Write(b.bytes(2 * l.ptrSize))
+		case kind == lleStartLength-gap:
+			w.WriteByte(kind)
+			w.Write(b.bytes(l.ptrSize))
+			util.EncodeULEB128(&w, b.uleb())
+		case isLoc && kind == lleGNUViewPair:
+			// location views are not used, drop the entry
+			b.uleb()
+			b.uleb()
+			hasExpr = false
+		default:
+			return 0, fmt.Errorf("unknown list entry kind %#x", kind)
+		}
+		if hasExpr {
+			if err := expr(b); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return 0, errors.New("truncated list")
+}
+
+// GNU split DWARF location list entries (DW_LLE_GNU_*), used by the
+// debug_loc.dwo section of DWARFv4 split units.
+const (
+	gnuLLEEndOfList  = 0
+	gnuLLEBaseAddr   = 1
+	gnuLLEStartEnd   = 2
+	gnuLLEStartLen   = 3
+	gnuLLEOffsetPair = 4
+	gnuLLEViewPair   = 9
+)
+
+// convertLoc converts the GNU location list at off in the debug_loc.dwo
+// contribution of su to a DWARFv4 location list with absolute addresses,
+// appended to the debug_loc section of the linked executable. Returns the
+// offset of the converted list.
+func (l *linker) convertLoc(su *splitUnit, off uint64) (uint64, error) {
+	if off >= uint64(len(su.c.loc)) {
+		return 0, errors.New("location list offset out of range")
+	}
+	var base uint64
+	if a, ok := su.sk.root.val(attrLowpc); ok {
+		base, _ = l.skeletonAddr(su.sk, a)
+	}
+	var w bytes.Buffer
+	pair := func(start, end uint64) {
+		var b [16]byte
+		putAddr(b[:l.ptrSize], start)
+		putAddr(b[l.ptrSize:2*l.ptrSize], end)
+		w.Write(b[:2*l.ptrSize])
+	}
+	// a base address selection entry makes the following addresses absolute
+	pair(^uint64(0), 0)
+	b := &buf{data: su.c.loc, off: int(off)}
+	for b.err == nil {
+		var start, end uint64
+		var err error
+		switch kind := b.u8(); kind {
+		case gnuLLEEndOfList:
+			pair(0, 0)
+			newOff := uint64(len(l.out.Loc))
+			l.out.Loc = append(l.out.Loc, w.Bytes()...)
+			return newOff, nil
+		case gnuLLEBaseAddr:
+			base, err = l.addr(su.sk, b.uleb())
+			if err != nil {
+				return 0, err
+			}
+			continue
+		case gnuLLEStartEnd:
+			if start, err = l.addr(su.sk, b.uleb()); err == nil {
+				end, err = l.addr(su.sk, b.uleb())
+			}
+		case gnuLLEStartLen:
+			start, err = l.addr(su.sk, b.uleb())
+			end = start + uint64(b.u32())
+		case gnuLLEOffsetPair:
+			start = base + uint64(b.u32())
+			end = base + uint64(b.u32())
+		case gnuLLEViewPair:
+			b.uleb()
+			b.uleb()
+			continue
+		default:
+			return 0, fmt.Errorf("unknown location list entry kind %#x", kind)
+		}
+		if err != nil {
+			return 0, err
+		}
+		e, err := rewriteExpr(b.bytes(int(b.u16())), func(idx uint64) (uint64, error) { return l.addr(su.sk, idx) }, l.ptrSize)
+		if err != nil {
+			return 0, err
+		}
+		if start == end {
+			// an empty range, which would be confused with the end of the list
+			continue
+		}
+		pair(start, end)
+		binary.Write(&w, binary.LittleEndian, uint16(len(e)))
+		w.Write(e)
+	}
+	return 0, errors.New("truncated location list")
+}
+
+// hideSkeleton changes the tag of the root entry of sk to
+// DW_TAG_skeleton_unit, using a copy of its abbreviation table.
+func (l *linker) hideSkeleton(sk *skeleton) error {
+	if sk.root.tag == tagSkeletonUnit {
+		return nil
+	}
+	abbrevs, err := parseAbbrevs(l.sec.Abbrev, sk.unit.abbrevOff)
+	if err != nil {
+		return err
+	}
+	newOff := uint64(len(l.out.Abbrev))
+	var w bytes.Buffer
+	for code, a := range abbrevs {
+		tag := a.tag
+		if code == sk.root.code {
+			tag = tagSkeletonUnit
+		}
+		writeAbbrev(&w, code, tag, a.children, a.fields)
+	}
+	w.WriteByte(0)
+	l.out.Abbrev = append(l.out.Abbrev, w.Bytes()...)
+
+	hdr := l.out.Info[sk.unit.off:]
+	off := 4
+	if sk.unit.is64 {
+		off = 12
+	}
+	off += 2 // version
+	if sk.unit.version >= 5 {
+		off += 2 // unit type and address size
+	}
+	if sk.unit.is64 {
+		binary.LittleEndian.PutUint64(hdr[off:], newOff)
+	} else {
+		binary.LittleEndian.PutUint32(hdr[off:], uint32(newOff))
+	}
+	return nil
+}
+
+// string returns the value of the string attribute a of an entry of su.
+func (su *splitUnit) string(a attrValue) (string, error) {
+	if a.form == formStrp {
+		return cstringAt(su.c.str, a.u)
+	}
+	var base uint64
+	if su.unit.version >= 5 {
+		// the string offsets of the split unit start right after the header
+		// of its contribution
+		base = 8
+	}
+	off, err := readOffsetAt(su.c.strOffsets, base+a.u*4, false)
+	if err != nil {
+		return "", err
+	}
+	return cstringAt(su.c.str, off)
+}
+
+// abbrevCode returns the code of the abbreviation of an entry of the
+// linked unit with the specified tag, children flag and attributes,
+// adding it to the abbreviation table of the linked unit if necessary.
+func (su *splitUnit) abbrevCode(tag uint64, children bool, attrs []outAttr) uint64 {
+	fields := make([]abbrevField, len(attrs))
+	for i, a := range attrs {
+		fields[i] = abbrevField{attr: a.attr, form: a.form}
+	}
+	var key bytes.Buffer
+	writeAbbrev(&key, 0, tag, children, fields)
+	if code, ok := su.outAbbrevs[key.String()]; ok {
+		return code
+	}
+	code := uint64(len(su.outAbbrevs) + 1)
+	su.outAbbrevs[key.String()] = code
+	writeAbbrev(&su.outAbbrevBuf, code, tag, children, fields)
+	return code
+}
+
+func writeAbbrev(w *bytes.Buffer, code, tag uint64, children bool, fields []abbrevField) {
+	util.EncodeULEB128(w, code)
+	util.EncodeULEB128(w, tag)
+	if children {
+		w.WriteByte(1)
+	} else {
+		w.WriteByte(0)
+	}
+	for _, f := range fields {
+		util.EncodeULEB128(w, f.attr)
+		util.EncodeULEB128(w, f.form)
+		if f.form == formImplicitConst {
+			util.EncodeSLEB128(w, f.implicit)
+		}
+	}
+	w.WriteByte(0)
+	w.WriteByte(0)
+}
+
+func encodeBlock(form uint64, data []byte) []byte {
+	var w bytes.Buffer
+	switch form {
+	case formBlock1:
+		w.WriteByte(byte(len(data)))
+	case formBlock2:
+		binary.Write(&w, binary.LittleEndian, uint16(len(data)))
+	case formBlock4:
+		binary.Write(&w, binary.LittleEndian, uint32(len(data)))
+	default:
+		util.EncodeULEB128(&w, uint64(len(data)))
+	}
+	w.Write(data)
+	return w.Bytes()
+}
+
+func putAddr(b []byte, addr uint64) {
+	if len(b) == 4 {
+		binary.LittleEndian.PutUint32(b, uint32(addr))
+	} else {
+		binary.LittleEndian.PutUint64(b, addr)
+	}
+}
+
+func ulebSize(x uint64) int {
+	n := 1
+	for x >= 0x80 {
+		x >>= 7
+		n++
+	}
+	return n
+}
+
+func cstringAt(data []byte, off uint64) (string, error) {
+	if off >= uint64(len(data)) {
+		return "", errors.New("string offset out of range")
+	}
+	i := bytes.IndexByte(data[off:], 0)
+	if i < 0 {
+		return "", errors.New("unterminated string")
+	}
+	return string(data[off : off+uint64(i)]), nil
+}
+
+func readOffsetAt(data []byte, off uint64, is64 bool) (uint64, error) {
+	b := &buf{data: data, off: int(off)}
+	if off > uint64(len(data)) {
+		return 0, errors.New("offset out of range")
+	}
+	r := b.offset(is64)
+	return r, b.err
+}
+
+// buf reads little endian DWARF data.
+type buf struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (b *buf) bytes(n int) []byte {
+	if b.err != nil || n < 0 || b.off+n > len(b.data) {
+		if b.err == nil {
+			b.err = errors.New("unexpected end of section")
+		}
+		return nil
+	}
+	r := b.data[b.off : b.off+n]
+	b.off += n
+	return r
+}
+
+func (b *buf) u8() uint8 {
+	if p := b.bytes(1); p != nil {
+		return p[0]
+	}
+	return 0
+}
+
+func (b *buf) u16() uint16 {
+	if p := b.bytes(2); p != nil {
+		return binary.LittleEndian.Uint16(p)
+	}
+	return 0
+}
+
+func (b *buf) u32() uint32 {
+	if p := b.bytes(4); p != nil {
+		return binary.LittleEndian.Uint32(p)
+	}
+	return 0
+}
+
+func (b *buf) u64() uint64 {
+	if p := b.bytes(8); p != nil {
+		return binary.LittleEndian.Uint64(p)
+	}
+	return 0
+}
+
+func (b *buf) offset(is64 bool) uint64 {
+	if is64 {
+		return b.u64()
+	}
+	return uint64(b.u32())
+}
+
+func (b *buf) addr(size int) uint64 {
+	switch size {
+	case 4:
+		return uint64(b.u32())
+	case 8:
+		return b.u64()
+	}
+	if b.err == nil {
+		b.err = fmt.Errorf("unsupported address size %d", size)
+	}
+	return 0
+}
+
+func (b *buf) uleb() uint64 {
+	var r uint64
+	var shift uint
+	for {
+		c := b.u8()
+		if b.err != nil {
+			return 0
+		}
+		r |= uint64(c&0x7f) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			return r
+		}
+	}
+}
+
+func (b *buf) sleb() int64 {
+	var r int64
+	var shift uint
+	for {
+		c := b.u8()
+		if b.err != nil {
+			return 0
+		}
+		r |= int64(c&0x7f) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			if shift < 64 && c&0x40 != 0 {
+				r |= -1 << shift
+			}
+			return r
+		}
+	}
+}
+
+func (b *buf) cstring() []byte {
+	i := bytes.IndexByte(b.data[b.off:], 0)
+	if i < 0 {
+		b.err = errors.New("unterminated string")
+		return nil
+	}
+	r := b.data[b.off : b.off+i]
+	b.off += i + 1
+	return r
+}
@@ -137,9 +137,7 @@ const (
 // Supported returns true if this pointer encoding is supported.
 func (ptrEnc ptrEnc) Supported() bool {
 	if ptrEnc != ptrEncOmit {
-		szenc := ptrEnc & 0x0f
-		if ((szenc > ptrEncUdata8) && (szenc < ptrEncSigned)) || (szenc > ptrEncSdata8) {
-			// These values aren't defined at the moment
+		if !ptrEnc.SizeSupported() {
 			return false
 		}
 		if ptrEnc&0xf0 != ptrEncPCRel {
@@ -149,3 +147,17 @@ func (ptrEnc ptrEnc) Supported() bool {
 	}
 	return true
 }
+
+// SizeSupported returns true if pointers with this encoding can be read,
+// even if their value can not be computed because of unsupported flags.
+func (ptrEnc ptrEnc) SizeSupported() bool {
+	if ptrEnc == ptrEncOmit {
+		return true
+	}
+	szenc := ptrEnc & 0x0f
+	if ((szenc > ptrEncUdata8) && (szenc < ptrEncSigned)) || (szenc > ptrEncSdata8) {
+		// These values aren't defined at the moment
+		return false
+	}
+	return true
+}
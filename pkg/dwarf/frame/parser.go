@@ -200,9 +200,10 @@ func parseCIE(ctx *parseContext) parsefunc {
 				// Personality function encoded as a pointer encoding byte followed by
 				// the pointer to the personality function encoded as specified by the
 				// pointer encoding.
-				// We don't support this but have to read it anyway.
+				// We don't support this but have to read it anyway, since its value
+				// is discarded any flag (for example DW_EH_PE_indirect) is accepted.
 				e, _ := buf.ReadByte()
-				if !ptrEnc(e).Supported() {
+				if !ptrEnc(e).SizeSupported() {
 					ctx.err = fmt.Errorf("pointer encoding not supported %#x at %#x", e, ctx.offset())
 					return nil
 				}
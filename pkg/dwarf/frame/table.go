@@ -24,7 +24,7 @@ type FrameContext struct {
 	CFA           DWRule
 	Regs          map[uint64]DWRule
 	initialRegs   map[uint64]DWRule
-	prevRegs      map[uint64]DWRule
+	stateStack    []frameState // states saved by DW_CFA_remember_state
 	buf           *bytes.Buffer
 	cie           *CommonInformationEntry
 	RetAddrReg    uint64
@@ -32,6 +32,13 @@ type FrameContext struct {
 	dataAlignment int64
 }
 
+// frameState is a row of the call frame information table saved by
+// DW_CFA_remember_state.
+type frameState struct {
+	cfa  DWRule
+	regs map[uint64]DWRule
+}
+
 // Instructions used to recreate the table from the .debug_frame data.
 const (
 	DW_CFA_nop                = 0x0        // No ops
@@ -123,7 +130,6 @@ func executeCIEInstructions(cie *CommonInformationEntry) *FrameContext {
 		Regs:          make(map[uint64]DWRule),
 		RetAddrReg:    cie.ReturnAddressRegister,
 		initialRegs:   make(map[uint64]DWRule),
-		prevRegs:      make(map[uint64]DWRule),
 		codeAlignment: cie.CodeAlignmentFactor,
 		dataAlignment: cie.DataAlignmentFactor,
 		buf:           bytes.NewBuffer(initialInstructions),
@@ -308,11 +314,21 @@ func register(frame *FrameContext) {
 }
 
 func rememberstate(frame *FrameContext) {
-	frame.prevRegs = frame.Regs
+	regs := make(map[uint64]DWRule, len(frame.Regs))
+	for reg, rule := range frame.Regs {
+		regs[reg] = rule
+	}
+	frame.stateStack = append(frame.stateStack, frameState{cfa: frame.CFA, regs: regs})
 }
 
 func restorestate(frame *FrameContext) {
-	frame.Regs = frame.prevRegs
+	if len(frame.stateStack) == 0 {
+		return
+	}
+	state := frame.stateStack[len(frame.stateStack)-1]
+	frame.stateStack = frame.stateStack[:len(frame.stateStack)-1]
+	frame.CFA = state.cfa
+	frame.Regs = state.regs
 }
 
 func restoreextended(frame *FrameContext) {
@@ -0,0 +1,55 @@
+package frame
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestRememberRestoreState(t *testing.T) {
+	cie := &CommonInformationEntry{
+		CodeAlignmentFactor:   1,
+		DataAlignmentFactor:   -8,
+		ReturnAddressRegister: 16,
+		InitialInstructions: []byte{
+			DW_CFA_def_cfa, 7, 8, // CFA = rsp+8
+			DW_CFA_offset | 16, 1, // rip at CFA-8
+		},
+	}
+	fde := &FrameDescriptionEntry{
+		CIE:   cie,
+		begin: 0x100,
+		size:  0x100,
+		order: binary.LittleEndian,
+		Instructions: []byte{
+			DW_CFA_advance_loc | 1,
+			DW_CFA_def_cfa_offset, 16, // CFA = rsp+16
+			DW_CFA_offset | 6, 2, // rbp at CFA-16
+			DW_CFA_advance_loc | 0x10,
+			DW_CFA_remember_state,
+			DW_CFA_def_cfa_offset, 8, // epilogue, CFA = rsp+8
+			DW_CFA_restore | 6,
+			DW_CFA_advance_loc | 1,
+			DW_CFA_restore_state, // code after the epilogue
+		},
+	}
+
+	for _, tc := range []struct {
+		pc        uint64
+		cfaOffset int64
+		rbpRule   Rule
+	}{
+		{0x100, 8, RuleUndefined},
+		{0x101, 16, RuleOffset},
+		{0x111, 8, RuleUndefined},
+		{0x112, 16, RuleOffset},
+		{0x150, 16, RuleOffset},
+	} {
+		ctx := fde.EstablishFrame(tc.pc)
+		if ctx.CFA.Offset != tc.cfaOffset {
+			t.Errorf("%#x: wrong CFA offset %d (expected %d)", tc.pc, ctx.CFA.Offset, tc.cfaOffset)
+		}
+		if ctx.Regs[6].Rule != tc.rbpRule {
+			t.Errorf("%#x: wrong rule for rbp %v (expected %v)", tc.pc, ctx.Regs[6].Rule, tc.rbpRule)
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package godwarf
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/go-delve/delve/pkg/dwarf/util"
+)
+
+// NameKind is the kind of entity named by a NameEntry.
+type NameKind uint8
+
+const (
+	NameOther NameKind = iota
+	NameFunction
+	NameVariable
+	NameType
+)
+
+// NameEntry is an entry of a NameIndex.
+type NameEntry struct {
+	Name string
+	Kind NameKind
+	CU   dwarf.Offset // offset of the entry of the compile unit defining the entity
+	// Offset is the offset of the debugging information entry of the entity,
+	// it is zero if the index does not record it (.gdb_index).
+	Offset dwarf.Offset
+}
+
+// NameIndex is an accelerator table mapping names of functions, variables
+// and types to the compile units, and possibly the debugging information
+// entries, defining them. It is read either from the debug_names section
+// of DWARFv5 or from the .gdb_index section produced by gdb-add-index and
+// some linkers.
+type NameIndex struct {
+	entries    []NameEntry
+	byName     map[string][]int
+	byUnit     map[dwarf.Offset][]int
+	hasOffsets bool
+}
+
+func newNameIndex(hasOffsets bool) *NameIndex {
+	return &NameIndex{byName: make(map[string][]int), byUnit: make(map[dwarf.Offset][]int), hasOffsets: hasOffsets}
+}
+
+func (idx *NameIndex) add(e NameEntry) {
+	idx.entries = append(idx.entries, e)
+	i := len(idx.entries) - 1
+	idx.byName[e.Name] = append(idx.byName[e.Name], i)
+	idx.byUnit[e.CU] = append(idx.byUnit[e.CU], i)
+}
+
+func (idx *NameIndex) addUnit(cu dwarf.Offset) {
+	if _, ok := idx.byUnit[cu]; !ok {
+		idx.byUnit[cu] = nil
+	}
+}
+
+// HasOffsets returns true if the entries of idx record the offset of the
+// debugging information entries they describe.
+func (idx *NameIndex) HasOffsets() bool {
+	return idx.hasOffsets
+}
+
+// Covers returns true if the compile unit whose entry is at offset cu is
+// indexed by idx.
+func (idx *NameIndex) Covers(cu dwarf.Offset) bool {
+	_, ok := idx.byUnit[cu]
+	return ok
+}
+
+// Lookup returns the entries for name.
+func (idx *NameIndex) Lookup(name string) []NameEntry {
+	return idx.collect(idx.byName[name])
+}
+
+// Unit returns the entries of the compile unit whose entry is at offset
+// cu, sorted by offset.
+func (idx *NameIndex) Unit(cu dwarf.Offset) []NameEntry {
+	r := idx.collect(idx.byUnit[cu])
+	sort.SliceStable(r, func(i, j int) bool { return r[i].Offset < r[j].Offset })
+	return r
+}
+
+// Names returns the names of the entries of idx of the specified kind.
+func (idx *NameIndex) Names(kind NameKind) []string {
+	r := []string{}
+	for name, is := range idx.byName {
+		for _, i := range is {
+			if idx.entries[i].Kind == kind {
+				r = append(r, name)
+				break
+			}
+		}
+	}
+	return r
+}
+
+func (idx *NameIndex) collect(is []int) []NameEntry {
+	r := make([]NameEntry, len(is))
+	for j, i := range is {
+		r[j] = idx.entries[i]
+	}
+	return r
+}
+
+// nameKindOfTag returns the kind of entity described by a debugging
+// information entry with the specified tag.
+func nameKindOfTag(tag dwarf.Tag) NameKind {
+	switch tag {
+	case dwarf.TagSubprogram:
+		return NameFunction
+	case dwarf.TagVariable:
+		return NameVariable
+	case dwarf.TagArrayType, dwarf.TagBaseType, dwarf.TagClassType, dwarf.TagStructType, dwarf.TagUnionType, dwarf.TagConstType, dwarf.TagVolatileType, dwarf.TagRestrictType, dwarf.TagEnumerationType, dwarf.TagPointerType, dwarf.TagSubroutineType, dwarf.TagTypedef, dwarf.TagUnspecifiedType:
+		return NameType
+	}
+	return NameOther
+}
+
+var errMalformedNameIndex = errors.New("malformed name index")
+
+// nameIndexBuf reads the little endian contents of a name index section.
+type nameIndexBuf struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (b *nameIndexBuf) bytes(n int) []byte {
+	if b.err != nil || n < 0 || n > len(b.data)-b.off {
+		b.err = errMalformedNameIndex
+		return make([]byte, 8)
+	}
+	r := b.data[b.off : b.off+n]
+	b.off += n
+	return r
+}
+
+func (b *nameIndexBuf) u8() uint8   { return b.bytes(1)[0] }
+func (b *nameIndexBuf) u16() uint16 { return binary.LittleEndian.Uint16(b.bytes(2)) }
+func (b *nameIndexBuf) u32() uint32 { return binary.LittleEndian.Uint32(b.bytes(4)) }
+func (b *nameIndexBuf) u64() uint64 { return binary.LittleEndian.Uint64(b.bytes(8)) }
+
+func (b *nameIndexBuf) offset(dwarf64 bool) uint64 {
+	if dwarf64 {
+		return b.u64()
+	}
+	return uint64(b.u32())
+}
+
+func (b *nameIndexBuf) uleb() uint64 {
+	if b.err != nil || b.off >= len(b.data) {
+		b.err = errMalformedNameIndex
+		return 0
+	}
+	buf := bytes.NewBuffer(b.data[b.off:])
+	r, n := util.DecodeULEB128(buf)
+	b.off += int(n)
+	return r
+}
+
+func (b *nameIndexBuf) sleb() int64 {
+	if b.err != nil || b.off >= len(b.data) {
+		b.err = errMalformedNameIndex
+		return 0
+	}
+	buf := bytes.NewBuffer(b.data[b.off:])
+	r, n := util.DecodeSLEB128(buf)
+	b.off += int(n)
+	return r
+}
+
+// Index attributes of debug_names, see DWARFv5 section 6.1.1.4.7.
+const (
+	idxCompileUnit = 1
+	idxDieOffset   = 3
+)
+
+// ParseDebugNames parses the contents of a debug_names section, str and
+// info are the contents of the debug_str and debug_info sections. See
+// DWARFv5 section 6.1.1 page 137 and following.
+func ParseDebugNames(data, str, info []byte) (*NameIndex, error) {
+	idx := newNameIndex(true)
+	for off := 0; off < len(data); {
+		next, err := parseNameTable(idx, data, off, str, info)
+		if err != nil {
+			return nil, err
+		}
+		off = next
+	}
+	return idx, nil
+}
+
+type namesAbbrev struct {
+	tag   dwarf.Tag
+	attrs [][2]uint64 // index attribute and form
+}
+
+// parseNameTable parses the name table at off in data, adding its entries
+// to idx, and returns the offset of the following table.
+func parseNameTable(idx *NameIndex, data []byte, off int, str, info []byte) (int, error) {
+	b := &nameIndexBuf{data: data, off: off}
+	length := uint64(b.u32())
+	dwarf64 := false
+	if length == 0xffffffff {
+		dwarf64 = true
+		length = b.u64()
+	}
+	end := b.off + int(length)
+	if b.err != nil || length > uint64(len(data)-b.off) {
+		return 0, errMalformedNameIndex
+	}
+	if version := b.u16(); version != 5 {
+		return 0, fmt.Errorf("unsupported debug_names version %d", version)
+	}
+	b.u16() // padding
+	cuCount := int(b.u32())
+	localTUCount := int(b.u32())
+	foreignTUCount := int(b.u32())
+	bucketCount := int(b.u32())
+	nameCount := int(b.u32())
+	abbrevSize := int(b.u32())
+	augmentationSize := int(b.u32())
+	b.bytes((augmentationSize + 3) &^ 3)
+
+	offsz := 4
+	if dwarf64 {
+		offsz = 8
+	}
+	// offsets of the compile unit headers, DIE offsets are relative to them
+	cus := make([]dwarf.Offset, cuCount)
+	for i := range cus {
+		cus[i] = dwarf.Offset(b.offset(dwarf64))
+		idx.addUnit(unitEntryOffset(info, cus[i]))
+	}
+	b.bytes(localTUCount*offsz + foreignTUCount*8)
+	if bucketCount > 0 {
+		b.bytes(bucketCount*4 + nameCount*4)
+	}
+	strOffs := &nameIndexBuf{data: data, off: b.off}
+	b.bytes(nameCount * offsz)
+	entryOffs := &nameIndexBuf{data: data, off: b.off}
+	b.bytes(nameCount * offsz)
+
+	abbrevs := make(map[uint64]*namesAbbrev)
+	ab := &nameIndexBuf{data: b.bytes(abbrevSize)}
+	for ab.err == nil {
+		code := ab.uleb()
+		if code == 0 {
+			break
+		}
+		a := &namesAbbrev{tag: dwarf.Tag(ab.uleb())}
+		for ab.err == nil {
+			attr, form := ab.uleb(), ab.uleb()
+			if attr == 0 && form == 0 {
+				break
+			}
+			a.attrs = append(a.attrs, [2]uint64{attr, form})
+		}
+		abbrevs[code] = a
+	}
+	pool := b.off
+	if b.err != nil || ab.err != nil {
+		return 0, errMalformedNameIndex
+	}
+
+	for i := 0; i < nameCount; i++ {
+		name, err := cstring(str, strOffs.offset(dwarf64))
+		if err != nil {
+			return 0, err
+		}
+		eb := &nameIndexBuf{data: data[:end], off: pool + int(entryOffs.offset(dwarf64))}
+		for eb.err == nil {
+			code := eb.uleb()
+			if code == 0 {
+				break
+			}
+			a := abbrevs[code]
+			if a == nil {
+				return 0, errMalformedNameIndex
+			}
+			cuIdx, dieOff := uint64(0), uint64(0)
+			hasDieOff := false
+			for _, attr := range a.attrs {
+				v := readNameIndexValue(eb, attr[1], dwarf64)
+				switch attr[0] {
+				case idxCompileUnit:
+					cuIdx = v
+				case idxDieOffset:
+					dieOff, hasDieOff = v, true
+				}
+			}
+			if !hasDieOff || cuIdx >= uint64(len(cus)) {
+				// entries of type units are not indexed
+				continue
+			}
+			cu := cus[cuIdx]
+			idx.add(NameEntry{Name: name, Kind: nameKindOfTag(a.tag), CU: unitEntryOffset(info, cu), Offset: cu + dwarf.Offset(dieOff)})
+		}
+		if eb.err != nil || strOffs.err != nil || entryOffs.err != nil {
+			return 0, errMalformedNameIndex
+		}
+	}
+	return end, nil
+}
+
+func readNameIndexValue(b *nameIndexBuf, form uint64, dwarf64 bool) uint64 {
+	switch form {
+	case 0x0b, 0x11, 0x0c: // data1, ref1, flag
+		return uint64(b.u8())
+	case 0x05, 0x12: // data2, ref2
+		return uint64(b.u16())
+	case 0x06, 0x13: // data4, ref4
+		return uint64(b.u32())
+	case 0x07, 0x14, 0x20: // data8, ref8, ref_sig8
+		return b.u64()
+	case 0x0f, 0x15: // udata, ref_udata
+		return b.uleb()
+	case 0x0d: // sdata
+		return uint64(b.sleb())
+	case 0x10, 0x17: // ref_addr, sec_offset
+		return b.offset(dwarf64)
+	case 0x19: // flag_present
+		return 1
+	}
+	b.err = fmt.Errorf("unsupported form %#x in debug_names", form)
+	return 0
+}
+
+// ParseGdbIndex parses the contents of a .gdb_index section, info is the
+// contents of the debug_info section. Versions 7 and 8 are supported. See
+// https://sourceware.org/gdb/onlinedocs/gdb/Index-Section-Format.html.
+// The .gdb_index section does not record the offsets of debugging
+// information entries.
+func ParseGdbIndex(data, info []byte) (*NameIndex, error) {
+	b := &nameIndexBuf{data: data}
+	version := b.u32()
+	if b.err == nil && (version < 7 || version > 8) {
+		return nil, fmt.Errorf("unsupported .gdb_index version %d", version)
+	}
+	cuListOff, typesListOff, _, symbolTableOff, constantPoolOff := b.u32(), b.u32(), b.u32(), b.u32(), b.u32()
+	if b.err != nil || cuListOff > typesListOff || typesListOff > symbolTableOff || symbolTableOff > constantPoolOff || int(constantPoolOff) > len(data) {
+		return nil, errMalformedNameIndex
+	}
+
+	idx := newNameIndex(false)
+	cb := &nameIndexBuf{data: data[:typesListOff], off: int(cuListOff)}
+	var cus []dwarf.Offset
+	for cb.off < len(cb.data) && cb.err == nil {
+		cu := unitEntryOffset(info, dwarf.Offset(cb.u64()))
+		cb.u64() // length
+		cus = append(cus, cu)
+		idx.addUnit(cu)
+	}
+
+	pool := data[constantPoolOff:]
+	sb := &nameIndexBuf{data: data[:constantPoolOff], off: int(symbolTableOff)}
+	for sb.off < len(sb.data) && sb.err == nil {
+		nameOff, vecOff := sb.u32(), sb.u32()
+		if nameOff == 0 && vecOff == 0 {
+			continue
+		}
+		name, err := cstring(pool, uint64(nameOff))
+		if err != nil {
+			return nil, err
+		}
+		vb := &nameIndexBuf{data: pool, off: int(vecOff)}
+		n := vb.u32()
+		for i := uint32(0); i < n && vb.err == nil; i++ {
+			v := vb.u32()
+			cuIdx := int(v & 0xffffff)
+			if cuIdx >= len(cus) {
+				// symbol defined in a type unit
+				continue
+			}
+			kind := NameOther
+			switch (v >> 28) & 7 {
+			case 1:
+				kind = NameType
+			case 2:
+				kind = NameVariable
+			case 3:
+				kind = NameFunction
+			}
+			idx.add(NameEntry{Name: name, Kind: kind, CU: cus[cuIdx]})
+		}
+		if vb.err != nil {
+			return nil, errMalformedNameIndex
+		}
+	}
+	if cb.err != nil || sb.err != nil {
+		return nil, errMalformedNameIndex
+	}
+	return idx, nil
+}
+
+// unitEntryOffset returns the offset of the first entry of the unit whose
+// header is at offset off of info, which is the offset used for compile
+// units by debug/dwarf.
+func unitEntryOffset(info []byte, off dwarf.Offset) dwarf.Offset {
+	b := &nameIndexBuf{data: info, off: int(off)}
+	dwarf64 := b.u32() == 0xffffffff
+	offsz := 4
+	if dwarf64 {
+		b.u64()
+		offsz = 8
+	}
+	if version := b.u16(); version >= 5 {
+		// unit_type, address_size, debug_abbrev_offset and the unit type
+		// specific fields
+		switch unitType := b.u8(); unitType {
+		case 0x04, 0x05: // DW_UT_skeleton, DW_UT_split_compile
+			b.bytes(1 + offsz + 8)
+		case 0x02, 0x06: // DW_UT_type, DW_UT_split_type
+			b.bytes(1 + offsz + 8 + offsz)
+		default:
+			b.bytes(1 + offsz)
+		}
+	} else {
+		// debug_abbrev_offset and address_size
+		b.bytes(offsz + 1)
+	}
+	if b.err != nil {
+		return off
+	}
+	return dwarf.Offset(b.off)
+}
+
+func cstring(data []byte, off uint64) (string, error) {
+	if off >= uint64(len(data)) {
+		return "", errMalformedNameIndex
+	}
+	n := bytes.IndexByte(data[off:], 0)
+	if n < 0 {
+		return "", errMalformedNameIndex
+	}
+	return string(data[off : off+uint64(n)]), nil
+}
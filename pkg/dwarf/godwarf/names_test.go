@@ -0,0 +1,150 @@
+package godwarf
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// buildAccelFixture builds the program in _fixtures/accelnames.ll. If
+// gdbIndex is not set it is compiled with llc, which produces a
+// debug_names section, otherwise the equivalent C program is compiled
+// with gcc and linked with gold, which produces a .gdb_index section.
+func buildAccelFixture(t *testing.T, gdbIndex bool) *elf.File {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("only supported on linux/amd64")
+	}
+	tools := []string{"llc", "gcc"}
+	if gdbIndex {
+		tools = []string{"gcc", "ld.gold"}
+	}
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found", tool)
+		}
+	}
+	src, _ := filepath.Abs("../../../_fixtures/accelnames.ll")
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "accelnames")
+	run := func(name string, args ...string) {
+		if out, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+			t.Skipf("%s failed: %v\n%s", name, err, out)
+		}
+	}
+	if gdbIndex {
+		csrc := filepath.Join(dir, "accelnames.c")
+		if err := os.WriteFile(csrc, []byte(accelNamesC), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		run("gcc", "-g", "-ggnu-pubnames", "-no-pie", "-fuse-ld=gold", "-Wl,--gdb-index", "-o", exe, csrc)
+	} else {
+		obj := filepath.Join(dir, "accelnames.o")
+		run("llc", "-filetype=obj", "-accel-tables=Dwarf", "-o", obj, src)
+		run("gcc", "-no-pie", "-o", exe, obj)
+	}
+	f, err := elf.Open(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// accelNamesC is the C program _fixtures/accelnames.ll was written from.
+const accelNamesC = `struct point { int x, y; };
+struct point origin = {1, 2};
+int add(int a, int b) { return a + b; }
+int main() { return add(origin.x, 3); }
+`
+
+func checkNameIndex(t *testing.T, f *elf.File, idx *NameIndex) {
+	d, err := f.DWARF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdr := d.Reader()
+	cu, err := rdr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !idx.Covers(cu.Offset) {
+		t.Errorf("compile unit %#x not covered", cu.Offset)
+	}
+
+	for _, tc := range []struct {
+		name string
+		kind NameKind
+		tag  dwarf.Tag
+	}{
+		{"add", NameFunction, dwarf.TagSubprogram},
+		{"main", NameFunction, dwarf.TagSubprogram},
+		{"origin", NameVariable, dwarf.TagVariable},
+		{"point", NameType, dwarf.TagStructType},
+		{"int", NameType, dwarf.TagBaseType},
+	} {
+		entries := idx.Lookup(tc.name)
+		if len(entries) != 1 {
+			t.Errorf("%s: wrong number of entries %v", tc.name, entries)
+			continue
+		}
+		e := entries[0]
+		if e.Kind != tc.kind || e.CU != cu.Offset {
+			t.Errorf("%s: wrong entry %#v", tc.name, e)
+		}
+		if !idx.HasOffsets() {
+			continue
+		}
+		rdr.Seek(e.Offset)
+		entry, err := rdr.Next()
+		if err != nil || entry == nil {
+			t.Errorf("%s: could not read entry at %#x: %v", tc.name, e.Offset, err)
+			continue
+		}
+		if name, _ := entry.Val(dwarf.AttrName).(string); entry.Tag != tc.tag || name != tc.name {
+			t.Errorf("%s: entry at %#x is %v %q", tc.name, e.Offset, entry.Tag, name)
+		}
+	}
+	if len(idx.Lookup("notexist")) != 0 {
+		t.Errorf("found entries for non-existent name")
+	}
+}
+
+func TestParseDebugNames(t *testing.T) {
+	f := buildAccelFixture(t, false)
+	data, err := GetDebugSectionElf(f, "names")
+	if err != nil {
+		t.Fatal(err)
+	}
+	str, _ := GetDebugSectionElf(f, "str")
+	info, _ := GetDebugSectionElf(f, "info")
+	idx, err := ParseDebugNames(data, str, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !idx.HasOffsets() {
+		t.Errorf("debug_names index without offsets")
+	}
+	checkNameIndex(t, f, idx)
+}
+
+func TestParseGdbIndex(t *testing.T) {
+	f := buildAccelFixture(t, true)
+	sec := f.Section(".gdb_index")
+	if sec == nil {
+		t.Skip("linker did not produce a .gdb_index section")
+	}
+	data, err := sec.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := GetDebugSectionElf(f, "info")
+	idx, err := ParseGdbIndex(data, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNameIndex(t, f, idx)
+}
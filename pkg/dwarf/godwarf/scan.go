@@ -0,0 +1,370 @@
+package godwarf
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// UnitEntry describes a debugging information entry that is a direct child
+// of the root entry of a unit.
+type UnitEntry struct {
+	Offset   dwarf.Offset
+	Tag      dwarf.Tag
+	Children bool
+}
+
+// UnitScanner lists the children of the root entry of the units of a
+// debug_info section without decoding their attributes, which is much
+// faster than reading them with debug/dwarf: only the abbreviation code of
+// each entry is read and the size of its attributes is computed from their
+// form. It is safe for concurrent use.
+type UnitScanner struct {
+	info, abbrev []byte
+	units        map[dwarf.Offset]scanUnit
+
+	mu      sync.Mutex // protects abbrevs
+	abbrevs map[uint64]map[uint64]*scanAbbrev
+}
+
+type scanUnit struct {
+	version      uint16
+	dwarf64      bool
+	addrSize     int
+	abbrevOff    uint64
+	end          dwarf.Offset // offset of the header of the next unit
+	nextEntryOff dwarf.Offset // offset of the root entry of the next unit, 0 if there is no next unit
+}
+
+type scanAbbrev struct {
+	tag      dwarf.Tag
+	children bool
+	forms    []uint64
+}
+
+var errMalformedUnit = errors.New("malformed debug_info unit")
+
+// NewUnitScanner returns a UnitScanner for the debug_info section info,
+// abbrev is the contents of the debug_abbrev section.
+func NewUnitScanner(info, abbrev []byte) *UnitScanner {
+	s := &UnitScanner{info: info, abbrev: abbrev, units: make(map[dwarf.Offset]scanUnit), abbrevs: make(map[uint64]map[uint64]*scanAbbrev)}
+	var prev dwarf.Offset
+	for off := 0; off < len(info); {
+		b := &unitBuf{data: info, off: off}
+		var u scanUnit
+		length := uint64(b.u32())
+		if length == 0xffffffff {
+			u.dwarf64 = true
+			length = b.u64()
+		}
+		if b.err != nil || length > uint64(len(info)-b.off) {
+			break
+		}
+		u.end = dwarf.Offset(b.off) + dwarf.Offset(length)
+		u.version = b.u16()
+		if u.version >= 5 {
+			unitType := b.u8()
+			u.addrSize = int(b.u8())
+			u.abbrevOff = b.offset(u.dwarf64)
+			switch unitType {
+			case 0x04, 0x05: // DW_UT_skeleton, DW_UT_split_compile
+				b.u64()
+			case 0x02, 0x06: // DW_UT_type, DW_UT_split_type
+				b.u64()
+				b.offset(u.dwarf64)
+			}
+		} else {
+			u.abbrevOff = b.offset(u.dwarf64)
+			u.addrSize = int(b.u8())
+		}
+		if b.err != nil {
+			break
+		}
+		entryOff := dwarf.Offset(b.off)
+		if prev != 0 {
+			pu := s.units[prev]
+			pu.nextEntryOff = entryOff
+			s.units[prev] = pu
+		}
+		s.units[entryOff] = u
+		prev = entryOff
+		off = int(u.end)
+	}
+	return s
+}
+
+// Children returns the direct children of the root entry of the unit whose
+// root entry is at offset off (the offset debug/dwarf uses for compile
+// units) and the offset of the root entry of the following unit, which is
+// 0 if off is the last unit.
+func (s *UnitScanner) Children(off dwarf.Offset) ([]UnitEntry, dwarf.Offset, error) {
+	u, ok := s.units[off]
+	if !ok {
+		return nil, 0, fmt.Errorf("no unit at %#x", off)
+	}
+	abbrevs, err := s.unitAbbrevs(u.abbrevOff)
+	if err != nil {
+		return nil, 0, err
+	}
+	b := &unitBuf{data: s.info[:u.end], off: int(off)}
+	var r []UnitEntry
+	depth := 0
+	for b.off < len(b.data) {
+		entryOff := dwarf.Offset(b.off)
+		code := b.uleb()
+		if b.err != nil {
+			return nil, 0, b.err
+		}
+		if code == 0 {
+			depth--
+			if depth <= 0 {
+				break
+			}
+			continue
+		}
+		a := abbrevs[code]
+		if a == nil {
+			return nil, 0, fmt.Errorf("unknown abbreviation code %d at %#x", code, entryOff)
+		}
+		if depth == 1 {
+			r = append(r, UnitEntry{Offset: entryOff, Tag: a.tag, Children: a.children})
+		}
+		for _, form := range a.forms {
+			if err := skipForm(b, form, &u); err != nil {
+				return nil, 0, fmt.Errorf("%v at %#x", err, entryOff)
+			}
+		}
+		if a.children {
+			depth++
+		} else if depth == 0 {
+			break
+		}
+	}
+	if b.err != nil {
+		return nil, 0, b.err
+	}
+	return r, u.nextEntryOff, nil
+}
+
+// NextUnit returns the offset of the root entry of the unit following the
+// one whose root entry is at offset off, 0 if off is the last unit. If off
+// is not the offset of the root entry of a unit false is returned.
+func (s *UnitScanner) NextUnit(off dwarf.Offset) (dwarf.Offset, bool) {
+	u, ok := s.units[off]
+	return u.nextEntryOff, ok
+}
+
+func (s *UnitScanner) unitAbbrevs(off uint64) (map[uint64]*scanAbbrev, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if abbrevs := s.abbrevs[off]; abbrevs != nil {
+		return abbrevs, nil
+	}
+	if off >= uint64(len(s.abbrev)) {
+		return nil, errMalformedUnit
+	}
+	abbrevs := make(map[uint64]*scanAbbrev)
+	b := &unitBuf{data: s.abbrev, off: int(off)}
+	for {
+		code := b.uleb()
+		if code == 0 || b.err != nil {
+			break
+		}
+		a := &scanAbbrev{tag: dwarf.Tag(b.uleb()), children: b.u8() != 0}
+		for {
+			attr, form := b.uleb(), b.uleb()
+			if attr == 0 && form == 0 || b.err != nil {
+				break
+			}
+			if form == formImplicitConst {
+				b.sleb()
+			}
+			a.forms = append(a.forms, form)
+		}
+		abbrevs[code] = a
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	s.abbrevs[off] = abbrevs
+	return abbrevs, nil
+}
+
+// Attribute forms, see DWARFv5 section 7.5.6 and the GNU extensions.
+const (
+	formAddr          = 0x01
+	formBlock2        = 0x03
+	formBlock4        = 0x04
+	formData2         = 0x05
+	formData4         = 0x06
+	formData8         = 0x07
+	formString        = 0x08
+	formBlock         = 0x09
+	formBlock1        = 0x0a
+	formData1         = 0x0b
+	formFlag          = 0x0c
+	formSdata         = 0x0d
+	formStrp          = 0x0e
+	formUdata         = 0x0f
+	formRefAddr       = 0x10
+	formRef1          = 0x11
+	formRef2          = 0x12
+	formRef4          = 0x13
+	formRef8          = 0x14
+	formRefUdata      = 0x15
+	formIndirect      = 0x16
+	formSecOffset     = 0x17
+	formExprloc       = 0x18
+	formFlagPresent   = 0x19
+	formStrx          = 0x1a
+	formAddrx         = 0x1b
+	formRefSup4       = 0x1c
+	formStrpSup       = 0x1d
+	formData16        = 0x1e
+	formLineStrp      = 0x1f
+	formRefSig8       = 0x20
+	formImplicitConst = 0x21
+	formLoclistx      = 0x22
+	formRnglistx      = 0x23
+	formRefSup8       = 0x24
+	formStrx1         = 0x25
+	formStrx2         = 0x26
+	formStrx3         = 0x27
+	formStrx4         = 0x28
+	formAddrx1        = 0x29
+	formAddrx2        = 0x2a
+	formAddrx3        = 0x2b
+	formAddrx4        = 0x2c
+	formGNUAddrIndex  = 0x1f01
+	formGNUStrIndex   = 0x1f02
+	formGNURefAlt     = 0x1f20
+	formGNUStrpAlt    = 0x1f21
+)
+
+// skipForm advances b past an attribute value encoded with form.
+func skipForm(b *unitBuf, form uint64, u *scanUnit) error {
+	offsz := 4
+	if u.dwarf64 {
+		offsz = 8
+	}
+	switch form {
+	case formFlagPresent, formImplicitConst:
+		// no value
+	case formData1, formRef1, formFlag, formStrx1, formAddrx1:
+		b.bytes(1)
+	case formData2, formRef2, formStrx2, formAddrx2:
+		b.bytes(2)
+	case formStrx3, formAddrx3:
+		b.bytes(3)
+	case formData4, formRef4, formRefSup4, formStrx4, formAddrx4:
+		b.bytes(4)
+	case formData8, formRef8, formRefSig8, formRefSup8:
+		b.bytes(8)
+	case formData16:
+		b.bytes(16)
+	case formAddr:
+		b.bytes(u.addrSize)
+	case formRefAddr:
+		if u.version <= 2 {
+			b.bytes(u.addrSize)
+		} else {
+			b.bytes(offsz)
+		}
+	case formStrp, formSecOffset, formStrpSup, formLineStrp, formGNURefAlt, formGNUStrpAlt:
+		b.bytes(offsz)
+	case formSdata:
+		b.sleb()
+	case formUdata, formRefUdata, formStrx, formAddrx, formLoclistx, formRnglistx, formGNUAddrIndex, formGNUStrIndex:
+		b.uleb()
+	case formString:
+		for b.err == nil && b.u8() != 0 {
+		}
+	case formBlock1:
+		b.bytes(int(b.u8()))
+	case formBlock2:
+		b.bytes(int(b.u16()))
+	case formBlock4:
+		b.bytes(int(b.u32()))
+	case formBlock, formExprloc:
+		b.bytes(int(b.uleb()))
+	case formIndirect:
+		return skipForm(b, b.uleb(), u)
+	default:
+		return fmt.Errorf("unknown attribute form %#x", form)
+	}
+	return nil
+}
+
+// unitBuf reads the little endian contents of debug_info and debug_abbrev.
+type unitBuf struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (b *unitBuf) bytes(n int) []byte {
+	if b.err != nil || n < 0 || n > len(b.data)-b.off {
+		b.err = errMalformedUnit
+		return make([]byte, 8)
+	}
+	r := b.data[b.off : b.off+n]
+	b.off += n
+	return r
+}
+
+func (b *unitBuf) u8() uint8   { return b.bytes(1)[0] }
+func (b *unitBuf) u16() uint16 { return binary.LittleEndian.Uint16(b.bytes(2)) }
+func (b *unitBuf) u32() uint32 { return binary.LittleEndian.Uint32(b.bytes(4)) }
+func (b *unitBuf) u64() uint64 { return binary.LittleEndian.Uint64(b.bytes(8)) }
+
+func (b *unitBuf) offset(dwarf64 bool) uint64 {
+	if dwarf64 {
+		return b.u64()
+	}
+	return uint64(b.u32())
+}
+
+func (b *unitBuf) uleb() uint64 {
+	var r uint64
+	for shift := uint(0); b.err == nil; shift += 7 {
+		if b.off >= len(b.data) {
+			b.err = errMalformedUnit
+			break
+		}
+		c := b.data[b.off]
+		b.off++
+		if shift < 64 {
+			r |= uint64(c&0x7f) << shift
+		}
+		if c&0x80 == 0 {
+			break
+		}
+	}
+	return r
+}
+
+func (b *unitBuf) sleb() int64 {
+	var r int64
+	shift := uint(0)
+	for b.err == nil {
+		if b.off >= len(b.data) {
+			b.err = errMalformedUnit
+			break
+		}
+		c := b.data[b.off]
+		b.off++
+		if shift < 64 {
+			r |= int64(c&0x7f) << shift
+		}
+		shift += 7
+		if c&0x80 == 0 {
+			if shift < 64 && c&0x40 != 0 {
+				r |= -1 << shift
+			}
+			break
+		}
+	}
+	return r
+}
@@ -0,0 +1,124 @@
+package godwarf
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// checkUnitScanner compares the children of the compile units of f listed
+// by UnitScanner with the ones read by debug/dwarf.
+func checkUnitScanner(t *testing.T, f *elf.File) {
+	info, err := GetDebugSectionElf(f, "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	abbrev, err := GetDebugSectionElf(f, "abbrev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := DwarfElf(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := NewUnitScanner(info, abbrev)
+	rdr := d.Reader()
+	nunits := 0
+	for {
+		cu, err := rdr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cu == nil {
+			break
+		}
+		nunits++
+		var expected []UnitEntry
+		if cu.Children {
+			for {
+				e, err := rdr.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if e.Tag == 0 {
+					break
+				}
+				expected = append(expected, UnitEntry{Offset: e.Offset, Tag: e.Tag, Children: e.Children})
+				rdr.SkipChildren()
+			}
+		}
+		children, next, err := scanner.Children(cu.Offset)
+		if err != nil {
+			t.Fatalf("unit at %#x: %v", cu.Offset, err)
+		}
+		if n, ok := scanner.NextUnit(cu.Offset); !ok || n != next {
+			t.Fatalf("unit at %#x: NextUnit returned %#x, Children returned %#x", cu.Offset, n, next)
+		}
+		if len(children) != len(expected) {
+			t.Fatalf("unit at %#x: got %d children, expected %d", cu.Offset, len(children), len(expected))
+		}
+		for i := range children {
+			if children[i] != expected[i] {
+				t.Fatalf("unit at %#x: child %d is %#v, expected %#v", cu.Offset, i, children[i], expected[i])
+			}
+		}
+		nextcu, err := rdr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case nextcu == nil && next != 0:
+			t.Fatalf("unit at %#x: next unit at %#x, expected none", cu.Offset, next)
+		case nextcu != nil && next != nextcu.Offset:
+			t.Fatalf("unit at %#x: next unit at %#x, expected %#x", cu.Offset, next, nextcu.Offset)
+		}
+		if nextcu == nil {
+			break
+		}
+		rdr.Seek(nextcu.Offset)
+	}
+	if nunits == 0 {
+		t.Fatal("no compile units")
+	}
+}
+
+func TestUnitScanner(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("only supported on linux")
+	}
+	t.Run("go", func(t *testing.T) {
+		src, _ := filepath.Abs("../../../_fixtures/testvariables2.go")
+		exe := filepath.Join(t.TempDir(), "testvariables2")
+		if out, err := exec.Command("go", "build", "-gcflags=all=-N -l", "-o", exe, src).CombinedOutput(); err != nil {
+			t.Fatalf("go build failed: %v\n%s", err, out)
+		}
+		f, err := elf.Open(exe)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		checkUnitScanner(t, f)
+	})
+	t.Run("c", func(t *testing.T) {
+		if _, err := exec.LookPath("gcc"); err != nil {
+			t.Skip("gcc not found")
+		}
+		dir := t.TempDir()
+		csrc, exe := filepath.Join(dir, "scan.c"), filepath.Join(dir, "scan")
+		if err := os.WriteFile(csrc, []byte(accelNamesC), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := exec.Command("gcc", "-g", "-o", exe, csrc).CombinedOutput(); err != nil {
+			t.Skipf("gcc failed: %v\n%s", err, out)
+		}
+		f, err := elf.Open(exe)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		checkUnitScanner(t, f)
+	})
+}
@@ -3,12 +3,23 @@ package godwarf
 import (
 	"bytes"
 	"compress/zlib"
+	"debug/dwarf"
 	"debug/elf"
 	"debug/macho"
 	"debug/pe"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"strings"
+	"sync"
+
+	"github.com/go-delve/delve/pkg/dwarf/zstd"
+)
+
+// Compression algorithms of SHF_COMPRESSED sections.
+const (
+	elfCompressZlib = 1 // ELFCOMPRESS_ZLIB
+	elfCompressZstd = 2 // ELFCOMPRESS_ZSTD
 )
 
 // GetDebugSectionElf returns the data contents of the specified debug
@@ -17,9 +28,17 @@ import (
 // .debug_line, if .debug_line doesn't exist it will try to return the
 // decompressed contents of .zdebug_line.
 func GetDebugSectionElf(f *elf.File, name string) ([]byte, error) {
+	return GetDebugSectionElfReader(f, nil, name)
+}
+
+// GetDebugSectionElfReader is like GetDebugSectionElf but it uses r, the
+// reader f was created from, to read SHF_COMPRESSED sections, so that
+// sections compressed with zstd can be read even if debug/elf does not
+// support them.
+func GetDebugSectionElfReader(f *elf.File, r io.ReaderAt, name string) ([]byte, error) {
 	sec := f.Section(".debug_" + name)
 	if sec != nil {
-		return sec.Data()
+		return ElfSectionData(f, r, sec)
 	}
 	sec = f.Section(".zdebug_" + name)
 	if sec == nil {
@@ -32,6 +51,158 @@ func GetDebugSectionElf(f *elf.File, name string) ([]byte, error) {
 	return decompressMaybe(b)
 }
 
+// ElfSectionData returns the contents of sec, which must be a section of
+// f. If sec is a SHF_COMPRESSED section and r, the reader f was created
+// from, is not nil the section is read from r and decompressed, otherwise
+// this is the same as sec.Data().
+func ElfSectionData(f *elf.File, r io.ReaderAt, sec *elf.Section) ([]byte, error) {
+	if sec.Flags&elf.SHF_COMPRESSED == 0 || sec.Type == elf.SHT_NOBITS || r == nil {
+		return sec.Data()
+	}
+	b := make([]byte, sec.FileSize)
+	if _, err := r.ReadAt(b, int64(sec.Offset)); err != nil {
+		return nil, fmt.Errorf("could not read section %s: %v", sec.Name, err)
+	}
+	var typ, size uint64
+	var hdrlen int
+	switch f.Class {
+	case elf.ELFCLASS32:
+		// Elf32_Chdr: ch_type, ch_size, ch_addralign
+		hdrlen = 12
+		if len(b) >= hdrlen {
+			typ = uint64(f.ByteOrder.Uint32(b[0:]))
+			size = uint64(f.ByteOrder.Uint32(b[4:]))
+		}
+	case elf.ELFCLASS64:
+		// Elf64_Chdr: ch_type, ch_reserved, ch_size, ch_addralign
+		hdrlen = 24
+		if len(b) >= hdrlen {
+			typ = uint64(f.ByteOrder.Uint32(b[0:]))
+			size = f.ByteOrder.Uint64(b[8:])
+		}
+	default:
+		return nil, fmt.Errorf("section %s: unknown ELF class %v", sec.Name, f.Class)
+	}
+	if len(b) < hdrlen {
+		return nil, fmt.Errorf("section %s: compression header too short", sec.Name)
+	}
+	out, err := decompress(typ, size, b[hdrlen:])
+	if err != nil {
+		return nil, fmt.Errorf("could not decompress section %s: %v", sec.Name, err)
+	}
+	return out, nil
+}
+
+// ElfSections reads the debug sections of an ELF file, like
+// GetDebugSectionElfReader, caching their contents so that each section is
+// read and decompressed only once. It is safe for concurrent use.
+type ElfSections struct {
+	f *elf.File
+	r io.ReaderAt
+
+	mu   sync.Mutex
+	secs map[string]*elfSection
+}
+
+type elfSection struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+// NewElfSections returns an ElfSections reading the debug sections of f,
+// r is the reader f was created from, see GetDebugSectionElfReader.
+func NewElfSections(f *elf.File, r io.ReaderAt) *ElfSections {
+	return &ElfSections{f: f, r: r, secs: make(map[string]*elfSection)}
+}
+
+// Get returns the contents of the debug section name, see
+// GetDebugSectionElfReader. If the section is being read by Prefetch Get
+// waits for it.
+func (s *ElfSections) Get(name string) ([]byte, error) {
+	s.mu.Lock()
+	sec := s.secs[name]
+	if sec == nil {
+		sec = &elfSection{}
+		s.secs[name] = sec
+	}
+	s.mu.Unlock()
+	sec.once.Do(func() {
+		sec.data, sec.err = GetDebugSectionElfReader(s.f, s.r, name)
+	})
+	return sec.data, sec.err
+}
+
+// Prefetch starts reading, and decompressing, the debug sections names in
+// the background, each one in its own goroutine.
+func (s *ElfSections) Prefetch(names ...string) {
+	for _, name := range names {
+		go s.Get(name)
+	}
+}
+
+// Dwarf returns the DWARF data of the ELF file, see DwarfElf.
+func (s *ElfSections) Dwarf() (*dwarf.Data, error) {
+	f := s.f
+	compressed := false
+	for _, sec := range f.Sections {
+		if sec.Flags&elf.SHF_COMPRESSED != 0 && strings.HasPrefix(sec.Name, ".debug_") {
+			compressed = true
+			break
+		}
+	}
+	if !compressed || s.r == nil {
+		return f.DWARF()
+	}
+
+	s.Prefetch(dwarfElfSections...)
+	section := func(name string) []byte {
+		b, _ := s.Get(name)
+		return b
+	}
+	info, err := s.Get("info")
+	if err != nil {
+		return nil, err
+	}
+	d, err := dwarf.New(section("abbrev"), nil, nil, info, section("line"), nil, section("ranges"), section("str"))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"addr", "line_str", "str_offsets", "rnglists"} {
+		if b := section(name); len(b) > 0 {
+			if err := d.AddSection(".debug_"+name, b); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for i, sec := range f.Sections {
+		if sec.Name != ".debug_types" {
+			continue
+		}
+		b, err := ElfSectionData(f, s.r, sec)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.AddTypes(fmt.Sprintf("types-%d", i), b); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// dwarfElfSections are the debug sections read by ElfSections.Dwarf.
+var dwarfElfSections = []string{"info", "abbrev", "line", "ranges", "str", "addr", "line_str", "str_offsets", "rnglists"}
+
+// DwarfElf returns the DWARF data of f, like f.DWARF(). If f has
+// SHF_COMPRESSED debug sections and r, the reader f was created from, is
+// not nil the sections are decompressed using ElfSectionData, so that
+// sections compressed with zstd are supported even if debug/elf does not
+// support them. Relocations are not applied to the debug sections read
+// this way, which only matters for relocatable object files.
+func DwarfElf(f *elf.File, r io.ReaderAt) (*dwarf.Data, error) {
+	return NewElfSections(f, r).Dwarf()
+}
+
 // GetDebugSectionPE returns the data contents of the specified debug
 // section, decompressing it if it is compressed.
 // For example GetDebugSectionPE("line") will return the contents of
@@ -92,16 +263,32 @@ func decompressMaybe(b []byte) ([]byte, error) {
 	}
 
 	dlen := binary.BigEndian.Uint64(b[4:12])
-	dbuf := make([]byte, dlen)
-	r, err := zlib.NewReader(bytes.NewBuffer(b[12:]))
-	if err != nil {
-		return nil, err
-	}
-	if _, err := io.ReadFull(r, dbuf); err != nil {
-		return nil, err
-	}
-	if err := r.Close(); err != nil {
-		return nil, err
+	return decompress(elfCompressZlib, dlen, b[12:])
+}
+
+// decompress decompresses b, which was compressed with the specified
+// compression algorithm (one of the ELFCOMPRESS_* constants), into a
+// buffer of size bytes.
+func decompress(typ, size uint64, b []byte) ([]byte, error) {
+	dbuf := make([]byte, size)
+	switch typ {
+	case elfCompressZlib:
+		r, err := zlib.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, dbuf); err != nil {
+			return nil, err
+		}
+		if err := r.Close(); err != nil {
+			return nil, err
+		}
+	case elfCompressZstd:
+		if _, err := io.ReadFull(zstd.NewReader(bytes.NewReader(b)), dbuf); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression type %d", typ)
 	}
 	return dbuf, nil
 }
@@ -0,0 +1,103 @@
+package godwarf
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCompressedSectionsElf(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("only supported on linux")
+	}
+	for _, tool := range []string{"gcc", "objcopy"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found", tool)
+		}
+	}
+	dir := t.TempDir()
+	csrc, exe := filepath.Join(dir, "compressed.c"), filepath.Join(dir, "compressed")
+	if err := os.WriteFile(csrc, []byte(accelNamesC), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("gcc", "-g", "-o", exe, csrc).CombinedOutput(); err != nil {
+		t.Skipf("gcc failed: %v\n%s", err, out)
+	}
+	orig, err := elf.Open(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer orig.Close()
+
+	for _, kind := range []string{"zlib-gabi", "zstd"} {
+		t.Run(kind, func(t *testing.T) {
+			compressed := exe + "." + kind
+			if out, err := exec.Command("objcopy", "--compress-debug-sections="+kind, exe, compressed).CombinedOutput(); err != nil {
+				t.Skipf("objcopy failed: %v\n%s", err, out)
+			}
+			fh, err := os.Open(compressed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer fh.Close()
+			f, err := elf.NewFile(fh)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			n := 0
+			for _, sec := range orig.Sections {
+				if !strings.HasPrefix(sec.Name, ".debug_") {
+					continue
+				}
+				name := sec.Name[len(".debug_"):]
+				if f.Section(sec.Name).Flags&elf.SHF_COMPRESSED != 0 {
+					n++
+				}
+				want, err := sec.Data()
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := GetDebugSectionElfReader(f, fh, name)
+				if err != nil {
+					t.Errorf("%s: %v", sec.Name, err)
+					continue
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("%s: wrong contents", sec.Name)
+				}
+			}
+			if n == 0 {
+				t.Skip("objcopy did not compress any section")
+			}
+
+			d, err := DwarfElf(f, fh)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rdr := d.Reader()
+			found := false
+			for {
+				e, err := rdr.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if e == nil {
+					break
+				}
+				if e.Tag == dwarf.TagSubprogram && e.Val(dwarf.AttrName) == "add" {
+					found = true
+				}
+			}
+			if !found {
+				t.Error("function add not found")
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"path"
 	"strings"
+	"sync"
 
 	"github.com/go-delve/delve/pkg/dwarf/util"
 )
@@ -33,6 +34,10 @@ type DebugLineInfo struct {
 
 	Logf func(string, ...interface{})
 
+	// cacheMu protects stateMachineCache, lastMachineCache and the state
+	// machines stored in lastMachineCache, which are advanced in place.
+	cacheMu sync.Mutex
+
 	// stateMachineCache[pc] is a state machine stopped at pc
 	stateMachineCache map[uint64]*StateMachine
 
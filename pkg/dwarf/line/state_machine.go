@@ -195,7 +195,15 @@ func (sm *StateMachine) copy() *StateMachine {
 	return &r
 }
 
-func (lineInfo *DebugLineInfo) stateMachineForEntry(basePC uint64) (sm *StateMachine) {
+func (lineInfo *DebugLineInfo) stateMachineForEntry(basePC uint64) *StateMachine {
+	lineInfo.cacheMu.Lock()
+	defer lineInfo.cacheMu.Unlock()
+	return lineInfo.stateMachineForEntryLocked(basePC)
+}
+
+// stateMachineForEntryLocked is like stateMachineForEntry but must be
+// called with cacheMu held.
+func (lineInfo *DebugLineInfo) stateMachineForEntryLocked(basePC uint64) (sm *StateMachine) {
 	sm = lineInfo.stateMachineCache[basePC]
 	if sm == nil {
 		sm = newStateMachine(lineInfo, lineInfo.Instructions, lineInfo.ptrSize)
@@ -219,12 +227,16 @@ func (lineInfo *DebugLineInfo) PCToLine(basePC, pc uint64) (string, int) {
 		panic(fmt.Errorf("basePC after pc %#x %#x", basePC, pc))
 	}
 
+	lineInfo.cacheMu.Lock()
+	defer lineInfo.cacheMu.Unlock()
+
 	sm := lineInfo.stateMachineFor(basePC, pc)
 
 	file, line, _ := sm.PCToLine(pc)
 	return file, line
 }
 
+// stateMachineFor must be called with cacheMu held.
 func (lineInfo *DebugLineInfo) stateMachineFor(basePC, pc uint64) *StateMachine {
 	var sm *StateMachine
 	if basePC == 0 {
@@ -236,7 +248,7 @@ func (lineInfo *DebugLineInfo) stateMachineFor(basePC, pc uint64) *StateMachine
 		// As a last resort start from the start of the debug_line section.
 		sm = lineInfo.lastMachineCache[basePC]
 		if sm == nil || sm.lastAddress >= pc {
-			sm = lineInfo.stateMachineForEntry(basePC)
+			sm = lineInfo.stateMachineForEntryLocked(basePC)
 			lineInfo.lastMachineCache[basePC] = sm
 		}
 	}
@@ -263,6 +275,10 @@ func (sm *StateMachine) PCToLine(pc uint64) (string, int, bool) {
 			if sm.address == pc {
 				return sm.file, sm.line, true
 			}
+		} else if sm.endSeq && sm.address > pc && pc >= sm.lastAddress && sm.lastAddress != ^uint64(0) {
+			// the address of the end of a sequence is the first address after
+			// the last row of the sequence
+			return sm.lastFile, sm.lastLine, true
 		}
 		if err := sm.next(); err != nil {
 			if sm.dbl.Logf != nil {
@@ -14,6 +14,7 @@ import (
 type Dwarf5Reader struct {
 	byteOrder binary.ByteOrder
 	ptrSz     int
+	dwarf64   bool
 	data      []byte
 }
 
@@ -25,6 +26,7 @@ func NewDwarf5Reader(data []byte) *Dwarf5Reader {
 
 	_, dwarf64, _, byteOrder := util.ReadDwarfLengthVersion(data)
 	r.byteOrder = byteOrder
+	r.dwarf64 = dwarf64
 
 	data = data[6:]
 	if dwarf64 {
@@ -73,6 +75,46 @@ func (rdr *Dwarf5Reader) Find(off int, staticBase, base, pc uint64, debugAddr *g
 	return nil, nil
 }
 
+// OffsetForIndex returns the offset of the loclist with index idx, as
+// specified by an attribute with form DW_FORM_loclistx. LoclistsBase is the
+// value of the DW_AT_loclists_base attribute of the compile unit.
+func (rdr *Dwarf5Reader) OffsetForIndex(loclistsBase, idx uint64) (int, error) {
+	offsz := uint64(4)
+	if rdr.dwarf64 {
+		offsz = 8
+	}
+	// the offset_entry_count field of the header immediately precedes the
+	// array of offsets
+	if loclistsBase < 4 || loclistsBase > uint64(len(rdr.data)) {
+		return 0, fmt.Errorf("malformed loclists base %#x", loclistsBase)
+	}
+	cnt := rdr.byteOrder.Uint32(rdr.data[loclistsBase-4:])
+	entry := loclistsBase + idx*offsz
+	if idx >= uint64(cnt) || entry+offsz > uint64(len(rdr.data)) {
+		return 0, fmt.Errorf("loclist index %d out of bounds", idx)
+	}
+	off, err := util.ReadUintRaw(bytes.NewReader(rdr.data[entry:]), rdr.byteOrder, int(offsz))
+	if err != nil {
+		return 0, err
+	}
+	return int(loclistsBase + off), nil
+}
+
+// Ranges returns the address ranges covered by the loclist starting at off,
+// the arguments have the same meaning as in Find.
+func (rdr *Dwarf5Reader) Ranges(off int, staticBase, base uint64, debugAddr *godwarf.DebugAddr) ([][2]uint64, error) {
+	it := &loclistsIterator{rdr: rdr, debugAddr: debugAddr, buf: bytes.NewBuffer(rdr.data), base: base, staticBase: staticBase}
+	it.buf.Next(off)
+
+	r := [][2]uint64{}
+	for it.next() {
+		if it.onRange {
+			r = append(r, [2]uint64{it.start, it.end})
+		}
+	}
+	return r, it.err
+}
+
 type loclistsIterator struct {
 	rdr        *Dwarf5Reader
 	debugAddr  *godwarf.DebugAddr
@@ -134,6 +176,8 @@ func (it *loclistsIterator) next() bool {
 		if it.err == nil {
 			it.end, it.err = it.debugAddr.Get(endIdx)
 		}
+		it.start += it.staticBase
+		it.end += it.staticBase
 		it.onRange = true
 
 	case _DW_LLE_startx_length:
@@ -142,6 +186,7 @@ func (it *loclistsIterator) next() bool {
 		it.readInstr()
 
 		it.start, it.err = it.debugAddr.Get(startIdx)
+		it.start += it.staticBase
 		it.end = it.start + length
 		it.onRange = true
 
@@ -168,12 +213,15 @@ func (it *loclistsIterator) next() bool {
 		it.start, it.err = util.ReadUintRaw(it.buf, it.rdr.byteOrder, it.rdr.ptrSz)
 		it.end, it.err = util.ReadUintRaw(it.buf, it.rdr.byteOrder, it.rdr.ptrSz)
 		it.readInstr()
+		it.start += it.staticBase
+		it.end += it.staticBase
 		it.onRange = true
 
 	case _DW_LLE_start_length:
 		it.start, it.err = util.ReadUintRaw(it.buf, it.rdr.byteOrder, it.rdr.ptrSz)
 		length, _ := util.DecodeULEB128(it.buf)
 		it.readInstr()
+		it.start += it.staticBase
 		it.end = it.start + length
 		it.onRange = true
 
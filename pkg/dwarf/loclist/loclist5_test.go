@@ -117,3 +117,69 @@ func TestLoclist5(t *testing.T) {
 		}
 	}
 }
+
+func TestLoclist5Index(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	p32 := func(n uint32) { binary.Write(buf, binary.LittleEndian, n) }
+	p16 := func(n uint16) { binary.Write(buf, binary.LittleEndian, n) }
+	p8 := func(n uint8) { binary.Write(buf, binary.LittleEndian, n) }
+	uleb := func(n uint64) { util.EncodeULEB128(buf, n) }
+
+	p32(0x0) // length (use 0 because it is ignored)
+	p16(0x5) // version
+	p8(4)    // address size
+	p8(0)    // segment selector size
+	p32(2)   // offset_entry_count
+
+	loclistsBase := buf.Len()
+	p32(8)  // offset of loclist 0
+	p32(16) // offset of loclist 1
+
+	// loclist 0: (offset) 0x100 .. 0x200: 1
+	p8(_DW_LLE_offset_pair)
+	uleb(0x100)
+	uleb(0x200)
+	uleb(1)
+	p8(1)
+	p8(_DW_LLE_end_of_list)
+
+	// loclist 1: (start end) 0x300 .. 0x400: 2, default location 3
+	p8(_DW_LLE_start_end)
+	p32(0x300)
+	p32(0x400)
+	uleb(1)
+	p8(2)
+	p8(_DW_LLE_default_location)
+	uleb(1)
+	p8(3)
+	p8(_DW_LLE_end_of_list)
+
+	ll := NewDwarf5Reader(buf.Bytes())
+
+	testCases := []struct {
+		idx uint64
+		tgt [][2]uint64
+	}{
+		{0, [][2]uint64{{0x1100, 0x1200}}},
+		{1, [][2]uint64{{0x10300, 0x10400}}},
+	}
+
+	for _, tc := range testCases {
+		off, err := ll.OffsetForIndex(uint64(loclistsBase), tc.idx)
+		if err != nil {
+			t.Fatalf("error returned for index %d: %v", tc.idx, err)
+		}
+		rngs, err := ll.Ranges(off, 0x10000, 0x1000, nil)
+		if err != nil {
+			t.Fatalf("error returned for index %d: %v", tc.idx, err)
+		}
+		if len(rngs) != len(tc.tgt) || rngs[0] != tc.tgt[0] {
+			t.Errorf("output mismatch for index %d,\nexpected %#v,\ngot     %#v", tc.idx, tc.tgt, rngs)
+		}
+	}
+
+	if _, err := ll.OffsetForIndex(uint64(loclistsBase), 2); err == nil {
+		t.Errorf("no error returned for out of bounds index")
+	}
+}
@@ -43,9 +43,10 @@ type Piece struct {
 type PieceKind uint8
 
 const (
-	AddrPiece PieceKind = iota // The piece is stored in memory, Val is the address
-	RegPiece                   // The piece is stored in a register, Val is the register number
-	ImmPiece                   // The piece is an immediate value, Val or Bytes is the value
+	AddrPiece        PieceKind = iota // The piece is stored in memory, Val is the address
+	RegPiece                          // The piece is stored in a register, Val is the register number
+	ImmPiece                          // The piece is an immediate value, Val or Bytes is the value
+	UnavailablePiece                  // The piece is not available, for example because it was optimized away
 )
 
 var (
@@ -255,7 +256,7 @@ func piece(opcode Opcode, ctxt *context) error {
 	if len(ctxt.stack) == 0 {
 		// nothing on the stack means this piece is unavailable (padding,
 		// optimized away...), see DWARFv4 sec. 2.6.1.3 page 30.
-		ctxt.pieces = append(ctxt.pieces, Piece{Size: int(sz), Kind: ImmPiece, Val: 0})
+		ctxt.pieces = append(ctxt.pieces, Piece{Size: int(sz), Kind: UnavailablePiece})
 		return nil
 	}
 
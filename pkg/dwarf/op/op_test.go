@@ -56,3 +56,21 @@ func TestBra(t *testing.T) {
 		byte(DW_OP_drop),
 	})
 }
+
+func TestUnavailablePiece(t *testing.T) {
+	// first piece empty, second piece a literal
+	instr := []byte{byte(DW_OP_piece), 8, byte(DW_OP_lit1), byte(DW_OP_stack_value), byte(DW_OP_piece), 8}
+	_, pieces, err := ExecuteStackProgram(DwarfRegisters{}, instr, 8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pieces) != 2 {
+		t.Fatalf("wrong number of pieces %d", len(pieces))
+	}
+	if pieces[0].Kind != UnavailablePiece || pieces[0].Size != 8 {
+		t.Errorf("wrong first piece %#v", pieces[0])
+	}
+	if pieces[1].Kind != ImmPiece || pieces[1].Val != 1 || pieces[1].Size != 8 {
+		t.Errorf("wrong second piece %#v", pieces[1])
+	}
+}
@@ -0,0 +1,66 @@
+package regnum
+
+import (
+	"fmt"
+)
+
+// The mapping between hardware registers and DWARF registers is specified
+// in the LoongArch ELF psABI, section "DWARF Register Numbers"
+// https://loongson.github.io/LoongArch-Documentation/LoongArch-ELF-ABI-EN.html
+// The psABI does not assign a number to the program counter, we use the
+// first number after the floating point registers.
+
+const (
+	LOONG64_R0         = 0  // R1 through R31 follow
+	LOONG64_LR         = 1  // also R1, RA
+	LOONG64_SP         = 3  // also R3
+	LOONG64_BP         = 22 // also R22, FP (used by Go to hold g)
+	LOONG64_F0         = 32 // F1 through F31 follow
+	LOONG64_PC         = 64
+	_LOONG64_MaxRegNum = LOONG64_PC
+)
+
+var loong64ABINames = [...]string{
+	"zero", "ra", "tp", "sp", "a0", "a1", "a2", "a3",
+	"a4", "a5", "a6", "a7", "t0", "t1", "t2", "t3",
+	"t4", "t5", "t6", "t7", "t8", "u0", "fp", "s0",
+	"s1", "s2", "s3", "s4", "s5", "s6", "s7", "s8",
+}
+
+// LOONG64ABIName returns the ABI name of integer register R<n>.
+func LOONG64ABIName(n int) string {
+	return loong64ABINames[n]
+}
+
+func LOONG64ToName(num uint64) string {
+	switch {
+	case num <= 31:
+		return fmt.Sprintf("R%d", num)
+	case num >= LOONG64_F0 && num <= LOONG64_F0+31:
+		return fmt.Sprintf("F%d", num-LOONG64_F0)
+	case num == LOONG64_PC:
+		return "PC"
+	default:
+		return fmt.Sprintf("unknown%d", num)
+	}
+}
+
+func LOONG64MaxRegNum() uint64 {
+	return _LOONG64_MaxRegNum
+}
+
+var LOONG64NameToDwarf = func() map[string]int {
+	r := make(map[string]int)
+	for i := 0; i <= 31; i++ {
+		r[fmt.Sprintf("r%d", i)] = LOONG64_R0 + i
+		r[loong64ABINames[i]] = LOONG64_R0 + i
+	}
+	r["era"] = LOONG64_PC
+	r["pc"] = LOONG64_PC
+
+	for i := 0; i <= 31; i++ {
+		r[fmt.Sprintf("f%d", i)] = LOONG64_F0 + i
+	}
+
+	return r
+}()
@@ -0,0 +1,66 @@
+package regnum
+
+import (
+	"fmt"
+)
+
+// The mapping between hardware registers and DWARF registers is specified
+// in the RISC-V ELF psABI, section "DWARF Register Numbers"
+// https://github.com/riscv-non-isa/riscv-elf-psabi-doc/blob/master/riscv-dwarf.adoc
+// The psABI does not assign a number to the program counter, we use the
+// first reserved number after the alternate frame return column.
+
+const (
+	RISCV64_X0         = 0  // X1 through X31 follow
+	RISCV64_LR         = 1  // also X1, RA
+	RISCV64_SP         = 2  // also X2
+	RISCV64_BP         = 8  // also X8, S0/FP
+	RISCV64_F0         = 32 // F1 through F31 follow
+	RISCV64_PC         = 65
+	_RISCV64_MaxRegNum = RISCV64_PC
+)
+
+var riscv64ABINames = [...]string{
+	"zero", "ra", "sp", "gp", "tp", "t0", "t1", "t2",
+	"s0", "s1", "a0", "a1", "a2", "a3", "a4", "a5",
+	"a6", "a7", "s2", "s3", "s4", "s5", "s6", "s7",
+	"s8", "s9", "s10", "s11", "t3", "t4", "t5", "t6",
+}
+
+// RISCV64ABIName returns the ABI name of integer register X<n>.
+func RISCV64ABIName(n int) string {
+	return riscv64ABINames[n]
+}
+
+func RISCV64ToName(num uint64) string {
+	switch {
+	case num <= 31:
+		return fmt.Sprintf("X%d", num)
+	case num >= RISCV64_F0 && num <= RISCV64_F0+31:
+		return fmt.Sprintf("F%d", num-RISCV64_F0)
+	case num == RISCV64_PC:
+		return "PC"
+	default:
+		return fmt.Sprintf("unknown%d", num)
+	}
+}
+
+func RISCV64MaxRegNum() uint64 {
+	return _RISCV64_MaxRegNum
+}
+
+var RISCV64NameToDwarf = func() map[string]int {
+	r := make(map[string]int)
+	for i := 0; i <= 31; i++ {
+		r[fmt.Sprintf("x%d", i)] = RISCV64_X0 + i
+		r[riscv64ABINames[i]] = RISCV64_X0 + i
+	}
+	r["fp"] = RISCV64_BP
+	r["pc"] = RISCV64_PC
+
+	for i := 0; i <= 31; i++ {
+		r[fmt.Sprintf("f%d", i)] = RISCV64_F0 + i
+	}
+
+	return r
+}()
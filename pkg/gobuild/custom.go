@@ -0,0 +1,144 @@
+package gobuild
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/go-delve/delve/pkg/config"
+)
+
+// customBuildData is the data of the templates of the build command and of
+// the output path of CustomBuild.
+type customBuildData struct {
+	Output   string // path where delve expects the executable
+	Package  string // first package, "." if none was specified
+	Packages string // all packages, separated by spaces
+}
+
+// CustomBuild builds 'pkgs' running 'buildcmd' instead of 'go build' and
+// writes the executable at 'debugname'. Both 'buildcmd' and 'output', the
+// path where the command writes the executable, are text/template
+// templates that can use the fields {{.Output}} (that is 'debugname'),
+// {{.Package}} and {{.Packages}}. If 'output' is empty the command must
+// write the executable at {{.Output}}, otherwise it is copied there.
+// An error is returned if the executable has no DWARF debug information.
+func CustomBuild(debugname string, pkgs []string, buildcmd, output string) error {
+	cmd, output, err := customBuildCmd(debugname, pkgs, buildcmd, output)
+	if err != nil {
+		return err
+	}
+	cmd.Stderr = os.Stdout
+	cmd.Stdout = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	return customBuildFinish(debugname, output)
+}
+
+// CustomBuildCombinedOutput is like CustomBuild but returns the output of
+// the build command instead of printing it.
+func CustomBuildCombinedOutput(debugname string, pkgs []string, buildcmd, output string) ([]byte, error) {
+	cmd, output, err := customBuildCmd(debugname, pkgs, buildcmd, output)
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return out, err
+	}
+	return out, customBuildFinish(debugname, output)
+}
+
+func customBuildCmd(debugname string, pkgs []string, buildcmd, output string) (*exec.Cmd, string, error) {
+	data := customBuildData{Output: debugname, Package: ".", Packages: strings.Join(pkgs, " ")}
+	if len(pkgs) > 0 {
+		data.Package = pkgs[0]
+	}
+	expand := func(name, text string) (string, error) {
+		tmpl, err := template.New(name).Parse(text)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s: %v", name, err)
+		}
+		var buf strings.Builder
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return "", fmt.Errorf("invalid %s: %v", name, err)
+		}
+		return buf.String(), nil
+	}
+	buildcmd, err := expand("build command", buildcmd)
+	if err != nil {
+		return nil, "", err
+	}
+	args := config.SplitQuotedFields(buildcmd, '\'')
+	if len(args) == 0 {
+		return nil, "", errors.New("empty build command")
+	}
+	if output == "" {
+		output = debugname
+	} else if output, err = expand("build output", output); err != nil {
+		return nil, "", err
+	}
+	return exec.Command(args[0], args[1:]...), output, nil
+}
+
+// customBuildFinish copies the executable produced by a custom build from
+// output to debugname and checks that it has debug information.
+func customBuildFinish(debugname, output string) error {
+	if abs, err := filepath.Abs(output); err == nil && abs != debugname {
+		if err := copyExecutable(debugname, output); err != nil {
+			return fmt.Errorf("could not copy %s: %v", output, err)
+		}
+	}
+	return checkDWARF(debugname)
+}
+
+func copyExecutable(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	// dst could be the executable of a running process, which can be
+	// removed but not overwritten.
+	os.Remove(dst)
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// checkDWARF returns an error if the executable at path has no DWARF debug
+// information.
+func checkDWARF(path string) error {
+	var err error
+	if f, ferr := elf.Open(path); ferr == nil {
+		_, err = f.DWARF()
+		f.Close()
+	} else if f, ferr := macho.Open(path); ferr == nil {
+		_, err = f.DWARF()
+		f.Close()
+	} else if f, ferr := pe.Open(path); ferr == nil {
+		_, err = f.DWARF()
+		f.Close()
+	} else {
+		return fmt.Errorf("%s is not an executable", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s has no debug information, make sure that the build command does not strip it (for example with -ldflags='-s -w'): %v", path, err)
+	}
+	return nil
+}
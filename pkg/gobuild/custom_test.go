@@ -0,0 +1,56 @@
+package gobuild
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	protest "github.com/go-delve/delve/pkg/proc/test"
+)
+
+func TestCustomBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "customBuild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pkgs := []string{filepath.Join(protest.FindFixturesDir(), "buildtest")}
+	debugname := filepath.Join(dir, "__debug_bin")
+
+	// the build command writes the executable at {{.Output}}
+	if err := CustomBuild(debugname, pkgs, "go build -gcflags='all=-N -l' -o {{.Output}} {{.Package}}", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(debugname); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(debugname)
+
+	// the build command writes the executable somewhere else
+	out, err := CustomBuildCombinedOutput(debugname, pkgs, "go build -o {{.Output}}.out {{.Packages}}", "{{.Output}}.out")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	if _, err := os.Stat(debugname); err != nil {
+		t.Fatal(err)
+	}
+
+	// stripped executables are rejected
+	_, err = CustomBuildCombinedOutput(debugname, pkgs, "go build -ldflags='-s -w' -o {{.Output}} {{.Package}}", "")
+	if err == nil || !strings.Contains(err.Error(), "no debug information") {
+		t.Fatalf("expected error for stripped executable, got %v", err)
+	}
+
+	for _, tc := range []struct{ buildcmd, output string }{
+		{"", ""},
+		{"go build -o {{.Output", ""},
+		{"go build -o {{.Nope}}", ""},
+		{"go build", "{{.Output"},
+	} {
+		if _, err := CustomBuildCombinedOutput(debugname, pkgs, tc.buildcmd, tc.output); err == nil {
+			t.Errorf("expected error for build command %q and output %q", tc.buildcmd, tc.output)
+		}
+	}
+}
@@ -60,6 +60,35 @@ func GoTestBuildCombinedOutput(debugname string, pkgs []string, buildflags strin
 	return gocommandCombinedOutput("test", args...)
 }
 
+// PackageDirs returns the directories of 'pkgs' and of their dependencies
+// that are not part of the standard library or of a dependency module,
+// i.e. the directories containing the source files of the program. If
+// 'isTest' is true the dependencies of the tests are included.
+func PackageDirs(pkgs []string, buildflags string, isTest bool) ([]string, error) {
+	args := []string{"-e", "-deps", "-f", "{{if and (not .Standard) (or (not .Module) .Module.Main)}}{{.Dir}}{{end}}"}
+	if isTest {
+		args = append(args, "-test")
+	}
+	if buildflags != "" {
+		args = append(args, config.SplitQuotedFields(buildflags, '\'')...)
+	}
+	args = append(args, pkgs...)
+	_, goList := gocommandExecCmd("list", args...)
+	out, err := goList.Output()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var dirs []string
+	for _, dir := range strings.Split(string(out), "\n") {
+		if dir = strings.TrimSpace(dir); dir != "" && !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
 func goBuildArgs(debugname string, pkgs []string, buildflags string, isTest bool) []string {
 	args := []string{"-o", debugname}
 	if isTest {
@@ -0,0 +1,43 @@
+package heapgraph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/go-delve/delve/pkg/proc"
+)
+
+// WriteDot writes g to w as a Graphviz DOT directed graph. Heap objects are
+// nodes identified by their address, with the attributes type and size,
+// root variables are nodes identified by "root" followed by their index,
+// with the attributes label and kind ("local" or "global"). Each reference
+// is an edge.
+func WriteDot(w io.Writer, g *proc.HeapGraph) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph heap {\n")
+	for i := range g.Roots {
+		root := &g.Roots[i]
+		kind := "global"
+		if root.Kind == proc.StackReference {
+			kind = "local"
+		}
+		fmt.Fprintf(bw, "\troot%d [label=%q kind=%q goroutine=%d];\n", i, rootName(root), kind, root.GoroutineID)
+	}
+	for i := range g.Objects {
+		obj := &g.Objects[i]
+		fmt.Fprintf(bw, "\t\"%#x\" [label=%q type=%q size=%d];\n", obj.Addr, obj.Type, obj.Type, obj.Size)
+	}
+	for i := range g.Roots {
+		for _, addr := range g.Roots[i].Refs {
+			fmt.Fprintf(bw, "\troot%d -> \"%#x\";\n", i, addr)
+		}
+	}
+	for i := range g.Objects {
+		for _, addr := range g.Objects[i].Refs {
+			fmt.Fprintf(bw, "\t\"%#x\" -> \"%#x\";\n", g.Objects[i].Addr, addr)
+		}
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
@@ -0,0 +1,36 @@
+// Package heapgraph writes the graph of the live heap objects of a target,
+// as returned by proc.Target.HeapGraph, in formats understood by external
+// analysis tools.
+package heapgraph
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-delve/delve/pkg/proc"
+)
+
+// Formats are the names of the supported output formats.
+var Formats = []string{"pprof", "dot"}
+
+// Write writes g to w in the format called format:
+//
+//	pprof	a gzipped pprof heap profile, see WritePprof
+//	dot	a Graphviz DOT graph, see WriteDot
+func Write(w io.Writer, g *proc.HeapGraph, format string) error {
+	switch format {
+	case "pprof":
+		return WritePprof(w, g)
+	case "dot":
+		return WriteDot(w, g)
+	}
+	return fmt.Errorf("unknown heap graph format %q, must be one of %v", format, Formats)
+}
+
+// rootName returns the name used for root in the output.
+func rootName(root *proc.HeapRoot) string {
+	if root.Kind == proc.StackReference && root.Fn != nil {
+		return fmt.Sprintf("%s (local %s)", root.Fn.Name, root.Name)
+	}
+	return root.Name
+}
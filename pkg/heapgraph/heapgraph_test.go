@@ -0,0 +1,78 @@
+package heapgraph
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/go-delve/delve/pkg/proc"
+)
+
+func testGraph() *proc.HeapGraph {
+	return &proc.HeapGraph{
+		Objects: []proc.HeapObject{
+			{Addr: 0x1000, Size: 16, Type: "main.List", Refs: []uint64{0x2000}},
+			{Addr: 0x2000, Size: 32, Type: "main.Node", Refs: []uint64{0x2020}},
+			{Addr: 0x2020, Size: 32, Type: "main.Node"},
+		},
+		Roots: []proc.HeapRoot{
+			{Kind: proc.GlobalReference, Name: "main.list", Refs: []uint64{0x1000}},
+		},
+	}
+}
+
+func TestWriteDot(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testGraph(), "dot"); err != nil {
+		t.Fatal(err)
+	}
+	const tgt = `digraph heap {
+	root0 [label="main.list" kind="global" goroutine=0];
+	"0x1000" [label="main.List" type="main.List" size=16];
+	"0x2000" [label="main.Node" type="main.Node" size=32];
+	"0x2020" [label="main.Node" type="main.Node" size=32];
+	root0 -> "0x1000";
+	"0x1000" -> "0x2000";
+	"0x2000" -> "0x2020";
+}
+`
+	if out := buf.String(); out != tgt {
+		t.Errorf("wrong output, expected:\n%s\ngot:\n%s", tgt, out)
+	}
+}
+
+func TestWritePprof(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testGraph(), "pprof"); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"inuse_objects", "inuse_space", "main.list", "main.List", "main.Node"} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("%q not found in profile", s)
+		}
+	}
+
+	p := newPprofBuilder()
+	p.add([]uint64{p.location("a"), p.location("b")}, 1, 16)
+	p.add([]uint64{p.location("a"), p.location("b")}, 1, 32)
+	p.add([]uint64{p.location("a")}, 1, 8)
+	if len(p.samples) != 2 || p.samples[0].count != 2 || p.samples[0].inuse != 48 {
+		t.Errorf("samples with the same stack not merged: %#v", p.samples)
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	if err := Write(ioutil.Discard, testGraph(), "json"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
@@ -0,0 +1,271 @@
+package heapgraph
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-delve/delve/pkg/proc"
+)
+
+// maxPprofDepth is the maximum number of frames of the samples written by
+// WritePprof, longer retention paths are truncated.
+const maxPprofDepth = 64
+
+// WritePprof writes g to w as a gzipped pprof heap profile, with the
+// sample types inuse_objects and inuse_space.
+//
+// The stack of each sample is a retention path: the leaf is the type of
+// the object, each frame above it is the type of the object referencing
+// it and the topmost frame is the root variable the path starts from. The
+// shortest retention path of each object is used. This way 'pprof -top'
+// lists the types using the most memory and the graph and flame graph
+// views show what is keeping them alive.
+func WritePprof(w io.Writer, g *proc.HeapGraph) error {
+	idx := make(map[uint64]int, len(g.Objects))
+	for i := range g.Objects {
+		idx[g.Objects[i].Addr] = i
+	}
+
+	// breadth-first visit from the roots to find the shortest retention
+	// path of each object, parent[i] is the index of the object referencing
+	// object i or, if it is negative, -1 minus the index of the root.
+	const unreached = int(^uint(0) >> 1)
+	parent := make([]int, len(g.Objects))
+	for i := range parent {
+		parent[i] = unreached
+	}
+	queue := []int{}
+	for ri := range g.Roots {
+		for _, addr := range g.Roots[ri].Refs {
+			if i, ok := idx[addr]; ok && parent[i] == unreached {
+				parent[i] = -1 - ri
+				queue = append(queue, i)
+			}
+		}
+	}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for _, addr := range g.Objects[i].Refs {
+			if j, ok := idx[addr]; ok && parent[j] == unreached {
+				parent[j] = i
+				queue = append(queue, j)
+			}
+		}
+	}
+
+	p := newPprofBuilder()
+	for i := range g.Objects {
+		stack := []uint64{}
+		j := i
+		for len(stack) < maxPprofDepth {
+			stack = append(stack, p.location(g.Objects[j].Type))
+			if parent[j] == unreached {
+				stack = append(stack, p.location("<unknown root>"))
+				break
+			}
+			if parent[j] < 0 {
+				stack = append(stack, p.location(rootName(&g.Roots[-1-parent[j]])))
+				break
+			}
+			j = parent[j]
+		}
+		p.add(stack, 1, int64(g.Objects[i].Size))
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(p.encode()); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// pprofBuilder builds a pprof profile, see
+// https://github.com/google/pprof/blob/master/proto/profile.proto.
+// Every location has a single line and every function a single location,
+// they share their ids.
+type pprofBuilder struct {
+	strings   []string
+	stringIdx map[string]int64
+	funcs     map[string]uint64 // id of each function, by name
+	samples   []pprofSample
+	sampleIdx map[string]int // index in samples, by stack
+}
+
+type pprofSample struct {
+	stack        []uint64
+	count, inuse int64
+}
+
+func newPprofBuilder() *pprofBuilder {
+	return &pprofBuilder{
+		strings:   []string{""},
+		stringIdx: map[string]int64{"": 0},
+		funcs:     make(map[string]uint64),
+		sampleIdx: make(map[string]int),
+	}
+}
+
+func (p *pprofBuilder) string(s string) int64 {
+	if i, ok := p.stringIdx[s]; ok {
+		return i
+	}
+	p.strings = append(p.strings, s)
+	p.stringIdx[s] = int64(len(p.strings) - 1)
+	return p.stringIdx[s]
+}
+
+// location returns the id of the location of the function called name.
+func (p *pprofBuilder) location(name string) uint64 {
+	if id, ok := p.funcs[name]; ok {
+		return id
+	}
+	id := uint64(len(p.funcs) + 1)
+	p.funcs[name] = id
+	p.string(name)
+	return id
+}
+
+// add adds count objects using inuse bytes to the sample with the
+// specified stack.
+func (p *pprofBuilder) add(stack []uint64, count, inuse int64) {
+	var key strings.Builder
+	for _, id := range stack {
+		fmt.Fprintf(&key, "%d,", id)
+	}
+	i, ok := p.sampleIdx[key.String()]
+	if !ok {
+		i = len(p.samples)
+		p.sampleIdx[key.String()] = i
+		p.samples = append(p.samples, pprofSample{stack: stack})
+	}
+	p.samples[i].count += count
+	p.samples[i].inuse += inuse
+}
+
+// Field numbers of the messages of profile.proto.
+const (
+	tagProfileSampleType        = 1
+	tagProfileSample            = 2
+	tagProfileLocation          = 4
+	tagProfileFunction          = 5
+	tagProfileStringTable       = 6
+	tagProfileTimeNanos         = 9
+	tagProfilePeriodType        = 11
+	tagProfilePeriod            = 12
+	tagProfileDefaultSampleType = 14
+
+	tagValueTypeType = 1
+	tagValueTypeUnit = 2
+
+	tagSampleLocation = 1
+	tagSampleValue    = 2
+
+	tagLocationID   = 1
+	tagLocationLine = 4
+
+	tagLineFunction = 1
+
+	tagFunctionID         = 1
+	tagFunctionName       = 2
+	tagFunctionSystemName = 3
+)
+
+func (p *pprofBuilder) encode() []byte {
+	valueType := func(typ, unit string) []byte {
+		var b protobuf
+		b.int64(tagValueTypeType, p.string(typ))
+		b.int64(tagValueTypeUnit, p.string(unit))
+		return b.data
+	}
+
+	var b protobuf
+	b.message(tagProfileSampleType, valueType("inuse_objects", "count"))
+	b.message(tagProfileSampleType, valueType("inuse_space", "bytes"))
+	for _, s := range p.samples {
+		var sb protobuf
+		sb.packedUint64(tagSampleLocation, s.stack)
+		sb.packedUint64(tagSampleValue, []uint64{uint64(s.count), uint64(s.inuse)})
+		b.message(tagProfileSample, sb.data)
+	}
+	names := make([]string, len(p.funcs))
+	for name, id := range p.funcs {
+		names[id-1] = name
+	}
+	for i, name := range names {
+		id := uint64(i + 1)
+		var line protobuf
+		line.uint64(tagLineFunction, id)
+		var loc protobuf
+		loc.uint64(tagLocationID, id)
+		loc.message(tagLocationLine, line.data)
+		b.message(tagProfileLocation, loc.data)
+
+		var fn protobuf
+		fn.uint64(tagFunctionID, id)
+		fn.int64(tagFunctionName, p.string(name))
+		fn.int64(tagFunctionSystemName, p.string(name))
+		b.message(tagProfileFunction, fn.data)
+	}
+	b.int64(tagProfileTimeNanos, time.Now().UnixNano())
+	b.message(tagProfilePeriodType, valueType("space", "bytes"))
+	b.int64(tagProfilePeriod, 1)
+	b.int64(tagProfileDefaultSampleType, p.string("inuse_space"))
+	// the string table must be written last, encoding the other fields can
+	// add strings to it.
+	for _, s := range p.strings {
+		b.string(tagProfileStringTable, s)
+	}
+	return b.data
+}
+
+// protobuf is a minimal protocol buffers encoder.
+type protobuf struct {
+	data []byte
+}
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protobuf) key(tag, wireType int) {
+	b.varint(uint64(tag)<<3 | uint64(wireType))
+}
+
+func (b *protobuf) uint64(tag int, x uint64) {
+	b.key(tag, 0)
+	b.varint(x)
+}
+
+func (b *protobuf) int64(tag int, x int64) {
+	b.uint64(tag, uint64(x))
+}
+
+func (b *protobuf) bytes(tag int, x []byte) {
+	b.key(tag, 2)
+	b.varint(uint64(len(x)))
+	b.data = append(b.data, x...)
+}
+
+func (b *protobuf) string(tag int, s string) {
+	b.bytes(tag, []byte(s))
+}
+
+func (b *protobuf) message(tag int, x []byte) {
+	b.bytes(tag, x)
+}
+
+func (b *protobuf) packedUint64(tag int, x []uint64) {
+	var p protobuf
+	for _, u := range x {
+		p.varint(u)
+	}
+	b.bytes(tag, p.data)
+}
@@ -58,6 +58,12 @@ func GdbWireLogger() *logrus.Entry {
 	return makeLogger(gdbWire, logrus.Fields{"layer": "gdbconn"})
 }
 
+// GdbStubLogger returns a logger for the server side of the GDB remote
+// protocol, used by 'dlv gdbserve'.
+func GdbStubLogger() *logrus.Entry {
+	return makeLogger(gdbWire, logrus.Fields{"layer": "gdbstub"})
+}
+
 // Debugger returns true if the debugger package should log.
 func Debugger() bool {
 	return debugger
@@ -123,6 +129,11 @@ func WriteDAPListeningMessage(addr net.Addr) {
 	writeListeningMessage("DAP", addr)
 }
 
+// WriteGdbStubListeningMessage writes the "GDB remote server listening" message in gdbserve mode.
+func WriteGdbStubListeningMessage(addr net.Addr) {
+	writeListeningMessage("GDB remote", addr)
+}
+
 // WriteAPIListeningMessage writes the "API server listening" message in headless mode.
 func WriteAPIListeningMessage(addr net.Addr) {
 	writeListeningMessage("API", addr)
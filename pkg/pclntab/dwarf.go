@@ -0,0 +1,373 @@
+package pclntab
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"encoding/binary"
+	"sort"
+	"strings"
+
+	"github.com/go-delve/delve/pkg/dwarf/frame"
+	"github.com/go-delve/delve/pkg/dwarf/line"
+	"github.com/go-delve/delve/pkg/dwarf/util"
+)
+
+// Arch describes the architecture of the executable, to produce its
+// .debug_frame section.
+type Arch struct {
+	// LinkRegister is true if the return address is stored in a register by
+	// call instructions, rather than being pushed on the stack.
+	LinkRegister bool
+	SPRegNum     uint64 // DWARF register number of the stack pointer
+	RARegNum     uint64 // DWARF register number of the return address (the PC or the link register)
+}
+
+// Sections are the DWARF sections produced by DWARF.
+type Sections struct {
+	Info, Abbrev, Line, Ranges, Frame []byte
+}
+
+// Abbreviation codes of the entries written by DWARF.
+const (
+	abbrevCompileUnit = iota + 1
+	abbrevSubprogram
+)
+
+// DWARF forms.
+const (
+	formAddr        = 0x01
+	formData1       = 0x0b
+	formString      = 0x08
+	formSecOffset   = 0x17
+	formFlagPresent = 0x19
+)
+
+// dwarfGoLanguage is DW_LANG_Go.
+const dwarfGoLanguage = 0x16
+
+// dataAlignmentFactor is the data alignment factor of the CIE, the same
+// used by the Go linker.
+const dataAlignmentFactor = -4
+
+// DWARF returns DWARF debug information describing the functions of t,
+// with a compile unit for each package, with the specified producer.
+// The compile units describe the address ranges and line tables of
+// functions, there are no variables or types. The frame of functions is
+// described by a .debug_frame section.
+func (t *Table) DWARF(arch Arch, producer string) *Sections {
+	w := &dwarfWriter{t: t, out: &Sections{}}
+	w.out.Abbrev = abbrevTable()
+
+	packages := make(map[string][]*Func)
+	var names []string
+	for i := range t.Funcs {
+		fn := &t.Funcs[i]
+		if fn.Name == "" || fn.End <= fn.Entry {
+			continue
+		}
+		pkg := packageName(fn.Name)
+		if packages[pkg] == nil {
+			names = append(names, pkg)
+		}
+		packages[pkg] = append(packages[pkg], fn)
+	}
+	sort.Strings(names)
+	for _, pkg := range names {
+		w.writeUnit(pkg, producer, packages[pkg])
+	}
+	w.writeFrame(arch)
+	return w.out
+}
+
+// packageName returns the path of the package of the function with the
+// specified name.
+func packageName(name string) string {
+	if i := strings.Index(name, "["); i >= 0 {
+		// type parameters can contain any package path
+		name = name[:i]
+	}
+	slash := strings.LastIndex(name, "/")
+	if slash < 0 {
+		slash = 0
+	}
+	if dot := strings.Index(name[slash:], "."); dot >= 0 {
+		return name[:slash+dot]
+	}
+	return name
+}
+
+func abbrevTable() []byte {
+	var b bytes.Buffer
+	for _, a := range []struct {
+		code     uint64
+		tag      dwarf.Tag
+		children bool
+		attrs    [][2]uint64
+	}{
+		{abbrevCompileUnit, dwarf.TagCompileUnit, true, [][2]uint64{{uint64(dwarf.AttrName), formString}, {uint64(dwarf.AttrProducer), formString}, {uint64(dwarf.AttrLanguage), formData1}, {uint64(dwarf.AttrLowpc), formAddr}, {uint64(dwarf.AttrRanges), formSecOffset}, {uint64(dwarf.AttrStmtList), formSecOffset}}},
+		{abbrevSubprogram, dwarf.TagSubprogram, false, [][2]uint64{{uint64(dwarf.AttrName), formString}, {uint64(dwarf.AttrLowpc), formAddr}, {uint64(dwarf.AttrHighpc), formAddr}, {uint64(dwarf.AttrExternal), formFlagPresent}}},
+	} {
+		util.EncodeULEB128(&b, a.code)
+		util.EncodeULEB128(&b, uint64(a.tag))
+		if a.children {
+			b.WriteByte(1)
+		} else {
+			b.WriteByte(0)
+		}
+		for _, attr := range a.attrs {
+			util.EncodeULEB128(&b, attr[0])
+			util.EncodeULEB128(&b, attr[1])
+		}
+		b.Write([]byte{0, 0})
+	}
+	b.WriteByte(0)
+	return b.Bytes()
+}
+
+type dwarfWriter struct {
+	t   *Table
+	out *Sections
+}
+
+func (w *dwarfWriter) addr(b []byte, v uint64) []byte {
+	var p [8]byte
+	w.t.bo.PutUint64(p[:], v)
+	if w.t.bo == binary.BigEndian {
+		return append(b, p[8-w.t.PtrSize:]...)
+	}
+	return append(b, p[:w.t.PtrSize]...)
+}
+
+func (w *dwarfWriter) u16(b []byte, v uint16) []byte {
+	var p [2]byte
+	w.t.bo.PutUint16(p[:], v)
+	return append(b, p[:]...)
+}
+
+func (w *dwarfWriter) u32(b []byte, v uint32) []byte {
+	var p [4]byte
+	w.t.bo.PutUint32(p[:], v)
+	return append(b, p[:]...)
+}
+
+// writeUnit writes the compile unit of package pkg, containing fns.
+func (w *dwarfWriter) writeUnit(pkg, producer string, fns []*Func) {
+	info := w.u32(nil, 0) // unit length, patched below
+	info = w.u16(info, 4)
+	info = w.u32(info, 0) // abbreviations offset
+	info = append(info, byte(w.t.PtrSize))
+
+	info = append(info, abbrevCompileUnit)
+	info = append(append(info, pkg...), 0)
+	info = append(append(info, producer...), 0)
+	info = append(info, dwarfGoLanguage)
+	info = w.addr(info, 0)
+	info = w.u32(info, uint32(len(w.out.Ranges)))
+	info = w.u32(info, uint32(len(w.out.Line)))
+
+	for _, fn := range fns {
+		info = append(info, abbrevSubprogram)
+		info = append(append(info, fn.Name...), 0)
+		info = w.addr(info, fn.Entry)
+		info = w.addr(info, fn.End)
+	}
+	info = append(info, 0)
+	w.t.bo.PutUint32(info, uint32(len(info)-4))
+	w.out.Info = append(w.out.Info, info...)
+
+	// adjacent functions are merged into a single range
+	var start, end uint64
+	for i, fn := range fns {
+		if i > 0 && fn.Entry != end {
+			w.out.Ranges = w.addr(w.addr(w.out.Ranges, start), end)
+			start = fn.Entry
+		} else if i == 0 {
+			start = fn.Entry
+		}
+		end = fn.End
+	}
+	w.out.Ranges = w.addr(w.addr(w.out.Ranges, start), end)
+	w.out.Ranges = w.addr(w.addr(w.out.Ranges, 0), 0)
+
+	w.out.Line = append(w.out.Line, w.lineProgram(fns)...)
+}
+
+// lineProgram returns the line number program of a compile unit containing
+// fns.
+func (w *dwarfWriter) lineProgram(fns []*Func) []byte {
+	fileIndex := make(map[string]uint64)
+	var files []string
+	lines := make([][]Line, len(fns))
+	for i, fn := range fns {
+		lines[i] = w.t.Lines(fn)
+		for _, ln := range lines[i] {
+			if _, ok := fileIndex[ln.File]; !ok {
+				files = append(files, ln.File)
+				fileIndex[ln.File] = uint64(len(files))
+			}
+		}
+	}
+
+	// Special opcodes are never used, line_base and line_range are
+	// irrelevant.
+	var hdr bytes.Buffer
+	hdr.Write([]byte{byte(w.t.Quantum), 1, 1, 0xfb, 14, 13}) // min_inst_length, max_ops_per_inst, default_is_stmt, line_base, line_range, opcode_base
+	hdr.Write([]byte{0, 1, 1, 1, 1, 0, 0, 0, 1, 0, 0, 1})    // standard_opcode_lengths
+	hdr.WriteByte(0)                                         // no include directories
+	for _, file := range files {
+		hdr.WriteString(file)
+		hdr.Write([]byte{0, 0, 0, 0}) // directory, modification time, size
+	}
+	hdr.WriteByte(0)
+
+	// All functions are written in a single sequence, like the Go linker
+	// does, so that the last row of a function extends up to the start of
+	// the next one.
+	var prog bytes.Buffer
+	quantum := uint64(w.t.Quantum)
+	var file, addr, end uint64
+	var lineno int
+	started := false
+	endSequence := func() {
+		if end > addr {
+			prog.WriteByte(line.DW_LNS_advance_pc)
+			util.EncodeULEB128(&prog, (end-addr)/quantum)
+		}
+		prog.Write([]byte{0, 1, line.DW_LINE_end_sequence})
+	}
+	for i, fn := range fns {
+		if len(lines[i]) == 0 {
+			continue
+		}
+		if started && fn.Entry < addr {
+			endSequence()
+			started = false
+		}
+		if !started {
+			prog.Write([]byte{0, byte(1 + w.t.PtrSize), line.DW_LINE_set_address})
+			prog.Write(w.addr(nil, fn.Entry))
+			file, lineno, addr = 1, 1, fn.Entry
+			started = true
+		}
+		for _, ln := range lines[i] {
+			if idx := fileIndex[ln.File]; idx != file {
+				prog.WriteByte(line.DW_LNS_set_file)
+				util.EncodeULEB128(&prog, idx)
+				file = idx
+			}
+			if ln.Line != lineno {
+				prog.WriteByte(line.DW_LNS_advance_line)
+				util.EncodeSLEB128(&prog, int64(ln.Line-lineno))
+				lineno = ln.Line
+			}
+			if ln.PC != addr {
+				prog.WriteByte(line.DW_LNS_advance_pc)
+				util.EncodeULEB128(&prog, (ln.PC-addr)/quantum)
+				addr = ln.PC
+			}
+			prog.WriteByte(line.DW_LNS_copy)
+		}
+		end = fn.End
+	}
+	if started {
+		endSequence()
+	}
+
+	out := w.u32(nil, 0) // unit length, patched below
+	out = w.u16(out, 4)
+	out = w.u32(out, uint32(hdr.Len()))
+	out = append(out, hdr.Bytes()...)
+	out = append(out, prog.Bytes()...)
+	w.t.bo.PutUint32(out, uint32(len(out)-4))
+	return out
+}
+
+// writeFrame writes the .debug_frame section, describing the frame of
+// each function using its SP delta table, like the Go linker does.
+func (w *dwarfWriter) writeFrame(arch Arch) {
+	ptrSize := int64(w.t.PtrSize)
+
+	var cie []byte
+	cie = w.u32(cie, 0) // length, patched below
+	cie = w.u32(cie, 0xffffffff)
+	cie = append(cie, 3, 0) // version, augmentation
+	cie = appendULEB(cie, 1)
+	cie = appendSLEB(cie, dataAlignmentFactor)
+	cie = appendULEB(cie, arch.RARegNum)
+	cie = append(cie, frame.DW_CFA_def_cfa)
+	cie = appendULEB(cie, arch.SPRegNum)
+	if arch.LinkRegister {
+		cie = appendULEB(cie, 0)
+		cie = append(cie, frame.DW_CFA_same_value)
+		cie = appendULEB(cie, arch.RARegNum)
+	} else {
+		// the return address is at CFA-ptrSize
+		cie = appendULEB(cie, uint64(ptrSize))
+		cie = append(cie, frame.DW_CFA_offset_extended)
+		cie = appendULEB(cie, arch.RARegNum)
+		cie = appendULEB(cie, uint64(-ptrSize/dataAlignmentFactor))
+	}
+	for len(cie)%w.t.PtrSize != 0 {
+		cie = append(cie, frame.DW_CFA_nop)
+	}
+	w.t.bo.PutUint32(cie, uint32(len(cie)-4))
+	w.out.Frame = cie
+
+	for i := range w.t.Funcs {
+		fn := &w.t.Funcs[i]
+		if fn.End <= fn.Entry {
+			continue
+		}
+		var insts []byte
+		deltas := w.t.SPDeltas(fn)
+		for j, d := range deltas {
+			next := fn.End
+			if j+1 < len(deltas) {
+				next = deltas[j+1].PC
+			}
+			cfa := d.Delta
+			if arch.LinkRegister {
+				if d.Delta > 0 {
+					// the return address is saved at 0(SP)
+					insts = append(insts, frame.DW_CFA_offset_extended_sf)
+					insts = appendULEB(insts, arch.RARegNum)
+					insts = appendSLEB(insts, -cfa/dataAlignmentFactor)
+				} else {
+					insts = append(insts, frame.DW_CFA_same_value)
+					insts = appendULEB(insts, arch.RARegNum)
+				}
+			} else {
+				cfa += ptrSize
+			}
+			insts = append(insts, frame.DW_CFA_def_cfa_offset_sf)
+			insts = appendSLEB(insts, cfa/dataAlignmentFactor)
+			if j+1 < len(deltas) {
+				insts = append(insts, frame.DW_CFA_advance_loc4)
+				insts = w.u32(insts, uint32(next-d.PC))
+			}
+		}
+
+		fde := w.u32(nil, 0) // length, patched below
+		fde = w.u32(fde, 0)  // CIE pointer
+		fde = w.addr(fde, fn.Entry)
+		fde = w.addr(fde, fn.End-fn.Entry)
+		fde = append(fde, insts...)
+		for len(fde)%w.t.PtrSize != 0 {
+			fde = append(fde, frame.DW_CFA_nop)
+		}
+		w.t.bo.PutUint32(fde, uint32(len(fde)-4))
+		w.out.Frame = append(w.out.Frame, fde...)
+	}
+}
+
+func appendULEB(b []byte, v uint64) []byte {
+	var buf bytes.Buffer
+	util.EncodeULEB128(&buf, v)
+	return append(b, buf.Bytes()...)
+}
+
+func appendSLEB(b []byte, v int64) []byte {
+	var buf bytes.Buffer
+	util.EncodeSLEB128(&buf, v)
+	return append(b, buf.Bytes()...)
+}
@@ -0,0 +1,343 @@
+// Package pclntab reads the function table of Go executables, the
+// pclntab, which is used by the Go runtime to produce stack traces and is
+// present even in executables built without DWARF debug information
+// (-ldflags=-w).
+//
+// The table is converted to DWARF, describing functions, their line
+// tables and how to unwind their frames, but not variables or types. The
+// format of the table is described by golang.org/s/go12symtab and by
+// $GOROOT/src/debug/gosym/pclntab.go.
+package pclntab
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Magic numbers at the start of the table of each version.
+const (
+	go12Magic  = 0xfffffffb
+	go116Magic = 0xfffffffa
+	go118Magic = 0xfffffff0
+	go120Magic = 0xfffffff1
+)
+
+type version uint8
+
+const (
+	ver12 version = iota + 1
+	ver116
+	ver118 // also 1.20, which only changed the encoding of function names
+)
+
+// Table is the function table of a Go executable.
+type Table struct {
+	PtrSize int
+	Quantum int // instruction size quantum, used to encode PC deltas
+
+	// Funcs are the functions of the executable, sorted by address.
+	Funcs []Func
+
+	bo          binary.ByteOrder
+	version     version
+	textStart   uint64
+	funcnametab []byte
+	cutab       []byte
+	filetab     []byte
+	pctab       []byte
+	funcdata    []byte
+	files       map[uint32]string // file names, by offset
+}
+
+// Func is a function described by the table.
+type Func struct {
+	Name       string
+	Entry, End uint64
+
+	cuOffset           uint32
+	pcsp, pcfile, pcln uint32
+}
+
+// Line is an entry of the line table of a function. It applies to all
+// instructions from PC up to the PC of the next entry.
+type Line struct {
+	PC   uint64
+	File string
+	Line int
+}
+
+// SPDelta is an entry of the table describing the frame of a function:
+// starting at PC the stack pointer is Delta bytes below its value at the
+// entry point of the function.
+type SPDelta struct {
+	PC    uint64
+	Delta int64
+}
+
+var errMalformed = errors.New("malformed pclntab")
+
+// Parse parses the pclntab in data. TextStart is the address of the text
+// segment, as specified by the runtime.text symbol.
+func Parse(data []byte, textStart uint64) (*Table, error) {
+	if len(data) < 16 || data[4] != 0 || data[5] != 0 || (data[6] != 1 && data[6] != 2 && data[6] != 4) || (data[7] != 4 && data[7] != 8) {
+		return nil, errors.New("unsupported pclntab format")
+	}
+	t := &Table{Quantum: int(data[6]), PtrSize: int(data[7]), textStart: textStart, files: make(map[uint32]string)}
+	switch {
+	case t.magic(data, binary.LittleEndian):
+		t.bo = binary.LittleEndian
+	case t.magic(data, binary.BigEndian):
+		t.bo = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("unsupported pclntab version %#x", binary.LittleEndian.Uint32(data))
+	}
+
+	word := func(i int) uint64 {
+		off := 8 + i*t.PtrSize
+		if off+t.PtrSize > len(data) {
+			return uint64(len(data))
+		}
+		return t.uintptr(data[off:])
+	}
+	tail := func(i int) []byte {
+		off := word(i)
+		if off > uint64(len(data)) {
+			return nil
+		}
+		return data[off:]
+	}
+
+	var nfunc int
+	var functab []byte
+	switch t.version {
+	case ver118:
+		nfunc = int(word(0))
+		t.funcnametab, t.cutab, t.filetab, t.pctab, t.funcdata = tail(3), tail(4), tail(5), tail(6), tail(7)
+		functab = t.funcdata
+	case ver116:
+		nfunc = int(word(0))
+		t.funcnametab, t.cutab, t.filetab, t.pctab, t.funcdata = tail(2), tail(3), tail(4), tail(5), tail(6)
+		functab = t.funcdata
+	case ver12:
+		nfunc = int(word(0))
+		t.funcnametab, t.pctab, t.funcdata = data, data, data
+		functab = data[8+t.PtrSize:]
+	}
+
+	fieldSize := t.PtrSize
+	if t.version >= ver118 {
+		fieldSize = 4
+	}
+	if nfunc < 0 || uint64(nfunc) > uint64(len(functab)) || (2*nfunc+1)*fieldSize > len(functab) {
+		return nil, errMalformed
+	}
+	field := func(i int) uint64 {
+		if fieldSize == 4 {
+			return uint64(t.bo.Uint32(functab[i*4:]))
+		}
+		return t.bo.Uint64(functab[i*8:])
+	}
+	if t.version == ver12 {
+		fileoff := uint64((2*nfunc + 1) * fieldSize)
+		if fileoff+4 > uint64(len(functab)) {
+			return nil, errMalformed
+		}
+		off := uint64(t.bo.Uint32(functab[fileoff:]))
+		if off+4 > uint64(len(data)) {
+			return nil, errMalformed
+		}
+		t.filetab = data[off:]
+	}
+
+	t.Funcs = make([]Func, 0, nfunc)
+	for i := 0; i < nfunc; i++ {
+		entry, end := field(2*i), field(2*i+2)
+		if t.version >= ver118 {
+			entry += textStart
+			end += textStart
+		}
+		fn, err := t.readFunc(field(2*i+1), entry, end)
+		if err != nil {
+			return nil, err
+		}
+		t.Funcs = append(t.Funcs, fn)
+	}
+	sort.SliceStable(t.Funcs, func(i, j int) bool { return t.Funcs[i].Entry < t.Funcs[j].Entry })
+	return t, nil
+}
+
+func (t *Table) magic(data []byte, bo binary.ByteOrder) bool {
+	switch bo.Uint32(data) {
+	case go12Magic:
+		t.version = ver12
+	case go116Magic:
+		t.version = ver116
+	case go118Magic, go120Magic:
+		t.version = ver118
+	default:
+		return false
+	}
+	return true
+}
+
+func (t *Table) uintptr(b []byte) uint64 {
+	if t.PtrSize == 4 {
+		return uint64(t.bo.Uint32(b))
+	}
+	return t.bo.Uint64(b)
+}
+
+// readFunc reads the _func structure at offset off of funcdata.
+func (t *Table) readFunc(off, entry, end uint64) (Func, error) {
+	// The first field is the entry point, which is a 32bit offset from the
+	// start of the text segment since Go 1.18, the following fields are
+	// 32bit values.
+	sz0 := uint64(t.PtrSize)
+	if t.version >= ver118 {
+		sz0 = 4
+	}
+	const nfields = 9
+	if off > uint64(len(t.funcdata)) || off+sz0+(nfields-1)*4 > uint64(len(t.funcdata)) {
+		return Func{}, errMalformed
+	}
+	data := t.funcdata[off:]
+	field := func(n uint64) uint32 {
+		return t.bo.Uint32(data[sz0+(n-1)*4:])
+	}
+	fn := Func{Entry: entry, End: end, pcsp: field(4), pcfile: field(5), pcln: field(6), cuOffset: ^uint32(0)}
+	if t.version >= ver116 {
+		fn.cuOffset = field(8)
+	}
+	fn.Name = t.string(t.funcnametab, field(1))
+	return fn, nil
+}
+
+// string returns the zero terminated string at offset off of tab.
+func (t *Table) string(tab []byte, off uint32) string {
+	if uint64(off) >= uint64(len(tab)) {
+		return ""
+	}
+	end := bytes.IndexByte(tab[off:], 0)
+	if end < 0 {
+		return ""
+	}
+	return string(tab[off : int(off)+end])
+}
+
+// file returns the name of file number fno of fn.
+func (t *Table) file(fn *Func, fno int64) string {
+	tab := t.filetab
+	var off uint32
+	if t.version == ver12 {
+		if fno <= 0 || uint64(fno)*4+4 > uint64(len(t.filetab)) {
+			return ""
+		}
+		tab, off = t.funcdata, t.bo.Uint32(t.filetab[fno*4:])
+	} else {
+		if fno < 0 || fn.cuOffset == ^uint32(0) {
+			return ""
+		}
+		idx := (uint64(fn.cuOffset) + uint64(fno)) * 4
+		if idx+4 > uint64(len(t.cutab)) {
+			return ""
+		}
+		off = t.bo.Uint32(t.cutab[idx:])
+		if off == ^uint32(0) {
+			return ""
+		}
+	}
+	if s, ok := t.files[off]; ok {
+		return s
+	}
+	s := t.string(tab, off)
+	t.files[off] = s
+	return s
+}
+
+// pcvalue is a run of a PC-value table: value applies to the instructions
+// from pc up to the pc of the next run.
+type pcvalue struct {
+	pc    uint64
+	value int64
+}
+
+// decode returns the runs of the PC-value table at offset off of pctab,
+// for a function starting at entry.
+func (t *Table) decode(off uint32, entry uint64) []pcvalue {
+	if off == 0 || uint64(off) >= uint64(len(t.pctab)) {
+		return nil
+	}
+	p := t.pctab[off:]
+	var r []pcvalue
+	pc, val := entry, int64(-1)
+	for first := true; ; first = false {
+		uvdelta, n := binary.Uvarint(p)
+		if n <= 0 || (uvdelta == 0 && !first) {
+			break
+		}
+		p = p[n:]
+		pcdelta, n := binary.Uvarint(p)
+		if n <= 0 {
+			break
+		}
+		p = p[n:]
+		if uvdelta&1 != 0 {
+			val += ^int64(uvdelta >> 1)
+		} else {
+			val += int64(uvdelta >> 1)
+		}
+		r = append(r, pcvalue{pc, val})
+		pc += pcdelta * uint64(t.Quantum)
+	}
+	return r
+}
+
+// Lines returns the line table of fn.
+func (t *Table) Lines(fn *Func) []Line {
+	files := t.decode(fn.pcfile, fn.Entry)
+	lines := t.decode(fn.pcln, fn.Entry)
+	var r []Line
+	i, j := 0, 0
+	for i < len(files) && j < len(lines) {
+		pc := files[i].pc
+		if lines[j].pc > pc {
+			pc = lines[j].pc
+		}
+		if pc >= fn.End {
+			break
+		}
+		if ln := (Line{pc, t.file(fn, files[i].value), int(lines[j].value)}); len(r) == 0 || r[len(r)-1].File != ln.File || r[len(r)-1].Line != ln.Line {
+			r = append(r, ln)
+		}
+		// advance the run that ends first
+		fileEnd, lineEnd := fn.End, fn.End
+		if i+1 < len(files) {
+			fileEnd = files[i+1].pc
+		}
+		if j+1 < len(lines) {
+			lineEnd = lines[j+1].pc
+		}
+		if fileEnd <= lineEnd {
+			i++
+		}
+		if lineEnd <= fileEnd {
+			j++
+		}
+	}
+	return r
+}
+
+// SPDeltas returns the table describing the frame of fn.
+func (t *Table) SPDeltas(fn *Func) []SPDelta {
+	var r []SPDelta
+	for _, v := range t.decode(fn.pcsp, fn.Entry) {
+		if v.pc >= fn.End {
+			break
+		}
+		r = append(r, SPDelta{v.pc, v.value})
+	}
+	return r
+}
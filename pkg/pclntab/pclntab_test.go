@@ -0,0 +1,192 @@
+package pclntab
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"debug/gosym"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/go-delve/delve/pkg/dwarf/frame"
+	"github.com/go-delve/delve/pkg/dwarf/line"
+	"github.com/go-delve/delve/pkg/dwarf/regnum"
+)
+
+func buildStripped(t *testing.T) *elf.File {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("only supported on linux/amd64")
+	}
+	src, _ := filepath.Abs("../../_fixtures/testnextprog.go")
+	exe := filepath.Join(t.TempDir(), "testnextprog")
+	if out, err := exec.Command("go", "build", "-ldflags=-w", "-o", exe, src).CombinedOutput(); err != nil {
+		t.Fatalf("could not build fixture: %v\n%s", err, out)
+	}
+	f, err := elf.Open(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func loadTable(t *testing.T, f *elf.File) (*Table, *gosym.Table) {
+	data, err := f.Section(".gopclntab").Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	syms, err := f.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var textStart uint64
+	for _, sym := range syms {
+		if sym.Name == "runtime.text" {
+			textStart = sym.Value
+		}
+	}
+	tab, err := Parse(data, textStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotab, err := gosym.NewTable(nil, gosym.NewLineTable(data, textStart))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tab, gotab
+}
+
+func TestParse(t *testing.T) {
+	f := buildStripped(t)
+	tab, gotab := loadTable(t, f)
+	if len(tab.Funcs) != len(gotab.Funcs) {
+		t.Fatalf("wrong number of functions %d, expected %d", len(tab.Funcs), len(gotab.Funcs))
+	}
+	for i := range tab.Funcs {
+		fn, gofn := &tab.Funcs[i], &gotab.Funcs[i]
+		if fn.Name != gofn.Name || fn.Entry != gofn.Entry || fn.End != gofn.End {
+			t.Fatalf("wrong function %s %#x-%#x, expected %s %#x-%#x", fn.Name, fn.Entry, fn.End, gofn.Name, gofn.Entry, gofn.End)
+		}
+		lines := tab.Lines(fn)
+		for j, ln := range lines {
+			end := fn.End
+			if j+1 < len(lines) {
+				end = lines[j+1].PC
+			}
+			for _, pc := range []uint64{ln.PC, end - 1} {
+				file, lineno, _ := gotab.PCToLine(pc)
+				if lineno == -1 && pc != ln.PC {
+					// padding after the last instruction of the function
+					continue
+				}
+				if file != ln.File || lineno != ln.Line {
+					t.Fatalf("%s: wrong position of %#x %s:%d, expected %s:%d", fn.Name, pc, ln.File, ln.Line, file, lineno)
+				}
+			}
+		}
+	}
+	main := gotab.LookupFunc("main.main")
+	if main == nil {
+		t.Fatal("main.main not found")
+	}
+	if tab.Lines(&tab.Funcs[sortSearch(tab, main.Entry)])[0].File != filepath.ToSlash(mustAbs("../../_fixtures/testnextprog.go")) {
+		t.Errorf("wrong file of main.main")
+	}
+}
+
+func sortSearch(tab *Table, entry uint64) int {
+	for i := range tab.Funcs {
+		if tab.Funcs[i].Entry == entry {
+			return i
+		}
+	}
+	return -1
+}
+
+func mustAbs(path string) string {
+	r, _ := filepath.Abs(path)
+	return r
+}
+
+func TestDWARF(t *testing.T) {
+	f := buildStripped(t)
+	tab, gotab := loadTable(t, f)
+	sec := tab.DWARF(Arch{SPRegNum: regnum.AMD64_Rsp, RARegNum: regnum.AMD64_Rip}, "Go cmd/compile go1.16")
+	d, err := dwarf.New(sec.Abbrev, nil, nil, sec.Info, nil, nil, sec.Ranges, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdr := d.Reader()
+	var cu *dwarf.Entry
+	var lineInfo *line.DebugLineInfo
+	nfuncs := 0
+	for {
+		e, err := rdr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e == nil {
+			break
+		}
+		switch e.Tag {
+		case dwarf.TagCompileUnit:
+			cu = e
+			off := e.Val(dwarf.AttrStmtList).(int64)
+			lineInfo = line.ParseAll(sec.Line[off:], nil, nil, 0, false, 8)[0]
+		case dwarf.TagSubprogram:
+			nfuncs++
+			name := e.Val(dwarf.AttrName).(string)
+			if pkg := cu.Val(dwarf.AttrName).(string); packageName(name) != pkg {
+				t.Errorf("function %s in compile unit %s", name, pkg)
+			}
+			if name != "main.main" {
+				continue
+			}
+			entry := e.Val(dwarf.AttrLowpc).(uint64)
+			gofn := gotab.LookupFunc("main.main")
+			if entry != gofn.Entry || e.Val(dwarf.AttrHighpc).(uint64) != gofn.End {
+				t.Errorf("wrong range of main.main")
+			}
+			for pc := gofn.Entry; pc < gofn.End; pc++ {
+				file, ln := lineInfo.PCToLine(entry, pc)
+				gofile, goln, _ := gotab.PCToLine(pc)
+				if goln == -1 {
+					// padding after the last instruction of the function
+					continue
+				}
+				if file != gofile || ln != goln {
+					t.Fatalf("wrong position of %#x %s:%d, expected %s:%d", pc, file, ln, gofile, goln)
+				}
+			}
+		}
+	}
+	if nfuncs == 0 || nfuncs > len(tab.Funcs) {
+		t.Errorf("wrong number of functions %d", nfuncs)
+	}
+
+	fdes, err := frame.Parse(sec.Frame, frame.DwarfEndian(sec.Info), 0, 8, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gofn := gotab.LookupFunc("main.main")
+	fde, err := fdes.FDEForPC(gofn.Entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fn *Func
+	for i := range tab.Funcs {
+		if tab.Funcs[i].Entry == gofn.Entry {
+			fn = &tab.Funcs[i]
+		}
+	}
+	for _, d := range tab.SPDeltas(fn) {
+		fctxt := fde.EstablishFrame(d.PC)
+		if fctxt.CFA.Reg != regnum.AMD64_Rsp || fctxt.CFA.Offset != d.Delta+8 {
+			t.Errorf("wrong CFA at %#x: %v, expected rsp+%d", d.PC, fctxt.CFA, d.Delta+8)
+		}
+		if ra := fctxt.Regs[regnum.AMD64_Rip]; ra.Rule != frame.RuleOffset || ra.Offset != -8 {
+			t.Errorf("wrong return address rule at %#x: %v", d.PC, ra)
+		}
+	}
+}
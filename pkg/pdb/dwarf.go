@@ -0,0 +1,531 @@
+package pdb
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"encoding/binary"
+
+	"github.com/go-delve/delve/pkg/dwarf/line"
+	"github.com/go-delve/delve/pkg/dwarf/op"
+	"github.com/go-delve/delve/pkg/dwarf/util"
+)
+
+// Machine types, see IMAGE_FILE_MACHINE_* in debug/pe.
+const (
+	machineI386  = 0x14c
+	machineAMD64 = 0x8664
+	machineARM64 = 0xaa64
+)
+
+// CodeView register numbers.
+const (
+	cvRegEAX = 17
+	cvRegEBX = 20
+	cvRegESP = 21
+	cvRegEBP = 22
+	cvRegEDI = 24
+
+	cvAMD64RAX = 328
+	cvAMD64RBP = 334
+	cvAMD64RSP = 335
+	cvAMD64R13 = 341
+	cvAMD64R15 = 343
+
+	cvARM64X0  = 50
+	cvARM64X19 = 69
+	cvARM64FP  = 79
+	cvARM64LR  = 80
+	cvARM64SP  = 81
+)
+
+// dwarfRegister returns the DWARF register number corresponding to the
+// CodeView register reg.
+func dwarfRegister(machine, reg uint16) (uint64, bool) {
+	switch machine {
+	case machineI386:
+		// eax, ecx, edx, ebx, esp, ebp, esi, edi have the same order
+		if reg >= cvRegEAX && reg <= cvRegEDI {
+			return uint64(reg - cvRegEAX), true
+		}
+	case machineAMD64:
+		if reg >= cvAMD64RAX && reg <= cvAMD64R15 {
+			// rax, rbx, rcx, rdx, rsi, rdi, rbp, rsp, r8-r15
+			return [...]uint64{0, 3, 2, 1, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}[reg-cvAMD64RAX], true
+		}
+	case machineARM64:
+		if reg >= cvARM64X0 && reg <= cvARM64SP {
+			return uint64(reg - cvARM64X0), true
+		}
+	}
+	return 0, false
+}
+
+// Sections are the DWARF sections used by AppendDWARF.
+type Sections struct {
+	Info, Abbrev, Line, Ranges []byte
+}
+
+// Abbreviation codes of the entries written by AppendDWARF.
+const (
+	abbrevCompileUnit = iota + 1
+	abbrevSubprogram
+	abbrevSubprogramNoChildren
+	abbrevFormalParameter
+	abbrevVariable
+	abbrevBaseType
+	abbrevPointerType
+	abbrevVoidPointerType
+	abbrevStructType
+	abbrevUnionType
+	abbrevDeclaration
+	abbrevMember
+	abbrevArrayType
+	abbrevSubrangeType
+)
+
+type abbrevAttr struct {
+	attr dwarf.Attr
+	form uint64
+}
+
+// DWARF forms.
+const (
+	formAddr        = 0x01
+	formData1       = 0x0b
+	formData2       = 0x05
+	formData4       = 0x06
+	formString      = 0x08
+	formUdata       = 0x0f
+	formRef4        = 0x13
+	formSecOffset   = 0x17
+	formExprloc     = 0x18
+	formFlagPresent = 0x19
+)
+
+var abbrevs = []struct {
+	code     uint64
+	tag      dwarf.Tag
+	children bool
+	attrs    []abbrevAttr
+}{
+	{abbrevCompileUnit, dwarf.TagCompileUnit, true, []abbrevAttr{{dwarf.AttrName, formString}, {dwarf.AttrProducer, formString}, {dwarf.AttrLanguage, formData2}, {dwarf.AttrLowpc, formAddr}, {dwarf.AttrRanges, formSecOffset}, {dwarf.AttrStmtList, formSecOffset}}},
+	{abbrevSubprogram, dwarf.TagSubprogram, true, []abbrevAttr{{dwarf.AttrName, formString}, {dwarf.AttrLowpc, formAddr}, {dwarf.AttrHighpc, formData4}}},
+	{abbrevSubprogramNoChildren, dwarf.TagSubprogram, false, []abbrevAttr{{dwarf.AttrName, formString}, {dwarf.AttrLowpc, formAddr}, {dwarf.AttrHighpc, formData4}}},
+	{abbrevFormalParameter, dwarf.TagFormalParameter, false, []abbrevAttr{{dwarf.AttrName, formString}, {dwarf.AttrType, formRef4}, {dwarf.AttrLocation, formExprloc}}},
+	{abbrevVariable, dwarf.TagVariable, false, []abbrevAttr{{dwarf.AttrName, formString}, {dwarf.AttrType, formRef4}, {dwarf.AttrLocation, formExprloc}}},
+	{abbrevBaseType, dwarf.TagBaseType, false, []abbrevAttr{{dwarf.AttrName, formString}, {dwarf.AttrEncoding, formData1}, {dwarf.AttrByteSize, formUdata}}},
+	{abbrevPointerType, dwarf.TagPointerType, false, []abbrevAttr{{dwarf.AttrType, formRef4}, {dwarf.AttrByteSize, formUdata}}},
+	{abbrevVoidPointerType, dwarf.TagPointerType, false, []abbrevAttr{{dwarf.AttrByteSize, formUdata}}},
+	{abbrevStructType, dwarf.TagStructType, true, []abbrevAttr{{dwarf.AttrName, formString}, {dwarf.AttrByteSize, formUdata}}},
+	{abbrevUnionType, dwarf.TagUnionType, true, []abbrevAttr{{dwarf.AttrName, formString}, {dwarf.AttrByteSize, formUdata}}},
+	{abbrevDeclaration, dwarf.TagStructType, false, []abbrevAttr{{dwarf.AttrName, formString}, {dwarf.AttrDeclaration, formFlagPresent}}},
+	{abbrevMember, dwarf.TagMember, false, []abbrevAttr{{dwarf.AttrName, formString}, {dwarf.AttrType, formRef4}, {dwarf.AttrDataMemberLoc, formUdata}}},
+	{abbrevArrayType, dwarf.TagArrayType, true, []abbrevAttr{{dwarf.AttrType, formRef4}}},
+	{abbrevSubrangeType, dwarf.TagSubrangeType, false, []abbrevAttr{{dwarf.AttrCount, formUdata}}},
+}
+
+// DWARF base type encodings and languages.
+const (
+	encBoolean      = 0x02
+	encFloat        = 0x04
+	encSigned       = 0x05
+	encSignedChar   = 0x06
+	encUnsigned     = 0x07
+	encUnsignedChar = 0x08
+
+	langCPlusPlus = 0x04
+	langC99       = 0x0c
+)
+
+// AppendDWARF returns a copy of sec where a compile unit, describing its
+// functions with their line tables, parameters and local variables, is
+// appended to sec.Info for each module of f, with the abbreviations, line
+// tables and range lists of the new units appended to the corresponding
+// sections.
+// Addresses are computed adding imageBase to the section addresses in
+// sections, which are the virtual addresses of the sections of the
+// executable (f.Sections can be used if the PDB file contains them).
+// The number of functions converted is also returned.
+func (f *File) AppendDWARF(sec *Sections, imageBase uint64, sections []uint32) (*Sections, int) {
+	out := &Sections{
+		Info:   append([]byte(nil), sec.Info...),
+		Abbrev: append([]byte(nil), sec.Abbrev...),
+		Line:   append([]byte(nil), sec.Line...),
+		Ranges: append([]byte(nil), sec.Ranges...),
+	}
+	w := &dwarfWriter{f: f, out: out, ptrSize: 8, imageBase: imageBase, sections: sections}
+	if f.Machine == machineI386 {
+		w.ptrSize = 4
+	}
+	w.abbrevOff = uint32(len(out.Abbrev))
+	out.Abbrev = append(out.Abbrev, abbrevTable()...)
+
+	n := 0
+	for _, mod := range f.Modules {
+		n += w.writeModule(mod)
+	}
+	if n == 0 {
+		return sec, 0
+	}
+	return out, n
+}
+
+func abbrevTable() []byte {
+	var b bytes.Buffer
+	for _, a := range abbrevs {
+		util.EncodeULEB128(&b, a.code)
+		util.EncodeULEB128(&b, uint64(a.tag))
+		if a.children {
+			b.WriteByte(1)
+		} else {
+			b.WriteByte(0)
+		}
+		for _, attr := range a.attrs {
+			util.EncodeULEB128(&b, uint64(attr.attr))
+			util.EncodeULEB128(&b, attr.form)
+		}
+		b.Write([]byte{0, 0})
+	}
+	b.WriteByte(0)
+	return b.Bytes()
+}
+
+// dwarfWriter converts the modules of a PDB file into DWARF compile units.
+type dwarfWriter struct {
+	f         *File
+	out       *Sections
+	ptrSize   int
+	imageBase uint64
+	sections  []uint32
+	abbrevOff uint32
+
+	// state of the current compile unit
+	info     bytes.Buffer
+	types    map[*Type]uint32 // offset of the entries of types in the unit
+	queue    []*Type          // types referenced but not written yet
+	fixups   []typeFixup
+	unitBase int // offset of the unit in out.Info
+}
+
+// typeFixup is a reference to a type whose entry has not been written yet.
+type typeFixup struct {
+	pos int
+	typ *Type
+}
+
+type dwarfFunction struct {
+	fn         *Function
+	start, end uint64
+}
+
+// writeModule writes the compile unit describing mod and returns the
+// number of functions it contains.
+func (w *dwarfWriter) writeModule(mod *Module) int {
+	var fns []dwarfFunction
+	for _, fn := range mod.Functions {
+		rva, ok := fn.Addr.RVA(w.sections)
+		if !ok || fn.Size == 0 {
+			continue
+		}
+		start := w.imageBase + uint64(rva)
+		fns = append(fns, dwarfFunction{fn, start, start + uint64(fn.Size)})
+	}
+	if len(fns) == 0 {
+		return 0
+	}
+
+	w.info.Reset()
+	w.types = make(map[*Type]uint32)
+	w.queue = w.queue[:0]
+	w.fixups = w.fixups[:0]
+	w.unitBase = len(w.out.Info)
+
+	// unit header
+	w.u32(0) // unit length, patched below
+	w.u16(4)
+	w.u32(w.abbrevOff)
+	w.info.WriteByte(byte(w.ptrSize))
+
+	lang := uint16(langC99)
+	if mod.Language == 1 { // CV_CFL_CXX
+		lang = langCPlusPlus
+	}
+	w.uleb(abbrevCompileUnit)
+	w.str(mod.Name)
+	w.str(mod.Compiler)
+	w.u16(lang)
+	w.addr(0)
+	w.u32(uint32(len(w.out.Ranges)))
+	w.u32(uint32(len(w.out.Line)))
+
+	for _, dfn := range fns {
+		w.writeFunction(dfn)
+	}
+	for len(w.queue) > 0 {
+		t := w.queue[0]
+		w.queue = w.queue[1:]
+		w.writeType(t)
+	}
+	w.info.WriteByte(0) // end of the children of the compile unit
+
+	info := w.info.Bytes()
+	for _, fixup := range w.fixups {
+		binary.LittleEndian.PutUint32(info[fixup.pos:], w.types[fixup.typ])
+	}
+	binary.LittleEndian.PutUint32(info, uint32(len(info)-4))
+	w.out.Info = append(w.out.Info, info...)
+
+	for _, dfn := range fns {
+		w.out.Ranges = appendAddr(w.out.Ranges, dfn.start, w.ptrSize)
+		w.out.Ranges = appendAddr(w.out.Ranges, dfn.end, w.ptrSize)
+	}
+	w.out.Ranges = appendAddr(w.out.Ranges, 0, w.ptrSize)
+	w.out.Ranges = appendAddr(w.out.Ranges, 0, w.ptrSize)
+
+	w.out.Line = append(w.out.Line, w.lineProgram(fns)...)
+	return len(fns)
+}
+
+func (w *dwarfWriter) writeFunction(dfn dwarfFunction) {
+	type variable struct {
+		v    Variable
+		typ  *Type
+		expr []byte
+	}
+	var vars []variable
+	for _, v := range dfn.fn.Variables {
+		typ := w.f.Type(v.Type)
+		if typ == nil || typ.Kind == KindVoid {
+			continue
+		}
+		reg, ok := dwarfRegister(w.f.Machine, v.Register)
+		if !ok {
+			continue
+		}
+		var expr bytes.Buffer
+		switch v.Location {
+		case VarRegRel:
+			if reg < 32 {
+				expr.WriteByte(byte(op.DW_OP_breg0) + byte(reg))
+			} else {
+				expr.WriteByte(byte(op.DW_OP_bregx))
+				util.EncodeULEB128(&expr, reg)
+			}
+			util.EncodeSLEB128(&expr, int64(v.Offset))
+		case VarRegister:
+			if reg < 32 {
+				expr.WriteByte(byte(op.DW_OP_reg0) + byte(reg))
+			} else {
+				expr.WriteByte(byte(op.DW_OP_regx))
+				util.EncodeULEB128(&expr, reg)
+			}
+		}
+		vars = append(vars, variable{v, typ, expr.Bytes()})
+	}
+
+	if len(vars) == 0 {
+		w.uleb(abbrevSubprogramNoChildren)
+	} else {
+		w.uleb(abbrevSubprogram)
+	}
+	w.str(dfn.fn.Name)
+	w.addr(dfn.start)
+	w.u32(uint32(dfn.end - dfn.start))
+	if len(vars) == 0 {
+		return
+	}
+	for _, v := range vars {
+		if v.v.Param {
+			w.uleb(abbrevFormalParameter)
+		} else {
+			w.uleb(abbrevVariable)
+		}
+		w.str(v.v.Name)
+		w.typeRef(v.typ)
+		w.uleb(uint64(len(v.expr)))
+		w.info.Write(v.expr)
+	}
+	w.info.WriteByte(0)
+}
+
+// typeRef writes a reference to the entry of typ, which will be written
+// later if it has not been written yet.
+func (w *dwarfWriter) typeRef(typ *Type) {
+	if _, ok := w.types[typ]; !ok {
+		w.types[typ] = 0
+		w.queue = append(w.queue, typ)
+	}
+	w.fixups = append(w.fixups, typeFixup{w.info.Len(), typ})
+	w.u32(0)
+}
+
+func (w *dwarfWriter) writeType(t *Type) {
+	w.types[t] = uint32(w.info.Len())
+	switch t.Kind {
+	case KindBool, KindChar, KindUchar, KindInt, KindUint, KindFloat:
+		w.uleb(abbrevBaseType)
+		w.str(t.Name)
+		w.info.WriteByte(map[TypeKind]byte{
+			KindBool:  encBoolean,
+			KindChar:  encSignedChar,
+			KindUchar: encUnsignedChar,
+			KindInt:   encSigned,
+			KindUint:  encUnsigned,
+			KindFloat: encFloat,
+		}[t.Kind])
+		w.uleb(uint64(t.Size))
+	case KindPointer:
+		if t.Elem == nil || t.Elem.Kind == KindVoid {
+			w.uleb(abbrevVoidPointerType)
+		} else {
+			w.uleb(abbrevPointerType)
+			w.typeRef(t.Elem)
+		}
+		w.uleb(uint64(t.Size))
+	case KindStruct, KindUnion:
+		if t.Incomplete {
+			w.uleb(abbrevDeclaration)
+			w.str(t.Name)
+			break
+		}
+		if t.Kind == KindStruct {
+			w.uleb(abbrevStructType)
+		} else {
+			w.uleb(abbrevUnionType)
+		}
+		w.str(t.Name)
+		w.uleb(uint64(t.Size))
+		for _, field := range t.Fields {
+			if field.Type.Kind == KindVoid {
+				continue
+			}
+			w.uleb(abbrevMember)
+			w.str(field.Name)
+			w.typeRef(field.Type)
+			w.uleb(uint64(field.Offset))
+		}
+		w.info.WriteByte(0)
+	case KindArray:
+		w.uleb(abbrevArrayType)
+		w.typeRef(t.Elem)
+		w.uleb(abbrevSubrangeType)
+		n := int64(0)
+		if t.Elem.Size > 0 {
+			n = t.Size / t.Elem.Size
+		}
+		w.uleb(uint64(n))
+		w.info.WriteByte(0)
+	default:
+		// void is only referenced through pointers, which use a separate
+		// abbreviation, write it as an empty declaration.
+		w.uleb(abbrevDeclaration)
+		w.str(t.Name)
+	}
+}
+
+// lineProgram returns the line number program of a compile unit containing
+// fns.
+func (w *dwarfWriter) lineProgram(fns []dwarfFunction) []byte {
+	fileIndex := make(map[string]uint64)
+	var files []string
+	for _, dfn := range fns {
+		for _, ln := range dfn.fn.Lines {
+			if _, ok := fileIndex[ln.File]; !ok {
+				files = append(files, ln.File)
+				fileIndex[ln.File] = uint64(len(files))
+			}
+		}
+	}
+
+	// Special opcodes are never used, line_base and line_range are
+	// irrelevant.
+	var hdr bytes.Buffer
+	hdr.Write([]byte{1, 1, 1, 0xfb, 14, 13})              // min_inst_length, max_ops_per_inst, default_is_stmt, line_base, line_range, opcode_base
+	hdr.Write([]byte{0, 1, 1, 1, 1, 0, 0, 0, 1, 0, 0, 1}) // standard_opcode_lengths
+	hdr.WriteByte(0)                                      // no include directories
+	for _, file := range files {
+		hdr.WriteString(file)
+		hdr.Write([]byte{0, 0, 0, 0}) // directory, modification time, size
+	}
+	hdr.WriteByte(0)
+
+	// Each function is written in its own sequence, so that the last row of
+	// a function never extends over the padding, or the code without line
+	// information, that follows it.
+	var prog bytes.Buffer
+	for _, dfn := range fns {
+		if len(dfn.fn.Lines) == 0 {
+			continue
+		}
+		prog.Write([]byte{0, byte(1 + w.ptrSize), byte(line.DW_LINE_set_address)})
+		prog.Write(appendAddr(nil, dfn.start, w.ptrSize))
+		file, lineno, addr := uint64(1), 1, dfn.start
+		for _, ln := range dfn.fn.Lines {
+			pc := dfn.start + uint64(ln.Offset)
+			if idx := fileIndex[ln.File]; idx != file {
+				prog.WriteByte(byte(line.DW_LNS_set_file))
+				util.EncodeULEB128(&prog, idx)
+				file = idx
+			}
+			if ln.Line != lineno {
+				prog.WriteByte(byte(line.DW_LNS_advance_line))
+				util.EncodeSLEB128(&prog, int64(ln.Line-lineno))
+				lineno = ln.Line
+			}
+			if pc != addr {
+				prog.WriteByte(byte(line.DW_LNS_advance_pc))
+				util.EncodeULEB128(&prog, pc-addr)
+				addr = pc
+			}
+			prog.WriteByte(byte(line.DW_LNS_copy))
+		}
+		if dfn.end > addr {
+			prog.WriteByte(byte(line.DW_LNS_advance_pc))
+			util.EncodeULEB128(&prog, dfn.end-addr)
+		}
+		prog.Write([]byte{0, 1, byte(line.DW_LINE_end_sequence)})
+	}
+
+	var out bytes.Buffer
+	var u32 [4]byte
+	out.Write(u32[:]) // unit length, patched below
+	out.Write([]byte{4, 0})
+	binary.LittleEndian.PutUint32(u32[:], uint32(hdr.Len()))
+	out.Write(u32[:])
+	out.Write(hdr.Bytes())
+	out.Write(prog.Bytes())
+	r := out.Bytes()
+	binary.LittleEndian.PutUint32(r, uint32(len(r)-4))
+	return r
+}
+
+func (w *dwarfWriter) u16(v uint16) {
+	var b [2]byte
+	binary.LittleEndian.PutUint16(b[:], v)
+	w.info.Write(b[:])
+}
+
+func (w *dwarfWriter) u32(v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	w.info.Write(b[:])
+}
+
+func (w *dwarfWriter) uleb(v uint64) {
+	util.EncodeULEB128(&w.info, v)
+}
+
+func (w *dwarfWriter) str(s string) {
+	w.info.WriteString(s)
+	w.info.WriteByte(0)
+}
+
+func (w *dwarfWriter) addr(v uint64) {
+	w.info.Write(appendAddr(nil, v, w.ptrSize))
+}
+
+func appendAddr(b []byte, v uint64, ptrSize int) []byte {
+	var p [8]byte
+	binary.LittleEndian.PutUint64(p[:], v)
+	return append(b, p[:ptrSize]...)
+}
@@ -0,0 +1,546 @@
+// Package pdb reads the debug symbols of Windows executables from PDB
+// files.
+//
+// Only the information needed to symbolicate code that has no DWARF debug
+// information is read: the functions of each module, with their line
+// tables and local variables, and the types of the variables. See
+// https://llvm.org/docs/PDB/index.html for a description of the format.
+package pdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+var msfMagic = []byte("Microsoft C/C++ MSF 7.00\r\n\x1aDS\x00\x00\x00")
+
+// Fixed stream indexes.
+const (
+	streamInfo = 1
+	streamTPI  = 2
+	streamDBI  = 3
+)
+
+// noStream is the index of a missing stream.
+const noStream = 0xffff
+
+// index of the section headers stream in the optional debug header of the
+// DBI stream.
+const dbgHeaderSectionHdr = 5
+
+// File is a PDB file.
+type File struct {
+	// GUID and Age identify the executable the PDB file belongs to, see
+	// CodeView.
+	GUID [16]byte
+	Age  uint32
+
+	// Machine is the machine type of the executable, one of the
+	// IMAGE_FILE_MACHINE_* constants of debug/pe.
+	Machine uint16
+
+	Modules []*Module
+
+	// Sections are the virtual addresses, relative to the image base, of
+	// the sections of the executable, if the PDB file contains a copy of
+	// its section headers.
+	Sections []uint32
+
+	msf    *msf
+	names  []byte // contents of the /names string table
+	types  *typeTable
+	parsed map[TypeIndex]*Type
+}
+
+// Module is a compilation unit (an object file or a library member) linked
+// into the executable.
+type Module struct {
+	Name    string
+	ObjFile string
+
+	// Language is the source language of the module, as specified by its
+	// S_COMPILE3 symbol (0 is C, 1 is C++).
+	Language uint8
+	// Compiler is the version string of the compiler that produced the
+	// module.
+	Compiler string
+
+	Functions []*Function // sorted by address
+}
+
+// Address is a location in the executable, as a section number (starting
+// at 1) and an offset inside the section.
+type Address struct {
+	Section uint16
+	Offset  uint32
+}
+
+// Function is a function described by a S_GPROC32 or S_LPROC32 symbol.
+type Function struct {
+	Name      string
+	Addr      Address
+	Size      uint32
+	Type      TypeIndex
+	Variables []Variable // parameters first, in order
+	Lines     []Line     // sorted by offset
+}
+
+// Line is an entry of the line table of a function.
+type Line struct {
+	Offset uint32 // offset from the start of the function
+	File   string
+	Line   int
+	IsStmt bool
+}
+
+// VarLocation is the kind of location of a variable.
+type VarLocation uint8
+
+const (
+	// VarRegRel is a variable stored in memory, at Offset from the value of
+	// Register.
+	VarRegRel VarLocation = iota
+	// VarRegister is a variable stored in Register.
+	VarRegister
+)
+
+// Variable is a parameter or a local variable of a function.
+type Variable struct {
+	Name     string
+	Type     TypeIndex
+	Param    bool
+	Location VarLocation
+	Register uint16 // CodeView register number (CV_REG_*, CV_AMD64_*, CV_ARM64_*)
+	Offset   int32
+}
+
+// Open opens the PDB file at path.
+func Open(path string) (*File, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	f, err := Parse(fh)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return f, nil
+}
+
+// Parse reads a PDB file from r. All the data needed is read before
+// returning.
+func Parse(r io.ReaderAt) (*File, error) {
+	m, err := openMSF(r)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{msf: m, parsed: make(map[TypeIndex]*Type)}
+
+	info, err := m.stream(streamInfo)
+	if err != nil {
+		return nil, err
+	}
+	namedStreams, err := f.parseInfo(info)
+	if err != nil {
+		return nil, fmt.Errorf("PDB info stream: %v", err)
+	}
+	if idx, ok := namedStreams["/names"]; ok {
+		names, err := m.stream(idx)
+		if err != nil {
+			return nil, err
+		}
+		f.names, err = parseStringTable(names)
+		if err != nil {
+			return nil, fmt.Errorf("/names stream: %v", err)
+		}
+	}
+
+	tpi, err := m.stream(streamTPI)
+	if err != nil {
+		return nil, err
+	}
+	f.types, err = parseTypeTable(tpi)
+	if err != nil {
+		return nil, fmt.Errorf("TPI stream: %v", err)
+	}
+
+	dbi, err := m.stream(streamDBI)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.parseDBI(dbi); err != nil {
+		return nil, fmt.Errorf("DBI stream: %v", err)
+	}
+	return f, nil
+}
+
+// RVA returns the address of addr relative to the image base, using the
+// section addresses in sections.
+func (addr Address) RVA(sections []uint32) (uint32, bool) {
+	if addr.Section == 0 || int(addr.Section) > len(sections) {
+		return 0, false
+	}
+	return sections[addr.Section-1] + addr.Offset, true
+}
+
+// msf is a Multi-Stream File, the container format of PDB files.
+type msf struct {
+	r         io.ReaderAt
+	blockSize uint32
+	streams   [][]uint32 // blocks of each stream
+	sizes     []uint32
+}
+
+func openMSF(r io.ReaderAt) (*msf, error) {
+	hdr := make([]byte, len(msfMagic)+6*4)
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		return nil, fmt.Errorf("could not read MSF header: %v", err)
+	}
+	if !bytes.Equal(hdr[:len(msfMagic)], msfMagic) {
+		return nil, errors.New("not a PDB file")
+	}
+	b := &buf{data: hdr[len(msfMagic):]}
+	m := &msf{r: r, blockSize: b.u32()}
+	b.u32() // free block map block
+	numBlocks := b.u32()
+	numDirectoryBytes := b.u32()
+	b.u32() // unknown
+	blockMapAddr := b.u32()
+	switch m.blockSize {
+	case 512, 1024, 2048, 4096, 8192, 16384, 32768:
+	default:
+		return nil, fmt.Errorf("invalid block size %d", m.blockSize)
+	}
+
+	// The block map lists the blocks of the stream directory.
+	numDirectoryBlocks := (numDirectoryBytes + m.blockSize - 1) / m.blockSize
+	blockMap, err := m.read([]uint32{blockMapAddr}, numDirectoryBlocks*4)
+	if err != nil {
+		return nil, err
+	}
+	dirBlocks := make([]uint32, numDirectoryBlocks)
+	for i := range dirBlocks {
+		dirBlocks[i] = binary.LittleEndian.Uint32(blockMap[i*4:])
+	}
+	dir, err := m.read(dirBlocks, numDirectoryBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	b = &buf{data: dir}
+	numStreams := b.u32()
+	if uint64(numStreams)*4 > uint64(len(dir)) {
+		return nil, errors.New("corrupted stream directory")
+	}
+	m.sizes = make([]uint32, numStreams)
+	for i := range m.sizes {
+		m.sizes[i] = b.u32()
+	}
+	m.streams = make([][]uint32, numStreams)
+	for i, size := range m.sizes {
+		if size == 0xffffffff {
+			m.sizes[i] = 0
+			continue
+		}
+		n := (size + m.blockSize - 1) / m.blockSize
+		if uint64(n)*4 > uint64(len(dir)) {
+			return nil, errors.New("corrupted stream directory")
+		}
+		m.streams[i] = make([]uint32, n)
+		for j := range m.streams[i] {
+			m.streams[i][j] = b.u32()
+			if m.streams[i][j] >= numBlocks {
+				return nil, fmt.Errorf("stream %d: invalid block %d", i, m.streams[i][j])
+			}
+		}
+	}
+	if b.err != nil {
+		return nil, fmt.Errorf("stream directory: %v", b.err)
+	}
+	return m, nil
+}
+
+// read reads size bytes from the specified blocks.
+func (m *msf) read(blocks []uint32, size uint32) ([]byte, error) {
+	if uint64(len(blocks))*uint64(m.blockSize) < uint64(size) {
+		return nil, errors.New("corrupted stream directory")
+	}
+	out := make([]byte, size)
+	for i, blk := range blocks {
+		start := uint32(i) * m.blockSize
+		if start >= size {
+			break
+		}
+		end := start + m.blockSize
+		if end > size {
+			end = size
+		}
+		if _, err := m.r.ReadAt(out[start:end], int64(blk)*int64(m.blockSize)); err != nil {
+			return nil, fmt.Errorf("could not read block %d: %v", blk, err)
+		}
+	}
+	return out, nil
+}
+
+// stream returns the contents of the stream with index idx.
+func (m *msf) stream(idx uint32) ([]byte, error) {
+	if idx >= uint32(len(m.streams)) {
+		return nil, fmt.Errorf("stream %d does not exist", idx)
+	}
+	return m.read(m.streams[idx], m.sizes[idx])
+}
+
+// parseInfo parses the PDB info stream and returns its map of named
+// streams.
+func (f *File) parseInfo(data []byte) (map[string]uint32, error) {
+	b := &buf{data: data}
+	b.u32() // version
+	b.u32() // signature
+	b.u32() // age, the one in the DBI stream is the one matching the executable
+	copy(f.GUID[:], b.bytes(16))
+
+	strs := b.bytes(int(b.u32()))
+	size := b.u32()
+	b.u32()       // capacity
+	b.bitVector() // present buckets
+	b.bitVector() // deleted buckets
+	if b.err != nil {
+		return nil, b.err
+	}
+	r := make(map[string]uint32)
+	for i := uint32(0); i < size; i++ {
+		key, value := b.u32(), b.u32()
+		if b.err != nil {
+			return nil, b.err
+		}
+		if int(key) < len(strs) {
+			if end := bytes.IndexByte(strs[key:], 0); end >= 0 {
+				r[string(strs[key:int(key)+end])] = value
+			}
+		}
+	}
+	return r, nil
+}
+
+// parseStringTable returns the string buffer of the /names stream.
+func parseStringTable(data []byte) ([]byte, error) {
+	b := &buf{data: data}
+	if sig := b.u32(); sig != 0xeffeeffe {
+		return nil, fmt.Errorf("wrong signature %#x", sig)
+	}
+	b.u32() // hash version
+	strs := b.bytes(int(b.u32()))
+	return strs, b.err
+}
+
+// name returns the string at offset off of the /names string table.
+func (f *File) name(off uint32) string {
+	if int(off) >= len(f.names) {
+		return ""
+	}
+	end := bytes.IndexByte(f.names[off:], 0)
+	if end < 0 {
+		return ""
+	}
+	return string(f.names[off : int(off)+end])
+}
+
+// parseDBI parses the DBI stream, which contains the list of modules.
+func (f *File) parseDBI(data []byte) error {
+	const headerSize = 64
+	b := &buf{data: data}
+	b.bytes(8) // version signature and header
+	f.Age = b.u32()
+	b.bytes(12)
+	modInfoSize := int(int32(b.u32()))
+	sectionContributionSize := int(int32(b.u32()))
+	sectionMapSize := int(int32(b.u32()))
+	sourceInfoSize := int(int32(b.u32()))
+	typeServerMapSize := int(int32(b.u32()))
+	b.u32() // MFC type server index
+	optionalDbgHeaderSize := int(int32(b.u32()))
+	ecSubstreamSize := int(int32(b.u32()))
+	b.u16() // flags
+	f.Machine = b.u16()
+	b.u32() // padding
+	if b.err != nil {
+		return b.err
+	}
+
+	modInfo := b.bytes(modInfoSize)
+	b.bytes(sectionContributionSize + sectionMapSize + sourceInfoSize + typeServerMapSize + ecSubstreamSize)
+	dbgHeader := b.bytes(optionalDbgHeaderSize)
+	if b.err != nil {
+		return b.err
+	}
+
+	if len(dbgHeader) >= (dbgHeaderSectionHdr+1)*2 {
+		if idx := binary.LittleEndian.Uint16(dbgHeader[dbgHeaderSectionHdr*2:]); idx != noStream {
+			if err := f.parseSectionHeaders(uint32(idx)); err != nil {
+				return err
+			}
+		}
+	}
+
+	mb := &buf{data: modInfo}
+	for mb.off < len(modInfo) {
+		mb.bytes(34) // unused field, section contribution and flags
+		symStream := mb.u16()
+		symByteSize := mb.u32()
+		c11ByteSize := mb.u32()
+		c13ByteSize := mb.u32()
+		mb.bytes(16) // source file count, padding, unused field and name indexes
+		mod := &Module{Name: string(mb.cstring()), ObjFile: string(mb.cstring())}
+		mb.align(4)
+		if mb.err != nil {
+			return fmt.Errorf("module information: %v", mb.err)
+		}
+		f.Modules = append(f.Modules, mod)
+		if symStream == noStream {
+			continue
+		}
+		stream, err := f.msf.stream(uint32(symStream))
+		if err != nil {
+			return fmt.Errorf("module %s: %v", mod.Name, err)
+		}
+		if err := f.parseModule(mod, stream, symByteSize, c11ByteSize, c13ByteSize); err != nil {
+			return fmt.Errorf("module %s: %v", mod.Name, err)
+		}
+	}
+	return nil
+}
+
+// parseSectionHeaders reads the virtual addresses of the sections of the
+// executable from the copy of its section headers in stream idx.
+func (f *File) parseSectionHeaders(idx uint32) error {
+	const sectionHeaderSize = 40 // sizeof(IMAGE_SECTION_HEADER)
+	data, err := f.msf.stream(idx)
+	if err != nil {
+		return err
+	}
+	for off := 0; off+sectionHeaderSize <= len(data); off += sectionHeaderSize {
+		f.Sections = append(f.Sections, binary.LittleEndian.Uint32(data[off+12:]))
+	}
+	return nil
+}
+
+func sortFunctions(fns []*Function) {
+	sort.Slice(fns, func(i, j int) bool {
+		if fns[i].Addr.Section != fns[j].Addr.Section {
+			return fns[i].Addr.Section < fns[j].Addr.Section
+		}
+		return fns[i].Addr.Offset < fns[j].Addr.Offset
+	})
+}
+
+// buf is a little endian reader for the contents of a stream.
+type buf struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (b *buf) bytes(n int) []byte {
+	if b.err != nil || n < 0 || b.off+n > len(b.data) {
+		if b.err == nil {
+			b.err = errors.New("unexpected end of stream")
+		}
+		return nil
+	}
+	r := b.data[b.off : b.off+n]
+	b.off += n
+	return r
+}
+
+func (b *buf) u8() uint8 {
+	if p := b.bytes(1); p != nil {
+		return p[0]
+	}
+	return 0
+}
+
+func (b *buf) u16() uint16 {
+	if p := b.bytes(2); p != nil {
+		return binary.LittleEndian.Uint16(p)
+	}
+	return 0
+}
+
+func (b *buf) u32() uint32 {
+	if p := b.bytes(4); p != nil {
+		return binary.LittleEndian.Uint32(p)
+	}
+	return 0
+}
+
+func (b *buf) u64() uint64 {
+	if p := b.bytes(8); p != nil {
+		return binary.LittleEndian.Uint64(p)
+	}
+	return 0
+}
+
+func (b *buf) cstring() []byte {
+	if b.err != nil {
+		return nil
+	}
+	i := bytes.IndexByte(b.data[b.off:], 0)
+	if i < 0 {
+		b.err = errors.New("unterminated string")
+		return nil
+	}
+	r := b.data[b.off : b.off+i]
+	b.off += i + 1
+	return r
+}
+
+func (b *buf) align(n int) {
+	if rem := b.off % n; rem != 0 && b.off+n-rem <= len(b.data) {
+		b.off += n - rem
+	}
+}
+
+// bitVector skips a serialized bit vector and returns its words.
+func (b *buf) bitVector() []uint32 {
+	n := b.u32()
+	if uint64(n)*4 > uint64(len(b.data)) {
+		b.err = errors.New("bit vector too long")
+		return nil
+	}
+	r := make([]uint32, n)
+	for i := range r {
+		r[i] = b.u32()
+	}
+	return r
+}
+
+// numeric reads a numeric leaf, used in type records to encode sizes and
+// offsets.
+func (b *buf) numeric() int64 {
+	v := b.u16()
+	if v < 0x8000 {
+		return int64(v)
+	}
+	switch v {
+	case 0x8000: // LF_CHAR
+		return int64(int8(b.u8()))
+	case 0x8001: // LF_SHORT
+		return int64(int16(b.u16()))
+	case 0x8002: // LF_USHORT
+		return int64(b.u16())
+	case 0x8003: // LF_LONG
+		return int64(int32(b.u32()))
+	case 0x8004: // LF_ULONG
+		return int64(b.u32())
+	case 0x8009, 0x800a: // LF_QUADWORD, LF_UQUADWORD
+		return int64(b.u64())
+	}
+	b.err = fmt.Errorf("unsupported numeric leaf %#x", v)
+	return 0
+}
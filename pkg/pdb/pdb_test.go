@@ -0,0 +1,178 @@
+package pdb
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-delve/delve/pkg/dwarf/line"
+	"github.com/go-delve/delve/pkg/dwarf/op"
+)
+
+// openFixture converts _fixtures/pdbsyms.yaml into a PDB file and opens it.
+func openFixture(t *testing.T) *File {
+	if _, err := exec.LookPath("llvm-pdbutil"); err != nil {
+		t.Skip("llvm-pdbutil not found")
+	}
+	src, _ := filepath.Abs("../../_fixtures/pdbsyms.yaml")
+	pdbPath := filepath.Join(t.TempDir(), "pdbsyms.pdb")
+	if out, err := exec.Command("llvm-pdbutil", "yaml2pdb", src, "--pdb="+pdbPath).CombinedOutput(); err != nil {
+		t.Skipf("llvm-pdbutil failed: %v\n%s", err, out)
+	}
+	f, err := Open(pdbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestParse(t *testing.T) {
+	f := openFixture(t)
+	if f.Machine != machineAMD64 || f.Age != 1 || f.GUID != [16]byte{4, 3, 2, 1, 6, 5, 8, 7, 9, 10, 11, 12, 13, 14, 15, 16} {
+		t.Errorf("wrong header: machine %#x age %d GUID %x", f.Machine, f.Age, f.GUID)
+	}
+	if len(f.Modules) != 1 {
+		t.Fatalf("wrong number of modules %d", len(f.Modules))
+	}
+	mod := f.Modules[0]
+	if mod.Name != `C:\src\hello.obj` || mod.Compiler != "Microsoft (R) Optimizing Compiler" {
+		t.Errorf("wrong module %q compiled by %q", mod.Name, mod.Compiler)
+	}
+	if len(mod.Functions) != 2 {
+		t.Fatalf("wrong number of functions %d", len(mod.Functions))
+	}
+
+	add, helper := mod.Functions[0], mod.Functions[1]
+	if add.Name != "add" || add.Addr != (Address{1, 16}) || add.Size != 32 {
+		t.Errorf("wrong function %#v", add)
+	}
+	wantLines := []Line{{0, `C:\src\hello.c`, 10, true}, {8, `C:\src\hello.c`, 11, true}, {20, `C:\src\hello.c`, 12, true}}
+	if len(add.Lines) != len(wantLines) {
+		t.Errorf("wrong lines %v", add.Lines)
+	}
+	for i := range add.Lines {
+		if i < len(wantLines) && add.Lines[i] != wantLines[i] {
+			t.Errorf("wrong line %d: %v", i, add.Lines[i])
+		}
+	}
+	wantVars := []Variable{
+		{Name: "a", Type: 0x74, Param: true, Location: VarRegRel, Register: cvAMD64RBP, Offset: 16},
+		{Name: "p", Type: 0x1004, Param: true, Location: VarRegRel, Register: cvAMD64RBP, Offset: 24},
+		{Name: "buf", Type: 0x1005, Location: VarRegRel, Register: cvAMD64RBP, Offset: -24},
+	}
+	checkVars(t, add, wantVars)
+
+	if helper.Name != "helper" || len(helper.Lines) != 2 {
+		t.Errorf("wrong function %#v", helper)
+	}
+	checkVars(t, helper, []Variable{
+		{Name: "x", Type: 0x74, Param: true, Location: VarRegRel, Register: cvAMD64R13, Offset: 48},
+		{Name: "y", Type: 0x74, Location: VarRegRel, Register: cvAMD64R13, Offset: -8},
+	})
+
+	p := f.Type(0x1004)
+	if p == nil || p.Kind != KindPointer || p.Size != 8 || p.Elem == nil {
+		t.Fatalf("wrong pointer type %#v", p)
+	}
+	if pt := p.Elem; pt.Kind != KindStruct || pt.Name != "point" || pt.Size != 8 || pt.Incomplete || len(pt.Fields) != 2 || pt.Fields[1].Name != "y" || pt.Fields[1].Offset != 4 || pt.Fields[1].Type.Name != "int" {
+		t.Errorf("wrong struct type %#v", pt)
+	}
+	if arr := f.Type(0x1005); arr == nil || arr.Kind != KindArray || arr.Size != 16 || arr.Elem.Kind != KindChar {
+		t.Errorf("wrong array type %#v", arr)
+	}
+}
+
+func checkVars(t *testing.T, fn *Function, want []Variable) {
+	t.Helper()
+	if len(fn.Variables) != len(want) {
+		t.Errorf("%s: wrong variables %#v", fn.Name, fn.Variables)
+		return
+	}
+	for i := range want {
+		if fn.Variables[i] != want[i] {
+			t.Errorf("%s: wrong variable %d %#v", fn.Name, i, fn.Variables[i])
+		}
+	}
+}
+
+func TestAppendDWARF(t *testing.T) {
+	f := openFixture(t)
+	const imageBase = 0x140000000
+	sec, n := f.AppendDWARF(&Sections{}, imageBase, []uint32{0x1000})
+	if n != 2 {
+		t.Fatalf("wrong number of functions converted %d", n)
+	}
+	d, err := dwarf.New(sec.Abbrev, nil, nil, sec.Info, nil, nil, sec.Ranges, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars := make(map[string]string)
+	var fns []string
+	rdr := d.Reader()
+	var fn string
+	for {
+		e, err := rdr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e == nil {
+			break
+		}
+		switch e.Tag {
+		case dwarf.TagCompileUnit:
+			ranges, err := d.Ranges(e)
+			if err != nil || len(ranges) != 2 || ranges[0] != [2]uint64{imageBase + 0x1010, imageBase + 0x1030} {
+				t.Errorf("wrong compile unit ranges %#x %v", ranges, err)
+			}
+		case dwarf.TagSubprogram:
+			fn = e.Val(dwarf.AttrName).(string)
+			fns = append(fns, fn)
+		case dwarf.TagFormalParameter, dwarf.TagVariable:
+			typ, err := d.Type(e.Val(dwarf.AttrType).(dwarf.Offset))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var loc bytes.Buffer
+			op.PrettyPrint(&loc, e.Val(dwarf.AttrLocation).([]byte))
+			vars[fn+"."+e.Val(dwarf.AttrName).(string)] = typ.String() + " " + strings.TrimSpace(loc.String())
+		}
+	}
+	if len(fns) != 2 || fns[0] != "add" || fns[1] != "helper" {
+		t.Errorf("wrong functions %v", fns)
+	}
+	for name, want := range map[string]string{
+		"add.a":    "int DW_OP_breg6 0x10",
+		"add.p":    "*struct point DW_OP_breg6 0x18",
+		"add.buf":  "[16]char DW_OP_breg6 -0x18",
+		"helper.x": "int DW_OP_breg13 0x30",
+		"helper.y": "int DW_OP_breg13 -0x8",
+	} {
+		if vars[name] != want {
+			t.Errorf("%s: got %q expected %q", name, vars[name], want)
+		}
+	}
+
+	lines := line.ParseAll(sec.Line, nil, nil, 0, true, 8)
+	if len(lines) != 1 {
+		t.Fatalf("wrong number of line tables %d", len(lines))
+	}
+	for _, tc := range []struct {
+		pc   uint64
+		line int
+	}{
+		{imageBase + 0x1010, 10},
+		{imageBase + 0x101c, 11},
+		{imageBase + 0x1024, 12},
+		{imageBase + 0x1038, 21},
+		{imageBase + 0x102f, 12}, // last row of add
+	} {
+		file, ln := lines[0].PCToLine(tc.pc, tc.pc)
+		if file != "C:/src/hello.c" || ln != tc.line {
+			t.Errorf("%#x: got %s:%d expected line %d", tc.pc, file, ln, tc.line)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package pdb
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"io"
+)
+
+// CodeViewInfo is the CodeView (RSDS) record of the debug directory of an
+// executable, which identifies its PDB file.
+type CodeViewInfo struct {
+	GUID [16]byte
+	Age  uint32
+	Path string // path of the PDB file when the executable was linked
+}
+
+const (
+	imageDirectoryEntryDebug = 6
+	imageDebugTypeCodeView   = 2
+)
+
+// CodeView returns the CodeView record of exe, read from r, or nil if exe
+// does not have one.
+func CodeView(exe *pe.File, r io.ReaderAt) *CodeViewInfo {
+	var dir pe.DataDirectory
+	switch opth := exe.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if len(opth.DataDirectory) > imageDirectoryEntryDebug {
+			dir = opth.DataDirectory[imageDirectoryEntryDebug]
+		}
+	case *pe.OptionalHeader64:
+		if len(opth.DataDirectory) > imageDirectoryEntryDebug {
+			dir = opth.DataDirectory[imageDirectoryEntryDebug]
+		}
+	}
+	if dir.Size == 0 {
+		return nil
+	}
+	var sec *pe.Section
+	for _, s := range exe.Sections {
+		if s.VirtualAddress <= dir.VirtualAddress && dir.VirtualAddress+dir.Size <= s.VirtualAddress+s.Size {
+			sec = s
+			break
+		}
+	}
+	if sec == nil {
+		return nil
+	}
+	dirData := make([]byte, dir.Size)
+	if _, err := r.ReadAt(dirData, int64(sec.Offset+dir.VirtualAddress-sec.VirtualAddress)); err != nil {
+		return nil
+	}
+
+	// IMAGE_DEBUG_DIRECTORY entries
+	const entrySize = 28
+	for off := 0; off+entrySize <= len(dirData); off += entrySize {
+		entry := dirData[off : off+entrySize]
+		if binary.LittleEndian.Uint32(entry[12:]) != imageDebugTypeCodeView {
+			continue
+		}
+		size := binary.LittleEndian.Uint32(entry[16:])
+		if size < 24 || size > 4096 {
+			continue
+		}
+		data := make([]byte, size)
+		if _, err := r.ReadAt(data, int64(binary.LittleEndian.Uint32(entry[24:]))); err != nil {
+			continue
+		}
+		if string(data[:4]) != "RSDS" {
+			continue
+		}
+		cv := &CodeViewInfo{Age: binary.LittleEndian.Uint32(data[20:])}
+		copy(cv.GUID[:], data[4:20])
+		cv.Path = string(data[24:])
+		if i := bytes.IndexByte(data[24:], 0); i >= 0 {
+			cv.Path = string(data[24 : 24+i])
+		}
+		return cv
+	}
+	return nil
+}
+
+// Matches returns true if f is the PDB file identified by cv.
+func (f *File) Matches(cv *CodeViewInfo) bool {
+	return f.GUID == cv.GUID && f.Age == cv.Age
+}
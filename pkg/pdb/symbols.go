@@ -0,0 +1,346 @@
+package pdb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Symbol record kinds.
+const (
+	symEnd              = 0x0006 // S_END
+	symThunk32          = 0x1102 // S_THUNK32
+	symBlock32          = 0x1103 // S_BLOCK32
+	symWith32           = 0x1104 // S_WITH32
+	symRegister         = 0x1106 // S_REGISTER
+	symBPRel32          = 0x110b // S_BPREL32
+	symLProc32          = 0x110f // S_LPROC32
+	symGProc32          = 0x1110 // S_GPROC32
+	symRegRel32         = 0x1111 // S_REGREL32
+	symFrameProc        = 0x1012 // S_FRAMEPROC
+	symSepCode          = 0x1132 // S_SEPCODE
+	symCompile3         = 0x113c // S_COMPILE3
+	symLocal            = 0x113e // S_LOCAL
+	symDefRangeRegister = 0x1141 // S_DEFRANGE_REGISTER
+	symDefRangeFPRelFS  = 0x1144 // S_DEFRANGE_FRAMEPOINTER_REL_FULL_SCOPE
+	symDefRangeRegRel   = 0x1145 // S_DEFRANGE_REGISTER_REL
+	symLProc32ID        = 0x1146 // S_LPROC32_ID
+	symGProc32ID        = 0x1147 // S_GPROC32_ID
+	symInlineSite       = 0x114d // S_INLINESITE
+	symInlineSiteEnd    = 0x114e // S_INLINESITE_END
+	symProcIDEnd        = 0x114f // S_PROC_ID_END
+)
+
+// C13 debug subsection kinds.
+const (
+	debugSLines        = 0xf2 // DEBUG_S_LINES
+	debugSFileChecksms = 0xf4 // DEBUG_S_FILECHKSMS
+	debugSIgnore       = 0x80000000
+)
+
+// cvSignatureC13 is the signature of module streams containing C13 line
+// information.
+const cvSignatureC13 = 4
+
+// Frame pointer registers used for S_BPREL32 symbols and for the frame
+// pointer encoded in S_FRAMEPROC symbols.
+var framePointerRegs = map[uint16][4]uint16{
+	machineI386:  {0, cvRegESP, cvRegEBP, cvRegEBX},
+	machineAMD64: {0, cvAMD64RSP, cvAMD64RBP, cvAMD64R13},
+	machineARM64: {0, cvARM64SP, cvARM64FP, cvARM64X19},
+}
+
+// parseModule reads the functions of mod, with their local variables and
+// line tables, from its module stream.
+func (f *File) parseModule(mod *Module, data []byte, symByteSize, c11ByteSize, c13ByteSize uint32) error {
+	if uint64(symByteSize)+uint64(c11ByteSize)+uint64(c13ByteSize) > uint64(len(data)) {
+		return errors.New("module stream too short")
+	}
+	if symByteSize < 4 {
+		return nil
+	}
+	b := &buf{data: data[:symByteSize]}
+	if sig := b.u32(); sig != cvSignatureC13 {
+		return fmt.Errorf("unsupported symbols signature %d", sig)
+	}
+	if err := f.parseSymbols(mod, b); err != nil {
+		return err
+	}
+	sortFunctions(mod.Functions)
+	c13 := data[symByteSize+c11ByteSize : symByteSize+c11ByteSize+c13ByteSize]
+	return f.parseLines(mod, c13)
+}
+
+// parseSymbols reads the symbol records of a module.
+func (f *File) parseSymbols(mod *Module, b *buf) error {
+	var (
+		fn          *Function
+		depth       int // nesting depth of scopes inside fn
+		inlineDepth int // depth of the outermost inline site, 0 if none
+		frameFlags  uint32
+		local       *Variable // last S_LOCAL symbol, waiting for its location
+	)
+	fpRegs := framePointerRegs[f.Machine]
+
+	addVar := func(v Variable) {
+		if fn != nil && inlineDepth == 0 {
+			fn.Variables = append(fn.Variables, v)
+		}
+	}
+
+	for b.off < len(b.data) {
+		size := int(b.u16())
+		if size < 2 {
+			return errors.New("invalid symbol record")
+		}
+		rec := &buf{data: b.bytes(size)}
+		if b.err != nil {
+			return b.err
+		}
+		kind := rec.u16()
+
+		if kind != symDefRangeRegister && kind != symDefRangeFPRelFS && kind != symDefRangeRegRel {
+			local = nil
+		}
+
+		switch kind {
+		case symCompile3:
+			flags := rec.u32()
+			mod.Language = uint8(flags)
+			rec.bytes(2 + 8*2) // machine, frontend and backend versions
+			mod.Compiler = string(rec.cstring())
+
+		case symGProc32, symLProc32, symGProc32ID, symLProc32ID:
+			if fn != nil {
+				// nested function, skip it
+				depth++
+				break
+			}
+			rec.bytes(12) // parent, end and next
+			fn = &Function{Size: rec.u32()}
+			rec.bytes(8) // debug start and end
+			fn.Type = TypeIndex(rec.u32())
+			if kind == symGProc32ID || kind == symLProc32ID {
+				// the type index refers to the IPI stream, which is not read
+				fn.Type = 0
+			}
+			fn.Addr.Offset = rec.u32()
+			fn.Addr.Section = rec.u16()
+			rec.u8() // flags
+			fn.Name = string(rec.cstring())
+			if rec.err != nil {
+				return fmt.Errorf("function record: %v", rec.err)
+			}
+			depth, inlineDepth, frameFlags = 1, 0, 0
+			mod.Functions = append(mod.Functions, fn)
+
+		case symBlock32, symThunk32, symWith32, symSepCode:
+			if fn != nil {
+				depth++
+			}
+
+		case symInlineSite:
+			if fn != nil {
+				depth++
+				if inlineDepth == 0 {
+					inlineDepth = depth
+				}
+			}
+
+		case symEnd, symProcIDEnd, symInlineSiteEnd:
+			if fn == nil {
+				break
+			}
+			if depth == inlineDepth {
+				inlineDepth = 0
+			}
+			depth--
+			if depth == 0 {
+				f.markParams(fn)
+				fn = nil
+			}
+
+		case symFrameProc:
+			rec.bytes(4*5 + 2)
+			frameFlags = rec.u32()
+
+		case symRegRel32:
+			v := Variable{Location: VarRegRel, Offset: int32(rec.u32()), Type: TypeIndex(rec.u32())}
+			v.Register = rec.u16()
+			v.Name = string(rec.cstring())
+			if rec.err == nil {
+				addVar(v)
+			}
+
+		case symBPRel32:
+			v := Variable{Location: VarRegRel, Offset: int32(rec.u32()), Type: TypeIndex(rec.u32()), Register: fpRegs[2]}
+			v.Name = string(rec.cstring())
+			if rec.err == nil && v.Register != 0 {
+				addVar(v)
+			}
+
+		case symRegister:
+			v := Variable{Location: VarRegister, Type: TypeIndex(rec.u32())}
+			v.Register = rec.u16()
+			v.Name = string(rec.cstring())
+			if rec.err == nil {
+				addVar(v)
+			}
+
+		case symLocal:
+			v := Variable{Type: TypeIndex(rec.u32())}
+			flags := rec.u16()
+			v.Param = flags&0x1 != 0 // fIsParam
+			v.Name = string(rec.cstring())
+			if rec.err == nil {
+				local = &v
+			}
+
+		case symDefRangeRegister, symDefRangeFPRelFS, symDefRangeRegRel:
+			// Only the first location of a S_LOCAL symbol is used, the address
+			// ranges where it is valid are ignored.
+			if local == nil {
+				break
+			}
+			v := *local
+			local = nil
+			switch kind {
+			case symDefRangeRegister:
+				v.Location = VarRegister
+				v.Register = rec.u16()
+			case symDefRangeFPRelFS:
+				v.Location = VarRegRel
+				v.Offset = int32(rec.u32())
+				// S_FRAMEPROC encodes the registers used to address locals and
+				// parameters.
+				enc := (frameFlags >> 14) & 0x3
+				if v.Param {
+					enc = (frameFlags >> 16) & 0x3
+				}
+				v.Register = fpRegs[enc]
+			case symDefRangeRegRel:
+				v.Location = VarRegRel
+				v.Register = rec.u16()
+				rec.u16() // flags
+				v.Offset = int32(rec.u32())
+			}
+			if rec.err == nil && v.Register != 0 {
+				addVar(v)
+			}
+		}
+	}
+	return nil
+}
+
+// markParams marks the first variables of fn, described by S_REGREL32,
+// S_BPREL32 or S_REGISTER symbols, as parameters using the number of
+// parameters of the type of fn. Variables described by S_LOCAL symbols
+// already have this information.
+func (f *File) markParams(fn *Function) {
+	n := f.types.paramCount(fn.Type)
+	for i := range fn.Variables {
+		if fn.Variables[i].Param {
+			return
+		}
+	}
+	for i := 0; i < n && i < len(fn.Variables); i++ {
+		fn.Variables[i].Param = true
+	}
+}
+
+// parseLines reads the C13 line information of a module and adds it to
+// its functions.
+func (f *File) parseLines(mod *Module, data []byte) error {
+	type subsection struct {
+		kind uint32
+		data []byte
+	}
+	var subsections []subsection
+	b := &buf{data: data}
+	for b.off < len(data) {
+		kind := b.u32()
+		body := b.bytes(int(b.u32()))
+		b.align(4)
+		if b.err != nil {
+			return fmt.Errorf("C13 line information: %v", b.err)
+		}
+		if kind&debugSIgnore == 0 {
+			subsections = append(subsections, subsection{kind, body})
+		}
+	}
+
+	// The file checksums subsection maps the offsets used by the lines
+	// subsections to file names.
+	files := make(map[uint32]string)
+	for _, s := range subsections {
+		if s.kind != debugSFileChecksms {
+			continue
+		}
+		cb := &buf{data: s.data}
+		for cb.off < len(s.data) {
+			off := uint32(cb.off)
+			nameOff := cb.u32()
+			csize := cb.u8()
+			cb.u8() // checksum kind
+			cb.bytes(int(csize))
+			cb.align(4)
+			if cb.err != nil {
+				return fmt.Errorf("file checksums: %v", cb.err)
+			}
+			files[off] = f.name(nameOff)
+		}
+	}
+
+	for _, s := range subsections {
+		if s.kind != debugSLines {
+			continue
+		}
+		lb := &buf{data: s.data}
+		start := Address{Offset: lb.u32()}
+		start.Section = lb.u16()
+		flags := lb.u16()
+		lb.u32() // code size
+		for lb.off < len(s.data) {
+			file := files[lb.u32()]
+			n := int(lb.u32())
+			lb.u32() // block size
+			for i := 0; i < n; i++ {
+				off := lb.u32()
+				lf := lb.u32()
+				ln := int(lf & 0xffffff)
+				if lb.err != nil || ln == 0xfeefee || ln == 0xf00f00 {
+					// hidden lines
+					continue
+				}
+				addr := Address{Section: start.Section, Offset: start.Offset + off}
+				if fn := mod.findFunction(addr); fn != nil {
+					fn.Lines = append(fn.Lines, Line{Offset: addr.Offset - fn.Addr.Offset, File: file, Line: ln, IsStmt: lf&(1<<31) != 0})
+				}
+			}
+			if flags&0x1 != 0 { // CV_LINES_HAVE_COLUMNS
+				lb.bytes(n * 4)
+			}
+			if lb.err != nil {
+				return fmt.Errorf("line information: %v", lb.err)
+			}
+		}
+	}
+	for _, fn := range mod.Functions {
+		sort.SliceStable(fn.Lines, func(i, j int) bool { return fn.Lines[i].Offset < fn.Lines[j].Offset })
+	}
+	return nil
+}
+
+// findFunction returns the function of mod containing addr.
+func (mod *Module) findFunction(addr Address) *Function {
+	i := sort.Search(len(mod.Functions), func(i int) bool {
+		fn := mod.Functions[i]
+		return fn.Addr.Section > addr.Section || (fn.Addr.Section == addr.Section && fn.Addr.Offset+fn.Size > addr.Offset)
+	})
+	if i < len(mod.Functions) {
+		if fn := mod.Functions[i]; fn.Addr.Section == addr.Section && fn.Addr.Offset <= addr.Offset {
+			return fn
+		}
+	}
+	return nil
+}
@@ -0,0 +1,413 @@
+package pdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TypeIndex is the index of a type in the TPI stream. Indexes below 0x1000
+// refer to primitive types.
+type TypeIndex uint32
+
+// TypeKind is the kind of a Type.
+type TypeKind uint8
+
+const (
+	KindVoid TypeKind = iota
+	KindBool
+	KindChar  // signed character
+	KindUchar // unsigned character
+	KindInt
+	KindUint
+	KindFloat
+	KindPointer
+	KindArray
+	KindStruct
+	KindUnion
+)
+
+// Type is a type described by a PDB file.
+type Type struct {
+	Kind   TypeKind
+	Name   string
+	Size   int64
+	Elem   *Type   // referenced type of pointers, nil for void pointers, element type of arrays
+	Fields []Field // fields of structs and unions
+
+	// Incomplete is true for structs and unions that are only declared.
+	Incomplete bool
+}
+
+// Field is a field of a struct or union.
+type Field struct {
+	Name   string
+	Type   *Type
+	Offset int64
+}
+
+// Type record kinds.
+const (
+	lfModifier  = 0x1001
+	lfPointer   = 0x1002
+	lfProcedure = 0x1008
+	lfMFunction = 0x1009
+	lfIndex     = 0x1404
+	lfBClass    = 0x1400
+	lfVFuncTab  = 0x1409
+	lfFieldList = 0x1203
+	lfBitfield  = 0x1205
+	lfEnumerate = 0x1502
+	lfArray     = 0x1503
+	lfClass     = 0x1504
+	lfStructure = 0x1505
+	lfUnion     = 0x1506
+	lfEnum      = 0x1507
+	lfMember    = 0x150d
+	lfSTMember  = 0x150e
+	lfMethod    = 0x150f
+	lfNestType  = 0x1510
+	lfOneMethod = 0x1511
+)
+
+// Properties of struct, union and enum records.
+const (
+	propForwardRef    = 0x80
+	propHasUniqueName = 0x200
+)
+
+// firstTypeIndex is the index of the first non-primitive type.
+const firstTypeIndex = 0x1000
+
+// typeTable is the list of type records of the TPI stream.
+type typeTable struct {
+	begin   TypeIndex
+	records [][]byte // contents of each record, starting with its kind
+
+	complete map[string]TypeIndex // complete definitions of structs and unions, by name
+}
+
+func parseTypeTable(data []byte) (*typeTable, error) {
+	b := &buf{data: data}
+	b.u32() // version
+	headerSize := b.u32()
+	t := &typeTable{begin: TypeIndex(b.u32())}
+	b.u32() // type index end
+	recordBytes := b.u32()
+	if b.err != nil {
+		return nil, b.err
+	}
+	if uint64(headerSize)+uint64(recordBytes) > uint64(len(data)) {
+		return nil, errors.New("type records too long")
+	}
+	b = &buf{data: data[headerSize : headerSize+recordBytes]}
+	for b.off < len(b.data) {
+		rec := b.bytes(int(b.u16()))
+		if b.err != nil {
+			return nil, b.err
+		}
+		t.records = append(t.records, rec)
+	}
+	return t, nil
+}
+
+// record returns a reader for the record of type ti and its kind.
+func (t *typeTable) record(ti TypeIndex) (*buf, uint16) {
+	if ti < t.begin || int(ti-t.begin) >= len(t.records) {
+		return nil, 0
+	}
+	b := &buf{data: t.records[ti-t.begin]}
+	return b, b.u16()
+}
+
+// paramCount returns the number of parameters of the function type ti,
+// including the this parameter of methods.
+func (t *typeTable) paramCount(ti TypeIndex) int {
+	b, kind := t.record(ti)
+	switch kind {
+	case lfProcedure:
+		b.u32() // return type
+		b.bytes(2)
+		return int(b.u16())
+	case lfMFunction:
+		b.bytes(8) // return and class types
+		this := b.u32()
+		b.bytes(2)
+		n := int(b.u16())
+		if this != 0 {
+			n++
+		}
+		return n
+	}
+	return 0
+}
+
+// completeType returns the complete definition of the struct or union
+// with the specified kind and name.
+func (t *typeTable) completeType(kind uint16, name string) (TypeIndex, bool) {
+	if t.complete == nil {
+		t.complete = make(map[string]TypeIndex)
+		for i := range t.records {
+			ti := t.begin + TypeIndex(i)
+			b, k := t.record(ti)
+			if k != lfClass && k != lfStructure && k != lfUnion {
+				continue
+			}
+			props, _, name := readAggregate(b, k)
+			if b.err != nil || props&propForwardRef != 0 {
+				continue
+			}
+			key := fmt.Sprintf("%d %s", aggregateKind(k), name)
+			if _, dup := t.complete[key]; !dup {
+				t.complete[key] = ti
+			}
+		}
+	}
+	ti, ok := t.complete[fmt.Sprintf("%d %s", aggregateKind(kind), name)]
+	return ti, ok
+}
+
+// aggregateKind returns the same value for classes and structs, which
+// are interchangeable.
+func aggregateKind(kind uint16) uint16 {
+	if kind == lfClass {
+		return lfStructure
+	}
+	return kind
+}
+
+// readAggregate reads the header of a struct or union record, returning
+// its properties, field list and name. The size of the type is the next
+// value in b.
+func readAggregate(b *buf, kind uint16) (props uint16, fieldList TypeIndex, name string) {
+	b.u16() // number of fields
+	props = b.u16()
+	fieldList = TypeIndex(b.u32())
+	if kind != lfUnion {
+		b.bytes(8) // derivation list and vtable shape
+	}
+	start := b.off
+	b.numeric()
+	name = string(b.cstring())
+	b.off = start
+	return props, fieldList, name
+}
+
+// Type returns the type with index ti or nil if it is not supported.
+func (f *File) Type(ti TypeIndex) *Type {
+	if t, ok := f.parsed[ti]; ok {
+		return t
+	}
+	if ti < firstTypeIndex {
+		t := primitiveType(ti)
+		f.parsed[ti] = t
+		return t
+	}
+	f.parsed[ti] = nil // breaks cycles through unsupported types
+	t := f.readType(ti)
+	f.parsed[ti] = t
+	return t
+}
+
+func (f *File) readType(ti TypeIndex) *Type {
+	b, kind := f.types.record(ti)
+	if b == nil {
+		return nil
+	}
+	switch kind {
+	case lfModifier:
+		return f.Type(TypeIndex(b.u32()))
+
+	case lfPointer:
+		elem := TypeIndex(b.u32())
+		attrs := b.u32()
+		if mode := (attrs >> 5) & 0x7; mode == 2 || mode == 3 {
+			// pointers to members
+			return nil
+		}
+		t := &Type{Kind: KindPointer, Size: int64((attrs >> 13) & 0x3f)}
+		f.parsed[ti] = t
+		t.Elem = f.Type(elem)
+		if t.Elem != nil && t.Elem.Kind == KindVoid {
+			t.Elem = nil
+		}
+		if t.Elem != nil {
+			t.Name = t.Elem.Name + " *"
+		} else {
+			t.Name = "void *"
+		}
+		return t
+
+	case lfArray:
+		elem := f.Type(TypeIndex(b.u32()))
+		b.u32() // index type
+		t := &Type{Kind: KindArray, Size: b.numeric(), Elem: elem}
+		if b.err != nil || elem == nil {
+			return nil
+		}
+		return t
+
+	case lfClass, lfStructure, lfUnion:
+		props, fieldList, name := readAggregate(b, kind)
+		t := &Type{Kind: KindStruct, Name: name, Size: b.numeric()}
+		if kind == lfUnion {
+			t.Kind = KindUnion
+		}
+		if b.err != nil {
+			return nil
+		}
+		if props&propForwardRef != 0 {
+			if cti, ok := f.types.completeType(kind, name); ok {
+				return f.Type(cti)
+			}
+			t.Incomplete = true
+			return t
+		}
+		f.parsed[ti] = t
+		t.Fields = f.readFields(fieldList)
+		return t
+
+	case lfEnum:
+		b.bytes(4) // number of enumerators and properties
+		underlying := f.Type(TypeIndex(b.u32()))
+		b.u32() // field list
+		name := string(b.cstring())
+		if underlying == nil || b.err != nil {
+			return nil
+		}
+		t := *underlying
+		t.Name = name
+		return &t
+	}
+	return nil
+}
+
+// readFields returns the data members of a field list.
+func (f *File) readFields(ti TypeIndex) []Field {
+	var fields []Field
+	for seen := 0; seen < 100; seen++ {
+		b, kind := f.types.record(ti)
+		if kind != lfFieldList {
+			return fields
+		}
+		ti = 0
+	members:
+		for b.off < len(b.data) {
+			kind := b.u16()
+			switch kind {
+			case lfMember:
+				b.u16() // attributes
+				typ := TypeIndex(b.u32())
+				off := b.numeric()
+				name := string(b.cstring())
+				if b.err != nil {
+					return fields
+				}
+				if ft := f.Type(typ); ft != nil {
+					fields = append(fields, Field{Name: name, Type: ft, Offset: off})
+				}
+			case lfBClass:
+				b.u16()
+				b.u32()
+				b.numeric()
+			case lfSTMember:
+				b.bytes(6)
+				b.cstring()
+			case lfNestType:
+				b.bytes(6)
+				b.cstring()
+			case lfOneMethod:
+				attrs := b.u16()
+				b.u32()
+				if prop := (attrs >> 2) & 0x7; prop == 4 || prop == 6 { // introducing virtual
+					b.u32()
+				}
+				b.cstring()
+			case lfMethod:
+				b.bytes(6)
+				b.cstring()
+			case lfVFuncTab:
+				b.bytes(6)
+			case lfEnumerate:
+				b.u16()
+				b.numeric()
+				b.cstring()
+			case lfIndex:
+				b.u16()
+				ti = TypeIndex(b.u32())
+			default:
+				// unknown member kind, the rest of the list can not be read
+				break members
+			}
+			// skip padding
+			for b.err == nil && b.off < len(b.data) && b.data[b.off] >= 0xf0 {
+				b.off++
+			}
+			if b.err != nil {
+				return fields
+			}
+		}
+		if ti == 0 {
+			break
+		}
+	}
+	return fields
+}
+
+// primitiveType returns the primitive type ti.
+func primitiveType(ti TypeIndex) *Type {
+	var t *Type
+	switch ti & 0xff {
+	case 0x03: // T_VOID
+		t = &Type{Kind: KindVoid, Name: "void"}
+	case 0x08: // T_HRESULT
+		t = &Type{Kind: KindInt, Name: "HRESULT", Size: 4}
+	case 0x10, 0x70, 0x68: // T_CHAR, T_RCHAR, T_INT1
+		t = &Type{Kind: KindChar, Name: "char", Size: 1}
+	case 0x20, 0x69, 0x7c: // T_UCHAR, T_UINT1, T_CHAR8
+		t = &Type{Kind: KindUchar, Name: "unsigned char", Size: 1}
+	case 0x71: // T_WCHAR
+		t = &Type{Kind: KindUint, Name: "wchar_t", Size: 2}
+	case 0x7a: // T_CHAR16
+		t = &Type{Kind: KindUint, Name: "char16_t", Size: 2}
+	case 0x7b: // T_CHAR32
+		t = &Type{Kind: KindUint, Name: "char32_t", Size: 4}
+	case 0x11, 0x72: // T_SHORT, T_INT2
+		t = &Type{Kind: KindInt, Name: "short", Size: 2}
+	case 0x21, 0x73: // T_USHORT, T_UINT2
+		t = &Type{Kind: KindUint, Name: "unsigned short", Size: 2}
+	case 0x12: // T_LONG
+		t = &Type{Kind: KindInt, Name: "long", Size: 4}
+	case 0x22: // T_ULONG
+		t = &Type{Kind: KindUint, Name: "unsigned long", Size: 4}
+	case 0x74: // T_INT4
+		t = &Type{Kind: KindInt, Name: "int", Size: 4}
+	case 0x75: // T_UINT4
+		t = &Type{Kind: KindUint, Name: "unsigned int", Size: 4}
+	case 0x13, 0x76: // T_QUAD, T_INT8
+		t = &Type{Kind: KindInt, Name: "long long", Size: 8}
+	case 0x23, 0x77: // T_UQUAD, T_UINT8
+		t = &Type{Kind: KindUint, Name: "unsigned long long", Size: 8}
+	case 0x30: // T_BOOL08
+		t = &Type{Kind: KindBool, Name: "bool", Size: 1}
+	case 0x40: // T_REAL32
+		t = &Type{Kind: KindFloat, Name: "float", Size: 4}
+	case 0x41: // T_REAL64
+		t = &Type{Kind: KindFloat, Name: "double", Size: 8}
+	default:
+		return nil
+	}
+	switch mode := (ti >> 8) & 0xf; mode {
+	case 0: // direct
+		return t
+	case 4, 6: // 32 and 64 bit pointers
+		p := &Type{Kind: KindPointer, Name: t.Name + " *", Size: 4, Elem: t}
+		if mode == 6 {
+			p.Size = 8
+		}
+		if t.Kind == KindVoid {
+			p.Elem = nil
+		}
+		return p
+	}
+	return nil
+}
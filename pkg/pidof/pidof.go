@@ -0,0 +1,47 @@
+// Package pidof finds the pid of running processes by name or by the port
+// they are listening on.
+package pidof
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotSupported is returned on operating systems where looking up
+// processes is not supported.
+var ErrNotSupported = errors.New("looking up processes by name or port is only supported on linux")
+
+// Process is a process matching a query.
+type Process struct {
+	Pid     int
+	Name    string
+	Cmdline string
+}
+
+// AmbiguousError is returned when more than one process matches a query.
+type AmbiguousError struct {
+	Query      string
+	Candidates []Process
+}
+
+func (err *AmbiguousError) Error() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "multiple processes match %s, specify a pid:", err.Query)
+	for _, p := range err.Candidates {
+		fmt.Fprintf(&buf, "\n\t%d\t%s\t%s", p.Pid, p.Name, p.Cmdline)
+	}
+	return buf.String()
+}
+
+// one returns the pid of the only process in candidates.
+func one(query string, candidates []Process) (int, error) {
+	switch len(candidates) {
+	case 0:
+		return 0, fmt.Errorf("no process matches %s", query)
+	case 1:
+		return candidates[0].Pid, nil
+	default:
+		return 0, &AmbiguousError{Query: query, Candidates: candidates}
+	}
+}
@@ -0,0 +1,150 @@
+package pidof
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// procfs is the mount point of the proc file system, changed by tests.
+var procfs = "/proc"
+
+// tcpListen is the state of listening sockets in /proc/net/tcp.
+const tcpListen = "0A"
+
+// Name returns the pid of the process called name: name is compared with
+// the name of the process (/proc/<pid>/comm), the base name of its first
+// argument and the base name of its executable. The process of the caller
+// is never returned.
+func Name(name string) (int, error) {
+	pids, err := pids()
+	if err != nil {
+		return 0, err
+	}
+	var candidates []Process
+	for _, pid := range pids {
+		if pid == os.Getpid() {
+			continue
+		}
+		p, ok := process(pid)
+		if !ok {
+			continue
+		}
+		dir := filepath.Join(procfs, strconv.Itoa(pid))
+		exe, _ := os.Readlink(filepath.Join(dir, "exe"))
+		cmdline, _ := ioutil.ReadFile(filepath.Join(dir, "cmdline"))
+		argv0 := string(bytes.SplitN(cmdline, []byte{0}, 2)[0])
+		if p.Name == name || filepath.Base(argv0) == name || (exe != "" && filepath.Base(exe) == name) {
+			candidates = append(candidates, p)
+		}
+	}
+	return one(fmt.Sprintf("name %q", name), candidates)
+}
+
+// Port returns the pid of the process listening on TCP port port, over
+// IPv4 or IPv6.
+func Port(port int) (int, error) {
+	inodes := map[string]bool{}
+	for _, file := range []string{"tcp", "tcp6"} {
+		if err := listeningSockets(filepath.Join(procfs, "net", file), port, inodes); err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+	query := fmt.Sprintf("port %d", port)
+	if len(inodes) == 0 {
+		return 0, fmt.Errorf("no process is listening on %s", query)
+	}
+	pids, err := pids()
+	if err != nil {
+		return 0, err
+	}
+	var candidates []Process
+	for _, pid := range pids {
+		fddir := filepath.Join(procfs, strconv.Itoa(pid), "fd")
+		fds, err := ioutil.ReadDir(fddir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fddir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") || !inodes[link[len("socket:["):len(link)-1]] {
+				continue
+			}
+			if p, ok := process(pid); ok {
+				candidates = append(candidates, p)
+			}
+			break
+		}
+	}
+	if len(candidates) == 0 {
+		return 0, fmt.Errorf("could not find the process listening on %s (permission denied?)", query)
+	}
+	return one(query, candidates)
+}
+
+// listeningSockets adds to inodes the inodes of the sockets listed in
+// file, in the format of /proc/net/tcp, that are listening on port.
+func listeningSockets(file string, port int, inodes map[string]bool) error {
+	fh, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	s := bufio.NewScanner(fh)
+	s.Scan() // header
+	for s.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ...
+		fields := strings.Fields(s.Text())
+		if len(fields) < 10 || fields[3] != tcpListen {
+			continue
+		}
+		colon := strings.LastIndex(fields[1], ":")
+		if colon < 0 {
+			continue
+		}
+		p, err := strconv.ParseUint(fields[1][colon+1:], 16, 16)
+		if err != nil || int(p) != port {
+			continue
+		}
+		inodes[fields[9]] = true
+	}
+	return s.Err()
+}
+
+// process returns the description of process pid.
+func process(pid int) (Process, bool) {
+	dir := filepath.Join(procfs, strconv.Itoa(pid))
+	comm, err := ioutil.ReadFile(filepath.Join(dir, "comm"))
+	if err != nil {
+		return Process{}, false
+	}
+	cmdline, _ := ioutil.ReadFile(filepath.Join(dir, "cmdline"))
+	cmdline = bytes.TrimRight(cmdline, "\x00")
+	return Process{
+		Pid:     pid,
+		Name:    strings.TrimSuffix(string(comm), "\n"),
+		Cmdline: string(bytes.ReplaceAll(cmdline, []byte{0}, []byte{' '})),
+	}, true
+}
+
+// pids returns the pids of all processes, in ascending order.
+func pids() ([]int, error) {
+	fis, err := ioutil.ReadDir(procfs)
+	if err != nil {
+		return nil, err
+	}
+	var r []int
+	for _, fi := range fis {
+		if pid, err := strconv.Atoi(fi.Name()); err == nil {
+			r = append(r, pid)
+		}
+	}
+	sort.Ints(r)
+	return r, nil
+}
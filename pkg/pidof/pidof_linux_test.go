@@ -0,0 +1,136 @@
+package pidof
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type fakeProcess struct {
+	comm    string
+	cmdline []string
+	sockets []string
+}
+
+const fakeTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 1001 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:A1B2 01 00000000:00000000 00:00000000 00000000  1000        0 1002 1 0000000000000000 20 4 30 10 -1
+   2: 0100007F:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1003 1 0000000000000000 100 0 0 10 0
+`
+
+const fakeTCP6 = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:1F91 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 1004 1 0000000000000000 100 0 0 10 0
+   1: 00000000000000000000000000000000:0050 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 1005 1 0000000000000000 100 0 0 10 0
+   2: 00000000000000000000000000000000:0050 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 1006 1 0000000000000000 100 0 0 10 0
+`
+
+// fakeProc creates a fake proc file system containing procs and installs
+// it as procfs.
+func fakeProc(t *testing.T, procs map[int]fakeProcess) {
+	dir, err := ioutil.TempDir("", "fakeproc")
+	must(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	must(t, os.MkdirAll(filepath.Join(dir, "net"), 0700))
+	must(t, ioutil.WriteFile(filepath.Join(dir, "net", "tcp"), []byte(fakeTCP), 0600))
+	must(t, ioutil.WriteFile(filepath.Join(dir, "net", "tcp6"), []byte(fakeTCP6), 0600))
+	for pid, p := range procs {
+		pdir := filepath.Join(dir, strconv.Itoa(pid))
+		must(t, os.MkdirAll(filepath.Join(pdir, "fd"), 0700))
+		must(t, ioutil.WriteFile(filepath.Join(pdir, "comm"), []byte(p.comm+"\n"), 0600))
+		must(t, ioutil.WriteFile(filepath.Join(pdir, "cmdline"), []byte(strings.Join(p.cmdline, "\x00")+"\x00"), 0600))
+		must(t, os.Symlink("/dev/null", filepath.Join(pdir, "fd", "0")))
+		for i, inode := range p.sockets {
+			must(t, os.Symlink("socket:["+inode+"]", filepath.Join(pdir, "fd", strconv.Itoa(i+3))))
+		}
+	}
+	old := procfs
+	procfs = dir
+	t.Cleanup(func() { procfs = old })
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPidof(t *testing.T) {
+	fakeProc(t, map[int]fakeProcess{
+		1:   {"systemd", []string{"/sbin/init"}, nil},
+		100: {"sshd", []string{"/usr/sbin/sshd", "-D"}, []string{"1003"}},
+		200: {"myserver", []string{"./myserver", "-port", "8080"}, []string{"1001", "1002"}},
+		300: {"worker", []string{"/opt/worker"}, []string{"1004"}},
+		301: {"worker", []string{"/opt/worker", "-n", "2"}, nil},
+		400: {"web", []string{"/usr/bin/web"}, []string{"1005"}},
+		401: {"web", []string{"/usr/bin/web"}, []string{"1006"}},
+		500: {"averyveryveryve", []string{"/usr/bin/averyveryverylongname"}, nil},
+	})
+
+	for _, tc := range []struct {
+		name string
+		pid  int
+	}{
+		{"myserver", 200},
+		{"sshd", 100},
+		{"init", 1},
+		{"averyveryverylongname", 500},
+	} {
+		pid, err := Name(tc.name)
+		if err != nil {
+			t.Errorf("Name(%q): %v", tc.name, err)
+		} else if pid != tc.pid {
+			t.Errorf("Name(%q) = %d, expected %d", tc.name, pid, tc.pid)
+		}
+	}
+
+	for _, tc := range []struct {
+		port, pid int
+	}{
+		{8080, 200},
+		{22, 100},
+		{8081, 300},
+	} {
+		pid, err := Port(tc.port)
+		if err != nil {
+			t.Errorf("Port(%d): %v", tc.port, err)
+		} else if pid != tc.pid {
+			t.Errorf("Port(%d) = %d, expected %d", tc.port, pid, tc.pid)
+		}
+	}
+
+	if _, err := Name("nosuchprocess"); err == nil {
+		t.Errorf("expected error for missing process")
+	}
+	if _, err := Port(9999); err == nil {
+		t.Errorf("expected error for unused port")
+	}
+
+	checkAmbiguous := func(err error, pids ...int) {
+		t.Helper()
+		var aerr *AmbiguousError
+		if !errors.As(err, &aerr) {
+			t.Fatalf("expected AmbiguousError, got %v", err)
+		}
+		if len(aerr.Candidates) != len(pids) {
+			t.Fatalf("wrong candidates %v", aerr.Candidates)
+		}
+		for i := range pids {
+			if aerr.Candidates[i].Pid != pids[i] {
+				t.Fatalf("wrong candidates %v", aerr.Candidates)
+			}
+		}
+		t.Log(err)
+	}
+	_, err := Name("worker")
+	checkAmbiguous(err, 300, 301)
+	if !strings.Contains(err.Error(), "/opt/worker -n 2") {
+		t.Errorf("command line missing from error: %v", err)
+	}
+	_, err = Port(80)
+	checkAmbiguous(err, 400, 401)
+}
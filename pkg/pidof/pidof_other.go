@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package pidof
+
+// Name returns the pid of the process called name.
+func Name(name string) (int, error) {
+	return 0, ErrNotSupported
+}
+
+// Port returns the pid of the process listening on TCP port port.
+func Port(port int) (int, error) {
+	return 0, ErrNotSupported
+}
@@ -47,11 +47,9 @@ func AMD64Arch(goos string) *Arch {
 
 func amd64FixFrameUnwindContext(fctxt *frame.FrameContext, pc uint64, bi *BinaryInfo) *frame.FrameContext {
 	a := bi.Arch
-	if a.sigreturnfn == nil {
-		a.sigreturnfn = bi.LookupFunc["runtime.sigreturn"]
-	}
+	sigreturnfn, crosscall2fn := a.unwindFuncs(bi)
 
-	if fctxt == nil || (a.sigreturnfn != nil && pc >= a.sigreturnfn.Entry && pc < a.sigreturnfn.End) {
+	if fctxt == nil || (sigreturnfn != nil && pc >= sigreturnfn.Entry && pc < sigreturnfn.End) {
 		// When there's no frame descriptor entry use BP (the frame pointer) instead
 		// - return register is [bp + a.PtrSize()] (i.e. [cfa-a.PtrSize()])
 		// - cfa is bp + a.PtrSize()*2
@@ -94,11 +92,7 @@ func amd64FixFrameUnwindContext(fctxt *frame.FrameContext, pc uint64, bi *Binary
 		}
 	}
 
-	if a.crosscall2fn == nil {
-		a.crosscall2fn = bi.LookupFunc["crosscall2"]
-	}
-
-	if a.crosscall2fn != nil && pc >= a.crosscall2fn.Entry && pc < a.crosscall2fn.End {
+	if crosscall2fn != nil && pc >= crosscall2fn.Entry && pc < crosscall2fn.End {
 		rule := fctxt.CFA
 		if rule.Offset == crosscall2SPOffsetBad {
 			switch bi.GOOS {
@@ -133,7 +127,7 @@ const amd64cgocallSPOffsetSaveSlot = 0x28
 
 func amd64SwitchStack(it *stackIterator, _ *op.DwarfRegisters) bool {
 	if it.frame.Current.Fn == nil {
-		if it.systemstack && it.g != nil && it.top {
+		if it.systemstack && it.g != nil && it.top && !it.unwindUnknownFrame() {
 			it.switchToGoroutineStack()
 			return true
 		}
@@ -3,6 +3,7 @@ package proc
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/go-delve/delve/pkg/dwarf/frame"
 	"github.com/go-delve/delve/pkg/dwarf/op"
@@ -68,6 +69,10 @@ type Arch struct {
 	// the signal handler. See comment in FixFrameUnwindContext for a
 	// description of why this is needed.
 	sigreturnfn *Function
+
+	// unwindFuncsOnce protects crosscall2fn and sigreturnfn, which are
+	// looked up while unwinding stacks concurrently, see unwindFuncs.
+	unwindFuncsOnce sync.Once
 }
 
 type asmRegister struct {
@@ -152,3 +157,14 @@ const (
 	crosscall2SPOffsetWindows    = 0x118
 	crosscall2SPOffsetNonWindows = 0x58
 )
+
+// unwindFuncs returns the functions that need special handling by
+// FixFrameUnwindContext, looking them up the first time it is called. It
+// can be called concurrently, see GoroutineStacktraces.
+func (a *Arch) unwindFuncs(bi *BinaryInfo) (sigreturnfn, crosscall2fn *Function) {
+	a.unwindFuncsOnce.Do(func() {
+		a.sigreturnfn = bi.LookupFunc["runtime.sigreturn"]
+		a.crosscall2fn = bi.LookupFunc["crosscall2"]
+	})
+	return a.sigreturnfn, a.crosscall2fn
+}
@@ -46,11 +46,9 @@ func ARM64Arch(goos string) *Arch {
 
 func arm64FixFrameUnwindContext(fctxt *frame.FrameContext, pc uint64, bi *BinaryInfo) *frame.FrameContext {
 	a := bi.Arch
-	if a.sigreturnfn == nil {
-		a.sigreturnfn = bi.LookupFunc["runtime.sigreturn"]
-	}
+	sigreturnfn, crosscall2fn := a.unwindFuncs(bi)
 
-	if fctxt == nil || (a.sigreturnfn != nil && pc >= a.sigreturnfn.Entry && pc < a.sigreturnfn.End) {
+	if fctxt == nil || (sigreturnfn != nil && pc >= sigreturnfn.Entry && pc < sigreturnfn.End) {
 		// When there's no frame descriptor entry use BP (the frame pointer) instead
 		// - return register is [bp + a.PtrSize()] (i.e. [cfa-a.PtrSize()])
 		// - cfa is bp + a.PtrSize()*2
@@ -93,11 +91,7 @@ func arm64FixFrameUnwindContext(fctxt *frame.FrameContext, pc uint64, bi *Binary
 		}
 	}
 
-	if a.crosscall2fn == nil {
-		a.crosscall2fn = bi.LookupFunc["crosscall2"]
-	}
-
-	if a.crosscall2fn != nil && pc >= a.crosscall2fn.Entry && pc < a.crosscall2fn.End {
+	if crosscall2fn != nil && pc >= crosscall2fn.Entry && pc < crosscall2fn.End {
 		rule := fctxt.CFA
 		if rule.Offset == crosscall2SPOffsetBad {
 			switch bi.GOOS {
@@ -136,7 +130,7 @@ const arm64cgocallSPOffsetSaveSlot = 0x8
 const prevG0schedSPOffsetSaveSlot = 0x10
 
 func arm64SwitchStack(it *stackIterator, callFrameRegs *op.DwarfRegisters) bool {
-	if it.frame.Current.Fn == nil && it.systemstack && it.g != nil && it.top {
+	if it.frame.Current.Fn == nil && it.systemstack && it.g != nil && it.top && !it.unwindUnknownFrame() {
 		it.switchToGoroutineStack()
 		return true
 	}
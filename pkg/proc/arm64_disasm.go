@@ -41,15 +41,32 @@ func arm64AsmDecode(asmInst *AsmInstruction, mem []byte, regs *op.DwarfRegisters
 }
 
 func resolveCallArgARM64(inst *arm64asm.Inst, instAddr uint64, currentGoroutine bool, regs *op.DwarfRegisters, mem MemoryReadWriter, bininfo *BinaryInfo) *Location {
+	var pc uint64
+	var err error
+
 	switch inst.Op {
 	case arm64asm.BL, arm64asm.BLR, arm64asm.B, arm64asm.BR:
 		//ok
+	case arm64asm.CBZ, arm64asm.CBNZ, arm64asm.TBZ, arm64asm.TBNZ:
+		// conditional branches, the destination is the last argument
+		for i := len(inst.Args) - 1; i >= 0; i-- {
+			if rel, ok := inst.Args[i].(arm64asm.PCRel); ok {
+				pc = uint64(instAddr) + uint64(rel)
+				return pcToDestLoc(bininfo, pc)
+			}
+		}
+		return nil
 	default:
 		return nil
 	}
 
-	var pc uint64
-	var err error
+	if _, iscond := inst.Args[0].(arm64asm.Cond); iscond && inst.Op == arm64asm.B {
+		// B.cond
+		if rel, ok := inst.Args[1].(arm64asm.PCRel); ok {
+			return pcToDestLoc(bininfo, uint64(instAddr)+uint64(rel))
+		}
+		return nil
+	}
 
 	switch arg := inst.Args[0].(type) {
 	case arm64asm.Imm:
@@ -68,11 +85,7 @@ func resolveCallArgARM64(inst *arm64asm.Inst, instAddr uint64, currentGoroutine
 		return nil
 	}
 
-	file, line, fn := bininfo.PCToLine(pc)
-	if fn == nil {
-		return &Location{PC: pc}
-	}
-	return &Location{PC: pc, File: file, Line: line, Fn: fn}
+	return pcToDestLoc(bininfo, pc)
 }
 
 // Possible stacksplit prologues are inserted by stacksplit in
@@ -15,12 +15,14 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/go-delve/delve/pkg/dwarf/dwo"
 	"github.com/go-delve/delve/pkg/dwarf/frame"
 	"github.com/go-delve/delve/pkg/dwarf/godwarf"
 	"github.com/go-delve/delve/pkg/dwarf/line"
@@ -30,15 +32,20 @@ import (
 	"github.com/go-delve/delve/pkg/dwarf/util"
 	"github.com/go-delve/delve/pkg/goversion"
 	"github.com/go-delve/delve/pkg/logflags"
+	"github.com/go-delve/delve/pkg/pclntab"
+	"github.com/go-delve/delve/pkg/pdb"
 	"github.com/go-delve/delve/pkg/proc/debuginfod"
+	"github.com/go-delve/delve/pkg/proc/macutil"
+	"github.com/go-delve/delve/pkg/symindex"
 	"github.com/hashicorp/golang-lru/simplelru"
 	"github.com/sirupsen/logrus"
 )
 
 const (
-	dwarfGoLanguage    = 22   // DW_LANG_Go (from DWARF v5, section 7.12, page 231)
-	dwarfAttrAddrBase  = 0x74 // debug/dwarf.AttrAddrBase in Go 1.14, defined here for compatibility with Go < 1.14
-	dwarfTreeCacheSize = 512  // size of the dwarfTree cache of each image
+	dwarfGoLanguage       = 22   // DW_LANG_Go (from DWARF v5, section 7.12, page 231)
+	dwarfAttrAddrBase     = 0x73 // debug/dwarf.AttrAddrBase in Go 1.14, defined here for compatibility with Go < 1.14
+	dwarfAttrLoclistsBase = 0x8c // debug/dwarf.AttrLoclistsBase in Go 1.14, defined here for compatibility with Go < 1.14
+	dwarfTreeCacheSize    = 512  // size of the dwarfTree cache of each image
 )
 
 // BinaryInfo holds information on the binaries being executed (this
@@ -52,7 +59,13 @@ type BinaryInfo struct {
 
 	debugInfoDirectories []string
 
-	// BuildID of this binary.
+	// rootDir, if not empty, is the directory where the root of the file
+	// system of the target process is visible (for example /proc/<pid>/root
+	// for a process running in a container). Shared libraries and separate
+	// debug info are looked up relative to it.
+	rootDir string
+
+	// BuildID of the executable file.
 	BuildID string
 
 	// Functions is a list of all DW_TAG_subprogram entries in debug_info, sorted by entry point
@@ -110,9 +123,25 @@ type BinaryInfo struct {
 	// dwrapUnwrapCache caches unwrapping of defer wrapper functions (dwrap)
 	dwrapUnwrapCache map[uint64]*Function
 
+	// deferredTypes and deferredInlinedCalls are the compile units whose
+	// types, package variables and constants, or inlined calls, have not
+	// been loaded yet, see loadDebugInfoMapsLazy.
+	deferredMu           sync.Mutex
+	deferredTypes        []*compileUnit
+	deferredInlinedCalls []*compileUnit
+
 	// Go 1.17 register ABI is enabled.
 	regabi bool
 
+	// functionIndex, typeIndex and sourceIndex cache the indexes returned by
+	// FunctionIndex, TypeIndex and SourceIndex.
+	functionIndex, typeIndex, sourceIndex symbolIndex
+
+	// loadMu is held while the data read from an image is added to the
+	// fields of BinaryInfo, since images can be loaded concurrently, see
+	// AddImages.
+	loadMu sync.Mutex
+
 	logger *logrus.Entry
 }
 
@@ -126,11 +155,16 @@ var (
 	ErrNoDebugInfoFound = errors.New("could not open debug info")
 )
 
+// _EM_LOONGARCH is missing from debug/elf before Go 1.19.
+const _EM_LOONGARCH elf.Machine = 258
+
 var (
 	supportedLinuxArch = map[elf.Machine]bool{
 		elf.EM_X86_64:  true,
 		elf.EM_AARCH64: true,
 		elf.EM_386:     true,
+		elf.EM_RISCV:   true,
+		_EM_LOONGARCH:  true,
 	}
 
 	supportedWindowsArch = map[_PEMachine]bool{
@@ -182,6 +216,7 @@ func FindFileLocation(p Process, filename string, lineno int) ([]uint64, error)
 		// that case it's possible for the line itself to not appear in debug_line
 		// at all, but it will still be in debug_info as the call site for an
 		// inlined subroutine entry.
+		bi.loadDeferredInlinedCalls()
 		for _, pc := range bi.inlinedCallLines[fileLine{filename, lineno}] {
 			pcs = append(pcs, line.PCStmt{PC: pc, Stmt: true})
 		}
@@ -313,13 +348,21 @@ func allInlineCallRanges(tree *godwarf.Tree) []inlRange {
 
 // FindFunction returns the functions with name funcName.
 func (bi *BinaryInfo) FindFunction(funcName string) ([]*Function, error) {
+	var fns []*Function
 	if fn := bi.LookupFunc[funcName]; fn != nil {
-		return []*Function{fn}, nil
+		fns = []*Function{fn}
+	} else {
+		fns = bi.LookupGenericFunc()[funcName]
 	}
-	fns := bi.LookupGenericFunc()[funcName]
 	if len(fns) == 0 {
 		return nil, &ErrFunctionNotFound{funcName}
 	}
+	for _, fn := range fns {
+		if fn.abstractOffset != 0 {
+			bi.loadDeferredInlinedCalls()
+			break
+		}
+	}
 	return fns, nil
 }
 
@@ -451,6 +494,18 @@ type compileUnit struct {
 	offset dwarf.Offset // offset of the entry describing the compile unit
 
 	image *Image // parent image of this compilation unit.
+
+	// lazy is true if the entries of this compile unit, other than its
+	// subprograms, are loaded only when they are needed. deferredTypes are
+	// the offsets of its type, variable and constant entries and
+	// deferredSubprograms the offsets of the subprogram entries whose
+	// children, the inlined calls, have not been read yet.
+	// deferredTypeEntries is true if deferredTypes contains type entries,
+	// the Go linker writes all of them in a single compile unit.
+	lazy                bool
+	deferredTypes       []dwarf.Offset
+	deferredSubprograms []dwarf.Offset
+	deferredTypeEntries bool
 }
 
 type fileLine struct {
@@ -479,6 +534,10 @@ type Function struct {
 
 	trampoline bool // DW_AT_trampoline attribute set to true
 
+	// abstractOffset is the offset of the abstract entry (DW_AT_inline) of
+	// the function, it is zero if the function was never inlined.
+	abstractOffset dwarf.Offset
+
 	// InlinedCalls lists all inlined calls to this function
 	InlinedCalls []InlinedCall
 }
@@ -646,6 +705,10 @@ func NewBinaryInfo(goos, goarch string) *BinaryInfo {
 		r.Arch = AMD64Arch(goos)
 	case "arm64":
 		r.Arch = ARM64Arch(goos)
+	case "loong64":
+		r.Arch = LOONG64Arch(goos)
+	case "riscv64":
+		r.Arch = RISCV64Arch(goos)
 	}
 	return r
 }
@@ -667,7 +730,7 @@ func loadBinaryInfo(bi *BinaryInfo, image *Image, path string, entryPoint uint64
 	defer wg.Wait()
 
 	switch bi.GOOS {
-	case "linux", "freebsd":
+	case "linux", "freebsd", "openbsd":
 		return loadBinaryInfoElf(bi, image, path, entryPoint, &wg)
 	case "windows":
 		return loadBinaryInfoPE(bi, image, path, entryPoint, &wg)
@@ -695,6 +758,7 @@ func (so *Image) DwarfReader() *reader.Reader {
 
 // Types returns list of types present in the debugged program.
 func (bi *BinaryInfo) Types() ([]string, error) {
+	bi.loadDeferredTypeEntries()
 	types := make([]string, 0, len(bi.types))
 	for k := range bi.types {
 		types = append(types, k)
@@ -702,6 +766,52 @@ func (bi *BinaryInfo) Types() ([]string, error) {
 	return types, nil
 }
 
+// symbolIndex caches a symindex.Index of a list of names that can only
+// grow, n is the length of the list when the index was built.
+type symbolIndex struct {
+	idx *symindex.Index
+	n   int
+}
+
+func (si *symbolIndex) get(n int, names func() []string) *symindex.Index {
+	if si.idx == nil || si.n != n {
+		si.idx = symindex.New(names())
+		si.n = n
+	}
+	return si.idx
+}
+
+// FunctionIndex returns an index of the names of bi.Functions, in the same
+// order. The index is built the first time it is needed and rebuilt when
+// new functions are loaded.
+func (bi *BinaryInfo) FunctionIndex() *symindex.Index {
+	return bi.functionIndex.get(len(bi.Functions), func() []string {
+		names := make([]string, len(bi.Functions))
+		for i := range bi.Functions {
+			names[i] = bi.Functions[i].Name
+		}
+		return names
+	})
+}
+
+// TypeIndex returns an index of the names of the types of the debugged
+// program, sorted alphabetically, see FunctionIndex.
+func (bi *BinaryInfo) TypeIndex() *symindex.Index {
+	bi.loadDeferredTypeEntries()
+	return bi.typeIndex.get(len(bi.types), func() []string {
+		names, _ := bi.Types()
+		sort.Strings(names)
+		return names
+	})
+}
+
+// SourceIndex returns an index of bi.Sources, see FunctionIndex.
+func (bi *BinaryInfo) SourceIndex() *symindex.Index {
+	return bi.sourceIndex.get(len(bi.Sources), func() []string {
+		return append([]string(nil), bi.Sources...)
+	})
+}
+
 // PCToLine converts an instruction address to a file/line/function.
 func (bi *BinaryInfo) PCToLine(pc uint64) (string, int, *Function) {
 	fn := bi.PCToFunc(pc)
@@ -771,6 +881,8 @@ type Image struct {
 
 	index int // index of this object in BinaryInfo.SharedObjects
 
+	buildID string // GNU build ID of this object
+
 	closer         io.Closer
 	sepDebugCloser io.Closer
 
@@ -780,11 +892,23 @@ type Image struct {
 	loclist5     *loclist.Dwarf5Reader
 	debugAddr    *godwarf.DebugAddrSection
 	debugLineStr []byte
+	debugAbbrev  []byte             // contents of debug_abbrev, used to scan the compile units, see loadDebugInfoMaps
+	nameIndex    *godwarf.NameIndex // accelerator table from .debug_names or .gdb_index, may be nil
+
+	// symbolsOnly is true if the executable does not have DWARF debug
+	// info and its functions were loaded from the pclntab, see loadPclntab.
+	symbolsOnly bool
+
+	// cSymbols are the function symbols of a shared object without debug
+	// info, sorted by address. They are used to name C frames in stack
+	// traces, see loadCFrameInfoElf.
+	cSymbols []cSymbol
 
 	typeCache map[dwarf.Offset]godwarf.Type
 
 	compileUnits []*compileUnit // compileUnits is sorted by increasing DWARF offset
 
+	dwarfTreeCacheMu    sync.Mutex // protects dwarfTreeCache, which is used by GoroutineStacktraces workers
 	dwarfTreeCache      *simplelru.LRU
 	runtimeMallocgcTree *godwarf.Tree // patched version of runtime.mallocgc's DIE
 
@@ -798,6 +922,29 @@ type Image struct {
 	loadErr   error
 }
 
+// cSymbol is a function symbol read from the symbol table of a shared
+// object without debug info.
+type cSymbol struct {
+	Name  string
+	Entry uint64
+	End   uint64
+}
+
+// PCToSymbol returns the name and entry point of the function symbol
+// containing pc. Only shared objects without debug info are searched, for
+// everything else PCToFunc should be used.
+func (bi *BinaryInfo) PCToSymbol(pc uint64) (name string, entry uint64) {
+	for _, image := range bi.Images {
+		i := sort.Search(len(image.cSymbols), func(i int) bool {
+			return image.cSymbols[i].End > pc
+		})
+		if i < len(image.cSymbols) && image.cSymbols[i].Entry <= pc {
+			return image.cSymbols[i].Name, image.cSymbols[i].Entry
+		}
+	}
+	return "", 0
+}
+
 func (image *Image) registerRuntimeTypeToDIE(entry *dwarf.Entry, ardr *reader.Reader) {
 	if off, ok := entry.Val(godwarf.AttrGoRuntimeType).(uint64); ok {
 		if _, ok := image.runtimeTypeToDIE[off]; !ok {
@@ -811,28 +958,94 @@ func (image *Image) registerRuntimeTypeToDIE(entry *dwarf.Entry, ardr *reader.Re
 // the relocation offset) for all other images.
 // The first image added must be the executable file.
 func (bi *BinaryInfo) AddImage(path string, addr uint64) error {
+	image, loadPath := bi.newImage(path, addr)
+	if image == nil {
+		return nil
+	}
+	err := bi.loadImage(image, loadPath)
+	bi.macOSDebugFrameBugWorkaround()
+	return err
+}
+
+// AddImages adds the images at paths to bi, like AddImage, addrs are their
+// relocation offsets. The images are loaded concurrently.
+// The executable file must have been added already. The error of the first
+// image that could not be loaded is returned, the errors of all images are
+// also available through their LoadError method.
+func (bi *BinaryInfo) AddImages(paths []string, addrs []uint64) error {
+	if len(bi.Images) == 0 {
+		return errors.New("executable file not loaded")
+	}
+	type imageLoad struct {
+		image    *Image
+		loadPath string
+		err      error
+	}
+	var loads []*imageLoad
+	for i, path := range paths {
+		if image, loadPath := bi.newImage(path, addrs[i]); image != nil {
+			loads = append(loads, &imageLoad{image: image, loadPath: loadPath})
+		}
+	}
+
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for _, load := range loads {
+		wg.Add(1)
+		go func(load *imageLoad) {
+			defer wg.Done()
+			sem <- struct{}{}
+			load.err = bi.loadImage(load.image, load.loadPath)
+			<-sem
+		}(load)
+	}
+	wg.Wait()
+
+	bi.macOSDebugFrameBugWorkaround()
+	for _, load := range loads {
+		if load.err != nil {
+			return load.err
+		}
+	}
+	return nil
+}
+
+// newImage creates a new image for the file at path and appends it to
+// bi.Images, returning it along with the path it should be loaded from. If
+// the image is already present nil is returned.
+func (bi *BinaryInfo) newImage(path string, addr uint64) (*Image, string) {
 	// Check if the image is already present.
 	if len(bi.Images) > 0 && !strings.HasPrefix(path, "/") {
-		return nil
+		return nil, ""
 	}
 	for _, image := range bi.Images {
 		if image.Path == path && image.addr == addr {
-			return nil
+			return nil, ""
 		}
 	}
 
-	// Actually add the image.
 	image := &Image{Path: path, addr: addr, typeCache: make(map[dwarf.Offset]godwarf.Type)}
 	image.dwarfTreeCache, _ = simplelru.NewLRU(dwarfTreeCacheSize, nil)
 
+	loadPath := path
+	if len(bi.Images) > 0 && bi.rootDir != "" && filepath.IsAbs(path) {
+		loadPath = filepath.Join(bi.rootDir, path)
+	}
+
 	// add Image regardless of error so that we don't attempt to re-add it every time we stop
 	image.index = len(bi.Images)
 	bi.Images = append(bi.Images, image)
-	err := loadBinaryInfo(bi, image, path, addr)
+	return image, loadPath
+}
+
+// loadImage loads the contents of image from the file at loadPath.
+func (bi *BinaryInfo) loadImage(image *Image, loadPath string) error {
+	err := loadBinaryInfo(bi, image, loadPath, image.addr)
 	if err != nil {
-		bi.Images[len(bi.Images)-1].loadErr = err
+		image.loadErrMu.Lock()
+		image.loadErr = err
+		image.loadErrMu.Unlock()
 	}
-	bi.macOSDebugFrameBugWorkaround()
 	return err
 }
 
@@ -928,10 +1141,19 @@ func (image *Image) LoadError() error {
 	return image.loadErr
 }
 
+// SymbolsOnly returns true if the image was built without DWARF debug
+// info (for example with -ldflags=-w) and only its functions, line
+// tables and frames, read from the Go symbol table, are known.
+func (image *Image) SymbolsOnly() bool {
+	return image.symbolsOnly
+}
+
 func (image *Image) getDwarfTree(off dwarf.Offset) (*godwarf.Tree, error) {
 	if image.runtimeMallocgcTree != nil && off == image.runtimeMallocgcTree.Offset {
 		return image.runtimeMallocgcTree, nil
 	}
+	image.dwarfTreeCacheMu.Lock()
+	defer image.dwarfTreeCacheMu.Unlock()
 	if r, ok := image.dwarfTreeCache.Get(off); ok {
 		return r.(*godwarf.Tree), nil
 	}
@@ -969,25 +1191,53 @@ func (bi *BinaryInfo) LoadImageFromData(dwdata *dwarf.Data, debugFrameBytes, deb
 }
 
 func (bi *BinaryInfo) locationExpr(entry godwarf.Entry, attr dwarf.Attr, pc uint64) ([]byte, *locationExpr, error) {
-	//TODO(aarzilli): handle DW_FORM_loclistx attribute form new in DWARFv5
 	a := entry.Val(attr)
 	if a == nil {
 		return nil, nil, fmt.Errorf("no location attribute %s", attr)
 	}
 	if instr, ok := a.([]byte); ok {
+		if len(instr) == 0 {
+			// An empty location description means that the variable is
+			// present in the source but not in the object code.
+			return nil, nil, errOptimizedOut
+		}
 		return instr, &locationExpr{isBlock: true, instr: instr}, nil
 	}
-	off, ok := a.(int64)
-	if !ok {
-		return nil, nil, fmt.Errorf("could not interpret location attribute %s", attr)
+	off, err := bi.loclistOffset(bi.findCompileUnit(pc), a)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not interpret location attribute %s: %v", attr, err)
 	}
 	instr := bi.loclistEntry(off, pc)
 	if instr == nil {
-		return nil, nil, fmt.Errorf("could not find loclist entry at %#x for address %#x", off, pc)
+		// The variable exists but its value is not available at this address.
+		return nil, &locationExpr{pc: pc, off: off}, errOptimizedOut
 	}
 	return instr, &locationExpr{pc: pc, off: off, instr: instr}, nil
 }
 
+// loclistOffset returns the offset of the location list specified by
+// attribute value a, which must have been read from an entry of cu.
+// Attributes with form DW_FORM_loclistx (new in DWARFv5) are resolved
+// using the DW_AT_loclists_base attribute of the compile unit.
+func (bi *BinaryInfo) loclistOffset(cu *compileUnit, a interface{}) (int64, error) {
+	switch a := a.(type) {
+	case int64:
+		return a, nil
+	case uint64:
+		if cu == nil || cu.image == nil || cu.image.loclist5 == nil {
+			return 0, errors.New("could not find compile unit")
+		}
+		loclistsBase, ok := cu.entry.Val(dwarfAttrLoclistsBase).(int64)
+		if !ok {
+			return 0, errors.New("DW_AT_loclists_base not found")
+		}
+		off, err := cu.image.loclist5.OffsetForIndex(uint64(loclistsBase), a)
+		return int64(off), err
+	default:
+		return 0, fmt.Errorf("unsupported type %T", a)
+	}
+}
+
 type locationExpr struct {
 	isBlock   bool
 	isEscaped bool
@@ -1027,20 +1277,24 @@ func (bi *BinaryInfo) LocationCovers(entry *dwarf.Entry, attr dwarf.Attr) ([][2]
 		return [][2]uint64{[2]uint64{0, ^uint64(0)}}, nil
 	}
 
-	off, ok := a.(int64)
-	if !ok {
-		return nil, fmt.Errorf("attribute %s of unsupported type %T", attr, a)
-	}
 	cu := bi.Images[0].findCompileUnitForOffset(entry.Offset)
 	if cu == nil {
 		return nil, errors.New("could not find compile unit")
 	}
-	if cu.Version >= 5 && cu.image.loclist5 != nil {
-		return nil, errors.New("LocationCovers does not support DWARFv5")
+	off, err := bi.loclistOffset(cu, a)
+	if err != nil {
+		return nil, fmt.Errorf("attribute %s: %v", attr, err)
 	}
 
 	image := cu.image
 	base := cu.lowPC
+	if cu.Version >= 5 && image != nil && image.loclist5 != nil && !image.loclist5.Empty() {
+		var debugAddr *godwarf.DebugAddr
+		if addrBase, ok := cu.entry.Val(dwarfAttrAddrBase).(int64); ok {
+			debugAddr = image.debugAddr.GetSubsection(uint64(addrBase))
+		}
+		return image.loclist5.Ranges(int(off), image.StaticBase, base, debugAddr)
+	}
 	if image == nil || image.loclist2.Empty() {
 		return nil, errors.New("malformed executable")
 	}
@@ -1177,7 +1431,9 @@ func (bi *BinaryInfo) parseDebugFrameGeneral(image *Image, debugFrameBytes []byt
 			image.setLoadError(bi.logger, "could not parse %s section: %v", debugFrameName, err)
 			return
 		}
+		bi.loadMu.Lock()
 		bi.frameEntries = bi.frameEntries.Append(fe)
+		bi.loadMu.Unlock()
 	}
 
 	if ehFrameBytes != nil && ehFrameAddr > 0 {
@@ -1190,8 +1446,114 @@ func (bi *BinaryInfo) parseDebugFrameGeneral(image *Image, debugFrameBytes []byt
 			bi.logger.Warnf("could not parse %s section: %v", ehFrameName, err)
 			return
 		}
+		bi.loadMu.Lock()
 		bi.frameEntries = bi.frameEntries.Append(fe)
+		bi.loadMu.Unlock()
+	}
+}
+
+// pclntabSymbols are the symbols of the executable used by loadPclntab.
+var pclntabSymbols = map[string]bool{
+	"runtime.text":         true,
+	"runtime.pclntab":      true,
+	"runtime.epclntab":     true,
+	"runtime.buildVersion": true,
+}
+
+// loadPclntab loads the functions of a Go executable built without DWARF
+// debug info (-ldflags=-w) from the pclntab, the table used by the Go
+// runtime to produce stack traces, converting it to DWARF. Only
+// functions, line tables and frame descriptions are available, not
+// variables or types.
+// Symbols maps the names in pclntabSymbols to their address, read reads
+// size bytes at address addr of the executable.
+func (bi *BinaryInfo) loadPclntab(image *Image, symbols map[string]uint64, read func(addr, size uint64) ([]byte, error), wg *sync.WaitGroup, cont func()) error {
+	start, end := symbols["runtime.pclntab"], symbols["runtime.epclntab"]
+	if start == 0 || end <= start {
+		return ErrNoDebugInfoFound
+	}
+	data, err := read(start, end-start)
+	if err != nil {
+		return err
+	}
+	tab, err := pclntab.Parse(data, symbols["runtime.text"])
+	if err != nil {
+		return err
+	}
+
+	arch := pclntab.Arch{SPRegNum: bi.Arch.SPRegNum, RARegNum: bi.Arch.PCRegNum}
+	if bi.Arch.usesLR {
+		arch.LinkRegister = true
+		arch.RARegNum = bi.Arch.LRRegNum
 	}
+	sec := tab.DWARF(arch, bi.pclntabProducer(symbols["runtime.buildVersion"], tab.PtrSize, read))
+	d, err := dwarf.New(sec.Abbrev, nil, nil, sec.Info, sec.Line, nil, sec.Ranges, nil)
+	if err != nil {
+		return err
+	}
+	bi.logger.Debugf("%s has no DWARF debug info, loading functions from the pclntab", image.Path)
+	image.dwarf = d
+	image.dwarfReader = d.Reader()
+	image.symbolsOnly = true
+
+	bi.parseDebugFrameGeneral(image, sec.Frame, ".debug_frame", nil, nil, 0, "", frame.DwarfEndian(sec.Info))
+	wg.Add(1)
+	go bi.loadDebugInfoMaps(image, sec.Info, sec.Line, wg, cont)
+	return nil
+}
+
+// pclntabProducer returns a producer string, like the one the compiler
+// writes in compile units, describing the version of Go, read from the
+// runtime.buildVersion variable at addr. An empty string is returned if
+// the version can not be read.
+func (bi *BinaryInfo) pclntabProducer(addr uint64, ptrSize int, read func(addr, size uint64) ([]byte, error)) string {
+	if addr == 0 {
+		return ""
+	}
+	hdr, err := read(addr, uint64(2*ptrSize))
+	if err != nil {
+		return ""
+	}
+	word := func(b []byte) uint64 {
+		if ptrSize == 4 {
+			return uint64(binary.LittleEndian.Uint32(b))
+		}
+		return binary.LittleEndian.Uint64(b)
+	}
+	strlen := word(hdr[ptrSize:])
+	if strlen == 0 || strlen > 256 {
+		return ""
+	}
+	verstr, err := read(word(hdr), strlen)
+	if err != nil {
+		return ""
+	}
+	ver, ok := goversion.Parse(string(verstr))
+	if !ok {
+		return ""
+	}
+	producer := "Go cmd/compile " + string(verstr)
+	// regabi can not be detected otherwise, assume it was enabled on the
+	// architectures where it is the default.
+	switch bi.Arch.Name {
+	case "amd64":
+		if ver.AfterOrEqual(goversion.GoVersion{Major: 1, Minor: 17, Rev: -1}) {
+			producer += "; regabi"
+		}
+	case "arm64", "ppc64le":
+		if ver.AfterOrEqual(goversion.GoVersion{Major: 1, Minor: 18, Rev: -1}) {
+			producer += "; regabi"
+		}
+	case "riscv64":
+		if ver.AfterOrEqual(goversion.GoVersion{Major: 1, Minor: 19, Rev: -1}) {
+			producer += "; regabi"
+		}
+	case "loong64":
+		if ver.AfterOrEqual(goversion.GoVersion{Major: 1, Minor: 20, Rev: -1}) {
+			producer += "; regabi"
+		}
+	}
+	return producer
 }
 
 // ELF ///////////////////////////////////////////////////////////////
@@ -1207,10 +1569,17 @@ func (bi *BinaryInfo) parseDebugFrameGeneral(image *Image, debugFrameBytes []byt
 func (bi *BinaryInfo) openSeparateDebugInfo(image *Image, exe *elf.File, debugInfoDirectories []string) (*os.File, *elf.File, error) {
 	var debugFilePath string
 	var err error
+	if bi.rootDir != "" {
+		rooted := make([]string, 0, 2*len(debugInfoDirectories))
+		for _, dir := range debugInfoDirectories {
+			rooted = append(rooted, filepath.Join(bi.rootDir, dir))
+		}
+		debugInfoDirectories = append(rooted, debugInfoDirectories...)
+	}
 	for _, dir := range debugInfoDirectories {
 		var potentialDebugFilePath string
-		if strings.Contains(dir, "build-id") && len(bi.BuildID) > 2 {
-			potentialDebugFilePath = fmt.Sprintf("%s/%s/%s.debug", dir, bi.BuildID[:2], bi.BuildID[2:])
+		if strings.Contains(dir, "build-id") && len(image.buildID) > 2 {
+			potentialDebugFilePath = fmt.Sprintf("%s/%s/%s.debug", dir, image.buildID[:2], image.buildID[2:])
 		} else if strings.HasPrefix(image.Path, "/proc") {
 			path, err := filepath.EvalSymlinks(image.Path)
 			if err == nil {
@@ -1228,7 +1597,7 @@ func (bi *BinaryInfo) openSeparateDebugInfo(image *Image, exe *elf.File, debugIn
 	// We cannot find the debug information locally on the system. Try and see if we're on a system that
 	// has debuginfod so that we can use that in order to find any relevant debug information.
 	if debugFilePath == "" {
-		debugFilePath, err = debuginfod.GetDebuginfo(bi.BuildID)
+		debugFilePath, err = debuginfod.GetDebuginfo(image.buildID)
 		if err != nil {
 			return nil, nil, ErrNoDebugInfoFound
 		}
@@ -1287,46 +1656,64 @@ func loadBinaryInfoElf(bi *BinaryInfo, image *Image, path string, addr uint64, w
 	}
 
 	dwarfFile := elfFile
+	var dwarfFileReader io.ReaderAt = exe
 
 	bi.loadBuildID(image, elfFile)
 	var debugInfoBytes []byte
-	image.dwarf, err = elfFile.DWARF()
+	secs := godwarf.NewElfSections(elfFile, exe)
+	secs.Prefetch(elfDebugSections...)
+	image.dwarf, err = secs.Dwarf()
 	if err != nil {
 		var sepFile *os.File
 		var serr error
 		sepFile, dwarfFile, serr = bi.openSeparateDebugInfo(image, elfFile, bi.debugInfoDirectories)
 		if serr != nil {
+			if serr == ErrNoDebugInfoFound {
+				if bi.loadPclntabElf(image, elfFile, wg) == nil {
+					return nil
+				}
+				if image.index != 0 {
+					bi.loadCFrameInfoElf(image, elfFile)
+				}
+			}
 			return serr
 		}
 		image.sepDebugCloser = sepFile
-		image.dwarf, err = dwarfFile.DWARF()
+		dwarfFileReader = sepFile
+		secs = godwarf.NewElfSections(dwarfFile, sepFile)
+		secs.Prefetch(elfDebugSections...)
+		image.dwarf, err = secs.Dwarf()
 		if err != nil {
 			return err
 		}
 	}
 
-	debugInfoBytes, err = godwarf.GetDebugSectionElf(dwarfFile, "info")
+	debugInfoBytes, err = secs.Get("info")
 	if err != nil {
 		return err
 	}
 
 	image.dwarfReader = image.dwarf.Reader()
 
-	debugLineBytes, err := godwarf.GetDebugSectionElf(dwarfFile, "line")
+	debugLineBytes, err := secs.Get("line")
 	if err != nil {
 		return err
 	}
-	debugLocBytes, _ := godwarf.GetDebugSectionElf(dwarfFile, "loc")
+	debugLocBytes, _ := secs.Get("loc")
 	image.loclist2 = loclist.NewDwarf2Reader(debugLocBytes, bi.Arch.PtrSize())
-	debugLoclistBytes, _ := godwarf.GetDebugSectionElf(dwarfFile, "loclists")
+	debugLoclistBytes, _ := secs.Get("loclists")
 	image.loclist5 = loclist.NewDwarf5Reader(debugLoclistBytes)
-	debugAddrBytes, _ := godwarf.GetDebugSectionElf(dwarfFile, "addr")
+	debugAddrBytes, _ := secs.Get("addr")
 	image.debugAddr = godwarf.ParseAddr(debugAddrBytes)
-	debugLineStrBytes, _ := godwarf.GetDebugSectionElf(dwarfFile, "line_str")
+	debugLineStrBytes, _ := secs.Get("line_str")
 	image.debugLineStr = debugLineStrBytes
+	image.debugAbbrev, _ = secs.Get("abbrev")
+	bi.loadNameIndexElf(image, dwarfFile, dwarfFileReader, secs, debugInfoBytes)
+
+	debugInfoBytes = bi.linkSplitDwarf(image, path, secs, debugInfoBytes, debugLineBytes)
 
 	wg.Add(3)
-	go bi.parseDebugFrameElf(image, dwarfFile, elfFile, debugInfoBytes, wg)
+	go bi.parseDebugFrameElf(image, secs, elfFile, debugInfoBytes, wg)
 	go bi.loadDebugInfoMaps(image, debugInfoBytes, debugLineBytes, wg, nil)
 	go bi.loadSymbolName(image, elfFile, wg)
 	if image.index == 0 {
@@ -1337,15 +1724,192 @@ func loadBinaryInfoElf(bi *BinaryInfo, image *Image, path string, addr uint64, w
 	return nil
 }
 
+// elfDebugSections are the debug sections of an ELF file read while
+// loading it, they are read concurrently, see godwarf.ElfSections.
+var elfDebugSections = []string{"info", "abbrev", "line", "line_str", "str", "str_offsets", "addr", "ranges", "rnglists", "loc", "loclists", "frame", "names"}
+
+// loadCFrameInfoElf loads the .eh_frame section and the function symbols
+// of a shared object that does not have debug info (for example the C
+// library) so that stack traces can be unwound through its frames.
+func (bi *BinaryInfo) loadCFrameInfoElf(image *Image, exe *elf.File) {
+	if ehFrameSection := exe.Section(".eh_frame"); ehFrameSection != nil {
+		ehFrameData, err := ehFrameSection.Data()
+		if err == nil {
+			bi.parseDebugFrameGeneral(image, nil, ".debug_frame", nil, ehFrameData, ehFrameSection.Addr, ".eh_frame", exe.ByteOrder)
+		}
+	}
+
+	syms, err := exe.Symbols()
+	if err != nil || len(syms) == 0 {
+		syms, _ = exe.DynamicSymbols()
+	}
+	for _, sym := range syms {
+		if elf.ST_TYPE(sym.Info) != elf.STT_FUNC || sym.Value == 0 || sym.Size == 0 {
+			continue
+		}
+		image.cSymbols = append(image.cSymbols, cSymbol{Name: sym.Name, Entry: sym.Value + image.StaticBase, End: sym.Value + sym.Size + image.StaticBase})
+	}
+	sort.Slice(image.cSymbols, func(i, j int) bool {
+		return image.cSymbols[i].Entry < image.cSymbols[j].Entry
+	})
+}
+
+// loadPclntabElf loads the functions of image from its pclntab, see
+// loadPclntab.
+func (bi *BinaryInfo) loadPclntabElf(image *Image, exe *elf.File, wg *sync.WaitGroup) error {
+	syms, err := exe.Symbols()
+	if err != nil {
+		return err
+	}
+	symbols := make(map[string]uint64)
+	for _, sym := range syms {
+		if pclntabSymbols[sym.Name] {
+			symbols[sym.Name] = sym.Value
+		}
+	}
+	read := func(addr, size uint64) ([]byte, error) {
+		for _, sec := range exe.Sections {
+			if sec.Flags&elf.SHF_ALLOC == 0 || sec.Type == elf.SHT_NOBITS || addr < sec.Addr || addr+size > sec.Addr+sec.Size {
+				continue
+			}
+			buf := make([]byte, size)
+			_, err := sec.ReadAt(buf, int64(addr-sec.Addr))
+			return buf, err
+		}
+		return nil, fmt.Errorf("address %#x not found in any section", addr)
+	}
+	if err := bi.loadPclntab(image, symbols, read, wg, nil); err != nil {
+		return err
+	}
+	wg.Add(1)
+	go bi.loadSymbolName(image, exe, wg)
+	if image.index == 0 {
+		wg.Add(1)
+		go bi.setGStructOffsetElf(image, exe, wg)
+	}
+	return nil
+}
+
+// loadNameIndexElf loads the accelerator table of image from the
+// debug_names section or, if it is missing, from the .gdb_index section.
+func (bi *BinaryInfo) loadNameIndexElf(image *Image, dwarfFile *elf.File, dwarfFileReader io.ReaderAt, secs *godwarf.ElfSections, debugInfoBytes []byte) {
+	var err error
+	if data, _ := secs.Get("names"); len(data) > 0 {
+		str, _ := secs.Get("str")
+		image.nameIndex, err = godwarf.ParseDebugNames(data, str, debugInfoBytes)
+	} else if sec := dwarfFile.Section(".gdb_index"); sec != nil {
+		var data []byte
+		data, err = godwarf.ElfSectionData(dwarfFile, dwarfFileReader, sec)
+		if err == nil {
+			image.nameIndex, err = godwarf.ParseGdbIndex(data, debugInfoBytes)
+		}
+	}
+	if err != nil {
+		bi.logger.Warnf("could not read accelerator tables of %s: %v", image.Path, err)
+		image.nameIndex = nil
+	}
+}
+
+// linkSplitDwarf links the compile units of image that were compiled with
+// -gsplit-dwarf to their .dwo files, or to the .dwp package next to the
+// executable, so that their variables and types are visible. The full
+// compile units are appended to the debug_info section of image and the
+// new debug_info section is returned, if there are no split compile units
+// debugInfoBytes is returned unchanged.
+func (bi *BinaryInfo) linkSplitDwarf(image *Image, path string, secs *godwarf.ElfSections, debugInfoBytes, debugLineBytes []byte) []byte {
+	section := func(name string) []byte {
+		data, _ := secs.Get(name)
+		return data
+	}
+	sec := &dwo.Sections{
+		Info:       debugInfoBytes,
+		Abbrev:     image.debugAbbrev,
+		Str:        section("str"),
+		StrOffsets: section("str_offsets"),
+		LineStr:    image.debugLineStr,
+		Addr:       section("addr"),
+		Ranges:     section("ranges"),
+		Rnglists:   section("rnglists"),
+		Loc:        section("loc"),
+		Loclists:   section("loclists"),
+	}
+
+	var pkg *dwo.File
+	pkgLoaded := false
+	opened := make(map[string]*dwo.File)
+	find := func(name, compDir string, id uint64) (*dwo.File, error) {
+		if !pkgLoaded {
+			pkg, _ = dwo.Open(path + ".dwp")
+			pkgLoaded = true
+		}
+		if pkg != nil && pkg.Contains(id) {
+			return pkg, nil
+		}
+		candidates := []string{name}
+		if !filepath.IsAbs(name) {
+			candidates = append(candidates, filepath.Join(compDir, name), filepath.Join(filepath.Dir(path), name))
+		}
+		candidates = append(candidates, filepath.Join(filepath.Dir(path), filepath.Base(name)))
+		for _, dir := range bi.debugInfoDirectories {
+			candidates = append(candidates, filepath.Join(dir, filepath.Base(name)))
+		}
+		for _, candidate := range candidates {
+			if f := opened[candidate]; f != nil {
+				return f, nil
+			}
+			if _, err := os.Stat(candidate); err != nil {
+				continue
+			}
+			f, err := dwo.Open(candidate)
+			if err != nil {
+				return nil, err
+			}
+			opened[candidate] = f
+			return f, nil
+		}
+		return nil, fmt.Errorf("could not find %s", name)
+	}
+
+	out, linked, errs := dwo.Link(sec, bi.Arch.PtrSize(), find)
+	for _, err := range errs {
+		bi.logger.Warnf("could not load split debug info of %s: %v", image.Path, err)
+	}
+	if linked == 0 {
+		return debugInfoBytes
+	}
+	d, err := dwarf.New(out.Abbrev, nil, nil, out.Info, debugLineBytes, nil, out.Ranges, out.Str)
+	if err == nil {
+		for name, data := range map[string][]byte{".debug_addr": out.Addr, ".debug_line_str": out.LineStr, ".debug_str_offsets": out.StrOffsets, ".debug_rnglists": out.Rnglists} {
+			if err = d.AddSection(name, data); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		bi.logger.Warnf("could not load split debug info of %s: %v", image.Path, err)
+		return debugInfoBytes
+	}
+	image.dwarf = d
+	image.dwarfReader = d.Reader()
+	image.debugAbbrev = out.Abbrev
+	// the accelerator tables describe the skeleton units, not the linked ones
+	image.nameIndex = nil
+	image.loclist2 = loclist.NewDwarf2Reader(out.Loc, bi.Arch.PtrSize())
+	image.loclist5 = loclist.NewDwarf5Reader(out.Loclists)
+	return out.Info
+}
+
 //  _STT_FUNC is a code object, see /usr/include/elf.h for a full definition.
 const _STT_FUNC = 2
 
 func (bi *BinaryInfo) loadSymbolName(image *Image, file *elf.File, wg *sync.WaitGroup) {
 	defer wg.Done()
+	symSecs, _ := file.Symbols()
+	bi.loadMu.Lock()
+	defer bi.loadMu.Unlock()
 	if bi.SymNames == nil {
 		bi.SymNames = make(map[uint64]*elf.Symbol)
 	}
-	symSecs, _ := file.Symbols()
 	for _, symSec := range symSecs {
 		if symSec.Info == _STT_FUNC { // TODO(chainhelen), need to parse others types.
 			s := symSec
@@ -1384,13 +1948,16 @@ func (bi *BinaryInfo) loadBuildID(image *Image, file *elf.File) {
 		bi.logger.Warnf("can't read build-id desc: %v", err)
 		return
 	}
-	bi.BuildID = hex.EncodeToString(descBinary)
+	image.buildID = hex.EncodeToString(descBinary)
+	if image.index == 0 {
+		bi.BuildID = image.buildID
+	}
 }
 
-func (bi *BinaryInfo) parseDebugFrameElf(image *Image, dwarfFile, exeFile *elf.File, debugInfoBytes []byte, wg *sync.WaitGroup) {
+func (bi *BinaryInfo) parseDebugFrameElf(image *Image, secs *godwarf.ElfSections, exeFile *elf.File, debugInfoBytes []byte, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	debugFrameData, debugFrameErr := godwarf.GetDebugSectionElf(dwarfFile, "frame")
+	debugFrameData, debugFrameErr := secs.Get("frame")
 	ehFrameSection := exeFile.Section(".eh_frame")
 	var ehFrameData []byte
 	var ehFrameAddr uint64
@@ -1451,6 +2018,17 @@ func (bi *BinaryInfo) setGStructOffsetElf(image *Image, exe *elf.File, wg *sync.
 
 		bi.gStructOffset = tlsg.Value + uint64(bi.Arch.PtrSize()*2) + ((tls.Vaddr - uint64(bi.Arch.PtrSize()*2)) & (tls.Align - 1))
 
+	case elf.EM_RISCV, _EM_LOONGARCH:
+		// The pointer to G is always kept in a register (X27 and R22
+		// respectively), runtime.tls_g is only used to save it across cgo
+		// calls. The thread pointer points to the start of the TLS block.
+		tlsg := getSymbol(image, bi.logger, exe, "runtime.tls_g")
+		if tlsg == nil || tls == nil {
+			return
+		}
+
+		bi.gStructOffset = tlsg.Value
+
 	default:
 		// we should never get here
 		panic("architecture not supported")
@@ -1488,14 +2066,6 @@ func loadBinaryInfoPE(bi *BinaryInfo, image *Image, path string, entryPoint uint
 	if !supportedWindowsArch[cpuArch] {
 		return &ErrUnsupportedArch{os: "windows", cpuArch: cpuArch}
 	}
-	image.dwarf, err = peFile.DWARF()
-	if err != nil {
-		return err
-	}
-	debugInfoBytes, err := godwarf.GetDebugSectionPE(peFile, "info")
-	if err != nil {
-		return err
-	}
 
 	//TODO(aarzilli): actually test this when Go supports PIE buildmode on Windows.
 	opth := peFile.OptionalHeader.(*pe.OptionalHeader64)
@@ -1507,6 +2077,23 @@ func loadBinaryInfoPE(bi *BinaryInfo, image *Image, path string, entryPoint uint
 		}
 	}
 
+	// Use ArbitraryUserPointer (0x28) as pointer to pointer
+	// to G struct per:
+	// https://golang.org/src/runtime/cgo/gcc_windows_amd64.c
+	bi.gStructOffset = 0x28
+
+	image.dwarf, err = peFile.DWARF()
+	if err != nil {
+		if bi.loadPclntabPE(image, peFile, opth.ImageBase, wg) == nil {
+			return nil
+		}
+		return err
+	}
+	debugInfoBytes, err := godwarf.GetDebugSectionPE(peFile, "info")
+	if err != nil {
+		return err
+	}
+
 	image.dwarfReader = image.dwarf.Reader()
 
 	debugLineBytes, err := godwarf.GetDebugSectionPE(peFile, "line")
@@ -1521,19 +2108,41 @@ func loadBinaryInfoPE(bi *BinaryInfo, image *Image, path string, entryPoint uint
 	image.debugAddr = godwarf.ParseAddr(debugAddrBytes)
 	debugLineStrBytes, _ := godwarf.GetDebugSectionPE(peFile, "line_str")
 	image.debugLineStr = debugLineStrBytes
+	image.debugAbbrev, _ = godwarf.GetDebugSectionPE(peFile, "abbrev")
+
+	debugInfoBytes, debugLineBytes = bi.loadPDB(image, path, peFile, closer.(io.ReaderAt), debugInfoBytes, debugLineBytes)
 
 	wg.Add(2)
 	go bi.parseDebugFramePE(image, peFile, debugInfoBytes, wg)
 	go bi.loadDebugInfoMaps(image, debugInfoBytes, debugLineBytes, wg, nil)
-
-	// Use ArbitraryUserPointer (0x28) as pointer to pointer
-	// to G struct per:
-	// https://golang.org/src/runtime/cgo/gcc_windows_amd64.c
-
-	bi.gStructOffset = 0x28
 	return nil
 }
 
+// loadPclntabPE loads the functions of image from its pclntab, see
+// loadPclntab.
+func (bi *BinaryInfo) loadPclntabPE(image *Image, exe *pe.File, imageBase uint64, wg *sync.WaitGroup) error {
+	symbols := make(map[string]uint64)
+	for _, sym := range exe.Symbols {
+		if !pclntabSymbols[sym.Name] || sym.SectionNumber <= 0 || int(sym.SectionNumber) > len(exe.Sections) {
+			continue
+		}
+		symbols[sym.Name] = imageBase + uint64(exe.Sections[sym.SectionNumber-1].VirtualAddress) + uint64(sym.Value)
+	}
+	read := func(addr, size uint64) ([]byte, error) {
+		for _, sec := range exe.Sections {
+			start := imageBase + uint64(sec.VirtualAddress)
+			if addr < start || addr+size > start+uint64(sec.Size) {
+				continue
+			}
+			buf := make([]byte, size)
+			_, err := sec.ReadAt(buf, int64(addr-start))
+			return buf, err
+		}
+		return nil, fmt.Errorf("address %#x not found in any section", addr)
+	}
+	return bi.loadPclntab(image, symbols, read, wg, nil)
+}
+
 func openExecutablePathPE(path string) (*pe.File, io.Closer, error) {
 	f, err := os.OpenFile(path, 0, os.ModePerm)
 	if err != nil {
@@ -1547,18 +2156,112 @@ func openExecutablePathPE(path string) (*pe.File, io.Closer, error) {
 	return peFile, f, nil
 }
 
-func (bi *BinaryInfo) parseDebugFramePE(image *Image, exe *pe.File, debugInfoBytes []byte, wg *sync.WaitGroup) {
-	defer wg.Done()
-
-	debugFrameBytes, err := godwarf.GetDebugSectionPE(exe, "frame")
-	bi.parseDebugFrameGeneral(image, debugFrameBytes, ".debug_frame", err, nil, 0, "", frame.DwarfEndian(debugInfoBytes))
-}
-
-// MACH-O ////////////////////////////////////////////////////////////
+// loadPDB loads the PDB file of the executable, if there is one, adding
+// compile units describing the code it contains to the debug info of
+// image. This is used to symbolicate code compiled by MSVC, which does
+// not produce DWARF.
+// The PDB file is searched at the path recorded in the executable, next
+// to the executable and in the debug-info-directories config value.
+// The (possibly new) contents of .debug_info and .debug_line are
+// returned.
+func (bi *BinaryInfo) loadPDB(image *Image, path string, exe *pe.File, r io.ReaderAt, debugInfoBytes, debugLineBytes []byte) ([]byte, []byte) {
+	cv := pdb.CodeView(exe, r)
+	var candidates []string
+	if cv != nil {
+		candidates = append(candidates, cv.Path)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + ".pdb"
+	if cv != nil {
+		// the recorded path is a Windows path
+		if i := strings.LastIndexAny(cv.Path, `\/`); i >= 0 {
+			name = cv.Path[i+1:]
+		} else if cv.Path != "" {
+			name = cv.Path
+		}
+	}
+	candidates = append(candidates, filepath.Join(filepath.Dir(path), name))
+	for _, dir := range bi.debugInfoDirectories {
+		candidates = append(candidates, filepath.Join(dir, name))
+	}
+
+	var f *pdb.File
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err != nil {
+			continue
+		}
+		pf, err := pdb.Open(candidate)
+		if err != nil {
+			bi.logger.Warnf("could not load PDB file %s: %v", candidate, err)
+			continue
+		}
+		if cv != nil && !pf.Matches(cv) {
+			bi.logger.Debugf("PDB file %s does not match %s", candidate, image.Path)
+			continue
+		}
+		bi.logger.Debugf("loading debug symbols of %s from %s", image.Path, candidate)
+		f = pf
+		break
+	}
+	if f == nil {
+		return debugInfoBytes, debugLineBytes
+	}
 
-// loadBinaryInfoMacho specifically loads information from a Mach-O binary.
-func loadBinaryInfoMacho(bi *BinaryInfo, image *Image, path string, entryPoint uint64, wg *sync.WaitGroup) error {
-	exe, err := macho.Open(path)
+	sections := make([]uint32, len(exe.Sections))
+	for i, sec := range exe.Sections {
+		sections[i] = sec.VirtualAddress
+	}
+	var imageBase uint64
+	switch opth := exe.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		imageBase = uint64(opth.ImageBase)
+	case *pe.OptionalHeader64:
+		imageBase = opth.ImageBase
+	}
+
+	section := func(name string) []byte {
+		data, _ := godwarf.GetDebugSectionPE(exe, name)
+		return data
+	}
+	sec := &pdb.Sections{
+		Info:   debugInfoBytes,
+		Abbrev: image.debugAbbrev,
+		Line:   debugLineBytes,
+		Ranges: section("ranges"),
+	}
+	out, n := f.AppendDWARF(sec, imageBase, sections)
+	if n == 0 {
+		return debugInfoBytes, debugLineBytes
+	}
+	d, err := dwarf.New(out.Abbrev, nil, nil, out.Info, out.Line, nil, out.Ranges, section("str"))
+	if err == nil {
+		for name, data := range map[string][]byte{".debug_addr": section("addr"), ".debug_line_str": image.debugLineStr, ".debug_str_offsets": section("str_offsets"), ".debug_rnglists": section("rnglists")} {
+			if err = d.AddSection(name, data); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		bi.logger.Warnf("could not load PDB file of %s: %v", image.Path, err)
+		return debugInfoBytes, debugLineBytes
+	}
+	image.dwarf = d
+	image.dwarfReader = d.Reader()
+	image.debugAbbrev = out.Abbrev
+	return out.Info, out.Line
+}
+
+func (bi *BinaryInfo) parseDebugFramePE(image *Image, exe *pe.File, debugInfoBytes []byte, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	debugFrameBytes, err := godwarf.GetDebugSectionPE(exe, "frame")
+	bi.parseDebugFrameGeneral(image, debugFrameBytes, ".debug_frame", err, nil, 0, "", frame.DwarfEndian(debugInfoBytes))
+}
+
+// MACH-O ////////////////////////////////////////////////////////////
+
+// loadBinaryInfoMacho specifically loads information from a Mach-O binary.
+func loadBinaryInfoMacho(bi *BinaryInfo, image *Image, path string, entryPoint uint64, wg *sync.WaitGroup) error {
+	exe, err := macho.Open(path)
 
 	if err != nil {
 		return err
@@ -1576,36 +2279,181 @@ func loadBinaryInfoMacho(bi *BinaryInfo, image *Image, path string, entryPoint u
 	if !supportedDarwinArch[exe.Cpu] {
 		return &ErrUnsupportedArch{os: "darwin", cpuArch: exe.Cpu}
 	}
+	dwarfFile := exe
 	image.dwarf, err = exe.DWARF()
 	if err != nil {
-		return err
+		sepFile, closer, serr := bi.openDsym(image, exe, path)
+		if serr != nil {
+			if serr != ErrNoDebugInfoFound {
+				return serr
+			}
+			if bi.loadPclntabMacho(image, exe, wg) == nil {
+				return nil
+			}
+			return err
+		}
+		image.sepDebugCloser = closer
+		dwarfFile = sepFile
+		image.dwarf, err = dwarfFile.DWARF()
+		if err != nil {
+			return err
+		}
 	}
-	debugInfoBytes, err := godwarf.GetDebugSectionMacho(exe, "info")
+	debugInfoBytes, err := godwarf.GetDebugSectionMacho(dwarfFile, "info")
 	if err != nil {
 		return err
 	}
 
 	image.dwarfReader = image.dwarf.Reader()
 
-	debugLineBytes, err := godwarf.GetDebugSectionMacho(exe, "line")
+	debugLineBytes, err := godwarf.GetDebugSectionMacho(dwarfFile, "line")
 	if err != nil {
 		return err
 	}
-	debugLocBytes, _ := godwarf.GetDebugSectionMacho(exe, "loc")
+	debugLocBytes, _ := godwarf.GetDebugSectionMacho(dwarfFile, "loc")
 	image.loclist2 = loclist.NewDwarf2Reader(debugLocBytes, bi.Arch.PtrSize())
-	debugLoclistBytes, _ := godwarf.GetDebugSectionMacho(exe, "loclists")
+	debugLoclistBytes, _ := godwarf.GetDebugSectionMacho(dwarfFile, "loclists")
 	image.loclist5 = loclist.NewDwarf5Reader(debugLoclistBytes)
-	debugAddrBytes, _ := godwarf.GetDebugSectionMacho(exe, "addr")
+	debugAddrBytes, _ := godwarf.GetDebugSectionMacho(dwarfFile, "addr")
 	image.debugAddr = godwarf.ParseAddr(debugAddrBytes)
-	debugLineStrBytes, _ := godwarf.GetDebugSectionMacho(exe, "line_str")
+	debugLineStrBytes, _ := godwarf.GetDebugSectionMacho(dwarfFile, "line_str")
 	image.debugLineStr = debugLineStrBytes
+	image.debugAbbrev, _ = godwarf.GetDebugSectionMacho(dwarfFile, "abbrev")
 
 	wg.Add(2)
-	go bi.parseDebugFrameMacho(image, exe, debugInfoBytes, wg)
+	go bi.parseDebugFrameMacho(image, dwarfFile, exe, debugInfoBytes, wg)
 	go bi.loadDebugInfoMaps(image, debugInfoBytes, debugLineBytes, wg, bi.setGStructOffsetMacho)
 	return nil
 }
 
+// loadPclntabMacho loads the functions of image from its pclntab, see
+// loadPclntab.
+func (bi *BinaryInfo) loadPclntabMacho(image *Image, exe *macho.File, wg *sync.WaitGroup) error {
+	if exe.Symtab == nil {
+		return ErrNoDebugInfoFound
+	}
+	symbols := make(map[string]uint64)
+	for _, sym := range exe.Symtab.Syms {
+		if pclntabSymbols[sym.Name] {
+			symbols[sym.Name] = sym.Value
+		}
+	}
+	read := func(addr, size uint64) ([]byte, error) {
+		const sZerofill = 0x1
+		for _, sec := range exe.Sections {
+			if sec.Flags&0xff == sZerofill || addr < sec.Addr || addr+size > sec.Addr+sec.Size {
+				continue
+			}
+			buf := make([]byte, size)
+			_, err := sec.ReadAt(buf, int64(addr-sec.Addr))
+			return buf, err
+		}
+		return nil, fmt.Errorf("address %#x not found in any section", addr)
+	}
+	return bi.loadPclntab(image, symbols, read, wg, bi.setGStructOffsetMacho)
+}
+
+// openDsym searches for the .dSYM bundle containing the debug symbols of
+// the Mach-O file exe, loaded from path, and returns the Mach-O file in it
+// with the same UUID and architecture as exe, along with the io.Closer
+// that must be used to close it.
+// The bundle is searched next to path (or next to the .app or .framework
+// bundle containing path), in the directories specified by the
+// debug-info-directories config value and, finally, using Spotlight, like
+// lldb does.
+// If no bundle is found ErrNoDebugInfoFound is returned.
+func (bi *BinaryInfo) openDsym(image *Image, exe *macho.File, path string) (*macho.File, io.Closer, error) {
+	uuid := machoUUID(exe)
+
+	bundles := []string{path + ".dSYM"}
+	names := []string{filepath.Base(path)}
+	for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if ext := filepath.Ext(dir); ext == ".app" || ext == ".framework" {
+			bundles = append(bundles, dir+".dSYM")
+			names = append(names, filepath.Base(dir))
+		}
+	}
+	debugInfoDirectories := bi.debugInfoDirectories
+	if bi.rootDir != "" {
+		rooted := make([]string, 0, 2*len(debugInfoDirectories))
+		for _, dir := range debugInfoDirectories {
+			rooted = append(rooted, filepath.Join(bi.rootDir, dir))
+		}
+		debugInfoDirectories = append(rooted, debugInfoDirectories...)
+	}
+	for _, dir := range debugInfoDirectories {
+		for _, name := range names {
+			bundles = append(bundles, filepath.Join(dir, name+".dSYM"))
+		}
+	}
+	if uuid != "" {
+		found, err := macutil.SpotlightDsyms(uuid)
+		if err == nil {
+			bundles = append(bundles, found...)
+		}
+	}
+
+	for _, bundle := range bundles {
+		dwarfDir := filepath.Join(bundle, "Contents", "Resources", "DWARF")
+		fis, err := os.ReadDir(dwarfDir)
+		if err != nil {
+			continue
+		}
+		for _, fi := range fis {
+			f, closer, err := openMachoArch(filepath.Join(dwarfDir, fi.Name()), exe.Cpu)
+			if err != nil {
+				continue
+			}
+			if machoUUID(f) != uuid {
+				closer.Close()
+				continue
+			}
+			bi.logger.Debugf("loading debug symbols of %s from %s", image.Path, bundle)
+			return f, closer, nil
+		}
+	}
+	return nil, nil, ErrNoDebugInfoFound
+}
+
+// openMachoArch opens the Mach-O file at path, if it is a universal binary
+// the file for the specified architecture is returned.
+func openMachoArch(path string, cpu macho.Cpu) (*macho.File, io.Closer, error) {
+	if f, err := macho.Open(path); err == nil {
+		if f.Cpu != cpu {
+			f.Close()
+			return nil, nil, fmt.Errorf("%s: wrong architecture %v", path, f.Cpu)
+		}
+		return f, f, nil
+	}
+	fat, err := macho.OpenFat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, arch := range fat.Arches {
+		if arch.Cpu == cpu {
+			return arch.File, fat, nil
+		}
+	}
+	fat.Close()
+	return nil, nil, fmt.Errorf("%s: no %v architecture", path, cpu)
+}
+
+// machoUUID returns the UUID contained in the LC_UUID load command of f,
+// formatted like Spotlight does, or the empty string if f does not have an
+// UUID.
+func machoUUID(f *macho.File) string {
+	const loadCmdUUID = 0x1b // LC_UUID
+	for _, l := range f.Loads {
+		raw := l.Raw()
+		if len(raw) < 24 || macho.LoadCmd(f.ByteOrder.Uint32(raw)) != loadCmdUUID {
+			continue
+		}
+		u := raw[8:24]
+		return strings.ToUpper(fmt.Sprintf("%x-%x-%x-%x-%x", u[:4], u[4:6], u[6:8], u[8:10], u[10:]))
+	}
+	return ""
+}
+
 func (bi *BinaryInfo) setGStructOffsetMacho() {
 	// In go1.11 it's 0x30, before 0x8a0, see:
 	// https://github.com/golang/go/issues/23617
@@ -1618,10 +2466,10 @@ func (bi *BinaryInfo) setGStructOffsetMacho() {
 	bi.gStructOffset = 0x8a0
 }
 
-func (bi *BinaryInfo) parseDebugFrameMacho(image *Image, exe *macho.File, debugInfoBytes []byte, wg *sync.WaitGroup) {
+func (bi *BinaryInfo) parseDebugFrameMacho(image *Image, dwarfFile, exe *macho.File, debugInfoBytes []byte, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	debugFrameBytes, debugFrameErr := godwarf.GetDebugSectionMacho(exe, "frame")
+	debugFrameBytes, debugFrameErr := godwarf.GetDebugSectionMacho(dwarfFile, "frame")
 	ehFrameSection := exe.Section("__eh_frame")
 	var ehFrameBytes []byte
 	var ehFrameAddr uint64
@@ -1713,6 +2561,13 @@ func (bi *BinaryInfo) macOSDebugFrameBugWorkaround() {
 
 // Do not call this function directly it isn't able to deal correctly with package paths
 func (bi *BinaryInfo) findType(name string) (godwarf.Type, error) {
+	bi.loadDeferredTypeEntries()
+	return bi.findLoadedType(name)
+}
+
+// findLoadedType is like findType but does not load deferred types, it can
+// be called while holding deferredMu.
+func (bi *BinaryInfo) findLoadedType(name string) (godwarf.Type, error) {
 	ref, found := bi.types[name]
 	if !found {
 		return nil, reader.ErrTypeNotFound
@@ -1818,11 +2673,73 @@ func (bi *BinaryInfo) registerTypeToPackageMap(entry *dwarf.Entry) {
 	bi.PackageMap[name] = []string{path}
 }
 
+// compileUnitLoad holds the results of the parts of the loading of a
+// compile unit that do not modify BinaryInfo, which loadDebugInfoMaps
+// executes concurrently for all compile units, see loadCompileUnitData.
+type compileUnitLoad struct {
+	cu             *compileUnit
+	compdir        string
+	lineInfoOffset int64
+	hasLineInfo    bool
+	gopkg          string
+	regabi         bool
+
+	// lazy is true if the compile unit can be loaded lazily, in that case
+	// subprograms are its subprogram entries and deferredTypes the offsets
+	// of its type, variable and constant entries, see loadDebugInfoMapsLazy.
+	lazy                bool
+	subprograms         []*dwarf.Entry
+	deferredTypes       []dwarf.Offset
+	deferredTypeEntries bool
+	err                 error
+
+	done chan struct{}
+}
+
 func (bi *BinaryInfo) loadDebugInfoMaps(image *Image, debugInfoBytes, debugLineBytes []byte, wg *sync.WaitGroup, cont func()) {
 	if wg != nil {
 		defer wg.Done()
 	}
 
+	image.runtimeTypeToDIE = make(map[uint64]runtimeTypeDIE)
+
+	offsetToVersion := util.ReadUnitVersions(debugInfoBytes)
+
+	var scanner *godwarf.UnitScanner
+	if debugInfoBytes != nil && image.debugAbbrev != nil {
+		scanner = godwarf.NewUnitScanner(debugInfoBytes, image.debugAbbrev)
+	}
+
+	// The root entries of the compile units are read first, then their line
+	// tables and, for the compile units that can be loaded lazily, their
+	// children are read by a pool of workers while the compile units are
+	// added to bi, in order.
+	loads := bi.readCompileUnits(image, scanner, offsetToVersion)
+
+	work := make(chan *compileUnitLoad)
+	nworkers := runtime.GOMAXPROCS(0)
+	if nworkers > len(loads) {
+		nworkers = len(loads)
+	}
+	for w := 0; w < nworkers; w++ {
+		go func() {
+			reader := image.DwarfReader()
+			for load := range work {
+				bi.loadCompileUnitData(image, scanner, reader, debugLineBytes, load)
+				close(load.done)
+			}
+		}()
+	}
+	go func() {
+		defer close(work)
+		for _, load := range loads {
+			work <- load
+		}
+	}()
+
+	bi.loadMu.Lock()
+	defer bi.loadMu.Unlock()
+
 	if bi.types == nil {
 		bi.types = make(map[string]dwarfRef)
 	}
@@ -1839,88 +2756,33 @@ func (bi *BinaryInfo) loadDebugInfoMaps(image *Image, debugInfoBytes, debugLineB
 		bi.dwrapUnwrapCache = make(map[uint64]*Function)
 	}
 
-	image.runtimeTypeToDIE = make(map[uint64]runtimeTypeDIE)
-
-	ctxt := newLoadDebugInfoMapsContext(bi, image, util.ReadUnitVersions(debugInfoBytes))
+	ctxt := newLoadDebugInfoMapsContext(bi, image, offsetToVersion)
 
 	reader := image.DwarfReader()
 
-	for {
-		entry, err := reader.Next()
-		if err != nil {
-			image.setLoadError(bi.logger, "error reading debug_info: %v", err)
-			break
+	for _, load := range loads {
+		<-load.done
+		cu := load.cu
+		if load.regabi {
+			bi.regabi = true
 		}
-		if entry == nil {
-			break
+		if load.gopkg != "" {
+			bi.PackageMap[load.gopkg] = append(bi.PackageMap[load.gopkg], escapePackagePath(strings.Replace(cu.name, "\\", "/", -1)))
 		}
-		switch entry.Tag {
-		case dwarf.TagCompileUnit:
-			cu := &compileUnit{}
-			cu.image = image
-			cu.entry = entry
-			cu.offset = entry.Offset
-			cu.Version = ctxt.offsetToVersion[cu.offset]
-			if lang, _ := entry.Val(dwarf.AttrLanguage).(int64); lang == dwarfGoLanguage {
-				cu.isgo = true
-			}
-			cu.name, _ = entry.Val(dwarf.AttrName).(string)
-			compdir, _ := entry.Val(dwarf.AttrCompDir).(string)
-			if compdir != "" {
-				cu.name = filepath.Join(compdir, cu.name)
-			}
-			cu.ranges, _ = image.dwarf.Ranges(entry)
-			for i := range cu.ranges {
-				cu.ranges[i][0] += image.StaticBase
-				cu.ranges[i][1] += image.StaticBase
-			}
-			if len(cu.ranges) >= 1 {
-				cu.lowPC = cu.ranges[0][0]
-			}
-			lineInfoOffset, hasLineInfo := entry.Val(dwarf.AttrStmtList).(int64)
-			if hasLineInfo && lineInfoOffset >= 0 && lineInfoOffset < int64(len(debugLineBytes)) {
-				var logfn func(string, ...interface{})
-				if logflags.DebugLineErrors() {
-					logger := logrus.New().WithFields(logrus.Fields{"layer": "dwarf-line"})
-					logger.Logger.Level = logrus.DebugLevel
-					logfn = func(fmt string, args ...interface{}) {
-						logger.Printf(fmt, args)
-					}
-				}
-				cu.lineInfo = line.Parse(compdir, bytes.NewBuffer(debugLineBytes[lineInfoOffset:]), image.debugLineStr, logfn, image.StaticBase, bi.GOOS == "windows", bi.Arch.PtrSize())
-			}
-			cu.producer, _ = entry.Val(dwarf.AttrProducer).(string)
-			if cu.isgo && cu.producer != "" {
-				semicolon := strings.Index(cu.producer, ";")
-				if semicolon < 0 {
-					cu.optimized = goversion.ProducerAfterOrEqual(cu.producer, 1, 10)
-				} else {
-					cu.optimized = !strings.Contains(cu.producer[semicolon:], "-N") || !strings.Contains(cu.producer[semicolon:], "-l")
-					const regabi = " regabi"
-					if i := strings.Index(cu.producer[semicolon:], regabi); i > 0 {
-						i += semicolon
-						if i+len(regabi) >= len(cu.producer) || cu.producer[i+len(regabi)] == ' ' {
-							bi.regabi = true
-						}
-					}
-					cu.producer = cu.producer[:semicolon]
-				}
-			}
-			gopkg, _ := entry.Val(godwarf.AttrGoPackageName).(string)
-			if cu.isgo && gopkg != "" {
-				bi.PackageMap[gopkg] = append(bi.PackageMap[gopkg], escapePackagePath(strings.Replace(cu.name, "\\", "/", -1)))
-			}
-			image.compileUnits = append(image.compileUnits, cu)
-			if entry.Children {
-				bi.loadDebugInfoMapsCompileUnit(ctxt, image, reader, cu)
+		if !cu.entry.Children {
+			continue
+		}
+		if idx := image.nameIndex; !cu.isgo && idx != nil && idx.Covers(cu.offset) {
+			bi.loadDebugInfoMapsIndexed(ctxt, image, cu)
+		} else if load.lazy {
+			bi.loadDebugInfoMapsLazy(ctxt, reader, image, load)
+		} else {
+			reader.Seek(cu.offset)
+			if _, err := reader.Next(); err != nil {
+				image.setLoadError(bi.logger, "error reading debug_info: %v", err)
+				continue
 			}
-
-		case dwarf.TagPartialUnit:
-			reader.SkipChildren()
-
-		default:
-			// ignore unknown tags
-			reader.SkipChildren()
+			bi.loadDebugInfoMapsCompileUnit(ctxt, image, reader, cu)
 		}
 	}
 
@@ -1944,24 +2806,178 @@ func (bi *BinaryInfo) loadDebugInfoMaps(image *Image, debugInfoBytes, debugLineB
 	sort.Strings(bi.Sources)
 	bi.Sources = uniq(bi.Sources)
 
-	if bi.regabi {
-		// prepare patch for runtime.mallocgc's DIE
-		fn := bi.LookupFunc["runtime.mallocgc"]
-		if fn != nil && fn.cu.image == image {
-			tree, err := image.getDwarfTree(fn.offset)
-			if err == nil {
-				tree.Children, err = regabiMallocgcWorkaround(bi)
-				if err != nil {
-					bi.logger.Errorf("could not patch runtime.mallogc: %v", err)
-				} else {
-					image.runtimeMallocgcTree = tree
+	if !bi.hasDeferredTypes(image) {
+		bi.patchRuntimeMallocgc(image)
+	}
+
+	if cont != nil {
+		cont()
+	}
+}
+
+// readCompileUnits reads the root entries of the compile units of image,
+// adding them to image.compileUnits. If scanner is not nil it is used to
+// find the next compile unit instead of reading the children of each one.
+func (bi *BinaryInfo) readCompileUnits(image *Image, scanner *godwarf.UnitScanner, offsetToVersion map[dwarf.Offset]uint8) []*compileUnitLoad {
+	var loads []*compileUnitLoad
+	reader := image.DwarfReader()
+	for {
+		entry, err := reader.Next()
+		if err != nil {
+			image.setLoadError(bi.logger, "error reading debug_info: %v", err)
+			break
+		}
+		if entry == nil {
+			break
+		}
+		if entry.Tag == dwarf.TagCompileUnit {
+			loads = append(loads, bi.readCompileUnit(image, scanner, entry, offsetToVersion))
+		}
+		if !entry.Children {
+			continue
+		}
+		if scanner != nil {
+			if next, ok := scanner.NextUnit(entry.Offset); ok {
+				if next == 0 {
+					break
 				}
+				reader.Seek(next)
+				continue
 			}
 		}
+		reader.SkipChildren()
+	}
+	return loads
+}
+
+// readCompileUnit creates the compile unit described by entry.
+func (bi *BinaryInfo) readCompileUnit(image *Image, scanner *godwarf.UnitScanner, entry *dwarf.Entry, offsetToVersion map[dwarf.Offset]uint8) *compileUnitLoad {
+	cu := &compileUnit{}
+	load := &compileUnitLoad{cu: cu, done: make(chan struct{})}
+	cu.image = image
+	cu.entry = entry
+	cu.offset = entry.Offset
+	cu.Version = offsetToVersion[cu.offset]
+	if lang, _ := entry.Val(dwarf.AttrLanguage).(int64); lang == dwarfGoLanguage {
+		cu.isgo = true
+	}
+	cu.name, _ = entry.Val(dwarf.AttrName).(string)
+	load.compdir, _ = entry.Val(dwarf.AttrCompDir).(string)
+	if load.compdir != "" {
+		cu.name = filepath.Join(load.compdir, cu.name)
+	}
+	cu.ranges, _ = image.dwarf.Ranges(entry)
+	for i := range cu.ranges {
+		cu.ranges[i][0] += image.StaticBase
+		cu.ranges[i][1] += image.StaticBase
+	}
+	if len(cu.ranges) >= 1 {
+		cu.lowPC = cu.ranges[0][0]
+	}
+	load.lineInfoOffset, load.hasLineInfo = entry.Val(dwarf.AttrStmtList).(int64)
+	cu.producer, _ = entry.Val(dwarf.AttrProducer).(string)
+	if cu.isgo && cu.producer != "" {
+		semicolon := strings.Index(cu.producer, ";")
+		if semicolon < 0 {
+			cu.optimized = goversion.ProducerAfterOrEqual(cu.producer, 1, 10)
+		} else {
+			cu.optimized = !strings.Contains(cu.producer[semicolon:], "-N") || !strings.Contains(cu.producer[semicolon:], "-l")
+			const regabi = " regabi"
+			if i := strings.Index(cu.producer[semicolon:], regabi); i > 0 {
+				i += semicolon
+				if i+len(regabi) >= len(cu.producer) || cu.producer[i+len(regabi)] == ' ' {
+					load.regabi = true
+				}
+			}
+			cu.producer = cu.producer[:semicolon]
+		}
+	}
+	if cu.isgo {
+		load.gopkg, _ = entry.Val(godwarf.AttrGoPackageName).(string)
+	}
+	// before Go 1.13 the package names are determined from the names of the
+	// types, see registerTypeToPackageMap, so the types can not be loaded
+	// lazily.
+	load.lazy = scanner != nil && entry.Children && cu.isgo && goversion.ProducerAfterOrEqual(cu.producer, 1, 13)
+	image.compileUnits = append(image.compileUnits, cu)
+	return load
+}
+
+// loadCompileUnitData parses the line table of a compile unit and, if it
+// can be loaded lazily, lists its children with scanner and reads its
+// subprogram entries with reader. It does not modify bi and can be called
+// concurrently for different compile units.
+func (bi *BinaryInfo) loadCompileUnitData(image *Image, scanner *godwarf.UnitScanner, reader *reader.Reader, debugLineBytes []byte, load *compileUnitLoad) {
+	cu := load.cu
+	if load.hasLineInfo && load.lineInfoOffset >= 0 && load.lineInfoOffset < int64(len(debugLineBytes)) {
+		var logfn func(string, ...interface{})
+		if logflags.DebugLineErrors() {
+			logger := logrus.New().WithFields(logrus.Fields{"layer": "dwarf-line"})
+			logger.Logger.Level = logrus.DebugLevel
+			logfn = func(fmt string, args ...interface{}) {
+				logger.Printf(fmt, args...)
+			}
+		}
+		cu.lineInfo = line.Parse(load.compdir, bytes.NewBuffer(debugLineBytes[load.lineInfoOffset:]), image.debugLineStr, logfn, image.StaticBase, bi.GOOS == "windows", bi.Arch.PtrSize())
 	}
 
-	if cont != nil {
-		cont()
+	if !load.lazy {
+		return
+	}
+	children, _, err := scanner.Children(cu.offset)
+	if err != nil {
+		bi.logger.Debugf("could not scan compile unit %s: %v", cu.name, err)
+		load.lazy = false
+		return
+	}
+	for _, child := range children {
+		switch child.Tag {
+		case dwarf.TagSubprogram, dwarf.TagVariable, dwarf.TagConstant, dwarf.TagArrayType, dwarf.TagBaseType, dwarf.TagClassType, dwarf.TagStructType, dwarf.TagUnionType, dwarf.TagConstType, dwarf.TagVolatileType, dwarf.TagRestrictType, dwarf.TagEnumerationType, dwarf.TagPointerType, dwarf.TagSubroutineType, dwarf.TagTypedef, dwarf.TagUnspecifiedType:
+			// ok
+		default:
+			if child.Children {
+				load.lazy = false
+				return
+			}
+		}
+	}
+
+	for _, child := range children {
+		switch child.Tag {
+		case dwarf.TagSubprogram:
+			reader.Seek(child.Offset)
+			entry, err := reader.Next()
+			if err != nil {
+				load.err = err
+				return
+			}
+			load.subprograms = append(load.subprograms, entry)
+		case dwarf.TagVariable, dwarf.TagConstant:
+			load.deferredTypes = append(load.deferredTypes, child.Offset)
+		case dwarf.TagArrayType, dwarf.TagBaseType, dwarf.TagClassType, dwarf.TagStructType, dwarf.TagUnionType, dwarf.TagConstType, dwarf.TagVolatileType, dwarf.TagRestrictType, dwarf.TagEnumerationType, dwarf.TagPointerType, dwarf.TagSubroutineType, dwarf.TagTypedef, dwarf.TagUnspecifiedType:
+			load.deferredTypes = append(load.deferredTypes, child.Offset)
+			load.deferredTypeEntries = true
+		}
+	}
+}
+
+// patchRuntimeMallocgc prepares the patch for runtime.mallocgc's DIE, see
+// regabiMallocgcWorkaround. The types of image must already be loaded.
+func (bi *BinaryInfo) patchRuntimeMallocgc(image *Image) {
+	if !bi.regabi || image.symbolsOnly {
+		return
+	}
+	fn := bi.LookupFunc["runtime.mallocgc"]
+	if fn != nil && fn.cu.image == image {
+		tree, err := image.getDwarfTree(fn.offset)
+		if err == nil {
+			tree.Children, err = regabiMallocgcWorkaround(bi)
+			if err != nil {
+				bi.logger.Errorf("could not patch runtime.mallogc: %v", err)
+			} else {
+				image.runtimeMallocgcTree = tree
+			}
+		}
 	}
 }
 
@@ -2010,70 +3026,22 @@ func (bi *BinaryInfo) loadDebugInfoMapsCompileUnit(ctxt *loadDebugInfoMapsContex
 			reader.SkipChildren()
 
 		case dwarf.TagArrayType, dwarf.TagBaseType, dwarf.TagClassType, dwarf.TagStructType, dwarf.TagUnionType, dwarf.TagConstType, dwarf.TagVolatileType, dwarf.TagRestrictType, dwarf.TagEnumerationType, dwarf.TagPointerType, dwarf.TagSubroutineType, dwarf.TagTypedef, dwarf.TagUnspecifiedType:
-			if name, ok := entry.Val(dwarf.AttrName).(string); ok {
-				if !cu.isgo {
-					name = "C." + name
-				}
-				if _, exists := bi.types[name]; !exists {
-					bi.types[name] = dwarfRef{image.index, entry.Offset}
-				}
-			}
+			bi.addType(entry, ctxt, image, cu)
 			if cu != nil && cu.isgo && !hasAttrGoPkgName {
 				bi.registerTypeToPackageMap(entry)
 			}
-			image.registerRuntimeTypeToDIE(entry, ctxt.ardr)
 			reader.SkipChildren()
 
 		case dwarf.TagVariable:
-			if n, ok := entry.Val(dwarf.AttrName).(string); ok {
-				var addr uint64
-				if loc, ok := entry.Val(dwarf.AttrLocation).([]byte); ok {
-					if len(loc) == bi.Arch.PtrSize()+1 && op.Opcode(loc[0]) == op.DW_OP_addr {
-						addr, _ = util.ReadUintRaw(bytes.NewReader(loc[1:]), binary.LittleEndian, bi.Arch.PtrSize())
-					}
-				}
-				if !cu.isgo {
-					n = "C." + n
-				}
-				if _, known := ctxt.knownPackageVars[n]; !known {
-					bi.packageVars = append(bi.packageVars, packageVar{n, cu, entry.Offset, addr + image.StaticBase})
-				}
-			}
+			bi.addPackageVar(entry, ctxt, image, cu)
 			reader.SkipChildren()
 
 		case dwarf.TagConstant:
-			name, okName := entry.Val(dwarf.AttrName).(string)
-			typ, okType := entry.Val(dwarf.AttrType).(dwarf.Offset)
-			val, okVal := entry.Val(dwarf.AttrConstValue).(int64)
-			if okName && okType && okVal {
-				if !cu.isgo {
-					name = "C." + name
-				}
-				ct := bi.consts[dwarfRef{image.index, typ}]
-				if ct == nil {
-					ct = &constantType{}
-					bi.consts[dwarfRef{image.index, typ}] = ct
-				}
-				ct.values = append(ct.values, constantValue{name: name, fullName: name, value: val})
-			}
+			bi.addConstant(entry, image, cu)
 			reader.SkipChildren()
 
 		case dwarf.TagSubprogram:
-			inlined := false
-			if inval, ok := entry.Val(dwarf.AttrInline).(int64); ok {
-				inlined = inval >= 1
-			}
-
-			if inlined {
-				bi.addAbstractSubprogram(entry, ctxt, reader, image, cu)
-			} else {
-				originOffset, hasAbstractOrigin := entry.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset)
-				if hasAbstractOrigin {
-					bi.addConcreteInlinedSubprogram(entry, originOffset, ctxt, reader, cu)
-				} else {
-					bi.addConcreteSubprogram(entry, ctxt, reader, cu)
-				}
-			}
+			bi.addSubprogram(entry, ctxt, reader, image, cu)
 
 		default:
 			if entry.Children {
@@ -2083,6 +3051,297 @@ func (bi *BinaryInfo) loadDebugInfoMapsCompileUnit(ctxt *loadDebugInfoMapsContex
 	}
 }
 
+// loadDebugInfoMapsIndexed loads the functions, variables and types of a
+// compile unit from the entries of the accelerator table of image, instead
+// of reading all its debugging information entries.
+// If the accelerator table does not record the offset of the entries
+// (.gdb_index) the compile unit is read normally, unless the table has no
+// entries for it.
+func (bi *BinaryInfo) loadDebugInfoMapsIndexed(ctxt *loadDebugInfoMapsContext, image *Image, cu *compileUnit) {
+	entries := image.nameIndex.Unit(cu.offset)
+	if len(entries) == 0 {
+		return
+	}
+	reader := image.DwarfReader()
+	if !image.nameIndex.HasOffsets() {
+		reader.Seek(cu.offset)
+		reader.Next()
+		bi.loadDebugInfoMapsCompileUnit(ctxt, image, reader, cu)
+		return
+	}
+	for _, ne := range entries {
+		switch ne.Kind {
+		case godwarf.NameType:
+			name := "C." + ne.Name
+			if _, exists := bi.types[name]; !exists {
+				bi.types[name] = dwarfRef{image.index, ne.Offset}
+			}
+			continue
+		case godwarf.NameFunction, godwarf.NameVariable:
+			// read below
+		default:
+			continue
+		}
+		reader.Seek(ne.Offset)
+		entry, err := reader.Next()
+		if err != nil {
+			image.setLoadError(bi.logger, "error reading debug_info: %v", err)
+			return
+		}
+		if entry == nil {
+			continue
+		}
+		switch entry.Tag {
+		case dwarf.TagVariable:
+			bi.addPackageVar(entry, ctxt, image, cu)
+		case dwarf.TagSubprogram:
+			bi.addSubprogram(entry, ctxt, reader, image, cu)
+		}
+	}
+}
+
+// loadDebugInfoMapsLazy loads the subprograms of a Go compile unit,
+// without reading their children, and records the offsets of its other
+// entries so that they can be loaded when they are first needed, by
+// loadDeferredTypes and loadDeferredInlinedCalls.
+// The entries of the compile unit were read by loadCompileUnitData.
+func (bi *BinaryInfo) loadDebugInfoMapsLazy(ctxt *loadDebugInfoMapsContext, reader *reader.Reader, image *Image, load *compileUnitLoad) {
+	cu := load.cu
+	cu.lazy = true
+	for _, entry := range load.subprograms {
+		bi.addSubprogram(entry, ctxt, reader, image, cu)
+	}
+	if load.err != nil {
+		image.setLoadError(bi.logger, "error reading debug_info: %v", load.err)
+		return
+	}
+	cu.deferredTypes = load.deferredTypes
+	cu.deferredTypeEntries = load.deferredTypeEntries
+	if len(cu.deferredTypes) > 0 {
+		bi.deferredTypes = append(bi.deferredTypes, cu)
+	}
+	if len(cu.deferredSubprograms) > 0 {
+		bi.deferredInlinedCalls = append(bi.deferredInlinedCalls, cu)
+	}
+}
+
+// hasDeferredTypes returns true if the types of some compile units of
+// image have not been loaded yet.
+func (bi *BinaryInfo) hasDeferredTypes(image *Image) bool {
+	bi.deferredMu.Lock()
+	defer bi.deferredMu.Unlock()
+	return bi.hasDeferredTypeEntries(image)
+}
+
+// hasDeferredTypeEntries is like hasDeferredTypes but must be called while
+// holding deferredMu.
+func (bi *BinaryInfo) hasDeferredTypeEntries(image *Image) bool {
+	for _, cu := range bi.deferredTypes {
+		if cu.image == image && cu.deferredTypeEntries {
+			return true
+		}
+	}
+	return false
+}
+
+// loadDeferredTypes loads the types, package variables and constants of
+// the compile units that were loaded lazily, see loadDebugInfoMapsLazy.
+// It must be called before accessing bi.packageVars or bi.consts, bi.types
+// and the runtimeTypeToDIE map of an image only need
+// loadDeferredTypeEntries.
+func (bi *BinaryInfo) loadDeferredTypes() {
+	bi.loadDeferredCompileUnits(func(*compileUnit) bool { return true })
+}
+
+// loadDeferredTypeEntries loads the compile units that were loaded lazily
+// and contain type entries, which are the only ones that can define the
+// types in bi.types and runtimeTypeToDIE.
+func (bi *BinaryInfo) loadDeferredTypeEntries() {
+	bi.loadDeferredCompileUnits(func(cu *compileUnit) bool { return cu.deferredTypeEntries })
+}
+
+// loadDeferredGlobal loads the compile units that were loaded lazily and
+// can contain the package variable or constant called name, either the
+// full name or a suffix of it following a '/', see findGlobalInternal.
+func (bi *BinaryInfo) loadDeferredGlobal(name string) {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		bi.loadDeferredTypes()
+		return
+	}
+	pkg := name[:i]
+	bi.loadDeferredCompileUnits(func(cu *compileUnit) bool {
+		return cu.name == pkg || strings.HasSuffix(cu.name, "/"+pkg)
+	})
+}
+
+// loadDeferredCompileUnits loads the types, package variables and
+// constants of the compile units that were loaded lazily and for which
+// match returns true.
+func (bi *BinaryInfo) loadDeferredCompileUnits(match func(*compileUnit) bool) {
+	bi.deferredMu.Lock()
+	var cus []*compileUnit
+	deferred := bi.deferredTypes[:0]
+	for _, cu := range bi.deferredTypes {
+		if match(cu) {
+			cus = append(cus, cu)
+		} else {
+			deferred = append(deferred, cu)
+		}
+	}
+	for i := len(deferred); i < len(bi.deferredTypes); i++ {
+		bi.deferredTypes[i] = nil
+	}
+	bi.deferredTypes = deferred
+	var images, typeImages []*Image
+	var ctxt *loadDebugInfoMapsContext
+	for _, cu := range cus {
+		image := cu.image
+		if ctxt == nil || images[len(images)-1] != image {
+			ctxt = newLoadDebugInfoMapsContext(bi, image, nil)
+			images = append(images, image)
+		}
+		if cu.deferredTypeEntries && (len(typeImages) == 0 || typeImages[len(typeImages)-1] != image) {
+			typeImages = append(typeImages, image)
+		}
+		reader := image.DwarfReader()
+		for _, off := range cu.deferredTypes {
+			reader.Seek(off)
+			entry, err := reader.Next()
+			if err != nil {
+				image.setLoadError(bi.logger, "error reading debug_info: %v", err)
+				break
+			}
+			switch entry.Tag {
+			case dwarf.TagVariable:
+				bi.addPackageVar(entry, ctxt, image, cu)
+			case dwarf.TagConstant:
+				bi.addConstant(entry, image, cu)
+			default:
+				bi.addType(entry, ctxt, image, cu)
+			}
+		}
+		cu.deferredTypes = nil
+		cu.deferredTypeEntries = false
+	}
+	if len(cus) > 0 {
+		sort.Sort(packageVarsByAddr(bi.packageVars))
+	}
+
+	// Patch runtime.mallocgc, once all the types of its image are loaded,
+	// before releasing deferredMu, otherwise another goroutine could find
+	// no deferred types left and read the unpatched DIE.
+	for _, image := range typeImages {
+		if !bi.hasDeferredTypeEntries(image) {
+			bi.patchRuntimeMallocgc(image)
+		}
+	}
+	bi.deferredMu.Unlock()
+}
+
+// loadDeferredInlinedCalls loads the inlined calls of the compile units
+// that were loaded lazily, see loadDebugInfoMapsLazy. It must be called
+// before accessing the InlinedCalls field of a function that was inlined
+// or bi.inlinedCallLines.
+func (bi *BinaryInfo) loadDeferredInlinedCalls() {
+	bi.deferredMu.Lock()
+	defer bi.deferredMu.Unlock()
+	cus := bi.deferredInlinedCalls
+	bi.deferredInlinedCalls = nil
+	var image *Image
+	var ctxt *loadDebugInfoMapsContext
+	for _, cu := range cus {
+		if ctxt == nil || image != cu.image {
+			image = cu.image
+			// bi.Functions was sorted after the abstract origins were recorded,
+			// rebuild the table from the functions of the image.
+			ctxt = &loadDebugInfoMapsContext{abstractOriginTable: make(map[dwarf.Offset]int), noNewFunctions: true}
+			for i := range bi.Functions {
+				fn := &bi.Functions[i]
+				if fn.abstractOffset != 0 && fn.cu != nil && fn.cu.image == image {
+					ctxt.abstractOriginTable[fn.abstractOffset] = i
+				}
+			}
+		}
+		reader := image.DwarfReader()
+		for _, off := range cu.deferredSubprograms {
+			reader.Seek(off)
+			if _, err := reader.Next(); err != nil {
+				image.setLoadError(bi.logger, "error reading debug_info: %v", err)
+				break
+			}
+			bi.loadDebugInfoMapsInlinedCalls(ctxt, reader, cu)
+		}
+		cu.deferredSubprograms = nil
+	}
+}
+
+func (bi *BinaryInfo) addType(entry *dwarf.Entry, ctxt *loadDebugInfoMapsContext, image *Image, cu *compileUnit) {
+	if name, ok := entry.Val(dwarf.AttrName).(string); ok {
+		if !cu.isgo {
+			name = "C." + name
+		}
+		if _, exists := bi.types[name]; !exists {
+			bi.types[name] = dwarfRef{image.index, entry.Offset}
+		}
+	}
+	image.registerRuntimeTypeToDIE(entry, ctxt.ardr)
+}
+
+func (bi *BinaryInfo) addConstant(entry *dwarf.Entry, image *Image, cu *compileUnit) {
+	name, okName := entry.Val(dwarf.AttrName).(string)
+	typ, okType := entry.Val(dwarf.AttrType).(dwarf.Offset)
+	val, okVal := entry.Val(dwarf.AttrConstValue).(int64)
+	if okName && okType && okVal {
+		if !cu.isgo {
+			name = "C." + name
+		}
+		ct := bi.consts[dwarfRef{image.index, typ}]
+		if ct == nil {
+			ct = &constantType{}
+			bi.consts[dwarfRef{image.index, typ}] = ct
+		}
+		ct.values = append(ct.values, constantValue{name: name, fullName: name, value: val})
+	}
+}
+
+func (bi *BinaryInfo) addPackageVar(entry *dwarf.Entry, ctxt *loadDebugInfoMapsContext, image *Image, cu *compileUnit) {
+	n, ok := entry.Val(dwarf.AttrName).(string)
+	if !ok {
+		return
+	}
+	var addr uint64
+	if loc, ok := entry.Val(dwarf.AttrLocation).([]byte); ok {
+		if len(loc) == bi.Arch.PtrSize()+1 && op.Opcode(loc[0]) == op.DW_OP_addr {
+			addr, _ = util.ReadUintRaw(bytes.NewReader(loc[1:]), binary.LittleEndian, bi.Arch.PtrSize())
+		}
+	}
+	if !cu.isgo {
+		n = "C." + n
+	}
+	if _, known := ctxt.knownPackageVars[n]; !known {
+		bi.packageVars = append(bi.packageVars, packageVar{n, cu, entry.Offset, addr + image.StaticBase})
+	}
+}
+
+func (bi *BinaryInfo) addSubprogram(entry *dwarf.Entry, ctxt *loadDebugInfoMapsContext, reader *reader.Reader, image *Image, cu *compileUnit) {
+	inlined := false
+	if inval, ok := entry.Val(dwarf.AttrInline).(int64); ok {
+		inlined = inval >= 1
+	}
+
+	if inlined {
+		bi.addAbstractSubprogram(entry, ctxt, reader, image, cu)
+	} else {
+		originOffset, hasAbstractOrigin := entry.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset)
+		if hasAbstractOrigin {
+			bi.addConcreteInlinedSubprogram(entry, originOffset, ctxt, reader, cu)
+		} else {
+			bi.addConcreteSubprogram(entry, ctxt, reader, cu)
+		}
+	}
+}
+
 // loadDebugInfoMapsImportedUnit loads entries into cu from the partial unit
 // referenced in a DW_TAG_imported_unit entry.
 func (bi *BinaryInfo) loadDebugInfoMapsImportedUnit(entry *dwarf.Entry, ctxt *loadDebugInfoMapsContext, image *Image, cu *compileUnit) {
@@ -2111,14 +3370,13 @@ func (bi *BinaryInfo) addAbstractSubprogram(entry *dwarf.Entry, ctxt *loadDebugI
 		// name, but we should process them anyway.
 	}
 
-	if entry.Children {
-		bi.loadDebugInfoMapsInlinedCalls(ctxt, reader, cu)
-	}
+	bi.loadSubprogramInlinedCalls(ctxt, reader, entry, cu)
 
 	originIdx := ctxt.lookupAbstractOrigin(bi, entry.Offset)
 	fn := &bi.Functions[originIdx]
 	fn.Name = name
 	fn.offset = entry.Offset
+	fn.abstractOffset = entry.Offset
 	fn.cu = cu
 }
 
@@ -2140,9 +3398,7 @@ func (bi *BinaryInfo) addConcreteInlinedSubprogram(entry *dwarf.Entry, originOff
 	fn.End = highpc
 	fn.cu = cu
 
-	if entry.Children {
-		bi.loadDebugInfoMapsInlinedCalls(ctxt, reader, cu)
-	}
+	bi.loadSubprogramInlinedCalls(ctxt, reader, entry, cu)
 }
 
 // addConcreteSubprogram adds a concrete subprogram (a normal subprogram
@@ -2174,9 +3430,21 @@ func (bi *BinaryInfo) addConcreteSubprogram(entry *dwarf.Entry, ctxt *loadDebugI
 	fn.cu = cu
 	fn.trampoline = trampoline
 
-	if entry.Children {
-		bi.loadDebugInfoMapsInlinedCalls(ctxt, reader, cu)
+	bi.loadSubprogramInlinedCalls(ctxt, reader, entry, cu)
+}
+
+// loadSubprogramInlinedCalls loads the inlined calls in the children of
+// the subprogram entry, if cu is loaded lazily they are only recorded to
+// be loaded by loadDeferredInlinedCalls.
+func (bi *BinaryInfo) loadSubprogramInlinedCalls(ctxt *loadDebugInfoMapsContext, reader *reader.Reader, entry *dwarf.Entry, cu *compileUnit) {
+	if !entry.Children {
+		return
+	}
+	if cu.lazy {
+		cu.deferredSubprograms = append(cu.deferredSubprograms, entry.Offset)
+		return
 	}
+	bi.loadDebugInfoMapsInlinedCalls(ctxt, reader, cu)
 }
 
 func subprogramEntryName(entry *dwarf.Entry, cu *compileUnit) (string, bool) {
@@ -2239,6 +3507,12 @@ func (bi *BinaryInfo) loadDebugInfoMapsInlinedCalls(ctxt *loadDebugInfoMapsConte
 				continue
 			}
 
+			if _, known := ctxt.abstractOriginTable[originOffset]; !known && ctxt.noNewFunctions {
+				bi.logger.Warnf("reading debug_info: inlined call with unknown origin offset at %#x", entry.Offset)
+				reader.SkipChildren()
+				continue
+			}
+
 			originIdx := ctxt.lookupAbstractOrigin(bi, originOffset)
 			fn := &bi.Functions[originIdx]
 
@@ -2345,6 +3619,7 @@ func (bi *BinaryInfo) symLookup(addr uint64) (string, uint64) {
 	if sym, ok := bi.SymNames[addr]; ok {
 		return sym.Name, addr
 	}
+	bi.loadDeferredTypes()
 	i := sort.Search(len(bi.packageVars), func(i int) bool {
 		return bi.packageVars[i].addr >= addr
 	})
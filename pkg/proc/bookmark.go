@@ -0,0 +1,119 @@
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Bookmark is a named position in a recording.
+type Bookmark struct {
+	Name string
+	// Event is the recording event number of the bookmarked position.
+	Event uint64
+	// Ticks is the number of ticks executed by the current thread since the
+	// start of Event.
+	Ticks uint64
+	// Checkpoint is the ID of the checkpoint backing this bookmark.
+	Checkpoint int
+}
+
+// AddBookmark records the current position in the recording under name.
+// The bookmark is backed by a checkpoint so that GotoBookmark can return
+// to the exact same position.
+func (t *Target) AddBookmark(name string) (Bookmark, error) {
+	if recorded, _ := t.Recorded(); !recorded {
+		return Bookmark{}, ErrNotRecorded
+	}
+	if name == "" {
+		return Bookmark{}, errors.New("bookmark name can not be empty")
+	}
+	if _, ok := t.bookmarks[name]; ok {
+		return Bookmark{}, fmt.Errorf("bookmark %q already exists", name)
+	}
+	event, err := t.WhenEvent()
+	if err != nil {
+		return Bookmark{}, err
+	}
+	ticks, err := t.WhenTicks()
+	if err != nil {
+		return Bookmark{}, err
+	}
+	cpid, err := t.Checkpoint("bookmark " + name)
+	if err != nil {
+		return Bookmark{}, err
+	}
+	if t.bookmarks == nil {
+		t.bookmarks = make(map[string]Bookmark)
+	}
+	bm := Bookmark{Name: name, Event: event, Ticks: ticks, Checkpoint: cpid}
+	t.bookmarks[name] = bm
+	return bm, nil
+}
+
+// Bookmarks returns the list of bookmarks, sorted by their position in the
+// recording.
+func (t *Target) Bookmarks() []Bookmark {
+	r := make([]Bookmark, 0, len(t.bookmarks))
+	for _, bm := range t.bookmarks {
+		r = append(r, bm)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].Event != r[j].Event {
+			return r[i].Event < r[j].Event
+		}
+		if r[i].Ticks != r[j].Ticks {
+			return r[i].Ticks < r[j].Ticks
+		}
+		return r[i].Name < r[j].Name
+	})
+	return r
+}
+
+// GotoBookmark restarts the recording from the position of the bookmark
+// with the given name.
+func (t *Target) GotoBookmark(name string) error {
+	bm, ok := t.bookmarks[name]
+	if !ok {
+		return fmt.Errorf("bookmark %q not found", name)
+	}
+	return t.Restart(fmt.Sprintf("c%d", bm.Checkpoint))
+}
+
+// ClearBookmark removes the bookmark with the given name, and the
+// checkpoint backing it.
+func (t *Target) ClearBookmark(name string) error {
+	bm, ok := t.bookmarks[name]
+	if !ok {
+		return fmt.Errorf("bookmark %q not found", name)
+	}
+	if err := t.ClearCheckpoint(bm.Checkpoint); err != nil {
+		return err
+	}
+	delete(t.bookmarks, name)
+	return nil
+}
+
+// WhenEvent returns the current event number of the recording.
+func (t *Target) WhenEvent() (uint64, error) {
+	when, err := t.When()
+	if err != nil {
+		return 0, err
+	}
+	return ParseWhenEvent(when)
+}
+
+// ParseWhenEvent extracts the event number from the output of When.
+func ParseWhenEvent(when string) (uint64, error) {
+	fields := strings.Fields(when)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("can not parse recording position %q", when)
+	}
+	event, err := strconv.ParseUint(fields[len(fields)-1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("can not parse recording position %q", when)
+	}
+	return event, nil
+}
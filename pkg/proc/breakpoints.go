@@ -14,6 +14,7 @@ import (
 	"github.com/go-delve/delve/pkg/dwarf/op"
 	"github.com/go-delve/delve/pkg/dwarf/reader"
 	"github.com/go-delve/delve/pkg/goversion"
+	"github.com/go-delve/delve/pkg/logflags"
 	"github.com/go-delve/delve/pkg/proc/internal/ebpf"
 )
 
@@ -51,8 +52,9 @@ type Breakpoint struct {
 
 	WatchExpr     string
 	WatchType     WatchType
-	HWBreakIndex  uint8 // hardware breakpoint index
-	watchStackOff int64 // for watchpoints of stack variables, offset of the address from top of the stack
+	WatchFunction string // for watchpoints, function of the scope where WatchExpr was evaluated
+	HWBreakIndex  uint8  // hardware breakpoint index
+	watchStackOff int64  // for watchpoints of stack variables, offset of the address from top of the stack
 
 	// Breaklets is the list of overlapping breakpoints on this physical breakpoint.
 	// There can be at most one UserBreakpoint in this list but multiple internal breakpoints are allowed.
@@ -149,6 +151,10 @@ const (
 	// adjust the watchpoint of stack variables.
 	StackResizeBreakpoint
 
+	// WatchOnEntryBreakpoint is a breakpoint used to create a watchpoint
+	// the next time a function is called, see SetWatchpointOnEntry.
+	WatchOnEntryBreakpoint
+
 	steppingMask = NextBreakpoint | NextDeferBreakpoint | StepBreakpoint
 )
 
@@ -220,6 +226,8 @@ func (bp *Breakpoint) VerboseDescr() []string {
 			r = append(r, fmt.Sprintf("WatchOutOfScope Cond=%q checkPanicCall=%v", exprToString(breaklet.Cond), breaklet.checkPanicCall))
 		case StackResizeBreakpoint:
 			r = append(r, fmt.Sprintf("StackResizeBreakpoint Cond=%q", exprToString(breaklet.Cond)))
+		case WatchOnEntryBreakpoint:
+			r = append(r, fmt.Sprintf("WatchOnEntry Cond=%q LogicalID=%d", exprToString(breaklet.Cond), breaklet.LogicalID))
 		default:
 			r = append(r, fmt.Sprintf("Unknown %d", breaklet.Kind))
 		}
@@ -315,7 +323,7 @@ func (bpstate *BreakpointState) checkCond(tgt *Target, breaklet *Breaklet, threa
 			}
 		}
 
-	case StackResizeBreakpoint:
+	case StackResizeBreakpoint, WatchOnEntryBreakpoint:
 		// no further checks
 
 	default:
@@ -326,7 +334,9 @@ func (bpstate *BreakpointState) checkCond(tgt *Target, breaklet *Breaklet, threa
 		if breaklet.callback != nil {
 			active = breaklet.callback(thread)
 		}
-		bpstate.Active = active
+		// a breaklet whose callback returns false must not hide the other
+		// breaklets set on the same address
+		bpstate.Active = bpstate.Active || active
 	}
 }
 
@@ -500,14 +510,28 @@ func (t *Target) SetEBPFTracepoint(fnName string) error {
 		return err
 	}
 
-	// Get information on the Goroutine so we can tell the
-	// eBPF program where to find it in order to get the
-	// goroutine ID.
-	rdr := t.BinInfo().Images[0].DwarfReader()
-	rdr.SeekToTypeNamed("runtime.g")
-	typ, err := t.BinInfo().findType("runtime.g")
+	goidOffset, err := ebpfGoidOffset(t.BinInfo())
 	if err != nil {
-		return errors.New("could not find type for runtime.g")
+		return err
+	}
+
+	for _, fn := range fns {
+		args, err := ebpfArgMap(t.BinInfo(), fn)
+		if err != nil {
+			return err
+		}
+		// Finally, set the uprobe on the function.
+		t.proc.SetUProbe(fn.Name, goidOffset, args)
+	}
+	return nil
+}
+
+// ebpfGoidOffset returns the offset of the goid field of runtime.g, so
+// that the eBPF program can find the ID of the goroutine that hit a uprobe.
+func ebpfGoidOffset(bi *BinaryInfo) (int64, error) {
+	typ, err := bi.findType("runtime.g")
+	if err != nil {
+		return 0, errors.New("could not find type for runtime.g")
 	}
 	var goidOffset int64
 	switch t := typ.(type) {
@@ -519,44 +543,69 @@ func (t *Target) SetEBPFTracepoint(fnName string) error {
 			}
 		}
 	}
-
-	for _, fn := range fns {
-		err := t.setEBPFTracepointOnFunc(fn, goidOffset)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return goidOffset, nil
 }
 
-func (t *Target) setEBPFTracepointOnFunc(fn *Function, goidOffset int64) error {
-	// Start putting together the argument map. This will tell the eBPF program
-	// all of the arguments we want to trace and how to find them.
-
+// ebpfArgMap returns the argument map of fn. This will tell the eBPF
+// program all of the arguments we want to trace and how to find them.
+func ebpfArgMap(bi *BinaryInfo, fn *Function) ([]ebpf.UProbeArgMap, error) {
 	// Start looping through each argument / return parameter for the function we
 	// are setting the uprobe on. Parse location information so that we can pass it
 	// along to the eBPF program.
 	dwarfTree, err := fn.cu.image.getDwarfTree(fn.offset)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	variablesFlags := reader.VariablesOnlyVisible
-	if t.BinInfo().Producer() != "" && goversion.ProducerAfterOrEqual(t.BinInfo().Producer(), 1, 15) {
+	if bi.Producer() != "" && goversion.ProducerAfterOrEqual(bi.Producer(), 1, 15) {
 		variablesFlags |= reader.VariablesTrustDeclLine
 	}
-	_, l, _ := t.BinInfo().PCToLine(fn.Entry)
+	_, l, _ := bi.PCToLine(fn.Entry)
+
+	// The location of return values is only described by DWARF at function
+	// entry, where they haven't been assigned yet. With the register based
+	// calling convention they are read from the registers assigned to them
+	// by the ABI when the function returns.
+	var abi *regABIAssigner
+	if bi.regabi {
+		abi = newRegABIAssigner(bi.Arch)
+	}
 
 	var args []ebpf.UProbeArgMap
+	inResults := false
 	varEntries := reader.Variables(dwarfTree, fn.Entry, l, variablesFlags)
 	for _, entry := range varEntries {
 		_, dt, err := readVarEntry(entry.Tree, fn.cu.image)
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		isret, _ := entry.Val(dwarf.AttrVarParam).(bool)
+		if abi != nil {
+			if isret && !inResults {
+				inResults = true
+				abi.startResults()
+			}
+			asg := abi.assign(dt)
+			if isret {
+				if asg.onStack {
+					// The return probe is hit at the RET instruction, after the
+					// frame of the function has been removed: the argument area
+					// starts one word above the stack pointer, either after the
+					// return address or after the slot reserved for the link
+					// register.
+					offset := asg.stackOff + int64(bi.Arch.PtrSize())
+					args = append(args, ebpf.UProbeArgMap{Offset: offset, Size: dt.Size(), Kind: dt.Common().ReflectKind, Ret: true})
+				} else if asg.readable {
+					args = append(args, ebpf.UProbeArgMap{Size: dt.Size(), Kind: dt.Common().ReflectKind, Pieces: asg.regs, InReg: true, Ret: true})
+				}
+				continue
+			}
 		}
 
-		offset, pieces, _, err := t.BinInfo().Location(entry, dwarf.AttrLocation, fn.Entry, op.DwarfRegisters{}, nil)
+		offset, pieces, _, err := bi.Location(entry, dwarf.AttrLocation, fn.Entry, op.DwarfRegisters{}, nil)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		paramPieces := make([]int, 0, len(pieces))
 		for _, piece := range pieces {
@@ -564,8 +613,12 @@ func (t *Target) setEBPFTracepointOnFunc(fn *Function, goidOffset int64) error {
 				paramPieces = append(paramPieces, int(piece.Val))
 			}
 		}
-		isret, _ := entry.Val(dwarf.AttrVarParam).(bool)
-		offset += int64(t.BinInfo().Arch.PtrSize())
+		if !bi.Arch.usesLR {
+			// The uprobe is hit before the function's prologue is executed: on
+			// architectures that don't use a link register the CFA is above the
+			// return address pushed by the call instruction.
+			offset += int64(bi.Arch.PtrSize())
+		}
 		args = append(args, ebpf.UProbeArgMap{
 			Offset: offset,
 			Size:   dt.Size(),
@@ -578,9 +631,7 @@ func (t *Target) setEBPFTracepointOnFunc(fn *Function, goidOffset int64) error {
 
 	//TODO(aarzilli): inlined calls?
 
-	// Finally, set the uprobe on the function.
-	t.proc.SetUProbe(fn.Name, goidOffset, args)
-	return nil
+	return args, nil
 }
 
 // SetWatchpoint sets a data breakpoint at addr and stores it in the
@@ -589,6 +640,9 @@ func (t *Target) SetWatchpoint(logicalID int, scope *EvalScope, expr string, wty
 	if (wtype&WatchWrite == 0) && (wtype&WatchRead == 0) {
 		return nil, errors.New("at least one of read and write must be set for watchpoint")
 	}
+	if !t.SupportsWatchpoints() {
+		return nil, fmt.Errorf("watchpoints are not supported on %s/%s", t.BinInfo().GOOS, t.BinInfo().Arch.Name)
+	}
 
 	n, err := parser.ParseExpr(expr)
 	if err != nil {
@@ -629,6 +683,9 @@ func (t *Target) SetWatchpoint(logicalID int, scope *EvalScope, expr string, wty
 		return bp, err
 	}
 	bp.WatchExpr = expr
+	if scope.Fn != nil {
+		bp.WatchFunction = scope.Fn.Name
+	}
 
 	if stackWatch {
 		bp.watchStackOff = int64(bp.Addr) - int64(scope.g.stack.hi)
@@ -641,6 +698,71 @@ func (t *Target) SetWatchpoint(logicalID int, scope *EvalScope, expr string, wty
 	return bp, nil
 }
 
+// SetWatchpointOnEntry arranges for a watchpoint on expr to be created,
+// with SetWatchpoint, the next time function fn is called, evaluating expr
+// in the scope of fn's frame. Until then a WatchOnEntryBreakpoint is set
+// after the prologue of fn; if expr can not be evaluated there the
+// watchpoint is not created and the next call is waited for.
+// The setup function, if not nil, is called with the watchpoint after it
+// is created.
+// This is used to recreate watchpoints after the target is restarted.
+func (t *Target) SetWatchpointOnEntry(logicalID int, fn *Function, expr string, wtype WatchType, cond ast.Expr, setup func(*Breakpoint)) error {
+	pc, err := FirstPCAfterPrologue(t, fn, false)
+	if err != nil {
+		return err
+	}
+	bp, err := t.SetBreakpoint(0, pc, WatchOnEntryBreakpoint, nil)
+	if err != nil {
+		return err
+	}
+	breaklet := bp.Breaklets[len(bp.Breaklets)-1]
+	breaklet.LogicalID = logicalID
+	breaklet.callback = func(th Thread) bool {
+		if breaklet.LogicalID == NoLogicalID {
+			// already created by another thread that hit this breakpoint
+			return false
+		}
+		scope, err := GoroutineScope(t, th)
+		if err != nil {
+			return false
+		}
+		wp, err := t.SetWatchpoint(logicalID, scope, expr, wtype, cond)
+		if err != nil {
+			logflags.DebuggerLogger().Debugf("could not recreate watchpoint %d on %s in %s: %v", logicalID, expr, fn.Name, err)
+			return false
+		}
+		if setup != nil {
+			setup(wp)
+		}
+		if err := t.ClearWatchpointOnEntry(logicalID); err != nil {
+			logflags.DebuggerLogger().Errorf("could not clear breakpoint on %s: %v", fn.Name, err)
+		}
+		return false // the user asked to stop when expr changes, not here
+	}
+	return nil
+}
+
+// ClearWatchpointOnEntry clears the WatchOnEntryBreakpoint set by
+// SetWatchpointOnEntry for the logical breakpoint logicalID.
+func (t *Target) ClearWatchpointOnEntry(logicalID int) error {
+	for _, bp := range t.Breakpoints().M {
+		changed := false
+		for i, breaklet := range bp.Breaklets {
+			if breaklet.Kind == WatchOnEntryBreakpoint && breaklet.LogicalID == logicalID {
+				breaklet.LogicalID = NoLogicalID
+				bp.Breaklets[i] = nil
+				changed = true
+			}
+		}
+		if changed {
+			if _, err := t.finishClearBreakpoint(bp); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (t *Target) setBreakpointInternal(logicalID int, addr uint64, kind BreakpointKind, wtype WatchType, cond ast.Expr) (*Breakpoint, error) {
 	if valid, err := t.Valid(); !valid {
 		recorded, _ := t.Recorded()
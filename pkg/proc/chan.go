@@ -0,0 +1,127 @@
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/go-delve/delve/pkg/dwarf/godwarf"
+)
+
+// ChanInfo describes the state of a channel, as read from its
+// runtime.hchan structure.
+type ChanInfo struct {
+	Addr     uint64 // address of the runtime.hchan structure
+	Len, Cap int64
+	Closed   bool
+	// Buffer contains the elements in the buffer of the channel, in the
+	// order they will be received.
+	Buffer []*Variable
+	// Senders and Receivers are the goroutines blocked sending to and
+	// receiving from the channel, in the order they will be woken.
+	Senders, Receivers []*G
+}
+
+// ChanInfo returns the state of channel v, the elements of its buffer are
+// loaded using cfg.
+func (t *Target) ChanInfo(v *Variable, cfg LoadConfig) (*ChanInfo, error) {
+	if v.Unreadable != nil {
+		return nil, v.Unreadable
+	}
+	chanType, ok := v.RealType.(*godwarf.ChanType)
+	if !ok || v.Kind != reflect.Chan {
+		return nil, fmt.Errorf("%s is not a channel", v.TypeString())
+	}
+	if v.Base == 0 {
+		return nil, errors.New("nil channel")
+	}
+	hv := v.clone()
+	hv.RealType = resolveTypedef(&chanType.TypedefType)
+	hv = hv.maybeDereference()
+	if hv.Unreadable != nil {
+		return nil, hv.Unreadable
+	}
+
+	field := func(name string) uint64 {
+		fv := hv.loadFieldNamed(name)
+		if fv == nil {
+			return 0
+		}
+		n, _ := runtimeUint(fv)
+		return n
+	}
+	r := &ChanInfo{
+		Addr:   hv.Addr,
+		Len:    int64(field("qcount")),   // +rtype -field hchan.qcount uint
+		Cap:    int64(field("dataqsiz")), // +rtype -field hchan.dataqsiz uint
+		Closed: field("closed") != 0,     // +rtype -field hchan.closed uint32
+	}
+	recvx := int64(field("recvx")) // +rtype -field hchan.recvx uint
+
+	if r.Len > 0 && r.Cap > 0 {
+		bufv, err := hv.structMember("buf") // +rtype -field hchan.buf unsafe.Pointer
+		if err != nil {
+			return nil, err
+		}
+		buf, err := readUintRaw(hv.mem, bufv.Addr, int64(t.BinInfo().Arch.PtrSize()))
+		if err != nil {
+			return nil, err
+		}
+		elemsz := chanType.ElemType.Size()
+		count := r.Len
+		if cfg.MaxArrayValues >= 0 && count > int64(cfg.MaxArrayValues) {
+			count = int64(cfg.MaxArrayValues)
+		}
+		for i := int64(0); i < count; i++ {
+			idx := (recvx + i) % r.Cap
+			ev := newVariable(fmt.Sprintf("[%d]", i), buf+uint64(idx*elemsz), chanType.ElemType, t.BinInfo(), hv.mem)
+			ev.loadValue(cfg)
+			r.Buffer = append(r.Buffer, ev)
+		}
+	}
+
+	sf, err := loadSudogFields(t.BinInfo())
+	if err != nil {
+		return r, err
+	}
+	gs, _, err := GoroutinesInfo(t, 0, 0)
+	if err != nil {
+		return r, err
+	}
+	gsByAddr := make(map[uint64]*G, len(gs))
+	for _, g := range gs {
+		if g.variable != nil {
+			gsByAddr[g.variable.Addr] = g
+		}
+	}
+	readWaitq := func(name string) ([]*G, error) {
+		wq, err := hv.structMember(name)
+		if err != nil {
+			return nil, err
+		}
+		first, err := wq.structMember("first") // +rtype -field waitq.first *sudog
+		if err != nil {
+			return nil, err
+		}
+		addr, err := readUintRaw(hv.mem, first.Addr, sf.ptrSize)
+		r := []*G{}
+		for n := 0; err == nil && addr != 0 && n < maxSudogs; n++ {
+			var sg *sudog
+			sg, err = sf.read(hv.mem, addr)
+			if err != nil {
+				break
+			}
+			if g := gsByAddr[sg.g]; g != nil {
+				r = append(r, g)
+			}
+			addr = sg.next
+		}
+		return r, err
+	}
+	r.Senders, err = readWaitq("sendq") // +rtype -field hchan.sendq waitq
+	if err != nil {
+		return r, err
+	}
+	r.Receivers, err = readWaitq("recvq") // +rtype -field hchan.recvq waitq
+	return r, err
+}
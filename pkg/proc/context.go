@@ -0,0 +1,209 @@
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// maxContextDepth is the maximum number of layers of a context.Context read
+// by ContextChain, to protect against corrupted values.
+const maxContextDepth = 1000
+
+// ContextKind is the function of package context that created a layer of
+// a context.Context.
+type ContextKind uint8
+
+const (
+	ContextUnknown       ContextKind = iota // not created by package context
+	ContextBackground                       // context.Background
+	ContextTODO                             // context.TODO
+	ContextEmpty                            // context.Background or context.TODO, before Go 1.21
+	ContextCancel                           // context.WithCancel and context.WithCancelCause
+	ContextDeadline                         // context.WithDeadline and context.WithTimeout
+	ContextValue                            // context.WithValue
+	ContextWithoutCancel                    // context.WithoutCancel
+	ContextAfterFunc                        // context.AfterFunc
+)
+
+func (k ContextKind) String() string {
+	switch k {
+	case ContextBackground:
+		return "Background"
+	case ContextTODO:
+		return "TODO"
+	case ContextEmpty:
+		return "Background/TODO"
+	case ContextCancel:
+		return "WithCancel"
+	case ContextDeadline:
+		return "WithDeadline"
+	case ContextValue:
+		return "WithValue"
+	case ContextWithoutCancel:
+		return "WithoutCancel"
+	case ContextAfterFunc:
+		return "AfterFunc"
+	default:
+		return "unknown"
+	}
+}
+
+// ContextLayer is a layer of a context.Context, i.e. one of the values
+// that wrap each other starting from context.Background.
+type ContextLayer struct {
+	Kind ContextKind
+	Type string // concrete type of the layer
+	Addr uint64
+
+	// Deadline is the deadline of the layer, a time.Time, for
+	// ContextDeadline.
+	Deadline *Variable
+	// Err and Cause are the error returned by Err and the cause of the
+	// cancellation, they are only set for layers that have been canceled.
+	Err, Cause *Variable
+	// Key and Value are the key/value pair stored by ContextValue layers.
+	Key, Value *Variable
+}
+
+// ContextChain returns the layers of context v, starting from v itself and
+// following the parent of each layer. Keys, values and errors are loaded
+// using cfg.
+// Layers of types not defined by package context are followed only if
+// they embed their parent context.
+func (t *Target) ContextChain(v *Variable, cfg LoadConfig) ([]*ContextLayer, error) {
+	r := []*ContextLayer{}
+	for len(r) < maxContextDepth {
+		v = contextConcreteValue(v)
+		if v == nil {
+			break
+		}
+		if v.Unreadable != nil {
+			return r, v.Unreadable
+		}
+		layer := &ContextLayer{Type: v.TypeString(), Addr: v.Addr}
+		r = append(r, layer)
+
+		var parent *Variable
+		member := func(v *Variable, name string) *Variable {
+			m, err := v.structMember(name)
+			if err != nil {
+				return nil
+			}
+			return m
+		}
+		loadCancel := func(c *Variable) {
+			if c == nil {
+				return
+			}
+			parent = member(c, "Context")
+			layer.Err = loadContextError(member(c, "err"), cfg)
+			if layer.Err != nil {
+				layer.Cause = loadContextError(member(c, "cause"), cfg)
+			}
+		}
+
+		switch v.RealType.Common().Name {
+		case "context.backgroundCtx":
+			layer.Kind = ContextBackground
+		case "context.todoCtx":
+			layer.Kind = ContextTODO
+		case "context.emptyCtx":
+			layer.Kind = ContextEmpty
+		case "context.cancelCtx":
+			layer.Kind = ContextCancel
+			loadCancel(v)
+		case "context.timerCtx":
+			layer.Kind = ContextDeadline
+			loadCancel(member(v, "cancelCtx"))
+			if layer.Deadline = member(v, "deadline"); layer.Deadline != nil {
+				layer.Deadline.loadValue(loadFullValue)
+			}
+		case "context.afterFuncCtx":
+			layer.Kind = ContextAfterFunc
+			loadCancel(member(v, "cancelCtx"))
+		case "context.valueCtx":
+			layer.Kind = ContextValue
+			parent = member(v, "Context")
+			for _, kv := range []struct {
+				name string
+				dst  **Variable
+			}{{"key", &layer.Key}, {"val", &layer.Value}} {
+				if *kv.dst = member(v, kv.name); *kv.dst != nil {
+					(*kv.dst).loadValue(cfg)
+				}
+			}
+		case "context.withoutCancelCtx":
+			layer.Kind = ContextWithoutCancel
+			parent = member(v, "c")
+		case "context.stopCtx":
+			// used internally by context.AfterFunc, not a layer of its own
+			r = r[:len(r)-1]
+			parent = member(v, "Context")
+		default:
+			if v.Kind == reflect.Struct {
+				if p := member(v, "Context"); p != nil && p.Kind == reflect.Interface {
+					parent = p
+				}
+			}
+		}
+		if parent == nil {
+			break
+		}
+		v = parent
+	}
+	if len(r) == 0 {
+		return nil, errors.New("nil context")
+	}
+	return r, nil
+}
+
+// contextConcreteValue returns the value stored in the context.Context
+// interface v, dereferencing it if it is a pointer. Returns nil if v is a
+// nil interface or a nil pointer.
+func contextConcreteValue(v *Variable) *Variable {
+	if v.Kind == reflect.Interface {
+		v.loadInterface(0, false, LoadConfig{})
+		if v.Unreadable != nil {
+			return v
+		}
+		if len(v.Children) == 0 || v.isNil() {
+			return nil
+		}
+		v = &v.Children[0]
+	}
+	if v.Kind == reflect.Ptr {
+		v = v.maybeDereference()
+		if v.Unreadable == nil && v.Addr == 0 {
+			return nil
+		}
+	}
+	if v.Unreadable == nil && v.Kind != reflect.Struct {
+		// emptyCtx is an int before Go 1.21
+		if v.RealType.Common().Name != "context.emptyCtx" {
+			v.Unreadable = fmt.Errorf("unexpected context type %s", v.TypeString())
+		}
+	}
+	return v
+}
+
+// loadContextError loads field v of context.cancelCtx, which contains an
+// error (or, in recent versions of Go, an atomic.Value storing an error).
+// Returns nil if v is nil or no error is stored.
+func loadContextError(v *Variable, cfg LoadConfig) *Variable {
+	if v == nil {
+		return nil
+	}
+	if v.Kind == reflect.Struct {
+		var err error
+		v, err = v.structMember("v")
+		if err != nil {
+			return nil
+		}
+	}
+	v.loadValue(cfg)
+	if v.Kind != reflect.Interface || v.Unreadable != nil || len(v.Children) == 0 || v.isNil() {
+		return nil
+	}
+	return v
+}
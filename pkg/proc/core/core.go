@@ -4,10 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"sort"
+	"sync"
 
 	"github.com/go-delve/delve/pkg/dwarf/op"
 	"github.com/go-delve/delve/pkg/elfwriter"
 	"github.com/go-delve/delve/pkg/proc"
+	"github.com/go-delve/delve/pkg/proc/core/internal/mmap"
 	"github.com/go-delve/delve/pkg/proc/internal/ebpf"
 )
 
@@ -27,6 +31,9 @@ var ErrNoThreads = errors.New("no threads found in core file")
 //                0x0000000000002000 0x0000000000002000  RW     1000
 // This can be represented in a SplicedMemory by adding the original region,
 // then putting the RW mapping on top of it.
+// The regions are kept sorted by address and never overlap, so that the
+// region containing an address can be found with a binary search: cores of
+// large programs can have hundreds of thousands of them.
 type splicedMemory struct {
 	readers []readerEntry
 }
@@ -43,7 +50,20 @@ func (r *splicedMemory) Add(reader proc.MemoryReader, off, length uint64) {
 		return
 	}
 	end := off + length - 1
-	newReaders := make([]readerEntry, 0, len(r.readers))
+	// Only the entries in r.readers[first:last] can overlap the new region.
+	first := sort.Search(len(r.readers), func(i int) bool {
+		entry := &r.readers[i]
+		return entry.offset+entry.length-1 >= off
+	})
+	if first == len(r.readers) {
+		// Fast path, regions are usually added in order.
+		r.readers = append(r.readers, readerEntry{off, length, reader})
+		return
+	}
+	last := first + sort.Search(len(r.readers)-first, func(i int) bool {
+		return r.readers[first+i].offset > end
+	})
+	newReaders := make([]readerEntry, 0, last-first+3)
 	add := func(e readerEntry) {
 		if e.length == 0 {
 			return
@@ -52,7 +72,7 @@ func (r *splicedMemory) Add(reader proc.MemoryReader, off, length uint64) {
 	}
 	inserted := false
 	// Walk through the list of regions, fixing up any that overlap and inserting the new one.
-	for _, entry := range r.readers {
+	for _, entry := range r.readers[first:last] {
 		entryEnd := entry.offset + entry.length - 1
 		switch {
 		case entryEnd < off:
@@ -94,13 +114,18 @@ func (r *splicedMemory) Add(reader proc.MemoryReader, off, length uint64) {
 	if !inserted {
 		newReaders = append(newReaders, readerEntry{off, length, reader})
 	}
-	r.readers = newReaders
+	tail := r.readers[last:]
+	r.readers = append(append(append(make([]readerEntry, 0, first+len(newReaders)+len(tail)), r.readers[:first]...), newReaders...), tail...)
 }
 
 // ReadMemory implements MemoryReader.ReadMemory.
 func (r *splicedMemory) ReadMemory(buf []byte, addr uint64) (n int, err error) {
 	started := false
-	for _, entry := range r.readers {
+	first := sort.Search(len(r.readers), func(i int) bool {
+		entry := &r.readers[i]
+		return entry.offset+entry.length > addr
+	})
+	for _, entry := range r.readers[first:] {
 		if entry.offset+entry.length <= addr {
 			if !started {
 				continue
@@ -152,6 +177,63 @@ func (r *offsetReaderAt) ReadMemory(buf []byte, addr uint64) (n int, err error)
 	return r.reader.ReadAt(buf, int64(addr-r.offset))
 }
 
+// fileMapping is a MemoryReader for a region of a file mapped at addr. The
+// region is memory mapped the first time it is read, so that opening a
+// core file does not require reading all of it.
+type fileMapping struct {
+	file *os.File
+	off  int64
+	addr uint64
+	size int
+
+	once   sync.Once
+	region *mmap.Region
+}
+
+// newFileMapping returns a fileMapping for length bytes of file starting at
+// off, mapped at addr. Since the part of the region past the end of the
+// file can not be mapped fileSize is used to truncate it, reading that part
+// returns io.EOF.
+func newFileMapping(file *os.File, fileSize, off int64, addr, length uint64) *fileMapping {
+	size := int64(0)
+	if off < fileSize {
+		size = fileSize - off
+		if uint64(size) > length {
+			size = int64(length)
+		}
+	}
+	if uint64(size) > uint64(maxInt) {
+		// Too big to map on this architecture, every read will go through
+		// the file.
+		size = -1
+	}
+	return &fileMapping{file: file, off: off, addr: addr, size: int(size)}
+}
+
+const maxInt = int(^uint(0) >> 1)
+
+// ReadMemory reads the memory at addr from the mapped region, if the file
+// could not be memory mapped it reads the file instead.
+func (m *fileMapping) ReadMemory(buf []byte, addr uint64) (int, error) {
+	m.once.Do(func() {
+		if m.size >= 0 {
+			m.region, _ = mmap.Map(m.file, m.off, m.size)
+		}
+	})
+	if m.region == nil {
+		return m.file.ReadAt(buf, m.off+int64(addr-m.addr))
+	}
+	data := m.region.Data
+	if addr < m.addr || addr-m.addr >= uint64(len(data)) {
+		return 0, io.EOF
+	}
+	n := copy(buf, data[addr-m.addr:])
+	if n < len(buf) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
 // process represents a core file.
 type process struct {
 	mem     proc.MemoryReader
@@ -193,7 +275,7 @@ var (
 
 type openFn func(string, string) (*process, proc.Thread, error)
 
-var openFns = []openFn{readLinuxOrPlatformIndependentCore, readAMD64Minidump}
+var openFns = []openFn{readLinuxOrPlatformIndependentCore, readAMD64Minidump, readMachOCore}
 
 // ErrUnrecognizedFormat is returned when the core file is not recognized as
 // any of the supported formats.
@@ -261,6 +343,9 @@ func (p *process) GetDirection() proc.Direction { return proc.Forward }
 // When does not apply to core files, it is to support the Mozilla 'rr' backend.
 func (p *process) When() (string, error) { return "", nil }
 
+// WhenTicks does not apply to core files, it is to support the Mozilla 'rr' backend.
+func (p *process) WhenTicks() (uint64, error) { return 0, ErrContinueCore }
+
 // Checkpoint for core files returns an error, there is no execution of a core file.
 func (p *process) Checkpoint(string) (int, error) { return -1, ErrContinueCore }
 
@@ -478,8 +563,17 @@ func (p *process) FindThread(threadID int) (proc.Thread, bool) {
 	return t, ok
 }
 
+// MemoryMap returns the memory regions stored in the core file.
 func (p *process) MemoryMap() ([]proc.MemoryMapEntry, error) {
-	return nil, proc.ErrMemoryMapNotSupported
+	mem, ok := p.mem.(*splicedMemory)
+	if !ok {
+		return nil, proc.ErrMemoryMapNotSupported
+	}
+	r := make([]proc.MemoryMapEntry, 0, len(mem.readers))
+	for _, entry := range mem.readers {
+		r = append(r, proc.MemoryMapEntry{Addr: entry.offset, Size: entry.length, Read: true})
+	}
+	return r, nil
 }
 
 func (p *process) DumpProcessNotes(notes []elfwriter.Note, threadDone func()) (threadsDone bool, out []elfwriter.Note, err error) {
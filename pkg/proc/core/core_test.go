@@ -198,6 +198,43 @@ func TestSplicedReader(t *testing.T) {
 	}
 }
 
+func TestFileMapping(t *testing.T) {
+	data := make([]byte, 0x3000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	f, err := ioutil.TempFile("", "filemapping")
+	assertNoError(err, t, "TempFile")
+	defer os.Remove(f.Name())
+	defer f.Close()
+	_, err = f.Write(data)
+	assertNoError(err, t, "Write")
+
+	// The region extends past the end of the file, like the bss section of
+	// an executable.
+	const addr = 0x400000
+	mem := &splicedMemory{}
+	mem.Add(newFileMapping(f, int64(len(data)), 0x1010, addr, 0x4000), addr, 0x4000)
+
+	got := make([]byte, 0x10)
+	n, err := mem.ReadMemory(got, addr+0x20)
+	if err != nil || n != len(got) || !bytes.Equal(got, data[0x1030:0x1040]) {
+		t.Errorf("ReadMemory(%#x) = %d, %v, %x", addr+0x20, n, err, got)
+	}
+
+	// Read straddling the end of the file
+	n, err = mem.ReadMemory(got, addr+0x1fe8)
+	if n != 8 || !bytes.Equal(got[:n], data[0x2ff8:]) {
+		t.Errorf("ReadMemory(%#x) = %d, %v, %x", addr+0x1fe8, n, err, got)
+	}
+
+	// Read entirely past the end of the file
+	n, err = mem.ReadMemory(got, addr+0x2000)
+	if n != 0 || err == nil {
+		t.Errorf("ReadMemory(%#x) = %d, %v", addr+0x2000, n, err)
+	}
+}
+
 func withCoreFile(t *testing.T, name, args string) *proc.Target {
 	// This is all very fragile and won't work on hosts with non-default core patterns.
 	// Might be better to check in the binary and core?
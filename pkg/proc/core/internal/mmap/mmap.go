@@ -0,0 +1,56 @@
+// Package mmap maps regions of files into memory, read-only.
+package mmap
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrNotSupported is returned by Map on systems where files can not be
+// memory mapped.
+var ErrNotSupported = errors.New("memory mapping files is not supported on this system")
+
+// Region is a read-only memory mapping of a region of a file.
+type Region struct {
+	// Data is the content of the region, reading it will fault if the file
+	// is truncated while it is mapped.
+	Data []byte
+
+	mapping []byte
+}
+
+// Map maps length bytes of f, starting at offset off, into memory.
+func Map(f *os.File, off int64, length int) (*Region, error) {
+	if length == 0 {
+		return &Region{}, nil
+	}
+	if off < 0 || length < 0 {
+		return nil, errors.New("negative offset or length")
+	}
+	// Accessing a mapping past the end of the file raises SIGBUS, instead
+	// of returning an error.
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if off+int64(length) > fi.Size() {
+		return nil, io.ErrUnexpectedEOF
+	}
+	aligned := off &^ (granularity - 1)
+	mapping, err := mmap(f, aligned, length+int(off-aligned))
+	if err != nil {
+		return nil, err
+	}
+	return &Region{Data: mapping[off-aligned:][:length], mapping: mapping}, nil
+}
+
+// Close unmaps r, r.Data can not be used afterwards.
+func (r *Region) Close() error {
+	if r.mapping == nil {
+		return nil
+	}
+	err := munmap(r.mapping)
+	r.Data, r.mapping = nil, nil
+	return err
+}
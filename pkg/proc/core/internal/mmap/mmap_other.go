@@ -0,0 +1,16 @@
+//go:build !darwin && !freebsd && !linux && !openbsd && !windows
+// +build !darwin,!freebsd,!linux,!openbsd,!windows
+
+package mmap
+
+import "os"
+
+const granularity = 0x1000
+
+func mmap(f *os.File, off int64, length int) ([]byte, error) {
+	return nil, ErrNotSupported
+}
+
+func munmap(b []byte) error {
+	return nil
+}
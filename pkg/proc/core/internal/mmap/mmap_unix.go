@@ -0,0 +1,22 @@
+//go:build darwin || freebsd || linux || openbsd
+// +build darwin freebsd linux openbsd
+
+package mmap
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// granularity is the alignment of the offsets of mappings, the smallest
+// page size of the supported systems.
+const granularity = 0x1000
+
+func mmap(f *os.File, off int64, length int) ([]byte, error) {
+	return unix.Mmap(int(f.Fd()), off, length, unix.PROT_READ, unix.MAP_SHARED)
+}
+
+func munmap(b []byte) error {
+	return unix.Munmap(b)
+}
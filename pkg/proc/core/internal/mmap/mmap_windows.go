@@ -0,0 +1,36 @@
+package mmap
+
+import (
+	"os"
+	"reflect"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// granularity is the alignment of the offsets of views of a file mapping
+// (the allocation granularity).
+const granularity = 0x10000
+
+func mmap(f *os.File, off int64, length int) ([]byte, error) {
+	h, err := windows.CreateFileMapping(windows.Handle(f.Fd()), nil, windows.PAGE_READONLY, 0, 0, nil)
+	if err != nil {
+		return nil, os.NewSyscallError("CreateFileMapping", err)
+	}
+	// The view keeps the file mapping alive.
+	defer windows.CloseHandle(h)
+	addr, err := windows.MapViewOfFile(h, windows.FILE_MAP_READ, uint32(off>>32), uint32(off), uintptr(length))
+	if err != nil {
+		return nil, os.NewSyscallError("MapViewOfFile", err)
+	}
+	var b []byte
+	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&b))
+	hdr.Data = addr
+	hdr.Len = length
+	hdr.Cap = length
+	return b, nil
+}
+
+func munmap(b []byte) error {
+	return windows.UnmapViewOfFile(uintptr(unsafe.Pointer(&b[0])))
+}
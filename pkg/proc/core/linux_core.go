@@ -4,14 +4,18 @@ import (
 	"bytes"
 	"debug/elf"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/go-delve/delve/pkg/elfwriter"
 	"github.com/go-delve/delve/pkg/proc"
 	"github.com/go-delve/delve/pkg/proc/amd64util"
+	"github.com/go-delve/delve/pkg/proc/core/internal/mmap"
 	"github.com/go-delve/delve/pkg/proc/linutil"
 )
 
@@ -53,14 +57,14 @@ func linuxThreadsFromNotes(p *process, notes []*note, machineType elf.Machine) p
 		case elf.NT_PRSTATUS:
 			if machineType == _EM_X86_64 {
 				t := note.Desc.(*linuxPrStatusAMD64)
-				lastThreadAMD = &linuxAMD64Thread{linutil.AMD64Registers{Regs: &t.Reg}, t}
+				lastThreadAMD = &linuxAMD64Thread{regs: linutil.AMD64Registers{Regs: &t.Reg}, t: t}
 				p.Threads[int(t.Pid)] = &thread{lastThreadAMD, p, proc.CommonThread{}}
 				if currentThread == nil {
 					currentThread = p.Threads[int(t.Pid)]
 				}
 			} else if machineType == _EM_AARCH64 {
 				t := note.Desc.(*linuxPrStatusARM64)
-				lastThreadARM = &linuxARM64Thread{linutil.ARM64Registers{Regs: &t.Reg}, t}
+				lastThreadARM = &linuxARM64Thread{regs: linutil.ARM64Registers{Regs: &t.Reg}, t: t}
 				p.Threads[int(t.Pid)] = &thread{lastThreadARM, p, proc.CommonThread{}}
 				if currentThread == nil {
 					currentThread = p.Threads[int(t.Pid)]
@@ -69,13 +73,13 @@ func linuxThreadsFromNotes(p *process, notes []*note, machineType elf.Machine) p
 		case _NT_FPREGSET:
 			if machineType == _EM_AARCH64 {
 				if lastThreadARM != nil {
-					lastThreadARM.regs.Fpregs = note.Desc.(*linutil.ARM64PtraceFpRegs).Decode()
+					lastThreadARM.fpregset = note.Desc.([]byte)
 				}
 			}
 		case _NT_X86_XSTATE:
 			if machineType == _EM_X86_64 {
 				if lastThreadAMD != nil {
-					lastThreadAMD.regs.Fpregs = note.Desc.(*amd64util.AMD64Xstate).Decode()
+					lastThreadAMD.xstate = note.Desc.([]byte)
 				}
 			}
 		case elf.NT_PRPSINFO:
@@ -93,8 +97,13 @@ func linuxThreadsFromNotes(p *process, notes []*note, machineType elf.Machine) p
 // elf_core_dump in http://lxr.free-electrons.com/source/fs/binfmt_elf.c,
 // and, if absolutely desperate, readelf.c from the binutils source.
 func readLinuxOrPlatformIndependentCore(corePath, exePath string) (*process, proc.Thread, error) {
-	coreFile, err := elf.Open(corePath)
+	core, err := os.Open(corePath)
 	if err != nil {
+		return nil, nil, err
+	}
+	coreFile, err := elf.NewFile(core)
+	if err != nil {
+		core.Close()
 		if _, isfmterr := err.(*elf.FormatError); isfmterr && (strings.Contains(err.Error(), elfErrorBadMagicNumber) || strings.Contains(err.Error(), " at offset 0x0: too short")) {
 			// Go >=1.11 and <1.11 produce different errors when reading a non-elf file.
 			return nil, nil, ErrUnrecognizedFormat
@@ -107,7 +116,7 @@ func readLinuxOrPlatformIndependentCore(corePath, exePath string) (*process, pro
 	}
 
 	machineType := coreFile.Machine
-	notes, platformIndependentDelveCore, err := readNotes(coreFile, machineType)
+	notes, platformIndependentDelveCore, err := readNotes(coreFile, core, machineType)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -130,7 +139,10 @@ func readLinuxOrPlatformIndependentCore(corePath, exePath string) (*process, pro
 		}
 	}
 
-	memory := buildMemory(coreFile, exeELF, exe, notes)
+	memory, err := buildMemory(coreFile, core, exeELF, exe, notes)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// TODO support 386
 	var bi *proc.BinaryInfo
@@ -173,14 +185,38 @@ func readLinuxOrPlatformIndependentCore(corePath, exePath string) (*process, pro
 type linuxAMD64Thread struct {
 	regs linutil.AMD64Registers
 	t    *linuxPrStatusAMD64
+
+	// xstate is the descriptor of the NT_X86_XSTATE note of the thread, it
+	// is decoded the first time the registers are requested.
+	xstate     []byte
+	fpregsOnce sync.Once
+	fpregsErr  error
 }
 
 type linuxARM64Thread struct {
 	regs linutil.ARM64Registers
 	t    *linuxPrStatusARM64
+
+	// fpregset is the descriptor of the NT_FPREGSET note of the thread, it
+	// is decoded the first time the registers are requested.
+	fpregset   []byte
+	fpregsOnce sync.Once
+	fpregsErr  error
 }
 
 func (t *linuxAMD64Thread) registers() (proc.Registers, error) {
+	t.fpregsOnce.Do(func() {
+		if t.xstate == nil {
+			return
+		}
+		var fpregs amd64util.AMD64Xstate
+		if t.fpregsErr = amd64util.AMD64XstateRead(t.xstate, true, &fpregs); t.fpregsErr == nil {
+			t.regs.Fpregs = fpregs.Decode()
+		}
+	})
+	if t.fpregsErr != nil {
+		return nil, t.fpregsErr
+	}
 	var r linutil.AMD64Registers
 	r.Regs = t.regs.Regs
 	r.Fpregs = t.regs.Fpregs
@@ -188,6 +224,23 @@ func (t *linuxAMD64Thread) registers() (proc.Registers, error) {
 }
 
 func (t *linuxARM64Thread) registers() (proc.Registers, error) {
+	t.fpregsOnce.Do(func() {
+		if t.fpregset == nil {
+			return
+		}
+		if len(t.fpregset) < _ARM_FP_HEADER_START {
+			t.fpregsErr = fmt.Errorf("NT_FPREGSET note too short (%d bytes)", len(t.fpregset))
+			return
+		}
+		fpregs := &linutil.ARM64PtraceFpRegs{}
+		rdr := bytes.NewReader(t.fpregset[:_ARM_FP_HEADER_START])
+		if t.fpregsErr = binary.Read(rdr, binary.LittleEndian, fpregs.Byte()); t.fpregsErr == nil {
+			t.regs.Fpregs = fpregs.Decode()
+		}
+	})
+	if t.fpregsErr != nil {
+		return nil, t.fpregsErr
+	}
 	var r linutil.ARM64Registers
 	r.Regs = t.regs.Regs
 	r.Fpregs = t.regs.Fpregs
@@ -215,8 +268,10 @@ type note struct {
 	Desc interface{} // Decoded Desc from the
 }
 
-// readNotes reads all the notes from the notes prog in core.
-func readNotes(core *elf.File, machineType elf.Machine) ([]*note, bool, error) {
+// readNotes reads all the notes from the notes prog in core. The
+// descriptors of the notes containing floating point registers are not
+// decoded, see the registers method of the thread types.
+func readNotes(core *elf.File, coreFile *os.File, machineType elf.Machine) ([]*note, bool, error) {
 	var notesProg *elf.Prog
 	for _, prog := range core.Progs {
 		if prog.Type == elf.PT_NOTE {
@@ -224,8 +279,21 @@ func readNotes(core *elf.File, machineType elf.Machine) ([]*note, bool, error) {
 			break
 		}
 	}
+	if notesProg == nil {
+		return nil, false, errors.New("no notes found in core file")
+	}
+
+	// Read the notes in one go, decoding them directly from the file would
+	// take a system call for every field.
+	var buf []byte
+	if region, err := mmap.Map(coreFile, int64(notesProg.Off), int(notesProg.Filesz)); err == nil {
+		defer region.Close()
+		buf = region.Data
+	} else if buf, err = ioutil.ReadAll(notesProg.Open()); err != nil {
+		return nil, false, err
+	}
 
-	r := notesProg.Open()
+	r := bytes.NewReader(buf)
 	hasDelveThread := false
 	hasDelveHeader := false
 	hasElfPrStatus := false
@@ -312,25 +380,8 @@ func readNote(r io.ReadSeeker, machineType elf.Machine) (*note, error) {
 			data.entries = append(data.entries, entry)
 		}
 		note.Desc = data
-	case _NT_X86_XSTATE:
-		if machineType == _EM_X86_64 {
-			var fpregs amd64util.AMD64Xstate
-			if err := amd64util.AMD64XstateRead(desc, true, &fpregs); err != nil {
-				return nil, err
-			}
-			note.Desc = &fpregs
-		}
-	case _NT_AUXV, elfwriter.DelveHeaderNoteType, elfwriter.DelveThreadNodeType:
+	case _NT_AUXV, elfwriter.DelveHeaderNoteType, elfwriter.DelveThreadNodeType, _NT_X86_XSTATE, _NT_FPREGSET:
 		note.Desc = desc
-	case _NT_FPREGSET:
-		if machineType == _EM_AARCH64 {
-			fpregs := &linutil.ARM64PtraceFpRegs{}
-			rdr := bytes.NewReader(desc[:_ARM_FP_HEADER_START])
-			if err := binary.Read(rdr, binary.LittleEndian, fpregs.Byte()); err != nil {
-				return nil, err
-			}
-			note.Desc = fpregs
-		}
 	}
 	if err := skipPadding(r, 4); err != nil {
 		return nil, fmt.Errorf("aligning after desc: %v", err)
@@ -353,18 +404,27 @@ func skipPadding(r io.ReadSeeker, pad int64) error {
 	return nil
 }
 
-func buildMemory(core, exeELF *elf.File, exe io.ReaderAt, notes []*note) proc.MemoryReader {
+// buildMemory returns the memory of the process, the segments of the core
+// file and of the executable are memory mapped the first time they are
+// read.
+func buildMemory(core *elf.File, coreFile *os.File, exeELF *elf.File, exe *os.File, notes []*note) (proc.MemoryReader, error) {
 	memory := &splicedMemory{}
 
+	exeInfo, err := exe.Stat()
+	if err != nil {
+		return nil, err
+	}
+	coreInfo, err := coreFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+
 	// For now, assume all file mappings are to the exe.
 	for _, note := range notes {
 		if note.Type == _NT_FILE {
 			fileNote := note.Desc.(*linuxNTFile)
 			for _, entry := range fileNote.entries {
-				r := &offsetReaderAt{
-					reader: exe,
-					offset: entry.Start - (entry.FileOfs * fileNote.PageSize),
-				}
+				r := newFileMapping(exe, exeInfo.Size(), int64(entry.FileOfs*fileNote.PageSize), entry.Start, entry.End-entry.Start)
 				memory.Add(r, entry.Start, entry.End-entry.Start)
 			}
 
@@ -373,24 +433,28 @@ func buildMemory(core, exeELF *elf.File, exe io.ReaderAt, notes []*note) proc.Me
 
 	// Load memory segments from exe and then from the core file,
 	// allowing the corefile to overwrite previously loaded segments
-	for _, elfFile := range []*elf.File{exeELF, core} {
-		if elfFile == nil {
+	for _, f := range []struct {
+		elfFile *elf.File
+		file    *os.File
+		size    int64
+	}{
+		{exeELF, exe, exeInfo.Size()},
+		{core, coreFile, coreInfo.Size()},
+	} {
+		if f.elfFile == nil {
 			continue
 		}
-		for _, prog := range elfFile.Progs {
+		for _, prog := range f.elfFile.Progs {
 			if prog.Type == elf.PT_LOAD {
 				if prog.Filesz == 0 {
 					continue
 				}
-				r := &offsetReaderAt{
-					reader: prog.ReaderAt,
-					offset: prog.Vaddr,
-				}
+				r := newFileMapping(f.file, f.size, int64(prog.Off), prog.Vaddr, prog.Filesz)
 				memory.Add(r, prog.Vaddr, prog.Filesz)
 			}
 		}
 	}
-	return memory
+	return memory, nil
 }
 
 func findEntryPoint(notes []*note, ptrSize int) uint64 {
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-delve/delve/pkg/elfwriter"
+	"github.com/go-delve/delve/pkg/proc"
+)
+
+const (
+	_MH_CORE    = 0x4
+	_LC_NOTE    = 0x31
+	machoNoteSz = 40 // cmd, cmdsize, data_owner[16], offset, size
+)
+
+// readMachOCore reads a Mach-O core file written by Delve (see
+// proc.(*Target).Dump). The LC_THREAD commands of Mach-O core files do not
+// contain enough information to find the goroutines running on each
+// thread, Delve uses the thread descriptions contained in its LC_NOTE
+// commands instead, which means that core files written by the macOS
+// kernel are not supported.
+func readMachOCore(corePath, exePath string) (*process, proc.Thread, error) {
+	core, err := os.Open(corePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	coreFile, err := macho.NewFile(core)
+	if err != nil {
+		core.Close()
+		if _, isfmterr := err.(*macho.FormatError); isfmterr {
+			return nil, nil, ErrUnrecognizedFormat
+		}
+		return nil, nil, err
+	}
+	if coreFile.Type != _MH_CORE {
+		core.Close()
+		return nil, nil, fmt.Errorf("%s is not a core file", corePath)
+	}
+	coreInfo, err := core.Stat()
+	if err != nil {
+		core.Close()
+		return nil, nil, err
+	}
+
+	var notes []*note
+	for _, load := range coreFile.Loads {
+		raw := load.Raw()
+		if len(raw) < machoNoteSz || coreFile.ByteOrder.Uint32(raw) != _LC_NOTE {
+			continue
+		}
+		var typ elf.NType
+		switch string(bytes.TrimRight(raw[8:24], "\x00")) {
+		case proc.MachODelveHeaderOwner:
+			typ = elfwriter.DelveHeaderNoteType
+		case proc.MachODelveThreadOwner:
+			typ = elfwriter.DelveThreadNodeType
+		default:
+			continue
+		}
+		off, sz := coreFile.ByteOrder.Uint64(raw[24:]), coreFile.ByteOrder.Uint64(raw[32:])
+		desc := make([]byte, sz)
+		if _, err := core.ReadAt(desc, int64(off)); err != nil {
+			core.Close()
+			return nil, nil, fmt.Errorf("could not read note: %v", err)
+		}
+		notes = append(notes, &note{Type: typ, Desc: desc})
+	}
+	if len(notes) == 0 || notes[0].Type != elfwriter.DelveHeaderNoteType {
+		core.Close()
+		return nil, nil, errors.New("Mach-O core file not written by Delve")
+	}
+
+	goos, goarch, err := platformFromNotes(notes)
+	if err != nil {
+		core.Close()
+		return nil, nil, err
+	}
+
+	memory := &splicedMemory{}
+	for _, seg := range coreFile.Loads {
+		seg, ok := seg.(*macho.Segment)
+		if !ok || seg.Filesz == 0 {
+			continue
+		}
+		memory.Add(newFileMapping(core, coreInfo.Size(), int64(seg.Offset), seg.Addr, seg.Filesz), seg.Addr, seg.Filesz)
+	}
+
+	p := &process{
+		mem:         memory,
+		Threads:     map[int]*thread{},
+		bi:          proc.NewBinaryInfo(goos, goarch),
+		breakpoints: proc.NewBreakpointMap(),
+	}
+	currentThread, err := threadsFromDelveNotes(p, notes)
+	return p, currentThread, err
+}
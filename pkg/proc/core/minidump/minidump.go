@@ -21,9 +21,11 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"unicode/utf16"
 	"unsafe"
 
+	"github.com/go-delve/delve/pkg/proc/core/internal/mmap"
 	"github.com/go-delve/delve/pkg/proc/winutil"
 )
 
@@ -318,7 +320,7 @@ const (
 
 // Open reads the minidump file at path and returns it as a Minidump structure.
 func Open(path string, logfn func(fmt string, args ...interface{})) (*Minidump, error) {
-	rawbuf, err := ioutil.ReadFile(path) //TODO(aarzilli): mmap?
+	rawbuf, err := readFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -417,6 +419,23 @@ func Open(path string, logfn func(fmt string, args ...interface{})) (*Minidump,
 	return &mdmp, nil
 }
 
+// readFile returns the contents of the file at path. The file is memory
+// mapped if possible so that opening a large minidump only reads the parts
+// of it that are actually used.
+func readFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err == nil && uint64(fi.Size()) <= uint64(^uint(0)>>1) {
+		if region, err := mmap.Map(f, 0, int(fi.Size())); err == nil {
+			return region.Data, nil
+		}
+	}
+	return ioutil.ReadAll(f)
+}
+
 // decodeUTF16 converts a NUL-terminated UTF16LE string to (non NUL-terminated) UTF8.
 func decodeUTF16(in []byte) string {
 	utf16encoded := []uint16{}
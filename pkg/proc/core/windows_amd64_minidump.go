@@ -1,6 +1,7 @@
 package core
 
 import (
+	"github.com/go-delve/delve/pkg/elfwriter"
 	"github.com/go-delve/delve/pkg/logflags"
 	"github.com/go-delve/delve/pkg/proc"
 	"github.com/go-delve/delve/pkg/proc/core/minidump"
@@ -33,10 +34,24 @@ func readAMD64Minidump(minidumpPath, exePath string) (*process, proc.Thread, err
 		entryPoint = mdmp.Modules[0].BaseOfImage
 	}
 
+	// Minidumps written by Delve can describe targets running on other
+	// operating systems, which are specified by the Delve header.
+	goos := "windows"
+	for i := range mdmp.Streams {
+		stream := &mdmp.Streams[i]
+		if stream.Type != minidump.CommentStreamA {
+			continue
+		}
+		hdrgoos, goarch, err := platformFromNotes([]*note{{Type: elfwriter.DelveHeaderNoteType, Desc: stream.RawData}})
+		if err == nil && goarch == "amd64" {
+			goos = hdrgoos
+		}
+	}
+
 	p := &process{
 		mem:         memory,
 		Threads:     map[int]*thread{},
-		bi:          proc.NewBinaryInfo("windows", "amd64"),
+		bi:          proc.NewBinaryInfo(goos, "amd64"),
 		entryPoint:  entryPoint,
 		breakpoints: proc.NewBreakpointMap(),
 		pid:         int(mdmp.Pid),
@@ -0,0 +1,378 @@
+package proc
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/go-delve/delve/pkg/dwarf/godwarf"
+)
+
+// maxSudogs is the maximum number of sudog structures read from a single
+// list or tree, to protect against corrupted runtime structures.
+const maxSudogs = 100000
+
+// WaitObjectKind is the kind of object a blocked goroutine is waiting on.
+type WaitObjectKind uint8
+
+const (
+	WaitChan WaitObjectKind = iota // channel, the goroutine is executing a channel operation or a select statement
+	WaitSema                       // runtime semaphore, used by sync.Mutex, sync.RWMutex and sync.WaitGroup
+)
+
+// WaitObject is an object a goroutine is waiting on.
+type WaitObject struct {
+	Kind WaitObjectKind
+	Addr uint64 // address of the channel or of the semaphore
+}
+
+// BlockedGoroutine is a goroutine that is part of a deadlock found by
+// FindDeadlocks.
+type BlockedGoroutine struct {
+	G       *G
+	Objects []WaitObject // objects the goroutine is waiting on, more than one for select statements
+	// Wakers are the IDs of the other goroutines that can wake this
+	// goroutine, i.e. the goroutines whose local variables contain a
+	// pointer to one of the objects it is waiting on. All of them are part
+	// of the same deadlock.
+	Wakers []int
+	// Global is true if one of the objects the goroutine is waiting on is
+	// referenced by a package variable, all goroutines are considered
+	// possible wakers.
+	Global bool
+}
+
+// sudogFields contains the offsets of the fields of runtime.sudog.
+type sudogFields struct {
+	size                                      int64
+	g, next, prev, elem, waitlink, c, ptrSize int64
+}
+
+func loadSudogFields(bi *BinaryInfo) (*sudogFields, error) {
+	// +rtype -field sudog.g *g
+	// +rtype -field sudog.next *sudog
+	// +rtype -field sudog.prev *sudog
+	// +rtype -field sudog.elem anytype
+	// +rtype -field sudog.waitlink *sudog
+	// +rtype -field sudog.c anytype
+
+	typ, err := bi.findType("runtime.sudog")
+	if err != nil {
+		return nil, err
+	}
+	st, ok := resolveTypedef(typ).(*godwarf.StructType)
+	if !ok {
+		return nil, errors.New("unexpected type for runtime.sudog")
+	}
+	r := &sudogFields{size: st.Size(), ptrSize: int64(bi.Arch.PtrSize())}
+	for _, f := range []struct {
+		name string
+		off  *int64
+	}{{"g", &r.g}, {"next", &r.next}, {"prev", &r.prev}, {"elem", &r.elem}, {"waitlink", &r.waitlink}, {"c", &r.c}} {
+		var sz int64
+		*f.off, sz, err = scalarFieldOffset(st, f.name)
+		if err != nil {
+			return nil, err
+		}
+		if sz != r.ptrSize {
+			return nil, errors.New("unexpected size for field " + f.name + " of runtime.sudog")
+		}
+	}
+	return r, nil
+}
+
+// sudog is the delve counterpart to runtime.sudog.
+type sudog struct {
+	g, next, prev, elem, waitlink, c uint64
+}
+
+func (sf *sudogFields) read(mem MemoryReadWriter, addr uint64) (*sudog, error) {
+	buf := make([]byte, sf.size)
+	if _, err := mem.ReadMemory(buf, addr); err != nil {
+		return nil, err
+	}
+	ptr := func(off int64) uint64 {
+		return readPtr(buf[off:], int(sf.ptrSize))
+	}
+	return &sudog{g: ptr(sf.g), next: ptr(sf.next), prev: ptr(sf.prev), elem: ptr(sf.elem), waitlink: ptr(sf.waitlink), c: ptr(sf.c)}, nil
+}
+
+// goroutineWaitObjects returns the objects each goroutine is waiting on,
+// indexed by the address of the runtime.g structure: channels are read
+// from the g.waiting list, semaphores from the runtime.semtable tree.
+func goroutineWaitObjects(t *Target, gs []*G) (map[uint64][]WaitObject, error) {
+	bi := t.BinInfo()
+	mem := t.Memory()
+	sf, err := loadSudogFields(bi)
+	if err != nil {
+		return nil, err
+	}
+	r := make(map[uint64][]WaitObject)
+
+	for _, g := range gs {
+		if g.Status != Gwaiting || g.variable == nil {
+			continue
+		}
+		waiting, err := g.variable.structMember("waiting") // +rtype *sudog
+		if err != nil {
+			continue
+		}
+		addr, err := readUintRaw(mem, waiting.Addr, sf.ptrSize)
+		for n := 0; err == nil && addr != 0 && n < maxSudogs; n++ {
+			var sg *sudog
+			sg, err = sf.read(mem, addr)
+			if err != nil {
+				break
+			}
+			if sg.c != 0 {
+				r[g.variable.Addr] = append(r[g.variable.Addr], WaitObject{Kind: WaitChan, Addr: sg.c})
+			}
+			addr = sg.waitlink
+		}
+	}
+
+	// +rtype -var semtable anytype
+	// +rtype -field semaRoot.treap *sudog
+	scope := globalScope(t, bi, bi.Images[0], mem)
+	semtable, err := scope.findGlobal("runtime", "semtable")
+	if err != nil {
+		return r, nil
+	}
+	arr, ok := resolveTypedef(semtable.RealType).(*godwarf.ArrayType)
+	if !ok {
+		return r, nil
+	}
+	elem, ok := resolveTypedef(arr.Type).(*godwarf.StructType)
+	if !ok {
+		return r, nil
+	}
+	var treapOff int64 = -1
+	for _, field := range elem.Field {
+		if field.Name != "root" {
+			continue
+		}
+		if root, ok := resolveTypedef(field.Type).(*godwarf.StructType); ok {
+			if off, _, err := scalarFieldOffset(root, "treap"); err == nil {
+				treapOff = field.ByteOffset + off
+			}
+		}
+	}
+	if treapOff < 0 {
+		return r, nil
+	}
+
+	visited := make(map[uint64]bool)
+	var visit func(addr uint64)
+	visit = func(addr uint64) {
+		if addr == 0 || visited[addr] || len(visited) >= maxSudogs {
+			return
+		}
+		visited[addr] = true
+		sg, err := sf.read(mem, addr)
+		if err != nil {
+			return
+		}
+		// all waiters for the same address are linked through waitlink
+		for w, n := sg, 0; w != nil && n < maxSudogs; n++ {
+			if w.g != 0 {
+				r[w.g] = append(r[w.g], WaitObject{Kind: WaitSema, Addr: sg.elem})
+			}
+			if w.waitlink == 0 {
+				break
+			}
+			w, _ = sf.read(mem, w.waitlink)
+		}
+		visit(sg.prev)
+		visit(sg.next)
+	}
+	for i := int64(0); i < arr.Count; i++ {
+		treap, err := readUintRaw(mem, semtable.Addr+uint64(i*elem.Size()+treapOff), sf.ptrSize)
+		if err != nil {
+			break
+		}
+		visit(treap)
+	}
+	return r, nil
+}
+
+// stackPointers returns the sorted list of pointers contained in the local
+// variables of g.
+func stackPointers(t *Target, g *G, pw *pointerWalker) []uint64 {
+	r := []uint64{}
+	forEachGoroutineVariable(t, g, func(v *Variable, _ Reference) bool {
+		r = appendVariablePointers(r, v, pw)
+		return true
+	})
+	sort.Slice(r, func(i, j int) bool { return r[i] < r[j] })
+	return r
+}
+
+// appendVariablePointers appends the pointers contained in v to r.
+func appendVariablePointers(r []uint64, v *Variable, pw *pointerWalker) []uint64 {
+	if v.Unreadable != nil {
+		return r
+	}
+	if v.Flags&VariableEscaped != 0 {
+		r = append(r, v.Addr)
+	}
+	buf := pw.readVariableMemory(v)
+	if buf == nil {
+		return r
+	}
+	pw.walk(buf, 0, v.DwarfType, "", func(off int64, typ godwarf.Type, _ string) bool {
+		if _, isiface := resolveTypedef(typ).(*godwarf.InterfaceType); isiface {
+			off += int64(pw.ptrSize)
+		}
+		if p := readPtr(buf[off:], pw.ptrSize); p != 0 {
+			r = append(r, p)
+		}
+		return true
+	})
+	return r
+}
+
+// containsPointerInto returns true if the sorted list ptrs contains a
+// pointer in [lo, hi).
+func containsPointerInto(ptrs []uint64, lo, hi uint64) bool {
+	i := sort.Search(len(ptrs), func(i int) bool { return ptrs[i] >= lo })
+	return i < len(ptrs) && ptrs[i] < hi
+}
+
+// FindDeadlocks builds a wait-for graph of the goroutines blocked on
+// channels and on the semaphores used by sync.Mutex, sync.RWMutex and
+// sync.WaitGroup and returns the groups of goroutines that can not make
+// progress.
+// A goroutine is considered able to wake a blocked goroutine if its local
+// variables contain a pointer to the object (or to the heap object
+// containing it) the blocked goroutine is waiting on. A blocked goroutine
+// is deadlocked if all goroutines able to wake it are themselves
+// deadlocked. Since pointers reached indirectly through other heap objects
+// are not followed this is a heuristic.
+// System goroutines are ignored.
+func (t *Target) FindDeadlocks() ([][]*BlockedGoroutine, error) {
+	gs, _, err := GoroutinesInfo(t, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	waits, err := goroutineWaitObjects(t, gs)
+	if err != nil {
+		return nil, err
+	}
+	spans, err := loadHeapSpans(t.BinInfo(), t.Memory())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].base < spans[j].base })
+
+	pw := newPointerWalker(t.BinInfo(), false)
+	globalPtrs := []uint64{}
+	forEachPackageVariable(t, func(v *Variable, _ Reference) bool {
+		globalPtrs = appendVariablePointers(globalPtrs, v, pw)
+		return true
+	})
+	sort.Slice(globalPtrs, func(i, j int) bool { return globalPtrs[i] < globalPtrs[j] })
+
+	userGs := []*G{}
+	for _, g := range gs {
+		if !g.System(t) {
+			userGs = append(userGs, g)
+		}
+	}
+	ptrs := make(map[int][]uint64)
+	for _, g := range userGs {
+		ptrs[g.ID] = stackPointers(t, g, pw)
+	}
+
+	blocked := make(map[int]*BlockedGoroutine)
+	for _, g := range userGs {
+		if g.variable == nil || len(waits[g.variable.Addr]) == 0 {
+			continue
+		}
+		bg := &BlockedGoroutine{G: g, Objects: waits[g.variable.Addr]}
+		for _, obj := range bg.Objects {
+			lo, hi := obj.Addr, obj.Addr+1
+			if s := findHeapSpan(spans, obj.Addr); s != nil {
+				lo = s.base + s.objectIndex(obj.Addr)*s.elemsize
+				hi = lo + s.elemsize
+			}
+			if containsPointerInto(globalPtrs, lo, hi) {
+				bg.Global = true
+			}
+			for _, g2 := range userGs {
+				if g2.ID != g.ID && (bg.Global || containsPointerInto(ptrs[g2.ID], lo, hi)) {
+					bg.Wakers = append(bg.Wakers, g2.ID)
+				}
+			}
+		}
+		sort.Ints(bg.Wakers)
+		bg.Wakers = uniqInts(bg.Wakers)
+		blocked[g.ID] = bg
+	}
+
+	// Remove goroutines that can be woken by a goroutine that isn't
+	// deadlocked until a fixpoint is reached.
+	for changed := true; changed; {
+		changed = false
+		for id, bg := range blocked {
+			for _, w := range bg.Wakers {
+				if blocked[w] == nil {
+					delete(blocked, id)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	// Group deadlocked goroutines in connected components of the wait-for
+	// graph.
+	ids := make([]int, 0, len(blocked))
+	for id := range blocked {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	component := make(map[int]int)
+	r := [][]*BlockedGoroutine{}
+	for _, id := range ids {
+		if _, done := component[id]; done {
+			continue
+		}
+		c := len(r)
+		r = append(r, nil)
+		queue := []int{id}
+		component[id] = c
+		for len(queue) > 0 {
+			cur := blocked[queue[0]]
+			queue = queue[1:]
+			r[c] = append(r[c], cur)
+			neighbors := cur.Wakers
+			for _, other := range ids {
+				for _, w := range blocked[other].Wakers {
+					if w == cur.G.ID {
+						neighbors = append(neighbors, other)
+					}
+				}
+			}
+			for _, n := range neighbors {
+				if _, done := component[n]; !done {
+					component[n] = c
+					queue = append(queue, n)
+				}
+			}
+		}
+		sort.Slice(r[c], func(i, j int) bool { return r[c][i].G.ID < r[c][j].G.ID })
+	}
+	return r, nil
+}
+
+func uniqInts(v []int) []int {
+	if len(v) == 0 {
+		return v
+	}
+	r := v[:1]
+	for _, x := range v[1:] {
+		if x != r[len(r)-1] {
+			r = append(r, x)
+		}
+	}
+	return r
+}
@@ -9,7 +9,7 @@ import (
 // AsmInstruction represents one assembly instruction.
 type AsmInstruction struct {
 	Loc        Location
-	DestLoc    *Location
+	DestLoc    *Location // destination of call and branch instructions, if it can be determined
 	Bytes      []byte
 	Breakpoint bool
 	AtPC       bool
@@ -127,17 +127,70 @@ func Disassemble(mem MemoryReadWriter, regs Registers, breakpoints *BreakpointMa
 	return disassemble(mem, regs, breakpoints, bi, startAddr, endAddr, false)
 }
 
-func disassemble(memrw MemoryReadWriter, regs Registers, breakpoints *BreakpointMap, bi *BinaryInfo, startAddr, endAddr uint64, singleInstr bool) ([]AsmInstruction, error) {
-	var dregs *op.DwarfRegisters
-	if regs != nil {
-		dregs = bi.Arch.RegistersToDwarfRegisters(0, regs)
+// maxDisassembleRawSize is the maximum size of the range disassembled by
+// DisassembleRaw.
+const maxDisassembleRawSize = 1 << 20
+
+// DisassembleRaw disassembles target memory between startAddr and endAddr
+// using only the instruction decoder of the target architecture. Unlike
+// Disassemble the range does not need to belong to a function and it does
+// not need to be entirely readable: decoding stops at the first address
+// that can not be read. This makes it suitable for inspecting JIT
+// generated code, PLT entries and corrupted PC values.
+func DisassembleRaw(memrw MemoryReadWriter, regs Registers, breakpoints *BreakpointMap, bi *BinaryInfo, startAddr, endAddr uint64) ([]AsmInstruction, error) {
+	if startAddr > endAddr {
+		return nil, fmt.Errorf("start address(%x) should be less than end address(%x)", startAddr, endAddr)
+	}
+	if endAddr-startAddr > maxDisassembleRawSize {
+		return nil, fmt.Errorf("can not disassemble more than %d bytes", maxDisassembleRawSize)
+	}
+	mem := make([]byte, int(endAddr-startAddr))
+	n := readMemoryPrefix(memrw, mem, startAddr)
+	if n == 0 && len(mem) > 0 {
+		return nil, fmt.Errorf("could not read memory at %#x", startAddr)
 	}
+	return decodeInstructions(memrw, mem[:n], regs, breakpoints, bi, startAddr, false), nil
+}
+
+// readMemoryPrefix reads as much of the memory starting at addr into buf as
+// possible, stopping at the first page that can not be read. Returns the
+// number of bytes read.
+func readMemoryPrefix(memrw MemoryReadWriter, buf []byte, addr uint64) int {
+	const pageSize = 0x1000
+	if n, err := memrw.ReadMemory(buf, addr); err == nil {
+		return n
+	}
+	n := 0
+	for n < len(buf) {
+		sz := pageSize - int((addr+uint64(n))%pageSize)
+		if sz > len(buf)-n {
+			sz = len(buf) - n
+		}
+		m, err := memrw.ReadMemory(buf[n:n+sz], addr+uint64(n))
+		n += m
+		if err != nil || m != sz {
+			break
+		}
+	}
+	return n
+}
 
+func disassemble(memrw MemoryReadWriter, regs Registers, breakpoints *BreakpointMap, bi *BinaryInfo, startAddr, endAddr uint64, singleInstr bool) ([]AsmInstruction, error) {
 	mem := make([]byte, int(endAddr-startAddr))
 	_, err := memrw.ReadMemory(mem, startAddr)
 	if err != nil {
 		return nil, err
 	}
+	return decodeInstructions(memrw, mem, regs, breakpoints, bi, startAddr, singleInstr), nil
+}
+
+// decodeInstructions decodes the instructions contained in mem, which was
+// read from startAddr of memrw.
+func decodeInstructions(memrw MemoryReadWriter, mem []byte, regs Registers, breakpoints *BreakpointMap, bi *BinaryInfo, startAddr uint64, singleInstr bool) []AsmInstruction {
+	var dregs *op.DwarfRegisters
+	if regs != nil {
+		dregs = bi.Arch.RegistersToDwarfRegisters(0, regs)
+	}
 
 	r := make([]AsmInstruction, 0, len(mem)/int(bi.Arch.MaxInstructionLength()))
 	pc := startAddr
@@ -173,7 +226,33 @@ func disassemble(memrw MemoryReadWriter, regs Registers, breakpoints *Breakpoint
 			break
 		}
 	}
-	return r, nil
+	return r
+}
+
+// pcToDestLoc returns the location of the destination pc of a call or
+// branch instruction.
+func pcToDestLoc(bi *BinaryInfo, pc uint64) *Location {
+	file, line, fn := bi.PCToLine(pc)
+	if fn == nil {
+		return &Location{PC: pc}
+	}
+	return &Location{PC: pc, File: file, Line: line, Fn: fn}
+}
+
+// branchTargetString formats the destination of a branch instruction for
+// the decoders that are implemented in this package.
+func branchTargetString(pc uint64, symLookup func(uint64) (string, uint64)) string {
+	if symLookup != nil {
+		if name, addr := symLookup(pc); name != "" && addr == pc {
+			return fmt.Sprintf("%#x <%s>", pc, name)
+		}
+	}
+	return fmt.Sprintf("%#x", pc)
+}
+
+// signExtend sign extends the bits-wide value v.
+func signExtend(v uint32, bits uint) int64 {
+	return int64(int32(v<<(32-bits)) >> (32 - bits))
 }
 
 // Text will return the assembly instructions in human readable format according to
@@ -37,6 +37,9 @@ type DumpFlags uint16
 
 const (
 	DumpPlatformIndependent DumpFlags = 1 << iota // always use platform-independent notes format
+	DumpNativeFormat                              // use the core file format of the target OS (minidump on windows, Mach-O on macOS) instead of ELF
+	DumpMinidump                                  // always write a minidump
+	DumpMachO                                     // always write a Mach-O core file
 )
 
 // MemoryMapEntry represent a memory mapping in the target process.
@@ -112,6 +115,18 @@ func (t *Target) Dump(out elfwriter.WriteCloserSeeker, flags DumpFlags, state *D
 
 	bi := t.BinInfo()
 
+	// ELF is the native format of every supported OS except windows and
+	// macOS.
+	native := flags&DumpNativeFormat != 0
+	switch {
+	case flags&DumpMinidump != 0 || (native && bi.GOOS == "windows"):
+		t.dumpMinidump(out, state)
+		return
+	case flags&DumpMachO != 0 || (native && bi.GOOS == "darwin"):
+		t.dumpMachO(out, state)
+		return
+	}
+
 	var fhdr elf.FileHeader
 	fhdr.Class = elf.ELFCLASS64
 	fhdr.Data = elf.ELFDATA2LSB
@@ -155,7 +170,7 @@ func (t *Target) Dump(out elfwriter.WriteCloserSeeker, flags DumpFlags, state *D
 	notes = append(notes, elfwriter.Note{
 		Type: elfwriter.DelveHeaderNoteType,
 		Name: "Delve Header",
-		Data: []byte(fmt.Sprintf("%s/%s\n%s\n%s%d\n%s%#x\n", bi.GOOS, bi.Arch.Name, version.DelveVersion.String(), elfwriter.DelveHeaderTargetPidPrefix, t.pid, elfwriter.DelveHeaderEntryPointPrefix, entryPoint)),
+		Data: []byte(t.dumpHeader(entryPoint)),
 	})
 
 	threads := t.ThreadList()
@@ -185,24 +200,12 @@ func (t *Target) Dump(out elfwriter.WriteCloserSeeker, flags DumpFlags, state *D
 		}
 	}
 
-	memmap, err := t.proc.MemoryMap()
+	memmapFilter, err := t.dumpMemoryMap(state)
 	if err != nil {
 		state.setErr(err)
 		return
 	}
 
-	memmapFilter := make([]MemoryMapEntry, 0, len(memmap))
-	memtot := uint64(0)
-	for i := range memmap {
-		mme := &memmap[i]
-		if t.shouldDumpMemory(mme) {
-			memmapFilter = append(memmapFilter, *mme)
-			memtot += mme.Size
-		}
-	}
-
-	state.setMemTotal(memtot)
-
 	for i := range memmapFilter {
 		mme := &memmapFilter[i]
 		if w.Err != nil {
@@ -226,6 +229,13 @@ func (t *Target) Dump(out elfwriter.WriteCloserSeeker, flags DumpFlags, state *D
 	state.Mutex.Unlock()
 }
 
+// dumpHeader returns the contents of the Delve header note, which
+// describes the target.
+func (t *Target) dumpHeader(entryPoint uint64) string {
+	bi := t.BinInfo()
+	return fmt.Sprintf("%s/%s\n%s\n%s%d\n%s%#x\n", bi.GOOS, bi.Arch.Name, version.DelveVersion.String(), elfwriter.DelveHeaderTargetPidPrefix, t.pid, elfwriter.DelveHeaderEntryPointPrefix, entryPoint)
+}
+
 // dumpThreadNotes appends notes describing a thread (thread id and its
 // registers) using a platform-independent format.
 func (t *Target) dumpThreadNotes(notes []elfwriter.Note, state *DumpState, th Thread) []elfwriter.Note {
@@ -288,6 +298,28 @@ func (t *Target) dumpThreadNotes(notes []elfwriter.Note, state *DumpState, th Th
 	})
 }
 
+// dumpMemoryMap returns the memory mappings that should be saved in the
+// core file and sets the total amount of memory to dump in state.
+func (t *Target) dumpMemoryMap(state *DumpState) ([]MemoryMapEntry, error) {
+	memmap, err := t.proc.MemoryMap()
+	if err != nil {
+		return nil, err
+	}
+
+	memmapFilter := make([]MemoryMapEntry, 0, len(memmap))
+	memtot := uint64(0)
+	for i := range memmap {
+		mme := &memmap[i]
+		if t.shouldDumpMemory(mme) {
+			memmapFilter = append(memmapFilter, *mme)
+			memtot += mme.Size
+		}
+	}
+
+	state.setMemTotal(memtot)
+	return memmapFilter, nil
+}
+
 func (t *Target) dumpMemory(state *DumpState, w *elfwriter.Writer, mme *MemoryMapEntry) {
 	var flags elf.ProgFlag
 	if mme.Read {
@@ -311,18 +343,23 @@ func (t *Target) dumpMemory(state *DumpState, w *elfwriter.Writer, mme *MemoryMa
 		Align:  0,
 	})
 
+	t.copyMemory(state, mme, func(buf []byte) error {
+		w.Write(buf)
+		return w.Err
+	})
+}
+
+// copyMemory reads the memory described by mme and passes it to write, in
+// chunks. Returns false if the dump was canceled or write failed.
+func (t *Target) copyMemory(state *DumpState, mme *MemoryMapEntry, write func([]byte) error) bool {
 	buf := make([]byte, 1024*1024)
 	addr := mme.Addr
 	sz := mme.Size
 	mem := t.Memory()
 
 	for sz > 0 {
-		if w.Err != nil {
-			state.setErr(fmt.Errorf("error writing to output file: %v", w.Err))
-			return
-		}
 		if state.isCanceled() {
-			return
+			return false
 		}
 		chunk := buf
 		if uint64(len(chunk)) > sz {
@@ -336,13 +373,17 @@ func (t *Target) dumpMemory(state *DumpState, w *elfwriter.Writer, mme *MemoryMa
 		// (*ProcessInternal).MemoryMap gave us a bad mapping that can't be read
 		// and the behavior that's maximally useful to the user is to generate an
 		// incomplete dump.
-		w.Write(chunk)
+		if werr := write(chunk); werr != nil {
+			state.setErr(fmt.Errorf("error writing to output file: %v", werr))
+			return false
+		}
 		addr += uint64(len(chunk))
 		sz -= uint64(len(chunk))
 		if err == nil {
 			state.memDone(uint64(len(chunk)))
 		}
 	}
+	return true
 }
 
 func (t *Target) shouldDumpMemory(mme *MemoryMapEntry) bool {
@@ -0,0 +1,251 @@
+package proc
+
+import (
+	"bytes"
+	"debug/macho"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/go-delve/delve/pkg/dwarf/regnum"
+	"github.com/go-delve/delve/pkg/elfwriter"
+)
+
+// Mach-O core file format, as written by the macOS kernel and by lldb's
+// process save-core: a header, one LC_THREAD command for each thread, one
+// LC_SEGMENT_64 command for each memory mapping and then the contents of
+// memory, page aligned.
+// Delve also writes LC_NOTE commands containing the Delve header and, for
+// each thread, the same description written in the notes of
+// platform-independent ELF core files (see dumpThreadNotes), since thread
+// states do not include thread IDs or the address of TLS.
+
+const (
+	machoTypeCore    = 0x4  // MH_CORE
+	machoLoadCmdNote = 0x31 // LC_NOTE
+
+	machoX86ThreadState64 = 4 // x86_THREAD_STATE64
+	machoARMThreadState64 = 6 // ARM_THREAD_STATE64
+
+	machoProtRead    = 0x1
+	machoProtWrite   = 0x2
+	machoProtExecute = 0x4
+
+	// MachODelveHeaderOwner is the data owner of the LC_NOTE containing the
+	// Delve header.
+	MachODelveHeaderOwner = "delve header"
+	// MachODelveThreadOwner is the data owner of the LC_NOTE commands
+	// describing threads.
+	MachODelveThreadOwner = "delve thread"
+)
+
+type machoHeader64 struct {
+	macho.FileHeader
+	Reserved uint32
+}
+
+type machoThreadHeader struct {
+	Cmd    macho.LoadCmd
+	Len    uint32
+	Flavor uint32
+	Count  uint32
+}
+
+type machoNote struct {
+	Cmd       uint32
+	Len       uint32
+	DataOwner [16]byte
+	Offset    uint64
+	Size      uint64
+}
+
+// machoAMD64ThreadState is the order of the registers in x86_THREAD_STATE64.
+var machoAMD64ThreadState = [...]uint64{
+	regnum.AMD64_Rax, regnum.AMD64_Rbx, regnum.AMD64_Rcx, regnum.AMD64_Rdx,
+	regnum.AMD64_Rdi, regnum.AMD64_Rsi, regnum.AMD64_Rbp, regnum.AMD64_Rsp,
+	regnum.AMD64_R8, regnum.AMD64_R9, regnum.AMD64_R10, regnum.AMD64_R11,
+	regnum.AMD64_R12, regnum.AMD64_R13, regnum.AMD64_R14, regnum.AMD64_R15,
+	regnum.AMD64_Rip, regnum.AMD64_Rflags, regnum.AMD64_Cs, regnum.AMD64_Fs,
+	regnum.AMD64_Gs,
+}
+
+// dumpMachO writes a Mach-O core file of the target to out.
+func (t *Target) dumpMachO(out elfwriter.WriteCloserSeeker, state *DumpState) {
+	bi := t.BinInfo()
+
+	var fhdr machoHeader64
+	fhdr.Magic = macho.Magic64
+	fhdr.Type = machoTypeCore
+	var pageSize uint64
+	switch bi.Arch.Name {
+	case "amd64":
+		fhdr.Cpu = macho.CpuAmd64
+		fhdr.SubCpu = 3 // CPU_SUBTYPE_X86_64_ALL
+		pageSize = 0x1000
+	case "arm64":
+		fhdr.Cpu = macho.CpuArm64
+		fhdr.SubCpu = 0 // CPU_SUBTYPE_ARM64_ALL
+		pageSize = 0x4000
+	default:
+		state.setErr(fmt.Errorf("Mach-O core files are not supported on %s/%s", bi.GOOS, bi.Arch.Name))
+		return
+	}
+
+	entryPoint, err := t.EntryPoint()
+	if err != nil {
+		state.setErr(err)
+		return
+	}
+
+	threads := t.ThreadList()
+	state.setThreadsTotal(len(threads))
+
+	var cmds bytes.Buffer
+	var threadNotes []elfwriter.Note
+	for _, th := range threads {
+		if state.isCanceled() {
+			return
+		}
+		regs, err := th.Registers()
+		if err != nil {
+			state.setErr(err)
+			continue
+		}
+		flavor, threadState := machoThreadState(bi, regs)
+		binary.Write(&cmds, binary.LittleEndian, &machoThreadHeader{
+			Cmd:    macho.LoadCmdThread,
+			Len:    uint32(binary.Size(machoThreadHeader{}) + len(threadState)),
+			Flavor: flavor,
+			Count:  uint32(len(threadState) / 4),
+		})
+		cmds.Write(threadState)
+		fhdr.Ncmd++
+		threadNotes = t.dumpThreadNotes(threadNotes, state, th)
+		state.threadDone()
+	}
+
+	memmap, err := t.dumpMemoryMap(state)
+	if err != nil {
+		state.setErr(err)
+		return
+	}
+
+	var notes bytes.Buffer
+	notesOwners := []string{MachODelveHeaderOwner}
+	notesData := [][]byte{[]byte(t.dumpHeader(entryPoint))}
+	for _, note := range threadNotes {
+		notesOwners = append(notesOwners, MachODelveThreadOwner)
+		notesData = append(notesData, note.Data)
+	}
+
+	hdrsz := uint64(binary.Size(fhdr))
+	cmdsz := uint64(cmds.Len()) + uint64(len(notesData)*binary.Size(machoNote{})) + uint64(len(memmap)*binary.Size(macho.Segment64{}))
+	off := hdrsz + cmdsz
+
+	for i := range notesData {
+		note := machoNote{Cmd: machoLoadCmdNote, Len: uint32(binary.Size(machoNote{})), Offset: off, Size: uint64(len(notesData[i]))}
+		copy(note.DataOwner[:], notesOwners[i])
+		binary.Write(&cmds, binary.LittleEndian, &note)
+		fhdr.Ncmd++
+		notes.Write(notesData[i])
+		off += uint64(len(notesData[i]))
+	}
+
+	offs := make([]uint64, len(memmap))
+	for i := range memmap {
+		mme := &memmap[i]
+		off = (off + pageSize - 1) &^ (pageSize - 1)
+		offs[i] = off
+		seg := macho.Segment64{
+			Cmd:    macho.LoadCmdSegment64,
+			Len:    uint32(binary.Size(macho.Segment64{})),
+			Addr:   mme.Addr,
+			Memsz:  mme.Size,
+			Offset: off,
+			Filesz: mme.Size,
+			Prot:   machoProtection(mme),
+		}
+		seg.Maxprot = seg.Prot
+		binary.Write(&cmds, binary.LittleEndian, &seg)
+		fhdr.Ncmd++
+		off += mme.Size
+	}
+	fhdr.Cmdsz = uint32(cmds.Len())
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, &fhdr)
+	buf.Write(cmds.Bytes())
+	buf.Write(notes.Bytes())
+
+	write := func(b []byte) error {
+		_, err := out.Write(b)
+		return err
+	}
+	if err := write(buf.Bytes()); err != nil {
+		state.setErr(fmt.Errorf("error writing to output file: %v", err))
+		return
+	}
+
+	cur := uint64(buf.Len())
+	for i := range memmap {
+		if pad := offs[i] - cur; pad > 0 {
+			if err := write(make([]byte, pad)); err != nil {
+				state.setErr(fmt.Errorf("error writing to output file: %v", err))
+				return
+			}
+		}
+		if !t.copyMemory(state, &memmap[i], write) {
+			return
+		}
+		cur = offs[i] + memmap[i].Size
+	}
+
+	state.Mutex.Lock()
+	state.AllDone = true
+	state.Mutex.Unlock()
+}
+
+// machoThreadState returns the flavor and the contents of the thread state
+// of a LC_THREAD command describing regs.
+func machoThreadState(bi *BinaryInfo, regs Registers) (uint32, []byte) {
+	dregs := bi.Arch.RegistersToDwarfRegisters(0, regs)
+	var buf bytes.Buffer
+	switch bi.Arch.Name {
+	case "amd64":
+		for _, n := range machoAMD64ThreadState {
+			binary.Write(&buf, binary.LittleEndian, dregs.Uint64Val(n))
+		}
+		return machoX86ThreadState64, buf.Bytes()
+	case "arm64":
+		// x0 through x28, fp, lr, sp, pc
+		for n := uint64(regnum.ARM64_X0); n <= regnum.ARM64_PC; n++ {
+			binary.Write(&buf, binary.LittleEndian, dregs.Uint64Val(n))
+		}
+		var cpsr uint32
+		if regsv, err := regs.Slice(false); err == nil {
+			for _, reg := range regsv {
+				if name := strings.ToLower(reg.Name); name == "cpsr" || name == "pstate" {
+					cpsr = uint32(reg.Reg.Uint64Val)
+				}
+			}
+		}
+		binary.Write(&buf, binary.LittleEndian, cpsr)
+		binary.Write(&buf, binary.LittleEndian, uint32(0)) // padding
+		return machoARMThreadState64, buf.Bytes()
+	}
+	panic("not implemented")
+}
+
+func machoProtection(mme *MemoryMapEntry) uint32 {
+	var prot uint32
+	if mme.Read {
+		prot |= machoProtRead
+	}
+	if mme.Write {
+		prot |= machoProtWrite
+	}
+	if mme.Exec {
+		prot |= machoProtExecute
+	}
+	return prot
+}
@@ -0,0 +1,454 @@
+package proc
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf16"
+
+	"github.com/go-delve/delve/pkg/dwarf/regnum"
+	"github.com/go-delve/delve/pkg/elfwriter"
+)
+
+// Minidump file format, see:
+// https://docs.microsoft.com/en-us/windows/win32/api/minidumpapiset/
+// Only the subset needed to describe a process (threads, modules and
+// memory) is written, the same subset read by pkg/proc/core/minidump.
+
+const (
+	minidumpSignature = 0x504d444d // "MDMP"
+	minidumpVersion   = 0xa793
+
+	// MiniDumpWithFullMemory | MiniDumpWithFullMemoryInfo
+	minidumpFlags = 0x00000002 | 0x00000800
+
+	minidumpThreadListStream     = 3
+	minidumpModuleListStream     = 4
+	minidumpSystemInfoStream     = 7
+	minidumpMemory64ListStream   = 9
+	minidumpCommentStreamA       = 10
+	minidumpMiscInfoStream       = 15
+	minidumpMemoryInfoListStream = 16
+
+	minidumpProcessorArchitectureAMD64 = 9
+	minidumpPlatformWin32NT            = 2
+	minidumpMiscProcessID              = 0x1
+
+	// CONTEXT_AMD64 | CONTEXT_CONTROL | CONTEXT_INTEGER | CONTEXT_SEGMENTS | CONTEXT_FLOATING_POINT
+	minidumpContextAMD64Flags = 0x10000f
+
+	minidumpMemCommit  = 0x1000
+	minidumpMemPrivate = 0x20000
+	minidumpMemImage   = 0x1000000
+
+	minidumpPageNoAccess         = 0x01
+	minidumpPageReadonly         = 0x02
+	minidumpPageReadWrite        = 0x04
+	minidumpPageExecute          = 0x10
+	minidumpPageExecuteRead      = 0x20
+	minidumpPageExecuteReadWrite = 0x40
+)
+
+type minidumpHeader struct {
+	Signature          uint32
+	Version            uint32
+	NumberOfStreams    uint32
+	StreamDirectoryRva uint32
+	CheckSum           uint32
+	TimeDateStamp      uint32
+	Flags              uint64
+}
+
+type minidumpLocation struct {
+	DataSize uint32
+	Rva      uint32
+}
+
+type minidumpDirectory struct {
+	StreamType uint32
+	Location   minidumpLocation
+}
+
+type minidumpSystemInfo struct {
+	ProcessorArchitecture uint16
+	ProcessorLevel        uint16
+	ProcessorRevision     uint16
+	NumberOfProcessors    uint8
+	ProductType           uint8
+	MajorVersion          uint32
+	MinorVersion          uint32
+	BuildNumber           uint32
+	PlatformID            uint32
+	CSDVersionRva         uint32
+	SuiteMask             uint16
+	Reserved2             uint16
+	CPU                   [24]byte
+}
+
+type minidumpMiscInfo struct {
+	SizeOfInfo        uint32
+	Flags1            uint32
+	ProcessID         uint32
+	ProcessCreateTime uint32
+	ProcessUserTime   uint32
+	ProcessKernelTime uint32
+}
+
+type minidumpThread struct {
+	ThreadID      uint32
+	SuspendCount  uint32
+	PriorityClass uint32
+	Priority      uint32
+	Teb           uint64
+	StackStart    uint64
+	Stack         minidumpLocation
+	Context       minidumpLocation
+}
+
+type minidumpModule struct {
+	BaseOfImage   uint64
+	SizeOfImage   uint32
+	CheckSum      uint32
+	TimeDateStamp uint32
+	ModuleNameRva uint32
+	VersionInfo   [13]uint32
+	CvRecord      minidumpLocation
+	MiscRecord    minidumpLocation
+	Reserved0     uint64
+	Reserved1     uint64
+}
+
+type minidumpMemoryInfoListHeader struct {
+	SizeOfHeader    uint32
+	SizeOfEntry     uint32
+	NumberOfEntries uint64
+}
+
+type minidumpMemoryInfo struct {
+	BaseAddress       uint64
+	AllocationBase    uint64
+	AllocationProtect uint32
+	_                 uint32
+	RegionSize        uint64
+	State             uint32
+	Protect           uint32
+	Type              uint32
+	_                 uint32
+}
+
+type minidumpMemoryDescriptor64 struct {
+	StartOfMemoryRange uint64
+	DataSize           uint64
+}
+
+// minidumpContextAMD64 is the CONTEXT structure of windows/amd64.
+type minidumpContextAMD64 struct {
+	Home         [6]uint64
+	ContextFlags uint32
+	MxCsr        uint32
+	SegCs        uint16
+	SegDs        uint16
+	SegEs        uint16
+	SegFs        uint16
+	SegGs        uint16
+	SegSs        uint16
+	EFlags       uint32
+	Dr           [6]uint64
+	Gpr          [16]uint64 // Rax, Rcx, Rdx, Rbx, Rsp, Rbp, Rsi, Rdi, R8 through R15
+	Rip          uint64
+
+	FltSave struct {
+		ControlWord    uint16
+		StatusWord     uint16
+		TagWord        uint8
+		Reserved1      uint8
+		ErrorOpcode    uint16
+		ErrorOffset    uint32
+		ErrorSelector  uint16
+		Reserved2      uint16
+		DataOffset     uint32
+		DataSelector   uint16
+		Reserved3      uint16
+		MxCsr          uint32
+		MxCsrMask      uint32
+		FloatRegisters [8][16]byte
+		XMMRegisters   [16][16]byte
+		Reserved4      [96]byte
+	}
+
+	VectorRegister       [26][16]byte
+	VectorControl        uint64
+	DebugControl         uint64
+	LastBranchToRip      uint64
+	LastBranchFromRip    uint64
+	LastExceptionToRip   uint64
+	LastExceptionFromRip uint64
+}
+
+// minidumpAMD64GprOrder is the order of the general purpose registers in
+// minidumpContextAMD64.Gpr.
+var minidumpAMD64GprOrder = [...]uint64{
+	regnum.AMD64_Rax, regnum.AMD64_Rcx, regnum.AMD64_Rdx, regnum.AMD64_Rbx,
+	regnum.AMD64_Rsp, regnum.AMD64_Rbp, regnum.AMD64_Rsi, regnum.AMD64_Rdi,
+	regnum.AMD64_R8, regnum.AMD64_R9, regnum.AMD64_R10, regnum.AMD64_R11,
+	regnum.AMD64_R12, regnum.AMD64_R13, regnum.AMD64_R14, regnum.AMD64_R15,
+}
+
+// minidumpBuffer accumulates the part of a minidump that precedes the
+// contents of memory.
+type minidumpBuffer struct {
+	bytes.Buffer
+}
+
+func (buf *minidumpBuffer) here() uint32 {
+	return uint32(buf.Len())
+}
+
+func (buf *minidumpBuffer) write(v interface{}) minidumpLocation {
+	loc := minidumpLocation{Rva: buf.here()}
+	_ = binary.Write(buf, binary.LittleEndian, v)
+	loc.DataSize = buf.here() - loc.Rva
+	return loc
+}
+
+// reserve writes the zero value of v and returns a function that
+// overwrites it with v.
+func (buf *minidumpBuffer) reserve(v interface{}) func(v interface{}) {
+	off := buf.here()
+	_ = binary.Write(buf, binary.LittleEndian, v)
+	return func(v interface{}) {
+		var b bytes.Buffer
+		_ = binary.Write(&b, binary.LittleEndian, v)
+		copy(buf.Bytes()[off:], b.Bytes())
+	}
+}
+
+func (buf *minidumpBuffer) align(n uint32) {
+	for buf.here()%n != 0 {
+		buf.WriteByte(0)
+	}
+}
+
+// writeString writes a MINIDUMP_STRING.
+func (buf *minidumpBuffer) writeString(s string) uint32 {
+	rva := buf.here()
+	u := utf16.Encode([]rune(s))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(2*len(u)))
+	_ = binary.Write(buf, binary.LittleEndian, append(u, 0))
+	return rva
+}
+
+// dumpMinidump writes a minidump of the target to out.
+func (t *Target) dumpMinidump(out elfwriter.WriteCloserSeeker, state *DumpState) {
+	bi := t.BinInfo()
+	if bi.Arch.Name != "amd64" {
+		state.setErr(fmt.Errorf("minidumps are not supported on %s/%s", bi.GOOS, bi.Arch.Name))
+		return
+	}
+
+	entryPoint, err := t.EntryPoint()
+	if err != nil {
+		state.setErr(err)
+		return
+	}
+
+	threads := t.ThreadList()
+	state.setThreadsTotal(len(threads))
+
+	var buf minidumpBuffer
+	streams := make([]minidumpDirectory, 0, 7)
+
+	buf.write(&minidumpHeader{
+		Signature:          minidumpSignature,
+		Version:            minidumpVersion,
+		NumberOfStreams:    uint32(cap(streams)),
+		StreamDirectoryRva: uint32(binary.Size(minidumpHeader{})),
+		TimeDateStamp:      uint32(time.Now().Unix()),
+		Flags:              minidumpFlags,
+	})
+	setDirectory := buf.reserve(make([]minidumpDirectory, cap(streams)))
+
+	sysinfo := &minidumpSystemInfo{
+		ProcessorArchitecture: minidumpProcessorArchitectureAMD64,
+		PlatformID:            minidumpPlatformWin32NT,
+		CSDVersionRva:         buf.writeString(""),
+	}
+	streams = append(streams, minidumpDirectory{minidumpSystemInfoStream, buf.write(sysinfo)})
+
+	streams = append(streams, minidumpDirectory{minidumpMiscInfoStream, buf.write(&minidumpMiscInfo{
+		SizeOfInfo: uint32(binary.Size(minidumpMiscInfo{})),
+		Flags1:     minidumpMiscProcessID,
+		ProcessID:  uint32(t.pid),
+	})})
+
+	commentRva := buf.here()
+	buf.WriteString(t.dumpHeader(entryPoint))
+	streams = append(streams, minidumpDirectory{minidumpCommentStreamA, minidumpLocation{buf.here() - commentRva, commentRva}})
+
+	mdthreads := make([]minidumpThread, 0, len(threads))
+	for _, th := range threads {
+		if state.isCanceled() {
+			return
+		}
+		regs, err := th.Registers()
+		if err != nil {
+			state.setErr(err)
+			continue
+		}
+		buf.align(16)
+		mdthreads = append(mdthreads, minidumpThread{
+			ThreadID:   uint32(th.ThreadID()),
+			Teb:        regs.TLS(),
+			StackStart: regs.SP(),
+			Context:    buf.write(minidumpAMD64Context(bi, regs)),
+		})
+		state.threadDone()
+	}
+	threadListLoc := buf.write(uint32(len(mdthreads)))
+	// The stacks of the threads are filled in once the position of the
+	// contents of memory is known.
+	setThreadList := buf.reserve(mdthreads)
+	threadListLoc.DataSize += uint32(binary.Size(mdthreads))
+	streams = append(streams, minidumpDirectory{minidumpThreadListStream, threadListLoc})
+
+	module := minidumpModule{BaseOfImage: entryPoint, ModuleNameRva: buf.writeString(bi.Images[0].Path)}
+	if f, err := pe.Open(bi.Images[0].Path); err == nil {
+		module.TimeDateStamp = f.FileHeader.TimeDateStamp
+		if opth, ok := f.OptionalHeader.(*pe.OptionalHeader64); ok {
+			module.SizeOfImage = opth.SizeOfImage
+			module.CheckSum = opth.CheckSum
+		}
+		f.Close()
+	}
+	modloc := buf.write(uint32(1))
+	modloc.DataSize += buf.write(&module).DataSize
+	streams = append(streams, minidumpDirectory{minidumpModuleListStream, modloc})
+
+	memmap, err := t.dumpMemoryMap(state)
+	if err != nil {
+		state.setErr(err)
+		return
+	}
+
+	meminfos := make([]minidumpMemoryInfo, len(memmap))
+	for i := range memmap {
+		mme := &memmap[i]
+		meminfo := &meminfos[i]
+		meminfo.BaseAddress = mme.Addr
+		meminfo.AllocationBase = mme.Addr
+		meminfo.RegionSize = mme.Size
+		meminfo.State = minidumpMemCommit
+		meminfo.Protect = minidumpProtection(mme)
+		meminfo.AllocationProtect = meminfo.Protect
+		meminfo.Type = minidumpMemPrivate
+		if mme.Filename != "" {
+			meminfo.Type = minidumpMemImage
+		}
+	}
+	meminfoloc := buf.write(&minidumpMemoryInfoListHeader{
+		SizeOfHeader:    uint32(binary.Size(minidumpMemoryInfoListHeader{})),
+		SizeOfEntry:     uint32(binary.Size(minidumpMemoryInfo{})),
+		NumberOfEntries: uint64(len(meminfos)),
+	})
+	meminfoloc.DataSize += buf.write(meminfos).DataSize
+	streams = append(streams, minidumpDirectory{minidumpMemoryInfoListStream, meminfoloc})
+
+	descrs := make([]minidumpMemoryDescriptor64, len(memmap))
+	for i := range memmap {
+		descrs[i] = minidumpMemoryDescriptor64{memmap[i].Addr, memmap[i].Size}
+	}
+	memlistRva := buf.here()
+	memBase := uint64(memlistRva) + 16 + uint64(binary.Size(descrs))
+	buf.write(uint64(len(descrs)))
+	buf.write(memBase)
+	buf.write(descrs)
+	streams = append(streams, minidumpDirectory{minidumpMemory64ListStream, minidumpLocation{buf.here() - memlistRva, memlistRva}})
+
+	off := memBase
+	for i := range memmap {
+		mme := &memmap[i]
+		for j := range mdthreads {
+			th := &mdthreads[j]
+			if th.StackStart < mme.Addr || th.StackStart >= mme.Addr+mme.Size || off+mme.Size > math.MaxUint32 {
+				continue
+			}
+			th.Stack = minidumpLocation{DataSize: uint32(mme.Addr + mme.Size - th.StackStart), Rva: uint32(off + th.StackStart - mme.Addr)}
+		}
+		off += mme.Size
+	}
+	for j := range mdthreads {
+		if mdthreads[j].Stack.Rva == 0 {
+			mdthreads[j].StackStart = 0
+		}
+	}
+	setThreadList(mdthreads)
+	setDirectory(streams)
+
+	if _, err := out.Write(buf.Bytes()); err != nil {
+		state.setErr(fmt.Errorf("error writing to output file: %v", err))
+		return
+	}
+
+	for i := range memmap {
+		ok := t.copyMemory(state, &memmap[i], func(b []byte) error {
+			_, err := out.Write(b)
+			return err
+		})
+		if !ok {
+			return
+		}
+	}
+
+	state.Mutex.Lock()
+	state.AllDone = true
+	state.Mutex.Unlock()
+}
+
+// minidumpAMD64Context converts regs to a windows/amd64 CONTEXT.
+func minidumpAMD64Context(bi *BinaryInfo, regs Registers) *minidumpContextAMD64 {
+	dregs := bi.Arch.RegistersToDwarfRegisters(0, regs)
+	ctx := &minidumpContextAMD64{ContextFlags: minidumpContextAMD64Flags}
+	for i, n := range minidumpAMD64GprOrder {
+		ctx.Gpr[i] = dregs.Uint64Val(n)
+	}
+	ctx.Rip = dregs.Uint64Val(regnum.AMD64_Rip)
+	ctx.EFlags = uint32(dregs.Uint64Val(regnum.AMD64_Rflags))
+	ctx.SegCs = uint16(dregs.Uint64Val(regnum.AMD64_Cs))
+	ctx.SegDs = uint16(dregs.Uint64Val(regnum.AMD64_Ds))
+	ctx.SegEs = uint16(dregs.Uint64Val(regnum.AMD64_Es))
+	ctx.SegFs = uint16(dregs.Uint64Val(regnum.AMD64_Fs))
+	ctx.SegGs = uint16(dregs.Uint64Val(regnum.AMD64_Gs))
+	ctx.SegSs = uint16(dregs.Uint64Val(regnum.AMD64_Ss))
+
+	ctx.MxCsr = uint32(dregs.Uint64Val(regnum.AMD64_MXCSR))
+	ctx.FltSave.MxCsr = ctx.MxCsr
+	ctx.FltSave.ControlWord = uint16(dregs.Uint64Val(regnum.AMD64_CW))
+	ctx.FltSave.StatusWord = uint16(dregs.Uint64Val(regnum.AMD64_SW))
+	for i := range ctx.FltSave.FloatRegisters {
+		copy(ctx.FltSave.FloatRegisters[i][:], dregs.Bytes(uint64(regnum.AMD64_ST0+i)))
+	}
+	for i := range ctx.FltSave.XMMRegisters {
+		copy(ctx.FltSave.XMMRegisters[i][:], dregs.Bytes(uint64(regnum.AMD64_XMM0+i)))
+	}
+	return ctx
+}
+
+func minidumpProtection(mme *MemoryMapEntry) uint32 {
+	switch {
+	case mme.Exec && mme.Write:
+		return minidumpPageExecuteReadWrite
+	case mme.Exec && mme.Read:
+		return minidumpPageExecuteRead
+	case mme.Exec:
+		return minidumpPageExecute
+	case mme.Write:
+		return minidumpPageReadWrite
+	case mme.Read:
+		return minidumpPageReadonly
+	default:
+		return minidumpPageNoAccess
+	}
+}
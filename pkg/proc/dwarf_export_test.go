@@ -7,6 +7,7 @@ import (
 
 // PackageVars returns bi.packageVars (for tests)
 func (bi *BinaryInfo) PackageVars() []packageVar {
+	bi.loadDeferredTypes()
 	return bi.packageVars
 }
 
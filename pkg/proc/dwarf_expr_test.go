@@ -156,7 +156,6 @@ func TestDwarfExprComposite(t *testing.T) {
 		"pair.v":  0x5678,
 		"n":       42,
 		"pair2.k": 0x8765,
-		"pair2.v": 0,
 	}
 
 	const stringVal = "this is a string"
@@ -219,6 +218,13 @@ func TestDwarfExprComposite(t *testing.T) {
 
 	dwarfExprCheck(t, scope, testCases)
 
+	// The second piece of pair2 is not available
+	pair2v, err := scope.EvalExpression("pair2.v", normalLoadConfig)
+	assertNoError(err, t, "EvalExpression(pair2.v)")
+	if pair2v.Unreadable == nil || pair2v.Unreadable.Error() != "optimized out" {
+		t.Errorf("expected pair2.v to be optimized out, got %v (unreadable: %v)", pair2v.Value, pair2v.Unreadable)
+	}
+
 	thevar, err := scope.EvalExpression("s", normalLoadConfig)
 	assertNoError(err, t, fmt.Sprintf("EvalExpression(%s)", "s"))
 	if thevar.Unreadable != nil {
@@ -320,7 +326,7 @@ func TestIssue1419(t *testing.T) {
 	if va.Unreadable == nil {
 		t.Fatalf("expected 'a' to be unreadable but it wasn't")
 	}
-	if va.Unreadable.Error() != "could not read 8 bytes from register 2 (size: 0)" {
+	if va.Unreadable.Error() != "optimized out" {
 		t.Fatalf("wrong unreadable reason for variable 'a': %v", va.Unreadable)
 	}
 }
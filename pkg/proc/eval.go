@@ -347,6 +347,9 @@ func afterLastArgAddr(vars []*Variable) uint64 {
 //   non-empty) or a pointer shaped type (map, channel, pointer or struct
 //   containing a single pointer field) the type conversion to "interface {}"
 //   is performed.
+// * If dstv is a non-empty interface and srcv is a pointer shaped type
+//   implementing it the type conversion is performed, provided that the
+//   runtime has an itab for the pair of types.
 // * If srcv and dstv have the same type and are both addressable then the
 //   contents of srcv are copied byte-by-byte into dstv
 func (scope *EvalScope) setValue(dstv, srcv *Variable, srcExpr string) error {
@@ -354,6 +357,10 @@ func (scope *EvalScope) setValue(dstv, srcv *Variable, srcExpr string) error {
 
 	typerr := srcv.isType(dstv.RealType, dstv.Kind)
 	if _, isTypeConvErr := typerr.(*typeConvErr); isTypeConvErr {
+		if dstv.RealType.String() != "interface {}" {
+			// attempt ptr-shaped -> iface conversions.
+			return scope.convertToIface(srcv, dstv)
+		}
 		// attempt iface -> eface and ptr-shaped -> eface conversions.
 		return convertToEface(srcv, dstv)
 	}
@@ -503,6 +510,7 @@ func regsReplaceStaticBase(regs op.DwarfRegisters, image *Image) op.DwarfRegiste
 
 // PackageVariables returns the name, value, and type of all package variables in the application.
 func (scope *EvalScope) PackageVariables(cfg LoadConfig) ([]*Variable, error) {
+	scope.BinInfo.loadDeferredTypes()
 	pkgvars := make([]packageVar, len(scope.BinInfo.packageVars))
 	copy(pkgvars, scope.BinInfo.packageVars)
 	sort.Slice(pkgvars, func(i, j int) bool {
@@ -550,6 +558,7 @@ func (scope *EvalScope) findGlobal(pkgName, varName string) (*Variable, error) {
 }
 
 func (scope *EvalScope) findGlobalInternal(name string) (*Variable, error) {
+	scope.BinInfo.loadDeferredGlobal(name)
 	for _, pkgvar := range scope.BinInfo.packageVars {
 		if pkgvar.name == name || strings.HasSuffix(pkgvar.name, "/"+name) {
 			reader := pkgvar.cu.image.dwarfReader
@@ -1284,6 +1293,9 @@ func (scope *EvalScope) evalIdent(node *ast.Ident) (*Variable, error) {
 		}
 	}
 
+	if scope.Fn != nil && scope.Fn.cu.image.symbolsOnly {
+		return nil, fmt.Errorf("could not find symbol value for %s: %s was built without debug info, variables can not be inspected", node.Name, scope.Fn.cu.image.Path)
+	}
 	return nil, fmt.Errorf("could not find symbol value for %s", node.Name)
 }
 
@@ -1456,14 +1468,17 @@ func (scope *EvalScope) evalReslice(node *ast.SliceExpr) (*Variable, error) {
 		}
 		return xev.reslice(low, high)
 	case reflect.Map:
-		if node.High != nil {
-			return nil, fmt.Errorf("second slice argument must be empty for maps")
+		if node.High != nil && high <= low {
+			return nil, fmt.Errorf("invalid slice index: %d > %d", low, high)
 		}
 		xev.mapSkip += int(low)
 		xev.mapIterator() // reads map length
 		if int64(xev.mapSkip) >= xev.Len {
 			return nil, fmt.Errorf("map index out of bounds")
 		}
+		if node.High != nil {
+			xev.mapLimit = int(high - low)
+		}
 		return xev, nil
 	case reflect.Ptr:
 		if xev.Flags&VariableCPtr != 0 {
@@ -2084,6 +2099,7 @@ func (v *Variable) LoadResliced(start int, cfg LoadConfig) (newV *Variable, err
 		newV.Children = nil
 		newV.loaded = false
 		newV.mapSkip = start
+		newV.mapLimit = 0
 	default:
 		return nil, fmt.Errorf("variable to reslice is not an array, slice, or map")
 	}
@@ -1260,7 +1260,7 @@ func regabiMallocgcWorkaround(bi *BinaryInfo) ([]*godwarf.Tree, error) {
 		if err1 != nil {
 			return nil
 		}
-		typ, err := bi.findType(name)
+		typ, err := bi.findLoadedType(name)
 		if err != nil {
 			err1 = err
 			return nil
@@ -78,6 +78,7 @@ import (
 	"time"
 
 	"github.com/go-delve/delve/pkg/dwarf/op"
+	"github.com/go-delve/delve/pkg/dwarf/regnum"
 	"github.com/go-delve/delve/pkg/elfwriter"
 	"github.com/go-delve/delve/pkg/logflags"
 	"github.com/go-delve/delve/pkg/proc"
@@ -216,6 +217,36 @@ type gdbRegister struct {
 // gdbRegname records names of important CPU registers
 type gdbRegnames struct {
 	PC, SP, BP, CX, FsBase string
+	G                      string // register holding the address of the current G, if any
+	LR                     string // link register, if any
+}
+
+// resolve checks that all the important registers are described by
+// regsInfo. If a register is missing but the stub knows it by one of its
+// aliases (for example qemu calls the frame pointer of arm64 'x29' instead
+// of 'fp') the alias will be used instead.
+func (regnames *gdbRegnames) resolve(goarch string, regsInfo []gdbRegisterInfo) error {
+	found := make(map[string]bool, len(regsInfo))
+	for _, reginfo := range regsInfo {
+		found[reginfo.Name] = true
+	}
+	for _, name := range []*string{&regnames.PC, &regnames.SP, &regnames.BP, &regnames.CX, &regnames.G, &regnames.LR} {
+		if *name == "" || found[*name] {
+			continue
+		}
+		resolved := false
+		for _, alias := range registerAliases(goarch, *name) {
+			if found[alias] {
+				*name = alias
+				resolved = true
+				break
+			}
+		}
+		if !resolved {
+			return fmt.Errorf("could not find %s register", *name)
+		}
+	}
+	return nil
 }
 
 // newProcess creates a new Process instance.
@@ -223,6 +254,13 @@ type gdbRegnames struct {
 // Detach.
 // Use Listen, Dial or Connect to complete connection.
 func newProcess(process *os.Process) *gdbProcess {
+	return newProcessForArch(process, runtime.GOOS, runtime.GOARCH)
+}
+
+// newProcessForArch is like newProcess but the target process runs on the
+// specified operating system and architecture, which can be different from
+// the ones of the host when the stub is an emulator.
+func newProcessForArch(process *os.Process, goos, goarch string) *gdbProcess {
 	logger := logflags.GdbWireLogger()
 	p := &gdbProcess{
 		conn: gdbConn{
@@ -230,11 +268,11 @@ func newProcess(process *os.Process) *gdbProcess {
 			inbuf:               make([]byte, 0, initialInputBufferSize),
 			direction:           proc.Forward,
 			log:                 logger,
-			goarch:              runtime.GOARCH,
-			goos:                runtime.GOOS,
+			goarch:              goarch,
+			goos:                goos,
 		},
 		threads:        make(map[int]*gdbThread),
-		bi:             proc.NewBinaryInfo(runtime.GOOS, runtime.GOARCH),
+		bi:             proc.NewBinaryInfo(goos, goarch),
 		regnames:       new(gdbRegnames),
 		breakpoints:    proc.NewBreakpointMap(),
 		gcmdok:         true,
@@ -249,6 +287,8 @@ func newProcess(process *os.Process) *gdbProcess {
 		p.breakpointKind = 1
 	case "arm64":
 		p.breakpointKind = 4
+	case "riscv64":
+		p.breakpointKind = 2
 	}
 
 	p.regnames.PC = registerName(p.bi.Arch, p.bi.Arch.PCRegNum)
@@ -259,6 +299,11 @@ func newProcess(process *os.Process) *gdbProcess {
 	case "arm64":
 		p.regnames.BP = "fp"
 		p.regnames.CX = "x0"
+		p.regnames.G = "x28"
+		p.regnames.LR = "lr"
+	case "riscv64":
+		p.regnames.G = "x27"
+		p.regnames.LR = "x1"
 	case "amd64":
 		p.regnames.CX = "rcx"
 		p.regnames.FsBase = "fs_base"
@@ -350,7 +395,7 @@ func (p *gdbProcess) Connect(conn net.Conn, path string, pid int, debugInfoDirs
 		return nil, err
 	}
 
-	if p.bi.Arch.Name != "arm64" {
+	if p.regnames.G == "" {
 		// None of the stubs we support returns the value of fs_base or gs_base
 		// along with the registers, therefore we have to resort to executing a MOV
 		// instruction on the inferior to find out where the G struct of a given
@@ -431,10 +476,12 @@ func commandLogger(binary string, arguments ...string) *exec.Cmd {
 // ErrUnsupportedOS is returned when trying to use the lldb backend on Windows.
 var ErrUnsupportedOS = errors.New("lldb backend not supported on Windows")
 
-func getLdEnvVars() []string {
+func getLdEnvVars(environ []string) []string {
 	var result []string
 
-	environ := os.Environ()
+	if environ == nil {
+		environ = os.Environ()
+	}
 	for i := 0; i < len(environ); i++ {
 		if strings.HasPrefix(environ[i], "LD_") ||
 			strings.HasPrefix(environ[i], "DYLD_") {
@@ -447,8 +494,9 @@ func getLdEnvVars() []string {
 
 // LLDBLaunch starts an instance of lldb-server and connects to it, asking
 // it to launch the specified target program with the specified arguments
-// (cmd) on the specified directory wd.
-func LLDBLaunch(cmd []string, wd string, flags proc.LaunchFlags, debugInfoDirs []string, tty string, redirects [3]string) (*proc.Target, error) {
+// (cmd) on the specified directory wd, with environment env (the
+// environment of the debugger if nil).
+func LLDBLaunch(cmd []string, wd string, env []string, flags proc.LaunchFlags, debugInfoDirs []string, tty string, redirects [3]string) (*proc.Target, error) {
 	if runtime.GOOS == "windows" {
 		return nil, ErrUnsupportedOS
 	}
@@ -472,7 +520,7 @@ func LLDBLaunch(cmd []string, wd string, flags proc.LaunchFlags, debugInfoDirs [
 		if err != nil {
 			return nil, err
 		}
-		ldEnvVars := getLdEnvVars()
+		ldEnvVars := getLdEnvVars(env)
 		args := make([]string, 0, len(cmd)+4+len(ldEnvVars))
 		args = append(args, ldEnvVars...)
 
@@ -543,8 +591,9 @@ func LLDBLaunch(cmd []string, wd string, flags proc.LaunchFlags, debugInfoDirs [
 		process.SysProcAttr = sysProcAttr(foreground)
 	}
 
+	process.Env = env
 	if runtime.GOOS == "darwin" {
-		process.Env = proc.DisableAsyncPreemptEnv()
+		process.Env = proc.DisableAsyncPreemptEnv(env)
 	}
 
 	if err = process.Start(); err != nil {
@@ -704,6 +753,12 @@ func (p *gdbProcess) initialize(path string, debugInfoDirs []string, stopReason
 			return nil, err
 		}
 	}
+	if p.conn.pid <= 0 && p.conn.multiprocess && p.currentThread != nil {
+		// Stubs that don't support qProcessInfo (for example qemu) still
+		// report the PID as part of thread IDs when the multiprocess
+		// extension is active.
+		p.conn.pid = pidFromThreadID(p.currentThread.strID)
+	}
 	tgt, err := proc.NewTarget(p, p.conn.pid, p.currentThread, proc.NewTargetConfig{
 		Path:                path,
 		DebugInfoDirs:       debugInfoDirs,
@@ -717,6 +772,17 @@ func (p *gdbProcess) initialize(path string, debugInfoDirs []string, stopReason
 	return tgt, nil
 }
 
+// pidFromThreadID returns the PID part of a thread ID in the 'p<pid>.<tid>'
+// format used by the multiprocess extension, or 0.
+func pidFromThreadID(threadID string) int {
+	period := strings.Index(threadID, ".")
+	if !strings.HasPrefix(threadID, "p") || period < 0 {
+		return 0
+	}
+	n, _ := strconv.ParseUint(threadID[1:period], 16, 32)
+	return int(n)
+}
+
 func queryProcessInfo(p *gdbProcess, pid int) (int, string, error) {
 	pi, err := p.conn.queryProcessInfo(pid)
 	if err != nil {
@@ -1101,6 +1167,29 @@ func (p *gdbProcess) When() (string, error) {
 	return strings.TrimSpace(event), nil
 }
 
+// WhenTicks executes the 'when-ticks' command for the Mozilla RR backend.
+// This command will return the number of ticks executed by the current
+// thread, which together with the event number identifies a precise
+// position in the recording.
+func (p *gdbProcess) WhenTicks() (uint64, error) {
+	if p.tracedir == "" {
+		return 0, proc.ErrNotRecorded
+	}
+	resp, err := p.conn.qRRCmd("when-ticks")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(resp)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("can not parse when-ticks response %q", resp)
+	}
+	ticks, err := strconv.ParseUint(fields[len(fields)-1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("can not parse when-ticks response %q", resp)
+	}
+	return ticks, nil
+}
+
 const (
 	checkpointPrefix = "Checkpoint "
 )
@@ -1634,6 +1723,17 @@ func (t *gdbThread) reloadRegisters() error {
 			} else {
 				return err
 			}
+		} else if n := t.p.conn.gregsLen; n < len(t.regs.buf) {
+			// Some stubs (for example qemu) only return the core registers in
+			// response to 'g', the remaining registers must be read one by one.
+			for _, reginfo := range t.p.conn.regsInfo {
+				if reginfo.Offset+reginfo.Bitsize/8 <= n {
+					continue
+				}
+				if err := t.p.conn.readRegister(t.strID, reginfo.Regnum, t.regs.regs[reginfo.Name].value); err != nil {
+					return err
+				}
+			}
 		}
 	}
 	if !t.p.gcmdok {
@@ -1653,11 +1753,11 @@ func (t *gdbThread) reloadRegisters() error {
 		}
 	}
 
-	if t.p.bi.Arch.Name == "arm64" {
-		// no need to play around with the GInstr on ARM64 because
-		// the G addr is stored in a register
+	if t.p.regnames.G != "" {
+		// no need to play around with the GInstr on ARM64 and RISCV64
+		// because the G addr is stored in a register
 
-		t.regs.gaddr = t.regs.byName("x28")
+		t.regs.gaddr = t.regs.byName(t.p.regnames.G)
 		t.regs.hasgaddr = true
 		t.regs.tls = 0
 	} else {
@@ -1687,15 +1787,20 @@ func (t *gdbThread) writeRegisters() error {
 		err := t.p.conn.writeRegisters(t.strID, t.regs.buf)
 		if isProtocolErrorUnsupported(err) {
 			t.p._Gcmdok = false
-		} else {
+		} else if err != nil || t.p.conn.gregsLen >= len(t.regs.buf) {
 			return err
 		}
 
 	}
-	for _, r := range t.regs.regs {
+	for _, reginfo := range t.p.conn.regsInfo {
+		r := t.regs.regs[reginfo.Name]
 		if r.ignoreOnWrite {
 			continue
 		}
+		if t.p.gcmdok && t.p._Gcmdok && reginfo.Offset+reginfo.Bitsize/8 <= t.p.conn.gregsLen {
+			// already written by 'G'
+			continue
+		}
 		if err := t.p.conn.writeRegister(t.strID, r.regnum, r.value); err != nil {
 			return err
 		}
@@ -1916,7 +2021,7 @@ func (regs *gdbRegisters) GAddr() (uint64, bool) {
 }
 
 func (regs *gdbRegisters) LR() uint64 {
-	return binary.LittleEndian.Uint64(regs.regs["lr"].value)
+	return binary.LittleEndian.Uint64(regs.regs[regs.regnames.LR].value)
 }
 
 func (regs *gdbRegisters) byName(name string) uint64 {
@@ -1955,8 +2060,11 @@ func (t *gdbThread) SetReg(regNum uint64, reg *op.DwarfRegister) error {
 			gdbreg, ok = t.regs.regs["z"+regName[1:]]
 		}
 	}
-	if !ok && t.p.bi.Arch.Name == "arm64" && regName == "x30" {
-		gdbreg, ok = t.regs.regs["lr"]
+	for _, alias := range registerAliases(t.p.bi.Arch.Name, regName) {
+		if ok {
+			break
+		}
+		gdbreg, ok = t.regs.regs[alias]
 	}
 	if !ok {
 		return fmt.Errorf("could not set register %s: not found", regName)
@@ -2056,6 +2164,37 @@ func registerName(arch *proc.Arch, regNum uint64) string {
 	return strings.ToLower(regName)
 }
 
+// registerAliases returns the other names that stubs use for the register
+// called name.
+func registerAliases(goarch, name string) []string {
+	switch goarch {
+	case "arm64":
+		switch name {
+		case "fp":
+			return []string{"x29"}
+		case "x29":
+			return []string{"fp"}
+		case "lr":
+			return []string{"x30"}
+		case "x30":
+			return []string{"lr"}
+		}
+	case "riscv64":
+		// The general purpose registers can be called either xN or by their
+		// ABI name, qemu uses the latter.
+		n, ok := regnum.RISCV64NameToDwarf[name]
+		if !ok || n > regnum.RISCV64_X0+31 {
+			return nil
+		}
+		aliases := []string{fmt.Sprintf("x%d", n), regnum.RISCV64ABIName(n)}
+		if n == regnum.RISCV64_BP {
+			aliases = append(aliases, "fp")
+		}
+		return aliases
+	}
+	return nil
+}
+
 func machTargetExcToError(sig uint8) error {
 	switch sig {
 	case 0x91:
@@ -33,6 +33,7 @@ type gdbConn struct {
 
 	packetSize int               // maximum packet size supported by stub
 	regsInfo   []gdbRegisterInfo // list of registers
+	gregsLen   int               // size of the register data returned by 'g', can be smaller than what regsInfo describes
 
 	workaroundReg *gdbRegisterInfo // used to work-around a register setting bug in debugserver, see use in gdbserver.go
 
@@ -142,29 +143,18 @@ func (conn *gdbConn) handshake(regnames *gdbRegnames) error {
 	}
 
 	// Attempt to figure out the name of the processor register.
-	// We either need qXfer:features:read (gdbserver/rr) or qRegisterInfo (lldb)
-	regFound := map[string]bool{
-		regnames.PC: false,
-		regnames.SP: false,
-		regnames.BP: false,
-		regnames.CX: false,
-	}
-	if err := conn.readRegisterInfo(regFound); err != nil {
+	// We either need qXfer:features:read (gdbserver/rr/qemu) or qRegisterInfo (lldb)
+	if err := conn.readRegisterInfo(); err != nil {
 		if isProtocolErrorUnsupported(err) {
-			if err := conn.readTargetXml(regFound); err != nil {
+			if err := conn.readTargetXml(); err != nil {
 				return err
 			}
 		} else {
 			return err
 		}
 	}
-	for n := range regFound {
-		if n == "" {
-			continue
-		}
-		if !regFound[n] {
-			return fmt.Errorf("could not find %s register", n)
-		}
+	if err := regnames.resolve(conn.goarch, conn.regsInfo); err != nil {
+		return err
 	}
 
 	// We either need:
@@ -225,16 +215,6 @@ func (conn *gdbConn) disableAck() error {
 	return err
 }
 
-// gdbTarget is a struct type used to parse target.xml
-type gdbTarget struct {
-	Includes  []gdbTargetInclude `xml:"xi include"`
-	Registers []gdbRegisterInfo  `xml:"reg"`
-}
-
-type gdbTargetInclude struct {
-	Href string `xml:"href,attr"`
-}
-
 type gdbRegisterInfo struct {
 	Name    string `xml:"name,attr"`
 	Bitsize int    `xml:"bitsize,attr"`
@@ -243,38 +223,64 @@ type gdbRegisterInfo struct {
 	Group   string `xml:"group,attr"`
 
 	ignoreOnWrite bool
+	feature       string // name of the target.xml feature describing the register
+}
+
+// ignoredTargetFeatures lists prefixes of target.xml features whose
+// registers are never used by Delve. Qemu describes hundreds of system and
+// control registers with them, which would otherwise have to be read, one
+// by one, every time the target stops.
+var ignoredTargetFeatures = []string{
+	"org.qemu.gdb.",
+	"org.gnu.gdb.riscv.csr",
+	"org.gnu.gdb.riscv.virtual",
 }
 
-func setRegFound(regFound map[string]bool, name string) {
-	for n := range regFound {
-		if name == n {
-			regFound[n] = true
+func ignoredTargetFeature(name string) bool {
+	for _, prefix := range ignoredTargetFeatures {
+		if strings.HasPrefix(name, prefix) {
+			return true
 		}
 	}
+	return false
+}
+
+// gdbArchitectures maps the names used by the architecture element of
+// target.xml to GOARCH values.
+var gdbArchitectures = map[string]string{
+	"i386:x86-64": "amd64",
+	"aarch64":     "arm64",
+	"riscv:rv64":  "riscv64",
 }
 
 // readTargetXml reads target.xml file from stub using qXfer:features:read,
 // then parses it requesting any additional files.
 // The schema of target.xml is described by:
 //  https://github.com/bminor/binutils-gdb/blob/61baf725eca99af2569262d10aca03dcde2698f6/gdb/features/gdb-target.dtd
-func (conn *gdbConn) readTargetXml(regFound map[string]bool) (err error) {
-	conn.regsInfo, err = conn.readAnnex("target.xml")
+func (conn *gdbConn) readTargetXml() (err error) {
+	regsInfo, err := conn.readAnnex("target.xml", "")
 	if err != nil {
 		return err
 	}
 	var offset int
 	regnum := 0
-	for i := range conn.regsInfo {
-		if conn.regsInfo[i].Regnum == 0 {
-			conn.regsInfo[i].Regnum = regnum
+	conn.regsInfo = conn.regsInfo[:0]
+	for i := range regsInfo {
+		if regsInfo[i].Regnum == 0 {
+			regsInfo[i].Regnum = regnum
 		} else {
-			regnum = conn.regsInfo[i].Regnum
+			regnum = regsInfo[i].Regnum
 		}
-		conn.regsInfo[i].Offset = offset
-		offset += conn.regsInfo[i].Bitsize / 8
-
-		setRegFound(regFound, conn.regsInfo[i].Name)
+		regsInfo[i].Offset = offset
+		offset += regsInfo[i].Bitsize / 8
 		regnum++
+
+		// Registers of ignored features are dropped after computing regnum and
+		// offset so that the numbering of the following registers is not
+		// affected.
+		if !ignoredTargetFeature(regsInfo[i].feature) {
+			conn.regsInfo = append(conn.regsInfo, regsInfo[i])
+		}
 	}
 
 	return nil
@@ -282,7 +288,7 @@ func (conn *gdbConn) readTargetXml(regFound map[string]bool) (err error) {
 
 // readRegisterInfo uses qRegisterInfo to read register information (used
 // when qXfer:feature:read is not supported).
-func (conn *gdbConn) readRegisterInfo(regFound map[string]bool) (err error) {
+func (conn *gdbConn) readRegisterInfo() (err error) {
 	regnum := 0
 	for {
 		conn.outbuf.Reset()
@@ -345,8 +351,6 @@ func (conn *gdbConn) readRegisterInfo(regFound map[string]bool) (err error) {
 			continue
 		}
 
-		setRegFound(regFound, regname)
-
 		conn.regsInfo = append(conn.regsInfo, gdbRegisterInfo{Regnum: regnum, Name: regname, Bitsize: bitsize, Offset: offset, ignoreOnWrite: ignoreOnWrite})
 
 		regnum++
@@ -355,24 +359,73 @@ func (conn *gdbConn) readRegisterInfo(regFound map[string]bool) (err error) {
 	return nil
 }
 
-func (conn *gdbConn) readAnnex(annex string) ([]gdbRegisterInfo, error) {
+// readAnnex reads and parses a target description file, registers are
+// returned in the order they are described, with the contents of included
+// files replacing the include element.
+// Feature is the name of the feature containing annex, if it's included
+// from inside a feature element.
+func (conn *gdbConn) readAnnex(annex, feature string) ([]gdbRegisterInfo, error) {
 	tgtbuf, err := conn.qXfer("features", annex, false)
 	if err != nil {
 		return nil, err
 	}
-	var tgt gdbTarget
-	if err := xml.Unmarshal(tgtbuf, &tgt); err != nil {
-		return nil, err
-	}
-
-	for _, incl := range tgt.Includes {
-		regs, err := conn.readAnnex(incl.Href)
+	var regs []gdbRegisterInfo
+	dec := xml.NewDecoder(bytes.NewReader(tgtbuf))
+	var features []string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
 			return nil, err
 		}
-		tgt.Registers = append(tgt.Registers, regs...)
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "feature":
+				features = append(features, feature)
+				for _, attr := range tok.Attr {
+					if attr.Name.Local == "name" {
+						feature = attr.Value
+					}
+				}
+			case "architecture":
+				var arch string
+				if err := dec.DecodeElement(&arch, &tok); err != nil {
+					return nil, err
+				}
+				arch = strings.TrimSpace(arch)
+				if goarch, ok := gdbArchitectures[arch]; ok && goarch != conn.goarch {
+					return nil, fmt.Errorf("target architecture %s does not match the architecture of the executable (%s)", arch, conn.goarch)
+				}
+			case "reg":
+				var reg gdbRegisterInfo
+				if err := dec.DecodeElement(&reg, &tok); err != nil {
+					return nil, err
+				}
+				reg.feature = feature
+				regs = append(regs, reg)
+			case "include":
+				for _, attr := range tok.Attr {
+					if attr.Name.Local != "href" {
+						continue
+					}
+					inclRegs, err := conn.readAnnex(attr.Value, feature)
+					if err != nil {
+						return nil, err
+					}
+					regs = append(regs, inclRegs...)
+				}
+			}
+		case xml.EndElement:
+			if tok.Name.Local == "feature" {
+				feature = features[len(features)-1]
+				features = features[:len(features)-1]
+			}
+		}
 	}
-	return tgt.Registers, nil
+	return regs, nil
 }
 
 func (conn *gdbConn) readExecFile() (string, error) {
@@ -491,10 +544,15 @@ func (conn *gdbConn) readRegisters(threadID string, data []byte) error {
 		return err
 	}
 
-	for i := 0; i < len(resp); i += 2 {
+	sz := len(resp) / 2
+	if sz > len(data) {
+		sz = len(data)
+	}
+	for i := 0; i < sz*2; i += 2 {
 		n, _ := strconv.ParseUint(string(resp[i:i+2]), 16, 8)
 		data[i/2] = uint8(n)
 	}
+	conn.gregsLen = sz
 
 	return nil
 }
@@ -509,6 +567,10 @@ func (conn *gdbConn) writeRegisters(threadID string, data []byte) error {
 	conn.outbuf.Reset()
 	conn.outbuf.WriteString("$G")
 
+	if conn.gregsLen > 0 && conn.gregsLen < len(data) {
+		// the stub expects the same registers it returned for 'g'
+		data = data[:conn.gregsLen]
+	}
 	for _, b := range data {
 		fmt.Fprintf(&conn.outbuf, "%02x", b)
 	}
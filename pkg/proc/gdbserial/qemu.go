@@ -0,0 +1,122 @@
+package gdbserial
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/go-delve/delve/pkg/proc"
+)
+
+const qemuEnvVar = "DELVE_QEMU_PATH" // use this environment variable to override the path to qemu-user used by QemuLaunch
+
+// ErrQemuUnsupportedOS is returned when trying to use the qemu backend on
+// an operating system other than linux, the only one supported by qemu-user.
+var ErrQemuUnsupportedOS = errors.New("qemu backend is only supported on linux")
+
+// qemuArch describes how an architecture is called by Go and by qemu.
+type qemuArch struct {
+	goarch   string
+	qemuName string // suffix of the name of the qemu-user executable
+}
+
+var qemuArchs = map[elf.Machine]qemuArch{
+	elf.EM_X86_64:  {"amd64", "x86_64"},
+	elf.EM_AARCH64: {"arm64", "aarch64"},
+	elf.EM_RISCV:   {"riscv64", "riscv64"},
+}
+
+// executableQemuArch determines the architecture of the executable at
+// path, which does not need to be the same as the one of the host.
+func executableQemuArch(path string) (qemuArch, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return qemuArch{}, fmt.Errorf("could not determine the architecture of %s: %v", path, err)
+	}
+	defer f.Close()
+	arch, ok := qemuArchs[f.Machine]
+	if !ok || f.Class != elf.ELFCLASS64 {
+		return qemuArch{}, fmt.Errorf("architecture of %s not supported by the qemu backend: %v", path, f.Machine)
+	}
+	return arch, nil
+}
+
+// QemuLaunch runs the specified target program (cmd) under qemu-user, on
+// the specified directory wd, and connects to its gdbstub.
+// The target program can be compiled for an architecture different from
+// the one of the host, the architecture is determined from the executable
+// file and the matching qemu-user executable (qemu-aarch64,
+// qemu-riscv64...) is used.
+func QemuLaunch(cmd []string, wd string, env []string, debugInfoDirs []string, redirects [3]string) (*proc.Target, error) {
+	if runtime.GOOS != "linux" {
+		return nil, ErrQemuUnsupportedOS
+	}
+	arch, err := executableQemuArch(cmd[0])
+	if err != nil {
+		return nil, err
+	}
+
+	qemu := os.Getenv(qemuEnvVar)
+	if qemu == "" {
+		qemu = "qemu-" + arch.qemuName
+	}
+	if _, err := exec.LookPath(qemu); err != nil {
+		return nil, fmt.Errorf("could not find %s, install qemu-user or set %s: %v", qemu, qemuEnvVar, err)
+	}
+
+	port := unusedPort()
+	args := make([]string, 0, len(cmd)+2)
+	args = append(args, "-g", port[1:])
+	args = append(args, cmd...)
+
+	process := commandLogger(qemu, args...)
+	var closefn func()
+	process.Stdin, process.Stdout, process.Stderr, closefn, err = openRedirects(redirects, false)
+	if err != nil {
+		return nil, err
+	}
+	defer closefn()
+	if wd != "" {
+		process.Dir = wd
+	}
+	// qemu-user passes its own environment to the target program.
+	process.Env = env
+	process.SysProcAttr = sysProcAttr(false)
+
+	if err := process.Start(); err != nil {
+		return nil, err
+	}
+
+	// qemu-user executes the target program inside its own process.
+	p := newProcessForArch(process.Process, "linux", arch.goarch)
+	tgt, err := p.Dial("127.0.0.1"+port, cmd[0], process.Process.Pid, debugInfoDirs, proc.StopLaunched)
+	if err != nil {
+		process.Process.Kill()
+		return nil, err
+	}
+	return tgt, nil
+}
+
+// QemuConnect connects to the gdbstub of an instance of qemu-user, started
+// with the -g option, listening at addr.
+// Path is the path to the executable of the target program, it is required
+// to determine the architecture of the target.
+func QemuConnect(addr, path string, debugInfoDirs []string) (*proc.Target, error) {
+	if path == "" {
+		return nil, errors.New("the path of the executable run by qemu must be specified")
+	}
+	arch, err := executableQemuArch(path)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	p := newProcessForArch(nil, "linux", arch.goarch)
+	return p.Connect(conn, path, 0, debugInfoDirs, proc.StopAttached)
+}
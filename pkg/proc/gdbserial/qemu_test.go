@@ -0,0 +1,117 @@
+package gdbserial
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeStub answers the packets sent by gdbConn during the handshake using
+// replies, packets without a reply are answered as unsupported.
+func fakeStub(conn net.Conn, replies map[string]string) {
+	stub := NewStubConn(conn)
+	defer stub.Close()
+	for {
+		pkt, err := stub.ReadPacket()
+		if err != nil {
+			return
+		}
+		reply := replies[string(pkt)]
+		if err := stub.WritePacket([]byte(reply)); err != nil {
+			return
+		}
+		if string(pkt) == "QStartNoAckMode" {
+			stub.DisableAck()
+		}
+	}
+}
+
+// qemuARM64Replies returns the replies of qemu-aarch64 to the handshake,
+// the target description is split in multiple files and the system
+// registers use a feature of their own.
+func qemuARM64Replies(arch string) map[string]string {
+	var core strings.Builder
+	core.WriteString(`<?xml version="1.0"?><!DOCTYPE feature SYSTEM "gdb-target.dtd"><feature name="org.gnu.gdb.aarch64.core">`)
+	for i := 0; i <= 30; i++ {
+		fmt.Fprintf(&core, `<reg name="x%d" bitsize="64"/>`, i)
+	}
+	core.WriteString(`<reg name="sp" bitsize="64" type="data_ptr"/><reg name="pc" bitsize="64" type="code_ptr"/><reg name="cpsr" bitsize="32"/></feature>`)
+
+	return map[string]string{
+		"QStartNoAckMode": "OK",
+		"qSupported:multiprocess+;swbreak+;hwbreak+;no-resumed+;xmlRegisters=i386": "PacketSize=1000;qXfer:features:read+;multiprocess+",
+		"Hgp0.0": "OK",
+		"qXfer:features:read:target.xml:0,fff": `l<?xml version="1.0"?><!DOCTYPE target SYSTEM "gdb-target.dtd"><target><architecture>` + arch + `</architecture>` +
+			`<xi:include href="aarch64-core.xml"/><xi:include href="system-registers.xml"/><xi:include href="aarch64-fpu.xml"/></target>`,
+		"qXfer:features:read:aarch64-core.xml:0,fff":     "l" + core.String(),
+		"qXfer:features:read:system-registers.xml:0,fff": `l<?xml version="1.0"?><feature name="org.qemu.gdb.arm64.sysregs"><reg name="MIDR_EL1" bitsize="64" group="cp_regs"/><reg name="MPIDR_EL1" bitsize="64" group="cp_regs"/></feature>`,
+		"qXfer:features:read:aarch64-fpu.xml:0,fff":      `l<?xml version="1.0"?><feature name="org.gnu.gdb.aarch64.fpu"><reg name="v0" bitsize="128" type="aarch64v"/><reg name="fpsr" bitsize="32"/></feature>`,
+	}
+}
+
+func testHandshake(goarch string, replies map[string]string) (*gdbProcess, error) {
+	client, server := net.Pipe()
+	go fakeStub(server, replies)
+	p := newProcessForArch(nil, "linux", goarch)
+	p.conn.conn = client
+	err := p.conn.handshake(p.regnames)
+	client.Close()
+	return p, err
+}
+
+func TestQemuTargetDescription(t *testing.T) {
+	p, err := testHandshake("arm64", qemuARM64Replies("aarch64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// qemu calls the frame pointer and the link register x29 and x30
+	if p.regnames.BP != "x29" || p.regnames.LR != "x30" || p.regnames.G != "x28" {
+		t.Errorf("wrong register names %#v", p.regnames)
+	}
+
+	regs := map[string]gdbRegisterInfo{}
+	for _, reginfo := range p.conn.regsInfo {
+		regs[reginfo.Name] = reginfo
+	}
+	if _, ok := regs["MIDR_EL1"]; ok {
+		t.Errorf("system registers were not ignored")
+	}
+	// registers following the ignored feature must keep their number
+	if v0 := regs["v0"]; v0.Regnum != 36 || v0.feature != "org.gnu.gdb.aarch64.fpu" {
+		t.Errorf("wrong description for v0: %#v", v0)
+	}
+	if pc := regs["pc"]; pc.Regnum != 32 || pc.Offset != 32*8 {
+		t.Errorf("wrong description for pc: %#v", pc)
+	}
+}
+
+func TestQemuArchitectureMismatch(t *testing.T) {
+	_, err := testHandshake("riscv64", qemuARM64Replies("aarch64"))
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("expected architecture mismatch error, got %v", err)
+	}
+}
+
+func TestQemuRISCV64RegisterNames(t *testing.T) {
+	var cpu strings.Builder
+	cpu.WriteString(`<?xml version="1.0"?><!DOCTYPE target SYSTEM "gdb-target.dtd"><target><architecture>riscv:rv64</architecture><feature name="org.gnu.gdb.riscv.cpu">`)
+	for _, name := range []string{"zero", "ra", "sp", "gp", "tp", "t0", "t1", "t2", "fp", "s1", "a0", "a1", "a2", "a3", "a4", "a5", "a6", "a7", "s2", "s3", "s4", "s5", "s6", "s7", "s8", "s9", "s10", "s11", "t3", "t4", "t5", "t6", "pc"} {
+		fmt.Fprintf(&cpu, `<reg name=%q bitsize="64"/>`, name)
+	}
+	cpu.WriteString(`</feature><feature name="org.gnu.gdb.riscv.csr"><reg name="fflags" bitsize="64" regnum="66"/></feature></target>`)
+	replies := qemuARM64Replies("")
+	replies["qXfer:features:read:target.xml:0,fff"] = "l" + cpu.String()
+
+	p, err := testHandshake("riscv64", replies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.regnames.PC != "pc" || p.regnames.SP != "sp" || p.regnames.BP != "fp" || p.regnames.G != "s11" || p.regnames.LR != "ra" {
+		t.Errorf("wrong register names %#v", p.regnames)
+	}
+	if len(p.conn.regsInfo) != 33 {
+		t.Errorf("wrong number of registers %d", len(p.conn.regsInfo))
+	}
+}
@@ -18,10 +18,11 @@ import (
 )
 
 // RecordAsync configures rr to record the execution of the specified
-// program. Returns a run function which will actually record the program, a
+// program, with environment env (the environment of the debugger if nil).
+// Returns a run function which will actually record the program, a
 // stop function which will prematurely terminate the recording of the
 // program.
-func RecordAsync(cmd []string, wd string, quiet bool, redirects [3]string) (run func() (string, error), stop func() error, err error) {
+func RecordAsync(cmd []string, wd string, env []string, quiet bool, redirects [3]string) (run func() (string, error), stop func() error, err error) {
 	if err := checkRRAvailable(); err != nil {
 		return nil, nil, err
 	}
@@ -42,6 +43,7 @@ func RecordAsync(cmd []string, wd string, quiet bool, redirects [3]string) (run
 	}
 	rrcmd.ExtraFiles = []*os.File{wfd}
 	rrcmd.Dir = wd
+	rrcmd.Env = env
 
 	tracedirChan := make(chan string)
 	go func() {
@@ -114,7 +116,7 @@ func openRedirects(redirects [3]string, quiet bool) (stdin, stdout, stderr *os.F
 // Record uses rr to record the execution of the specified program and
 // returns the trace directory's path.
 func Record(cmd []string, wd string, quiet bool, redirects [3]string) (tracedir string, err error) {
-	run, _, err := RecordAsync(cmd, wd, quiet, redirects)
+	run, _, err := RecordAsync(cmd, wd, nil, quiet, redirects)
 	if err != nil {
 		return "", err
 	}
@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"testing"
 
 	"github.com/go-delve/delve/pkg/logflags"
@@ -277,6 +278,87 @@ func TestCheckpoints(t *testing.T) {
 	})
 }
 
+func TestBookmarks(t *testing.T) {
+	protest.AllowRecording(t)
+	withTestRecording("continuetestprog", t, func(p *proc.Target, fixture protest.Fixture) {
+		setFunctionBreakpoint(p, t, "main.main")
+		assertNoError(p.Continue(), t, "Continue")
+		bm0, err := p.AddBookmark("start")
+		assertNoError(err, t, "AddBookmark")
+		_, loc0 := getPosition(p, t)
+		t.Logf("bm0: %#v (%#x)", bm0, loc0.PC)
+
+		if _, err := p.AddBookmark("start"); err == nil {
+			t.Fatal("adding a duplicate bookmark did not fail")
+		}
+
+		assertNoError(p.Next(), t, "First Next")
+		assertNoError(p.Next(), t, "Second Next")
+		bm1, err := p.AddBookmark("after")
+		assertNoError(err, t, "AddBookmark")
+		_, loc1 := getPosition(p, t)
+		t.Logf("bm1: %#v (%#x)", bm1, loc1.PC)
+
+		bms := p.Bookmarks()
+		if len(bms) != 2 || bms[0].Name != "start" || bms[1].Name != "after" {
+			t.Fatalf("wrong bookmarks %v", bms)
+		}
+
+		assertNoError(p.GotoBookmark("start"), t, "GotoBookmark(start)")
+		_, loc2 := getPosition(p, t)
+		if loc2.PC != loc0.PC {
+			t.Fatalf("PC address mismatch %#x != %#x", loc0.PC, loc2.PC)
+		}
+		ticks, err := p.WhenTicks()
+		assertNoError(err, t, "WhenTicks")
+		if ticks != bm0.Ticks {
+			t.Fatalf("ticks mismatch %d != %d", bm0.Ticks, ticks)
+		}
+
+		assertNoError(p.GotoBookmark("after"), t, "GotoBookmark(after)")
+		_, loc3 := getPosition(p, t)
+		if loc3.PC != loc1.PC {
+			t.Fatalf("PC address mismatch %#x != %#x", loc1.PC, loc3.PC)
+		}
+
+		assertNoError(p.ClearBookmark("start"), t, "ClearBookmark")
+		if bms := p.Bookmarks(); len(bms) != 1 {
+			t.Fatalf("wrong bookmarks %v (one expected)", bms)
+		}
+		if err := p.GotoBookmark("start"); err == nil {
+			t.Fatal("GotoBookmark of a cleared bookmark did not fail")
+		}
+	})
+}
+
+func TestRestartFromEvent(t *testing.T) {
+	protest.AllowRecording(t)
+	withTestRecording("continuetestprog", t, func(p *proc.Target, fixture protest.Fixture) {
+		setFunctionBreakpoint(p, t, "main.main")
+		assertNoError(p.Continue(), t, "Continue")
+		event, err := p.WhenEvent()
+		assertNoError(err, t, "WhenEvent")
+		t.Logf("event: %d", event)
+		if event == 0 {
+			t.Fatal("event number not advanced after continue")
+		}
+
+		assertNoError(p.Restart(""), t, "Restart")
+		event0, err := p.WhenEvent()
+		assertNoError(err, t, "WhenEvent")
+		if event0 >= event {
+			t.Fatalf("event number after restart %d not before %d", event0, event)
+		}
+
+		assertNoError(p.Restart(strconv.FormatUint(event, 10)), t, "Restart(event)")
+		event1, err := p.WhenEvent()
+		assertNoError(err, t, "WhenEvent")
+		if event1 != event {
+			t.Fatalf("event number mismatch %d != %d", event, event1)
+		}
+	})
+}
+
 func TestIssue1376(t *testing.T) {
 	// Backward Continue should terminate when it encounters the start of the process.
 	protest.AllowRecording(t)
@@ -0,0 +1,162 @@
+package gdbserial
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/go-delve/delve/pkg/logflags"
+	"github.com/sirupsen/logrus"
+)
+
+// interruptByte is sent by the client, outside of a packet, to request
+// that a running target is stopped.
+const interruptByte = 0x03
+
+// StubConn is the stub side of a connection using the GDB Remote Serial
+// Protocol, it reads the packets sent by a client (gdb, lldb, etc) and
+// sends back replies.
+// It is the reverse of gdbConn, which Delve uses to talk to debugserver,
+// rr and other stubs.
+type StubConn struct {
+	conn io.ReadWriteCloser
+	rdr  *bufio.Reader
+
+	inbuf []byte
+
+	mu  sync.Mutex // serializes writes to conn
+	ack bool       // when ack is true acknowledgment packets are enabled
+
+	log *logrus.Entry
+}
+
+// ErrStubInterrupt is returned by ReadPacket when the client requests
+// that the target is stopped.
+var ErrStubInterrupt = errors.New("interrupt requested")
+
+// NewStubConn returns a new StubConn reading from and writing to conn.
+// Acknowledgment packets are initially enabled, as required by the
+// protocol.
+func NewStubConn(conn io.ReadWriteCloser) *StubConn {
+	return &StubConn{
+		conn:  conn,
+		rdr:   bufio.NewReader(conn),
+		inbuf: make([]byte, 0, initialInputBufferSize),
+		ack:   true,
+		log:   logflags.GdbStubLogger(),
+	}
+}
+
+// ReadPacket reads the next packet sent by the client and returns its
+// payload, with escape sequences removed. Acknowledgments sent by the
+// client are discarded, if the client sends an interrupt request
+// ErrStubInterrupt is returned.
+// The returned slice is only valid until the next call to ReadPacket.
+func (conn *StubConn) ReadPacket() ([]byte, error) {
+	for {
+		ch, err := conn.rdr.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch ch {
+		case '+', '-':
+			// Replies are not retransmitted: the connection is reliable and
+			// the client is expected to disable acknowledgments anyway.
+			continue
+		case interruptByte:
+			if logflags.GdbWire() {
+				conn.log.Debugf("-> ^C")
+			}
+			return nil, ErrStubInterrupt
+		case '$':
+			// start of packet
+		default:
+			continue
+		}
+
+		pkt, err := conn.rdr.ReadBytes('#')
+		if err != nil {
+			return nil, err
+		}
+		pkt = append([]byte{'$'}, pkt...)
+		var checksumBuf [2]byte
+		if _, err := io.ReadFull(conn.rdr, checksumBuf[:]); err != nil {
+			return nil, err
+		}
+		if logflags.GdbWire() {
+			if len(pkt) > gdbWireMaxLen {
+				conn.log.Debugf("-> %q...", string(pkt[:gdbWireMaxLen]))
+			} else {
+				conn.log.Debugf("-> %q%s", string(pkt), string(checksumBuf[:]))
+			}
+		}
+
+		conn.mu.Lock()
+		ack := conn.ack
+		conn.mu.Unlock()
+
+		if ack {
+			if !checksumok(pkt, checksumBuf[:]) {
+				if err := conn.writeRaw([]byte{'-'}); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if err := conn.writeRaw([]byte{'+'}); err != nil {
+				return nil, err
+			}
+		}
+
+		var payload []byte
+		conn.inbuf, payload = binarywiredecode(pkt, conn.inbuf)
+		return payload, nil
+	}
+}
+
+// WritePacket sends a packet with the specified payload to the client,
+// characters that have a special meaning are escaped.
+func (conn *StubConn) WritePacket(payload []byte) error {
+	pkt := make([]byte, 0, len(payload)+5)
+	pkt = append(pkt, '$')
+	for _, ch := range payload {
+		switch ch {
+		case '$', '#', '}', '*':
+			pkt = append(pkt, '}', ch^escapeXor)
+		default:
+			pkt = append(pkt, ch)
+		}
+	}
+	pkt = append(pkt, '#')
+	sum := checksum(pkt)
+	pkt = append(pkt, hexdigit[sum>>4], hexdigit[sum&0xf])
+
+	if logflags.GdbWire() {
+		if len(pkt) > gdbWireMaxLen {
+			conn.log.Debugf("<- %q...", string(pkt[:gdbWireMaxLen]))
+		} else {
+			conn.log.Debugf("<- %q", string(pkt))
+		}
+	}
+	return conn.writeRaw(pkt)
+}
+
+func (conn *StubConn) writeRaw(buf []byte) error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	_, err := conn.conn.Write(buf)
+	return err
+}
+
+// DisableAck disables acknowledgment packets, it should be called after
+// replying to QStartNoAckMode.
+func (conn *StubConn) DisableAck() {
+	conn.mu.Lock()
+	conn.ack = false
+	conn.mu.Unlock()
+}
+
+// Close closes the underlying connection.
+func (conn *StubConn) Close() error {
+	return conn.conn.Close()
+}
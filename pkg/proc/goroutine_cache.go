@@ -1,16 +1,42 @@
 package proc
 
+import (
+	"fmt"
+
+	"github.com/go-delve/delve/pkg/dwarf/godwarf"
+)
+
 type goroutineCache struct {
 	partialGCache map[int]*G
 	allGCache     []*G
 
+	// allgs[i] caches what is known about the i-th entry of runtime.allgs,
+	// so that the pages requested by successive calls to GoroutinesInfo and
+	// GoroutinesCount do not read the same goroutines again.
+	allgs []allgEntry
+	// threadGs maps the ID of each goroutine running on a thread to its G.
+	threadGs map[int]*G
+
 	allgentryAddr, allglenAddr uint64
+
+	// statusOff and statusSize are the offset and size of the atomicstatus
+	// field of runtime.g, statusSize is zero if they are not known.
+	statusOff, statusSize int64
+	statusLoaded          bool
+
+	// nanotime caches the value returned by RuntimeNanotime
+	nanotime       int64
+	nanotimeLoaded bool
 }
 
 func (gcache *goroutineCache) init(bi *BinaryInfo) {
 	var err error
 
 	exeimage := bi.Images[0]
+	if exeimage.dwarf == nil {
+		// not a Go program, see NewTargetConfig.AllowNoDebugInfo
+		return
+	}
 	rdr := exeimage.DwarfReader()
 
 	gcache.allglenAddr, _ = rdr.AddrFor("runtime.allglen", exeimage.StaticBase, bi.Arch.PtrSize())
@@ -23,6 +49,35 @@ func (gcache *goroutineCache) init(bi *BinaryInfo) {
 	}
 }
 
+// loadStatusField finds the offset and size of the atomicstatus field of
+// runtime.g, which are used to read the status of a goroutine without
+// reading the rest of it.
+func (gcache *goroutineCache) loadStatusField(bi *BinaryInfo) {
+	if gcache.statusLoaded {
+		return
+	}
+	gcache.statusLoaded = true
+	typ, err := bi.findType("runtime.g")
+	if err != nil {
+		return
+	}
+	styp, ok := typ.(*godwarf.StructType)
+	if !ok {
+		return
+	}
+	for _, field := range styp.Field {
+		if field.Name != "atomicstatus" {
+			continue
+		}
+		// Since Go 1.20 atomicstatus is an atomic.Uint32, a struct whose
+		// only non-empty field is the value.
+		switch sz := field.Type.Size(); sz {
+		case 1, 2, 4, 8:
+			gcache.statusOff, gcache.statusSize = field.ByteOffset, sz
+		}
+	}
+}
+
 func (gcache *goroutineCache) getRuntimeAllg(bi *BinaryInfo, mem MemoryReadWriter) (uint64, uint64, error) {
 	if gcache.allglenAddr == 0 || gcache.allgentryAddr == 0 {
 		return 0, 0, ErrNoRuntimeAllG
@@ -31,6 +86,9 @@ func (gcache *goroutineCache) getRuntimeAllg(bi *BinaryInfo, mem MemoryReadWrite
 	if err != nil {
 		return 0, 0, err
 	}
+	if allglen > maxGoroutines {
+		return 0, 0, fmt.Errorf("runtime.allglen too large (%d)", allglen)
+	}
 
 	allgptr, err := readUintRaw(mem, gcache.allgentryAddr, int64(bi.Arch.PtrSize()))
 	if err != nil {
@@ -39,6 +97,25 @@ func (gcache *goroutineCache) getRuntimeAllg(bi *BinaryInfo, mem MemoryReadWrite
 	return allgptr, allglen, nil
 }
 
+// allgEntry is what is known about an entry of runtime.allgs.
+type allgEntry struct {
+	g     *G   // the goroutine, nil if it hasn't been read
+	state byte // one of allgUnknown, allgAlive, allgDead
+}
+
+const (
+	allgUnknown = iota
+	allgAlive   // the goroutine is not dead, or it can't be read
+	allgDead
+)
+
+// growAllgs makes room in gcache.allgs for allglen entries.
+func (gcache *goroutineCache) growAllgs(allglen uint64) {
+	if uint64(len(gcache.allgs)) < allglen {
+		gcache.allgs = append(gcache.allgs, make([]allgEntry, int(allglen)-len(gcache.allgs))...)
+	}
+}
+
 func (gcache *goroutineCache) addGoroutine(g *G) {
 	if gcache.partialGCache == nil {
 		gcache.partialGCache = make(map[int]*G)
@@ -50,4 +127,7 @@ func (gcache *goroutineCache) addGoroutine(g *G) {
 func (gcache *goroutineCache) Clear() {
 	gcache.partialGCache = nil
 	gcache.allGCache = nil
+	gcache.allgs = nil
+	gcache.threadGs = nil
+	gcache.nanotimeLoaded = false
 }
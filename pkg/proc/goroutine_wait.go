@@ -0,0 +1,107 @@
+package proc
+
+import (
+	"go/constant"
+	"reflect"
+	"time"
+)
+
+// WaitReasonStrings returns the descriptions of the wait reasons of
+// goroutines used by the runtime of the target, indexed by
+// G.WaitReason. Returns nil if they can not be read.
+func WaitReasonStrings(t *Target) []string {
+	// +rtype -var waitReasonStrings anytype
+
+	scope := globalScope(t, t.BinInfo(), t.BinInfo().Images[0], t.Memory())
+	v, err := scope.EvalExpression("runtime.waitReasonStrings", LoadConfig{MaxStringLen: 64, MaxArrayValues: 256})
+	if err != nil || v.Unreadable != nil || v.Kind != reflect.Array {
+		return nil
+	}
+	r := make([]string, len(v.Children))
+	for i := range v.Children {
+		if v.Children[i].Value != nil && v.Children[i].Value.Kind() == constant.String {
+			r[i] = constant.StringVal(v.Children[i].Value)
+		}
+	}
+	return r
+}
+
+// RuntimeNanotime returns an approximation of the current value of
+// runtime.nanotime in the target, which is the time base used by
+// G.WaitSince. Since the value of the clock can not be read directly the
+// most recent timestamp recorded by the runtime is returned instead,
+// zero if none is available.
+func RuntimeNanotime(t *Target) int64 {
+	// +rtype -var sched schedt
+	// +rtype -field schedt.lastpoll anytype
+	// +rtype -var work anytype
+	// +rtype -var memstats mstats
+	// +rtype -field mstats.last_gc_nanotime uint64
+
+	if t.gcache.nanotimeLoaded {
+		return t.gcache.nanotime
+	}
+
+	scope := globalScope(t, t.BinInfo(), t.BinInfo().Images[0], t.Memory())
+	var now int64
+	for _, expr := range []string{"runtime.sched.lastpoll", "runtime.work.tstart", "runtime.memstats.last_gc_nanotime"} {
+		v, err := scope.EvalExpression(expr, loadSingleValue)
+		if err != nil {
+			continue
+		}
+		if n := runtimeTimestampValue(v); n > now {
+			now = n
+		}
+	}
+	gs, _, err := GoroutinesInfo(t, 0, 0)
+	if err == nil {
+		for _, g := range gs {
+			if g.WaitSince > now {
+				now = g.WaitSince
+			}
+		}
+	}
+	t.gcache.nanotime, t.gcache.nanotimeLoaded = now, true
+	return now
+}
+
+// WaitDuration returns an approximation of how long g has been in its
+// current wait state, zero if g isn't waiting or the duration is unknown.
+// The runtime only records when a goroutine started waiting if a garbage
+// collection cycle happened while it was blocked.
+func WaitDuration(t *Target, g *G) time.Duration {
+	if g.Status != Gwaiting && g.Status != Gsyscall {
+		return 0
+	}
+	if g.WaitSince <= 0 {
+		return 0
+	}
+	now := RuntimeNanotime(t)
+	if now <= 0 || g.WaitSince > now {
+		return 0
+	}
+	return time.Duration(now - g.WaitSince)
+}
+
+// runtimeTimestampValue returns the value of v, which is either an
+// integer or an atomic wrapper around an integer.
+func runtimeTimestampValue(v *Variable) int64 {
+	for v != nil && v.Unreadable == nil && v.Kind == reflect.Struct {
+		var field *Variable
+		for _, name := range []string{"v", "value"} {
+			if field, _ = v.structMember(name); field != nil && field.Unreadable == nil {
+				break
+			}
+		}
+		if field == nil {
+			return 0
+		}
+		field.loadValue(loadSingleValue)
+		v = field
+	}
+	if v == nil || v.Unreadable != nil || v.Value == nil || v.Value.Kind() != constant.Int {
+		return 0
+	}
+	n, _ := constant.Int64Val(v.Value)
+	return n
+}
@@ -0,0 +1,940 @@
+package proc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/go-delve/delve/pkg/dwarf/godwarf"
+)
+
+const (
+	heapPageSize = 8192 // +rtype _PageSize
+	mSpanInUse   = 1    // +rtype mSpanInUse
+
+	// referencesStackDepth is the maximum number of frames of each goroutine
+	// examined by FindReferences.
+	referencesStackDepth = 100
+	// referencesMaxVarSize is the maximum size of a variable that
+	// FindReferences will scan for pointers.
+	referencesMaxVarSize = 64 * 1024 * 1024
+)
+
+// ReferenceKind describes where a reference found by FindReferences is
+// stored.
+type ReferenceKind uint8
+
+const (
+	StackReference  ReferenceKind = iota // local variable of a goroutine
+	GlobalReference                      // package variable
+	HeapReference                        // heap allocated object
+)
+
+// Reference is a pointer to an object found by FindReferences.
+type Reference struct {
+	Kind ReferenceKind
+	Addr uint64 // address of the pointer, zero if unknown
+
+	// GoroutineID, Frame and Fn describe the stack frame containing the
+	// pointer, for StackReference.
+	GoroutineID int
+	Frame       int
+	Fn          *Function
+
+	// Path is the name of the variable followed by the path of the field
+	// containing the pointer, Type is the type of that field. Only set for
+	// StackReference and GlobalReference.
+	Path string
+	Type godwarf.Type
+
+	// ObjectAddr and ObjectSize describe the heap object containing the
+	// pointer, for HeapReference.
+	ObjectAddr, ObjectSize uint64
+}
+
+// heapSpan is the delve counterpart to runtime.mspan.
+type heapSpan struct {
+	base, limit uint64
+	elemsize    uint64
+	nelems      uint64
+	freeindex   uint64
+	allocBits   uint64
+	noscan      bool
+}
+
+// contains returns true if addr belongs to the span.
+func (s *heapSpan) contains(addr uint64) bool {
+	return addr >= s.base && addr < s.limit
+}
+
+// objectIndex returns the index of the object containing addr.
+func (s *heapSpan) objectIndex(addr uint64) uint64 {
+	return (addr - s.base) / s.elemsize
+}
+
+// isAllocated returns true if the object with index i is allocated, see
+// runtime.(*mspan).isFree.
+func (s *heapSpan) isAllocated(mem MemoryReadWriter, i uint64) bool {
+	if i >= s.nelems {
+		return false
+	}
+	if i < s.freeindex {
+		return true
+	}
+	var b [1]byte
+	if _, err := mem.ReadMemory(b[:], s.allocBits+i/8); err != nil {
+		return false
+	}
+	return b[0]&(1<<(i%8)) != 0
+}
+
+// loadHeapSpans returns the in use spans of the heap, as described by
+// runtime.mheap_.allspans.
+func loadHeapSpans(bi *BinaryInfo, mem MemoryReadWriter) ([]heapSpan, error) {
+	// +rtype -var mheap_ mheap
+	// +rtype -field mheap.allspans []*mspan
+
+	scope := globalScope(nil, bi, bi.Images[0], mem)
+	mheap, err := scope.findGlobal("runtime", "mheap_")
+	if err != nil {
+		return nil, err
+	}
+	allspans, err := mheap.structMember("allspans")
+	if err != nil {
+		return nil, err
+	}
+	if allspans.Unreadable != nil {
+		return nil, allspans.Unreadable
+	}
+
+	ptrSize := int64(bi.Arch.PtrSize())
+	array, err := readUintRaw(mem, allspans.Addr, ptrSize)
+	if err != nil {
+		return nil, err
+	}
+	n, err := readUintRaw(mem, allspans.Addr+uint64(ptrSize), ptrSize)
+	if err != nil {
+		return nil, err
+	}
+	spanPtrs := make([]byte, n*uint64(ptrSize))
+	if _, err := mem.ReadMemory(spanPtrs, array); err != nil {
+		return nil, err
+	}
+
+	typ, err := bi.findType("runtime.mspan")
+	if err != nil {
+		return nil, err
+	}
+	mspan, ok := resolveTypedef(typ).(*godwarf.StructType)
+	if !ok {
+		return nil, errors.New("unexpected type for runtime.mspan")
+	}
+
+	type field struct {
+		name      string
+		off, size int64
+	}
+	fields := []*field{
+		{name: "startAddr"}, // +rtype -field mspan.startAddr uintptr
+		{name: "npages"},    // +rtype -field mspan.npages uintptr
+		{name: "elemsize"},  // +rtype -field mspan.elemsize uintptr
+		{name: "nelems"},    // +rtype -field mspan.nelems anytype
+		{name: "freeindex"}, // +rtype -field mspan.freeindex anytype
+		{name: "allocBits"}, // +rtype -field mspan.allocBits *gcBits
+		{name: "spanclass"}, // +rtype -field mspan.spanclass spanClass
+		{name: "state"},     // +rtype -field mspan.state anytype
+	}
+	for _, f := range fields {
+		f.off, f.size, err = scalarFieldOffset(mspan, f.name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	buf := make([]byte, mspan.Size())
+	get := func(f *field) uint64 {
+		b := buf[f.off : f.off+f.size]
+		switch f.size {
+		case 1:
+			return uint64(b[0])
+		case 2:
+			return uint64(binary.LittleEndian.Uint16(b))
+		case 4:
+			return uint64(binary.LittleEndian.Uint32(b))
+		default:
+			return binary.LittleEndian.Uint64(b)
+		}
+	}
+
+	r := []heapSpan{}
+	for i := uint64(0); i < n; i++ {
+		spanAddr := binary.LittleEndian.Uint64(spanPtrs[i*uint64(ptrSize):])
+		if ptrSize == 4 {
+			spanAddr = uint64(binary.LittleEndian.Uint32(spanPtrs[i*uint64(ptrSize):]))
+		}
+		if spanAddr == 0 {
+			continue
+		}
+		if _, err := mem.ReadMemory(buf, spanAddr); err != nil {
+			continue
+		}
+		startAddr, npages, elemsize, nelems, freeindex, allocBits, spanclass, state := get(fields[0]), get(fields[1]), get(fields[2]), get(fields[3]), get(fields[4]), get(fields[5]), get(fields[6]), get(fields[7])
+		if state != mSpanInUse || elemsize == 0 {
+			continue
+		}
+		r = append(r, heapSpan{
+			base:      startAddr,
+			limit:     startAddr + npages*heapPageSize,
+			elemsize:  elemsize,
+			nelems:    nelems,
+			freeindex: freeindex,
+			allocBits: allocBits,
+			noscan:    spanclass&1 != 0,
+		})
+	}
+	return r, nil
+}
+
+// scalarFieldOffset returns the offset and size of the field called name
+// of typ. If the field is a struct (for example an atomic wrapper) its
+// first scalar field is returned instead.
+func scalarFieldOffset(typ *godwarf.StructType, name string) (int64, int64, error) {
+	for _, field := range typ.Field {
+		if field.Name != name {
+			continue
+		}
+		off := field.ByteOffset
+		ftyp := resolveTypedef(field.Type)
+		for {
+			st, isstruct := ftyp.(*godwarf.StructType)
+			if !isstruct {
+				break
+			}
+			var next *godwarf.StructField
+			for _, f := range st.Field {
+				if f.Type.Size() > 0 {
+					next = f
+					break
+				}
+			}
+			if next == nil {
+				return 0, 0, fmt.Errorf("could not find field %s of %s", name, typ.String())
+			}
+			off += next.ByteOffset
+			ftyp = resolveTypedef(next.Type)
+		}
+		if sz := ftyp.Size(); sz == 1 || sz == 2 || sz == 4 || sz == 8 {
+			return off, sz, nil
+		}
+		break
+	}
+	return 0, 0, fmt.Errorf("could not find field %s of %s", name, typ.String())
+}
+
+// referencedRegion returns the memory region referenced by v: the object
+// v points to for pointer-like values, v itself otherwise.
+func referencedRegion(v *Variable) (addr, size uint64, err error) {
+	if v.Unreadable != nil {
+		return 0, 0, v.Unreadable
+	}
+	ptrSize := int64(v.bi.Arch.PtrSize())
+	switch v.Kind {
+	case reflect.Ptr, reflect.UnsafePointer:
+		if len(v.Children) > 0 {
+			addr = v.Children[0].Addr
+			if v.Children[0].RealType != nil {
+				size = uint64(v.Children[0].RealType.Size())
+			}
+		} else {
+			addr, err = readUintRaw(v.mem, v.Addr, ptrSize)
+		}
+	case reflect.Map, reflect.Chan, reflect.Func:
+		addr, err = readUintRaw(v.mem, v.Addr, ptrSize)
+	case reflect.Slice, reflect.String:
+		addr = v.Base
+	default:
+		if v.Flags&VariableFakeAddress != 0 {
+			return 0, 0, fmt.Errorf("%s does not have an address", v.Name)
+		}
+		addr, size = v.Addr, uint64(v.RealType.Size())
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if addr == 0 {
+		return 0, 0, fmt.Errorf("%s is nil", v.Name)
+	}
+	if size == 0 {
+		size = 1
+	}
+	return addr, size, nil
+}
+
+// pointerWalker enumerates the pointers contained in a value, following
+// its type.
+type pointerWalker struct {
+	ptrSize int
+	paths   bool // compute the path of each pointer
+	hasPtr  map[godwarf.Type]bool
+}
+
+func newPointerWalker(bi *BinaryInfo, paths bool) *pointerWalker {
+	return &pointerWalker{ptrSize: bi.Arch.PtrSize(), paths: paths, hasPtr: make(map[godwarf.Type]bool)}
+}
+
+// walk calls fn for each pointer-like value (pointers, maps, channels,
+// functions, strings, slices and interfaces) contained in the value of
+// type typ stored at offset off of buf. The offset, type and path of each
+// value are passed to fn, the walk stops when fn returns false.
+func (pw *pointerWalker) walk(buf []byte, off int64, typ godwarf.Type, path string, fn func(off int64, typ godwarf.Type, path string) bool) bool {
+	switch t := resolveTypedef(typ).(type) {
+	case *godwarf.PtrType, *godwarf.FuncType, *godwarf.MapType, *godwarf.ChanType, *godwarf.StringType, *godwarf.SliceType, *godwarf.InterfaceType:
+		if off+typ.Size() > int64(len(buf)) {
+			return true
+		}
+		return fn(off, typ, path)
+	case *godwarf.StructType:
+		for _, field := range t.Field {
+			if !pw.hasPointers(field.Type) {
+				continue
+			}
+			fieldPath := ""
+			if pw.paths {
+				fieldPath = path + "." + field.Name
+			}
+			if !pw.walk(buf, off+field.ByteOffset, field.Type, fieldPath, fn) {
+				return false
+			}
+		}
+	case *godwarf.ArrayType:
+		elemSize := t.Type.Size()
+		if elemSize <= 0 || !pw.hasPointers(t.Type) {
+			return true
+		}
+		for i := int64(0); i < t.Count && off+(i+1)*elemSize <= int64(len(buf)); i++ {
+			elemPath := ""
+			if pw.paths {
+				elemPath = fmt.Sprintf("%s[%d]", path, i)
+			}
+			if !pw.walk(buf, off+i*elemSize, t.Type, elemPath, fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// hasPointers returns true if values of type typ can contain pointers.
+func (pw *pointerWalker) hasPointers(typ godwarf.Type) bool {
+	if r, ok := pw.hasPtr[typ]; ok {
+		return r
+	}
+	r := false
+	switch t := resolveTypedef(typ).(type) {
+	case *godwarf.PtrType, *godwarf.FuncType, *godwarf.MapType, *godwarf.ChanType, *godwarf.StringType, *godwarf.SliceType, *godwarf.InterfaceType:
+		r = true
+	case *godwarf.StructType:
+		pw.hasPtr[typ] = false // break cycles
+		for _, field := range t.Field {
+			if pw.hasPointers(field.Type) {
+				r = true
+				break
+			}
+		}
+	case *godwarf.ArrayType:
+		r = t.Count > 0 && pw.hasPointers(t.Type)
+	}
+	pw.hasPtr[typ] = r
+	return r
+}
+
+// readVariableMemory returns the contents of v, or nil if v can not be
+// read or does not contain pointers.
+func (pw *pointerWalker) readVariableMemory(v *Variable) []byte {
+	if v.Unreadable != nil || v.RealType == nil {
+		return nil
+	}
+	sz := v.RealType.Size()
+	if sz <= 0 || sz > referencesMaxVarSize || !pw.hasPointers(v.RealType) {
+		return nil
+	}
+	buf := make([]byte, sz)
+	if _, err := v.mem.ReadMemory(buf, v.Addr); err != nil {
+		return nil
+	}
+	return buf
+}
+
+func readPtr(buf []byte, ptrSize int) uint64 {
+	if ptrSize == 4 {
+		return uint64(binary.LittleEndian.Uint32(buf))
+	}
+	return binary.LittleEndian.Uint64(buf)
+}
+
+// forEachRootVariable calls fn for the local variables of all goroutines
+// and for all package variables, stopping when fn returns false. For local
+// variables ref describes the stack frame containing the variable.
+func forEachRootVariable(t *Target, fn func(v *Variable, ref Reference) bool) error {
+	gs, _, err := GoroutinesInfo(t, 0, 0)
+	if err != nil {
+		return err
+	}
+	for _, g := range gs {
+		if !forEachGoroutineVariable(t, g, fn) {
+			return nil
+		}
+	}
+	return forEachPackageVariable(t, fn)
+}
+
+// forEachGoroutineVariable calls fn for the local variables of the first
+// referencesStackDepth frames of g, returns false if fn returned false.
+func forEachGoroutineVariable(t *Target, g *G, fn func(v *Variable, ref Reference) bool) bool {
+	frames, err := g.Stacktrace(referencesStackDepth, 0)
+	if err != nil {
+		return true
+	}
+	for i := range frames {
+		if frames[i].Current.Fn == nil {
+			continue
+		}
+		scope := FrameToScope(t, t.Memory(), g, frames[i:]...)
+		vars, err := scope.Locals(0)
+		if err != nil {
+			continue
+		}
+		for _, v := range vars {
+			if !fn(v, Reference{Kind: StackReference, GoroutineID: g.ID, Frame: i, Fn: frames[i].Current.Fn}) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// forEachPackageVariable calls fn for all package variables, stopping when
+// fn returns false.
+func forEachPackageVariable(t *Target, fn func(v *Variable, ref Reference) bool) error {
+	scope := globalScope(t, t.BinInfo(), t.BinInfo().Images[0], t.Memory())
+	vars, err := scope.PackageVariables(LoadConfig{})
+	if err != nil {
+		return err
+	}
+	for _, v := range vars {
+		if !fn(v, Reference{Kind: GlobalReference}) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// referenceScanner finds pointers into the interval [lo, hi).
+type referenceScanner struct {
+	t      *Target
+	lo, hi uint64
+	max    int
+	refs   []Reference
+	seen   map[uint64]bool // addresses of the pointers already reported
+	pw     *pointerWalker
+}
+
+func (rs *referenceScanner) full() bool {
+	return rs.max > 0 && len(rs.refs) >= rs.max
+}
+
+func (rs *referenceScanner) add(ref Reference) {
+	if rs.full() {
+		return
+	}
+	if ref.Addr != 0 {
+		if rs.seen[ref.Addr] {
+			return
+		}
+		rs.seen[ref.Addr] = true
+	}
+	rs.refs = append(rs.refs, ref)
+}
+
+func (rs *referenceScanner) pointsToObject(p uint64) bool {
+	return p >= rs.lo && p < rs.hi
+}
+
+// scanVariable reports all pointers into the object contained in v,
+// following the type of v.
+func (rs *referenceScanner) scanVariable(v *Variable, ref Reference) bool {
+	if v.Unreadable != nil || v.RealType == nil {
+		return true
+	}
+	if v.Flags&VariableEscaped != 0 && rs.pointsToObject(v.Addr) {
+		// the stack contains a pointer to the escaped variable
+		eref := ref
+		eref.Path = "&" + v.Name
+		eref.Type = pointerTo(v.DwarfType, v.bi.Arch)
+		rs.add(eref)
+	}
+	buf := rs.pw.readVariableMemory(v)
+	if buf == nil {
+		return !rs.full()
+	}
+	rs.pw.walk(buf, 0, v.DwarfType, v.Name, func(off int64, typ godwarf.Type, path string) bool {
+		ptrOff := off
+		if _, isiface := resolveTypedef(typ).(*godwarf.InterfaceType); isiface {
+			ptrOff += int64(rs.pw.ptrSize)
+		}
+		if !rs.pointsToObject(readPtr(buf[ptrOff:], rs.pw.ptrSize)) {
+			return true
+		}
+		ref.Path, ref.Type = path, typ
+		ref.Addr = 0
+		if v.Flags&VariableFakeAddress == 0 {
+			ref.Addr = v.Addr + uint64(ptrOff)
+		}
+		rs.add(ref)
+		return !rs.full()
+	})
+	return !rs.full()
+}
+
+// scanHeap reports pointers to the object stored in allocated heap
+// objects. The type of heap objects is unknown, all words of objects
+// allocated in spans that can contain pointers are examined.
+func (rs *referenceScanner) scanHeap(spans []heapSpan) {
+	mem := rs.t.Memory()
+	ptrSize := uint64(rs.t.BinInfo().Arch.PtrSize())
+	buf := make([]byte, memSearchChunkSize)
+	for i := range spans {
+		s := &spans[i]
+		if s.noscan {
+			continue
+		}
+		for addr := s.base; addr < s.limit; addr += memSearchChunkSize {
+			chunk := buf
+			if s.limit-addr < uint64(len(chunk)) {
+				chunk = chunk[:s.limit-addr]
+			}
+			n, _ := mem.ReadMemory(chunk, addr)
+			chunk = chunk[:n]
+			for off := uint64(0); off+ptrSize <= uint64(len(chunk)); off += ptrSize {
+				p := readPtr(chunk[off:], rs.pw.ptrSize)
+				if !rs.pointsToObject(p) {
+					continue
+				}
+				paddr := addr + off
+				if rs.pointsToObject(paddr) {
+					// pointer inside the object itself
+					continue
+				}
+				idx := s.objectIndex(paddr)
+				if !s.isAllocated(mem, idx) {
+					continue
+				}
+				rs.add(Reference{Kind: HeapReference, Addr: paddr, ObjectAddr: s.base + idx*s.elemsize, ObjectSize: s.elemsize})
+				if rs.full() {
+					return
+				}
+			}
+		}
+	}
+}
+
+// FindReferences searches goroutine stacks, package variables and the
+// heap for pointers to the object referenced by v (see referencedRegion).
+// If the object is allocated on the heap all pointers to the heap object
+// containing it are reported. Returns the address and size of the object
+// and at most max references (all references if max is zero).
+// Pointers stored in temporary values that are not described by the debug
+// symbols of the target are not reported.
+func (t *Target) FindReferences(v *Variable, max int) (addr, size uint64, refs []Reference, err error) {
+	addr, size, err = referencedRegion(v)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	spans, err := loadHeapSpans(t.BinInfo(), t.Memory())
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("could not read heap spans: %v", err)
+	}
+	for i := range spans {
+		s := &spans[i]
+		if s.contains(addr) {
+			idx := s.objectIndex(addr)
+			if !s.isAllocated(t.Memory(), idx) {
+				return 0, 0, nil, fmt.Errorf("%#x is not an allocated heap object", addr)
+			}
+			addr, size = s.base+idx*s.elemsize, s.elemsize
+			break
+		}
+	}
+
+	rs := &referenceScanner{t: t, lo: addr, hi: addr + size, max: max, seen: make(map[uint64]bool), pw: newPointerWalker(t.BinInfo(), true)}
+	if err := forEachRootVariable(t, rs.scanVariable); err != nil {
+		return 0, 0, nil, err
+	}
+	rs.scanHeap(spans)
+	return addr, size, rs.refs, nil
+}
+
+// heapObjectWalker infers the type of live heap objects by following
+// typed pointers, starting from the local variables of all goroutines and
+// from package variables.
+type heapObjectWalker struct {
+	t       *Target
+	mem     MemoryReadWriter
+	spans   []heapSpan // sorted by base address
+	pw      *pointerWalker
+	visited map[uint64]int64        // size of the value scanned at each address
+	objs    map[uint64]godwarf.Type // type of each heap object, by base address
+	queue   []heapObjectRef
+
+	// If edges is not nil the references between heap objects, and from
+	// root variables to heap objects, are recorded: from is the base
+	// address of the heap object being scanned, or zero while scanning the
+	// last root variable in roots.
+	edges map[heapEdge]bool
+	roots []HeapRoot
+	from  uint64
+}
+
+// heapEdge is a reference from the heap object at address from to the heap
+// object at address to.
+type heapEdge struct {
+	from, to uint64
+}
+
+// heapObjectRef is a typed pointer into a heap object.
+type heapObjectRef struct {
+	addr uint64
+	typ  godwarf.Type
+}
+
+func (hw *heapObjectWalker) findSpan(addr uint64) *heapSpan {
+	return findHeapSpan(hw.spans, addr)
+}
+
+// findHeapSpan returns the span containing addr, spans must be sorted by
+// base address.
+func findHeapSpan(spans []heapSpan, addr uint64) *heapSpan {
+	i := sort.Search(len(spans), func(i int) bool { return spans[i].limit > addr })
+	if i < len(spans) && spans[i].contains(addr) {
+		return &spans[i]
+	}
+	return nil
+}
+
+// enqueue schedules the value of type typ at addr to be scanned, if it is
+// part of an allocated heap object.
+func (hw *heapObjectWalker) enqueue(addr uint64, typ godwarf.Type) {
+	if addr == 0 || typ == nil {
+		return
+	}
+	if hw.edges == nil {
+		if sz, ok := hw.visited[addr]; ok && sz >= typ.Size() {
+			return
+		}
+	}
+	s := hw.findSpan(addr)
+	if s == nil {
+		return
+	}
+	idx := s.objectIndex(addr)
+	if !s.isAllocated(hw.mem, idx) {
+		return
+	}
+	base := s.base + idx*s.elemsize
+	if hw.edges != nil {
+		hw.addEdge(base)
+		if sz, ok := hw.visited[addr]; ok && sz >= typ.Size() {
+			return
+		}
+	}
+	hw.visited[addr] = typ.Size()
+	if base == addr && uint64(typ.Size()) <= s.elemsize {
+		// a pointer to the first field of a struct has the same address of
+		// the struct, keep the largest type
+		if otyp, ok := hw.objs[base]; !ok || otyp.Size() < typ.Size() {
+			hw.objs[base] = typ
+		}
+	}
+	hw.queue = append(hw.queue, heapObjectRef{addr, typ})
+}
+
+// addEdge records a reference from the value being scanned to the heap
+// object at address to.
+func (hw *heapObjectWalker) addEdge(to uint64) {
+	if hw.from == 0 {
+		if len(hw.roots) == 0 {
+			return
+		}
+		root := &hw.roots[len(hw.roots)-1]
+		for _, addr := range root.Refs {
+			if addr == to {
+				return
+			}
+		}
+		root.Refs = append(root.Refs, to)
+		return
+	}
+	if hw.from != to {
+		hw.edges[heapEdge{hw.from, to}] = true
+	}
+}
+
+// scan enqueues the destinations of the pointers contained in buf, which
+// holds a value of type typ read from addr.
+func (hw *heapObjectWalker) scan(buf []byte, addr uint64, typ godwarf.Type) {
+	ptrSize := hw.pw.ptrSize
+	hw.pw.walk(buf, 0, typ, "", func(off int64, typ godwarf.Type, _ string) bool {
+		switch t := resolveTypedef(typ).(type) {
+		case *godwarf.PtrType:
+			hw.enqueue(readPtr(buf[off:], ptrSize), t.Type)
+		case *godwarf.SliceType:
+			array := readPtr(buf[off:], ptrSize)
+			cap := int64(readPtr(buf[off+2*int64(ptrSize):], ptrSize))
+			if elemSize := t.ElemType.Size(); cap > 0 && elemSize > 0 {
+				hw.enqueue(array, fakeArrayType(uint64(cap), t.ElemType))
+			}
+		case *godwarf.InterfaceType:
+			if addr == 0 {
+				break
+			}
+			v := newVariable("", addr+uint64(off), typ, hw.t.BinInfo(), hw.mem)
+			v.loadInterface(0, false, LoadConfig{})
+			if v.Unreadable != nil || len(v.Children) == 0 {
+				break
+			}
+			data := &v.Children[0]
+			if data.Addr != addr+uint64(off)+uint64(ptrSize) {
+				// data points to a value of the dynamic type
+				hw.enqueue(data.Addr, data.DwarfType)
+			} else if ptyp, isptr := resolveTypedef(data.DwarfType).(*godwarf.PtrType); isptr {
+				hw.enqueue(readPtr(buf[off+int64(ptrSize):], ptrSize), ptyp.Type)
+			}
+		}
+		return true
+	})
+}
+
+func (hw *heapObjectWalker) scanVariable(v *Variable, ref Reference) bool {
+	if v.Unreadable != nil {
+		return true
+	}
+	if hw.edges != nil {
+		hw.roots = append(hw.roots, HeapRoot{Kind: ref.Kind, Name: v.Name, GoroutineID: ref.GoroutineID, Fn: ref.Fn})
+	}
+	if v.Flags&VariableEscaped != 0 {
+		hw.enqueue(v.Addr, v.DwarfType)
+		return true
+	}
+	if buf := hw.pw.readVariableMemory(v); buf != nil {
+		addr := v.Addr
+		if v.Flags&VariableFakeAddress != 0 {
+			addr = 0
+		}
+		hw.scan(buf, addr, v.DwarfType)
+	}
+	return true
+}
+
+// run scans all values reachable from the roots.
+func (hw *heapObjectWalker) run() error {
+	if err := forEachRootVariable(hw.t, hw.scanVariable); err != nil {
+		return err
+	}
+	for len(hw.queue) > 0 {
+		ref := hw.queue[len(hw.queue)-1]
+		hw.queue = hw.queue[:len(hw.queue)-1]
+		sz := ref.typ.Size()
+		if s := hw.findSpan(ref.addr); s != nil {
+			hw.from = s.base + s.objectIndex(ref.addr)*s.elemsize
+			// do not read past the end of the heap object
+			end := hw.from + s.elemsize
+			if uint64(sz) > end-ref.addr {
+				sz = int64(end - ref.addr)
+			}
+		}
+		if sz <= 0 || !hw.pw.hasPointers(ref.typ) {
+			continue
+		}
+		buf := make([]byte, sz)
+		if _, err := hw.mem.ReadMemory(buf, ref.addr); err != nil {
+			continue
+		}
+		hw.scan(buf, ref.addr, ref.typ)
+	}
+	return nil
+}
+
+// HeapObjects returns the live heap objects of the type called typename,
+// sorted by address and loaded using cfg. If max is greater than zero at
+// most max objects are returned.
+// The heap does not record the type of the objects it contains, the type
+// of each object is inferred by following typed pointers starting from the
+// local variables of all goroutines and from package variables: objects
+// only reachable through unsafe.Pointer values, maps or channels are not
+// found.
+func (t *Target) HeapObjects(typename string, max int, cfg LoadConfig) ([]*Variable, error) {
+	hw, err := t.walkHeapObjects(false)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := []uint64{}
+	for addr, typ := range hw.objs {
+		if typ.String() == typename {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+	if max > 0 && len(addrs) > max {
+		addrs = addrs[:max]
+	}
+
+	r := make([]*Variable, len(addrs))
+	for i, addr := range addrs {
+		r[i] = newVariable("", addr, hw.objs[addr], t.BinInfo(), t.Memory())
+		r[i].loadValue(cfg)
+	}
+	return r, nil
+}
+
+// HeapTypeUsage is the number of live heap objects of a type and the
+// memory they use, as returned by HeapUsage.
+type HeapTypeUsage struct {
+	Type  string
+	Count int
+	Bytes uint64 // sum of the sizes of the heap slots of the objects
+}
+
+// HeapUsage returns the number of live heap objects of each type and the
+// memory they use, sorted by type name. The type of heap objects is
+// inferred like HeapObjects does, objects of unknown type are not counted.
+func (t *Target) HeapUsage() ([]HeapTypeUsage, error) {
+	hw, err := t.walkHeapObjects(false)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]*HeapTypeUsage)
+	for addr, typ := range hw.objs {
+		name := typ.String()
+		u := m[name]
+		if u == nil {
+			u = &HeapTypeUsage{Type: name}
+			m[name] = u
+		}
+		u.Count++
+		if s := hw.findSpan(addr); s != nil {
+			u.Bytes += s.elemsize
+		}
+	}
+	r := make([]HeapTypeUsage, 0, len(m))
+	for _, u := range m {
+		r = append(r, *u)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Type < r[j].Type })
+	return r, nil
+}
+
+// walkHeapObjects infers the type of the live heap objects of t, if
+// graph is true the references to heap objects are also recorded.
+func (t *Target) walkHeapObjects(graph bool) (*heapObjectWalker, error) {
+	spans, err := loadHeapSpans(t.BinInfo(), t.Memory())
+	if err != nil {
+		return nil, fmt.Errorf("could not read heap spans: %v", err)
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].base < spans[j].base })
+
+	hw := &heapObjectWalker{
+		t:       t,
+		mem:     t.Memory(),
+		spans:   spans,
+		pw:      newPointerWalker(t.BinInfo(), false),
+		visited: make(map[uint64]int64),
+		objs:    make(map[uint64]godwarf.Type),
+	}
+	if graph {
+		hw.edges = make(map[heapEdge]bool)
+	}
+	if err := hw.run(); err != nil {
+		return nil, err
+	}
+	return hw, nil
+}
+
+// HeapGraph is the graph of the references between the live heap objects
+// of the target, as returned by Target.HeapGraph.
+type HeapGraph struct {
+	Objects []HeapObject // sorted by address
+	Roots   []HeapRoot   // variables referencing heap objects
+}
+
+// HeapObject is a live heap object.
+type HeapObject struct {
+	Addr uint64
+	Size uint64 // size of the heap slot containing the object
+	Type string
+	Refs []uint64 // addresses of the heap objects referenced by this object
+}
+
+// HeapRoot is a local or package variable referencing heap objects.
+type HeapRoot struct {
+	Kind ReferenceKind // StackReference or GlobalReference
+	Name string
+
+	// GoroutineID and Fn describe the stack frame containing the variable,
+	// for StackReference.
+	GoroutineID int
+	Fn          *Function
+
+	Refs []uint64 // addresses of the heap objects referenced by the variable
+}
+
+// HeapGraph returns the live heap objects of the target and the references
+// between them. The type of heap objects is inferred like HeapObjects does,
+// objects of unknown type and references stored in them are not included.
+func (t *Target) HeapGraph() (*HeapGraph, error) {
+	hw, err := t.walkHeapObjects(true)
+	if err != nil {
+		return nil, err
+	}
+	g := &HeapGraph{Objects: make([]HeapObject, 0, len(hw.objs))}
+	idx := make(map[uint64]int, len(hw.objs))
+	for addr, typ := range hw.objs {
+		var size uint64
+		if s := hw.findSpan(addr); s != nil {
+			size = s.elemsize
+		}
+		g.Objects = append(g.Objects, HeapObject{Addr: addr, Size: size, Type: typ.String()})
+	}
+	sort.Slice(g.Objects, func(i, j int) bool { return g.Objects[i].Addr < g.Objects[j].Addr })
+	for i := range g.Objects {
+		idx[g.Objects[i].Addr] = i
+	}
+	for e := range hw.edges {
+		i, ok := idx[e.from]
+		if ok {
+			if _, ok := idx[e.to]; ok {
+				g.Objects[i].Refs = append(g.Objects[i].Refs, e.to)
+			}
+		}
+	}
+	for i := range g.Objects {
+		refs := g.Objects[i].Refs
+		sort.Slice(refs, func(i, j int) bool { return refs[i] < refs[j] })
+	}
+	for _, root := range hw.roots {
+		refs := root.Refs[:0]
+		for _, addr := range root.Refs {
+			if _, ok := idx[addr]; ok {
+				refs = append(refs, addr)
+			}
+		}
+		if len(refs) > 0 {
+			root.Refs = refs
+			g.Roots = append(g.Roots, root)
+		}
+	}
+	return g, nil
+}
@@ -41,11 +41,9 @@ func I386Arch(goos string) *Arch {
 
 func i386FixFrameUnwindContext(fctxt *frame.FrameContext, pc uint64, bi *BinaryInfo) *frame.FrameContext {
 	i := bi.Arch
-	if i.sigreturnfn == nil {
-		i.sigreturnfn = bi.LookupFunc["runtime.sigreturn"]
-	}
+	sigreturnfn, crosscall2fn := i.unwindFuncs(bi)
 
-	if fctxt == nil || (i.sigreturnfn != nil && pc >= i.sigreturnfn.Entry && pc < i.sigreturnfn.End) {
+	if fctxt == nil || (sigreturnfn != nil && pc >= sigreturnfn.Entry && pc < sigreturnfn.End) {
 		// When there's no frame descriptor entry use BP (the frame pointer) instead
 		// - return register is [bp + i.PtrSize()] (i.e. [cfa-i.PtrSize()])
 		// - cfa is bp + i.PtrSize()*2
@@ -88,13 +86,9 @@ func i386FixFrameUnwindContext(fctxt *frame.FrameContext, pc uint64, bi *BinaryI
 		}
 	}
 
-	if i.crosscall2fn == nil {
-		i.crosscall2fn = bi.LookupFunc["crosscall2"]
-	}
-
 	// TODO(chainhelen), need to check whether there is a bad frame descriptor like amd64.
 	// crosscall2 is defined in $GOROOT/src/runtime/cgo/asm_386.s.
-	if i.crosscall2fn != nil && pc >= i.crosscall2fn.Entry && pc < i.crosscall2fn.End {
+	if crosscall2fn != nil && pc >= crosscall2fn.Entry && pc < crosscall2fn.End {
 		rule := fctxt.CFA
 		fctxt.CFA = rule
 	}
@@ -60,6 +60,30 @@ type ProcessInternal interface {
 	StartCallInjection() (func(), error)
 }
 
+// followExecProcess is implemented by backends that can follow the
+// children of the target process, see (*Target).FollowExec.
+type followExecProcess interface {
+	FollowExec(follow bool, match func(path string) bool) error
+	NewChildren() ([]*Target, error)
+}
+
+// execProcess is implemented by backends that detect when the target
+// process executes a new program, see (*Target).ExecTarget.
+type execProcess interface {
+	ExecTarget() (*Target, error)
+}
+
+// nonStopProcess is implemented by backends that support non-stop mode,
+// see (*Target).SetNonStop.
+type nonStopProcess interface {
+	// SetNonStop enables or disables non-stop mode, when it is disabled all
+	// the threads that are still running must be stopped.
+	SetNonStop(enabled bool) error
+	// ThreadRunning returns true if thread was not stopped by the last call
+	// to ContinueOnce.
+	ThreadRunning(thread Thread) bool
+}
+
 // RecordingManipulation is an interface for manipulating process recordings.
 type RecordingManipulation interface {
 	// Recorded returns true if the current process is a recording and the path
@@ -71,6 +95,9 @@ type RecordingManipulation interface {
 	GetDirection() Direction
 	// When returns current recording position.
 	When() (string, error)
+	// WhenTicks returns the number of ticks executed by the current thread
+	// since the start of the current event.
+	WhenTicks() (uint64, error)
 	// Checkpoint sets a checkpoint at the current position.
 	Checkpoint(where string) (id int, err error)
 	// Checkpoints returns the list of currently set checkpoint.
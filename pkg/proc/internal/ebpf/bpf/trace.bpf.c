@@ -3,6 +3,26 @@
 
 #define STRING_KIND 24
 
+#if defined(__TARGET_ARCH_arm64)
+// vmlinux.h is generated for amd64, struct pt_regs of arm64 starts with the
+// same fields as struct user_pt_regs.
+struct arm64_user_pt_regs {
+    u64 regs[31];
+    u64 sp;
+    u64 pc;
+    u64 pstate;
+};
+
+#define GO_REGS(ctx) ((struct arm64_user_pt_regs *)(ctx))
+#define GO_SP(ctx) (GO_REGS(ctx)->sp)
+#define GO_PC(ctx) (GO_REGS(ctx)->pc)
+// The Go ABI on arm64 always keeps the current g in R28.
+#define GO_G_REG 28
+#else
+#define GO_SP(ctx) ((ctx)->sp)
+#define GO_PC(ctx) ((ctx)->ip)
+#endif
+
 // parse_string_param will parse a string parameter. The parsed value of the string
 // will be put into param->deref_val. This function expects the string struct
 // which contains a pointer to the string and the length of the string to have
@@ -31,7 +51,7 @@ int parse_string_param(struct pt_regs *ctx, function_parameter_t *param) {
 __always_inline
 int parse_param_stack(struct pt_regs *ctx, function_parameter_t *param) {
     long ret;
-    size_t addr = ctx->sp + param->offset;
+    size_t addr = GO_SP(ctx) + param->offset;
     ret = bpf_probe_read_user(&param->val, param->size, (void *)(addr));
     if (ret < 0) {
         return 1;
@@ -39,6 +59,65 @@ int parse_param_stack(struct pt_regs *ctx, function_parameter_t *param) {
     return 0;
 }
 
+#if defined(__TARGET_ARCH_arm64)
+// get_value_from_register copies the value of the register with the given
+// DWARF number to dest. Go passes integer arguments in R0 through R15.
+// Accesses to ctx must use constant offsets, hence the switch.
+__always_inline
+void get_value_from_register(struct pt_regs *ctx, void *dest, int reg_num) {
+    struct arm64_user_pt_regs *regs = GO_REGS(ctx);
+    switch (reg_num) {
+        case 0:
+            memcpy(dest, &regs->regs[0], sizeof(u64));
+            break;
+        case 1:
+            memcpy(dest, &regs->regs[1], sizeof(u64));
+            break;
+        case 2:
+            memcpy(dest, &regs->regs[2], sizeof(u64));
+            break;
+        case 3:
+            memcpy(dest, &regs->regs[3], sizeof(u64));
+            break;
+        case 4:
+            memcpy(dest, &regs->regs[4], sizeof(u64));
+            break;
+        case 5:
+            memcpy(dest, &regs->regs[5], sizeof(u64));
+            break;
+        case 6:
+            memcpy(dest, &regs->regs[6], sizeof(u64));
+            break;
+        case 7:
+            memcpy(dest, &regs->regs[7], sizeof(u64));
+            break;
+        case 8:
+            memcpy(dest, &regs->regs[8], sizeof(u64));
+            break;
+        case 9:
+            memcpy(dest, &regs->regs[9], sizeof(u64));
+            break;
+        case 10:
+            memcpy(dest, &regs->regs[10], sizeof(u64));
+            break;
+        case 11:
+            memcpy(dest, &regs->regs[11], sizeof(u64));
+            break;
+        case 12:
+            memcpy(dest, &regs->regs[12], sizeof(u64));
+            break;
+        case 13:
+            memcpy(dest, &regs->regs[13], sizeof(u64));
+            break;
+        case 14:
+            memcpy(dest, &regs->regs[14], sizeof(u64));
+            break;
+        case 15:
+            memcpy(dest, &regs->regs[15], sizeof(u64));
+            break;
+    }
+}
+#else
 __always_inline
 void get_value_from_register(struct pt_regs *ctx, void *dest, int reg_num) {
     switch (reg_num) {
@@ -92,6 +171,7 @@ void get_value_from_register(struct pt_regs *ctx, void *dest, int reg_num) {
             break;
     }
 }
+#endif
 
 __always_inline
 int parse_param_registers(struct pt_regs *ctx, function_parameter_t *param) {
@@ -140,8 +220,20 @@ int parse_param(struct pt_regs *ctx, function_parameter_t *param) {
     return 0;
 }
 
+#if defined(__TARGET_ARCH_arm64)
+__always_inline
+int get_goroutine_id(struct pt_regs *ctx, function_parameter_list_t *parsed_args) {
+    __u64 goid;
+    size_t g_addr = GO_REGS(ctx)->regs[GO_G_REG];
+    if (bpf_probe_read_user(&goid, sizeof(void *), (void*)(g_addr+parsed_args->goid_offset)) < 0) {
+        return 0;
+    }
+    parsed_args->goroutine_id = goid;
+    return 1;
+}
+#else
 __always_inline
-int get_goroutine_id(function_parameter_list_t *parsed_args) {
+int get_goroutine_id(struct pt_regs *ctx, function_parameter_list_t *parsed_args) {
     // Since eBPF programs have such strict stack requirements
     // me must implement our own heap using a ringbuffer.
     // Reserve some memory in our "heap" for the task_struct.
@@ -175,6 +267,7 @@ int get_goroutine_id(function_parameter_list_t *parsed_args) {
 
     return 1;
 }
+#endif
 
 __always_inline
 void parse_params(struct pt_regs *ctx, unsigned int n_params, function_parameter_t params[6]) {
@@ -200,7 +293,7 @@ SEC("uprobe/dlv_trace")
 int uprobe__dlv_trace(struct pt_regs *ctx) {
     function_parameter_list_t *args;
     function_parameter_list_t *parsed_args;
-    uint64_t key = ctx->ip;
+    uint64_t key = GO_PC(ctx);
 
     args = bpf_map_lookup_elem(&arg_map, &key);
     if (!args) {
@@ -222,7 +315,7 @@ int uprobe__dlv_trace(struct pt_regs *ctx) {
     memcpy(parsed_args->params, args->params, sizeof(args->params));
     memcpy(parsed_args->ret_params, args->ret_params, sizeof(args->ret_params));
 
-    if (!get_goroutine_id(parsed_args)) {
+    if (!get_goroutine_id(ctx, parsed_args)) {
         bpf_ringbuf_discard(parsed_args, 0);
         return 1;
     }
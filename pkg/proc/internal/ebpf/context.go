@@ -2,6 +2,7 @@ package ebpf
 
 import (
 	"reflect"
+	"time"
 
 	"github.com/go-delve/delve/pkg/dwarf/godwarf"
 	"github.com/go-delve/delve/pkg/dwarf/op"
@@ -29,6 +30,7 @@ type RawUProbeParam struct {
 type RawUProbeParams struct {
 	FnAddr       int
 	GoroutineID  int
+	Time         time.Time // Time the event was read from the ring buffer.
 	InputParams  []*RawUProbeParam
 	ReturnParams []*RawUProbeParam
 }
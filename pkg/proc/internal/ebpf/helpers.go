@@ -12,6 +12,7 @@ import (
 	"reflect"
 	"runtime"
 	"sync"
+	"time"
 	"unsafe"
 
 	"github.com/go-delve/delve/pkg/dwarf/godwarf"
@@ -22,7 +23,10 @@ import (
 	"github.com/cilium/ebpf/ringbuf"
 )
 
-//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -tags "go1.16" -target amd64 trace bpf/trace.bpf.c -- -I./bpf/include
+// The arm64 object, trace_bpfel_arm64.o, must be generated with
+// build/build-ebpf-objects.sh before arm64 can be added to the build
+// constraints of this file.
+//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -tags "go1.16" -target amd64,arm64 trace bpf/trace.bpf.c -- -I./bpf/include
 
 const FakeAddressBase = 0xbeed000000000000
 
@@ -107,6 +111,7 @@ func LoadEBPFTracingProgram(path string) (*EBPFContext, error) {
 			}
 
 			parsed := parseFunctionParameterList(e.RawSample)
+			parsed.Time = time.Now()
 
 			ctx.m.Lock()
 			ctx.parsedBpfEvents = append(ctx.parsedBpfEvents, parsed)
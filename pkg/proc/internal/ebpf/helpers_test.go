@@ -0,0 +1,34 @@
+//go:build linux && amd64 && cgo && go1.16
+// +build linux,amd64,cgo,go1.16
+
+package ebpf
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+)
+
+// TestLoadTraceSpec checks that the BPF object embedded for the current
+// architecture can be parsed and contains the program and maps used by
+// LoadEBPFTracingProgram. Loading it in the kernel requires privileges and
+// is not done here.
+func TestLoadTraceSpec(t *testing.T) {
+	spec, err := loadTrace()
+	if err != nil {
+		t.Fatalf("could not load BPF object: %v", err)
+	}
+	var specs traceSpecs
+	if err := spec.Assign(&specs); err != nil {
+		t.Fatalf("could not assign BPF object: %v", err)
+	}
+	if typ := specs.UprobeDlvTrace.Type; typ != ebpf.Kprobe {
+		t.Errorf("wrong program type %v", typ)
+	}
+	if typ := specs.ArgMap.Type; typ != ebpf.Hash {
+		t.Errorf("wrong type for arg_map %v", typ)
+	}
+	if typ := specs.Events.Type; typ != ebpf.RingBuf {
+		t.Errorf("wrong type for events %v", typ)
+	}
+}
@@ -164,6 +164,7 @@ func ElfUpdateSharedObjects(p proc.Process) error {
 	}
 
 	libs := []string{}
+	addrs := []uint64{}
 
 	for {
 		if r_map == 0 {
@@ -176,10 +177,12 @@ func ElfUpdateSharedObjects(p proc.Process) error {
 		if err != nil {
 			return err
 		}
-		bi.AddImage(lm.name, lm.addr)
 		libs = append(libs, lm.name)
+		addrs = append(addrs, lm.addr)
 		r_map = lm.next
 	}
 
+	bi.AddImages(libs, addrs)
+
 	return nil
 }
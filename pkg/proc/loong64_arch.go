@@ -0,0 +1,161 @@
+package proc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/go-delve/delve/pkg/dwarf/frame"
+	"github.com/go-delve/delve/pkg/dwarf/op"
+	"github.com/go-delve/delve/pkg/dwarf/regnum"
+)
+
+var loong64BreakInstruction = []byte{0x00, 0x00, 0x2a, 0x00} // BREAK 0
+
+// LOONG64Arch returns an initialized LOONG64
+// struct.
+func LOONG64Arch(goos string) *Arch {
+	return &Arch{
+		Name:                             "loong64",
+		ptrSize:                          8,
+		maxInstructionLength:             4,
+		breakpointInstruction:            loong64BreakInstruction,
+		breakInstrMovesPC:                false,
+		derefTLS:                         false,
+		prologues:                        prologuesLOONG64,
+		fixFrameUnwindContext:            loong64FixFrameUnwindContext,
+		switchStack:                      loong64SwitchStack,
+		regSize:                          loong64RegSize,
+		RegistersToDwarfRegisters:        loong64RegistersToDwarfRegisters,
+		addrAndStackRegsToDwarfRegisters: loong64AddrAndStackRegsToDwarfRegisters,
+		DwarfRegisterToString:            loong64DwarfRegisterToString,
+		inhibitStepInto:                  func(*BinaryInfo, uint64) bool { return false },
+		asmDecode:                        loong64AsmDecode,
+		usesLR:                           true,
+		PCRegNum:                         regnum.LOONG64_PC,
+		SPRegNum:                         regnum.LOONG64_SP,
+		BPRegNum:                         regnum.LOONG64_BP,
+		ContextRegNum:                    regnum.LOONG64_R0 + 29,
+		LRRegNum:                         regnum.LOONG64_LR,
+		asmRegisters:                     loong64AsmRegisters,
+		RegisterNameToDwarf:              nameToDwarfFunc(regnum.LOONG64NameToDwarf),
+		maxRegArgBytes:                   16*8 + 16*8, // 16 int argument registers plus 16 float argument registers
+	}
+}
+
+func loong64FixFrameUnwindContext(fctxt *frame.FrameContext, pc uint64, bi *BinaryInfo) *frame.FrameContext {
+	if fctxt == nil {
+		// Go does not maintain a frame pointer on loong64, when there's no
+		// frame descriptor entry the best we can do is assume that we are
+		// stopped at the entry point of a function:
+		// - cfa is sp
+		// - the return address is in the link register
+		return &frame.FrameContext{
+			RetAddrReg: regnum.LOONG64_LR,
+			Regs: map[uint64]frame.DWRule{
+				regnum.LOONG64_LR: frame.DWRule{
+					Rule: frame.RuleSameVal,
+				},
+				regnum.LOONG64_SP: frame.DWRule{
+					Rule:   frame.RuleValOffset,
+					Offset: 0,
+				},
+			},
+			CFA: frame.DWRule{
+				Rule:   frame.RuleCFA,
+				Reg:    regnum.LOONG64_SP,
+				Offset: 0,
+			},
+		}
+	}
+
+	return fctxt
+}
+
+// loong64cgocallSPOffsetSaveSlot is the offset from the system stack
+// pointer where runtime.asmcgocall saves the distance between the
+// goroutine stack pointer and the top of the goroutine stack, see
+// $GOROOT/src/runtime/asm_loong64.s.
+const loong64cgocallSPOffsetSaveSlot = 0x8
+
+func loong64SwitchStack(it *stackIterator, callFrameRegs *op.DwarfRegisters) bool {
+	if it.frame.Current.Fn == nil && it.systemstack && it.g != nil && it.top && !it.unwindUnknownFrame() {
+		it.switchToGoroutineStack()
+		return true
+	}
+	if it.frame.Current.Fn != nil {
+		switch it.frame.Current.Fn.Name {
+		case "runtime.asmcgocall", "runtime.cgocallback", "runtime.sigpanic":
+			//do nothing
+		case "runtime.goexit", "runtime.rt0_go", "runtime.mcall":
+			// Look for "top of stack" functions.
+			it.atend = true
+			return true
+		default:
+			if it.systemstack && it.top && it.g != nil && strings.HasPrefix(it.frame.Current.Fn.Name, "runtime.") && it.frame.Current.Fn.Name != "runtime.throw" && it.frame.Current.Fn.Name != "runtime.fatalthrow" {
+				// The runtime switches to the system stack in multiple places,
+				// since we are only interested in printing the system stack for
+				// cgo calls we switch directly to the goroutine stack, see the
+				// comment in arm64SwitchStack.
+				it.switchToGoroutineStack()
+				return true
+			}
+		}
+	}
+
+	fn := it.bi.PCToFunc(it.frame.Ret)
+	if fn == nil || fn.Name != "runtime.asmcgocall" || !it.systemstack {
+		return false
+	}
+
+	// This function is called by a goroutine to execute a C function and
+	// switches from the goroutine stack to the system stack.
+	// Since we are unwinding the stack from callee to caller we have to switch
+	// from the system stack to the goroutine stack.
+	off, _ := readIntRaw(it.mem, uint64(callFrameRegs.SP()+loong64cgocallSPOffsetSaveSlot), int64(it.bi.Arch.PtrSize()))
+	oldsp := callFrameRegs.SP()
+	newsp := uint64(int64(it.stackhi) - off)
+
+	// runtime.asmcgocall can also be called from inside the system stack,
+	// in that case no stack switch actually happens
+	if newsp == oldsp {
+		return false
+	}
+	it.systemstack = false
+	callFrameRegs.Reg(callFrameRegs.SPRegNum).Uint64Val = uint64(int64(newsp))
+	return false
+}
+
+func loong64RegSize(regnum uint64) int {
+	return 8 // general and fp registers
+}
+
+func loong64RegistersToDwarfRegisters(staticBase uint64, regs Registers) *op.DwarfRegisters {
+	dregs := initDwarfRegistersFromSlice(int(regnum.LOONG64MaxRegNum()), regs, regnum.LOONG64NameToDwarf)
+	dr := op.NewDwarfRegisters(staticBase, dregs, binary.LittleEndian, regnum.LOONG64_PC, regnum.LOONG64_SP, regnum.LOONG64_BP, regnum.LOONG64_LR)
+	dr.SetLoadMoreCallback(loadMoreDwarfRegistersFromSliceFunc(dr, regs, regnum.LOONG64NameToDwarf))
+	return dr
+}
+
+func loong64AddrAndStackRegsToDwarfRegisters(staticBase, pc, sp, bp, lr uint64) op.DwarfRegisters {
+	dregs := make([]*op.DwarfRegister, regnum.LOONG64_PC+1)
+	dregs[regnum.LOONG64_PC] = op.DwarfRegisterFromUint64(pc)
+	dregs[regnum.LOONG64_SP] = op.DwarfRegisterFromUint64(sp)
+	dregs[regnum.LOONG64_BP] = op.DwarfRegisterFromUint64(bp)
+	dregs[regnum.LOONG64_LR] = op.DwarfRegisterFromUint64(lr)
+
+	return *op.NewDwarfRegisters(staticBase, dregs, binary.LittleEndian, regnum.LOONG64_PC, regnum.LOONG64_SP, regnum.LOONG64_BP, regnum.LOONG64_LR)
+}
+
+func loong64DwarfRegisterToString(i int, reg *op.DwarfRegister) (name string, floatingPoint bool, repr string) {
+	name = regnum.LOONG64ToName(uint64(i))
+
+	if reg == nil {
+		return name, false, ""
+	}
+
+	if name[0] == 'F' {
+		return name, true, fmt.Sprintf("%#016x", reg.Uint64Val)
+	}
+	return name, false, fmt.Sprintf("%#016x", reg.Uint64Val)
+}
@@ -0,0 +1,422 @@
+package proc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/go-delve/delve/pkg/dwarf/op"
+	"github.com/go-delve/delve/pkg/dwarf/regnum"
+)
+
+// golang.org/x/arch does not provide a LoongArch decoder, the instructions
+// below are the subset of LA64 that Delve needs to classify calls, returns
+// and jumps, to recognize function prologues and to produce a readable
+// listing of Go code. Everything else is printed as raw data.
+
+type loong64Op uint16
+
+const (
+	loong64OpUnknown loong64Op = iota
+	loong64OpBEQZ
+	loong64OpBNEZ
+	loong64OpBCEQZ
+	loong64OpBCNEZ
+	loong64OpJIRL
+	loong64OpB
+	loong64OpBL
+	loong64OpBEQ
+	loong64OpBNE
+	loong64OpBLT
+	loong64OpBGE
+	loong64OpBLTU
+	loong64OpBGEU
+	loong64OpLU12IW
+	loong64OpLU32ID
+	loong64OpPCADDI
+	loong64OpPCALAU12I
+	loong64OpPCADDU12I
+	loong64OpPCADDU18I
+	loong64OpSLTI
+	loong64OpSLTUI
+	loong64OpADDIW
+	loong64OpADDID
+	loong64OpLU52ID
+	loong64OpANDI
+	loong64OpORI
+	loong64OpXORI
+	loong64OpLDB
+	loong64OpLDH
+	loong64OpLDW
+	loong64OpLDD
+	loong64OpSTB
+	loong64OpSTH
+	loong64OpSTW
+	loong64OpSTD
+	loong64OpLDBU
+	loong64OpLDHU
+	loong64OpLDWU
+	loong64OpADDW
+	loong64OpADDD
+	loong64OpSUBW
+	loong64OpSUBD
+	loong64OpSLT
+	loong64OpSLTU
+	loong64OpNOR
+	loong64OpAND
+	loong64OpOR
+	loong64OpXOR
+	loong64OpMASKEQZ
+	loong64OpMASKNEZ
+	loong64OpSLLW
+	loong64OpSRLW
+	loong64OpSRAW
+	loong64OpSLLD
+	loong64OpSRLD
+	loong64OpSRAD
+	loong64OpMULW
+	loong64OpMULD
+	loong64OpMULHD
+	loong64OpMULHDU
+	loong64OpDIVD
+	loong64OpMODD
+	loong64OpDIVDU
+	loong64OpMODDU
+	loong64OpSLLID
+	loong64OpSRLID
+	loong64OpSRAID
+	loong64OpBREAK
+	loong64OpSYSCALL
+)
+
+var loong64OpNames = [...]string{
+	loong64OpUnknown:   "?",
+	loong64OpBEQZ:      "beqz",
+	loong64OpBNEZ:      "bnez",
+	loong64OpBCEQZ:     "bceqz",
+	loong64OpBCNEZ:     "bcnez",
+	loong64OpJIRL:      "jirl",
+	loong64OpB:         "b",
+	loong64OpBL:        "bl",
+	loong64OpBEQ:       "beq",
+	loong64OpBNE:       "bne",
+	loong64OpBLT:       "blt",
+	loong64OpBGE:       "bge",
+	loong64OpBLTU:      "bltu",
+	loong64OpBGEU:      "bgeu",
+	loong64OpLU12IW:    "lu12i.w",
+	loong64OpLU32ID:    "lu32i.d",
+	loong64OpPCADDI:    "pcaddi",
+	loong64OpPCALAU12I: "pcalau12i",
+	loong64OpPCADDU12I: "pcaddu12i",
+	loong64OpPCADDU18I: "pcaddu18i",
+	loong64OpSLTI:      "slti",
+	loong64OpSLTUI:     "sltui",
+	loong64OpADDIW:     "addi.w",
+	loong64OpADDID:     "addi.d",
+	loong64OpLU52ID:    "lu52i.d",
+	loong64OpANDI:      "andi",
+	loong64OpORI:       "ori",
+	loong64OpXORI:      "xori",
+	loong64OpLDB:       "ld.b",
+	loong64OpLDH:       "ld.h",
+	loong64OpLDW:       "ld.w",
+	loong64OpLDD:       "ld.d",
+	loong64OpSTB:       "st.b",
+	loong64OpSTH:       "st.h",
+	loong64OpSTW:       "st.w",
+	loong64OpSTD:       "st.d",
+	loong64OpLDBU:      "ld.bu",
+	loong64OpLDHU:      "ld.hu",
+	loong64OpLDWU:      "ld.wu",
+	loong64OpADDW:      "add.w",
+	loong64OpADDD:      "add.d",
+	loong64OpSUBW:      "sub.w",
+	loong64OpSUBD:      "sub.d",
+	loong64OpSLT:       "slt",
+	loong64OpSLTU:      "sltu",
+	loong64OpNOR:       "nor",
+	loong64OpAND:       "and",
+	loong64OpOR:        "or",
+	loong64OpXOR:       "xor",
+	loong64OpMASKEQZ:   "maskeqz",
+	loong64OpMASKNEZ:   "masknez",
+	loong64OpSLLW:      "sll.w",
+	loong64OpSRLW:      "srl.w",
+	loong64OpSRAW:      "sra.w",
+	loong64OpSLLD:      "sll.d",
+	loong64OpSRLD:      "srl.d",
+	loong64OpSRAD:      "sra.d",
+	loong64OpMULW:      "mul.w",
+	loong64OpMULD:      "mul.d",
+	loong64OpMULHD:     "mulh.d",
+	loong64OpMULHDU:    "mulh.du",
+	loong64OpDIVD:      "div.d",
+	loong64OpMODD:      "mod.d",
+	loong64OpDIVDU:     "div.du",
+	loong64OpMODDU:     "mod.du",
+	loong64OpSLLID:     "slli.d",
+	loong64OpSRLID:     "srli.d",
+	loong64OpSRAID:     "srai.d",
+	loong64OpBREAK:     "break",
+	loong64OpSYSCALL:   "syscall",
+}
+
+var (
+	// indexed by the 6 bit opcode minus 0x10
+	loong64BranchOps = [...]loong64Op{loong64OpBEQZ, loong64OpBNEZ, loong64OpUnknown, loong64OpJIRL, loong64OpB, loong64OpBL, loong64OpBEQ, loong64OpBNE, loong64OpBLT, loong64OpBGE, loong64OpBLTU, loong64OpBGEU}
+	// indexed by the 7 bit opcode minus 0x0a
+	loong64Imm20Ops = [...]loong64Op{loong64OpLU12IW, loong64OpLU32ID, loong64OpPCADDI, loong64OpPCALAU12I, loong64OpPCADDU12I, loong64OpPCADDU18I}
+	// indexed by the 10 bit opcode minus 0x008
+	loong64Imm12Ops = [...]loong64Op{loong64OpSLTI, loong64OpSLTUI, loong64OpADDIW, loong64OpADDID, loong64OpLU52ID, loong64OpANDI, loong64OpORI, loong64OpXORI}
+	// indexed by the 10 bit opcode minus 0x0a0
+	loong64MemOps = [...]loong64Op{loong64OpLDB, loong64OpLDH, loong64OpLDW, loong64OpLDD, loong64OpSTB, loong64OpSTH, loong64OpSTW, loong64OpSTD, loong64OpLDBU, loong64OpLDHU, loong64OpLDWU}
+	// indexed by the 17 bit opcode
+	loong64RegOps = map[uint32]loong64Op{
+		0x20: loong64OpADDW,
+		0x21: loong64OpADDD,
+		0x22: loong64OpSUBW,
+		0x23: loong64OpSUBD,
+		0x24: loong64OpSLT,
+		0x25: loong64OpSLTU,
+		0x26: loong64OpMASKEQZ,
+		0x27: loong64OpMASKNEZ,
+		0x28: loong64OpNOR,
+		0x29: loong64OpAND,
+		0x2a: loong64OpOR,
+		0x2b: loong64OpXOR,
+		0x2e: loong64OpSLLW,
+		0x2f: loong64OpSRLW,
+		0x30: loong64OpSRAW,
+		0x31: loong64OpSLLD,
+		0x32: loong64OpSRLD,
+		0x33: loong64OpSRAD,
+		0x38: loong64OpMULW,
+		0x3b: loong64OpMULD,
+		0x3c: loong64OpMULHD,
+		0x3d: loong64OpMULHDU,
+		0x44: loong64OpDIVD,
+		0x45: loong64OpMODD,
+		0x46: loong64OpDIVDU,
+		0x47: loong64OpMODDU,
+		0x54: loong64OpBREAK,
+		0x56: loong64OpSYSCALL,
+	}
+	// indexed by the 16 bit opcode
+	loong64ShiftOps = map[uint32]loong64Op{
+		0x41: loong64OpSLLID,
+		0x45: loong64OpSRLID,
+		0x49: loong64OpSRAID,
+	}
+)
+
+var errLOONG64Truncated = errors.New("truncated instruction")
+
+// loong64Inst is a decoded LoongArch instruction.
+type loong64Inst struct {
+	Op         loong64Op
+	Rd, Rj, Rk uint8
+	Imm        int64 // immediate operand, branch offsets are relative to the instruction address
+	Enc        uint32
+}
+
+func loong64Decode(mem []byte) (loong64Inst, error) {
+	if len(mem) < 4 {
+		return loong64Inst{}, errLOONG64Truncated
+	}
+
+	x := binary.LittleEndian.Uint32(mem)
+	inst := loong64Inst{Enc: x, Rd: uint8(x & 0x1f), Rj: uint8(x >> 5 & 0x1f), Rk: uint8(x >> 10 & 0x1f)}
+	offs16 := x >> 10 & 0xffff
+
+	switch op6, op7, op10 := x>>26, x>>25, x>>22; {
+	case op6 >= 0x10 && op6 <= 0x1b:
+		inst.Op = loong64BranchOps[op6-0x10]
+		switch op6 {
+		case 0x10, 0x11:
+			inst.Imm = signExtend(offs16|(x&0x1f)<<16, 21) << 2
+		case 0x12:
+			inst.Imm = signExtend(offs16|(x&0x1f)<<16, 21) << 2
+			switch x >> 8 & 3 {
+			case 0:
+				inst.Op = loong64OpBCEQZ
+			case 1:
+				inst.Op = loong64OpBCNEZ
+			}
+			inst.Rj &= 0x7 // condition flag register
+		case 0x14, 0x15:
+			inst.Imm = signExtend(offs16|(x&0x3ff)<<16, 26) << 2
+		default:
+			inst.Imm = signExtend(offs16, 16) << 2
+		}
+	case op7 >= 0x0a && op7 <= 0x0f:
+		inst.Op = loong64Imm20Ops[op7-0x0a]
+		inst.Imm = signExtend(x>>5&0xfffff, 20)
+	case op10 >= 0x008 && op10 <= 0x00f:
+		inst.Op = loong64Imm12Ops[op10-0x008]
+		switch inst.Op {
+		case loong64OpANDI, loong64OpORI, loong64OpXORI:
+			inst.Imm = int64(x >> 10 & 0xfff)
+		default:
+			inst.Imm = signExtend(x>>10&0xfff, 12)
+		}
+	case op10 >= 0x0a0 && op10 <= 0x0aa:
+		inst.Op = loong64MemOps[op10-0x0a0]
+		inst.Imm = signExtend(x>>10&0xfff, 12)
+	case loong64ShiftOps[x>>16] != loong64OpUnknown:
+		inst.Op = loong64ShiftOps[x>>16]
+		inst.Imm = int64(x >> 10 & 0x3f)
+	default:
+		inst.Op = loong64RegOps[x>>15]
+		if inst.Op == loong64OpBREAK || inst.Op == loong64OpSYSCALL {
+			inst.Imm = int64(x & 0x7fff)
+		}
+	}
+	return inst, nil
+}
+
+func loong64AsmDecode(asmInst *AsmInstruction, mem []byte, regs *op.DwarfRegisters, memrw MemoryReadWriter, bi *BinaryInfo) error {
+	inst, err := loong64Decode(mem)
+	if err != nil {
+		asmInst.Size = len(mem)
+		asmInst.Bytes = mem
+		asmInst.Inst = (*loong64ArchInst)(nil)
+		return err
+	}
+
+	asmInst.Size = 4
+	asmInst.Bytes = mem[:asmInst.Size]
+	asmInst.Inst = (*loong64ArchInst)(&inst)
+	asmInst.Kind = OtherInstruction
+
+	switch inst.Op {
+	case loong64OpBL:
+		asmInst.Kind = CallInstruction
+	case loong64OpB:
+		asmInst.Kind = JmpInstruction
+	case loong64OpJIRL:
+		switch {
+		case inst.Rd == 0 && inst.Rj == regnum.LOONG64_LR && inst.Imm == 0:
+			asmInst.Kind = RetInstruction
+		case inst.Rd == 0:
+			asmInst.Kind = JmpInstruction
+		default:
+			asmInst.Kind = CallInstruction
+		}
+	case loong64OpBREAK:
+		asmInst.Kind = HardBreakInstruction
+	}
+
+	asmInst.DestLoc = resolveCallArgLOONG64(&inst, asmInst.Loc.PC, asmInst.AtPC, regs, bi)
+
+	return nil
+}
+
+func resolveCallArgLOONG64(inst *loong64Inst, instAddr uint64, currentGoroutine bool, regs *op.DwarfRegisters, bininfo *BinaryInfo) *Location {
+	switch inst.Op {
+	case loong64OpB, loong64OpBL, loong64OpBEQZ, loong64OpBNEZ, loong64OpBCEQZ, loong64OpBCNEZ, loong64OpBEQ, loong64OpBNE, loong64OpBLT, loong64OpBGE, loong64OpBLTU, loong64OpBGEU:
+		return pcToDestLoc(bininfo, instAddr+uint64(inst.Imm))
+	case loong64OpJIRL:
+		if !currentGoroutine || regs == nil {
+			return nil
+		}
+		pc, err := bininfo.Arch.getAsmRegister(regs, int(inst.Rj))
+		if err != nil {
+			return nil
+		}
+		return pcToDestLoc(bininfo, pc+uint64(inst.Imm))
+	}
+	return nil
+}
+
+// Possible stacksplit prologues are inserted by stacksplit in
+// $GOROOT/src/cmd/internal/obj/loong64/obj.go.
+var prologuesLOONG64 []opcodeSeq
+
+func init() {
+	var smallStacksplit = opcodeSeq{uint64(loong64OpSLTU)}
+	var bigStacksplit = opcodeSeq{uint64(loong64OpADDID), uint64(loong64OpSLTU)}
+	var getStackguard = opcodeSeq{uint64(loong64OpLDD)}
+
+	prologuesLOONG64 = make([]opcodeSeq, 0, 4)
+	for _, stacksplit := range []opcodeSeq{smallStacksplit, bigStacksplit} {
+		// The condition of the branch to runtime.morestack changed between
+		// Go versions.
+		for _, branch := range []loong64Op{loong64OpBEQZ, loong64OpBNEZ} {
+			prologue := make(opcodeSeq, 0, len(getStackguard)+len(stacksplit)+1)
+			prologue = append(prologue, getStackguard...)
+			prologue = append(prologue, stacksplit...)
+			prologue = append(prologue, uint64(branch))
+			prologuesLOONG64 = append(prologuesLOONG64, prologue)
+		}
+	}
+}
+
+type loong64ArchInst loong64Inst
+
+// Text returns the instruction in GNU syntax, using ABI register names,
+// regardless of flavour.
+func (inst *loong64ArchInst) Text(flavour AssemblyFlavour, pc uint64, symLookup func(uint64) (string, uint64)) string {
+	if inst == nil {
+		return "?"
+	}
+
+	reg := func(n uint8) string { return "$" + regnum.LOONG64ABIName(int(n)) }
+	name := loong64OpNames[inst.Op]
+	rd, rj, rk := reg(inst.Rd), reg(inst.Rj), reg(inst.Rk)
+	target := branchTargetString(pc+uint64(inst.Imm), symLookup)
+
+	switch inst.Op {
+	case loong64OpUnknown:
+		return fmt.Sprintf(".word %#08x", inst.Enc)
+	case loong64OpB, loong64OpBL:
+		return name + " " + target
+	case loong64OpBEQZ, loong64OpBNEZ:
+		return fmt.Sprintf("%s %s, %s", name, rj, target)
+	case loong64OpBCEQZ, loong64OpBCNEZ:
+		return fmt.Sprintf("%s $fcc%d, %s", name, inst.Rj, target)
+	case loong64OpBEQ, loong64OpBNE, loong64OpBLT, loong64OpBGE, loong64OpBLTU, loong64OpBGEU:
+		return fmt.Sprintf("%s %s, %s, %s", name, rj, rd, target)
+	case loong64OpJIRL:
+		switch {
+		case inst.Rd == 0 && inst.Rj == regnum.LOONG64_LR && inst.Imm == 0:
+			return "ret"
+		case inst.Rd == 0 && inst.Imm == 0:
+			return "jr " + rj
+		}
+		return fmt.Sprintf("%s %s, %s, %d", name, rd, rj, inst.Imm)
+	case loong64OpLU12IW, loong64OpLU32ID, loong64OpPCADDI, loong64OpPCALAU12I, loong64OpPCADDU12I, loong64OpPCADDU18I:
+		return fmt.Sprintf("%s %s, %d", name, rd, inst.Imm)
+	case loong64OpANDI:
+		if inst.Rd == 0 && inst.Rj == 0 && inst.Imm == 0 {
+			return "nop"
+		}
+		return fmt.Sprintf("%s %s, %s, %#x", name, rd, rj, inst.Imm)
+	case loong64OpORI, loong64OpXORI:
+		return fmt.Sprintf("%s %s, %s, %#x", name, rd, rj, inst.Imm)
+	case loong64OpSLTI, loong64OpSLTUI, loong64OpADDIW, loong64OpADDID, loong64OpLU52ID, loong64OpLDB, loong64OpLDH, loong64OpLDW, loong64OpLDD, loong64OpSTB, loong64OpSTH, loong64OpSTW, loong64OpSTD, loong64OpLDBU, loong64OpLDHU, loong64OpLDWU, loong64OpSLLID, loong64OpSRLID, loong64OpSRAID:
+		return fmt.Sprintf("%s %s, %s, %d", name, rd, rj, inst.Imm)
+	case loong64OpBREAK, loong64OpSYSCALL:
+		return fmt.Sprintf("%s %#x", name, inst.Imm)
+	case loong64OpOR:
+		if inst.Rk == 0 {
+			return fmt.Sprintf("move %s, %s", rd, rj)
+		}
+	}
+	return fmt.Sprintf("%s %s, %s, %s", name, rd, rj, rk)
+}
+
+func (inst *loong64ArchInst) OpcodeEquals(op uint64) bool {
+	if inst == nil {
+		return false
+	}
+	return uint64(inst.Op) == op
+}
+
+var loong64AsmRegisters = func() map[int]asmRegister {
+	r := make(map[int]asmRegister)
+	for i := 0; i <= 31; i++ {
+		r[i] = asmRegister{regnum.LOONG64_R0 + uint64(i), 0, 0}
+	}
+	return r
+}()
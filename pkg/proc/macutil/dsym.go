@@ -0,0 +1,26 @@
+package macutil
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// SpotlightDsyms uses Spotlight to find the .dSYM bundles containing the
+// debug symbols of the Mach-O file with the specified UUID, which must be
+// formatted as an uppercase string with dashes.
+func SpotlightDsyms(uuid string) ([]string, error) {
+	if _, err := exec.LookPath("mdfind"); err != nil {
+		return nil, err
+	}
+	out, err := exec.Command("mdfind", "com_apple_xcode_dsym_uuids == "+uuid).Output()
+	if err != nil {
+		return nil, err
+	}
+	var r []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			r = append(r, line)
+		}
+	}
+	return r, nil
+}
@@ -115,6 +115,14 @@ func newCompositeMemory(mem MemoryReadWriter, arch *Arch, regs op.DwarfRegisters
 		switch piece.Kind {
 		case op.RegPiece:
 			reg := regs.Bytes(piece.Val)
+			if reg == nil && piece.Size > 0 {
+				// The register was not saved by the callee, which happens for
+				// all registers used to pass arguments when this isn't the
+				// topmost frame.
+				piece.Kind = op.UnavailablePiece
+				cmem.data = append(cmem.data, make([]byte, piece.Size)...)
+				continue
+			}
 			if piece.Size == 0 && i == len(pieces)-1 {
 				piece.Size = len(reg)
 			}
@@ -143,6 +151,8 @@ func newCompositeMemory(mem MemoryReadWriter, arch *Arch, regs op.DwarfRegisters
 				binary.LittleEndian.PutUint64(buf, piece.Val)
 			}
 			cmem.data = append(cmem.data, buf[:piece.Size]...)
+		case op.UnavailablePiece:
+			cmem.data = append(cmem.data, make([]byte, piece.Size)...)
 		default:
 			panic("unsupported piece kind")
 		}
@@ -150,11 +160,23 @@ func newCompositeMemory(mem MemoryReadWriter, arch *Arch, regs op.DwarfRegisters
 	return cmem, nil
 }
 
+// errOptimizedOut is returned when reading a part of a composite location
+// that is not available, for example an argument passed in a register that
+// was reused after the last use of the argument.
+var errOptimizedOut = errors.New("optimized out")
+
 func (mem *compositeMemory) ReadMemory(data []byte, addr uint64) (int, error) {
 	addr -= mem.base
 	if addr >= uint64(len(mem.data)) || addr+uint64(len(data)) > uint64(len(mem.data)) {
 		return 0, errors.New("read out of bounds")
 	}
+	curAddr := uint64(0)
+	for _, piece := range mem.pieces {
+		if piece.Kind == op.UnavailablePiece && curAddr < addr+uint64(len(data)) && addr < curAddr+uint64(piece.Size) {
+			return 0, errOptimizedOut
+		}
+		curAddr += uint64(piece.Size)
+	}
 	copy(data, mem.data[addr:addr+uint64(len(data))])
 	return len(data), nil
 }
@@ -193,6 +215,8 @@ func (mem *compositeMemory) WriteMemory(addr uint64, data []byte) (int, error) {
 			case op.ImmPiece:
 				//TODO(aarzilli): maybe return an error if the user tried to change the value?
 				// nothing to do
+			case op.UnavailablePiece:
+				return donesz, errOptimizedOut
 			default:
 				panic("unsupported piece kind")
 			}
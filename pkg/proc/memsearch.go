@@ -0,0 +1,123 @@
+package proc
+
+import (
+	"bytes"
+	"errors"
+)
+
+// memSearchChunkSize is the size of the reads issued by SearchMemory.
+const memSearchChunkSize = 1024 * 1024
+
+// MemorySearchMatch is an occurrence of a pattern found by SearchMemory.
+type MemorySearchMatch struct {
+	Addr    uint64
+	Mapping MemoryMapEntry // memory mapping containing Addr
+}
+
+// SearchMemory scans the readable memory mappings of the target process
+// for occurrences of pattern.
+// If end is not zero only occurrences that start in the interval
+// [start, end) are reported. If max is greater than zero at most max
+// matches are returned.
+// If the backend can not produce a memory map of the target process the
+// interval [start, end) must be specified and will be searched as if it
+// was a single mapping.
+// Parts of the mappings that can not be read are skipped and returned as
+// unreadable, each with the same attributes as the mapping it belongs to.
+func (t *Target) SearchMemory(pattern []byte, start, end uint64, max int) (matches []MemorySearchMatch, unreadable []MemoryMapEntry, err error) {
+	if len(pattern) == 0 {
+		return nil, nil, errors.New("empty search pattern")
+	}
+	if end != 0 && end <= start {
+		return nil, nil, errors.New("invalid search range")
+	}
+
+	memmap, err := t.proc.MemoryMap()
+	if err != nil {
+		if err != ErrMemoryMapNotSupported || end == 0 {
+			return nil, nil, err
+		}
+		memmap = []MemoryMapEntry{{Addr: start, Size: end - start, Read: true}}
+	}
+
+	mem := t.Memory()
+	buf := make([]byte, memSearchChunkSize+len(pattern)-1)
+	matches = []MemorySearchMatch{}
+
+	// skip records [lo, hi) of mme as unreadable, merging it with the
+	// previous unreadable range if they overlap.
+	skip := func(mme *MemoryMapEntry, lo, hi uint64) {
+		if n := len(unreadable); n > 0 {
+			last := &unreadable[n-1]
+			if last.Addr+last.Size >= lo && last.Addr <= lo {
+				if hi > last.Addr+last.Size {
+					last.Size = hi - last.Addr
+				}
+				return
+			}
+		}
+		e := *mme
+		e.Offset += lo - mme.Addr
+		e.Addr, e.Size = lo, hi-lo
+		unreadable = append(unreadable, e)
+	}
+
+	for _, mme := range memmap {
+		if !mme.Read || mme.Size == 0 {
+			continue
+		}
+		lo, hi := mme.Addr, mme.Addr+mme.Size
+		if end != 0 {
+			if lo < start {
+				lo = start
+			}
+			if hi > end+uint64(len(pattern)-1) {
+				hi = end + uint64(len(pattern)-1)
+			}
+		}
+		if lo >= hi {
+			continue
+		}
+
+		// Each chunk is read together with the len(pattern)-1 bytes that follow
+		// it, so that matches straddling two chunks are not missed.
+		for addr := lo; addr < hi; addr += memSearchChunkSize {
+			sz := hi - addr
+			if sz > uint64(len(buf)) {
+				sz = uint64(len(buf))
+			}
+			chunk := buf[:sz]
+			n, err := mem.ReadMemory(chunk, addr)
+			if err != nil || n < len(chunk) {
+				if n < 0 {
+					n = 0
+				}
+				skip(&mme, addr+uint64(n), addr+sz)
+			}
+			chunk = chunk[:n]
+
+			for off := 0; ; {
+				i := bytes.Index(chunk[off:], pattern)
+				if i < 0 {
+					break
+				}
+				off += i
+				if off >= memSearchChunkSize {
+					// will be found again by the next chunk
+					break
+				}
+				matchAddr := addr + uint64(off)
+				if end != 0 && matchAddr >= end {
+					break
+				}
+				matches = append(matches, MemorySearchMatch{Addr: matchAddr, Mapping: mme})
+				if max > 0 && len(matches) >= max {
+					return matches, unreadable, nil
+				}
+				off++
+			}
+		}
+	}
+
+	return matches, unreadable, nil
+}
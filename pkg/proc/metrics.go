@@ -0,0 +1,345 @@
+package proc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go/constant"
+	"reflect"
+	"time"
+
+	"github.com/go-delve/delve/pkg/dwarf/godwarf"
+)
+
+const (
+	timeHistMinBucketBits = 9  // +rtype timeHistMinBucketBits
+	timeHistSubBucketBits = 2  // +rtype timeHistSubBucketBits
+	timeHistNumBuckets    = 40 // +rtype timeHistNumBuckets
+)
+
+// RuntimeMetrics are statistics kept by the runtime, read from the memory
+// of the target. Metrics that the runtime of the target doesn't have are
+// listed in Unavailable and left to zero.
+type RuntimeMetrics struct {
+	// HeapLive is the number of bytes of heap considered live by the GC:
+	// the bytes marked by the last cycle plus the bytes allocated since.
+	HeapLive uint64
+	// HeapMarked is the number of bytes marked by the last GC cycle.
+	HeapMarked uint64
+	// HeapGoal is the value of HeapLive at which the next GC cycle should
+	// end, derived from GOGC without considering the memory limit.
+	HeapGoal uint64
+
+	NumGC        uint64        // number of completed GC cycles
+	NumForcedGC  uint64        // number of GC cycles forced by calls to runtime.GC
+	GCPauseTotal time.Duration // total time spent in stop-the-world pauses
+
+	Goroutines int // number of goroutines, excluding system goroutines
+	GOMAXPROCS int
+
+	// SchedLatency is the distribution of the time goroutines spent
+	// runnable before running, only buckets with a non-zero count are
+	// included.
+	SchedLatency []HistogramBucket
+
+	Unavailable []string
+}
+
+// HistogramBucket is a bucket of a histogram of durations.
+type HistogramBucket struct {
+	Min, Max time.Duration // range of the bucket, Max is excluded
+	Count    uint64
+}
+
+// RuntimeMetrics returns the metrics of the runtime of the target.
+func (t *Target) RuntimeMetrics() (*RuntimeMetrics, error) {
+	// +rtype -var gcController gcControllerState
+	// +rtype -var memstats mstats
+	// +rtype -var gomaxprocs int32
+	// +rtype -var sched schedt
+
+	r := newRuntimeVarReader(t)
+	m := &RuntimeMetrics{}
+
+	gcController := r.global("gcController", "HeapLive", "HeapMarked", "HeapGoal")
+	m.HeapLive = r.uintField(gcController, "heapLive", "HeapLive")          // +rtype -field gcControllerState.heapLive anytype
+	m.HeapMarked = r.uintField(gcController, "heapMarked", "HeapMarked")    // +rtype -field gcControllerState.heapMarked uint64
+	m.HeapGoal = r.uintField(gcController, "gcPercentHeapGoal", "HeapGoal") // +rtype -field gcControllerState.gcPercentHeapGoal anytype
+
+	memstats := r.global("memstats", "NumGC", "NumForcedGC", "GCPauseTotal")
+	m.NumGC = r.uintField(memstats, "numgc", "NumGC")                                       // +rtype -field mstats.numgc uint32
+	m.NumForcedGC = r.uintField(memstats, "numforcedgc", "NumForcedGC")                     // +rtype -field mstats.numforcedgc uint32
+	m.GCPauseTotal = time.Duration(r.uintField(memstats, "pause_total_ns", "GCPauseTotal")) // +rtype -field mstats.pause_total_ns uint64
+
+	m.GOMAXPROCS = int(r.globalUint("gomaxprocs", "GOMAXPROCS"))
+
+	gs, _, err := GoroutinesInfo(t, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range gs {
+		if !g.System(t) {
+			m.Goroutines++
+		}
+	}
+
+	if sched := r.global("sched", "SchedLatency"); sched != nil {
+		m.SchedLatency, err = loadSchedLatency(sched)
+		if err != nil {
+			r.unavailable = append(r.unavailable, "SchedLatency")
+		}
+	}
+
+	m.Unavailable = r.unavailable
+	return m, nil
+}
+
+// GCPhase is the phase of the garbage collector.
+type GCPhase uint8
+
+const (
+	GCOff             GCPhase = iota // not running, sweeping in background
+	GCMark                           // marking
+	GCMarkTermination                // mark termination, the world is stopped
+)
+
+func (phase GCPhase) String() string {
+	switch phase {
+	case GCOff:
+		return "off"
+	case GCMark:
+		return "mark"
+	case GCMarkTermination:
+		return "mark termination"
+	}
+	return fmt.Sprintf("unknown (%d)", uint8(phase))
+}
+
+// GCState is the state of the garbage collector of the target. Values that
+// the runtime of the target doesn't have are listed in Unavailable and left
+// to zero.
+type GCState struct {
+	Phase GCPhase
+
+	// HeapLive, HeapMarked and HeapGoal are as in RuntimeMetrics.
+	HeapLive   uint64
+	HeapMarked uint64
+	HeapGoal   uint64
+	// HeapTrigger is the value of HeapLive at which the next GC cycle will
+	// start. It is estimated the way the runtime computes it, without
+	// considering the memory limit and the trigger derived from the
+	// progress of sweeping.
+	HeapTrigger uint64
+
+	NumGC     uint64        // number of completed GC cycles
+	LastGC    time.Time     // end of the last GC cycle
+	LastPause time.Duration // duration of the stop-the-world pauses of the last GC cycle
+
+	Unavailable []string
+}
+
+// GCState returns the state of the garbage collector of the target.
+func (t *Target) GCState() (*GCState, error) {
+	// +rtype -var gcphase uint32
+	// +rtype -var gcController gcControllerState
+	// +rtype -var memstats mstats
+
+	r := newRuntimeVarReader(t)
+	s := &GCState{}
+
+	s.Phase = GCPhase(r.globalUint("gcphase", "Phase"))
+
+	gcController := r.global("gcController", "HeapLive", "HeapMarked", "HeapGoal", "HeapTrigger")
+	s.HeapLive = r.uintField(gcController, "heapLive", "HeapLive")
+	s.HeapMarked = r.uintField(gcController, "heapMarked", "HeapMarked")
+	s.HeapGoal = r.uintField(gcController, "gcPercentHeapGoal", "HeapGoal")
+	if gcController != nil {
+		if runwayv := gcController.loadFieldNamed("runway"); /* +rtype -field gcControllerState.runway anytype */ runwayv != nil && s.HeapGoal != 0 {
+			if runway, ok := runtimeUint(runwayv); ok {
+				s.HeapTrigger = estimateGCTrigger(s.HeapGoal, s.HeapMarked, runway)
+			}
+		}
+		if s.HeapTrigger == 0 {
+			r.unavailable = append(r.unavailable, "HeapTrigger")
+		}
+	}
+
+	memstats := r.global("memstats", "NumGC", "LastGC", "LastPause")
+	s.NumGC = r.uintField(memstats, "numgc", "NumGC")
+	if lastGC := r.uintField(memstats, "last_gc_unix", "LastGC"); lastGC != 0 { // +rtype -field mstats.last_gc_unix uint64
+		s.LastGC = time.Unix(0, int64(lastGC))
+	}
+	if memstats != nil {
+		// The pauses of the last 256 cycles are stored in a circular buffer,
+		// see runtime.readmemstats_m.
+		pauses, err := memstats.structMember("pause_ns") // +rtype -field mstats.pause_ns [256]uint64
+		if err == nil && s.NumGC > 0 {
+			if typ, ok := resolveTypedef(pauses.RealType).(*godwarf.ArrayType); ok && typ.Count > 0 {
+				i := (s.NumGC - 1) % uint64(typ.Count)
+				var pause uint64
+				pause, err = readUintRaw(pauses.mem, pauses.Addr+i*8, 8)
+				s.LastPause = time.Duration(pause)
+			}
+		}
+		if err != nil {
+			r.unavailable = append(r.unavailable, "LastPause")
+		}
+	}
+
+	s.Unavailable = r.unavailable
+	return s, nil
+}
+
+// estimateGCTrigger returns the value of heapLive at which the next GC cycle
+// will start, computed like runtime.(*gcControllerState).trigger does
+// without considering the memory limit and the minimum trigger derived from
+// the progress of sweeping.
+func estimateGCTrigger(goal, heapMarked, runway uint64) uint64 {
+	const (
+		triggerRatioDen    = 64      // +rtype triggerRatioDen
+		minTriggerRatioNum = 45      // +rtype minTriggerRatioNum
+		maxTriggerRatioNum = 61      // +rtype maxTriggerRatioNum
+		defaultHeapMinimum = 4 << 20 // +rtype defaultHeapMinimum
+	)
+	if heapMarked >= goal {
+		return goal
+	}
+	minTrigger := ((goal-heapMarked)/triggerRatioDen)*minTriggerRatioNum + heapMarked
+	maxTrigger := ((goal-heapMarked)/triggerRatioDen)*maxTriggerRatioNum + heapMarked
+	if goal > defaultHeapMinimum && goal-defaultHeapMinimum > maxTrigger {
+		maxTrigger = goal - defaultHeapMinimum
+	}
+	if maxTrigger < minTrigger {
+		maxTrigger = minTrigger
+	}
+	trigger := minTrigger
+	if runway <= goal {
+		trigger = goal - runway
+	}
+	if trigger < minTrigger {
+		trigger = minTrigger
+	}
+	if trigger > maxTrigger {
+		trigger = maxTrigger
+	}
+	return trigger
+}
+
+// runtimeVarReader reads package variables of the runtime, keeping track of
+// the statistics that could not be read.
+type runtimeVarReader struct {
+	scope       *EvalScope
+	unavailable []string
+}
+
+func newRuntimeVarReader(t *Target) *runtimeVarReader {
+	return &runtimeVarReader{scope: globalScope(t, t.BinInfo(), t.BinInfo().Images[0], t.Memory())}
+}
+
+// global returns the package variable runtime.name, if it can not be read
+// the statistics read from it are marked as unavailable and nil is
+// returned.
+func (r *runtimeVarReader) global(name string, stats ...string) *Variable {
+	v, err := r.scope.findGlobal("runtime", name)
+	if err != nil || v.Unreadable != nil {
+		r.unavailable = append(r.unavailable, stats...)
+		return nil
+	}
+	return v
+}
+
+// globalUint returns the value of the integer package variable runtime.name,
+// which is the statistic stat.
+func (r *runtimeVarReader) globalUint(name, stat string) uint64 {
+	v, err := r.scope.findGlobal("runtime", name)
+	if err == nil && v.Unreadable == nil {
+		v.loadValue(loadFullValue)
+		if n, ok := runtimeUint(v); ok {
+			return n
+		}
+	}
+	r.unavailable = append(r.unavailable, stat)
+	return 0
+}
+
+// uintField returns the value of the integer field name of v, which is the
+// statistic stat. If v is nil the statistic was already marked as
+// unavailable by global.
+func (r *runtimeVarReader) uintField(v *Variable, name, stat string) uint64 {
+	if v == nil {
+		return 0
+	}
+	if fv := v.loadFieldNamed(name); fv != nil {
+		if n, ok := runtimeUint(fv); ok {
+			return n
+		}
+	}
+	r.unavailable = append(r.unavailable, stat)
+	return 0
+}
+
+// runtimeUint returns the value of v, a loaded integer variable which can
+// also be one of the types of runtime/internal/atomic.
+func runtimeUint(v *Variable) (uint64, bool) {
+	if v.Unreadable != nil {
+		return 0, false
+	}
+	if v.Kind == reflect.Struct {
+		v = v.fieldVariable("value")
+		if v == nil {
+			return 0, false
+		}
+	}
+	switch v.Kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := constant.Int64Val(v.Value)
+		return uint64(n), ok
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return constant.Uint64Val(v.Value)
+	}
+	return 0, false
+}
+
+// loadSchedLatency reads the histogram sched.timeToRun. See
+// runtime.timeHistogram and runtime.timeHistogramMetricsBuckets for a
+// description of its buckets, only the layout used since Go 1.20 is
+// supported.
+func loadSchedLatency(sched *Variable) ([]HistogramBucket, error) {
+	timeToRun, err := sched.structMember("timeToRun") // +rtype timeHistogram
+	if err != nil {
+		return nil, err
+	}
+	counts, err := timeToRun.structMember("counts") // +rtype -field timeHistogram.counts anytype
+	if err != nil {
+		return nil, err
+	}
+	typ, ok := resolveTypedef(counts.RealType).(*godwarf.ArrayType)
+	const numSubBuckets = 1 << timeHistSubBucketBits
+	if !ok || typ.Count != timeHistNumBuckets*numSubBuckets || typ.Type.Size() != 8 {
+		return nil, errors.New("unsupported layout of runtime.timeHistogram")
+	}
+	buf := make([]byte, typ.Count*8)
+	if _, err := counts.mem.ReadMemory(buf, counts.Addr); err != nil {
+		return nil, err
+	}
+
+	// lowerBound returns the lower bound of the i-th bucket.
+	lowerBound := func(i int) time.Duration {
+		bucket, subBucket := i/numSubBuckets, i%numSubBuckets
+		if bucket == 0 {
+			return time.Duration(subBucket) << (timeHistMinBucketBits - 1 - timeHistSubBucketBits)
+		}
+		bucketBit := uint(bucket + timeHistMinBucketBits - 1)
+		return time.Duration(1)<<(bucketBit-1) | time.Duration(subBucket)<<(bucketBit-1-timeHistSubBucketBits)
+	}
+
+	r := []HistogramBucket{}
+	for i := 0; i < int(typ.Count); i++ {
+		n := binary.LittleEndian.Uint64(buf[i*8:])
+		if n == 0 {
+			continue
+		}
+		r = append(r, HistogramBucket{Min: lowerBound(i), Max: lowerBound(i + 1), Count: n})
+	}
+	return r, nil
+}
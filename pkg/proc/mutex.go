@@ -0,0 +1,180 @@
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Constants describing the state of sync.Mutex, see $GOROOT/src/sync/mutex.go
+// (or $GOROOT/src/internal/sync/mutex.go since Go 1.24).
+const (
+	mutexLocked       = 1 << 0
+	mutexStarving     = 1 << 2
+	rwmutexMaxReaders = 1 << 30
+)
+
+// MutexInfo describes the state of a sync.Mutex or sync.RWMutex.
+type MutexInfo struct {
+	Addr uint64
+	RW   bool // the mutex is a sync.RWMutex
+
+	// Locked is true if the mutex is locked, for a sync.RWMutex it is true if
+	// a writer holds the lock or is waiting for the readers to release it.
+	Locked   bool
+	Starving bool // the mutex is in starvation mode
+	// Readers is the number of goroutines holding a read lock on a
+	// sync.RWMutex.
+	Readers int64
+
+	// Waiters are the goroutines waiting to lock the mutex, for a
+	// sync.RWMutex the goroutines waiting to lock it for writing.
+	Waiters []*G
+	// ReadWaiters are the goroutines waiting to lock a sync.RWMutex for
+	// reading.
+	ReadWaiters []*G
+	// Holders are the goroutines that possibly hold the lock: goroutines
+	// that aren't waiting on the mutex and whose local variables contain a
+	// pointer to it (or to the heap object containing it). Only set if the
+	// mutex is locked.
+	Holders []*G
+}
+
+// MutexInfo returns the state of v, which must be a sync.Mutex, a
+// sync.RWMutex or a pointer to one of them.
+// The runtime does not record which goroutine holds a mutex, the holders
+// are found by searching the stacks of all goroutines for pointers to it,
+// the result is therefore only an approximation. System goroutines are
+// ignored.
+func (t *Target) MutexInfo(v *Variable) (*MutexInfo, error) {
+	if v.Unreadable != nil {
+		return nil, v.Unreadable
+	}
+	if v.Kind == reflect.Ptr {
+		v = v.maybeDereference()
+		if v.Unreadable != nil {
+			return nil, v.Unreadable
+		}
+		if v.Addr == 0 {
+			return nil, errors.New("nil pointer")
+		}
+	}
+	r := &MutexInfo{Addr: v.Addr}
+	var m *Variable
+	switch v.RealType.Common().Name {
+	case "sync.Mutex":
+		m = v
+	case "sync.RWMutex":
+		r.RW = true
+		var err error
+		m, err = v.structMember("w")
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%s is not a sync.Mutex or a sync.RWMutex", v.TypeString())
+	}
+	// Since Go 1.24 sync.Mutex wraps internal/sync.Mutex.
+	if mu, err := m.structMember("mu"); err == nil {
+		m = mu
+	}
+
+	field := func(v *Variable, name string) (int64, error) {
+		fv := v.loadFieldNamed(name)
+		if fv != nil && fv.Kind == reflect.Struct {
+			// sync/atomic types, used since Go 1.20
+			fv = fv.loadFieldNamed("v")
+		}
+		if fv == nil {
+			return 0, fmt.Errorf("could not read field %s of %s", name, v.TypeString())
+		}
+		n, _ := runtimeUint(fv)
+		return int64(int32(n)), nil
+	}
+	state, err := field(m, "state")
+	if err != nil {
+		return nil, err
+	}
+	r.Locked = state&mutexLocked != 0
+	r.Starving = state&mutexStarving != 0
+
+	semas := map[uint64]*[]*G{}
+	addSema := func(v *Variable, name string, dst *[]*G) error {
+		sv, err := v.structMember(name)
+		if err != nil {
+			return err
+		}
+		semas[sv.Addr] = dst
+		return nil
+	}
+	if err := addSema(m, "sema", &r.Waiters); err != nil {
+		return nil, err
+	}
+	if r.RW {
+		readerCount, err := field(v, "readerCount")
+		if err != nil {
+			return nil, err
+		}
+		if readerCount < 0 {
+			// a writer is waiting for the readers to release the lock
+			readerCount += rwmutexMaxReaders
+		}
+		r.Readers = readerCount
+		if err := addSema(v, "writerSem", &r.Waiters); err != nil {
+			return nil, err
+		}
+		if err := addSema(v, "readerSem", &r.ReadWaiters); err != nil {
+			return nil, err
+		}
+	}
+
+	gs, _, err := GoroutinesInfo(t, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	userGs := []*G{}
+	for _, g := range gs {
+		if !g.System(t) {
+			userGs = append(userGs, g)
+		}
+	}
+	waits, err := goroutineWaitObjects(t, userGs)
+	if err != nil {
+		return nil, err
+	}
+	waiting := map[int]bool{}
+	for _, g := range userGs {
+		if g.variable == nil {
+			continue
+		}
+		for _, obj := range waits[g.variable.Addr] {
+			if dst := semas[obj.Addr]; obj.Kind == WaitSema && dst != nil {
+				*dst = append(*dst, g)
+				waiting[g.ID] = true
+				break
+			}
+		}
+	}
+
+	if !r.Locked && r.Readers <= 0 {
+		return r, nil
+	}
+	spans, err := loadHeapSpans(t.BinInfo(), t.Memory())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].base < spans[j].base })
+	lo, hi := r.Addr, r.Addr+uint64(v.RealType.Size())
+	if s := findHeapSpan(spans, r.Addr); s != nil {
+		lo = s.base + s.objectIndex(r.Addr)*s.elemsize
+		hi = lo + s.elemsize
+	}
+	pw := newPointerWalker(t.BinInfo(), false)
+	for _, g := range userGs {
+		if !waiting[g.ID] && containsPointerInto(stackPointers(t, g, pw), lo, hi) {
+			r.Holders = append(r.Holders, g)
+		}
+	}
+	return r, nil
+}
@@ -1,5 +1,5 @@
-//go:build (freebsd && amd64) || darwin
-// +build freebsd,amd64 darwin
+//go:build (freebsd && amd64) || (openbsd && amd64) || darwin
+// +build freebsd,amd64 openbsd,amd64 darwin
 
 package native
 
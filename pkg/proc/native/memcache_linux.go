@@ -0,0 +1,122 @@
+package native
+
+import (
+	sys "golang.org/x/sys/unix"
+)
+
+const (
+	cachePageSize = 0x1000
+
+	// maxCachedReadPages is the maximum number of pages a read can span to
+	// go through the cache, bigger reads (for example the ones done by the
+	// examinemem command or while dumping the process) are rarely repeated
+	// and would only evict useful pages.
+	maxCachedReadPages = 16
+
+	// maxCachedPages is the maximum number of pages kept by the cache.
+	maxCachedPages = 2048
+)
+
+// pageCache caches pages of the memory of the target process while it is
+// stopped.
+// Loading variables results in many small reads of close addresses
+// (struct fields, slice elements, the words of a string header...), which
+// would otherwise need at least one system call each. Instead all the
+// pages touched by a read that aren't already cached are read with a single
+// process_vm_readv call, adjacent pages being coalesced into one remote
+// iovec, and subsequent reads of the same pages are served from memory.
+//
+// The cache must be invalidated every time a thread of the target process
+// is resumed and whenever the memory of the target process is written.
+type pageCache struct {
+	pages map[uint64][]byte
+}
+
+// invalidate discards all cached pages.
+func (c *pageCache) invalidate() {
+	c.pages = nil
+}
+
+// invalidateRange discards the cached pages overlapping the size bytes
+// starting at addr.
+func (c *pageCache) invalidateRange(addr uint64, size int) {
+	if len(c.pages) == 0 || size <= 0 {
+		return
+	}
+	first := addr &^ (cachePageSize - 1)
+	last := (addr + uint64(size) - 1) &^ (cachePageSize - 1)
+	if last < first || (last-first)/cachePageSize >= maxCachedPages {
+		c.invalidate()
+		return
+	}
+	for page := first; ; page += cachePageSize {
+		delete(c.pages, page)
+		if page == last {
+			break
+		}
+	}
+}
+
+// read reads len(data) bytes at addr from the memory of thread tid, using
+// the cached pages when possible. It returns false if the read can not be
+// satisfied by the cache, either because it is too big or because some of
+// the pages it spans could not be read, the caller should then read the
+// memory directly.
+func (c *pageCache) read(tid int, data []byte, addr uint64) bool {
+	if len(data) == 0 || addr+uint64(len(data)) < addr {
+		return false
+	}
+	first := addr &^ (cachePageSize - 1)
+	last := (addr + uint64(len(data)) - 1) &^ (cachePageSize - 1)
+	npages := int((last-first)/cachePageSize) + 1
+	if npages > maxCachedReadPages {
+		return false
+	}
+
+	pages := make([][]byte, 0, npages)
+	var (
+		missing []uint64
+		local   []sys.Iovec
+		remote  []remoteIovec
+	)
+	for page := first; ; page += cachePageSize {
+		buf, ok := c.pages[page]
+		if !ok {
+			buf = make([]byte, cachePageSize)
+			missing = append(missing, page)
+			iov := sys.Iovec{Base: &buf[0]}
+			iov.SetLen(cachePageSize)
+			local = append(local, iov)
+			if n := len(remote); n > 0 && remote[n-1].base+remote[n-1].len == uintptr(page) {
+				remote[n-1].len += cachePageSize
+			} else {
+				remote = append(remote, remoteIovec{base: uintptr(page), len: cachePageSize})
+			}
+		}
+		pages = append(pages, buf)
+		if page == last {
+			break
+		}
+	}
+
+	if len(missing) > 0 {
+		n, err := processVmReadv(tid, local, remote)
+		if err != nil || n != len(missing)*cachePageSize {
+			return false
+		}
+		if c.pages == nil || len(c.pages)+len(missing) > maxCachedPages {
+			c.pages = make(map[uint64][]byte)
+		}
+		for _, page := range missing {
+			c.pages[page] = pages[(page-first)/cachePageSize]
+		}
+	}
+
+	off := int(addr - first)
+	for _, buf := range pages {
+		n := copy(data, buf[off:])
+		data = data[n:]
+		off = 0
+	}
+	return true
+}
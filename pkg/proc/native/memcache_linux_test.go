@@ -0,0 +1,100 @@
+package native
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"unsafe"
+)
+
+// The tests in this file use the memory of the test process itself as the
+// memory of the target.
+
+func TestPageCache(t *testing.T) {
+	pid := os.Getpid()
+	mem := make([]byte, 4*cachePageSize)
+	for i := range mem {
+		mem[i] = byte(i)
+	}
+	addr := uint64(uintptr(unsafe.Pointer(&mem[0])))
+
+	var c pageCache
+	read := func(off, size int) []byte {
+		t.Helper()
+		buf := make([]byte, size)
+		if !c.read(pid, buf, addr+uint64(off)) {
+			t.Fatalf("read of %d bytes at %#x not served by the cache", size, addr+uint64(off))
+		}
+		return buf
+	}
+
+	// read spanning two pages
+	off := cachePageSize - 4
+	if buf := read(off, 8); !bytes.Equal(buf, mem[off:off+8]) {
+		t.Fatalf("wrong data %x, expected %x", buf, mem[off:off+8])
+	}
+
+	// the cache is not updated when memory changes
+	mem[off] = ^mem[off]
+	if buf := read(off, 8); buf[0] == mem[off] {
+		t.Fatalf("read was not served from the cached page")
+	}
+
+	// until the range is invalidated
+	c.invalidateRange(addr+uint64(off), 1)
+	if buf := read(off, 8); !bytes.Equal(buf, mem[off:off+8]) {
+		t.Fatalf("wrong data after invalidateRange %x, expected %x", buf, mem[off:off+8])
+	}
+
+	// or the whole cache is
+	mem[off+4] = ^mem[off+4]
+	c.invalidate()
+	if len(c.pages) != 0 {
+		t.Fatalf("pages left in the cache after invalidate: %d", len(c.pages))
+	}
+	if buf := read(off, 8); !bytes.Equal(buf, mem[off:off+8]) {
+		t.Fatalf("wrong data after invalidate %x, expected %x", buf, mem[off:off+8])
+	}
+
+	// big reads are not cached
+	big := make([]byte, (maxCachedReadPages+1)*cachePageSize)
+	if c.read(pid, big, addr) {
+		t.Fatalf("read of %d bytes served by the cache", len(big))
+	}
+
+	// neither are reads of unmapped memory
+	if c.read(pid, make([]byte, 8), 0) {
+		t.Fatalf("read of unmapped memory succeeded")
+	}
+}
+
+// BenchmarkMemoryRead measures the small reads, close to each other, done
+// while loading variables.
+func BenchmarkMemoryRead(b *testing.B) {
+	pid := os.Getpid()
+	mem := make([]byte, 2*cachePageSize)
+	addr := uint64(uintptr(unsafe.Pointer(&mem[0])))
+	buf := make([]byte, 8)
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for off := uint64(0); off < uint64(len(mem)); off += 64 {
+				if _, err := processVmRead(pid, uintptr(addr+off), buf); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			// the cache is invalidated every time the target is resumed
+			var c pageCache
+			for off := uint64(0); off < uint64(len(mem)); off += 64 {
+				if !c.read(pid, buf, addr+off) {
+					b.Fatal("read failed")
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,16 @@
+//go:build !linux || !amd64 || !go1.16 || !cgo
+// +build !linux !amd64 !go1.16 !cgo
+
+package native
+
+import (
+	"errors"
+
+	"github.com/go-delve/delve/pkg/proc"
+)
+
+// TraceUProbes returns a tracer that sets eBPF uprobes on the process with
+// the given PID without attaching to it.
+func TraceUProbes(pid int, debugInfoDirs []string) (*proc.UProbeTracer, error) {
+	return nil, errors.New("eBPF is not supported")
+}
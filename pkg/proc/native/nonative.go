@@ -1,5 +1,5 @@
-//go:build darwin && !macnative
-// +build darwin,!macnative
+//go:build (darwin && !macnative) || (openbsd && !cgo)
+// +build darwin,!macnative openbsd,!cgo
 
 package native
 
@@ -16,7 +16,7 @@ import (
 var ErrNativeBackendDisabled = errors.New("native backend disabled during compilation")
 
 // Launch returns ErrNativeBackendDisabled.
-func Launch(_ []string, _ string, _ proc.LaunchFlags, _ []string, _ string, _ [3]string) (*proc.Target, error) {
+func Launch(_ []string, _ string, _ []string, _ proc.LaunchFlags, _ []string, _ string, _ [3]string) (*proc.Target, error) {
 	return nil, ErrNativeBackendDisabled
 }
 
@@ -28,11 +28,11 @@ func Attach(_ int, _ []string) (*proc.Target, error) {
 // waitStatus is a synonym for the platform-specific WaitStatus
 type waitStatus struct{}
 
-// osSpecificDetails holds information specific to the OSX/Darwin
-// operating system / kernel.
+// osSpecificDetails holds information specific to the operating system /
+// kernel.
 type osSpecificDetails struct{}
 
-// osProcessDetails holds Darwin specific information.
+// osProcessDetails holds OS specific information.
 type osProcessDetails struct{}
 
 func (os *osProcessDetails) Close() {}
@@ -24,11 +24,33 @@ type nativeProcess struct {
 	// Thread used to read and write memory
 	memthread *nativeThread
 
-	os             *osProcessDetails
-	firstStart     bool
-	ptraceChan     chan func()
-	ptraceDoneChan chan interface{}
-	childProcess   bool // this process was launched, not attached to
+	os           *osProcessDetails
+	firstStart   bool
+	ptraceThread *ptraceThread
+	childProcess bool // this process was launched, not attached to
+
+	// followExec is true if the children of this process that fork, or
+	// execute a program, accepted by followExecMatch should be attached, see
+	// FollowExec.
+	followExec      bool
+	followExecMatch func(path string) bool
+	// newChildren contains the pids of the children that forked, or executed
+	// a program, accepted by followExecMatch and are waiting to be attached by
+	// NewChildren.
+	newChildren   []int
+	debugInfoDirs []string
+
+	// execed is true if the process executed a new program, which can be
+	// debugged with the target returned by ExecTarget.
+	execed bool
+	// fromExec is true if this process was created by ExecTarget, the
+	// program it executes can lack debug info.
+	fromExec bool
+
+	// rootDir is the directory where the root file system of the process is
+	// visible, if it is different from the root of the debugger (for example
+	// because the process runs in a container).
+	rootDir string
 
 	// Controlling terminal file descriptor for
 	// this process.
@@ -44,18 +66,23 @@ type nativeProcess struct {
 // functions. For more information, see the documentation on
 // `handlePtraceFuncs`.
 func newProcess(pid int) *nativeProcess {
-	dbp := &nativeProcess{
-		pid:            pid,
-		threads:        make(map[int]*nativeThread),
-		breakpoints:    proc.NewBreakpointMap(),
-		firstStart:     true,
-		os:             new(osProcessDetails),
-		ptraceChan:     make(chan func()),
-		ptraceDoneChan: make(chan interface{}),
-		bi:             proc.NewBinaryInfo(runtime.GOOS, runtime.GOARCH),
+	return newChildProcess(newPtraceThread(), pid)
+}
+
+// newChildProcess returns an initialized Process struct that uses
+// ptraceThread to call ptrace(2), this is needed for the children of
+// traced processes which are automatically traced by the same thread as
+// their parent.
+func newChildProcess(ptraceThread *ptraceThread, pid int) *nativeProcess {
+	return &nativeProcess{
+		pid:          pid,
+		threads:      make(map[int]*nativeThread),
+		breakpoints:  proc.NewBreakpointMap(),
+		firstStart:   true,
+		os:           new(osProcessDetails),
+		ptraceThread: ptraceThread.acquire(),
+		bi:           proc.NewBinaryInfo(runtime.GOOS, runtime.GOARCH),
 	}
-	go dbp.handlePtraceFuncs()
-	return dbp
 }
 
 // BinInfo will return the binary info struct associated with this process.
@@ -204,6 +231,12 @@ func (dbp *nativeProcess) ContinueOnce(cctx *proc.ContinueOnceContext) (proc.Thr
 		}
 		if trapthread != nil {
 			dbp.memthread = trapthread
+			if dbp.execed {
+				return trapthread, proc.StopExec, nil
+			}
+			if len(dbp.newChildren) > 0 {
+				return trapthread, proc.StopNewChild, nil
+			}
 			return trapthread, proc.StopUnknown, nil
 		}
 	}
@@ -227,6 +260,7 @@ func (dbp *nativeProcess) FindBreakpoint(pc uint64, adjustPC bool) (*proc.Breakp
 // initialize will ensure that all relevant information is loaded
 // so the process is ready to be debugged.
 func (dbp *nativeProcess) initialize(path string, debugInfoDirs []string) (*proc.Target, error) {
+	dbp.debugInfoDirs = debugInfoDirs
 	if err := initialize(dbp); err != nil {
 		return nil, err
 	}
@@ -246,15 +280,20 @@ func (dbp *nativeProcess) initialize(path string, debugInfoDirs []string) (*proc
 		//    https://github.com/golang/go/issues/36494
 		//  - freebsd's backend is generally broken and asyncpreempt makes it even more so, see:
 		//    https://github.com/go-delve/delve/issues/1754
+		//  - on openbsd the signals used by asyncpreempt stop the whole
+		//    process, like on freebsd
 		//  - on linux/arm64 asyncpreempt can sometimes restart a sequence of
 		//    instructions, if the sequence happens to contain a breakpoint it will
 		//    look like the breakpoint was hit twice when it was "logically" only
 		//    executed once.
 		//    See: https://go-review.googlesource.com/c/go/+/208126
-		DisableAsyncPreempt: runtime.GOOS == "windows" || runtime.GOOS == "freebsd" || (runtime.GOOS == "linux" && runtime.GOARCH == "arm64"),
+		DisableAsyncPreempt: runtime.GOOS == "windows" || runtime.GOOS == "freebsd" || runtime.GOOS == "openbsd" || (runtime.GOOS == "linux" && runtime.GOARCH == "arm64"),
 
 		StopReason: stopReason,
 		CanDump:    runtime.GOOS == "linux" || runtime.GOOS == "windows",
+		RootDir:    dbp.rootDir,
+
+		AllowNoDebugInfo: dbp.fromExec,
 	})
 	if err != nil {
 		return nil, err
@@ -265,7 +304,37 @@ func (dbp *nativeProcess) initialize(path string, debugInfoDirs []string) (*proc
 	return tgt, nil
 }
 
-func (dbp *nativeProcess) handlePtraceFuncs() {
+// ptraceThread is the goroutine, locked to its OS thread, that calls
+// ptrace(2) for one or more processes.
+type ptraceThread struct {
+	ptraceRefCnt   int
+	ptraceChan     chan func()
+	ptraceDoneChan chan interface{}
+}
+
+func newPtraceThread() *ptraceThread {
+	pt := &ptraceThread{
+		ptraceChan:     make(chan func()),
+		ptraceDoneChan: make(chan interface{}),
+	}
+	go pt.handlePtraceFuncs()
+	return pt
+}
+
+func (pt *ptraceThread) acquire() *ptraceThread {
+	pt.ptraceRefCnt++
+	return pt
+}
+
+func (pt *ptraceThread) release() {
+	pt.ptraceRefCnt--
+	if pt.ptraceRefCnt == 0 {
+		close(pt.ptraceChan)
+		close(pt.ptraceDoneChan)
+	}
+}
+
+func (pt *ptraceThread) handlePtraceFuncs() {
 	// We must ensure here that we are running on the same thread during
 	// while invoking the ptrace(2) syscall. This is due to the fact that ptrace(2) expects
 	// all commands after PTRACE_ATTACH to come from the same thread.
@@ -278,21 +347,20 @@ func (dbp *nativeProcess) handlePtraceFuncs() {
 		defer runtime.UnlockOSThread()
 	}
 
-	for fn := range dbp.ptraceChan {
+	for fn := range pt.ptraceChan {
 		fn()
-		dbp.ptraceDoneChan <- nil
+		pt.ptraceDoneChan <- nil
 	}
 }
 
 func (dbp *nativeProcess) execPtraceFunc(fn func()) {
-	dbp.ptraceChan <- fn
-	<-dbp.ptraceDoneChan
+	dbp.ptraceThread.ptraceChan <- fn
+	<-dbp.ptraceThread.ptraceDoneChan
 }
 
 func (dbp *nativeProcess) postExit() {
 	dbp.exited = true
-	close(dbp.ptraceChan)
-	close(dbp.ptraceDoneChan)
+	dbp.ptraceThread.release()
 	dbp.bi.Close()
 	if dbp.ctty != nil {
 		dbp.ctty.Close()
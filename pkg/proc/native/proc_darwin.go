@@ -42,7 +42,10 @@ func (os *osProcessDetails) Close() {}
 // custom fork/exec process in order to take advantage of
 // PT_SIGEXC on Darwin which will turn Unix signals into
 // Mach exceptions.
-func Launch(cmd []string, wd string, flags proc.LaunchFlags, _ []string, _ string, _ [3]string) (*proc.Target, error) {
+func Launch(cmd []string, wd string, env []string, flags proc.LaunchFlags, _ []string, _ string, _ [3]string) (*proc.Target, error) {
+	if env != nil {
+		return nil, errors.New("changing the environment of the target is not supported by this backend")
+	}
 	argv0Go, err := filepath.Abs(cmd[0])
 	if err != nil {
 		return nil, err
@@ -3,6 +3,32 @@
 
 package native
 
+import (
+	"fmt"
+	"io/ioutil"
+	"runtime"
+
+	"github.com/go-delve/delve/pkg/proc"
+	"github.com/go-delve/delve/pkg/proc/linutil"
+)
+
 func (dbp *nativeProcess) SupportsBPF() bool {
 	return true
 }
+
+// TraceUProbes returns a tracer that sets eBPF uprobes on the process with
+// the given PID without attaching to it. If the DWARF information cannot be
+// found in the binary, Delve will look for external debug files in the
+// directories passed in.
+func TraceUProbes(pid int, debugInfoDirs []string) (*proc.UProbeTracer, error) {
+	auxvbuf, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/auxv", pid))
+	if err != nil {
+		return nil, fmt.Errorf("could not read auxiliary vector: %v", err)
+	}
+	bi := proc.NewBinaryInfo("linux", runtime.GOARCH)
+	entryPoint := linutil.EntryPointFromAuxv(auxvbuf, bi.Arch.PtrSize())
+	if err := bi.LoadBinaryInfo(findExecutable("", pid), entryPoint, debugInfoDirs); err != nil {
+		return nil, err
+	}
+	return proc.NewUProbeTracer(pid, bi)
+}
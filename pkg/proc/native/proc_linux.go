@@ -3,7 +3,6 @@ package native
 import (
 	"bufio"
 	"bytes"
-	"debug/elf"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -49,6 +48,30 @@ type osProcessDetails struct {
 	comm string
 
 	ebpf *ebpf.EBPFContext
+
+	// forked contains the pids of the children of the process that are
+	// traced because followExec is set but did not execute a program yet.
+	forked map[int]bool
+	// vforked contains the pids in forked that share the address space of
+	// the process (vfork), while one of them runs it can change the memory
+	// of the process and memcache is not used.
+	vforked map[int]bool
+	// earlyStops contains the pids of tasks that stopped before we were
+	// notified of their creation.
+	earlyStops map[int]bool
+
+	// seized is true if the process was attached with PTRACE_SEIZE, its
+	// threads are then stopped with PTRACE_INTERRUPT instead of SIGSTOP.
+	seized bool
+	// stoppedAtAttach is true if the process was in group-stop (for example
+	// it had received SIGSTOP) when we attached to it.
+	stoppedAtAttach bool
+
+	// nonStop is true if non-stop mode is enabled, only the threads that
+	// receive a signal are stopped, see proc.(*Target).SetNonStop.
+	nonStop bool
+
+	memcache pageCache
 }
 
 func (os *osProcessDetails) Close() {
@@ -62,7 +85,7 @@ func (os *osProcessDetails) Close() {
 // to be supplied to that process. `wd` is working directory of the program.
 // If the DWARF information cannot be found in the binary, Delve will look
 // for external debug files in the directories passed in.
-func Launch(cmd []string, wd string, flags proc.LaunchFlags, debugInfoDirs []string, tty string, redirects [3]string) (*proc.Target, error) {
+func Launch(cmd []string, wd string, env []string, flags proc.LaunchFlags, debugInfoDirs []string, tty string, redirects [3]string) (*proc.Target, error) {
 	var (
 		process *exec.Cmd
 		err     error
@@ -99,6 +122,7 @@ func Launch(cmd []string, wd string, flags proc.LaunchFlags, debugInfoDirs []str
 
 		process = exec.Command(cmd[0])
 		process.Args = cmd
+		process.Env = env
 		process.Stdin = stdin
 		process.Stdout = stdout
 		process.Stderr = stderr
@@ -144,8 +168,19 @@ func Launch(cmd []string, wd string, flags proc.LaunchFlags, debugInfoDirs []str
 func Attach(pid int, debugInfoDirs []string) (*proc.Target, error) {
 	dbp := newProcess(pid)
 
+	// PTRACE_SEIZE is used instead of PTRACE_ATTACH so that no SIGSTOP is
+	// sent to the process: it could be observed by the process (or by its
+	// parent) and it would interfere with job control.
+	dbp.os.seized = true
+	dbp.os.stoppedAtAttach = groupStopped(pid)
+
 	var err error
-	dbp.execPtraceFunc(func() { err = ptraceAttach(dbp.pid) })
+	dbp.execPtraceFunc(func() {
+		err = ptraceSeize(dbp.pid, dbp.ptraceOptions())
+		if err == nil {
+			err = ptraceInterrupt(dbp.pid)
+		}
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -194,6 +229,16 @@ func initialize(dbp *nativeProcess) error {
 	}
 	dbp.os.comm = strings.ReplaceAll(string(comm), "%", "%%")
 
+	// If the process has a different root directory than ours (for example
+	// it is running in a container) its files must be accessed through
+	// /proc/<pid>/root.
+	root := fmt.Sprintf("/proc/%d/root", dbp.pid)
+	if fi, err := os.Stat(root); err == nil {
+		if ourfi, err := os.Stat("/"); err == nil && !os.SameFile(fi, ourfi) {
+			dbp.rootDir = root
+		}
+	}
+
 	return nil
 }
 
@@ -212,7 +257,12 @@ func (dbp *nativeProcess) kill() error {
 	if !dbp.threads[dbp.pid].Stopped() {
 		return errors.New("process must be stopped in order to kill it")
 	}
-	if err := sys.Kill(-dbp.pid, sys.SIGKILL); err != nil {
+	pid := dbp.pid
+	if pgid, _ := sys.Getpgid(dbp.pid); pgid == dbp.pid {
+		// kill the whole process group if we launched it.
+		pid = -dbp.pid
+	}
+	if err := sys.Kill(pid, sys.SIGKILL); err != nil {
 		return errors.New("could not deliver signal " + err.Error())
 	}
 	// wait for other threads first or the thread group leader (dbp.pid) will never exit.
@@ -246,7 +296,16 @@ func (dbp *nativeProcess) addThread(tid int, attach bool) (*nativeThread, error)
 
 	var err error
 	if attach {
-		dbp.execPtraceFunc(func() { err = sys.PtraceAttach(tid) })
+		if dbp.os.seized {
+			dbp.execPtraceFunc(func() {
+				err = ptraceSeize(tid, dbp.ptraceOptions())
+				if err == nil {
+					err = ptraceInterrupt(tid)
+				}
+			})
+		} else {
+			dbp.execPtraceFunc(func() { err = sys.PtraceAttach(tid) })
+		}
 		if err != nil && err != sys.EPERM {
 			// Do not return err if err == EPERM,
 			// we may already be tracing this thread due to
@@ -263,12 +322,12 @@ func (dbp *nativeProcess) addThread(tid int, attach bool) (*nativeThread, error)
 		}
 	}
 
-	dbp.execPtraceFunc(func() { err = syscall.PtraceSetOptions(tid, syscall.PTRACE_O_TRACECLONE) })
+	dbp.execPtraceFunc(func() { err = syscall.PtraceSetOptions(tid, dbp.ptraceOptions()) })
 	if err == syscall.ESRCH {
 		if _, _, err = dbp.waitFast(tid); err != nil {
 			return nil, fmt.Errorf("error while waiting after adding thread: %d %s", tid, err)
 		}
-		dbp.execPtraceFunc(func() { err = syscall.PtraceSetOptions(tid, syscall.PTRACE_O_TRACECLONE) })
+		dbp.execPtraceFunc(func() { err = syscall.PtraceSetOptions(tid, dbp.ptraceOptions()) })
 		if err == syscall.ESRCH {
 			return nil, err
 		}
@@ -296,6 +355,230 @@ func (dbp *nativeProcess) addThread(tid int, attach bool) (*nativeThread, error)
 	return dbp.threads[tid], nil
 }
 
+// ptraceOptions returns the ptrace options for the threads of the process.
+func (dbp *nativeProcess) ptraceOptions() int {
+	options := syscall.PTRACE_O_TRACECLONE | syscall.PTRACE_O_TRACEEXEC
+	if dbp.followExec {
+		options |= syscall.PTRACE_O_TRACEFORK | syscall.PTRACE_O_TRACEVFORK
+	}
+	return options
+}
+
+// FollowExec enables or disables following the children of the process
+// whose program is accepted by match, see proc.(*Target).FollowExec.
+func (dbp *nativeProcess) FollowExec(follow bool, match func(path string) bool) error {
+	if dbp.exited {
+		return proc.ErrProcessExited{Pid: dbp.pid}
+	}
+	dbp.followExec = follow
+	dbp.followExecMatch = match
+	for _, th := range dbp.threads {
+		var err error
+		dbp.execPtraceFunc(func() { err = syscall.PtraceSetOptions(th.ID, dbp.ptraceOptions()) })
+		if err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("could not set options for thread %d: %v", th.ID, err)
+		}
+	}
+	return nil
+}
+
+// ExecTarget returns a target for the program executed by the process, see
+// proc.(*Target).ExecTarget. If the program can not be loaded the process
+// is detached.
+func (dbp *nativeProcess) ExecTarget() (*proc.Target, error) {
+	if !dbp.execed {
+		return nil, errors.New("the process did not execute a new program")
+	}
+	p := newChildProcess(dbp.ptraceThread, dbp.pid)
+	p.fromExec = true
+	p.childProcess = dbp.childProcess
+	p.followExec = dbp.followExec
+	p.followExecMatch = dbp.followExecMatch
+	p.newChildren = dbp.newChildren
+	p.ctty = dbp.ctty
+	p.os.forked = dbp.os.forked
+	p.os.vforked = dbp.os.vforked
+	p.os.earlyStops = dbp.os.earlyStops
+	p.os.seized = dbp.os.seized
+	p.os.stoppedAtAttach = dbp.os.stoppedAtAttach
+
+	// the old process object must not be used anymore
+	dbp.ctty = nil
+	dbp.detached = true
+	dbp.postExit()
+
+	path, _ := os.Readlink(fmt.Sprintf("/proc/%d/exe", p.pid))
+	tgt, err := p.initialize(findExecutable(path, p.pid), dbp.debugInfoDirs)
+	if err != nil {
+		p.execPtraceFunc(func() { _ = ptraceDetach(p.pid, 0) })
+		p.detached = true
+		p.postExit()
+		return nil, fmt.Errorf("could not load the program executed by process %d, detached: %v", p.pid, err)
+	}
+	return tgt, nil
+}
+
+// SetNonStop enables or disables non-stop mode, see
+// proc.(*Target).SetNonStop.
+func (dbp *nativeProcess) SetNonStop(enabled bool) error {
+	if dbp.exited {
+		return proc.ErrProcessExited{Pid: dbp.pid}
+	}
+	dbp.os.nonStop = enabled
+	if enabled {
+		return nil
+	}
+	running := []*nativeThread{}
+	for _, th := range dbp.threads {
+		if th.os.running {
+			running = append(running, th)
+		}
+	}
+	if err := dbp.stopRunning(); err != nil {
+		return err
+	}
+	for _, th := range running {
+		if !th.os.setbp {
+			continue
+		}
+		// The thread hit a breakpoint while we were stopping it, rewind it so
+		// that the breakpoint is hit again, and reported, when it is resumed.
+		if err := th.SetCurrentBreakpoint(true); err != nil {
+			return err
+		}
+		th.CurrentBreakpoint.Clear()
+		th.os.setbp = false
+	}
+	return nil
+}
+
+// ThreadRunning returns true if thread was left running by the last stop,
+// which only happens in non-stop mode.
+func (dbp *nativeProcess) ThreadRunning(thread proc.Thread) bool {
+	th, ok := thread.(*nativeThread)
+	return ok && th.os.running
+}
+
+// NewChildren returns a target for each child of the process that forked,
+// or executed a program, accepted by followExecMatch since the last call.
+func (dbp *nativeProcess) NewChildren() ([]*proc.Target, error) {
+	var tgts []*proc.Target
+	for len(dbp.newChildren) > 0 {
+		pid := dbp.newChildren[0]
+		dbp.newChildren = dbp.newChildren[1:]
+
+		child := newChildProcess(dbp.ptraceThread, pid)
+		child.childProcess = true
+		child.followExec = dbp.followExec
+		child.followExecMatch = dbp.followExecMatch
+		path, _ := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+		tgt, err := child.initialize(findExecutable(path, pid), dbp.debugInfoDirs)
+		if err != nil {
+			_ = child.Detach(true)
+			return tgts, fmt.Errorf("could not attach to child process %d: %v", pid, err)
+		}
+		tgts = append(tgts, tgt)
+	}
+	return tgts, nil
+}
+
+// addForkedChild handles pid, a new child of the process. If the child
+// does not share the address space of the process (fork, not vfork) it is
+// a copy of the process and it is added to dbp.newChildren when its
+// program is accepted by followExecMatch, in which case addForkedChild
+// returns true. Otherwise the child is tracked until it executes a
+// program, see forkedChildEvent.
+func (dbp *nativeProcess) addForkedChild(pid int, fork bool) (bool, error) {
+	if dbp.os.earlyStops[pid] {
+		delete(dbp.os.earlyStops, pid)
+	} else if _, _, err := dbp.waitFast(pid); err != nil {
+		// wait for the SIGSTOP children receive when they are automatically
+		// attached.
+		return false, err
+	}
+	var err error
+	if fork {
+		// The child has a copy of the memory of the process, including our
+		// breakpoints, which must be removed before it runs.
+		for _, bp := range dbp.Breakpoints().M {
+			if bp.WatchType != 0 || len(bp.OriginalData) == 0 {
+				continue
+			}
+			dbp.execPtraceFunc(func() { _, err = sys.PtracePokeData(pid, uintptr(bp.Addr), bp.OriginalData) })
+			if err != nil {
+				if err == sys.ESRCH {
+					return false, nil
+				}
+				return false, fmt.Errorf("could not clear breakpoint at %#x in child process %d: %v", bp.Addr, pid, err)
+			}
+		}
+		path, _ := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+		if dbp.followExecMatch == nil || dbp.followExecMatch(path) {
+			dbp.newChildren = append(dbp.newChildren, pid)
+			return true, nil
+		}
+	}
+	dbp.execPtraceFunc(func() { err = syscall.PtraceSetOptions(pid, syscall.PTRACE_O_TRACEEXEC) })
+	if err == nil {
+		dbp.execPtraceFunc(func() { err = ptraceCont(pid, 0) })
+	}
+	if err != nil {
+		if err == sys.ESRCH {
+			return false, nil
+		}
+		return false, fmt.Errorf("could not continue child process %d: %v", pid, err)
+	}
+	if dbp.os.forked == nil {
+		dbp.os.forked = make(map[int]bool)
+	}
+	dbp.os.forked[pid] = true
+	if !fork {
+		if dbp.os.vforked == nil {
+			dbp.os.vforked = make(map[int]bool)
+		}
+		dbp.os.vforked[pid] = true
+		dbp.os.memcache.invalidate()
+	}
+	return false, nil
+}
+
+// forkedChildEvent handles the wait status of one of the children in
+// dbp.os.forked, it returns true if the child executed a program accepted by
+// followExecMatch and was added to dbp.newChildren.
+func (dbp *nativeProcess) forkedChildEvent(pid int, status *sys.WaitStatus) (bool, error) {
+	if status.Exited() || status.Signaled() {
+		dbp.forgetForkedChild(pid)
+		return false, nil
+	}
+	var err error
+	if status.StopSignal() == sys.SIGTRAP && status.TrapCause() == sys.PTRACE_EVENT_EXEC {
+		dbp.forgetForkedChild(pid)
+		path, _ := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+		if dbp.followExecMatch == nil || dbp.followExecMatch(path) {
+			dbp.newChildren = append(dbp.newChildren, pid)
+			return true, nil
+		}
+		dbp.execPtraceFunc(func() { err = ptraceDetach(pid, 0) })
+	} else {
+		dbp.execPtraceFunc(func() { err = ptraceCont(pid, int(status.StopSignal())) })
+	}
+	if err != nil && err != sys.ESRCH {
+		return false, fmt.Errorf("could not resume child process %d: %v", pid, err)
+	}
+	return false, nil
+}
+
+// forgetForkedChild removes pid from dbp.os.forked after it exited or
+// executed a program.
+func (dbp *nativeProcess) forgetForkedChild(pid int) {
+	delete(dbp.os.forked, pid)
+	if dbp.os.vforked[pid] {
+		// the child could have changed the memory of the process
+		delete(dbp.os.vforked, pid)
+		dbp.os.memcache.invalidate()
+	}
+}
+
 func (dbp *nativeProcess) updateThreadList() error {
 	tids, _ := filepath.Glob(fmt.Sprintf("/proc/%d/task/*", dbp.pid))
 	for _, tidpath := range tids {
@@ -351,6 +634,24 @@ func (dbp *nativeProcess) trapWaitInternal(pid int, options trapWaitOptions) (*n
 		if ok {
 			th.Status = (*waitStatus)(status)
 		}
+		if dbp.os.forked[wpid] {
+			attach, err := dbp.forkedChildEvent(wpid, status)
+			if err != nil {
+				return nil, err
+			}
+			if attach && options&(trapWaitHalt|trapWaitNohang) == 0 {
+				// Return one of our threads, still running, so that the caller
+				// stops the process. Since it did not receive a SIGTRAP it will
+				// not be checked for breakpoints, see stop.
+				if th := dbp.threads[dbp.pid]; th != nil {
+					return th, nil
+				}
+				for _, th := range dbp.threads {
+					return th, nil
+				}
+			}
+			continue
+		}
 		if status.Exited() {
 			if wpid == dbp.pid {
 				dbp.postExit()
@@ -381,6 +682,7 @@ func (dbp *nativeProcess) trapWaitInternal(pid int, options trapWaitOptions) (*n
 				}
 				return nil, fmt.Errorf("could not get event message: %s", err)
 			}
+			delete(dbp.os.earlyStops, int(cloned))
 			th, err = dbp.addThread(int(cloned), false)
 			if err != nil {
 				if err == sys.ESRCH {
@@ -410,8 +712,89 @@ func (dbp *nativeProcess) trapWaitInternal(pid int, options trapWaitOptions) (*n
 			}
 			continue
 		}
+		if status.StopSignal() == sys.SIGTRAP && status.TrapCause() == sys.PTRACE_EVENT_EXEC {
+			// The process executed a new program, all its threads except the one
+			// that called exec are gone and the thread calling exec now has the
+			// pid of the process.
+			for tid := range dbp.threads {
+				if tid != dbp.pid {
+					delete(dbp.threads, tid)
+				}
+			}
+			th = dbp.threads[dbp.pid]
+			if th == nil {
+				// the main thread had exited before the exec
+				th, err = dbp.addThread(dbp.pid, false)
+				if err != nil {
+					return nil, err
+				}
+			}
+			th.Status = (*waitStatus)(status)
+			th.os.running = false
+			th.os.setbp = false
+			dbp.execed = true
+			return th, nil
+		}
+		if status.StopSignal() == sys.SIGTRAP && (status.TrapCause() == sys.PTRACE_EVENT_FORK || status.TrapCause() == sys.PTRACE_EVENT_VFORK) {
+			// A traced thread has forked, the child is automatically traced and
+			// it is either attached right away or followed until it executes a
+			// program, see addForkedChild.
+			var child uint
+			attach := false
+			dbp.execPtraceFunc(func() { child, err = sys.PtraceGetEventMsg(wpid) })
+			if err == nil {
+				attach, err = dbp.addForkedChild(int(child), status.TrapCause() == sys.PTRACE_EVENT_FORK)
+			}
+			if err != nil && err != sys.ESRCH {
+				return nil, fmt.Errorf("could not add child process: %v", err)
+			}
+			if th == nil {
+				continue
+			}
+			if halt {
+				th.os.running = false
+				return nil, nil
+			}
+			if err := th.Continue(); err != nil && err != sys.ESRCH {
+				return nil, fmt.Errorf("could not continue existing thread %d %s", wpid, err)
+			}
+			if attach && options&trapWaitNohang == 0 {
+				// th is still running, see forkedChildEvent.
+				return th, nil
+			}
+			continue
+		}
 		if th == nil {
 			// Sometimes we get an unknown thread, ignore it?
+			// When following children it could also be a new child that stopped
+			// before we received the fork event of its parent.
+			if dbp.followExec && status.Stopped() {
+				if dbp.os.earlyStops == nil {
+					dbp.os.earlyStops = make(map[int]bool)
+				}
+				dbp.os.earlyStops[wpid] = true
+			}
+			continue
+		}
+		if isPtraceEventStop(status) {
+			if halt {
+				// the thread was stopped by PTRACE_INTERRUPT, possibly while in
+				// group-stop.
+				th.os.running = false
+				return th, nil
+			}
+			if status.StopSignal() != sys.SIGTRAP {
+				// The thread entered group-stop, leave it stopped until it
+				// receives SIGCONT, as it would happen if it wasn't traced.
+				if err := dbp.listen(th); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			// the thread left group-stop
+			if err := th.resumeWithSig(0); err != nil && err != sys.ESRCH {
+				return nil, err
+			}
 			continue
 		}
 		if (halt && status.StopSignal() == sys.SIGSTOP) || (status.StopSignal() == sys.SIGTRAP) {
@@ -446,6 +829,34 @@ func (dbp *nativeProcess) trapWaitInternal(pid int, options trapWaitOptions) (*n
 	}
 }
 
+// groupStopped returns true if the process is in group-stop. It can be
+// called before the process is initialized.
+func groupStopped(pid int) bool {
+	buf, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// The state is the field following the name of the task, which is in
+	// parenthesis and can contain spaces and parenthesis, see status.
+	i := bytes.LastIndexByte(buf, ')')
+	if i < 0 || i+2 >= len(buf) {
+		return false
+	}
+	return buf[i+2] == statusTraceStopT
+}
+
+// listen lets th, which is in group-stop, remain stopped while still
+// allowing it to be resumed by SIGCONT.
+func (dbp *nativeProcess) listen(th *nativeThread) error {
+	var err error
+	dbp.execPtraceFunc(func() { err = ptraceListen(th.ID) })
+	if err != nil && err != sys.ESRCH {
+		return fmt.Errorf("could not listen on thread %d: %v", th.ID, err)
+	}
+	th.os.running = true
+	return nil
+}
+
 func status(pid int, comm string) rune {
 	f, err := os.Open(fmt.Sprintf("/proc/%d/stat", pid))
 	if err != nil {
@@ -530,6 +941,10 @@ func (dbp *nativeProcess) resume() error {
 	}
 	// everything is resumed
 	for _, thread := range dbp.threads {
+		if thread.os.running {
+			// left running by a stop in non-stop mode
+			continue
+		}
 		if err := thread.resume(); err != nil && err != sys.ESRCH {
 			return err
 		}
@@ -546,7 +961,9 @@ func (dbp *nativeProcess) stop(cctx *proc.ContinueOnceContext, trapthread *nativ
 	for _, th := range dbp.threads {
 		th.os.setbp = false
 	}
-	trapthread.os.setbp = true
+	// trapthread is still running if we are stopping because a child
+	// executed a program, see trapWaitInternal.
+	trapthread.os.setbp = !trapthread.os.running
 
 	// check if any other thread simultaneously received a SIGTRAP
 	for {
@@ -559,31 +976,21 @@ func (dbp *nativeProcess) stop(cctx *proc.ContinueOnceContext, trapthread *nativ
 		}
 	}
 
-	// stop all threads that are still running
-	for _, th := range dbp.threads {
-		if th.os.running {
-			if err := th.stop(); err != nil {
-				return nil, dbp.exitGuard(err)
-			}
+	// In non-stop mode the threads that did not receive a signal are left
+	// running, unless the user asked to stop the process.
+	if !dbp.os.nonStop || trapthread.os.running || cctx.GetManualStopRequested() {
+		if err := dbp.stopRunning(); err != nil {
+			return nil, err
 		}
 	}
+	if dbp.memthread.os.running {
+		dbp.memthread = trapthread
+	}
 
-	// wait for all threads to stop
-	for {
-		allstopped := true
-		for _, th := range dbp.threads {
-			if th.os.running {
-				allstopped = false
-				break
-			}
-		}
-		if allstopped {
-			break
-		}
-		_, err := dbp.trapWaitInternal(-1, trapWaitHalt)
-		if err != nil {
-			return nil, err
-		}
+	if dbp.execed {
+		// The debug info and the breakpoints are those of the old program,
+		// see ExecTarget.
+		return dbp.threads[dbp.pid], nil
 	}
 
 	if err := linutil.ElfUpdateSharedObjects(dbp); err != nil {
@@ -595,6 +1002,9 @@ func (dbp *nativeProcess) stop(cctx *proc.ContinueOnceContext, trapthread *nativ
 	// set breakpoints on SIGTRAP threads
 	var err1 error
 	for _, th := range dbp.threads {
+		if th.os.running {
+			continue
+		}
 		pc, _ := th.PC()
 
 		if !th.os.setbp && pc != th.os.phantomBreakpointPC {
@@ -672,6 +1082,35 @@ func (dbp *nativeProcess) stop(cctx *proc.ContinueOnceContext, trapthread *nativ
 	return trapthread, nil
 }
 
+// stopRunning stops all threads that are still running.
+func (dbp *nativeProcess) stopRunning() error {
+	for _, th := range dbp.threads {
+		if th.os.running {
+			if err := th.stop(); err != nil {
+				return dbp.exitGuard(err)
+			}
+		}
+	}
+
+	// wait for all threads to stop
+	for {
+		allstopped := true
+		for _, th := range dbp.threads {
+			if th.os.running {
+				allstopped = false
+				break
+			}
+		}
+		if allstopped {
+			return nil
+		}
+		_, err := dbp.trapWaitInternal(-1, trapWaitHalt)
+		if err != nil {
+			return err
+		}
+	}
+}
+
 func (dbp *nativeProcess) detach(kill bool) error {
 	for threadID := range dbp.threads {
 		err := ptraceDetach(threadID, 0)
@@ -682,6 +1121,14 @@ func (dbp *nativeProcess) detach(kill bool) error {
 	if kill {
 		return nil
 	}
+	if dbp.os.seized {
+		// We never sent SIGSTOP to a seized process, it keeps running after the
+		// detach unless it was stopped when we attached to it.
+		if dbp.os.stoppedAtAttach && status(dbp.pid, dbp.os.comm) != statusTraceStopT {
+			_ = sys.Kill(dbp.pid, sys.SIGSTOP)
+		}
+		return nil
+	}
 	// For some reason the process will sometimes enter stopped state after a
 	// detach, this doesn't happen immediately either.
 	// We have to wait a bit here, then check if the main thread is stopped and
@@ -714,74 +1161,12 @@ func (dbp *nativeProcess) SetUProbe(fnName string, goidOffset int64, args []ebpf
 		}
 	}
 
-	// We only allow up to 12 args for a BPF probe.
-	// 6 inputs + 6 outputs.
-	// Return early if we have more.
-	if len(args) > 12 {
-		return errors.New("too many arguments in traced function, max is 12 input+return")
-	}
-
 	fn, ok := dbp.bi.LookupFunc[fnName]
 	if !ok {
 		return fmt.Errorf("could not find function: %s", fnName)
 	}
 
-	key := fn.Entry
-	err := dbp.os.ebpf.UpdateArgMap(key, goidOffset, args, dbp.BinInfo().GStructOffset(), false)
-	if err != nil {
-		return err
-	}
-
-	debugname := dbp.bi.Images[0].Path
-
-	// First attach a uprobe at all return addresses. We do this instead of using a uretprobe
-	// for two reasons:
-	// 1. uretprobes do not play well with Go
-	// 2. uretprobes seem to not restore the function return addr on the stack when removed, destroying any
-	//    kind of workaround we could come up with.
-	// TODO(derekparker): this whole thing could likely be optimized a bit.
-	img := dbp.BinInfo().PCToImage(fn.Entry)
-	f, err := elf.Open(img.Path)
-	if err != nil {
-		return fmt.Errorf("could not open elf file to resolve symbol offset: %w", err)
-	}
-
-	var regs proc.Registers
-	mem := dbp.Memory()
-	regs, _ = dbp.memthread.Registers()
-	instructions, err := proc.Disassemble(mem, regs, &proc.BreakpointMap{}, dbp.BinInfo(), fn.Entry, fn.End)
-	if err != nil {
-		return err
-	}
-
-	var addrs []uint64
-	for _, instruction := range instructions {
-		if instruction.IsRet() {
-			addrs = append(addrs, instruction.Loc.PC)
-		}
-	}
-	addrs = append(addrs, proc.FindDeferReturnCalls(instructions)...)
-	for _, addr := range addrs {
-		err := dbp.os.ebpf.UpdateArgMap(addr, goidOffset, args, dbp.BinInfo().GStructOffset(), true)
-		if err != nil {
-			return err
-		}
-		off, err := ebpf.AddressToOffset(f, addr)
-		if err != nil {
-			return err
-		}
-		err = dbp.os.ebpf.AttachUprobe(dbp.pid, debugname, off)
-		if err != nil {
-			return err
-		}
-	}
-
-	off, err := ebpf.AddressToOffset(f, fn.Entry)
-	if err != nil {
-		return err
-	}
-
-	return dbp.os.ebpf.AttachUprobe(dbp.pid, debugname, off)
+	return proc.AttachUProbes(dbp.os.ebpf, dbp.pid, dbp.bi, dbp.Memory(), fn, goidOffset, args)
 }
 
 func killProcess(pid int) error {
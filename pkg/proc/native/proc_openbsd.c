@@ -0,0 +1,116 @@
+#include <sys/param.h>
+#include <sys/types.h>
+#include <sys/ptrace.h>
+#include <sys/sysctl.h>
+#include <sys/exec_elf.h>
+
+#include <errno.h>
+#include <limits.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include "proc_openbsd.h"
+
+static int get_kinfo_proc(int pid, struct kinfo_proc *kp) {
+	int mib[6] = { CTL_KERN, KERN_PROC, KERN_PROC_PID, pid, sizeof(*kp), 1 };
+	size_t len = sizeof(*kp);
+
+	if (sysctl(mib, 6, kp, &len, NULL, 0) == -1)
+		return (-1);
+	if (len == 0) {
+		errno = ESRCH;
+		return (-1);
+	}
+	return (0);
+}
+
+/*
+ * Returns the pathname of the process's executable, computed from its
+ * first argument and current working directory since OpenBSD does not
+ * record it. Must be freed by the caller. Sets errno on failure.
+ */
+char * find_executable(int pid) {
+	int mib[4] = { CTL_KERN, KERN_PROC_ARGS, pid, KERN_PROC_ARGV };
+	int cwdmib[3] = { CTL_KERN, KERN_PROC_CWD, pid };
+	char cwd[PATH_MAX];
+	char **argv;
+	char *pathname;
+	size_t len = 0;
+
+	if (sysctl(mib, 4, NULL, &len, NULL, 0) == -1)
+		return (NULL);
+	argv = malloc(len);
+	if (argv == NULL)
+		return (NULL);
+	if (sysctl(mib, 4, argv, &len, NULL, 0) == -1 || argv[0] == NULL) {
+		free(argv);
+		return (NULL);
+	}
+	pathname = malloc(PATH_MAX);
+	if (pathname == NULL) {
+		free(argv);
+		return (NULL);
+	}
+	len = sizeof(cwd);
+	if (argv[0][0] != '/' && strchr(argv[0], '/') != NULL &&
+	    sysctl(cwdmib, 3, cwd, &len, NULL, 0) == 0)
+		snprintf(pathname, PATH_MAX, "%s/%s", cwd, argv[0]);
+	else
+		strlcpy(pathname, argv[0], PATH_MAX);
+	free(argv);
+	return (pathname);
+}
+
+/*
+ * Returns the comm value of the process, which is usually the basename of its
+ * executable. Must be freed by the caller.  Sets errno on failure.
+ */
+char * find_command_name(int pid) {
+	char *command_name = NULL;
+	struct kinfo_proc kp;
+
+	if (get_kinfo_proc(pid, &kp) == 0) {
+		command_name = malloc(KI_MAXCOMLEN);
+		if (command_name != NULL)
+			strlcpy(command_name, kp.p_comm, KI_MAXCOMLEN);
+	}
+
+	return (command_name);
+}
+
+int find_status(int pid){
+	struct kinfo_proc kp;
+
+	if (get_kinfo_proc(pid, &kp) == -1)
+		return ('?');
+	return (kp.p_stat);
+}
+
+/*
+ * Returns the entry point of the process, read from its auxiliary vector,
+ * or 0 and sets errno on failure.
+ */
+uintptr_t get_entry_point(int pid) {
+	Aux64Info auxv[64];
+	struct ptrace_io_desc piod;
+	size_t i;
+
+	piod.piod_op = PIOD_READ_AUXV;
+	piod.piod_offs = 0;
+	piod.piod_addr = auxv;
+	piod.piod_len = sizeof(auxv);
+	if (ptrace(PT_IO, (pid_t)pid, (caddr_t)&piod, 0) == -1)
+		return (0);
+
+	errno = EINVAL;
+	for (i = 0; i < piod.piod_len / sizeof(auxv[0]); i++) {
+		if (auxv[i].au_id == AUX_null)
+			break;
+		if (auxv[i].au_id == AUX_entry) {
+			errno = 0;
+			return ((uintptr_t)auxv[i].au_v);
+		}
+	}
+	return (0);
+}
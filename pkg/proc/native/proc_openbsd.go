@@ -0,0 +1,378 @@
+package native
+
+// #include <stdlib.h>
+// #include "proc_openbsd.h"
+import "C"
+import (
+	"fmt"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	sys "golang.org/x/sys/unix"
+
+	"github.com/go-delve/delve/pkg/proc"
+	"github.com/go-delve/delve/pkg/proc/internal/ebpf"
+
+	isatty "github.com/mattn/go-isatty"
+)
+
+// Process statuses
+const (
+	statusIdle     = 1
+	statusRunning  = 2
+	statusSleeping = 3
+	statusStopped  = 4
+	statusZombie   = 5
+	statusDead     = 6
+	statusOnProc   = 7
+)
+
+// osProcessDetails contains OpenBSD specific
+// process details.
+type osProcessDetails struct {
+	comm string
+}
+
+func (os *osProcessDetails) Close() {}
+
+// Launch creates and begins debugging a new process. First entry in
+// `cmd` is the program to run, and then rest are the arguments
+// to be supplied to that process. `wd` is working directory of the program.
+// If the DWARF information cannot be found in the binary, Delve will look
+// for external debug files in the directories passed in.
+func Launch(cmd []string, wd string, env []string, flags proc.LaunchFlags, debugInfoDirs []string, tty string, redirects [3]string) (*proc.Target, error) {
+	var (
+		process *exec.Cmd
+		err     error
+	)
+
+	foreground := flags&proc.LaunchForeground != 0
+
+	stdin, stdout, stderr, closefn, err := openRedirects(redirects, foreground)
+	if err != nil {
+		return nil, err
+	}
+
+	if stdin == nil || !isatty.IsTerminal(stdin.Fd()) {
+		// exec.(*Process).Start will fail if we try to send a process to
+		// foreground but we are not attached to a terminal.
+		foreground = false
+	}
+
+	dbp := newProcess(0)
+	defer func() {
+		if err != nil && dbp.pid != 0 {
+			_ = dbp.Detach(true)
+		}
+	}()
+	dbp.execPtraceFunc(func() {
+		process = exec.Command(cmd[0])
+		process.Args = cmd
+		process.Stdin = stdin
+		process.Stdout = stdout
+		process.Stderr = stderr
+		process.SysProcAttr = &syscall.SysProcAttr{Ptrace: true, Setpgid: true, Foreground: foreground}
+		process.Env = proc.DisableAsyncPreemptEnv(env)
+		if foreground {
+			signal.Ignore(syscall.SIGTTOU, syscall.SIGTTIN)
+		}
+		if tty != "" {
+			dbp.ctty, err = attachProcessToTTY(process, tty)
+			if err != nil {
+				return
+			}
+		}
+		if wd != "" {
+			process.Dir = wd
+		}
+		err = process.Start()
+	})
+	closefn()
+	if err != nil {
+		return nil, err
+	}
+	dbp.pid = process.Process.Pid
+	dbp.childProcess = true
+	_, _, err = dbp.wait(process.Process.Pid, 0)
+	if err != nil {
+		return nil, fmt.Errorf("waiting for target execve failed: %s", err)
+	}
+	tgt, err := dbp.initialize(cmd[0], debugInfoDirs)
+	if err != nil {
+		return nil, err
+	}
+	return tgt, nil
+}
+
+// Attach to an existing process with the given PID. Once attached, if
+// the DWARF information cannot be found in the binary, Delve will look
+// for external debug files in the directories passed in.
+func Attach(pid int, debugInfoDirs []string) (*proc.Target, error) {
+	dbp := newProcess(pid)
+
+	var err error
+	dbp.execPtraceFunc(func() { err = ptraceAttach(dbp.pid) })
+	if err != nil {
+		return nil, err
+	}
+	_, _, err = dbp.wait(dbp.pid, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tgt, err := dbp.initialize(findExecutable("", dbp.pid), debugInfoDirs)
+	if err != nil {
+		dbp.Detach(false)
+		return nil, err
+	}
+	return tgt, nil
+}
+
+func initialize(dbp *nativeProcess) error {
+	comm, _ := C.find_command_name(C.int(dbp.pid))
+	defer C.free(unsafe.Pointer(comm))
+	comm_str := C.GoString(comm)
+	dbp.os.comm = strings.Replace(string(comm_str), "%", "%%", -1)
+	return nil
+}
+
+// kill kills the target process.
+func (dbp *nativeProcess) kill() (err error) {
+	if dbp.exited {
+		return nil
+	}
+	dbp.execPtraceFunc(func() { err = ptraceKill(dbp.pid) })
+	if err != nil {
+		return err
+	}
+	if _, _, err = dbp.wait(dbp.pid, 0); err != nil {
+		return err
+	}
+	dbp.postExit()
+	return nil
+}
+
+// Used by RequestManualStop
+func (dbp *nativeProcess) requestManualStop() (err error) {
+	return sys.Kill(dbp.pid, sys.SIGTRAP)
+}
+
+// Store a thread in our list of known threads, OpenBSD stops and resumes
+// all threads of the process together so there is no need to attach to
+// it.
+func (dbp *nativeProcess) addThread(tid int, attach bool) (*nativeThread, error) {
+	if thread, ok := dbp.threads[tid]; ok {
+		return thread, nil
+	}
+
+	dbp.threads[tid] = &nativeThread{
+		ID:  tid,
+		dbp: dbp,
+		os:  new(osSpecificDetails),
+	}
+
+	if dbp.memthread == nil {
+		dbp.memthread = dbp.threads[tid]
+	}
+
+	return dbp.threads[tid], nil
+}
+
+// Used by initialize and trapWait. Threads are enumerated with
+// PT_GET_THREAD_FIRST/PT_GET_THREAD_NEXT every time the process stops,
+// because OpenBSD does not report the creation and exit of threads.
+func (dbp *nativeProcess) updateThreadList() error {
+	var tids []int
+	var err error
+	dbp.execPtraceFunc(func() { tids, err = ptraceGetThreadList(dbp.pid) })
+	if err != nil {
+		return err
+	}
+	alive := make(map[int]bool, len(tids))
+	for _, tid := range tids {
+		alive[tid] = true
+		if _, err := dbp.addThread(tid, false); err != nil {
+			return err
+		}
+	}
+	for tid := range dbp.threads {
+		if !alive[tid] {
+			delete(dbp.threads, tid)
+		}
+	}
+	if dbp.memthread != nil && !alive[dbp.memthread.ID] {
+		dbp.memthread = nil
+		for _, th := range dbp.threads {
+			dbp.memthread = th
+			break
+		}
+	}
+	return nil
+}
+
+// Used by Attach
+func findExecutable(path string, pid int) string {
+	if path == "" {
+		cstr := C.find_executable(C.int(pid))
+		defer C.free(unsafe.Pointer(cstr))
+		path = C.GoString(cstr)
+	}
+	return path
+}
+
+func (dbp *nativeProcess) trapWait(pid int) (*nativeThread, error) {
+	return dbp.trapWaitInternal(pid, false)
+}
+
+// Used by stop and trapWait
+func (dbp *nativeProcess) trapWaitInternal(pid int, halt bool) (*nativeThread, error) {
+	for {
+		wpid, status, err := dbp.wait(pid, 0)
+		if err != nil {
+			return nil, fmt.Errorf("wait err %s %d", err, pid)
+		}
+		if status.Killed() {
+			// "Killed" status may arrive as a result of a Process.Kill() of some other process in
+			// the system performed by the same tracer (e.g. in the previous test)
+			continue
+		}
+		if status.Exited() {
+			dbp.postExit()
+			return nil, proc.ErrProcessExited{Pid: wpid, Status: status.ExitStatus()}
+		}
+
+		if err := dbp.updateThreadList(); err != nil {
+			return nil, err
+		}
+		var tid int
+		dbp.execPtraceFunc(func() { tid, err = ptraceGetEventThread(dbp.pid) })
+		if err != nil {
+			return nil, fmt.Errorf("ptraceGetEventThread err %s %d", err, pid)
+		}
+		th, ok := dbp.threads[tid]
+		if ok {
+			th.Status = (*waitStatus)(status)
+		}
+
+		if th == nil {
+			if err := dbp.resumeWithSig(int(status.StopSignal())); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if (halt && status.StopSignal() == sys.SIGSTOP) || (status.StopSignal() == sys.SIGTRAP) {
+			return th, nil
+		}
+
+		// TODO(dp) alert user about unexpected signals here.
+		if err := th.resumeWithSig(int(status.StopSignal())); err != nil {
+			if err == sys.ESRCH {
+				return nil, proc.ErrProcessExited{Pid: dbp.pid}
+			}
+			return nil, err
+		}
+	}
+}
+
+// resumeWithSig resumes the process delivering sig to it.
+func (dbp *nativeProcess) resumeWithSig(sig int) (err error) {
+	dbp.execPtraceFunc(func() { err = ptraceCont(dbp.pid, sig) })
+	if err == sys.ESRCH {
+		return proc.ErrProcessExited{Pid: dbp.pid}
+	}
+	return err
+}
+
+// Helper function used here and in threads_openbsd.go
+// Return the status code
+func status(pid int) rune {
+	status := rune(C.find_status(C.int(pid)))
+	return status
+}
+
+// Used by stop and singleStep
+// waitFast is like wait but does not handle process-exit correctly
+func (dbp *nativeProcess) waitFast(pid int) (int, *sys.WaitStatus, error) {
+	var s sys.WaitStatus
+	wpid, err := sys.Wait4(pid, &s, 0, nil)
+	return wpid, &s, err
+}
+
+// Only used in this file
+func (dbp *nativeProcess) wait(pid, options int) (int, *sys.WaitStatus, error) {
+	var s sys.WaitStatus
+	wpid, err := sys.Wait4(pid, &s, options, nil)
+	return wpid, &s, err
+}
+
+// Used by ContinueOnce
+func (dbp *nativeProcess) resume() error {
+	// all threads stopped over a breakpoint are made to step over it
+	for _, thread := range dbp.threads {
+		if thread.CurrentBreakpoint.Breakpoint != nil {
+			if err := thread.StepInstruction(); err != nil {
+				return err
+			}
+			thread.CurrentBreakpoint.Clear()
+		}
+	}
+	// all threads are resumed
+	var err error
+	dbp.execPtraceFunc(func() { err = ptraceCont(dbp.pid, 0) })
+	return err
+}
+
+// Used by ContinueOnce
+// stop stops all running threads and sets breakpoints
+func (dbp *nativeProcess) stop(cctx *proc.ContinueOnceContext, trapthread *nativeThread) (*nativeThread, error) {
+	if dbp.exited {
+		return nil, proc.ErrProcessExited{Pid: dbp.pid}
+	}
+	// set breakpoints on all threads
+	for _, th := range dbp.threads {
+		if th.CurrentBreakpoint.Breakpoint == nil {
+			if err := th.SetCurrentBreakpoint(true); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return trapthread, nil
+}
+
+// Used by Detach
+func (dbp *nativeProcess) detach(kill bool) error {
+	return ptraceDetach(dbp.pid)
+}
+
+// Used by PostInitializationSetup
+// EntryPoint will return the process entry point address, useful for debugging PIEs.
+func (dbp *nativeProcess) EntryPoint() (uint64, error) {
+	var ep C.uintptr_t
+	var err error
+	dbp.execPtraceFunc(func() { ep, err = C.get_entry_point(C.int(dbp.pid)) })
+	if ep == 0 {
+		return 0, err
+	}
+	return uint64(ep), nil
+}
+
+func (dbp *nativeProcess) SupportsBPF() bool {
+	return false
+}
+
+func (dbp *nativeProcess) SetUProbe(fnName string, goidOffset int64, args []ebpf.UProbeArgMap) error {
+	panic("not implemented")
+}
+
+func (dbp *nativeProcess) GetBufferedTracepoints() []ebpf.RawUProbeParams {
+	panic("not implemented")
+}
+
+// Used by Detach
+func killProcess(pid int) error {
+	return sys.Kill(pid, sys.SIGINT)
+}
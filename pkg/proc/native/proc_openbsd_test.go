@@ -0,0 +1,100 @@
+//go:build cgo
+// +build cgo
+
+package native
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/go-delve/delve/pkg/proc"
+	protest "github.com/go-delve/delve/pkg/proc/test"
+)
+
+// The tests in this file exercise the basic operations of the OpenBSD
+// backend directly, the full test suite in pkg/proc also runs with it.
+
+func TestMain(m *testing.M) {
+	os.Exit(protest.RunTestsWithFixtures(m))
+}
+
+func setFunctionBreakpoint(t *testing.T, p *proc.Target, fname string) uint64 {
+	t.Helper()
+	addrs, err := proc.FindFunctionLocation(p, fname, 0)
+	if err != nil {
+		t.Fatalf("FindFunctionLocation(%s): %v", fname, err)
+	}
+	if _, err := p.SetBreakpoint(0, addrs[0], proc.UserBreakpoint, nil); err != nil {
+		t.Fatalf("SetBreakpoint(%#x): %v", addrs[0], err)
+	}
+	return addrs[0]
+}
+
+func assertPC(t *testing.T, p *proc.Target, addr uint64) {
+	t.Helper()
+	regs, err := p.CurrentThread().Registers()
+	if err != nil {
+		t.Fatalf("Registers(): %v", err)
+	}
+	if regs.PC() != addr {
+		t.Fatalf("stopped at %#x, expected %#x", regs.PC(), addr)
+	}
+}
+
+func TestLaunchContinue(t *testing.T) {
+	fixture := protest.BuildFixture("testprog", 0)
+	p, err := Launch([]string{fixture.Path}, ".", nil, 0, []string{}, "", [3]string{})
+	if err != nil {
+		t.Fatalf("Launch(): %v", err)
+	}
+	defer p.Detach(true)
+
+	addr := setFunctionBreakpoint(t, p, "main.helloworld")
+	if err := p.Continue(); err != nil {
+		t.Fatalf("Continue(): %v", err)
+	}
+	assertPC(t, p, addr)
+
+	if err := p.ClearBreakpoint(addr); err != nil {
+		t.Fatalf("ClearBreakpoint(): %v", err)
+	}
+	err = p.Continue()
+	if pe, ok := err.(proc.ErrProcessExited); !ok || pe.Status != 0 {
+		t.Fatalf("expected the process to exit with status 0, got: %v", err)
+	}
+}
+
+func TestAttachContinue(t *testing.T) {
+	fixture := protest.BuildFixture("loopprog", 0)
+	cmd := exec.Command(fixture.Path)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting fixture: %v", err)
+	}
+	defer cmd.Wait()
+
+	// wait for loopprog to enter main.loop
+	if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+		cmd.Process.Kill()
+		t.Fatalf("reading fixture output: %v", err)
+	}
+
+	p, err := Attach(cmd.Process.Pid, []string{})
+	if err != nil {
+		cmd.Process.Kill()
+		t.Fatalf("Attach(): %v", err)
+	}
+	defer p.Detach(true)
+
+	// main.loop calls fmt.Println every million iterations
+	addr := setFunctionBreakpoint(t, p, "fmt.Println")
+	if err := p.Continue(); err != nil {
+		t.Fatalf("Continue(): %v", err)
+	}
+	assertPC(t, p, addr)
+}
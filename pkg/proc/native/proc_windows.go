@@ -25,13 +25,13 @@ type osProcessDetails struct {
 func (os *osProcessDetails) Close() {}
 
 // Launch creates and begins debugging a new process.
-func Launch(cmd []string, wd string, flags proc.LaunchFlags, _ []string, _ string, redirects [3]string) (*proc.Target, error) {
+func Launch(cmd []string, wd string, env []string, flags proc.LaunchFlags, _ []string, _ string, redirects [3]string) (*proc.Target, error) {
 	argv0Go, err := filepath.Abs(cmd[0])
 	if err != nil {
 		return nil, err
 	}
 
-	env := proc.DisableAsyncPreemptEnv()
+	env = proc.DisableAsyncPreemptEnv(env)
 
 	stdin, stdout, stderr, closefn, err := openRedirects(redirects, true)
 	if err != nil {
@@ -2,13 +2,47 @@ package native
 
 import (
 	"syscall"
+	"unsafe"
 
 	sys "golang.org/x/sys/unix"
 )
 
-// ptraceAttach executes the sys.PtraceAttach call.
-func ptraceAttach(pid int) error {
-	return sys.PtraceAttach(pid)
+// ptraceSeize executes ptrace PTRACE_SEIZE, unlike PTRACE_ATTACH it does
+// not stop the thread.
+func ptraceSeize(tid, options int) error {
+	_, _, err := sys.Syscall6(sys.SYS_PTRACE, sys.PTRACE_SEIZE, uintptr(tid), 0, uintptr(options), 0, 0)
+	if err != syscall.Errno(0) {
+		return err
+	}
+	return nil
+}
+
+// ptraceInterrupt executes ptrace PTRACE_INTERRUPT, the thread, which must
+// have been attached with PTRACE_SEIZE, will stop with PTRACE_EVENT_STOP.
+func ptraceInterrupt(tid int) error {
+	_, _, err := sys.Syscall6(sys.SYS_PTRACE, sys.PTRACE_INTERRUPT, uintptr(tid), 0, 0, 0, 0)
+	if err != syscall.Errno(0) {
+		return err
+	}
+	return nil
+}
+
+// ptraceListen executes ptrace PTRACE_LISTEN, the thread remains in
+// group-stop but it will be reported again when it leaves it.
+func ptraceListen(tid int) error {
+	_, _, err := sys.Syscall6(sys.SYS_PTRACE, sys.PTRACE_LISTEN, uintptr(tid), 0, 0, 0, 0)
+	if err != syscall.Errno(0) {
+		return err
+	}
+	return nil
+}
+
+// isPtraceEventStop returns true if status is a PTRACE_EVENT_STOP, which is
+// only reported for threads attached with PTRACE_SEIZE. The stop signal is
+// SIGTRAP if the stop was caused by PTRACE_INTERRUPT (or is the initial
+// stop of a new thread), otherwise the thread entered group-stop.
+func isPtraceEventStop(status *sys.WaitStatus) bool {
+	return status.Stopped() && uint32(*status)>>16 == sys.PTRACE_EVENT_STOP
 }
 
 // ptraceDetach calls ptrace(PTRACE_DETACH).
@@ -41,3 +75,14 @@ type remoteIovec struct {
 	base uintptr
 	len  uintptr
 }
+
+// processVmReadv calls process_vm_readv with multiple local and remote
+// iovecs, the data read from the remote iovecs is scattered, in order,
+// into the local ones.
+func processVmReadv(tid int, local []sys.Iovec, remote []remoteIovec) (int, error) {
+	n, _, err := syscall.Syscall6(sys.SYS_PROCESS_VM_READV, uintptr(tid), uintptr(unsafe.Pointer(&local[0])), uintptr(len(local)), uintptr(unsafe.Pointer(&remote[0])), uintptr(len(remote)), 0)
+	if err != syscall.Errno(0) {
+		return 0, err
+	}
+	return int(n), nil
+}
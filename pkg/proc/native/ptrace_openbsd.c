@@ -0,0 +1,73 @@
+#include <sys/types.h>
+#include <sys/ptrace.h>
+
+#include <errno.h>
+#include <stdint.h>
+
+#include "ptrace_openbsd.h"
+
+/* Resumes the traced process, delivering sig. id may be a PID or a TID. */
+int ptrace_cont(int id, int sig) {
+	return (ptrace(PT_CONTINUE, (pid_t)id, (caddr_t)1, sig));
+}
+
+/* Single steps the thread id. */
+int ptrace_single_step(int id) {
+	return (ptrace(PT_STEP, (pid_t)id, (caddr_t)1, 0));
+}
+
+int ptrace_detach(int pid) {
+	return (ptrace(PT_DETACH, (pid_t)pid, (caddr_t)1, 0));
+}
+
+/*
+ * Transfers len bytes between buf and the address space of pid, op is one
+ * of the PIOD_* constants. Returns the number of bytes transferred, or -1
+ * and sets errno on failure.
+ */
+int ptrace_io(int pid, int op, uintptr_t addr, void *buf, size_t len) {
+	struct ptrace_io_desc piod;
+
+	piod.piod_op = op;
+	piod.piod_offs = (void *)addr;
+	piod.piod_addr = buf;
+	piod.piod_len = len;
+	if (ptrace(PT_IO, (pid_t)pid, (caddr_t)&piod, 0) == -1)
+		return (-1);
+	return ((int)piod.piod_len);
+}
+
+/*
+ * Fetches the thread IDs of pid into tids, using PT_GET_THREAD_FIRST and
+ * PT_GET_THREAD_NEXT. Returns the number of threads of the process, which
+ * can be greater than len, or -1 and sets errno on failure.
+ */
+int ptrace_get_thread_list(int pid, int *tids, size_t len) {
+	struct ptrace_thread_state pts;
+	int n = 0;
+
+	errno = 0;
+	if (ptrace(PT_GET_THREAD_FIRST, (pid_t)pid, (caddr_t)&pts, sizeof(pts)) == -1)
+		return (-1);
+	while (pts.pts_tid != -1) {
+		if ((size_t)n < len)
+			tids[n] = pts.pts_tid;
+		n++;
+		if (ptrace(PT_GET_THREAD_NEXT, (pid_t)pid, (caddr_t)&pts, sizeof(pts)) == -1)
+			return (-1);
+	}
+	return (n);
+}
+
+/*
+ * Returns the TID of the thread that caused pid to stop, or -1 and sets
+ * errno on failure.
+ */
+int ptrace_get_event_thread(int pid) {
+	struct ptrace_state pe;
+
+	errno = 0;
+	if (ptrace(PT_GET_PROCESS_STATE, (pid_t)pid, (caddr_t)&pe, sizeof(pe)) == -1)
+		return (-1);
+	return (pe.pe_tid);
+}
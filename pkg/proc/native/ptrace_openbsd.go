@@ -0,0 +1,139 @@
+package native
+
+//#include <sys/types.h>
+//#include <sys/ptrace.h>
+//
+// #include <stdlib.h>
+// #include "ptrace_openbsd.h"
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/go-delve/delve/pkg/proc/amd64util"
+	"github.com/go-delve/delve/pkg/proc/obsdutil"
+)
+
+// ptraceAttach executes ptrace PT_ATTACH.
+// pid must be a PID, not a TID
+func ptraceAttach(pid int) error {
+	if ret, err := C.ptrace(C.PT_ATTACH, C.pid_t(pid), nil, 0); ret == -1 {
+		return err
+	}
+	return nil
+}
+
+// ptraceDetach executes ptrace PT_DETACH.
+func ptraceDetach(pid int) error {
+	if ret, err := C.ptrace_detach(C.int(pid)); ret == -1 {
+		return err
+	}
+	return nil
+}
+
+// ptraceKill executes ptrace PT_KILL.
+func ptraceKill(pid int) error {
+	if ret, err := C.ptrace(C.PT_KILL, C.pid_t(pid), nil, 0); ret == -1 {
+		return err
+	}
+	return nil
+}
+
+// ptraceCont executes ptrace PT_CONTINUE, resuming all threads of the
+// process.
+// id may be a PID or a TID
+func ptraceCont(id, sig int) error {
+	if ret, err := C.ptrace_cont(C.int(id), C.int(sig)); ret == -1 {
+		return err
+	}
+	return nil
+}
+
+// ptraceSingleStep executes ptrace PT_STEP.
+// id may be a PID or a TID
+func ptraceSingleStep(id int) error {
+	if ret, err := C.ptrace_single_step(C.int(id)); ret == -1 {
+		return err
+	}
+	return nil
+}
+
+// Get a list of the thread ids of a process
+func ptraceGetThreadList(pid int) ([]int, error) {
+	tids := make([]C.int, 16)
+	for {
+		n, err := C.ptrace_get_thread_list(C.int(pid), &tids[0], C.size_t(len(tids)))
+		if n < 0 {
+			return nil, err
+		}
+		if int(n) <= len(tids) {
+			r := make([]int, n)
+			for i := range r {
+				r[i] = int(tids[i])
+			}
+			return r, nil
+		}
+		// new threads were created, try again with a bigger buffer
+		tids = make([]C.int, 2*n)
+	}
+}
+
+// Get the thread that caused the process to stop.
+func ptraceGetEventThread(pid int) (int, error) {
+	tid, err := C.ptrace_get_event_thread(C.int(pid))
+	if tid < 0 {
+		return 0, err
+	}
+	return int(tid), nil
+}
+
+// id may be a PID or a TID
+func ptraceGetRegs(id int, regs *obsdutil.AMD64PtraceRegs) error {
+	if ret, err := C.ptrace(C.PT_GETREGS, C.pid_t(id), C.caddr_t(unsafe.Pointer(regs)), 0); ret == -1 {
+		return err
+	}
+	return nil
+}
+
+// id may be a PID or a TID
+func ptraceSetRegs(id int, regs *obsdutil.AMD64PtraceRegs) error {
+	if ret, err := C.ptrace(C.PT_SETREGS, C.pid_t(id), C.caddr_t(unsafe.Pointer(regs)), 0); ret == -1 {
+		return err
+	}
+	return nil
+}
+
+// ptraceGetRegset returns the floating point registers of id, in the
+// FXSAVE format.
+func ptraceGetRegset(id int) (regset amd64util.AMD64Xstate, err error) {
+	if ret, err := C.ptrace(C.PT_GETFPREGS, C.pid_t(id), C.caddr_t(unsafe.Pointer(&regset.AMD64PtraceFpRegs)), 0); ret == -1 {
+		return regset, err
+	}
+	return regset, nil
+}
+
+// id may be a PID or a TID
+func ptraceSetFpRegs(id int, fpregs *amd64util.AMD64PtraceFpRegs) error {
+	if ret, err := C.ptrace(C.PT_SETFPREGS, C.pid_t(id), C.caddr_t(unsafe.Pointer(fpregs)), 0); ret == -1 {
+		return err
+	}
+	return nil
+}
+
+func ptraceIO(pid int, op C.int, addr uintptr, data []byte) (int, error) {
+	n, err := C.ptrace_io(C.int(pid), op, C.uintptr_t(addr), unsafe.Pointer(&data[0]), C.size_t(len(data)))
+	if n < 0 {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// pid must be a PID, not a TID
+func ptraceReadData(pid int, addr uintptr, data []byte) (n int, err error) {
+	return ptraceIO(pid, C.PIOD_READ_D, addr, data)
+}
+
+// pid must be a PID, not a TID
+func ptraceWriteData(pid int, addr uintptr, data []byte) (n int, err error) {
+	return ptraceIO(pid, C.PIOD_WRITE_D, addr, data)
+}
@@ -0,0 +1,9 @@
+#include <stddef.h>
+#include <sys/types.h>
+
+int ptrace_cont(int id, int sig);
+int ptrace_single_step(int id);
+int ptrace_detach(int pid);
+int ptrace_io(int pid, int op, uintptr_t addr, void *buf, size_t len);
+int ptrace_get_thread_list(int pid, int *tids, size_t len);
+int ptrace_get_event_thread(int pid);
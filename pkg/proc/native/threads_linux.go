@@ -20,7 +20,11 @@ type osSpecificDetails struct {
 }
 
 func (t *nativeThread) stop() (err error) {
-	err = sys.Tgkill(t.dbp.pid, t.ID, sys.SIGSTOP)
+	if t.dbp.os.seized {
+		t.dbp.execPtraceFunc(func() { err = ptraceInterrupt(t.ID) })
+	} else {
+		err = sys.Tgkill(t.dbp.pid, t.ID, sys.SIGSTOP)
+	}
 	if err != nil {
 		err = fmt.Errorf("stop err %s on thread %d", err, t.ID)
 		return
@@ -43,12 +47,14 @@ func (t *nativeThread) resume() error {
 
 func (t *nativeThread) resumeWithSig(sig int) (err error) {
 	t.os.running = true
+	t.dbp.os.memcache.invalidate()
 	t.dbp.execPtraceFunc(func() { err = ptraceCont(t.ID, sig) })
 	return
 }
 
 func (t *nativeThread) singleStep() (err error) {
 	sig := 0
+	t.dbp.os.memcache.invalidate()
 	for {
 		t.dbp.execPtraceFunc(func() { err = ptraceSingleStep(t.ID, sig) })
 		sig = 0
@@ -68,6 +74,11 @@ func (t *nativeThread) singleStep() (err error) {
 			return proc.ErrProcessExited{Pid: t.dbp.pid, Status: rs}
 		}
 		if wpid == t.ID {
+			if isPtraceEventStop(status) {
+				// The thread was interrupted or entered group-stop before
+				// executing the instruction, step it again.
+				continue
+			}
 			switch s := status.StopSignal(); s {
 			case sys.SIGTRAP:
 				return nil
@@ -91,6 +102,7 @@ func (t *nativeThread) WriteMemory(addr uint64, data []byte) (written int, err e
 	if len(data) == 0 {
 		return
 	}
+	t.dbp.os.memcache.invalidateRange(addr, len(data))
 	// ProcessVmWrite can't poke read-only memory like ptrace, so don't
 	// even bother for small writes -- likely breakpoints and such.
 	if len(data) > sys.SizeofPtr {
@@ -109,6 +121,12 @@ func (t *nativeThread) ReadMemory(data []byte, addr uint64) (n int, err error) {
 	if len(data) == 0 {
 		return
 	}
+	// In non-stop mode the memory can be changed by the threads that are
+	// still running, the cache can not be used. The same is true while a
+	// vfork child shares our address space.
+	if !t.dbp.os.nonStop && len(t.dbp.os.vforked) == 0 && t.dbp.os.memcache.read(t.ID, data, addr) {
+		return len(data), nil
+	}
 	n, _ = processVmRead(t.ID, uintptr(addr), data)
 	if n == 0 {
 		t.dbp.execPtraceFunc(func() { n, err = sys.PtracePeekData(t.ID, uintptr(addr), data) })
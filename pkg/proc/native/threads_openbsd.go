@@ -0,0 +1,116 @@
+//go:build cgo
+// +build cgo
+
+package native
+
+import (
+	"fmt"
+
+	sys "golang.org/x/sys/unix"
+
+	"github.com/go-delve/delve/pkg/proc"
+	"github.com/go-delve/delve/pkg/proc/amd64util"
+	"github.com/go-delve/delve/pkg/proc/obsdutil"
+)
+
+type waitStatus sys.WaitStatus
+
+// osSpecificDetails hold OpenBSD specific process details.
+type osSpecificDetails struct{}
+
+func (t *nativeThread) stop() (err error) {
+	// All threads of a traced process are stopped together.
+	err = sys.Kill(t.dbp.pid, sys.SIGSTOP)
+	if err != nil {
+		err = fmt.Errorf("stop err %s on thread %d", err, t.ID)
+		return
+	}
+	_, _, err = t.dbp.waitFast(t.dbp.pid)
+	if err != nil {
+		err = fmt.Errorf("wait err %s on thread %d", err, t.ID)
+		return
+	}
+	return
+}
+
+func (t *nativeThread) Stopped() bool {
+	state := status(t.dbp.pid)
+	return state == statusStopped
+}
+
+func (t *nativeThread) resume() error {
+	return t.resumeWithSig(0)
+}
+
+func (t *nativeThread) resumeWithSig(sig int) (err error) {
+	t.dbp.execPtraceFunc(func() { err = ptraceCont(t.ID, sig) })
+	return
+}
+
+func (t *nativeThread) singleStep() (err error) {
+	t.dbp.execPtraceFunc(func() { err = ptraceSingleStep(t.ID) })
+	if err != nil {
+		return err
+	}
+	for {
+		th, err := t.dbp.trapWait(t.dbp.pid)
+		if err != nil {
+			return err
+		}
+		if th.ID == t.ID {
+			break
+		}
+		t.dbp.execPtraceFunc(func() { err = ptraceCont(th.ID, 0) })
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *nativeThread) restoreRegisters(savedRegs proc.Registers) error {
+	sr := savedRegs.(*obsdutil.AMD64Registers)
+
+	var restoreRegistersErr error
+	t.dbp.execPtraceFunc(func() {
+		restoreRegistersErr = ptraceSetRegs(t.ID, sr.Regs)
+		if restoreRegistersErr != nil {
+			return
+		}
+		if sr.Fpregset != nil {
+			restoreRegistersErr = ptraceSetFpRegs(t.ID, &sr.Fpregset.AMD64PtraceFpRegs)
+		}
+	})
+	return restoreRegistersErr
+}
+
+func (t *nativeThread) WriteMemory(addr uint64, data []byte) (written int, err error) {
+	if t.dbp.exited {
+		return 0, proc.ErrProcessExited{Pid: t.dbp.pid}
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	t.dbp.execPtraceFunc(func() { written, err = ptraceWriteData(t.dbp.pid, uintptr(addr), data) })
+	return written, err
+}
+
+func (t *nativeThread) ReadMemory(data []byte, addr uint64) (n int, err error) {
+	if t.dbp.exited {
+		return 0, proc.ErrProcessExited{Pid: t.dbp.pid}
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	t.dbp.execPtraceFunc(func() { n, err = ptraceReadData(t.dbp.pid, uintptr(addr), data) })
+	return n, err
+}
+
+func (t *nativeThread) withDebugRegisters(f func(*amd64util.DebugRegisters) error) error {
+	return proc.ErrHWBreakUnsupported
+}
+
+// SoftExc returns true if this thread received a software exception during the last resume.
+func (t *nativeThread) SoftExc() bool {
+	return false
+}
@@ -0,0 +1,154 @@
+package obsdutil
+
+import (
+	"github.com/go-delve/delve/pkg/proc"
+	"github.com/go-delve/delve/pkg/proc/amd64util"
+)
+
+// AMD64Registers implements the proc.Registers interface for the
+// native/openbsd backend, on AMD64.
+type AMD64Registers struct {
+	Regs     *AMD64PtraceRegs
+	Fpregs   []proc.Register
+	Fpregset *amd64util.AMD64Xstate
+
+	loadFpRegs func(*AMD64Registers) error
+}
+
+func NewAMD64Registers(regs *AMD64PtraceRegs, loadFpRegs func(*AMD64Registers) error) *AMD64Registers {
+	return &AMD64Registers{Regs: regs, loadFpRegs: loadFpRegs}
+}
+
+// AMD64PtraceRegs is the struct used by the openbsd kernel to return the
+// general purpose registers for AMD64 CPUs.
+// source: sys/arch/amd64/include/reg.h
+type AMD64PtraceRegs struct {
+	Rdi    int64
+	Rsi    int64
+	Rdx    int64
+	Rcx    int64
+	R8     int64
+	R9     int64
+	R10    int64
+	R11    int64
+	R12    int64
+	R13    int64
+	R14    int64
+	R15    int64
+	Rbp    int64
+	Rbx    int64
+	Rax    int64
+	Rsp    int64
+	Rip    int64
+	Rflags int64
+	Cs     int64
+	Ss     int64
+	Ds     int64
+	Es     int64
+	Fs     int64
+	Gs     int64
+}
+
+// Slice returns the registers as a list of (name, value) pairs.
+func (r *AMD64Registers) Slice(floatingPoint bool) ([]proc.Register, error) {
+	var regs64 = []struct {
+		k string
+		v int64
+	}{
+		{"R15", r.Regs.R15},
+		{"R14", r.Regs.R14},
+		{"R13", r.Regs.R13},
+		{"R12", r.Regs.R12},
+		{"R11", r.Regs.R11},
+		{"R10", r.Regs.R10},
+		{"R9", r.Regs.R9},
+		{"R8", r.Regs.R8},
+		{"Rdi", r.Regs.Rdi},
+		{"Rsi", r.Regs.Rsi},
+		{"Rbp", r.Regs.Rbp},
+		{"Rbx", r.Regs.Rbx},
+		{"Rdx", r.Regs.Rdx},
+		{"Rcx", r.Regs.Rcx},
+		{"Rax", r.Regs.Rax},
+		{"Rip", r.Regs.Rip},
+		{"Cs", r.Regs.Cs},
+		{"Rflags", r.Regs.Rflags},
+		{"Rsp", r.Regs.Rsp},
+		{"Ss", r.Regs.Ss},
+		{"Ds", r.Regs.Ds},
+		{"Es", r.Regs.Es},
+		{"Fs", r.Regs.Fs},
+		{"Gs", r.Regs.Gs},
+	}
+	out := make([]proc.Register, 0, len(regs64)+len(r.Fpregs))
+	for _, reg := range regs64 {
+		// OpenBSD defines the registers as signed, cast to what Delve
+		// expects.
+		out = proc.AppendUint64Register(out, reg.k, uint64(reg.v))
+	}
+	var floatLoadError error
+	if floatingPoint {
+		if r.loadFpRegs != nil {
+			floatLoadError = r.loadFpRegs(r)
+			r.loadFpRegs = nil
+		}
+		out = append(out, r.Fpregs...)
+	}
+	return out, floatLoadError
+}
+
+// PC returns the value of RIP register.
+func (r *AMD64Registers) PC() uint64 {
+	return uint64(r.Regs.Rip)
+}
+
+// SP returns the value of RSP register.
+func (r *AMD64Registers) SP() uint64 {
+	return uint64(r.Regs.Rsp)
+}
+
+func (r *AMD64Registers) BP() uint64 {
+	return uint64(r.Regs.Rbp)
+}
+
+func (r *AMD64Registers) LR() uint64 {
+	return 0
+}
+
+// TLS returns the address of the thread local storage memory segment.
+// The base of the FS segment is not exported by ptrace on OpenBSD, see
+// GAddr.
+func (r *AMD64Registers) TLS() uint64 {
+	return 0
+}
+
+// GAddr returns the address of the G variable if it is known, 0 and false
+// otherwise.
+// Since the TLS can not be read, the G variable is read from R14, where Go
+// 1.17 and later keep it while running Go code (register ABI).
+func (r *AMD64Registers) GAddr() (uint64, bool) {
+	return uint64(r.Regs.R14), true
+}
+
+// Copy returns a copy of these registers that is guaranteed not to change.
+func (r *AMD64Registers) Copy() (proc.Registers, error) {
+	if r.loadFpRegs != nil {
+		err := r.loadFpRegs(r)
+		r.loadFpRegs = nil
+		if err != nil {
+			return nil, err
+		}
+	}
+	var rr AMD64Registers
+	rr.Regs = &AMD64PtraceRegs{}
+	rr.Fpregset = &amd64util.AMD64Xstate{}
+	*(rr.Regs) = *(r.Regs)
+	if r.Fpregset != nil {
+		*(rr.Fpregset) = *(r.Fpregset)
+	}
+	if r.Fpregs != nil {
+		rr.Fpregs = make([]proc.Register, len(r.Fpregs))
+		copy(rr.Fpregs, r.Fpregs)
+	}
+	return &rr, nil
+}
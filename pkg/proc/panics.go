@@ -0,0 +1,113 @@
+package proc
+
+import (
+	"errors"
+	"go/constant"
+	"strings"
+)
+
+const (
+	// maxPanics is the maximum number of panics read from the list of a
+	// goroutine, to protect against corrupted runtime structures.
+	maxPanics = 100
+	// panicsStackDepth is the depth of the stacktrace searched for the
+	// frames that called panic.
+	panicsStackDepth = 100
+)
+
+// Panic is a panic of a goroutine, as described by runtime._panic.
+type Panic struct {
+	// Value is the argument of panic.
+	Value *Variable
+	// Recovered is true if the panic was recovered by a deferred call.
+	Recovered bool
+	// Repanicked is true if the value of the panic was passed to panic
+	// again after recovering it, only set since Go 1.23.
+	Repanicked bool
+	// Frame is the index in the stacktrace of the goroutine of the frame
+	// that caused the panic: the caller of panic or, for runtime errors, the
+	// function that caused the error. It is -1 if the frame could not be
+	// found.
+	Frame int
+	// Location is the location of Frame.
+	Location Location
+}
+
+// GoroutinePanics returns the panics of goroutine g, starting from the most
+// recent one and following the panics they interrupted. The values of the
+// panics are loaded using cfg.
+func (t *Target) GoroutinePanics(g *G, cfg LoadConfig) ([]*Panic, error) {
+	if g == nil || g.variable == nil {
+		return nil, errors.New("no goroutine")
+	}
+	frames, err := g.Stacktrace(panicsStackDepth, 0)
+	if err != nil {
+		return nil, err
+	}
+	// The frames of runtime.gopanic, one for each panic that is still
+	// running, in the same order as the list of panics.
+	gopanicFrames := []int{}
+	for i := range frames {
+		if frames[i].Current.Fn != nil && frames[i].Current.Fn.Name == "runtime.gopanic" {
+			gopanicFrames = append(gopanicFrames, i)
+		}
+	}
+
+	pvar, err := g.variable.structMember("_panic") // +rtype *_panic
+	if err != nil {
+		return nil, err
+	}
+	r := []*Panic{}
+	for len(r) < maxPanics {
+		pvar = pvar.maybeDereference()
+		if pvar.Unreadable != nil {
+			return r, pvar.Unreadable
+		}
+		if pvar.Addr == 0 {
+			break
+		}
+		loadBool := func(name string) bool {
+			v := pvar.loadFieldNamed(name)
+			return v != nil && v.Value != nil && constant.BoolVal(v.Value)
+		}
+		// runtime.Goexit uses a panic to run deferred calls, without calling
+		// runtime.gopanic.
+		if !loadBool("goexit") { // +rtype -field _panic.goexit bool
+			p := &Panic{
+				Recovered:  loadBool("recovered"),  // +rtype -field _panic.recovered bool
+				Repanicked: loadBool("repanicked"), // +rtype -opt -field _panic.repanicked bool
+				Frame:      -1,
+			}
+			p.Value, err = pvar.structMember("arg") // +rtype -field _panic.arg interface{}
+			if err != nil {
+				return r, err
+			}
+			p.Value.loadValue(cfg)
+			if len(r) < len(gopanicFrames) {
+				p.Frame = panicOriginFrame(frames, gopanicFrames[len(r)])
+				if p.Frame >= 0 {
+					p.Location = frames[p.Frame].Call
+				}
+			}
+			r = append(r, p)
+		}
+		pvar, err = pvar.structMember("link") // +rtype -field _panic.link *_panic
+		if err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// panicOriginFrame returns the index of the frame that caused the panic
+// whose runtime.gopanic frame has index gopanicFrame: the first frame below
+// it that doesn't belong to the runtime, skipping for example
+// runtime.panicmem and runtime.sigpanic for runtime errors.
+func panicOriginFrame(frames []Stackframe, gopanicFrame int) int {
+	for i := gopanicFrame + 1; i < len(frames); i++ {
+		if fn := frames[i].Call.Fn; fn != nil && !strings.HasPrefix(fn.Name, "runtime.") {
+			return i
+		}
+	}
+	return -1
+}
@@ -1,8 +1,16 @@
 package proc
 
 import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"testing"
 	"unsafe"
@@ -100,6 +108,22 @@ func TestReadCStringValue(t *testing.T) {
 	}
 }
 
+func TestRuntimeAllglenBound(t *testing.T) {
+	bi := NewBinaryInfo("linux", "amd64")
+	dm := &dummyMem{t: t, base: 0x1000, mem: make([]byte, 16)}
+	gcache := &goroutineCache{allglenAddr: 0x1000, allgentryAddr: 0x1008}
+
+	binary.LittleEndian.PutUint64(dm.mem, 10)
+	if _, allglen, err := gcache.getRuntimeAllg(bi, dm); err != nil || allglen != 10 {
+		t.Fatalf("getRuntimeAllg: %d %v", allglen, err)
+	}
+
+	binary.LittleEndian.PutUint64(dm.mem, maxGoroutines+1)
+	if _, _, err := gcache.getRuntimeAllg(bi, dm); err == nil {
+		t.Fatalf("getRuntimeAllg accepted allglen %d", maxGoroutines+1)
+	}
+}
+
 func assertNoError(err error, t testing.TB, s string) {
 	if err != nil {
 		_, file, line, _ := runtime.Caller(1)
@@ -120,6 +144,111 @@ func TestDwarfVersion(t *testing.T) {
 	}
 }
 
+func TestLazyCompileUnits(t *testing.T) {
+	// Tests that the types, variables and inlined calls of Go compile units
+	// are loaded only when they are needed.
+	fixture := protest.BuildFixture("doubleinline", protest.EnableInlining|protest.EnableOptimization)
+	bi := NewBinaryInfo(runtime.GOOS, runtime.GOARCH)
+	assertNoError(bi.LoadBinaryInfo(fixture.Path, 0, nil), t, "LoadBinaryInfo")
+	if len(bi.deferredTypes) == 0 || len(bi.deferredInlinedCalls) == 0 {
+		t.Fatalf("nothing was deferred: %d %d", len(bi.deferredTypes), len(bi.deferredInlinedCalls))
+	}
+	if len(bi.types) != 0 {
+		t.Errorf("%d types loaded eagerly", len(bi.types))
+	}
+
+	fns, err := bi.FindFunction("main.(*Rectangle).Height")
+	assertNoError(err, t, "FindFunction")
+	if len(fns[0].InlinedCalls) != 1 {
+		t.Errorf("expected one inlined call for Height, got %d", len(fns[0].InlinedCalls))
+	}
+	if len(bi.deferredInlinedCalls) != 0 {
+		t.Errorf("inlined calls of %d compile units still deferred", len(bi.deferredInlinedCalls))
+	}
+
+	deferred := len(bi.deferredTypes)
+	_, err = bi.findType("main.Rectangle")
+	assertNoError(err, t, "findType")
+	if len(bi.deferredTypes) == 0 || len(bi.deferredTypes) >= deferred {
+		t.Errorf("findType loaded the types of %d compile units out of %d", deferred-len(bi.deferredTypes), deferred)
+	}
+	for _, cu := range bi.deferredTypes {
+		if cu.deferredTypeEntries {
+			t.Errorf("type entries of compile unit %s still deferred", cu.name)
+		}
+	}
+
+	hasPackageVar := func(name string) bool {
+		for _, v := range bi.packageVars {
+			if v.name == name {
+				return true
+			}
+		}
+		return false
+	}
+	deferred = len(bi.deferredTypes)
+	bi.loadDeferredGlobal("strconv.ErrRange")
+	for _, cu := range bi.deferredTypes {
+		if cu.name == "strconv" {
+			t.Errorf("compile unit strconv still deferred")
+		}
+	}
+	if len(bi.deferredTypes) == 0 || len(bi.deferredTypes) >= deferred {
+		t.Errorf("loadDeferredGlobal loaded %d compile units out of %d", deferred-len(bi.deferredTypes), deferred)
+	}
+	if !hasPackageVar("strconv.ErrRange") {
+		t.Errorf("package variables of strconv not loaded")
+	}
+
+	bi.loadDeferredTypes()
+	if len(bi.deferredTypes) != 0 {
+		t.Errorf("types of %d compile units still deferred", len(bi.deferredTypes))
+	}
+	if !hasPackageVar("runtime.buildVersion") {
+		t.Errorf("package variables not loaded")
+	}
+}
+
+func TestParallelLoadBinaryInfo(t *testing.T) {
+	// Tests that loading the debug info of a binary concurrently produces the
+	// same result as loading it with a single worker.
+	fixture := protest.BuildFixture("testvariables2", 0)
+	load := func(procs int) *BinaryInfo {
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+		bi := NewBinaryInfo(runtime.GOOS, runtime.GOARCH)
+		assertNoError(bi.LoadBinaryInfo(fixture.Path, 0, nil), t, "LoadBinaryInfo")
+		return bi
+	}
+	bi1, bi4 := load(1), load(4)
+	if len(bi1.Functions) != len(bi4.Functions) {
+		t.Fatalf("different number of functions: %d %d", len(bi1.Functions), len(bi4.Functions))
+	}
+	for i := range bi1.Functions {
+		fn1, fn4 := &bi1.Functions[i], &bi4.Functions[i]
+		if fn1.Name != fn4.Name || fn1.Entry != fn4.Entry || fn1.End != fn4.End || fn1.offset != fn4.offset {
+			t.Errorf("function %d: %s %#x differs from %s %#x", i, fn1.Name, fn1.Entry, fn4.Name, fn4.Entry)
+		}
+	}
+	if !reflect.DeepEqual(bi1.Sources, bi4.Sources) {
+		t.Error("different sources")
+	}
+	if !reflect.DeepEqual(bi1.PackageMap, bi4.PackageMap) {
+		t.Error("different package maps")
+	}
+	cus1, cus4 := bi1.Images[0].compileUnits, bi4.Images[0].compileUnits
+	if len(cus1) != len(cus4) {
+		t.Fatalf("different number of compile units: %d %d", len(cus1), len(cus4))
+	}
+	for i := range cus1 {
+		if cus1[i].name != cus4[i].name || (cus1[i].lineInfo == nil) != (cus4[i].lineInfo == nil) || !reflect.DeepEqual(cus1[i].deferredTypes, cus4[i].deferredTypes) {
+			t.Errorf("compile unit %d: %s differs from %s", i, cus1[i].name, cus4[i].name)
+		}
+	}
+	if len(bi1.Functions) == 0 || len(cus1) == 0 {
+		t.Fatal("nothing loaded")
+	}
+}
+
 func TestRegabiFlagSentinel(t *testing.T) {
 	// Detect if the regabi flag in the producer string gets removed
 	if !protest.RegabiSupported() {
@@ -166,3 +295,385 @@ func TestGenericFunctionParser(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadWithNameIndex(t *testing.T) {
+	// Compile units indexed by a debug_names section are loaded from the
+	// entries of the index, check that the result is the same.
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("only supported on linux/amd64")
+	}
+	for _, tool := range []string{"llc", "gcc"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found", tool)
+		}
+	}
+	fixturesDir := protest.FindFixturesDir()
+	dir := t.TempDir()
+	obj, exe := filepath.Join(dir, "accelnames.o"), filepath.Join(dir, "accelnames")
+	for _, args := range [][]string{
+		{"llc", "-filetype=obj", "-accel-tables=Dwarf", "-o", obj, filepath.Join(fixturesDir, "accelnames.ll")},
+		{"gcc", "-no-pie", "-o", exe, obj},
+	} {
+		if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			t.Skipf("%s failed: %v\n%s", args[0], err, out)
+		}
+	}
+
+	bi := NewBinaryInfo("linux", "amd64")
+	if err := bi.LoadBinaryInfo(exe, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if bi.Images[0].nameIndex == nil {
+		t.Fatal("name index not loaded")
+	}
+
+	f, err := elf.Open(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	syms, _ := f.Symbols()
+	symAddr := map[string]uint64{}
+	for _, sym := range syms {
+		symAddr["C."+sym.Name] = sym.Value
+	}
+
+	for _, name := range []string{"C.add", "C.main"} {
+		fn := bi.LookupFunc[name]
+		if fn == nil {
+			t.Errorf("function %s not found", name)
+			continue
+		}
+		if fn.Entry != symAddr[name] {
+			t.Errorf("function %s: entry %#x, expected %#x", name, fn.Entry, symAddr[name])
+		}
+		if bi.PCToFunc(fn.Entry) != fn {
+			t.Errorf("function %s: PCToFunc(%#x) returned %v", name, fn.Entry, bi.PCToFunc(fn.Entry))
+		}
+	}
+	found := false
+	for _, v := range bi.packageVars {
+		if v.name == "C.origin" {
+			found = true
+			if v.addr != symAddr[v.name] {
+				t.Errorf("variable %s: address %#x, expected %#x", v.name, v.addr, symAddr[v.name])
+			}
+		}
+	}
+	if !found {
+		t.Errorf("variable C.origin not found")
+	}
+	for _, name := range []string{"C.point", "C.int"} {
+		if _, err := bi.findType(name); err != nil {
+			t.Errorf("type %s: %v", name, err)
+		}
+	}
+}
+
+func TestLoadCompressedDebugSections(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("only supported on linux")
+	}
+	if _, err := exec.LookPath("objcopy"); err != nil {
+		t.Skip("objcopy not found")
+	}
+	// The debug sections are compressed with objcopy, check that the
+	// result of loading the executable is the same as before.
+	fixture := protest.BuildFixture("math", 0)
+	load := func(exe string) *BinaryInfo {
+		bi := NewBinaryInfo(runtime.GOOS, runtime.GOARCH)
+		if err := bi.LoadBinaryInfo(exe, 0, nil); err != nil {
+			t.Fatalf("%s: %v", exe, err)
+		}
+		return bi
+	}
+	orig := load(fixture.Path)
+	origFn := orig.LookupFunc["main.main"]
+	if origFn == nil {
+		t.Fatal("function main.main not found")
+	}
+	origFile, origLine, _ := orig.PCToLine(origFn.Entry)
+
+	for _, kind := range []string{"zlib-gabi", "zstd"} {
+		exe := filepath.Join(t.TempDir(), "math."+kind)
+		if out, err := exec.Command("objcopy", "--compress-debug-sections="+kind, fixture.Path, exe).CombinedOutput(); err != nil {
+			t.Skipf("objcopy failed: %v\n%s", err, out)
+		}
+		bi := load(exe)
+		if len(bi.Functions) != len(orig.Functions) || len(bi.Sources) != len(orig.Sources) {
+			t.Errorf("%s: %d functions and %d sources, expected %d and %d", kind, len(bi.Functions), len(bi.Sources), len(orig.Functions), len(orig.Sources))
+		}
+		fn := bi.LookupFunc["main.main"]
+		if fn == nil {
+			t.Errorf("%s: function main.main not found", kind)
+			continue
+		}
+		if file, line, _ := bi.PCToLine(fn.Entry); fn.Entry != origFn.Entry || file != origFile || line != origLine {
+			t.Errorf("%s: main.main at %#x %s:%d, expected %#x %s:%d", kind, fn.Entry, file, line, origFn.Entry, origFile, origLine)
+		}
+	}
+}
+
+func TestLoadDsym(t *testing.T) {
+	// Builds a stripped darwin executable and puts the unstripped one in a
+	// .dSYM bundle, with the same UUID, to check that the debug symbols are
+	// loaded from the bundle.
+	dir := t.TempDir()
+	full, stripped := filepath.Join(dir, "full"), filepath.Join(dir, "Math.app", "Contents", "MacOS", "math")
+	src := filepath.Join(protest.FindFixturesDir(), "math.go")
+	for _, args := range [][]string{{"-o", full}, {"-ldflags=-w", "-o", stripped}} {
+		cmd := exec.Command("go", append(append([]string{"build"}, args...), src)...)
+		cmd.Env = append(os.Environ(), "GOOS=darwin", "GOARCH=amd64", "CGO_ENABLED=0")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Skipf("could not build darwin executable: %v\n%s", err, out)
+		}
+	}
+	uuid := func(path string) []byte {
+		f, err := macho.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		for _, l := range f.Loads {
+			if raw := l.Raw(); f.ByteOrder.Uint32(raw) == 0x1b {
+				return append([]byte(nil), raw[8:24]...)
+			}
+		}
+		t.Skipf("%s has no UUID", path)
+		return nil
+	}
+	mismatched, err := os.ReadFile(stripped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched := bytes.Replace(mismatched, uuid(stripped), uuid(full), 1)
+	if err := os.WriteFile(stripped, patched, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	load := func(path string, debugInfoDirs []string) (*BinaryInfo, error) {
+		bi := NewBinaryInfo("darwin", "amd64")
+		err := bi.LoadBinaryInfo(path, 0, debugInfoDirs)
+		return bi, err
+	}
+	// without a bundle functions are loaded from the pclntab
+	if bi, err := load(stripped, nil); err != nil || !bi.Images[0].SymbolsOnly() {
+		t.Fatalf("stripped executable not loaded from the pclntab: %v", err)
+	}
+
+	fullBuf, err := os.ReadFile(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	debugDir := filepath.Join(dir, "symbols")
+	for _, tc := range []struct {
+		name, bundle  string
+		debugInfoDirs []string
+	}{
+		{"next to the executable", stripped + ".dSYM", nil},
+		{"next to the app bundle", filepath.Join(dir, "Math.app.dSYM"), nil},
+		{"in debug-info-directories", filepath.Join(debugDir, "math.dSYM"), []string{debugDir}},
+	} {
+		dwarfDir := filepath.Join(tc.bundle, "Contents", "Resources", "DWARF")
+		if err := os.MkdirAll(dwarfDir, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dwarfDir, "math"), fullBuf, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		bi, err := load(stripped, tc.debugInfoDirs)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		} else if bi.LookupFunc["main.main"] == nil {
+			t.Errorf("%s: function main.main not found", tc.name)
+		} else if bi.Images[0].SymbolsOnly() {
+			t.Errorf("%s: .dSYM bundle not used", tc.name)
+		}
+
+		// a bundle with a different UUID must not be used
+		if err := os.WriteFile(stripped, mismatched, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if bi, err := load(stripped, tc.debugInfoDirs); err == nil && !bi.Images[0].SymbolsOnly() {
+			t.Errorf("%s: loaded .dSYM bundle with the wrong UUID", tc.name)
+		}
+		if err := os.WriteFile(stripped, patched, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		os.RemoveAll(tc.bundle)
+	}
+}
+
+func TestLoadPDB(t *testing.T) {
+	// Puts a PDB file, describing C functions, next to a windows executable
+	// and checks that the functions are added to its debug info.
+	if _, err := exec.LookPath("llvm-pdbutil"); err != nil {
+		t.Skip("llvm-pdbutil not found")
+	}
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "math.exe")
+	cmd := exec.Command("go", "build", "-o", exe, filepath.Join(protest.FindFixturesDir(), "math.go"))
+	cmd.Env = append(os.Environ(), "GOOS=windows", "GOARCH=amd64", "CGO_ENABLED=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("could not build windows executable: %v\n%s", err, out)
+	}
+	if out, err := exec.Command("llvm-pdbutil", "yaml2pdb", filepath.Join(protest.FindFixturesDir(), "pdbsyms.yaml"), "--pdb="+filepath.Join(dir, "math.pdb")).CombinedOutput(); err != nil {
+		t.Skipf("llvm-pdbutil failed: %v\n%s", err, out)
+	}
+
+	peFile, err := pe.Open(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peFile.Close()
+	imageBase := peFile.OptionalHeader.(*pe.OptionalHeader64).ImageBase
+
+	bi := NewBinaryInfo("windows", "amd64")
+	if err := bi.LoadBinaryInfo(exe, imageBase, nil); err != nil {
+		t.Fatal(err)
+	}
+	if bi.LookupFunc["main.main"] == nil {
+		t.Error("function main.main not found")
+	}
+	fn := bi.LookupFunc["C.add"]
+	if fn == nil {
+		t.Fatal("function C.add not found")
+	}
+	if entry := imageBase + uint64(peFile.Sections[0].VirtualAddress) + 16; fn.Entry != entry {
+		t.Errorf("wrong entry point of C.add %#x, expected %#x", fn.Entry, entry)
+	}
+	if file, line := fn.cu.lineInfo.PCToLine(fn.Entry, fn.Entry+8); file != "C:/src/hello.c" || line != 11 {
+		t.Errorf("wrong position of C.add+8 %s:%d", file, line)
+	}
+}
+
+func TestLoadPclntab(t *testing.T) {
+	// Executables built with -ldflags=-w are loaded from the pclntab, check
+	// that functions end up at the same position as when DWARF is present.
+	for _, goos := range []string{"linux", "windows", "darwin"} {
+		t.Run(goos, func(t *testing.T) {
+			dir := t.TempDir()
+			build := func(name string, ldflags string) string {
+				exe := filepath.Join(dir, name)
+				cmd := exec.Command("go", "build", "-ldflags="+ldflags, "-o", exe, filepath.Join(protest.FindFixturesDir(), "math.go"))
+				cmd.Env = append(os.Environ(), "GOOS="+goos, "GOARCH=amd64", "CGO_ENABLED=0")
+				if out, err := cmd.CombinedOutput(); err != nil {
+					t.Skipf("could not build %s executable: %v\n%s", goos, err, out)
+				}
+				return exe
+			}
+			load := func(exe string) *BinaryInfo {
+				bi := NewBinaryInfo(goos, "amd64")
+				var entryPoint uint64
+				if goos == "windows" {
+					peFile, err := pe.Open(exe)
+					if err != nil {
+						t.Fatal(err)
+					}
+					entryPoint = peFile.OptionalHeader.(*pe.OptionalHeader64).ImageBase
+					peFile.Close()
+				}
+				if err := bi.LoadBinaryInfo(exe, entryPoint, nil); err != nil {
+					t.Fatalf("%s: %v", exe, err)
+				}
+				return bi
+			}
+			orig := load(build("math", ""))
+			bi := load(build("math-nodwarf", "-w"))
+			if !bi.Images[0].SymbolsOnly() || orig.Images[0].SymbolsOnly() {
+				t.Errorf("wrong SymbolsOnly value")
+			}
+			// the pclntab does not know about the padding at the end of
+			// functions
+			for _, name := range []string{"main.main", "runtime.main"} {
+				origFn, fn := orig.LookupFunc[name], bi.LookupFunc[name]
+				if origFn == nil || fn == nil {
+					t.Fatalf("function %s not found", name)
+				}
+				_, origLine, _ := orig.PCToLine(origFn.Entry)
+				if _, line, _ := bi.PCToLine(fn.Entry); fn.Entry != origFn.Entry || fn.End < origFn.End || line != origLine {
+					t.Errorf("%s at %#x-%#x line %d, expected %#x-%#x line %d", name, fn.Entry, fn.End, line, origFn.Entry, origFn.End, origLine)
+				}
+			}
+			src, _ := filepath.Abs(filepath.Join(protest.FindFixturesDir(), "math.go"))
+			fn := bi.LookupFunc["main.main"]
+			if file, _, _ := bi.PCToLine(fn.Entry); file != filepath.ToSlash(src) {
+				t.Errorf("wrong file of main.main %s", file)
+			}
+		})
+	}
+}
+
+type elfTextMemory struct {
+	text *elf.Section
+}
+
+func (mem elfTextMemory) ReadMemory(buf []byte, addr uint64) (int, error) {
+	return mem.text.ReadAt(buf, int64(addr-mem.text.Addr))
+}
+
+func (mem elfTextMemory) WriteMemory(addr uint64, data []byte) (int, error) {
+	return 0, errors.New("read only")
+}
+
+func TestDisassembleLinuxArchs(t *testing.T) {
+	// Disassembly, prologue detection and stack unwinding tables for the
+	// architectures that use a decoder implemented by Delve.
+	for _, goarch := range []string{"riscv64", "loong64"} {
+		t.Run(goarch, func(t *testing.T) {
+			exe := filepath.Join(t.TempDir(), "testnextprog")
+			cmd := exec.Command("go", "build", "-gcflags=-N -l", "-o", exe, filepath.Join(protest.FindFixturesDir(), "testnextprog.go"))
+			cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH="+goarch, "CGO_ENABLED=0")
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Skipf("could not build %s executable: %v\n%s", goarch, err, out)
+			}
+			bi := NewBinaryInfo("linux", goarch)
+			if err := bi.LoadBinaryInfo(exe, 0, nil); err != nil {
+				t.Fatal(err)
+			}
+			elfFile, err := elf.Open(exe)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer elfFile.Close()
+			mem := elfTextMemory{elfFile.Section(".text")}
+			bpmap := NewBreakpointMap()
+
+			fn := bi.LookupFunc["main.testnext"]
+			text, err := disassemble(mem, nil, &bpmap, bi, fn.Entry, fn.End, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			calls := map[string]bool{}
+			rets := 0
+			for _, instr := range text {
+				t.Logf("%#x %s", instr.Loc.PC, instr.Text(GNUFlavour, bi))
+				switch {
+				case instr.IsCall() && instr.DestLoc != nil && instr.DestLoc.Fn != nil:
+					calls[instr.DestLoc.Fn.Name] = true
+				case instr.IsRet():
+					rets++
+				}
+			}
+			for _, name := range []string{"main.sleepytime", "main.helloworld", "runtime.morestack_noctxt"} {
+				if !calls[name] {
+					t.Errorf("call to %s not found", name)
+				}
+			}
+			if rets == 0 {
+				t.Errorf("no return instruction found")
+			}
+
+			found := false
+			for _, prologue := range bi.Arch.prologues {
+				if len(prologue) < len(text) && checkPrologue(text, prologue) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("stacksplit prologue not recognized")
+			}
+		})
+	}
+}
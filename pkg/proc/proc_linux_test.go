@@ -1,11 +1,17 @@
 package proc_test
 
 import (
+	"bytes"
+	"fmt"
+	"go/constant"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/go-delve/delve/pkg/proc"
 	"github.com/go-delve/delve/pkg/proc/native"
 	protest "github.com/go-delve/delve/pkg/proc/test"
 )
@@ -14,7 +20,7 @@ func TestLoadingExternalDebugInfo(t *testing.T) {
 	fixture := protest.BuildFixture("locationsprog", 0)
 	defer os.Remove(fixture.Path)
 	stripAndCopyDebugInfo(fixture, t)
-	p, err := native.Launch(append([]string{fixture.Path}, ""), "", 0, []string{filepath.Dir(fixture.Path)}, "", [3]string{})
+	p, err := native.Launch(append([]string{fixture.Path}, ""), "", nil, 0, []string{filepath.Dir(fixture.Path)}, "", [3]string{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -37,3 +43,301 @@ func stripAndCopyDebugInfo(f protest.Fixture, t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestFollowExec(t *testing.T) {
+	if testBackend != "native" {
+		t.Skip("follow exec is only supported by the native backend")
+	}
+	withTestProcess("spawnexec", t, func(p *proc.Target, fixture protest.Fixture) {
+		assertNoError(p.FollowExec(true, "", ""), t, "FollowExec")
+		assertNoError(p.Continue(), t, "Continue")
+		if p.StopReason != proc.StopNewChild {
+			t.Fatalf("wrong stop reason %v", p.StopReason)
+		}
+		children, err := p.NewChildren()
+		assertNoError(err, t, "NewChildren")
+		if len(children) != 1 {
+			t.Fatalf("wrong number of children %d", len(children))
+		}
+		child := children[0]
+		defer child.Detach(true)
+		if child.Pid() == p.Pid() {
+			t.Fatalf("child has the same pid as its parent %d", p.Pid())
+		}
+		if path := child.BinInfo().Images[0].Path; path != fixture.Path {
+			t.Errorf("wrong executable for child %q, expected %q", path, fixture.Path)
+		}
+
+		setFunctionBreakpoint(child, t, "main.child")
+		assertNoError(child.Continue(), t, "Continue (child)")
+		if loc, _ := child.CurrentThread().Location(); loc == nil || loc.Fn == nil || loc.Fn.Name != "main.child" {
+			t.Fatalf("child stopped at the wrong location %#v", loc)
+		}
+
+		err = child.Continue()
+		if pe, ok := err.(proc.ErrProcessExited); !ok || pe.Status != 2 {
+			t.Fatalf("expected child to exit with status 2, got %v", err)
+		}
+		err = p.Continue()
+		if pe, ok := err.(proc.ErrProcessExited); !ok || pe.Status != 0 {
+			t.Fatalf("expected parent to exit with status 0, got %v", err)
+		}
+	})
+}
+
+func TestExecNonGoProgram(t *testing.T) {
+	if testBackend != "native" {
+		t.Skip("exec detection is only supported by the native backend")
+	}
+	withTestProcess("execprog", t, func(p *proc.Target, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue")
+		if p.StopReason != proc.StopExec {
+			t.Fatalf("wrong stop reason %v", p.StopReason)
+		}
+		np, err := p.ExecTarget()
+		assertNoError(err, t, "ExecTarget")
+		defer np.Detach(true)
+		if valid, _ := p.Valid(); valid {
+			t.Errorf("old target still valid after exec")
+		}
+		if np.Pid() != p.Pid() {
+			t.Errorf("wrong pid %d, expected %d", np.Pid(), p.Pid())
+		}
+		if path := np.BinInfo().Images[0].Path; filepath.Base(path) == filepath.Base(fixture.Path) {
+			t.Errorf("wrong executable %q", path)
+		}
+
+		// symbol-less debugging
+		regs, err := np.CurrentThread().Registers()
+		assertNoError(err, t, "Registers")
+		text, err := proc.Disassemble(np.Memory(), regs, np.Breakpoints(), np.BinInfo(), regs.PC(), regs.PC()+16)
+		assertNoError(err, t, "Disassemble")
+		if len(text) == 0 {
+			t.Fatalf("no instructions disassembled at %#x", regs.PC())
+		}
+
+		err = np.Continue()
+		if pe, ok := err.(proc.ErrProcessExited); !ok || pe.Status != 3 {
+			t.Fatalf("expected process to exit with status 3, got %v", err)
+		}
+	})
+}
+
+func TestExecGoProgram(t *testing.T) {
+	if testBackend != "native" {
+		t.Skip("exec detection is only supported by the native backend")
+	}
+	withTestProcessArgs("execprog", t, ".", []string{"self"}, 0, func(p *proc.Target, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue")
+		if p.StopReason != proc.StopExec {
+			t.Fatalf("wrong stop reason %v", p.StopReason)
+		}
+		np, err := p.ExecTarget()
+		assertNoError(err, t, "ExecTarget")
+		defer np.Detach(true)
+		if np.BinInfo().Images[0].LoadError() != nil {
+			t.Fatalf("could not load debug info of the new program: %v", np.BinInfo().Images[0].LoadError())
+		}
+		setFunctionBreakpoint(np, t, "main.child")
+		assertNoError(np.Continue(), t, "Continue (new program)")
+		if loc, _ := np.CurrentThread().Location(); loc == nil || loc.Fn == nil || loc.Fn.Name != "main.child" {
+			t.Fatalf("stopped at the wrong location %#v", loc)
+		}
+		err = np.Continue()
+		if pe, ok := err.(proc.ErrProcessExited); !ok || pe.Status != 2 {
+			t.Fatalf("expected process to exit with status 2, got %v", err)
+		}
+	})
+}
+
+func TestNonStopMode(t *testing.T) {
+	if testBackend != "native" {
+		t.Skip("non-stop mode is only supported by the native backend")
+	}
+	withTestProcess("nonstop", t, func(p *proc.Target, fixture protest.Fixture) {
+		counter := func() uint64 {
+			n, _ := constant.Uint64Val(evalVariable(p, t, "main.counter").Value)
+			return n
+		}
+		running := func() int {
+			n := 0
+			for _, th := range p.ThreadList() {
+				if p.ThreadRunning(th) {
+					n++
+				}
+			}
+			return n
+		}
+
+		assertNoError(p.SetNonStop(true), t, "SetNonStop(true)")
+		setFunctionBreakpoint(p, t, "main.tick")
+		for i := 0; i < 2; i++ {
+			assertNoError(p.Continue(), t, "Continue")
+			if p.ThreadRunning(p.CurrentThread()) {
+				t.Fatalf("current thread %d is running", p.CurrentThread().ThreadID())
+			}
+			if loc, _ := p.CurrentThread().Location(); loc == nil || loc.Fn == nil || loc.Fn.Name != "main.tick" {
+				t.Fatalf("stopped at the wrong location %#v", loc)
+			}
+			if running() == 0 {
+				t.Fatalf("no thread was left running")
+			}
+			// main.spin must keep running while main.tick is stopped
+			c1 := counter()
+			time.Sleep(50 * time.Millisecond)
+			if c2 := counter(); c2 == c1 {
+				t.Fatalf("counter did not change while stopped in non-stop mode (%d)", c1)
+			}
+		}
+
+		assertNoError(p.SetNonStop(false), t, "SetNonStop(false)")
+		if n := running(); n != 0 {
+			t.Fatalf("%d threads still running after disabling non-stop mode", n)
+		}
+		c1 := counter()
+		time.Sleep(50 * time.Millisecond)
+		if c2 := counter(); c2 != c1 {
+			t.Fatalf("counter changed after disabling non-stop mode (%d -> %d)", c1, c2)
+		}
+	})
+}
+
+func TestFollowFork(t *testing.T) {
+	if testBackend != "native" {
+		t.Skip("follow exec is only supported by the native backend")
+	}
+	withTestProcess("spawnfork", t, func(p *proc.Target, fixture protest.Fixture) {
+		assertNoError(p.FollowExec(true, "", ""), t, "FollowExec")
+		// the breakpoint must not be copied in the memory of the child
+		setFunctionBreakpoint(p, t, "main.child")
+		assertNoError(p.Continue(), t, "Continue")
+		if p.StopReason != proc.StopNewChild {
+			t.Fatalf("wrong stop reason %v", p.StopReason)
+		}
+		children, err := p.NewChildren()
+		assertNoError(err, t, "NewChildren")
+		if len(children) != 1 {
+			t.Fatalf("wrong number of children %d", len(children))
+		}
+		child := children[0]
+		defer child.Detach(true)
+		if child.Pid() == p.Pid() {
+			t.Fatalf("child has the same pid as its parent %d", p.Pid())
+		}
+		if path := child.BinInfo().Images[0].Path; path != fixture.Path {
+			t.Errorf("wrong executable for child %q, expected %q", path, fixture.Path)
+		}
+
+		setFunctionBreakpoint(child, t, "main.child")
+		assertNoError(child.Continue(), t, "Continue (child)")
+		if loc, _ := child.CurrentThread().Location(); loc == nil || loc.Fn == nil || loc.Fn.Name != "main.child" {
+			t.Fatalf("child stopped at the wrong location %#v", loc)
+		}
+
+		err = child.Continue()
+		if pe, ok := err.(proc.ErrProcessExited); !ok || pe.Status != 2 {
+			t.Fatalf("expected child to exit with status 2, got %v", err)
+		}
+		err = p.Continue()
+		if pe, ok := err.(proc.ErrProcessExited); !ok || pe.Status != 0 {
+			t.Fatalf("expected parent to exit with status 0, got %v", err)
+		}
+	})
+}
+
+func TestMemoryCacheInvalidation(t *testing.T) {
+	if testBackend != "native" {
+		t.Skip("memory cache is only used by the native backend")
+	}
+	withTestProcess("callme", t, func(p *proc.Target, fixture protest.Fixture) {
+		setFunctionBreakpoint(p, t, "main.callme2")
+		assertNoError(p.Continue(), t, "Continue")
+		addr := evalVariable(p, t, "zeroarr").Addr
+		mem := p.Memory()
+
+		assertMemory := func(expected []byte) {
+			t.Helper()
+			buf := make([]byte, len(expected))
+			_, err := mem.ReadMemory(buf, addr)
+			assertNoError(err, t, "ReadMemory")
+			if !bytes.Equal(buf, expected) {
+				t.Fatalf("wrong memory contents %q, expected %q", buf, expected)
+			}
+		}
+
+		assertMemory(make([]byte, 10))
+
+		// writing must invalidate the cached page
+		_, err := mem.WriteMemory(addr, []byte{'1'})
+		assertNoError(err, t, "WriteMemory")
+		assertMemory(append([]byte{'1'}, make([]byte, 9)...))
+
+		// and so must resuming the target, callme2 overwrites zeroarr before
+		// calling fmt.Println
+		setFunctionBreakpoint(p, t, "fmt.Println")
+		assertNoError(p.Continue(), t, "Continue")
+		assertMemory(bytes.Repeat([]byte{'0'}, 10))
+	})
+}
+
+func TestAttachSeize(t *testing.T) {
+	if testBackend != "native" {
+		t.Skip("test only for the native backend")
+	}
+	var buildFlags protest.BuildFlags
+	if buildMode == "pie" {
+		buildFlags |= protest.BuildModePIE
+	}
+	fixture := protest.BuildFixture("loopprog", buildFlags)
+
+	// procState returns the state of pid as reported by /proc/pid/stat.
+	procState := func(t *testing.T, pid int) byte {
+		t.Helper()
+		buf, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		assertNoError(err, t, "ReadFile")
+		i := bytes.LastIndexByte(buf, ')')
+		return buf[i+2]
+	}
+
+	// waitState waits for pid to enter (or leave, if !in) state.
+	waitState := func(t *testing.T, pid int, state byte, in bool) {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			if (procState(t, pid) == state) == in {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatalf("process %d in wrong state %c", pid, procState(t, pid))
+	}
+
+	for _, stopped := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stopped=%v", stopped), func(t *testing.T) {
+			cmd := exec.Command(fixture.Path)
+			assertNoError(cmd.Start(), t, "starting fixture")
+			defer cmd.Wait()
+			defer cmd.Process.Kill()
+			pid := cmd.Process.Pid
+
+			if stopped {
+				assertNoError(syscall.Kill(pid, syscall.SIGSTOP), t, "Kill")
+				waitState(t, pid, 'T', true)
+			}
+
+			p, err := native.Attach(pid, []string{})
+			assertNoError(err, t, "Attach")
+			assertNoError(p.StepInstruction(), t, "StepInstruction")
+			assertNoError(p.Detach(false), t, "Detach")
+
+			// the process must be left as it was before attaching
+			time.Sleep(100 * time.Millisecond)
+			if s := procState(t, pid); (s == 'T') != stopped {
+				t.Fatalf("process in state %c after detach", s)
+			}
+			if stopped {
+				assertNoError(syscall.Kill(pid, syscall.SIGCONT), t, "Kill")
+				waitState(t, pid, 'T', false)
+			}
+		})
+	}
+}
@@ -102,9 +102,9 @@ func withTestProcessArgs(name string, t testing.TB, wd string, args []string, bu
 
 	switch testBackend {
 	case "native":
-		p, err = native.Launch(append([]string{fixture.Path}, args...), wd, 0, []string{}, "", [3]string{})
+		p, err = native.Launch(append([]string{fixture.Path}, args...), wd, nil, 0, []string{}, "", [3]string{})
 	case "lldb":
-		p, err = gdbserial.LLDBLaunch(append([]string{fixture.Path}, args...), wd, 0, []string{}, "", [3]string{})
+		p, err = gdbserial.LLDBLaunch(append([]string{fixture.Path}, args...), wd, nil, 0, []string{}, "", [3]string{})
 	case "rr":
 		protest.MustHaveRecordingAllowed(t)
 		t.Log("recording")
@@ -897,6 +897,35 @@ func TestStacktrace(t *testing.T) {
 	})
 }
 
+func TestStacktraceWithoutDWARF(t *testing.T) {
+	// Executables built with -ldflags=-w are loaded from the pclntab,
+	// breakpoints and stacktraces still work but variables are not
+	// available.
+	stack := []loc{{4, "main.stacktraceme"}, {8, "main.func1"}, {16, "main.main"}}
+	withTestProcessArgs("stacktraceprog", t, ".", []string{}, protest.LinkDisableDWARF, func(p *proc.Target, fixture protest.Fixture) {
+		if !p.BinInfo().Images[0].SymbolsOnly() {
+			t.Fatal("executable was not loaded from the pclntab")
+		}
+		setFunctionBreakpoint(p, t, "main.stacktraceme")
+		assertNoError(p.Continue(), t, "Continue()")
+		locations, err := proc.ThreadStacktrace(p.CurrentThread(), 40)
+		assertNoError(err, t, "Stacktrace()")
+		if !stackMatch(stack, locations, false) {
+			for i := range locations {
+				t.Logf("\t%s:%d [%s]\n", locations[i].Call.File, locations[i].Call.Line, locations[i].Call.Fn.Name)
+			}
+			t.Fatalf("Stack error at main.stacktraceme()\n%v\n", locations)
+		}
+
+		scope, err := proc.ThreadScope(p, p.CurrentThread())
+		assertNoError(err, t, "ThreadScope()")
+		_, err = scope.EvalExpression("f", normalLoadConfig)
+		if err == nil || !strings.Contains(err.Error(), "built without debug info") {
+			t.Errorf("wrong error evaluating a variable: %v", err)
+		}
+	})
+}
+
 func TestStacktrace2(t *testing.T) {
 	withTestProcess("retstack", t, func(p *proc.Target, fixture protest.Fixture) {
 		assertNoError(p.Continue(), t, "Continue()")
@@ -2211,9 +2240,9 @@ func TestUnsupportedArch(t *testing.T) {
 
 	switch testBackend {
 	case "native":
-		p, err = native.Launch([]string{outfile}, ".", 0, []string{}, "", [3]string{})
+		p, err = native.Launch([]string{outfile}, ".", nil, 0, []string{}, "", [3]string{})
 	case "lldb":
-		p, err = gdbserial.LLDBLaunch([]string{outfile}, ".", 0, []string{}, "", [3]string{})
+		p, err = gdbserial.LLDBLaunch([]string{outfile}, ".", nil, 0, []string{}, "", [3]string{})
 	default:
 		t.Skip("test not valid for this backend")
 	}
@@ -3778,6 +3807,22 @@ func TestDisassembleGlobalVars(t *testing.T) {
 	})
 }
 
+func TestDisassembleRaw(t *testing.T) {
+	withTestProcess("teststepconcurrent", t, func(p *proc.Target, fixture protest.Fixture) {
+		mainfn := p.BinInfo().LookupFunc["main.main"]
+		regs, _ := p.CurrentThread().Registers()
+		text, err := proc.DisassembleRaw(p.Memory(), regs, p.Breakpoints(), p.BinInfo(), mainfn.Entry, mainfn.End)
+		assertNoError(err, t, "DisassembleRaw")
+		if len(text) == 0 || text[0].Loc.PC != mainfn.Entry {
+			t.Fatalf("wrong disassembly of main.main: %v", text)
+		}
+		_, err = proc.DisassembleRaw(p.Memory(), regs, p.Breakpoints(), p.BinInfo(), mainfn.Entry, mainfn.Entry+1<<40)
+		if err == nil {
+			t.Fatal("disassembling a 1TB range did not return an error")
+		}
+	})
+}
+
 func checkFrame(frame proc.Stackframe, fnname, file string, line int, inlined bool) error {
 	if frame.Call.Fn == nil || frame.Call.Fn.Name != fnname {
 		return fmt.Errorf("wrong function name: %s", fnname)
@@ -4127,6 +4172,15 @@ func TestMapLoadConfigWithReslice(t *testing.T) {
 		if len(m1cont.Children) != 20 {
 			t.Fatalf("wrong number of children returned %d\n", len(m1cont.Children)/2)
 		}
+
+		m1page, err := scope.EvalExpression("m1[10:15]", zolotovLoadCfg)
+		assertNoError(err, t, "EvalVariable(m1[10:15])")
+		if len(m1page.Children) != 10 {
+			t.Fatalf("wrong number of children returned for m1[10:15] %d\n", len(m1page.Children)/2)
+		}
+		if m1page.Children[0].Value != m1cont.Children[0].Value {
+			t.Fatalf("wrong first key for m1[10:15] %v %v\n", m1page.Children[0].Value, m1cont.Children[0].Value)
+		}
 	})
 }
 
@@ -4211,6 +4265,37 @@ func TestIssue1264(t *testing.T) {
 	})
 }
 
+func TestDeferCapturedVars(t *testing.T) {
+	if !goversion.VersionAfterOrEqual(runtime.Version(), 1, 23) {
+		t.Skip("closure offsets not available")
+	}
+	withTestProcess("defercapture", t, func(p *proc.Target, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue")
+		frames, err := p.SelectedGoroutine().Stacktrace(10, proc.StacktraceReadDefers)
+		assertNoError(err, t, "Stacktrace")
+		var d *proc.Defer
+		for i := range frames {
+			if len(frames[i].Defers) > 0 {
+				d = frames[i].Defers[0]
+				break
+			}
+		}
+		if d == nil {
+			t.Fatal("no deferred call found")
+		}
+		vars, err := d.CapturedVars(p, normalLoadConfig)
+		assertNoError(err, t, "CapturedVars")
+		found := map[string]string{}
+		for _, v := range vars {
+			found[v.Name] = api.ConvertVar(v).SinglelineString()
+		}
+		t.Logf("%v", found)
+		if found["n"] != "2" || found["s"] != `"hello"` {
+			t.Errorf("wrong captured variables %v", found)
+		}
+	})
+}
+
 func TestReadDefer(t *testing.T) {
 	withTestProcess("deferstack", t, func(p *proc.Target, fixture protest.Fixture) {
 		assertNoError(p.Continue(), t, "Continue")
@@ -4406,6 +4491,40 @@ func TestGoroutinesInfoLimit(t *testing.T) {
 	})
 }
 
+func TestGoroutinesCount(t *testing.T) {
+	withTestProcess("teststepconcurrent", t, func(p *proc.Target, fixture protest.Fixture) {
+		setFunctionBreakpoint(p, t, "main.Foo")
+		assertNoError(p.Continue(), t, "Continue()")
+
+		// Counting before any goroutine is read only reads their status.
+		n, err := proc.GoroutinesCount(p, 0)
+		assertNoError(err, t, "GoroutinesCount(0)")
+
+		page, nextg, err := proc.GoroutinesInfo(p, 0, 3)
+		assertNoError(err, t, "GoroutinesInfo(0, 3)")
+		if nextg < 0 {
+			t.Fatalf("expected more than 3 goroutines")
+		}
+		rest, err := proc.GoroutinesCount(p, nextg)
+		assertNoError(err, t, fmt.Sprintf("GoroutinesCount(%d)", nextg))
+		if len(page)+rest != n {
+			t.Errorf("mismatch in the number of goroutines: %d+%d, expected %d", len(page), rest, n)
+		}
+
+		gs, _, err := proc.GoroutinesInfo(p, 0, 0)
+		assertNoError(err, t, "GoroutinesInfo(0, 0)")
+		if len(gs) != n {
+			t.Fatalf("mismatch in the number of goroutines: %d, expected %d", len(gs), n)
+		}
+		// The goroutines of the first page are not read again.
+		for i := range page {
+			if gs[i] != page[i] {
+				t.Errorf("goroutine %d was read again", page[i].ID)
+			}
+		}
+	})
+}
+
 func TestIssue1469(t *testing.T) {
 	withTestProcess("issue1469", t, func(p *proc.Target, fixture protest.Fixture) {
 		setFileBreakpoint(p, t, fixture.Source, 13)
@@ -4454,6 +4573,74 @@ func TestDeadlockBreakpoint(t *testing.T) {
 	})
 }
 
+func TestFatalThrowMessage(t *testing.T) {
+	// Since Go 1.20 deadlocks are reported by runtime.fatal instead of
+	// runtime.throw.
+	skipOn(t, "upstream issue - https://github.com/golang/go/issues/29322", "pie")
+	protest.AllowRecording(t)
+	withTestProcess("testdeadlock", t, func(p *proc.Target, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue()")
+
+		bp := p.CurrentThread().Breakpoint()
+		if bp.Breakpoint == nil || bp.Name != proc.FatalThrow {
+			t.Fatalf("did not stop at fatal throw breakpoint %v", bp)
+		}
+		if len(bp.Variables) != 1 {
+			t.Fatalf("wrong breakpoint variables %v", bp.Variables)
+		}
+		// There is no goroutine during a deadlock.
+		scope, err := proc.ThreadScope(p, p.CurrentThread())
+		assertNoError(err, t, "ThreadScope")
+		v, err := scope.EvalExpression(bp.Variables[0], normalLoadConfig)
+		assertNoError(err, t, "EvalExpression")
+		if v.Unreadable != nil {
+			t.Fatalf("message unreadable: %v", v.Unreadable)
+		}
+		if msg := constant.StringVal(v.Value); !strings.Contains(msg, "deadlock") {
+			t.Errorf("wrong message %q", msg)
+		}
+	})
+}
+
+func TestGoroutinePanics(t *testing.T) {
+	protest.AllowRecording(t)
+	withTestProcess("repanic", t, func(p *proc.Target, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue()")
+		bp := p.CurrentThread().Breakpoint()
+		if bp.Breakpoint == nil || bp.Name != proc.UnrecoveredPanic {
+			t.Fatalf("did not stop at unrecovered panic breakpoint %v", bp)
+		}
+		panics, err := p.GoroutinePanics(p.SelectedGoroutine(), normalLoadConfig)
+		assertNoError(err, t, "GoroutinePanics")
+		if len(panics) != 2 {
+			t.Fatalf("wrong number of panics %d", len(panics))
+		}
+		for i, tc := range []struct {
+			value     string
+			recovered bool
+			fn        string
+			line      int
+		}{
+			{`"panic while recovering: {42 BOOM!}"`, false, "main.main.func1", 13},
+			{`main.panicError {code: 42, msg: "BOOM!"}`, true, "main.main", 15},
+		} {
+			pnc := panics[i]
+			if len(pnc.Value.Children) != 1 {
+				t.Fatalf("panic %d: wrong value %v", i, pnc.Value)
+			}
+			if value := api.ConvertVar(&pnc.Value.Children[0]).SinglelineString(); value != tc.value {
+				t.Errorf("panic %d: wrong value %s (expected %s)", i, value, tc.value)
+			}
+			if pnc.Recovered != tc.recovered {
+				t.Errorf("panic %d: wrong recovered %v", i, pnc.Recovered)
+			}
+			if pnc.Frame < 0 || pnc.Location.Fn == nil || pnc.Location.Fn.Name != tc.fn || pnc.Location.Line != tc.line {
+				t.Errorf("panic %d: wrong location %d %s:%d", i, pnc.Frame, pnc.Location.File, pnc.Location.Line)
+			}
+		}
+	})
+}
+
 func findSource(source string, sources []string) bool {
 	for _, s := range sources {
 		if s == source {
@@ -4675,6 +4862,71 @@ func TestCgoStacktrace2(t *testing.T) {
 	})
 }
 
+func TestCgoStacktraceSharedObject(t *testing.T) {
+	skipUnlessOn(t, "linux only", "linux")
+	skipUnlessOn(t, "amd64 only", "amd64")
+	protest.MustHaveCgo(t)
+	// The stacktrace of a Go function called back from a C function of a
+	// shared object without debug info (qsort in the C library) should unwind
+	// through the C frames, using .eh_frame, and back into Go.
+	withTestProcess("cgoqsort", t, func(p *proc.Target, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue()")
+		frames, err := proc.ThreadStacktrace(p.CurrentThread(), 100)
+		assertNoError(err, t, "Stacktrace()")
+		logStacktrace(t, p, frames)
+		m := stacktraceCheck(t, []string{"main.goCompare", "C.compare", "C.sortInts", "main.main"}, frames)
+		if m == nil {
+			t.Fatal("see previous loglines")
+		}
+		found := false
+		for _, frame := range frames[m[1]:m[2]] {
+			if frame.Current.Fn != nil {
+				continue
+			}
+			name, _ := p.BinInfo().PCToSymbol(frame.Current.PC)
+			t.Logf("%#x %s", frame.Current.PC, name)
+			if strings.Contains(name, "qsort") {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatal("could not find qsort frame")
+		}
+	})
+}
+
+func TestStackframeMarkers(t *testing.T) {
+	withTestProcess("defermarkers", t, func(p *proc.Target, fixture protest.Fixture) {
+		findMarked := func(markers proc.StackframeMarkers) *proc.Stackframe {
+			t.Helper()
+			frames, err := proc.ThreadStacktrace(p.CurrentThread(), 20)
+			assertNoError(err, t, "Stacktrace")
+			logStacktrace(t, p, frames)
+			for i := range frames {
+				if frames[i].Markers&markers != 0 {
+					return &frames[i]
+				}
+			}
+			t.Fatalf("no frame with markers %#x", markers)
+			return nil
+		}
+		checkFn := func(frame *proc.Stackframe, name string) {
+			t.Helper()
+			if frame.Current.Fn == nil || frame.Current.Fn.Name != name {
+				t.Errorf("wrong marked frame %v (expected %s)", frame.Current.Fn, name)
+			}
+		}
+
+		assertNoError(p.Continue(), t, "Continue 1")
+		checkFn(findMarked(proc.StackframeDeferCall), "main.loopDefers.func1")
+		checkFn(findMarked(proc.StackframeRunningDefers), "main.loopDefers")
+
+		assertNoError(p.Continue(), t, "Continue 2")
+		checkFn(findMarked(proc.StackframePanicDeferCall), "main.panicDefers.func1")
+		checkFn(findMarked(proc.StackframePanicking), "main.panicDefers")
+	})
+}
+
 func TestIssue1656(t *testing.T) {
 	skipUnlessOn(t, "amd64 only", "amd64")
 	withTestProcess("issue1656/", t, func(p *proc.Target, fixture protest.Fixture) {
@@ -5349,6 +5601,114 @@ func TestDump(t *testing.T) {
 			defer os.Remove(corePathPlatIndep)
 			testDump(p, c2)
 		}
+
+		corePathNative := filepath.Join(fixture.BuildDir, "coredump-native")
+		switch {
+		case runtime.GOOS == "windows" && runtime.GOARCH == "amd64":
+			t.Logf("testing minidump")
+			c3 := makeDump(p, corePathNative, fixture.Path, proc.DumpNativeFormat)
+			defer os.Remove(corePathNative)
+			testDump(p, c3)
+
+		case runtime.GOOS == "darwin":
+			t.Logf("testing Mach-O core")
+			c3 := makeDump(p, corePathNative, fixture.Path, proc.DumpNativeFormat)
+			defer os.Remove(corePathNative)
+			testDump(p, c3)
+		}
+	})
+}
+
+func TestDumpMinidumpMachO(t *testing.T) {
+	// Checks that minidumps and Mach-O core files written by Delve can be
+	// read back, regardless of the OS of the target.
+	if runtime.GOOS == "freebsd" || (runtime.GOOS == "darwin" && testBackend == "native") {
+		t.Skip("not supported")
+	}
+	if runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+		t.Skip("not supported")
+	}
+
+	frames := func(p *proc.Target) []string {
+		g, err := proc.GetG(p.CurrentThread())
+		assertNoError(err, t, "GetG()")
+		stack, err := g.Stacktrace(20, 0)
+		assertNoError(err, t, "Stacktrace()")
+		r := []string{fmt.Sprintf("goroutine %d", g.ID)}
+		for _, frame := range stack {
+			fnname := ""
+			if frame.Call.Fn != nil {
+				fnname = frame.Call.Fn.Name
+			}
+			r = append(r, fmt.Sprintf("%#x %s", frame.Call.PC, fnname))
+		}
+		return r
+	}
+
+	withTestProcess("testvariables2", t, func(p *proc.Target, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue()")
+
+		for _, tc := range []struct {
+			name  string
+			flags proc.DumpFlags
+		}{
+			{"minidump", proc.DumpMinidump},
+			{"macho", proc.DumpMachO},
+		} {
+			if tc.flags == proc.DumpMinidump && runtime.GOARCH != "amd64" {
+				continue
+			}
+			t.Run(tc.name, func(t *testing.T) {
+				corePath := filepath.Join(fixture.BuildDir, "coredump-"+tc.name)
+				fh, err := os.Create(corePath)
+				assertNoError(err, t, "Create()")
+				defer os.Remove(corePath)
+				var state proc.DumpState
+				p.Dump(fh, tc.flags, &state)
+				assertNoError(state.Err, t, "Dump()")
+				if state.ThreadsDone != state.ThreadsTotal || state.MemDone != state.MemTotal || !state.AllDone {
+					t.Fatalf("bad DumpState %#v", &state)
+				}
+				c, err := core.OpenCore(corePath, fixture.Path, nil)
+				assertNoError(err, t, "OpenCore()")
+
+				if c.Pid() != p.Pid() {
+					t.Errorf("pid mismatch %d %d", p.Pid(), c.Pid())
+				}
+				if c.BinInfo().GOOS != p.BinInfo().GOOS {
+					t.Errorf("GOOS mismatch %s %s", p.BinInfo().GOOS, c.BinInfo().GOOS)
+				}
+
+				threads, cthreads := map[int]string{}, map[int]string{}
+				for _, th := range p.ThreadList() {
+					regs, err := th.Registers()
+					assertNoError(err, t, "Registers()")
+					threads[th.ThreadID()] = fmt.Sprintf("pc=%#x sp=%#x", regs.PC(), regs.SP())
+				}
+				for _, th := range c.ThreadList() {
+					regs, err := th.Registers()
+					assertNoError(err, t, "Registers() - core")
+					cthreads[th.ThreadID()] = fmt.Sprintf("pc=%#x sp=%#x", regs.PC(), regs.SP())
+				}
+				if !reflect.DeepEqual(threads, cthreads) {
+					t.Errorf("threads mismatch\nlive:\t%v\ncore:\t%v", threads, cthreads)
+				}
+
+				buf, cbuf := make([]byte, 64), make([]byte, 64)
+				regs, _ := p.CurrentThread().Registers()
+				_, err = p.Memory().ReadMemory(buf, regs.SP())
+				assertNoError(err, t, "ReadMemory()")
+				_, err = c.Memory().ReadMemory(cbuf, regs.SP())
+				assertNoError(err, t, "ReadMemory() - core")
+				if !bytes.Equal(buf, cbuf) {
+					t.Errorf("memory mismatch at %#x\nlive:\t%x\ncore:\t%x", regs.SP(), buf, cbuf)
+				}
+
+				if stack, cstack := frames(p), frames(c); !reflect.DeepEqual(stack, cstack) {
+					t.Errorf("stacktrace mismatch\nlive:\t%v\ncore:\t%v", stack, cstack)
+				}
+			})
+		}
 	})
 }
 
@@ -5803,6 +6163,70 @@ func TestSetYMMRegister(t *testing.T) {
 	})
 }
 
+func TestSetRegisterLane(t *testing.T) {
+	skipUnlessOn(t, "N/A", "linux", "amd64")
+	if testBackend != "native" {
+		t.Skip("only supported by the native backend")
+	}
+	withTestProcess("testvariables2", t, func(p *proc.Target, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue()")
+
+		_, err := proc.SetRegister(p, p.CurrentThread(), "xmm0.u64[1]", "0xff")
+		assertNoError(err, t, "SetRegister(xmm0.u64[1])")
+		_, err = proc.SetRegister(p, p.CurrentThread(), "XMM0.float64[0]", "1.5")
+		assertNoError(err, t, "SetRegister(XMM0.float64[0])")
+		p.ClearCaches()
+
+		xmm0 := evalVariable(p, t, "XMM0.uint64")
+		if len(xmm0.Children) < 2 || constant.Compare(xmm0.Children[1].Value, token.NEQ, constant.MakeUint64(0xff)) {
+			t.Errorf("wrong value of XMM0.uint64: %v", xmm0.Children)
+		}
+		xmm0 = evalVariable(p, t, "XMM0.f64")
+		if len(xmm0.Children) < 1 || constant.Compare(xmm0.Children[0].Value, token.NEQ, constant.MakeFloat64(1.5)) {
+			t.Errorf("wrong value of XMM0.f64: %v", xmm0.Children)
+		}
+
+		for _, expr := range []string{"XMM0", "XMM0.uint64", "XMM0.uint64[100]", "XMM0.complex64[0]", "NOTAREG"} {
+			if _, err := proc.SetRegister(p, p.CurrentThread(), expr, "1"); err == nil {
+				t.Errorf("SetRegister(%s) did not return an error", expr)
+			}
+		}
+	})
+}
+
+func TestJump(t *testing.T) {
+	protest.AllowRecording(t)
+	withTestProcess("testnextprog", t, func(p *proc.Target, fixture protest.Fixture) {
+		setFileBreakpoint(p, t, fixture.Source, 34)
+		assertNoError(p.Continue(), t, "Continue()")
+		assertLineNumber(p, t, 34, "Continue()")
+
+		assertNoError(p.Jump(findFileLocation(p, t, fixture.Source, 24)), t, "Jump(24)")
+		assertLineNumber(p, t, 24, "Jump(24)")
+		if bp := p.CurrentThread().Breakpoint(); bp.Breakpoint != nil {
+			t.Errorf("thread still stopped at breakpoint %d after Jump", bp.Breakpoint.LogicalID())
+		}
+
+		// Jumping back to the breakpoint restores the breakpoint state, so that
+		// Continue steps over it.
+		assertNoError(p.Jump(findFileLocation(p, t, fixture.Source, 34)), t, "Jump(34)")
+		if bp := p.CurrentThread().Breakpoint(); bp.Breakpoint == nil {
+			t.Errorf("thread not stopped at breakpoint after Jump(34)")
+		}
+
+		for _, pc := range []uint64{
+			findFunctionLocation(p, t, "main.helloworld"),  // different function
+			findFunctionLocation(p, t, "main.testnext"),    // prologue
+			findFileLocation(p, t, fixture.Source, 24) + 1, // not a statement
+		} {
+			if err := p.Jump(pc); err == nil {
+				t.Errorf("Jump(%#x) did not return an error", pc)
+			}
+		}
+		assertLineNumber(p, t, 34, "failed Jump")
+	})
+}
+
 func TestNilPtrDerefInBreakInstr(t *testing.T) {
 	// Checks that having a breakpoint on the exact instruction that causes a
 	// nil pointer dereference does not cause problems.
@@ -5908,3 +6332,155 @@ func TestCallInjectionFlagCorruption(t *testing.T) {
 		assertLineNumber(p, t, 17, "expected line :17") // since we passed "0" as argument we should be going into the false branch at line :17
 	})
 }
+
+func TestGoroutineStacktraces(t *testing.T) {
+	// GoroutineStacktraces must return the same stacktraces as
+	// G.Stacktrace, both when unwinding and when using cached results.
+	protest.AllowRecording(t)
+	withTestProcess("goroutinestackprog", t, func(p *proc.Target, fixture protest.Fixture) {
+		setFunctionBreakpoint(p, t, "main.stacktraceme")
+		assertNoError(p.Continue(), t, "Continue()")
+
+		gs, _, err := proc.GoroutinesInfo(p, 0, 0)
+		assertNoError(err, t, "GoroutinesInfo")
+
+		for pass := 0; pass < 2; pass++ {
+			n := 0
+			proc.GoroutineStacktraces(p, gs, 40, 0, func(i int, frames []proc.Stackframe, err error) bool {
+				n++
+				expected, experr := gs[i].Stacktrace(40, 0)
+				if (err != nil) != (experr != nil) {
+					t.Errorf("pass %d goroutine %d: error mismatch %v %v", pass, gs[i].ID, err, experr)
+					return true
+				}
+				if len(frames) != len(expected) {
+					t.Errorf("pass %d goroutine %d: got %d frames, expected %d", pass, gs[i].ID, len(frames), len(expected))
+					return true
+				}
+				for j := range frames {
+					if frames[j].Call.PC != expected[j].Call.PC || frames[j].Call.Line != expected[j].Call.Line {
+						t.Errorf("pass %d goroutine %d frame %d: got %#x:%d expected %#x:%d", pass, gs[i].ID, j, frames[j].Call.PC, frames[j].Call.Line, expected[j].Call.PC, expected[j].Call.Line)
+					}
+				}
+				return true
+			})
+			if n != len(gs) {
+				t.Errorf("pass %d: got %d stacktraces, expected %d", pass, n, len(gs))
+			}
+		}
+
+		n := 0
+		proc.GoroutineStacktraces(p, gs, 40, 0, func(i int, frames []proc.Stackframe, err error) bool {
+			n++
+			return false
+		})
+		if n != 1 {
+			t.Errorf("stacktraces returned after stopping: %d", n)
+		}
+	})
+}
+
+// startFns returns the names of the functions that started goroutines gs,
+// sorted.
+func startFns(p *proc.Target, gs []*proc.G) []string {
+	r := make([]string, len(gs))
+	for i, g := range gs {
+		if loc := g.StartLoc(p); loc.Fn != nil {
+			r[i] = loc.Fn.Name
+		}
+	}
+	sort.Strings(r)
+	return r
+}
+
+func containsString(strs []string, s string) bool {
+	for _, x := range strs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestChanInfo(t *testing.T) {
+	withTestProcess("chanstate", t, func(p *proc.Target, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue")
+
+		ci, err := p.ChanInfo(evalVariable(p, t, "buffered"), normalLoadConfig)
+		assertNoError(err, t, "ChanInfo(buffered)")
+		if ci.Len != 4 || ci.Cap != 4 || ci.Closed {
+			t.Errorf("wrong state of buffered: len %d cap %d closed %v", ci.Len, ci.Cap, ci.Closed)
+		}
+		// the buffer wraps around, the elements must still be in the order
+		// they will be received
+		var buf []string
+		for _, v := range ci.Buffer {
+			buf = append(buf, v.Value.String())
+		}
+		if fmt.Sprint(buf) != "[2 3 4 5]" {
+			t.Errorf("wrong buffer of buffered: %v", buf)
+		}
+		if fns := startFns(p, ci.Senders); fmt.Sprint(fns) != "[main.send]" || len(ci.Receivers) != 0 {
+			t.Errorf("wrong waiters of buffered: senders %v receivers %d", fns, len(ci.Receivers))
+		}
+
+		ci, err = p.ChanInfo(evalVariable(p, t, "unbuffered"), normalLoadConfig)
+		assertNoError(err, t, "ChanInfo(unbuffered)")
+		if ci.Len != 0 || ci.Cap != 0 || len(ci.Buffer) != 0 {
+			t.Errorf("wrong state of unbuffered: len %d cap %d buffer %d", ci.Len, ci.Cap, len(ci.Buffer))
+		}
+		if fns := startFns(p, ci.Receivers); fmt.Sprint(fns) != "[main.recv]" || len(ci.Senders) != 0 {
+			t.Errorf("wrong waiters of unbuffered: receivers %v senders %d", fns, len(ci.Senders))
+		}
+
+		ci, err = p.ChanInfo(evalVariable(p, t, "closed"), normalLoadConfig)
+		assertNoError(err, t, "ChanInfo(closed)")
+		if ci.Len != 0 || ci.Cap != 1 || !ci.Closed {
+			t.Errorf("wrong state of closed: len %d cap %d closed %v", ci.Len, ci.Cap, ci.Closed)
+		}
+	})
+}
+
+func TestMutexInfo(t *testing.T) {
+	withTestProcess("mutexstate", t, func(p *proc.Target, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue")
+
+		mi, err := p.MutexInfo(evalVariable(p, t, "s.mu"))
+		assertNoError(err, t, "MutexInfo(s.mu)")
+		if mi.RW || !mi.Locked {
+			t.Errorf("wrong state of s.mu: rw %v locked %v", mi.RW, mi.Locked)
+		}
+		if fns := startFns(p, mi.Waiters); fmt.Sprint(fns) != "[main.(*store).get main.(*store).get]" {
+			t.Errorf("wrong waiters of s.mu: %v", fns)
+		}
+		// the main goroutine also has a pointer to s.mu in its local
+		// variables and is reported as a possible holder, the waiters must
+		// not be.
+		if fns := startFns(p, mi.Holders); !containsString(fns, "main.(*store).hold") || containsString(fns, "main.(*store).get") {
+			t.Errorf("wrong holders of s.mu: %v", fns)
+		}
+
+		// a pointer to a sync.RWMutex, held for reading while a writer waits
+		mi, err = p.MutexInfo(evalVariable(p, t, "rw"))
+		assertNoError(err, t, "MutexInfo(rw)")
+		if !mi.RW || !mi.Locked || mi.Readers != 1 {
+			t.Errorf("wrong state of rw: rw %v locked %v readers %d", mi.RW, mi.Locked, mi.Readers)
+		}
+		if fns := startFns(p, mi.Waiters); fmt.Sprint(fns) != "[main.writeLock]" || len(mi.ReadWaiters) != 0 {
+			t.Errorf("wrong waiters of rw: %v, read waiters %d", fns, len(mi.ReadWaiters))
+		}
+		if fns := startFns(p, mi.Holders); !containsString(fns, "main.readLock") || containsString(fns, "main.writeLock") {
+			t.Errorf("wrong holders of rw: %v", fns)
+		}
+
+		mi, err = p.MutexInfo(evalVariable(p, t, "free"))
+		assertNoError(err, t, "MutexInfo(free)")
+		if mi.Locked || len(mi.Waiters) != 0 || len(mi.Holders) != 0 {
+			t.Errorf("wrong state of free: locked %v waiters %d holders %d", mi.Locked, len(mi.Waiters), len(mi.Holders))
+		}
+
+		if _, err := p.MutexInfo(evalVariable(p, t, "locked")); err == nil {
+			t.Errorf("MutexInfo of a non-mutex succeeded")
+		}
+	})
+}
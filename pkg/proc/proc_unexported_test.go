@@ -1,7 +1,15 @@
 package proc
 
 import (
+	"bytes"
+	"debug/elf"
+	"os"
+	"reflect"
+	"runtime"
 	"testing"
+
+	"github.com/go-delve/delve/pkg/dwarf/godwarf"
+	"github.com/go-delve/delve/pkg/dwarf/regnum"
 )
 
 func TestAlignAddr(t *testing.T) {
@@ -96,3 +104,105 @@ func TestConvertInt(t *testing.T) {
 		}
 	}
 }
+
+func TestRegABIAssign(t *testing.T) {
+	basic := func(size int64, kind reflect.Kind) godwarf.BasicType {
+		return godwarf.BasicType{CommonType: godwarf.CommonType{ByteSize: size, ReflectKind: kind}}
+	}
+	intType := &godwarf.IntType{BasicType: basic(8, reflect.Int)}
+	int32Type := &godwarf.IntType{BasicType: basic(4, reflect.Int32)}
+	floatType := &godwarf.FloatType{BasicType: basic(8, reflect.Float64)}
+	stringType := &godwarf.StringType{StructType: godwarf.StructType{CommonType: godwarf.CommonType{ByteSize: 16, ReflectKind: reflect.String}}}
+	pairType := &godwarf.StructType{
+		CommonType: godwarf.CommonType{ByteSize: 8, ReflectKind: reflect.Struct},
+		Field: []*godwarf.StructField{
+			{Name: "a", Type: int32Type, ByteOffset: 0},
+			{Name: "b", Type: int32Type, ByteOffset: 4},
+		},
+	}
+	bigType := &godwarf.ArrayType{CommonType: godwarf.CommonType{ByteSize: 16, ReflectKind: reflect.Array}, Type: intType, Count: 2}
+
+	abi := newRegABIAssigner(AMD64Arch("linux"))
+
+	// func(a int, s string, arr [2]int, p pair) (int, string, float64, [2]int)
+	for _, arg := range []godwarf.Type{intType, stringType, bigType, pairType} {
+		abi.assign(arg)
+	}
+	if abi.stackOff != 16 {
+		t.Errorf("wrong size of stack assigned arguments: %d", abi.stackOff)
+	}
+	abi.startResults()
+
+	tgts := []regABIAssignment{
+		{regs: []int{regnum.AMD64_Rax}, readable: true},
+		{regs: []int{regnum.AMD64_Rbx, regnum.AMD64_Rcx}, readable: true},
+		{readable: false},
+		{onStack: true, stackOff: 16, readable: true},
+	}
+	for i, res := range []godwarf.Type{intType, stringType, floatType, bigType} {
+		asg := abi.assign(res)
+		if asg.onStack != tgts[i].onStack || asg.stackOff != tgts[i].stackOff || asg.readable != tgts[i].readable || !reflect.DeepEqual(asg.regs, tgts[i].regs) {
+			t.Errorf("result %d: got %#v expected %#v", i, asg, tgts[i])
+		}
+	}
+
+	abi = newRegABIAssigner(AMD64Arch("linux"))
+	if asg := abi.assign(pairType); asg.onStack || asg.readable || len(asg.regs) != 2 {
+		t.Errorf("struct with sub-word fields: got %#v", asg)
+	}
+}
+
+func TestExecutableMemory(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("ELF executables only")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := elf.Open(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	text := f.Section(".text")
+	tgt := make([]byte, 64)
+	if _, err := text.ReadAt(tgt, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	const staticBase = 0x10000
+	mem, err := newExecutableMemory(&Image{Path: exe, StaticBase: staticBase})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mem.Close()
+	buf := make([]byte, len(tgt))
+	if _, err := mem.ReadMemory(buf, text.Addr+staticBase); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, tgt) {
+		t.Errorf("mismatched text, got %x expected %x", buf, tgt)
+	}
+	if _, err := mem.ReadMemory(buf, text.Addr); err == nil {
+		t.Errorf("read of unmapped address succeeded")
+	}
+}
+
+func TestEstimateGCTrigger(t *testing.T) {
+	const mb = 1 << 20
+	for _, tc := range []struct {
+		goal, heapMarked, runway, tgt uint64
+	}{
+		{8 * mb, 4 * mb, 1 * mb, 7 * mb},
+		{8 * mb, 4 * mb, 3 * mb, 4*mb + 4*mb/64*45}, // clamped to the lower bound
+		{8 * mb, 4 * mb, 9 * mb, 4*mb + 4*mb/64*45}, // runway larger than the goal
+		{8 * mb, 4 * mb, 0, 4*mb + 4*mb/64*61},      // clamped to the upper bound
+		{200 * mb, 8 * mb, 0, 196 * mb},             // upper bound is goal-defaultHeapMinimum
+		{8 * mb, 8 * mb, 1 * mb, 8 * mb},
+	} {
+		if trigger := estimateGCTrigger(tc.goal, tc.heapMarked, tc.runway); trigger != tc.tgt {
+			t.Errorf("estimateGCTrigger(%d, %d, %d) = %d, expected %d", tc.goal, tc.heapMarked, tc.runway, trigger, tc.tgt)
+		}
+	}
+}
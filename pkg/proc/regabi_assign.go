@@ -0,0 +1,143 @@
+package proc
+
+import (
+	"reflect"
+
+	"github.com/go-delve/delve/pkg/dwarf/godwarf"
+	"github.com/go-delve/delve/pkg/dwarf/regnum"
+)
+
+// regABIAssigner assigns arguments and results of a function to registers
+// or to the stack following the register based calling convention, see
+// $GOROOT/src/cmd/compile/abi-internal.md.
+// Only the integer registers are tracked by number, floating point values
+// are counted but can not be read back.
+type regABIAssigner struct {
+	intRegs  []int // DWARF numbers of the integer registers, in assignment order
+	maxFloat int   // number of floating point registers
+	ptrSize  int64
+
+	nint, nfloat int
+	stackOff     int64
+}
+
+// regABIAssignment describes where a value is stored.
+type regABIAssignment struct {
+	// onStack is true if the value was assigned to the stack, at stackOff
+	// bytes from the start of the argument area.
+	onStack  bool
+	stackOff int64
+	// regs are the DWARF numbers of the integer registers the value was
+	// assigned to.
+	regs []int
+	// readable is false if the value is stored in registers in a way that
+	// can not be reconstructed by copying each register into consecutive
+	// words of memory, for example because it has floating point fields or
+	// fields smaller than a word.
+	readable bool
+}
+
+// newRegABIAssigner returns an assigner for the architecture or nil if the
+// register based calling convention is not known for it.
+func newRegABIAssigner(arch *Arch) *regABIAssigner {
+	switch arch.Name {
+	case "amd64":
+		return &regABIAssigner{
+			intRegs:  []int{regnum.AMD64_Rax, regnum.AMD64_Rbx, regnum.AMD64_Rcx, regnum.AMD64_Rdi, regnum.AMD64_Rsi, regnum.AMD64_R8, regnum.AMD64_R9, regnum.AMD64_R10, regnum.AMD64_R11},
+			maxFloat: 15,
+			ptrSize:  int64(arch.PtrSize()),
+		}
+	case "arm64":
+		intRegs := make([]int, 16)
+		for i := range intRegs {
+			intRegs[i] = regnum.ARM64_X0 + i
+		}
+		return &regABIAssigner{intRegs: intRegs, maxFloat: 16, ptrSize: int64(arch.PtrSize())}
+	}
+	return nil
+}
+
+// startResults must be called after all the arguments have been assigned
+// and before assigning the results: registers are assigned again from the
+// first one and results are stored on the stack after the arguments.
+func (a *regABIAssigner) startResults() {
+	a.nint, a.nfloat = 0, 0
+	a.stackOff = alignAddr(a.stackOff, a.ptrSize)
+}
+
+// assign assigns the next argument or result, of type typ.
+func (a *regABIAssigner) assign(typ godwarf.Type) regABIAssignment {
+	nint, nfloat := a.nint, a.nfloat
+	asg := regABIAssignment{readable: true}
+	if a.regAssign(resolveTypedef(typ), 0, &asg) {
+		return asg
+	}
+	a.nint, a.nfloat = nint, nfloat
+	a.stackOff = alignAddr(a.stackOff, typ.Align())
+	asg = regABIAssignment{onStack: true, stackOff: a.stackOff, readable: true}
+	a.stackOff += typ.Size()
+	return asg
+}
+
+func (a *regABIAssigner) intReg(memOff int64, asg *regABIAssignment) bool {
+	if a.nint >= len(a.intRegs) {
+		return false
+	}
+	if memOff != int64(len(asg.regs))*a.ptrSize {
+		asg.readable = false
+	}
+	asg.regs = append(asg.regs, a.intRegs[a.nint])
+	a.nint++
+	return true
+}
+
+func (a *regABIAssigner) floatReg(asg *regABIAssignment) bool {
+	if a.nfloat >= a.maxFloat {
+		return false
+	}
+	asg.readable = false
+	a.nfloat++
+	return true
+}
+
+func (a *regABIAssigner) regAssign(typ godwarf.Type, memOff int64, asg *regABIAssignment) bool {
+	switch typ.Common().ReflectKind {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Ptr, reflect.UnsafePointer, reflect.Chan, reflect.Map, reflect.Func:
+		if typ.Size() > a.ptrSize {
+			return false
+		}
+		return a.intReg(memOff, asg)
+	case reflect.Float32, reflect.Float64:
+		return a.floatReg(asg)
+	case reflect.Complex64, reflect.Complex128:
+		return a.floatReg(asg) && a.floatReg(asg)
+	case reflect.String, reflect.Interface:
+		return a.intReg(memOff, asg) && a.intReg(memOff+a.ptrSize, asg)
+	case reflect.Slice:
+		return a.intReg(memOff, asg) && a.intReg(memOff+a.ptrSize, asg) && a.intReg(memOff+2*a.ptrSize, asg)
+	case reflect.Struct:
+		styp, ok := typ.(*godwarf.StructType)
+		if !ok {
+			return false
+		}
+		for _, field := range styp.Field {
+			if !a.regAssign(resolveTypedef(field.Type), memOff+field.ByteOffset, asg) {
+				return false
+			}
+		}
+		return true
+	case reflect.Array:
+		atyp, ok := typ.(*godwarf.ArrayType)
+		if !ok {
+			return false
+		}
+		switch atyp.Count {
+		case 0:
+			return true
+		case 1:
+			return a.regAssign(resolveTypedef(atyp.Type), memOff, asg)
+		}
+		return false
+	}
+	return false
+}
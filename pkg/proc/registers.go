@@ -1,9 +1,15 @@
 package proc
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
 	"math"
+	"strconv"
 	"strings"
 
 	"github.com/go-delve/delve/pkg/dwarf/op"
@@ -125,3 +131,206 @@ func (descr flagRegisterDescr) Describe(reg uint64, bitsize int) string {
 	}
 	return fmt.Sprintf("%#0*x\t[%s]", bitsize/4, reg, strings.Join(r, " "))
 }
+
+// registerLaneTypes maps the abbreviations accepted for the types of the
+// lanes of vector registers to the corresponding types.
+var registerLaneTypes = map[string]string{
+	"i8": "int8", "i16": "int16", "i32": "int32", "i64": "int64",
+	"u8": "uint8", "u16": "uint16", "u32": "uint32", "u64": "uint64",
+	"f32": "float32", "f64": "float64",
+}
+
+// RegisterLanes splits the value of a (vector) register into lanes of type
+// typ, which is one of int8, ..., int64, uint8, ..., uint64, float32 and
+// float64 (or i8, ..., u64, f32, f64). Returns the values of the lanes and
+// their size in bytes.
+// Other sizes of integer lanes, for example uint128, are returned as
+// hexadecimal strings.
+func RegisterLanes(b []byte, typ string) (lanes []constant.Value, size int, err error) {
+	if t, ok := registerLaneTypes[typ]; ok {
+		typ = t
+	}
+	size = registerLaneSize(typ)
+	if size == 0 {
+		for _, pfx := range []string{"uint", "int"} {
+			if strings.HasPrefix(typ, pfx) {
+				size, _ = strconv.Atoi(typ[len(pfx):])
+				break
+			}
+		}
+		if size == 0 || popcnt(uint64(size)) != 1 {
+			return nil, 0, fmt.Errorf("unknown CPU register type conversion to %q", typ)
+		}
+		size = size / 8
+	}
+	for i := 0; i+size <= len(b); i += size {
+		var lane constant.Value
+		switch typ {
+		case "int8":
+			lane = constant.MakeInt64(int64(int8(b[i])))
+		case "int16":
+			lane = constant.MakeInt64(int64(int16(binary.LittleEndian.Uint16(b[i:]))))
+		case "int32":
+			lane = constant.MakeInt64(int64(int32(binary.LittleEndian.Uint32(b[i:]))))
+		case "int64":
+			lane = constant.MakeInt64(int64(binary.LittleEndian.Uint64(b[i:])))
+		case "uint8":
+			lane = constant.MakeUint64(uint64(b[i]))
+		case "uint16":
+			lane = constant.MakeUint64(uint64(binary.LittleEndian.Uint16(b[i:])))
+		case "uint32":
+			lane = constant.MakeUint64(uint64(binary.LittleEndian.Uint32(b[i:])))
+		case "uint64":
+			lane = constant.MakeUint64(binary.LittleEndian.Uint64(b[i:]))
+		case "float32":
+			lane = constant.MakeFloat64(float64(math.Float32frombits(binary.LittleEndian.Uint32(b[i:]))))
+		case "float64":
+			lane = constant.MakeFloat64(math.Float64frombits(binary.LittleEndian.Uint64(b[i:])))
+		default:
+			lane = constant.MakeString(fmt.Sprintf("%x", b[i:][:size]))
+		}
+		lanes = append(lanes, lane)
+	}
+	return lanes, size, nil
+}
+
+// registerLaneSize returns the size of lanes of type typ, or 0 if typ is
+// not a basic numeric type.
+func registerLaneSize(typ string) int {
+	switch typ {
+	case "int8", "uint8":
+		return 1
+	case "int16", "uint16":
+		return 2
+	case "int32", "uint32", "float32":
+		return 4
+	case "int64", "uint64", "float64":
+		return 8
+	}
+	return 0
+}
+
+// SetRegister changes the value of a register of thread. Expr is either the
+// name of a register or, to change a single lane of a vector register, the
+// name of the register followed by the type of its lanes and the index of
+// the lane, for example XMM0.uint64[1] or V0.f32[3]. Value is evaluated in
+// the scope of the topmost frame of thread and must be a number.
+// Returns the DWARF register number of the register that was changed.
+func SetRegister(t *Target, thread Thread, expr, value string) (uint64, error) {
+	regname, lanetyp, idx, err := parseRegisterLane(expr)
+	if err != nil {
+		return 0, err
+	}
+	regnum, ok := t.BinInfo().Arch.RegisterNameToDwarf(regname)
+	if !ok {
+		return 0, fmt.Errorf("unknown register %s", regname)
+	}
+	scope, err := ThreadScope(t, thread)
+	if err != nil {
+		return 0, err
+	}
+	reg := scope.Regs.Reg(uint64(regnum))
+	if reg == nil {
+		return 0, fmt.Errorf("register %s is not available", regname)
+	}
+	reg.FillBytes()
+	v, err := scope.EvalExpression(value, loadSingleValue)
+	if err != nil {
+		return 0, err
+	}
+	if v.Unreadable != nil {
+		return 0, v.Unreadable
+	}
+	if v.Value == nil || (v.Value.Kind() != constant.Int && v.Value.Kind() != constant.Float) {
+		return 0, fmt.Errorf("value of register %s must be a number", regname)
+	}
+
+	if lanetyp == "" {
+		if len(reg.Bytes) > 8 {
+			return 0, fmt.Errorf("vector register %s can only be changed one lane at a time, for example %s.uint64[0]", regname, regname)
+		}
+		n, err := constantToUint64(v.Value)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(regnum), thread.SetReg(uint64(regnum), op.DwarfRegisterFromUint64(n))
+	}
+
+	if full, ok := registerLaneTypes[lanetyp]; ok {
+		lanetyp = full
+	}
+	size := registerLaneSize(lanetyp)
+	if size == 0 {
+		return 0, fmt.Errorf("unsupported lane type %q", lanetyp)
+	}
+	if idx < 0 || (idx+1)*size > len(reg.Bytes) {
+		return 0, fmt.Errorf("lane %d of register %s out of range [0, %d)", idx, regname, len(reg.Bytes)/size)
+	}
+	b := make([]byte, len(reg.Bytes))
+	copy(b, reg.Bytes)
+	lane := b[idx*size:][:size]
+	switch lanetyp {
+	case "float32":
+		f, _ := constant.Float64Val(constant.ToFloat(v.Value))
+		binary.LittleEndian.PutUint32(lane, math.Float32bits(float32(f)))
+	case "float64":
+		f, _ := constant.Float64Val(constant.ToFloat(v.Value))
+		binary.LittleEndian.PutUint64(lane, math.Float64bits(f))
+	default:
+		n, err := constantToUint64(v.Value)
+		if err != nil {
+			return 0, err
+		}
+		for i := range lane {
+			lane[i] = byte(n >> (8 * i))
+		}
+	}
+	return uint64(regnum), thread.SetReg(uint64(regnum), op.DwarfRegisterFromBytes(b))
+}
+
+// parseRegisterLane parses expressions of the form REG, REG.type and
+// REG.type[idx], idx is -1 if it isn't specified.
+func parseRegisterLane(expr string) (regname, lanetyp string, idx int, err error) {
+	badExpr := fmt.Errorf("invalid register %q", expr)
+	n, err := parser.ParseExpr(expr)
+	if err != nil {
+		return "", "", 0, badExpr
+	}
+	idx = -1
+	if ie, ok := n.(*ast.IndexExpr); ok {
+		lit, ok := ie.Index.(*ast.BasicLit)
+		if !ok || lit.Kind != token.INT {
+			return "", "", 0, badExpr
+		}
+		idx, err = strconv.Atoi(lit.Value)
+		if err != nil {
+			return "", "", 0, badExpr
+		}
+		n = ie.X
+	}
+	if se, ok := n.(*ast.SelectorExpr); ok {
+		lanetyp = se.Sel.Name
+		n = se.X
+	}
+	id, ok := n.(*ast.Ident)
+	if !ok || (lanetyp != "" && idx < 0) || (lanetyp == "" && idx >= 0) {
+		return "", "", 0, badExpr
+	}
+	return strings.ToUpper(id.Name), lanetyp, idx, nil
+}
+
+// constantToUint64 converts an integer constant, signed or unsigned, to
+// its two's complement representation.
+func constantToUint64(v constant.Value) (uint64, error) {
+	v = constant.ToInt(v)
+	if v.Kind() != constant.Int {
+		return 0, errors.New("value must be an integer")
+	}
+	if n, exact := constant.Uint64Val(v); exact {
+		return n, nil
+	}
+	if n, exact := constant.Int64Val(v); exact {
+		return uint64(n), nil
+	}
+	return 0, errors.New("value does not fit in 64 bits")
+}
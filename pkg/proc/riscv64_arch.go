@@ -0,0 +1,163 @@
+package proc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/go-delve/delve/pkg/dwarf/frame"
+	"github.com/go-delve/delve/pkg/dwarf/op"
+	"github.com/go-delve/delve/pkg/dwarf/regnum"
+)
+
+// riscv64BreakInstruction is C.EBREAK, the compressed encoding is used so
+// that breakpoints can also be set on compressed instructions.
+var riscv64BreakInstruction = []byte{0x02, 0x90}
+
+// RISCV64Arch returns an initialized RISCV64
+// struct.
+func RISCV64Arch(goos string) *Arch {
+	return &Arch{
+		Name:                             "riscv64",
+		ptrSize:                          8,
+		maxInstructionLength:             4,
+		breakpointInstruction:            riscv64BreakInstruction,
+		breakInstrMovesPC:                false,
+		derefTLS:                         false,
+		prologues:                        prologuesRISCV64,
+		fixFrameUnwindContext:            riscv64FixFrameUnwindContext,
+		switchStack:                      riscv64SwitchStack,
+		regSize:                          riscv64RegSize,
+		RegistersToDwarfRegisters:        riscv64RegistersToDwarfRegisters,
+		addrAndStackRegsToDwarfRegisters: riscv64AddrAndStackRegsToDwarfRegisters,
+		DwarfRegisterToString:            riscv64DwarfRegisterToString,
+		inhibitStepInto:                  func(*BinaryInfo, uint64) bool { return false },
+		asmDecode:                        riscv64AsmDecode,
+		usesLR:                           true,
+		PCRegNum:                         regnum.RISCV64_PC,
+		SPRegNum:                         regnum.RISCV64_SP,
+		BPRegNum:                         regnum.RISCV64_BP,
+		ContextRegNum:                    regnum.RISCV64_X0 + 26,
+		LRRegNum:                         regnum.RISCV64_LR,
+		asmRegisters:                     riscv64AsmRegisters,
+		RegisterNameToDwarf:              nameToDwarfFunc(regnum.RISCV64NameToDwarf),
+		maxRegArgBytes:                   16*8 + 16*8, // 16 int argument registers plus 16 float argument registers
+	}
+}
+
+func riscv64FixFrameUnwindContext(fctxt *frame.FrameContext, pc uint64, bi *BinaryInfo) *frame.FrameContext {
+	if fctxt == nil {
+		// Go does not maintain a frame pointer on riscv64, when there's no
+		// frame descriptor entry the best we can do is assume that we are
+		// stopped at the entry point of a function:
+		// - cfa is sp
+		// - the return address is in the link register
+		return &frame.FrameContext{
+			RetAddrReg: regnum.RISCV64_LR,
+			Regs: map[uint64]frame.DWRule{
+				regnum.RISCV64_LR: frame.DWRule{
+					Rule: frame.RuleSameVal,
+				},
+				regnum.RISCV64_SP: frame.DWRule{
+					Rule:   frame.RuleValOffset,
+					Offset: 0,
+				},
+			},
+			CFA: frame.DWRule{
+				Rule:   frame.RuleCFA,
+				Reg:    regnum.RISCV64_SP,
+				Offset: 0,
+			},
+		}
+	}
+
+	return fctxt
+}
+
+// riscv64cgocallSPOffsetSaveSlot is the offset from the system stack
+// pointer where runtime.asmcgocall saves the distance between the
+// goroutine stack pointer and the top of the goroutine stack, see
+// $GOROOT/src/runtime/asm_riscv64.s.
+const riscv64cgocallSPOffsetSaveSlot = 0x8
+
+func riscv64SwitchStack(it *stackIterator, callFrameRegs *op.DwarfRegisters) bool {
+	if it.frame.Current.Fn == nil && it.systemstack && it.g != nil && it.top && !it.unwindUnknownFrame() {
+		it.switchToGoroutineStack()
+		return true
+	}
+	if it.frame.Current.Fn != nil {
+		switch it.frame.Current.Fn.Name {
+		case "runtime.asmcgocall", "runtime.cgocallback", "runtime.sigpanic":
+			//do nothing
+		case "runtime.goexit", "runtime.rt0_go", "runtime.mcall":
+			// Look for "top of stack" functions.
+			it.atend = true
+			return true
+		default:
+			if it.systemstack && it.top && it.g != nil && strings.HasPrefix(it.frame.Current.Fn.Name, "runtime.") && it.frame.Current.Fn.Name != "runtime.throw" && it.frame.Current.Fn.Name != "runtime.fatalthrow" {
+				// The runtime switches to the system stack in multiple places,
+				// since we are only interested in printing the system stack for
+				// cgo calls we switch directly to the goroutine stack, see the
+				// comment in arm64SwitchStack.
+				it.switchToGoroutineStack()
+				return true
+			}
+		}
+	}
+
+	fn := it.bi.PCToFunc(it.frame.Ret)
+	if fn == nil || fn.Name != "runtime.asmcgocall" || !it.systemstack {
+		return false
+	}
+
+	// This function is called by a goroutine to execute a C function and
+	// switches from the goroutine stack to the system stack.
+	// Since we are unwinding the stack from callee to caller we have to switch
+	// from the system stack to the goroutine stack.
+	off, _ := readIntRaw(it.mem, uint64(callFrameRegs.SP()+riscv64cgocallSPOffsetSaveSlot), int64(it.bi.Arch.PtrSize()))
+	oldsp := callFrameRegs.SP()
+	newsp := uint64(int64(it.stackhi) - off)
+
+	// runtime.asmcgocall can also be called from inside the system stack,
+	// in that case no stack switch actually happens
+	if newsp == oldsp {
+		return false
+	}
+	it.systemstack = false
+	callFrameRegs.Reg(callFrameRegs.SPRegNum).Uint64Val = uint64(int64(newsp))
+	return false
+}
+
+func riscv64RegSize(regnum uint64) int {
+	return 8 // general and fp registers
+}
+
+func riscv64RegistersToDwarfRegisters(staticBase uint64, regs Registers) *op.DwarfRegisters {
+	dregs := initDwarfRegistersFromSlice(int(regnum.RISCV64MaxRegNum()), regs, regnum.RISCV64NameToDwarf)
+	dr := op.NewDwarfRegisters(staticBase, dregs, binary.LittleEndian, regnum.RISCV64_PC, regnum.RISCV64_SP, regnum.RISCV64_BP, regnum.RISCV64_LR)
+	dr.SetLoadMoreCallback(loadMoreDwarfRegistersFromSliceFunc(dr, regs, regnum.RISCV64NameToDwarf))
+	return dr
+}
+
+func riscv64AddrAndStackRegsToDwarfRegisters(staticBase, pc, sp, bp, lr uint64) op.DwarfRegisters {
+	dregs := make([]*op.DwarfRegister, regnum.RISCV64_PC+1)
+	dregs[regnum.RISCV64_PC] = op.DwarfRegisterFromUint64(pc)
+	dregs[regnum.RISCV64_SP] = op.DwarfRegisterFromUint64(sp)
+	dregs[regnum.RISCV64_BP] = op.DwarfRegisterFromUint64(bp)
+	dregs[regnum.RISCV64_LR] = op.DwarfRegisterFromUint64(lr)
+
+	return *op.NewDwarfRegisters(staticBase, dregs, binary.LittleEndian, regnum.RISCV64_PC, regnum.RISCV64_SP, regnum.RISCV64_BP, regnum.RISCV64_LR)
+}
+
+func riscv64DwarfRegisterToString(i int, reg *op.DwarfRegister) (name string, floatingPoint bool, repr string) {
+	name = regnum.RISCV64ToName(uint64(i))
+
+	if reg == nil {
+		return name, false, ""
+	}
+
+	if name[0] == 'F' {
+		return name, true, fmt.Sprintf("%#016x", reg.Uint64Val)
+	}
+	return name, false, fmt.Sprintf("%#016x", reg.Uint64Val)
+}
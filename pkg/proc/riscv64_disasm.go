@@ -0,0 +1,506 @@
+package proc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/go-delve/delve/pkg/dwarf/op"
+	"github.com/go-delve/delve/pkg/dwarf/regnum"
+)
+
+// golang.org/x/arch does not provide a RISC-V decoder, the instructions
+// below are the subset of RV64IMC that Delve needs to classify calls,
+// returns and jumps, to recognize function prologues and to produce a
+// readable listing of Go code. Everything else is printed as raw data.
+
+type riscv64Op uint16
+
+const (
+	riscv64OpUnknown riscv64Op = iota
+	riscv64OpLUI
+	riscv64OpAUIPC
+	riscv64OpJAL
+	riscv64OpJALR
+	riscv64OpBEQ
+	riscv64OpBNE
+	riscv64OpBLT
+	riscv64OpBGE
+	riscv64OpBLTU
+	riscv64OpBGEU
+	riscv64OpLB
+	riscv64OpLH
+	riscv64OpLW
+	riscv64OpLD
+	riscv64OpLBU
+	riscv64OpLHU
+	riscv64OpLWU
+	riscv64OpSB
+	riscv64OpSH
+	riscv64OpSW
+	riscv64OpSD
+	riscv64OpADDI
+	riscv64OpSLTI
+	riscv64OpSLTIU
+	riscv64OpXORI
+	riscv64OpORI
+	riscv64OpANDI
+	riscv64OpSLLI
+	riscv64OpSRLI
+	riscv64OpSRAI
+	riscv64OpADDIW
+	riscv64OpADD
+	riscv64OpSUB
+	riscv64OpSLL
+	riscv64OpSLT
+	riscv64OpSLTU
+	riscv64OpXOR
+	riscv64OpSRL
+	riscv64OpSRA
+	riscv64OpOR
+	riscv64OpAND
+	riscv64OpMUL
+	riscv64OpMULH
+	riscv64OpMULHSU
+	riscv64OpMULHU
+	riscv64OpDIV
+	riscv64OpDIVU
+	riscv64OpREM
+	riscv64OpREMU
+	riscv64OpADDW
+	riscv64OpSUBW
+	riscv64OpECALL
+	riscv64OpEBREAK
+)
+
+var riscv64OpNames = [...]string{
+	riscv64OpUnknown: "?",
+	riscv64OpLUI:     "lui",
+	riscv64OpAUIPC:   "auipc",
+	riscv64OpJAL:     "jal",
+	riscv64OpJALR:    "jalr",
+	riscv64OpBEQ:     "beq",
+	riscv64OpBNE:     "bne",
+	riscv64OpBLT:     "blt",
+	riscv64OpBGE:     "bge",
+	riscv64OpBLTU:    "bltu",
+	riscv64OpBGEU:    "bgeu",
+	riscv64OpLB:      "lb",
+	riscv64OpLH:      "lh",
+	riscv64OpLW:      "lw",
+	riscv64OpLD:      "ld",
+	riscv64OpLBU:     "lbu",
+	riscv64OpLHU:     "lhu",
+	riscv64OpLWU:     "lwu",
+	riscv64OpSB:      "sb",
+	riscv64OpSH:      "sh",
+	riscv64OpSW:      "sw",
+	riscv64OpSD:      "sd",
+	riscv64OpADDI:    "addi",
+	riscv64OpSLTI:    "slti",
+	riscv64OpSLTIU:   "sltiu",
+	riscv64OpXORI:    "xori",
+	riscv64OpORI:     "ori",
+	riscv64OpANDI:    "andi",
+	riscv64OpSLLI:    "slli",
+	riscv64OpSRLI:    "srli",
+	riscv64OpSRAI:    "srai",
+	riscv64OpADDIW:   "addiw",
+	riscv64OpADD:     "add",
+	riscv64OpSUB:     "sub",
+	riscv64OpSLL:     "sll",
+	riscv64OpSLT:     "slt",
+	riscv64OpSLTU:    "sltu",
+	riscv64OpXOR:     "xor",
+	riscv64OpSRL:     "srl",
+	riscv64OpSRA:     "sra",
+	riscv64OpOR:      "or",
+	riscv64OpAND:     "and",
+	riscv64OpMUL:     "mul",
+	riscv64OpMULH:    "mulh",
+	riscv64OpMULHSU:  "mulhsu",
+	riscv64OpMULHU:   "mulhu",
+	riscv64OpDIV:     "div",
+	riscv64OpDIVU:    "divu",
+	riscv64OpREM:     "rem",
+	riscv64OpREMU:    "remu",
+	riscv64OpADDW:    "addw",
+	riscv64OpSUBW:    "subw",
+	riscv64OpECALL:   "ecall",
+	riscv64OpEBREAK:  "ebreak",
+}
+
+var (
+	riscv64BranchOps = [8]riscv64Op{riscv64OpBEQ, riscv64OpBNE, riscv64OpUnknown, riscv64OpUnknown, riscv64OpBLT, riscv64OpBGE, riscv64OpBLTU, riscv64OpBGEU}
+	riscv64LoadOps   = [8]riscv64Op{riscv64OpLB, riscv64OpLH, riscv64OpLW, riscv64OpLD, riscv64OpLBU, riscv64OpLHU, riscv64OpLWU, riscv64OpUnknown}
+	riscv64StoreOps  = [8]riscv64Op{riscv64OpSB, riscv64OpSH, riscv64OpSW, riscv64OpSD, riscv64OpUnknown, riscv64OpUnknown, riscv64OpUnknown, riscv64OpUnknown}
+	riscv64OpImmOps  = [8]riscv64Op{riscv64OpADDI, riscv64OpSLLI, riscv64OpSLTI, riscv64OpSLTIU, riscv64OpXORI, riscv64OpSRLI, riscv64OpORI, riscv64OpANDI}
+	riscv64OpOps     = [8]riscv64Op{riscv64OpADD, riscv64OpSLL, riscv64OpSLT, riscv64OpSLTU, riscv64OpXOR, riscv64OpSRL, riscv64OpOR, riscv64OpAND}
+	riscv64MulOps    = [8]riscv64Op{riscv64OpMUL, riscv64OpMULH, riscv64OpMULHSU, riscv64OpMULHU, riscv64OpDIV, riscv64OpDIVU, riscv64OpREM, riscv64OpREMU}
+)
+
+var errRISCV64Truncated = errors.New("truncated instruction")
+
+// riscv64Inst is a decoded RISC-V instruction.
+type riscv64Inst struct {
+	Op           riscv64Op
+	Rd, Rs1, Rs2 uint8
+	Imm          int64 // immediate operand, branch offsets are relative to the instruction address
+	Len          int   // 2 for compressed instructions, 4 otherwise
+	Enc          uint32
+}
+
+func riscv64Decode(mem []byte) (riscv64Inst, error) {
+	if len(mem) < 2 {
+		return riscv64Inst{}, errRISCV64Truncated
+	}
+	if lo := binary.LittleEndian.Uint16(mem); lo&3 != 3 {
+		return riscv64DecodeCompressed(lo), nil
+	}
+	if len(mem) < 4 {
+		return riscv64Inst{}, errRISCV64Truncated
+	}
+
+	x := binary.LittleEndian.Uint32(mem)
+	inst := riscv64Inst{Len: 4, Enc: x, Rd: uint8(x >> 7 & 0x1f), Rs1: uint8(x >> 15 & 0x1f), Rs2: uint8(x >> 20 & 0x1f)}
+	funct3 := x >> 12 & 0x7
+	funct7 := x >> 25
+	immI := signExtend(x>>20, 12)
+
+	switch x & 0x7f {
+	case 0x37:
+		inst.Op = riscv64OpLUI
+		inst.Imm = int64(x >> 12)
+	case 0x17:
+		inst.Op = riscv64OpAUIPC
+		inst.Imm = int64(x >> 12)
+	case 0x6f:
+		inst.Op = riscv64OpJAL
+		inst.Imm = signExtend((x>>31&1)<<20|(x>>12&0xff)<<12|(x>>20&1)<<11|(x>>21&0x3ff)<<1, 21)
+	case 0x67:
+		if funct3 == 0 {
+			inst.Op = riscv64OpJALR
+			inst.Imm = immI
+		}
+	case 0x63:
+		inst.Op = riscv64BranchOps[funct3]
+		inst.Imm = signExtend((x>>31&1)<<12|(x>>7&1)<<11|(x>>25&0x3f)<<5|(x>>8&0xf)<<1, 13)
+	case 0x03:
+		inst.Op = riscv64LoadOps[funct3]
+		inst.Imm = immI
+	case 0x23:
+		inst.Op = riscv64StoreOps[funct3]
+		inst.Imm = signExtend((x>>25)<<5|(x>>7&0x1f), 12)
+	case 0x13:
+		inst.Op = riscv64OpImmOps[funct3]
+		inst.Imm = immI
+		switch funct3 {
+		case 1:
+			inst.Imm = int64(x >> 20 & 0x3f)
+		case 5:
+			inst.Imm = int64(x >> 20 & 0x3f)
+			if x>>30&1 != 0 {
+				inst.Op = riscv64OpSRAI
+			}
+		}
+	case 0x1b:
+		if funct3 == 0 {
+			inst.Op = riscv64OpADDIW
+			inst.Imm = immI
+		}
+	case 0x33:
+		switch funct7 {
+		case 0x00:
+			inst.Op = riscv64OpOps[funct3]
+		case 0x01:
+			inst.Op = riscv64MulOps[funct3]
+		case 0x20:
+			switch funct3 {
+			case 0:
+				inst.Op = riscv64OpSUB
+			case 5:
+				inst.Op = riscv64OpSRA
+			}
+		}
+	case 0x3b:
+		switch {
+		case funct3 == 0 && funct7 == 0x00:
+			inst.Op = riscv64OpADDW
+		case funct3 == 0 && funct7 == 0x20:
+			inst.Op = riscv64OpSUBW
+		}
+	case 0x73:
+		switch x {
+		case 0x00000073:
+			inst.Op = riscv64OpECALL
+		case 0x00100073:
+			inst.Op = riscv64OpEBREAK
+		}
+	}
+	return inst, nil
+}
+
+// riscv64DecodeCompressed decodes a compressed instruction into the
+// equivalent base instruction.
+func riscv64DecodeCompressed(x uint16) riscv64Inst {
+	inst := riscv64Inst{Len: 2, Enc: uint32(x)}
+	c := uint32(x)
+	funct3 := c >> 13
+	rd := uint8(c >> 7 & 0x1f)
+	rs2 := uint8(c >> 2 & 0x1f)
+	rdp := uint8(c>>2&7) + 8  // rd' and rs2'
+	rs1p := uint8(c>>7&7) + 8 // rs1' and rd'
+	imm6 := signExtend((c>>12&1)<<5|c>>2&0x1f, 6)
+
+	switch c & 3 {
+	case 0:
+		switch funct3 {
+		case 0:
+			if c == 0 {
+				break
+			}
+			inst.Op, inst.Rd, inst.Rs1 = riscv64OpADDI, rdp, regnum.RISCV64_SP
+			inst.Imm = int64((c>>11&3)<<4 | (c>>7&0xf)<<6 | (c>>6&1)<<2 | (c>>5&1)<<3)
+		case 2, 6:
+			inst.Op, inst.Rd, inst.Rs1, inst.Rs2 = riscv64OpLW, rdp, rs1p, rdp
+			if funct3 == 6 {
+				inst.Op = riscv64OpSW
+			}
+			inst.Imm = int64((c>>10&7)<<3 | (c>>6&1)<<2 | (c>>5&1)<<6)
+		case 3, 7:
+			inst.Op, inst.Rd, inst.Rs1, inst.Rs2 = riscv64OpLD, rdp, rs1p, rdp
+			if funct3 == 7 {
+				inst.Op = riscv64OpSD
+			}
+			inst.Imm = int64((c>>10&7)<<3 | (c>>5&3)<<6)
+		}
+	case 1:
+		switch funct3 {
+		case 0:
+			inst.Op, inst.Rd, inst.Rs1, inst.Imm = riscv64OpADDI, rd, rd, imm6
+		case 1:
+			inst.Op, inst.Rd, inst.Rs1, inst.Imm = riscv64OpADDIW, rd, rd, imm6
+		case 2:
+			inst.Op, inst.Rd, inst.Imm = riscv64OpADDI, rd, imm6
+		case 3:
+			if rd == regnum.RISCV64_SP {
+				inst.Op, inst.Rd, inst.Rs1 = riscv64OpADDI, rd, rd
+				inst.Imm = signExtend((c>>12&1)<<9|(c>>6&1)<<4|(c>>5&1)<<6|(c>>3&3)<<7|(c>>2&1)<<5, 10)
+			} else {
+				inst.Op, inst.Rd = riscv64OpLUI, rd
+				inst.Imm = int64(uint32(imm6) & 0xfffff)
+			}
+		case 4:
+			inst.Rd, inst.Rs1, inst.Rs2 = rs1p, rs1p, rdp
+			switch c >> 10 & 3 {
+			case 0:
+				inst.Op, inst.Imm = riscv64OpSRLI, int64((c>>12&1)<<5|c>>2&0x1f)
+			case 1:
+				inst.Op, inst.Imm = riscv64OpSRAI, int64((c>>12&1)<<5|c>>2&0x1f)
+			case 2:
+				inst.Op, inst.Imm = riscv64OpANDI, imm6
+			case 3:
+				if c>>12&1 == 0 {
+					inst.Op = [4]riscv64Op{riscv64OpSUB, riscv64OpXOR, riscv64OpOR, riscv64OpAND}[c>>5&3]
+				} else {
+					inst.Op = [4]riscv64Op{riscv64OpSUBW, riscv64OpADDW, riscv64OpUnknown, riscv64OpUnknown}[c>>5&3]
+				}
+			}
+		case 5:
+			inst.Op = riscv64OpJAL
+			inst.Imm = signExtend((c>>12&1)<<11|(c>>11&1)<<4|(c>>9&3)<<8|(c>>8&1)<<10|(c>>7&1)<<6|(c>>6&1)<<7|(c>>3&7)<<1|(c>>2&1)<<5, 12)
+		case 6, 7:
+			inst.Op, inst.Rs1 = riscv64OpBEQ, rs1p
+			if funct3 == 7 {
+				inst.Op = riscv64OpBNE
+			}
+			inst.Imm = signExtend((c>>12&1)<<8|(c>>10&3)<<3|(c>>5&3)<<6|(c>>3&3)<<1|(c>>2&1)<<5, 9)
+		}
+	case 2:
+		switch funct3 {
+		case 0:
+			inst.Op, inst.Rd, inst.Rs1, inst.Imm = riscv64OpSLLI, rd, rd, int64((c>>12&1)<<5|c>>2&0x1f)
+		case 2:
+			inst.Op, inst.Rd, inst.Rs1 = riscv64OpLW, rd, regnum.RISCV64_SP
+			inst.Imm = int64((c>>12&1)<<5 | (c>>4&7)<<2 | (c>>2&3)<<6)
+		case 3:
+			inst.Op, inst.Rd, inst.Rs1 = riscv64OpLD, rd, regnum.RISCV64_SP
+			inst.Imm = int64((c>>12&1)<<5 | (c>>5&3)<<3 | (c>>2&7)<<6)
+		case 4:
+			switch {
+			case c>>12&1 == 0 && rs2 == 0:
+				inst.Op, inst.Rs1 = riscv64OpJALR, rd
+			case c>>12&1 == 0:
+				inst.Op, inst.Rd, inst.Rs2 = riscv64OpADD, rd, rs2
+			case rd == 0 && rs2 == 0:
+				inst.Op = riscv64OpEBREAK
+			case rs2 == 0:
+				inst.Op, inst.Rd, inst.Rs1 = riscv64OpJALR, regnum.RISCV64_LR, rd
+			default:
+				inst.Op, inst.Rd, inst.Rs1, inst.Rs2 = riscv64OpADD, rd, rd, rs2
+			}
+		case 6:
+			inst.Op, inst.Rs1, inst.Rs2 = riscv64OpSW, regnum.RISCV64_SP, rs2
+			inst.Imm = int64((c>>9&0xf)<<2 | (c>>7&3)<<6)
+		case 7:
+			inst.Op, inst.Rs1, inst.Rs2 = riscv64OpSD, regnum.RISCV64_SP, rs2
+			inst.Imm = int64((c>>10&7)<<3 | (c>>7&7)<<6)
+		}
+	}
+	return inst
+}
+
+func riscv64AsmDecode(asmInst *AsmInstruction, mem []byte, regs *op.DwarfRegisters, memrw MemoryReadWriter, bi *BinaryInfo) error {
+	inst, err := riscv64Decode(mem)
+	if err != nil {
+		asmInst.Size = len(mem)
+		asmInst.Bytes = mem
+		asmInst.Inst = (*riscv64ArchInst)(nil)
+		return err
+	}
+
+	asmInst.Size = inst.Len
+	asmInst.Bytes = mem[:asmInst.Size]
+	asmInst.Inst = (*riscv64ArchInst)(&inst)
+	asmInst.Kind = OtherInstruction
+
+	switch inst.Op {
+	case riscv64OpJAL:
+		if inst.Rd == 0 {
+			asmInst.Kind = JmpInstruction
+		} else {
+			asmInst.Kind = CallInstruction
+		}
+	case riscv64OpJALR:
+		switch {
+		case inst.Rd == 0 && inst.Rs1 == regnum.RISCV64_LR && inst.Imm == 0:
+			asmInst.Kind = RetInstruction
+		case inst.Rd == 0:
+			asmInst.Kind = JmpInstruction
+		default:
+			asmInst.Kind = CallInstruction
+		}
+	case riscv64OpEBREAK:
+		asmInst.Kind = HardBreakInstruction
+	}
+
+	asmInst.DestLoc = resolveCallArgRISCV64(&inst, asmInst.Loc.PC, asmInst.AtPC, regs, bi)
+
+	return nil
+}
+
+func resolveCallArgRISCV64(inst *riscv64Inst, instAddr uint64, currentGoroutine bool, regs *op.DwarfRegisters, bininfo *BinaryInfo) *Location {
+	switch inst.Op {
+	case riscv64OpJAL, riscv64OpBEQ, riscv64OpBNE, riscv64OpBLT, riscv64OpBGE, riscv64OpBLTU, riscv64OpBGEU:
+		return pcToDestLoc(bininfo, instAddr+uint64(inst.Imm))
+	case riscv64OpJALR:
+		if !currentGoroutine || regs == nil {
+			return nil
+		}
+		pc, err := bininfo.Arch.getAsmRegister(regs, int(inst.Rs1))
+		if err != nil {
+			return nil
+		}
+		return pcToDestLoc(bininfo, pc+uint64(inst.Imm))
+	}
+	return nil
+}
+
+// Possible stacksplit prologues are inserted by stacksplit in
+// $GOROOT/src/cmd/internal/obj/riscv/obj.go.
+var prologuesRISCV64 []opcodeSeq
+
+func init() {
+	var smallStacksplit = opcodeSeq{uint64(riscv64OpBLTU)}
+	var bigStacksplit = opcodeSeq{uint64(riscv64OpADDI), uint64(riscv64OpBLTU)}
+	var getStackguard = opcodeSeq{uint64(riscv64OpLD)}
+
+	prologuesRISCV64 = make([]opcodeSeq, 0, 2)
+	for _, stacksplit := range []opcodeSeq{smallStacksplit, bigStacksplit} {
+		prologue := make(opcodeSeq, 0, len(getStackguard)+len(stacksplit))
+		prologue = append(prologue, getStackguard...)
+		prologue = append(prologue, stacksplit...)
+		prologuesRISCV64 = append(prologuesRISCV64, prologue)
+	}
+}
+
+type riscv64ArchInst riscv64Inst
+
+// Text returns the instruction in GNU syntax, using ABI register names,
+// regardless of flavour.
+func (inst *riscv64ArchInst) Text(flavour AssemblyFlavour, pc uint64, symLookup func(uint64) (string, uint64)) string {
+	if inst == nil {
+		return "?"
+	}
+
+	reg := regnum.RISCV64ABIName
+	name := riscv64OpNames[inst.Op]
+	rd, rs1, rs2 := reg(int(inst.Rd)), reg(int(inst.Rs1)), reg(int(inst.Rs2))
+	target := branchTargetString(pc+uint64(inst.Imm), symLookup)
+
+	switch inst.Op {
+	case riscv64OpUnknown:
+		if inst.Len == 2 {
+			return fmt.Sprintf(".2byte %#04x", inst.Enc)
+		}
+		return fmt.Sprintf(".4byte %#08x", inst.Enc)
+	case riscv64OpLUI, riscv64OpAUIPC:
+		return fmt.Sprintf("%s %s, %#x", name, rd, inst.Imm)
+	case riscv64OpJAL:
+		switch inst.Rd {
+		case 0:
+			return "j " + target
+		case regnum.RISCV64_LR:
+			return "jal " + target
+		}
+		return fmt.Sprintf("%s %s, %s", name, rd, target)
+	case riscv64OpJALR:
+		switch {
+		case inst.Rd == 0 && inst.Rs1 == regnum.RISCV64_LR && inst.Imm == 0:
+			return "ret"
+		case inst.Rd == 0 && inst.Imm == 0:
+			return "jr " + rs1
+		}
+		return fmt.Sprintf("%s %s, %d(%s)", name, rd, inst.Imm, rs1)
+	case riscv64OpBEQ, riscv64OpBNE, riscv64OpBLT, riscv64OpBGE, riscv64OpBLTU, riscv64OpBGEU:
+		return fmt.Sprintf("%s %s, %s, %s", name, rs1, rs2, target)
+	case riscv64OpLB, riscv64OpLH, riscv64OpLW, riscv64OpLD, riscv64OpLBU, riscv64OpLHU, riscv64OpLWU:
+		return fmt.Sprintf("%s %s, %d(%s)", name, rd, inst.Imm, rs1)
+	case riscv64OpSB, riscv64OpSH, riscv64OpSW, riscv64OpSD:
+		return fmt.Sprintf("%s %s, %d(%s)", name, rs2, inst.Imm, rs1)
+	case riscv64OpADDI:
+		switch {
+		case inst.Rd == 0 && inst.Rs1 == 0 && inst.Imm == 0:
+			return "nop"
+		case inst.Rs1 == 0:
+			return fmt.Sprintf("li %s, %d", rd, inst.Imm)
+		case inst.Imm == 0:
+			return fmt.Sprintf("mv %s, %s", rd, rs1)
+		}
+		return fmt.Sprintf("%s %s, %s, %d", name, rd, rs1, inst.Imm)
+	case riscv64OpSLTI, riscv64OpSLTIU, riscv64OpXORI, riscv64OpORI, riscv64OpANDI, riscv64OpSLLI, riscv64OpSRLI, riscv64OpSRAI, riscv64OpADDIW:
+		return fmt.Sprintf("%s %s, %s, %d", name, rd, rs1, inst.Imm)
+	case riscv64OpADD:
+		if inst.Rs1 == 0 {
+			return fmt.Sprintf("mv %s, %s", rd, rs2)
+		}
+	case riscv64OpECALL, riscv64OpEBREAK:
+		return name
+	}
+	return fmt.Sprintf("%s %s, %s, %s", name, rd, rs1, rs2)
+}
+
+func (inst *riscv64ArchInst) OpcodeEquals(op uint64) bool {
+	if inst == nil {
+		return false
+	}
+	return uint64(inst.Op) == op
+}
+
+var riscv64AsmRegisters = func() map[int]asmRegister {
+	r := make(map[int]asmRegister)
+	for i := 0; i <= 31; i++ {
+		r[i] = asmRegister{regnum.RISCV64_X0 + uint64(i), 0, 0}
+	}
+	return r
+}()
@@ -8,6 +8,7 @@ import (
 	"reflect"
 
 	"github.com/go-delve/delve/pkg/dwarf/frame"
+	"github.com/go-delve/delve/pkg/dwarf/godwarf"
 	"github.com/go-delve/delve/pkg/dwarf/op"
 	"github.com/go-delve/delve/pkg/dwarf/reader"
 )
@@ -69,6 +70,45 @@ type Stackframe struct {
 
 	// Defers is the list of functions deferred by this stack frame (so far).
 	Defers []*Defer
+
+	// Markers describes the control flow situation of this stack frame.
+	Markers StackframeMarkers
+}
+
+// StackframeMarkers describes the control flow situation of a stack frame,
+// for example if it is executing a deferred call.
+type StackframeMarkers uint8
+
+const (
+	// StackframeDeferCall is set on frames executing a deferred function
+	// called by runtime.deferreturn.
+	StackframeDeferCall StackframeMarkers = 1 << iota
+	// StackframeRunningDefers is set on frames that are running their
+	// deferred functions through runtime.deferreturn.
+	StackframeRunningDefers
+	// StackframePanicDeferCall is set on frames executing a deferred function
+	// called by runtime.gopanic.
+	StackframePanicDeferCall
+	// StackframePanicking is set on frames that called runtime.gopanic.
+	StackframePanicking
+)
+
+// Strings returns a description of each marker that is set.
+func (markers StackframeMarkers) Strings() []string {
+	var r []string
+	if markers&StackframeDeferCall != 0 {
+		r = append(r, "deferred call")
+	}
+	if markers&StackframeRunningDefers != 0 {
+		r = append(r, "running deferred calls")
+	}
+	if markers&StackframePanicDeferCall != 0 {
+		r = append(r, "deferred call during panic")
+	}
+	if markers&StackframePanicking != 0 {
+		r = append(r, "panicking")
+	}
+	return r
 }
 
 // FrameOffset returns the address of the stack frame, absolute for system
@@ -236,11 +276,21 @@ func (it *stackIterator) switchToGoroutineStack() {
 	it.pc = it.g.PC
 	it.regs.Reg(it.regs.SPRegNum).Uint64Val = it.g.SP
 	it.regs.AddReg(it.regs.BPRegNum, op.DwarfRegisterFromUint64(it.g.BP))
-	if it.bi.Arch.Name == "arm64" {
+	if it.bi.Arch.usesLR {
 		it.regs.Reg(it.regs.LRRegNum).Uint64Val = it.g.LR
 	}
 }
 
+// unwindUnknownFrame returns true if the current frame, which does not
+// belong to a Go function, should be unwound using its call frame
+// information. This is the case for C functions that are covered by an
+// .eh_frame section, usually C code called through cgo. Otherwise the
+// iterator should switch directly to the goroutine stack.
+func (it *stackIterator) unwindUnknownFrame() bool {
+	_, err := it.bi.frameEntries.FDEForPC(it.frame.Current.PC)
+	return err == nil
+}
+
 // Frame returns the frame the iterator is pointing at.
 func (it *stackIterator) Frame() Stackframe {
 	it.frame.Bottom = it.atend
@@ -319,9 +369,57 @@ func (it *stackIterator) stacktrace(depth int) ([]Stackframe, error) {
 		}
 		frames = append(frames, Stackframe{Err: err})
 	}
+	markFrames(frames)
 	return frames, nil
 }
 
+// markFrames sets the Markers field of frames calling, or called by,
+// runtime.deferreturn and runtime.gopanic. Autogenerated wrappers between
+// the deferred function and the runtime are skipped, like
+// skipAutogeneratedWrappersOut does.
+func markFrames(frames []Stackframe) {
+	for i := range frames {
+		fn := frames[i].Current.Fn
+		if fn == nil {
+			continue
+		}
+		var callee, caller StackframeMarkers
+		switch fn.Name {
+		case "runtime.deferreturn":
+			callee, caller = StackframeDeferCall, StackframeRunningDefers
+		case "runtime.gopanic":
+			callee, caller = StackframePanicDeferCall, StackframePanicking
+		default:
+			continue
+		}
+		if i+1 < len(frames) {
+			frames[i+1].Markers |= caller
+		}
+		for j := i - 1; j >= 0 && j >= i-1-maxSkipAutogeneratedWrappers; j-- {
+			if frames[j].Current.Fn == nil {
+				break
+			}
+			if !isAutogeneratedWrapper(frames[j].Current.Fn) || j == 0 {
+				// runtime.gopanic also calls runtime functions that are not
+				// deferred calls (for example runtime.fatalpanic)
+				if frames[j].Current.Fn.PackageName() != "runtime" {
+					frames[j].Markers |= callee
+				}
+				break
+			}
+		}
+	}
+}
+
+// isAutogeneratedWrapper returns true if fn is an autogenerated wrapper.
+func isAutogeneratedWrapper(fn *Function) bool {
+	if fn.cu == nil || fn.cu.lineInfo == nil {
+		return false
+	}
+	file, line := fn.cu.lineInfo.PCToLine(fn.Entry, fn.Entry)
+	return isAutogenerated(Location{File: file, Line: line, Fn: fn})
+}
+
 func (it *stackIterator) appendInlineCalls(frames []Stackframe, frame Stackframe) []Stackframe {
 	if frame.Call.Fn == nil {
 		return append(frames, frame)
@@ -435,7 +533,7 @@ func (it *stackIterator) advanceRegs() (callFrameRegs op.DwarfRegisters, ret uin
 		}
 	}
 
-	if it.bi.Arch.Name == "arm64" {
+	if it.bi.Arch.usesLR {
 		if ret == 0 && it.regs.Reg(it.regs.LRRegNum) != nil {
 			ret = it.regs.Reg(it.regs.LRRegNum).Uint64Val
 		}
@@ -530,6 +628,8 @@ type Defer struct {
 	link    *Defer // Next deferred function
 	argSz   int64  // Always 0 in Go >=1.17
 
+	closureAddr uint64 // address of the funcval of the deferred function
+
 	variable   *Variable
 	Unreadable error
 }
@@ -590,8 +690,10 @@ func (d *Defer) load() {
 	if fnvar.Kind == reflect.Func {
 		// In Go 1.18, fn is a func().
 		d.DwrapPC = fnvar.Base
+		d.closureAddr = fnvar.closureAddr
 	} else if val := fnvar.maybeDereference(); val.Addr != 0 {
 		// In Go <1.18, fn is a *funcval.
+		d.closureAddr = val.Addr
 		fnvar = fnvar.loadFieldNamed("fn")
 		if fnvar.Unreadable == nil {
 			d.DwrapPC, _ = constant.Uint64Val(fnvar.Value)
@@ -682,6 +784,49 @@ func (d *Defer) EvalScope(t *Target, thread Thread) (*EvalScope, error) {
 	return scope, nil
 }
 
+// CapturedVars returns the variables captured by the deferred function,
+// loaded using cfg. The offsets of captured variables in the closure are
+// only described by the debug info produced by Go 1.23 and later.
+func (d *Defer) CapturedVars(p *Target, cfg LoadConfig) ([]*Variable, error) {
+	if d.closureAddr == 0 {
+		return nil, nil
+	}
+	bi := p.BinInfo()
+	fn := bi.PCToFunc(d.DwrapPC)
+	if fn == nil {
+		return nil, fmt.Errorf("could not find function at %#x", d.DwrapPC)
+	}
+	image := fn.cu.image
+	dwarfTree, err := image.getDwarfTree(fn.offset)
+	if err != nil {
+		return nil, err
+	}
+	vars := []*Variable{}
+	for _, entry := range dwarfTree.Children {
+		if entry.Tag != dwarf.TagVariable {
+			continue
+		}
+		off, ok := entry.Val(godwarf.AttrGoClosureOffset).(int64)
+		if !ok {
+			continue
+		}
+		name, typ, err := readVarEntry(entry, image)
+		if err != nil {
+			continue
+		}
+		v := newVariable(name, d.closureAddr+uint64(off), typ, bi, d.variable.mem)
+		if len(name) > 1 && name[0] == '&' {
+			// variables captured by reference
+			v = v.maybeDereference()
+			v.Name = name[1:]
+			v.Flags |= VariableEscaped
+		}
+		v.loadValue(cfg)
+		vars = append(vars, v)
+	}
+	return vars, nil
+}
+
 // DeferredFunc returns the deferred function, on Go 1.17 and later unwraps
 // any defer wrapper.
 func (d *Defer) DeferredFunc(p *Target) (file string, line int, fn *Function) {
@@ -0,0 +1,222 @@
+package proc
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"runtime"
+	"sync"
+)
+
+// maxCachedStackSize is the maximum size of a goroutine stack that will be
+// read in a single operation and cached by GoroutineStacktraces.
+const maxCachedStackSize = 64 * 1024 * 1024
+
+// lockedMemory serializes accesses to the memory of the target, which is
+// not in general safe for concurrent use.
+type lockedMemory struct {
+	mu  sync.Mutex
+	mem MemoryReadWriter
+}
+
+func (m *lockedMemory) ReadMemory(buf []byte, addr uint64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mem.ReadMemory(buf, addr)
+}
+
+func (m *lockedMemory) WriteMemory(addr uint64, data []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mem.WriteMemory(addr, data)
+}
+
+// stackCacheKey identifies an unwound stack in stackCache. The hash covers
+// the registers of the goroutine, its stack bounds and the contents of its
+// stack: if none of them changed the goroutine hasn't run and its
+// stacktrace is the same.
+type stackCacheKey struct {
+	goid  int
+	hash  uint64
+	depth int
+	opts  StacktraceOptions
+}
+
+// stackCache caches the stacktraces of parked goroutines across stops of
+// the target.
+type stackCache struct {
+	mu sync.Mutex
+	// prev contains the stacktraces unwound by the previous call to
+	// GoroutineStacktraces, cur the ones used by the current call. Entries
+	// not used by the current call are dropped at the end of it.
+	prev, cur map[stackCacheKey][]Stackframe
+}
+
+func (c *stackCache) get(key stackCacheKey) []Stackframe {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	frames, ok := c.cur[key]
+	if !ok {
+		frames, ok = c.prev[key]
+		if ok {
+			c.cur[key] = frames
+		}
+	}
+	return frames
+}
+
+func (c *stackCache) put(key stackCacheKey, frames []Stackframe) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cur[key] = frames
+}
+
+// GoroutineStacktraces returns the stacktraces of the goroutines in gs, up
+// to depth frames each, calling fn with the index in gs and the stacktrace
+// of each goroutine, in the same order as gs. If fn returns false no more
+// stacktraces are returned.
+//
+// The stacks of goroutines that are not running on a thread are unwound
+// concurrently, each one is read from the target with a single operation
+// and the result is cached, so that the stacktraces of goroutines that did
+// not run between two calls are not unwound again.
+//
+// The workers share t and its BinaryInfo without holding any lock other
+// than the one serializing accesses to the memory of the target, fn is
+// also called while holding it. State of BinaryInfo and Arch that is
+// computed lazily while unwinding must be protected by its own lock (see
+// for example Arch.unwindFuncs, Image.dwarfTreeCacheMu and the cache of
+// line.DebugLineInfo), everything else they read must not change after
+// the binary is loaded. TestGoroutineStacktracesRace checks this when run
+// with the race detector.
+func GoroutineStacktraces(t *Target, gs []*G, depth int, opts StacktraceOptions, fn func(i int, frames []Stackframe, err error) bool) {
+	mem := &lockedMemory{mem: t.Memory()}
+
+	t.stackCache.mu.Lock()
+	t.stackCache.prev, t.stackCache.cur = t.stackCache.cur, make(map[stackCacheKey][]Stackframe)
+	t.stackCache.mu.Unlock()
+
+	type result struct {
+		frames []Stackframe
+		err    error
+		done   chan struct{}
+	}
+
+	results := make([]result, len(gs))
+	parked := make([]int, 0, len(gs))
+	for i, g := range gs {
+		if g.Thread == nil {
+			results[i].done = make(chan struct{})
+			parked = append(parked, i)
+		}
+	}
+
+	unwind := func(i int) {
+		results[i].frames, results[i].err = t.stackCache.stacktrace(gs[i], mem, depth, opts&^StacktraceReadDefers)
+		close(results[i].done)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	if len(parked) > 0 {
+		work := make(chan int)
+		nworkers := runtime.GOMAXPROCS(0)
+		if nworkers > len(parked) {
+			nworkers = len(parked)
+		}
+		for w := 0; w < nworkers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range work {
+					unwind(i)
+				}
+			}()
+		}
+		go func() {
+			defer close(work)
+			for _, i := range parked {
+				select {
+				case work <- i:
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+
+	for i, g := range gs {
+		var frames []Stackframe
+		var err error
+		if results[i].done == nil {
+			// goroutines running on a thread need to access its registers, they
+			// are unwound while holding the lock on the target memory.
+			mem.mu.Lock()
+			frames, err = g.Stacktrace(depth, opts)
+			mem.mu.Unlock()
+		} else {
+			<-results[i].done
+			frames, err = results[i].frames, results[i].err
+			if err == nil && opts&StacktraceReadDefers != 0 {
+				// frames can be shared with the cache, copy them before adding the
+				// deferred calls.
+				frames = append([]Stackframe(nil), frames...)
+				mem.mu.Lock()
+				g.readDefers(frames)
+				mem.mu.Unlock()
+			}
+		}
+		// fn can access the target, the workers must not read its memory
+		// concurrently.
+		mem.mu.Lock()
+		cont := fn(i, frames, err)
+		mem.mu.Unlock()
+		if !cont {
+			break
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+}
+
+// stacktrace returns the stacktrace of g, which must not be running on a
+// thread, either from the cache or by unwinding its stack.
+func (c *stackCache) stacktrace(g *G, mem *lockedMemory, depth int, opts StacktraceOptions) ([]Stackframe, error) {
+	bi := g.variable.bi
+	var stackmem MemoryReadWriter = mem
+	var key *stackCacheKey
+	if g.stack.lo <= g.SP && g.SP < g.stack.hi && g.stack.hi-g.SP <= maxCachedStackSize {
+		buf := make([]byte, g.stack.hi-g.SP)
+		if _, err := mem.ReadMemory(buf, g.SP); err == nil {
+			stackmem = &memCache{loaded: true, cacheAddr: g.SP, cache: buf, mem: mem}
+			key = &stackCacheKey{goid: g.ID, hash: stackHash(g, buf), depth: depth, opts: opts}
+			if frames := c.get(*key); frames != nil {
+				return frames, nil
+			}
+		}
+	}
+
+	so := bi.PCToImage(g.PC)
+	it := newStackIterator(
+		bi, stackmem,
+		bi.Arch.addrAndStackRegsToDwarfRegisters(so.StaticBase, g.PC, g.SP, g.BP, g.LR),
+		g.stack.hi, g, opts)
+	frames, err := it.stacktrace(depth)
+	if err == nil && key != nil {
+		c.put(*key, frames)
+	}
+	return frames, err
+}
+
+// stackHash returns a hash of the registers, stack bounds and stack
+// contents of g.
+func stackHash(g *G, stack []byte) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, x := range []uint64{g.PC, g.SP, g.BP, g.LR, g.stack.lo, g.stack.hi} {
+		binary.LittleEndian.PutUint64(buf[:], x)
+		h.Write(buf[:])
+	}
+	h.Write(stack)
+	return h.Sum64()
+}
@@ -0,0 +1,39 @@
+package proc_test
+
+import (
+	"testing"
+
+	"github.com/go-delve/delve/pkg/proc"
+	protest "github.com/go-delve/delve/pkg/proc/test"
+)
+
+// TestGoroutineStacktracesRace unwinds the stacks of all goroutines
+// concurrently right after the target is loaded, when none of the state of
+// BinaryInfo that is computed lazily has been computed yet. It is meant to
+// be run with the race detector, see GoroutineStacktraces.
+func TestGoroutineStacktracesRace(t *testing.T) {
+	protest.AllowRecording(t)
+	withTestProcess("goroutinestackprog", t, func(p *proc.Target, fixture protest.Fixture) {
+		setFunctionBreakpoint(p, t, "main.stacktraceme")
+		assertNoError(p.Continue(), t, "Continue()")
+
+		gs, _, err := proc.GoroutinesInfo(p, 0, 0)
+		assertNoError(err, t, "GoroutinesInfo")
+		parked := 0
+		for _, g := range gs {
+			if g.Thread == nil {
+				parked++
+			}
+		}
+		if parked < 2 {
+			t.Fatalf("not enough parked goroutines: %d", parked)
+		}
+
+		proc.GoroutineStacktraces(p, gs, 40, proc.StacktraceReadDefers, func(i int, frames []proc.Stackframe, err error) bool {
+			if err != nil {
+				t.Errorf("goroutine %d: %v", gs[i].ID, err)
+			}
+			return true
+		})
+	})
+}
@@ -5,12 +5,13 @@ import (
 	"fmt"
 	"go/constant"
 	"os"
+	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/go-delve/delve/pkg/dwarf/op"
 	"github.com/go-delve/delve/pkg/goversion"
-	"github.com/go-delve/delve/pkg/proc/internal/ebpf"
 )
 
 var (
@@ -24,6 +25,18 @@ var (
 
 	// ErrProcessDetached indicates that we detached from the target process.
 	ErrProcessDetached = errors.New("detached from the process")
+
+	// ErrFollowExecNotSupported is returned by FollowExec when the backend
+	// can not follow the children of the target process.
+	ErrFollowExecNotSupported = errors.New("following child processes is not supported by this backend")
+
+	// ErrExecNotSupported is returned by ExecTarget when the backend can
+	// not detect that the target process executed a new program.
+	ErrExecNotSupported = errors.New("detecting exec is not supported by this backend")
+
+	// ErrNonStopNotSupported is returned by SetNonStop when the backend
+	// does not support non-stop mode.
+	ErrNonStopNotSupported = errors.New("non-stop mode is not supported by this backend")
 )
 
 type LaunchFlags uint8
@@ -74,6 +87,15 @@ type Target struct {
 	gcache goroutineCache
 	iscgo  *bool
 
+	// stackCache caches the stacktraces of goroutines returned by
+	// GoroutineStacktraces. Unlike gcache it is keyed on the contents of
+	// the stack and survives resuming the target.
+	stackCache stackCache
+
+	// bookmarks maps the names of the bookmarks set on a recording to their
+	// position, see AddBookmark.
+	bookmarks map[string]Bookmark
+
 	// exitStatus is the exit status of the process we are debugging.
 	// Saved here to relay to any future commands.
 	exitStatus int
@@ -84,6 +106,9 @@ type Target struct {
 	fakeMemoryRegistry    []*compositeMemory
 	fakeMemoryRegistryMap map[string]*compositeMemory
 
+	// nonStop is true if non-stop mode is enabled, see SetNonStop.
+	nonStop bool
+
 	cctx *ContinueOnceContext
 }
 
@@ -133,6 +158,10 @@ func (sr StopReason) String() string {
 		return "call returned"
 	case StopWatchpoint:
 		return "watchpoint"
+	case StopNewChild:
+		return "new child"
+	case StopExec:
+		return "exec"
 	default:
 		return ""
 	}
@@ -149,6 +178,8 @@ const (
 	StopNextFinished                   // The next/step/stepout/stepInstruction command terminated
 	StopCallReturned                   // An injected call completed
 	StopWatchpoint                     // The target process hit one or more watchpoints
+	StopNewChild                       // A child of the target process forked or executed a program and can be attached, see FollowExec
+	StopExec                           // The target process executed a new program, see ExecTarget
 )
 
 // NewTargetConfig contains the configuration for a new Target object,
@@ -158,12 +189,18 @@ type NewTargetConfig struct {
 	DisableAsyncPreempt bool       // Go 1.14 asynchronous preemption should be disabled
 	StopReason          StopReason // Initial stop reason
 	CanDump             bool       // Can create core dumps (must implement ProcessInternal.MemoryMap)
+	RootDir             string     // Directory where the root file system of the process is visible, if it differs from ours
+	AllowNoDebugInfo    bool       // The executable can lack debug info, it can then only be debugged at the instruction level
 }
 
-// DisableAsyncPreemptEnv returns a process environment (like os.Environ)
-// where asyncpreemptoff is set to 1.
-func DisableAsyncPreemptEnv() []string {
-	env := os.Environ()
+// DisableAsyncPreemptEnv returns a copy of the process environment env
+// (os.Environ if env is nil) where asyncpreemptoff is set to 1.
+func DisableAsyncPreemptEnv(env []string) []string {
+	if env == nil {
+		env = os.Environ()
+	} else {
+		env = append([]string(nil), env...)
+	}
 	for i := range env {
 		if strings.HasPrefix(env[i], "GODEBUG=") {
 			// Go 1.14 asynchronous preemption mechanism is incompatible with
@@ -182,12 +219,19 @@ func NewTarget(p ProcessInternal, pid int, currentThread Thread, cfg NewTargetCo
 		return nil, err
 	}
 
+	p.BinInfo().rootDir = cfg.RootDir
 	err = p.BinInfo().LoadBinaryInfo(cfg.Path, entryPoint, cfg.DebugInfoDirs)
+	if err == ErrNoDebugInfoFound && cfg.AllowNoDebugInfo {
+		// No functions, types or variables are available but disassembly and
+		// registers still work, see (*Image).LoadError.
+		p.BinInfo().logger.Warnf("%s has no debug info", cfg.Path)
+		err = nil
+	}
 	if err != nil {
 		return nil, err
 	}
 	for _, image := range p.BinInfo().Images {
-		if image.loadErr != nil {
+		if image.loadErr != nil && (image.index != 0 || !cfg.AllowNoDebugInfo || image.loadErr != ErrNoDebugInfoFound) {
 			return nil, image.loadErr
 		}
 	}
@@ -263,12 +307,133 @@ func (t *Target) Valid() (bool, error) {
 	return ok, err
 }
 
+// FollowExec enables or disables following the children of the target
+// process. While it is enabled the target stops, with StopNewChild, every
+// time one of its children executes a program whose path matches the
+// regular expression include (any program if it is empty) and doesn't
+// match exclude (if it isn't empty), and the child can be attached by
+// calling NewChildren. Children that fork without executing a program are
+// matched against the path of the program of the target and attached
+// right after the fork.
+func (t *Target) FollowExec(follow bool, include, exclude string) error {
+	fe, ok := t.proc.(followExecProcess)
+	if !ok {
+		return ErrFollowExecNotSupported
+	}
+	var includeRe, excludeRe *regexp.Regexp
+	var err error
+	if include != "" {
+		includeRe, err = regexp.Compile(include)
+		if err != nil {
+			return err
+		}
+	}
+	if exclude != "" {
+		excludeRe, err = regexp.Compile(exclude)
+		if err != nil {
+			return err
+		}
+	}
+	return fe.FollowExec(follow, func(path string) bool {
+		return (includeRe == nil || includeRe.MatchString(path)) && (excludeRe == nil || !excludeRe.MatchString(path))
+	})
+}
+
+// NewChildren returns a new target for each child of the target process
+// that forked, or executed a program, matching the regular expressions
+// passed to FollowExec since the last call. The new targets are stopped
+// right after the call to fork or exec.
+func (t *Target) NewChildren() ([]*Target, error) {
+	fe, ok := t.proc.(followExecProcess)
+	if !ok {
+		return nil, nil
+	}
+	return fe.NewChildren()
+}
+
+// ExecTarget returns a new target for the program executed by the target
+// process, after Continue stopped with StopExec. The new program does not
+// need to be a Go program, if it has no debug info the new target can only
+// be debugged at the instruction level.
+// The process can no longer be controlled through the old target, whose
+// breakpoints are lost.
+func (t *Target) ExecTarget() (*Target, error) {
+	ep, ok := t.proc.(execProcess)
+	if !ok {
+		return nil, ErrExecNotSupported
+	}
+	tgt, err := ep.ExecTarget()
+	if err != nil {
+		return nil, err
+	}
+	tgt.StopReason = StopExec
+	return tgt, nil
+}
+
+// SetNonStop enables or disables non-stop mode. In non-stop mode only the
+// threads that hit a breakpoint (or were otherwise stopped by the operating
+// system) stop when Continue returns, all other threads of the target keep
+// running and are resumed with the stopped ones by the next call to
+// Continue. Manual stop requests still stop every thread.
+// Disabling non-stop mode stops all the threads that are still running.
+func (t *Target) SetNonStop(enabled bool) error {
+	ns, ok := t.proc.(nonStopProcess)
+	if !ok {
+		return ErrNonStopNotSupported
+	}
+	if err := ns.SetNonStop(enabled); err != nil {
+		return err
+	}
+	t.nonStop = enabled
+	return nil
+}
+
+// NonStop returns true if non-stop mode is enabled.
+func (t *Target) NonStop() bool {
+	return t.nonStop
+}
+
+// ThreadRunning returns true if thread is still running, which can only
+// happen in non-stop mode. The registers of a running thread can not be
+// read.
+func (t *Target) ThreadRunning(thread Thread) bool {
+	if !t.nonStop {
+		return false
+	}
+	return t.proc.(nonStopProcess).ThreadRunning(thread)
+}
+
+// stoppedThreads returns the threads of the target that are not running,
+// see ThreadRunning.
+func (t *Target) stoppedThreads() []Thread {
+	threads := t.ThreadList()
+	if !t.nonStop {
+		return threads
+	}
+	r := make([]Thread, 0, len(threads))
+	for _, thread := range threads {
+		if !t.ThreadRunning(thread) {
+			r = append(r, thread)
+		}
+	}
+	return r
+}
+
 // SupportsFunctionCalls returns whether or not the backend supports
 // calling functions during a debug session.
 // Currently only non-recorded processes running on AMD64 support
 // function calls.
 func (t *Target) SupportsFunctionCalls() bool {
-	return (t.Process.BinInfo().Arch.Name == "amd64" && t.Process.BinInfo().GOOS != "freebsd") || t.Process.BinInfo().Arch.Name == "arm64"
+	return (t.Process.BinInfo().Arch.Name == "amd64" && t.Process.BinInfo().GOOS != "freebsd" && t.Process.BinInfo().GOOS != "openbsd") || t.Process.BinInfo().Arch.Name == "arm64"
+}
+
+// SupportsWatchpoints returns whether or not hardware watchpoints can be
+// set on the target.
+// Currently only AMD64 and linux/ARM64 targets support them, in particular
+// they are not implemented on loong64 and riscv64.
+func (t *Target) SupportsWatchpoints() bool {
+	bi := t.Process.BinInfo()
+	return bi.Arch.Name == "amd64" || (bi.Arch.Name == "arm64" && bi.GOOS == "linux")
 }
 
 // ClearCaches clears internal caches that should not survive a restart.
@@ -326,6 +491,9 @@ func (p *Target) SwitchThread(tid int) error {
 		return err
 	}
 	if th, ok := p.FindThread(tid); ok {
+		if p.ThreadRunning(th) {
+			return fmt.Errorf("thread %d is running", tid)
+		}
 		p.currentThread = th
 		p.selectedGoroutine, _ = GetG(p.CurrentThread())
 		return nil
@@ -338,6 +506,12 @@ func (p *Target) SwitchThread(tid int) error {
 // we were previously debugging.
 // If kill is true then the process will be killed when we detach.
 func (t *Target) Detach(kill bool) error {
+	if t.nonStop {
+		// threads must be stopped to be detached
+		if err := t.SetNonStop(false); err != nil && !kill {
+			return err
+		}
+	}
 	if !kill {
 		if t.asyncPreemptChanged {
 			setAsyncPreemptOff(t, t.asyncPreemptOff)
@@ -404,13 +578,20 @@ func (t *Target) createUnrecoveredPanicBreakpoint() {
 	}
 }
 
-// createFatalThrowBreakpoint creates the a breakpoint as runtime.fatalthrow.
+// createFatalThrowBreakpoint creates the fatal throw breakpoint on
+// runtime.throw, used for errors internal to the runtime, and runtime.fatal,
+// used for errors caused by the program (for example concurrent map
+// writes). The message is loaded from the argument s of both functions.
 func (t *Target) createFatalThrowBreakpoint() {
-	fatalpcs, err := FindFunctionLocation(t.Process, "runtime.throw", 0)
-	if err == nil {
+	for _, fnName := range []string{"runtime.throw", "runtime.fatal"} {
+		fatalpcs, err := FindFunctionLocation(t.Process, fnName, 0)
+		if err != nil {
+			continue
+		}
 		bp, err := t.SetBreakpoint(fatalThrowID, fatalpcs[0], UserBreakpoint, nil)
 		if err == nil {
 			bp.Name = FatalThrow
+			bp.Variables = []string{"s"}
 		}
 	}
 }
@@ -425,46 +606,14 @@ func (t *Target) CurrentThread() Thread {
 type UProbeTraceResult struct {
 	FnAddr       int
 	GoroutineID  int
+	IsRet        bool      // The event was generated when the function returned.
+	Time         time.Time // Time the event was received from the eBPF program.
 	InputParams  []*Variable
 	ReturnParams []*Variable
 }
 
 func (t *Target) GetBufferedTracepoints() []*UProbeTraceResult {
-	var results []*UProbeTraceResult
-	tracepoints := t.proc.GetBufferedTracepoints()
-	convertInputParamToVariable := func(ip *ebpf.RawUProbeParam) *Variable {
-		v := &Variable{}
-		v.RealType = ip.RealType
-		v.Len = ip.Len
-		v.Base = ip.Base
-		v.Addr = ip.Addr
-		v.Kind = ip.Kind
-
-		cachedMem := CreateLoadedCachedMemory(ip.Data)
-		compMem, _ := CreateCompositeMemory(cachedMem, t.BinInfo().Arch, op.DwarfRegisters{}, ip.Pieces)
-		v.mem = compMem
-
-		// Load the value here so that we don't have to export
-		// loadValue outside of proc.
-		v.loadValue(loadFullValue)
-
-		return v
-	}
-	for _, tp := range tracepoints {
-		r := &UProbeTraceResult{}
-		r.FnAddr = tp.FnAddr
-		r.GoroutineID = tp.GoroutineID
-		for _, ip := range tp.InputParams {
-			v := convertInputParamToVariable(ip)
-			r.InputParams = append(r.InputParams, v)
-		}
-		for _, ip := range tp.ReturnParams {
-			v := convertInputParamToVariable(ip)
-			r.ReturnParams = append(r.ReturnParams, v)
-		}
-		results = append(results, r)
-	}
-	return results
+	return convertUProbeTraceResults(t.BinInfo(), t.proc.GetBufferedTracepoints())
 }
 
 // ResumeNotify specifies a channel that will be closed the next time
@@ -596,6 +745,10 @@ func (*dummyRecordingManipulation) GetDirection() Direction { return Forward }
 // When will always return an empty string and nil, not supported on native proc backend.
 func (*dummyRecordingManipulation) When() (string, error) { return "", nil }
 
+// WhenTicks will always return an error on the native proc backend,
+// only supported for recorded traces.
+func (*dummyRecordingManipulation) WhenTicks() (uint64, error) { return 0, ErrNotRecorded }
+
 // Checkpoint will always return an error on the native proc backend,
 // only supported for recorded traces.
 func (*dummyRecordingManipulation) Checkpoint(string) (int, error) { return -1, ErrNotRecorded }
@@ -81,7 +81,9 @@ func (dbp *Target) Continue() error {
 		trapthread, stopReason, contOnceErr := dbp.proc.ContinueOnce(dbp.cctx)
 		dbp.StopReason = stopReason
 
-		threads := dbp.ThreadList()
+		// In non-stop mode only the threads that stopped are considered, the
+		// others are still running and their state can not be read.
+		threads := dbp.stoppedThreads()
 		for _, thread := range threads {
 			if thread.Breakpoint().Breakpoint != nil {
 				thread.Breakpoint().Breakpoint.checkCondition(dbp, thread, thread.Breakpoint())
@@ -105,6 +107,11 @@ func (dbp *Target) Continue() error {
 			}
 			return contOnceErr
 		}
+		if dbp.StopReason == StopExec {
+			// The memory of the process has been replaced, the breakpoints and the
+			// debug info of the target are no longer valid, see ExecTarget.
+			return nil
+		}
 		if dbp.StopReason == StopLaunched {
 			dbp.ClearSteppingBreakpoints()
 		}
@@ -196,6 +203,10 @@ func (dbp *Target) Continue() error {
 			dbp.StopReason = StopCallReturned
 			return conditionErrors(threads)
 		}
+		if dbp.StopReason == StopNewChild {
+			// a child can be attached, see FollowExec
+			return conditionErrors(threads)
+		}
 	}
 }
 
@@ -214,10 +225,19 @@ func conditionErrors(threads []Thread) error {
 }
 
 // pick a new dbp.currentThread, with the following priority:
+// 	- a thread stopped at the fatal throw breakpoint, since the process is
+// 	  about to die
 // 	- a thread with an active stepping breakpoint
 // 	- a thread with an active breakpoint, prioritizing trapthread
 // 	- trapthread
+// In non-stop mode threads must only contain the threads that are stopped,
+// see stoppedThreads, trapthread is always stopped.
 func pickCurrentThread(dbp *Target, trapthread Thread, threads []Thread) error {
+	for _, th := range threads {
+		if bp := th.Breakpoint(); bp.Active && bp.Breakpoint != nil && bp.Name == FatalThrow {
+			return dbp.SwitchThread(th.ThreadID())
+		}
+	}
 	for _, th := range threads {
 		if bp := th.Breakpoint(); bp.Active && bp.Stepping {
 			return dbp.SwitchThread(th.ThreadID())
@@ -429,6 +449,68 @@ func (dbp *Target) StepInstruction() (err error) {
 	return nil
 }
 
+// Jump moves the program counter of the thread running the selected
+// goroutine to pc, without executing any code. The destination must be the
+// first instruction of a statement of the current function, not on the line
+// of the function declaration.
+// The stack of the goroutine is not changed, local variables keep their
+// current value, even when they are not yet initialized at pc.
+func (dbp *Target) Jump(pc uint64) error {
+	if _, err := dbp.Valid(); err != nil {
+		return err
+	}
+	if dbp.Breakpoints().HasSteppingBreakpoints() {
+		return errors.New("jump while nexting")
+	}
+	thread := dbp.CurrentThread()
+	if g := dbp.SelectedGoroutine(); g != nil {
+		if g.Thread == nil {
+			return errors.New("can not jump on a goroutine that is not running on a thread")
+		}
+		thread = g.Thread
+	}
+	regs, err := thread.Registers()
+	if err != nil {
+		return err
+	}
+	fn := dbp.BinInfo().PCToFunc(regs.PC())
+	if fn == nil {
+		return &ErrNoSourceForPC{regs.PC()}
+	}
+	if dst := dbp.BinInfo().PCToFunc(pc); dst != fn {
+		return fmt.Errorf("%#x is not in the current function %s", pc, fn.Name)
+	}
+	// The instructions on the line of the function declaration set up the
+	// stack frame of the function, executing them again corrupts it.
+	entryFile, entryLine, _ := dbp.BinInfo().PCToLine(fn.Entry)
+	if file, line, _ := dbp.BinInfo().PCToLine(pc); file == entryFile && line == entryLine {
+		return fmt.Errorf("%#x is in the prologue of %s", pc, fn.Name)
+	}
+	pcs, err := fn.cu.lineInfo.AllPCsBetween(fn.Entry, fn.End-1, "", -1)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, stmtpc := range pcs {
+		if stmtpc == pc {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("%#x is not the start of a statement", pc)
+	}
+
+	if err := setPC(thread, pc); err != nil {
+		return err
+	}
+	dbp.ClearCaches()
+	// The thread is no longer stopped at the breakpoint it hit, if any, but
+	// it could be stopped on a different one.
+	thread.Breakpoint().Clear()
+	return thread.SetCurrentBreakpoint(false)
+}
+
 // Set breakpoints at every line, and the return address. Also look for
 // a deferred function and set a breakpoint there too.
 // If stepInto is true it will also set breakpoints inside all
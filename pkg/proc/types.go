@@ -82,6 +82,10 @@ type loadDebugInfoMapsContext struct {
 	abstractOriginTable map[dwarf.Offset]int
 	knownPackageVars    map[string]struct{}
 	offsetToVersion     map[dwarf.Offset]uint8
+
+	// noNewFunctions is set when loading the deferred inlined calls of lazily
+	// loaded compile units, when bi.Functions can no longer be appended to.
+	noNewFunctions bool
 }
 
 func newLoadDebugInfoMapsContext(bi *BinaryInfo, image *Image, offsetToVersion map[dwarf.Offset]uint8) *loadDebugInfoMapsContext {
@@ -135,6 +139,7 @@ func runtimeTypeToDIE(_type *Variable, dataAddr uint64) (typ godwarf.Type, kind
 	if md != nil {
 		so := bi.moduleDataToImage(md)
 		if so != nil {
+			bi.loadDeferredTypeEntries()
 			if rtdie, ok := so.runtimeTypeToDIE[uint64(_type.Addr-md.types)]; ok {
 				typ, err := godwarf.ReadType(so.dwarf, so.index, rtdie.offset, so.typeCache)
 				if err != nil {
@@ -0,0 +1,240 @@
+package proc
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/go-delve/delve/pkg/dwarf/op"
+	"github.com/go-delve/delve/pkg/proc/internal/ebpf"
+)
+
+// AttachUProbes attaches the uprobes of the eBPF program loaded in ctx to
+// the entry point and to every return of fn in the process pid. The
+// instructions of fn are read from mem.
+func AttachUProbes(ctx *ebpf.EBPFContext, pid int, bi *BinaryInfo, mem MemoryReadWriter, fn *Function, goidOffset int64, args []ebpf.UProbeArgMap) error {
+	// We only allow up to 12 args for a BPF probe.
+	// 6 inputs + 6 outputs.
+	// Return early if we have more.
+	if len(args) > 12 {
+		return errors.New("too many arguments in traced function, max is 12 input+return")
+	}
+
+	key := fn.Entry
+	err := ctx.UpdateArgMap(key, goidOffset, args, bi.GStructOffset(), false)
+	if err != nil {
+		return err
+	}
+
+	img := bi.PCToImage(fn.Entry)
+	debugname := img.Path
+
+	// First attach a uprobe at all return addresses. We do this instead of using a uretprobe
+	// for two reasons:
+	// 1. uretprobes do not play well with Go
+	// 2. uretprobes seem to not restore the function return addr on the stack when removed, destroying any
+	//    kind of workaround we could come up with.
+	// TODO(derekparker): this whole thing could likely be optimized a bit.
+	f, err := elf.Open(img.Path)
+	if err != nil {
+		return fmt.Errorf("could not open elf file to resolve symbol offset: %w", err)
+	}
+	defer f.Close()
+
+	instructions, err := Disassemble(mem, nil, &BreakpointMap{}, bi, fn.Entry, fn.End)
+	if err != nil {
+		return err
+	}
+
+	var addrs []uint64
+	for _, instruction := range instructions {
+		if instruction.IsRet() {
+			addrs = append(addrs, instruction.Loc.PC)
+		}
+	}
+	addrs = append(addrs, FindDeferReturnCalls(instructions)...)
+	for _, addr := range addrs {
+		err := ctx.UpdateArgMap(addr, goidOffset, args, bi.GStructOffset(), true)
+		if err != nil {
+			return err
+		}
+		off, err := ebpf.AddressToOffset(f, addr)
+		if err != nil {
+			return err
+		}
+		err = ctx.AttachUprobe(pid, debugname, off)
+		if err != nil {
+			return err
+		}
+	}
+
+	off, err := ebpf.AddressToOffset(f, fn.Entry)
+	if err != nil {
+		return err
+	}
+
+	return ctx.AttachUprobe(pid, debugname, off)
+}
+
+// UProbeTracer traces the functions of a running process using only eBPF
+// uprobes, without attaching to it: the process is never stopped and its
+// memory is never read or written, the instructions of the traced
+// functions are read from the executable file instead.
+type UProbeTracer struct {
+	pid        int
+	bi         *BinaryInfo
+	ctx        *ebpf.EBPFContext
+	exe        *executableMemory
+	goidOffset int64
+}
+
+// NewUProbeTracer returns a tracer for the process pid, whose executable
+// was loaded in bi.
+func NewUProbeTracer(pid int, bi *BinaryInfo) (*UProbeTracer, error) {
+	goidOffset, err := ebpfGoidOffset(bi)
+	if err != nil {
+		return nil, err
+	}
+	exe, err := newExecutableMemory(bi.Images[0])
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := ebpf.LoadEBPFTracingProgram(bi.Images[0].Path)
+	if err != nil {
+		exe.Close()
+		return nil, err
+	}
+	return &UProbeTracer{pid: pid, bi: bi, ctx: ctx, exe: exe, goidOffset: goidOffset}, nil
+}
+
+// BinInfo returns the BinaryInfo of the traced executable.
+func (ut *UProbeTracer) BinInfo() *BinaryInfo {
+	return ut.bi
+}
+
+// Functions returns the names of the functions matching filter.
+func (ut *UProbeTracer) Functions(filter string) ([]string, error) {
+	regex, err := regexp.Compile(filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter argument: %s", err.Error())
+	}
+	funcs := []string{}
+	for _, f := range ut.bi.Functions {
+		if regex.MatchString(f.Name) {
+			funcs = append(funcs, f.Name)
+		}
+	}
+	return funcs, nil
+}
+
+// SetTracepoint attaches uprobes to the function specified by fnName.
+func (ut *UProbeTracer) SetTracepoint(fnName string) error {
+	fns, err := ut.bi.FindFunction(fnName)
+	if err != nil {
+		return err
+	}
+	for _, fn := range fns {
+		args, err := ebpfArgMap(ut.bi, fn)
+		if err != nil {
+			return err
+		}
+		if err := AttachUProbes(ut.ctx, ut.pid, ut.bi, ut.exe, fn, ut.goidOffset, args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetBufferedTracepoints returns the events received since the last call.
+func (ut *UProbeTracer) GetBufferedTracepoints() []*UProbeTraceResult {
+	return convertUProbeTraceResults(ut.bi, ut.ctx.GetBufferedTracepoints())
+}
+
+// Close detaches all uprobes from the process.
+func (ut *UProbeTracer) Close() error {
+	ut.ctx.Close()
+	return ut.exe.Close()
+}
+
+// convertUProbeTraceResults converts the events received from the eBPF
+// program to UProbeTraceResult values.
+func convertUProbeTraceResults(bi *BinaryInfo, tracepoints []ebpf.RawUProbeParams) []*UProbeTraceResult {
+	var results []*UProbeTraceResult
+	convertInputParamToVariable := func(ip *ebpf.RawUProbeParam) *Variable {
+		v := &Variable{}
+		v.RealType = ip.RealType
+		v.Len = ip.Len
+		v.Base = ip.Base
+		v.Addr = ip.Addr
+		v.Kind = ip.Kind
+
+		cachedMem := CreateLoadedCachedMemory(ip.Data)
+		compMem, _ := CreateCompositeMemory(cachedMem, bi.Arch, op.DwarfRegisters{}, ip.Pieces)
+		v.mem = compMem
+
+		// Load the value here so that we don't have to export
+		// loadValue outside of proc.
+		v.loadValue(loadFullValue)
+
+		return v
+	}
+	for _, tp := range tracepoints {
+		r := &UProbeTraceResult{}
+		r.FnAddr = tp.FnAddr
+		r.GoroutineID = tp.GoroutineID
+		r.Time = tp.Time
+		// Return probes are set on the RET instructions of the function and
+		// report their own address.
+		if fn := bi.PCToFunc(uint64(tp.FnAddr)); fn != nil {
+			r.IsRet = fn.Entry != uint64(tp.FnAddr)
+		}
+		// Only the parameters read by the probe that was hit are meaningful.
+		if !r.IsRet {
+			for _, ip := range tp.InputParams {
+				v := convertInputParamToVariable(ip)
+				r.InputParams = append(r.InputParams, v)
+			}
+		} else {
+			for _, ip := range tp.ReturnParams {
+				v := convertInputParamToVariable(ip)
+				r.ReturnParams = append(r.ReturnParams, v)
+			}
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// executableMemory reads the loadable segments of an executable from its
+// file, at the addresses where they are mapped in the process.
+type executableMemory struct {
+	f          *elf.File
+	staticBase uint64
+}
+
+func newExecutableMemory(image *Image) (*executableMemory, error) {
+	f, err := elf.Open(image.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &executableMemory{f: f, staticBase: image.StaticBase}, nil
+}
+
+func (mem *executableMemory) ReadMemory(buf []byte, addr uint64) (int, error) {
+	addr -= mem.staticBase
+	for _, prog := range mem.f.Progs {
+		if prog.Type == elf.PT_LOAD && addr >= prog.Vaddr && addr+uint64(len(buf)) <= prog.Vaddr+prog.Filesz {
+			return prog.ReadAt(buf, int64(addr-prog.Vaddr))
+		}
+	}
+	return 0, fmt.Errorf("could not read %#x from the executable", addr+mem.staticBase)
+}
+
+func (mem *executableMemory) WriteMemory(addr uint64, data []byte) (int, error) {
+	return 0, errors.New("can not write to the executable")
+}
+
+func (mem *executableMemory) Close() error {
+	return mem.f.Close()
+}
@@ -11,7 +11,6 @@ import (
 	"math"
 	"reflect"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 	"unsafe"
@@ -41,6 +40,8 @@ const (
 	maxMapBucketsFactor = 100 // Maximum numbers of map buckets to read for every requested map entry when loading variables through (*EvalScope).LocalVariables and (*EvalScope).FunctionArguments.
 
 	maxGoroutineUserCurrentDepth = 30 // Maximum depth used by (*G).UserCurrent to search its location
+
+	maxGoroutines = 1 << 22 // Maximum value of runtime.allglen we will accept
 )
 
 type floatSpecial uint8
@@ -123,6 +124,8 @@ type Variable struct {
 
 	// number of elements to skip when loading a map
 	mapSkip int
+	// maximum number of elements to load from a map, if greater than zero
+	mapLimit int
 
 	Children []Variable
 
@@ -300,6 +303,8 @@ func GetG(thread Thread) (*G, error) {
 // GoroutinesInfo also returns the next index to be used as 'start' argument
 // while scanning for all available goroutines, or -1 if there was an error
 // or if the index already reached the last possible value.
+// Goroutines are read only once between stops, later calls reuse them and
+// skip the dead goroutines found by earlier calls or by GoroutinesCount.
 func GoroutinesInfo(dbp *Target, start, count int) ([]*G, int, error) {
 	if _, err := dbp.Valid(); err != nil {
 		return nil, -1, err
@@ -311,52 +316,35 @@ func GoroutinesInfo(dbp *Target, start, count int) ([]*G, int, error) {
 		}
 	}
 
-	var (
-		threadg = map[int]*G{}
-		allg    []*G
-	)
-
-	threads := dbp.ThreadList()
-	for _, th := range threads {
-		g, _ := GetG(th)
-		if g != nil {
-			threadg[g.ID] = g
-		}
-	}
-
 	allgptr, allglen, err := dbp.gcache.getRuntimeAllg(dbp.BinInfo(), dbp.Memory())
 	if err != nil {
 		return nil, -1, err
 	}
+	dbp.gcache.growAllgs(allglen)
 
+	var allg []*G
 	for i := uint64(start); i < allglen; i++ {
 		if count != 0 && len(allg) >= count {
 			return allg, int(i), nil
 		}
-		gvar, err := newGVariable(dbp.CurrentThread(), allgptr+(i*uint64(dbp.BinInfo().Arch.PtrSize())), true)
-		if err != nil {
-			allg = append(allg, &G{Unreadable: err})
-			continue
-		}
-		g, err := gvar.parseG()
-		if err != nil {
-			allg = append(allg, &G{Unreadable: err})
-			continue
-		}
-		if thg, allocated := threadg[g.ID]; allocated {
-			loc, err := thg.Thread.Location()
+		e := &dbp.gcache.allgs[i]
+		if e.g == nil {
+			if e.state == allgDead {
+				continue
+			}
+			e.g, err = readAllgEntry(dbp, allgptr, i)
 			if err != nil {
+				e.g = nil
 				return nil, -1, err
 			}
-			g.Thread = thg.Thread
-			// Prefer actual thread location information.
-			g.CurrentLoc = *loc
-			g.SystemStack = thg.SystemStack
+			e.state = allgAlive
+			if e.g.Status == Gdead {
+				e.state = allgDead
+			}
 		}
-		if g.Status != Gdead {
-			allg = append(allg, g)
+		if e.state != allgDead {
+			allg = append(allg, e.g)
 		}
-		dbp.gcache.addGoroutine(g)
 	}
 	if start == 0 {
 		dbp.gcache.allGCache = allg
@@ -365,6 +353,112 @@ func GoroutinesInfo(dbp *Target, start, count int) ([]*G, int, error) {
 	return allg, -1, nil
 }
 
+// readAllgEntry reads the goroutine at index i of runtime.allgs, which
+// starts at allgptr. If the goroutine can not be read a G with the
+// Unreadable field set is returned.
+func readAllgEntry(dbp *Target, allgptr, i uint64) (*G, error) {
+	gvar, err := newGVariable(dbp.CurrentThread(), allgptr+(i*uint64(dbp.BinInfo().Arch.PtrSize())), true)
+	if err != nil {
+		return &G{Unreadable: err}, nil
+	}
+	g, err := gvar.parseG()
+	if err != nil {
+		return &G{Unreadable: err}, nil
+	}
+	if thg, allocated := dbp.threadGoroutines()[g.ID]; allocated {
+		loc, err := thg.Thread.Location()
+		if err != nil {
+			return nil, err
+		}
+		g.Thread = thg.Thread
+		// Prefer actual thread location information.
+		g.CurrentLoc = *loc
+		g.SystemStack = thg.SystemStack
+	}
+	dbp.gcache.addGoroutine(g)
+	return g, nil
+}
+
+// threadGoroutines returns the goroutines running on the threads of the
+// target, indexed by goroutine ID.
+func (dbp *Target) threadGoroutines() map[int]*G {
+	if dbp.gcache.threadGs != nil {
+		return dbp.gcache.threadGs
+	}
+	dbp.gcache.threadGs = map[int]*G{}
+	for _, th := range dbp.ThreadList() {
+		g, _ := GetG(th)
+		if g != nil {
+			dbp.gcache.threadGs[g.ID] = g
+		}
+	}
+	return dbp.gcache.threadGs
+}
+
+// GoroutinesCount returns the number of goroutines that GoroutinesInfo
+// would return when called with the same start and a count of zero.
+// Goroutines that have not already been read by GoroutinesInfo are not
+// read, only their status is.
+func GoroutinesCount(dbp *Target, start int) (int, error) {
+	if _, err := dbp.Valid(); err != nil {
+		return 0, err
+	}
+	if start == 0 && dbp.gcache.allGCache != nil {
+		return len(dbp.gcache.allGCache), nil
+	}
+
+	allgptr, allglen, err := dbp.gcache.getRuntimeAllg(dbp.BinInfo(), dbp.Memory())
+	if err != nil {
+		return 0, err
+	}
+	dbp.gcache.growAllgs(allglen)
+	dbp.gcache.loadStatusField(dbp.BinInfo())
+
+	n := 0
+	for i := uint64(start); i < allglen; i++ {
+		e := &dbp.gcache.allgs[i]
+		if e.state == allgUnknown {
+			status, ok := dbp.readAllgStatus(allgptr, i)
+			if !ok {
+				e.g, err = readAllgEntry(dbp, allgptr, i)
+				if err != nil {
+					e.g = nil
+					return 0, err
+				}
+				status = e.g.Status
+			}
+			e.state = allgAlive
+			if status == Gdead {
+				e.state = allgDead
+			}
+		}
+		if e.state != allgDead {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// readAllgStatus reads the status of the goroutine at index i of
+// runtime.allgs, which starts at allgptr, returns false if it can not be
+// read.
+func (dbp *Target) readAllgStatus(allgptr, i uint64) (uint64, bool) {
+	if dbp.gcache.statusSize == 0 {
+		return 0, false
+	}
+	mem := dbp.Memory()
+	ptrSize := int64(dbp.BinInfo().Arch.PtrSize())
+	gaddr, err := readUintRaw(mem, allgptr+i*uint64(ptrSize), ptrSize)
+	if err != nil || gaddr == 0 {
+		return 0, false
+	}
+	status, err := readUintRaw(mem, gaddr+uint64(dbp.gcache.statusOff), dbp.gcache.statusSize)
+	if err != nil {
+		return 0, false
+	}
+	return status, true
+}
+
 // FindGoroutine returns a G struct representing the goroutine
 // specified by `gid`.
 func FindGoroutine(dbp *Target, gid int) (*G, error) {
@@ -1381,6 +1475,86 @@ func convertToEface(srcv, dstv *Variable) error {
 	return dstv.writeEmptyInterface(typeAddr, srcv)
 }
 
+// convertToIface converts srcv into the non-empty interface type of dstv
+// and writes it to dstv.
+// Srcv must be a pointer shaped variable and the itab for the pair of types
+// must exist in the target, which is the case if the compiler generated a
+// conversion between them anywhere in the program.
+func (scope *EvalScope) convertToIface(srcv, dstv *Variable) error {
+	if _, isiface := dstv.RealType.(*godwarf.InterfaceType); !isiface {
+		return &typeConvErr{srcv.DwarfType, dstv.RealType}
+	}
+	typeAddr, typeKind, runtimeTypeFound, err := dwarfToRuntimeType(srcv.bi, srcv.mem, srcv.RealType)
+	if err != nil {
+		return err
+	}
+	if !runtimeTypeFound || typeKind&kindDirectIface == 0 {
+		return &typeConvErr{srcv.DwarfType, dstv.RealType}
+	}
+	interAddr, _, runtimeTypeFound, err := dwarfToRuntimeType(dstv.bi, dstv.mem, dstv.DwarfType)
+	if err != nil {
+		return err
+	}
+	if !runtimeTypeFound {
+		return &typeConvErr{srcv.DwarfType, dstv.RealType}
+	}
+	itab, err := scope.findItab(typeAddr, interAddr)
+	if err != nil {
+		return err
+	}
+	if itab == 0 {
+		return &typeConvErr{srcv.DwarfType, dstv.RealType}
+	}
+	return dstv.writeInterface(itab, srcv)
+}
+
+// findItab returns the address of the itab for the concrete type at
+// typeAddr and the interface type at interAddr, or 0 if the runtime doesn't
+// have one.
+func (scope *EvalScope) findItab(typeAddr, interAddr uint64) (uint64, error) {
+	itabTable, err := scope.findGlobal("runtime", "itabTable") // +rtype -var itabTable *itabTableType
+	if err != nil {
+		return 0, err
+	}
+	itabTable = itabTable.maybeDereference()
+	if itabTable.Unreadable != nil {
+		return 0, itabTable.Unreadable
+	}
+	sizev := itabTable.loadFieldNamed("size") // +rtype uintptr
+	if sizev == nil || sizev.Unreadable != nil {
+		return 0, errors.New("could not read runtime.itabTable")
+	}
+	entries, err := itabTable.structMember("entries") // +rtype anytype
+	if err != nil {
+		return 0, err
+	}
+	size, _ := constant.Uint64Val(sizev.Value)
+	ptrSize := int64(scope.BinInfo.Arch.PtrSize())
+	for i := uint64(0); i < size; i++ {
+		itab, err := readUintRaw(scope.Mem, entries.Addr+i*uint64(ptrSize), ptrSize)
+		if err != nil {
+			return 0, err
+		}
+		if itab == 0 {
+			continue
+		}
+		// The first two fields of an itab are the interface type and the
+		// concrete type.
+		inter, err := readUintRaw(scope.Mem, itab, ptrSize)
+		if err != nil {
+			return 0, err
+		}
+		typ, err := readUintRaw(scope.Mem, itab+uint64(ptrSize), ptrSize)
+		if err != nil {
+			return 0, err
+		}
+		if inter == interAddr && typ == typeAddr {
+			return itab, nil
+		}
+	}
+	return 0, nil
+}
+
 func readStringInfo(mem MemoryReadWriter, arch *Arch, addr uint64) (uint64, int64, error) {
 	// string data structure is always two ptrs in size. Addr, followed by len
 	// http://research.swtch.com/godata
@@ -1772,6 +1946,34 @@ func (v *Variable) writeEmptyInterface(typeAddr uint64, data *Variable) error {
 	return nil
 }
 
+// writeInterface writes a non-empty interface with the given itab and the
+// value of data to v.
+func (v *Variable) writeInterface(itab uint64, data *Variable) error {
+	ityp := resolveTypedef(&v.RealType.(*godwarf.InterfaceType).TypedefType).(*godwarf.StructType)
+	for _, f := range ityp.Field {
+		fv, err := v.toField(f)
+		if err != nil {
+			return err
+		}
+		switch f.Name {
+		case "tab":
+			err = fv.writeUint(itab, fv.RealType.Size())
+		case "data":
+			if data.Kind == reflect.Ptr && len(data.Children) > 0 {
+				// data could be the result of a conversion from an integer,
+				// which is not addressable.
+				err = fv.writeUint(data.Children[0].Addr, fv.RealType.Size())
+			} else {
+				err = fv.writeCopy(data)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (v *Variable) writeSlice(len, cap int64, base uint64) error {
 	for _, f := range v.RealType.(*godwarf.SliceType).Field {
 		switch f.Name {
@@ -1887,7 +2089,7 @@ func (v *Variable) loadMap(recurseLevel int, cfg LoadConfig) {
 		if errcount > maxErrCount {
 			break
 		}
-		if count >= cfg.MaxArrayValues || int64(count) >= v.Len {
+		if count >= cfg.MaxArrayValues || int64(count) >= v.Len || (v.mapLimit > 0 && count >= v.mapLimit) {
 			break
 		}
 	}
@@ -2249,6 +2451,7 @@ func (v *Variable) ConstDescr() string {
 	if v.bi == nil || (v.Flags&VariableConstant != 0) {
 		return ""
 	}
+	v.bi.loadDeferredTypes()
 	ctyp := v.bi.consts.Get(v.DwarfType)
 	if ctyp == nil {
 		return ""
@@ -2272,60 +2475,12 @@ func (v *Variable) ConstDescr() string {
 
 // registerVariableTypeConv implements type conversions for CPU register variables (REGNAME.int8, etc)
 func (v *Variable) registerVariableTypeConv(newtyp string) (*Variable, error) {
-	var n int = 0
-	for i := 0; i < len(v.reg.Bytes); i += n {
-		var child *Variable
-		switch newtyp {
-		case "int8":
-			child = newConstant(constant.MakeInt64(int64(int8(v.reg.Bytes[i]))), v.mem)
-			n = 1
-		case "int16":
-			child = newConstant(constant.MakeInt64(int64(int16(binary.LittleEndian.Uint16(v.reg.Bytes[i:])))), v.mem)
-			n = 2
-		case "int32":
-			child = newConstant(constant.MakeInt64(int64(int32(binary.LittleEndian.Uint32(v.reg.Bytes[i:])))), v.mem)
-			n = 4
-		case "int64":
-			child = newConstant(constant.MakeInt64(int64(binary.LittleEndian.Uint64(v.reg.Bytes[i:]))), v.mem)
-			n = 8
-		case "uint8":
-			child = newConstant(constant.MakeUint64(uint64(v.reg.Bytes[i])), v.mem)
-			n = 1
-		case "uint16":
-			child = newConstant(constant.MakeUint64(uint64(binary.LittleEndian.Uint16(v.reg.Bytes[i:]))), v.mem)
-			n = 2
-		case "uint32":
-			child = newConstant(constant.MakeUint64(uint64(binary.LittleEndian.Uint32(v.reg.Bytes[i:]))), v.mem)
-			n = 4
-		case "uint64":
-			child = newConstant(constant.MakeUint64(uint64(binary.LittleEndian.Uint64(v.reg.Bytes[i:]))), v.mem)
-			n = 8
-		case "float32":
-			a := binary.LittleEndian.Uint32(v.reg.Bytes[i:])
-			x := *(*float32)(unsafe.Pointer(&a))
-			child = newConstant(constant.MakeFloat64(float64(x)), v.mem)
-			n = 4
-		case "float64":
-			a := binary.LittleEndian.Uint64(v.reg.Bytes[i:])
-			x := *(*float64)(unsafe.Pointer(&a))
-			child = newConstant(constant.MakeFloat64(x), v.mem)
-			n = 8
-		default:
-			if n == 0 {
-				for _, pfx := range []string{"uint", "int"} {
-					if strings.HasPrefix(newtyp, pfx) {
-						n, _ = strconv.Atoi(newtyp[len(pfx):])
-						break
-					}
-				}
-				if n == 0 || popcnt(uint64(n)) != 1 {
-					return nil, fmt.Errorf("unknown CPU register type conversion to %q", newtyp)
-				}
-				n = n / 8
-			}
-			child = newConstant(constant.MakeString(fmt.Sprintf("%x", v.reg.Bytes[i:][:n])), v.mem)
-		}
-		v.Children = append(v.Children, *child)
+	lanes, n, err := RegisterLanes(v.reg.Bytes, newtyp)
+	if err != nil {
+		return nil, err
+	}
+	for _, lane := range lanes {
+		v.Children = append(v.Children, *newConstant(lane, v.mem))
 	}
 
 	v.loaded = true
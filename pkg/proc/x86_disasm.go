@@ -82,6 +82,11 @@ func resolveCallArgX86(inst *x86asm.Inst, instAddr uint64, currentGoroutine bool
 	switch inst.Op {
 	case x86asm.CALL, x86asm.LCALL, x86asm.JMP, x86asm.LJMP:
 		// ok
+	case x86asm.JA, x86asm.JAE, x86asm.JB, x86asm.JBE, x86asm.JCXZ, x86asm.JE, x86asm.JECXZ, x86asm.JG, x86asm.JGE, x86asm.JL, x86asm.JLE, x86asm.JNE, x86asm.JNO, x86asm.JNP, x86asm.JNS, x86asm.JO, x86asm.JP, x86asm.JRCXZ, x86asm.JS, x86asm.LOOP, x86asm.LOOPE, x86asm.LOOPNE:
+		// conditional jumps always have an immediate destination
+		if _, isimm := inst.Args[0].(x86asm.Imm); !isimm {
+			return nil
+		}
 	default:
 		return nil
 	}
@@ -121,9 +126,5 @@ func resolveCallArgX86(inst *x86asm.Inst, instAddr uint64, currentGoroutine bool
 		return nil
 	}
 
-	file, line, fn := bininfo.PCToLine(pc)
-	if fn == nil {
-		return &Location{PC: pc}
-	}
-	return &Location{PC: pc, File: file, Line: line, Fn: fn}
+	return pcToDestLoc(bininfo, pc)
 }
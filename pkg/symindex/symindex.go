@@ -0,0 +1,273 @@
+// Package symindex implements an index of symbol names (functions, types,
+// source files) that can be searched with regular expressions and fuzzy
+// queries without scanning all the names.
+package symindex
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// Index is a trigram index of a list of names.
+type Index struct {
+	names []string
+	lower []string // names converted to lower case
+
+	// trigrams maps each trigram to the sorted list of the indices of the
+	// names containing it.
+	trigrams map[uint32][]int32
+	// masks[i] has a bit set for each character of lower[i], see charMask.
+	masks []uint64
+}
+
+// New returns an index of names. The names are not copied and must not be
+// modified while the index is in use.
+func New(names []string) *Index {
+	idx := &Index{
+		names:    names,
+		lower:    make([]string, len(names)),
+		trigrams: make(map[uint32][]int32),
+		masks:    make([]uint64, len(names)),
+	}
+	for i, name := range names {
+		idx.lower[i] = strings.ToLower(name)
+		idx.masks[i] = charMask(idx.lower[i])
+		for j := 0; j+3 <= len(name); j++ {
+			t := trigram(name[j:])
+			p := idx.trigrams[t]
+			if len(p) == 0 || p[len(p)-1] != int32(i) {
+				idx.trigrams[t] = append(p, int32(i))
+			}
+		}
+	}
+	return idx
+}
+
+// Len returns the number of indexed names.
+func (idx *Index) Len() int {
+	return len(idx.names)
+}
+
+func trigram(s string) uint32 {
+	return uint32(s[0])<<16 | uint32(s[1])<<8 | uint32(s[2])
+}
+
+// charMask returns a bit mask with a bit set for each lower case letter,
+// digit and some of the punctuation characters that appear in s, all other
+// characters share the remaining bits.
+func charMask(s string) uint64 {
+	var m uint64
+	for i := 0; i < len(s); i++ {
+		m |= 1 << charBit(s[i])
+	}
+	return m
+}
+
+func charBit(c byte) uint {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return uint(c - 'a')
+	case c >= '0' && c <= '9':
+		return 26 + uint(c-'0')
+	}
+	switch c {
+	case '.':
+		return 36
+	case '/':
+		return 37
+	case '_':
+		return 38
+	case '*':
+		return 39
+	case '[':
+		return 40
+	case '(':
+		return 41
+	case '-':
+		return 42
+	}
+	if c >= 0x80 {
+		return 43
+	}
+	return 44
+}
+
+// Match returns the names matching re, in the order they were passed to
+// New. If re contains literal strings that must appear in every match only
+// the names containing their trigrams are tested, otherwise all names are.
+func (idx *Index) Match(re *regexp.Regexp) []string {
+	r := []string{}
+	candidates, all := idx.regexpCandidates(re.String())
+	if all {
+		for _, name := range idx.names {
+			if re.MatchString(name) {
+				r = append(r, name)
+			}
+		}
+		return r
+	}
+	for _, i := range candidates {
+		if re.MatchString(idx.names[i]) {
+			r = append(r, idx.names[i])
+		}
+	}
+	return r
+}
+
+// regexpCandidates returns the indices of the names that could match the
+// regular expression expr, if the index can not be used to restrict the
+// search all is true.
+func (idx *Index) regexpCandidates(expr string) (candidates []int32, all bool) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil, true
+	}
+	lits := requiredLiterals(re.Simplify())
+	all = true
+	for _, lit := range lits {
+		for j := 0; j+3 <= len(lit); j++ {
+			p := idx.trigrams[trigram(lit[j:])]
+			if all {
+				candidates = p
+				all = false
+			} else {
+				candidates = intersect(candidates, p)
+			}
+			if len(candidates) == 0 {
+				return nil, false
+			}
+		}
+	}
+	return candidates, all
+}
+
+// requiredLiterals returns literal strings, case sensitive, that appear in
+// every string matched by re.
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return nil
+		}
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var r []string
+		for _, sub := range re.Sub {
+			r = append(r, requiredLiterals(sub)...)
+		}
+		return r
+	}
+	return nil
+}
+
+// intersect returns the elements of the sorted slices a and b that appear
+// in both.
+func intersect(a, b []int32) []int32 {
+	r := []int32{}
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] < b[0]:
+			a = a[1:]
+		case a[0] > b[0]:
+			b = b[1:]
+		default:
+			r = append(r, a[0])
+			a, b = a[1:], b[1:]
+		}
+	}
+	return r
+}
+
+// Result is a name matching a fuzzy query.
+type Result struct {
+	Name  string
+	Score int // higher is better
+}
+
+// Scores of the kinds of fuzzy matches, the score of a match is further
+// reduced by the length of the name and, for subsequence matches, by the
+// number of characters skipped.
+const (
+	scoreExact       = 4000 // the whole name
+	scoreShortName   = 3000 // the part of the name after the last '.' or '/'
+	scoreShortPrefix = 2000 // a prefix of the short name
+	scoreSubstring   = 1000 // a substring of the name
+	scoreSubsequence = 0    // the characters of the query appear in order in the name
+)
+
+// Find returns the names matching query, ignoring case, sorted by
+// decreasing score. A name matches if the characters of query appear in it
+// in the same order, names where they appear contiguously, at the end of
+// the name or after a separator are ranked higher. At most limit results
+// are returned, if limit is zero or negative all of them are.
+func (idx *Index) Find(query string, limit int) []Result {
+	r := []Result{}
+	query = strings.ToLower(query)
+	if query == "" {
+		return r
+	}
+	mask := charMask(query)
+	for i, lower := range idx.lower {
+		if idx.masks[i]&mask != mask {
+			continue
+		}
+		if score, ok := fuzzyScore(lower, query); ok {
+			r = append(r, Result{Name: idx.names[i], Score: score})
+		}
+	}
+	sort.SliceStable(r, func(i, j int) bool {
+		if r[i].Score != r[j].Score {
+			return r[i].Score > r[j].Score
+		}
+		return r[i].Name < r[j].Name
+	})
+	if limit > 0 && len(r) > limit {
+		r = r[:limit]
+	}
+	return r
+}
+
+// fuzzyScore returns the score of name, in lower case, as a match for
+// query, see Find.
+func fuzzyScore(name, query string) (int, bool) {
+	penalty := len(name)
+	if penalty > 500 {
+		penalty = 500
+	}
+	if name == query {
+		return scoreExact, true
+	}
+	short := name
+	if i := strings.LastIndexAny(name, "./"); i >= 0 && i+1 < len(name) {
+		short = name[i+1:]
+	}
+	switch {
+	case short == query:
+		return scoreShortName - penalty, true
+	case strings.HasPrefix(short, query):
+		return scoreShortPrefix - penalty, true
+	case strings.Contains(name, query):
+		return scoreSubstring - penalty, true
+	}
+	skipped := 0
+	j := 0
+	for i := 0; i < len(name) && j < len(query); i++ {
+		if name[i] == query[j] {
+			j++
+		} else if j > 0 {
+			skipped++
+		}
+	}
+	if j < len(query) {
+		return 0, false
+	}
+	return scoreSubsequence - penalty - skipped, true
+}
@@ -0,0 +1,89 @@
+package symindex
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+var testNames = []string{
+	"main.main",
+	"main.(*Rectangle).Height",
+	"main.(*Rectangle).Width",
+	"runtime.main",
+	"runtime.mallocgc",
+	"runtime.gopark",
+	"fmt.Println",
+	"fmt.Sprintf",
+	"github.com/go-delve/delve/pkg/proc.(*Target).Continue",
+	"/usr/local/go/src/runtime/proc.go",
+	"/home/user/project/main.go",
+	"MAIN.Upper",
+}
+
+func TestMatch(t *testing.T) {
+	idx := New(testNames)
+	for _, expr := range []string{"", "main", "^main\\.", "runtime\\.ma.*", "(?i)main\\.upper", "Rect.*Width", "(proc|fmt)", "prin", "Print", "go$", "a{2,}", "(mall)+oc", "nothing here", "\\.go$"} {
+		re := regexp.MustCompile(expr)
+		want := []string{}
+		for _, name := range testNames {
+			if re.MatchString(name) {
+				want = append(want, name)
+			}
+		}
+		if got := idx.Match(re); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %q, want %q", expr, got, want)
+		}
+	}
+}
+
+func TestRegexpCandidates(t *testing.T) {
+	idx := New(testNames)
+	for _, tc := range []struct {
+		expr string
+		all  bool
+		n    int
+	}{
+		{"main", false, 5},
+		{"runtime\\.mall", false, 1},
+		{"Rect.*Width", false, 1},
+		{"(?i)main", true, 0},
+		{"ma", true, 0},
+		{"proc|fmt", true, 0},
+		{"xyzzy", false, 0},
+	} {
+		candidates, all := idx.regexpCandidates(tc.expr)
+		if all != tc.all || len(candidates) != tc.n {
+			t.Errorf("%q: got %d candidates (all=%v), want %d (all=%v)", tc.expr, len(candidates), all, tc.n, tc.all)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	idx := New(testNames)
+	names := func(rs []Result) []string {
+		r := []string{}
+		for _, x := range rs {
+			r = append(r, x.Name)
+		}
+		return r
+	}
+	for _, tc := range []struct {
+		query string
+		limit int
+		want  []string
+	}{
+		{"main", 3, []string{"main.main", "runtime.main", "MAIN.Upper"}},
+		{"MAIN.upper", 0, []string{"MAIN.Upper"}},
+		{"height", 0, []string{"main.(*Rectangle).Height"}},
+		{"rtmlc", 0, []string{"runtime.mallocgc"}},
+		{"sprf", 0, []string{"fmt.Sprintf"}},
+		{"cont", 0, []string{"github.com/go-delve/delve/pkg/proc.(*Target).Continue", "/usr/local/go/src/runtime/proc.go"}},
+		{"zzz", 0, []string{}},
+		{"", 0, []string{}},
+	} {
+		if got := names(idx.Find(tc.query, tc.limit)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %q, want %q", tc.query, got, tc.want)
+		}
+	}
+}
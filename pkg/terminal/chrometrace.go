@@ -0,0 +1,79 @@
+package terminal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// chromeTraceEvent is an event of the Chrome trace event format, see:
+// https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+type chromeTraceEvent struct {
+	Name  string            `json:"name"`
+	Phase string            `json:"ph"`
+	Ts    float64           `json:"ts"` // microseconds
+	Dur   float64           `json:"dur,omitempty"`
+	Pid   int               `json:"pid"`
+	Tid   int               `json:"tid"`
+	Args  map[string]string `json:"args,omitempty"`
+}
+
+// chromeTraceWriter writes each traced call as a complete event on the
+// track of its goroutine.
+type chromeTraceWriter struct {
+	w     *bufio.Writer
+	pid   int
+	start time.Time
+	n     int          // number of events written
+	seen  map[int]bool // goroutines for which a track name was written
+}
+
+func newChromeTraceWriter(w io.Writer, pid int) *chromeTraceWriter {
+	return &chromeTraceWriter{w: bufio.NewWriter(w), pid: pid, start: time.Now(), seen: make(map[int]bool)}
+}
+
+func (cw *chromeTraceWriter) ts(t time.Time) float64 {
+	return float64(t.Sub(cw.start).Nanoseconds()) / 1e3
+}
+
+func (cw *chromeTraceWriter) event(ev *chromeTraceEvent) {
+	if cw.n == 0 {
+		cw.w.WriteString("[\n")
+	} else {
+		cw.w.WriteString(",\n")
+	}
+	cw.n++
+	buf, _ := json.Marshal(ev)
+	cw.w.Write(buf)
+}
+
+// complete writes the event for call, which returned at time end.
+func (cw *chromeTraceWriter) complete(call *traceCall, end time.Time, goid int, retVals []traceValue) {
+	if !cw.seen[goid] {
+		cw.seen[goid] = true
+		cw.event(&chromeTraceEvent{Name: "thread_name", Phase: "M", Pid: cw.pid, Tid: goid, Args: map[string]string{"name": fmt.Sprintf("goroutine %d", goid)}})
+	}
+	ev := &chromeTraceEvent{Name: call.fn, Phase: "X", Ts: cw.ts(call.start), Dur: cw.ts(end) - cw.ts(call.start), Pid: cw.pid, Tid: goid}
+	if len(call.args) > 0 || len(retVals) > 0 {
+		ev.Args = make(map[string]string)
+		for _, v := range call.args {
+			ev.Args[v.Name] = v.Value
+		}
+		for _, v := range retVals {
+			ev.Args["return "+v.Name] = v.Value
+		}
+	}
+	cw.event(ev)
+	cw.w.Flush()
+}
+
+// close terminates the JSON array of events.
+func (cw *chromeTraceWriter) close() {
+	if cw.n == 0 {
+		cw.w.WriteString("[")
+	}
+	cw.w.WriteString("\n]\n")
+	cw.w.Flush()
+}
@@ -7,6 +7,7 @@ package terminal
 import (
 	"bufio"
 	"bytes"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"go/parser"
@@ -72,6 +73,10 @@ type command struct {
 	allowedPrefixes cmdPrefix
 	helpMsg         string
 	cmdFn           cmdfunc
+
+	// completer, if set, returns the completions for the last argument of
+	// the command, given the arguments typed so far.
+	completer func(args string) []string
 }
 
 // Returns true if the command string matches one of the aliases for this command
@@ -157,15 +162,20 @@ For recorded targets the command takes the following forms:
 
 	restart					resets to the start of the recording
 	restart [checkpoint]			resets the recording to the given checkpoint
-	restart -r [newargv...]	[redirects...]	re-records the target process
+	restart -r [options] [newargv...]	[redirects...]	re-records the target process
 	
 For live targets the command takes the following forms:
 
-	restart [newargv...] [redirects...]	restarts the process
+	restart [options] [newargv...] [redirects...]	restarts the process
 
 If newargv is omitted the process is restarted (or re-recorded) with the same argument vector.
 If -noargs is specified instead, the argument vector is cleared.
 
+The following options change the environment of the process, for this and the following restarts:
+
+	-env KEY=VALUE	sets the environment variable KEY, can be repeated, -env KEY removes it
+	-wd <dir>	changes the working directory
+
 A list of file redirections can be specified after the new argument list to override the redirections defined using the '--redirect' command line option. A syntax similar to Unix shells is used:
 
 	<input.txt	redirects the standard input of the target process from input.txt
@@ -193,6 +203,16 @@ For example:
 Optional [count] argument allows you to skip multiple lines.
 `},
 		{aliases: []string{"stepout", "so"}, group: runCmds, allowedPrefixes: revPrefix, cmdFn: c.stepout, helpMsg: "Step out of the current function."},
+		{aliases: []string{"jump", "j"}, group: runCmds, cmdFn: c.jump, helpMsg: `Moves the program counter of the current goroutine to another line of the current function.
+
+	jump <locspec>
+
+No code is executed: jumping backward runs again the statements between the destination and the current position, jumping forward skips them. The destination must be a statement of the current function, the stack of the goroutine is not changed, therefore variables declared in the skipped statements keep whatever value they had. For example:
+
+	jump +2
+	jump main.go:42
+
+See $GOPATH/src/github.com/go-delve/delve/Documentation/cli/locspec.md for the syntax of locspec.`},
 		{aliases: []string{"call"}, group: runCmds, cmdFn: c.call, helpMsg: `Resumes process, injecting a function call (EXPERIMENTAL!!!)
 	
 	call [-unsafe] <function call expression>
@@ -213,6 +233,20 @@ Current limitations:
 		{aliases: []string{"thread", "tr"}, group: goroutineCmds, cmdFn: thread, helpMsg: `Switch to the specified thread.
 
 	thread <id>`},
+		{aliases: []string{"targets"}, group: goroutineCmds, cmdFn: targets, helpMsg: `Lists and switches between the processes being debugged.
+
+	targets
+	targets <pid>
+
+Without arguments lists the processes being debugged, marking the current one, with a pid switches to that process.
+
+	targets follow-exec [on [regex] [-exclude regex]|off]
+
+Enables or disables following the children of the processes being debugged (only supported on linux with the native backend). While it is enabled every child that executes a program whose path matches regex, and doesn't match the -exclude regex, is attached and becomes the current process. Children that fork without executing a program are matched using the program of their parent. The process that spawned it stays stopped until the child exits, then it is resumed. Without arguments shows whether follow-exec is enabled.
+
+	targets scope <breakpoint name or id> <pid|all>
+
+Restricts a breakpoint to a single process or sets it in all of them. Breakpoints that are not restricted to a process are also set in the children attached by follow-exec.`},
 		{aliases: []string{"clear"}, group: breakCmds, cmdFn: clear, helpMsg: `Deletes breakpoint.
 
 	clear <breakpoint name or id>`},
@@ -226,7 +260,7 @@ If called with the locspec argument it will delete all the breakpoints matching
 toggle <breakpoint name or id>`},
 		{aliases: []string{"goroutines", "grs"}, group: goroutineCmds, cmdFn: goroutines, helpMsg: `List program goroutines.
 
-	goroutines [-u|-r|-g|-s] [-t [depth]] [-l] [-with loc expr] [-without loc expr] [-group argument]
+	goroutines [-u|-r|-g|-s] [-t [depth]] [-l] [-with loc expr] [-without loc expr] [-group argument] [-leaks [threshold]] [-diff] [-sort id|waittime]
 
 Print out info for every goroutine. The flag controls what information is shown along with each goroutine:
 
@@ -239,6 +273,15 @@ Print out info for every goroutine. The flag controls what information is shown
 
 If no flag is specified the default is -u, i.e. the first frame within the first 30 frames that is not executing a runtime private function.
 
+Goroutines that are blocked are shown with their wait reason and, if known, an approximation of how long they have been blocked. The runtime only records when a goroutine was blocked if a garbage collection cycle happened since.
+
+SORTING
+
+	goroutines -sort id
+	goroutines -sort waittime
+
+Sorts goroutines by ID (default) or by how long they have been blocked, longest first. Sorting is not applied to groups.
+
 FILTERING
 
 If -with or -without are specified only goroutines that match the given condition are returned.
@@ -281,7 +324,26 @@ Groups goroutines by the given location, running status or user classification,
 	goroutines -group label key
 
 Groups goroutines by the value of the label with the specified key.
+
+LEAK DETECTION
+
+	goroutines -leaks [threshold]
+
+Groups goroutines by the location of the go statement that created them, their wait reason and how long they have been blocked. Groups of goroutines that have been blocked on a channel operation, select statement or sync primitive for longer than threshold (default: 1m) are listed first and marked as possible leaks. The blocked duration is only known for goroutines that have been blocked during a garbage collection cycle and it is approximate.
+
+CHANGES BETWEEN STOPS
+
+	goroutines -diff
+
+Lists the goroutines that were created and the goroutines that exited since the previous stop of the target. Goroutines are only recorded after the first use of -diff and only if there are at most 10000 of them. New goroutines are printed along with the location of the go statement that created them and, if the target was started with GODEBUG=tracebackancestors=N, the stacktrace of the goroutine that created them. Can be combined with -u, -r, -g, -s, -t and -l but not with filtering or grouping.
 `},
+		{aliases: []string{"deadlock"}, group: goroutineCmds, cmdFn: deadlockCmd, helpMsg: `Finds goroutines that are waiting on each other.
+
+	deadlock
+
+Builds a wait-for graph of the goroutines blocked on channels, sync.Mutex, sync.RWMutex and sync.WaitGroup and prints each group of goroutines that can not make progress, with the objects they are waiting on.
+
+A goroutine is considered able to wake a blocked goroutine if its local variables contain a pointer to the object the blocked goroutine is waiting on, a blocked goroutine is deadlocked if all the goroutines that can wake it are deadlocked as well. Pointers reached through other heap objects are not followed, therefore the result is only an approximation. System goroutines are ignored.`},
 		{aliases: []string{"goroutine", "gr"}, group: goroutineCmds, allowedPrefixes: onPrefix, cmdFn: c.goroutine, helpMsg: `Shows or changes current goroutine
 
 	goroutine
@@ -298,11 +360,21 @@ Called with more arguments it will execute a command on the specified goroutine.
 Specifying -a prints all physical breakpoint, including internal breakpoints.`},
 		{aliases: []string{"print", "p"}, group: dataCmds, allowedPrefixes: onPrefix | deferredPrefix, cmdFn: printVar, helpMsg: `Evaluate an expression.
 
-	[goroutine <n>] [frame <m>] print [%format] <expression>
+	[goroutine <n>] [frame <m>] print [-S] [@<options>] [%format] <expression>
 
 See Documentation/cli/expr.md for a description of supported expressions.
 
-The optional format argument is a format specifier, like the ones used by the fmt package. For example "print %x v" will print v as an hexadecimal number.`},
+The optional format argument is a format specifier, like the ones used by the fmt package. For example "print %x v" will print v as an hexadecimal number.
+
+The optional @<options> argument overrides, for this expression only, the limits used to load its value, it is a comma separated list of:
+
+	depth=<n>	maximum depth of nested structs, arrays and pointers (max-variable-recurse)
+	maxstr=<n>	maximum length of strings (max-string-len)
+	maxarr=<n>	maximum number of elements of arrays, slices and maps (max-array-values)
+
+For example "print @depth=5,maxstr=4096 resp.Body".
+
+If -S is specified the Error or String method of the value is also called, using call injection, and its result is printed after the value. The call is only made on the topmost frame and is interrupted if it doesn't return within 2 seconds, if it can not be made the reason is printed instead. The Error or String method is always called for the types listed in the stringer-types configuration option.`},
 		{aliases: []string{"whatis"}, group: dataCmds, cmdFn: whatisCommand, helpMsg: `Prints type of an expression.
 
 	whatis <expression>`},
@@ -311,6 +383,20 @@ The optional format argument is a format specifier, like the ones used by the fm
 	[goroutine <n>] [frame <m>] set <variable> = <value>
 
 See Documentation/cli/expr.md for a description of supported expressions. Only numerical variables and pointers can be changed.`},
+		{aliases: []string{"set-reg"}, group: dataCmds, cmdFn: setRegCommand, helpMsg: `Changes the value of a CPU register of the current thread.
+
+	set-reg <register> [=] <value>
+	set-reg <register>.<type>[<lane>] [=] <value>
+
+The second form changes a single lane of a vector register, the type of the lanes is specified like in 'regs -lanes'. For example:
+
+	set-reg XMM0.uint64[1] = 0xff
+	set-reg V2.f32[0] 1.5
+	set-reg RIP = RIP+3
+
+Changing the program counter or the stack pointer prints a warning: the thread will resume from the new address without any adjustment of its stack, which can leave the goroutine in an inconsistent state.
+
+Register names are case insensitive. Changing registers is not supported for core files and recordings.`},
 		{aliases: []string{"sources"}, cmdFn: sources, helpMsg: `Print list of source files.
 
 	sources [<regex>]
@@ -326,6 +412,16 @@ If regex is specified only the functions matching it will be returned.`},
 	types [<regex>]
 
 If regex is specified only the types matching it will be returned.`},
+		{aliases: []string{"find"}, cmdFn: findCmd, helpMsg: `Searches functions, types and source files by name.
+
+	find [-n <max>] <query>
+
+Prints the functions, types and source files whose name contains all the characters of the query in the same order, ignoring case, best matches first. Exact matches, matches of the last component of a name (the part after the last '.' or '/') and matches of contiguous characters are ranked higher than the others. For example:
+
+	find main
+	find httpsrv
+
+	-n <max>	prints at most <max> symbols (default: 20, 0 means no limit)`},
 		{aliases: []string{"args"}, allowedPrefixes: onPrefix | deferredPrefix, group: dataCmds, cmdFn: args, helpMsg: `Print function arguments.
 
 	[goroutine <n>] [frame <m>] args [-v] [<regex>]
@@ -345,9 +441,11 @@ If regex is specified only local variables with a name matching it will be retur
 If regex is specified only package variables with a name matching it will be returned. If -v is specified more information about each package variable will be shown.`},
 		{aliases: []string{"regs"}, cmdFn: regs, group: dataCmds, helpMsg: `Print contents of CPU registers.
 
-	regs [-a]
+	regs [-a] [-lanes <type>]
+
+Argument -a shows more registers. Individual registers can also be displayed by 'print' and 'display'. See Documentation/cli/expr.md.
 
-Argument -a shows more registers. Individual registers can also be displayed by 'print' and 'display'. See Documentation/cli/expr.md.`},
+Argument -lanes shows the vector registers (XMM/YMM on amd64, V on arm64) as lanes of the specified type, one of int8, int16, int32, int64, uint8, uint16, uint32, uint64, float32 and float64 (or i8, ..., u64, f32, f64). It implies -a.`},
 		{aliases: []string{"exit", "quit", "q"}, cmdFn: exitCommand, helpMsg: `Exit the debugger.
 		
 	exit [-c]
@@ -367,18 +465,35 @@ For example:
 	list 40`},
 		{aliases: []string{"stack", "bt"}, allowedPrefixes: onPrefix, group: stackCmds, cmdFn: stackCommand, helpMsg: `Print stack trace.
 
-	[goroutine <n>] [frame <m>] stack [<depth>] [-full] [-offsets] [-defer] [-a <n>] [-adepth <depth>] [-mode <mode>]
+	[goroutine <n>] [frame <m>] stack [<depth>] [-full] [-offsets] [-defer] [-user|-all] [-a <n>] [-adepth <depth>] [-mode <mode>]
 
 	-full		every stackframe is decorated with the value of its local variables and arguments.
 	-offsets	prints frame offset of each frame.
-	-defer		prints deferred function call stack for each frame.
+	-defer		prints deferred function call stack for each frame, with the variables captured by deferred closures.
+	-user		hides the frames of the runtime, each run of consecutive runtime frames is replaced by a single line.
+	-all		shows all frames, overriding the stack-user-frames configuration option.
 	-a <n>		prints stacktrace of n ancestors of the selected goroutine (target process must have tracebackancestors enabled)
 	-adepth <depth>	configures depth of ancestor stacktrace
 	-mode <mode>	specifies the stacktrace mode, possible values are:
 			normal	- attempts to automatically switch between cgo frames and go frames
 			simple	- disables automatic switch between cgo and go
 			fromg	- starts from the registers stored in the runtime.g struct
+
+Frames executing a deferred call, running their deferred calls or in the middle of a panic are marked, after the function name, with "deferred call", "running deferred calls", "deferred call during panic" or "panicking".
 `},
+		{aliases: []string{"ancestors"}, group: stackCmds, cmdFn: c.ancestors, helpMsg: `Navigate the ancestors of a goroutine.
+
+	[goroutine <n>] ancestors [-depth <depth>]
+	[goroutine <n>] ancestors [-depth <depth>] <index>
+	[goroutine <n>] ancestors <index> <frame>
+
+The first form lists the ancestors of the selected goroutine, starting with the goroutine that created it, each one followed by the stacktrace recorded when it created its descendant.
+The second form switches to the ancestor with the given index if it is still running, otherwise it prints its recorded stacktrace.
+The third form shows the source code at the given frame of the recorded stacktrace of the ancestor.
+
+Only the PC of each frame is recorded, therefore local variables of ancestor frames can not be evaluated.
+The target process must have been started with GODEBUG=tracebackancestors=N, only N ancestors are recorded.
+The default depth of the recorded stacktraces is 10.`},
 		{aliases: []string{"frame"},
 			group: stackCmds,
 			cmdFn: func(t *Term, ctx callContext, arg string) error {
@@ -421,18 +536,29 @@ Executes the specified command (print, args, locals) in the context of the n-th
 		{aliases: []string{"source"}, cmdFn: c.sourceCommand, helpMsg: `Executes a file containing a list of delve commands
 
 	source <path>
-	
+	source -<ext> -
+
 If path ends with the .star extension it will be interpreted as a starlark script. See $GOPATH/src/github.com/go-delve/delve/Documentation/cli/starlark.md for the syntax.
 
-If path is a single '-' character an interactive starlark interpreter will start instead. Type 'exit' to exit.`},
+If path ends with the .lua extension it will be interpreted as a Lua script. The API calls available to starlark scripts are functions of the global table 'dlv', arguments can be passed positionally or as a single table with the argument names as keys, for example dlv.eval(nil, "x") or dlv.eval{Expr="x"}. Commands are executed with dlv.command, global functions with a name starting with command_ become commands.
+
+If path is a single '-' character an interactive starlark interpreter will start instead. Type 'exit' to exit. The second form starts an interactive interpreter for the scripting language of files with extension <ext>, for example 'source -lua -'.
+
+Interpreters for other scripting languages can be added by programs embedding the terminal with terminal.RegisterScriptEngine, scripts written in those languages are executed when path ends with the extension they are registered for.`},
 		{aliases: []string{"disassemble", "disass"}, cmdFn: disassCommand, helpMsg: `Disassembler.
 
-	[goroutine <n>] [frame <m>] disassemble [-a <start> <end>] [-l <locspec>]
+	[goroutine <n>] [frame <m>] disassemble [-s] [-raw] [-a <start> <end>] [-l <locspec>]
 
 If no argument is specified the function being executed in the selected stack frame will be executed.
 
 	-a <start> <end>	disassembles the specified address range
-	-l <locspec>		disassembles the specified function`},
+	-l <locspec>		disassembles the specified function
+	-s			interleaves the source code with the disassembly
+	-raw			disassembles memory even if it does not belong to any function
+
+With -raw the address range does not need to be part of a function, which is useful to inspect JIT generated code, PLT entries or corrupted PC values. Disassembly stops at the first address that can not be read. If -a is not specified the 64 bytes following the current PC (or the address specified by -l) are disassembled.
+
+The destination of call and branch instructions is annotated at the end of the instruction: jumps within the disassembled range are marked with an arrow (↑ for backward jumps, ↓ for forward jumps) followed by the destination address and line, calls and jumps to other functions are marked with → followed by the name of the destination function.`},
 		{aliases: []string{"on"}, group: breakCmds, cmdFn: c.onCmd, helpMsg: `Executes a command when a breakpoint is hit.
 
 	on <breakpoint name or id> <command>
@@ -496,10 +622,27 @@ Changes the value of a configuration parameter.
 
 Adds or removes a path substitution rule.
 
+When a source file of the program can not be found, or the file of a breakpoint does not match any source file of the program, Delve tries to infer a path substitution rule from the build information of the program, the current module, GOROOT, the module cache and GOPATH, and asks for confirmation before adding it.
+
 	config alias <command> <alias>
 	config alias <alias>
 
-Defines <alias> as an alias to <command> or removes an alias.`},
+Defines <alias> as an alias to <command> or removes an alias.
+
+	config alias <alias> = "<command line>"
+
+Defines <alias> as a parametrized alias, when <alias> is called it is replaced by <command line> where:
+
+	$1 ... $9	are replaced by the corresponding argument of the alias
+	${n:default}	is replaced by the n-th argument of the alias, or by default if it wasn't specified
+	$*		is replaced by all the arguments of the alias
+	$$		is replaced by $
+
+For example after:
+
+	config alias pbt = "goroutine $1 stack -full ${2:10}"
+
+the command 'pbt 5' is equivalent to 'goroutine 5 stack -full 10'. Arguments can be quoted with double quotes. Parametrized aliases can not redefine existing commands and the command line they are replaced by is not expanded further.`},
 
 		{aliases: []string{"edit", "ed"}, cmdFn: edit, helpMsg: `Open where you are in $DELVE_EDITOR or $EDITOR
 
@@ -527,29 +670,156 @@ For example:
     x -fmt hex -count 20 -size 1 -x &myVar
     x -fmt hex -count 20 -size 1 -x myPtrVar`},
 
+		{aliases: []string{"search"}, group: dataCmds, cmdFn: searchMemoryCmd, helpMsg: `Search the memory of the target process for a pattern.
+
+	search [-n <max>] -s <string> [<start> <end>]
+	search [-n <max>] -x <hex bytes> [<start> <end>]
+
+Scans all readable memory mappings of the target process and prints the address of every occurrence of the pattern along with the mapping that contains it. If <start> and <end> are specified only the addresses in the range [start, end) are searched.
+
+	-s <string>	searches for the specified string (use quotes if it contains spaces)
+	-x <hex bytes>	searches for the specified sequence of bytes, written in hexadecimal
+	-n <max>	stops after <max> matches (default: 100, 0 means no limit)
+
+For example:
+
+    search -s "magic"
+    search -x DEADBEEF
+    search -n 10 -x 0badc0de 0xc000000000 0xc000400000`},
+
+		{aliases: []string{"references"}, group: dataCmds, cmdFn: referencesCmd, helpMsg: `Finds the pointers to an object.
+
+	[goroutine <n>] [frame <m>] references [-n <max>] <expression>
+
+Searches the local variables of all goroutines, package variables and all allocated heap objects for pointers to the object referenced by the expression and prints each of them. If the expression is a pointer (or a map, channel, slice or string) the object it points to is used, otherwise the value of the expression itself. If the object is allocated on the heap pointers to any part of the heap object containing it are reported.
+
+References found in local and package variables are printed with the name of the variable, the path of the field containing the pointer and its type. Heap objects do not have type information and are printed with their address, size and the offset of the pointer.
+
+	-n <max>	stops after <max> references (default: 100, 0 means no limit)
+
+Pointers stored in temporary values that are not described by debug symbols are not reported.`},
+
+		{aliases: []string{"objects"}, group: dataCmds, cmdFn: objectsCmd, helpMsg: `Lists live heap objects of a type.
+
+	objects <type> [<limit>]
+
+Walks the heap of the target process and prints the address and a summary of the contents of each live object of the specified type, at most <limit> objects are printed (default: 100, 0 means no limit). For example:
+
+	objects main.node
+	objects net/http.Request 10
+
+The heap does not record the type of the objects it contains, the type of each object is inferred by following typed pointers starting from the local variables of all goroutines and from package variables. Objects only reachable through unsafe.Pointer values, maps or channels are not listed.`},
+
+		{aliases: []string{"chan"}, group: dataCmds, cmdFn: chanCmd, helpMsg: `Inspects a channel.
+
+	[goroutine <n>] [frame <m>] chan <expression>
+
+Prints the length, capacity and state of the channel the expression evaluates to, the elements in its buffer in the order they will be received and the goroutines blocked sending to or receiving from it. Use 'goroutine <id>' to switch to one of the blocked goroutines.`},
+
+		{aliases: []string{"mutex"}, group: dataCmds, cmdFn: mutexCmd, helpMsg: `Inspects a sync.Mutex or sync.RWMutex.
+
+	[goroutine <n>] [frame <m>] mutex <expression>
+
+Prints whether the mutex the expression evaluates to is locked, the goroutines waiting to lock it and the goroutines that likely hold it. The expression can also be a pointer to a mutex.
+
+The runtime does not record which goroutine holds a mutex: the goroutines that likely hold it are the goroutines that aren't waiting on it and whose local variables contain a pointer to it, or to the heap object containing it. Pointers reached through other heap objects are not followed, therefore the result is only an approximation. System goroutines are ignored.`},
+
+		{aliases: []string{"ctx"}, group: dataCmds, cmdFn: ctxCmd, helpMsg: `Shows the layers of a context.Context.
+
+	[goroutine <n>] [frame <m>] ctx <expression>
+
+Prints each layer of the context the expression evaluates to, starting from the expression itself and following its parents up to context.Background: the function that created it (WithCancel, WithDeadline, WithValue...), its deadline, whether it has been canceled and the cause, and the key/value pairs stored by WithValue.
+
+Layers of types not defined by package context are followed only if they embed their parent context.`},
+
 		{aliases: []string{"display"}, group: dataCmds, cmdFn: display, helpMsg: `Print value of an expression every time the program stops.
 
-	display -a [%format] <expression>
+	display -a [-changes] [%format] <expression>
 	display -d <number>
 
 The '-a' option adds an expression to the list of expression printed every time the program stops. The '-d' option removes the specified expression from the list.
 
+Values are compared with the ones they had at the previous stop: expressions whose value changed are highlighted, followed by the list of the fields, elements or pointed values that changed. If '-changes' is specified the expression is only printed when it changes and only its changed values are printed, along with their previous value.
+
 If display is called without arguments it will print the value of all expression in the list.`},
 
 		{aliases: []string{"dump"}, cmdFn: dump, helpMsg: `Creates a core dump from the current process state
 
-	dump <output file>
+	dump [-native] <output file>
+
+By default the core dump is written in ELF, even on systems (windows, macOS) where this is not customary. For environments other than linux/amd64 threads and registers are dumped in a format that only Delve can read back.
+
+With -native the core dump is written in the format native to the operating system of the target: a minidump on windows and a Mach-O core file on macOS, which can be opened by the debuggers of those systems. Delve can read back minidumps of windows/amd64 programs but not Mach-O core files.`},
+
+		{aliases: []string{"dump-heap"}, cmdFn: dumpHeap, helpMsg: `Writes the graph of the live heap objects to a file.
+
+	dump-heap [-format <format>] <output file>
+
+Walks the heap of the target process and writes its live objects, with their type and size, and the references between them to the output file. The format can be:
+
+	pprof	a pprof heap profile (default)
+	dot	a Graphviz DOT graph
+
+In the pprof profile the stack of each sample is the shortest path of references keeping the objects alive, starting from a local or package variable, so that 'go tool pprof -top' lists the types using the most memory and the graph views show what is retaining them. In the DOT graph every heap object and every variable referencing heap objects is a node and every reference is an edge.
+
+The type of heap objects is inferred like the 'objects' command does, objects only reachable through unsafe.Pointer values, maps or channels are not included.`},
+
+		{aliases: []string{"metrics"}, group: dataCmds, cmdFn: metricsCmd, helpMsg: `Prints statistics kept by the runtime.
+
+	metrics
+
+Prints the size of the heap, the number of GC cycles, the number of goroutines and the percentiles of the time goroutines spent waiting to be scheduled once runnable. The statistics are read from the memory of the target, which isn't resumed. Statistics that the runtime of the target doesn't keep are listed as not available.`},
+
+		{aliases: []string{"gc"}, group: dataCmds, cmdFn: gcCmd, helpMsg: `Prints the state of the garbage collector.
 
-The core dump is always written in ELF, even on systems (windows, macOS) where this is not customary. For environments other than linux/amd64 threads and registers are dumped in a format that only Delve can read back.`},
+	gc
+
+Prints the phase of the garbage collector, the size of the live heap, the heap goal, the heap size that will trigger the next GC cycle and the time and pause of the last cycle, read from the memory of the target. A GC cycle in progress while stepping can explain unexpected pauses.
+
+The heap goal and the trigger are derived from GOGC, the memory limit (GOMEMLIMIT) is not considered.`},
+
+		{aliases: []string{"profile"}, group: runCmds, cmdFn: profile, helpMsg: `Collects a CPU profile or an execution trace of the target.
+
+	profile cpu <duration> [<output file>]
+	profile trace [-open] <duration> [<output file>]
+
+Starts the CPU profiler or the execution tracer of the target, resumes it for the specified duration (for example 10s) and writes the profile collected to the output file, cpu.pprof or trace.out by default. CPU profiles can be read with 'go tool pprof' and execution traces with 'go tool trace', with -open 'go tool trace' is started on the trace once it is written. The target is stopped again once the duration expires, or earlier if it hits a breakpoint.
+
+The profiler is started and stopped by calling functions of runtime/pprof (runtime/pprof.StartCPUProfile and runtime/pprof.StopCPUProfile) or runtime/trace (runtime/trace.Start and runtime/trace.Stop) on the current goroutine (see the call command): the target must import the package and be stopped at a point where function calls are allowed. There is no fallback for targets that do not import runtime/pprof or runtime/trace.`},
+
+		{aliases: []string{"tui"}, cmdFn: tuiCommand, helpMsg: `Switches to a full-screen text user interface.
+
+	tui
+
+The screen is divided in panes showing the source and the disassembly around the current location, the arguments, local variables and display expressions of the current frame, the goroutines and the breakpoints. All panes are updated every time the program stops.
+
+Commands are entered at the prompt at the bottom of the screen and their output is shown above it. The following keys, followed by enter, can be used as shortcuts:
+
+	s	step
+	n	next
+	o	stepout
+	c	continue
+	r	restart
+	q	return to the normal command line
+
+An empty line repeats the last command.`},
 
 		{aliases: []string{"transcript"}, cmdFn: transcript, helpMsg: `Appends command output to a file.
 
 	transcript [-t] [-x] <output file>
-	transcript -off
+	transcript -structured [-t] <output file>
+	transcript [-structured] -off
 
 Output of Delve's command is appended to the specified output file. If '-t' is specified and the output file exists it is truncated. If '-x' is specified output to stdout is suppressed instead.
 
-Using the -off option disables the transcript.`},
+If '-structured' is specified a log of the session is appended to the output file instead, one JSON object per line. Every object has a "time" and a "kind" field, the kind is one of:
+
+	command		a command was executed, "command" is the command line
+	error		the command in "command" failed with "error"
+	rpc		an API call was made, "method", "args", "reply", "error" and "duration" describe it
+	stop		the target stopped, "state" is its state
+
+Using the -off option disables the transcript, or the structured transcript if '-structured' is also specified.`},
 	}
 
 	addrecorded := client == nil
@@ -590,6 +860,36 @@ The "note" is arbitrary text that can be used to identify the checkpoint, if it
 				helpMsg: `Deletes checkpoint.
 
 	clear-checkpoint <id>`,
+			},
+			command{
+				aliases: []string{"when"},
+				cmdFn:   when,
+				helpMsg: `Prints the current position in the recording.
+
+The position is reported as the recording event number and the number of ticks executed by the current thread since the start of the event, the same values used by rr's own "when" and "when-ticks" commands.`,
+			},
+			command{
+				aliases: []string{"seek"},
+				cmdFn:   seek,
+				helpMsg: `Moves the recording to the start of an event.
+
+	seek <event>
+	seek +<n>
+	seek -<n>
+
+The first form moves to the absolute event number, the other two move forward or backward by n events relative to the current event.`,
+			},
+			command{
+				aliases: []string{"bookmark", "bm"},
+				cmdFn:   bookmark,
+				helpMsg: `Manages named positions in the recording.
+
+	bookmark add <name>
+	bookmark goto <name>
+	bookmark clear <name>
+	bookmark [list]
+
+Bookmarks record the precise position (event and ticks) of the recording and "bookmark goto" returns exactly to it. "bookmark list" prints the bookmarks in recording order.`,
 			},
 			command{
 				aliases: []string{"rev"},
@@ -646,7 +946,20 @@ func (c *Commands) CallWithContext(cmdstr string, t *Term, ctx callContext) erro
 	if len(vals) > 1 {
 		args = strings.TrimSpace(vals[1])
 	}
-	return c.Find(cmdname, ctx.Prefix).cmdFn(t, ctx, args)
+	cmd := c.Find(cmdname, ctx.Prefix)
+	if tmpl, ok := t.parametrizedAlias(cmdname); ok && cmd.aliases[0] == "nocmd" {
+		expanded, err := expandAlias(tmpl, config.SplitQuotedFields(args, '"'))
+		if err != nil {
+			return fmt.Errorf("could not expand alias %s: %v", cmdname, err)
+		}
+		vals = strings.SplitN(strings.TrimSpace(expanded), " ", 2)
+		args = ""
+		if len(vals) > 1 {
+			args = strings.TrimSpace(vals[1])
+		}
+		cmd = c.Find(vals[0], ctx.Prefix)
+	}
+	return cmd.cmdFn(t, ctx, args)
 }
 
 // Call takes a command to execute.
@@ -747,7 +1060,9 @@ func threads(t *Term, ctx callContext, args string) error {
 		if state.CurrentThread != nil && state.CurrentThread.ID == th.ID {
 			prefix = "* "
 		}
-		if th.Function != nil {
+		if th.Running {
+			fmt.Fprintf(t.stdout, "%sThread %d (running)\n", prefix, th.ID)
+		} else if th.Function != nil {
 			fmt.Fprintf(t.stdout, "%sThread %d at %#v %s:%d %s\n",
 				prefix, th.ID, th.PC, t.formatPath(th.File),
 				th.Line, th.Function.Name())
@@ -787,14 +1102,149 @@ func thread(t *Term, ctx callContext, args string) error {
 	return nil
 }
 
+func targets(t *Term, ctx callContext, args string) error {
+	argv := strings.Fields(args)
+	if len(argv) == 0 {
+		tgts, err := t.client.ListTargets()
+		if err != nil {
+			return err
+		}
+		pid := t.client.ProcessPid()
+		for _, tgt := range tgts {
+			prefix := "  "
+			if tgt.Pid == pid {
+				prefix = "* "
+			}
+			fmt.Fprintf(t.stdout, "%sTarget %d %s\n", prefix, tgt.Pid, t.formatPath(tgt.Path))
+		}
+		return nil
+	}
+
+	switch argv[0] {
+	case "follow-exec":
+		return targetsFollowExec(t, argv[1:])
+	case "scope":
+		if len(argv) != 3 {
+			return errors.New("wrong number of arguments: targets scope <breakpoint name or id> <pid|all>")
+		}
+		bp, err := getBreakpointByIDOrName(t, argv[1])
+		if err != nil {
+			return err
+		}
+		bp.TargetPid = 0
+		if argv[2] != "all" {
+			bp.TargetPid, err = strconv.Atoi(argv[2])
+			if err != nil {
+				return err
+			}
+		}
+		return t.client.AmendBreakpoint(bp)
+	}
+
+	if len(argv) != 1 {
+		return errors.New("too many arguments")
+	}
+	pid, err := strconv.Atoi(argv[0])
+	if err != nil {
+		return err
+	}
+	oldPid := t.client.ProcessPid()
+	if _, err := t.client.SwitchTarget(pid); err != nil {
+		return err
+	}
+	fmt.Fprintf(t.stdout, "Switched from %d to %d\n", oldPid, pid)
+	return nil
+}
+
+func targetsFollowExec(t *Term, argv []string) error {
+	if len(argv) == 0 {
+		enabled, regex, exclude, err := t.client.FollowExecEnabled()
+		if err != nil {
+			return err
+		}
+		switch {
+		case !enabled:
+			fmt.Fprintf(t.stdout, "Follow exec mode is disabled\n")
+		case regex == "" && exclude == "":
+			fmt.Fprintf(t.stdout, "Follow exec mode is enabled\n")
+		default:
+			fmt.Fprintf(t.stdout, "Follow exec mode is enabled with regex %q, excluding %q\n", regex, exclude)
+		}
+		return nil
+	}
+	switch argv[0] {
+	case "off":
+		if len(argv) != 1 {
+			return errors.New("too many arguments")
+		}
+		return t.client.FollowExec(false, "", "")
+	case "on":
+		var regex, exclude string
+		argv = argv[1:]
+		for len(argv) > 0 {
+			if argv[0] == "-exclude" {
+				if len(argv) < 2 {
+					return errors.New("-exclude needs a regular expression")
+				}
+				exclude = argv[1]
+				argv = argv[2:]
+				continue
+			}
+			if regex != "" {
+				return errors.New("too many arguments")
+			}
+			regex = argv[0]
+			argv = argv[1:]
+		}
+		return t.client.FollowExec(true, regex, exclude)
+	default:
+		return fmt.Errorf("unknown argument %q, expected on or off", argv[0])
+	}
+}
+
 type byGoroutineID []*api.Goroutine
 
 func (a byGoroutineID) Len() int           { return len(a) }
 func (a byGoroutineID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a byGoroutineID) Less(i, j int) bool { return a[i].ID < a[j].ID }
 
-func printGoroutines(t *Term, indent string, gs []*api.Goroutine, fgl api.FormatGoroutineLoc, flags api.PrintGoroutinesFlags, depth int, state *api.DebuggerState) error {
-	for _, g := range gs {
+// sortGoroutines sorts gs using less, if stacks is not nil it is kept
+// parallel to gs.
+func sortGoroutines(gs []*api.Goroutine, stacks [][]api.Stackframe, less func(a, b *api.Goroutine) bool) {
+	sort.Sort(goroutinesSorter{gs, stacks, less})
+}
+
+type goroutinesSorter struct {
+	gs     []*api.Goroutine
+	stacks [][]api.Stackframe
+	less   func(a, b *api.Goroutine) bool
+}
+
+func (a goroutinesSorter) Len() int { return len(a.gs) }
+func (a goroutinesSorter) Swap(i, j int) {
+	a.gs[i], a.gs[j] = a.gs[j], a.gs[i]
+	if a.stacks != nil {
+		a.stacks[i], a.stacks[j] = a.stacks[j], a.stacks[i]
+	}
+}
+func (a goroutinesSorter) Less(i, j int) bool { return a.less(a.gs[i], a.gs[j]) }
+
+func goroutineIDLess(a, b *api.Goroutine) bool { return a.ID < b.ID }
+
+// goroutineWaitTimeLess sorts goroutines that have been waiting for
+// longer first, goroutines with the same wait time are sorted by ID.
+func goroutineWaitTimeLess(a, b *api.Goroutine) bool {
+	if a.WaitDuration != b.WaitDuration {
+		return a.WaitDuration > b.WaitDuration
+	}
+	return a.ID < b.ID
+}
+
+// printGoroutines prints the goroutines in gs. If flags contains
+// PrintGoroutinesStack and stacks is not nil stacks[i] is used as the
+// stacktrace of gs[i], otherwise stacktraces are requested to the server.
+func printGoroutines(t *Term, indent string, gs []*api.Goroutine, stacks [][]api.Stackframe, fgl api.FormatGoroutineLoc, flags api.PrintGoroutinesFlags, depth int, state *api.DebuggerState) error {
+	for i, g := range gs {
 		prefix := indent + "  "
 		if state.SelectedGoroutine != nil && g.ID == state.SelectedGoroutine.ID {
 			prefix = indent + "* "
@@ -804,9 +1254,15 @@ func printGoroutines(t *Term, indent string, gs []*api.Goroutine, fgl api.Format
 			writeGoroutineLabels(t.stdout, g, indent+"\t")
 		}
 		if flags&api.PrintGoroutinesStack != 0 {
-			stack, err := t.client.Stacktrace(g.ID, depth, 0, nil)
-			if err != nil {
-				return err
+			var stack []api.Stackframe
+			if stacks != nil {
+				stack = stacks[i]
+			} else {
+				var err error
+				stack, err = t.client.Stacktrace(g.ID, depth, 0, nil)
+				if err != nil {
+					return err
+				}
 			}
 			printStack(t, t.stdout, stack, indent+"\t", false)
 		}
@@ -824,10 +1280,17 @@ func goroutines(t *Term, ctx callContext, argstr string) error {
 	if err != nil {
 		return err
 	}
+	if flags&api.PrintGoroutinesDiff != 0 {
+		if len(filters) > 0 || group.GroupBy != api.GoroutineFieldNone {
+			return errors.New("-diff can not be used with filtering or grouping")
+		}
+		return goroutinesDiff(t, fgl, flags, depth, state)
+	}
 	var (
 		start         = 0
 		gslen         = 0
 		gs            []*api.Goroutine
+		stacks        [][]api.Stackframe
 		groups        []api.GoroutineGroup
 		tooManyGroups bool
 	)
@@ -837,14 +1300,22 @@ func goroutines(t *Term, ctx callContext, argstr string) error {
 			fmt.Fprintf(t.stdout, "interrupted\n")
 			return nil
 		}
-		gs, groups, start, tooManyGroups, err = t.client.ListGoroutinesWithFilter(start, batchSize, filters, &group)
+		if flags&api.PrintGoroutinesStack != 0 {
+			gs, stacks, groups, start, tooManyGroups, err = t.client.ListGoroutinesWithStacktraces(start, batchSize, filters, &group, depth)
+		} else {
+			gs, groups, start, tooManyGroups, err = t.client.ListGoroutinesWithFilter(start, batchSize, filters, &group)
+		}
 		if err != nil {
 			return err
 		}
 		if len(groups) > 0 {
 			for i := range groups {
 				fmt.Fprintf(t.stdout, "%s\n", groups[i].Name)
-				err = printGoroutines(t, "\t", gs[groups[i].Offset:][:groups[i].Count], fgl, flags, depth, state)
+				var groupStacks [][]api.Stackframe
+				if stacks != nil {
+					groupStacks = stacks[groups[i].Offset:][:groups[i].Count]
+				}
+				err = printGoroutines(t, "\t", gs[groups[i].Offset:][:groups[i].Count], groupStacks, fgl, flags, depth, state)
 				if err != nil {
 					return err
 				}
@@ -857,8 +1328,12 @@ func goroutines(t *Term, ctx callContext, argstr string) error {
 				fmt.Fprintf(t.stdout, "Too many groups\n")
 			}
 		} else {
-			sort.Sort(byGoroutineID(gs))
-			err = printGoroutines(t, "", gs, fgl, flags, depth, state)
+			if flags&api.PrintGoroutinesSortWaitTime != 0 {
+				sortGoroutines(gs, stacks, goroutineWaitTimeLess)
+			} else {
+				sortGoroutines(gs, stacks, goroutineIDLess)
+			}
+			err = printGoroutines(t, "", gs, stacks, fgl, flags, depth, state)
 			if err != nil {
 				return err
 			}
@@ -871,6 +1346,94 @@ func goroutines(t *Term, ctx callContext, argstr string) error {
 	return nil
 }
 
+// goroutinesDiff prints the goroutines that were created and the
+// goroutines that exited between the last two stops of the target.
+func goroutinesDiff(t *Term, fgl api.FormatGoroutineLoc, flags api.PrintGoroutinesFlags, depth int, state *api.DebuggerState) error {
+	if !t.recordStopGoroutines {
+		t.recordStopGoroutines = true
+		t.recordGoroutines()
+		return errors.New("no previous stop recorded, goroutines will be recorded from now on")
+	}
+	if t.prevStopGoroutines == nil || t.lastStopGoroutines == nil {
+		return fmt.Errorf("no previous stop recorded or more than %d goroutines", maxRecordedGoroutines)
+	}
+	var created, exited []*api.Goroutine
+	for id, g := range t.lastStopGoroutines {
+		if _, ok := t.prevStopGoroutines[id]; !ok {
+			created = append(created, g)
+		}
+	}
+	for id, g := range t.prevStopGoroutines {
+		if _, ok := t.lastStopGoroutines[id]; !ok {
+			exited = append(exited, g)
+		}
+	}
+	sort.Sort(byGoroutineID(created))
+	sort.Sort(byGoroutineID(exited))
+
+	fmt.Fprintf(t.stdout, "Created (%d):\n", len(created))
+	for _, g := range created {
+		if err := printGoroutines(t, "", []*api.Goroutine{g}, nil, fgl, flags, depth, state); err != nil {
+			return err
+		}
+		fmt.Fprintf(t.stdout, "\tCreated at: %s\n", t.formatLocation(g.GoStatementLoc))
+		ancestors, err := t.client.Ancestors(g.ID, 1, depth)
+		if err != nil {
+			continue
+		}
+		for _, ancestor := range ancestors {
+			fmt.Fprintf(t.stdout, "\tCreated by Goroutine %d:\n", ancestor.ID)
+			if ancestor.Unreadable != "" {
+				fmt.Fprintf(t.stdout, "\t\t%s\n", ancestor.Unreadable)
+				continue
+			}
+			printStack(t, t.stdout, ancestor.Stack, "\t\t", false)
+		}
+	}
+	fmt.Fprintf(t.stdout, "Exited (%d):\n", len(exited))
+	for _, g := range exited {
+		// the stacks of exited goroutines can not be read anymore
+		fmt.Fprintf(t.stdout, "  Goroutine %s\n", t.formatGoroutine(g, fgl))
+	}
+	return nil
+}
+
+func deadlockCmd(t *Term, ctx callContext, args string) error {
+	if args != "" {
+		return errors.New("too many arguments")
+	}
+	deadlocks, err := t.client.FindDeadlocks()
+	if err != nil {
+		return err
+	}
+	if len(deadlocks) == 0 {
+		fmt.Fprintf(t.stdout, "no deadlocks found\n")
+		return nil
+	}
+	for i, deadlock := range deadlocks {
+		fmt.Fprintf(t.stdout, "Deadlock %d (%d goroutines):\n", i+1, len(deadlock))
+		for _, dg := range deadlock {
+			fmt.Fprintf(t.stdout, "\tGoroutine %s\n", t.formatGoroutine(dg.Goroutine, api.FglUserCurrent))
+			for _, obj := range dg.Objects {
+				fmt.Fprintf(t.stdout, "\t\twaiting on %s %#x\n", obj.Kind, obj.Addr)
+			}
+			switch {
+			case dg.Global:
+				fmt.Fprintf(t.stdout, "\t\treferenced by a package variable\n")
+			case len(dg.Wakers) == 0:
+				fmt.Fprintf(t.stdout, "\t\tno other goroutine references it\n")
+			default:
+				wakers := make([]string, len(dg.Wakers))
+				for j := range dg.Wakers {
+					wakers[j] = strconv.Itoa(dg.Wakers[j])
+				}
+				fmt.Fprintf(t.stdout, "\t\tcan only be woken by goroutines %s\n", strings.Join(wakers, ", "))
+			}
+		}
+	}
+	return nil
+}
+
 func selectedGID(state *api.DebuggerState) int {
 	if state.SelectedGoroutine == nil {
 		return 0
@@ -919,6 +1482,103 @@ func (c *Commands) goroutine(t *Term, ctx callContext, argstr string) error {
 	return c.CallWithContext(args[1], t, ctx)
 }
 
+// maxAncestors is the maximum number of ancestors read by the ancestors
+// command, the runtime records at most GODEBUG=tracebackancestors of them.
+const maxAncestors = 1000
+
+func (c *Commands) ancestors(t *Term, ctx callContext, argstr string) error {
+	depth := 10
+	args := strings.Fields(argstr)
+	if len(args) >= 2 && args[0] == "-depth" {
+		var err error
+		depth, err = strconv.Atoi(args[1])
+		if err != nil || depth <= 0 {
+			return fmt.Errorf("wrong argument to -depth: %q", args[1])
+		}
+		args = args[2:]
+	}
+	if len(args) > 2 {
+		return errors.New("too many arguments to ancestors")
+	}
+
+	idx, frame := -1, -1
+	var err error
+	if len(args) >= 1 {
+		if idx, err = strconv.Atoi(args[0]); err != nil || idx < 0 {
+			return fmt.Errorf("invalid ancestor index %q", args[0])
+		}
+	}
+	if len(args) == 2 {
+		if frame, err = strconv.Atoi(args[1]); err != nil || frame < 0 {
+			return fmt.Errorf("invalid frame %q", args[1])
+		}
+		if frame+1 > depth {
+			depth = frame + 1
+		}
+	}
+
+	ancestors, err := t.client.Ancestors(ctx.Scope.GoroutineID, maxAncestors, depth)
+	if err != nil {
+		return err
+	}
+	if len(ancestors) == 0 {
+		fmt.Fprintln(t.stdout, "no ancestors recorded")
+		return nil
+	}
+
+	if idx < 0 {
+		for i := range ancestors {
+			printAncestor(t, i, &ancestors[i])
+		}
+		return nil
+	}
+
+	if idx >= len(ancestors) {
+		return fmt.Errorf("invalid ancestor index %d, goroutine has %d recorded ancestors", idx, len(ancestors))
+	}
+	ancestor := &ancestors[idx]
+
+	if frame >= 0 {
+		if ancestor.Unreadable != "" {
+			return errors.New(ancestor.Unreadable)
+		}
+		if frame >= len(ancestor.Stack) {
+			return fmt.Errorf("Invalid frame %d", frame)
+		}
+		loc := ancestor.Stack[frame]
+		fmt.Fprintf(t.stdout, "Ancestor %d (goroutine %d) frame %d: %s:%d (PC: %x)\n", idx, ancestor.ID, frame, t.formatPath(loc.File), loc.Line, loc.PC)
+		return printfile(t, loc.File, loc.Line, true)
+	}
+
+	oldState, err := t.client.GetState()
+	if err != nil {
+		return err
+	}
+	newState, err := t.client.SwitchGoroutine(int(ancestor.ID))
+	if err != nil {
+		// The type information gets lost in serialization, see Term.Run
+		if !strings.Contains(err.Error(), "unknown goroutine") {
+			return err
+		}
+		// the ancestor has exited, only its recorded stacktrace is available
+		fmt.Fprintf(t.stdout, "Goroutine %d is not running anymore\n", ancestor.ID)
+		printAncestor(t, idx, ancestor)
+		return nil
+	}
+	c.frame = 0
+	fmt.Fprintf(t.stdout, "Switched from %d to %d (thread %d)\n", selectedGID(oldState), ancestor.ID, newState.CurrentThread.ID)
+	return nil
+}
+
+func printAncestor(t *Term, idx int, ancestor *api.Ancestor) {
+	fmt.Fprintf(t.stdout, "Ancestor %d: Goroutine %d\n", idx, ancestor.ID)
+	if ancestor.Unreadable != "" {
+		fmt.Fprintf(t.stdout, "\t%s\n", ancestor.Unreadable)
+		return
+	}
+	printStack(t, t.stdout, ancestor.Stack, "\t", false)
+}
+
 // Handle "frame", "up", "down" commands.
 func (c *Commands) frameCommand(t *Term, ctx callContext, argstr string, direction frameDirection) error {
 	frame := 1
@@ -1050,8 +1710,8 @@ func (t *Term) formatGoroutine(g *api.Goroutine, fgl api.FormatGoroutineLoc) str
 			wr = fmt.Sprintf("unknown wait reason %d", g.WaitReason)
 		}
 		fmt.Fprintf(buf, " [%s", wr)
-		if g.WaitSince > 0 {
-			fmt.Fprintf(buf, " %s", time.Since(time.Unix(0, g.WaitSince)).String())
+		if g.WaitDuration > 0 {
+			fmt.Fprintf(buf, " %s", g.WaitDuration.Round(time.Millisecond).String())
 		}
 		fmt.Fprintf(buf, "]")
 	}
@@ -1143,14 +1803,20 @@ func restartRecorded(t *Term, ctx callContext, args string) error {
 	resetArgs := false
 	newArgv := []string{}
 	newRedirects := [3]string{}
+	var newEnv []string
+	newWd := ""
 	restartPos := ""
 
 	if len(v) > 0 {
 		if v[0] == "-r" {
 			rerecord = true
 			if len(v) == 2 {
-				var err error
-				resetArgs, newArgv, newRedirects, err = parseNewArgv(v[1])
+				rest, env, wd, err := parseRestartOptions(v[1])
+				if err != nil {
+					return err
+				}
+				newEnv, newWd = env, wd
+				resetArgs, newArgv, newRedirects, err = parseNewArgv(rest)
 				if err != nil {
 					return err
 				}
@@ -1163,7 +1829,7 @@ func restartRecorded(t *Term, ctx callContext, args string) error {
 		}
 	}
 
-	if err := restartIntl(t, rerecord, restartPos, resetArgs, newArgv, newRedirects); err != nil {
+	if err := restartIntl(t, rerecord, restartPos, resetArgs, newArgv, newRedirects, newEnv, newWd); err != nil {
 		return err
 	}
 
@@ -1187,30 +1853,60 @@ func parseOptionalCount(arg string) (int64, error) {
 }
 
 func restartLive(t *Term, ctx callContext, args string) error {
+	args, newEnv, newWd, err := parseRestartOptions(args)
+	if err != nil {
+		return err
+	}
 	resetArgs, newArgv, newRedirects, err := parseNewArgv(args)
 	if err != nil {
 		return err
 	}
 
-	if err := restartIntl(t, false, "", resetArgs, newArgv, newRedirects); err != nil {
+	if err := restartIntl(t, false, "", resetArgs, newArgv, newRedirects, newEnv, newWd); err != nil {
 		return err
 	}
 
 	fmt.Fprintln(t.stdout, "Process restarted with PID", t.client.ProcessPid())
+	t.recordGoroutines()
 	return nil
 }
 
-func restartIntl(t *Term, rerecord bool, restartPos string, resetArgs bool, newArgv []string, newRedirects [3]string) error {
-	discarded, err := t.client.RestartFrom(rerecord, restartPos, resetArgs, newArgv, newRedirects, false)
+func restartIntl(t *Term, rerecord bool, restartPos string, resetArgs bool, newArgv []string, newRedirects [3]string, newEnv []string, newWd string) error {
+	discarded, err := t.client.RestartFromWithEnv(rerecord, restartPos, resetArgs, newArgv, newRedirects, false, newEnv, newWd)
 	if err != nil {
 		return err
 	}
 	for i := range discarded {
 		fmt.Fprintf(t.stdout, "Discarded %s at %s: %v\n", formatBreakpointName(discarded[i].Breakpoint, false), t.formatBreakpointLocation(discarded[i].Breakpoint), discarded[i].Reason)
 	}
+	t.lastStopGoroutines, t.prevStopGoroutines = nil, nil
 	return nil
 }
 
+// parseRestartOptions parses the -env and -wd options at the start of the
+// arguments of the restart command and returns the remaining arguments.
+func parseRestartOptions(args string) (rest string, newEnv []string, newWd string, err error) {
+	for {
+		v := config.Split2PartsBySpace(args)
+		if v[0] != "-env" && v[0] != "-wd" {
+			return args, newEnv, newWd, nil
+		}
+		if len(v) < 2 || v[1] == "" {
+			return "", nil, "", fmt.Errorf("%s requires an argument", v[0])
+		}
+		w := config.Split2PartsBySpace(v[1])
+		if v[0] == "-env" {
+			newEnv = append(newEnv, w[0])
+		} else {
+			newWd = w[0]
+		}
+		args = ""
+		if len(w) > 1 {
+			args = w[1]
+		}
+	}
+}
+
 func parseNewArgv(args string) (resetArgs bool, newArgv []string, newRedirects [3]string, err error) {
 	if args == "" {
 		return false, nil, [3]string{}, nil
@@ -1316,6 +2012,7 @@ func (c *Commands) cont(t *Term, ctx callContext, args string) error {
 	}
 	defer t.onStop()
 	c.frame = 0
+	oldPid := t.client.ProcessPid()
 	stateChan := t.client.Continue()
 	var state *api.DebuggerState
 	for state = range stateChan {
@@ -1323,6 +2020,11 @@ func (c *Commands) cont(t *Term, ctx callContext, args string) error {
 			printcontextNoState(t)
 			return state.Err
 		}
+		if state.Pid != 0 && state.Pid != oldPid {
+			// see targets follow-exec
+			fmt.Fprintf(t.stdout, "Switched to process %d\n", state.Pid)
+			oldPid = state.Pid
+		}
 		printcontext(t, state)
 	}
 	printfile(t, state.CurrentThread.File, state.CurrentThread.Line, true)
@@ -1453,6 +2155,22 @@ func (c *Commands) stepInstruction(t *Term, ctx callContext, args string) error
 	return nil
 }
 
+func (c *Commands) jump(t *Term, ctx callContext, args string) error {
+	if len(args) == 0 {
+		return errors.New("not enough arguments")
+	}
+	if c.frame != 0 {
+		return errNotOnFrameZero
+	}
+	state, err := t.client.Jump(args, t.substitutePathRules())
+	if err != nil {
+		return err
+	}
+	printcontext(t, state)
+	printfile(t, state.CurrentThread.File, state.CurrentThread.Line, true)
+	return nil
+}
+
 func (c *Commands) revCmd(t *Term, ctx callContext, args string) error {
 	if len(args) == 0 {
 		return errors.New("not enough arguments")
@@ -1637,7 +2355,14 @@ func breakpoints(t *Term, ctx callContext, args string) error {
 		if bp.Disabled {
 			enabled = "(disabled)"
 		}
-		fmt.Fprintf(t.stdout, "%s %s at %v (%d)\n", formatBreakpointName(bp, true), enabled, t.formatBreakpointLocation(bp), bp.TotalHitCount)
+		if bp.WatchPending {
+			enabled = fmt.Sprintf("(pending until %s is called)", bp.WatchFunction)
+		}
+		scope := ""
+		if bp.TargetPid != 0 {
+			scope = fmt.Sprintf(" process %d", bp.TargetPid)
+		}
+		fmt.Fprintf(t.stdout, "%s %s at %v (%d)%s\n", formatBreakpointName(bp, true), enabled, t.formatBreakpointLocation(bp), bp.TotalHitCount, scope)
 
 		attrs := formatBreakpointAttrs("\t", bp, false)
 
@@ -1714,6 +2439,9 @@ func setBreakpoint(t *Term, ctx callContext, tracepoint bool, argstr string) ([]
 
 	requestedBp.Tracepoint = tracepoint
 	locs, err := t.client.FindLocation(ctx.Scope, spec, true, t.substitutePathRules())
+	if err != nil && t.inferSubstitutePathForBreakpoint(spec) {
+		locs, err = t.client.FindLocation(ctx.Scope, spec, true, t.substitutePathRules())
+	}
 	if err != nil {
 		if requestedBp.Name == "" {
 			return nil, err
@@ -1973,6 +2701,305 @@ loop:
 	return nil
 }
 
+func searchMemoryCmd(t *Term, ctx callContext, argstr string) error {
+	pattern, start, end, max, err := parseSearchArgs(argstr)
+	if err != nil {
+		return err
+	}
+	matches, unreadable, err := t.client.SearchMemory(pattern, start, end, max)
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		fmt.Fprintf(t.stdout, "%#x in %s\n", m.Addr, formatMemoryMapEntry(&m.Mapping))
+	}
+	for i := range unreadable {
+		fmt.Fprintf(t.stdout, "could not read %s\n", formatMemoryMapEntry(&unreadable[i]))
+	}
+	if max > 0 && len(matches) >= max {
+		fmt.Fprintf(t.stdout, "(stopped after %d matches, use -n to change the limit)\n", max)
+	} else if len(matches) == 0 {
+		fmt.Fprintf(t.stdout, "pattern not found\n")
+	}
+	return nil
+}
+
+func referencesCmd(t *Term, ctx callContext, args string) error {
+	max := 100
+	if strings.HasPrefix(args, "-n ") {
+		v := config.Split2PartsBySpace(strings.TrimSpace(args[len("-n "):]))
+		if len(v) != 2 {
+			return errors.New("not enough arguments")
+		}
+		n, err := strconv.Atoi(v[0])
+		if err != nil || n < 0 {
+			return fmt.Errorf("wrong argument: %q is not a valid number of references", v[0])
+		}
+		max, args = n, v[1]
+	}
+	if args == "" {
+		return errors.New("not enough arguments")
+	}
+	addr, size, refs, err := t.client.FindReferences(ctx.Scope, args, max)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(t.stdout, "Object %#x (size %d) has %d references:\n", addr, size, len(refs))
+	for i := range refs {
+		fmt.Fprintf(t.stdout, "\t%s\n", formatObjectReference(&refs[i]))
+	}
+	if max > 0 && len(refs) >= max {
+		fmt.Fprintf(t.stdout, "(stopped after %d references, use -n to change the limit)\n", max)
+	}
+	return nil
+}
+
+func formatObjectReference(ref *api.ObjectReference) string {
+	var buf strings.Builder
+	switch ref.Kind {
+	case api.StackReference:
+		fn := "?"
+		if ref.Function != nil {
+			fn = ref.Function.Name()
+		}
+		fmt.Fprintf(&buf, "goroutine %d frame %d %s: %s", ref.GoroutineID, ref.Frame, fn, ref.Path)
+	case api.GlobalReference:
+		fmt.Fprintf(&buf, "global %s", ref.Path)
+	case api.HeapReference:
+		fmt.Fprintf(&buf, "heap object %#x (size %d) +%#x", ref.ObjectAddr, ref.ObjectSize, ref.Addr-ref.ObjectAddr)
+	}
+	if ref.Type != "" {
+		fmt.Fprintf(&buf, " (%s)", ref.Type)
+	}
+	if ref.Addr != 0 {
+		fmt.Fprintf(&buf, " at %#x", ref.Addr)
+	}
+	return buf.String()
+}
+
+func chanCmd(t *Term, ctx callContext, args string) error {
+	if args == "" {
+		return errors.New("not enough arguments")
+	}
+	ci, err := t.client.ChanInfo(ctx.Scope, args, t.loadConfig())
+	if err != nil {
+		return err
+	}
+	closed := ""
+	if ci.Closed {
+		closed = ", closed"
+	}
+	fmt.Fprintf(t.stdout, "%s %#x len %d, cap %d%s\n", ci.Type, ci.Addr, ci.Len, ci.Cap, closed)
+	if len(ci.Buffer) > 0 {
+		fmt.Fprintf(t.stdout, "Buffer:\n")
+		for i := range ci.Buffer {
+			fmt.Fprintf(t.stdout, "\t%s = %s\n", ci.Buffer[i].Name, ci.Buffer[i].SinglelineString())
+		}
+		if int64(len(ci.Buffer)) < ci.Len {
+			fmt.Fprintf(t.stdout, "\t...+%d more\n", ci.Len-int64(len(ci.Buffer)))
+		}
+	}
+	printBlocked := func(what string, gs []*api.Goroutine) {
+		fmt.Fprintf(t.stdout, "Goroutines blocked %s: %d\n", what, len(gs))
+		for _, g := range gs {
+			fmt.Fprintf(t.stdout, "\tGoroutine %s\n", t.formatGoroutine(g, api.FglUserCurrent))
+		}
+	}
+	printBlocked("sending", ci.Senders)
+	printBlocked("receiving", ci.Receivers)
+	if len(ci.Senders) > 0 || len(ci.Receivers) > 0 {
+		fmt.Fprintf(t.stdout, "(use 'goroutine <id>' to switch to a goroutine)\n")
+	}
+	return nil
+}
+
+func mutexCmd(t *Term, ctx callContext, args string) error {
+	if args == "" {
+		return errors.New("not enough arguments")
+	}
+	mi, err := t.client.MutexInfo(ctx.Scope, args)
+	if err != nil {
+		return err
+	}
+	state := "unlocked"
+	if mi.Locked {
+		state = "locked"
+	}
+	if mi.Readers > 0 {
+		state += fmt.Sprintf(", %d readers", mi.Readers)
+	}
+	if mi.Starving {
+		state += ", starving"
+	}
+	fmt.Fprintf(t.stdout, "%s %#x %s\n", mi.Type, mi.Addr, state)
+	printGoroutines := func(what string, gs []*api.Goroutine) {
+		fmt.Fprintf(t.stdout, "%s: %d\n", what, len(gs))
+		for _, g := range gs {
+			fmt.Fprintf(t.stdout, "\tGoroutine %s\n", t.formatGoroutine(g, api.FglUserCurrent))
+		}
+	}
+	if mi.Locked || mi.Readers > 0 {
+		printGoroutines("Likely held by", mi.Holders)
+	}
+	printGoroutines("Waiting", mi.Waiters)
+	if strings.HasSuffix(mi.Type, "RWMutex") {
+		printGoroutines("Waiting to read", mi.ReadWaiters)
+	}
+	return nil
+}
+
+func ctxCmd(t *Term, ctx callContext, args string) error {
+	if args == "" {
+		return errors.New("not enough arguments")
+	}
+	layers, err := t.client.ContextChain(ctx.Scope, args, t.loadConfig())
+	if err != nil {
+		return err
+	}
+	d := digits(len(layers) - 1)
+	for i, l := range layers {
+		fmt.Fprintf(t.stdout, "%*d  %s %s %#x\n", d, i, l.Kind, l.Type, l.Addr)
+		ind := strings.Repeat(" ", d+2)
+		if l.Deadline != nil {
+			fmt.Fprintf(t.stdout, "%sdeadline: %s\n", ind, l.Deadline.Value)
+		}
+		if l.Key != nil {
+			fmt.Fprintf(t.stdout, "%skey: %s\n", ind, l.Key.SinglelineString())
+		}
+		if l.Value != nil {
+			fmt.Fprintf(t.stdout, "%svalue: %s\n", ind, l.Value.SinglelineString())
+		}
+		if l.Err != nil {
+			fmt.Fprintf(t.stdout, "%scanceled: %s\n", ind, l.Err.SinglelineString())
+			if l.Cause != nil {
+				fmt.Fprintf(t.stdout, "%scause: %s\n", ind, l.Cause.SinglelineString())
+			}
+		} else if l.Kind == "WithCancel" || l.Kind == "WithDeadline" || l.Kind == "AfterFunc" {
+			fmt.Fprintf(t.stdout, "%snot canceled\n", ind)
+		}
+	}
+	return nil
+}
+
+func objectsCmd(t *Term, ctx callContext, args string) error {
+	v := strings.Fields(args)
+	if len(v) < 1 || len(v) > 2 {
+		return errors.New("wrong number of arguments: objects <type> [<limit>]")
+	}
+	max := 100
+	if len(v) == 2 {
+		n, err := strconv.Atoi(v[1])
+		if err != nil || n < 0 {
+			return fmt.Errorf("wrong argument: %q is not a valid limit", v[1])
+		}
+		max = n
+	}
+	objs, err := t.client.ListHeapObjects(v[0], max, ShortLoadConfig)
+	if err != nil {
+		return err
+	}
+	for i := range objs {
+		fmt.Fprintf(t.stdout, "%#x\t%s\n", objs[i].Addr, objs[i].SinglelineString())
+	}
+	switch {
+	case len(objs) == 0:
+		fmt.Fprintf(t.stdout, "no live objects of type %s found\n", v[0])
+	case max > 0 && len(objs) >= max:
+		fmt.Fprintf(t.stdout, "(stopped after %d objects)\n", max)
+	}
+	return nil
+}
+
+func parseSearchArgs(argstr string) (pattern []byte, start, end uint64, max int, err error) {
+	v, err := argv.Argv(argstr,
+		func(s string) (string, error) {
+			return "", fmt.Errorf("Backtick not supported in '%s'", s)
+		},
+		nil)
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+	if len(v) != 1 {
+		return nil, 0, 0, 0, fmt.Errorf("illegal arguments '%s'", argstr)
+	}
+	args := v[0]
+	max = 100
+
+	var rest []string
+	for len(args) > 0 {
+		arg := args[0]
+		args = args[1:]
+		switch arg {
+		case "-s", "-x", "-n":
+			if len(args) == 0 {
+				return nil, 0, 0, 0, fmt.Errorf("expected argument after %s", arg)
+			}
+			val := args[0]
+			args = args[1:]
+			switch arg {
+			case "-s":
+				pattern = []byte(val)
+			case "-x":
+				val = strings.TrimPrefix(strings.TrimPrefix(val, "0x"), "0X")
+				pattern, err = hex.DecodeString(val)
+				if err != nil {
+					return nil, 0, 0, 0, fmt.Errorf("invalid hex pattern %q: %v", val, err)
+				}
+			case "-n":
+				max, err = strconv.Atoi(val)
+				if err != nil || max < 0 {
+					return nil, 0, 0, 0, fmt.Errorf("-n must be a non-negative integer")
+				}
+			}
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	if len(pattern) == 0 {
+		return nil, 0, 0, 0, fmt.Errorf("no pattern specified, use -s or -x")
+	}
+
+	switch len(rest) {
+	case 0:
+		// search everything
+	case 2:
+		start, err = strconv.ParseUint(rest[0], 0, 64)
+		if err != nil {
+			return nil, 0, 0, 0, fmt.Errorf("invalid start address %q: %v", rest[0], err)
+		}
+		end, err = strconv.ParseUint(rest[1], 0, 64)
+		if err != nil {
+			return nil, 0, 0, 0, fmt.Errorf("invalid end address %q: %v", rest[1], err)
+		}
+		if end <= start {
+			return nil, 0, 0, 0, fmt.Errorf("end address must be greater than start address")
+		}
+	default:
+		return nil, 0, 0, 0, fmt.Errorf("wrong number of arguments, expected a start and an end address")
+	}
+
+	return pattern, start, end, max, nil
+}
+
+func formatMemoryMapEntry(mme *api.MemoryMapEntry) string {
+	perm := []byte("---")
+	if mme.Read {
+		perm[0] = 'r'
+	}
+	if mme.Write {
+		perm[1] = 'w'
+	}
+	if mme.Exec {
+		perm[2] = 'x'
+	}
+	r := fmt.Sprintf("[%#x-%#x %s]", mme.Addr, mme.Addr+mme.Size, perm)
+	if mme.Filename != "" {
+		r += " " + mme.Filename
+	}
+	return r
+}
+
 func parseFormatArg(args string) (fmtstr, argsOut string) {
 	if len(args) < 1 || args[0] != '%' {
 		return "", args
@@ -1984,6 +3011,40 @@ func parseFormatArg(args string) (fmtstr, argsOut string) {
 	return v[0], v[1]
 }
 
+// parseLoadConfigArg parses the optional load configuration argument of
+// print, a list of comma separated overrides of cfg starting with '@', for
+// example "@depth=5,maxstr=4096".
+func parseLoadConfigArg(args string, cfg api.LoadConfig) (cfgOut api.LoadConfig, argsOut string, err error) {
+	if len(args) < 1 || args[0] != '@' {
+		return cfg, args, nil
+	}
+	v := strings.SplitN(args, " ", 2)
+	if len(v) == 1 {
+		return cfg, "", fmt.Errorf("not enough arguments")
+	}
+	for _, opt := range strings.Split(v[0][1:], ",") {
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 {
+			return cfg, "", fmt.Errorf("invalid load configuration option %q", opt)
+		}
+		n, err := strconv.Atoi(kv[1])
+		if err != nil || n < 0 {
+			return cfg, "", fmt.Errorf("argument of %q must be a non-negative number", kv[0])
+		}
+		switch kv[0] {
+		case "depth":
+			cfg.MaxVariableRecurse = n
+		case "maxstr":
+			cfg.MaxStringLen = n
+		case "maxarr":
+			cfg.MaxArrayValues = n
+		default:
+			return cfg, "", fmt.Errorf("unknown load configuration option %q", kv[0])
+		}
+	}
+	return cfg, strings.TrimSpace(v[1]), nil
+}
+
 func printVar(t *Term, ctx callContext, args string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("not enough arguments")
@@ -1992,13 +3053,106 @@ func printVar(t *Term, ctx callContext, args string) error {
 		ctx.Breakpoint.Variables = append(ctx.Breakpoint.Variables, args)
 		return nil
 	}
+	const stringerPrefix = "-S "
+	callStringer := false
+	if ctx.Prefix == noPrefix && strings.HasPrefix(args, stringerPrefix) {
+		callStringer = true
+		args = strings.TrimSpace(args[len(stringerPrefix):])
+	}
+	cfg, args, err := parseLoadConfigArg(args, t.loadConfig())
+	if err != nil {
+		return err
+	}
 	fmtstr, args := parseFormatArg(args)
-	val, err := t.client.EvalVariable(ctx.Scope, args, t.loadConfig())
+	val, err := t.client.EvalVariable(ctx.Scope, args, cfg)
 	if err != nil {
 		return err
 	}
 
 	fmt.Fprintln(t.stdout, val.MultilineString("", fmtstr))
+	if callStringer || t.isStringerType(val) {
+		printStringer(t, ctx, args)
+	}
+	return nil
+}
+
+// stringerCallTimeout is the maximum time print waits for the String or
+// Error method of a value to return, after which the target is halted.
+const stringerCallTimeout = 2 * time.Second
+
+// isStringerType returns true if the type of val is listed in the
+// stringer-types configuration option.
+func (t *Term) isStringerType(val *api.Variable) bool {
+	if t.conf == nil {
+		return false
+	}
+	for _, typ := range t.conf.StringerTypes {
+		if typ == val.Type || typ == strings.TrimPrefix(val.Type, "*") {
+			return true
+		}
+	}
+	return false
+}
+
+// printStringer calls the Error or String method of expr using call
+// injection and prints its result. If the method can not be called the
+// reason is printed instead.
+func printStringer(t *Term, ctx callContext, expr string) {
+	method := ""
+	for _, name := range []string{"Error", "String"} {
+		fn, err := t.client.EvalVariable(ctx.Scope, "("+expr+")."+name, ShortLoadConfig)
+		if err == nil && fn.Kind == reflect.Func {
+			method = name
+			break
+		}
+	}
+	if method == "" {
+		fmt.Fprintln(t.stdout, "(no String or Error method)")
+		return
+	}
+	if ctx.Scope.Frame != 0 || ctx.Scope.DeferredCall != 0 {
+		fmt.Fprintf(t.stdout, "(%s() not called: only supported in the topmost frame)\n", method)
+		return
+	}
+
+	timer := time.AfterFunc(stringerCallTimeout, func() { t.client.Halt() })
+	state, err := exitedToError(t.client.Call(ctx.Scope.GoroutineID, "("+expr+")."+method+"()", false))
+	if !timer.Stop() && err == nil {
+		err = fmt.Errorf("call did not complete within %v", stringerCallTimeout)
+	}
+	if err == nil && (state.CurrentThread == nil || !state.CurrentThread.CallReturn) {
+		err = errors.New("call did not complete")
+	}
+	if err != nil {
+		fmt.Fprintf(t.stdout, "(%s() not available: %v)\n", method, err)
+		return
+	}
+	retVals := state.CurrentThread.ReturnValues
+	if len(retVals) == 0 {
+		fmt.Fprintf(t.stdout, "(%s() not available: no return value)\n", method)
+		return
+	}
+	fmt.Fprintf(t.stdout, "%s(): %s\n", method, retVals[0].SinglelineString())
+}
+
+func setRegCommand(t *Term, ctx callContext, args string) error {
+	args = strings.TrimSpace(args)
+	var v []string
+	if strings.Contains(args, "=") {
+		v = strings.SplitN(args, "=", 2)
+	} else {
+		v = strings.SplitN(args, " ", 2)
+	}
+	if len(v) != 2 || strings.TrimSpace(v[0]) == "" || strings.TrimSpace(v[1]) == "" {
+		return errors.New("wrong number of arguments: set-reg <register> [=] <value>")
+	}
+	warning, err := t.client.SetRegister(0, strings.TrimSpace(v[0]), strings.TrimSpace(v[1]))
+	if err != nil {
+		return err
+	}
+	if warning != "" {
+		fmt.Fprintf(t.stdout, "Warning: %s\n", warning)
+	}
 	return nil
 }
 
@@ -2095,6 +3249,36 @@ func types(t *Term, ctx callContext, args string) error {
 	return t.printSortedStrings(t.client.ListTypes(args))
 }
 
+func findCmd(t *Term, ctx callContext, args string) error {
+	max := 20
+	if strings.HasPrefix(args, "-n ") {
+		v := config.Split2PartsBySpace(strings.TrimSpace(args[len("-n "):]))
+		if len(v) != 2 {
+			return errors.New("not enough arguments")
+		}
+		n, err := strconv.Atoi(v[0])
+		if err != nil || n < 0 {
+			return fmt.Errorf("wrong argument: %q is not a valid number of symbols", v[0])
+		}
+		max, args = n, v[1]
+	}
+	args = strings.TrimSpace(args)
+	if args == "" {
+		return errors.New("not enough arguments")
+	}
+	syms, err := t.client.FindSymbols(args, max)
+	if err != nil {
+		return err
+	}
+	for _, sym := range syms {
+		fmt.Fprintf(t.stdout, "%-6s %s\n", sym.Kind, sym.Name)
+	}
+	if max > 0 && len(syms) >= max {
+		fmt.Fprintf(t.stdout, "(stopped after %d symbols, use -n to change the limit)\n", max)
+	}
+	return nil
+}
+
 func parseVarArguments(args string, t *Term) (filter string, cfg api.LoadConfig) {
 	if v := config.Split2PartsBySpace(args); len(v) >= 1 && v[0] == "-v" {
 		if len(v) == 2 {
@@ -2149,8 +3333,22 @@ func vars(t *Term, ctx callContext, args string) error {
 
 func regs(t *Term, ctx callContext, args string) error {
 	includeFp := false
-	if args == "-a" {
-		includeFp = true
+	lanes := ""
+	v := strings.Fields(args)
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case "-a":
+			includeFp = true
+		case "-lanes":
+			if i+1 >= len(v) {
+				return errors.New("-lanes requires an argument")
+			}
+			i++
+			lanes = v[i]
+			includeFp = true
+		default:
+			return fmt.Errorf("unknown argument %q", v[i])
+		}
 	}
 	var regs api.Registers
 	var err error
@@ -2162,6 +3360,14 @@ func regs(t *Term, ctx callContext, args string) error {
 	if err != nil {
 		return err
 	}
+	if lanes != "" {
+		s, err := regs.LanesString(lanes)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(t.stdout, s)
+		return nil
+	}
 	fmt.Fprintln(t.stdout, regs)
 	return nil
 }
@@ -2183,7 +3389,11 @@ func stackCommand(t *Term, ctx callContext, args string) error {
 	if err != nil {
 		return err
 	}
-	printStack(t, t.stdout, stack, "", sa.offsets)
+	if sa.user || (!sa.all && t.conf != nil && t.conf.StackUserFrames) {
+		printUserStack(t, t.stdout, stack, "", sa.offsets)
+	} else {
+		printStack(t, t.stdout, stack, "", sa.offsets)
+	}
 	if sa.ancestors > 0 {
 		ancestors, err := t.client.Ancestors(ctx.Scope.GoroutineID, sa.ancestors, sa.ancestorDepth)
 		if err != nil {
@@ -2205,6 +3415,8 @@ type stackArgs struct {
 	depth   int
 	full    bool
 	offsets bool
+	user    bool
+	all     bool
 	opts    api.StacktraceOptions
 
 	ancestors     int
@@ -2237,6 +3449,10 @@ func parseStackArgs(argstr string) (stackArgs, error) {
 				r.offsets = true
 			case "-defer":
 				r.opts |= api.StacktraceReadDefers
+			case "-user":
+				r.user, r.all = true, false
+			case "-all":
+				r.user, r.all = false, true
 			case "-mode":
 				i++
 				if i >= len(args) {
@@ -2352,23 +3568,48 @@ func (c *Commands) sourceCommand(t *Term, ctx callContext, args string) error {
 		return fmt.Errorf("wrong number of arguments: source <filename>")
 	}
 
-	if filepath.Ext(args) == ".star" {
-		_, err := t.starlarkEnv.Execute(args, nil, "main", nil)
-		return err
+	if engine := t.scriptEngines[filepath.Ext(args)]; engine != nil {
+		return engine.Execute(args)
 	}
 
 	if args == "-" {
 		return t.starlarkEnv.REPL()
 	}
 
+	if argv := strings.Fields(args); len(argv) == 2 && strings.HasPrefix(argv[0], "-") && argv[1] == "-" {
+		engine := t.scriptEngines["."+argv[0][1:]]
+		if engine == nil {
+			return fmt.Errorf("unknown script language %q", argv[0][1:])
+		}
+		return engine.REPL()
+	}
+
 	return c.executeFile(t, args)
 }
 
-var errDisasmUsage = errors.New("wrong number of arguments: disassemble [-a <start> <end>] [-l <locspec>]")
+var errDisasmUsage = errors.New("wrong number of arguments: disassemble [-s] [-raw] [-a <start> <end>] [-l <locspec>]")
+
+// disasmRawWindow is the number of bytes disassembled by 'disassemble -raw'
+// when the end of the range is not specified.
+const disasmRawWindow = 64
 
 func disassCommand(t *Term, ctx callContext, args string) error {
 	var cmd, rest string
 
+	showSource, raw := false, false
+	var fields []string
+	for _, field := range strings.Fields(args) {
+		switch field {
+		case "-s":
+			showSource = true
+		case "-raw":
+			raw = true
+		default:
+			fields = append(fields, field)
+		}
+	}
+	args = strings.Join(fields, " ")
+
 	if args != "" {
 		argv := config.Split2PartsBySpace(args)
 		if len(argv) != 2 {
@@ -2395,6 +3636,14 @@ func disassCommand(t *Term, ctx callContext, args string) error {
 
 	switch cmd {
 	case "":
+		if raw {
+			pc, err := t.currentPC(ctx)
+			if err != nil {
+				return err
+			}
+			disasm, disasmErr = t.client.DisassembleRaw(ctx.Scope, pc, pc+disasmRawWindow, flavor)
+			break
+		}
 		locs, err := t.client.FindLocation(ctx.Scope, "+0", true, t.substitutePathRules())
 		if err != nil {
 			return err
@@ -2413,7 +3662,11 @@ func disassCommand(t *Term, ctx callContext, args string) error {
 		if err != nil {
 			return fmt.Errorf("wrong argument: %q is not a number", v[1])
 		}
-		disasm, disasmErr = t.client.DisassembleRange(ctx.Scope, uint64(startpc), uint64(endpc), flavor)
+		if raw {
+			disasm, disasmErr = t.client.DisassembleRaw(ctx.Scope, uint64(startpc), uint64(endpc), flavor)
+		} else {
+			disasm, disasmErr = t.client.DisassembleRange(ctx.Scope, uint64(startpc), uint64(endpc), flavor)
+		}
 	case "-l":
 		locs, err := t.client.FindLocation(ctx.Scope, rest, true, t.substitutePathRules())
 		if err != nil {
@@ -2422,7 +3675,11 @@ func disassCommand(t *Term, ctx callContext, args string) error {
 		if len(locs) != 1 {
 			return errors.New("expression specifies multiple locations")
 		}
-		disasm, disasmErr = t.client.DisassemblePC(ctx.Scope, locs[0].PC, flavor)
+		if raw {
+			disasm, disasmErr = t.client.DisassembleRaw(ctx.Scope, locs[0].PC, locs[0].PC+disasmRawWindow, flavor)
+		} else {
+			disasm, disasmErr = t.client.DisassemblePC(ctx.Scope, locs[0].PC, flavor)
+		}
 	default:
 		return errDisasmUsage
 	}
@@ -2431,11 +3688,64 @@ func disassCommand(t *Term, ctx callContext, args string) error {
 		return disasmErr
 	}
 
-	disasmPrint(disasm, t.stdout)
+	var sourceLine func(string, int) string
+	if showSource {
+		sourceLine = t.sourceLineReader()
+	}
+
+	disasmPrint(disasm, t.stdout, sourceLine)
 
 	return nil
 }
 
+// currentPC returns the PC of the selected stack frame, it does not require
+// the PC to belong to a function.
+func (t *Term) currentPC(ctx callContext) (uint64, error) {
+	locs, err := t.client.FindLocation(ctx.Scope, "+0", true, t.substitutePathRules())
+	if err == nil && len(locs) > 0 {
+		return locs[0].PC, nil
+	}
+	state, err := t.client.GetState()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case state.SelectedGoroutine != nil:
+		return state.SelectedGoroutine.CurrentLoc.PC, nil
+	case state.CurrentThread != nil:
+		return state.CurrentThread.PC, nil
+	}
+	return 0, errors.New("could not determine current PC")
+}
+
+// sourceLineReader returns a function that returns the text of the
+// specified line of a source file, or the empty string if the file can not
+// be read. Files are read only once.
+func (t *Term) sourceLineReader() func(string, int) string {
+	files := make(map[string][]string)
+	return func(filename string, line int) string {
+		lines, ok := files[filename]
+		if !ok {
+			path := t.substitutePath(filename)
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				foundPath, err := debuginfod.GetSource(t.client.BuildID(), filename)
+				if err == nil {
+					path = foundPath
+				}
+			}
+			buf, err := ioutil.ReadFile(path)
+			if err == nil {
+				lines = strings.Split(string(buf), "\n")
+			}
+			files[filename] = lines
+		}
+		if line <= 0 || line > len(lines) {
+			return ""
+		}
+		return lines[line-1]
+	}
+}
+
 func libraries(t *Term, ctx callContext, args string) error {
 	libs, err := t.client.ListDynamicLibraries()
 	if err != nil {
@@ -2459,6 +3769,26 @@ func printStack(t *Term, out io.Writer, stack []api.Stackframe, ind string, offs
 	api.PrintStack(t.formatPath, out, stack, ind, offsets, func(api.Stackframe) bool { return true })
 }
 
+// printUserStack prints stack replacing each run of runtime frames with a
+// single line.
+func printUserStack(t *Term, out io.Writer, stack []api.Stackframe, ind string, offsets bool) {
+	api.PrintStackCollapsed(t.formatPath, out, stack, ind, offsets, isRuntimeFrame, func(first, last int) string {
+		if first == last {
+			return fmt.Sprintf("... 1 runtime frame hidden (%d), use stack -all to show it", first)
+		}
+		return fmt.Sprintf("... %d runtime frames hidden (%d-%d), use stack -all to show them", last-first+1, first, last)
+	})
+}
+
+// isRuntimeFrame returns true if frame belongs to a function of the runtime.
+func isRuntimeFrame(frame api.Stackframe) bool {
+	if frame.Function == nil {
+		return false
+	}
+	name := frame.Function.Name()
+	return strings.HasPrefix(name, "runtime.") || strings.HasPrefix(name, "runtime/internal/") || strings.HasPrefix(name, "internal/runtime/")
+}
+
 func printcontext(t *Term, state *api.DebuggerState) {
 	for i := range state.Threads {
 		if (state.CurrentThread != nil) && (state.Threads[i].ID == state.CurrentThread.ID) {
@@ -2562,6 +3892,12 @@ func printcontextThread(t *Term, th *api.Thread) {
 	}
 
 	if th.Breakpoint.Tracepoint || th.Breakpoint.TraceReturn {
+		if t.traceOutput != nil {
+			t.traceOutput.tracepoint(t, th)
+			if t.traceOutput.format != TraceOutputText {
+				return
+			}
+		}
 		printTracepoint(t, th, bpname, fn, args, hasReturnValue)
 		return
 	}
@@ -2625,6 +3961,24 @@ func printBreakpointInfo(t *Term, th *api.Thread, tracepointOnNewline bool) {
 		fmt.Fprintf(t.stdout, "\t%s: %s\n", v.Name, v.MultilineString("\t", ""))
 	}
 
+	// Panics are printed starting from the oldest one, like the runtime does.
+	for i := len(bpi.Panics) - 1; i >= 0; i-- {
+		p := &bpi.Panics[i]
+		tracepointnl()
+		recovered := ""
+		if p.Recovered {
+			recovered = " [recovered]"
+		}
+		fmt.Fprintf(t.stdout, "\tpanic: %s%s\n", p.Value.SinglelineString(), recovered)
+		if p.Frame >= 0 {
+			fnname := ""
+			if p.Location.Function != nil {
+				fnname = p.Location.Function.Name()
+			}
+			fmt.Fprintf(t.stdout, "\t\tat %s() %s:%d (frame %d)\n", fnname, t.formatPath(p.Location.File), p.Location.Line, p.Frame)
+		}
+	}
+
 	for _, v := range bpi.Locals {
 		tracepointnl()
 		if *bp.LoadLocals == longLoadConfig {
@@ -2685,9 +4039,13 @@ func printfile(t *Term, filename string, line int, showArrow bool) error {
 	var file *os.File
 	path := t.substitutePath(filename)
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		foundPath, err := debuginfod.GetSource(t.client.BuildID(), filename)
-		if err == nil {
+		rootedPath := filepath.Join(t.SourceRoot, path)
+		if _, err := os.Stat(rootedPath); t.SourceRoot != "" && err == nil {
+			path = rootedPath
+		} else if foundPath, err := debuginfod.GetSource(t.client.BuildID(), filename); err == nil {
 			path = foundPath
+		} else if t.inferSubstitutePath(filename) {
+			path = t.substitutePath(filename)
 		}
 	}
 	file, err := os.OpenFile(path, 0, os.ModePerm)
@@ -2953,6 +4311,95 @@ func clearCheckpoint(t *Term, ctx callContext, args string) error {
 	return t.client.ClearCheckpoint(id)
 }
 
+func when(t *Term, ctx callContext, args string) error {
+	state, err := t.client.GetState()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(t.stdout, "%s\nCurrent tick: %d\n", state.When, state.Ticks)
+	return nil
+}
+
+func seek(t *Term, ctx callContext, args string) error {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		return errors.New("not enough arguments to seek")
+	}
+	var event uint64
+	if args[0] == '+' || args[0] == '-' {
+		delta, err := strconv.ParseUint(args[1:], 10, 64)
+		if err != nil {
+			return fmt.Errorf("seek argument must be an event number: %v", err)
+		}
+		state, err := t.client.GetState()
+		if err != nil {
+			return err
+		}
+		cur := state.Event
+		if args[0] == '+' {
+			event = cur + delta
+		} else if delta > cur {
+			event = 0
+		} else {
+			event = cur - delta
+		}
+	} else {
+		var err error
+		event, err = strconv.ParseUint(args, 10, 64)
+		if err != nil {
+			return fmt.Errorf("seek argument must be an event number: %v", err)
+		}
+	}
+	return restartRecorded(t, ctx, strconv.FormatUint(event, 10))
+}
+
+func bookmark(t *Term, ctx callContext, args string) error {
+	v := config.Split2PartsBySpace(args)
+	if len(v) == 0 || v[0] == "" || v[0] == "list" {
+		bms, err := t.client.ListBookmarks()
+		if err != nil {
+			return err
+		}
+		w := new(tabwriter.Writer)
+		w.Init(t.stdout, 4, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "Name\tEvent\tTicks\tCheckpoint")
+		for _, bm := range bms {
+			fmt.Fprintf(w, "%s\t%d\t%d\tc%d\n", bm.Name, bm.Event, bm.Ticks, bm.Checkpoint)
+		}
+		w.Flush()
+		return nil
+	}
+	if len(v) != 2 || strings.TrimSpace(v[1]) == "" {
+		return fmt.Errorf("not enough arguments to bookmark %s", v[0])
+	}
+	name := strings.TrimSpace(v[1])
+	switch v[0] {
+	case "add":
+		bm, err := t.client.AddBookmark(name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(t.stdout, "Bookmark %s created at event %d, ticks %d.\n", bm.Name, bm.Event, bm.Ticks)
+		return nil
+	case "goto":
+		if err := t.client.GotoBookmark(name); err != nil {
+			return err
+		}
+		state, err := t.client.GetState()
+		if err != nil {
+			return err
+		}
+		printcontext(t, state)
+		printfile(t, state.CurrentThread.File, state.CurrentThread.Line, true)
+		t.onStop()
+		return nil
+	case "clear":
+		return t.client.ClearBookmark(name)
+	default:
+		return fmt.Errorf("unknown bookmark subcommand %q", v[0])
+	}
+}
+
 func display(t *Term, ctx callContext, args string) error {
 	const (
 		addOption = "-a "
@@ -2960,16 +4407,21 @@ func display(t *Term, ctx callContext, args string) error {
 	)
 	switch {
 	case args == "":
-		t.printDisplays()
+		t.printDisplays(false)
 
 	case strings.HasPrefix(args, addOption):
 		args = strings.TrimSpace(args[len(addOption):])
+		changesOnly := false
+		if args == "-changes" || strings.HasPrefix(args, "-changes ") {
+			changesOnly = true
+			args = strings.TrimSpace(args[len("-changes"):])
+		}
 		fmtstr, args := parseFormatArg(args)
 		if args == "" {
 			return fmt.Errorf("not enough arguments")
 		}
-		t.addDisplay(args, fmtstr)
-		t.printDisplay(len(t.displays) - 1)
+		t.addDisplay(args, fmtstr, changesOnly)
+		t.printDisplay(len(t.displays)-1, false)
 
 	case strings.HasPrefix(args, delOption):
 		args = strings.TrimSpace(args[len(delOption):])
@@ -2986,10 +4438,19 @@ func display(t *Term, ctx callContext, args string) error {
 }
 
 func dump(t *Term, ctx callContext, args string) error {
+	native := false
+	if rest := strings.TrimPrefix(args, "-native"); rest != args && (rest == "" || rest[0] == ' ') {
+		native = true
+		args = strings.TrimSpace(rest)
+	}
 	if args == "" {
 		return fmt.Errorf("not enough arguments")
 	}
-	dumpState, err := t.client.CoreDumpStart(args)
+	coreDumpStart := t.client.CoreDumpStart
+	if native {
+		coreDumpStart = t.client.CoreDumpStartNative
+	}
+	dumpState, err := coreDumpStart(args)
 	if err != nil {
 		return err
 	}
@@ -3015,16 +4476,174 @@ func dump(t *Term, ctx callContext, args string) error {
 	return nil
 }
 
+func dumpHeap(t *Term, ctx callContext, args string) error {
+	format := "pprof"
+	if rest := strings.TrimPrefix(args, "-format "); rest != args {
+		v := strings.SplitN(strings.TrimSpace(rest), " ", 2)
+		format = v[0]
+		args = ""
+		if len(v) > 1 {
+			args = strings.TrimSpace(v[1])
+		}
+	}
+	if args == "" {
+		return fmt.Errorf("not enough arguments")
+	}
+	objects, bytes, err := t.client.DumpHeap(args, format)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(t.stdout, "%d objects (%d bytes) written to %s\n", objects, bytes, args)
+	return nil
+}
+
+func metricsCmd(t *Term, ctx callContext, args string) error {
+	if args != "" {
+		return errors.New("too many arguments")
+	}
+	m, err := t.client.GetRuntimeMetrics()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(t.stdout, "Heap:               live %d bytes, marked %d bytes, goal %d bytes\n", m.HeapLive, m.HeapMarked, m.HeapGoal)
+	fmt.Fprintf(t.stdout, "GC:                 %d cycles (%d forced), %v total pause\n", m.NumGC, m.NumForcedGC, m.GCPauseTotal)
+	fmt.Fprintf(t.stdout, "Goroutines:         %d (GOMAXPROCS %d)\n", m.Goroutines, m.GOMAXPROCS)
+	var total uint64
+	for _, b := range m.SchedLatency {
+		total += b.Count
+	}
+	if total > 0 {
+		// percentile returns the upper bound of the bucket containing the
+		// p-th percentile.
+		percentile := func(p uint64) time.Duration {
+			var n uint64
+			for _, b := range m.SchedLatency {
+				n += b.Count
+				if n*100 >= total*p {
+					return b.Max
+				}
+			}
+			return m.SchedLatency[len(m.SchedLatency)-1].Max
+		}
+		fmt.Fprintf(t.stdout, "Scheduling latency: %d samples, p50 < %v, p90 < %v, p99 < %v, max < %v\n", total, percentile(50), percentile(90), percentile(99), m.SchedLatency[len(m.SchedLatency)-1].Max)
+	}
+	if len(m.Unavailable) > 0 {
+		fmt.Fprintf(t.stdout, "Not available:      %s\n", strings.Join(m.Unavailable, ", "))
+	}
+	return nil
+}
+
+func gcCmd(t *Term, ctx callContext, args string) error {
+	if args != "" {
+		return errors.New("too many arguments")
+	}
+	s, err := t.client.GetGCState()
+	if err != nil {
+		return err
+	}
+	if s.InProgress {
+		fmt.Fprintf(t.stdout, "Phase:        %s (GC cycle %d in progress)\n", s.Phase, s.NumGC+1)
+	} else {
+		fmt.Fprintf(t.stdout, "Phase:        %s\n", s.Phase)
+	}
+	fmt.Fprintf(t.stdout, "Heap live:    %d bytes (%d bytes marked by the last cycle)\n", s.HeapLive, s.HeapMarked)
+	fmt.Fprintf(t.stdout, "Heap goal:    %d bytes\n", s.HeapGoal)
+	fmt.Fprintf(t.stdout, "Next trigger: %d bytes", s.HeapTrigger)
+	if !s.InProgress && s.HeapTrigger > s.HeapLive {
+		fmt.Fprintf(t.stdout, " (%d bytes from now)", s.HeapTrigger-s.HeapLive)
+	}
+	fmt.Fprintf(t.stdout, "\n")
+	if s.NumGC == 0 {
+		fmt.Fprintf(t.stdout, "Last GC:      none\n")
+	} else {
+		fmt.Fprintf(t.stdout, "Last GC:      cycle %d, ended %s, pause %v\n", s.NumGC, s.LastGC.Format(time.RFC3339Nano), s.LastPause)
+	}
+	if len(s.Unavailable) > 0 {
+		fmt.Fprintf(t.stdout, "Not available: %s\n", strings.Join(s.Unavailable, ", "))
+	}
+	return nil
+}
+
+func profile(t *Term, ctx callContext, args string) error {
+	v := strings.Fields(args)
+	if len(v) < 2 {
+		return fmt.Errorf("not enough arguments")
+	}
+	kind := v[0]
+	v = v[1:]
+	var dest string
+	switch kind {
+	case "cpu":
+		dest = "cpu.pprof"
+	case "trace":
+		dest = "trace.out"
+	default:
+		return fmt.Errorf("unknown profile %q", kind)
+	}
+	open := false
+	if kind == "trace" && v[0] == "-open" {
+		open = true
+		v = v[1:]
+	}
+	if len(v) < 1 {
+		return fmt.Errorf("not enough arguments")
+	}
+	duration, err := time.ParseDuration(v[0])
+	if err != nil {
+		return err
+	}
+	switch len(v) {
+	case 1:
+	case 2:
+		dest = v[1]
+	default:
+		return fmt.Errorf("too many arguments")
+	}
+	var buf []byte
+	if kind == "cpu" {
+		buf, err = t.client.ProfileCPU(ctx.Scope.GoroutineID, duration)
+	} else {
+		buf, err = t.client.ProfileTrace(ctx.Scope.GoroutineID, duration)
+	}
+	printcontextNoState(t)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(dest, buf, 0666); err != nil {
+		return err
+	}
+	if kind == "cpu" {
+		fmt.Fprintf(t.stdout, "CPU profile written to %s (%d bytes)\n", dest, len(buf))
+		return nil
+	}
+	fmt.Fprintf(t.stdout, "Execution trace written to %s (%d bytes)\n", dest, len(buf))
+	if open {
+		// go tool trace serves its user interface until it is killed, it is
+		// left running in the background.
+		cmd := exec.Command("go", "tool", "trace", dest)
+		cmd.Stdout = t.stdout
+		cmd.Stderr = t.stdout
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("could not start go tool trace: %v", err)
+		}
+		go cmd.Wait()
+	}
+	return nil
+}
+
 func transcript(t *Term, ctx callContext, args string) error {
 	argv := strings.SplitN(args, " ", -1)
 	truncate := false
 	fileOnly := false
 	disable := false
+	structured := false
 	path := ""
 	for _, arg := range argv {
 		switch arg {
 		case "-x":
 			fileOnly = true
+		case "-structured":
+			structured = true
 		case "-t":
 			truncate = true
 		case "-off":
@@ -3042,6 +4661,11 @@ func transcript(t *Term, ctx callContext, args string) error {
 		if path != "" {
 			return errors.New("-o option specified with an output path")
 		}
+		if structured {
+			err := t.structuredTranscript.Close()
+			t.structuredTranscript = nil
+			return err
+		}
 		return t.stdout.CloseTranscript()
 	}
 
@@ -3049,6 +4673,18 @@ func transcript(t *Term, ctx callContext, args string) error {
 		return errors.New("no output path specified")
 	}
 
+	var client *rpc2.RPCClient
+	if structured {
+		if fileOnly {
+			return errors.New("-x can not be used with -structured")
+		}
+		var ok bool
+		client, ok = t.client.(*rpc2.RPCClient)
+		if !ok {
+			return errors.New("structured transcripts are not supported by this client")
+		}
+	}
+
 	flags := os.O_APPEND | os.O_WRONLY | os.O_CREATE
 	if truncate {
 		flags |= os.O_TRUNC
@@ -3058,6 +4694,15 @@ func transcript(t *Term, ctx callContext, args string) error {
 		return err
 	}
 
+	if structured {
+		if err := t.structuredTranscript.Close(); err != nil {
+			fh.Close()
+			return err
+		}
+		t.structuredTranscript = newStructuredTranscript(fh, client)
+		return nil
+	}
+
 	if err := t.stdout.CloseTranscript(); err != nil {
 		return err
 	}
@@ -2,11 +2,14 @@ package terminal
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -21,6 +24,7 @@ import (
 	"github.com/go-delve/delve/pkg/goversion"
 	"github.com/go-delve/delve/pkg/logflags"
 	"github.com/go-delve/delve/pkg/proc/test"
+	"github.com/go-delve/delve/pkg/terminal/scriptbind"
 	"github.com/go-delve/delve/service"
 	"github.com/go-delve/delve/service/api"
 	"github.com/go-delve/delve/service/debugger"
@@ -276,6 +280,184 @@ func TestTraceWithName(t *testing.T) {
 	})
 }
 
+func TestTraceOutputFormat(t *testing.T) {
+	test.AllowRecording(t)
+	withTestTerminal("issue573", t, func(term *FakeTerminal) {
+		if err := term.SetTraceOutputFormat(TraceOutputJSON, nil); err != nil {
+			t.Fatal(err)
+		}
+		term.MustExec("trace foo")
+		out, _ := term.Exec("continue")
+		var recs []traceRecord
+		for _, line := range strings.Split(out, "\n") {
+			if !strings.HasPrefix(line, "{") {
+				continue
+			}
+			var rec traceRecord
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				t.Fatalf("could not parse %q: %v", line, err)
+			}
+			recs = append(recs, rec)
+		}
+		if len(recs) != 2 {
+			t.Fatalf("wrong number of trace records %d:\n%s", len(recs), out)
+		}
+		if recs[0].Kind != traceRecordCall || recs[0].Function != "main.foo" || len(recs[0].Args) != 2 || recs[0].Args[0].Value != "99" || recs[0].Args[1].Value != "9801" {
+			t.Errorf("wrong call record %#v", recs[0])
+		}
+		if recs[1].Kind != traceRecordReturn || recs[1].Function != "main.foo" || len(recs[1].ReturnValues) != 1 || recs[1].ReturnValues[0].Value != "9900" {
+			t.Errorf("wrong return record %#v", recs[1])
+		}
+		if recs[1].Goroutine != recs[0].Goroutine || recs[1].Duration <= 0 {
+			t.Errorf("return record not paired with call record: %#v %#v", recs[0], recs[1])
+		}
+	})
+	withTestTerminal("issue573", t, func(term *FakeTerminal) {
+		if err := term.SetTraceOutputFormat(TraceOutputCSV, nil); err != nil {
+			t.Fatal(err)
+		}
+		term.MustExec("trace foo")
+		out, _ := term.Exec("continue")
+		if !strings.Contains(out, "time,kind,goroutine,function,breakpoint,args,return_values,duration_ns\n") {
+			t.Fatalf("CSV header not found:\n%s", out)
+		}
+		if !strings.Contains(out, ",call,1,main.foo,,\"x=99, y=9801\",,\n") || !strings.Contains(out, ",return,1,main.foo,,,z=9900,") {
+			t.Fatalf("wrong CSV output:\n%s", out)
+		}
+	})
+}
+
+func TestTraceExporter(t *testing.T) {
+	reqs := make(chan otlpExportRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != otlpTracesPath || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("wrong request %s %q", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		var req otlpExportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("could not decode request: %v", err)
+		}
+		reqs <- req
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	term := &Term{stdout: &transcriptWriter{w: &buf}}
+	if err := term.SetTraceExporter(srv.URL, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	hit := func(goid int, fn string, ret bool, vals ...api.Variable) {
+		th := &api.Thread{GoroutineID: goid, Function: &api.Function{Name_: fn}, Breakpoint: &api.Breakpoint{Tracepoint: !ret, TraceReturn: ret}}
+		if ret {
+			th.ReturnValues = vals
+		} else {
+			th.BreakpointInfo = &api.BreakpointInfo{Arguments: vals}
+		}
+		term.traceOutput.tracepoint(term, th)
+	}
+	arg := func(name, value string) api.Variable {
+		return api.Variable{Name: name, Kind: reflect.Int, Value: value, Flags: api.VariableArgument}
+	}
+
+	hit(1, "main.outer", false, arg("a", "1"))
+	hit(1, "main.inner", false, arg("b", "2"))
+	hit(2, "main.other", false)
+	hit(1, "main.inner", true, api.Variable{Name: "~r0", Kind: reflect.Int, Value: "3"})
+	hit(1, "main.outer", true)
+	hit(2, "main.other", true)
+	term.traceOutput.exporter.flush()
+
+	req := <-reqs
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("wrong request %#v", req)
+	}
+	if attr := req.ResourceSpans[0].Resource.Attributes; len(attr) != 1 || attr[0].Key != "service.name" || *attr[0].Value.StringValue != "test" {
+		t.Errorf("wrong resource attributes %#v", attr)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 3 {
+		t.Fatalf("wrong number of spans %d", len(spans))
+	}
+	inner, outer, other := spans[0], spans[1], spans[2]
+	if inner.Name != "main.inner" || outer.Name != "main.outer" || other.Name != "main.other" {
+		t.Fatalf("wrong spans %#v", spans)
+	}
+	if inner.ParentSpanID != outer.SpanID || inner.TraceID != outer.TraceID || outer.ParentSpanID != "" {
+		t.Errorf("inner span is not a child of outer: %#v %#v", inner, outer)
+	}
+	if other.ParentSpanID != "" || other.TraceID == outer.TraceID {
+		t.Errorf("span on a different goroutine is part of the same trace: %#v", other)
+	}
+	attrs := map[string]string{}
+	for _, kv := range inner.Attributes {
+		if kv.Value.IntValue != nil {
+			attrs[kv.Key] = *kv.Value.IntValue
+		} else {
+			attrs[kv.Key] = *kv.Value.StringValue
+		}
+	}
+	if attrs["goroutine.id"] != "1" || attrs["arg.b"] != "2" || attrs["return.~r0"] != "3" {
+		t.Errorf("wrong attributes %v", attrs)
+	}
+	if inner.StartTimeUnixNano > inner.EndTimeUnixNano {
+		t.Errorf("wrong span times %s %s", inner.StartTimeUnixNano, inner.EndTimeUnixNano)
+	}
+}
+
+func TestTraceOutputChromeTrace(t *testing.T) {
+	var buf bytes.Buffer
+	term := &Term{stdout: &transcriptWriter{w: ioutil.Discard}}
+	if err := term.SetTraceOutputFormat(TraceOutputChromeTrace, &buf); err != nil {
+		t.Fatal(err)
+	}
+	hit := func(goid int, fn string, ret bool) {
+		th := &api.Thread{GoroutineID: goid, Function: &api.Function{Name_: fn}, Breakpoint: &api.Breakpoint{Tracepoint: !ret, TraceReturn: ret}}
+		if !ret {
+			th.BreakpointInfo = &api.BreakpointInfo{Arguments: []api.Variable{{Name: "a", Kind: reflect.Int, Value: "1", Flags: api.VariableArgument}}}
+		}
+		term.traceOutput.tracepoint(term, th)
+	}
+	hit(1, "main.outer", false)
+	hit(1, "main.inner", false)
+	hit(1, "main.inner", true)
+	hit(2, "main.other", false)
+	hit(1, "main.outer", true)
+	hit(2, "main.other", true)
+	term.traceOutput.close()
+
+	var evs []chromeTraceEvent
+	if err := json.Unmarshal(buf.Bytes(), &evs); err != nil {
+		t.Fatalf("could not parse trace %q: %v", buf.String(), err)
+	}
+	var calls []chromeTraceEvent
+	tracks := map[int]string{}
+	for _, ev := range evs {
+		switch ev.Phase {
+		case "M":
+			tracks[ev.Tid] = ev.Args["name"]
+		case "X":
+			calls = append(calls, ev)
+		}
+	}
+	if tracks[1] != "goroutine 1" || tracks[2] != "goroutine 2" {
+		t.Errorf("wrong tracks %v", tracks)
+	}
+	if len(calls) != 3 {
+		t.Fatalf("wrong number of calls %d", len(calls))
+	}
+	inner, outer, other := calls[0], calls[1], calls[2]
+	if inner.Name != "main.inner" || inner.Tid != 1 || outer.Name != "main.outer" || outer.Tid != 1 || other.Name != "main.other" || other.Tid != 2 {
+		t.Fatalf("wrong calls %#v", calls)
+	}
+	if inner.Ts < outer.Ts || inner.Ts+inner.Dur > outer.Ts+outer.Dur {
+		t.Errorf("inner call not nested in outer call: %#v %#v", inner, outer)
+	}
+	if inner.Args["a"] != "1" {
+		t.Errorf("wrong arguments %v", inner.Args)
+	}
+}
+
 func TestTraceOnNonFunctionEntry(t *testing.T) {
 	test.AllowRecording(t)
 	withTestTerminal("issue573", t, func(term *FakeTerminal) {
@@ -705,6 +887,45 @@ func TestRestart(t *testing.T) {
 	})
 }
 
+func TestRestartEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dlvrestartenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	withTestTerminal("restartenv", t, func(term *FakeTerminal) {
+		term.MustExec("continue")
+		if out := term.MustExec("print env"); !strings.Contains(out, `""`) {
+			t.Fatalf("wrong env: %q", out)
+		}
+		term.MustExec("restart -env DLV_RESTART_ENV=hello -wd " + dir)
+		term.MustExec("continue")
+		if out := term.MustExec("print env"); !strings.Contains(out, `"hello"`) {
+			t.Fatalf("wrong env: %q", out)
+		}
+		if out := term.MustExec("print wd"); !strings.Contains(out, filepath.Base(dir)) {
+			t.Fatalf("wrong working directory: %q", out)
+		}
+		if _, ok := os.LookupEnv("DLV_RESTART_ENV"); ok {
+			t.Fatalf("restart -env changed the environment of the debugger")
+		}
+		// The changes are kept by the following restarts.
+		term.MustExec("restart")
+		term.MustExec("continue")
+		if out := term.MustExec("print env"); !strings.Contains(out, `"hello"`) {
+			t.Fatalf("wrong env: %q", out)
+		}
+		term.MustExec("restart -env DLV_RESTART_ENV")
+		term.MustExec("continue")
+		if out := term.MustExec("print env"); !strings.Contains(out, `""`) {
+			t.Fatalf("wrong env: %q", out)
+		}
+		if _, err := term.Exec("restart -wd"); err == nil {
+			t.Fatalf("restart -wd without a directory did not return an error")
+		}
+	})
+}
+
 func TestIssue827(t *testing.T) {
 	// switching goroutines when the current thread isn't running any goroutine
 	// causes nil pointer dereference.
@@ -915,6 +1136,32 @@ func TestTruncateStacktrace(t *testing.T) {
 	})
 }
 
+func TestStackUser(t *testing.T) {
+	withTestTerminal("stacktraceprog", t, func(term *FakeTerminal) {
+		term.MustExec("break main.stacktraceme")
+		term.MustExec("continue")
+		out := term.MustExec("stack -user")
+		t.Logf("output %q", out)
+		if strings.Contains(out, "in runtime.") {
+			t.Fatalf("runtime frames not hidden")
+		}
+		if !strings.Contains(out, "runtime frames hidden") {
+			t.Fatalf("collapsed runtime frames marker missing")
+		}
+		if !strings.Contains(out, "in main.main") {
+			t.Fatalf("user frames missing")
+		}
+
+		term.conf.StackUserFrames = true
+		if out2 := term.MustExec("stack"); out2 != out {
+			t.Fatalf("stack-user-frames configuration ignored: %q", out2)
+		}
+		if out3 := term.MustExec("stack -all"); !strings.Contains(out3, "in runtime.main") {
+			t.Fatalf("runtime frames not shown with -all: %q", out3)
+		}
+	})
+}
+
 func TestIssue1493(t *testing.T) {
 	// The 'regs' command without the '-a' option should only return
 	// general purpose registers.
@@ -949,6 +1196,28 @@ func TestIssue1598(t *testing.T) {
 	})
 }
 
+func TestPrintStringer(t *testing.T) {
+	test.MustSupportFunctionCalls(t, testBackend)
+	withTestTerminal("fncall", t, func(term *FakeTerminal) {
+		term.MustExec("continue")
+		out := term.MustExec("print -S issue2698")
+		t.Logf("output %q", out)
+		if !strings.Contains(out, "main.Issue2698 {") || !strings.Contains(out, "\nString(): \"1 2 3 4\"\n") {
+			t.Fatalf("wrong output for print -S issue2698")
+		}
+		if out := term.MustExec("print -S one"); !strings.Contains(out, "(no String or Error method)") {
+			t.Fatalf("wrong output for print -S one: %q", out)
+		}
+		if out := term.MustExec("print issue2698"); strings.Contains(out, "String()") {
+			t.Fatalf("String method called without -S: %q", out)
+		}
+		term.conf.StringerTypes = []string{"main.Issue2698"}
+		if out := term.MustExec("print issue2698"); !strings.Contains(out, "\nString(): \"1 2 3 4\"\n") {
+			t.Fatalf("String method not called for type in stringer-types: %q", out)
+		}
+	})
+}
+
 func TestExamineMemoryCmd(t *testing.T) {
 	withTestTerminal("examinememory", t, func(term *FakeTerminal) {
 		term.MustExec("break examinememory.go:19")
@@ -1059,6 +1328,64 @@ func TestPrintOnTracepoint(t *testing.T) {
 	})
 }
 
+func TestRegsLanesAndSetReg(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" || testBackend != "native" {
+		t.Skip("only tested on linux/amd64 with the native backend")
+	}
+	withTestTerminal("testvariables2", t, func(term *FakeTerminal) {
+		term.MustExec("continue")
+		term.MustExec("set-reg XMM0.u64[0] = 7")
+		term.MustExec("set-reg xmm0.uint64[1] 0xff")
+		out := term.MustExec("regs -lanes uint64")
+		if !strings.Contains(out, "XMM0 = { 7 255 ") {
+			t.Errorf("wrong output of regs -lanes uint64: %q", out)
+		}
+		if _, err := term.Exec("set-reg XMM0 = 1"); err == nil {
+			t.Errorf("set-reg XMM0 = 1 did not return an error")
+		}
+		if _, err := term.Exec("regs -lanes complex64"); err == nil {
+			t.Errorf("regs -lanes complex64 did not return an error")
+		}
+		if out := term.MustExec("set-reg RIP = RIP"); !strings.HasPrefix(out, "Warning: changing the program counter") {
+			t.Errorf("no warning printed changing the program counter: %q", out)
+		}
+	})
+}
+
+func TestJump(t *testing.T) {
+	withTestTerminal("testnextprog", t, func(term *FakeTerminal) {
+		term.MustExec("break testnextprog.go:34")
+		term.MustExec("continue")
+		out := term.MustExec("jump testnextprog.go:24")
+		if !strings.Contains(out, "testnextprog.go:24") {
+			t.Errorf("wrong output of jump: %q", out)
+		}
+		if _, err := term.Exec("jump main.helloworld"); err == nil {
+			t.Errorf("jump to a different function did not return an error")
+		}
+	})
+}
+
+func TestPrintLoadConfigArg(t *testing.T) {
+	withTestTerminal("testvariables2", t, func(term *FakeTerminal) {
+		term.MustExec("continue")
+		if out := term.MustExec("print @maxstr=4 longstr"); out != "\"very...+133 more\"\n" {
+			t.Errorf("wrong output for print @maxstr=4 longstr: %q", out)
+		}
+		if out := term.MustExec("print @maxarr=2,depth=0 %x byteslice"); out != "[]uint8 len: 5, cap: 5, [74,c3,...+3 more]\n" {
+			t.Errorf("wrong output for print @maxarr=2,depth=0 %%x byteslice: %q", out)
+		}
+		if out := term.MustExec("print longstr"); strings.Contains(out, "+133 more") {
+			t.Errorf("load configuration override persisted: %q", out)
+		}
+		for _, cmd := range []string{"print @maxstr longstr", "print @maxstr=-1 longstr", "print @unknown=1 longstr", "print @maxstr=4"} {
+			if _, err := term.Exec(cmd); err == nil {
+				t.Errorf("%q did not return an error", cmd)
+			}
+		}
+	})
+}
+
 func TestPrintCastToInterface(t *testing.T) {
 	withTestTerminal("testvariables2", t, func(term *FakeTerminal) {
 		term.MustExec("continue")
@@ -1311,3 +1638,676 @@ func TestTranscript(t *testing.T) {
 		os.Remove(name)
 	})
 }
+
+func TestParseSearchArgs(t *testing.T) {
+	testCases := []struct {
+		in      string
+		pattern string
+		start   uint64
+		end     uint64
+		max     int
+		tgterr  string
+	}{
+		{`-s magic`, "magic", 0, 0, 100, ""},
+		{`-s "two words"`, "two words", 0, 0, 100, ""},
+		{`-x DEADBEEF`, "\xde\xad\xbe\xef", 0, 0, 100, ""},
+		{`-x 0xdeadbeef 0x1000 0x2000`, "\xde\xad\xbe\xef", 0x1000, 0x2000, 100, ""},
+		{`-n 0 -s magic`, "magic", 0, 0, 0, ""},
+		{`-s magic -n 3 4096 8192`, "magic", 4096, 8192, 3, ""},
+		{`-x DEADBEE`, "", 0, 0, 0, `invalid hex pattern "DEADBEE": encoding/hex: odd length hex string`},
+		{`0x1000 0x2000`, "", 0, 0, 0, "no pattern specified, use -s or -x"},
+		{`-s magic 0x1000`, "", 0, 0, 0, "wrong number of arguments, expected a start and an end address"},
+		{`-s magic 0x2000 0x1000`, "", 0, 0, 0, "end address must be greater than start address"},
+		{`-s`, "", 0, 0, 0, "expected argument after -s"},
+	}
+
+	for _, tc := range testCases {
+		pattern, start, end, max, err := parseSearchArgs(tc.in)
+		t.Logf("%q -> %q %#x %#x %d %v", tc.in, pattern, start, end, max, err)
+		if tc.tgterr != "" {
+			if err == nil {
+				t.Errorf("Expected error %q, got no error", tc.tgterr)
+			} else if errstr := err.Error(); errstr != tc.tgterr {
+				t.Errorf("Expected error %q, got error %q", tc.tgterr, errstr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tc.in, err)
+			continue
+		}
+		if string(pattern) != tc.pattern || start != tc.start || end != tc.end || max != tc.max {
+			t.Errorf("%q: expected %q %#x %#x %d, got %q %#x %#x %d", tc.in, tc.pattern, tc.start, tc.end, tc.max, pattern, start, end, max)
+		}
+	}
+}
+
+func TestSearchMemoryCmd(t *testing.T) {
+	withTestTerminal("examinememory", t, func(term *FakeTerminal) {
+		term.MustExec("break examinememory.go:19")
+		term.MustExec("continue")
+
+		addressStr := strings.TrimSpace(term.MustExec("p bspUintptr"))
+		address, err := strconv.ParseUint(addressStr, 0, 64)
+		if err != nil {
+			t.Fatalf("could convert %s into uint64, err %s", addressStr, err)
+		}
+
+		res := term.MustExec(fmt.Sprintf("search -x 0a0b0c0d0e0f1011 %#x %#x", address, address+51))
+		t.Logf("search result:\n%s", res)
+		if !strings.HasPrefix(res, fmt.Sprintf("%#x in [", address)) {
+			t.Fatalf("expected match at %#x", address)
+		}
+
+		res = term.MustExec(fmt.Sprintf("search -x 0a0b0c0d0e0f1011 %#x %#x", address+1, address+51))
+		if !strings.Contains(res, "pattern not found") {
+			t.Fatalf("unexpected match: %s", res)
+		}
+	})
+}
+
+func TestDisasmPrintAnnotations(t *testing.T) {
+	mainfn := &api.Function{Name_: "main.main", Value: 0x1000}
+	otherfn := &api.Function{Name_: "main.f", Value: 0x2000}
+	loc := func(pc uint64, line int, fn *api.Function) api.Location {
+		return api.Location{PC: pc, File: "/src/main.go", Line: line, Function: fn}
+	}
+	dv := api.AsmInstructions{
+		{Loc: loc(0x1000, 3, mainfn), Text: "cmp rax, rbx", Bytes: []byte{0x90}},
+		{Loc: loc(0x1001, 3, mainfn), Text: "jbe 0x1004", Bytes: []byte{0x90}, DestLoc: &api.Location{PC: 0x1004, File: "/src/main.go", Line: 5, Function: mainfn}},
+		{Loc: loc(0x1002, 4, mainfn), Text: "call rcx", Bytes: []byte{0x90}, DestLoc: &api.Location{PC: 0x2000, File: "/src/f.go", Line: 10, Function: otherfn}},
+		{Loc: loc(0x1003, 4, mainfn), Text: "call $main.f", Bytes: []byte{0x90}, DestLoc: &api.Location{PC: 0x2000, File: "/src/f.go", Line: 10, Function: otherfn}},
+		{Loc: loc(0x1004, 5, mainfn), Text: "jmp 0x1000", Bytes: []byte{0x90}, DestLoc: &api.Location{PC: 0x1000, File: "/src/main.go", Line: 3, Function: mainfn}},
+	}
+
+	tgt := []string{"", "↓ 0x1004 main.go:5", "→ main.f", "", "↑ 0x1000 main.go:3"}
+	for i := range dv {
+		if out := dv.BranchAnnotation(i); out != tgt[i] {
+			t.Errorf("instruction %d: expected annotation %q, got %q", i, tgt[i], out)
+		}
+	}
+
+	var buf bytes.Buffer
+	disasmPrint(dv, &buf, func(file string, line int) string {
+		return fmt.Sprintf("source line %d", line)
+	})
+	out := buf.String()
+	t.Logf("%s", out)
+	for _, line := range []int{3, 4, 5} {
+		if n := strings.Count(out, fmt.Sprintf("source line %d\n", line)); n != 1 {
+			t.Errorf("source line %d printed %d times", line, n)
+		}
+	}
+}
+
+func TestDisasmPrintRaw(t *testing.T) {
+	// instructions outside of any function have no file, line or function
+	dv := api.AsmInstructions{
+		{Loc: api.Location{PC: 0x4000}, Text: "push rbp", Bytes: []byte{0x55}},
+		{Loc: api.Location{PC: 0x4001}, Text: "jmp 0x4000", Bytes: []byte{0xeb, 0xfd}, DestLoc: &api.Location{PC: 0x4000}},
+	}
+	var buf bytes.Buffer
+	disasmPrint(dv, &buf, func(file string, line int) string {
+		t.Errorf("unexpected source line request for %s:%d", file, line)
+		return ""
+	})
+	out := buf.String()
+	t.Logf("%s", out)
+	if strings.Contains(out, "TEXT") || strings.Contains(out, ":0") {
+		t.Errorf("unexpected function header or line number in output")
+	}
+	if !strings.Contains(out, "↑ 0x4000") {
+		t.Errorf("missing branch annotation")
+	}
+}
+
+func TestReferencesCmd(t *testing.T) {
+	withTestTerminal("references", t, func(term *FakeTerminal) {
+		term.MustExec("continue")
+		out := term.MustExec("frame 1 references target")
+		t.Logf("references:\n%s", out)
+		for _, tgt := range []string{"global main.global (*main.node)", "heap object"} {
+			if !strings.Contains(out, tgt) {
+				t.Errorf("missing %q in output", tgt)
+			}
+		}
+	})
+}
+
+func TestFindCmd(t *testing.T) {
+	withTestTerminal("testvariables2", t, func(term *FakeTerminal) {
+		out := term.MustExec("find main.astruct")
+		t.Logf("find:\n%s", out)
+		if !strings.HasPrefix(out, "type   main.astruct\n") {
+			t.Errorf("main.astruct is not the first symbol")
+		}
+		out = term.MustExec("find -n 2 astruct")
+		if n := strings.Count(out, "\n"); n != 3 || !strings.Contains(out, "stopped after 2 symbols") {
+			t.Errorf("wrong output with -n 2:\n%s", out)
+		}
+		if _, err := term.Exec("find -n x astruct"); err == nil {
+			t.Errorf("expected error for invalid limit")
+		}
+	})
+}
+
+func TestObjectsCmd(t *testing.T) {
+	withTestTerminal("references", t, func(term *FakeTerminal) {
+		term.MustExec("continue")
+		out := term.MustExec("objects main.node")
+		t.Logf("objects:\n%s", out)
+		for _, tgt := range []string{`name: "target"`, `name: "a"`} {
+			if !strings.Contains(out, tgt) {
+				t.Errorf("missing %q in output", tgt)
+			}
+		}
+		out = term.MustExec("objects main.node 1")
+		if !strings.Contains(out, "stopped after 1 objects") {
+			t.Errorf("limit not respected:\n%s", out)
+		}
+	})
+}
+
+func TestDumpHeapCmd(t *testing.T) {
+	withTestTerminal("references", t, func(term *FakeTerminal) {
+		term.MustExec("continue")
+		dir, err := ioutil.TempDir("", "dlv-dump-heap")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		out := term.MustExec("dump-heap -format dot " + filepath.Join(dir, "heap.dot"))
+		t.Logf("dump-heap: %s", out)
+		buf, err := ioutil.ReadFile(filepath.Join(dir, "heap.dot"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tgt := range []string{`type="main.node"`, `label="main.global"`} {
+			if !strings.Contains(string(buf), tgt) {
+				t.Errorf("missing %q in output:\n%s", tgt, buf)
+			}
+		}
+		term.MustExec("dump-heap " + filepath.Join(dir, "heap.pprof"))
+		if _, err := term.Exec("dump-heap -format json " + filepath.Join(dir, "heap.json")); err == nil {
+			t.Errorf("expected error for unknown format")
+		}
+	})
+}
+
+func TestMetricsCmd(t *testing.T) {
+	withTestTerminal("references", t, func(term *FakeTerminal) {
+		term.MustExec("continue")
+		out := term.MustExec("metrics")
+		t.Logf("metrics:\n%s", out)
+		for _, tgt := range []string{"Heap:", "GC:", "Goroutines:"} {
+			if !strings.Contains(out, tgt) {
+				t.Errorf("missing %q in output", tgt)
+			}
+		}
+		if strings.Contains(out, "Not available:") && goversion.VersionAfterOrEqual(runtime.Version(), 1, 20) {
+			t.Errorf("some metrics not available")
+		}
+	})
+}
+
+func TestGCCmd(t *testing.T) {
+	withTestTerminal("references", t, func(term *FakeTerminal) {
+		term.MustExec("continue")
+		out := term.MustExec("gc")
+		t.Logf("gc:\n%s", out)
+		for _, tgt := range []string{"Phase:", "Heap goal:", "Next trigger:", "Last GC:"} {
+			if !strings.Contains(out, tgt) {
+				t.Errorf("missing %q in output", tgt)
+			}
+		}
+		if strings.Contains(out, "Not available:") && goversion.VersionAfterOrEqual(runtime.Version(), 1, 20) {
+			t.Errorf("some values not available")
+		}
+	})
+}
+
+func TestChanCmd(t *testing.T) {
+	withTestTerminal("chanstate", t, func(term *FakeTerminal) {
+		term.MustExec("continue")
+		check := func(expr string, tgts ...string) {
+			out := term.MustExec("chan " + expr)
+			t.Logf("chan %s:\n%s", expr, out)
+			for _, tgt := range tgts {
+				if !strings.Contains(out, tgt) {
+					t.Errorf("missing %q in output of chan %s", tgt, expr)
+				}
+			}
+		}
+		check("buffered", "len 4, cap 4", "[0] = 2", "[3] = 5", "Goroutines blocked sending: 1", "main.send", "Goroutines blocked receiving: 0")
+		check("unbuffered", "len 0, cap 0", "Goroutines blocked sending: 0", "Goroutines blocked receiving: 1", "main.recv")
+		check("closed", "len 0, cap 1, closed")
+		if _, err := term.Exec("chan 1"); err == nil {
+			t.Errorf("chan of a non-channel expression succeeded")
+		}
+	})
+}
+
+func TestMutexCmd(t *testing.T) {
+	withTestTerminal("mutexstate", t, func(term *FakeTerminal) {
+		term.MustExec("continue")
+		check := func(expr string, tgts ...string) {
+			out := term.MustExec("mutex " + expr)
+			t.Logf("mutex %s:\n%s", expr, out)
+			for _, tgt := range tgts {
+				if !strings.Contains(out, tgt) {
+					t.Errorf("missing %q in output of mutex %s", tgt, expr)
+				}
+			}
+		}
+		check("s.mu", "sync.Mutex", " locked", "Likely held by", "main.(*store).hold", "Waiting: 2")
+		check("rw", "*sync.RWMutex", " locked, 1 readers", "main.readLock", "Waiting: 1", "Waiting to read: 0")
+		check("free", "unlocked", "Waiting: 0")
+		if _, err := term.Exec("mutex s"); err == nil {
+			t.Errorf("mutex of a non-mutex expression succeeded")
+		}
+	})
+}
+
+func TestCtxCmd(t *testing.T) {
+	withTestTerminal("ctxchain", t, func(term *FakeTerminal) {
+		term.MustExec("continue")
+		out := term.MustExec("ctx ctx")
+		t.Logf("ctx ctx:\n%s", out)
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		tgts := []string{
+			"0  WithValue", "key: main.ctxKey \"request\"", "value: interface {}(int) 42",
+			"1  WithCancel", "canceled: ", "cause: ", "shutting down",
+			"2  WithDeadline", "deadline: ", "not canceled",
+			"3  WithValue", "key: main.ctxKey \"user\"", "value: interface {}(string) \"alice\"",
+			"4  Background",
+		}
+		// the targets must appear in order
+		for _, line := range lines {
+			for len(tgts) > 0 && strings.Contains(line, tgts[0]) {
+				tgts = tgts[1:]
+			}
+		}
+		if len(tgts) > 0 {
+			t.Errorf("missing %q in output", tgts[0])
+		}
+		if _, err := term.Exec("ctx 1"); err == nil {
+			t.Errorf("ctx of a non-context expression succeeded")
+		}
+	})
+}
+
+func TestTranscriptStructured(t *testing.T) {
+	withTestTerminal("math", t, func(term *FakeTerminal) {
+		fh, err := ioutil.TempFile("", "test-transcript-*.jsonl")
+		if err != nil {
+			t.Fatalf("TempFile: %v", err)
+		}
+		name := fh.Name()
+		fh.Close()
+		defer os.Remove(name)
+
+		term.MustExec(fmt.Sprintf("transcript -structured -t %s", name))
+		term.MustExec("break main.main")
+		term.MustExec("continue")
+		term.MustExec("transcript -structured -off")
+
+		buf, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatalf("could not read transcript file: %v", err)
+		}
+		t.Logf("transcript:\n%s", buf)
+		var methods []string
+		stops := 0
+		for _, line := range strings.Split(strings.TrimSpace(string(buf)), "\n") {
+			var entry struct {
+				Kind   string
+				Method string
+				State  *api.DebuggerState
+			}
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("could not unmarshal %q: %v", line, err)
+			}
+			switch entry.Kind {
+			case "rpc":
+				methods = append(methods, entry.Method)
+			case "stop":
+				stops++
+				if entry.State == nil || entry.State.CurrentThread == nil || entry.State.CurrentThread.Function.Name() != "main.main" {
+					t.Errorf("wrong stop location %s", line)
+				}
+			}
+		}
+		for _, tgt := range []string{"CreateBreakpoint", "Command"} {
+			found := false
+			for _, method := range methods {
+				if method == tgt {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("RPC call %s not recorded: %v", tgt, methods)
+			}
+		}
+		if stops != 1 {
+			t.Errorf("expected one stop, got %d", stops)
+		}
+	})
+}
+
+func TestGoroutinesDiff(t *testing.T) {
+	withTestTerminal("goroutinestackprog", t, func(term *FakeTerminal) {
+		term.MustExec("break main.main")
+		term.MustExec("continue")
+		if term.lastStopGoroutines != nil {
+			t.Errorf("goroutines recorded before the first use of -diff")
+		}
+		if _, err := term.Exec("goroutines -diff"); err == nil {
+			t.Errorf("expected error without a previous stop")
+		}
+		term.MustExec("break stacktraceme")
+		term.MustExec("continue")
+		out := term.MustExec("goroutines -diff")
+		t.Logf("goroutines -diff:\n%s", out)
+		if n := strings.Count(out, "main.agoroutine"); n < 10 {
+			t.Errorf("expected 10 new goroutines running main.agoroutine, got %d", n)
+		}
+		if !strings.Contains(out, "Created at:") {
+			t.Errorf("missing creation location")
+		}
+		term.MustExec("continue")
+		out = term.MustExec("goroutines -diff -s")
+		t.Logf("goroutines -diff -s:\n%s", out)
+		if i := strings.Index(out, "Exited"); i < 0 || strings.Contains(out[:i], "main.agoroutine") {
+			t.Errorf("unexpected new goroutines")
+		}
+	})
+}
+
+func TestSortGoroutinesWaitTime(t *testing.T) {
+	gs := []*api.Goroutine{
+		{ID: 1},
+		{ID: 2, WaitDuration: time.Second},
+		{ID: 3, WaitDuration: time.Minute},
+		{ID: 4, WaitDuration: time.Second},
+	}
+	stacks := [][]api.Stackframe{{{Err: "1"}}, {{Err: "2"}}, {{Err: "3"}}, {{Err: "4"}}}
+	sortGoroutines(gs, stacks, goroutineWaitTimeLess)
+	for i, id := range []int{3, 2, 4, 1} {
+		if gs[i].ID != id {
+			t.Errorf("goroutine %d: got %d expected %d", i, gs[i].ID, id)
+		}
+		if stacks[i][0].Err != strconv.Itoa(gs[i].ID) {
+			t.Errorf("goroutine %d: stacktrace of goroutine %s", gs[i].ID, stacks[i][0].Err)
+		}
+	}
+}
+
+func TestAncestorsCmd(t *testing.T) {
+	if !goversion.VersionAfterOrEqual(runtime.Version(), 1, 11) {
+		t.Skip("not supported on Go <= 1.10")
+	}
+	savedGodebug := os.Getenv("GODEBUG")
+	os.Setenv("GODEBUG", "tracebackancestors=100")
+	defer os.Setenv("GODEBUG", savedGodebug)
+	withTestTerminal("testnextprog", t, func(term *FakeTerminal) {
+		term.MustExec("break main.testgoroutine")
+		term.MustExec("continue")
+		out := term.MustExec("ancestors")
+		t.Logf("ancestors:\n%s", out)
+		if !strings.Contains(out, "Ancestor 0: Goroutine 1") || !strings.Contains(out, "main.main") {
+			t.Errorf("wrong output for ancestors")
+		}
+		out = term.MustExec("ancestors 0 0")
+		t.Logf("ancestors 0 0:\n%s", out)
+		if !strings.Contains(out, "Ancestor 0 (goroutine 1) frame 0:") || !strings.Contains(out, "go testgoroutine(9, d)") {
+			t.Errorf("wrong output for ancestors 0 0")
+		}
+		out = term.MustExec("ancestors 0")
+		if !strings.Contains(out, "Switched from") {
+			t.Errorf("wrong output for ancestors 0: %s", out)
+		}
+		if _, err := term.Exec("ancestors 1"); err == nil {
+			t.Errorf("expected error for invalid ancestor")
+		}
+	})
+}
+
+func TestDisplayChanges(t *testing.T) {
+	withTestTerminal("testnextprog", t, func(term *FakeTerminal) {
+		term.MustExec("break testnextprog.go:24")
+		term.MustExec("continue")
+		out := term.MustExec("display -a j")
+		if strings.TrimSpace(out) != "0: j = 1" {
+			t.Errorf("wrong output for display -a: %q", out)
+		}
+		term.MustExec("display -a -changes i")
+		term.MustExec("display -a f")
+
+		out = term.MustExec("continue")
+		t.Logf("continue:\n%s", out)
+		for _, tgt := range []string{"0: j = 1\n", "1: i: 0 => 1\n", "2: f = 2\n"} {
+			if !strings.Contains(out, tgt) {
+				t.Errorf("missing %q in output", tgt)
+			}
+		}
+
+		out = term.MustExec("display")
+		if !strings.Contains(out, "1: i = 1") {
+			t.Errorf("display without arguments should print all values: %q", out)
+		}
+	})
+}
+
+func TestOnStopHook(t *testing.T) {
+	withTestTerminal("testnextprog", t, func(term *FakeTerminal) {
+		// The continue in the hook must not run the hook again.
+		term.conf.OnStop = []string{"print 1+2", "nonexistentcmd", "continue"}
+		term.MustExec("break main.helloworld")
+		term.MustExec("break main.testnext")
+		out := term.MustExec("continue")
+		t.Logf("continue:\n%s", out)
+		if n := strings.Count(out, "3\n"); n != 1 {
+			t.Errorf("expected hook to run once, got %d times", n)
+		}
+		if !strings.Contains(out, "on-stop: nonexistentcmd:") {
+			t.Errorf("missing error of failed hook command")
+		}
+		if !strings.Contains(out, "main.helloworld()") {
+			t.Errorf("continue in hook did not resume the target")
+		}
+	})
+}
+
+func TestTUIPaneRender(t *testing.T) {
+	pane := tuiPane{title: "Source", lines: []string{"\tx := 1", "a very long line that does not fit"}}
+	lines := pane.render(12, 4)
+	tgt := []string{
+		tuiReverseEscape + "Source      " + terminalResetEscapeCode,
+		"    x := 1  ",
+		"a very long ",
+		"            ",
+	}
+	if len(lines) != len(tgt) {
+		t.Fatalf("wrong number of lines %d %q", len(lines), lines)
+	}
+	for i := range tgt {
+		if lines[i] != tgt[i] {
+			t.Errorf("line %d: got %q expected %q", i, lines[i], tgt[i])
+		}
+	}
+}
+
+func TestParametrizedAlias(t *testing.T) {
+	for _, tc := range []struct {
+		tmpl string
+		args []string
+		tgt  string
+		err  bool
+	}{
+		{"goroutine $1 stack -full", []string{"5"}, "goroutine 5 stack -full", false},
+		{"goroutine $1 stack -full", nil, "", true},
+		{"goroutine $1 stack -full", []string{"5", "6"}, "", true},
+		{"goroutine ${1} stack ${2:10}", []string{"5"}, "goroutine 5 stack 10", false},
+		{"goroutine ${1} stack ${2:10}", []string{"5", "20"}, "goroutine 5 stack 20", false},
+		{"print $*", []string{"a", "b + c"}, "print a b + c", false},
+		{"print $$1 $1", []string{"a"}, "print $1 a", false},
+		{"print ${x}", nil, "", true},
+		{"print $", nil, "", true},
+	} {
+		out, err := expandAlias(tc.tmpl, tc.args)
+		if tc.err {
+			if err == nil {
+				t.Errorf("expandAlias(%q, %q): expected error, got %q", tc.tmpl, tc.args, out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expandAlias(%q, %q): %v", tc.tmpl, tc.args, err)
+			continue
+		}
+		if out != tc.tgt {
+			t.Errorf("expandAlias(%q, %q): got %q expected %q", tc.tmpl, tc.args, out, tc.tgt)
+		}
+	}
+
+	var term Term
+	term.conf = &config.Config{}
+	term.cmds = DebugCommands(nil)
+
+	if err := configureCmd(&term, callContext{}, `alias print = "print $1"`); err == nil {
+		t.Fatalf("expected error redefining print")
+	}
+	if err := configureCmd(&term, callContext{}, `alias msl = "config max-string-len ${1:20}"`); err != nil {
+		t.Fatalf("error executing configureCmd(alias msl = ...): %v", err)
+	}
+	if term.conf.ParametrizedAliases["msl"] != "config max-string-len ${1:20}" {
+		t.Fatalf("parametrized alias not added %v", term.conf.ParametrizedAliases)
+	}
+
+	if err := term.cmds.Call("msl 10", &term); err != nil {
+		t.Fatalf("error executing 'msl 10': %v", err)
+	}
+	if term.conf.MaxStringLen == nil || *term.conf.MaxStringLen != 10 {
+		t.Fatalf("alias not expanded correctly, MaxStringLen %v", term.conf.MaxStringLen)
+	}
+	if err := term.cmds.Call("msl", &term); err != nil {
+		t.Fatalf("error executing 'msl': %v", err)
+	}
+	if *term.conf.MaxStringLen != 20 {
+		t.Fatalf("default argument not used, MaxStringLen %d", *term.conf.MaxStringLen)
+	}
+
+	if err := configureCmd(&term, callContext{}, "alias msl"); err != nil {
+		t.Fatalf("error executing configureCmd(alias msl): %v", err)
+	}
+	if _, ok := term.conf.ParametrizedAliases["msl"]; ok {
+		t.Fatalf("parametrized alias not removed")
+	}
+	if err := term.cmds.Call("msl 10", &term); err != errNoCmd {
+		t.Fatalf("expected errNoCmd executing removed alias, got %v", err)
+	}
+}
+
+type fakeScriptEngine struct {
+	executed []string
+	repl     int
+}
+
+func (e *fakeScriptEngine) Execute(path string) error {
+	e.executed = append(e.executed, path)
+	return nil
+}
+
+func (e *fakeScriptEngine) REPL() error {
+	e.repl++
+	return nil
+}
+
+func (e *fakeScriptEngine) Cancel() {}
+
+func TestScriptEngine(t *testing.T) {
+	var engine *fakeScriptEngine
+	RegisterScriptEngine(".fake", func(ctx scriptbind.Context, out io.Writer) ScriptEngine {
+		engine = &fakeScriptEngine{}
+		return engine
+	})
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("registering an engine for .star did not panic")
+			}
+		}()
+		RegisterScriptEngine(".star", func(ctx scriptbind.Context, out io.Writer) ScriptEngine { return nil })
+	}()
+
+	term := New(nil, &config.Config{})
+	defer term.Close()
+	if engine == nil {
+		t.Fatal("script engine not created")
+	}
+
+	if err := term.cmds.Call("source script.fake", term); err != nil {
+		t.Fatalf("source script.fake: %v", err)
+	}
+	if len(engine.executed) != 1 || engine.executed[0] != "script.fake" {
+		t.Fatalf("script not executed by engine: %v", engine.executed)
+	}
+	if err := term.cmds.Call("source -fake -", term); err != nil {
+		t.Fatalf("source -fake -: %v", err)
+	}
+	if engine.repl != 1 {
+		t.Fatalf("REPL not started")
+	}
+	if err := term.cmds.Call("source -nonexistent -", term); err == nil {
+		t.Fatalf("expected error for unknown script language")
+	}
+}
+
+func TestInferSubstitutePath(t *testing.T) {
+	files := map[string]bool{
+		filepath.FromSlash("/home/me/mymod/pkg/util/x.go"):                         true,
+		filepath.FromSlash("/home/me/mymod/main.go"):                               true,
+		filepath.FromSlash("/opt/go/src/runtime/proc.go"):                          true,
+		filepath.FromSlash("/home/me/go/pkg/mod/github.com/foo/bar@v1.2.3/bar.go"): true,
+	}
+	exists := func(path string) bool { return files[path] }
+	mod := goModule{path: "example.com/mymod", dir: filepath.FromSlash("/home/me/mymod")}
+	pkgs := []api.PackageBuildInfo{
+		{ImportPath: "example.com/mymod", DirectoryPath: "/build/src"},
+		{ImportPath: "example.com/mymod/pkg/util", DirectoryPath: "/build/src/pkg/util"},
+	}
+	roots := []string{filepath.FromSlash("/home/me/mymod"), filepath.FromSlash("/opt/go"), filepath.FromSlash("/home/me/go/pkg/mod")}
+
+	for _, tc := range []struct {
+		path     string
+		from, to string
+	}{
+		{"/build/src/pkg/util/x.go", "/build/src", "/home/me/mymod"},
+		{"/build/src/main.go", "/build/src", "/home/me/mymod"},
+		{"/usr/local/go/src/runtime/proc.go", "/usr/local/go", "/opt/go"},
+		{"github.com/foo/bar@v1.2.3/bar.go", "github.com", "/home/me/go/pkg/mod/github.com"},
+		{"/elsewhere/pkg/util/x.go", "/elsewhere", "/home/me/mymod"},
+		{"/elsewhere/y.go", "", ""},
+	} {
+		from, to, ok := inferSubstitutePathToLocal(tc.path, pkgs, mod, roots, exists)
+		if tc.from == "" {
+			if ok {
+				t.Errorf("%s: unexpected rule %q -> %q", tc.path, from, to)
+			}
+			continue
+		}
+		if !ok || from != tc.from || to != filepath.FromSlash(tc.to) {
+			t.Errorf("%s: got %q -> %q (%v), expected %q -> %q", tc.path, from, to, ok, tc.from, tc.to)
+		}
+	}
+
+	sources := []string{"/build/src/pkg/util/x.go", "/build/src/main.go", "/build/src/other/main.go"}
+	from, to, ok := inferSubstitutePathFromSources("/home/me/mymod/pkg/util/x.go", sources)
+	if !ok || from != "/build/src" || to != "/home/me/mymod" {
+		t.Errorf("got %q -> %q (%v)", from, to, ok)
+	}
+	if _, _, ok := inferSubstitutePathFromSources("/home/me/mymod/x/main.go", sources); ok {
+		t.Errorf("expected ambiguous match for main.go")
+	}
+}
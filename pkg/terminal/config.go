@@ -1,9 +1,12 @@
 package terminal
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/go-delve/delve/pkg/config"
@@ -63,6 +66,7 @@ func configureSet(t *Term, args string) error {
 }
 
 func configureSetSubstitutePath(t *Term, rest string) error {
+	t.substitutePathRulesCache = nil
 	argv := config.SplitQuotedFields(rest, '"')
 	switch len(argv) {
 	case 1: // delete substitute-path rule
@@ -92,6 +96,7 @@ func configureSetAlias(t *Term, rest string) error {
 	argv := config.SplitQuotedFields(rest, '"')
 	switch len(argv) {
 	case 1: // delete alias rule
+		delete(t.conf.ParametrizedAliases, argv[0])
 		for k := range t.conf.Aliases {
 			v := t.conf.Aliases[k]
 			for i := range v {
@@ -107,7 +112,111 @@ func configureSetAlias(t *Term, rest string) error {
 			t.conf.Aliases = make(map[string][]string)
 		}
 		t.conf.Aliases[cmd] = append(t.conf.Aliases[cmd], alias)
+	case 3: // add parametrized alias
+		alias, tmpl := argv[0], argv[2]
+		if argv[1] != "=" {
+			return fmt.Errorf("wrong syntax for parametrized alias, expected: config alias <alias> = \"<command line>\"")
+		}
+		if t.cmds.Find(alias, noPrefix).aliases[0] != "nocmd" {
+			return fmt.Errorf("%q is already a command", alias)
+		}
+		if _, err := expandAlias(tmpl, nil); err != nil {
+			if _, ok := err.(missingAliasArgError); !ok {
+				return err
+			}
+		}
+		if t.conf.ParametrizedAliases == nil {
+			t.conf.ParametrizedAliases = make(map[string]string)
+		}
+		t.conf.ParametrizedAliases[alias] = tmpl
+		return nil
+	default:
+		return fmt.Errorf("wrong number of arguments to \"config alias\"")
 	}
 	t.cmds.Merge(t.conf.Aliases)
 	return nil
 }
+
+// parametrizedAlias returns the command line that the parametrized alias
+// name expands to.
+func (t *Term) parametrizedAlias(name string) (string, bool) {
+	if t == nil || t.conf == nil {
+		return "", false
+	}
+	tmpl, ok := t.conf.ParametrizedAliases[name]
+	return tmpl, ok
+}
+
+// missingAliasArgError is returned by expandAlias when an argument without
+// a default value is not specified.
+type missingAliasArgError struct {
+	n int
+}
+
+func (err missingAliasArgError) Error() string {
+	return fmt.Sprintf("missing argument $%d", err.n)
+}
+
+// expandAlias returns the command line of a parametrized alias, replacing
+// $1 ... $9, ${n} and ${n:default} in tmpl with the corresponding element
+// of args, $* with all of args and $$ with $.
+func expandAlias(tmpl string, args []string) (string, error) {
+	var buf strings.Builder
+	used := 0
+	for i := 0; i < len(tmpl); i++ {
+		if tmpl[i] != '$' {
+			buf.WriteByte(tmpl[i])
+			continue
+		}
+		i++
+		if i >= len(tmpl) {
+			return "", errors.New("unterminated $ in alias")
+		}
+		var n int
+		var def string
+		hasDef := false
+		switch ch := tmpl[i]; {
+		case ch == '$':
+			buf.WriteByte('$')
+			continue
+		case ch == '*':
+			buf.WriteString(strings.Join(args, " "))
+			used = len(args)
+			continue
+		case ch >= '1' && ch <= '9':
+			n = int(ch - '0')
+		case ch == '{':
+			end := strings.IndexByte(tmpl[i:], '}')
+			if end < 0 {
+				return "", errors.New("unterminated ${ in alias")
+			}
+			param := tmpl[i+1 : i+end]
+			i += end
+			if colon := strings.IndexByte(param, ':'); colon >= 0 {
+				param, def, hasDef = param[:colon], param[colon+1:], true
+			}
+			var err error
+			n, err = strconv.Atoi(param)
+			if err != nil || n <= 0 {
+				return "", fmt.Errorf("invalid argument number %q in alias", param)
+			}
+		default:
+			return "", fmt.Errorf("invalid use of $ in alias %q", tmpl)
+		}
+		switch {
+		case n <= len(args):
+			buf.WriteString(args[n-1])
+		case hasDef:
+			buf.WriteString(def)
+		default:
+			return "", missingAliasArgError{n}
+		}
+		if n > used {
+			used = n
+		}
+	}
+	if used < len(args) {
+		return "", fmt.Errorf("too many arguments, expected at most %d", used)
+	}
+	return buf.String(), nil
+}
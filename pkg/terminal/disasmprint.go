@@ -5,12 +5,16 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/go-delve/delve/service/api"
 )
 
-func disasmPrint(dv api.AsmInstructions, out io.Writer) {
+// disasmPrint prints the disassembly dv to out. If sourceLine is not nil
+// each run of instructions belonging to the same source line is preceded
+// by the text of that line, as returned by sourceLine.
+func disasmPrint(dv api.AsmInstructions, out io.Writer, sourceLine func(file string, line int) string) {
 	bw := bufio.NewWriter(out)
 	defer bw.Flush()
 	if len(dv) > 0 && dv[0].Loc.Function != nil {
@@ -18,7 +22,14 @@ func disasmPrint(dv api.AsmInstructions, out io.Writer) {
 	}
 	tw := tabwriter.NewWriter(bw, 1, 8, 1, '\t', 0)
 	defer tw.Flush()
-	for _, inst := range dv {
+	lastFile, lastLine := "", -1
+	for i, inst := range dv {
+		if sourceLine != nil && inst.Loc.File != "" && (inst.Loc.File != lastFile || inst.Loc.Line != lastLine) {
+			lastFile, lastLine = inst.Loc.File, inst.Loc.Line
+			if src := sourceLine(inst.Loc.File, inst.Loc.Line); src != "" {
+				fmt.Fprintf(tw, "\t%s:%d:\t%s\n", filepath.Base(inst.Loc.File), inst.Loc.Line, strings.TrimSpace(src))
+			}
+		}
 		atbp := ""
 		if inst.Breakpoint {
 			atbp = "*"
@@ -27,6 +38,14 @@ func disasmPrint(dv api.AsmInstructions, out io.Writer) {
 		if inst.AtPC {
 			atpc = "=>"
 		}
-		fmt.Fprintf(tw, "%s\t%s:%d\t%#x%s\t%x\t%s\n", atpc, filepath.Base(inst.Loc.File), inst.Loc.Line, inst.Loc.PC, atbp, inst.Bytes, inst.Text)
+		annot := ""
+		if a := dv.BranchAnnotation(i); a != "" {
+			annot = "\t" + a
+		}
+		loc := "?"
+		if inst.Loc.File != "" {
+			loc = fmt.Sprintf("%s:%d", filepath.Base(inst.Loc.File), inst.Loc.Line)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%#x%s\t%x\t%s%s\n", atpc, loc, inst.Loc.PC, atbp, inst.Bytes, inst.Text, annot)
 	}
 }
@@ -0,0 +1,277 @@
+// Package luabind implements an interpreter for Lua scripts executed by the
+// terminal, using the bindings of package scriptbind.
+package luabind
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/go-delve/delve/pkg/terminal/scriptbind"
+)
+
+const (
+	dlvTableName          = "dlv"
+	commandPrefix         = "command_"
+	mainFnName            = "main"
+	commandBuiltinName    = "command"
+	curScopeBuiltinName   = "cur_scope"
+	loadConfigBuiltinName = "default_load_config"
+)
+
+// Env is the environment used to evaluate Lua scripts.
+// API calls are available as functions of the global table 'dlv', with the
+// same names they have for starlark scripts.
+type Env struct {
+	L *lua.LState
+
+	contextMu sync.Mutex
+	cancelfn  context.CancelFunc
+
+	ctx scriptbind.Context
+	out io.Writer
+}
+
+// New creates a new Lua environment.
+func New(ctx scriptbind.Context, out io.Writer) *Env {
+	env := &Env{ctx: ctx, out: out}
+	env.L = lua.NewState()
+
+	env.L.SetGlobal("print", env.L.NewFunction(env.print))
+
+	dlv := env.L.NewTable()
+	for _, m := range scriptbind.Methods() {
+		m := m
+		dlv.RawSetString(m.Name, env.L.NewFunction(func(L *lua.LState) int {
+			return env.callMethod(L, &m)
+		}))
+	}
+	dlv.RawSetString(commandBuiltinName, env.L.NewFunction(func(L *lua.LState) int {
+		argstrs := make([]string, L.GetTop())
+		for i := range argstrs {
+			argstrs[i] = L.CheckString(i + 1)
+		}
+		if err := env.ctx.CallCommand(strings.Join(argstrs, " ")); err != nil {
+			L.RaiseError("%v", err)
+		}
+		return 0
+	}))
+	dlv.RawSetString(curScopeBuiltinName, env.L.NewFunction(func(L *lua.LState) int {
+		return env.pushValue(L, env.ctx.Scope())
+	}))
+	dlv.RawSetString(loadConfigBuiltinName, env.L.NewFunction(func(L *lua.LState) int {
+		return env.pushValue(L, env.ctx.LoadConfig())
+	}))
+	env.L.SetGlobal(dlvTableName, dlv)
+	return env
+}
+
+func (env *Env) print(L *lua.LState) int {
+	args := make([]string, L.GetTop())
+	for i := range args {
+		args[i] = L.ToStringMeta(L.Get(i + 1)).String()
+	}
+	fmt.Fprintln(env.out, strings.Join(args, "\t"))
+	return 0
+}
+
+// callMethod calls m with the arguments on the stack of L. If the only
+// argument is a table whose keys are all names of arguments of m the
+// arguments are passed by name.
+func (env *Env) callMethod(L *lua.LState, m *scriptbind.Method) int {
+	if ctx := L.Context(); ctx != nil && ctx.Err() != nil {
+		L.RaiseError("%v", ctx.Err())
+	}
+	var args []interface{}
+	var kwargs map[string]interface{}
+	if tbl, ok := L.Get(1).(*lua.LTable); ok && L.GetTop() == 1 && isKwargs(tbl, m) {
+		kwargs = map[string]interface{}{}
+		tbl.ForEach(func(k, v lua.LValue) {
+			kwargs[k.String()] = fromLua(v)
+		})
+	} else {
+		args = make([]interface{}, L.GetTop())
+		for i := range args {
+			args[i] = fromLua(L.Get(i + 1))
+		}
+	}
+	r, err := m.Call(env.ctx.Client(), args, kwargs)
+	if err != nil {
+		L.RaiseError("%s: %v", m.Name, err)
+	}
+	L.Push(toLua(L, r))
+	return 1
+}
+
+func isKwargs(tbl *lua.LTable, m *scriptbind.Method) bool {
+	r := true
+	n := 0
+	tbl.ForEach(func(k, _ lua.LValue) {
+		n++
+		name, ok := k.(lua.LString)
+		if !ok {
+			r = false
+			return
+		}
+		found := false
+		for _, argName := range m.ArgNames {
+			if string(name) == argName {
+				found = true
+				break
+			}
+		}
+		r = r && found
+	})
+	return r && n > 0
+}
+
+func (env *Env) pushValue(L *lua.LState, v interface{}) int {
+	r, err := scriptbind.ToValue(v)
+	if err != nil {
+		L.RaiseError("%v", err)
+	}
+	L.Push(toLua(L, r))
+	return 1
+}
+
+// Execute executes the Lua script at path. After the script is executed,
+// global functions with a name starting with "command_" are registered as
+// commands and, if a global function named "main" exists, it is called.
+func (env *Env) Execute(path string) error {
+	env.newContext()
+	defer env.L.RemoveContext()
+	if err := env.L.DoFile(path); err != nil {
+		return err
+	}
+	env.exportCommands()
+	mainfn, ok := env.L.GetGlobal(mainFnName).(*lua.LFunction)
+	if !ok {
+		return nil
+	}
+	return env.L.CallByParam(lua.P{Fn: mainfn, NRet: 0, Protect: true})
+}
+
+// exportCommands creates commands from global functions with a name
+// starting with "command_". The function is called with the arguments of
+// the command as a single string.
+func (env *Env) exportCommands() {
+	var names []string
+	env.L.G.Global.ForEach(func(k, v lua.LValue) {
+		name, ok := k.(lua.LString)
+		if _, isfn := v.(*lua.LFunction); ok && isfn && strings.HasPrefix(string(name), commandPrefix) {
+			names = append(names, string(name))
+		}
+	})
+	sort.Strings(names)
+	for _, name := range names {
+		name := name
+		env.ctx.RegisterCommand(name[len(commandPrefix):], "user defined", func(args string) error {
+			fn, ok := env.L.GetGlobal(name).(*lua.LFunction)
+			if !ok {
+				return fmt.Errorf("%s is not a function", name)
+			}
+			env.newContext()
+			defer env.L.RemoveContext()
+			return env.L.CallByParam(lua.P{Fn: fn, NRet: 0, Protect: true}, lua.LString(args))
+		})
+	}
+}
+
+func (env *Env) newContext() {
+	env.contextMu.Lock()
+	var ctx context.Context
+	ctx, env.cancelfn = context.WithCancel(context.Background())
+	env.contextMu.Unlock()
+	env.L.SetContext(ctx)
+}
+
+// Cancel cancels the execution of a currently running script or function.
+func (env *Env) Cancel() {
+	if env == nil {
+		return
+	}
+	env.contextMu.Lock()
+	if env.cancelfn != nil {
+		env.cancelfn()
+		env.cancelfn = nil
+	}
+	env.contextMu.Unlock()
+}
+
+// Close releases the resources used by the environment.
+func (env *Env) Close() {
+	env.L.Close()
+}
+
+// toLua converts a generic value, as returned by scriptbind, to a Lua
+// value. Arrays are converted to tables indexed starting at 1.
+func toLua(L *lua.LState, v interface{}) lua.LValue {
+	switch v := v.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(v)
+	case int64:
+		return lua.LNumber(v)
+	case float64:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case []interface{}:
+		tbl := L.CreateTable(len(v), 0)
+		for i := range v {
+			tbl.RawSetInt(i+1, toLua(L, v[i]))
+		}
+		return tbl
+	case map[string]interface{}:
+		tbl := L.CreateTable(0, len(v))
+		for k := range v {
+			tbl.RawSetString(k, toLua(L, v[k]))
+		}
+		return tbl
+	default:
+		return lua.LString(fmt.Sprint(v))
+	}
+}
+
+// fromLua converts a Lua value to a generic value. Tables with only
+// consecutive integer keys starting at 1 are converted to arrays, empty
+// tables are converted to nil.
+func fromLua(v lua.LValue) interface{} {
+	switch v := v.(type) {
+	case lua.LBool:
+		return bool(v)
+	case lua.LNumber:
+		if f := float64(v); f == float64(int64(f)) {
+			return int64(f)
+		}
+		return float64(v)
+	case lua.LString:
+		return string(v)
+	case *lua.LTable:
+		n, maxn := 0, v.MaxN()
+		v.ForEach(func(_, _ lua.LValue) { n++ })
+		switch {
+		case n == 0:
+			return nil
+		case n == maxn:
+			r := make([]interface{}, maxn)
+			for i := range r {
+				r[i] = fromLua(v.RawGetInt(i + 1))
+			}
+			return r
+		}
+		r := map[string]interface{}{}
+		v.ForEach(func(k, v lua.LValue) {
+			r[k.String()] = fromLua(v)
+		})
+		return r
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+package luabind
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/go-delve/liner"
+)
+
+const (
+	normalPrompt = "lua> "
+	extraPrompt  = "...> "
+
+	exitCommand = "exit"
+)
+
+// REPL executes a read, eval, print loop.
+func (env *Env) REPL() error {
+	env.newContext()
+	defer env.L.RemoveContext()
+
+	rl := liner.NewLiner()
+	defer rl.Close()
+	for {
+		if err := env.L.Context().Err(); err != nil {
+			return err
+		}
+		if err := env.rep(rl); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+	}
+	fmt.Fprintln(env.out)
+	env.exportCommands()
+	return nil
+}
+
+// rep reads, evaluates, and prints one chunk. Chunks that are incomplete
+// are continued on the next line. If the chunk is an expression its values
+// are printed.
+func (env *Env) rep(rl *liner.State) error {
+	var src string
+	prompt := normalPrompt
+	for {
+		line, err := rl.Prompt(prompt)
+		if err != nil {
+			if err == liner.ErrPromptAborted {
+				return nil
+			}
+			return err
+		}
+		if src == "" && strings.TrimSpace(line) == exitCommand {
+			return io.EOF
+		}
+		rl.AppendHistory(line)
+		src += line + "\n"
+
+		fn, err := env.L.LoadString("return " + src)
+		if err != nil {
+			fn, err = env.L.LoadString(src)
+		}
+		if err != nil {
+			if strings.Contains(err.Error(), " at EOF:") {
+				prompt = extraPrompt
+				continue
+			}
+			fmt.Fprintln(env.out, err)
+			return nil
+		}
+
+		top := env.L.GetTop()
+		env.L.Push(fn)
+		if err := env.L.PCall(0, lua.MultRet, nil); err != nil {
+			fmt.Fprintln(env.out, err)
+			return nil
+		}
+		if n := env.L.GetTop() - top; n > 0 {
+			vals := make([]string, n)
+			for i := range vals {
+				vals[i] = env.L.ToStringMeta(env.L.Get(top + i + 1)).String()
+			}
+			env.L.Pop(n)
+			fmt.Fprintln(env.out, strings.Join(vals, "\t"))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,184 @@
+package terminal
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	otlpTracesPath     = "/v1/traces"
+	otlpSpanKindIntern = 1 // SPAN_KIND_INTERNAL
+	otlpMaxBatch       = 512
+	otlpFlushInterval  = 5 * time.Second
+)
+
+// otlpExporter sends the traced calls as spans to an OpenTelemetry
+// collector, using OTLP over HTTP with the JSON encoding.
+type otlpExporter struct {
+	endpoint    string
+	serviceName string
+	client      *http.Client
+	errOut      io.Writer
+
+	spans     []otlpSpan
+	lastFlush time.Time
+	failed    bool // an error was already reported
+}
+
+// SetTraceExporter exports the traced calls, paired with their return, as
+// spans to the OpenTelemetry collector at endpoint. If endpoint has no path
+// the default OTLP/HTTP path, /v1/traces, is used.
+func (t *Term) SetTraceExporter(endpoint, serviceName string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported OTLP endpoint %q, must be an http or https URL", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = otlpTracesPath
+	}
+	if serviceName == "" {
+		serviceName = "dlv"
+	}
+	t.getTraceOutput().exporter = &otlpExporter{
+		endpoint:    u.String(),
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		errOut:      t.stdout,
+		lastFlush:   time.Now(),
+	}
+	return nil
+}
+
+// The types below are the subset of the JSON encoding of
+// ExportTraceServiceRequest used by otlpExporter.
+
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+func otlpString(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}}
+}
+
+func otlpInt(key string, value int64) otlpKeyValue {
+	s := strconv.FormatInt(value, 10)
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{IntValue: &s}}
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// span queues the span for call, which returned at time end with the given
+// return values.
+func (e *otlpExporter) span(call, parent *traceCall, end time.Time, goid int, retVals []traceValue) {
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(call.traceID[:]),
+		SpanID:            hex.EncodeToString(call.spanID[:]),
+		Name:              call.fn,
+		Kind:              otlpSpanKindIntern,
+		StartTimeUnixNano: otlpTime(call.start),
+		EndTimeUnixNano:   otlpTime(end),
+		Attributes: []otlpKeyValue{
+			otlpInt("goroutine.id", int64(goid)),
+			otlpString("code.function", call.fn),
+		},
+	}
+	if parent != nil {
+		span.ParentSpanID = hex.EncodeToString(parent.spanID[:])
+	}
+	for _, v := range call.args {
+		span.Attributes = append(span.Attributes, otlpString("arg."+v.Name, v.Value))
+	}
+	for _, v := range retVals {
+		span.Attributes = append(span.Attributes, otlpString("return."+v.Name, v.Value))
+	}
+	e.spans = append(e.spans, span)
+	if len(e.spans) >= otlpMaxBatch || time.Since(e.lastFlush) >= otlpFlushInterval {
+		e.flush()
+	}
+}
+
+// flush sends the queued spans to the collector.
+func (e *otlpExporter) flush() {
+	e.lastFlush = time.Now()
+	if len(e.spans) == 0 {
+		return
+	}
+	req := otlpExportRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{otlpString("service.name", e.serviceName)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "dlv trace"}, Spans: e.spans}},
+	}}}
+	e.spans = nil
+	if err := e.send(&req); err != nil && !e.failed {
+		e.failed = true
+		fmt.Fprintf(e.errOut, "could not export spans to %s: %v\n", e.endpoint, err)
+	}
+}
+
+func (e *otlpExporter) send(req *otlpExportRequest) error {
+	buf, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+// Package scriptbind contains the bindings to the debugger shared by the
+// interpreters of scripting languages used by the terminal.
+//
+// Values are exchanged with the interpreters as the generic Go values
+// produced by encoding/json (nil, bool, int64, float64, string,
+// []interface{} and map[string]interface{}), arguments and return values
+// of API calls have the same structure they have in the JSON-RPC API.
+package scriptbind
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/go-delve/delve/service"
+	"github.com/go-delve/delve/service/api"
+	"github.com/go-delve/delve/service/rpc2"
+)
+
+// Context is the context in which scripts are evaluated.
+// It contains methods to call API functions, command line commands, etc.
+type Context interface {
+	Client() service.Client
+	RegisterCommand(name, helpMsg string, cmdfn func(args string) error)
+	RegisterCommandCompleter(name string, complete func(args string) []string)
+	CallCommand(cmdstr string) error
+	Scope() api.EvalScope
+	LoadConfig() api.LoadConfig
+}
+
+// Method is an API call that can be made by scripts.
+type Method struct {
+	// Name is the name of the binding, the name of the API call in snake
+	// case, it is the same name used by starlark scripts.
+	Name string
+	// RPCName is the name of the API call.
+	RPCName string
+	// ArgNames are the names of the arguments of the API call, in the order
+	// in which they can be passed positionally.
+	ArgNames []string
+
+	argType, retType reflect.Type
+}
+
+// callbackMethods are the API calls that reply through a
+// service.RPCCallback, and the types of their replies.
+var callbackMethods = map[string]reflect.Type{
+	"Command": reflect.TypeOf(rpc2.CommandOut{}),
+	"Restart": reflect.TypeOf(rpc2.RestartOut{}),
+	"State":   reflect.TypeOf(rpc2.StateOut{}),
+}
+
+var methods []Method
+
+func init() {
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	typ := reflect.TypeOf(&rpc2.RPCServer{})
+	for i := 0; i < typ.NumMethod(); i++ {
+		m := typ.Method(i)
+		// m.Type includes the receiver
+		if m.Type.NumIn() != 3 || m.Type.In(1).Kind() != reflect.Struct {
+			continue
+		}
+		var retType reflect.Type
+		if rt, ok := callbackMethods[m.Name]; ok {
+			retType = rt
+		} else {
+			// arguments must be (args, *reply), return values must be (error)
+			if m.Type.In(2).Kind() != reflect.Ptr || m.Type.In(2).Elem().Kind() != reflect.Struct {
+				continue
+			}
+			if m.Type.NumOut() != 1 || m.Type.Out(0) != errorType {
+				continue
+			}
+			retType = m.Type.In(2).Elem()
+		}
+		argType := m.Type.In(1)
+		argNames := make([]string, argType.NumField())
+		for j := range argNames {
+			argNames[j] = argType.Field(j).Name
+		}
+		methods = append(methods, Method{Name: bindingName(m.Name), RPCName: m.Name, ArgNames: argNames, argType: argType, retType: retType})
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+}
+
+// bindingName returns the name of the binding for the API call rpcName,
+// it must be kept in sync with _scripts/gen-starlark-bindings.go.
+func bindingName(rpcName string) string {
+	out := []rune{}
+	isupper := func(i int) bool {
+		return rpcName[i] >= 'A' && rpcName[i] <= 'Z'
+	}
+	for i, ch := range rpcName {
+		if i > 0 && isupper(i) {
+			if !isupper(i - 1) {
+				out = append(out, '_')
+			} else if i+1 < len(rpcName) && !isupper(i+1) {
+				out = append(out, '_')
+			}
+		}
+		out = append(out, unicode.ToLower(ch))
+	}
+	name := string(out)
+	switch name {
+	case "set":
+		return "set_expr"
+	case "command":
+		return "raw_command"
+	}
+	return strings.TrimPrefix(name, "list_")
+}
+
+// Methods returns the API calls that can be made by scripts, sorted by
+// name.
+func Methods() []Method {
+	return methods
+}
+
+// Call makes the API call m using client. The arguments of the call are
+// taken from args, positionally, and from kwargs, by name. The reply is
+// returned as a generic value.
+func (m *Method) Call(client service.Client, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	if len(args) > len(m.ArgNames) {
+		return nil, fmt.Errorf("too many arguments for %s", m.Name)
+	}
+	rpcArgs := reflect.New(m.argType)
+	for i, arg := range args {
+		if arg == nil {
+			continue
+		}
+		if err := unmarshalField(rpcArgs.Elem().Field(i), arg, m.ArgNames[i]); err != nil {
+			return nil, err
+		}
+	}
+	for name, arg := range kwargs {
+		field := rpcArgs.Elem().FieldByName(name)
+		if !field.IsValid() {
+			return nil, fmt.Errorf("unknown argument %q", name)
+		}
+		if err := unmarshalField(field, arg, name); err != nil {
+			return nil, err
+		}
+	}
+	rpcRet := reflect.New(m.retType)
+	if err := client.CallAPI(m.RPCName, rpcArgs.Interface(), rpcRet.Interface()); err != nil {
+		return nil, err
+	}
+	return ToValue(rpcRet.Interface())
+}
+
+// unmarshalField converts the generic value v to the type of field.
+func unmarshalField(field reflect.Value, v interface{}, name string) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("argument %s: %v", name, err)
+	}
+	if err := json.Unmarshal(buf, field.Addr().Interface()); err != nil {
+		return fmt.Errorf("argument %s: %v", name, err)
+	}
+	return nil
+}
+
+// ToValue converts v to a generic value, as it would be seen by a JSON-RPC
+// client. Integers are returned as int64 if they fit, float64 otherwise.
+func ToValue(v interface{}) (interface{}, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	var r interface{}
+	if err := dec.Decode(&r); err != nil {
+		return nil, err
+	}
+	return convertNumbers(r), nil
+}
+
+func convertNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i := range v {
+			v[i] = convertNumbers(v[i])
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = convertNumbers(v[k])
+		}
+	}
+	return v
+}
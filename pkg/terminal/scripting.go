@@ -0,0 +1,86 @@
+package terminal
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/go-delve/delve/pkg/terminal/luabind"
+	"github.com/go-delve/delve/pkg/terminal/scriptbind"
+	"github.com/go-delve/delve/pkg/terminal/starbind"
+)
+
+// ScriptEngine is an interpreter for a scripting language that can be used
+// to write scripts for the source command.
+type ScriptEngine interface {
+	// Execute executes the script at path.
+	Execute(path string) error
+	// REPL starts an interactive interpreter.
+	REPL() error
+	// Cancel interrupts the script currently executing, if any.
+	Cancel()
+}
+
+// NewScriptEngineFunc creates a ScriptEngine for a terminal. The engine
+// accesses the debugger through ctx and writes its output to out, see
+// package scriptbind for the bindings shared by all engines.
+type NewScriptEngineFunc func(ctx scriptbind.Context, out io.Writer) ScriptEngine
+
+const (
+	starlarkExt = ".star"
+	luaExt      = ".lua"
+)
+
+var (
+	scriptEnginesMu sync.Mutex
+	scriptEngines   = map[string]NewScriptEngineFunc{}
+)
+
+// RegisterScriptEngine makes a scripting language available to the source
+// command, for scripts with extension ext (including the leading dot).
+// Engines must be registered before the terminal is created.
+// If RegisterScriptEngine is called twice for the same extension, or for
+// the extension of the built-in starlark and Lua engines, it panics.
+func RegisterScriptEngine(ext string, newEngine NewScriptEngineFunc) {
+	scriptEnginesMu.Lock()
+	defer scriptEnginesMu.Unlock()
+	if newEngine == nil {
+		panic("terminal: RegisterScriptEngine engine is nil")
+	}
+	if _, dup := scriptEngines[ext]; dup || ext == starlarkExt || ext == luaExt {
+		panic(fmt.Sprintf("terminal: RegisterScriptEngine called twice for extension %s", ext))
+	}
+	scriptEngines[ext] = newEngine
+}
+
+// newScriptEngines returns the script engines of t, indexed by extension.
+func (t *Term) newScriptEngines() map[string]ScriptEngine {
+	scriptEnginesMu.Lock()
+	defer scriptEnginesMu.Unlock()
+	r := map[string]ScriptEngine{
+		starlarkExt: starlarkEngine{t.starlarkEnv},
+		luaExt:      luabind.New(starlarkContext{t}, t.stdout),
+	}
+	for ext, newEngine := range scriptEngines {
+		r[ext] = newEngine(starlarkContext{t}, t.stdout)
+	}
+	return r
+}
+
+// starlarkEngine is the ScriptEngine for starlark scripts.
+type starlarkEngine struct {
+	env *starbind.Env
+}
+
+func (e starlarkEngine) Execute(path string) error {
+	_, err := e.env.Execute(path, nil, "main", nil)
+	return err
+}
+
+func (e starlarkEngine) REPL() error {
+	return e.env.REPL()
+}
+
+func (e starlarkEngine) Cancel() {
+	e.env.Cancel()
+}
@@ -0,0 +1,321 @@
+package starbind
+
+import (
+	"fmt"
+	"strings"
+
+	"go.starlark.net/starlark"
+
+	"github.com/go-delve/delve/pkg/config"
+)
+
+// commandArgSpec describes an argument of a command registered with
+// register_command.
+type commandArgSpec struct {
+	name     string // name of the keyword argument passed to the command function
+	flag     string // name of the flag, including the leading '-', empty for positional arguments
+	hasValue bool   // the flag takes a value
+	optional bool   // the positional argument can be omitted
+	variadic bool   // the positional argument collects all remaining arguments
+}
+
+// parseCommandArgSpecs parses the argument specifications passed to
+// register_command:
+//
+//	"-name"		a flag, passed to the function as True or False
+//	"-name="	a flag with a value, passed as a string or None
+//	"name"		a positional argument
+//	"name?"		an optional positional argument, passed as a string or None
+//	"name..."	all remaining positional arguments, passed as a list of strings
+func parseCommandArgSpecs(specs []string) ([]commandArgSpec, error) {
+	r := make([]commandArgSpec, 0, len(specs))
+	seenOptional, seenVariadic := false, false
+	for _, s := range specs {
+		var spec commandArgSpec
+		switch {
+		case strings.HasPrefix(s, "-"):
+			spec.flag = s
+			if strings.HasSuffix(s, "=") {
+				spec.flag = s[:len(s)-1]
+				spec.hasValue = true
+			}
+			spec.name = strings.Replace(spec.flag[1:], "-", "_", -1)
+		case strings.HasSuffix(s, "..."):
+			spec.name = s[:len(s)-len("...")]
+			spec.variadic = true
+		case strings.HasSuffix(s, "?"):
+			spec.name = s[:len(s)-1]
+			spec.optional = true
+		default:
+			spec.name = s
+		}
+		if spec.name == "" {
+			return nil, fmt.Errorf("invalid argument specification %q", s)
+		}
+		if spec.flag == "" {
+			if seenVariadic {
+				return nil, fmt.Errorf("argument %q follows a variadic argument", s)
+			}
+			if seenOptional && !spec.optional && !spec.variadic {
+				return nil, fmt.Errorf("required argument %q follows an optional argument", s)
+			}
+			seenOptional = seenOptional || spec.optional
+			seenVariadic = spec.variadic
+		}
+		for i := range r {
+			if r[i].name == spec.name {
+				return nil, fmt.Errorf("duplicate argument %q", spec.name)
+			}
+		}
+		r = append(r, spec)
+	}
+	return r, nil
+}
+
+// commandUsage returns the usage line of command name.
+func commandUsage(name string, specs []commandArgSpec) string {
+	var buf strings.Builder
+	buf.WriteString(name)
+	for _, spec := range specs {
+		switch {
+		case spec.hasValue:
+			fmt.Fprintf(&buf, " [%s <%s>]", spec.flag, spec.name)
+		case spec.flag != "":
+			fmt.Fprintf(&buf, " [%s]", spec.flag)
+		case spec.variadic:
+			fmt.Fprintf(&buf, " [<%s>...]", spec.name)
+		case spec.optional:
+			fmt.Fprintf(&buf, " [<%s>]", spec.name)
+		default:
+			fmt.Fprintf(&buf, " <%s>", spec.name)
+		}
+	}
+	return buf.String()
+}
+
+// parseCommandArgs parses the command line args according to specs and
+// returns the keyword arguments for the command function.
+func parseCommandArgs(specs []commandArgSpec, args string) ([]starlark.Tuple, error) {
+	vals := make(map[string]starlark.Value, len(specs))
+	var positional []commandArgSpec
+	for _, spec := range specs {
+		switch {
+		case spec.hasValue, spec.optional:
+			vals[spec.name] = starlark.None
+		case spec.flag != "":
+			vals[spec.name] = starlark.False
+		case spec.variadic:
+			vals[spec.name] = starlark.NewList(nil)
+		}
+		if spec.flag == "" {
+			positional = append(positional, spec)
+		}
+	}
+
+	findFlag := func(flag string) *commandArgSpec {
+		for i := range specs {
+			if specs[i].flag == flag {
+				return &specs[i]
+			}
+		}
+		return nil
+	}
+
+	argv := config.SplitQuotedFields(args, '"')
+	flagsDone := false
+	for i := 0; i < len(argv); i++ {
+		arg := argv[i]
+		if !flagsDone && arg == "--" {
+			flagsDone = true
+			continue
+		}
+		if !flagsDone && strings.HasPrefix(arg, "-") && len(arg) > 1 {
+			flag, value, hasValue := arg, "", false
+			if eq := strings.Index(arg, "="); eq >= 0 {
+				flag, value, hasValue = arg[:eq], arg[eq+1:], true
+			}
+			if spec := findFlag(flag); spec != nil {
+				switch {
+				case !spec.hasValue && hasValue:
+					return nil, fmt.Errorf("flag %s does not take a value", flag)
+				case !spec.hasValue:
+					vals[spec.name] = starlark.True
+				case hasValue:
+					vals[spec.name] = starlark.String(value)
+				case i+1 < len(argv):
+					i++
+					vals[spec.name] = starlark.String(argv[i])
+				default:
+					return nil, fmt.Errorf("flag %s needs a value", flag)
+				}
+				continue
+			}
+			if len(positional) == 0 || !isNumber(arg) {
+				return nil, fmt.Errorf("unknown flag %s", flag)
+			}
+		}
+		if len(positional) == 0 {
+			return nil, fmt.Errorf("too many arguments")
+		}
+		if positional[0].variadic {
+			vals[positional[0].name].(*starlark.List).Append(starlark.String(arg))
+			continue
+		}
+		vals[positional[0].name] = starlark.String(arg)
+		positional = positional[1:]
+	}
+	for _, spec := range positional {
+		if !spec.optional && !spec.variadic {
+			return nil, fmt.Errorf("missing argument <%s>", spec.name)
+		}
+	}
+
+	kwargs := make([]starlark.Tuple, 0, len(specs))
+	for _, spec := range specs {
+		kwargs = append(kwargs, starlark.Tuple{starlark.String(spec.name), vals[spec.name]})
+	}
+	return kwargs, nil
+}
+
+// isNumber returns true if s is a negative number, which is passed as a
+// positional argument instead of being interpreted as an unknown flag.
+func isNumber(s string) bool {
+	for _, ch := range s[1:] {
+		if (ch < '0' || ch > '9') && ch != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// registerCommand implements the register_command builtin.
+func (env *Env) registerCommand(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var (
+		name      string
+		fn        starlark.Callable
+		argsList  *starlark.List
+		help      string
+		completer starlark.Value = starlark.None
+	)
+	if err := starlark.UnpackArgs(registerCommandBuiltinName, args, kwargs, "name", &name, "fn", &fn, "args?", &argsList, "help?", &help, "completer?", &completer); err != nil {
+		return nil, decorateError(thread, err)
+	}
+
+	var specstrs []string
+	if argsList != nil {
+		for i := 0; i < argsList.Len(); i++ {
+			s, ok := argsList.Index(i).(starlark.String)
+			if !ok {
+				return nil, decorateError(thread, fmt.Errorf("argument specifications must be strings"))
+			}
+			specstrs = append(specstrs, string(s))
+		}
+	}
+	specs, err := parseCommandArgSpecs(specstrs)
+	if err != nil {
+		return nil, decorateError(thread, err)
+	}
+
+	complete, err := env.commandCompleter(specs, completer)
+	if err != nil {
+		return nil, decorateError(thread, err)
+	}
+
+	if help == "" {
+		if fnval, ok := fn.(*starlark.Function); ok {
+			help = fnval.Doc()
+		}
+	}
+	if help == "" {
+		help = "user defined"
+	}
+	summary, rest := help, ""
+	if nl := strings.Index(help, "\n"); nl >= 0 {
+		summary, rest = help[:nl], strings.TrimSpace(help[nl+1:])
+	}
+	helpMsg := summary + "\n\n\t" + commandUsage(name, specs)
+	if rest != "" {
+		helpMsg += "\n\n" + rest
+	}
+
+	env.ctx.RegisterCommand(name, helpMsg, func(args string) error {
+		kwargs, err := parseCommandArgs(specs, args)
+		if err != nil {
+			return fmt.Errorf("%v\nusage: %s", err, commandUsage(name, specs))
+		}
+		_, err = starlark.Call(env.newThread(), fn, nil, kwargs)
+		return err
+	})
+	env.ctx.RegisterCommandCompleter(name, complete)
+	return starlark.None, nil
+}
+
+// commandCompleter returns the completion function for a command
+// registered with register_command. The function receives the arguments
+// typed so far and returns the candidates for the last one.
+// The completer specified by the script can either be a function, called
+// with the arguments typed so far, or a list of candidates. Flag names are
+// always completed.
+func (env *Env) commandCompleter(specs []commandArgSpec, completer starlark.Value) (func(args string) []string, error) {
+	var hints []string
+	var completerFn starlark.Callable
+	switch completer := completer.(type) {
+	case starlark.NoneType:
+	case starlark.String:
+		return nil, fmt.Errorf("completer must be a function or a list of strings")
+	case starlark.Callable:
+		completerFn = completer
+	case starlark.Indexable:
+		var err error
+		hints, err = stringList(completer)
+		if err != nil {
+			return nil, fmt.Errorf("completer: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("completer must be a function or a list of strings")
+	}
+
+	return func(args string) []string {
+		last := ""
+		if !strings.HasSuffix(args, " ") {
+			if fields := strings.Fields(args); len(fields) > 0 {
+				last = fields[len(fields)-1]
+			}
+		}
+		var r []string
+		if strings.HasPrefix(last, "-") {
+			for _, spec := range specs {
+				if spec.flag != "" && strings.HasPrefix(spec.flag, last) {
+					r = append(r, spec.flag)
+				}
+			}
+		}
+		if completerFn != nil {
+			v, err := starlark.Call(env.newThread(), completerFn, starlark.Tuple{starlark.String(args)}, nil)
+			if err != nil {
+				return r
+			}
+			if v, ok := v.(starlark.Indexable); ok {
+				hints, _ = stringList(v)
+			}
+		}
+		for _, hint := range hints {
+			if strings.HasPrefix(hint, last) {
+				r = append(r, hint)
+			}
+		}
+		return r
+	}, nil
+}
+
+func stringList(v starlark.Indexable) ([]string, error) {
+	r := make([]string, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		s, ok := v.Index(i).(starlark.String)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a string", v.Index(i))
+		}
+		r = append(r, string(s))
+	}
+	return r, nil
+}
@@ -12,8 +12,7 @@ import (
 	"go.starlark.net/resolve"
 	"go.starlark.net/starlark"
 
-	"github.com/go-delve/delve/service"
-	"github.com/go-delve/delve/service/api"
+	"github.com/go-delve/delve/pkg/terminal/scriptbind"
 )
 
 //go:generate go run ../../../_scripts/gen-starlark-bindings.go go ./starlark_mapping.go
@@ -21,6 +20,7 @@ import (
 
 const (
 	dlvCommandBuiltinName        = "dlv_command"
+	registerCommandBuiltinName   = "register_command"
 	readFileBuiltinName          = "read_file"
 	writeFileBuiltinName         = "write_file"
 	commandPrefix                = "command_"
@@ -41,13 +41,7 @@ func init() {
 
 // Context is the context in which starlark scripts are evaluated.
 // It contains methods to call API functions, command line commands, etc.
-type Context interface {
-	Client() service.Client
-	RegisterCommand(name, helpMsg string, cmdfn func(args string) error)
-	CallCommand(cmdstr string) error
-	Scope() api.EvalScope
-	LoadConfig() api.LoadConfig
-}
+type Context = scriptbind.Context
 
 // Env is the environment used to evaluate starlark scripts.
 type Env struct {
@@ -86,6 +80,7 @@ func New(ctx Context, out EchoWriter) *Env {
 		}
 		return starlark.None, decorateError(thread, err)
 	})
+	env.env[registerCommandBuiltinName] = starlark.NewBuiltin(registerCommandBuiltinName, env.registerCommand)
 	env.env[readFileBuiltinName] = starlark.NewBuiltin(readFileBuiltinName, func(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 		if len(args) != 1 {
 			return nil, decorateError(thread, fmt.Errorf("wrong number of arguments"))
@@ -46,6 +46,16 @@ func (ctx starlarkContext) RegisterCommand(name, helpMsg string, fn func(args st
 	}
 }
 
+func (ctx starlarkContext) RegisterCommandCompleter(name string, complete func(args string) []string) {
+	for i := range ctx.term.cmds.cmds {
+		cmd := &ctx.term.cmds.cmds[i]
+		if cmd.match(name) {
+			cmd.completer = complete
+			return
+		}
+	}
+}
+
 func (ctx starlarkContext) CallCommand(cmdstr string) error {
 	return ctx.term.cmds.Call(cmdstr, ctx.term)
 }
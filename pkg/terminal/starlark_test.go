@@ -1,9 +1,14 @@
 package terminal
 
 import (
+	"bytes"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/go-delve/delve/pkg/config"
+	"github.com/go-delve/delve/pkg/proc/test"
 )
 
 func TestStarlarkExamples(t *testing.T) {
@@ -158,3 +163,92 @@ func TestStarlarkVariable(t *testing.T) {
 		}
 	})
 }
+
+func TestStarlarkRegisterCommand(t *testing.T) {
+	term := New(nil, &config.Config{})
+	defer term.Close()
+	var buf bytes.Buffer
+	term.RedirectTo(&buf)
+	term.stdout.colorEscapes = nil
+	term.starlarkEnv.Redirect(term.stdout)
+
+	_, err := term.starlarkEnv.Execute("<stdin>", `
+def dumpq(full, depth, queue, fields):
+	"""Prints a queue."""
+	print(queue, full, depth, fields)
+
+def complete_queue(args):
+	return ["workQueue", "waitQueue", "other"]
+
+register_command("dumpq", dumpq, args=["-full", "-depth=", "queue", "fields..."], completer=complete_queue)
+`, "main", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		args, tgt string
+	}{
+		{"q1", "q1 False None []\n"},
+		{"-full q1 a b", "q1 True None [\"a\", \"b\"]\n"},
+		{"q1 -depth 3 a", "q1 False 3 [\"a\"]\n"},
+		{"-depth=-1 -- -q1", "-q1 False -1 []\n"},
+	} {
+		buf.Reset()
+		if err := term.cmds.Call("dumpq "+tc.args, term); err != nil {
+			t.Fatalf("dumpq %s: %v", tc.args, err)
+		}
+		if buf.String() != tc.tgt {
+			t.Errorf("dumpq %s: got %q expected %q", tc.args, buf.String(), tc.tgt)
+		}
+	}
+
+	for _, args := range []string{"", "-bogus q1", "-depth", "-full=1 q1"} {
+		if err := term.cmds.Call("dumpq "+args, term); err == nil {
+			t.Errorf("dumpq %s: expected error", args)
+		}
+	}
+
+	cmd := term.cmds.Find("dumpq", noPrefix)
+	if !strings.Contains(cmd.helpMsg, "dumpq [-full] [-depth <depth>] <queue> [<fields>...]") {
+		t.Errorf("usage not in help message: %q", cmd.helpMsg)
+	}
+	if cmd.completer == nil {
+		t.Fatal("completer not registered")
+	}
+	for _, tc := range []struct {
+		args string
+		tgt  []string
+	}{
+		{"w", []string{"workQueue", "waitQueue"}},
+		{"-f", []string{"-full"}},
+		{"-full o", []string{"other"}},
+	} {
+		out := cmd.completer(tc.args)
+		if fmt.Sprint(out) != fmt.Sprint(tc.tgt) {
+			t.Errorf("completer(%q): got %q expected %q", tc.args, out, tc.tgt)
+		}
+	}
+}
+
+func TestLuaExample(t *testing.T) {
+	withTestTerminal("testvariables2", t, func(term *FakeTerminal) {
+		term.MustExec("continue")
+		out := term.MustExec("source " + filepath.Join(test.FindFixturesDir(), "lua_example.lua"))
+		t.Logf("source: %s", out)
+		if !strings.Contains(out, "main.afunc1") {
+			t.Errorf("breakpoint not created by lua script")
+		}
+		out = term.MustExec("luaecho i1")
+		if out != "i1 = 1\n" {
+			t.Errorf("wrong output of luaecho: %q", out)
+		}
+		out = term.MustExec("luafuncs ^main\\.afunc")
+		if out != "3 functions\n" {
+			t.Errorf("wrong output of luafuncs: %q", out)
+		}
+		if _, err := term.Exec("luaecho nonexistentvar"); err == nil || !strings.Contains(err.Error(), "eval: ") {
+			t.Errorf("expected eval error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package terminal
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/go-delve/delve/service/rpc2"
+)
+
+// Kinds of the entries of a structured transcript.
+const (
+	transcriptEntryCommand = "command"
+	transcriptEntryError   = "error"
+	transcriptEntryRPC     = "rpc"
+	transcriptEntryStop    = "stop"
+)
+
+// structuredTranscriptEntry is a line of a structured transcript.
+type structuredTranscriptEntry struct {
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"`
+
+	// Command is the command line, for command and error entries.
+	Command string `json:"command,omitempty"`
+	// Error is the error returned by the command or by the RPC call.
+	Error string `json:"error,omitempty"`
+
+	// Method, Args, Reply and Duration describe an RPC call.
+	Method   string        `json:"method,omitempty"`
+	Args     interface{}   `json:"args,omitempty"`
+	Reply    interface{}   `json:"reply,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+
+	// State is the state of the target, for stop entries.
+	State *api.DebuggerState `json:"state,omitempty"`
+}
+
+// structuredTranscript writes a log of the commands executed, the RPC calls
+// they make and the stops of the target, as JSON lines.
+type structuredTranscript struct {
+	mu     sync.Mutex
+	fh     io.Closer
+	enc    *json.Encoder
+	client *rpc2.RPCClient
+}
+
+func newStructuredTranscript(fh io.WriteCloser, client *rpc2.RPCClient) *structuredTranscript {
+	st := &structuredTranscript{fh: fh, enc: json.NewEncoder(fh), client: client}
+	client.SetCallObserver(st.rpc)
+	return st
+}
+
+func (st *structuredTranscript) write(entry *structuredTranscriptEntry) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.enc == nil {
+		return
+	}
+	st.enc.Encode(entry)
+}
+
+// command records the execution of cmdstr.
+func (st *structuredTranscript) command(cmdstr string) {
+	if st == nil {
+		return
+	}
+	st.write(&structuredTranscriptEntry{Time: time.Now(), Kind: transcriptEntryCommand, Command: cmdstr})
+}
+
+// commandError records the error returned by cmdstr.
+func (st *structuredTranscript) commandError(cmdstr string, err error) {
+	if st == nil || err == nil {
+		return
+	}
+	st.write(&structuredTranscriptEntry{Time: time.Now(), Kind: transcriptEntryError, Command: cmdstr, Error: err.Error()})
+}
+
+// rpc records an RPC call and, if the call resumed the target, the state
+// of the target when it stopped.
+func (st *structuredTranscript) rpc(method string, args, reply interface{}, err error, start time.Time) {
+	end := time.Now()
+	entry := &structuredTranscriptEntry{Time: start, Kind: transcriptEntryRPC, Method: method, Args: args, Reply: reply, Duration: end.Sub(start)}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	st.write(entry)
+
+	var out *rpc2.CommandOut
+	switch reply := reply.(type) {
+	case *rpc2.CommandOut:
+		out = reply
+	case **rpc2.CommandOut:
+		out = *reply
+	}
+	if out != nil && err == nil && !out.State.Running {
+		st.write(&structuredTranscriptEntry{Time: end, Kind: transcriptEntryStop, State: &out.State})
+	}
+}
+
+// Close stops recording and closes the output file.
+func (st *structuredTranscript) Close() error {
+	if st == nil {
+		return nil
+	}
+	st.client.SetCallObserver(nil)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.enc = nil
+	return st.fh.Close()
+}
@@ -0,0 +1,258 @@
+package terminal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	isatty "github.com/mattn/go-isatty"
+
+	"github.com/go-delve/delve/pkg/config"
+	"github.com/go-delve/delve/service/api"
+)
+
+// goModule is the main module of the current directory.
+type goModule struct {
+	path string // module path
+	dir  string // directory containing go.mod
+}
+
+// pathComponents splits path into its components, accepting both / and \
+// as separators.
+func pathComponents(path string) []string {
+	return strings.FieldsFunc(path, func(ch rune) bool { return ch == '/' || ch == '\\' })
+}
+
+// trimPathComponents removes the last n components of path, preserving
+// its separators.
+func trimPathComponents(path string, n int) string {
+	for ; n > 0; n-- {
+		path = strings.TrimRight(path, "/\\")
+		i := strings.LastIndexAny(path, "/\\")
+		if i < 0 {
+			return ""
+		}
+		path = path[:i]
+	}
+	return strings.TrimRight(path, "/\\")
+}
+
+// commonSuffixLen returns the number of trailing components a and b have
+// in common.
+func commonSuffixLen(a, b []string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return n
+}
+
+// inferSubstitutePathFromSources returns a substitute-path rule mapping
+// the source file of the target that corresponds to the local file path.
+// The source with the most trailing path components in common with path is
+// used, if it is not ambiguous.
+func inferSubstitutePathFromSources(path string, sources []string) (from, to string, ok bool) {
+	local := pathComponents(path)
+	best, bestn, ambiguous := "", 0, false
+	for _, source := range sources {
+		n := commonSuffixLen(local, pathComponents(source))
+		switch {
+		case n > bestn:
+			best, bestn, ambiguous = source, n, false
+		case n == bestn && n > 0 && source != best:
+			ambiguous = true
+		}
+	}
+	if bestn == 0 || ambiguous || bestn == len(local) || bestn == len(pathComponents(best)) {
+		return "", "", false
+	}
+	return trimPathComponents(best, bestn), trimPathComponents(path, bestn), true
+}
+
+// inferSubstitutePathToLocal returns a substitute-path rule that maps path,
+// a source file of the target that does not exist locally, to a local file.
+// If the package containing path belongs to mod the rule maps the
+// directory of the module; otherwise the longest suffix of path that exists
+// in one of the directories in roots is used. Relative paths, produced by
+// -trimpath, are looked up in roots as they are.
+func inferSubstitutePathToLocal(path string, pkgs []api.PackageBuildInfo, mod goModule, roots []string, exists func(string) bool) (from, to string, ok bool) {
+	comps := pathComponents(path)
+	if len(comps) < 2 {
+		return "", "", false
+	}
+
+	if mod.path != "" {
+		dir := trimPathComponents(path, 1)
+		for _, pkg := range pkgs {
+			if pkg.DirectoryPath != dir || (pkg.ImportPath != mod.path && !strings.HasPrefix(pkg.ImportPath, mod.path+"/")) {
+				continue
+			}
+			rel := strings.TrimPrefix(pkg.ImportPath[len(mod.path):], "/")
+			nrel := len(pathComponents(rel))
+			if nrel > 0 && commonSuffixLen(pathComponents(dir), pathComponents(rel)) != nrel {
+				// the directory of the package was not derived from its import path
+				break
+			}
+			if exists(filepath.Join(mod.dir, filepath.FromSlash(rel), comps[len(comps)-1])) {
+				return trimPathComponents(dir, nrel), mod.dir, true
+			}
+			break
+		}
+	}
+
+	if !strings.HasPrefix(path, "/") && !filepath.IsAbs(path) {
+		// paths of packages outside of the main module, compiled with
+		// -trimpath, start with the module path
+		for _, root := range roots {
+			if root != "" && exists(filepath.Join(root, filepath.Join(comps...))) {
+				return comps[0], filepath.Join(root, comps[0]), true
+			}
+		}
+	}
+
+	for n := len(comps) - 1; n > 0; n-- {
+		suffix := filepath.Join(comps[len(comps)-n:]...)
+		for _, root := range roots {
+			if root != "" && exists(filepath.Join(root, suffix)) {
+				return trimPathComponents(path, n), root, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+var goModuleRe = regexp.MustCompile(`^\s*module\s+"?([^"\s]+)"?`)
+
+// findGoModule returns the module containing dir.
+func findGoModule(dir string) goModule {
+	for {
+		fh, err := os.Open(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			defer fh.Close()
+			s := bufio.NewScanner(fh)
+			for s.Scan() {
+				if m := goModuleRe.FindStringSubmatch(s.Text()); m != nil {
+					return goModule{path: m[1], dir: dir}
+				}
+			}
+			return goModule{}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return goModule{}
+		}
+		dir = parent
+	}
+}
+
+// substitutePathRoots returns the local directories where the source files
+// of the target are searched: the current directory, GOROOT, the module
+// cache and GOPATH/src.
+func (t *Term) substitutePathRoots() []string {
+	wd, _ := os.Getwd()
+	roots := []string{wd}
+	goenv := map[string]string{
+		"GOROOT":     runtime.GOROOT(),
+		"GOMODCACHE": os.Getenv("GOMODCACHE"),
+		"GOPATH":     os.Getenv("GOPATH"),
+	}
+	if out, err := exec.Command("go", "env", "GOROOT", "GOMODCACHE", "GOPATH").Output(); err == nil {
+		v := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+		if len(v) == 3 {
+			goenv["GOROOT"], goenv["GOMODCACHE"], goenv["GOPATH"] = v[0], v[1], v[2]
+		}
+	}
+	if goenv["GOROOT"] != "" {
+		roots = append(roots, filepath.Join(goenv["GOROOT"], "src"), goenv["GOROOT"])
+	}
+	roots = append(roots, goenv["GOMODCACHE"])
+	for _, gopath := range filepath.SplitList(goenv["GOPATH"]) {
+		if goenv["GOMODCACHE"] == "" {
+			roots = append(roots, filepath.Join(gopath, "pkg", "mod"))
+		}
+		roots = append(roots, filepath.Join(gopath, "src"))
+	}
+	return roots
+}
+
+// inferSubstitutePath tries to find a local copy of path, a source file of
+// the target that does not exist locally, and offers to add the
+// substitute-path rule mapping it to the user. Returns true if a rule was
+// added.
+func (t *Term) inferSubstitutePath(path string) bool {
+	dir := trimPathComponents(path, 1)
+	if t.conf == nil || t.substitutePathTried[dir] || !t.canPrompt() {
+		return false
+	}
+	if t.substitutePathTried == nil {
+		t.substitutePathTried = make(map[string]bool)
+	}
+	t.substitutePathTried[dir] = true
+
+	pkgs, _ := t.client.ListPackagesBuildInfo(false)
+	wd, _ := os.Getwd()
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	from, to, ok := inferSubstitutePathToLocal(path, pkgs, findGoModule(wd), t.substitutePathRoots(), exists)
+	if !ok {
+		return false
+	}
+	return t.offerSubstitutePathRule(from, to)
+}
+
+// inferSubstitutePathForBreakpoint is called when the location of a
+// breakpoint, spec, can not be found. If spec refers to a local file that
+// doesn't match any of the source files of the target it offers to add the
+// substitute-path rule mapping the corresponding source file of the target
+// to it. Returns true if a rule was added.
+func (t *Term) inferSubstitutePathForBreakpoint(spec string) bool {
+	if t.conf == nil || !t.canPrompt() {
+		return false
+	}
+	i := strings.LastIndex(spec, ":")
+	if i <= 0 || !strings.HasSuffix(spec[:i], ".go") {
+		return false
+	}
+	path, err := filepath.Abs(spec[:i])
+	if err != nil {
+		return false
+	}
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	sources, err := t.client.ListSources(`[/\\]` + regexp.QuoteMeta(filepath.Base(path)) + "$")
+	if err != nil {
+		return false
+	}
+	from, to, ok := inferSubstitutePathFromSources(path, sources)
+	if !ok {
+		return false
+	}
+	return t.offerSubstitutePathRule(from, to)
+}
+
+// canPrompt returns true if the user can be asked questions.
+func (t *Term) canPrompt() bool {
+	return t.line != nil && isatty.IsTerminal(os.Stdin.Fd())
+}
+
+// offerSubstitutePathRule asks the user whether the substitute-path rule
+// from -> to should be added to the configuration and adds it.
+func (t *Term) offerSubstitutePathRule(from, to string) bool {
+	fmt.Fprintf(t.stdout, "Source files of the target in %s appear to be in %s\n", from, to)
+	ok, err := yesno(t.line, fmt.Sprintf("Add substitute-path rule %q -> %q? [y/n] ", from, to))
+	if err != nil || !ok {
+		return false
+	}
+	t.conf.SubstitutePath = append(t.conf.SubstitutePath, config.SubstitutePathRule{From: from, To: to})
+	t.substitutePathRulesCache = nil
+	fmt.Fprintf(t.stdout, "Use 'config -save' to save the rule to the configuration file\n")
+	return true
+}
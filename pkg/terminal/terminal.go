@@ -4,14 +4,19 @@ package terminal
 
 import (
 	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/rpc"
 	"os"
 	"os/signal"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/derekparker/trie"
 	"github.com/go-delve/liner"
@@ -22,6 +27,7 @@ import (
 	"github.com/go-delve/delve/pkg/terminal/starbind"
 	"github.com/go-delve/delve/service"
 	"github.com/go-delve/delve/service/api"
+	"github.com/go-delve/delve/service/rpc2"
 )
 
 const (
@@ -60,26 +66,74 @@ type Term struct {
 	InitFile string
 	displays []displayEntry
 
+	// InitCommands are executed after InitFile.
+	InitCommands []string
+
+	// SourceRoot, if not empty, is a directory where source files that can
+	// not be found are searched, for example the root file system of a
+	// container.
+	SourceRoot string
+
 	historyFile *os.File
 
+	// structuredTranscript, if not nil, records commands, RPC calls and stops
+	// of the target, see 'transcript -structured'.
+	structuredTranscript *structuredTranscript
+
 	starlarkEnv *starbind.Env
 
+	// traceOutput, if not nil, is used to print tracepoint hits in a
+	// structured format and to export them, see SetTraceOutputFormat and
+	// SetTraceExporter.
+	traceOutput *traceOutput
+
+	// scriptEngines are the interpreters used by the source command, indexed
+	// by the extension of the scripts they execute.
+	scriptEngines map[string]ScriptEngine
+
 	substitutePathRulesCache [][2]string
 
+	// substitutePathTried contains the directories of source files for which
+	// inferSubstitutePath already tried to infer a substitute-path rule.
+	substitutePathTried map[string]bool
+
+	// lastStopGoroutines and prevStopGoroutines are the goroutines that
+	// existed at the last two stops of the target, used by
+	// 'goroutines -diff'. They are only recorded once recordStopGoroutines
+	// is set by the first use of 'goroutines -diff'.
+	lastStopGoroutines, prevStopGoroutines map[int]*api.Goroutine
+	recordStopGoroutines                   bool
+
 	// quitContinue is set to true by exitCommand to signal that the process
 	// should be resumed before quitting.
 	quitContinue bool
 
+	// inStopHook is set while the on-stop hook is running, so that its
+	// commands do not trigger it again.
+	inStopHook bool
+
 	longCommandMu         sync.Mutex
 	longCommandCancelFlag bool
 
 	quittingMutex sync.Mutex
 	quitting      bool
+
+	// Redial, if not nil, is used to reconnect to a headless server with
+	// --accept-multiclient when the connection is lost.
+	Redial func() (net.Conn, error)
+
+	// session is the id of the resumable session of the terminal, see
+	// rpc2.RPCClient.SubscribeSession.
+	session string
 }
 
 type displayEntry struct {
 	expr   string
 	fmtstr string
+
+	// changesOnly is set if only the values that changed since the previous
+	// stop should be printed when the target stops.
+	changesOnly bool
 }
 
 // New returns a new Term.
@@ -118,6 +172,7 @@ func New(client service.Client, conf *config.Config) *Term {
 		t.stdout.colorEscapes[colorize.NumberStyle] = conf.SourceListNumberColor
 		t.stdout.colorEscapes[colorize.CommentStyle] = wd(conf.SourceListCommentColor, ansiBrMagenta)
 		t.stdout.colorEscapes[colorize.ArrowStyle] = wd(conf.SourceListArrowColor, ansiYellow)
+		t.stdout.highlightEscape = fmt.Sprintf(terminalHighlightEscapeCode, ansiBrYellow)
 		switch x := conf.SourceListLineColor.(type) {
 		case string:
 			t.stdout.colorEscapes[colorize.LineNoStyle] = x
@@ -137,6 +192,7 @@ func New(client service.Client, conf *config.Config) *Term {
 	}
 
 	t.starlarkEnv = starbind.New(starlarkContext{t}, t.stdout)
+	t.scriptEngines = t.newScriptEngines()
 	return t
 }
 
@@ -146,12 +202,20 @@ func (t *Term) Close() {
 	if err := t.stdout.CloseTranscript(); err != nil {
 		fmt.Fprintf(os.Stderr, "error closing transcript file: %v\n", err)
 	}
+	if err := t.structuredTranscript.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "error closing structured transcript file: %v\n", err)
+	}
+	if t.traceOutput != nil {
+		t.traceOutput.close()
+	}
 }
 
 func (t *Term) sigintGuard(ch <-chan os.Signal, multiClient bool) {
 	for range ch {
 		t.longCommandCancel()
-		t.starlarkEnv.Cancel()
+		for _, engine := range t.scriptEngines {
+			engine.Cancel()
+		}
 		state, err := t.client.GetStateNonBlocking()
 		if err == nil && state.Recording {
 			fmt.Fprintf(t.stdout, "received SIGINT, stopping recording (will not forward signal)\n")
@@ -186,6 +250,7 @@ func (t *Term) sigintGuard(ch <-chan os.Signal, multiClient bool) {
 				t.quittingMutex.Lock()
 				t.quitting = true
 				t.quittingMutex.Unlock()
+				t.endSession()
 				err := t.client.Disconnect(false)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "%v", err)
@@ -206,6 +271,116 @@ func (t *Term) sigintGuard(ch <-chan os.Signal, multiClient bool) {
 	}
 }
 
+// printReloads prints a message every time the target is reloaded because
+// its source files changed, see 'dlv debug --watch'.
+func (t *Term) printReloads(client *rpc2.RPCClient) {
+	client.Subscribe(func(ev *api.Event) bool {
+		if ev.Kind != api.EventReloaded {
+			return true
+		}
+		if ev.Err != "" {
+			fmt.Fprintf(t.stdout, "\nSource files changed, could not reload: %s\n", ev.Err)
+			return true
+		}
+		fmt.Fprintf(t.stdout, "\nSource files changed, process restarted with PID %d\n", ev.Pid)
+		for _, dbp := range ev.DiscardedBreakpoints {
+			fmt.Fprintf(t.stdout, "Discarded %s at %s: %v\n", formatBreakpointName(dbp.Breakpoint, false), t.formatBreakpointLocation(dbp.Breakpoint), dbp.Reason)
+		}
+		return true
+	})
+}
+
+// proxyTTY copies the output of the target, written to its pseudo-terminal
+// (see 'dlv debug --pty'), to stdout and sets the size of the
+// pseudo-terminal to the size of the terminal every time it changes.
+func (t *Term) proxyTTY(client *rpc2.RPCClient) {
+	resize := func() {
+		if width, height := terminalSize(); width > 0 && height > 0 {
+			client.ResizeTTY(height, width)
+		}
+	}
+	resize()
+	ch := make(chan os.Signal, 1)
+	notifyResize(ch)
+	go func() {
+		for range ch {
+			resize()
+		}
+	}()
+	client.StreamTTY(func(data []byte) bool {
+		os.Stdout.Write(data)
+		return true
+	})
+}
+
+// reconnectTimeout is how long the terminal tries to reconnect to the
+// headless server after the connection is lost.
+const reconnectTimeout = time.Minute
+
+func newSessionID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// isConnectionLost returns true if err means that the connection to the
+// server was lost.
+func isConnectionLost(err error) bool {
+	return errors.Is(err, rpc.ErrShutdown) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// reconnect reconnects to the headless server after the connection was
+// lost, resumes the session of the terminal and prints the current state of
+// the target, waiting for it to stop if it is running.
+func (t *Term) reconnect() error {
+	client := t.client.(*rpc2.RPCClient)
+	fmt.Fprintln(t.stdout, "Connection to the server lost, reconnecting...")
+	deadline := time.Now().Add(reconnectTimeout)
+	for {
+		conn, err := t.Redial()
+		if err == nil {
+			err = client.Reconnect(conn)
+			if err == nil {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("could not reconnect: %v", err)
+		}
+		time.Sleep(time.Second)
+	}
+	go client.SubscribeSession(t.session, func(*api.Event) bool { return true })
+	fmt.Fprintln(t.stdout, "Reconnected.")
+
+	state, err := client.GetStateNonBlocking()
+	if err != nil {
+		if isErrProcessExited(err) {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return nil
+		}
+		return err
+	}
+	if state.Running {
+		fmt.Fprintln(t.stdout, "The target is running, waiting for it to stop (Ctrl-C to halt it)...")
+		state, err = client.GetState()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return nil
+		}
+	}
+	printcontext(t, state)
+	return nil
+}
+
+// endSession ends the resumable session of the terminal, so that the
+// server does not wait for it to reconnect.
+func (t *Term) endSession() {
+	if t.session == "" {
+		return
+	}
+	t.client.(*rpc2.RPCClient).EndSession(t.session)
+}
+
 // Run begins running dlv in the terminal.
 func (t *Term) Run() (int, error) {
 	defer t.Close()
@@ -217,6 +392,21 @@ func (t *Term) Run() (int, error) {
 	signal.Notify(ch, syscall.SIGINT)
 	go t.sigintGuard(ch, multiClient)
 
+	if client, ok := t.client.(*rpc2.RPCClient); ok {
+		if caps, err := client.Capabilities(); err == nil {
+			if caps.Watch {
+				go t.printReloads(client)
+			}
+			if caps.PTY {
+				go t.proxyTTY(client)
+			}
+		}
+		if multiClient && t.Redial != nil {
+			t.session = newSessionID()
+			go client.SubscribeSession(t.session, func(*api.Event) bool { return true })
+		}
+	}
+
 	fns := trie.New()
 	cmds := trie.New()
 	funcs, _ := t.client.ListFunctions("")
@@ -231,6 +421,19 @@ func (t *Term) Run() (int, error) {
 
 	t.line.SetCompleter(func(line string) (c []string) {
 		cmd := t.cmds.Find(strings.Split(line, " ")[0], noPrefix)
+		if cmd.completer != nil {
+			if spc := strings.Index(line, " "); spc > 0 {
+				args := line[spc+1:]
+				prefix := line
+				if lastspc := strings.LastIndex(line, " "); lastspc >= 0 {
+					prefix = line[:lastspc+1]
+				}
+				for _, completion := range cmd.completer(args) {
+					c = append(c, prefix+completion)
+				}
+			}
+			return
+		}
 		switch cmd.aliases[0] {
 		case "break", "trace", "continue":
 			if spc := strings.LastIndex(line, " "); spc > 0 {
@@ -262,6 +465,12 @@ func (t *Term) Run() (int, error) {
 
 	fmt.Println("Type 'help' for list of commands.")
 
+	if t.conf != nil {
+		if err := t.runHook("on-attach", t.conf.OnAttach); err != nil {
+			return t.handleExit()
+		}
+	}
+
 	if t.InitFile != "" {
 		err := t.cmds.executeFile(t, t.InitFile)
 		if err != nil {
@@ -271,12 +480,22 @@ func (t *Term) Run() (int, error) {
 			fmt.Fprintf(os.Stderr, "Error executing init file: %s\n", err)
 		}
 	}
+	for _, cmdstr := range t.InitCommands {
+		if err := t.cmds.Call(cmdstr, t); err != nil {
+			if _, ok := err.(ExitRequestError); ok {
+				return t.handleExit()
+			}
+			fmt.Fprintf(os.Stderr, "Command failed: %s\n", err)
+			break
+		}
+	}
 
 	var lastCmd string
 
 	// Ensure that the target process is neither running nor recording by
 	// making a blocking call.
 	_, _ = t.client.GetState()
+	t.recordGoroutines()
 
 	for {
 		cmdstr, err := t.promptForInput()
@@ -295,10 +514,19 @@ func (t *Term) Run() (int, error) {
 
 		lastCmd = cmdstr
 
+		t.structuredTranscript.command(cmdstr)
 		if err := t.cmds.Call(cmdstr, t); err != nil {
+			t.structuredTranscript.commandError(cmdstr, err)
 			if _, ok := err.(ExitRequestError); ok {
 				return t.handleExit()
 			}
+			if t.session != "" && isConnectionLost(err) {
+				if err := t.reconnect(); err != nil {
+					return 1, err
+				}
+				t.stdout.Flush()
+				continue
+			}
 			// The type information gets lost in serialization / de-serialization,
 			// so we do a string compare on the error message to see if the process
 			// has exited, or if the command actually failed.
@@ -406,6 +634,8 @@ func (t *Term) handleExit() (int, error) {
 		return 0, nil
 	}
 
+	t.endSession()
+
 	s, err := t.client.GetState()
 	if err != nil {
 		if isErrProcessExited(err) {
@@ -426,6 +656,9 @@ func (t *Term) handleExit() (int, error) {
 		return 1, err
 	}
 	if !s.Exited {
+		if t.conf != nil {
+			t.runHook("on-detach", t.conf.OnDetach)
+		}
 		if t.quitContinue {
 			err := t.client.Disconnect(true)
 			if err != nil {
@@ -482,7 +715,7 @@ func (t *Term) removeDisplay(n int) error {
 	if n < 0 || n >= len(t.displays) {
 		return fmt.Errorf("%d is out of range", n)
 	}
-	t.displays[n] = displayEntry{"", ""}
+	t.displays[n] = displayEntry{}
 	for i := len(t.displays) - 1; i >= 0; i-- {
 		if t.displays[i].expr != "" {
 			t.displays = t.displays[:i+1]
@@ -493,13 +726,16 @@ func (t *Term) removeDisplay(n int) error {
 	return nil
 }
 
-func (t *Term) addDisplay(expr, fmtstr string) {
-	t.displays = append(t.displays, displayEntry{expr: expr, fmtstr: fmtstr})
+func (t *Term) addDisplay(expr, fmtstr string, changesOnly bool) {
+	t.displays = append(t.displays, displayEntry{expr: expr, fmtstr: fmtstr, changesOnly: changesOnly})
 }
 
-func (t *Term) printDisplay(i int) {
+// printDisplay prints the value of the i-th display expression, values
+// that changed since the previous stop are highlighted. If changesOnly is
+// set only the changed values are printed.
+func (t *Term) printDisplay(i int, changesOnly bool) {
 	expr, fmtstr := t.displays[i].expr, t.displays[i].fmtstr
-	val, err := t.client.EvalVariable(api.EvalScope{GoroutineID: -1}, expr, ShortLoadConfig)
+	val, changes, err := t.client.EvalDisplay(api.EvalScope{GoroutineID: -1}, expr, ShortLoadConfig)
 	if err != nil {
 		if isErrProcessExited(err) {
 			return
@@ -507,19 +743,91 @@ func (t *Term) printDisplay(i int) {
 		fmt.Fprintf(t.stdout, "%d: %s = error %v\n", i, expr, err)
 		return
 	}
-	fmt.Fprintf(t.stdout, "%d: %s = %s\n", i, val.Name, val.SinglelineStringFormatted(fmtstr))
+	if changesOnly {
+		for _, change := range changes {
+			fmt.Fprintf(t.stdout, "%d: %s: %s => ", i, change.Path, change.Old)
+			t.stdout.PrintHighlighted(change.New)
+			fmt.Fprintln(t.stdout)
+		}
+		return
+	}
+	line := fmt.Sprintf("%d: %s = %s", i, val.Name, val.SinglelineStringFormatted(fmtstr))
+	if len(changes) == 0 {
+		fmt.Fprintln(t.stdout, line)
+		return
+	}
+	t.stdout.PrintHighlighted(line)
+	if len(changes) > 1 || changes[0].Path != expr {
+		paths := make([]string, len(changes))
+		for j := range changes {
+			paths[j] = changes[j].Path
+		}
+		fmt.Fprintf(t.stdout, " (changed: %s)", strings.Join(paths, ", "))
+	}
+	fmt.Fprintln(t.stdout)
 }
 
-func (t *Term) printDisplays() {
+// printDisplays prints all display expressions, if onStop is set display
+// expressions added with -changes only print their changed values.
+func (t *Term) printDisplays(onStop bool) {
 	for i := range t.displays {
 		if t.displays[i].expr != "" {
-			t.printDisplay(i)
+			t.printDisplay(i, onStop && t.displays[i].changesOnly)
 		}
 	}
 }
 
 func (t *Term) onStop() {
-	t.printDisplays()
+	t.printDisplays(true)
+	t.recordGoroutines()
+	if t.conf != nil && len(t.conf.OnStop) > 0 && !t.inStopHook {
+		if state, err := t.client.GetState(); err == nil && !state.Exited {
+			// Commands that resume the target call onStop again.
+			t.inStopHook = true
+			t.runHook("on-stop", t.conf.OnStop)
+			t.inStopHook = false
+		}
+	}
+}
+
+// runHook executes the commands of a hook of the configuration file (see
+// config.Config.OnAttach), errors are printed and the following commands
+// are executed anyway. Returns an ExitRequestError if one of the commands
+// is exit.
+func (t *Term) runHook(name string, cmds []string) error {
+	for _, cmdstr := range cmds {
+		if err := t.cmds.Call(cmdstr, t); err != nil {
+			if _, ok := err.(ExitRequestError); ok {
+				return err
+			}
+			fmt.Fprintf(t.stdout, "%s: %s: %v\n", name, cmdstr, err)
+		}
+	}
+	return nil
+}
+
+// maxRecordedGoroutines is the maximum number of goroutines saved by
+// recordGoroutines.
+const maxRecordedGoroutines = 10000
+
+// recordGoroutines saves the set of goroutines of the stopped target,
+// the previously saved set is kept as well so that they can be compared.
+// If the target has more than maxRecordedGoroutines goroutines nothing is
+// saved.
+func (t *Term) recordGoroutines() {
+	if !t.recordStopGoroutines {
+		return
+	}
+	gs, nextg, err := t.client.ListGoroutines(0, maxRecordedGoroutines)
+	if err != nil || nextg >= 0 {
+		t.prevStopGoroutines, t.lastStopGoroutines = t.lastStopGoroutines, nil
+		return
+	}
+	m := make(map[int]*api.Goroutine, len(gs))
+	for _, g := range gs {
+		m[g.ID] = g
+	}
+	t.prevStopGoroutines, t.lastStopGoroutines = t.lastStopGoroutines, m
 }
 
 func (t *Term) longCommandCancel() {
@@ -559,6 +867,10 @@ type transcriptWriter struct {
 	file         *bufio.Writer
 	fh           io.Closer
 	colorEscapes map[colorize.Style]string
+
+	// highlightEscape is the escape sequence used by PrintHighlighted, empty
+	// if colors are disabled.
+	highlightEscape string
 }
 
 func (w *transcriptWriter) Write(p []byte) (nn int, err error) {
@@ -589,6 +901,19 @@ func (w *transcriptWriter) ColorizePrint(path string, reader io.ReadSeeker, star
 	return err
 }
 
+// PrintHighlighted writes s highlighted, the optional transcript file
+// receives s without any escape sequence.
+func (w *transcriptWriter) PrintHighlighted(s string) {
+	if !w.fileOnly {
+		if w.highlightEscape != "" {
+			fmt.Fprint(w.w, w.highlightEscape+s+terminalResetEscapeCode)
+		} else {
+			fmt.Fprint(w.w, s)
+		}
+	}
+	w.Echo(s)
+}
+
 // Echo outputs str only to the optional transcript file.
 func (w *transcriptWriter) Echo(str string) {
 	if w.file != nil {
@@ -6,6 +6,9 @@ package terminal
 import (
 	"io"
 	"os"
+	"os/signal"
+
+	"golang.org/x/sys/unix"
 )
 
 // getColorableWriter simply returns stdout on
@@ -13,3 +16,18 @@ import (
 func getColorableWriter() io.Writer {
 	return os.Stdout
 }
+
+// terminalSize returns the width and height of the terminal attached to
+// stdout, or zero if it can not be determined.
+func terminalSize() (width, height int) {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0
+	}
+	return int(ws.Col), int(ws.Row)
+}
+
+// notifyResize relays SIGWINCH to ch.
+func notifyResize(ch chan<- os.Signal) {
+	signal.Notify(ch, unix.SIGWINCH)
+}
@@ -7,6 +7,7 @@ import (
 	"syscall"
 
 	"github.com/mattn/go-colorable"
+	"golang.org/x/sys/windows"
 )
 
 // getColorableWriter will return a writer that is capable
@@ -33,3 +34,18 @@ func getColorableWriter() io.Writer {
 	}
 	return colorable.NewColorableStdout()
 }
+
+// terminalSize returns the width and height of the console attached to
+// stdout, or zero if it can not be determined.
+func terminalSize() (width, height int) {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(os.Stdout.Fd()), &info); err != nil {
+		return 0, 0
+	}
+	return int(info.Window.Right-info.Window.Left) + 1, int(info.Window.Bottom-info.Window.Top) + 1
+}
+
+// notifyResize does nothing, changes to the size of the console are not
+// signaled on windows.
+func notifyResize(ch chan<- os.Signal) {
+}
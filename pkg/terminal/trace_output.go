@@ -0,0 +1,250 @@
+package terminal
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-delve/delve/service/api"
+)
+
+// Formats accepted by SetTraceOutputFormat.
+const (
+	TraceOutputText = "text"
+	TraceOutputJSON = "json"
+	TraceOutputCSV  = "csv"
+	// TraceOutputChromeTrace writes a JSON file in the Chrome trace event
+	// format, viewable with chrome://tracing or Perfetto.
+	TraceOutputChromeTrace = "chrometrace"
+)
+
+// Kinds of trace records.
+const (
+	traceRecordCall   = "call"
+	traceRecordReturn = "return"
+)
+
+// traceRecord is the structured output for a single hit of a tracepoint.
+type traceRecord struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Goroutine int       `json:"goroutine"`
+	Function  string    `json:"function"`
+	// Breakpoint is the name of the tracepoint, if it has one.
+	Breakpoint string `json:"breakpoint,omitempty"`
+
+	Args         []traceValue `json:"args,omitempty"`
+	ReturnValues []traceValue `json:"returnValues,omitempty"`
+
+	// Duration is the time elapsed since the matching call record, only set
+	// for return records.
+	Duration time.Duration `json:"duration,omitempty"`
+
+	Stack []string `json:"stack,omitempty"`
+}
+
+type traceValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+var traceCSVHeader = []string{"time", "kind", "goroutine", "function", "breakpoint", "args", "return_values", "duration_ns"}
+
+// traceOutput writes tracepoint hits as JSON lines or CSV records and pairs
+// the return of each traced call with its entry to compute its duration.
+// Paired calls are also sent to the OTLP exporter, if one is set.
+type traceOutput struct {
+	format string // TraceOutputText if tracepoints are printed by printTracepoint
+	enc    *json.Encoder
+	csv    *csv.Writer
+	chrome *chromeTraceWriter
+
+	headerDone bool // the CSV header was written
+
+	// calls contains, for each goroutine, the stack of the traced calls that
+	// haven't returned yet.
+	calls map[int][]*traceCall
+
+	exporter *otlpExporter
+}
+
+// traceCall is a call to a traced function that hasn't returned yet.
+type traceCall struct {
+	fn      string
+	start   time.Time
+	args    []traceValue
+	traceID [16]byte
+	spanID  [8]byte
+}
+
+// SetTraceOutputFormat sets the format used to print tracepoints hits,
+// either TraceOutputText (the default), TraceOutputJSON, TraceOutputCSV or
+// TraceOutputChromeTrace. Structured formats are written to w, or to the
+// output of the terminal if w is nil.
+func (t *Term) SetTraceOutputFormat(format string, w io.Writer) error {
+	if w == nil {
+		w = t.stdout
+	}
+	switch format {
+	case "", TraceOutputText:
+		if t.traceOutput != nil {
+			t.traceOutput.close()
+			t.traceOutput.format = TraceOutputText
+		}
+		return nil
+	case TraceOutputJSON, TraceOutputCSV, TraceOutputChromeTrace:
+		to := t.getTraceOutput()
+		to.close()
+		to.format = format
+		switch format {
+		case TraceOutputJSON:
+			to.enc = json.NewEncoder(w)
+		case TraceOutputCSV:
+			to.csv = csv.NewWriter(w)
+		case TraceOutputChromeTrace:
+			pid := 0
+			if t.client != nil {
+				pid = t.client.ProcessPid()
+			}
+			to.chrome = newChromeTraceWriter(w, pid)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown trace output format %q", format)
+	}
+}
+
+func (t *Term) getTraceOutput() *traceOutput {
+	if t.traceOutput == nil {
+		t.traceOutput = &traceOutput{format: TraceOutputText, calls: make(map[int][]*traceCall)}
+	}
+	return t.traceOutput
+}
+
+// tracepoint records the hit of a tracepoint by th.
+func (to *traceOutput) tracepoint(t *Term, th *api.Thread) {
+	now := time.Now()
+	rec := traceRecord{Time: now, Goroutine: th.GoroutineID, Function: th.Function.Name(), Breakpoint: th.Breakpoint.Name}
+	if th.Breakpoint.TraceReturn {
+		rec.Kind = traceRecordReturn
+		for _, v := range th.ReturnValues {
+			rec.ReturnValues = append(rec.ReturnValues, traceValue{Name: v.Name, Value: v.SinglelineString()})
+		}
+		if call, parent := to.popCall(th.GoroutineID, rec.Function); call != nil {
+			rec.Duration = now.Sub(call.start)
+			if to.exporter != nil {
+				to.exporter.span(call, parent, now, th.GoroutineID, rec.ReturnValues)
+			}
+			if to.chrome != nil {
+				to.chrome.complete(call, now, th.GoroutineID, rec.ReturnValues)
+			}
+		}
+	} else {
+		rec.Kind = traceRecordCall
+		if th.BreakpointInfo != nil {
+			for _, v := range th.BreakpointInfo.Arguments {
+				if v.Flags&api.VariableArgument != 0 {
+					rec.Args = append(rec.Args, traceValue{Name: v.Name, Value: v.SinglelineString()})
+				}
+			}
+		}
+		to.pushCall(th.GoroutineID, &traceCall{fn: rec.Function, start: now, args: rec.Args})
+	}
+	if to.format == TraceOutputText || to.format == TraceOutputChromeTrace {
+		return
+	}
+	if th.BreakpointInfo != nil {
+		for _, frame := range th.BreakpointInfo.Stacktrace {
+			rec.Stack = append(rec.Stack, fmt.Sprintf("%s() %s:%d", frame.Function.Name(), t.formatPath(frame.File), frame.Line))
+		}
+	}
+	to.write(&rec)
+}
+
+// close terminates the output of the current structured format and sends
+// the spans that haven't been exported yet.
+func (to *traceOutput) close() {
+	if to.chrome != nil {
+		to.chrome.close()
+	}
+	if to.exporter != nil {
+		to.exporter.flush()
+	}
+	to.enc, to.csv, to.chrome, to.headerDone = nil, nil, nil, false
+}
+
+// pushCall records the entry of a traced call on goroutine goid, the call
+// is a child of the innermost traced call that hasn't returned yet.
+func (to *traceOutput) pushCall(goid int, call *traceCall) {
+	stack := to.calls[goid]
+	if len(stack) > 0 {
+		call.traceID = stack[len(stack)-1].traceID
+	} else {
+		rand.Read(call.traceID[:])
+	}
+	rand.Read(call.spanID[:])
+	to.calls[goid] = append(stack, call)
+}
+
+// popCall returns the innermost call to fn on goroutine goid that hasn't
+// returned yet, and its parent. Calls above it never returned (for example
+// because of a panic) and are discarded.
+func (to *traceOutput) popCall(goid int, fn string) (call, parent *traceCall) {
+	stack := to.calls[goid]
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].fn != fn {
+			continue
+		}
+		call = stack[i]
+		if i > 0 {
+			parent = stack[i-1]
+		}
+		if i == 0 {
+			delete(to.calls, goid)
+		} else {
+			to.calls[goid] = stack[:i]
+		}
+		return call, parent
+	}
+	return nil, nil
+}
+
+func (to *traceOutput) write(rec *traceRecord) {
+	switch to.format {
+	case TraceOutputJSON:
+		to.enc.Encode(rec)
+	case TraceOutputCSV:
+		if !to.headerDone {
+			to.csv.Write(traceCSVHeader)
+			to.headerDone = true
+		}
+		duration := ""
+		if rec.Kind == traceRecordReturn {
+			duration = strconv.FormatInt(int64(rec.Duration), 10)
+		}
+		to.csv.Write([]string{
+			rec.Time.Format(time.RFC3339Nano),
+			rec.Kind,
+			strconv.Itoa(rec.Goroutine),
+			rec.Function,
+			rec.Breakpoint,
+			formatTraceValues(rec.Args),
+			formatTraceValues(rec.ReturnValues),
+			duration,
+		})
+		to.csv.Flush()
+	}
+}
+
+func formatTraceValues(vals []traceValue) string {
+	s := make([]string, len(vals))
+	for i := range vals {
+		s[i] = vals[i].Name + "=" + vals[i].Value
+	}
+	return strings.Join(s, ", ")
+}
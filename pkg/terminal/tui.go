@@ -0,0 +1,400 @@
+package terminal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/go-delve/liner"
+)
+
+const (
+	tuiEnterEscape   = "\x1b[?1049h"
+	tuiLeaveEscape   = "\x1b[?1049l"
+	tuiClearEscape   = "\x1b[H\x1b[2J"
+	tuiReverseEscape = "\x1b[7m"
+
+	tuiPrompt = "(tui) "
+
+	tuiDefaultWidth  = 80
+	tuiDefaultHeight = 24
+	tuiMinWidth      = 40
+	tuiMinHeight     = 16
+)
+
+// tuiKeys maps the keybindings of the tui to the commands they execute.
+var tuiKeys = map[string]string{
+	"s": "step",
+	"n": "next",
+	"o": "stepout",
+	"c": "continue",
+	"r": "restart",
+}
+
+// tuiPane is a titled region of the screen drawn by the tui command.
+type tuiPane struct {
+	title string
+	lines []string
+}
+
+func tuiCommand(t *Term, ctx callContext, args string) error {
+	if args != "" {
+		return errors.New("too many arguments to tui")
+	}
+	if t.stdout.fileOnly {
+		return errors.New("tui can not be used while the output is suppressed by transcript -x")
+	}
+
+	out := t.stdout.w
+	fmt.Fprint(out, tuiEnterEscape)
+	defer fmt.Fprint(out, tuiLeaveEscape)
+
+	var output []string
+	lastCmd := "next"
+	for {
+		t.tuiDraw(out, output)
+		cmdstr, err := t.line.Prompt(tuiPrompt)
+		if err != nil {
+			if err == io.EOF || err == liner.ErrPromptAborted {
+				return nil
+			}
+			return err
+		}
+		cmdstr = strings.TrimSpace(cmdstr)
+		switch cmdstr {
+		case "":
+			cmdstr = lastCmd
+		case "q":
+			return nil
+		}
+		if cmd, ok := tuiKeys[cmdstr]; ok {
+			cmdstr = cmd
+		}
+		if t.cmds.Find(strings.Fields(cmdstr)[0], ctx.Prefix).aliases[0] == "tui" {
+			output = []string{"Already in tui mode"}
+			continue
+		}
+		t.line.AppendHistory(cmdstr)
+		t.stdout.Echo(tuiPrompt + cmdstr + "\n")
+		lastCmd = cmdstr
+
+		output, err = t.tuiCall(cmdstr)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// tuiCall executes cmdstr and returns its output. Errors other than
+// ExitRequestError are reported in the output.
+func (t *Term) tuiCall(cmdstr string) ([]string, error) {
+	var buf bytes.Buffer
+	w, colorEscapes, highlightEscape := t.stdout.w, t.stdout.colorEscapes, t.stdout.highlightEscape
+	t.stdout.w, t.stdout.colorEscapes, t.stdout.highlightEscape = &buf, nil, ""
+	t.structuredTranscript.command(cmdstr)
+	err := t.cmds.Call(cmdstr, t)
+	t.structuredTranscript.commandError(cmdstr, err)
+	t.stdout.w, t.stdout.colorEscapes, t.stdout.highlightEscape = w, colorEscapes, highlightEscape
+	t.stdout.Flush()
+
+	if err != nil {
+		if _, ok := err.(ExitRequestError); ok {
+			return nil, err
+		}
+		if strings.Contains(err.Error(), "exited") {
+			fmt.Fprintln(&buf, err.Error())
+		} else {
+			fmt.Fprintf(&buf, "Command failed: %s\n", err)
+		}
+	}
+	s := strings.TrimRight(buf.String(), "\n")
+	if s == "" {
+		return nil, nil
+	}
+	return strings.Split(s, "\n"), nil
+}
+
+// tuiDraw redraws the whole screen: the source, disassembly, variables,
+// goroutines and breakpoints panes followed by the output of the last
+// command.
+func (t *Term) tuiDraw(out io.Writer, output []string) {
+	width, height := terminalSize()
+	if width <= 0 || height <= 0 {
+		width, height = tuiDefaultWidth, tuiDefaultHeight
+	}
+	if width < tuiMinWidth {
+		width = tuiMinWidth
+	}
+	if height < tuiMinHeight {
+		height = tuiMinHeight
+	}
+
+	outh := height / 5
+	if outh < 3 {
+		outh = 3
+	}
+	// one line for the status and one for the prompt
+	mainh := height - outh - 2
+	leftw := width * 3 / 5
+	rightw := width - leftw - 1
+
+	state, err := t.client.GetStateNonBlocking()
+	status := "Process running"
+	var loc *api.Location
+	switch {
+	case err != nil:
+		status = err.Error()
+	case state.Exited:
+		status = fmt.Sprintf("Process exited with status %d", state.ExitStatus)
+	case state.Running:
+	case state.SelectedGoroutine != nil:
+		g := state.SelectedGoroutine
+		loc = &g.CurrentLoc
+		status = fmt.Sprintf("Goroutine %d at %s", g.ID, t.formatLocation(g.CurrentLoc))
+	case state.CurrentThread != nil:
+		th := state.CurrentThread
+		loc = &api.Location{PC: th.PC, File: th.File, Line: th.Line, Function: th.Function}
+		status = fmt.Sprintf("Thread %d at %s", th.ID, t.formatLocation(*loc))
+	}
+	stopped := loc != nil
+
+	var bps []*api.Breakpoint
+	if stopped {
+		bps, _ = t.client.ListBreakpoints(false)
+	}
+
+	srch := mainh / 2
+	left := append(
+		t.tuiSourcePane(loc, bps).render(leftw, srch),
+		t.tuiDisassemblyPane(loc).render(leftw, mainh-srch)...)
+
+	varh, grh := mainh/3, mainh/3
+	right := t.tuiVariablesPane(stopped).render(rightw, varh)
+	right = append(right, t.tuiGoroutinesPane(state, stopped, grh-1).render(rightw, grh)...)
+	right = append(right, t.tuiBreakpointsPane(bps).render(rightw, mainh-varh-grh)...)
+
+	if len(output) > outh-1 {
+		output = output[len(output)-(outh-1):]
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(tuiClearEscape)
+	buf.WriteString(tuiPadLine(status, width))
+	buf.WriteString("\n")
+	for i := 0; i < mainh; i++ {
+		buf.WriteString(left[i])
+		buf.WriteString("|")
+		buf.WriteString(right[i])
+		buf.WriteString("\n")
+	}
+	for _, line := range (tuiPane{"Output", output}).render(width, outh) {
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	out.Write(buf.Bytes())
+}
+
+func (t *Term) tuiSourcePane(loc *api.Location, bps []*api.Breakpoint) tuiPane {
+	pane := tuiPane{title: "Source"}
+	if loc == nil || loc.File == "" {
+		return pane
+	}
+	pane.title = fmt.Sprintf("Source %s", t.formatPath(loc.File))
+	buf, err := os.ReadFile(t.substitutePath(loc.File))
+	if err != nil {
+		pane.lines = []string{err.Error()}
+		return pane
+	}
+	bplines := make(map[int]bool)
+	for _, bp := range bps {
+		if bp.File == loc.File {
+			bplines[bp.Line] = true
+		}
+	}
+	for i, text := range strings.Split(string(buf), "\n") {
+		lineno := i + 1
+		marker := "  "
+		if lineno == loc.Line {
+			marker = "=>"
+		}
+		bpmarker := " "
+		if bplines[lineno] {
+			bpmarker = "*"
+		}
+		pane.lines = append(pane.lines, fmt.Sprintf("%s%s%5d: %s", marker, bpmarker, lineno, text))
+	}
+	pane.lines = tuiWindow(pane.lines, loc.Line-1)
+	return pane
+}
+
+func (t *Term) tuiDisassemblyPane(loc *api.Location) tuiPane {
+	pane := tuiPane{title: "Disassembly"}
+	if loc == nil {
+		return pane
+	}
+	flavor := api.IntelFlavour
+	if t.conf != nil && t.conf.DisassembleFlavor != nil {
+		switch *t.conf.DisassembleFlavor {
+		case "go":
+			flavor = api.GoFlavour
+		case "gnu":
+			flavor = api.GNUFlavour
+		}
+	}
+	disasm, err := t.client.DisassemblePC(api.EvalScope{GoroutineID: -1, Frame: t.cmds.frame}, loc.PC, flavor)
+	if err != nil {
+		pane.lines = []string{err.Error()}
+		return pane
+	}
+	cur := 0
+	for i, inst := range disasm {
+		marker := "  "
+		if inst.AtPC {
+			marker = "=>"
+			cur = i
+		}
+		bpmarker := " "
+		if inst.Breakpoint {
+			bpmarker = "*"
+		}
+		pane.lines = append(pane.lines, fmt.Sprintf("%s%s%#x %s:%d\t%s", marker, bpmarker, inst.Loc.PC, filepath.Base(inst.Loc.File), inst.Loc.Line, inst.Text))
+	}
+	pane.lines = tuiWindow(pane.lines, cur)
+	return pane
+}
+
+func (t *Term) tuiVariablesPane(stopped bool) tuiPane {
+	pane := tuiPane{title: "Variables"}
+	if !stopped {
+		return pane
+	}
+	scope := api.EvalScope{GoroutineID: -1, Frame: t.cmds.frame}
+	for _, display := range t.displays {
+		if display.expr == "" {
+			continue
+		}
+		v, err := t.client.EvalVariable(scope, display.expr, ShortLoadConfig)
+		if err != nil {
+			pane.lines = append(pane.lines, fmt.Sprintf("%s = error %v", display.expr, err))
+			continue
+		}
+		pane.lines = append(pane.lines, fmt.Sprintf("%s = %s", display.expr, v.SinglelineString()))
+	}
+	args, _ := t.client.ListFunctionArgs(scope, ShortLoadConfig)
+	locals, _ := t.client.ListLocalVariables(scope, ShortLoadConfig)
+	for _, vars := range [][]api.Variable{args, locals} {
+		for i := range vars {
+			pane.lines = append(pane.lines, fmt.Sprintf("%s = %s", vars[i].Name, vars[i].SinglelineString()))
+		}
+	}
+	return pane
+}
+
+func (t *Term) tuiGoroutinesPane(state *api.DebuggerState, stopped bool, count int) tuiPane {
+	pane := tuiPane{title: "Goroutines"}
+	if !stopped {
+		return pane
+	}
+	gs, _, err := t.client.ListGoroutines(0, count)
+	if err != nil {
+		pane.lines = []string{err.Error()}
+		return pane
+	}
+	for _, g := range gs {
+		prefix := "  "
+		if state.SelectedGoroutine != nil && g.ID == state.SelectedGoroutine.ID {
+			prefix = "* "
+		}
+		pane.lines = append(pane.lines, prefix+t.formatGoroutine(g, api.FglUserCurrent))
+	}
+	return pane
+}
+
+func (t *Term) tuiBreakpointsPane(bps []*api.Breakpoint) tuiPane {
+	pane := tuiPane{title: "Breakpoints"}
+	for _, bp := range bps {
+		if bp.ID < 0 {
+			continue
+		}
+		name := ""
+		if bp.Name != "" {
+			name = " " + bp.Name
+		}
+		enabled := ""
+		if bp.Disabled {
+			enabled = " (disabled)"
+		}
+		pane.lines = append(pane.lines, fmt.Sprintf("%d%s at %s:%d (%d)%s", bp.ID, name, t.formatPath(bp.File), bp.Line, bp.TotalHitCount, enabled))
+	}
+	return pane
+}
+
+// render returns exactly height lines, each one width characters wide,
+// containing the title of the pane followed by as many of its lines as
+// will fit.
+func (pane tuiPane) render(width, height int) []string {
+	r := make([]string, 0, height)
+	if height <= 0 {
+		return r
+	}
+	r = append(r, tuiReverseEscape+tuiPadLine(pane.title, width)+terminalResetEscapeCode)
+	for _, line := range pane.lines {
+		if len(r) >= height {
+			break
+		}
+		r = append(r, tuiPadLine(line, width))
+	}
+	for len(r) < height {
+		r = append(r, strings.Repeat(" ", width))
+	}
+	return r
+}
+
+// tuiWindow returns the lines of a pane, scrolled so that the line at index
+// cur is the fourth line shown.
+func tuiWindow(lines []string, cur int) []string {
+	start := cur - 3
+	if start < 0 {
+		start = 0
+	}
+	if start > len(lines) {
+		start = len(lines)
+	}
+	return lines[start:]
+}
+
+// tuiPadLine expands the tabs in s and truncates or pads it with spaces to
+// width characters.
+func tuiPadLine(s string, width int) string {
+	var buf strings.Builder
+	n := 0
+	for _, ch := range s {
+		if n >= width {
+			break
+		}
+		switch {
+		case ch == '\t':
+			buf.WriteByte(' ')
+			n++
+			for n%4 != 0 && n < width {
+				buf.WriteByte(' ')
+				n++
+			}
+			continue
+		case ch < ' ' || ch == utf8.RuneError:
+			ch = '?'
+		}
+		buf.WriteRune(ch)
+		n++
+	}
+	for ; n < width; n++ {
+		buf.WriteByte(' ')
+	}
+	return buf.String()
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type PrintGoroutinesFlags uint8
@@ -11,6 +12,8 @@ type PrintGoroutinesFlags uint8
 const (
 	PrintGoroutinesStack PrintGoroutinesFlags = 1 << iota
 	PrintGoroutinesLabels
+	PrintGoroutinesDiff
+	PrintGoroutinesSortWaitTime
 )
 
 type FormatGoroutineLoc int
@@ -30,6 +33,11 @@ const (
 // The number of goroutines we're going to request on each RPC call
 const goroutineBatchSize = 10000
 
+// The number of goroutines we're going to request on each RPC call when
+// their stacktraces are also requested, smaller so that they can be
+// printed while the rest are still being unwound
+const goroutineStacktraceBatchSize = 1000
+
 func ParseGoroutineArgs(argstr string) ([]ListGoroutinesFilter, GoroutineGroupingOptions, FormatGoroutineLoc, PrintGoroutinesFlags, int, int, error) {
 	args := strings.Split(argstr, " ")
 	var filters []ListGoroutinesFilter
@@ -55,6 +63,21 @@ func ParseGoroutineArgs(argstr string) ([]ListGoroutinesFilter, GoroutineGroupin
 			fgl = FglStart
 		case "-l":
 			flags |= PrintGoroutinesLabels
+		case "-diff":
+			flags |= PrintGoroutinesDiff
+		case "-sort":
+			if i+1 >= len(args) {
+				return nil, GoroutineGroupingOptions{}, 0, 0, 0, 0, fmt.Errorf("%s must be followed by an argument", arg)
+			}
+			i++
+			switch args[i] {
+			case "id":
+				flags &^= PrintGoroutinesSortWaitTime
+			case "waittime":
+				flags |= PrintGoroutinesSortWaitTime
+			default:
+				return nil, GoroutineGroupingOptions{}, 0, 0, 0, 0, fmt.Errorf("wrong argument: '%s'", args[i])
+			}
 		case "-t":
 			flags |= PrintGoroutinesStack
 			// optional depth argument
@@ -97,12 +120,28 @@ func ParseGoroutineArgs(argstr string) ([]ListGoroutinesFilter, GoroutineGroupin
 			}
 			batchSize = 0 // grouping only works well if run on all goroutines
 
+		case "-leaks":
+			group.GroupBy = GoroutineLeak
+			// optional threshold argument
+			if i+1 < len(args) && len(args[i+1]) > 0 {
+				if _, err := time.ParseDuration(args[i+1]); err == nil {
+					group.GroupByKey = args[i+1]
+					i++
+				}
+			}
+			batchSize = 0
+
 		case "":
 			// nothing to do
 		default:
 			return nil, GoroutineGroupingOptions{}, 0, 0, 0, 0, fmt.Errorf("wrong argument: '%s'", arg)
 		}
 	}
+	if flags&PrintGoroutinesSortWaitTime != 0 {
+		batchSize = 0 // sorting by wait time only works if run on all goroutines
+	} else if flags&PrintGoroutinesStack != 0 && batchSize == goroutineBatchSize {
+		batchSize = goroutineStacktraceBatchSize
+	}
 	return filters, group, fgl, flags, depth, batchSize, nil
 }
 
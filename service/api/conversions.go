@@ -20,23 +20,24 @@ import (
 // an api.Breakpoint.
 func ConvertBreakpoint(bp *proc.Breakpoint) *Breakpoint {
 	b := &Breakpoint{
-		Name:         bp.Name,
-		ID:           bp.LogicalID(),
-		FunctionName: bp.FunctionName,
-		File:         bp.File,
-		Line:         bp.Line,
-		Addr:         bp.Addr,
-		Tracepoint:   bp.Tracepoint,
-		TraceReturn:  bp.TraceReturn,
-		Stacktrace:   bp.Stacktrace,
-		Goroutine:    bp.Goroutine,
-		Variables:    bp.Variables,
-		LoadArgs:     LoadConfigFromProc(bp.LoadArgs),
-		LoadLocals:   LoadConfigFromProc(bp.LoadLocals),
-		WatchExpr:    bp.WatchExpr,
-		WatchType:    WatchType(bp.WatchType),
-		Addrs:        []uint64{bp.Addr},
-		UserData:     bp.UserData,
+		Name:          bp.Name,
+		ID:            bp.LogicalID(),
+		FunctionName:  bp.FunctionName,
+		File:          bp.File,
+		Line:          bp.Line,
+		Addr:          bp.Addr,
+		Tracepoint:    bp.Tracepoint,
+		TraceReturn:   bp.TraceReturn,
+		Stacktrace:    bp.Stacktrace,
+		Goroutine:     bp.Goroutine,
+		Variables:     bp.Variables,
+		LoadArgs:      LoadConfigFromProc(bp.LoadArgs),
+		LoadLocals:    LoadConfigFromProc(bp.LoadLocals),
+		WatchExpr:     bp.WatchExpr,
+		WatchType:     WatchType(bp.WatchType),
+		WatchFunction: bp.WatchFunction,
+		Addrs:         []uint64{bp.Addr},
+		UserData:      bp.UserData,
 	}
 
 	breaklet := bp.UserBreaklet()
@@ -137,6 +138,18 @@ func ConvertThread(th proc.Thread) *Thread {
 	}
 }
 
+// ConvertTarget converts a proc.Target into an api.Target.
+func ConvertTarget(tgt *proc.Target) *Target {
+	r := &Target{
+		Pid:  tgt.Pid(),
+		Path: tgt.BinInfo().Images[0].Path,
+	}
+	if th := tgt.CurrentThread(); th != nil {
+		r.CurrentThread = ConvertThread(th)
+	}
+	return r
+}
+
 // ConvertThreads converts a slice of proc.Thread into a slice of api.Thread.
 func ConvertThreads(threads []proc.Thread) []*Thread {
 	r := make([]*Thread, len(threads))
@@ -175,6 +188,12 @@ func convertFloatValue(v *proc.Variable, sz int) string {
 
 // ConvertVar converts from proc.Variable to api.Variable.
 func ConvertVar(v *proc.Variable) *Variable {
+	r := convertVar(v)
+	labelReferences(r)
+	return r
+}
+
+func convertVar(v *proc.Variable) *Variable {
 	r := Variable{
 		Addr:     v.Addr,
 		OnlyAddr: v.OnlyAddr,
@@ -245,13 +264,51 @@ func ConvertVar(v *proc.Variable) *Variable {
 		r.Children = make([]Variable, len(v.Children))
 
 		for i := range v.Children {
-			r.Children[i] = *ConvertVar(&v.Children[i])
+			r.Children[i] = *convertVar(&v.Children[i])
 		}
 	}
 
 	return &r
 }
 
+// labelReferences finds the pointers in v that point to a struct or array
+// already expanded elsewhere in v, because of a cycle or because the value
+// is shared, and replaces their contents with a back reference to it.
+func labelReferences(v *Variable) {
+	type key struct {
+		addr uint64
+		typ  string
+	}
+	expanded := map[key]*Variable{}
+	nextID := 1
+	var visit func(v *Variable)
+	visit = func(v *Variable) {
+		if v.Kind == reflect.Ptr && len(v.Children) == 1 {
+			c := &v.Children[0]
+			if tgt := expanded[key{c.Addr, c.Type}]; tgt != nil {
+				if tgt.RefID == 0 {
+					tgt.RefID = nextID
+					nextID++
+				}
+				v.BackRef = tgt.RefID
+				c.Children = nil
+				c.OnlyAddr = true
+				return
+			}
+		}
+		if (v.Kind == reflect.Struct || v.Kind == reflect.Array) && v.Addr != 0 && !v.OnlyAddr && len(v.Children) > 0 {
+			k := key{v.Addr, v.Type}
+			if expanded[k] == nil {
+				expanded[k] = v
+			}
+		}
+		for i := range v.Children {
+			visit(&v.Children[i])
+		}
+	}
+	visit(v)
+}
+
 func VariableValueAsString(v *proc.Variable) string {
 	if v.Value == nil {
 		return ""
@@ -284,6 +341,33 @@ func ConvertVars(pv []*proc.Variable) []Variable {
 	return vars
 }
 
+// ConvertTracepointResults converts the events of eBPF tracepoints to
+// TracepointResult values.
+func ConvertTracepointResults(bi *proc.BinaryInfo, traces []*proc.UProbeTraceResult) []TracepointResult {
+	if traces == nil {
+		return nil
+	}
+	results := make([]TracepointResult, len(traces))
+	for i, trace := range traces {
+		f, l, fn := bi.PCToLine(uint64(trace.FnAddr))
+
+		results[i].FunctionName = fn.Name
+		results[i].Line = l
+		results[i].File = f
+		results[i].GoroutineID = trace.GoroutineID
+		results[i].IsRet = trace.IsRet
+		results[i].Time = trace.Time
+
+		for _, p := range trace.InputParams {
+			results[i].InputParams = append(results[i].InputParams, *ConvertVar(p))
+		}
+		for _, p := range trace.ReturnParams {
+			results[i].ReturnParams = append(results[i].ReturnParams, *ConvertVar(p))
+		}
+	}
+	return results
+}
+
 // ConvertFunction converts from gosym.Func to
 // api.Function.
 func ConvertFunction(fn *proc.Function) *Function {
@@ -304,7 +388,9 @@ func ConvertFunction(fn *proc.Function) *Function {
 	}
 }
 
-// ConvertGoroutine converts from proc.G to api.Goroutine.
+// ConvertGoroutine converts from proc.G to api.Goroutine. WaitDuration is
+// not set, it must be computed by the caller while holding the target lock,
+// see proc.WaitDuration.
 func ConvertGoroutine(tgt *proc.Target, g *proc.G) *Goroutine {
 	th := g.Thread
 	tid := 0
@@ -419,7 +505,11 @@ func ConvertRegisters(in *op.DwarfRegisters, dwarfRegisterToString func(int, *op
 		if !floatingPoint && fp {
 			continue
 		}
-		out = append(out, Register{name, repr, i})
+		r := Register{Name: name, Value: repr, DwarfNumber: i}
+		if len(reg.Bytes) > 8 {
+			r.Bytes = reg.Bytes
+		}
+		out = append(out, r)
 	}
 	// Sort the registers in a canonical order we prefer, this is mostly
 	// because the DWARF register numbering for AMD64 is weird.
@@ -464,3 +554,169 @@ func ConvertDumpState(dumpState *proc.DumpState) *DumpState {
 	}
 	return r
 }
+
+// ConvertMemoryMapEntry converts from proc.MemoryMapEntry to api.MemoryMapEntry.
+func ConvertMemoryMapEntry(mme *proc.MemoryMapEntry) MemoryMapEntry {
+	return MemoryMapEntry{
+		Addr:     mme.Addr,
+		Size:     mme.Size,
+		Read:     mme.Read,
+		Write:    mme.Write,
+		Exec:     mme.Exec,
+		Filename: mme.Filename,
+		Offset:   mme.Offset,
+	}
+}
+
+// ConvertReference converts from proc.Reference to api.ObjectReference.
+func ConvertReference(ref *proc.Reference) ObjectReference {
+	r := ObjectReference{
+		Addr:        ref.Addr,
+		GoroutineID: ref.GoroutineID,
+		Frame:       ref.Frame,
+		Path:        ref.Path,
+		ObjectAddr:  ref.ObjectAddr,
+		ObjectSize:  ref.ObjectSize,
+	}
+	switch ref.Kind {
+	case proc.StackReference:
+		r.Kind = StackReference
+	case proc.GlobalReference:
+		r.Kind = GlobalReference
+	case proc.HeapReference:
+		r.Kind = HeapReference
+	}
+	if ref.Fn != nil {
+		r.Function = ConvertFunction(ref.Fn)
+	}
+	if ref.Type != nil {
+		r.Type = PrettyTypeName(ref.Type)
+	}
+	return r
+}
+
+// ConvertBlockedGoroutine converts from proc.BlockedGoroutine to api.DeadlockedGoroutine.
+func ConvertBlockedGoroutine(tgt *proc.Target, bg *proc.BlockedGoroutine) DeadlockedGoroutine {
+	r := DeadlockedGoroutine{
+		Goroutine: ConvertGoroutine(tgt, bg.G),
+		Objects:   make([]WaitObject, len(bg.Objects)),
+		Wakers:    bg.Wakers,
+		Global:    bg.Global,
+	}
+	for i, obj := range bg.Objects {
+		r.Objects[i].Addr = obj.Addr
+		switch obj.Kind {
+		case proc.WaitChan:
+			r.Objects[i].Kind = "chan"
+		case proc.WaitSema:
+			r.Objects[i].Kind = "sema"
+		}
+	}
+	return r
+}
+
+// ConvertRuntimeMetrics converts from proc.RuntimeMetrics to api.RuntimeMetrics.
+func ConvertRuntimeMetrics(m *proc.RuntimeMetrics) *RuntimeMetrics {
+	r := &RuntimeMetrics{
+		HeapLive:     m.HeapLive,
+		HeapMarked:   m.HeapMarked,
+		HeapGoal:     m.HeapGoal,
+		NumGC:        m.NumGC,
+		NumForcedGC:  m.NumForcedGC,
+		GCPauseTotal: m.GCPauseTotal,
+		Goroutines:   m.Goroutines,
+		GOMAXPROCS:   m.GOMAXPROCS,
+		SchedLatency: make([]HistogramBucket, len(m.SchedLatency)),
+		Unavailable:  m.Unavailable,
+	}
+	for i, b := range m.SchedLatency {
+		r.SchedLatency[i] = HistogramBucket(b)
+	}
+	return r
+}
+
+// ConvertGCState converts from proc.GCState to api.GCState.
+func ConvertGCState(s *proc.GCState) *GCState {
+	return &GCState{
+		Phase:       s.Phase.String(),
+		InProgress:  s.Phase != proc.GCOff,
+		HeapLive:    s.HeapLive,
+		HeapMarked:  s.HeapMarked,
+		HeapGoal:    s.HeapGoal,
+		HeapTrigger: s.HeapTrigger,
+		NumGC:       s.NumGC,
+		LastGC:      s.LastGC,
+		LastPause:   s.LastPause,
+		Unavailable: s.Unavailable,
+	}
+}
+
+// ConvertChanInfo converts from proc.ChanInfo to api.ChanInfo, typ is the
+// type of the channel.
+func ConvertChanInfo(tgt *proc.Target, typ string, ci *proc.ChanInfo) *ChanInfo {
+	r := &ChanInfo{
+		Addr:      ci.Addr,
+		Type:      typ,
+		Len:       ci.Len,
+		Cap:       ci.Cap,
+		Closed:    ci.Closed,
+		Buffer:    ConvertVars(ci.Buffer),
+		Senders:   ConvertGoroutines(tgt, ci.Senders),
+		Receivers: ConvertGoroutines(tgt, ci.Receivers),
+	}
+	return r
+}
+
+// ConvertMutexInfo converts from proc.MutexInfo to api.MutexInfo, typ is the
+// type of the mutex.
+func ConvertMutexInfo(tgt *proc.Target, typ string, mi *proc.MutexInfo) *MutexInfo {
+	return &MutexInfo{
+		Addr:        mi.Addr,
+		Type:        typ,
+		Locked:      mi.Locked,
+		Starving:    mi.Starving,
+		Readers:     mi.Readers,
+		Waiters:     ConvertGoroutines(tgt, mi.Waiters),
+		ReadWaiters: ConvertGoroutines(tgt, mi.ReadWaiters),
+		Holders:     ConvertGoroutines(tgt, mi.Holders),
+	}
+}
+
+// ConvertContextChain converts from []*proc.ContextLayer to []api.ContextLayer.
+func ConvertContextChain(layers []*proc.ContextLayer) []ContextLayer {
+	r := make([]ContextLayer, len(layers))
+	convertVar := func(v *proc.Variable) *Variable {
+		if v == nil {
+			return nil
+		}
+		return ConvertVar(v)
+	}
+	for i, l := range layers {
+		r[i] = ContextLayer{
+			Kind:     l.Kind.String(),
+			Type:     l.Type,
+			Addr:     l.Addr,
+			Deadline: convertVar(l.Deadline),
+			Err:      convertVar(l.Err),
+			Cause:    convertVar(l.Cause),
+			Key:      convertVar(l.Key),
+			Value:    convertVar(l.Value),
+		}
+	}
+	return r
+}
+
+// ConvertPanics converts from []*proc.Panic to []api.Panic.
+func ConvertPanics(panics []*proc.Panic) []Panic {
+	r := make([]Panic, len(panics))
+	for i, p := range panics {
+		r[i] = Panic{
+			Value:      *ConvertVar(p.Value),
+			Recovered:  p.Recovered,
+			Repanicked: p.Repanicked,
+			Frame:      p.Frame,
+			Location:   ConvertLocation(p.Location),
+		}
+	}
+	return r
+}
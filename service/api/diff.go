@@ -0,0 +1,127 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// DiffVariables compares two evaluations, old and new, of the expression
+// expr and returns the list of values that changed between them. The Path
+// of each change is an expression that evaluates to the changed value.
+//
+// Values are compared structurally: a change inside a struct, array,
+// slice, map or pointed value is reported at the innermost value that
+// changed. Children that were not loaded in both evaluations are not
+// compared.
+func DiffVariables(expr string, old, new *Variable) []VariableChange {
+	return diffVariables(expr, old, new, nil)
+}
+
+func diffVariables(path string, old, new *Variable, changes []VariableChange) []VariableChange {
+	changed := func() []VariableChange {
+		return append(changes, VariableChange{Path: path, Old: old.SinglelineString(), New: new.SinglelineString()})
+	}
+
+	if old.Unreadable != new.Unreadable || old.Kind != new.Kind || old.Type != new.Type {
+		return changed()
+	}
+	if old.Unreadable != "" {
+		return changes
+	}
+
+	switch old.Kind {
+	case reflect.Ptr:
+		if len(old.Children) == 0 || len(new.Children) == 0 {
+			if old.Value != new.Value {
+				return changed()
+			}
+			return changes
+		}
+		if old.Children[0].Addr != new.Children[0].Addr {
+			return changed()
+		}
+		if old.Children[0].OnlyAddr || new.Children[0].OnlyAddr {
+			return changes
+		}
+		return diffVariables("(*"+path+")", &old.Children[0], &new.Children[0], changes)
+
+	case reflect.Interface:
+		if len(old.Children) == 0 || len(new.Children) == 0 {
+			if len(old.Children) != len(new.Children) {
+				return changed()
+			}
+			return changes
+		}
+		oldc, newc := &old.Children[0], &new.Children[0]
+		if oldc.Type != newc.Type || oldc.Kind != newc.Kind {
+			return changed()
+		}
+		return diffVariables(fmt.Sprintf("%s.(%s)", path, oldc.Type), oldc, newc, changes)
+
+	case reflect.Struct:
+		if len(old.Children) != len(new.Children) {
+			return changed()
+		}
+		for i := range old.Children {
+			changes = diffVariables(path+"."+old.Children[i].Name, &old.Children[i], &new.Children[i], changes)
+		}
+		return changes
+
+	case reflect.Array, reflect.Slice:
+		if old.Len != new.Len {
+			return changed()
+		}
+		n := len(old.Children)
+		if len(new.Children) < n {
+			n = len(new.Children)
+		}
+		for i := 0; i < n; i++ {
+			changes = diffVariables(fmt.Sprintf("%s[%d]", path, i), &old.Children[i], &new.Children[i], changes)
+		}
+		return changes
+
+	case reflect.Map:
+		if old.Len != new.Len {
+			return changed()
+		}
+		oldm := make(map[string]*Variable, len(old.Children)/2)
+		for i := 0; i+1 < len(old.Children); i += 2 {
+			oldm[old.Children[i].SinglelineString()] = &old.Children[i+1]
+		}
+		for i := 0; i+1 < len(new.Children); i += 2 {
+			key := new.Children[i].SinglelineString()
+			oldv := oldm[key]
+			if oldv == nil {
+				// either a new key or a key that wasn't loaded in the old
+				// evaluation, they can't be told apart
+				continue
+			}
+			changes = diffVariables(fmt.Sprintf("%s[%s]", path, key), oldv, &new.Children[i+1], changes)
+		}
+		return changes
+
+	case reflect.Chan:
+		if old.Base != new.Base || old.Len != new.Len || old.Cap != new.Cap {
+			return changed()
+		}
+		return changes
+
+	case reflect.String:
+		if old.Len != new.Len || old.Value != new.Value {
+			return changed()
+		}
+		return changes
+
+	default:
+		if old.Value != new.Value || len(old.Children) != len(new.Children) {
+			return changed()
+		}
+		for i := range old.Children {
+			// complex numbers
+			if old.Children[i].Value != new.Children[i].Value {
+				return changed()
+			}
+		}
+		return changes
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffVariables(t *testing.T) {
+	intv := func(name, val string) Variable {
+		return Variable{Name: name, Kind: reflect.Int, Type: "int", Value: val}
+	}
+	structv := func(a, b string) *Variable {
+		return &Variable{Kind: reflect.Struct, Type: "main.S", Children: []Variable{intv("A", a), intv("B", b)}}
+	}
+	slicev := func(vals ...string) *Variable {
+		v := &Variable{Kind: reflect.Slice, Type: "[]int", Len: int64(len(vals))}
+		for _, val := range vals {
+			v.Children = append(v.Children, intv("", val))
+		}
+		return v
+	}
+	ptrv := func(addr uint64, pointee *Variable) *Variable {
+		pointee.Addr = addr
+		return &Variable{Kind: reflect.Ptr, Type: "*main.S", Children: []Variable{*pointee}}
+	}
+
+	testCases := []struct {
+		name     string
+		old, new *Variable
+		paths    []string
+	}{
+		{"unchanged", structv("1", "2"), structv("1", "2"), nil},
+		{"struct field", structv("1", "2"), structv("1", "3"), []string{"x.B"}},
+		{"struct fields", structv("1", "2"), structv("4", "3"), []string{"x.A", "x.B"}},
+		{"slice element", slicev("1", "2", "3"), slicev("1", "5", "3"), []string{"x[1]"}},
+		{"slice length", slicev("1", "2"), slicev("1", "2", "3"), []string{"x"}},
+		{"pointee", ptrv(0x10, structv("1", "2")), ptrv(0x10, structv("1", "3")), []string{"(*x).B"}},
+		{"pointer", ptrv(0x10, structv("1", "2")), ptrv(0x20, structv("1", "2")), []string{"x"}},
+		{"unreadable", structv("1", "2"), &Variable{Kind: reflect.Struct, Type: "main.S", Unreadable: "error"}, []string{"x"}},
+	}
+
+	for _, tc := range testCases {
+		changes := DiffVariables("x", tc.old, tc.new)
+		var paths []string
+		for _, change := range changes {
+			paths = append(paths, change.Path)
+		}
+		if !reflect.DeepEqual(paths, tc.paths) {
+			t.Errorf("%s: got %v expected %v", tc.name, paths, tc.paths)
+		}
+	}
+
+	changes := DiffVariables("x", structv("1", "2"), structv("1", "3"))
+	if changes[0].Old != "2" || changes[0].New != "3" {
+		t.Errorf("wrong old and new values: %#v", changes[0])
+	}
+}
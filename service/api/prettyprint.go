@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
@@ -54,6 +55,10 @@ func (v *Variable) writeTo(buf io.Writer, top, newlines, includeType bool, inden
 		return
 	}
 
+	if v.RefID != 0 {
+		fmt.Fprintf(buf, "#%d ", v.RefID)
+	}
+
 	switch v.Kind {
 	case reflect.Slice:
 		v.writeSliceTo(buf, newlines, includeType, indent, fmtstr)
@@ -68,6 +73,14 @@ func (v *Variable) writeTo(buf io.Writer, top, newlines, includeType bool, inden
 			} else {
 				fmt.Fprintf(buf, "(%s)(%#x)", v.Type, v.Children[0].Addr)
 			}
+			if v.BackRef != 0 {
+				fmt.Fprintf(buf, " ↩ see #%d", v.BackRef)
+			}
+		} else if c := v.Children[0]; c.RefID != 0 {
+			// print the label before the '*'
+			fmt.Fprintf(buf, "#%d *", c.RefID)
+			c.RefID = 0
+			c.writeTo(buf, false, newlines, includeType, indent, fmtstr)
 		} else {
 			fmt.Fprint(buf, "*")
 			v.Children[0].writeTo(buf, false, newlines, includeType, indent, fmtstr)
@@ -120,6 +133,9 @@ func (v *Variable) writeTo(buf io.Writer, top, newlines, includeType bool, inden
 				fmt.Fprint(buf, "nil")
 			} else if data.Children[0].OnlyAddr {
 				fmt.Fprintf(buf, "0x%x", v.Children[0].Addr)
+				if data.BackRef != 0 {
+					fmt.Fprintf(buf, " ↩ see #%d", data.BackRef)
+				}
 			} else {
 				v.Children[0].writeTo(buf, false, newlines, !includeType, indent, fmtstr)
 			}
@@ -509,6 +525,18 @@ func digits(n int) int {
 }
 
 func PrintStack(formatPath func(string) string, out io.Writer, stack []Stackframe, ind string, offsets bool, include func(Stackframe) bool) {
+	printStack(formatPath, out, stack, ind, offsets, include, nil, nil)
+}
+
+// PrintStackCollapsed prints a stack trace like PrintStack, but runs of
+// consecutive frames for which collapse returns true are replaced by a
+// single line, returned by marker when called with the indices of the
+// first and last frame of the run.
+func PrintStackCollapsed(formatPath func(string) string, out io.Writer, stack []Stackframe, ind string, offsets bool, collapse func(Stackframe) bool, marker func(first, last int) string) {
+	printStack(formatPath, out, stack, ind, offsets, func(Stackframe) bool { return true }, collapse, marker)
+}
+
+func printStack(formatPath func(string) string, out io.Writer, stack []Stackframe, ind string, offsets bool, include, collapse func(Stackframe) bool, marker func(first, last int) string) {
 	if len(stack) == 0 {
 		return
 	}
@@ -525,15 +553,30 @@ func PrintStack(formatPath func(string) string, out io.Writer, stack []Stackfram
 	fmtstr := "%s%" + strconv.Itoa(d) + "d  0x%016x in %s\n"
 	s := ind + strings.Repeat(" ", d+2+len(ind))
 
-	for i := range stack {
+	for i := 0; i < len(stack); i++ {
 		if !include(stack[i]) {
 			continue
 		}
+		if collapse != nil && stack[i].Err == "" && collapse(stack[i]) {
+			first := i
+			for i+1 < len(stack) && stack[i+1].Err == "" && collapse(stack[i+1]) {
+				i++
+			}
+			fmt.Fprintf(out, "%s%s%s\n", ind, strings.Repeat(" ", d+2), marker(first, i))
+			if extranl {
+				fmt.Fprintln(out)
+			}
+			continue
+		}
 		if stack[i].Err != "" {
 			fmt.Fprintf(out, "%serror: %s\n", s, stack[i].Err)
 			continue
 		}
-		fmt.Fprintf(out, fmtstr, ind, i, stack[i].PC, stack[i].Function.Name())
+		fnname := stack[i].Function.Name()
+		if len(stack[i].Markers) > 0 {
+			fnname += " [" + strings.Join(stack[i].Markers, ", ") + "]"
+		}
+		fmt.Fprintf(out, fmtstr, ind, i, stack[i].PC, fnname)
 		fmt.Fprintf(out, "%sat %s:%d\n", s, formatPath(stack[i].File), stack[i].Line)
 
 		if offsets {
@@ -550,6 +593,9 @@ func PrintStack(formatPath func(string) string, out io.Writer, stack []Stackfram
 			fmt.Fprintf(out, "%s%#016x in %s\n", deferHeader, d.DeferredLoc.PC, d.DeferredLoc.Function.Name())
 			fmt.Fprintf(out, "%sat %s:%d\n", s2, formatPath(d.DeferredLoc.File), d.DeferredLoc.Line)
 			fmt.Fprintf(out, "%sdeferred by %s at %s:%d\n", s2, d.DeferLoc.Function.Name(), formatPath(d.DeferLoc.File), d.DeferLoc.Line)
+			for k := range d.CapturedVars {
+				fmt.Fprintf(out, "%s    %s = %s\n", s2, d.CapturedVars[k].Name, d.CapturedVars[k].SinglelineString())
+			}
 		}
 
 		for j := range stack[i].Arguments {
@@ -568,3 +614,40 @@ func PrintStack(formatPath func(string) string, out io.Writer, stack []Stackfram
 		fmt.Fprintf(out, "%s"+stacktraceTruncatedMessage+"\n", ind)
 	}
 }
+
+// BranchAnnotation returns a short description of the destination of the
+// i-th instruction of dv, if it is a call or a branch.
+// Destinations inside the same function or inside the range spanned by dv
+// are described with an arrow indicating the direction of the jump followed
+// by the destination address and line, other destinations are described
+// with the name of the function they belong to, unless it already appears
+// in the text of the instruction.
+func (dv AsmInstructions) BranchAnnotation(i int) string {
+	inst := &dv[i]
+	if inst.DestLoc == nil {
+		return ""
+	}
+	dest := inst.DestLoc
+	sameFn := dest.Function != nil && inst.Loc.Function != nil && dest.Function.Name() == inst.Loc.Function.Name()
+	inRange := dest.PC >= dv[0].Loc.PC && dest.PC <= dv[len(dv)-1].Loc.PC
+	if sameFn || inRange {
+		arrow := "↓"
+		if dest.PC <= inst.Loc.PC {
+			arrow = "↑"
+		}
+		if dest.File == "" {
+			return fmt.Sprintf("%s %#x", arrow, dest.PC)
+		}
+		return fmt.Sprintf("%s %#x %s:%d", arrow, dest.PC, filepath.Base(dest.File), dest.Line)
+	}
+	if dest.Function == nil {
+		return ""
+	}
+	if strings.Contains(inst.Text, dest.Function.Name()) {
+		return ""
+	}
+	if off := dest.PC - dest.Function.Value; off != 0 {
+		return fmt.Sprintf("→ %s+%#x", dest.Function.Name(), off)
+	}
+	return fmt.Sprintf("→ %s", dest.Function.Name())
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"fmt"
 	"math"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -34,6 +35,42 @@ func TestPrettyExamineMemory(t *testing.T) {
 	}
 }
 
+func TestPrettyBackReferences(t *testing.T) {
+	intVar := func(name, value string) Variable {
+		return Variable{Name: name, Kind: reflect.Int, Type: "int", Value: value, Addr: 0x10}
+	}
+	ptrTo := func(name string, v Variable) Variable {
+		return Variable{Name: name, Kind: reflect.Ptr, Type: "*" + v.Type, Addr: 0x20, Children: []Variable{v}}
+	}
+
+	// cycle: n.next.next == &n
+	n := &Variable{Kind: reflect.Struct, Type: "main.node", Addr: 0x100, Len: 2, Children: []Variable{
+		intVar("val", "1"),
+		ptrTo("next", Variable{Kind: reflect.Struct, Type: "main.node", Addr: 0x200, Len: 2, Children: []Variable{
+			intVar("val", "2"),
+			ptrTo("next", Variable{Kind: reflect.Struct, Type: "main.node", Addr: 0x100, Len: 2, Children: []Variable{
+				intVar("val", "1"),
+				ptrTo("next", Variable{Kind: reflect.Struct, Type: "main.node", Addr: 0x200, OnlyAddr: true}),
+			}}),
+		}}),
+	}}
+	labelReferences(n)
+	if n.RefID != 1 || n.Children[1].Children[0].Children[1].BackRef != 1 {
+		t.Errorf("wrong references %d %d", n.RefID, n.Children[1].Children[0].Children[1].BackRef)
+	}
+	if got, want := n.SinglelineString(), "#1 main.node {val: 1, next: *main.node {val: 2, next: (*main.node)(0x100) ↩ see #1}}"; got != want {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+
+	// shared: p.a == p.b
+	leaf := Variable{Kind: reflect.Struct, Type: "main.leaf", Addr: 0x300, Len: 1, Children: []Variable{intVar("x", "3")}}
+	p := &Variable{Kind: reflect.Struct, Type: "main.pair", Addr: 0x400, Len: 2, Children: []Variable{ptrTo("a", leaf), ptrTo("b", leaf)}}
+	labelReferences(p)
+	if got, want := p.SinglelineString(), "main.pair {a: #1 *main.leaf {x: 3}, b: (*main.leaf)(0x300) ↩ see #1}"; got != want {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+}
+
 func Test_byteArrayToUInt64(t *testing.T) {
 	tests := []struct {
 		name string
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"go/constant"
 	"reflect"
 	"strconv"
+	"time"
 	"unicode"
 
 	"github.com/go-delve/delve/pkg/proc"
@@ -47,6 +49,11 @@ type DebuggerState struct {
 	ExitStatus int  `json:"exitStatus"`
 	// When contains a description of the current position in a recording
 	When string
+	// Event is the current event number of a recording.
+	Event uint64 `json:",omitempty"`
+	// Ticks is the number of ticks executed by the current thread since the
+	// start of Event.
+	Ticks uint64 `json:",omitempty"`
 	// Filled by RPCClient.Continue, indicates an error
 	Err error `json:"-"`
 }
@@ -64,6 +71,11 @@ type TracepointResult struct {
 
 	GoroutineID int `json:"goroutineID"`
 
+	// IsRet is true if the tracepoint was hit when the function returned.
+	IsRet bool `json:"isRet,omitempty"`
+	// Time is the time at which the tracepoint was hit.
+	Time time.Time `json:"time"`
+
 	InputParams  []Variable `json:"inputParams,omitempty"`
 	ReturnParams []Variable `json:"returnParams,omitempty"`
 }
@@ -112,6 +124,11 @@ type Breakpoint struct {
 	// WatchExpr is the expression used to create this watchpoint
 	WatchExpr string
 	WatchType WatchType
+	// WatchFunction is the function of the scope where WatchExpr was
+	// evaluated. When the target is restarted the watchpoint is recreated
+	// the next time WatchFunction is called, until then WatchPending is set.
+	WatchFunction string `json:"watchFunction,omitempty"`
+	WatchPending  bool   `json:"watchPending,omitempty"`
 
 	VerboseDescr []string `json:"VerboseDescr,omitempty"`
 
@@ -121,6 +138,10 @@ type Breakpoint struct {
 	TotalHitCount uint64 `json:"totalHitCount"`
 	// Disabled flag, signifying the state of the breakpoint
 	Disabled bool `json:"disabled"`
+	// TargetPid, if not zero, restricts the breakpoint to the target process
+	// with this pid. Otherwise the breakpoint is also set in the child
+	// processes attached when following exec.
+	TargetPid int `json:"targetPid,omitempty"`
 
 	UserData interface{} `json:"-"`
 }
@@ -176,6 +197,10 @@ type Thread struct {
 	ReturnValues []Variable
 	// CallReturn is true if ReturnValues are the return values of an injected call.
 	CallReturn bool
+
+	// Running is true if the thread was left running by the last stop, which
+	// only happens in non-stop mode. Only ID is valid for running threads.
+	Running bool `json:"running,omitempty"`
 }
 
 // Location holds program location information.
@@ -204,6 +229,10 @@ type Stackframe struct {
 
 	Bottom bool `json:"Bottom,omitempty"` // Bottom is true if this is the bottom frame of the stack
 
+	// Markers describe the control flow situation of the frame, for example
+	// "deferred call" or "panicking".
+	Markers []string `json:"markers,omitempty"`
+
 	Err string
 }
 
@@ -213,6 +242,9 @@ type Defer struct {
 	DeferLoc    Location // location of the defer statement
 	SP          uint64   // value of SP when the function was deferred
 	Unreadable  string
+
+	// CapturedVars are the variables captured by the deferred function.
+	CapturedVars []Variable `json:"capturedVars,omitempty"`
 }
 
 // Var will return the variable described by 'name' within
@@ -335,6 +367,26 @@ type Variable struct {
 	LocationExpr string
 	// DeclLine is the line number of this variable's declaration
 	DeclLine int64
+
+	// RefID identifies this struct or array if it is the target of a
+	// BackRef elsewhere in the same variable.
+	RefID int `json:"refID,omitempty"`
+	// BackRef is set for pointers that point to a struct or array already
+	// expanded elsewhere in the same variable, because of a cycle or because
+	// the value is shared. It is the RefID of that value, the pointer is
+	// not expanded again and its child only contains the address.
+	BackRef int `json:"backRef,omitempty"`
+}
+
+// VariableChange describes a value that changed between two evaluations
+// of the same expression, see DiffVariables.
+type VariableChange struct {
+	// Path is an expression that evaluates to the changed value.
+	Path string `json:"path"`
+	// Old and New are the single line representations of the value before
+	// and after the change.
+	Old string `json:"old"`
+	New string `json:"new"`
 }
 
 // LoadConfig describes how to load values from target's memory
@@ -369,7 +421,10 @@ type Goroutine struct {
 	Status     uint64 `json:"status"`
 	WaitSince  int64  `json:"waitSince"`
 	WaitReason int64  `json:"waitReason"`
-	Unreadable string `json:"unreadable"`
+	// WaitDuration is an approximation of how long the goroutine has been
+	// in its current wait state, zero if it is unknown.
+	WaitDuration time.Duration `json:"waitDuration,omitempty"`
+	Unreadable   string        `json:"unreadable"`
 	// Goroutine's pprof labels
 	Labels map[string]string `json:"labels,omitempty"`
 }
@@ -409,6 +464,10 @@ type DebuggerCommand struct {
 	// violate the rules about stack objects you can disable this safety check
 	// by setting UnsafeCall to true.
 	UnsafeCall bool `json:"unsafeCall,omitempty"`
+
+	// TargetPid is used to specify which target process to use with the
+	// SwitchTarget command.
+	TargetPid int `json:"targetPid,omitempty"`
 }
 
 // BreakpointInfo contains informations about the current breakpoint
@@ -418,6 +477,24 @@ type BreakpointInfo struct {
 	Variables  []Variable   `json:"variables,omitempty"`
 	Arguments  []Variable   `json:"arguments,omitempty"`
 	Locals     []Variable   `json:"locals,omitempty"`
+	// Panics are the panics of the goroutine stopped at the unrecovered
+	// panic breakpoint, starting from the most recent one.
+	Panics []Panic `json:"panics,omitempty"`
+}
+
+// Panic is a panic of a goroutine.
+type Panic struct {
+	// Value is the argument of panic.
+	Value Variable `json:"value"`
+	// Recovered is true if the panic was recovered by a deferred call.
+	Recovered bool `json:"recovered,omitempty"`
+	// Repanicked is true if the value was passed to panic again after
+	// recovering it.
+	Repanicked bool `json:"repanicked,omitempty"`
+	// Frame is the index in the stacktrace of the goroutine of the frame
+	// that caused the panic, -1 if unknown. Location is its location.
+	Frame    int      `json:"frame"`
+	Location Location `json:"location"`
 }
 
 // EvalScope is the scope a command should
@@ -455,6 +532,9 @@ const (
 	SwitchThread = "switchThread"
 	// SwitchGoroutine switches the debugger's current thread context to the thread running the specified goroutine
 	SwitchGoroutine = "switchGoroutine"
+	// SwitchTarget switches the debugger's current target process, when
+	// following exec.
+	SwitchTarget = "switchTarget"
 	// Halt suspends the process.
 	// The effect of Halt while the target process is stopped, or in the
 	// process of stopping, is operating system and timing dependent. It will
@@ -481,7 +561,7 @@ const (
 type AsmInstruction struct {
 	// Loc is the location of this instruction
 	Loc Location
-	// Destination of CALL instructions
+	// Destination of CALL and branch instructions
 	DestLoc *Location
 	// Text is the formatted representation of the instruction
 	Text string
@@ -511,6 +591,54 @@ type GetVersionOut struct {
 	MaxSupportedVersionOfGo string
 }
 
+// MaxAPIVersion is the most recent version of the JSON-RPC API.
+const MaxAPIVersion = 2
+
+// Capabilities describes the optional features that are supported with the
+// current target and backend, so that clients do not need to find out by
+// trying them.
+type Capabilities struct {
+	// APIVersions are the versions of the JSON-RPC API served.
+	APIVersions []int
+	// Backend is the backend in use.
+	Backend string
+	// Watchpoints is true if watchpoints can be created.
+	Watchpoints bool
+	// FunctionCalls is true if functions of the target can be called.
+	FunctionCalls bool
+	// ReverseExecution is true if the target can be executed backwards
+	// (rewind, reverse next, step and stepout).
+	ReverseExecution bool
+	// Checkpoints is true if checkpoints can be created.
+	Checkpoints bool
+	// Restart is true if the target can be restarted.
+	Restart bool
+	// CoreDump is true if a core dump of the target can be created.
+	CoreDump bool
+	// StreamingCalls is true if the streaming variants of the calls
+	// returning large results and event subscriptions are supported.
+	StreamingCalls bool
+	// FollowExec is true if the debugger can follow the children of the
+	// target process.
+	FollowExec bool
+	// Watch is true if the target is rebuilt and restarted when its source
+	// files change, every reload is sent to subscribers as an
+	// EventReloaded event.
+	Watch bool
+	// PTY is true if the target has a pseudo-terminal, its output can be
+	// read with StreamTTY and its input written with WriteTTY.
+	PTY bool
+}
+
+// CapabilitiesIn is the input for Capabilities.
+type CapabilitiesIn struct {
+}
+
+// CapabilitiesOut is the output for Capabilities.
+type CapabilitiesOut struct {
+	Capabilities
+}
+
 // SetAPIVersionIn is the input for SetAPIVersion.
 type SetAPIVersionIn struct {
 	APIVersion int
@@ -520,11 +648,31 @@ type SetAPIVersionIn struct {
 type SetAPIVersionOut struct {
 }
 
+// StreamChunk is a partial result of a streaming call. The chunks of a
+// streaming call are sent as responses with the same id as the call, before
+// its final response.
+type StreamChunk struct {
+	Chunk interface{}
+}
+
+// CancelStreamIn is the input for CancelStream.
+type CancelStreamIn struct {
+	// Seq is the id of the streaming call to cancel.
+	Seq uint64
+}
+
+// CancelStreamOut is the output for CancelStream.
+type CancelStreamOut struct {
+}
+
 // Register holds information on a CPU register.
 type Register struct {
 	Name        string
 	Value       string
 	DwarfNumber int
+	// Bytes is the raw value of registers larger than 64 bits, like
+	// vector registers.
+	Bytes []byte `json:",omitempty"`
 }
 
 // Registers is a list of CPU registers.
@@ -545,6 +693,41 @@ func (regs Registers) String() string {
 	return buf.String()
 }
 
+// LanesString is like String but the values of vector registers (the
+// registers whose size is a multiple of 128 bits) are printed as lanes of
+// type typ, see proc.RegisterLanes for the supported
+// types.
+func (regs Registers) LanesString(typ string) (string, error) {
+	r := make(Registers, len(regs))
+	copy(r, regs)
+	for i := range r {
+		if len(r[i].Bytes) == 0 || len(r[i].Bytes)%16 != 0 {
+			// not a vector register
+			continue
+		}
+		lanes, _, err := proc.RegisterLanes(r[i].Bytes, typ)
+		if err != nil {
+			return "", err
+		}
+		var buf bytes.Buffer
+		buf.WriteString("{")
+		for _, lane := range lanes {
+			switch lane.Kind() {
+			case constant.Float:
+				f, _ := constant.Float64Val(lane)
+				fmt.Fprintf(&buf, " %g", f)
+			case constant.String:
+				fmt.Fprintf(&buf, " %s", constant.StringVal(lane))
+			default:
+				fmt.Fprintf(&buf, " %s", lane)
+			}
+		}
+		buf.WriteString(" }")
+		r[i].Value = buf.String()
+	}
+	return r.String(), nil
+}
+
 // DiscardedBreakpoint is a breakpoint that is not
 // reinstated during a restart.
 type DiscardedBreakpoint struct {
@@ -552,6 +735,65 @@ type DiscardedBreakpoint struct {
 	Reason     string
 }
 
+// EventKind is the kind of an Event.
+type EventKind string
+
+const (
+	// EventResumed is sent when the target is resumed.
+	EventResumed EventKind = "resumed"
+	// EventStopped is sent when the target stops, State is the state of the
+	// debugger.
+	EventStopped EventKind = "stopped"
+	// EventExited is sent when the target process exits, State is the state
+	// of the debugger.
+	EventExited EventKind = "exited"
+	// EventBreakpointCreated is sent when Breakpoint is created.
+	EventBreakpointCreated EventKind = "breakpointCreated"
+	// EventBreakpointChanged is sent when Breakpoint is amended.
+	EventBreakpointChanged EventKind = "breakpointChanged"
+	// EventBreakpointCleared is sent when Breakpoint is cleared.
+	EventBreakpointCleared EventKind = "breakpointCleared"
+	// EventTargetAttached is sent when the debugger attaches to a new
+	// target process, Pid is its pid.
+	EventTargetAttached EventKind = "targetAttached"
+	// EventReloaded is sent when the source files changed and the target
+	// was rebuilt and restarted, see Capabilities.Watch. Pid is the pid of
+	// the new process or, if the reload failed, Err is set (if the build
+	// failed the old process is still running).
+	EventReloaded EventKind = "reloaded"
+	// EventSessionResumed is sent when a client resumes its session after
+	// reconnecting, after the events that were queued while it was
+	// disconnected. State is the current state of the debugger (or Err is
+	// set) and Dropped is the number of events that did not fit the queue.
+	EventSessionResumed EventKind = "sessionResumed"
+)
+
+// Event is a notification of a change of the state of the debugger, sent
+// to all subscribed clients.
+type Event struct {
+	Kind       EventKind
+	State      *DebuggerState `json:",omitempty"`
+	Breakpoint *Breakpoint    `json:",omitempty"`
+	Pid        int            `json:",omitempty"`
+	Err        string         `json:",omitempty"`
+
+	// DiscardedBreakpoints are the breakpoints that could not be set in the
+	// reloaded target.
+	DiscardedBreakpoints []DiscardedBreakpoint `json:",omitempty"`
+
+	Dropped int `json:",omitempty"`
+}
+
+// Target is a process being debugged. There is more than one target when
+// the debugger follows the children of the process, see
+// RPCServer.FollowExec.
+type Target struct {
+	Pid int
+	// Path is the path of the executable of the process.
+	Path          string
+	CurrentThread *Thread `json:",omitempty"`
+}
+
 // Checkpoint is a point in the program that
 // can be returned to in certain execution modes.
 type Checkpoint struct {
@@ -560,6 +802,19 @@ type Checkpoint struct {
 	Where string
 }
 
+// Bookmark is a named position in a recording.
+type Bookmark struct {
+	Name string
+	// Event is the recording event number of the bookmarked position.
+	Event uint64
+	// Ticks is the number of ticks executed by the current thread since the
+	// start of Event, together with Event it identifies a precise position
+	// in the recording.
+	Ticks uint64
+	// Checkpoint is the ID of the checkpoint backing the bookmark.
+	Checkpoint int
+}
+
 // Image represents a loaded shared object (go plugin or shared library)
 type Image struct {
 	Path    string
@@ -631,6 +886,7 @@ const (
 	GoroutineLabel                     // the goroutine's label
 	GoroutineRunning                   // the goroutine is running
 	GoroutineUser                      // the goroutine is a user goroutine
+	GoroutineLeak                      // the goroutine's GoStatementLoc, wait reason and blocked duration, only for grouping
 )
 
 // GoroutineGroup represents a group of goroutines in the return value of
@@ -643,8 +899,213 @@ type GoroutineGroup struct {
 }
 
 type GoroutineGroupingOptions struct {
-	GroupBy         GoroutineField
+	GroupBy GoroutineField
+	// GroupByKey is the label key for GoroutineLabel and the minimum
+	// blocked duration of suspected leaks for GoroutineLeak (parsed by
+	// time.ParseDuration).
 	GroupByKey      string
 	MaxGroupMembers int
 	MaxGroups       int
 }
+
+// MemoryMapEntry describes a memory mapping in the target process.
+type MemoryMapEntry struct {
+	Addr uint64
+	Size uint64
+
+	Read, Write, Exec bool
+
+	Filename string
+	Offset   uint64
+}
+
+// MemorySearchMatch is an occurrence of a pattern in the memory of the
+// target process.
+type MemorySearchMatch struct {
+	Addr    uint64
+	Mapping MemoryMapEntry // memory mapping containing Addr
+}
+
+// ReferenceKind describes where an ObjectReference is stored.
+type ReferenceKind string
+
+const (
+	StackReference  ReferenceKind = "stack"  // local variable of a goroutine
+	GlobalReference ReferenceKind = "global" // package variable
+	HeapReference   ReferenceKind = "heap"   // heap allocated object
+)
+
+// WaitObject is an object a goroutine is blocked on.
+type WaitObject struct {
+	Kind string // "chan" or "sema"
+	Addr uint64 // address of the channel or of the semaphore
+}
+
+// DeadlockedGoroutine is a goroutine that is part of a deadlock.
+type DeadlockedGoroutine struct {
+	Goroutine *Goroutine
+	// Objects are the objects the goroutine is waiting on, more than one
+	// for select statements.
+	Objects []WaitObject
+	// Wakers are the IDs of the other goroutines that reference the
+	// objects the goroutine is waiting on, they are all part of the same
+	// deadlock.
+	Wakers []int
+	// Global is true if one of the objects is referenced by a package
+	// variable.
+	Global bool
+}
+
+// ObjectReference is a pointer to an object in the target process.
+type ObjectReference struct {
+	Kind ReferenceKind
+	// Addr is the address of the pointer, zero if the pointer is stored in
+	// a register.
+	Addr uint64
+
+	// GoroutineID, Frame and Function describe the stack frame containing
+	// the pointer, for StackReference.
+	GoroutineID int
+	Frame       int
+	Function    *Function
+
+	// Path is the name of the variable followed by the path of the field
+	// containing the pointer (for example "x.f[2].g"), Type is the type of
+	// that field. Only set for StackReference and GlobalReference.
+	Path string
+	Type string
+
+	// ObjectAddr and ObjectSize describe the heap object containing the
+	// pointer, for HeapReference. The type of heap objects is not known.
+	ObjectAddr uint64
+	ObjectSize uint64
+}
+
+// SymbolKind is the kind of a Symbol.
+type SymbolKind string
+
+const (
+	FunctionSymbol SymbolKind = "func"
+	TypeSymbol     SymbolKind = "type"
+	SourceSymbol   SymbolKind = "source"
+)
+
+// Symbol is a function, type or source file whose name matches a query.
+type Symbol struct {
+	Kind  SymbolKind
+	Name  string
+	Score int // how well Name matches the query, higher is better
+}
+
+// RuntimeMetrics are statistics kept by the runtime of the target.
+type RuntimeMetrics struct {
+	// HeapLive is the number of bytes of heap considered live by the GC:
+	// the bytes marked by the last cycle plus the bytes allocated since.
+	HeapLive uint64
+	// HeapMarked is the number of bytes marked by the last GC cycle.
+	HeapMarked uint64
+	// HeapGoal is the value of HeapLive at which the next GC cycle should
+	// end, derived from GOGC without considering the memory limit.
+	HeapGoal uint64
+
+	NumGC        uint64        // number of completed GC cycles
+	NumForcedGC  uint64        // number of GC cycles forced by calls to runtime.GC
+	GCPauseTotal time.Duration // total time spent in stop-the-world pauses
+
+	Goroutines int // number of goroutines, excluding system goroutines
+	GOMAXPROCS int
+
+	// SchedLatency is the distribution of the time goroutines spent
+	// runnable before running, only buckets with a non-zero count are
+	// included.
+	SchedLatency []HistogramBucket
+
+	// Unavailable lists the names of the fields of RuntimeMetrics that
+	// could not be read from the runtime of the target, they are left to
+	// zero.
+	Unavailable []string
+}
+
+// HistogramBucket is a bucket of a histogram of durations.
+type HistogramBucket struct {
+	Min, Max time.Duration // range of the bucket, Max is excluded
+	Count    uint64
+}
+
+// ChanInfo is the state of a channel.
+type ChanInfo struct {
+	Addr     uint64 // address of the runtime.hchan structure
+	Type     string
+	Len, Cap int64
+	Closed   bool
+	// Buffer contains the elements in the buffer of the channel, in the
+	// order they will be received.
+	Buffer []Variable
+	// Senders and Receivers are the goroutines blocked sending to and
+	// receiving from the channel.
+	Senders, Receivers []*Goroutine
+}
+
+// MutexInfo is the state of a sync.Mutex or sync.RWMutex.
+type MutexInfo struct {
+	Addr uint64
+	Type string
+	// Locked is true if the mutex is locked, for a sync.RWMutex it is true if
+	// a writer holds the lock or is waiting for the readers to release it.
+	Locked   bool
+	Starving bool // the mutex is in starvation mode
+	// Readers is the number of goroutines holding a read lock on a
+	// sync.RWMutex.
+	Readers int64
+	// Waiters are the goroutines waiting to lock the mutex (for writing, if
+	// it is a sync.RWMutex), ReadWaiters are the goroutines waiting to lock
+	// a sync.RWMutex for reading.
+	Waiters, ReadWaiters []*Goroutine
+	// Holders are the goroutines that possibly hold the lock, found by
+	// searching their stacks for pointers to the mutex.
+	Holders []*Goroutine
+}
+
+// ContextLayer is a layer of a context.Context.
+type ContextLayer struct {
+	// Kind is the function that created the layer: "Background", "TODO",
+	// "WithCancel", "WithDeadline", "WithValue", "WithoutCancel", "AfterFunc"
+	// or "unknown" for types not defined by package context.
+	Kind string
+	Type string // concrete type of the layer
+	Addr uint64
+
+	// Deadline is the deadline of WithDeadline layers.
+	Deadline *Variable `json:",omitempty"`
+	// Err and Cause are only set if the layer has been canceled.
+	Err   *Variable `json:",omitempty"`
+	Cause *Variable `json:",omitempty"`
+	// Key and Value are the key/value pair stored by WithValue layers.
+	Key   *Variable `json:",omitempty"`
+	Value *Variable `json:",omitempty"`
+}
+
+// GCState is the state of the garbage collector of the target.
+type GCState struct {
+	// Phase is the phase of the garbage collector: "off", "mark" or "mark
+	// termination".
+	Phase string
+	// InProgress is true if a GC cycle is in progress.
+	InProgress bool
+
+	// HeapLive, HeapMarked and HeapGoal are as in RuntimeMetrics.
+	HeapLive   uint64
+	HeapMarked uint64
+	HeapGoal   uint64
+	// HeapTrigger is the estimated value of HeapLive at which the next GC
+	// cycle will start.
+	HeapTrigger uint64
+
+	NumGC     uint64        // number of completed GC cycles
+	LastGC    time.Time     // end of the last GC cycle
+	LastPause time.Duration // duration of the stop-the-world pauses of the last GC cycle
+
+	// Unavailable lists the names of the fields of GCState that could not
+	// be read from the runtime of the target, they are left to zero.
+	Unavailable []string
+}
@@ -25,6 +25,10 @@ type Client interface {
 	Restart(rebuild bool) ([]api.DiscardedBreakpoint, error)
 	// RestartFrom restarts program from the specified position.
 	RestartFrom(rerecord bool, pos string, resetArgs bool, newArgs []string, newRedirects [3]string, rebuild bool) ([]api.DiscardedBreakpoint, error)
+	// RestartFromWithEnv is like RestartFrom but the variables in newEnv
+	// (KEY=VALUE) are also added to the environment of the program and
+	// newWd, if not empty, replaces its working directory.
+	RestartFromWithEnv(rerecord bool, pos string, resetArgs bool, newArgs []string, newRedirects [3]string, rebuild bool, newEnv []string, newWd string) ([]api.DiscardedBreakpoint, error)
 
 	// GetState returns the current debugger state.
 	GetState() (*api.DebuggerState, error)
@@ -51,6 +55,8 @@ type Client interface {
 	ReverseStepOut() (*api.DebuggerState, error)
 	// Call resumes process execution while making a function call.
 	Call(goroutineID int, expr string, unsafe bool) (*api.DebuggerState, error)
+	// Jump moves the program counter of the current goroutine to another statement of the current function.
+	Jump(loc string, substitutePathRules [][2]string) (*api.DebuggerState, error)
 
 	// StepInstruction will step a single cpu instruction.
 	StepInstruction() (*api.DebuggerState, error)
@@ -60,6 +66,8 @@ type Client interface {
 	SwitchThread(threadID int) (*api.DebuggerState, error)
 	// SwitchGoroutine switches the current goroutine (and the current thread as well)
 	SwitchGoroutine(goroutineID int) (*api.DebuggerState, error)
+	// SwitchTarget switches the current target process, see FollowExec.
+	SwitchTarget(pid int) (*api.DebuggerState, error)
 	// Halt suspends the process.
 	Halt() (*api.DebuggerState, error)
 
@@ -96,16 +104,33 @@ type Client interface {
 	ListPackageVariables(filter string, cfg api.LoadConfig) ([]api.Variable, error)
 	// EvalVariable returns a variable in the context of the current thread.
 	EvalVariable(scope api.EvalScope, symbol string, cfg api.LoadConfig) (*api.Variable, error)
+	// EvalMany evaluates all expressions in exprs in the same scope, with a
+	// single request. Expressions that can not be evaluated are returned as
+	// unreadable variables.
+	EvalMany(scope api.EvalScope, exprs []string, cfg api.LoadConfig) ([]*api.Variable, error)
+	// EvalDisplay evaluates an expression like EvalVariable and returns the
+	// values that changed since it was evaluated at the previous stop.
+	EvalDisplay(scope api.EvalScope, expr string, cfg api.LoadConfig) (*api.Variable, []api.VariableChange, error)
+	// ListVariableChildren evaluates expr, an array, slice or map, and
+	// returns it with up to count of its elements, starting from index start.
+	ListVariableChildren(scope api.EvalScope, expr string, start, count int, cfg api.LoadConfig) (*api.Variable, error)
 
 	// SetVariable sets the value of a variable
 	SetVariable(scope api.EvalScope, symbol, value string) error
 
 	// ListSources lists all source files in the process matching filter.
 	ListSources(filter string) ([]string, error)
+	// ListPackagesBuildInfo lists the packages used by the program along with
+	// the directory where each package was compiled.
+	ListPackagesBuildInfo(includeFiles bool) ([]api.PackageBuildInfo, error)
 	// ListFunctions lists all functions in the process matching filter.
 	ListFunctions(filter string) ([]string, error)
 	// ListTypes lists all types in the process matching filter.
 	ListTypes(filter string) ([]string, error)
+	// FindSymbols returns the functions, types and source files matching
+	// the fuzzy query, best matches first. If limit is greater than zero at
+	// most limit symbols are returned.
+	FindSymbols(query string, limit int) ([]api.Symbol, error)
 	// ListLocalVariables lists all local variables in scope.
 	ListLocalVariables(scope api.EvalScope, cfg api.LoadConfig) ([]api.Variable, error)
 	// ListFunctionArgs lists all arguments to the current function.
@@ -114,11 +139,18 @@ type Client interface {
 	ListThreadRegisters(threadID int, includeFp bool) (api.Registers, error)
 	// ListScopeRegisters lists registers and their values, for the given scope.
 	ListScopeRegisters(scope api.EvalScope, includeFp bool) (api.Registers, error)
+	// SetRegister changes the value of a register of the given thread, or
+	// of the current thread if threadID is 0. Single lanes of vector
+	// registers can be changed using the syntax REG.type[idx].
+	SetRegister(threadID int, reg, value string) (warning string, err error)
 
 	// ListGoroutines lists all goroutines.
 	ListGoroutines(start, count int) ([]*api.Goroutine, int, error)
 	// ListGoroutinesWithFilter lists goroutines matching the filters
 	ListGoroutinesWithFilter(start, count int, filters []api.ListGoroutinesFilter, group *api.GoroutineGroupingOptions) ([]*api.Goroutine, []api.GoroutineGroup, int, bool, error)
+	// ListGoroutinesWithStacktraces is like ListGoroutinesWithFilter but also
+	// returns the stacktraces of the returned goroutines, up to depth frames.
+	ListGoroutinesWithStacktraces(start, count int, filters []api.ListGoroutinesFilter, group *api.GoroutineGroupingOptions, depth int) ([]*api.Goroutine, [][]api.Stackframe, []api.GoroutineGroup, int, bool, error)
 
 	// Stacktrace returns stacktrace
 	Stacktrace(goroutineID int, depth int, opts api.StacktraceOptions, cfg *api.LoadConfig) ([]api.Stackframe, error)
@@ -147,6 +179,8 @@ type Client interface {
 	DisassembleRange(scope api.EvalScope, startPC, endPC uint64, flavour api.AssemblyFlavour) (api.AsmInstructions, error)
 	// DisassemblePC disassemble code of the function containing PC
 	DisassemblePC(scope api.EvalScope, pc uint64, flavour api.AssemblyFlavour) (api.AsmInstructions, error)
+	// DisassembleRaw disassemble memory between startPC and endPC, even if it does not belong to a function
+	DisassembleRaw(scope api.EvalScope, startPC, endPC uint64, flavour api.AssemblyFlavour) (api.AsmInstructions, error)
 
 	// Recorded returns true if the target is a recording.
 	Recorded() bool
@@ -159,6 +193,15 @@ type Client interface {
 	// ClearCheckpoint removes a checkpoint
 	ClearCheckpoint(id int) error
 
+	// AddBookmark records the current position of the recording under name.
+	AddBookmark(name string) (api.Bookmark, error)
+	// ListBookmarks gets all bookmarks, in recording order.
+	ListBookmarks() ([]api.Bookmark, error)
+	// GotoBookmark restarts the recording from the position of the named bookmark.
+	GotoBookmark(name string) error
+	// ClearBookmark removes a bookmark.
+	ClearBookmark(name string) error
+
 	// SetReturnValuesLoadConfig sets the load configuration for return values.
 	SetReturnValuesLoadConfig(*api.LoadConfig)
 
@@ -173,16 +216,82 @@ type Client interface {
 	// This function will return an error if it reads less than `length` bytes.
 	ExamineMemory(address uint64, length int) ([]byte, bool, error)
 
+	// SearchMemory returns the addresses where pattern occurs in the memory
+	// of the target process. If end is not zero only the interval [start, end)
+	// is searched. If max is greater than zero at most max matches are returned.
+	// The parts of the memory that could not be read are also returned.
+	SearchMemory(pattern []byte, start, end uint64, max int) ([]api.MemorySearchMatch, []api.MemoryMapEntry, error)
+
+	// FindReferences returns the address and size of the object referenced
+	// by expr and the pointers to it found in goroutine stacks, package
+	// variables and the heap. If max is greater than zero at most max
+	// references are returned.
+	FindReferences(scope api.EvalScope, expr string, max int) (addr, size uint64, refs []api.ObjectReference, err error)
+
+	// ListHeapObjects returns the live heap objects of the specified type.
+	// If max is greater than zero at most max objects are returned.
+	ListHeapObjects(typename string, max int, cfg api.LoadConfig) ([]api.Variable, error)
+
+	// FindDeadlocks returns the groups of goroutines that are waiting on
+	// each other.
+	FindDeadlocks() ([][]api.DeadlockedGoroutine, error)
+	// ChanInfo returns the state of the channel expr evaluates to, the
+	// elements of its buffer are loaded using cfg.
+	ChanInfo(scope api.EvalScope, expr string, cfg api.LoadConfig) (*api.ChanInfo, error)
+	// MutexInfo returns the state of the sync.Mutex or sync.RWMutex expr
+	// evaluates to.
+	MutexInfo(scope api.EvalScope, expr string) (*api.MutexInfo, error)
+	// ContextChain returns the layers of the context.Context expr
+	// evaluates to, keys, values and errors are loaded using cfg.
+	ContextChain(scope api.EvalScope, expr string, cfg api.LoadConfig) ([]api.ContextLayer, error)
+	// GetRuntimeMetrics returns statistics kept by the runtime of the target.
+	GetRuntimeMetrics() (*api.RuntimeMetrics, error)
+	// GetGCState returns the state of the garbage collector of the target.
+	GetGCState() (*api.GCState, error)
+
+	// ListTargets returns the processes being debugged.
+	ListTargets() ([]api.Target, error)
+	// FollowExec enables or disables following the children of the
+	// processes being debugged that execute a program matching regex (any
+	// program if it is empty) and not matching exclude (if it isn't empty).
+	FollowExec(enabled bool, regex, exclude string) error
+	// FollowExecEnabled returns true if following children is enabled and
+	// the regular expressions used to select them.
+	FollowExecEnabled() (enabled bool, regex, exclude string, err error)
+
+	// WriteTTY writes data to the pseudo-terminal of the target.
+	WriteTTY(data []byte) error
+	// ResizeTTY changes the size of the pseudo-terminal of the target.
+	ResizeTTY(rows, cols int) error
+
 	// StopRecording stops a recording if one is in progress.
 	StopRecording() error
 
 	// CoreDumpStart starts creating a core dump to the specified file
 	CoreDumpStart(dest string) (api.DumpState, error)
+	// CoreDumpStartNative is like CoreDumpStart but writes the core dump in
+	// the format native to the operating system of the target (a minidump
+	// on windows, a Mach-O core file on macOS).
+	CoreDumpStartNative(dest string) (api.DumpState, error)
 	// CoreDumpWait waits for the core dump to finish, or for the specified amount of milliseconds
 	CoreDumpWait(msec int) api.DumpState
 	// CoreDumpCancel cancels a core dump in progress
 	CoreDumpCancel() error
 
+	// DumpHeap writes the graph of the live heap objects of the target to
+	// the specified file, in the "pprof" or "dot" format. Returns the number
+	// of objects written and the memory they use.
+	DumpHeap(dest, format string) (objects int, bytes uint64, err error)
+
+	// ProfileCPU resumes the target for the specified duration while its
+	// CPU profiler is running and returns the profile, in the format
+	// written by runtime/pprof. Functions of runtime/pprof are called on
+	// goroutine goroutineID, or on the selected goroutine if it is 0.
+	ProfileCPU(goroutineID int, duration time.Duration) ([]byte, error)
+	// ProfileTrace is like ProfileCPU but collects an execution trace, in
+	// the format written by runtime/trace.
+	ProfileTrace(goroutineID int, duration time.Duration) ([]byte, error)
+
 	// Disconnect closes the connection to the server without sending a Detach request first.
 	// If cont is true a continue command will be sent instead.
 	Disconnect(cont bool) error
@@ -34,6 +34,16 @@ type Config struct {
 	// connections come from the same user that started the headless server
 	CheckLocalConnUser bool
 
+	// AllowedOrigins are the origins of the web pages allowed to connect to
+	// the server with WebSocket. "*" allows any origin.
+	AllowedOrigins []string
+
 	// DisconnectChan will be closed by the server when the client disconnects
 	DisconnectChan chan<- struct{}
+
+	// HaltOnDisconnect halts the target when the connection of a client
+	// with a resumable session (see rpc2.SubscribeIn) is lost while the
+	// target is running, so that it is still stopped when the client
+	// reconnects. Only used with AcceptMulti.
+	HaltOnDisconnect bool
 }
@@ -136,7 +136,7 @@ func (s *Session) evaluateConfig(_, _ int, expr string) (string, error) {
 					Areas: []dap.InvalidatedAreas{"variables"},
 				},
 			})
-		case "goroutineFilters", "hideSystemGoroutines":
+		case "goroutineFilters", "hideSystemGoroutines", "hideGoroutinesWithoutUserFrames", "maxGoroutines":
 			// Thread related data has become invalidated.
 			s.send(&dap.InvalidatedEvent{
 				Event: *newEvent("invalidated"),
@@ -144,6 +144,14 @@ func (s *Session) evaluateConfig(_, _ int, expr string) (string, error) {
 					Areas: []dap.InvalidatedAreas{"threads"},
 				},
 			})
+		case "hideRuntimeFrames":
+			// Stack frames have become invalidated.
+			s.send(&dap.InvalidatedEvent{
+				Event: *newEvent("invalidated"),
+				Body: dap.InvalidatedEventBody{
+					Areas: []dap.InvalidatedAreas{"stacks"},
+				},
+			})
 		}
 		res += "\nUpdated"
 	}
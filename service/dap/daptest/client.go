@@ -107,6 +107,8 @@ func (c *Client) ExpectInitializeResponseAndCapabilities(t *testing.T) *dap.Init
 		SupportsDelayedStackTraceLoading: true,
 		SupportsExceptionInfoRequest:     true,
 		SupportsSetVariable:              true,
+		SupportsSetExpression:            true,
+		SupportsRestartRequest:           true,
 		SupportsFunctionBreakpoints:      true,
 		SupportsInstructionBreakpoints:   true,
 		SupportsEvaluateForHovers:        true,
@@ -114,6 +116,9 @@ func (c *Client) ExpectInitializeResponseAndCapabilities(t *testing.T) *dap.Init
 		SupportsSteppingGranularity:      true,
 		SupportsLogPoints:                true,
 		SupportsDisassembleRequest:       true,
+		SupportsReadMemoryRequest:        true,
+		SupportsWriteMemoryRequest:       true,
+		SupportsModulesRequest:           true,
 	}
 	if !reflect.DeepEqual(initResp.Body, wantCapabilities) {
 		t.Errorf("capabilities in initializeResponse: got %+v, want %v", pretty(initResp.Body), pretty(wantCapabilities))
@@ -440,6 +445,14 @@ func (c *Client) RestartRequest() {
 	c.send(&dap.RestartRequest{Request: *c.newRequest("restart")})
 }
 
+// RestartRequestWithArgs sends a 'restart' request with the latest version
+// of the launch or attach configuration.
+func (c *Client) RestartRequestWithArgs(arguments map[string]interface{}) {
+	request := &dap.RestartRequest{Request: *c.newRequest("restart")}
+	request.Arguments.Arguments = arguments
+	c.send(request)
+}
+
 // SetFunctionBreakpointsRequest sends a 'setFunctionBreakpoints' request.
 func (c *Client) SetFunctionBreakpointsRequest(breakpoints []dap.FunctionBreakpoint) {
 	c.send(&dap.SetFunctionBreakpointsRequest{
@@ -490,8 +503,12 @@ func (c *Client) GotoRequest() {
 }
 
 // SetExpressionRequest sends a 'setExpression' request.
-func (c *Client) SetExpressionRequest() {
-	c.send(&dap.SetExpressionRequest{Request: *c.newRequest("setExpression")})
+func (c *Client) SetExpressionRequest(expression, value string, frameID int) {
+	request := &dap.SetExpressionRequest{Request: *c.newRequest("setExpression")}
+	request.Arguments.Expression = expression
+	request.Arguments.Value = value
+	request.Arguments.FrameId = frameID
+	c.send(request)
 }
 
 // SourceRequest sends a 'source' request.
@@ -551,8 +568,29 @@ func (c *Client) SetDataBreakpointsRequest() {
 }
 
 // ReadMemoryRequest sends a 'readMemory' request.
-func (c *Client) ReadMemoryRequest() {
-	c.send(&dap.ReadMemoryRequest{Request: *c.newRequest("readMemory")})
+func (c *Client) ReadMemoryRequest(memoryReference string, offset, count int) {
+	c.send(&dap.ReadMemoryRequest{
+		Request: *c.newRequest("readMemory"),
+		Arguments: dap.ReadMemoryArguments{
+			MemoryReference: memoryReference,
+			Offset:          offset,
+			Count:           count,
+		},
+	})
+}
+
+// WriteMemoryRequest sends a 'writeMemory' request with data encoded in
+// base64.
+func (c *Client) WriteMemoryRequest(memoryReference string, offset int, allowPartial bool, data string) {
+	c.send(&dap.WriteMemoryRequest{
+		Request: *c.newRequest("writeMemory"),
+		Arguments: dap.WriteMemoryArguments{
+			MemoryReference: memoryReference,
+			Offset:          offset,
+			AllowPartial:    allowPartial,
+			Data:            data,
+		},
+	})
 }
 
 // DisassembleRequest sends a 'disassemble' request.
@@ -569,6 +607,20 @@ func (c *Client) DisassembleRequest(memoryReference string, instructionOffset, i
 	})
 }
 
+// DisassembleOffsetRequest sends a 'disassemble' request with a byte offset
+// applied to memoryReference.
+func (c *Client) DisassembleOffsetRequest(memoryReference string, offset, instructionOffset, instructionCount int) {
+	c.send(&dap.DisassembleRequest{
+		Request: *c.newRequest("disassemble"),
+		Arguments: dap.DisassembleArguments{
+			MemoryReference:   memoryReference,
+			Offset:            offset,
+			InstructionOffset: instructionOffset,
+			InstructionCount:  instructionCount,
+		},
+	})
+}
+
 // CancelRequest sends a 'cancel' request.
 func (c *Client) CancelRequest() {
 	c.send(&dap.CancelRequest{Request: *c.newRequest("cancel")})
@@ -584,6 +636,15 @@ func (c *Client) ModulesRequest() {
 	c.send(&dap.ModulesRequest{Request: *c.newRequest("modules")})
 }
 
+// ModulesRequestWithArgs sends a 'modules' request for count modules
+// starting at start.
+func (c *Client) ModulesRequestWithArgs(start, count int) {
+	request := &dap.ModulesRequest{Request: *c.newRequest("modules")}
+	request.Arguments.StartModule = start
+	request.Arguments.ModuleCount = count
+	c.send(request)
+}
+
 // UnknownRequest triggers dap.DecodeProtocolMessageFieldError.
 func (c *Client) UnknownRequest() {
 	request := c.newRequest("unknown")
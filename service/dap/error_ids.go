@@ -28,6 +28,10 @@ const (
 	UnableToDisassemble        = 2013
 	UnableToListRegisters      = 2014
 	UnableToRunDlvCommand      = 2015
+	UnableToReadMemory         = 2016
+	UnableToWriteMemory        = 2017
+	UnableToSetExpression      = 2018
+	UnableToRestart            = 2019
 
 	// Add more codes as we support more requests
 
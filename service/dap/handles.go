@@ -27,6 +27,9 @@ type fullyQualifiedVariable struct {
 	// startIndex is the index of the first child for an array or slice.
 	// This variable represents a chunk of the array, slice or map.
 	startIndex int
+	// registersOf is the frame whose registers are listed by this variable,
+	// only set for the Registers scope.
+	registersOf *stackFrame
 }
 
 func newHandlesMap() *handlesMap {
@@ -11,6 +11,7 @@ package dap
 import (
 	"bufio"
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -30,6 +31,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-delve/delve/pkg/gobuild"
@@ -127,6 +129,16 @@ type Session struct {
 	exceptionErr error
 	// clientCapabilities tracks special settings for handling debug session requests.
 	clientCapabilities dapClientCapabilites
+	// launchConfig and attachConfig are the configurations of the launch or
+	// attach request that started the session, used to restart it.
+	launchConfig *LaunchConfig
+	attachConfig *AttachConfig
+	// progressCount is the number of progress reports started, used to
+	// generate their ids. It must be accessed atomically.
+	progressCount int64
+	// modules tracks the modules reported to the client, by id. It is nil
+	// until the first modules request, which enables module events.
+	modules map[int]dap.Module
 
 	// mu synchronizes access to objects set on start-up (from run goroutine)
 	// and stopped on teardown (from main goroutine)
@@ -218,6 +230,16 @@ type launchAttachArgs struct {
 	// HideSystemGoroutines indicates if system goroutines should be removed from threads
 	// responses.
 	HideSystemGoroutines bool `cfgName:"hideSystemGoroutines"`
+	// HideGoroutinesWithoutUserFrames indicates if goroutines that are only
+	// executing runtime code should be removed from threads responses.
+	HideGoroutinesWithoutUserFrames bool `cfgName:"hideGoroutinesWithoutUserFrames"`
+	// HideRuntimeFrames indicates if the source of runtime frames should be
+	// marked as deemphasized in stackTrace responses, so that clients
+	// collapse them.
+	HideRuntimeFrames bool `cfgName:"hideRuntimeFrames"`
+	// MaxGoroutines is the maximum number of goroutines returned by threads
+	// requests, if zero maxGoroutines is used.
+	MaxGoroutines int `cfgName:"maxGoroutines"`
 	// substitutePathClientToServer indicates rules for converting file paths between client and debugger.
 	// These must be directory paths.
 	substitutePathClientToServer [][2]string `cfgName:"substitutePath"`
@@ -230,14 +252,17 @@ type launchAttachArgs struct {
 // TODO(polinasok): clean up this and its reference (Server.args)
 // in favor of default*Config variables defined in types.go.
 var defaultArgs = launchAttachArgs{
-	stopOnEntry:                  false,
-	StackTraceDepth:              50,
-	ShowGlobalVariables:          false,
-	HideSystemGoroutines:         false,
-	ShowRegisters:                false,
-	GoroutineFilters:             "",
-	substitutePathClientToServer: [][2]string{},
-	substitutePathServerToClient: [][2]string{},
+	stopOnEntry:                     false,
+	StackTraceDepth:                 50,
+	ShowGlobalVariables:             false,
+	HideSystemGoroutines:            false,
+	HideGoroutinesWithoutUserFrames: false,
+	HideRuntimeFrames:               false,
+	MaxGoroutines:                   0,
+	ShowRegisters:                   false,
+	GoroutineFilters:                "",
+	substitutePathClientToServer:    [][2]string{},
+	substitutePathServerToClient:    [][2]string{},
 }
 
 // dapClientCapabilites captures arguments from intitialize request that
@@ -279,8 +304,16 @@ var (
 	// Max number of goroutines that we will return.
 	// This is a var for testing
 	maxGoroutines = 1 << 10
+	// Max number of goroutines that we will scan looking for goroutines
+	// that satisfy the goroutine filters of a threads request.
+	// This is a var for testing
+	maxScannedGoroutines = 1 << 14
 )
 
+// moreGoroutinesThreadID is the id of the synthetic thread that stands for
+// the goroutines that were left out of a threads response.
+const moreGoroutinesThreadID = -2
+
 // NewServer creates a new DAP Server. It takes an opened Listener
 // via config and assumes its ownership. config.DisconnectChan has to be set;
 // it will be closed by the server when the client fails to connect,
@@ -348,6 +381,9 @@ func (s *Session) setLaunchAttachArgs(args LaunchAttachCommonConfig) error {
 	s.args.ShowGlobalVariables = args.ShowGlobalVariables
 	s.args.ShowRegisters = args.ShowRegisters
 	s.args.HideSystemGoroutines = args.HideSystemGoroutines
+	s.args.HideGoroutinesWithoutUserFrames = args.HideGoroutinesWithoutUserFrames
+	s.args.HideRuntimeFrames = args.HideRuntimeFrames
+	s.args.MaxGoroutines = args.MaxGoroutines
 	s.args.GoroutineFilters = args.GoroutineFilters
 	if paths := args.SubstitutePath; len(paths) > 0 {
 		clientToServer := make([][2]string, 0, len(paths))
@@ -605,7 +641,12 @@ func (s *Session) handleRequest(request dap.Message) {
 		/*TODO*/ s.onTerminateRequest(request) // not yet implemented
 		return
 	case *dap.RestartRequest: // Optional (capability ‘supportsRestartRequest’)
-		/*TODO*/ s.onRestartRequest(request) // not yet implemented
+		resumeRequestLoop := make(chan struct{})
+		go func() {
+			defer s.recoverPanic(request)
+			s.onRestartRequest(request, resumeRequestLoop)
+		}()
+		<-resumeRequestLoop
 		return
 	}
 
@@ -751,15 +792,17 @@ func (s *Session) handleRequest(request dap.Message) {
 	case *dap.SourceRequest: // Required
 		/*TODO*/ s.sendUnsupportedErrorResponse(request.Request) // https://github.com/go-delve/delve/issues/2851
 	case *dap.SetExpressionRequest: // Optional (capability ‘supportsSetExpression’)
-		/*TODO*/ s.onSetExpressionRequest(request) // Not yet implemented
+		s.onSetExpressionRequest(request)
 	case *dap.LoadedSourcesRequest: // Optional (capability ‘supportsLoadedSourcesRequest’)
 		/*TODO*/ s.onLoadedSourcesRequest(request) // Not yet implemented
 	case *dap.ReadMemoryRequest: // Optional (capability ‘supportsReadMemoryRequest‘)
-		/*TODO*/ s.onReadMemoryRequest(request) // Not yet implemented
+		s.onReadMemoryRequest(request)
+	case *dap.WriteMemoryRequest: // Optional (capability ‘supportsWriteMemoryRequest‘)
+		s.onWriteMemoryRequest(request)
 	case *dap.CancelRequest: // Optional (capability ‘supportsCancelRequest’)
 		/*TODO*/ s.onCancelRequest(request) // Not yet implemented (does this make sense?)
 	case *dap.ModulesRequest: // Optional (capability ‘supportsModulesRequest’)
-		/*TODO*/ s.sendUnsupportedErrorResponse(request.Request) // Not yet implemented (does this make sense?)
+		s.onModulesRequest(request)
 	//--- Requests that we do not plan to support ---
 	case *dap.RestartFrameRequest: // Optional (capability ’supportsRestartFrame’)
 		s.sendUnsupportedErrorResponse(request.Request)
@@ -801,6 +844,45 @@ func (s *Session) send(message dap.Message) {
 	}
 }
 
+// startProgress sends a progressStart event, if the client supports
+// progress reporting, and returns the id of the progress, which is empty
+// otherwise.
+func (s *Session) startProgress(title, message string) string {
+	if !s.clientCapabilities.supportsProgressReporting {
+		return ""
+	}
+	progressID := fmt.Sprintf("progress%d", atomic.AddInt64(&s.progressCount, 1))
+	s.send(&dap.ProgressStartEvent{
+		Event: *newEvent("progressStart"),
+		Body:  dap.ProgressStartEventBody{ProgressId: progressID, Title: title, Message: message},
+	})
+	return progressID
+}
+
+// updateProgress sends a progressUpdate event for the progress started by
+// startProgress. A percentage of zero is not reported to the client.
+func (s *Session) updateProgress(progressID, message string, percentage int) {
+	if progressID == "" {
+		return
+	}
+	s.send(&dap.ProgressUpdateEvent{
+		Event: *newEvent("progressUpdate"),
+		Body:  dap.ProgressUpdateEventBody{ProgressId: progressID, Message: message, Percentage: percentage},
+	})
+}
+
+// endProgress sends a progressEnd event for the progress started by
+// startProgress.
+func (s *Session) endProgress(progressID, message string) {
+	if progressID == "" {
+		return
+	}
+	s.send(&dap.ProgressEndEvent{
+		Event: *newEvent("progressEnd"),
+		Body:  dap.ProgressEndEventBody{ProgressId: progressID, Message: message},
+	})
+}
+
 func (s *Session) logToConsole(msg string) {
 	s.send(&dap.OutputEvent{
 		Event: *newEvent("output"),
@@ -838,20 +920,22 @@ func (s *Session) onInitializeRequest(request *dap.InitializeRequest) {
 	response.Body.SupportsInstructionBreakpoints = true
 	response.Body.SupportsExceptionInfoRequest = true
 	response.Body.SupportsSetVariable = true
+	response.Body.SupportsSetExpression = true
 	response.Body.SupportsEvaluateForHovers = true
 	response.Body.SupportsClipboardContext = true
 	response.Body.SupportsSteppingGranularity = true
 	response.Body.SupportsLogPoints = true
 	response.Body.SupportsDisassembleRequest = true
+	response.Body.SupportsReadMemoryRequest = true
+	response.Body.SupportsWriteMemoryRequest = true
+	response.Body.SupportsRestartRequest = true
+	response.Body.SupportsModulesRequest = true
 	// To be enabled by CapabilitiesEvent based on launch configuration
 	response.Body.SupportsStepBack = false
 	response.Body.SupportTerminateDebuggee = false
 	// TODO(polina): support these requests in addition to vscode-go feature parity
 	response.Body.SupportsTerminateRequest = false
-	response.Body.SupportsRestartRequest = false
-	response.Body.SupportsSetExpression = false
 	response.Body.SupportsLoadedSourcesRequest = false
-	response.Body.SupportsReadMemoryRequest = false
 	response.Body.SupportsCancelRequest = false
 	s.send(response)
 }
@@ -974,24 +1058,8 @@ func (s *Session) onLaunchRequest(request *dap.LaunchRequest) {
 		}
 		debugbinary = args.Output
 
-		var cmd string
-		var out []byte
-		var err error
-		switch args.Mode {
-		case "debug":
-			cmd, out, err = gobuild.GoBuildCombinedOutput(args.Output, []string{args.Program}, args.BuildFlags)
-		case "test":
-			cmd, out, err = gobuild.GoTestBuildCombinedOutput(args.Output, []string{args.Program}, args.BuildFlags)
-		}
 		args.DlvCwd, _ = filepath.Abs(args.DlvCwd)
-		s.config.log.Debugf("building from %q: [%s]", args.DlvCwd, cmd)
-		if err != nil {
-			s.send(&dap.OutputEvent{
-				Event: *newEvent("output"),
-				Body: dap.OutputEventBody{
-					Output:   fmt.Sprintf("Build Error: %s\n%s (%s)\n", cmd, strings.TrimSpace(string(out)), err.Error()),
-					Category: "stderr",
-				}})
+		if err := s.build(&args); err != nil {
 			// Users are used to checking the Debug Console for build errors.
 			// No need to bother them with a visible pop-up.
 			s.sendErrorResponse(request.Request, FailedToLaunch, "Failed to launch",
@@ -1051,15 +1119,11 @@ func (s *Session) onLaunchRequest(request *dap.LaunchRequest) {
 		return
 	}
 
-	func() {
-		s.mu.Lock()
-		defer s.mu.Unlock() // Make sure to unlock in case of panic that will become internal error
-		s.debugger, err = debugger.New(&s.config.Debugger, s.config.ProcessArgs)
-	}()
-	if err != nil {
+	if err = s.newDebugger(s.config.ProcessArgs); err != nil {
 		s.sendShowUserErrorResponse(request.Request, FailedToLaunch, "Failed to launch", err.Error())
 		return
 	}
+	s.launchConfig = &args
 	// Enable StepBack controls on supported backends
 	if s.config.Debugger.Backend == "rr" {
 		s.send(&dap.CapabilitiesEvent{Event: *newEvent("capabilities"), Body: dap.CapabilitiesEventBody{Capabilities: dap.Capabilities{SupportsStepBack: true}}})
@@ -1072,6 +1136,45 @@ func (s *Session) onLaunchRequest(request *dap.LaunchRequest) {
 	s.send(&dap.LaunchResponse{Response: *newResponse(request.Request)})
 }
 
+// build compiles the program of a launch configuration in 'debug' or
+// 'test' mode. Build errors are reported on the debug console.
+func (s *Session) build(args *LaunchConfig) error {
+	progressID := s.startProgress("Building", args.Program)
+	var cmd string
+	var out []byte
+	var err error
+	switch args.Mode {
+	case "debug":
+		cmd, out, err = gobuild.GoBuildCombinedOutput(args.Output, []string{args.Program}, args.BuildFlags)
+	case "test":
+		cmd, out, err = gobuild.GoTestBuildCombinedOutput(args.Output, []string{args.Program}, args.BuildFlags)
+	}
+	s.config.log.Debugf("building from %q: [%s]", args.DlvCwd, cmd)
+	if err != nil {
+		s.endProgress(progressID, "Build failed")
+		s.send(&dap.OutputEvent{
+			Event: *newEvent("output"),
+			Body: dap.OutputEventBody{
+				Output:   fmt.Sprintf("Build Error: %s\n%s (%s)\n", cmd, strings.TrimSpace(string(out)), err.Error()),
+				Category: "stderr",
+			}})
+		return err
+	}
+	s.endProgress(progressID, "")
+	return nil
+}
+
+// newDebugger creates the debugger for processArgs, or for the process
+// to attach to, reporting the progress of loading the debug information.
+func (s *Session) newDebugger(processArgs []string) (err error) {
+	progressID := s.startProgress("Loading debug information", "")
+	defer s.endProgress(progressID, "")
+	s.mu.Lock()
+	defer s.mu.Unlock() // Make sure to unlock in case of panic that will become internal error
+	s.debugger, err = debugger.New(&s.config.Debugger, processArgs)
+	return err
+}
+
 func (s *Session) getPackageDir(pkg string) string {
 	cmd := exec.Command("go", "list", "-f", "{{.Dir}}", pkg)
 	out, err := cmd.Output()
@@ -1456,9 +1559,9 @@ func (s *Session) onSetFunctionBreakpointsRequest(request *dap.SetFunctionBreakp
 			return nil, err
 		}
 		if len(locs) == 0 {
-			return nil, err
+			return nil, fmt.Errorf("location %q not found", want.Name)
 		}
-		if len(locs) > 0 {
+		if len(locs) > 1 {
 			s.config.log.Debugf("multiple locations found for %s", want.Name)
 		}
 
@@ -1605,23 +1708,10 @@ func fnPackageName(loc *proc.Location) string {
 func (s *Session) onThreadsRequest(request *dap.ThreadsRequest) {
 	var err error
 	var gs []*proc.G
-	var next int
+	var more int
+	var filtered bool
 	if s.debugger != nil {
-		gs, next, err = s.debugger.Goroutines(0, maxGoroutines)
-		if err == nil {
-			// Parse the goroutine arguments.
-			filters, _, _, _, _, _, parseErr := api.ParseGoroutineArgs(s.args.GoroutineFilters)
-			if parseErr != nil {
-				s.logToConsole(parseErr.Error())
-			}
-			if s.args.HideSystemGoroutines {
-				filters = append(filters, api.ListGoroutinesFilter{
-					Kind:    api.GoroutineUser,
-					Negated: false,
-				})
-			}
-			gs = s.debugger.FilterGoroutines(gs, filters)
-		}
+		gs, more, filtered, err = s.loadThreadsGoroutines()
 	}
 
 	var threads []dap.Thread
@@ -1648,7 +1738,7 @@ func (s *Session) onThreadsRequest(request *dap.ThreadsRequest) {
 			s.config.log.Debug("Unable to get debugger state: ", err)
 		}
 
-		if next >= 0 {
+		if more > 0 {
 			s.logToConsole(fmt.Sprintf("Too many goroutines, only loaded %d", len(gs)))
 
 			// Make sure the selected goroutine is included in the list of threads
@@ -1669,6 +1759,7 @@ func (s *Session) onThreadsRequest(request *dap.ThreadsRequest) {
 						// TODO(suzmue): Consider putting the selected goroutine at the top.
 						// To be consistent we may want to do this for all threads requests.
 						gs = append(gs, g)
+						more--
 					}
 				}
 			}
@@ -1693,6 +1784,17 @@ func (s *Session) onThreadsRequest(request *dap.ThreadsRequest) {
 			threads[i].Name = fmt.Sprintf("%s[Go %d] %s%s", selected, g.ID, fnName(&loc), thread)
 			threads[i].Id = g.ID
 		}
+
+		// The goroutines that were not loaded are represented by a single
+		// synthetic thread, without stack frames.
+		if more > 0 {
+			name := fmt.Sprintf("... %d more goroutines", more)
+			if filtered {
+				// Only the goroutines that were loaded have been filtered.
+				name = fmt.Sprintf("... up to %d more goroutines", more)
+			}
+			threads = append(threads, dap.Thread{Id: moreGoroutinesThreadID, Name: name})
+		}
 	}
 
 	response := &dap.ThreadsResponse{
@@ -1702,6 +1804,102 @@ func (s *Session) onThreadsRequest(request *dap.ThreadsRequest) {
 	s.send(response)
 }
 
+// loadThreadsGoroutines loads the goroutines returned by a threads request:
+// at most maxGoroutines (or the limit set by the launch configuration)
+// goroutines that satisfy the goroutine filters of the session.
+// At most maxScannedGoroutines goroutines are examined looking for
+// goroutines that satisfy the filters.
+// Returns also the number of goroutines that were left out and whether
+// the goroutines were filtered, in which case the goroutines left out are
+// not all guaranteed to satisfy the filters.
+func (s *Session) loadThreadsGoroutines() (gs []*proc.G, more int, filtered bool, err error) {
+	limit := maxGoroutines
+	if s.args.MaxGoroutines > 0 {
+		limit = s.args.MaxGoroutines
+	}
+
+	// Parse the goroutine arguments.
+	filters, _, _, _, _, _, parseErr := api.ParseGoroutineArgs(s.args.GoroutineFilters)
+	if parseErr != nil {
+		s.logToConsole(parseErr.Error())
+	}
+	if s.args.HideSystemGoroutines {
+		filters = append(filters, api.ListGoroutinesFilter{
+			Kind:    api.GoroutineUser,
+			Negated: false,
+		})
+	}
+	filtered = len(filters) > 0 || s.args.HideGoroutinesWithoutUserFrames
+
+	// Load goroutines one page at a time until enough of them satisfy the
+	// filters or too many were examined.
+	// Progress is reported if more than one page is needed, as the fraction
+	// of the goroutines that can be examined that were loaded.
+	progressID := ""
+	progressTotal := 0
+	loaded := 0
+	next := 0
+	for next >= 0 && len(gs) < limit && loaded < maxScannedGoroutines {
+		var page []*proc.G
+		page, next, err = s.debugger.Goroutines(next, limit)
+		if err != nil {
+			s.endProgress(progressID, "")
+			return nil, 0, false, err
+		}
+		loaded += len(page)
+		page = s.debugger.FilterGoroutines(page, filters)
+		if s.args.HideGoroutinesWithoutUserFrames {
+			page = s.filterGoroutinesWithUserFrames(page)
+		}
+		gs = append(gs, page...)
+		if next >= 0 && len(gs) < limit && loaded < maxScannedGoroutines {
+			if progressID == "" {
+				progressID = s.startProgress("Loading goroutines", "")
+				if progressID != "" {
+					progressTotal, _ = s.debugger.GoroutinesCount(0)
+					if progressTotal > maxScannedGoroutines {
+						progressTotal = maxScannedGoroutines
+					}
+				}
+			}
+			percentage := 0
+			if progressTotal > 0 {
+				percentage = loaded * 100 / progressTotal
+			}
+			s.updateProgress(progressID, fmt.Sprintf("%d goroutines loaded", loaded), percentage)
+		}
+	}
+	s.endProgress(progressID, "")
+	if len(gs) > limit {
+		more = len(gs) - limit
+		gs = gs[:limit]
+	}
+	if next >= 0 {
+		// Count the remaining goroutines without loading them.
+		rest, err := s.debugger.GoroutinesCount(next)
+		if err != nil {
+			s.config.log.Debug("Unable to count goroutines: ", err)
+		}
+		more += rest
+	}
+	return gs, more, filtered, nil
+}
+
+// filterGoroutinesWithUserFrames returns the goroutines in gs that have at
+// least one frame outside of the runtime.
+func (s *Session) filterGoroutinesWithUserFrames(gs []*proc.G) []*proc.G {
+	s.debugger.LockTarget()
+	defer s.debugger.UnlockTarget()
+	r := gs[:0]
+	for _, g := range gs {
+		loc := g.UserCurrent()
+		if loc.Fn != nil && loc.Fn.PackageName() != "runtime" && !strings.HasPrefix(loc.Fn.Name, "internal/") {
+			r = append(r, g)
+		}
+	}
+	return r
+}
+
 // onAttachRequest handles 'attach' request.
 // This is a mandatory request to support.
 // Attach debug sessions support the following modes:
@@ -1740,13 +1938,7 @@ func (s *Session) onAttachRequest(request *dap.AttachRequest) {
 		}
 		s.config.Debugger.Backend = args.Backend
 		s.config.log.Debugf("attaching to pid %d", args.ProcessID)
-		var err error
-		func() {
-			s.mu.Lock()
-			defer s.mu.Unlock() // Make sure to unlock in case of panic that will become internal error
-			s.debugger, err = debugger.New(&s.config.Debugger, nil)
-		}()
-		if err != nil {
+		if err := s.newDebugger(nil); err != nil {
 			s.sendShowUserErrorResponse(request.Request, FailedToAttach, "Failed to attach", err.Error())
 			return
 		}
@@ -1783,6 +1975,7 @@ func (s *Session) onAttachRequest(request *dap.AttachRequest) {
 		s.sendShowUserErrorResponse(request.Request, FailedToAttach, "Failed to attach", err.Error())
 		return
 	}
+	s.attachConfig = &args
 
 	// Notify the client that the debugger is ready to start accepting
 	// configuration requests for setting breakpoints, etc. The client
@@ -1883,18 +2076,24 @@ func (s *Session) stepUntilStopAndNotify(command string, threadId int, granulari
 	}
 
 	if granularity == "instruction" {
-		switch command {
-		case api.ReverseNext:
-			command = api.ReverseStepInstruction
-		default:
-			// TODO(suzmue): consider differentiating between next, step in, and step out.
-			// For example, next could step over call requests.
-			command = api.StepInstruction
-		}
+		command = instructionStepCommand(command)
 	}
 	s.runUntilStopAndNotify(command, allowNextStateChange)
 }
 
+// instructionStepCommand returns the command that executes a single
+// instruction in the direction of the stepping command.
+// TODO(suzmue): consider differentiating between next, step in, and step out.
+// For example, next could step over call requests.
+func instructionStepCommand(command string) string {
+	switch command {
+	case api.ReverseNext, api.ReverseStep, api.ReverseStepOut, api.ReverseStepInstruction:
+		return api.ReverseStepInstruction
+	default:
+		return api.StepInstruction
+	}
+}
+
 // onPauseRequest handles 'pause' request.
 // This is a mandatory request to support.
 func (s *Session) onPauseRequest(request *dap.PauseRequest) {
@@ -1932,6 +2131,16 @@ func (s *Session) onStackTraceRequest(request *dap.StackTraceRequest) {
 	}
 
 	goroutineID := request.Arguments.ThreadId
+	if goroutineID == moreGoroutinesThreadID {
+		// The synthetic thread standing for the goroutines that were not
+		// loaded has no stack frames.
+		response := &dap.StackTraceResponse{
+			Response: *newResponse(request.Request),
+			Body:     dap.StackTraceResponseBody{StackFrames: []dap.StackFrame{}},
+		}
+		s.send(response)
+		return
+	}
 	start := request.Arguments.StartFrame
 	if start < 0 {
 		start = 0
@@ -1962,7 +2171,13 @@ func (s *Session) onStackTraceRequest(request *dap.StackTraceRequest) {
 		loc := &frame.Call
 		uniqueStackFrameID := s.stackFrameHandles.create(stackFrame{goroutineID, start + i})
 		stackFrame := dap.StackFrame{Id: uniqueStackFrameID, Line: loc.Line, Name: fnName(loc), InstructionPointerReference: fmt.Sprintf("%#x", loc.PC)}
-		if loc.File != "<autogenerated>" {
+		if loc.Fn == nil {
+			// C function without debug info, try the symbol table of its shared object
+			if name, _ := s.debugger.Target().BinInfo().PCToSymbol(loc.PC); name != "" {
+				stackFrame.Name = name
+			}
+		}
+		if loc.File != "<autogenerated>" && loc.File != "?" {
 			clientPath := s.toClientPath(loc.File)
 			stackFrame.Source = dap.Source{Name: filepath.Base(clientPath), Path: clientPath}
 		}
@@ -1971,6 +2186,11 @@ func (s *Session) onStackTraceRequest(request *dap.StackTraceRequest) {
 		packageName := fnPackageName(loc)
 		if !isSystemGoroutine && packageName == "runtime" {
 			stackFrame.PresentationHint = "subtle"
+			if s.args.HideRuntimeFrames && loc.Fn != nil {
+				// Clients collapse runs of frames with a deemphasized source
+				// into a single element that can be expanded.
+				stackFrame.Source.PresentationHint = "deemphasize"
+			}
 		}
 		stackFrames = append(stackFrames, stackFrame)
 	}
@@ -2027,7 +2247,7 @@ func (s *Session) onScopesRequest(request *dap.ScopesRequest) {
 		s.sendErrorResponse(request.Request, UnableToListLocals, "Unable to list locals", err.Error())
 		return
 	}
-	locScope := &fullyQualifiedVariable{&proc.Variable{Name: fmt.Sprintf("Locals%s", suffix), Children: slicePtrVarToSliceVar(append(args, locals...))}, "", true, 0}
+	locScope := &fullyQualifiedVariable{&proc.Variable{Name: fmt.Sprintf("Locals%s", suffix), Children: slicePtrVarToSliceVar(append(args, locals...))}, "", true, 0, nil}
 	scopeLocals := dap.Scope{Name: locScope.Name, VariablesReference: s.variableHandles.create(locScope)}
 	scopes := []dap.Scope{scopeLocals}
 
@@ -2061,7 +2281,7 @@ func (s *Session) onScopesRequest(request *dap.ScopesRequest) {
 		globScope := &fullyQualifiedVariable{&proc.Variable{
 			Name:     fmt.Sprintf("Globals (package %s)", currPkg),
 			Children: slicePtrVarToSliceVar(globals),
-		}, currPkg, true, 0}
+		}, currPkg, true, 0, nil}
 		scopeGlobals := dap.Scope{Name: globScope.Name, VariablesReference: s.variableHandles.create(globScope)}
 		scopes = append(scopes, scopeGlobals)
 	}
@@ -2082,7 +2302,7 @@ func (s *Session) onScopesRequest(request *dap.ScopesRequest) {
 				Kind:  reflect.Kind(proc.VariableConstant),
 			}
 		}
-		regsScope := &fullyQualifiedVariable{&proc.Variable{Name: "Registers", Children: regsVar}, "", true, 0}
+		regsScope := &fullyQualifiedVariable{&proc.Variable{Name: "Registers", Children: regsVar}, "", true, 0, &stackFrame{goid, frame}}
 		scopeRegisters := dap.Scope{Name: regsScope.Name, VariablesReference: s.variableHandles.create(regsScope)}
 		scopes = append(scopes, scopeRegisters)
 	}
@@ -2159,7 +2379,7 @@ func (s *Session) maybeLoadResliced(v *fullyQualifiedVariable, start, count int)
 	if err != nil {
 		return nil, err
 	}
-	return &fullyQualifiedVariable{newV, v.fullyQualifiedNameOrExpr, false, start}, nil
+	return &fullyQualifiedVariable{newV, v.fullyQualifiedNameOrExpr, false, start, nil}, nil
 }
 
 func getIndexedVariableCount(c *proc.Variable) int {
@@ -2214,6 +2434,7 @@ func (s *Session) childrenToDAPVariables(v *fullyQualifiedVariable) ([]dap.Varia
 					VariablesReference: keyref,
 					IndexedVariables:   getIndexedVariableCount(keyv),
 					NamedVariables:     getNamedVariableCount(keyv),
+					MemoryReference:    s.getMemoryReferenceIfSupported(keyv),
 				}
 				valvar := dap.Variable{
 					Name:               fmt.Sprintf("[val %d]", v.startIndex+kvIndex),
@@ -2223,6 +2444,7 @@ func (s *Session) childrenToDAPVariables(v *fullyQualifiedVariable) ([]dap.Varia
 					VariablesReference: valref,
 					IndexedVariables:   getIndexedVariableCount(valv),
 					NamedVariables:     getNamedVariableCount(valv),
+					MemoryReference:    s.getMemoryReferenceIfSupported(valv),
 				}
 				children = append(children, keyvar, valvar)
 			} else { // At least one is a scalar
@@ -2231,10 +2453,11 @@ func (s *Session) childrenToDAPVariables(v *fullyQualifiedVariable) ([]dap.Varia
 					keyValType = fmt.Sprintf("%s: %s", keyType, valType)
 				}
 				kvvar := dap.Variable{
-					Name:         key,
-					EvaluateName: valexpr,
-					Type:         keyValType,
-					Value:        val,
+					Name:            key,
+					EvaluateName:    valexpr,
+					Type:            keyValType,
+					Value:           val,
+					MemoryReference: s.getMemoryReferenceIfSupported(valv),
 				}
 				if keyref != 0 { // key is a type to be expanded
 					if len(key) > maxMapKeyValueLen {
@@ -2266,6 +2489,7 @@ func (s *Session) childrenToDAPVariables(v *fullyQualifiedVariable) ([]dap.Varia
 				VariablesReference: cvarref,
 				IndexedVariables:   getIndexedVariableCount(&v.Children[i]),
 				NamedVariables:     getNamedVariableCount(&v.Children[i]),
+				MemoryReference:    s.getMemoryReferenceIfSupported(&v.Children[i]),
 			}
 		}
 	default:
@@ -2317,6 +2541,7 @@ func (s *Session) childrenToDAPVariables(v *fullyQualifiedVariable) ([]dap.Varia
 				VariablesReference: cvarref,
 				IndexedVariables:   getIndexedVariableCount(c),
 				NamedVariables:     getNamedVariableCount(c),
+				MemoryReference:    s.getMemoryReferenceIfSupported(c),
 			}
 		}
 	}
@@ -2392,6 +2617,28 @@ func (s *Session) getTypeIfSupported(v *proc.Variable) string {
 	return v.TypeString()
 }
 
+// getMemoryReferenceIfSupported returns the memory reference of a pointer
+// or slice variable, the address it points to, if the client supports
+// memory references.
+func (s *Session) getMemoryReferenceIfSupported(v *proc.Variable) string {
+	if !s.clientCapabilities.supportsMemoryReferences || v.Unreadable != nil {
+		return ""
+	}
+	var addr uint64
+	switch v.Kind {
+	case reflect.Ptr, reflect.UnsafePointer:
+		if len(v.Children) == 1 {
+			addr = v.Children[0].Addr
+		}
+	case reflect.Slice:
+		addr = v.Base
+	}
+	if addr == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%#x", addr)
+}
+
 // convertVariable converts proc.Variable to dap.Variable value and reference
 // while keeping track of the full qualified name or load expression.
 // Variable reference is used to keep track of the children associated with each
@@ -2436,7 +2683,7 @@ func (s *Session) convertVariableWithOpts(v *proc.Variable, qualifiedNameOrExpr
 		if opts&skipRef != 0 {
 			return 0
 		}
-		return s.variableHandles.create(&fullyQualifiedVariable{v, qualifiedNameOrExpr, false /*not a scope*/, 0})
+		return s.variableHandles.create(&fullyQualifiedVariable{v, qualifiedNameOrExpr, false /*not a scope*/, 0, nil})
 	}
 	value = api.ConvertVar(v).SinglelineString()
 	if v.Unreadable != nil {
@@ -2636,7 +2883,7 @@ func (s *Session) onEvaluateRequest(request *dap.EvaluateRequest) {
 			}
 			response.Body = dap.EvaluateResponseBody{
 				Result:             strings.TrimRight(retVarsAsStr, ", "),
-				VariablesReference: s.variableHandles.create(&fullyQualifiedVariable{retVarsAsVar, "", false /*not a scope*/, 0}),
+				VariablesReference: s.variableHandles.create(&fullyQualifiedVariable{retVarsAsVar, "", false /*not a scope*/, 0, nil}),
 			}
 		}
 	} else { // {expression}
@@ -2669,7 +2916,7 @@ func (s *Session) onEvaluateRequest(request *dap.EvaluateRequest) {
 			opts |= showFullValue
 		}
 		exprVal, exprRef := s.convertVariableWithOpts(exprVar, fmt.Sprintf("(%s)", request.Arguments.Expression), opts)
-		response.Body = dap.EvaluateResponseBody{Result: exprVal, VariablesReference: exprRef, IndexedVariables: getIndexedVariableCount(exprVar), NamedVariables: getNamedVariableCount(exprVar)}
+		response.Body = dap.EvaluateResponseBody{Result: exprVal, VariablesReference: exprRef, IndexedVariables: getIndexedVariableCount(exprVar), NamedVariables: getNamedVariableCount(exprVar), MemoryReference: s.getMemoryReferenceIfSupported(exprVar)}
 	}
 	s.send(response)
 }
@@ -2784,10 +3031,154 @@ func (s *Session) onTerminateRequest(request *dap.TerminateRequest) {
 	s.sendNotYetImplementedErrorResponse(request.Request)
 }
 
-// onRestartRequest sends a not-yet-implemented error response
-// Capability 'supportsRestartRequest' is not set in 'initialize' response.
-func (s *Session) onRestartRequest(request *dap.RestartRequest) {
-	s.sendNotYetImplementedErrorResponse(request.Request)
+// onRestartRequest handles 'restart' request.
+// This is an optional request enabled by capability ‘supportsRestartRequest’.
+// The program is rebuilt (in 'debug' and 'test' mode) and relaunched, or
+// attached to again, keeping the breakpoints of the session. The arguments
+// of the request can update the program arguments, the build flags and the
+// options that can be changed during the session.
+func (s *Session) onRestartRequest(request *dap.RestartRequest, allowNextStateChange chan struct{}) {
+	defer closeIfOpen(allowNextStateChange)
+	if err := s.restart(request.Arguments.Arguments); err != nil {
+		s.sendErrorResponse(request.Request, UnableToRestart, "Unable to restart", err.Error())
+		return
+	}
+	s.send(&dap.RestartResponse{Response: *newResponse(request.Request)})
+
+	if s.args.stopOnEntry {
+		s.send(&dap.StoppedEvent{
+			Event: *newEvent("stopped"),
+			Body:  dap.StoppedEventBody{Reason: "entry", ThreadId: 1, AllThreadsStopped: true},
+		})
+		return
+	}
+	s.runUntilStopAndNotify(api.Continue, allowNextStateChange)
+}
+
+// restart halts the target and restarts it with the configuration of the
+// session, updated with the restart arguments.
+func (s *Session) restart(arguments interface{}) error {
+	s.changeStateMu.Lock()
+	defer s.changeStateMu.Unlock()
+
+	// Halting will stop any command that's pending on another goroutine,
+	// tell the auto-resumer not to resume.
+	s.setHaltRequested(true)
+	if _, err := s.halt(); err != nil {
+		if _, exited := err.(proc.ErrProcessExited); !exited {
+			return err
+		}
+	}
+
+	var discarded []api.DiscardedBreakpoint
+	var err error
+	switch {
+	case s.launchConfig != nil:
+		discarded, err = s.restartLaunch(arguments)
+	case s.attachConfig != nil && s.attachConfig.Mode == "local":
+		discarded, err = s.restartAttach(arguments)
+	default:
+		// Remote attach: restart the target of the server, as the 'restart'
+		// command of the terminal client does.
+		args := defaultAttachConfig
+		if err := s.updateRestartConfig(arguments, &args); err != nil {
+			return err
+		}
+		rebuild := s.config.Debugger.ExecuteKind == debugger.ExecutingGeneratedFile || s.config.Debugger.ExecuteKind == debugger.ExecutingGeneratedTest
+		discarded, err = s.debugger.Restart(false, "", false, nil, [3]string{}, rebuild, nil, "")
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, bp := range discarded {
+		s.logToConsole(fmt.Sprintf("Discarded breakpoint %d at %s:%d: %s", bp.Breakpoint.ID, bp.Breakpoint.File, bp.Breakpoint.Line, bp.Reason))
+	}
+	s.debugger.Target().KeepSteppingBreakpoints = proc.HaltKeepsSteppingBreakpoints | proc.TracepointKeepsSteppingBreakpoints
+	s.exceptionErr = nil
+	s.resetHandlesForStoppedEvent()
+	s.setHaltRequested(false)
+	return nil
+}
+
+// updateRestartConfig unmarshals the arguments of a restart request, the
+// latest version of the launch or attach configuration, into config and
+// applies its options that can be changed during the session.
+func (s *Session) updateRestartConfig(arguments interface{}, config interface{}) error {
+	if arguments == nil {
+		return nil
+	}
+	input, err := json.Marshal(arguments)
+	if err != nil {
+		return err
+	}
+	if err := unmarshalLaunchAttachArgs(input, config); err != nil {
+		return fmt.Errorf("invalid debug configuration - %v", err)
+	}
+	switch config := config.(type) {
+	case *LaunchConfig:
+		return s.setLaunchAttachArgs(config.LaunchAttachCommonConfig)
+	case *AttachConfig:
+		return s.setLaunchAttachArgs(config.LaunchAttachCommonConfig)
+	}
+	return nil
+}
+
+// restartLaunch rebuilds and relaunches the program of a launch request.
+// Only the program arguments and the build flags of the launch
+// configuration are updated by the restart arguments.
+func (s *Session) restartLaunch(arguments interface{}) ([]api.DiscardedBreakpoint, error) {
+	args := *s.launchConfig
+	if arguments != nil {
+		// Changes to the other attributes would require a new session.
+		newArgs := defaultLaunchConfig
+		if err := s.updateRestartConfig(arguments, &newArgs); err != nil {
+			return nil, err
+		}
+		args.Args, args.BuildFlags = newArgs.Args, newArgs.BuildFlags
+	}
+
+	if args.Mode == "debug" || args.Mode == "test" {
+		if err := s.build(&args); err != nil {
+			return nil, errors.New("build error: check the debug console for details")
+		}
+	}
+	progressID := s.startProgress("Restarting", "")
+	discarded, err := s.debugger.Restart(false, "", true, args.Args, s.config.Debugger.Redirects, false, nil, "")
+	s.endProgress(progressID, "")
+	if err != nil {
+		return nil, err
+	}
+	s.launchConfig = &args
+	return discarded, nil
+}
+
+// restartAttach detaches from the process of a local attach request and
+// attaches to it again, recreating the breakpoints.
+func (s *Session) restartAttach(arguments interface{}) ([]api.DiscardedBreakpoint, error) {
+	args := defaultAttachConfig
+	if err := s.updateRestartConfig(arguments, &args); err != nil {
+		return nil, err
+	}
+
+	bps := s.debugger.Breakpoints(false)
+	if err := s.debugger.Detach(false); err != nil {
+		return nil, err
+	}
+	if err := s.newDebugger(nil); err != nil {
+		return nil, err
+	}
+
+	discarded := []api.DiscardedBreakpoint{}
+	for _, bp := range bps {
+		if bp.ID < 0 {
+			continue
+		}
+		if _, err := s.debugger.CreateBreakpoint(bp); err != nil {
+			discarded = append(discarded, api.DiscardedBreakpoint{Breakpoint: bp, Reason: err.Error()})
+		}
+	}
+	return discarded, nil
 }
 
 // onStepBackRequest handles 'stepBack' request.
@@ -2833,6 +3224,10 @@ func (s *Session) onSetVariableRequest(request *dap.SetVariableRequest) {
 		s.sendErrorResponse(request.Request, UnableToSetVariable, "Unable to lookup variable", fmt.Sprintf("unknown reference %d", arg.VariablesReference))
 		return
 	}
+	if v.registersOf != nil {
+		s.setRegister(request, *v.registersOf)
+		return
+	}
 	// We need to translate the arg.Name to its evaluateName if the name
 	// refers to a field or element of a variable.
 	// https://github.com/microsoft/vscode/issues/120774
@@ -2853,6 +3248,82 @@ func (s *Session) onSetVariableRequest(request *dap.SetVariableRequest) {
 		return
 	}
 
+	if err := s.setVariableValue(goid, frame, evaluateName, evaluated, arg.Value); err != nil {
+		s.sendErrorResponse(request.Request, UnableToSetVariable, "Unable to set variable", err.Error())
+		return
+	}
+
+	// * Note on inconsistent state after set variable:
+	//
+	// The variable handles may be in inconsistent state - for example,
+	// let's assume there are two aliased variables pointing to the same
+	// memory and both are already loaded and cached in the variable handle.
+	// VSCode tries to locally update the UI when the set variable
+	// request succeeds, and may issue additional scopes or evaluate requests
+	// to update the variable/watch sections if necessary.
+	//
+	// More complicated situation is when the set variable involves call
+	// injection - after the injected call is completed, the debugee can
+	// be in a completely different state (see the note in doCall) due to
+	// how the call injection is implemented. Ideally, we need to also refresh
+	// the stack frames but that is complicated. For now we don't try to actively
+	// invalidate this state hoping that the editors will refetch the state
+	// as soon as the user resumes debugging.
+
+	response := &dap.SetVariableResponse{Response: *newResponse(request.Request)}
+	response.Body.Value = arg.Value
+	// TODO(hyangah): instead of arg.Value, reload the variable and return
+	// the presentation of the new value.
+	s.send(response)
+}
+
+// setRegister handles a 'setVariable' request for the Registers scope of
+// frame. Only the registers of the topmost frame of a goroutine running on
+// a thread can be changed.
+func (s *Session) setRegister(request *dap.SetVariableRequest, frame stackFrame) {
+	arg := request.Arguments
+	if frame.frameIndex != 0 {
+		s.sendErrorResponse(request.Request, UnableToSetVariable, "Unable to set register", "registers can only be changed in the topmost frame")
+		return
+	}
+	g, err := s.debugger.FindGoroutine(frame.goroutineID)
+	if err != nil {
+		s.sendErrorResponse(request.Request, UnableToSetVariable, "Unable to set register", err.Error())
+		return
+	}
+	if g == nil || g.Thread == nil {
+		s.sendErrorResponse(request.Request, UnableToSetVariable, "Unable to set register", "goroutine is not running on a thread")
+		return
+	}
+	// Register names are padded to align their values.
+	warning, err := s.debugger.SetRegister(g.Thread.ThreadID(), strings.TrimSpace(arg.Name), arg.Value)
+	if err != nil {
+		s.sendErrorResponse(request.Request, UnableToSetVariable, "Unable to set register", err.Error())
+		return
+	}
+	if warning != "" {
+		s.logToConsole("Warning: " + warning)
+	}
+
+	response := &dap.SetVariableResponse{Response: *newResponse(request.Request)}
+	response.Body.Value = arg.Value
+	s.send(response)
+	// Changing a register can change the values of the variables and, for
+	// the program counter and the stack pointer, the stack of the goroutine.
+	areas := []dap.InvalidatedAreas{"variables"}
+	if warning != "" {
+		areas = []dap.InvalidatedAreas{"all"}
+	}
+	s.send(&dap.InvalidatedEvent{
+		Event: *newEvent("invalidated"),
+		Body:  dap.InvalidatedEventBody{Areas: areas},
+	})
+}
+
+// setVariableValue assigns value to the variable, or l-value expression,
+// evaluateName. evaluated is the result of evaluating evaluateName in the
+// given goroutine and frame.
+func (s *Session) setVariableValue(goid, frame int, evaluateName string, evaluated *proc.Variable, value string) error {
 	useFnCall := false
 	switch evaluated.Kind {
 	case reflect.String:
@@ -2860,16 +3331,15 @@ func (s *Session) onSetVariableRequest(request *dap.SetVariableRequest) {
 	default:
 		// TODO(hyangah): it's possible to set a non-string variable using (`call i = fn()`)
 		// and we don't support it through the Set Variable request yet.
-		// If we want to support it for non-string types, we need to parse arg.Value.
+		// If we want to support it for non-string types, we need to parse value.
 	}
 
 	if useFnCall {
 		// TODO(hyangah): function call injection currentlly allows to assign return values of
 		// a function call to variables. So, curious users would find set variable
 		// on string would accept expression like `fn()`.
-		if state, retVals, err := s.doCall(goid, frame, fmt.Sprintf("%v=%v", evaluateName, arg.Value)); err != nil {
-			s.sendErrorResponse(request.Request, UnableToSetVariable, "Unable to set variable", err.Error())
-			return
+		if state, retVals, err := s.doCall(goid, frame, fmt.Sprintf("%v=%v", evaluateName, value)); err != nil {
+			return err
 		} else if retVals != nil {
 			// The assignment expression isn't supposed to return values, but we got them.
 			// That indicates something went wrong (e.g. panic).
@@ -2885,44 +3355,51 @@ func (s *Session) onSetVariableRequest(request *dap.SetVariableRequest) {
 			if len(r) > 0 {
 				msg = "interrupted:" + strings.Join(r, ", ")
 			}
-
-			s.sendErrorResponse(request.Request, UnableToSetVariable, "Unable to set variable", msg)
-			return
+			return errors.New(msg)
 		}
 	} else {
-		if err := s.debugger.SetVariableInScope(goid, frame, 0, evaluateName, arg.Value); err != nil {
-			s.sendErrorResponse(request.Request, UnableToSetVariable, "Unable to set variable", err.Error())
-			return
+		if err := s.debugger.SetVariableInScope(goid, frame, 0, evaluateName, value); err != nil {
+			return err
 		}
 	}
-	// * Note on inconsistent state after set variable:
-	//
-	// The variable handles may be in inconsistent state - for example,
-	// let's assume there are two aliased variables pointing to the same
-	// memory and both are already loaded and cached in the variable handle.
-	// VSCode tries to locally update the UI when the set variable
-	// request succeeds, and may issue additional scopes or evaluate requests
-	// to update the variable/watch sections if necessary.
-	//
-	// More complicated situation is when the set variable involves call
-	// injection - after the injected call is completed, the debugee can
-	// be in a completely different state (see the note in doCall) due to
-	// how the call injection is implemented. Ideally, we need to also refresh
-	// the stack frames but that is complicated. For now we don't try to actively
-	// invalidate this state hoping that the editors will refetch the state
-	// as soon as the user resumes debugging.
-
-	response := &dap.SetVariableResponse{Response: *newResponse(request.Request)}
-	response.Body.Value = arg.Value
-	// TODO(hyangah): instead of arg.Value, reload the variable and return
-	// the presentation of the new value.
-	s.send(response)
+	return nil
 }
 
-// onSetExpression sends a not-yet-implemented error response.
-// Capability 'supportsSetExpression' is not set 'initialize' response.
+// onSetExpressionRequest handles 'setExpression' requests, which assign a
+// value to an arbitrary l-value expression, for example from the watch panel.
+// Capability 'supportsSetExpression' is set in 'initialize' response.
 func (s *Session) onSetExpressionRequest(request *dap.SetExpressionRequest) {
-	s.sendNotYetImplementedErrorResponse(request.Request)
+	arg := request.Arguments
+
+	// If no frame is specified use the topmost frame of the current goroutine,
+	// like evaluate requests do.
+	goid, frame := -1, 0
+	if sf, ok := s.stackFrameHandles.get(arg.FrameId); ok {
+		goid = sf.(stackFrame).goroutineID
+		frame = sf.(stackFrame).frameIndex
+	}
+
+	evaluated, err := s.debugger.EvalVariableInScope(goid, frame, 0, arg.Expression, DefaultLoadConfig)
+	if err != nil {
+		s.sendErrorResponse(request.Request, UnableToSetExpression, "Unable to lookup expression", err.Error())
+		return
+	}
+	if err := s.setVariableValue(goid, frame, arg.Expression, evaluated, arg.Value); err != nil {
+		s.sendErrorResponse(request.Request, UnableToSetExpression, "Unable to set expression", err.Error())
+		return
+	}
+
+	// Reload the expression to return the presentation of the new value.
+	response := &dap.SetExpressionResponse{Response: *newResponse(request.Request)}
+	if v, err := s.debugger.EvalVariableInScope(goid, frame, 0, arg.Expression, DefaultLoadConfig); err != nil {
+		response.Body.Value = arg.Value
+	} else {
+		response.Body.Value, response.Body.VariablesReference = s.convertVariable(v, fmt.Sprintf("(%s)", arg.Expression))
+		response.Body.Type = s.getTypeIfSupported(v)
+		response.Body.IndexedVariables = getIndexedVariableCount(v)
+		response.Body.NamedVariables = getNamedVariableCount(v)
+	}
+	s.send(response)
 }
 
 // onLoadedSourcesRequest sends a not-yet-implemented error response.
@@ -2931,10 +3408,186 @@ func (s *Session) onLoadedSourcesRequest(request *dap.LoadedSourcesRequest) {
 	s.sendNotYetImplementedErrorResponse(request.Request)
 }
 
-// onReadMemoryRequest sends a not-yet-implemented error response.
-// Capability 'supportsReadMemoryRequest' is not set 'initialize' response.
+// onModulesRequest handles 'modules' request.
+// This is an optional request enabled by capability ‘supportsModulesRequest’.
+// The modules are the executable and the shared libraries and plugins
+// loaded by the target. After the first modules request, changes to the
+// list of modules are reported with module events when the target stops.
+func (s *Session) onModulesRequest(request *dap.ModulesRequest) {
+	modules := s.listModules()
+	s.modules = make(map[int]dap.Module, len(modules))
+	for _, m := range modules {
+		s.modules[m.Id.(int)] = m
+	}
+
+	total := len(modules)
+	start := request.Arguments.StartModule
+	if start < 0 || start > total {
+		start = total
+	}
+	end := total
+	if count := request.Arguments.ModuleCount; count > 0 && start+count < total {
+		end = start + count
+	}
+	response := &dap.ModulesResponse{
+		Response: *newResponse(request.Request),
+		Body:     dap.ModulesResponseBody{Modules: modules[start:end], TotalModules: total},
+	}
+	s.send(response)
+}
+
+// listModules returns the images loaded by the target as DAP modules.
+func (s *Session) listModules() []dap.Module {
+	s.debugger.LockTarget()
+	defer s.debugger.UnlockTarget()
+	images := s.debugger.Target().BinInfo().Images
+	modules := make([]dap.Module, len(images))
+	for i, image := range images {
+		modules[i] = dap.Module{
+			Id:           i,
+			Name:         filepath.Base(image.Path),
+			Path:         s.toClientPath(image.Path),
+			SymbolStatus: "Symbols loaded",
+		}
+		if err := image.LoadError(); err != nil {
+			modules[i].SymbolStatus = fmt.Sprintf("Symbols not loaded: %v", err)
+		}
+		if image.StaticBase != 0 {
+			modules[i].AddressRange = fmt.Sprintf("%#x", image.StaticBase)
+		}
+	}
+	return modules
+}
+
+// sendModuleEvents sends module events for the modules that were loaded,
+// changed or unloaded since the modules were last reported to the client.
+func (s *Session) sendModuleEvents() {
+	if s.modules == nil {
+		return
+	}
+	modules := s.listModules()
+	seen := make(map[int]bool, len(modules))
+	for _, m := range modules {
+		id := m.Id.(int)
+		seen[id] = true
+		reason := "new"
+		if old, ok := s.modules[id]; ok {
+			if old == m {
+				continue
+			}
+			reason = "changed"
+		}
+		s.modules[id] = m
+		s.send(&dap.ModuleEvent{Event: *newEvent("module"), Body: dap.ModuleEventBody{Reason: reason, Module: m}})
+	}
+	removed := []int{}
+	for id := range s.modules {
+		if !seen[id] {
+			removed = append(removed, id)
+		}
+	}
+	sort.Ints(removed)
+	for _, id := range removed {
+		s.send(&dap.ModuleEvent{Event: *newEvent("module"), Body: dap.ModuleEventBody{Reason: "removed", Module: s.modules[id]}})
+		delete(s.modules, id)
+	}
+}
+
+// readMemoryChunk is the size of the blocks read by onReadMemoryRequest,
+// used to find the first unreadable byte of a partially readable range.
+const readMemoryChunk = 4096
+
+// maxReadMemoryCount is the maximum number of bytes returned by a
+// 'readMemory' request.
+const maxReadMemoryCount = 1 << 20
+
+// memoryReferenceAddress returns the address at offset bytes from a memory
+// reference, which is the address formatted as a hexadecimal number.
+func memoryReferenceAddress(memoryReference string, offset int) (uint64, error) {
+	addr, err := strconv.ParseUint(memoryReference, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory reference %q", memoryReference)
+	}
+	return addr + uint64(offset), nil
+}
+
+// onReadMemoryRequest handles 'readMemory' requests.
+// Capability 'supportsReadMemoryRequest' is set in 'initialize' response.
 func (s *Session) onReadMemoryRequest(request *dap.ReadMemoryRequest) {
-	s.sendNotYetImplementedErrorResponse(request.Request)
+	addr, err := memoryReferenceAddress(request.Arguments.MemoryReference, request.Arguments.Offset)
+	if err != nil {
+		s.sendErrorResponse(request.Request, UnableToReadMemory, "Unable to read memory", err.Error())
+		return
+	}
+	count := request.Arguments.Count
+	if count < 0 {
+		s.sendErrorResponse(request.Request, UnableToReadMemory, "Unable to read memory", "invalid count")
+		return
+	}
+	if count > maxReadMemoryCount {
+		count = maxReadMemoryCount
+	}
+
+	// Read the memory in chunks aligned to readMemoryChunk, stopping at the
+	// first chunk that can not be read: the rest of the range is reported as
+	// unreadable.
+	data := make([]byte, 0, count)
+	for len(data) < count {
+		cur := addr + uint64(len(data))
+		n := readMemoryChunk - int(cur%readMemoryChunk)
+		if n > count-len(data) {
+			n = count - len(data)
+		}
+		buf, err := s.debugger.ExamineMemory(cur, n)
+		if err != nil {
+			break
+		}
+		data = append(data, buf...)
+	}
+
+	response := &dap.ReadMemoryResponse{
+		Response: *newResponse(request.Request),
+		Body: dap.ReadMemoryResponseBody{
+			Address:         fmt.Sprintf("%#x", addr),
+			UnreadableBytes: count - len(data),
+			Data:            base64.StdEncoding.EncodeToString(data),
+		},
+	}
+	s.send(response)
+}
+
+// onWriteMemoryRequest handles 'writeMemory' requests.
+// Capability 'supportsWriteMemoryRequest' is set in 'initialize' response.
+func (s *Session) onWriteMemoryRequest(request *dap.WriteMemoryRequest) {
+	addr, err := memoryReferenceAddress(request.Arguments.MemoryReference, request.Arguments.Offset)
+	if err != nil {
+		s.sendErrorResponse(request.Request, UnableToWriteMemory, "Unable to write memory", err.Error())
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(request.Arguments.Data)
+	if err != nil {
+		s.sendErrorResponse(request.Request, UnableToWriteMemory, "Unable to write memory", fmt.Sprintf("invalid data: %v", err))
+		return
+	}
+
+	n, err := s.debugger.WriteMemory(addr, data)
+	if err != nil && (n == 0 || !request.Arguments.AllowPartial) {
+		s.sendErrorResponse(request.Request, UnableToWriteMemory, "Unable to write memory", err.Error())
+		return
+	}
+
+	response := &dap.WriteMemoryResponse{
+		Response: *newResponse(request.Request),
+		Body: dap.WriteMemoryResponseBody{
+			BytesWritten: n,
+		},
+	}
+	s.send(response)
+	// The values of the variables may have changed.
+	s.send(&dap.InvalidatedEvent{
+		Event: *newEvent("invalidated"),
+		Body:  dap.InvalidatedEventBody{Areas: []dap.InvalidatedAreas{"variables"}},
+	})
 }
 
 var invalidInstruction = dap.DisassembledInstruction{
@@ -2952,6 +3605,9 @@ func (s *Session) onDisassembleRequest(request *dap.DisassembleRequest) {
 		s.sendErrorResponse(request.Request, UnableToDisassemble, "Unable to disassemble", err.Error())
 		return
 	}
+	if request.Arguments.Offset != 0 && addr != 0 && addr != uint64(math.MaxUint64) {
+		addr += uint64(request.Arguments.Offset)
+	}
 
 	// If the requested memory address is an invalid location, return all invalid instructions.
 	// TODO(suzmue): consider adding fake addresses that would allow us to receive out of bounds
@@ -2997,6 +3653,12 @@ func (s *Session) onDisassembleRequest(request *dap.DisassembleRequest) {
 		return
 	}
 
+	// The byte offset can move the reference location in the middle of an
+	// instruction, use the instruction containing it.
+	if request.Arguments.Offset != 0 {
+		addr = instructionContaining(procInstructions, addr)
+	}
+
 	// Find the section of instructions that were requested.
 	procInstructions, offset, err := findInstructions(procInstructions, addr, request.Arguments.InstructionOffset, request.Arguments.InstructionCount)
 	if err != nil {
@@ -3004,9 +3666,17 @@ func (s *Session) onDisassembleRequest(request *dap.DisassembleRequest) {
 		return
 	}
 
+	// Convert all the instructions first, so that branch destinations can be
+	// annotated.
+	apiInstructions := make(api.AsmInstructions, len(procInstructions))
+	for i := range procInstructions {
+		apiInstructions[i] = api.ConvertAsmInstruction(procInstructions[i], s.debugger.AsmInstructionText(&procInstructions[i], proc.GoFlavour))
+	}
+
 	// Turn the given range of instructions into dap instructions.
 	instructions := make([]dap.DisassembledInstruction, request.Arguments.InstructionCount)
 	lastFile, lastLine := "", -1
+	var lastFn *api.Function
 	for i := range instructions {
 		// i is not in a valid range, use an address that is just before or after
 		// the range. This ensures that it can still be parsed as an int.
@@ -3023,12 +3693,20 @@ func (s *Session) onDisassembleRequest(request *dap.DisassembleRequest) {
 			continue
 
 		}
-		instruction := api.ConvertAsmInstruction(procInstructions[i-offset], s.debugger.AsmInstructionText(&procInstructions[i-offset], proc.GoFlavour))
+		instruction := apiInstructions[i-offset]
 		instructions[i] = dap.DisassembledInstruction{
 			Address:          fmt.Sprintf("%#x", instruction.Loc.PC),
 			InstructionBytes: fmt.Sprintf("%x", instruction.Bytes),
 			Instruction:      instruction.Text,
 		}
+		if annot := apiInstructions.BranchAnnotation(i - offset); annot != "" {
+			instructions[i].Instruction += "\t" + annot
+		}
+		// Only set the symbol on the first instruction of each function.
+		if fn := instruction.Loc.Function; fn != nil && (lastFn == nil || fn.Name() != lastFn.Name()) {
+			instructions[i].Symbol = fn.Name()
+			lastFn = fn
+		}
 		// Only set the location on the first instruction for a given line.
 		if instruction.Loc.File != lastFile || instruction.Loc.Line != lastLine {
 			instructions[i].Location = dap.Source{Path: instruction.Loc.File}
@@ -3046,6 +3724,22 @@ func (s *Session) onDisassembleRequest(request *dap.DisassembleRequest) {
 	s.send(response)
 }
 
+// instructionContaining returns the address of the instruction in
+// procInstructions that contains addr, or addr if there is none.
+func instructionContaining(procInstructions []proc.AsmInstruction, addr uint64) uint64 {
+	i := sort.Search(len(procInstructions), func(i int) bool {
+		return procInstructions[i].Loc.PC > addr
+	})
+	if i == 0 {
+		return addr
+	}
+	inst := &procInstructions[i-1]
+	if addr < inst.Loc.PC+uint64(inst.Size) {
+		return inst.Loc.PC
+	}
+	return addr
+}
+
 func findInstructions(procInstructions []proc.AsmInstruction, addr uint64, instructionOffset, count int) ([]proc.AsmInstruction, int, error) {
 	ref := sort.Search(len(procInstructions), func(i int) bool {
 		return procInstructions[i].Loc.PC >= addr
@@ -3202,6 +3896,15 @@ func (s *Session) onExceptionInfoRequest(request *dap.ExceptionInfoRequest) {
 			if err != nil {
 				body.Description = fmt.Sprintf("Error getting panic message: %s", err.Error())
 			}
+			// Attempt to get the full panic value and the panics it interrupted.
+			if details, err := s.panicDetails(goroutineID); err != nil {
+				s.config.log.Debug("Unable to get panic details: ", err)
+			} else {
+				body.Details = details
+				if body.Description == "" {
+					body.Description = details.Message
+				}
+			}
 		}
 	} else {
 		// If this thread is not stopped on a breakpoint, then a runtime error must have occurred.
@@ -3281,6 +3984,56 @@ func (s *Session) panicReason(goroutineID int) (string, error) {
 	return s.getExprString("(*msgs).arg.(data)", goroutineID, 0)
 }
 
+// maxPanicDetails is the maximum number of panics returned by panicDetails.
+const maxPanicDetails = 10
+
+// panicDetails returns the details of the panic of a goroutine stopped in
+// runtime.fatalpanic: the panic value, rendered in full, its type and the
+// location of the frame that caused it.
+// The panics that were running deferred calls when the panic happened are
+// returned as inner exceptions, marked as recovered like the runtime does
+// if they were.
+func (s *Session) panicDetails(goroutineID int) (dap.ExceptionDetails, error) {
+	var details dap.ExceptionDetails
+	cur := &details
+	p := "(*msgs)"
+	// Only used for the location of each panic, their values are rendered
+	// from the expressions evaluated below.
+	panics, _ := s.debugger.GoroutinePanics(goroutineID, proc.LoadConfig{})
+	for i := 0; i < maxPanicDetails; i++ {
+		v, err := s.debugger.EvalVariableInScope(goroutineID, 0, 0, p+".arg.(data)", DefaultLoadConfig)
+		if err != nil {
+			if i == 0 {
+				return details, err
+			}
+			break
+		}
+		cur.Message, _ = s.convertVariableWithOpts(v, "", skipRef|showFullValue)
+		cur.TypeName = v.TypeString()
+		cur.EvaluateName = p + ".arg"
+		if recovered, err := s.debugger.EvalVariableInScope(goroutineID, 0, 0, p+".recovered", DefaultLoadConfig); err == nil && recovered.Value != nil && constant.BoolVal(recovered.Value) {
+			cur.Message += " [recovered]"
+		}
+		if i < len(panics) && panics[i].Frame >= 0 {
+			loc := panics[i].Location
+			fnname := "?"
+			if loc.Fn != nil {
+				fnname = loc.Fn.Name
+			}
+			cur.StackTrace = fmt.Sprintf("%s()\n\t%s:%d", fnname, s.toClientPath(loc.File), loc.Line)
+		}
+
+		link, err := s.debugger.EvalVariableInScope(goroutineID, 0, 0, p+".link != nil", DefaultLoadConfig)
+		if err != nil || link.Value == nil || !constant.BoolVal(link.Value) {
+			break
+		}
+		cur.InnerException = []dap.ExceptionDetails{{}}
+		cur = &cur.InnerException[0]
+		p = "(*" + p + ".link)"
+	}
+	return details, nil
+}
+
 func (s *Session) getExprString(expr string, goroutineID, frame int) (string, error) {
 	exprVar, err := s.debugger.EvalVariableInScope(goroutineID, frame, 0, expr, DefaultLoadConfig)
 	if err != nil {
@@ -3457,6 +4210,7 @@ func (s *Session) runUntilStopAndNotify(command string, allowNextStateChange cha
 	}
 	s.config.log.Debugf("%q command stopped - reason %q, location %s:%d", command, stopReason, file, line)
 
+	s.sendModuleEvents()
 	s.resetHandlesForStoppedEvent()
 	stopped := &dap.StoppedEvent{Event: *newEvent("stopped")}
 	stopped.Body.AllThreadsStopped = true
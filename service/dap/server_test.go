@@ -2,6 +2,8 @@ package dap
 
 import (
 	"bufio"
+	"encoding/base64"
+	"encoding/binary"
 	"flag"
 	"fmt"
 	"io"
@@ -1291,8 +1293,12 @@ func TestSelectedThreadsRequest(t *testing.T) {
 					}
 					tr := client.ExpectThreadsResponse(t)
 
-					if len(tr.Body.Threads) != 2 {
-						t.Errorf("got %d threads, expected 2\n", len(tr.Body.Threads))
+					// The loaded goroutine, the selected goroutine and the
+					// synthetic thread for the goroutines that were not loaded.
+					if len(tr.Body.Threads) != 3 {
+						t.Errorf("got %d threads, expected 3\n", len(tr.Body.Threads))
+					} else if last := tr.Body.Threads[2]; last.Id != moreGoroutinesThreadID || !strings.HasSuffix(last.Name, "more goroutines") {
+						t.Errorf("got %#v, want Id=%d Name=\"... N more goroutines\"\n", last, moreGoroutinesThreadID)
 					}
 
 					var selectedFound bool
@@ -1357,6 +1363,109 @@ func TestHideSystemGoroutinesRequest(t *testing.T) {
 	}
 }
 
+func TestHideSystemGoroutinesScanLimit(t *testing.T) {
+	runTest(t, "goroutinestackprog", func(client *daptest.Client, fixture protest.Fixture) {
+		runDebugSessionWithBPs(t, client, "launch",
+			// Launch
+			func() {
+				client.LaunchRequestWithArgs(map[string]interface{}{
+					"mode":                 "exec",
+					"program":              fixture.Path,
+					"hideSystemGoroutines": true,
+					"maxGoroutines":        2,
+					"stopOnEntry":          !stopOnEntry,
+				})
+			},
+			// Set breakpoints
+			fixture.Source, []int{25},
+			[]onBreakpoint{{
+				execute: func() {
+					checkStop(t, client, 1, "main.main", 25)
+
+					defaultMaxScannedGoroutines := maxScannedGoroutines
+					defer func() { maxScannedGoroutines = defaultMaxScannedGoroutines }()
+
+					// The first page contains the main goroutine and a system
+					// goroutine, the second page only system goroutines.
+					maxScannedGoroutines = 4
+					client.ThreadsRequest()
+					client.ExpectOutputEvent(t)
+					tr := client.ExpectThreadsResponse(t)
+
+					// The main goroutine and the synthetic thread for all the
+					// others.
+					if len(tr.Body.Threads) != 2 {
+						t.Fatalf("got %#v, expected 2 threads\n", tr.Body.Threads)
+					}
+					if more := tr.Body.Threads[1]; more.Id != moreGoroutinesThreadID || !strings.HasPrefix(more.Name, "... up to ") {
+						t.Errorf("got %#v, want Id=%d Name=\"... up to N more goroutines\"\n", more, moreGoroutinesThreadID)
+					}
+				},
+				disconnect: true,
+			}})
+	})
+}
+
+func TestMaxGoroutinesRequest(t *testing.T) {
+	runTest(t, "goroutinestackprog", func(client *daptest.Client, fixture protest.Fixture) {
+		runDebugSessionWithBPs(t, client, "launch",
+			// Launch
+			func() {
+				client.LaunchRequestWithArgs(map[string]interface{}{
+					"mode":          "exec",
+					"program":       fixture.Path,
+					"maxGoroutines": 5,
+					"stopOnEntry":   !stopOnEntry,
+				})
+			},
+			// Set breakpoints
+			fixture.Source, []int{25},
+			[]onBreakpoint{{
+				execute: func() {
+					checkStop(t, client, 1, "main.main", 25)
+
+					client.ThreadsRequest()
+					client.ExpectOutputEvent(t)
+					tr := client.ExpectThreadsResponse(t)
+
+					// 5 goroutines and the synthetic thread for all the others.
+					if len(tr.Body.Threads) != 6 {
+						t.Fatalf("got %d threads, expected 6\n", len(tr.Body.Threads))
+					}
+					more := tr.Body.Threads[5]
+					var n int
+					if _, err := fmt.Sscanf(more.Name, "... %d more goroutines", &n); err != nil || more.Id != moreGoroutinesThreadID || n < 6 {
+						t.Errorf("got %#v, want Id=%d Name=\"... N more goroutines\" with N >= 6\n", more, moreGoroutinesThreadID)
+					}
+
+					// The synthetic thread has no stack frames.
+					client.StackTraceRequest(moreGoroutinesThreadID, 0, 20)
+					st := client.ExpectStackTraceResponse(t)
+					if len(st.Body.StackFrames) != 0 {
+						t.Errorf("got %#v, want no stack frames", st)
+					}
+
+					// All goroutines of the user process have user frames, the
+					// goroutines of the runtime don't.
+					client.EvaluateRequest("dlv config maxGoroutines 0", 1000, "repl")
+					client.ExpectInvalidatedEvent(t)
+					client.ExpectEvaluateResponse(t)
+					client.EvaluateRequest("dlv config hideGoroutinesWithoutUserFrames true", 1000, "repl")
+					client.ExpectInvalidatedEvent(t)
+					client.ExpectEvaluateResponse(t)
+
+					client.ThreadsRequest()
+					tr = client.ExpectThreadsResponse(t)
+					userCount := 11
+					if len(tr.Body.Threads) != userCount {
+						t.Errorf("got %d goroutines, expected %d\n", len(tr.Body.Threads), userCount)
+					}
+				},
+				disconnect: true,
+			}})
+	})
+}
+
 // TestScopesAndVariablesRequests executes to a breakpoint and tests different
 // configurations of 'scopes' and 'variables' requests.
 func TestScopesAndVariablesRequests(t *testing.T) {
@@ -2551,6 +2660,73 @@ func TestRegistersScopeAndVariables(t *testing.T) {
 	})
 }
 
+// TestSetRegister executes to a breakpoint and changes the value of a
+// register through a setVariable request on the Registers scope.
+func TestSetRegister(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("test only written for amd64")
+	}
+	runTest(t, "consts", func(client *daptest.Client, fixture protest.Fixture) {
+		runDebugSessionWithBPs(t, client, "launch",
+			// Launch
+			func() {
+				client.LaunchRequestWithArgs(map[string]interface{}{
+					"mode": "exec", "program": fixture.Path, "showRegisters": true,
+				})
+			},
+			// Breakpoints are set within the program
+			fixture.Source, []int{},
+			[]onBreakpoint{{
+				// Stop at line 36
+				execute: func() {
+					client.StackTraceRequest(1, 0, 20)
+					client.ExpectStackTraceResponse(t)
+
+					// The Registers scope gets a new reference every time it is
+					// requested.
+					var registersScope int
+					findReg := func(name string) dap.Variable {
+						t.Helper()
+						client.ScopesRequest(1000)
+						scopes := client.ExpectScopesResponse(t)
+						if len(scopes.Body.Scopes) < 2 || scopes.Body.Scopes[1].Name != "Registers" {
+							t.Fatalf("got %#v, want Registers scope", scopes.Body.Scopes)
+						}
+						registersScope = scopes.Body.Scopes[1].VariablesReference
+						client.VariablesRequest(registersScope)
+						vr := client.ExpectVariablesResponse(t)
+						for _, reg := range vr.Body.Variables {
+							if strings.TrimSpace(reg.Name) == name {
+								return reg
+							}
+						}
+						t.Fatalf("register %s not found in %#v", name, vr.Body.Variables)
+						return dap.Variable{}
+					}
+
+					rax := findReg("rax")
+					client.SetVariableRequest(registersScope, rax.Name, "0x1234")
+					client.ExpectSetVariableResponse(t)
+					client.ExpectInvalidatedEvent(t)
+					if rax = findReg("rax"); rax.Value != "0x0000000000001234" {
+						t.Errorf("got rax = %s, want 0x0000000000001234", rax.Value)
+					}
+
+					// Changing the program counter succeeds with a warning.
+					rip := findReg("rip")
+					client.SetVariableRequest(registersScope, rip.Name, rip.Value)
+					client.ExpectOutputEventRegex(t, "Warning: changing the program counter .*\n")
+					client.ExpectSetVariableResponse(t)
+					client.ExpectInvalidatedEvent(t)
+
+					client.SetVariableRequest(registersScope, "notareg", "1")
+					client.ExpectErrorResponse(t)
+				},
+				disconnect: true,
+			}})
+	})
+}
+
 func findPcReg(regs []dap.Variable) int {
 	for i, reg := range regs {
 		if isPcReg(reg) {
@@ -3230,6 +3406,58 @@ func TestSetFunctionBreakpoints(t *testing.T) {
 	})
 }
 
+// TestSetFunctionBreakpointsWithConditions tests that the condition
+// and the hit condition of function breakpoints are honored.
+func TestSetFunctionBreakpointsWithConditions(t *testing.T) {
+	runTest(t, "callme", func(client *daptest.Client, fixture protest.Fixture) {
+		runDebugSessionWithBPs(t, client, "launch",
+			// Launch
+			func() {
+				client.LaunchRequest("exec", fixture.Path, !stopOnEntry)
+			},
+			// Set breakpoints
+			fixture.Source, []int{23},
+			[]onBreakpoint{{
+				execute: func() {
+					checkStop(t, client, 1, "main.main", 23)
+
+					client.SetFunctionBreakpointsRequest([]dap.FunctionBreakpoint{
+						{Name: "main.callme", Condition: "i > 1", HitCondition: "% 2"},
+					})
+					got := client.ExpectSetFunctionBreakpointsResponse(t)
+					if len(got.Body.Breakpoints) != 1 || !got.Body.Breakpoints[0].Verified || got.Body.Breakpoints[0].Line != 5 {
+						t.Errorf("got %#v, want one verified breakpoint at line 5", got)
+					}
+
+					// The condition is true for i=2,3,4 and the hit condition
+					// for the second of those hits.
+					client.ContinueRequest(1)
+					client.ExpectContinueResponse(t)
+					if se := client.ExpectStoppedEvent(t); se.Body.Reason != "function breakpoint" || se.Body.ThreadId != 1 {
+						t.Errorf("got %#v, want Reason=\"function breakpoint\", ThreadId=1", se)
+					}
+					checkStop(t, client, 1, "main.callme", 5)
+					client.VariablesRequest(localsScope)
+					args := client.ExpectVariablesResponse(t)
+					checkVarExact(t, args, 0, "i", "i", "3", "int", noChildren)
+
+					// Invalid hit conditions are reported.
+					client.SetFunctionBreakpointsRequest([]dap.FunctionBreakpoint{
+						{Name: "main.callme", HitCondition: "= 2"},
+					})
+					got = client.ExpectSetFunctionBreakpointsResponse(t)
+					if len(got.Body.Breakpoints) != 1 || got.Body.Breakpoints[0].Verified {
+						t.Errorf("got %#v, want one unverified breakpoint", got)
+					}
+
+					client.SetFunctionBreakpointsRequest([]dap.FunctionBreakpoint{})
+					client.ExpectSetFunctionBreakpointsResponse(t)
+				},
+				disconnect: true,
+			}})
+	})
+}
+
 // TestLogPoints executes to a breakpoint and tests that log points
 // send OutputEvents and do not halt program execution.
 func TestLogPoints(t *testing.T) {
@@ -3949,6 +4177,9 @@ showGlobalVariables	%v
 showRegisters	%v
 goroutineFilters	%q
 hideSystemGoroutines	%v
+hideGoroutinesWithoutUserFrames	false
+hideRuntimeFrames	false
+maxGoroutines	0
 substitutePath	%v
 `
 	return fmt.Sprintf(formatStr, depth, showGlobals, showRegisters, goroutineFilters, hideSystemGoroutines, substitutePath)
@@ -4455,6 +4686,25 @@ func TestHardCodedBreakpoints(t *testing.T) {
 
 // TestStepInstruction executes to a breakpoint and tests stepping
 // a single instruction
+func TestInstructionStepCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{api.Next, api.StepInstruction},
+		{api.Step, api.StepInstruction},
+		{api.StepOut, api.StepInstruction},
+		{api.ReverseNext, api.ReverseStepInstruction},
+		{api.ReverseStep, api.ReverseStepInstruction},
+		{api.ReverseStepOut, api.ReverseStepInstruction},
+	}
+	for _, tc := range tests {
+		if got := instructionStepCommand(tc.command); got != tc.want {
+			t.Errorf("instructionStepCommand(%q) = %q, want %q", tc.command, got, tc.want)
+		}
+	}
+}
+
 func TestStepInstruction(t *testing.T) {
 	runTest(t, "testvariables", func(client *daptest.Client, fixture protest.Fixture) {
 		runDebugSessionWithBPs(t, client, "launch",
@@ -4913,6 +5163,9 @@ func TestPanicBreakpointOnContinue(t *testing.T) {
 					if eInfo.Body.ExceptionId != "panic" || eInfo.Body.Description != text {
 						t.Errorf("\ngot  %#v\nwant ExceptionId=\"panic\" Description=%q", eInfo, text)
 					}
+					if d := eInfo.Body.Details; d.Message != text || d.TypeName != "string" || len(d.InnerException) != 0 || !strings.Contains(d.StackTrace, "main.main") {
+						t.Errorf("\ngot  %#v\nwant Details={Message=%q TypeName=\"string\" StackTrace=\"...main.main...\"}", d, text)
+					}
 
 					client.StackTraceRequest(se.Body.ThreadId, 0, 20)
 					st := client.ExpectStackTraceResponse(t)
@@ -4932,6 +5185,90 @@ func TestPanicBreakpointOnContinue(t *testing.T) {
 	})
 }
 
+// TestHideRuntimeFrames checks that the sources of runtime frames are
+// deemphasized, so that clients collapse them, when hideRuntimeFrames is set.
+func TestHideRuntimeFrames(t *testing.T) {
+	runTest(t, "consts", func(client *daptest.Client, fixture protest.Fixture) {
+		runDebugSessionWithBPs(t, client, "launch",
+			// Launch
+			func() {
+				client.LaunchRequestWithArgs(map[string]interface{}{
+					"mode": "exec", "program": fixture.Path, "hideRuntimeFrames": true,
+				})
+			},
+			// Breakpoints are set within the program
+			fixture.Source, []int{},
+			[]onBreakpoint{{
+				execute: func() {
+					checkFrames := func(hide bool) {
+						t.Helper()
+						client.StackTraceRequest(1, 0, 20)
+						st := client.ExpectStackTraceResponse(t)
+						nruntime := 0
+						for i, frame := range st.Body.StackFrames {
+							want := ""
+							if hide && strings.HasPrefix(frame.Name, "runtime.") {
+								want = "deemphasize"
+								nruntime++
+							}
+							if frame.Source.PresentationHint != want {
+								t.Errorf("\ngot Body.StackFrames[%d]=%#v\nwant Source.PresentationHint=%q", i, frame, want)
+							}
+						}
+						if hide && nruntime == 0 {
+							t.Errorf("no runtime frames in %#v", st.Body.StackFrames)
+						}
+					}
+
+					checkFrames(true)
+
+					client.EvaluateRequest("dlv config hideRuntimeFrames false", 1000, "repl")
+					client.ExpectInvalidatedEvent(t)
+					client.ExpectEvaluateResponse(t)
+
+					checkFrames(false)
+				},
+				disconnect: true,
+			}})
+	})
+}
+
+func TestPanicBreakpointRecoveredPanic(t *testing.T) {
+	runTest(t, "repanic", func(client *daptest.Client, fixture protest.Fixture) {
+		runDebugSessionWithBPs(t, client, "launch",
+			// Launch
+			func() {
+				client.LaunchRequest("exec", fixture.Path, !stopOnEntry)
+			},
+			// Set breakpoints
+			fixture.Source, []int{},
+			[]onBreakpoint{{
+				execute: func() {
+					client.ExceptionInfoRequest(1)
+					eInfo := client.ExpectExceptionInfoResponse(t)
+					text := `"panic while recovering: {42 BOOM!}"`
+					if eInfo.Body.ExceptionId != "panic" || eInfo.Body.Description != text {
+						t.Errorf("\ngot  %#v\nwant ExceptionId=\"panic\" Description=%q", eInfo, text)
+					}
+					d := eInfo.Body.Details
+					if d.Message != text || d.TypeName != "string" || d.EvaluateName != "(*msgs).arg" {
+						t.Errorf("\ngot  %#v\nwant Message=%q TypeName=\"string\" EvaluateName=\"(*msgs).arg\"", d, text)
+					}
+					// The first panic was recovered, its value is a struct.
+					if len(d.InnerException) != 1 {
+						t.Fatalf("\ngot  %#v\nwant len(InnerException)=1", d)
+					}
+					inner := d.InnerException[0]
+					wantInner := `main.panicError {code: 42, msg: "BOOM!"} [recovered]`
+					if inner.Message != wantInner || inner.TypeName != "main.panicError" || inner.EvaluateName != "(*(*msgs).link).arg" {
+						t.Errorf("\ngot  %#v\nwant Message=%q TypeName=\"main.panicError\"", inner, wantInner)
+					}
+				},
+				disconnect: true,
+			}})
+	})
+}
+
 func TestPanicBreakpointOnNext(t *testing.T) {
 	if !goversion.VersionAfterOrEqual(runtime.Version(), 1, 14) {
 		// In Go 1.13, 'next' will step into the defer in the runtime
@@ -5777,9 +6114,6 @@ func TestUnupportedCommandResponses(t *testing.T) {
 		client.BreakpointLocationsRequest()
 		expectUnsupportedCommand("breakpointLocations")
 
-		client.ModulesRequest()
-		expectUnsupportedCommand("modules")
-
 		client.DisconnectRequest()
 		client.ExpectDisconnectResponse(t)
 	})
@@ -6030,6 +6364,160 @@ func TestSetVariable(t *testing.T) {
 	})
 }
 
+func TestModulesRequest(t *testing.T) {
+	runTest(t, "increment", func(client *daptest.Client, fixture protest.Fixture) {
+		runDebugSessionWithBPs(t, client, "launch",
+			// Launch
+			func() {
+				client.LaunchRequest("exec", fixture.Path, !stopOnEntry)
+			},
+			// Set breakpoints
+			fixture.Source, []int{8},
+			[]onBreakpoint{{
+				execute: func() {
+					checkStop(t, client, 1, "main.Increment", 8)
+
+					client.ModulesRequest()
+					got := client.ExpectModulesResponse(t)
+					if len(got.Body.Modules) < 1 || got.Body.TotalModules != len(got.Body.Modules) {
+						t.Fatalf("got %#v, want at least one module and TotalModules=len(Modules)", got)
+					}
+					exe := got.Body.Modules[0]
+					if fmt.Sprint(exe.Id) != "0" || exe.Name != filepath.Base(fixture.Path) || exe.Path != fixture.Path || exe.SymbolStatus != "Symbols loaded" {
+						t.Errorf("got %#v, want Id=0 Name=%q Path=%q SymbolStatus=\"Symbols loaded\"", exe, filepath.Base(fixture.Path), fixture.Path)
+					}
+
+					client.ModulesRequestWithArgs(0, 1)
+					got = client.ExpectModulesResponse(t)
+					if len(got.Body.Modules) != 1 || got.Body.Modules[0].Path != fixture.Path {
+						t.Errorf("got %#v, want the executable only", got)
+					}
+
+					client.ModulesRequestWithArgs(100, 1)
+					got = client.ExpectModulesResponse(t)
+					if len(got.Body.Modules) != 0 {
+						t.Errorf("got %#v, want no modules", got)
+					}
+				},
+				disconnect: true,
+			}})
+	})
+}
+
+func TestRestartRequest(t *testing.T) {
+	runTest(t, "increment", func(client *daptest.Client, fixture protest.Fixture) {
+		launchArgs := map[string]interface{}{"mode": "debug", "program": fixture.Source}
+		runDebugSessionWithBPs(t, client, "launch",
+			func() {
+				client.LaunchRequestWithArgs(launchArgs)
+			},
+			fixture.Source, []int{8},
+			[]onBreakpoint{{
+				execute: func() {
+					checkStop(t, client, 1, "main.Increment", 8)
+
+					// The program is rebuilt and relaunched, stopping again
+					// on the same breakpoint.
+					client.RestartRequest()
+					client.ExpectRestartResponse(t)
+					client.ExpectStoppedEvent(t)
+					checkStop(t, client, 1, "main.Increment", 8)
+
+					// The arguments of the request update the configuration.
+					launchArgs["args"] = []string{"arg1"}
+					launchArgs["stopOnEntry"] = true
+					client.RestartRequestWithArgs(launchArgs)
+					client.ExpectRestartResponse(t)
+					se := client.ExpectStoppedEvent(t)
+					if se.Body.Reason != "entry" {
+						t.Errorf("got %#v, want Reason=\"entry\"", se)
+					}
+					client.ContinueRequest(1)
+					client.ExpectContinueResponse(t)
+					client.ExpectStoppedEvent(t)
+					checkStop(t, client, 1, "main.Increment", 8)
+
+					// Build errors are reported on the debug console.
+					launchArgs["buildFlags"] = "-nosuchflag"
+					client.RestartRequestWithArgs(launchArgs)
+					oe := client.ExpectOutputEvent(t)
+					if !strings.HasPrefix(oe.Body.Output, "Build Error: ") || oe.Body.Category != "stderr" {
+						t.Errorf("got %#v, want Category=\"stderr\" Output=\"Build Error: ...\"", oe)
+					}
+					er := client.ExpectErrorResponse(t)
+					if er.Body.Error.Id != UnableToRestart {
+						t.Errorf("got %#v, want Id=%d", er, UnableToRestart)
+					}
+				},
+				disconnect: false,
+			}})
+	})
+}
+
+func TestSetExpression(t *testing.T) {
+	runTest(t, "testvariables", func(client *daptest.Client, fixture protest.Fixture) {
+		runDebugSessionWithBPs(t, client, "launch",
+			func() {
+				client.LaunchRequestWithArgs(map[string]interface{}{
+					"mode": "exec", "program": fixture.Path, "showGlobalVariables": true,
+				})
+			},
+			fixture.Source, []int{}, // breakpoints are set within the program.
+			[]onBreakpoint{{
+				execute: func() {
+					tester := &helperForSetVariable{t, client}
+					checkStop(t, client, 1, "main.foobar", -1)
+
+					expectSetExpression := func(expr, value, want string, hasRef bool) {
+						t.Helper()
+						client.SetExpressionRequest(expr, value, 1000)
+						got := client.ExpectSetExpressionResponse(t)
+						if !got.Success || got.Body.Value != want || (got.Body.VariablesReference > 0) != hasRef {
+							t.Errorf("SetExpressionRequest(%v, %v)=%#v, want {Success=true, Body.Value=%q, hasRef=%v}", expr, value, got, want, hasRef)
+						}
+					}
+
+					// Variables shown in scopes.
+					expectSetExpression("a2", "42", "42", noChildren)
+					tester.evaluate("a2", "42", noChildren)
+
+					// Expressions that are not shown in scopes.
+					expectSetExpression("a7.Baz", "9", "9", noChildren)
+					tester.evaluate("a7", `*main.FooBar {Baz: 9, Bur: "strum"}`, hasChildren)
+
+					expectSetExpression("a5[a2-40]", "100", "100", noChildren)
+					tester.evaluate("a5", "[]int len: 5, cap: 5, [1,2,100,4,5]", hasChildren)
+
+					expectSetExpression("a9", "&a6", `*main.FooBar {Baz: 8, Bur: "word"}`, hasChildren)
+
+					// Global variables.
+					expectSetExpression("main.p1", "-10", "-10", noChildren)
+					tester.evaluate("p1", "-10", noChildren)
+
+					// Errors
+					client.SetExpressionRequest("a2", "false", 1000)
+					er := client.ExpectErrorResponse(t)
+					if er.Body.Error.Id != UnableToSetExpression || !stringContainsCaseInsensitive(er.Body.Error.Format, "can not convert") {
+						t.Errorf("got %#v, want Id=%d and error containing \"can not convert\"", er, UnableToSetExpression)
+					}
+
+					client.SetExpressionRequest("a2+1", "3", 1000)
+					er = client.ExpectErrorResponse(t)
+					if er.Body.Error.Id != UnableToSetExpression {
+						t.Errorf("got %#v, want Id=%d", er, UnableToSetExpression)
+					}
+
+					client.SetExpressionRequest("nosuchvar", "3", 1000)
+					er = client.ExpectErrorResponse(t)
+					if er.Body.Error.Id != UnableToSetExpression || er.Message != "Unable to lookup expression" {
+						t.Errorf("got %#v, want Id=%d Message=\"Unable to lookup expression\"", er, UnableToSetExpression)
+					}
+				},
+				disconnect: true,
+			}})
+	})
+}
+
 // TestSetVariableWithCall tests SetVariable features that do not depend on function calls support.
 func TestSetVariableWithCall(t *testing.T) {
 	protest.MustSupportFunctionCalls(t, testBackend)
@@ -6159,18 +6647,9 @@ func TestOptionalNotYetImplementedResponses(t *testing.T) {
 		client.TerminateRequest()
 		expectNotYetImplemented("terminate")
 
-		client.RestartRequest()
-		expectNotYetImplemented("restart")
-
-		client.SetExpressionRequest()
-		expectNotYetImplemented("setExpression")
-
 		client.LoadedSourcesRequest()
 		expectNotYetImplemented("loadedSources")
 
-		client.ReadMemoryRequest()
-		expectNotYetImplemented("readMemory")
-
 		client.CancelRequest()
 		expectNotYetImplemented("cancel")
 
@@ -6932,6 +7411,27 @@ func TestDisassemble(t *testing.T) {
 						t.Errorf("\ngot %#v\nwant instructions[1].Address = %s", dr, pc)
 					}
 
+					if len(dr.Body.Instructions) == 3 {
+						// Request the instruction that the program is stopped at using a
+						// byte offset from the address of the previous instruction.
+						prev := dr.Body.Instructions[0].Address
+						prevAddr, _ := strconv.ParseUint(prev, 0, 64)
+						pcAddr, _ := strconv.ParseUint(pc, 0, 64)
+						client.DisassembleOffsetRequest(prev, int(pcAddr-prevAddr), 0, 1)
+						dr = client.ExpectDisassembleResponse(t)
+						if len(dr.Body.Instructions) != 1 || dr.Body.Instructions[0].Address != pc {
+							t.Errorf("\ngot %#v\nwant instructions[0].Address = %s", dr, pc)
+						}
+
+						// A byte offset in the middle of an instruction selects the
+						// instruction containing it.
+						client.DisassembleOffsetRequest(prev, 1, 1, 1)
+						dr = client.ExpectDisassembleResponse(t)
+						if len(dr.Body.Instructions) != 1 || dr.Body.Instructions[0].Address != pc {
+							t.Errorf("\ngot %#v\nwant instructions[0].Address = %s", dr, pc)
+						}
+					}
+
 					// Request zero instrutions.
 					client.DisassembleRequest(pc, 0, 0)
 					dr = client.ExpectDisassembleResponse(t)
@@ -6978,6 +7478,206 @@ func TestDisassemble(t *testing.T) {
 	})
 }
 
+func TestReadWriteMemory(t *testing.T) {
+	runTest(t, "testvariables", func(client *daptest.Client, fixture protest.Fixture) {
+		client.InitializeRequestWithArgs(dap.InitializeRequestArguments{
+			AdapterID:                "go",
+			PathFormat:               "path",
+			LinesStartAt1:            true,
+			ColumnsStartAt1:          true,
+			SupportsVariableType:     true,
+			SupportsMemoryReferences: true,
+		})
+		client.ExpectInitializeResponseAndCapabilities(t)
+
+		client.LaunchRequest("exec", fixture.Path, !stopOnEntry)
+		client.ExpectInitializedEvent(t)
+		client.ExpectLaunchResponse(t)
+
+		// Breakpoints are set within the program
+		client.ConfigurationDoneRequest()
+		client.ExpectConfigurationDoneResponse(t)
+		client.ExpectStoppedEvent(t)
+
+		client.StackTraceRequest(1, 0, 20)
+		client.ExpectStackTraceResponse(t)
+
+		// Pointers have a memory reference to the value they point to.
+		client.EvaluateRequest("a7", 1000, "repl")
+		ev := client.ExpectEvaluateResponse(t)
+		if ev.Body.MemoryReference == "" {
+			t.Fatalf("\ngot  %#v\nwant MemoryReference != \"\"", ev)
+		}
+		a7ref := ev.Body.MemoryReference
+
+		// a7.Baz is 5
+		client.ReadMemoryRequest(a7ref, 0, 8)
+		rm := client.ExpectReadMemoryResponse(t)
+		data, err := base64.StdEncoding.DecodeString(rm.Body.Data)
+		if err != nil || len(data) != 8 || rm.Body.Address != a7ref || rm.Body.UnreadableBytes != 0 {
+			t.Errorf("\ngot  %#v\nwant Address=%s UnreadableBytes=0 len(Data)=8", rm, a7ref)
+		} else if binary.LittleEndian.Uint64(data) != 5 {
+			t.Errorf("got a7.Baz = %d, want 5", binary.LittleEndian.Uint64(data))
+		}
+
+		// Slices have a memory reference to their backing array.
+		client.EvaluateRequest("a5", 1000, "repl")
+		ev = client.ExpectEvaluateResponse(t)
+		if ev.Body.MemoryReference == "" {
+			t.Fatalf("\ngot  %#v\nwant MemoryReference != \"\"", ev)
+		}
+		client.ReadMemoryRequest(ev.Body.MemoryReference, 8, 16)
+		rm = client.ExpectReadMemoryResponse(t)
+		data, _ = base64.StdEncoding.DecodeString(rm.Body.Data)
+		if len(data) != 16 || binary.LittleEndian.Uint64(data) != 2 || binary.LittleEndian.Uint64(data[8:]) != 3 {
+			t.Errorf("\ngot  %#v\nwant a5[1:3] = [2 3]", rm)
+		}
+
+		// Scalars don't have a memory reference.
+		client.EvaluateRequest("a2", 1000, "repl")
+		ev = client.ExpectEvaluateResponse(t)
+		if ev.Body.MemoryReference != "" {
+			t.Errorf("\ngot  %#v\nwant MemoryReference = \"\"", ev)
+		}
+
+		// Unreadable memory.
+		client.ReadMemoryRequest("0x0", 0, 16)
+		rm = client.ExpectReadMemoryResponse(t)
+		if rm.Body.Data != "" || rm.Body.UnreadableBytes != 16 {
+			t.Errorf("\ngot  %#v\nwant Data=\"\" UnreadableBytes=16", rm)
+		}
+
+		client.ReadMemoryRequest("invalid", 0, 16)
+		client.ExpectErrorResponseWith(t, UnableToReadMemory, "Unable to read memory: invalid memory reference \"invalid\"", false)
+
+		// Write a7.Baz
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, 9)
+		client.WriteMemoryRequest(a7ref, 0, false, base64.StdEncoding.EncodeToString(buf))
+		wm := client.ExpectWriteMemoryResponse(t)
+		if wm.Body.BytesWritten != 8 {
+			t.Errorf("\ngot  %#v\nwant BytesWritten=8", wm)
+		}
+		client.ExpectInvalidatedEvent(t)
+
+		client.EvaluateRequest("a7.Baz", 1000, "repl")
+		ev = client.ExpectEvaluateResponse(t)
+		if ev.Body.Result != "9" {
+			t.Errorf("\ngot  %#v\nwant Result=\"9\"", ev)
+		}
+
+		client.WriteMemoryRequest(a7ref, 0, false, "not base64!")
+		client.ExpectErrorResponseWith(t, UnableToWriteMemory, "Unable to write memory: invalid data: .*", false)
+
+		client.DisconnectRequestWithKillOption(true)
+		client.ExpectOutputEventDetachingKill(t)
+		client.ExpectDisconnectResponse(t)
+		client.ExpectTerminatedEvent(t)
+	})
+}
+
+// TestProgressEvents tests that building and loading the debug information
+// of the program are reported with progress events to clients that support
+// them.
+func TestProgressEvents(t *testing.T) {
+	runTest(t, "increment", func(client *daptest.Client, fixture protest.Fixture) {
+		client.InitializeRequestWithArgs(dap.InitializeRequestArguments{
+			AdapterID:                 "go",
+			PathFormat:                "path",
+			LinesStartAt1:             true,
+			ColumnsStartAt1:           true,
+			SupportsProgressReporting: true,
+		})
+		client.ExpectInitializeResponseAndCapabilities(t)
+
+		expectProgress := func(title string) {
+			t.Helper()
+			start := client.ExpectProgressStartEvent(t)
+			if start.Body.Title != title || start.Body.ProgressId == "" {
+				t.Errorf("got %#v, want Title=%q and a ProgressId", start, title)
+			}
+			end := client.ExpectProgressEndEvent(t)
+			if end.Body.ProgressId != start.Body.ProgressId {
+				t.Errorf("got %#v, want ProgressId=%q", end, start.Body.ProgressId)
+			}
+		}
+
+		client.LaunchRequestWithArgs(map[string]interface{}{"mode": "debug", "program": fixture.Source})
+		expectProgress("Building")
+		expectProgress("Loading debug information")
+		client.ExpectInitializedEvent(t)
+		client.ExpectLaunchResponse(t)
+
+		client.DisconnectRequestWithKillOption(true)
+		client.ExpectOutputEventDetachingKill(t)
+		client.ExpectDisconnectResponse(t)
+		client.ExpectTerminatedEvent(t)
+	})
+}
+
+// TestGoroutinesProgress tests that loading the goroutines of a threads
+// request in more than one page is reported with progress events.
+func TestGoroutinesProgress(t *testing.T) {
+	runTest(t, "goroutinestackprog", func(client *daptest.Client, fixture protest.Fixture) {
+		client.InitializeRequestWithArgs(dap.InitializeRequestArguments{
+			AdapterID:                 "go",
+			PathFormat:                "path",
+			LinesStartAt1:             true,
+			ColumnsStartAt1:           true,
+			SupportsProgressReporting: true,
+		})
+		client.ExpectInitializeResponseAndCapabilities(t)
+		client.LaunchRequestWithArgs(map[string]interface{}{
+			"mode":                 "exec",
+			"program":              fixture.Path,
+			"hideSystemGoroutines": true,
+			"maxGoroutines":        2,
+		})
+		client.ExpectProgressStartEvent(t)
+		client.ExpectProgressEndEvent(t)
+		client.ExpectInitializedEvent(t)
+		client.ExpectLaunchResponse(t)
+		client.SetFunctionBreakpointsRequest([]dap.FunctionBreakpoint{{Name: "main.stacktraceme"}})
+		client.ExpectSetFunctionBreakpointsResponse(t)
+		client.ConfigurationDoneRequest()
+		client.ExpectConfigurationDoneResponse(t)
+		client.ExpectStoppedEvent(t)
+
+		// The main goroutine is followed by system goroutines, more than one
+		// page of goroutines is needed to find another user goroutine.
+		client.ThreadsRequest()
+		start := client.ExpectProgressStartEvent(t)
+		if start.Body.Title != "Loading goroutines" {
+			t.Errorf("got %#v, want Title=%q", start, "Loading goroutines")
+		}
+		prev := 0
+		for {
+			m := client.ExpectMessage(t)
+			if _, isEnd := m.(*dap.ProgressEndEvent); isEnd {
+				break
+			}
+			update, ok := m.(*dap.ProgressUpdateEvent)
+			if !ok {
+				t.Fatalf("got %#v, want progressUpdate or progressEnd", m)
+			}
+			if update.Body.Percentage <= prev || update.Body.Percentage > 100 {
+				t.Errorf("got %#v, want Percentage in (%d, 100]", update, prev)
+			}
+			prev = update.Body.Percentage
+		}
+		if prev == 0 {
+			t.Errorf("no progress update received")
+		}
+		client.ExpectOutputEvent(t)
+		client.ExpectThreadsResponse(t)
+
+		client.DisconnectRequestWithKillOption(true)
+		client.ExpectOutputEventDetachingKill(t)
+		client.ExpectDisconnectResponse(t)
+		client.ExpectTerminatedEvent(t)
+	})
+}
+
 func TestAlignPCs(t *testing.T) {
 	NUM_FUNCS := 10
 	// Create fake functions to test align PCs.
@@ -166,6 +166,19 @@ type LaunchAttachCommonConfig struct {
 	// should be hidden from the call stack view.
 	HideSystemGoroutines bool `json:"hideSystemGoroutines,omitempty"`
 
+	// Boolean value to indicate whether goroutines that are only executing
+	// runtime code should be hidden from the call stack view.
+	HideGoroutinesWithoutUserFrames bool `json:"hideGoroutinesWithoutUserFrames,omitempty"`
+
+	// Boolean value to indicate whether the frames of the runtime should
+	// be collapsed in the call stack view of user goroutines.
+	HideRuntimeFrames bool `json:"hideRuntimeFrames,omitempty"`
+
+	// Maximum number of goroutines shown in the call stack view.
+	// The goroutines beyond the limit are represented by a single
+	// "N more goroutines" element. If zero, the default of 1024 is used.
+	MaxGoroutines int `json:"maxGoroutines,omitempty"`
+
 	// String value to indicate which system goroutines should be
 	// shown in the call stack view. See filtering documentation:
 	// https://github.com/go-delve/delve/blob/master/Documentation/cli/README.md#goroutines
@@ -20,12 +20,14 @@ import (
 	"github.com/go-delve/delve/pkg/dwarf/op"
 	"github.com/go-delve/delve/pkg/gobuild"
 	"github.com/go-delve/delve/pkg/goversion"
+	"github.com/go-delve/delve/pkg/heapgraph"
 	"github.com/go-delve/delve/pkg/locspec"
 	"github.com/go-delve/delve/pkg/logflags"
 	"github.com/go-delve/delve/pkg/proc"
 	"github.com/go-delve/delve/pkg/proc/core"
 	"github.com/go-delve/delve/pkg/proc/gdbserial"
 	"github.com/go-delve/delve/pkg/proc/native"
+	"github.com/go-delve/delve/pkg/symindex"
 	"github.com/go-delve/delve/service/api"
 	"github.com/sirupsen/logrus"
 )
@@ -59,9 +61,16 @@ type Debugger struct {
 	config *Config
 	// arguments to launch a new process.
 	processArgs []string
+	// environment of the launched process, nil to use ours.
+	processEnv []string
 
 	targetMutex sync.Mutex
 	target      *proc.Target
+	// targets contains all the processes being debugged, including target
+	// which is the one currently selected, after the debugger attached to
+	// the children of the first one (see FollowExec). It is nil while
+	// there is a single process.
+	targets []*proc.Target
 
 	log *logrus.Entry
 
@@ -76,8 +85,36 @@ type Debugger struct {
 	// so lower layers like proc doesn't need to deal
 	// with them
 	disabledBreakpoints map[int]*api.Breakpoint
+	// pendingWatchpoints contains the watchpoints that will be recreated
+	// when the function where they were created is called again after a
+	// restart, see rearmWatchpoint.
+	pendingWatchpoints map[int]*api.Breakpoint
 
 	breakpointIDCounter int
+	// scopedBreakpoints maps the IDs of the breakpoints restricted to a
+	// single target to the pid of that target, see api.Breakpoint.TargetPid.
+	scopedBreakpoints map[int]int
+
+	// stopCount is incremented every time the target is resumed.
+	stopCount uint64
+	// displays contains the previous values of display expressions, see
+	// EvalDisplay.
+	displays map[evalKey]*displayValue
+	// stopCache caches the results of queries while the target is stopped,
+	// see currentStopCache.
+	stopCache stopCache
+
+	subscribersMu    sync.Mutex
+	subscribers      map[int]func(*api.Event)
+	subscriberIDNext int
+
+	// watchStop stops watching the source files, see Config.Watch.
+	watchStop chan struct{}
+
+	// pty is the pseudo-terminal of the target, see Config.PTY. It is
+	// allocated by the first launch and reused when the target is
+	// restarted.
+	pty *targetPTY
 }
 
 type ExecuteKind int
@@ -125,12 +162,24 @@ type Config struct {
 	// TTY for that process.
 	TTY string
 
+	// PTY, if true, allocates a pseudo-terminal for the target process and
+	// uses it as its controlling terminal. The output of the target can be
+	// read with SubscribeTTY and its input written with WriteTTY. Only
+	// supported by the native and lldb backends.
+	PTY bool
+
 	// Packages contains the packages that we are debugging.
 	Packages []string
 
 	// BuildFlags contains the flags passed to the compiler.
 	BuildFlags string
 
+	// BuildCommand and BuildOutput, if BuildCommand is not empty, are the
+	// command used to rebuild the program instead of 'go build' and the
+	// path of the executable it produces, see gobuild.CustomBuild.
+	BuildCommand string
+	BuildOutput  string
+
 	// ExecuteKind contains the kind of the executed program.
 	ExecuteKind ExecuteKind
 
@@ -139,6 +188,29 @@ type Config struct {
 
 	// DisableASLR disables ASLR
 	DisableASLR bool
+
+	// FollowExec, if true, attaches to the children of the target process
+	// that fork, or execute a program, matching FollowExecRegex (any program if it is
+	// empty) and not matching FollowExecExclude, see Debugger.FollowExec.
+	FollowExec        bool
+	FollowExecRegex   string
+	FollowExecExclude string
+
+	// DetachOnExec, if true, detaches from the target process when it
+	// executes a new program. Otherwise the new program is debugged, at the
+	// instruction level if it has no debug info (for example because it
+	// isn't a Go program).
+	DetachOnExec bool
+
+	// NonStop, if true, enables non-stop mode: when a thread hits a
+	// breakpoint the other threads of the target process keep running, see
+	// proc.(*Target).SetNonStop.
+	NonStop bool
+
+	// Watch, if true, rebuilds and restarts the target when the source
+	// files of Packages change. Only valid if ExecuteKind is
+	// ExecutingGeneratedFile or ExecutingGeneratedTest.
+	Watch bool
 }
 
 // New creates a new Debugger. ProcessArgs specify the commandline arguments for the
@@ -166,6 +238,7 @@ func New(config *Config, processArgs []string) (*Debugger, error) {
 			return nil, attachErrorMessage(d.config.AttachPid, err)
 		}
 		d.target = p
+		d.warnSymbolsOnly()
 
 	case d.config.CoreFile != "":
 		var p *proc.Target
@@ -183,6 +256,7 @@ func New(config *Config, processArgs []string) (*Debugger, error) {
 			return nil, err
 		}
 		d.target = p
+		d.warnSymbolsOnly()
 		if err := d.checkGoVersion(); err != nil {
 			d.target.Detach(true)
 			return nil, err
@@ -190,7 +264,7 @@ func New(config *Config, processArgs []string) (*Debugger, error) {
 
 	default:
 		d.log.Infof("launching process with args: %v", d.processArgs)
-		p, err := d.Launch(d.processArgs, d.config.WorkingDir)
+		p, err := d.Launch(d.processArgs, d.config.WorkingDir, d.processEnv)
 		if err != nil {
 			if _, ok := err.(*proc.ErrUnsupportedArch); !ok {
 				err = go11DecodeErrorCheck(err)
@@ -202,6 +276,7 @@ func New(config *Config, processArgs []string) (*Debugger, error) {
 		if p != nil {
 			// if p == nil and err == nil then we are doing a recording, don't touch d.target
 			d.target = p
+			d.warnSymbolsOnly()
 		}
 		if err := d.checkGoVersion(); err != nil {
 			d.target.Detach(true)
@@ -209,11 +284,53 @@ func New(config *Config, processArgs []string) (*Debugger, error) {
 		}
 	}
 
+	if d.config.FollowExec && d.target != nil {
+		if err := d.target.FollowExec(true, d.config.FollowExecRegex, d.config.FollowExecExclude); err != nil {
+			d.target.Detach(d.config.AttachPid == 0)
+			return nil, err
+		}
+	}
+
+	if d.config.NonStop && d.target != nil {
+		if err := d.target.SetNonStop(true); err != nil {
+			d.target.Detach(d.config.AttachPid == 0)
+			return nil, err
+		}
+	}
+
 	d.disabledBreakpoints = make(map[int]*api.Breakpoint)
+	d.scopedBreakpoints = make(map[int]int)
+
+	if d.config.Watch {
+		if d.config.ExecuteKind != ExecutingGeneratedFile && d.config.ExecuteKind != ExecutingGeneratedTest {
+			d.target.Detach(true)
+			return nil, errors.New("only programs built by delve can be watched")
+		}
+		snapshot, err := d.watchSnapshot()
+		if err != nil {
+			d.target.Detach(true)
+			return nil, fmt.Errorf("could not list source files to watch: %v", err)
+		}
+		d.watchStop = make(chan struct{})
+		go d.watch(snapshot, d.watchStop)
+	}
 
 	return d, nil
 }
 
+// build builds the program being debugged, writing the executable at
+// debugname, the same way it was built when the debugger started.
+func (d *Debugger) build(debugname string) error {
+	switch {
+	case d.config.BuildCommand != "":
+		return gobuild.CustomBuild(debugname, d.config.Packages, d.config.BuildCommand, d.config.BuildOutput)
+	case d.config.ExecuteKind == ExecutingGeneratedTest:
+		return gobuild.GoTestBuild(debugname, d.config.Packages, d.config.BuildFlags)
+	default:
+		return gobuild.GoBuild(debugname, d.config.Packages, d.config.BuildFlags)
+	}
+}
+
 // canRestart returns true if the target was started with Launch and can be restarted
 func (d *Debugger) canRestart() bool {
 	switch {
@@ -221,6 +338,8 @@ func (d *Debugger) canRestart() bool {
 		return false
 	case d.config.CoreFile != "":
 		return false
+	case strings.HasPrefix(d.config.Backend, qemuConnectPrefix):
+		return false
 	default:
 		return true
 	}
@@ -238,6 +357,14 @@ func (d *Debugger) checkGoVersion() error {
 	return goversion.Compatible(producer, !d.config.CheckGoVersion)
 }
 
+// warnSymbolsOnly warns the user if the target was built without DWARF
+// debug info and was loaded using the Go symbol table.
+func (d *Debugger) warnSymbolsOnly() {
+	if d.target.BinInfo().Images[0].SymbolsOnly() {
+		logflags.WriteError("WARNING: the target was built without debug info (-ldflags=-w), functions and line numbers were loaded from the Go symbol table, variables can not be inspected")
+	}
+}
+
 func (d *Debugger) TargetGoVersion() string {
 	d.targetMutex.Lock()
 	defer d.targetMutex.Unlock()
@@ -245,7 +372,7 @@ func (d *Debugger) TargetGoVersion() string {
 }
 
 // Launch will start a process with the given args and working directory.
-func (d *Debugger) Launch(processArgs []string, wd string) (*proc.Target, error) {
+func (d *Debugger) Launch(processArgs []string, wd string, env []string) (*proc.Target, error) {
 	if err := verifyBinaryFormat(processArgs[0]); err != nil {
 		return nil, err
 	}
@@ -258,18 +385,35 @@ func (d *Debugger) Launch(processArgs []string, wd string) (*proc.Target, error)
 		launchFlags |= proc.LaunchDisableASLR
 	}
 
+	tty := d.config.TTY
+	if d.config.PTY {
+		switch d.backendName() {
+		case "native", "lldb":
+		default:
+			return nil, fmt.Errorf("pseudo-terminals are not supported by backend %q", d.config.Backend)
+		}
+		if d.pty == nil {
+			p, err := openTargetPTY()
+			if err != nil {
+				return nil, fmt.Errorf("could not allocate pseudo-terminal: %v", err)
+			}
+			d.pty = p
+		}
+		tty = d.pty.name()
+	}
+
 	switch d.config.Backend {
 	case "native":
-		return native.Launch(processArgs, wd, launchFlags, d.config.DebugInfoDirectories, d.config.TTY, d.config.Redirects)
+		return native.Launch(processArgs, wd, env, launchFlags, d.config.DebugInfoDirectories, tty, d.config.Redirects)
 	case "lldb":
-		return betterGdbserialLaunchError(gdbserial.LLDBLaunch(processArgs, wd, launchFlags, d.config.DebugInfoDirectories, d.config.TTY, d.config.Redirects))
+		return betterGdbserialLaunchError(gdbserial.LLDBLaunch(processArgs, wd, env, launchFlags, d.config.DebugInfoDirectories, tty, d.config.Redirects))
 	case "rr":
 		if d.target != nil {
 			// restart should not call us if the backend is 'rr'
 			panic("internal error: call to Launch with rr backend and target already exists")
 		}
 
-		run, stop, err := gdbserial.RecordAsync(processArgs, wd, false, d.config.Redirects)
+		run, stop, err := gdbserial.RecordAsync(processArgs, wd, env, false, d.config.Redirects)
 		if err != nil {
 			return nil, err
 		}
@@ -302,16 +446,31 @@ func (d *Debugger) Launch(processArgs []string, wd string) (*proc.Target, error)
 		}()
 		return nil, nil
 
+	case "qemu":
+		return gdbserial.QemuLaunch(processArgs, wd, env, d.config.DebugInfoDirectories, d.config.Redirects)
+
 	case "default":
 		if runtime.GOOS == "darwin" {
-			return betterGdbserialLaunchError(gdbserial.LLDBLaunch(processArgs, wd, launchFlags, d.config.DebugInfoDirectories, d.config.TTY, d.config.Redirects))
+			return betterGdbserialLaunchError(gdbserial.LLDBLaunch(processArgs, wd, env, launchFlags, d.config.DebugInfoDirectories, tty, d.config.Redirects))
 		}
-		return native.Launch(processArgs, wd, launchFlags, d.config.DebugInfoDirectories, d.config.TTY, d.config.Redirects)
+		return native.Launch(processArgs, wd, env, launchFlags, d.config.DebugInfoDirectories, tty, d.config.Redirects)
 	default:
+		if strings.HasPrefix(d.config.Backend, qemuConnectPrefix) {
+			if d.target != nil {
+				// restart should not call us if the target is an already running qemu
+				panic("internal error: call to Launch with qemu:<addr> backend and target already exists")
+			}
+			return gdbserial.QemuConnect(d.config.Backend[len(qemuConnectPrefix):], processArgs[0], d.config.DebugInfoDirectories)
+		}
 		return nil, fmt.Errorf("unknown backend %q", d.config.Backend)
 	}
 }
 
+// qemuConnectPrefix is the prefix of the backend used to connect to an
+// instance of qemu-user that is already running the target, the address of
+// its gdbstub follows the prefix.
+const qemuConnectPrefix = "qemu:"
+
 func (d *Debugger) recordingStart(stop func() error) {
 	d.recordMutex.Lock()
 	d.stopRecording = stop
@@ -433,6 +592,13 @@ func (d *Debugger) Detach(kill bool) error {
 	d.log.Debug("detaching")
 	d.targetMutex.Lock()
 	defer d.targetMutex.Unlock()
+	if d.watchStop != nil {
+		close(d.watchStop)
+		d.watchStop = nil
+	}
+	if d.pty != nil {
+		defer d.pty.close()
+	}
 	if ok, _ := d.target.Valid(); !ok {
 		return nil
 	}
@@ -443,7 +609,15 @@ func (d *Debugger) detach(kill bool) error {
 	if d.config.AttachPid == 0 {
 		kill = true
 	}
-	return d.target.Detach(kill)
+	// detach from the children first, killing the first process could also
+	// kill them.
+	group := d.targetGroup()
+	for i := len(group) - 1; i > 0; i-- {
+		if err := group[i].Detach(kill); err != nil {
+			return err
+		}
+	}
+	return group[0].Detach(kill)
 }
 
 // Restart will restart the target process, first killing
@@ -451,11 +625,33 @@ func (d *Debugger) detach(kill bool) error {
 // If the target process is a recording it will restart it from the given
 // position. If pos starts with 'c' it's a checkpoint ID, otherwise it's an
 // event number. If resetArgs is true, newArgs will replace the process args.
-func (d *Debugger) Restart(rerecord bool, pos string, resetArgs bool, newArgs []string, newRedirects [3]string, rebuild bool) ([]api.DiscardedBreakpoint, error) {
+// The variables in newEnv, in the form KEY=VALUE, are added to the
+// environment of the process (a KEY without '=' removes the variable) and
+// newWd, if not empty, replaces its working directory; both changes also
+// apply to the following restarts.
+func (d *Debugger) Restart(rerecord bool, pos string, resetArgs bool, newArgs []string, newRedirects [3]string, rebuild bool, newEnv []string, newWd string) ([]api.DiscardedBreakpoint, error) {
 	d.targetMutex.Lock()
 	defer d.targetMutex.Unlock()
 
+	d.stopCount++
+
 	recorded, _ := d.target.Recorded()
+	if (len(newEnv) > 0 || newWd != "") && (pos != "" || (recorded && !rerecord)) {
+		return nil, errors.New("can not change the environment or working directory of a recording without re-recording it")
+	}
+	for _, kv := range newEnv {
+		if kv == "" || kv[0] == '=' {
+			return nil, fmt.Errorf("malformed environment variable %q", kv)
+		}
+	}
+	if newWd != "" {
+		if fi, err := os.Stat(newWd); err != nil {
+			return nil, err
+		} else if !fi.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", newWd)
+		}
+	}
+
 	if recorded && !rerecord {
 		d.target.ResumeNotify(nil)
 		return nil, d.target.Restart(pos)
@@ -469,31 +665,45 @@ func (d *Debugger) Restart(rerecord bool, pos string, resetArgs bool, newArgs []
 		return nil, ErrCanNotRestart
 	}
 
+	d.target = d.targetGroup()[0]
 	if valid, _ := d.target.Valid(); valid && !recorded {
 		// Ensure the process is in a PTRACE_STOP.
 		if err := stopProcess(d.target.Pid()); err != nil {
 			return nil, err
 		}
 	}
+	var checkpoints []proc.Checkpoint
+	var bookmarks []proc.Bookmark
+	if recorded {
+		checkpoints, _ = d.target.Checkpoints()
+		bookmarks = d.target.Bookmarks()
+	}
+	pending := d.pendingWatchpoints
+	d.pendingWatchpoints = nil
 	if err := d.detach(true); err != nil {
 		return nil, err
 	}
+	d.targets = nil
+	// the previous values of display expressions belong to the old process
+	d.displays = nil
 	if resetArgs {
 		d.processArgs = append([]string{d.processArgs[0]}, newArgs...)
 		d.config.Redirects = newRedirects
 	}
+	env := d.processEnv
+	if len(newEnv) > 0 {
+		env = mergeEnv(env, newEnv)
+	}
+	if newWd != "" {
+		d.config.WorkingDir = newWd
+	}
 	var p *proc.Target
 	var err error
 
 	if rebuild {
 		switch d.config.ExecuteKind {
-		case ExecutingGeneratedFile:
-			err = gobuild.GoBuild(d.processArgs[0], d.config.Packages, d.config.BuildFlags)
-			if err != nil {
-				return nil, fmt.Errorf("could not rebuild process: %s", err)
-			}
-		case ExecutingGeneratedTest:
-			err = gobuild.GoTestBuild(d.processArgs[0], d.config.Packages, d.config.BuildFlags)
+		case ExecutingGeneratedFile, ExecutingGeneratedTest:
+			err = d.build(d.processArgs[0])
 			if err != nil {
 				return nil, fmt.Errorf("could not rebuild process: %s", err)
 			}
@@ -504,7 +714,7 @@ func (d *Debugger) Restart(rerecord bool, pos string, resetArgs bool, newArgs []
 	}
 
 	if recorded {
-		run, stop, err2 := gdbserial.RecordAsync(d.processArgs, d.config.WorkingDir, false, d.config.Redirects)
+		run, stop, err2 := gdbserial.RecordAsync(d.processArgs, d.config.WorkingDir, env, false, d.config.Redirects)
 		if err2 != nil {
 			return nil, err2
 		}
@@ -512,16 +722,41 @@ func (d *Debugger) Restart(rerecord bool, pos string, resetArgs bool, newArgs []
 		d.recordingStart(stop)
 		p, err = d.recordingRun(run)
 		d.recordingDone()
+		if err == nil {
+			d.restoreCheckpoints(p, checkpoints, bookmarks)
+		}
 	} else {
-		p, err = d.Launch(d.processArgs, d.config.WorkingDir)
+		p, err = d.Launch(d.processArgs, d.config.WorkingDir, env)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("could not launch process: %s", err)
 	}
+	d.processEnv = env
+	if d.config.FollowExec {
+		if err := p.FollowExec(true, d.config.FollowExecRegex, d.config.FollowExecExclude); err != nil {
+			return nil, err
+		}
+	}
+	if d.config.NonStop {
+		if err := p.SetNonStop(true); err != nil {
+			return nil, err
+		}
+	}
 
 	discarded := []api.DiscardedBreakpoint{}
 	breakpoints := api.ConvertBreakpoints(d.breakpoints())
+	oldPid := d.target.Pid()
 	d.target = p
+	for id, pid := range d.scopedBreakpoints {
+		if pid == oldPid {
+			d.scopedBreakpoints[id] = p.Pid()
+		} else {
+			delete(d.scopedBreakpoints, id)
+		}
+	}
+	for _, bp := range pending {
+		breakpoints = append(breakpoints, bp)
+	}
 	maxID := 0
 	for _, oldBp := range breakpoints {
 		if oldBp.ID < 0 {
@@ -531,14 +766,16 @@ func (d *Debugger) Restart(rerecord bool, pos string, resetArgs bool, newArgs []
 			maxID = oldBp.ID
 		}
 		if oldBp.WatchExpr != "" {
-			discarded = append(discarded, api.DiscardedBreakpoint{Breakpoint: oldBp, Reason: "can not recreate watchpoints on restart"})
+			if err := d.rearmWatchpoint(p, oldBp); err != nil {
+				discarded = append(discarded, api.DiscardedBreakpoint{Breakpoint: oldBp, Reason: err.Error()})
+			}
 		} else if len(oldBp.File) > 0 {
 			addrs, err := proc.FindFileLocation(p, oldBp.File, oldBp.Line)
 			if err != nil {
 				discarded = append(discarded, api.DiscardedBreakpoint{Breakpoint: oldBp, Reason: err.Error()})
 				continue
 			}
-			createLogicalBreakpoint(d, addrs, oldBp, oldBp.ID)
+			createLogicalBreakpoint(d, p, addrs, oldBp, oldBp.ID)
 		} else {
 			// Avoid setting a breakpoint based on address when rebuilding
 			if rebuild {
@@ -560,9 +797,111 @@ func (d *Debugger) Restart(rerecord bool, pos string, resetArgs bool, newArgs []
 		}
 	}
 	d.breakpointIDCounter = maxID
+	d.notify(&api.Event{Kind: api.EventTargetAttached, Pid: p.Pid()})
 	return discarded, nil
 }
 
+// mergeEnv returns a copy of env (os.Environ if env is nil) with the
+// changes in newEnv applied: each KEY=VALUE entry replaces the variable KEY
+// and each KEY entry without '=' removes it.
+func mergeEnv(env, newEnv []string) []string {
+	if env == nil {
+		env = os.Environ()
+	}
+	key := func(kv string) string {
+		if i := strings.Index(kv, "="); i >= 0 {
+			kv = kv[:i]
+		}
+		if runtime.GOOS == "windows" {
+			kv = strings.ToUpper(kv)
+		}
+		return kv
+	}
+	r := make([]string, 0, len(env)+len(newEnv))
+	for _, kv := range env {
+		found := false
+		for _, nkv := range newEnv {
+			if key(nkv) == key(kv) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			r = append(r, kv)
+		}
+	}
+	for _, nkv := range newEnv {
+		if strings.Contains(nkv, "=") {
+			r = append(r, nkv)
+		}
+	}
+	return r
+}
+
+// rearmWatchpoint recreates the watchpoint oldBp in the restarted target p
+// the next time the function where it was created is called, until then
+// it is kept in pendingWatchpoints.
+func (d *Debugger) rearmWatchpoint(p *proc.Target, oldBp *api.Breakpoint) error {
+	fn := p.BinInfo().LookupFunc[oldBp.WatchFunction]
+	if fn == nil {
+		return errors.New("can not recreate watchpoint, the function where it was created does not exist")
+	}
+	bp := *oldBp
+	bp.WatchPending = true
+	bp.Addr = 0
+	bp.Addrs = nil
+	bp.HitCount = map[string]uint64{}
+	bp.TotalHitCount = 0
+	err := p.SetWatchpointOnEntry(bp.ID, fn, bp.WatchExpr, proc.WatchType(bp.WatchType), nil, func(wp *proc.Breakpoint) {
+		delete(d.pendingWatchpoints, bp.ID)
+		if err := copyBreakpointInfo(wp, &bp); err != nil {
+			d.log.Errorf("could not recreate watchpoint %d: %v", bp.ID, err)
+		}
+		d.notify(&api.Event{Kind: api.EventBreakpointChanged, Breakpoint: api.ConvertBreakpoint(wp)})
+	})
+	if err != nil {
+		return err
+	}
+	if d.pendingWatchpoints == nil {
+		d.pendingWatchpoints = make(map[int]*api.Breakpoint)
+	}
+	d.pendingWatchpoints[bp.ID] = &bp
+	return nil
+}
+
+// restoreCheckpoints recreates the checkpoints and bookmarks of the
+// previous recording in the new recording p, at the start of the same
+// events. Checkpoints get new IDs, positions that can not be reached are
+// lost.
+func (d *Debugger) restoreCheckpoints(p *proc.Target, checkpoints []proc.Checkpoint, bookmarks []proc.Bookmark) {
+	if len(checkpoints) == 0 {
+		return
+	}
+	bookmarkOf := make(map[int]string)
+	for _, bm := range bookmarks {
+		bookmarkOf[bm.Checkpoint] = bm.Name
+	}
+	for _, cp := range checkpoints {
+		event, err := proc.ParseWhenEvent(cp.When)
+		if err == nil {
+			err = p.Restart(strconv.FormatUint(event, 10))
+		}
+		if err == nil {
+			if name, ok := bookmarkOf[cp.ID]; ok {
+				_, err = p.AddBookmark(name)
+			} else {
+				_, err = p.Checkpoint(cp.Where)
+			}
+		}
+		if err != nil {
+			d.log.Warnf("could not restore checkpoint %d (%s) after re-recording: %v", cp.ID, cp.Where, err)
+		}
+	}
+	if err := p.Restart(""); err != nil {
+		d.log.Errorf("could not restart recording: %v", err)
+	}
+}
+
 // State returns the current state of the debugger.
 func (d *Debugger) State(nowait bool) (*api.DebuggerState, error) {
 	if d.IsRunning() && nowait {
@@ -596,6 +935,7 @@ func (d *Debugger) state(retLoadCfg *proc.LoadConfig) (*api.DebuggerState, error
 
 	if d.target.SelectedGoroutine() != nil {
 		goroutine = api.ConvertGoroutine(d.target, d.target.SelectedGoroutine())
+		goroutine.WaitDuration = proc.WaitDuration(d.target, d.target.SelectedGoroutine())
 	}
 
 	exited := false
@@ -604,12 +944,14 @@ func (d *Debugger) state(retLoadCfg *proc.LoadConfig) (*api.DebuggerState, error
 	}
 
 	state = &api.DebuggerState{
+		Pid:               d.target.Pid(),
 		SelectedGoroutine: goroutine,
 		Exited:            exited,
 	}
 
 	for _, thread := range d.target.ThreadList() {
 		th := api.ConvertThread(thread)
+		th.Running = d.target.ThreadRunning(thread)
 
 		th.CallReturn = thread.Common().CallReturn
 		if retLoadCfg != nil {
@@ -626,6 +968,8 @@ func (d *Debugger) state(retLoadCfg *proc.LoadConfig) (*api.DebuggerState, error
 
 	if recorded, _ := d.target.Recorded(); recorded {
 		state.When, _ = d.target.When()
+		state.Event, _ = d.target.WhenEvent()
+		state.Ticks, _ = d.target.WhenTicks()
 	}
 
 	state.WatchOutOfScope = make([]*api.Breakpoint, 0, len(d.target.Breakpoints().WatchOutOfScope))
@@ -672,6 +1016,14 @@ func (d *Debugger) CreateBreakpoint(requestedBp *api.Breakpoint) (*api.Breakpoin
 		err   error
 	)
 
+	p := d.target
+	if requestedBp.TargetPid != 0 {
+		p = d.findTarget(requestedBp.TargetPid)
+		if p == nil {
+			return nil, fmt.Errorf("no process with pid %d", requestedBp.TargetPid)
+		}
+	}
+
 	if requestedBp.Name != "" {
 		if (d.findBreakpointByName(requestedBp.Name) != nil) || (d.findDisabledBreakpointByName(requestedBp.Name) != nil) {
 			return nil, errors.New("breakpoint name already exists")
@@ -686,16 +1038,16 @@ func (d *Debugger) CreateBreakpoint(requestedBp *api.Breakpoint) (*api.Breakpoin
 		if runtime.GOOS == "windows" {
 			// Accept fileName which is case-insensitive and slash-insensitive match
 			fileNameNormalized := strings.ToLower(filepath.ToSlash(fileName))
-			for _, symFile := range d.target.BinInfo().Sources {
+			for _, symFile := range p.BinInfo().Sources {
 				if fileNameNormalized == strings.ToLower(filepath.ToSlash(symFile)) {
 					fileName = symFile
 					break
 				}
 			}
 		}
-		addrs, err = proc.FindFileLocation(d.target, fileName, requestedBp.Line)
+		addrs, err = proc.FindFileLocation(p, fileName, requestedBp.Line)
 	case len(requestedBp.FunctionName) > 0:
-		addrs, err = proc.FindFunctionLocation(d.target, requestedBp.FunctionName, requestedBp.Line)
+		addrs, err = proc.FindFunctionLocation(p, requestedBp.FunctionName, requestedBp.Line)
 	case len(requestedBp.Addrs) > 0:
 		addrs = requestedBp.Addrs
 	default:
@@ -706,23 +1058,42 @@ func (d *Debugger) CreateBreakpoint(requestedBp *api.Breakpoint) (*api.Breakpoin
 		return nil, err
 	}
 
-	createdBp, err := createLogicalBreakpoint(d, addrs, requestedBp, 0)
+	createdBp, err := createLogicalBreakpoint(d, p, addrs, requestedBp, 0)
 	if err != nil {
 		return nil, err
 	}
+	if requestedBp.TargetPid != 0 {
+		d.scopedBreakpoints[createdBp.ID] = requestedBp.TargetPid
+		createdBp.TargetPid = requestedBp.TargetPid
+	} else {
+		d.propagateBreakpoint(createdBp, p)
+	}
 	d.log.Infof("created breakpoint: %#v", createdBp)
 	return createdBp, nil
 }
 
 // createLogicalBreakpoint creates one physical breakpoint for each address
-// in addrs and associates all of them with the same logical breakpoint.
-func createLogicalBreakpoint(d *Debugger, addrs []uint64, requestedBp *api.Breakpoint, id int) (*api.Breakpoint, error) {
-	p := d.target
-
+// in addrs, in the target p, and associates all of them with the same
+// logical breakpoint.
+func createLogicalBreakpoint(d *Debugger, p *proc.Target, addrs []uint64, requestedBp *api.Breakpoint, id int) (*api.Breakpoint, error) {
 	if dbp, ok := d.disabledBreakpoints[requestedBp.ID]; ok {
 		return dbp, proc.BreakpointExistsError{File: dbp.File, Line: dbp.Line, Addr: dbp.Addr}
 	}
 
+	bps, err := setLogicalBreakpoint(d, p, addrs, requestedBp, id)
+	if err != nil {
+		return nil, err
+	}
+
+	createdBp := api.ConvertBreakpoints(bps)
+	d.notify(&api.Event{Kind: api.EventBreakpointCreated, Breakpoint: createdBp[0]})
+	return createdBp[0], nil // we created a single logical breakpoint, the slice here will always have len == 1
+}
+
+// setLogicalBreakpoint sets the physical breakpoints of
+// createLogicalBreakpoint, if one of them can not be set the ones already
+// set are cleared.
+func setLogicalBreakpoint(d *Debugger, p *proc.Target, addrs []uint64, requestedBp *api.Breakpoint, id int) ([]*proc.Breakpoint, error) {
 	bps := make([]*proc.Breakpoint, len(addrs))
 	var err error
 	for i := range addrs {
@@ -754,9 +1125,38 @@ func createLogicalBreakpoint(d *Debugger, addrs []uint64, requestedBp *api.Break
 		}
 		return nil, err
 	}
+	return bps, nil
+}
 
-	createdBp := api.ConvertBreakpoints(bps)
-	return createdBp[0], nil // we created a single logical breakpoint, the slice here will always have len == 1
+// propagateBreakpoint sets bp, a breakpoint of the target src that isn't
+// scoped to a single target, in all the other targets. The location of bp
+// is searched again in each target, by file and line or, for breakpoints
+// on the entry point of a function, by function name. Targets where it can
+// not be found are skipped.
+func (d *Debugger) propagateBreakpoint(bp *api.Breakpoint, src *proc.Target) {
+	if bp.WatchExpr != "" || bp.TraceReturn || (bp.File == "" && bp.FunctionName == "") {
+		return
+	}
+	atFunctionEntry := false
+	if bp.FunctionName != "" {
+		entry, err := proc.FindFunctionLocation(src, bp.FunctionName, 0)
+		atFunctionEntry = err == nil && len(entry) == 1 && entry[0] == bp.Addr
+	}
+	for _, p := range d.targetGroup() {
+		if p == src || len(d.findBreakpointIn(p, bp.ID)) > 0 {
+			continue
+		}
+		addrs, err := proc.FindFileLocation(p, bp.File, bp.Line)
+		if err != nil && atFunctionEntry {
+			addrs, err = proc.FindFunctionLocation(p, bp.FunctionName, 0)
+		}
+		if err == nil {
+			_, err = setLogicalBreakpoint(d, p, addrs, bp, bp.ID)
+		}
+		if err != nil {
+			d.log.Debugf("could not set breakpoint %d in process %d: %v", bp.ID, p.Pid(), err)
+		}
+	}
 }
 
 func isBreakpointExistsErr(err error) bool {
@@ -775,7 +1175,21 @@ func (d *Debugger) CreateEBPFTracepoint(fnName string) error {
 // It also enables or disables the breakpoint.
 // We can consume this function to avoid locking a goroutine.
 func (d *Debugger) amendBreakpoint(amend *api.Breakpoint) error {
-	originals := d.findBreakpoint(amend.ID)
+	if wp := d.pendingWatchpoints[amend.ID]; wp != nil {
+		if amend.Disabled {
+			return errors.New("can not disable watchpoints")
+		}
+		// applied when the watchpoint is recreated, see rearmWatchpoint
+		wp.Name, wp.Cond, wp.HitCond = amend.Name, amend.Cond, amend.HitCond
+		wp.Tracepoint, wp.Goroutine, wp.Stacktrace = amend.Tracepoint, amend.Goroutine, amend.Stacktrace
+		wp.Variables, wp.LoadArgs, wp.LoadLocals, wp.UserData = amend.Variables, amend.LoadArgs, amend.LoadLocals, amend.UserData
+		return nil
+	}
+
+	var originals []*proc.Breakpoint
+	for _, p := range d.targetGroup() {
+		originals = append(originals, d.findBreakpointIn(p, amend.ID)...)
+	}
 
 	if len(originals) > 0 && originals[0].WatchExpr != "" && amend.Disabled {
 		return errors.New("can not disable watchpoints")
@@ -785,8 +1199,18 @@ func (d *Debugger) amendBreakpoint(amend *api.Breakpoint) error {
 	if originals == nil && !disabled {
 		return fmt.Errorf("no breakpoint with ID %d", amend.ID)
 	}
+	if err := d.amendBreakpointScope(amend, disabled); err != nil {
+		return err
+	}
 	if !amend.Disabled && disabled { // enable the breakpoint
-		bp, err := d.target.SetBreakpoint(amend.ID, amend.Addr, proc.UserBreakpoint, nil)
+		p := d.target
+		if pid := d.scopedBreakpoints[amend.ID]; pid != 0 {
+			p = d.findTarget(pid)
+			if p == nil {
+				return fmt.Errorf("no process with pid %d", pid)
+			}
+		}
+		bp, err := p.SetBreakpoint(amend.ID, amend.Addr, proc.UserBreakpoint, nil)
 		if err != nil {
 			return err
 		}
@@ -803,6 +1227,9 @@ func (d *Debugger) amendBreakpoint(amend *api.Breakpoint) error {
 			}
 		}
 		delete(d.disabledBreakpoints, amend.ID)
+		if d.scopedBreakpoints[amend.ID] == 0 {
+			d.propagateBreakpoint(amend, p)
+		}
 	}
 	if amend.Disabled && !disabled { // disable the breakpoint
 		if _, err := d.clearBreakpoint(amend); err != nil {
@@ -819,13 +1246,60 @@ func (d *Debugger) amendBreakpoint(amend *api.Breakpoint) error {
 	return nil
 }
 
+// amendBreakpointScope changes the target the breakpoint amend is scoped
+// to, if amend.TargetPid is different from it. Breakpoints scoped to a
+// single target are cleared from all the other targets and breakpoints
+// that are no longer scoped are set again in all targets.
+func (d *Debugger) amendBreakpointScope(amend *api.Breakpoint, disabled bool) error {
+	if amend.TargetPid == d.scopedBreakpoints[amend.ID] {
+		return nil
+	}
+	if amend.TargetPid == 0 {
+		delete(d.scopedBreakpoints, amend.ID)
+		if !disabled && !amend.Disabled {
+			for _, p := range d.targetGroup() {
+				if bps := d.findBreakpointIn(p, amend.ID); len(bps) > 0 {
+					d.propagateBreakpoint(api.ConvertBreakpoints(bps)[0], p)
+					break
+				}
+			}
+		}
+		return nil
+	}
+	p := d.findTarget(amend.TargetPid)
+	if p == nil {
+		return fmt.Errorf("no process with pid %d", amend.TargetPid)
+	}
+	if !disabled {
+		if len(d.findBreakpointIn(p, amend.ID)) == 0 {
+			return fmt.Errorf("breakpoint %d is not set in process %d", amend.ID, amend.TargetPid)
+		}
+		for _, other := range d.targetGroup() {
+			if other == p {
+				continue
+			}
+			for _, bp := range d.findBreakpointIn(other, amend.ID) {
+				if err := other.ClearBreakpoint(bp.Addr); err != nil {
+					return fmt.Errorf("could not clear breakpoint %d from process %d: %v", amend.ID, other.Pid(), err)
+				}
+			}
+		}
+	}
+	d.scopedBreakpoints[amend.ID] = amend.TargetPid
+	return nil
+}
+
 // AmendBreakpoint will update the breakpoint with the matching ID.
 // It also enables or disables the breakpoint.
 func (d *Debugger) AmendBreakpoint(amend *api.Breakpoint) error {
 	d.targetMutex.Lock()
 	defer d.targetMutex.Unlock()
 
-	return d.amendBreakpoint(amend)
+	err := d.amendBreakpoint(amend)
+	if err == nil {
+		d.notify(&api.Event{Kind: api.EventBreakpointChanged, Breakpoint: amend})
+	}
+	return err
 }
 
 // CancelNext will clear internal breakpoints, thus cancelling the 'next',
@@ -914,21 +1388,32 @@ func parseHitCondition(hitCond string) (token.Token, int, error) {
 func (d *Debugger) ClearBreakpoint(requestedBp *api.Breakpoint) (*api.Breakpoint, error) {
 	d.targetMutex.Lock()
 	defer d.targetMutex.Unlock()
-	return d.clearBreakpoint(requestedBp)
+	bp, err := d.clearBreakpoint(requestedBp)
+	if err == nil {
+		d.notify(&api.Event{Kind: api.EventBreakpointCleared, Breakpoint: bp})
+	}
+	return bp, err
 }
 
 // clearBreakpoint clears a breakpoint, we can consume this function to avoid locking a goroutine
 func (d *Debugger) clearBreakpoint(requestedBp *api.Breakpoint) (*api.Breakpoint, error) {
 	if bp, ok := d.disabledBreakpoints[requestedBp.ID]; ok {
 		delete(d.disabledBreakpoints, bp.ID)
+		delete(d.scopedBreakpoints, bp.ID)
 		return bp, nil
 	}
+	if bp, ok := d.pendingWatchpoints[requestedBp.ID]; ok {
+		delete(d.pendingWatchpoints, bp.ID)
+		return bp, d.target.ClearWatchpointOnEntry(bp.ID)
+	}
 
 	var clearBps []*proc.Breakpoint
 
 	toclear := func(addr uint64) {
 		bp := d.target.Breakpoints().M[addr]
-		if bp != nil {
+		// when there are multiple targets the same address could belong to
+		// a different breakpoint scoped to the current target.
+		if bp != nil && (d.targets == nil || bp.LogicalID() == requestedBp.ID) {
 			clearBps = append(clearBps, bp)
 		}
 	}
@@ -944,10 +1429,29 @@ func (d *Debugger) clearBreakpoint(requestedBp *api.Breakpoint) (*api.Breakpoint
 		toclear(requestedBp.Addr)
 	}
 
+	// The breakpoint could also be set in the other targets, possibly at
+	// different addresses.
+	var otherBps []*proc.Breakpoint
+	var otherTargets []*proc.Target
+	for _, p := range d.targetGroup() {
+		if p == d.target {
+			continue
+		}
+		for _, bp := range d.findBreakpointIn(p, requestedBp.ID) {
+			otherBps = append(otherBps, bp)
+			otherTargets = append(otherTargets, p)
+		}
+	}
+
 	// Breakpoints need to be converted before clearing them or they won't have
 	// an ID anymore.
-	sort.Sort(breakpointsByLogicalID(clearBps))
-	clearedBp := api.ConvertBreakpoints(clearBps)[0]
+	var clearedBp *api.Breakpoint
+	if len(clearBps) == 0 && len(otherBps) > 0 {
+		clearedBp = api.ConvertBreakpoints(d.findBreakpointIn(otherTargets[0], requestedBp.ID))[0]
+	} else {
+		sort.Sort(breakpointsByLogicalID(clearBps))
+		clearedBp = api.ConvertBreakpoints(clearBps)[0]
+	}
 
 	var errs []error
 	for _, bp := range clearBps {
@@ -956,6 +1460,12 @@ func (d *Debugger) clearBreakpoint(requestedBp *api.Breakpoint) (*api.Breakpoint
 			errs = append(errs, fmt.Errorf("address %#x: %v", bp.Addr, err))
 		}
 	}
+	for i, bp := range otherBps {
+		if err := otherTargets[i].ClearBreakpoint(bp.Addr); err != nil {
+			errs = append(errs, fmt.Errorf("process %d address %#x: %v", otherTargets[i].Pid(), bp.Addr, err))
+		}
+	}
+	clearBps = append(clearBps, otherBps...)
 
 	if len(errs) > 0 {
 		buf := new(bytes.Buffer)
@@ -972,6 +1482,9 @@ func (d *Debugger) clearBreakpoint(requestedBp *api.Breakpoint) (*api.Breakpoint
 		return nil, fmt.Errorf("unable to clear breakpoint %d (partial): %s", requestedBp.ID, buf.String())
 	}
 
+	if !requestedBp.Disabled {
+		delete(d.scopedBreakpoints, requestedBp.ID)
+	}
 	d.log.Infof("cleared breakpoint: %#v", clearedBp)
 	return clearedBp, nil
 }
@@ -1018,20 +1531,46 @@ func (d *Debugger) Breakpoints(all bool) []*api.Breakpoint {
 			abp.VerboseDescr = bp.VerboseDescr()
 			bps = append(bps, abp)
 		}
+		// user breakpoints scoped to the other targets
+		var others []*proc.Breakpoint
+		for _, bp := range d.breakpoints() {
+			if len(d.findBreakpointIn(d.target, bp.LogicalID())) == 0 {
+				others = append(others, bp)
+			}
+		}
+		bps = append(bps, api.ConvertBreakpoints(others)...)
 	}
 
 	for _, bp := range d.disabledBreakpoints {
 		bps = append(bps, bp)
 	}
+	for _, bp := range d.pendingWatchpoints {
+		bps = append(bps, bp)
+	}
+
+	for _, bp := range bps {
+		bp.TargetPid = d.scopedBreakpoints[bp.ID]
+	}
 
 	return bps
 }
 
+// breakpoints returns the user breakpoints of all targets. Breakpoints set
+// in more than one target are only returned once, with the addresses they
+// have in the current target if they are set there.
 func (d *Debugger) breakpoints() []*proc.Breakpoint {
 	bps := []*proc.Breakpoint{}
-	for _, bp := range d.target.Breakpoints().M {
-		if bp.IsUser() {
-			bps = append(bps, bp)
+	seen := make(map[int]bool)
+	for _, p := range d.targetsCurrentFirst() {
+		found := make(map[int]bool)
+		for _, bp := range p.Breakpoints().M {
+			if bp.IsUser() && !seen[bp.LogicalID()] {
+				bps = append(bps, bp)
+				found[bp.LogicalID()] = true
+			}
+		}
+		for id := range found {
+			seen[id] = true
 		}
 	}
 	sort.Sort(breakpointsByLogicalID(bps))
@@ -1047,12 +1586,26 @@ func (d *Debugger) FindBreakpoint(id int) *api.Breakpoint {
 	if len(bps) <= 0 {
 		return nil
 	}
+	bps[0].TargetPid = d.scopedBreakpoints[id]
 	return bps[0]
 }
 
+// findBreakpoint returns the physical breakpoints of the logical breakpoint
+// id in the first target, starting from the current one, where it is set.
 func (d *Debugger) findBreakpoint(id int) []*proc.Breakpoint {
+	for _, p := range d.targetsCurrentFirst() {
+		if bps := d.findBreakpointIn(p, id); len(bps) > 0 {
+			return bps
+		}
+	}
+	return nil
+}
+
+// findBreakpointIn returns the physical breakpoints of the logical
+// breakpoint id in the target p.
+func (d *Debugger) findBreakpointIn(p *proc.Target, id int) []*proc.Breakpoint {
 	var bps []*proc.Breakpoint
-	for _, bp := range d.target.Breakpoints().M {
+	for _, bp := range p.Breakpoints().M {
 		if bp.LogicalID() == id {
 			bps = append(bps, bp)
 		}
@@ -1067,6 +1620,9 @@ func (d *Debugger) findDisabledBreakpoint(id int) []*api.Breakpoint {
 			bps = append(bps, dbp)
 		}
 	}
+	if wp := d.pendingWatchpoints[id]; wp != nil {
+		bps = append(bps, wp)
+	}
 	return bps
 }
 
@@ -1079,6 +1635,9 @@ func (d *Debugger) FindBreakpointByName(name string) *api.Breakpoint {
 	if bp == nil {
 		bp = d.findDisabledBreakpointByName(name)
 	}
+	if bp != nil {
+		bp.TargetPid = d.scopedBreakpoints[bp.ID]
+	}
 	return bp
 }
 
@@ -1103,6 +1662,11 @@ func (d *Debugger) findDisabledBreakpointByName(name string) *api.Breakpoint {
 			return dbp
 		}
 	}
+	for _, wp := range d.pendingWatchpoints {
+		if wp.Name == name {
+			return wp
+		}
+	}
 	return nil
 }
 
@@ -1120,7 +1684,9 @@ func (d *Debugger) CreateWatchpoint(goid, frame, deferredCall int, expr string,
 	if d.findBreakpointByName(expr) == nil {
 		bp.Name = expr
 	}
-	return api.ConvertBreakpoint(bp), nil
+	createdBp := api.ConvertBreakpoint(bp)
+	d.notify(&api.Event{Kind: api.EventBreakpointCreated, Breakpoint: createdBp})
+	return createdBp, nil
 }
 
 // Threads returns the threads of the target process.
@@ -1174,6 +1740,19 @@ func (d *Debugger) IsRunning() bool {
 
 // Command handles commands which control the debugger lifecycle
 func (d *Debugger) Command(command *api.DebuggerCommand, resumeNotify chan struct{}) (*api.DebuggerState, error) {
+	state, err := d.command(command, resumeNotify)
+	if err == nil && state != nil {
+		switch {
+		case state.Exited:
+			d.notify(&api.Event{Kind: api.EventExited, State: state})
+		case command.Name != api.SwitchGoroutine && command.Name != api.SwitchThread && command.Name != api.SwitchTarget && command.Name != api.Halt:
+			d.notify(&api.Event{Kind: api.EventStopped, State: state})
+		}
+	}
+	return state, err
+}
+
+func (d *Debugger) command(command *api.DebuggerCommand, resumeNotify chan struct{}) (*api.DebuggerState, error) {
 	var err error
 
 	if command.Name == api.Halt {
@@ -1201,11 +1780,16 @@ func (d *Debugger) Command(command *api.DebuggerCommand, resumeNotify chan struc
 	d.setRunning(true)
 	defer d.setRunning(false)
 
-	if command.Name != api.SwitchGoroutine && command.Name != api.SwitchThread && command.Name != api.Halt {
+	if command.Name != api.SwitchGoroutine && command.Name != api.SwitchThread && command.Name != api.SwitchTarget && command.Name != api.Halt {
 		d.target.ResumeNotify(resumeNotify)
+		d.stopCount++
+		d.notify(&api.Event{Kind: api.EventResumed})
 	} else if resumeNotify != nil {
 		close(resumeNotify)
 	}
+	// Queries about the selected goroutine return different results after
+	// switching, even though the target doesn't run.
+	d.clearStopCache()
 
 	switch command.Name {
 	case api.Continue:
@@ -1299,13 +1883,28 @@ func (d *Debugger) Command(command *api.DebuggerCommand, resumeNotify chan struc
 			err = d.target.SwitchGoroutine(g)
 		}
 		withBreakpointInfo = false
+	case api.SwitchTarget:
+		d.log.Debugf("switching to process %d", command.TargetPid)
+		if p := d.findTarget(command.TargetPid); p != nil {
+			d.target = p
+		} else {
+			err = fmt.Errorf("no process with pid %d", command.TargetPid)
+		}
+		withBreakpointInfo = false
 	case api.Halt:
 		// RequestManualStop already called
 		withBreakpointInfo = false
 	}
 
+	switch command.Name {
+	case api.Call, api.SwitchThread, api.SwitchGoroutine, api.SwitchTarget, api.Halt:
+		// not resumed or resumed only for the duration of a function call
+	default:
+		err = d.followChildren(err)
+	}
+
 	if err != nil {
-		if pe, ok := err.(proc.ErrProcessExited); ok && command.Name != api.SwitchGoroutine && command.Name != api.SwitchThread {
+		if pe, ok := err.(proc.ErrProcessExited); ok && command.Name != api.SwitchGoroutine && command.Name != api.SwitchThread && command.Name != api.SwitchTarget {
 			state := &api.DebuggerState{}
 			state.Pid = d.target.Pid()
 			state.Exited = true
@@ -1333,35 +1932,327 @@ func (d *Debugger) Command(command *api.DebuggerCommand, resumeNotify chan struc
 	}
 	if bp := state.CurrentThread.Breakpoint; bp != nil && isBpHitCondNotSatisfiable(bp) {
 		bp.Disabled = true
+		bp.TargetPid = d.scopedBreakpoints[bp.ID]
 		d.amendBreakpoint(bp)
 	}
 	return state, err
 }
 
-func (d *Debugger) collectBreakpointInformation(state *api.DebuggerState) error {
-	if state == nil {
-		return nil
-	}
-
-	for i := range state.Threads {
-		if state.Threads[i].Breakpoint == nil || state.Threads[i].BreakpointInfo != nil {
+// followChildren is called with the result of the command that resumed the
+// current target. If the target stopped because some of its children
+// executed a program they are attached and the last one is resumed, if the
+// target exited and there are other targets the one that was selected
+// before it is resumed. Only one target runs at any time: a process stays
+// stopped while its children run.
+func (d *Debugger) followChildren(err error) error {
+	for {
+		if pe, ok := err.(proc.ErrProcessExited); ok && d.targets != nil {
+			d.log.Infof("process %d exited with status %d", pe.Pid, pe.Status)
+			d.notify(&api.Event{Kind: api.EventExited, Pid: pe.Pid, State: &api.DebuggerState{Pid: pe.Pid, Exited: true, ExitStatus: pe.Status}})
+			d.removeExitedTarget()
+			err = d.target.Continue()
 			continue
 		}
+		if err == nil && d.target.StopReason == proc.StopExec {
+			return d.replaceExecTarget()
+		}
+		if err != nil || d.target.StopReason != proc.StopNewChild {
+			return err
+		}
+		var children []*proc.Target
+		children, err = d.attachNewChildren()
+		if len(children) == 0 {
+			return err
+		}
+		if err != nil {
+			d.log.Errorf("%v", err)
+		}
+		d.target = children[len(children)-1]
+		err = d.target.Continue()
+	}
+}
 
-		bp := state.Threads[i].Breakpoint
-		bpi := &api.BreakpointInfo{}
-		state.Threads[i].BreakpointInfo = bpi
+// replaceExecTarget replaces the current target, whose process executed a
+// new program, with a target for the new program. The breakpoints of the
+// old program are set again by file and line, where possible.
+// If DetachOnExec is set the process is detached instead.
+func (d *Debugger) replaceExecTarget() error {
+	old := d.target
+	bps := api.ConvertBreakpoints(d.findUserBreakpointsIn(old))
+	p, err := old.ExecTarget()
+	if err != nil {
+		return err
+	}
+	path := p.BinInfo().Images[0].Path
+	d.log.Infof("process %d executed %s", p.Pid(), path)
+	for i := range d.targets {
+		if d.targets[i] == old {
+			d.targets[i] = p
+		}
+	}
+	d.target = p
 
-		if bp.Goroutine {
-			g, err := proc.GetG(d.target.CurrentThread())
-			if err != nil {
-				return err
-			}
-			bpi.Goroutine = api.ConvertGoroutine(d.target, g)
+	if d.config.DetachOnExec {
+		if err := p.Detach(false); err != nil {
+			return err
 		}
+		return fmt.Errorf("process %d executed %s: %v", p.Pid(), path, proc.ErrProcessDetached)
+	}
 
-		if bp.Stacktrace > 0 {
-			rawlocs, err := proc.ThreadStacktrace(d.target.CurrentThread(), bp.Stacktrace)
+	if p.BinInfo().Images[0].LoadError() != nil {
+		logflags.WriteError(fmt.Sprintf("WARNING: process %d executed %s, which has no debug info, only registers and disassembly are available", p.Pid(), path))
+	}
+	if d.config.NonStop {
+		if err := p.SetNonStop(true); err != nil {
+			d.log.Errorf("could not enable non-stop mode: %v", err)
+		}
+	}
+	for _, bp := range bps {
+		if bp.ID < 0 || bp.WatchExpr != "" || bp.File == "" {
+			// breakpoints with a negative ID (unrecovered-panic, fatal-throw)
+			// are created by the new target itself
+			continue
+		}
+		addrs, err := proc.FindFileLocation(p, bp.File, bp.Line)
+		if err == nil {
+			_, err = setLogicalBreakpoint(d, p, addrs, bp, bp.ID)
+		}
+		if err != nil {
+			d.log.Debugf("could not set breakpoint %d in %s: %v", bp.ID, path, err)
+		}
+	}
+	return nil
+}
+
+// attachNewChildren adds the new children of the current target to the
+// target group. The breakpoints of the current target that aren't scoped
+// to it are also set in the children, where possible.
+func (d *Debugger) attachNewChildren() ([]*proc.Target, error) {
+	parent := d.target
+	children, err := parent.NewChildren()
+	if len(children) == 0 {
+		return nil, err
+	}
+	if d.targets == nil {
+		d.targets = []*proc.Target{parent}
+	}
+	var bps []*api.Breakpoint
+	for _, bp := range api.ConvertBreakpoints(d.findUserBreakpointsIn(parent)) {
+		if d.scopedBreakpoints[bp.ID] == 0 {
+			bps = append(bps, bp)
+		}
+	}
+	for _, child := range children {
+		d.log.Infof("attached to child process %d", child.Pid())
+		d.targets = append(d.targets, child)
+		if d.config.NonStop {
+			if err := child.SetNonStop(true); err != nil {
+				d.log.Errorf("could not enable non-stop mode for child process %d: %v", child.Pid(), err)
+			}
+		}
+		for _, bp := range bps {
+			d.propagateBreakpoint(bp, parent)
+		}
+		d.notify(&api.Event{Kind: api.EventTargetAttached, Pid: child.Pid()})
+	}
+	return children, err
+}
+
+// removeExitedTarget removes the current target, which exited, from the
+// target group and selects the target that precedes it.
+func (d *Debugger) removeExitedTarget() {
+	for i, p := range d.targets {
+		if p != d.target {
+			continue
+		}
+		d.targets = append(d.targets[:i], d.targets[i+1:]...)
+		for id, pid := range d.scopedBreakpoints {
+			if pid == p.Pid() {
+				delete(d.scopedBreakpoints, id)
+			}
+		}
+		if i > 0 {
+			i--
+		}
+		d.target = d.targets[i]
+		break
+	}
+	if len(d.targets) == 1 {
+		d.targets = nil
+	}
+}
+
+// findUserBreakpointsIn returns the user breakpoints of the target p.
+func (d *Debugger) findUserBreakpointsIn(p *proc.Target) []*proc.Breakpoint {
+	var bps []*proc.Breakpoint
+	for _, bp := range p.Breakpoints().M {
+		if bp.IsUser() {
+			bps = append(bps, bp)
+		}
+	}
+	sort.Sort(breakpointsByLogicalID(bps))
+	return bps
+}
+
+// targetGroup returns all the targets being debugged, the first one is the
+// process that was launched or attached by the debugger.
+func (d *Debugger) targetGroup() []*proc.Target {
+	if d.targets == nil {
+		return []*proc.Target{d.target}
+	}
+	return d.targets
+}
+
+// targetsCurrentFirst returns the targets being debugged, starting with
+// the current one.
+func (d *Debugger) targetsCurrentFirst() []*proc.Target {
+	r := []*proc.Target{d.target}
+	for _, p := range d.targets {
+		if p != d.target {
+			r = append(r, p)
+		}
+	}
+	return r
+}
+
+// findTarget returns the target with the given pid or nil.
+func (d *Debugger) findTarget(pid int) *proc.Target {
+	for _, p := range d.targetGroup() {
+		if p.Pid() == pid {
+			return p
+		}
+	}
+	return nil
+}
+
+// Targets returns all the processes being debugged, the first one is the
+// process that was launched or attached by the debugger.
+func (d *Debugger) Targets() []*proc.Target {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+	return append([]*proc.Target(nil), d.targetGroup()...)
+}
+
+// FollowExec enables or disables following the children of the processes
+// being debugged. While it is enabled every child that executes a program
+// matching the regular expression regex (any program if regex is empty)
+// and not matching exclude (if it isn't empty) is attached and resumed, a
+// child that forks without executing a program is matched by the program
+// of its parent. The processes being debugged can be listed with Targets
+// and selected with the SwitchTarget command.
+func (d *Debugger) FollowExec(enabled bool, regex, exclude string) error {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+	for _, p := range d.targetGroup() {
+		if err := p.FollowExec(enabled, regex, exclude); err != nil {
+			return err
+		}
+	}
+	d.config.FollowExec = enabled
+	d.config.FollowExecRegex = regex
+	d.config.FollowExecExclude = exclude
+	return nil
+}
+
+// FollowExecEnabled returns true if the debugger follows the children of
+// the processes being debugged, and the regular expressions passed to
+// FollowExec.
+func (d *Debugger) FollowExecEnabled() (enabled bool, regex, exclude string) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+	return d.config.FollowExec, d.config.FollowExecRegex, d.config.FollowExecExclude
+}
+
+// Subscribe registers fn to be called with every event of the debugger,
+// until the returned function is called. Since fn is called with the
+// target locked it must not block or call other methods of the debugger.
+func (d *Debugger) Subscribe(fn func(*api.Event)) (unsubscribe func()) {
+	d.subscribersMu.Lock()
+	defer d.subscribersMu.Unlock()
+	if d.subscribers == nil {
+		d.subscribers = make(map[int]func(*api.Event))
+	}
+	id := d.subscriberIDNext
+	d.subscriberIDNext++
+	d.subscribers[id] = fn
+	return func() {
+		d.subscribersMu.Lock()
+		delete(d.subscribers, id)
+		d.subscribersMu.Unlock()
+	}
+}
+
+// errNoPTY is returned by the methods accessing the pseudo-terminal of the
+// target when Config.PTY is not set.
+var errNoPTY = errors.New("the target process does not have a pseudo-terminal")
+
+// SubscribeTTY calls fn with the output of the target, written to its
+// pseudo-terminal (see Config.PTY), until unsubscribe is called. The
+// output written while there were no subscribers is sent to the first one.
+// The function must not block.
+func (d *Debugger) SubscribeTTY(fn func([]byte)) (unsubscribe func(), err error) {
+	if d.pty == nil {
+		return nil, errNoPTY
+	}
+	return d.pty.subscribe(fn), nil
+}
+
+// WriteTTY writes data to the pseudo-terminal of the target, as if it was
+// typed by the user.
+func (d *Debugger) WriteTTY(data []byte) error {
+	if d.pty == nil {
+		return errNoPTY
+	}
+	return d.pty.write(data)
+}
+
+// ResizeTTY changes the size of the pseudo-terminal of the target, which
+// sends SIGWINCH to its foreground process group.
+func (d *Debugger) ResizeTTY(rows, cols int) error {
+	if d.pty == nil {
+		return errNoPTY
+	}
+	return d.pty.resize(rows, cols)
+}
+
+// notify sends ev to all subscribers.
+func (d *Debugger) notify(ev *api.Event) {
+	d.subscribersMu.Lock()
+	defer d.subscribersMu.Unlock()
+	for _, fn := range d.subscribers {
+		fn(ev)
+	}
+}
+
+// breakpointInfoLoadConfig is the configuration used to load the variables
+// of breakpoints and the values of panics.
+var breakpointInfoLoadConfig = proc.LoadConfig{FollowPointers: true, MaxVariableRecurse: 1, MaxStringLen: 64, MaxArrayValues: 64, MaxStructFields: -1}
+
+func (d *Debugger) collectBreakpointInformation(state *api.DebuggerState) error {
+	if state == nil {
+		return nil
+	}
+
+	for i := range state.Threads {
+		if state.Threads[i].Breakpoint == nil || state.Threads[i].BreakpointInfo != nil {
+			continue
+		}
+
+		bp := state.Threads[i].Breakpoint
+		bpi := &api.BreakpointInfo{}
+		state.Threads[i].BreakpointInfo = bpi
+
+		if bp.Goroutine {
+			g, err := proc.GetG(d.target.CurrentThread())
+			if err != nil {
+				return err
+			}
+			bpi.Goroutine = api.ConvertGoroutine(d.target, g)
+			bpi.Goroutine.WaitDuration = proc.WaitDuration(d.target, g)
+		}
+
+		if bp.Stacktrace > 0 {
+			rawlocs, err := proc.ThreadStacktrace(d.target.CurrentThread(), bp.Stacktrace)
 			if err != nil {
 				return err
 			}
@@ -1376,12 +2267,27 @@ func (d *Debugger) collectBreakpointInformation(state *api.DebuggerState) error
 			return fmt.Errorf("could not find thread %d", state.Threads[i].ID)
 		}
 
+		if bp.Name == proc.UnrecoveredPanic {
+			if g, _ := proc.GetG(thread); g != nil {
+				panics, err := d.target.GoroutinePanics(g, breakpointInfoLoadConfig)
+				if err != nil {
+					d.log.Debugf("could not read panics of goroutine %d: %v", g.ID, err)
+				}
+				bpi.Panics = api.ConvertPanics(panics)
+			}
+		}
+
 		if len(bp.Variables) == 0 && bp.LoadArgs == nil && bp.LoadLocals == nil {
 			// don't try to create goroutine scope if there is nothing to load
 			continue
 		}
 
 		s, err := proc.GoroutineScope(d.target, thread)
+		if _, isNoG := err.(proc.ErrNoGoroutine); isNoG {
+			// The thread can be stopped outside of any goroutine, for example
+			// at the fatal throw breakpoint during a deadlock.
+			s, err = proc.ThreadScope(d.target, thread)
+		}
 		if err != nil {
 			return err
 		}
@@ -1390,7 +2296,7 @@ func (d *Debugger) collectBreakpointInformation(state *api.DebuggerState) error
 			bpi.Variables = make([]api.Variable, len(bp.Variables))
 		}
 		for i := range bp.Variables {
-			v, err := s.EvalExpression(bp.Variables[i], proc.LoadConfig{FollowPointers: true, MaxVariableRecurse: 1, MaxStringLen: 64, MaxArrayValues: 64, MaxStructFields: -1})
+			v, err := s.EvalExpression(bp.Variables[i], breakpointInfoLoadConfig)
 			if err != nil {
 				bpi.Variables[i] = api.Variable{Name: bp.Variables[i], Unreadable: fmt.Sprintf("eval error: %v", err)}
 			} else {
@@ -1422,13 +2328,7 @@ func (d *Debugger) Sources(filter string) ([]string, error) {
 		return nil, fmt.Errorf("invalid filter argument: %s", err.Error())
 	}
 
-	files := []string{}
-	for _, f := range d.target.BinInfo().Sources {
-		if regex.Match([]byte(f)) {
-			files = append(files, f)
-		}
-	}
-	return files, nil
+	return d.target.BinInfo().SourceIndex().Match(regex), nil
 }
 
 // Functions returns a list of functions in the target process.
@@ -1441,13 +2341,7 @@ func (d *Debugger) Functions(filter string) ([]string, error) {
 		return nil, fmt.Errorf("invalid filter argument: %s", err.Error())
 	}
 
-	funcs := []string{}
-	for _, f := range d.target.BinInfo().Functions {
-		if regex.MatchString(f.Name) {
-			funcs = append(funcs, f.Name)
-		}
-	}
-	return funcs, nil
+	return d.target.BinInfo().FunctionIndex().Match(regex), nil
 }
 
 // Types returns all type information in the binary.
@@ -1460,19 +2354,39 @@ func (d *Debugger) Types(filter string) ([]string, error) {
 		return nil, fmt.Errorf("invalid filter argument: %s", err.Error())
 	}
 
-	types, err := d.target.BinInfo().Types()
-	if err != nil {
-		return nil, err
-	}
+	return d.target.BinInfo().TypeIndex().Match(regex), nil
+}
 
-	r := make([]string, 0, len(types))
-	for _, typ := range types {
-		if regex.Match([]byte(typ)) {
-			r = append(r, typ)
-		}
-	}
+// FindSymbols returns the functions, types and source files whose name
+// matches query, sorted by decreasing score. The characters of query must
+// appear in the name in the same order, ignoring case, see
+// symindex.Index.Find. At most limit symbols are returned, zero means no
+// limit.
+func (d *Debugger) FindSymbols(query string, limit int) []api.Symbol {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
 
-	return r, nil
+	bi := d.target.BinInfo()
+	r := []api.Symbol{}
+	for _, ns := range []struct {
+		kind api.SymbolKind
+		idx  *symindex.Index
+	}{
+		{api.FunctionSymbol, bi.FunctionIndex()},
+		{api.TypeSymbol, bi.TypeIndex()},
+		{api.SourceSymbol, bi.SourceIndex()},
+	} {
+		for _, res := range ns.idx.Find(query, limit) {
+			r = append(r, api.Symbol{Kind: ns.kind, Name: res.Name, Score: res.Score})
+		}
+	}
+	sort.SliceStable(r, func(i, j int) bool {
+		return r[i].Score > r[j].Score
+	})
+	if limit > 0 && len(r) > limit {
+		r = r[:limit]
+	}
+	return r
 }
 
 // PackageVariables returns a list of package variables for the thread,
@@ -1578,11 +2492,118 @@ func (d *Debugger) EvalVariableInScope(goid, frame, deferredCall int, expr strin
 	d.targetMutex.Lock()
 	defer d.targetMutex.Unlock()
 
+	var s *proc.EvalScope
+	return d.evalCached(evalKey{goid, frame, deferredCall, expr, cfg}, &s)
+}
+
+// evalCached evaluates the expression identified by key, the result is
+// cached until the target runs again. If *s is nil it is set to the scope
+// of key, so that callers evaluating several expressions in the same scope
+// can convert it only once.
+func (d *Debugger) evalCached(key evalKey, s **proc.EvalScope) (*proc.Variable, error) {
+	c := d.currentStopCache()
+	r, ok := c.vars[key]
+	if !ok {
+		if *s == nil {
+			var err error
+			*s, err = proc.ConvertEvalScope(d.target, key.goid, key.frame, key.deferredCall)
+			if err != nil {
+				return nil, err
+			}
+		}
+		r.v, r.err = (*s).EvalExpression(key.expr, key.cfg)
+		c.vars[key] = r
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	// Callers can modify the variable they get, for example by renaming it.
+	v := *r.v
+	return &v, nil
+}
+
+// EvalVariablesInScope evaluates each expression of exprs in the scope of
+// the specified goroutine, frame and deferred call. The scope is computed
+// only once for all expressions.
+// Expressions that can not be evaluated are returned as unreadable
+// variables whose name is the expression itself.
+func (d *Debugger) EvalVariablesInScope(goid, frame, deferredCall int, exprs []string, cfg proc.LoadConfig) ([]*proc.Variable, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
 	s, err := proc.ConvertEvalScope(d.target, goid, frame, deferredCall)
 	if err != nil {
 		return nil, err
 	}
-	return s.EvalExpression(expr, cfg)
+	vars := make([]*proc.Variable, len(exprs))
+	for i, expr := range exprs {
+		v, err := d.evalCached(evalKey{goid, frame, deferredCall, expr, cfg}, &s)
+		if err != nil {
+			v = &proc.Variable{Name: expr, Unreadable: err}
+		}
+		vars[i] = v
+	}
+	return vars, nil
+}
+
+// evalKey identifies an expression evaluated in a scope.
+type evalKey struct {
+	goid, frame, deferredCall int
+	expr                      string
+	cfg                       proc.LoadConfig
+}
+
+// displayValue is the value of a display expression at the last two stops
+// where it was evaluated.
+type displayValue struct {
+	stop      uint64 // value of stopCount when cur was evaluated
+	prev, cur *api.Variable
+}
+
+// EvalDisplay evaluates expr like EvalVariableInScope and also returns the
+// values that changed since the evaluation of the same expression, with the
+// same scope and load configuration, at the previous stop of the target.
+// No changes are returned the first time an expression is evaluated.
+// Expressions that were not evaluated at the previous stop, for example
+// because the client removed them, are forgotten.
+func (d *Debugger) EvalDisplay(goid, frame, deferredCall int, expr string, cfg proc.LoadConfig) (*api.Variable, []api.VariableChange, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	var v *api.Variable
+	var s *proc.EvalScope
+	pv, err := d.evalCached(evalKey{goid, frame, deferredCall, expr, cfg}, &s)
+	if err == nil {
+		v = api.ConvertVar(pv)
+	}
+
+	if d.displays == nil {
+		d.displays = make(map[evalKey]*displayValue)
+	}
+	for key, dv := range d.displays {
+		if dv.stop+1 < d.stopCount {
+			delete(d.displays, key)
+		}
+	}
+	key := evalKey{goid, frame, deferredCall, expr, cfg}
+	dv := d.displays[key]
+	switch {
+	case dv == nil:
+		dv = &displayValue{stop: d.stopCount}
+		d.displays[key] = dv
+	case dv.stop != d.stopCount:
+		dv.prev = dv.cur
+		dv.stop = d.stopCount
+	}
+	dv.cur = v
+
+	if err != nil {
+		return nil, nil, err
+	}
+	if dv.prev == nil {
+		return v, nil, nil
+	}
+	return v, api.DiffVariables(expr, dv.prev, v), nil
 }
 
 // LoadResliced will attempt to 'reslice' a map, array or slice so that the values
@@ -1593,6 +2614,38 @@ func (d *Debugger) LoadResliced(v *proc.Variable, start int, cfg proc.LoadConfig
 	return v.LoadResliced(start, cfg)
 }
 
+// ListVariableChildren evaluates expr in the specified scope, it must be an
+// array, a slice or a map, and returns it with up to count of its elements
+// loaded starting from index start. The elements are loaded using cfg, the
+// length of the returned variable is the length of the whole expression.
+func (d *Debugger) ListVariableChildren(goid, frame, deferredCall int, expr string, start, count int, cfg proc.LoadConfig) (*proc.Variable, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	if start < 0 || count < 0 {
+		return nil, errors.New("negative start or count")
+	}
+	s, err := proc.ConvertEvalScope(d.target, goid, frame, deferredCall)
+	if err != nil {
+		return nil, err
+	}
+	v, err := s.EvalExpression(expr, proc.LoadConfig{})
+	if err != nil {
+		return nil, err
+	}
+	if v.Unreadable != nil {
+		return nil, v.Unreadable
+	}
+	cfg.MaxArrayValues = count
+	r, err := v.LoadResliced(start, cfg)
+	if err != nil {
+		return nil, err
+	}
+	// reslicing arrays and slices changes their length
+	r.Len, r.Cap = v.Len, v.Cap
+	return r, nil
+}
+
 // SetVariableInScope will set the value of the variable represented by
 // 'symbol' to the value given, in the given scope.
 func (d *Debugger) SetVariableInScope(goid, frame, deferredCall int, symbol, value string) error {
@@ -1603,14 +2656,126 @@ func (d *Debugger) SetVariableInScope(goid, frame, deferredCall int, symbol, val
 	if err != nil {
 		return err
 	}
+	d.clearStopCache()
 	return s.SetVariable(symbol, value)
 }
 
+// SetRegister changes the value of a register of thread threadID, see
+// proc.SetRegister for the syntax of reg.
+// If the program counter or the stack pointer are changed a warning is
+// returned, since the thread could be left in an inconsistent state.
+func (d *Debugger) SetRegister(threadID int, reg, value string) (warning string, err error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	thread, found := d.target.FindThread(threadID)
+	if !found {
+		return "", fmt.Errorf("couldn't find thread %d", threadID)
+	}
+	regnum, err := proc.SetRegister(d.target, thread, reg, value)
+	if err != nil {
+		return "", err
+	}
+	d.target.ClearCaches()
+	d.clearStopCache()
+	arch := d.target.BinInfo().Arch
+	switch regnum {
+	case arch.PCRegNum:
+		// the thread is no longer stopped at the breakpoint it hit, if any.
+		if err := thread.SetCurrentBreakpoint(false); err != nil {
+			return "", err
+		}
+		warning = "changing the program counter can leave the thread in an inconsistent state"
+	case arch.SPRegNum:
+		warning = "changing the stack pointer can corrupt the stack of the current goroutine"
+	}
+	return warning, nil
+}
+
+// Jump moves the program counter of the selected goroutine to the location
+// specified by locStr, which must resolve to a statement of the current
+// function, see proc.(*Target).Jump.
+func (d *Debugger) Jump(locStr string, substitutePathRules [][2]string) error {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	if _, err := d.target.Valid(); err != nil {
+		return err
+	}
+	loc, err := locspec.Parse(locStr)
+	if err != nil {
+		return err
+	}
+	locs, err := d.findLocation(-1, 0, 0, locStr, loc, false, substitutePathRules)
+	if err != nil {
+		return err
+	}
+	s, err := proc.ConvertEvalScope(d.target, -1, 0, 0)
+	if err != nil {
+		return err
+	}
+	curfn := d.target.BinInfo().PCToFunc(s.PC)
+	if curfn == nil {
+		return fmt.Errorf("no function at the current address %#x", s.PC)
+	}
+	// A location can have more than one address, for example when a line
+	// is inlined in other functions, only the ones in the current function
+	// are valid destinations.
+	pcs := []uint64{}
+	for _, loc := range locs {
+		if len(loc.PCs) == 0 {
+			loc.PCs = []uint64{loc.PC}
+		}
+		for _, pc := range loc.PCs {
+			if d.target.BinInfo().PCToFunc(pc) == curfn {
+				pcs = append(pcs, pc)
+			}
+		}
+	}
+	if len(pcs) == 0 {
+		return fmt.Errorf("location %q is not in the current function %s", locStr, curfn.Name)
+	}
+	sort.Slice(pcs, func(i, j int) bool { return pcs[i] < pcs[j] })
+	d.clearStopCache()
+	return d.target.Jump(pcs[0])
+}
+
 // Goroutines will return a list of goroutines in the target process.
 func (d *Debugger) Goroutines(start, count int) ([]*proc.G, int, error) {
 	d.targetMutex.Lock()
 	defer d.targetMutex.Unlock()
-	return proc.GoroutinesInfo(d.target, start, count)
+	c := d.currentStopCache()
+	key := [2]int{start, count}
+	r, ok := c.goroutines[key]
+	if !ok {
+		r.gs, r.nextg, r.err = proc.GoroutinesInfo(d.target, start, count)
+		c.goroutines[key] = r
+	}
+	if r.err != nil {
+		return nil, -1, r.err
+	}
+	// Callers can filter the returned slice in place.
+	return append([]*proc.G(nil), r.gs...), r.nextg, nil
+}
+
+// GoroutinesWaitDurations returns how long each goroutine in gs has been
+// waiting, see proc.WaitDuration.
+func (d *Debugger) GoroutinesWaitDurations(gs []*proc.G) []time.Duration {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+	r := make([]time.Duration, len(gs))
+	for i, g := range gs {
+		r[i] = proc.WaitDuration(d.target, g)
+	}
+	return r
+}
+
+// GoroutinesCount returns the number of goroutines that Goroutines would
+// return for start, without reading them.
+func (d *Debugger) GoroutinesCount(start int) (int, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+	return proc.GoroutinesCount(d.target, start)
 }
 
 // FilterGoroutines returns the goroutines in gs that satisfy the specified filters.
@@ -1694,9 +2859,16 @@ func (d *Debugger) GroupGoroutines(gs []*proc.G, group *api.GoroutineGroupingOpt
 	groupMembers := map[string][]*proc.G{}
 	totals := map[string]int{}
 
+	var lc *leakClassifier
+	if group.GroupBy == api.GoroutineLeak {
+		lc = newLeakClassifier(d.target, group.GroupByKey)
+	}
+
 	for _, g := range gs {
 		var key string
 		switch group.GroupBy {
+		case api.GoroutineLeak:
+			key = lc.groupName(g)
 		case api.GoroutineCurrentLoc:
 			key = formatLoc(g.CurrentLoc)
 		case api.GoroutineUserLoc:
@@ -1723,6 +2895,15 @@ func (d *Debugger) GroupGoroutines(gs []*proc.G, group *api.GoroutineGroupingOpt
 		keys = append(keys, key)
 	}
 	sort.Strings(keys)
+	if lc != nil {
+		// possible leaks first, then larger groups first
+		sort.SliceStable(keys, func(i, j int) bool {
+			if li, lj := lc.leaks[keys[i]], lc.leaks[keys[j]]; li != lj {
+				return li
+			}
+			return totals[keys[i]] > totals[keys[j]]
+		})
+	}
 
 	tooManyGroups := false
 	gsout := []*proc.G{}
@@ -1738,6 +2919,88 @@ func (d *Debugger) GroupGoroutines(gs []*proc.G, group *api.GoroutineGroupingOpt
 	return gsout, groups, tooManyGroups
 }
 
+// defaultLeakThreshold is the minimum blocked duration of goroutines
+// reported as possible leaks, if not specified.
+const defaultLeakThreshold = time.Minute
+
+// leakBuckets are the intervals used to group goroutines by blocked
+// duration.
+var leakBuckets = []time.Duration{time.Second, 10 * time.Second, time.Minute, 10 * time.Minute, time.Hour, 24 * time.Hour}
+
+// leakClassifier groups goroutines by the location of their go statement,
+// wait reason and blocked duration, and detects groups of goroutines that
+// are possibly leaked.
+type leakClassifier struct {
+	tgt         *proc.Target
+	waitReasons []string
+	threshold   time.Duration
+	leaks       map[string]bool // groups of possibly leaked goroutines
+}
+
+func newLeakClassifier(tgt *proc.Target, threshold string) *leakClassifier {
+	lc := &leakClassifier{
+		tgt:         tgt,
+		waitReasons: proc.WaitReasonStrings(tgt),
+		threshold:   defaultLeakThreshold,
+		leaks:       make(map[string]bool),
+	}
+	if d, err := time.ParseDuration(threshold); err == nil {
+		lc.threshold = d
+	}
+	return lc
+}
+
+// waitReason returns the description of the state of g.
+func (lc *leakClassifier) waitReason(g *proc.G) string {
+	switch g.Status {
+	case proc.Grunnable:
+		return "runnable"
+	case proc.Grunning:
+		return "running"
+	case proc.Gsyscall:
+		return "syscall"
+	case proc.Gwaiting:
+		if g.WaitReason >= 0 && g.WaitReason < int64(len(lc.waitReasons)) && lc.waitReasons[g.WaitReason] != "" {
+			return lc.waitReasons[g.WaitReason]
+		}
+		return fmt.Sprintf("wait reason %d", g.WaitReason)
+	}
+	return fmt.Sprintf("status %d", g.Status)
+}
+
+// isLeakProne returns true if goroutines waiting for reason can wait forever.
+func isLeakProne(reason string) bool {
+	switch reason {
+	case "chan receive", "chan send", "chan receive (nil chan)", "chan send (nil chan)", "select", "select (no cases)", "semacquire":
+		return true
+	}
+	return strings.HasPrefix(reason, "sync.")
+}
+
+func (lc *leakClassifier) groupName(g *proc.G) string {
+	reason := lc.waitReason(g)
+	if g.Status != proc.Gwaiting {
+		return fmt.Sprintf("%s [%s]", formatLoc(g.Go()), reason)
+	}
+	blocked := proc.WaitDuration(lc.tgt, g)
+	if blocked <= 0 {
+		return fmt.Sprintf("%s [%s, blocked for unknown time]", formatLoc(g.Go()), reason)
+	}
+	bucket := "less than " + leakBuckets[0].String()
+	for i := len(leakBuckets) - 1; i >= 0; i-- {
+		if blocked >= leakBuckets[i] {
+			bucket = "at least " + leakBuckets[i].String()
+			break
+		}
+	}
+	key := fmt.Sprintf("%s [%s, blocked for %s]", formatLoc(g.Go()), reason, bucket)
+	if isLeakProne(reason) && blocked >= lc.threshold {
+		key += " (possible leak)"
+		lc.leaks[key] = true
+	}
+	return key
+}
+
 // Stacktrace returns a list of Stackframes for the given goroutine. The
 // length of the returned list will be min(stack_len, depth).
 // If 'full' is true, then local vars, function args, etc will be returned as well.
@@ -1749,6 +3012,20 @@ func (d *Debugger) Stacktrace(goroutineID, depth int, opts api.StacktraceOptions
 		return nil, err
 	}
 
+	c := d.currentStopCache()
+	key := stacktraceKey{goroutineID, depth, opts}
+	r, ok := c.stacktraces[key]
+	if !ok {
+		r.frames, r.err = d.stacktrace(goroutineID, depth, opts)
+		c.stacktraces[key] = r
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return append([]proc.Stackframe(nil), r.frames...), nil
+}
+
+func (d *Debugger) stacktrace(goroutineID, depth int, opts api.StacktraceOptions) ([]proc.Stackframe, error) {
 	g, err := proc.FindGoroutine(d.target, goroutineID)
 	if err != nil {
 		return nil, err
@@ -1761,6 +3038,33 @@ func (d *Debugger) Stacktrace(goroutineID, depth int, opts api.StacktraceOptions
 	}
 }
 
+// GoroutinesStacktraces returns the stacktraces of the goroutines in gs,
+// up to depth frames each. Stacktraces that can not be read are returned
+// as a single frame with the Err field set.
+func (d *Debugger) GoroutinesStacktraces(gs []*proc.G, depth int, opts api.StacktraceOptions) ([][]api.Stackframe, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	if _, err := d.target.Valid(); err != nil {
+		return nil, err
+	}
+
+	r := make([][]api.Stackframe, len(gs))
+	var err error
+	proc.GoroutineStacktraces(d.target, gs, depth, proc.StacktraceOptions(opts), func(i int, frames []proc.Stackframe, ferr error) bool {
+		if ferr != nil {
+			r[i] = []api.Stackframe{{Err: ferr.Error()}}
+			return true
+		}
+		r[i], err = d.convertStacktrace(frames, nil)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 // Ancestors returns the stacktraces for the ancestors of a goroutine.
 func (d *Debugger) Ancestors(goroutineID, numAncestors, depth int) ([]api.Ancestor, error) {
 	d.targetMutex.Lock()
@@ -1812,22 +3116,42 @@ func (d *Debugger) ConvertStacktrace(rawlocs []proc.Stackframe, cfg *proc.LoadCo
 	return d.convertStacktrace(rawlocs, cfg)
 }
 
+// ConvertStacktraceFrames converts the first n frames of rawlocs, the frames
+// that follow them are used to compute the scope of the converted frames.
+func (d *Debugger) ConvertStacktraceFrames(rawlocs []proc.Stackframe, n int, cfg *proc.LoadConfig) ([]api.Stackframe, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+	return d.convertStacktraceFrames(rawlocs, n, cfg)
+}
+
 func (d *Debugger) convertStacktrace(rawlocs []proc.Stackframe, cfg *proc.LoadConfig) ([]api.Stackframe, error) {
-	locations := make([]api.Stackframe, 0, len(rawlocs))
-	for i := range rawlocs {
+	return d.convertStacktraceFrames(rawlocs, len(rawlocs), cfg)
+}
+
+func (d *Debugger) convertStacktraceFrames(rawlocs []proc.Stackframe, n int, cfg *proc.LoadConfig) ([]api.Stackframe, error) {
+	locations := make([]api.Stackframe, 0, n)
+	for i := 0; i < n; i++ {
 		frame := api.Stackframe{
 			Location: api.ConvertLocation(rawlocs[i].Call),
 
 			FrameOffset:        rawlocs[i].FrameOffset(),
 			FramePointerOffset: rawlocs[i].FramePointerOffset(),
 
-			Defers: d.convertDefers(rawlocs[i].Defers),
+			Defers: d.convertDefers(rawlocs[i].Defers, cfg),
 
 			Bottom: rawlocs[i].Bottom,
+
+			Markers: rawlocs[i].Markers.Strings(),
 		}
 		if rawlocs[i].Err != nil {
 			frame.Err = rawlocs[i].Err.Error()
 		}
+		if frame.Function == nil {
+			// C function without debug info, try the symbol table of its shared object
+			if name, entry := d.target.BinInfo().PCToSymbol(rawlocs[i].Call.PC); name != "" {
+				frame.Function = &api.Function{Name_: name, Value: entry}
+			}
+		}
 		if cfg != nil && rawlocs[i].Current.Fn != nil {
 			var err error
 			scope := proc.FrameToScope(d.target, d.target.Memory(), nil, rawlocs[i:]...)
@@ -1849,7 +3173,14 @@ func (d *Debugger) convertStacktrace(rawlocs []proc.Stackframe, cfg *proc.LoadCo
 	return locations, nil
 }
 
-func (d *Debugger) convertDefers(defers []*proc.Defer) []api.Defer {
+// capturedVarsLoadConfig is used to load the variables captured by deferred
+// functions when the stacktrace is converted without loading local variables.
+var capturedVarsLoadConfig = proc.LoadConfig{FollowPointers: true, MaxVariableRecurse: 1, MaxStringLen: 64, MaxArrayValues: 64, MaxStructFields: -1}
+
+func (d *Debugger) convertDefers(defers []*proc.Defer, cfg *proc.LoadConfig) []api.Defer {
+	if cfg == nil {
+		cfg = &capturedVarsLoadConfig
+	}
 	r := make([]api.Defer, len(defers))
 	for i := range defers {
 		ddf, ddl, ddfn := defers[i].DeferredFunc(d.target)
@@ -1873,7 +3204,14 @@ func (d *Debugger) convertDefers(defers []*proc.Defer) []api.Defer {
 
 		if defers[i].Unreadable != nil {
 			r[i].Unreadable = defers[i].Unreadable.Error()
+			continue
 		}
+
+		vars, err := defers[i].CapturedVars(d.target, *cfg)
+		if err != nil {
+			d.log.Debugf("could not read captured variables of deferred call: %v", err)
+		}
+		r[i].CapturedVars = api.ConvertVars(vars)
 	}
 
 	return r
@@ -1980,6 +3318,30 @@ func (d *Debugger) Disassemble(goroutineID int, addr1, addr2 uint64) ([]proc.Asm
 	return proc.Disassemble(d.target.Memory(), regs, d.target.Breakpoints(), d.target.BinInfo(), addr1, addr2)
 }
 
+// DisassembleRaw returns the disassembly of the memory between addr1 and
+// addr2, the range does not need to belong to a function.
+func (d *Debugger) DisassembleRaw(goroutineID int, addr1, addr2 uint64) ([]proc.AsmInstruction, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	if _, err := d.target.Valid(); err != nil {
+		return nil, err
+	}
+
+	g, err := proc.FindGoroutine(d.target, goroutineID)
+	if err != nil {
+		return nil, err
+	}
+
+	curthread := d.target.CurrentThread()
+	if g != nil && g.Thread != nil {
+		curthread = g.Thread
+	}
+	regs, _ := curthread.Registers()
+
+	return proc.DisassembleRaw(d.target.Memory(), regs, d.target.Breakpoints(), d.target.BinInfo(), addr1, addr2)
+}
+
 func (d *Debugger) AsmInstructionText(inst *proc.AsmInstruction, flavour proc.AssemblyFlavour) string {
 	d.targetMutex.Lock()
 	defer d.targetMutex.Unlock()
@@ -2032,6 +3394,40 @@ func (d *Debugger) ClearCheckpoint(id int) error {
 	return d.target.ClearCheckpoint(id)
 }
 
+// AddBookmark records the current position of the recording under name.
+func (d *Debugger) AddBookmark(name string) (proc.Bookmark, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+	return d.target.AddBookmark(name)
+}
+
+// Bookmarks returns the list of bookmarks, in recording order.
+func (d *Debugger) Bookmarks() ([]proc.Bookmark, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+	if recorded, _ := d.target.Recorded(); !recorded {
+		return nil, proc.ErrNotRecorded
+	}
+	return d.target.Bookmarks(), nil
+}
+
+// GotoBookmark restarts the recording from the position of the named
+// bookmark.
+func (d *Debugger) GotoBookmark(name string) error {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+	d.stopCount++
+	d.target.ResumeNotify(nil)
+	return d.target.GotoBookmark(name)
+}
+
+// ClearBookmark removes the named bookmark.
+func (d *Debugger) ClearBookmark(name string) error {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+	return d.target.ClearBookmark(name)
+}
+
 // ListDynamicLibraries returns a list of loaded dynamic libraries.
 func (d *Debugger) ListDynamicLibraries() []*proc.Image {
 	d.targetMutex.Lock()
@@ -2059,25 +3455,219 @@ func (d *Debugger) ExamineMemory(address uint64, length int) ([]byte, error) {
 	return data, nil
 }
 
-func (d *Debugger) GetVersion(out *api.GetVersionOut) error {
-	if d.config.CoreFile != "" {
-		if d.config.Backend == "rr" {
-			out.Backend = "rr"
-		} else {
-			out.Backend = "core"
-		}
-	} else {
-		if d.config.Backend == "default" {
-			if runtime.GOOS == "darwin" {
-				out.Backend = "lldb"
-			} else {
-				out.Backend = "native"
-			}
-		} else {
-			out.Backend = d.config.Backend
-		}
+// WriteMemory writes data to the memory of the target at the given address.
+// Returns the number of bytes written.
+func (d *Debugger) WriteMemory(address uint64, data []byte) (int, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	if _, err := d.target.Valid(); err != nil {
+		return 0, err
+	}
+	d.clearStopCache()
+	return d.target.Memory().WriteMemory(address, data)
+}
+
+// SearchMemory searches the memory of the target process for pattern.
+// See (*proc.Target).SearchMemory.
+func (d *Debugger) SearchMemory(pattern []byte, start, end uint64, max int) ([]proc.MemorySearchMatch, []proc.MemoryMapEntry, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	if _, err := d.target.Valid(); err != nil {
+		return nil, nil, err
+	}
+
+	return d.target.SearchMemory(pattern, start, end, max)
+}
+
+// FindReferences evaluates expr in the specified scope and returns the
+// address and size of the object it references together with the
+// pointers to that object found in goroutine stacks, package variables and
+// the heap.
+func (d *Debugger) FindReferences(goid, frame, deferredCall int, expr string, max int) (addr, size uint64, refs []proc.Reference, err error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	if _, err := d.target.Valid(); err != nil {
+		return 0, 0, nil, err
+	}
+
+	s, err := proc.ConvertEvalScope(d.target, goid, frame, deferredCall)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	v, err := s.EvalExpression(expr, proc.LoadConfig{})
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return d.target.FindReferences(v, max)
+}
+
+// FindDeadlocks returns the groups of goroutines that are deadlocked.
+func (d *Debugger) FindDeadlocks() ([][]*proc.BlockedGoroutine, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	if _, err := d.target.Valid(); err != nil {
+		return nil, err
+	}
+
+	return d.target.FindDeadlocks()
+}
+
+// RuntimeMetrics returns the metrics of the runtime of the target.
+func (d *Debugger) RuntimeMetrics() (*proc.RuntimeMetrics, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	if _, err := d.target.Valid(); err != nil {
+		return nil, err
+	}
+
+	return d.target.RuntimeMetrics()
+}
+
+// GoroutinePanics returns the panics of goroutine goid, see
+// proc.(*Target).GoroutinePanics.
+func (d *Debugger) GoroutinePanics(goid int, cfg proc.LoadConfig) ([]*proc.Panic, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	g, err := proc.FindGoroutine(d.target, goid)
+	if err != nil {
+		return nil, err
+	}
+	return d.target.GoroutinePanics(g, cfg)
+}
+
+// ChanInfo evaluates expr in the specified scope and returns its type and
+// the state of the channel it refers to, the elements of its buffer are
+// loaded using cfg.
+func (d *Debugger) ChanInfo(goid, frame, deferredCall int, expr string, cfg proc.LoadConfig) (string, *proc.ChanInfo, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	if _, err := d.target.Valid(); err != nil {
+		return "", nil, err
 	}
 
+	s, err := proc.ConvertEvalScope(d.target, goid, frame, deferredCall)
+	if err != nil {
+		return "", nil, err
+	}
+	v, err := s.EvalExpression(expr, proc.LoadConfig{})
+	if err != nil {
+		return "", nil, err
+	}
+	ci, err := d.target.ChanInfo(v, cfg)
+	return v.TypeString(), ci, err
+}
+
+// MutexInfo evaluates expr in the specified scope and returns its type and
+// the state of the sync.Mutex or sync.RWMutex it refers to.
+func (d *Debugger) MutexInfo(goid, frame, deferredCall int, expr string) (string, *proc.MutexInfo, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	if _, err := d.target.Valid(); err != nil {
+		return "", nil, err
+	}
+
+	s, err := proc.ConvertEvalScope(d.target, goid, frame, deferredCall)
+	if err != nil {
+		return "", nil, err
+	}
+	v, err := s.EvalExpression(expr, proc.LoadConfig{})
+	if err != nil {
+		return "", nil, err
+	}
+	mi, err := d.target.MutexInfo(v)
+	return v.TypeString(), mi, err
+}
+
+// ContextChain evaluates expr in the specified scope and returns the layers
+// of the context.Context it refers to, loading keys, values and errors
+// using cfg.
+func (d *Debugger) ContextChain(goid, frame, deferredCall int, expr string, cfg proc.LoadConfig) ([]*proc.ContextLayer, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	if _, err := d.target.Valid(); err != nil {
+		return nil, err
+	}
+
+	s, err := proc.ConvertEvalScope(d.target, goid, frame, deferredCall)
+	if err != nil {
+		return nil, err
+	}
+	v, err := s.EvalExpression(expr, proc.LoadConfig{})
+	if err != nil {
+		return nil, err
+	}
+	return d.target.ContextChain(v, cfg)
+}
+
+// GCState returns the state of the garbage collector of the target.
+func (d *Debugger) GCState() (*proc.GCState, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	if _, err := d.target.Valid(); err != nil {
+		return nil, err
+	}
+
+	return d.target.GCState()
+}
+
+// HeapObjects returns the live heap objects of type typename, loaded
+// using cfg.
+func (d *Debugger) HeapObjects(typename string, max int, cfg proc.LoadConfig) ([]*proc.Variable, error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	if _, err := d.target.Valid(); err != nil {
+		return nil, err
+	}
+
+	return d.target.HeapObjects(typename, max, cfg)
+}
+
+// DumpHeap writes the graph of the live heap objects of the target to dest
+// in the specified format, see heapgraph.Write. Returns the number of
+// objects written and the memory they use.
+func (d *Debugger) DumpHeap(dest, format string) (objects int, bytes uint64, err error) {
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+
+	if _, err := d.target.Valid(); err != nil {
+		return 0, 0, err
+	}
+
+	g, err := d.target.HeapGraph()
+	if err != nil {
+		return 0, 0, err
+	}
+	fh, err := os.Create(dest)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := heapgraph.Write(fh, g, format); err != nil {
+		fh.Close()
+		return 0, 0, err
+	}
+	if err := fh.Close(); err != nil {
+		return 0, 0, err
+	}
+	for i := range g.Objects {
+		bytes += g.Objects[i].Size
+	}
+	return len(g.Objects), bytes, nil
+}
+
+func (d *Debugger) GetVersion(out *api.GetVersionOut) error {
+	out.Backend = d.backendName()
+
 	if !d.isRecording() && !d.IsRunning() {
 		out.TargetGoVersion = d.target.BinInfo().Producer()
 	}
@@ -2088,6 +3678,53 @@ func (d *Debugger) GetVersion(out *api.GetVersionOut) error {
 	return nil
 }
 
+// backendName returns the name of the backend in use.
+func (d *Debugger) backendName() string {
+	switch {
+	case d.config.CoreFile != "" && d.config.Backend == "rr":
+		return "rr"
+	case d.config.CoreFile != "":
+		return "core"
+	case d.config.Backend == "default" && runtime.GOOS == "darwin":
+		return "lldb"
+	case d.config.Backend == "default":
+		return "native"
+	case strings.HasPrefix(d.config.Backend, qemuConnectPrefix):
+		return "qemu"
+	default:
+		return d.config.Backend
+	}
+}
+
+// Capabilities returns the optional features supported with the current
+// target and backend.
+func (d *Debugger) Capabilities() api.Capabilities {
+	caps := api.Capabilities{
+		Backend:        d.backendName(),
+		Restart:        d.canRestart(),
+		StreamingCalls: true,
+		PTY:            d.pty != nil,
+	}
+	if d.isRecording() {
+		return caps
+	}
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+	recorded, _ := d.target.Recorded()
+	caps.ReverseExecution = recorded
+	caps.Checkpoints = recorded
+	caps.CoreDump = d.target.CanDump
+	caps.FunctionCalls = d.target.SupportsFunctionCalls() && !recorded && caps.Backend != "core"
+	// hardware watchpoints are implemented on amd64 and linux/arm64
+	caps.Watchpoints = caps.Backend != "core" && d.target.SupportsWatchpoints()
+	caps.FollowExec = caps.Backend == "native" && runtime.GOOS == "linux"
+	caps.Watch = d.config.Watch
+	if caps.Backend == "rr" {
+		caps.Restart = true
+	}
+	return caps
+}
+
 // ListPackagesBuildInfo returns the list of packages used by the program along with
 // the directory where each package was compiled and optionally the list of
 // files constituting the package.
@@ -2126,8 +3763,9 @@ func (d *Debugger) UnlockTarget() {
 	d.targetMutex.Unlock()
 }
 
-// DumpStart starts a core dump to dest.
-func (d *Debugger) DumpStart(dest string) error {
+// DumpStart starts a core dump to dest. If native is set the core dump is
+// written in the format native to the operating system of the target.
+func (d *Debugger) DumpStart(dest string, native bool) error {
 	d.targetMutex.Lock()
 	// targetMutex will only be unlocked when the dump is done
 
@@ -2161,7 +3799,11 @@ func (d *Debugger) DumpStart(dest string) error {
 	d.dumpState.Err = nil
 	go func() {
 		defer d.targetMutex.Unlock()
-		d.target.Dump(fh, 0, &d.dumpState)
+		var flags proc.DumpFlags
+		if native {
+			flags |= proc.DumpNativeFormat
+		}
+		d.target.Dump(fh, flags, &d.dumpState)
 	}()
 
 	return nil
@@ -2206,27 +3848,7 @@ func (d *Debugger) BuildID() string {
 }
 
 func (d *Debugger) GetBufferedTracepoints() []api.TracepointResult {
-	traces := d.target.GetBufferedTracepoints()
-	if traces == nil {
-		return nil
-	}
-	results := make([]api.TracepointResult, len(traces))
-	for i, trace := range traces {
-		f, l, fn := d.target.BinInfo().PCToLine(uint64(trace.FnAddr))
-
-		results[i].FunctionName = fn.Name
-		results[i].Line = l
-		results[i].File = f
-		results[i].GoroutineID = trace.GoroutineID
-
-		for _, p := range trace.InputParams {
-			results[i].InputParams = append(results[i].InputParams, *api.ConvertVar(p))
-		}
-		for _, p := range trace.ReturnParams {
-			results[i].ReturnParams = append(results[i].ReturnParams, *api.ConvertVar(p))
-		}
-	}
-	return results
+	return api.ConvertTracepointResults(d.target.BinInfo(), d.target.GetBufferedTracepoints())
 }
 
 func go11DecodeErrorCheck(err error) error {
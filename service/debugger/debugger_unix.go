@@ -32,7 +32,7 @@ func verifyBinaryFormat(exePath string) error {
 	switch runtime.GOOS {
 	case "darwin":
 		exe, err = macho.NewFile(f)
-	case "linux", "freebsd":
+	case "linux", "freebsd", "openbsd":
 		exe, err = elf.NewFile(f)
 	default:
 		panic("attempting to open file Delve cannot parse")
@@ -6,12 +6,14 @@ package debugger
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/creack/pty"
 	"github.com/go-delve/delve/pkg/gobuild"
@@ -46,7 +48,7 @@ func TestDebugger_LaunchNoExecutablePerm(t *testing.T) {
 		t.Fatal(err)
 	}
 	d := new(Debugger)
-	_, err := d.Launch([]string{exepath}, ".")
+	_, err := d.Launch([]string{exepath}, ".", nil)
 	if err == nil {
 		t.Fatalf("expected error but none was generated")
 	}
@@ -102,3 +104,141 @@ func TestDebugger_LaunchWithTTY(t *testing.T) {
 		t.Fatalf("process open file list does not contain expected tty %q", wantTTYName)
 	}
 }
+
+func TestDebugger_Watch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dlvwatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile := func(name, contents string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("go.mod", "module watchtest\n\ngo 1.16\n")
+	writeFile("main.go", "package main\n\nfunc main() {\n\tprintln(\"one\")\n}\n")
+
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	exepath := filepath.Join(dir, "__debug_bin")
+	if err := gobuild.GoBuild(exepath, []string{"."}, ""); err != nil {
+		t.Fatalf("go build error %v", err)
+	}
+	d, err := New(&Config{Backend: "default", ExecuteKind: ExecutingGeneratedFile, Packages: []string{"."}, Watch: true}, []string{exepath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Detach(true)
+	if !d.Capabilities().Watch {
+		t.Errorf("Watch capability not reported")
+	}
+
+	events := make(chan *api.Event, 10)
+	defer d.Subscribe(func(ev *api.Event) {
+		if ev.Kind == api.EventReloaded {
+			events <- ev
+		}
+	})()
+	waitReload := func() *api.Event {
+		t.Helper()
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(time.Minute):
+			t.Fatal("timeout waiting for reload")
+			return nil
+		}
+	}
+
+	pid := d.ProcessPid()
+	writeFile("main.go", "package main\n\nfunc main() {\n\tprintln(\"two\")\n}\n")
+	ev := waitReload()
+	if ev.Err != "" || ev.Pid == pid || ev.Pid != d.ProcessPid() {
+		t.Fatalf("wrong reload event %#v (old pid %d, current pid %d)", ev, pid, d.ProcessPid())
+	}
+
+	pid = d.ProcessPid()
+	writeFile("main.go", "package main\n\nfunc main() {\n\tprintln(\"three\"\n}\n")
+	ev = waitReload()
+	if ev.Err == "" || d.ProcessPid() != pid {
+		t.Fatalf("expected failed reload, got %#v (old pid %d, current pid %d)", ev, pid, d.ProcessPid())
+	}
+	t.Log(ev.Err)
+}
+
+func TestDebugger_LaunchWithPTY(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dlvpty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "main.go")
+	if err := ioutil.WriteFile(src, []byte(`package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+func main() {
+	fi, _ := os.Stdout.Stat()
+	fmt.Printf("chardev=%v\n", fi.Mode()&os.ModeCharDevice != 0)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Printf("read=%s", line)
+}
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	exepath := filepath.Join(dir, "__debug_bin")
+	if err := gobuild.GoBuild(exepath, []string{src}, ""); err != nil {
+		t.Fatalf("go build error %v", err)
+	}
+	var backend string
+	protest.DefaultTestBackend(&backend)
+	d, err := New(&Config{Backend: backend, PTY: true}, []string{exepath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Detach(true)
+	if !d.Capabilities().PTY {
+		t.Errorf("PTY capability not reported")
+	}
+
+	output := make(chan []byte, 100)
+	unsubscribe, err := d.SubscribeTTY(func(data []byte) {
+		output <- data
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsubscribe()
+	if err := d.ResizeTTY(30, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.WriteTTY([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Command(&api.DebuggerCommand{Name: api.Continue}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf []byte
+	timeout := time.After(time.Minute)
+	for !bytes.Contains(buf, []byte("read=hello")) {
+		select {
+		case data := <-output:
+			buf = append(buf, data...)
+		case <-timeout:
+			t.Fatalf("timeout waiting for output, got %q", buf)
+		}
+	}
+	if !bytes.Contains(buf, []byte("chardev=true")) {
+		t.Errorf("stdout of the target is not a terminal: %q", buf)
+	}
+}
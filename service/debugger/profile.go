@@ -0,0 +1,208 @@
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"go/constant"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-delve/delve/pkg/proc"
+	"github.com/go-delve/delve/service/api"
+)
+
+var profileLoadConfig = api.LoadConfig{FollowPointers: true, MaxVariableRecurse: 1, MaxStringLen: 256, MaxArrayValues: 0, MaxStructFields: 3}
+
+// profiler describes one of the profilers of runtime/pprof or runtime/trace.
+type profiler struct {
+	name    string // used in error messages
+	pkg     string // package path
+	start   string // function starting the profiler, it takes an io.Writer and returns an error
+	stop    string // function stopping the profiler
+	pattern string // pattern of the name of the temporary file, in the target, the profile is written to
+}
+
+var (
+	cpuProfiler   = profiler{name: "CPU profiler", pkg: "runtime/pprof", start: "StartCPUProfile", stop: "StopCPUProfile", pattern: "dlv-cpu-*.pprof"}
+	traceProfiler = profiler{name: "execution tracer", pkg: "runtime/trace", start: "Start", stop: "Stop", pattern: "dlv-trace-*.out"}
+)
+
+// ProfileCPU collects a CPU profile of the target for the given duration and
+// returns it, in the format written by runtime/pprof.
+// The profiler of the target is started and stopped by injecting calls to
+// runtime/pprof.StartCPUProfile and runtime/pprof.StopCPUProfile on
+// goroutine goid (or on the selected goroutine if goid is 0), in between the
+// target is resumed and then stopped once the duration expires. The profile
+// is written by the target to a temporary file on its own filesystem, which
+// is then read back with an injected call to os.ReadFile and removed.
+// If resumeNotify is not nil it will be closed once the target is resumed.
+//
+// Targets that do not import runtime/pprof can not be profiled, sending
+// SIGPROF to the target is not an alternative since only runtime/pprof
+// reads the samples collected by the runtime.
+func (d *Debugger) ProfileCPU(goid int, duration time.Duration, resumeNotify chan struct{}) ([]byte, error) {
+	return d.profile(&cpuProfiler, goid, duration, resumeNotify)
+}
+
+// ProfileTrace collects an execution trace of the target for the given
+// duration and returns it, in the format written by runtime/trace.
+// The tracer is started and stopped by injecting calls to
+// runtime/trace.Start and runtime/trace.Stop, see ProfileCPU.
+func (d *Debugger) ProfileTrace(goid int, duration time.Duration, resumeNotify chan struct{}) ([]byte, error) {
+	return d.profile(&traceProfiler, goid, duration, resumeNotify)
+}
+
+func (d *Debugger) profile(p *profiler, goid int, duration time.Duration, resumeNotify chan struct{}) ([]byte, error) {
+	if duration <= 0 {
+		return nil, errors.New("profile duration must be positive")
+	}
+	d.targetMutex.Lock()
+	bi := d.target.BinInfo()
+	d.targetMutex.Unlock()
+	for _, fnName := range []string{"os.CreateTemp", "os.ReadFile", "os.Remove", p.pkg + "." + p.start, p.pkg + "." + p.stop} {
+		if bi.LookupFunc[fnName] == nil {
+			return nil, fmt.Errorf("could not find %s, the target must import %s to be profiled", fnName, p.pkg)
+		}
+	}
+
+	// The file is created by the target, its filesystem can be different
+	// from ours (containers, remote sessions, qemu...).
+	retVals, err := d.injectCall(goid, "os.CreateTemp(\"\", "+strconv.Quote(p.pattern)+")")
+	if err != nil {
+		return nil, err
+	}
+	if len(retVals) != 2 || len(retVals[0].Children) == 0 || retVals[0].Children[0].Addr == 0 {
+		return nil, fmt.Errorf("could not create a temporary file in the target: %s", injectedCallError(retVals))
+	}
+	file := fmt.Sprintf("(*os.File)(%#x)", retVals[0].Children[0].Addr)
+	namev, err := d.EvalVariableInScope(goid, 0, 0, file+".file.name", proc.LoadConfig{MaxStringLen: 4096})
+	if err != nil {
+		return nil, err
+	}
+	if namev.Unreadable != nil || namev.Value == nil || namev.Value.Kind() != constant.String {
+		return nil, errors.New("could not read the name of the temporary file in the target")
+	}
+	path := strconv.Quote(constant.StringVal(namev.Value))
+	defer d.injectCall(goid, "os.Remove("+path+")")
+	defer d.injectCall(goid, file+".Close()")
+
+	retVals, err = d.injectCall(goid, fmt.Sprintf("%q.%s(%s)", p.pkg, p.start, file))
+	if err != nil {
+		return nil, err
+	}
+	if errstr := injectedCallError(retVals); errstr != "" {
+		return nil, fmt.Errorf("could not start the %s: %s", p.name, errstr)
+	}
+
+	// Resume the target and stop it once the duration expires. The target
+	// can also stop earlier, for example because it hit a breakpoint, in
+	// which case the profile will be shorter and the halt must not be sent
+	// anymore, since it would stop the next continue.
+	var haltMu sync.Mutex
+	continuing := true
+	timer := time.AfterFunc(duration, func() {
+		haltMu.Lock()
+		defer haltMu.Unlock()
+		if continuing {
+			d.Command(&api.DebuggerCommand{Name: api.Halt}, nil)
+		}
+	})
+	state, err := d.Command(&api.DebuggerCommand{Name: api.Continue}, resumeNotify)
+	haltMu.Lock()
+	continuing = false
+	haltMu.Unlock()
+	timer.Stop()
+	if err != nil {
+		return nil, err
+	}
+	if state.Exited {
+		return nil, fmt.Errorf("process exited with status %d while profiling", state.ExitStatus)
+	}
+
+	if err := d.stopProfile(p, goid); err != nil {
+		return nil, err
+	}
+	return d.readTargetFile(goid, path)
+}
+
+// readTargetFile returns the contents of the file at path (a quoted
+// string) in the filesystem of the target, read by an injected call to
+// os.ReadFile on goroutine goid.
+func (d *Debugger) readTargetFile(goid int, path string) ([]byte, error) {
+	retVals, err := d.injectCall(goid, "os.ReadFile("+path+")")
+	if err != nil {
+		return nil, err
+	}
+	if errstr := injectedCallError(retVals); errstr != "" || len(retVals) != 2 {
+		return nil, fmt.Errorf("could not read %s in the target: %s", path, errstr)
+	}
+	// The returned slice is read directly, loading it as a variable would
+	// create a Variable for each byte.
+	buf := make([]byte, retVals[0].Len)
+	if len(buf) == 0 {
+		return buf, nil
+	}
+	d.targetMutex.Lock()
+	defer d.targetMutex.Unlock()
+	n, err := d.target.Memory().ReadMemory(buf, retVals[0].Base)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// stopProfile stops profiler p calling its stop function on goroutine goid.
+// The target could have been stopped anywhere, if goid can not be used the
+// call is attempted on the goroutines running on the other threads.
+func (d *Debugger) stopProfile(p *profiler, goid int) error {
+	expr := fmt.Sprintf("%q.%s()", p.pkg, p.stop)
+	_, err := d.injectCall(goid, expr)
+	if err == nil {
+		return nil
+	}
+	d.targetMutex.Lock()
+	var goids []int
+	for _, th := range d.target.ThreadList() {
+		if g, _ := proc.GetG(th); g != nil && g.ID != goid {
+			goids = append(goids, g.ID)
+		}
+	}
+	d.targetMutex.Unlock()
+	for _, othergoid := range goids {
+		if _, err := d.injectCall(othergoid, expr); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("could not stop the %s, call %s once the target is stopped at a safe point: %v", p.name, expr, err)
+}
+
+// injectCall calls the function call expression expr on goroutine goid and
+// returns its return values.
+func (d *Debugger) injectCall(goid int, expr string) ([]api.Variable, error) {
+	state, err := d.Command(&api.DebuggerCommand{Name: api.Call, Expr: expr, GoroutineID: goid, ReturnInfoLoadConfig: &profileLoadConfig}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if state.Exited {
+		return nil, fmt.Errorf("process exited with status %d", state.ExitStatus)
+	}
+	if state.CurrentThread == nil || !state.CurrentThread.CallReturn {
+		return nil, fmt.Errorf("call to %s did not complete", expr)
+	}
+	return state.CurrentThread.ReturnValues, nil
+}
+
+// injectedCallError returns the description of the error returned as the
+// last return value of an injected call, or an empty string if it is nil.
+func injectedCallError(retVals []api.Variable) string {
+	if len(retVals) == 0 {
+		return ""
+	}
+	errv := retVals[len(retVals)-1]
+	if len(errv.Children) == 0 || (errv.Children[0].Kind == reflect.Invalid && errv.Children[0].Addr == 0) {
+		return ""
+	}
+	return errv.SinglelineString()
+}
@@ -0,0 +1,101 @@
+//go:build !windows
+// +build !windows
+
+package debugger
+
+import (
+	"os"
+	"sync"
+
+	"github.com/creack/pty"
+)
+
+// ptyBufferSize is the maximum amount of output of the target that is kept
+// while nobody is reading the pseudo-terminal, see Debugger.SubscribeTTY.
+const ptyBufferSize = 64 * 1024
+
+// targetPTY is the pseudo-terminal allocated for the target process when
+// Config.PTY is set. The output of the target is read from the master side
+// and sent to the subscribers.
+type targetPTY struct {
+	master, tty *os.File
+
+	mu               sync.Mutex
+	subscribers      map[int]func([]byte)
+	subscriberIDNext int
+	// pending is the output read while there were no subscribers, it is
+	// sent to the first one.
+	pending []byte
+}
+
+func openTargetPTY() (*targetPTY, error) {
+	master, tty, err := pty.Open()
+	if err != nil {
+		return nil, err
+	}
+	p := &targetPTY{master: master, tty: tty, subscribers: make(map[int]func([]byte))}
+	go p.readLoop()
+	return p, nil
+}
+
+// name returns the path of the slave side of the pseudo-terminal, to be
+// used as the controlling terminal of the target.
+func (p *targetPTY) name() string {
+	return p.tty.Name()
+}
+
+func (p *targetPTY) readLoop() {
+	buf := make([]byte, 4096)
+	for {
+		n, err := p.master.Read(buf)
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, buf[:n])
+			p.mu.Lock()
+			if len(p.subscribers) == 0 {
+				p.pending = append(p.pending, data...)
+				if len(p.pending) > ptyBufferSize {
+					p.pending = p.pending[len(p.pending)-ptyBufferSize:]
+				}
+			}
+			for _, fn := range p.subscribers {
+				fn(data)
+			}
+			p.mu.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (p *targetPTY) subscribe(fn func([]byte)) (unsubscribe func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.pending) > 0 {
+		fn(p.pending)
+		p.pending = nil
+	}
+	id := p.subscriberIDNext
+	p.subscriberIDNext++
+	p.subscribers[id] = fn
+	return func() {
+		p.mu.Lock()
+		delete(p.subscribers, id)
+		p.mu.Unlock()
+	}
+}
+
+func (p *targetPTY) write(data []byte) error {
+	_, err := p.master.Write(data)
+	return err
+}
+
+func (p *targetPTY) resize(rows, cols int) error {
+	return pty.Setsize(p.master, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+}
+
+func (p *targetPTY) close() {
+	p.tty.Close()
+	p.master.Close()
+}
@@ -0,0 +1,21 @@
+package debugger
+
+import "errors"
+
+// targetPTY is the pseudo-terminal allocated for the target process when
+// Config.PTY is set, not supported on windows.
+type targetPTY struct{}
+
+func openTargetPTY() (*targetPTY, error) {
+	return nil, errors.New("pseudo-terminals are not supported on windows")
+}
+
+func (p *targetPTY) name() string { return "" }
+
+func (p *targetPTY) subscribe(fn func([]byte)) (unsubscribe func()) { return func() {} }
+
+func (p *targetPTY) write(data []byte) error { return nil }
+
+func (p *targetPTY) resize(rows, cols int) error { return nil }
+
+func (p *targetPTY) close() {}
@@ -0,0 +1,76 @@
+package debugger
+
+import (
+	"github.com/go-delve/delve/pkg/proc"
+	"github.com/go-delve/delve/service/api"
+)
+
+// stopCache caches the stacktraces, goroutine lists and evaluated
+// expressions returned while the target is stopped, so that clients that
+// repeat the same queries, for example from several panels, are served
+// from memory.
+// It is valid as long as stopCount, which is incremented every time the
+// target runs, and the selected target do not change. Operations that
+// change the state of the target without running it must clear it
+// explicitly, see clearStopCache.
+type stopCache struct {
+	stop   uint64 // value of stopCount when the cache was created
+	target *proc.Target
+
+	stacktraces map[stacktraceKey]stacktraceResult
+	goroutines  map[[2]int]goroutinesResult // keyed on start and count
+	vars        map[evalKey]evalResult
+}
+
+type stacktraceKey struct {
+	goroutineID, depth int
+	opts               api.StacktraceOptions
+}
+
+type stacktraceResult struct {
+	frames []proc.Stackframe
+	err    error
+}
+
+type goroutinesResult struct {
+	gs    []*proc.G
+	nextg int
+	err   error
+}
+
+type evalResult struct {
+	v   *proc.Variable
+	err error
+}
+
+// currentStopCache returns the cache for the current stop of the target,
+// discarding the contents cached at previous stops. Must be called with
+// targetMutex held.
+func (d *Debugger) currentStopCache() *stopCache {
+	if d.target.NonStop() {
+		// Threads that did not stop keep changing the state of the target,
+		// return an empty cache that will not be used again.
+		return d.newStopCache()
+	}
+	c := &d.stopCache
+	if c.stacktraces == nil || c.stop != d.stopCount || c.target != d.target {
+		*c = *d.newStopCache()
+	}
+	return c
+}
+
+func (d *Debugger) newStopCache() *stopCache {
+	return &stopCache{
+		stop:        d.stopCount,
+		target:      d.target,
+		stacktraces: make(map[stacktraceKey]stacktraceResult),
+		goroutines:  make(map[[2]int]goroutinesResult),
+		vars:        make(map[evalKey]evalResult),
+	}
+}
+
+// clearStopCache discards the contents of the stop cache. Must be called
+// with targetMutex held.
+func (d *Debugger) clearStopCache() {
+	d.stopCache = stopCache{}
+}
@@ -0,0 +1,159 @@
+package debugger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-delve/delve/pkg/gobuild"
+	"github.com/go-delve/delve/service/api"
+)
+
+// watchInterval is how often the source files are checked for changes
+// when Config.Watch is set.
+const watchInterval = 500 * time.Millisecond
+
+// fileStamp is the state of a source file, a change of either field is a
+// change of the file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchSnapshot returns the state of the source files of the packages
+// being debugged.
+func (d *Debugger) watchSnapshot() (map[string]fileStamp, error) {
+	dirs, err := gobuild.PackageDirs(d.config.Packages, d.config.BuildFlags, d.config.ExecuteKind == ExecutingGeneratedTest)
+	if err != nil {
+		return nil, err
+	}
+	r := make(map[string]fileStamp)
+	for _, dir := range dirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			fi, err := os.Stat(file)
+			if err != nil {
+				continue
+			}
+			r[file] = fileStamp{fi.ModTime(), fi.Size()}
+		}
+	}
+	return r, nil
+}
+
+func sameSnapshot(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for file, stamp := range a {
+		if b[file] != stamp {
+			return false
+		}
+	}
+	return true
+}
+
+// watch reloads the target, see reload, every time the source files of the
+// packages being debugged change from snapshot, until stop is closed.
+func (d *Debugger) watch(snapshot map[string]fileStamp, stop <-chan struct{}) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	wait := func() bool {
+		select {
+		case <-stop:
+			return false
+		case <-ticker.C:
+			return true
+		}
+	}
+	for wait() {
+		cur, err := d.watchSnapshot()
+		if err != nil {
+			d.log.Debugf("could not list source files to watch: %v", err)
+			continue
+		}
+		if sameSnapshot(cur, snapshot) {
+			continue
+		}
+		// Editors can write more than one file, or the same file more than
+		// once, wait until the files stop changing.
+		for {
+			if !wait() {
+				return
+			}
+			next, err := d.watchSnapshot()
+			if err != nil || sameSnapshot(next, cur) {
+				break
+			}
+			cur = next
+		}
+		d.reload()
+		// The imports, and therefore the directories to watch, could have
+		// changed. Files that were already watched keep the state they had
+		// before the reload, so that changes made during the reload are
+		// not missed.
+		snapshot = cur
+		if next, err := d.watchSnapshot(); err == nil {
+			for file, stamp := range cur {
+				if _, ok := next[file]; ok {
+					next[file] = stamp
+				}
+			}
+			snapshot = next
+		}
+	}
+}
+
+// reload rebuilds the program and, if the build succeeds, restarts the
+// target with its breakpoints. An api.EventReloaded event is sent to the
+// subscribers either way.
+func (d *Debugger) reload() {
+	d.log.Infof("source files changed, rebuilding")
+	ev := &api.Event{Kind: api.EventReloaded}
+	defer func() { d.notify(ev) }()
+
+	// Build to a temporary file first, so that the target is not killed if
+	// the build fails. The build done by Restart will use the build cache.
+	dir, err := ioutil.TempDir("", "dlv-watch")
+	if err != nil {
+		ev.Err = err.Error()
+		return
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, filepath.Base(d.processArgs[0]))
+	var out []byte
+	switch {
+	case d.config.BuildCommand != "":
+		out, err = gobuild.CustomBuildCombinedOutput(tmp, d.config.Packages, d.config.BuildCommand, d.config.BuildOutput)
+	case d.config.ExecuteKind == ExecutingGeneratedTest:
+		_, out, err = gobuild.GoTestBuildCombinedOutput(tmp, d.config.Packages, d.config.BuildFlags)
+	default:
+		_, out, err = gobuild.GoBuildCombinedOutput(tmp, d.config.Packages, d.config.BuildFlags)
+	}
+	if err != nil {
+		ev.Err = fmt.Sprintf("could not rebuild process: %s", strings.TrimSpace(string(out)))
+		d.log.Error(ev.Err)
+		return
+	}
+
+	if d.IsRunning() {
+		if _, err := d.Command(&api.DebuggerCommand{Name: api.Halt}, nil); err != nil {
+			ev.Err = err.Error()
+			return
+		}
+	}
+	discarded, err := d.Restart(false, "", false, nil, [3]string{}, true, nil, "")
+	if err != nil {
+		ev.Err = err.Error()
+		d.log.Errorf("could not restart process: %v", err)
+		return
+	}
+	ev.Pid = d.ProcessPid()
+	ev.DiscardedBreakpoints = discarded
+}
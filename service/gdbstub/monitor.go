@@ -0,0 +1,166 @@
+package gdbstub
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-delve/delve/pkg/proc"
+	"github.com/go-delve/delve/service/api"
+)
+
+// Monitor commands are sent by the client with the qRcmd packet, they
+// expose the goroutine aware functionality of Delve that has no
+// equivalent in the remote protocol.
+
+type monitorCommand struct {
+	name string
+	args string
+	help string
+	fn   func(ss *session, args string, out io.Writer) error
+}
+
+var monitorCommands []monitorCommand
+
+func init() {
+	monitorCommands = []monitorCommand{
+		{"help", "", "Prints the list of monitor commands.", monitorHelp},
+		{"goroutines", "", "Lists the goroutines of the target process, the selected one is marked with '*'.", monitorGoroutines},
+		{"goroutine", "<id>", "Selects the goroutine used by 'stack' and 'eval'.", monitorGoroutine},
+		{"stack", "[<depth>]", "Prints the stack trace of the selected goroutine.", monitorStack},
+		{"eval", "<expr>", "Evaluates a Go expression in the topmost frame of the selected goroutine.", monitorEval},
+	}
+}
+
+// monitor executes the monitor command cmd, its output is sent to the
+// client with 'O' packets.
+func (ss *session) monitor(cmd string) []byte {
+	name, args := cmd, ""
+	if i := strings.IndexAny(cmd, " \t"); i >= 0 {
+		name, args = cmd[:i], strings.TrimSpace(cmd[i+1:])
+	}
+	var out bytes.Buffer
+	var err error
+	found := false
+	for _, c := range monitorCommands {
+		if c.name == name {
+			found = true
+			err = c.fn(ss, args, &out)
+			break
+		}
+	}
+	if !found {
+		err = fmt.Errorf("unknown command %q, see 'monitor help'", name)
+	}
+	if err != nil {
+		fmt.Fprintf(&out, "error: %v\n", err)
+	}
+
+	const chunkSize = maxPacketSize/2 - 1
+	for b := out.Bytes(); len(b) > 0; {
+		n := len(b)
+		if n > chunkSize {
+			n = chunkSize
+		}
+		if err := ss.conn.WritePacket(appendHex([]byte("O"), b[:n])); err != nil {
+			return ss.errorReply(err)
+		}
+		b = b[n:]
+	}
+	return okReply
+}
+
+func monitorHelp(ss *session, args string, out io.Writer) error {
+	fmt.Fprintf(out, "Delve monitor commands:\n")
+	for _, c := range monitorCommands {
+		usage := c.name
+		if c.args != "" {
+			usage += " " + c.args
+		}
+		fmt.Fprintf(out, "    %-20s %s\n", usage, c.help)
+	}
+	return nil
+}
+
+func monitorGoroutines(ss *session, args string, out io.Writer) error {
+	state, err := ss.d.State(false)
+	if err != nil {
+		return err
+	}
+	gs, _, err := ss.d.Goroutines(0, 0)
+	if err != nil {
+		return err
+	}
+	for _, g := range gs {
+		prefix := "  "
+		if state.SelectedGoroutine != nil && state.SelectedGoroutine.ID == g.ID {
+			prefix = "* "
+		}
+		thread := ""
+		if g.Thread != nil {
+			thread = fmt.Sprintf(" [thread %d]", g.Thread.ThreadID())
+		}
+		fmt.Fprintf(out, "%sGoroutine %d - %s%s\n", prefix, g.ID, formatLocation(g.UserCurrent()), thread)
+	}
+	return nil
+}
+
+func monitorGoroutine(ss *session, args string, out io.Writer) error {
+	id, err := strconv.Atoi(args)
+	if err != nil {
+		return errors.New("goroutine ID required")
+	}
+	if _, err := ss.d.Command(&api.DebuggerCommand{Name: api.SwitchGoroutine, GoroutineID: id}, nil); err != nil {
+		return err
+	}
+	g, err := ss.d.FindGoroutine(id)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Switched to goroutine %d - %s\n", id, formatLocation(g.UserCurrent()))
+	if g.Thread != nil {
+		fmt.Fprintf(out, "Goroutine %d is running on thread %d\n", id, g.Thread.ThreadID())
+	}
+	return nil
+}
+
+func monitorStack(ss *session, args string, out io.Writer) error {
+	depth := 50
+	if args != "" {
+		var err error
+		if depth, err = strconv.Atoi(args); err != nil {
+			return fmt.Errorf("wrong depth %q", args)
+		}
+	}
+	frames, err := ss.d.Stacktrace(-1, depth, 0)
+	if err != nil {
+		return err
+	}
+	for i, frame := range frames {
+		fmt.Fprintf(out, "%2d  %#016x in %s\n", i, frame.Current.PC, formatLocation(frame.Call))
+	}
+	return nil
+}
+
+func monitorEval(ss *session, args string, out io.Writer) error {
+	if args == "" {
+		return errors.New("expression required")
+	}
+	v, err := ss.d.EvalVariableInScope(-1, 0, 0, args, proc.LoadConfig{FollowPointers: true, MaxVariableRecurse: 1, MaxStringLen: 64, MaxArrayValues: 64, MaxStructFields: -1})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, api.ConvertVar(v).MultilineString("", ""))
+	return nil
+}
+
+func formatLocation(loc proc.Location) string {
+	fn := "?"
+	if loc.Fn != nil {
+		fn = loc.Fn.Name
+	}
+	return fmt.Sprintf("%s at %s:%d", fn, loc.File, loc.Line)
+}
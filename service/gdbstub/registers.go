@@ -0,0 +1,163 @@
+package gdbstub
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/go-delve/delve/pkg/dwarf/op"
+	"github.com/go-delve/delve/pkg/dwarf/regnum"
+)
+
+// noRegnum marks registers of the GDB layout that Delve does not know
+// about, their value is always reported as unavailable.
+const noRegnum = ^uint64(0)
+
+// stubRegister is a register in the layout used by the 'g' and 'p'
+// packets, the layout is described to the client by target.xml.
+type stubRegister struct {
+	name    string
+	bitsize int
+	typ     string
+	dwarf   uint64 // DWARF register number, noRegnum if unknown
+	feature string
+}
+
+// stubArch describes how the registers of an architecture are exposed to
+// the client.
+type stubArch struct {
+	gdbArch string // value of the architecture element of target.xml
+	regs    []stubRegister
+}
+
+func amd64StubArch() *stubArch {
+	const core = "org.gnu.gdb.i386.core"
+	const sse = "org.gnu.gdb.i386.sse"
+	regs := []stubRegister{
+		{"rax", 64, "int64", regnum.AMD64_Rax, core},
+		{"rbx", 64, "int64", regnum.AMD64_Rbx, core},
+		{"rcx", 64, "int64", regnum.AMD64_Rcx, core},
+		{"rdx", 64, "int64", regnum.AMD64_Rdx, core},
+		{"rsi", 64, "int64", regnum.AMD64_Rsi, core},
+		{"rdi", 64, "int64", regnum.AMD64_Rdi, core},
+		{"rbp", 64, "data_ptr", regnum.AMD64_Rbp, core},
+		{"rsp", 64, "data_ptr", regnum.AMD64_Rsp, core},
+	}
+	for i := 8; i <= 15; i++ {
+		regs = append(regs, stubRegister{fmt.Sprintf("r%d", i), 64, "int64", uint64(regnum.AMD64_R8 + i - 8), core})
+	}
+	regs = append(regs,
+		stubRegister{"rip", 64, "code_ptr", regnum.AMD64_Rip, core},
+		stubRegister{"eflags", 32, "int32", regnum.AMD64_Rflags, core},
+		stubRegister{"cs", 32, "int32", regnum.AMD64_Cs, core},
+		stubRegister{"ss", 32, "int32", regnum.AMD64_Ss, core},
+		stubRegister{"ds", 32, "int32", regnum.AMD64_Ds, core},
+		stubRegister{"es", 32, "int32", regnum.AMD64_Es, core},
+		stubRegister{"fs", 32, "int32", regnum.AMD64_Fs, core},
+		stubRegister{"gs", 32, "int32", regnum.AMD64_Gs, core})
+	for i := 0; i < 8; i++ {
+		regs = append(regs, stubRegister{fmt.Sprintf("st%d", i), 80, "i387_ext", uint64(regnum.AMD64_ST0 + i), core})
+	}
+	for _, name := range []string{"fctrl", "fstat", "ftag", "fiseg", "fioff", "foseg", "fooff", "fop"} {
+		dwarf := noRegnum
+		switch name {
+		case "fctrl":
+			dwarf = regnum.AMD64_CW
+		case "fstat":
+			dwarf = regnum.AMD64_SW
+		}
+		regs = append(regs, stubRegister{name, 32, "int", dwarf, core})
+	}
+	for i := 0; i < 16; i++ {
+		regs = append(regs, stubRegister{fmt.Sprintf("xmm%d", i), 128, "vec128", uint64(regnum.AMD64_XMM0 + i), sse})
+	}
+	regs = append(regs, stubRegister{"mxcsr", 32, "int", regnum.AMD64_MXCSR, sse})
+	return &stubArch{gdbArch: "i386:x86-64", regs: regs}
+}
+
+func arm64StubArch() *stubArch {
+	const core = "org.gnu.gdb.aarch64.core"
+	var regs []stubRegister
+	for i := 0; i <= 30; i++ {
+		regs = append(regs, stubRegister{fmt.Sprintf("x%d", i), 64, "int", uint64(regnum.ARM64_X0 + i), core})
+	}
+	regs = append(regs,
+		stubRegister{"sp", 64, "data_ptr", regnum.ARM64_SP, core},
+		stubRegister{"pc", 64, "code_ptr", regnum.ARM64_PC, core},
+		stubRegister{"cpsr", 32, "int", noRegnum, core})
+	return &stubArch{gdbArch: "aarch64", regs: regs}
+}
+
+func newStubArch(arch string) (*stubArch, error) {
+	switch arch {
+	case "amd64":
+		return amd64StubArch(), nil
+	case "arm64":
+		return arm64StubArch(), nil
+	default:
+		return nil, fmt.Errorf("gdbserve is not supported on %s", arch)
+	}
+}
+
+// targetXML returns the target description of the architecture, see
+// https://sourceware.org/gdb/current/onlinedocs/gdb/Target-Descriptions.html
+func (a *stubArch) targetXML() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<?xml version=\"1.0\"?>\n<!DOCTYPE target SYSTEM \"gdb-target.dtd\">\n<target version=\"1.0\">\n")
+	fmt.Fprintf(&buf, "<architecture>%s</architecture>\n", a.gdbArch)
+	feature := ""
+	for i, reg := range a.regs {
+		if reg.feature != feature {
+			if feature != "" {
+				fmt.Fprintf(&buf, "</feature>\n")
+			}
+			feature = reg.feature
+			fmt.Fprintf(&buf, "<feature name=%q>\n", feature)
+		}
+		fmt.Fprintf(&buf, "<reg name=%q bitsize=\"%d\" type=%q regnum=\"%d\"/>\n", reg.name, reg.bitsize, reg.typ, i)
+	}
+	if feature != "" {
+		fmt.Fprintf(&buf, "</feature>\n")
+	}
+	fmt.Fprintf(&buf, "</target>\n")
+	return buf.Bytes()
+}
+
+// appendRegister appends the hex encoding of register reg, taken from
+// dregs, to out. Unavailable registers are encoded as a sequence of 'x'.
+func appendRegister(out []byte, reg stubRegister, dregs *op.DwarfRegisters) []byte {
+	sz := reg.bitsize / 8
+	var dreg *op.DwarfRegister
+	if reg.dwarf != noRegnum && dregs != nil {
+		dreg = dregs.Reg(reg.dwarf)
+	}
+	if dreg == nil {
+		return append(out, bytes.Repeat([]byte{'x'}, sz*2)...)
+	}
+	buf := make([]byte, sz)
+	if len(dreg.Bytes) > 0 {
+		copy(buf, dreg.Bytes)
+	} else {
+		var u [8]byte
+		binary.LittleEndian.PutUint64(u[:], dreg.Uint64Val)
+		copy(buf, u[:])
+	}
+	return appendHex(out, buf)
+}
+
+// parseRegister decodes the hex encoded value of reg.
+func parseRegister(reg stubRegister, in []byte) (*op.DwarfRegister, error) {
+	buf, err := parseHex(in)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) != reg.bitsize/8 {
+		return nil, fmt.Errorf("wrong size for register %s", reg.name)
+	}
+	if len(buf) <= 8 {
+		var u [8]byte
+		copy(u[:], buf)
+		return op.DwarfRegisterFromUint64(binary.LittleEndian.Uint64(u[:])), nil
+	}
+	return op.DwarfRegisterFromBytes(buf), nil
+}
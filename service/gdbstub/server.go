@@ -0,0 +1,734 @@
+// Package gdbstub implements a stub for the GDB Remote Serial Protocol,
+// exposing a process debugged by Delve to gdb, lldb and any other
+// debugger that can connect to a remote stub.
+//
+// The protocol is documented at:
+// https://sourceware.org/gdb/current/onlinedocs/gdb/Remote-Protocol.html
+package gdbstub
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-delve/delve/pkg/dwarf/op"
+	"github.com/go-delve/delve/pkg/logflags"
+	"github.com/go-delve/delve/pkg/proc/gdbserial"
+	"github.com/go-delve/delve/service"
+	"github.com/go-delve/delve/service/api"
+	"github.com/go-delve/delve/service/debugger"
+	"github.com/go-delve/delve/service/internal/sameuser"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxPacketSize is the maximum size of a packet we accept, it is
+	// advertised to the client in the reply to qSupported.
+	maxPacketSize = 0x4000
+
+	sigint  = 2
+	sigtrap = 5
+)
+
+// Server exposes the target process over the GDB Remote Serial Protocol.
+// Connections are served one at a time, goroutine aware functionality is
+// available through the monitor commands of the client (see monitor.go).
+type Server struct {
+	// config is all the information necessary to start the debugger and server.
+	config *service.Config
+	// listener is used to accept connections.
+	listener net.Listener
+	// stopChan is used to stop the listener goroutine.
+	stopChan chan struct{}
+	// debugger is the debugger service.
+	debugger *debugger.Debugger
+	// arch describes the register layout exposed to clients.
+	arch *stubArch
+	log  *logrus.Entry
+}
+
+// NewServer creates a new Server, the target process is launched or
+// attached to by Run.
+func NewServer(config *service.Config) *Server {
+	logger := logflags.GdbStubLogger()
+	if config.Debugger.Foreground {
+		// Print listener address
+		logflags.WriteGdbStubListeningMessage(config.Listener.Addr())
+		logger.Debug("gdb stub pid = ", os.Getpid())
+	}
+	return &Server{
+		config:   config,
+		listener: config.Listener,
+		stopChan: make(chan struct{}),
+		log:      logger,
+	}
+}
+
+// Stop stops the server and detaches from the target process, killing
+// it if it was launched by Delve.
+func (s *Server) Stop() error {
+	s.log.Debug("stopping")
+	close(s.stopChan)
+	s.listener.Close()
+	if s.debugger == nil {
+		return nil
+	}
+	if s.debugger.IsRunning() {
+		s.debugger.Command(&api.DebuggerCommand{Name: api.Halt}, nil)
+	}
+	kill := s.config.Debugger.AttachPid == 0
+	return s.debugger.Detach(kill)
+}
+
+// Run starts the debugger and starts accepting connections. Run does not
+// wait for a client to connect.
+func (s *Server) Run() error {
+	var err error
+
+	config := s.config.Debugger
+	if s.debugger, err = debugger.New(&config, s.config.ProcessArgs); err != nil {
+		return err
+	}
+	if s.arch, err = newStubArch(s.debugger.Target().BinInfo().Arch.Name); err != nil {
+		s.debugger.Detach(s.config.Debugger.AttachPid == 0)
+		s.debugger = nil
+		return err
+	}
+
+	go func() {
+		defer s.listener.Close()
+		for {
+			c, err := s.listener.Accept()
+			if err != nil {
+				select {
+				case <-s.stopChan:
+					// We were supposed to exit, do nothing and return
+					return
+				default:
+					panic(err)
+				}
+			}
+
+			if s.config.CheckLocalConnUser {
+				if !sameuser.CanAccept(s.listener.Addr(), c.LocalAddr(), c.RemoteAddr()) {
+					c.Close()
+					continue
+				}
+			}
+
+			// The protocol has no notion of concurrent clients, the next
+			// connection is accepted after this one ends.
+			ended := newSession(s, c).serve()
+			if ended || !s.config.AcceptMulti {
+				if s.config.DisconnectChan != nil {
+					close(s.config.DisconnectChan)
+					s.config.DisconnectChan = nil
+				}
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// session is a connection with a client.
+type session struct {
+	s    *Server
+	d    *debugger.Debugger
+	conn *gdbserial.StubConn
+	log  *logrus.Entry
+
+	// gthread and cthread are the threads selected by the Hg and Hc
+	// packets, zero means any thread.
+	gthread, cthread int
+
+	// bps maps the addresses of the breakpoints set by the client to the
+	// IDs of the corresponding Delve breakpoints.
+	bps map[uint64]int
+
+	// ended is set when the client detaches or kills the target process.
+	ended bool
+
+	mu          sync.Mutex
+	interrupted bool // the target was stopped by an interrupt request
+}
+
+func newSession(s *Server, c io.ReadWriteCloser) *session {
+	return &session{
+		s:    s,
+		d:    s.debugger,
+		conn: gdbserial.NewStubConn(c),
+		log:  s.log,
+		bps:  make(map[uint64]int),
+	}
+}
+
+// serve handles packets until the connection is closed, it returns true
+// if the target process was killed or detached from.
+func (ss *session) serve() bool {
+	defer ss.conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	pkts := make(chan []byte)
+
+	// Packets are read by a separate goroutine so that interrupt requests
+	// can be received while the target process is running.
+	go func() {
+		defer close(pkts)
+		for {
+			pkt, err := ss.conn.ReadPacket()
+			if err == gdbserial.ErrStubInterrupt {
+				ss.interrupt()
+				continue
+			}
+			if err != nil {
+				if err != io.EOF {
+					ss.log.Debugf("error reading packet: %v", err)
+				}
+				return
+			}
+			select {
+			case pkts <- append([]byte(nil), pkt...):
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for pkt := range pkts {
+		reply, send := ss.handle(pkt)
+		if send {
+			if err := ss.conn.WritePacket(reply); err != nil {
+				ss.log.Debugf("error writing packet: %v", err)
+				break
+			}
+		}
+		if ss.ended {
+			break
+		}
+	}
+	ss.removeBreakpoints()
+	return ss.ended
+}
+
+func (ss *session) interrupt() {
+	ss.mu.Lock()
+	ss.interrupted = true
+	ss.mu.Unlock()
+	if ss.d.IsRunning() {
+		ss.d.Command(&api.DebuggerCommand{Name: api.Halt}, nil)
+	}
+}
+
+// errorReply returns a generic error reply, the protocol has no way to
+// report the error message for most packets so it is only logged.
+func (ss *session) errorReply(err error) []byte {
+	if err != nil {
+		ss.log.Debugf("error: %v", err)
+	}
+	return []byte("E01")
+}
+
+var okReply = []byte("OK")
+
+// handle executes the command in pkt and returns its reply, send is false
+// if the command does not have a reply.
+func (ss *session) handle(pkt []byte) (reply []byte, send bool) {
+	if len(pkt) == 0 {
+		return nil, true
+	}
+	cmd, args := pkt[0], string(pkt[1:])
+	switch cmd {
+	case '?':
+		state, err := ss.d.State(false)
+		if err != nil {
+			return ss.errorReply(err), true
+		}
+		return ss.stopReply(state), true
+	case 'q', 'Q':
+		return ss.query(string(pkt)), true
+	case 'v':
+		return ss.vpacket(string(pkt))
+	case 'H':
+		if len(args) < 1 {
+			return ss.errorReply(nil), true
+		}
+		tid, err := parseThreadID(args[1:])
+		if err != nil {
+			return ss.errorReply(err), true
+		}
+		switch args[0] {
+		case 'g':
+			ss.gthread = tid
+		case 'c':
+			ss.cthread = tid
+		default:
+			return ss.errorReply(nil), true
+		}
+		return okReply, true
+	case 'T':
+		tid, err := parseThreadID(args)
+		if err != nil {
+			return ss.errorReply(err), true
+		}
+		th, err := ss.d.FindThread(tid)
+		if err != nil || th == nil {
+			return ss.errorReply(err), true
+		}
+		return okReply, true
+	case 'g':
+		return ss.readRegisters(), true
+	case 'G':
+		return ss.writeRegisters(args), true
+	case 'p':
+		return ss.readRegister(args), true
+	case 'P':
+		return ss.writeRegister(args), true
+	case 'm':
+		return ss.readMemory(args), true
+	case 'M', 'X':
+		return ss.writeMemory(args, cmd == 'X'), true
+	case 'Z', 'z':
+		return ss.breakpoint(args, cmd == 'Z'), true
+	case 'c', 'C':
+		return ss.resume(api.Continue, ss.cthread), true
+	case 's', 'S':
+		return ss.resume(api.StepInstruction, ss.cthread), true
+	case 'D':
+		ss.ended = true
+		if err := ss.d.Detach(false); err != nil {
+			return ss.errorReply(err), true
+		}
+		return okReply, true
+	case 'k':
+		ss.ended = true
+		ss.d.Detach(true)
+		return nil, false
+	default:
+		return nil, true
+	}
+}
+
+func (ss *session) query(pkt string) []byte {
+	switch {
+	case strings.HasPrefix(pkt, "qSupported"):
+		return []byte(fmt.Sprintf("PacketSize=%x;QStartNoAckMode+;qXfer:features:read+;vContSupported+", maxPacketSize))
+	case pkt == "QStartNoAckMode":
+		// Acknowledgments are disabled before sending the reply, the client
+		// stops sending them as soon as it receives it.
+		ss.conn.DisableAck()
+		return okReply
+	case strings.HasPrefix(pkt, "qXfer:features:read:"):
+		return ss.readFeatures(pkt[len("qXfer:features:read:"):])
+	case pkt == "qAttached":
+		if ss.s.config.Debugger.AttachPid != 0 {
+			return []byte("1")
+		}
+		return []byte("0")
+	case pkt == "qC":
+		tid, err := ss.currentThread()
+		if err != nil {
+			return ss.errorReply(err)
+		}
+		return []byte(fmt.Sprintf("QC%x", tid))
+	case pkt == "qfThreadInfo":
+		threads, err := ss.d.Threads()
+		if err != nil {
+			return ss.errorReply(err)
+		}
+		var buf bytes.Buffer
+		buf.WriteByte('m')
+		for i, th := range threads {
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(&buf, "%x", th.ThreadID())
+		}
+		return buf.Bytes()
+	case pkt == "qsThreadInfo":
+		return []byte("l")
+	case strings.HasPrefix(pkt, "qThreadExtraInfo,"):
+		tid, err := parseThreadID(pkt[len("qThreadExtraInfo,"):])
+		if err != nil {
+			return ss.errorReply(err)
+		}
+		return []byte(hex.EncodeToString([]byte(ss.threadExtraInfo(tid))))
+	case strings.HasPrefix(pkt, "qRcmd,"):
+		cmd, err := hex.DecodeString(pkt[len("qRcmd,"):])
+		if err != nil {
+			return ss.errorReply(err)
+		}
+		return ss.monitor(string(cmd))
+	case strings.HasPrefix(pkt, "qSymbol:"):
+		return okReply
+	default:
+		return nil
+	}
+}
+
+func (ss *session) vpacket(pkt string) (reply []byte, send bool) {
+	switch {
+	case pkt == "vCont?":
+		return []byte("vCont;c;C;s;S"), true
+	case strings.HasPrefix(pkt, "vCont;"):
+		// Delve always resumes all threads, the only thing that matters is
+		// whether one of them is stepped.
+		for _, action := range strings.Split(pkt[len("vCont;"):], ";") {
+			if action == "" || (action[0] != 's' && action[0] != 'S') {
+				continue
+			}
+			tid := ss.cthread
+			if i := strings.Index(action, ":"); i >= 0 {
+				var err error
+				tid, err = parseThreadID(action[i+1:])
+				if err != nil {
+					return ss.errorReply(err), true
+				}
+			}
+			return ss.resume(api.StepInstruction, tid), true
+		}
+		return ss.resume(api.Continue, 0), true
+	case strings.HasPrefix(pkt, "vKill"):
+		ss.ended = true
+		if err := ss.d.Detach(true); err != nil {
+			return ss.errorReply(err), true
+		}
+		return okReply, true
+	default:
+		return nil, true
+	}
+}
+
+// resume resumes the target process and returns the stop reply, if tid is
+// not zero the thread is selected first.
+func (ss *session) resume(cmd string, tid int) []byte {
+	if tid > 0 {
+		if _, err := ss.d.Command(&api.DebuggerCommand{Name: api.SwitchThread, ThreadID: tid}, nil); err != nil {
+			return ss.errorReply(err)
+		}
+	}
+	ss.mu.Lock()
+	ss.interrupted = false
+	ss.mu.Unlock()
+	state, err := ss.d.Command(&api.DebuggerCommand{Name: cmd}, nil)
+	if err != nil {
+		ss.log.Debugf("%s: %v", cmd, err)
+		if state, err = ss.d.State(false); err != nil {
+			return ss.errorReply(err)
+		}
+	}
+	ss.gthread, ss.cthread = 0, 0
+	return ss.stopReply(state)
+}
+
+func (ss *session) stopReply(state *api.DebuggerState) []byte {
+	if state.Exited {
+		return []byte(fmt.Sprintf("W%02x", uint8(state.ExitStatus)))
+	}
+	sig := sigtrap
+	ss.mu.Lock()
+	if ss.interrupted {
+		sig = sigint
+	}
+	ss.mu.Unlock()
+	if state.CurrentThread == nil {
+		return []byte(fmt.Sprintf("S%02x", sig))
+	}
+	return []byte(fmt.Sprintf("T%02xthread:%x;", sig, state.CurrentThread.ID))
+}
+
+// currentThread returns the ID of the current thread of the target.
+func (ss *session) currentThread() (int, error) {
+	ss.d.LockTarget()
+	defer ss.d.UnlockTarget()
+	t := ss.d.Target()
+	if _, err := t.Valid(); err != nil {
+		return 0, err
+	}
+	return t.CurrentThread().ThreadID(), nil
+}
+
+// registersThread returns the thread selected for register access.
+func (ss *session) registersThread() (int, error) {
+	if ss.gthread > 0 {
+		return ss.gthread, nil
+	}
+	return ss.currentThread()
+}
+
+func (ss *session) threadRegisters() (*op.DwarfRegisters, int, error) {
+	tid, err := ss.registersThread()
+	if err != nil {
+		return nil, 0, err
+	}
+	dregs, err := ss.d.ThreadRegisters(tid, true)
+	return dregs, tid, err
+}
+
+func (ss *session) readRegisters() []byte {
+	dregs, _, err := ss.threadRegisters()
+	if err != nil {
+		return ss.errorReply(err)
+	}
+	var out []byte
+	for _, reg := range ss.s.arch.regs {
+		out = appendRegister(out, reg, dregs)
+	}
+	return out
+}
+
+func (ss *session) writeRegisters(args string) []byte {
+	dregs, tid, err := ss.threadRegisters()
+	if err != nil {
+		return ss.errorReply(err)
+	}
+	in := []byte(args)
+	for _, reg := range ss.s.arch.regs {
+		sz := reg.bitsize / 4
+		if len(in) < sz {
+			break
+		}
+		val := in[:sz]
+		in = in[sz:]
+		if reg.dwarf == noRegnum || val[0] == 'x' {
+			continue
+		}
+		// Only registers that changed are written, Delve can not write
+		// all the registers on all backends.
+		if bytes.Equal(val, appendRegister(nil, reg, dregs)) {
+			continue
+		}
+		if err := ss.setRegister(tid, reg, val); err != nil {
+			return ss.errorReply(err)
+		}
+	}
+	return okReply
+}
+
+func (ss *session) readRegister(args string) []byte {
+	n, err := strconv.ParseUint(args, 16, 32)
+	if err != nil || n >= uint64(len(ss.s.arch.regs)) {
+		return ss.errorReply(err)
+	}
+	dregs, _, err := ss.threadRegisters()
+	if err != nil {
+		return ss.errorReply(err)
+	}
+	return appendRegister(nil, ss.s.arch.regs[n], dregs)
+}
+
+func (ss *session) writeRegister(args string) []byte {
+	i := strings.Index(args, "=")
+	if i < 0 {
+		return ss.errorReply(nil)
+	}
+	n, err := strconv.ParseUint(args[:i], 16, 32)
+	if err != nil || n >= uint64(len(ss.s.arch.regs)) {
+		return ss.errorReply(err)
+	}
+	reg := ss.s.arch.regs[n]
+	if reg.dwarf == noRegnum {
+		return ss.errorReply(nil)
+	}
+	tid, err := ss.registersThread()
+	if err != nil {
+		return ss.errorReply(err)
+	}
+	if err := ss.setRegister(tid, reg, []byte(args[i+1:])); err != nil {
+		return ss.errorReply(err)
+	}
+	return okReply
+}
+
+func (ss *session) setRegister(tid int, reg stubRegister, val []byte) error {
+	dreg, err := parseRegister(reg, val)
+	if err != nil {
+		return err
+	}
+	ss.d.LockTarget()
+	defer ss.d.UnlockTarget()
+	t := ss.d.Target()
+	th, ok := t.FindThread(tid)
+	if !ok {
+		return fmt.Errorf("couldn't find thread %d", tid)
+	}
+	if err := th.SetReg(reg.dwarf, dreg); err != nil {
+		return err
+	}
+	t.ClearCaches()
+	return nil
+}
+
+func (ss *session) readMemory(args string) []byte {
+	addr, sz, _, err := parseAddrLength(args)
+	if err != nil {
+		return ss.errorReply(err)
+	}
+	if sz > maxPacketSize/2 {
+		sz = maxPacketSize / 2
+	}
+	data, err := ss.d.ExamineMemory(addr, int(sz))
+	if err != nil {
+		return ss.errorReply(err)
+	}
+	return appendHex(nil, data)
+}
+
+func (ss *session) writeMemory(args string, binary bool) []byte {
+	addr, sz, rest, err := parseAddrLength(args)
+	if err != nil {
+		return ss.errorReply(err)
+	}
+	if sz == 0 {
+		// used by clients to probe support for the X packet
+		return okReply
+	}
+	data := []byte(rest)
+	if !binary {
+		data, err = parseHex(data)
+		if err != nil {
+			return ss.errorReply(err)
+		}
+	}
+	if uint64(len(data)) != sz {
+		return ss.errorReply(nil)
+	}
+	if _, err := ss.d.WriteMemory(addr, data); err != nil {
+		return ss.errorReply(err)
+	}
+	return okReply
+}
+
+// breakpoint handles the Z and z packets, only software breakpoints are
+// supported.
+func (ss *session) breakpoint(args string, set bool) []byte {
+	fields := strings.Split(args, ",")
+	if len(fields) < 2 || fields[0] != "0" {
+		return nil
+	}
+	addr, err := strconv.ParseUint(fields[1], 16, 64)
+	if err != nil {
+		return ss.errorReply(err)
+	}
+	if set {
+		if _, ok := ss.bps[addr]; ok {
+			return okReply
+		}
+		bp, err := ss.d.CreateBreakpoint(&api.Breakpoint{Addr: addr})
+		if err != nil {
+			return ss.errorReply(err)
+		}
+		ss.bps[addr] = bp.ID
+		return okReply
+	}
+	id, ok := ss.bps[addr]
+	if !ok {
+		return okReply
+	}
+	delete(ss.bps, addr)
+	if bp := ss.d.FindBreakpoint(id); bp != nil {
+		if _, err := ss.d.ClearBreakpoint(bp); err != nil {
+			return ss.errorReply(err)
+		}
+	}
+	return okReply
+}
+
+// removeBreakpoints removes the breakpoints left over by the client when
+// the connection ends.
+func (ss *session) removeBreakpoints() {
+	if ss.ended {
+		return
+	}
+	for addr, id := range ss.bps {
+		if bp := ss.d.FindBreakpoint(id); bp != nil {
+			ss.d.ClearBreakpoint(bp)
+		}
+		delete(ss.bps, addr)
+	}
+}
+
+func (ss *session) readFeatures(args string) []byte {
+	i := strings.Index(args, ":")
+	if i < 0 || args[:i] != "target.xml" {
+		return ss.errorReply(nil)
+	}
+	off, sz, _, err := parseAddrLength(args[i+1:])
+	if err != nil {
+		return ss.errorReply(err)
+	}
+	xml := ss.s.arch.targetXML()
+	if off >= uint64(len(xml)) {
+		return []byte("l")
+	}
+	xml = xml[off:]
+	if uint64(len(xml)) <= sz {
+		return append([]byte("l"), xml...)
+	}
+	return append([]byte("m"), xml[:sz]...)
+}
+
+// threadExtraInfo describes the goroutine running on thread tid.
+func (ss *session) threadExtraInfo(tid int) string {
+	gs, _, err := ss.d.Goroutines(0, 0)
+	if err != nil {
+		return ""
+	}
+	for _, g := range gs {
+		if g.Thread != nil && g.Thread.ThreadID() == tid {
+			loc := g.UserCurrent()
+			if loc.Fn != nil {
+				return fmt.Sprintf("goroutine %d %s", g.ID, loc.Fn.Name)
+			}
+			return fmt.Sprintf("goroutine %d", g.ID)
+		}
+	}
+	return ""
+}
+
+func parseThreadID(s string) (int, error) {
+	if s == "-1" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(s, 16, 32)
+	return int(n), err
+}
+
+// parseAddrLength parses arguments in the form 'addr,length[:rest]'.
+func parseAddrLength(args string) (addr, sz uint64, rest string, err error) {
+	if i := strings.Index(args, ":"); i >= 0 {
+		args, rest = args[:i], args[i+1:]
+	}
+	fields := strings.Split(args, ",")
+	if len(fields) != 2 {
+		return 0, 0, "", errors.New("malformed packet")
+	}
+	if addr, err = strconv.ParseUint(fields[0], 16, 64); err != nil {
+		return 0, 0, "", err
+	}
+	if sz, err = strconv.ParseUint(fields[1], 16, 64); err != nil {
+		return 0, 0, "", err
+	}
+	return addr, sz, rest, nil
+}
+
+func appendHex(out, data []byte) []byte {
+	n := len(out)
+	out = append(out, make([]byte, hex.EncodedLen(len(data)))...)
+	hex.Encode(out[n:], data)
+	return out
+}
+
+func parseHex(in []byte) ([]byte, error) {
+	out := make([]byte, hex.DecodedLen(len(in)))
+	_, err := hex.Decode(out, in)
+	return out, err
+}
@@ -0,0 +1,210 @@
+package gdbstub
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-delve/delve/pkg/logflags"
+	"github.com/go-delve/delve/pkg/proc"
+	"github.com/go-delve/delve/pkg/proc/gdbserial"
+	protest "github.com/go-delve/delve/pkg/proc/test"
+	"github.com/go-delve/delve/service"
+	"github.com/go-delve/delve/service/debugger"
+)
+
+var testBackend string
+
+func TestMain(m *testing.M) {
+	flag.StringVar(&testBackend, "backend", "", "selects backend")
+	var logOutput string
+	flag.StringVar(&logOutput, "log-output", "", "configures log output")
+	flag.Parse()
+	protest.DefaultTestBackend(&testBackend)
+	logflags.Setup(logOutput != "", logOutput, "")
+	os.Exit(protest.RunTestsWithFixtures(m))
+}
+
+// testClient sends packets to the stub, the framing of packets is
+// symmetric so StubConn can be used on the client side too.
+type testClient struct {
+	t    *testing.T
+	conn *gdbserial.StubConn
+}
+
+func (c *testClient) exec(pkt string) string {
+	c.t.Helper()
+	if err := c.conn.WritePacket([]byte(pkt)); err != nil {
+		c.t.Fatalf("writing %q: %v", pkt, err)
+	}
+	reply, err := c.conn.ReadPacket()
+	if err != nil {
+		c.t.Fatalf("reading reply to %q: %v", pkt, err)
+	}
+	return string(reply)
+}
+
+func (c *testClient) monitor(cmd string) string {
+	c.t.Helper()
+	if err := c.conn.WritePacket([]byte("qRcmd," + hex.EncodeToString([]byte(cmd)))); err != nil {
+		c.t.Fatalf("writing monitor command %q: %v", cmd, err)
+	}
+	var out strings.Builder
+	for {
+		reply, err := c.conn.ReadPacket()
+		if err != nil {
+			c.t.Fatalf("reading reply to monitor command %q: %v", cmd, err)
+		}
+		if len(reply) == 0 || reply[0] != 'O' || string(reply) == "OK" {
+			if string(reply) != "OK" {
+				c.t.Fatalf("unexpected reply to monitor command %q: %q", cmd, reply)
+			}
+			return out.String()
+		}
+		b, err := hex.DecodeString(string(reply[1:]))
+		if err != nil {
+			c.t.Fatalf("malformed output packet %q: %v", reply, err)
+		}
+		out.Write(b)
+	}
+}
+
+func withTestStub(name string, t *testing.T, fn func(s *Server, c *testClient)) {
+	if runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+		t.Skip("gdbserve is not supported on " + runtime.GOARCH)
+	}
+	fixture := protest.BuildFixture(name, 0)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't start listener: %s\n", err)
+	}
+	disconnectChan := make(chan struct{})
+	server := NewServer(&service.Config{
+		Listener:       listener,
+		ProcessArgs:    []string{fixture.Path},
+		DisconnectChan: disconnectChan,
+		Debugger: debugger.Config{
+			Backend:     testBackend,
+			ExecuteKind: debugger.ExecutingExistingFile,
+		},
+	})
+	if err := server.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &testClient{t: t, conn: gdbserial.NewStubConn(conn)}
+	fn(server, c)
+	conn.Close()
+	<-disconnectChan
+}
+
+// regOffset returns the offset, in the reply to the 'g' packet, of the
+// register with the specified name.
+func regOffset(s *Server, name string) (int, int) {
+	off := 0
+	for _, reg := range s.arch.regs {
+		if reg.name == name {
+			return off, reg.bitsize / 4
+		}
+		off += reg.bitsize / 4
+	}
+	panic("unknown register " + name)
+}
+
+func parseRegisterHex(t *testing.T, in string) uint64 {
+	b, err := hex.DecodeString(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v
+}
+
+func TestStubBreakpoint(t *testing.T) {
+	withTestStub("testnextprog", t, func(s *Server, c *testClient) {
+		if reply := c.exec("QStartNoAckMode"); reply != "OK" {
+			t.Fatalf("QStartNoAckMode: %q", reply)
+		}
+		c.conn.DisableAck()
+
+		if reply := c.exec("qSupported:swbreak+"); !strings.Contains(reply, "qXfer:features:read+") {
+			t.Fatalf("qSupported: %q", reply)
+		}
+		if reply := c.exec("qXfer:features:read:target.xml:0,ffff"); !strings.HasPrefix(reply, "l") || !strings.Contains(reply, "<architecture>") {
+			t.Fatalf("target.xml: %q", reply)
+		}
+		if reply := c.exec("?"); !strings.HasPrefix(reply, "T05thread:") {
+			t.Fatalf("stop reply: %q", reply)
+		}
+
+		pcs, err := proc.FindFunctionLocation(s.debugger.Target(), "main.helloworld", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := pcs[0]
+
+		if reply := c.exec(fmt.Sprintf("Z0,%x,1", addr)); reply != "OK" {
+			t.Fatalf("Z0: %q", reply)
+		}
+		reply := c.exec("vCont;c")
+		if !strings.HasPrefix(reply, "T05thread:") {
+			t.Fatalf("stop reply after continue: %q", reply)
+		}
+		tid, err := strconv.ParseUint(strings.TrimSuffix(reply[len("T05thread:"):], ";"), 16, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply := c.exec(fmt.Sprintf("Hg%x", tid)); reply != "OK" {
+			t.Fatalf("Hg: %q", reply)
+		}
+
+		pcName := "rip"
+		if runtime.GOARCH == "arm64" {
+			pcName = "pc"
+		}
+		regs := c.exec("g")
+		off, sz := regOffset(s, pcName)
+		if len(regs) < off+sz {
+			t.Fatalf("short reply to 'g': %q", regs)
+		}
+		if pc := parseRegisterHex(t, regs[off:off+sz]); pc != addr {
+			t.Fatalf("wrong pc %#x, expected %#x", pc, addr)
+		}
+
+		if reply := c.exec(fmt.Sprintf("m%x,4", addr)); len(reply) != 8 {
+			t.Fatalf("m: %q", reply)
+		}
+
+		if out := c.monitor("goroutines"); !strings.Contains(out, "* Goroutine 1 - main.helloworld") {
+			t.Fatalf("monitor goroutines: %q", out)
+		}
+		if out := c.monitor("stack"); !strings.Contains(out, "main.helloworld") || !strings.Contains(out, "main.main") {
+			t.Fatalf("monitor stack: %q", out)
+		}
+		if out := c.monitor("nonexistent"); !strings.Contains(out, "unknown command") {
+			t.Fatalf("monitor nonexistent: %q", out)
+		}
+
+		if reply := c.exec(fmt.Sprintf("z0,%x,1", addr)); reply != "OK" {
+			t.Fatalf("z0: %q", reply)
+		}
+		if reply := c.exec("c"); !strings.HasPrefix(reply, "W") {
+			t.Fatalf("expected exit after removing the breakpoint: %q", reply)
+		}
+		c.exec("vKill;1")
+	})
+}
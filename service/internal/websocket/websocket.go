@@ -0,0 +1,256 @@
+// Package websocket implements the server side of the WebSocket protocol
+// (RFC 6455), enough to carry the JSON-RPC messages of the headless server.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// acceptGUID is appended to the key sent by the client to compute the
+// accept key of the handshake.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxFrameLen is the maximum length of the payload of a frame received
+// from the client.
+const maxFrameLen = 64 << 20
+
+// Frame opcodes.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// Conn is a WebSocket connection. Each call to Write sends a text message,
+// Read returns the payload of the data messages received, in order.
+type Conn struct {
+	rw io.ReadWriteCloser
+	br *bufio.Reader
+
+	// payload is the unread part of the current frame.
+	payload []byte
+	closed  bool
+
+	wmu sync.Mutex
+}
+
+// Accept reads the opening handshake of a WebSocket connection from br and
+// replies to it on rw. If checkOrigin is not nil the connection is
+// rejected unless it returns true for the Origin header sent by the
+// client.
+func Accept(rw io.ReadWriteCloser, br *bufio.Reader, checkOrigin func(origin *url.URL, host string) bool) (*Conn, error) {
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return nil, err
+	}
+	fail := func(status int, err error) (*Conn, error) {
+		fmt.Fprintf(rw, "HTTP/1.1 %d %s\r\nConnection: close\r\n\r\n", status, http.StatusText(status))
+		return nil, err
+	}
+	if req.Method != http.MethodGet || !headerContains(req.Header, "Connection", "upgrade") || !headerContains(req.Header, "Upgrade", "websocket") {
+		return fail(http.StatusBadRequest, errors.New("not a websocket handshake"))
+	}
+	if req.Header.Get("Sec-Websocket-Version") != "13" {
+		return fail(http.StatusBadRequest, errors.New("unsupported websocket version"))
+	}
+	key := req.Header.Get("Sec-Websocket-Key")
+	if key == "" {
+		return fail(http.StatusBadRequest, errors.New("missing websocket key"))
+	}
+	if checkOrigin != nil {
+		var origin *url.URL
+		if o := req.Header.Get("Origin"); o != "" {
+			origin, err = url.Parse(o)
+			if err != nil {
+				return fail(http.StatusForbidden, fmt.Errorf("invalid origin %q", o))
+			}
+		}
+		if !checkOrigin(origin, req.Host) {
+			return fail(http.StatusForbidden, fmt.Errorf("origin %q not allowed", req.Header.Get("Origin")))
+		}
+	}
+	_, err = fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key))
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{rw: rw, br: br}, nil
+}
+
+// CheckOrigin returns a function, to be passed to Accept, that accepts
+// clients that aren't browsers, which don't send an Origin header, and web
+// pages with one of the allowed origins ("scheme://host:port", "*" allows
+// any origin).
+// If loopback is true the Host header must also refer to a loopback name or
+// address: a web page that reaches a server listening on a loopback address
+// through DNS rebinding sends the name of its own domain.
+func CheckOrigin(allowed []string, loopback bool) func(origin *url.URL, host string) bool {
+	return func(origin *url.URL, host string) bool {
+		if loopback && !IsLoopbackHost(host) {
+			return false
+		}
+		if origin == nil {
+			return true
+		}
+		for _, o := range allowed {
+			if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin.Scheme+"://"+origin.Host) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// IsLoopbackHost returns true if host, the value of a Host header, is
+// localhost or a loopback address.
+func IsLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	io.WriteString(h, key+acceptGUID)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContains(h http.Header, name, value string) bool {
+	for _, v := range h.Values(name) {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Read reads the payload of the data messages sent by the client. Control
+// frames are handled transparently, io.EOF is returned after the client
+// closes the connection.
+func (c *Conn) Read(p []byte) (int, error) {
+	for len(c.payload) == 0 {
+		if c.closed {
+			return 0, io.EOF
+		}
+		if err := c.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.payload)
+	c.payload = c.payload[n:]
+	return n, nil
+}
+
+// readFrame reads the next frame sent by the client.
+func (c *Conn) readFrame() error {
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.br, hdr[:]); err != nil {
+		return err
+	}
+	opcode := hdr[0] & 0xf
+	masked := hdr[1]&0x80 != 0
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return err
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return err
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if !masked {
+		return errors.New("websocket: unmasked frame from client")
+	}
+	if n > maxFrameLen {
+		return errors.New("websocket: frame too large")
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	switch opcode {
+	case opText, opBinary, opContinuation:
+		c.payload = payload
+	case opPing:
+		return c.writeFrame(opPong, payload)
+	case opPong:
+		// nothing to do
+	case opClose:
+		c.closed = true
+		if len(payload) > 2 {
+			payload = payload[:2]
+		}
+		return c.writeFrame(opClose, payload)
+	default:
+		return fmt.Errorf("websocket: unknown opcode %#x", opcode)
+	}
+	return nil
+}
+
+// Write sends p as a text message.
+func (c *Conn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(opText, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	buf := make([]byte, 0, len(payload)+10)
+	buf = append(buf, 0x80|opcode)
+	switch n := len(payload); {
+	case n < 126:
+		buf = append(buf, byte(n))
+	case n <= 0xffff:
+		buf = append(buf, 126, 0, 0)
+		binary.BigEndian.PutUint16(buf[2:], uint16(n))
+	default:
+		buf = append(buf, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(buf[2:], uint64(n))
+	}
+	buf = append(buf, payload...)
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.rw.Write(buf)
+	return err
+}
+
+// Close closes the underlying connection.
+func (c *Conn) Close() error {
+	return c.rw.Close()
+}
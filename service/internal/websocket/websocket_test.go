@@ -0,0 +1,194 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// writeClientFrame writes a masked frame, like clients do.
+func writeClientFrame(t *testing.T, w io.Writer, opcode byte, payload []byte) {
+	t.Helper()
+	mask := [4]byte{1, 2, 3, 4}
+	buf := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		buf = append(buf, 0x80|byte(n))
+	default:
+		buf = append(buf, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(buf[2:], uint16(n))
+	}
+	buf = append(buf, mask[:]...)
+	for i := range payload {
+		buf = append(buf, payload[i]^mask[i%4])
+	}
+	if _, err := w.Write(buf); err != nil {
+		t.Error(err)
+	}
+}
+
+// readServerFrame reads an unmasked frame, like servers send.
+func readServerFrame(t *testing.T, r *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		t.Fatal(err)
+	}
+	n := int(hdr[1] & 0x7f)
+	if n == 126 {
+		var b [2]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			t.Fatal(err)
+		}
+		n = int(binary.BigEndian.Uint16(b[:]))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return hdr[0] & 0xf, payload
+}
+
+const handshake = "GET / HTTP/1.1\r\nHost: 127.0.0.1:1234\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n%s\r\n"
+
+func TestAcceptKey(t *testing.T) {
+	// Example from RFC 6455, section 1.3.
+	if got, want := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestConn(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	accepted := make(chan *Conn)
+	go func() {
+		conn, err := Accept(server, bufio.NewReader(server), CheckOrigin([]string{"http://127.0.0.1:1234"}, true))
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- conn
+	}()
+
+	go io.WriteString(client, strings.Replace(handshake, "%s", "Origin: http://127.0.0.1:1234\r\n", 1))
+	cr := bufio.NewReader(client)
+	resp, err := http.ReadResponse(cr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("wrong status %s", resp.Status)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("wrong accept key %q", got)
+	}
+	conn := <-accepted
+	if conn == nil {
+		return
+	}
+
+	// A ping is answered with a pong, messages are read in order.
+	msg := strings.Repeat("x", 200)
+	buf := make([]byte, len(msg))
+	done := make(chan error)
+	go func() {
+		_, err := io.ReadFull(conn, buf)
+		done <- err
+	}()
+	go func() {
+		writeClientFrame(t, client, opPing, []byte("ping"))
+		writeClientFrame(t, client, opText, []byte(msg))
+	}()
+	if op, payload := readServerFrame(t, cr); op != opPong || string(payload) != "ping" {
+		t.Errorf("got %#x %q, want pong", op, payload)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != msg {
+		t.Errorf("got %q, want %q", buf, msg)
+	}
+
+	go conn.Write([]byte("reply"))
+	if op, payload := readServerFrame(t, cr); op != opText || string(payload) != "reply" {
+		t.Errorf("got %#x %q, want text %q", op, payload, "reply")
+	}
+
+	// Closing the connection is acknowledged and ends the stream.
+	go writeClientFrame(t, client, opClose, []byte{0x3, 0xe8})
+	go func() {
+		_, err := conn.Read(buf)
+		done <- err
+	}()
+	if op, _ := readServerFrame(t, cr); op != opClose {
+		t.Errorf("got %#x, want close", op)
+	}
+	if err := <-done; err != io.EOF {
+		t.Errorf("got %v, want EOF", err)
+	}
+}
+
+func TestAcceptOrigin(t *testing.T) {
+	for _, tc := range []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{"Origin: http://localhost:8080\r\n", true},
+		{"Origin: http://127.0.0.1:1234\r\n", false},
+		{"Origin: http://evil.example.com\r\n", false},
+	} {
+		server, client := net.Pipe()
+		go io.WriteString(client, strings.Replace(handshake, "%s", tc.origin, 1))
+		go func() {
+			resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+			if err == nil {
+				resp.Body.Close()
+			}
+			client.Close()
+		}()
+		_, err := Accept(server, bufio.NewReader(server), CheckOrigin([]string{"http://localhost:8080/"}, true))
+		if (err == nil) != tc.ok {
+			t.Errorf("%q: got error %v", tc.origin, err)
+		}
+		server.Close()
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	allowed := []string{"http://localhost:8080"}
+	for _, tc := range []struct {
+		origin, host string
+		loopback     bool
+		ok           bool
+	}{
+		{"", "127.0.0.1:1234", true, true},
+		{"http://localhost:8080", "127.0.0.1:1234", true, true},
+		{"HTTP://LOCALHOST:8080", "localhost:1234", true, true},
+		{"http://localhost:9090", "127.0.0.1:1234", true, false},
+		// An origin equal to the host is not implicitly allowed.
+		{"http://127.0.0.1:1234", "127.0.0.1:1234", true, false},
+		// DNS rebinding: the Host header is the domain of the attacker.
+		{"", "evil.example.com:1234", true, false},
+		{"", "[::1]:1234", true, true},
+		{"", "debugger.example.com:1234", false, true},
+		{"http://debugger.example.com:1234", "debugger.example.com:1234", false, false},
+	} {
+		var origin *url.URL
+		if tc.origin != "" {
+			origin, _ = url.Parse(tc.origin)
+		}
+		if got := CheckOrigin(allowed, tc.loopback)(origin, tc.host); got != tc.ok {
+			t.Errorf("origin %q host %q loopback %v: got %v, want %v", tc.origin, tc.host, tc.loopback, got, tc.ok)
+		}
+	}
+	if !CheckOrigin([]string{"*"}, true)(&url.URL{Scheme: "http", Host: "evil.example.com"}, "localhost:1234") {
+		t.Errorf("origin not allowed with *")
+	}
+}
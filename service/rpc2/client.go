@@ -1,11 +1,13 @@
 package rpc2
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net"
 	"net/rpc"
 	"net/rpc/jsonrpc"
+	"sync"
 	"time"
 
 	"github.com/go-delve/delve/service"
@@ -14,32 +16,67 @@ import (
 
 // RPCClient is a RPC service.Client.
 type RPCClient struct {
-	client *rpc.Client
+	clientMu sync.Mutex
+	client   *rpc.Client
 
 	retValLoadCfg *api.LoadConfig
+
+	observerMu sync.Mutex
+	observer   CallObserver
 }
 
+// CallObserver is called by RPCClient after every call to the server with
+// the name of the method, its arguments, its reply, the error returned and
+// the time the call started.
+type CallObserver func(method string, args, reply interface{}, err error, start time.Time)
+
 // Ensure the implementation satisfies the interface.
 var _ service.Client = &RPCClient{}
 
 // NewClient creates a new RPCClient.
 func NewClient(addr string) *RPCClient {
-	client, err := jsonrpc.Dial("tcp", addr)
+	conn, err := net.Dial("tcp", addr)
 	if err != nil {
 		log.Fatal("dialing:", err)
 	}
-	return newFromRPCClient(client)
+	return NewClientFromConn(conn)
 }
 
 func newFromRPCClient(client *rpc.Client) *RPCClient {
 	c := &RPCClient{client: client}
-	c.call("SetApiVersion", api.SetAPIVersionIn{APIVersion: 2}, &api.SetAPIVersionOut{})
+	c.setAPIVersion()
 	return c
 }
 
+func (c *RPCClient) setAPIVersion() error {
+	return c.call("SetApiVersion", api.SetAPIVersionIn{APIVersion: 2}, &api.SetAPIVersionOut{})
+}
+
 // NewClientFromConn creates a new RPCClient from the given connection.
 func NewClientFromConn(conn net.Conn) *RPCClient {
-	return newFromRPCClient(jsonrpc.NewClient(conn))
+	return newFromRPCClient(newRPCClient(conn))
+}
+
+func newRPCClient(conn net.Conn) *rpc.Client {
+	return rpc.NewClientWithCodec(newStreamClientCodec(jsonrpc.NewClientCodec(conn)))
+}
+
+// rpcClient returns the client of the current connection.
+func (c *RPCClient) rpcClient() *rpc.Client {
+	c.clientMu.Lock()
+	defer c.clientMu.Unlock()
+	return c.client
+}
+
+// Reconnect replaces the connection to the server, after it was lost, with
+// conn. Sessions (see SubscribeSession) must be resumed by the caller.
+func (c *RPCClient) Reconnect(conn net.Conn) error {
+	c.clientMu.Lock()
+	old := c.client
+	c.client = newRPCClient(conn)
+	c.clientMu.Unlock()
+	old.Close()
+	return c.setAPIVersion()
 }
 
 func (c *RPCClient) ProcessPid() int {
@@ -61,20 +98,24 @@ func (c *RPCClient) LastModified() time.Time {
 }
 
 func (c *RPCClient) Detach(kill bool) error {
-	defer c.client.Close()
+	defer c.rpcClient().Close()
 	out := new(DetachOut)
 	return c.call("Detach", DetachIn{kill}, out)
 }
 
 func (c *RPCClient) Restart(rebuild bool) ([]api.DiscardedBreakpoint, error) {
 	out := new(RestartOut)
-	err := c.call("Restart", RestartIn{"", false, nil, false, rebuild, [3]string{}}, out)
+	err := c.call("Restart", RestartIn{"", false, nil, false, rebuild, [3]string{}, nil, ""}, out)
 	return out.DiscardedBreakpoints, err
 }
 
 func (c *RPCClient) RestartFrom(rerecord bool, pos string, resetArgs bool, newArgs []string, newRedirects [3]string, rebuild bool) ([]api.DiscardedBreakpoint, error) {
+	return c.RestartFromWithEnv(rerecord, pos, resetArgs, newArgs, newRedirects, rebuild, nil, "")
+}
+
+func (c *RPCClient) RestartFromWithEnv(rerecord bool, pos string, resetArgs bool, newArgs []string, newRedirects [3]string, rebuild bool, newEnv []string, newWd string) ([]api.DiscardedBreakpoint, error) {
 	out := new(RestartOut)
-	err := c.call("Restart", RestartIn{pos, resetArgs, newArgs, rerecord, rebuild, newRedirects}, out)
+	err := c.call("Restart", RestartIn{pos, resetArgs, newArgs, rerecord, rebuild, newRedirects, newEnv, newWd}, out)
 	return out.DiscardedBreakpoints, err
 }
 
@@ -183,6 +224,12 @@ func (c *RPCClient) Call(goroutineID int, expr string, unsafe bool) (*api.Debugg
 	return &out.State, err
 }
 
+func (c *RPCClient) Jump(loc string, substitutePathRules [][2]string) (*api.DebuggerState, error) {
+	var out JumpOut
+	err := c.call("Jump", JumpIn{Loc: loc, SubstitutePathRules: substitutePathRules}, &out)
+	return &out.State, err
+}
+
 func (c *RPCClient) StepInstruction() (*api.DebuggerState, error) {
 	var out CommandOut
 	err := c.call("Command", api.DebuggerCommand{Name: api.StepInstruction}, &out)
@@ -215,6 +262,16 @@ func (c *RPCClient) SwitchGoroutine(goroutineID int) (*api.DebuggerState, error)
 	return &out.State, err
 }
 
+func (c *RPCClient) SwitchTarget(pid int) (*api.DebuggerState, error) {
+	var out CommandOut
+	cmd := api.DebuggerCommand{
+		Name:      api.SwitchTarget,
+		TargetPid: pid,
+	}
+	err := c.call("Command", cmd, &out)
+	return &out.State, err
+}
+
 func (c *RPCClient) Halt() (*api.DebuggerState, error) {
 	var out CommandOut
 	err := c.call("Command", api.DebuggerCommand{Name: api.Halt}, &out)
@@ -319,6 +376,24 @@ func (c *RPCClient) EvalVariable(scope api.EvalScope, expr string, cfg api.LoadC
 	return out.Variable, err
 }
 
+func (c *RPCClient) EvalMany(scope api.EvalScope, exprs []string, cfg api.LoadConfig) ([]*api.Variable, error) {
+	var out EvalManyOut
+	err := c.call("EvalMany", EvalManyIn{Scope: scope, Exprs: exprs, Cfg: &cfg}, &out)
+	return out.Variables, err
+}
+
+func (c *RPCClient) EvalDisplay(scope api.EvalScope, expr string, cfg api.LoadConfig) (*api.Variable, []api.VariableChange, error) {
+	var out EvalDisplayOut
+	err := c.call("EvalDisplay", EvalDisplayIn{Scope: scope, Expr: expr, Cfg: &cfg}, &out)
+	return out.Variable, out.Changes, err
+}
+
+func (c *RPCClient) ListVariableChildren(scope api.EvalScope, expr string, start, count int, cfg api.LoadConfig) (*api.Variable, error) {
+	var out ListVariableChildrenOut
+	err := c.call("ListVariableChildren", ListVariableChildrenIn{Scope: scope, Expr: expr, Start: start, Count: count, Cfg: &cfg}, &out)
+	return out.Variable, err
+}
+
 func (c *RPCClient) SetVariable(scope api.EvalScope, symbol, value string) error {
 	out := new(SetOut)
 	return c.call("Set", SetIn{scope, symbol, value}, out)
@@ -330,12 +405,24 @@ func (c *RPCClient) ListSources(filter string) ([]string, error) {
 	return sources.Sources, err
 }
 
+func (c *RPCClient) ListPackagesBuildInfo(includeFiles bool) ([]api.PackageBuildInfo, error) {
+	var out ListPackagesBuildInfoOut
+	err := c.call("ListPackagesBuildInfo", ListPackagesBuildInfoIn{IncludeFiles: includeFiles}, &out)
+	return out.List, err
+}
+
 func (c *RPCClient) ListFunctions(filter string) ([]string, error) {
 	funcs := new(ListFunctionsOut)
 	err := c.call("ListFunctions", ListFunctionsIn{filter}, funcs)
 	return funcs.Funcs, err
 }
 
+func (c *RPCClient) FindSymbols(query string, limit int) ([]api.Symbol, error) {
+	var out FindSymbolsOut
+	err := c.call("FindSymbols", FindSymbolsIn{Query: query, Limit: limit}, &out)
+	return out.Symbols, err
+}
+
 func (c *RPCClient) ListTypes(filter string) ([]string, error) {
 	types := new(ListTypesOut)
 	err := c.call("ListTypes", ListTypesIn{filter}, types)
@@ -354,6 +441,12 @@ func (c *RPCClient) ListLocalVariables(scope api.EvalScope, cfg api.LoadConfig)
 	return out.Variables, err
 }
 
+func (c *RPCClient) SetRegister(threadID int, reg, value string) (string, error) {
+	out := new(SetRegisterOut)
+	err := c.call("SetRegister", SetRegisterIn{ThreadID: threadID, Register: reg, Value: value}, out)
+	return out.Warning, err
+}
+
 func (c *RPCClient) ListThreadRegisters(threadID int, includeFp bool) (api.Registers, error) {
 	out := new(ListRegistersOut)
 	err := c.call("ListRegisters", ListRegistersIn{ThreadID: threadID, IncludeFp: includeFp, Scope: nil}, out)
@@ -374,7 +467,7 @@ func (c *RPCClient) ListFunctionArgs(scope api.EvalScope, cfg api.LoadConfig) ([
 
 func (c *RPCClient) ListGoroutines(start, count int) ([]*api.Goroutine, int, error) {
 	var out ListGoroutinesOut
-	err := c.call("ListGoroutines", ListGoroutinesIn{start, count, nil, api.GoroutineGroupingOptions{}}, &out)
+	err := c.call("ListGoroutines", ListGoroutinesIn{Start: start, Count: count}, &out)
 	return out.Goroutines, out.Nextg, err
 }
 
@@ -383,10 +476,19 @@ func (c *RPCClient) ListGoroutinesWithFilter(start, count int, filters []api.Lis
 		group = &api.GoroutineGroupingOptions{}
 	}
 	var out ListGoroutinesOut
-	err := c.call("ListGoroutines", ListGoroutinesIn{start, count, filters, *group}, &out)
+	err := c.call("ListGoroutines", ListGoroutinesIn{Start: start, Count: count, Filters: filters, GoroutineGroupingOptions: *group}, &out)
 	return out.Goroutines, out.Groups, out.Nextg, out.TooManyGroups, err
 }
 
+func (c *RPCClient) ListGoroutinesWithStacktraces(start, count int, filters []api.ListGoroutinesFilter, group *api.GoroutineGroupingOptions, depth int) ([]*api.Goroutine, [][]api.Stackframe, []api.GoroutineGroup, int, bool, error) {
+	if group == nil {
+		group = &api.GoroutineGroupingOptions{}
+	}
+	var out ListGoroutinesOut
+	err := c.call("ListGoroutines", ListGoroutinesIn{Start: start, Count: count, Filters: filters, GoroutineGroupingOptions: *group, StacktraceDepth: depth}, &out)
+	return out.Goroutines, out.Stacktraces, out.Groups, out.Nextg, out.TooManyGroups, err
+}
+
 func (c *RPCClient) Stacktrace(goroutineId, depth int, opts api.StacktraceOptions, cfg *api.LoadConfig) ([]api.Stackframe, error) {
 	var out StacktraceOut
 	err := c.call("Stacktrace", StacktraceIn{goroutineId, depth, false, false, opts, cfg}, &out)
@@ -414,14 +516,22 @@ func (c *RPCClient) FindLocation(scope api.EvalScope, loc string, findInstructio
 // DisassembleRange disassembles code between startPC and endPC
 func (c *RPCClient) DisassembleRange(scope api.EvalScope, startPC, endPC uint64, flavour api.AssemblyFlavour) (api.AsmInstructions, error) {
 	var out DisassembleOut
-	err := c.call("Disassemble", DisassembleIn{scope, startPC, endPC, flavour}, &out)
+	err := c.call("Disassemble", DisassembleIn{Scope: scope, StartPC: startPC, EndPC: endPC, Flavour: flavour}, &out)
 	return out.Disassemble, err
 }
 
 // DisassemblePC disassembles function containing pc
 func (c *RPCClient) DisassemblePC(scope api.EvalScope, pc uint64, flavour api.AssemblyFlavour) (api.AsmInstructions, error) {
 	var out DisassembleOut
-	err := c.call("Disassemble", DisassembleIn{scope, pc, 0, flavour}, &out)
+	err := c.call("Disassemble", DisassembleIn{Scope: scope, StartPC: pc, EndPC: 0, Flavour: flavour}, &out)
+	return out.Disassemble, err
+}
+
+// DisassembleRaw disassembles memory between startPC and endPC, the range
+// does not need to belong to a function.
+func (c *RPCClient) DisassembleRaw(scope api.EvalScope, startPC, endPC uint64, flavour api.AssemblyFlavour) (api.AsmInstructions, error) {
+	var out DisassembleOut
+	err := c.call("Disassemble", DisassembleIn{Scope: scope, StartPC: startPC, EndPC: endPC, Flavour: flavour, Raw: true}, &out)
 	return out.Disassemble, err
 }
 
@@ -460,6 +570,32 @@ func (c *RPCClient) ClearCheckpoint(id int) error {
 	return err
 }
 
+// AddBookmark records the current position of the recording under name.
+func (c *RPCClient) AddBookmark(name string) (api.Bookmark, error) {
+	var out AddBookmarkOut
+	err := c.call("AddBookmark", AddBookmarkIn{name}, &out)
+	return out.Bookmark, err
+}
+
+// ListBookmarks gets all bookmarks, in recording order.
+func (c *RPCClient) ListBookmarks() ([]api.Bookmark, error) {
+	var out ListBookmarksOut
+	err := c.call("ListBookmarks", ListBookmarksIn{}, &out)
+	return out.Bookmarks, err
+}
+
+// GotoBookmark restarts the recording from the position of the named bookmark.
+func (c *RPCClient) GotoBookmark(name string) error {
+	var out GotoBookmarkOut
+	return c.call("GotoBookmark", GotoBookmarkIn{name}, &out)
+}
+
+// ClearBookmark removes a bookmark.
+func (c *RPCClient) ClearBookmark(name string) error {
+	var out ClearBookmarkOut
+	return c.call("ClearBookmark", ClearBookmarkIn{name}, &out)
+}
+
 func (c *RPCClient) SetReturnValuesLoadConfig(cfg *api.LoadConfig) {
 	c.retValLoadCfg = cfg
 }
@@ -479,9 +615,9 @@ func (c *RPCClient) IsMulticlient() bool {
 func (c *RPCClient) Disconnect(cont bool) error {
 	if cont {
 		out := new(CommandOut)
-		c.client.Go("RPCServer.Command", &api.DebuggerCommand{Name: api.Continue, ReturnInfoLoadConfig: c.retValLoadCfg}, &out, nil)
+		c.rpcClient().Go("RPCServer.Command", &api.DebuggerCommand{Name: api.Continue, ReturnInfoLoadConfig: c.retValLoadCfg}, &out, nil)
 	}
-	return c.client.Close()
+	return c.rpcClient().Close()
 }
 
 func (c *RPCClient) ListDynamicLibraries() ([]api.Image, error) {
@@ -500,6 +636,60 @@ func (c *RPCClient) ExamineMemory(address uint64, count int) ([]byte, bool, erro
 	return out.Mem, out.IsLittleEndian, nil
 }
 
+func (c *RPCClient) SearchMemory(pattern []byte, start, end uint64, max int) ([]api.MemorySearchMatch, []api.MemoryMapEntry, error) {
+	out := &SearchMemoryOut{}
+	err := c.call("SearchMemory", SearchMemoryIn{Pattern: pattern, Start: start, End: end, Max: max}, out)
+	return out.Matches, out.Unreadable, err
+}
+
+func (c *RPCClient) FindReferences(scope api.EvalScope, expr string, max int) (addr, size uint64, refs []api.ObjectReference, err error) {
+	out := &FindReferencesOut{}
+	err = c.call("FindReferences", FindReferencesIn{Scope: scope, Expr: expr, Max: max}, out)
+	return out.Addr, out.Size, out.References, err
+}
+
+func (c *RPCClient) ListHeapObjects(typename string, max int, cfg api.LoadConfig) ([]api.Variable, error) {
+	out := &ListHeapObjectsOut{}
+	err := c.call("ListHeapObjects", ListHeapObjectsIn{Type: typename, Max: max, Cfg: cfg}, out)
+	return out.Objects, err
+}
+
+func (c *RPCClient) FindDeadlocks() ([][]api.DeadlockedGoroutine, error) {
+	out := &FindDeadlocksOut{}
+	err := c.call("FindDeadlocks", FindDeadlocksIn{}, out)
+	return out.Deadlocks, err
+}
+
+func (c *RPCClient) GetRuntimeMetrics() (*api.RuntimeMetrics, error) {
+	out := &GetRuntimeMetricsOut{}
+	err := c.call("GetRuntimeMetrics", GetRuntimeMetricsIn{}, out)
+	return &out.Metrics, err
+}
+
+func (c *RPCClient) ChanInfo(scope api.EvalScope, expr string, cfg api.LoadConfig) (*api.ChanInfo, error) {
+	out := &ChanInfoOut{}
+	err := c.call("ChanInfo", ChanInfoIn{Scope: scope, Expr: expr, Cfg: cfg}, out)
+	return &out.Chan, err
+}
+
+func (c *RPCClient) MutexInfo(scope api.EvalScope, expr string) (*api.MutexInfo, error) {
+	out := &MutexInfoOut{}
+	err := c.call("MutexInfo", MutexInfoIn{Scope: scope, Expr: expr}, out)
+	return &out.Mutex, err
+}
+
+func (c *RPCClient) ContextChain(scope api.EvalScope, expr string, cfg api.LoadConfig) ([]api.ContextLayer, error) {
+	out := &ContextChainOut{}
+	err := c.call("ContextChain", ContextChainIn{Scope: scope, Expr: expr, Cfg: cfg}, out)
+	return out.Layers, err
+}
+
+func (c *RPCClient) GetGCState() (*api.GCState, error) {
+	out := &GetGCStateOut{}
+	err := c.call("GetGCState", GetGCStateIn{}, out)
+	return &out.State, err
+}
+
 func (c *RPCClient) StopRecording() error {
 	return c.call("StopRecording", StopRecordingIn{}, &StopRecordingOut{})
 }
@@ -510,6 +700,12 @@ func (c *RPCClient) CoreDumpStart(dest string) (api.DumpState, error) {
 	return out.State, err
 }
 
+func (c *RPCClient) CoreDumpStartNative(dest string) (api.DumpState, error) {
+	out := &DumpStartOut{}
+	err := c.call("DumpStart", DumpStartIn{Destination: dest, Native: true}, out)
+	return out.State, err
+}
+
 func (c *RPCClient) CoreDumpWait(msec int) api.DumpState {
 	out := &DumpWaitOut{}
 	_ = c.call("DumpWait", DumpWaitIn{Wait: msec}, out)
@@ -521,10 +717,320 @@ func (c *RPCClient) CoreDumpCancel() error {
 	return c.call("DumpCancel", DumpCancelIn{}, out)
 }
 
+func (c *RPCClient) DumpHeap(dest, format string) (objects int, bytes uint64, err error) {
+	out := &DumpHeapOut{}
+	err = c.call("DumpHeap", DumpHeapIn{Destination: dest, Format: format}, out)
+	return out.Objects, out.Bytes, err
+}
+
+func (c *RPCClient) ProfileCPU(goroutineID int, duration time.Duration) ([]byte, error) {
+	out := &ProfileCPUOut{}
+	err := c.call("ProfileCPU", ProfileCPUIn{GoroutineID: goroutineID, Duration: duration}, out)
+	return out.Profile, err
+}
+
+func (c *RPCClient) ProfileTrace(goroutineID int, duration time.Duration) ([]byte, error) {
+	out := &ProfileTraceOut{}
+	err := c.call("ProfileTrace", ProfileTraceIn{GoroutineID: goroutineID, Duration: duration}, out)
+	return out.Trace, err
+}
+
+// StreamStacktrace is the streaming variant of Stacktrace, fn is called
+// with up to chunkSize frames at a time and can return false to cancel the
+// call.
+func (c *RPCClient) StreamStacktrace(goroutineId, depth int, opts api.StacktraceOptions, cfg *api.LoadConfig, chunkSize int, fn func([]api.Stackframe) bool) error {
+	return c.stream("StreamStacktrace", StreamStacktraceIn{StacktraceIn{goroutineId, depth, false, false, opts, cfg}, chunkSize}, func(raw json.RawMessage) (bool, error) {
+		var chunk StreamStacktraceChunk
+		if err := json.Unmarshal(raw, &chunk); err != nil {
+			return false, err
+		}
+		return fn(chunk.Locations), nil
+	})
+}
+
+// StreamGoroutines is the streaming variant of ListGoroutinesWithFilter, fn
+// is called with up to chunkSize goroutines at a time, and their
+// stacktraces if stacktraceDepth is greater than zero, and can return false
+// to cancel the call.
+func (c *RPCClient) StreamGoroutines(start, count int, filters []api.ListGoroutinesFilter, stacktraceDepth int, chunkSize int, fn func([]*api.Goroutine, [][]api.Stackframe) bool) error {
+	return c.stream("StreamGoroutines", StreamGoroutinesIn{start, count, filters, stacktraceDepth, chunkSize}, func(raw json.RawMessage) (bool, error) {
+		var chunk StreamGoroutinesChunk
+		if err := json.Unmarshal(raw, &chunk); err != nil {
+			return false, err
+		}
+		return fn(chunk.Goroutines, chunk.Stacktraces), nil
+	})
+}
+
+// StreamFunctions is the streaming variant of ListFunctions, fn is called
+// with up to chunkSize functions at a time and can return false to cancel
+// the call.
+func (c *RPCClient) StreamFunctions(filter string, chunkSize int, fn func([]string) bool) error {
+	return c.stream("StreamFunctions", StreamFunctionsIn{filter, chunkSize}, func(raw json.RawMessage) (bool, error) {
+		var chunk StreamFunctionsChunk
+		if err := json.Unmarshal(raw, &chunk); err != nil {
+			return false, err
+		}
+		return fn(chunk.Funcs), nil
+	})
+}
+
+// StreamLocalVariables is the streaming variant of ListLocalVariables, fn
+// is called with up to chunkSize variables at a time and can return false
+// to cancel the call.
+func (c *RPCClient) StreamLocalVariables(scope api.EvalScope, cfg api.LoadConfig, chunkSize int, fn func([]api.Variable) bool) error {
+	return c.streamVars(scope, cfg, false, chunkSize, fn)
+}
+
+// StreamFunctionArguments is the streaming variant of
+// ListFunctionArgs, fn is called with up to chunkSize arguments at a time
+// and can return false to cancel the call.
+func (c *RPCClient) StreamFunctionArguments(scope api.EvalScope, cfg api.LoadConfig, chunkSize int, fn func([]api.Variable) bool) error {
+	return c.streamVars(scope, cfg, true, chunkSize, fn)
+}
+
+func (c *RPCClient) streamVars(scope api.EvalScope, cfg api.LoadConfig, args bool, chunkSize int, fn func([]api.Variable) bool) error {
+	return c.stream("StreamVars", StreamVarsIn{scope, cfg, args, chunkSize}, func(raw json.RawMessage) (bool, error) {
+		var chunk StreamVarsChunk
+		if err := json.Unmarshal(raw, &chunk); err != nil {
+			return false, err
+		}
+		return fn(chunk.Variables), nil
+	})
+}
+
+// Capabilities returns the optional features supported by the debugger
+// with the current target and backend.
+func (c *RPCClient) Capabilities() (*api.Capabilities, error) {
+	var out api.CapabilitiesOut
+	err := c.call("Capabilities", api.CapabilitiesIn{}, &out)
+	return &out.Capabilities, err
+}
+
+// ListTargets returns the processes being debugged.
+func (c *RPCClient) ListTargets() ([]api.Target, error) {
+	var out ListTargetsOut
+	err := c.call("ListTargets", ListTargetsIn{}, &out)
+	return out.Targets, err
+}
+
+// FollowExec enables or disables following the children of the processes
+// being debugged.
+func (c *RPCClient) FollowExec(enabled bool, regex, exclude string) error {
+	var out FollowExecOut
+	return c.call("FollowExec", FollowExecIn{Enable: enabled, Regex: regex, Exclude: exclude}, &out)
+}
+
+// FollowExecEnabled returns true if following children is enabled.
+func (c *RPCClient) FollowExecEnabled() (enabled bool, regex, exclude string, err error) {
+	var out FollowExecEnabledOut
+	err = c.call("FollowExecEnabled", FollowExecEnabledIn{}, &out)
+	return out.Enabled, out.Regex, out.Exclude, err
+}
+
+// Subscribe calls fn with the events of the debugger, including those
+// caused by other clients, until fn returns false.
+func (c *RPCClient) Subscribe(fn func(*api.Event) bool) error {
+	return c.stream("Subscribe", SubscribeIn{}, func(raw json.RawMessage) (bool, error) {
+		var ev api.Event
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return false, err
+		}
+		return fn(&ev), nil
+	})
+}
+
+// SubscribeSession is like Subscribe but the subscription is a resumable
+// session: if the connection is lost the events are queued by the server
+// and sent, followed by an api.EventSessionResumed event, when
+// SubscribeSession is called again with the same id after Reconnect.
+func (c *RPCClient) SubscribeSession(id string, fn func(*api.Event) bool) error {
+	return c.stream("Subscribe", SubscribeIn{Session: id}, func(raw json.RawMessage) (bool, error) {
+		var ev api.Event
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return false, err
+		}
+		return fn(&ev), nil
+	})
+}
+
+// EndSession ends the session id, started by SubscribeSession.
+func (c *RPCClient) EndSession(id string) error {
+	return c.call("EndSession", EndSessionIn{id}, &EndSessionOut{})
+}
+
+// StreamTTY calls fn with the output of the target, written to its
+// pseudo-terminal, until fn returns false.
+func (c *RPCClient) StreamTTY(fn func([]byte) bool) error {
+	return c.stream("StreamTTY", StreamTTYIn{}, func(raw json.RawMessage) (bool, error) {
+		var chunk StreamTTYChunk
+		if err := json.Unmarshal(raw, &chunk); err != nil {
+			return false, err
+		}
+		return fn(chunk.Data), nil
+	})
+}
+
+// WriteTTY writes data to the pseudo-terminal of the target.
+func (c *RPCClient) WriteTTY(data []byte) error {
+	return c.call("WriteTTY", WriteTTYIn{data}, &WriteTTYOut{})
+}
+
+// ResizeTTY changes the size of the pseudo-terminal of the target.
+func (c *RPCClient) ResizeTTY(rows, cols int) error {
+	return c.call("ResizeTTY", ResizeTTYIn{rows, cols}, &ResizeTTYOut{})
+}
+
+// SetCallObserver sets the function called after every call to the
+// server, nil removes it.
+func (c *RPCClient) SetCallObserver(observer CallObserver) {
+	c.observerMu.Lock()
+	c.observer = observer
+	c.observerMu.Unlock()
+}
+
 func (c *RPCClient) call(method string, args, reply interface{}) error {
-	return c.client.Call("RPCServer."+method, args, reply)
+	start := time.Now()
+	err := c.rpcClient().Call("RPCServer."+method, args, reply)
+	c.observerMu.Lock()
+	observer := c.observer
+	c.observerMu.Unlock()
+	if observer != nil {
+		observer(method, args, reply, err, start)
+	}
+	return err
+}
+
+// stream makes the streaming call method, fn is called with each chunk of
+// the result, in order, and can return false to cancel the call. The chunks
+// that arrive after the call is canceled are discarded.
+func (c *RPCClient) stream(method string, args interface{}, fn func(chunk json.RawMessage) (bool, error)) error {
+	start := time.Now()
+	sc := &streamCall{args: args, chunks: make(chan json.RawMessage)}
+	out := new(StreamOut)
+	client := c.rpcClient()
+	call := client.Go("RPCServer."+method, sc, out, make(chan *rpc.Call, 1))
+	var chunkErr error
+	canceled := false
+	for {
+		select {
+		case chunk := <-sc.chunks:
+			if canceled {
+				continue
+			}
+			ok, err := fn(chunk)
+			if err != nil || !ok {
+				chunkErr = err
+				canceled = true
+				go client.Call(cancelStreamMethod, api.CancelStreamIn{Seq: sc.seq}, new(api.CancelStreamOut))
+			}
+		case <-call.Done:
+			err := call.Error
+			if err == nil {
+				err = chunkErr
+			}
+			c.observerMu.Lock()
+			observer := c.observer
+			c.observerMu.Unlock()
+			if observer != nil {
+				observer(method, args, out, err, start)
+			}
+			return err
+		}
+	}
 }
 
 func (c *RPCClient) CallAPI(method string, args, reply interface{}) error {
 	return c.call(method, args, reply)
 }
+
+// cancelStreamMethod is the method used to cancel streaming calls.
+const cancelStreamMethod = "RPCServer.CancelStream"
+
+// streamCall is passed to streamClientCodec as the arguments of a
+// streaming call, the chunks of its result are sent to chunks.
+type streamCall struct {
+	args   interface{}
+	seq    uint64
+	chunks chan json.RawMessage
+}
+
+// streamClientCodec is a rpc.ClientCodec that supports streaming calls:
+// the chunks of their result, sent by the server as responses with the id of
+// the call before its final response, are delivered to the caller instead of
+// completing the call.
+type streamClientCodec struct {
+	rpc.ClientCodec
+
+	mu      sync.Mutex
+	streams map[uint64]*streamCall
+
+	// result is the body of the response read by ReadResponseHeader, if it
+	// is the final response of a streaming call.
+	result    json.RawMessage
+	hasResult bool
+}
+
+func newStreamClientCodec(codec rpc.ClientCodec) *streamClientCodec {
+	return &streamClientCodec{ClientCodec: codec, streams: make(map[uint64]*streamCall)}
+}
+
+func (cc *streamClientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	sc, ok := body.(*streamCall)
+	if !ok {
+		return cc.ClientCodec.WriteRequest(r, body)
+	}
+	sc.seq = r.Seq
+	cc.mu.Lock()
+	cc.streams[r.Seq] = sc
+	cc.mu.Unlock()
+	err := cc.ClientCodec.WriteRequest(r, sc.args)
+	if err != nil {
+		cc.mu.Lock()
+		delete(cc.streams, r.Seq)
+		cc.mu.Unlock()
+	}
+	return err
+}
+
+func (cc *streamClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	for {
+		if err := cc.ClientCodec.ReadResponseHeader(r); err != nil {
+			return err
+		}
+		cc.mu.Lock()
+		sc := cc.streams[r.Seq]
+		cc.mu.Unlock()
+		if sc == nil {
+			return nil
+		}
+		var result json.RawMessage
+		if r.Error == "" {
+			if err := cc.ClientCodec.ReadResponseBody(&result); err != nil {
+				return err
+			}
+			var chunk api.StreamChunk
+			chunk.Chunk = new(json.RawMessage)
+			if json.Unmarshal(result, &chunk) == nil && len(*chunk.Chunk.(*json.RawMessage)) > 0 {
+				sc.chunks <- *chunk.Chunk.(*json.RawMessage)
+				continue
+			}
+		}
+		cc.mu.Lock()
+		delete(cc.streams, r.Seq)
+		cc.mu.Unlock()
+		cc.result, cc.hasResult = result, true
+		return nil
+	}
+}
+
+func (cc *streamClientCodec) ReadResponseBody(body interface{}) error {
+	if !cc.hasResult {
+		return cc.ClientCodec.ReadResponseBody(body)
+	}
+	cc.hasResult = false
+	if body == nil {
+		return nil
+	}
+	return json.Unmarshal(cc.result, body)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/go-delve/delve/pkg/dwarf/op"
@@ -18,10 +19,15 @@ type RPCServer struct {
 	config *service.Config
 	// debugger is a debugger service.
 	debugger *debugger.Debugger
+
+	// sessions are the resumable sessions of the clients, indexed by their
+	// id, see SubscribeIn.
+	sessionsMu sync.Mutex
+	sessions   map[string]*session
 }
 
 func NewServer(config *service.Config, debugger *debugger.Debugger) *RPCServer {
-	return &RPCServer{config, debugger}
+	return &RPCServer{config: config, debugger: debugger}
 }
 
 type ProcessPidIn struct {
@@ -79,6 +85,14 @@ type RestartIn struct {
 	Rebuild bool
 
 	NewRedirects [3]string
+
+	// NewEnv are environment variables, in the form KEY=VALUE, added to the
+	// environment of the new process. A KEY without '=' removes the
+	// variable. The changes also apply to the following restarts.
+	NewEnv []string
+	// NewWorkingDir, if not empty, replaces the working directory of the
+	// new process, also for the following restarts.
+	NewWorkingDir string
 }
 
 type RestartOut struct {
@@ -94,7 +108,7 @@ func (s *RPCServer) Restart(arg RestartIn, cb service.RPCCallback) {
 	}
 	var out RestartOut
 	var err error
-	out.DiscardedBreakpoints, err = s.debugger.Restart(arg.Rerecord, arg.Position, arg.ResetArgs, arg.NewArgs, arg.NewRedirects, arg.Rebuild)
+	out.DiscardedBreakpoints, err = s.debugger.Restart(arg.Rerecord, arg.Position, arg.ResetArgs, arg.NewArgs, arg.NewRedirects, arg.Rebuild, arg.NewEnv, arg.NewWorkingDir)
 	cb.Return(out, err)
 }
 
@@ -388,6 +402,9 @@ func (s *RPCServer) ListThreads(arg ListThreadsIn, out *ListThreadsOut) (err err
 	s.debugger.LockTarget()
 	defer s.debugger.UnlockTarget()
 	out.Threads = api.ConvertThreads(threads)
+	for i := range threads {
+		out.Threads[i].Running = s.debugger.Target().ThreadRunning(threads[i])
+	}
 	return nil
 }
 
@@ -411,6 +428,7 @@ func (s *RPCServer) GetThread(arg GetThreadIn, out *GetThreadOut) error {
 	s.debugger.LockTarget()
 	defer s.debugger.UnlockTarget()
 	out.Thread = api.ConvertThread(t)
+	out.Thread.Running = s.debugger.Target().ThreadRunning(t)
 	return nil
 }
 
@@ -538,6 +556,101 @@ func (s *RPCServer) Eval(arg EvalIn, out *EvalOut) error {
 	return nil
 }
 
+type EvalManyIn struct {
+	Scope api.EvalScope
+	Exprs []string
+	Cfg   *api.LoadConfig
+}
+
+type EvalManyOut struct {
+	Variables []*api.Variable
+}
+
+// EvalMany evaluates all expressions in arg.Exprs in the specified context,
+// in a single request. The returned variables are in the same order as the
+// expressions, an expression that could not be evaluated is returned as a
+// variable with the Unreadable field set to the evaluation error.
+// An error is returned only if the scope itself is invalid.
+func (s *RPCServer) EvalMany(arg EvalManyIn, out *EvalManyOut) error {
+	cfg := arg.Cfg
+	if cfg == nil {
+		cfg = &api.LoadConfig{FollowPointers: true, MaxVariableRecurse: 1, MaxStringLen: 64, MaxArrayValues: 64, MaxStructFields: -1}
+	}
+	vars, err := s.debugger.EvalVariablesInScope(arg.Scope.GoroutineID, arg.Scope.Frame, arg.Scope.DeferredCall, arg.Exprs, *api.LoadConfigToProc(cfg))
+	if err != nil {
+		return err
+	}
+	out.Variables = make([]*api.Variable, len(vars))
+	for i := range vars {
+		out.Variables[i] = api.ConvertVar(vars[i])
+	}
+	return nil
+}
+
+type EvalDisplayIn struct {
+	Scope api.EvalScope
+	Expr  string
+	Cfg   *api.LoadConfig
+}
+
+type EvalDisplayOut struct {
+	Variable *api.Variable
+	// Changes lists the values that changed since the previous stop.
+	Changes []api.VariableChange
+}
+
+// EvalDisplay is like Eval but it also compares the value of the
+// expression with the value it had when EvalDisplay was called with the
+// same arguments at the previous stop of the target, and returns the list of
+// values that changed in Changes.
+//
+// Values are compared structurally, each change is described by an
+// expression evaluating to the changed value and its old and new values.
+func (s *RPCServer) EvalDisplay(arg EvalDisplayIn, out *EvalDisplayOut) error {
+	cfg := arg.Cfg
+	if cfg == nil {
+		cfg = &api.LoadConfig{FollowPointers: true, MaxVariableRecurse: 1, MaxStringLen: 64, MaxArrayValues: 64, MaxStructFields: -1}
+	}
+	var err error
+	out.Variable, out.Changes, err = s.debugger.EvalDisplay(arg.Scope.GoroutineID, arg.Scope.Frame, arg.Scope.DeferredCall, arg.Expr, *api.LoadConfigToProc(cfg))
+	return err
+}
+
+type ListVariableChildrenIn struct {
+	Scope api.EvalScope
+	Expr  string
+	// Start is the index of the first element returned and Count the
+	// maximum number of elements returned.
+	Start, Count int
+	Cfg          *api.LoadConfig
+}
+
+type ListVariableChildrenOut struct {
+	// Variable is the value of Expr, its children are the requested
+	// elements while its Len is the total number of elements.
+	Variable *api.Variable
+}
+
+// ListVariableChildren returns up to Count elements of Expr, which must be
+// an array, a slice or a map, starting from the element with index Start.
+// The elements of a map are numbered in iteration order, which does not
+// change as long as the map is not modified.
+//
+// This allows clients to page through large variables instead of loading
+// them at once.
+func (s *RPCServer) ListVariableChildren(arg ListVariableChildrenIn, out *ListVariableChildrenOut) error {
+	cfg := arg.Cfg
+	if cfg == nil {
+		cfg = &api.LoadConfig{FollowPointers: true, MaxVariableRecurse: 1, MaxStringLen: 64, MaxStructFields: -1}
+	}
+	v, err := s.debugger.ListVariableChildren(arg.Scope.GoroutineID, arg.Scope.Frame, arg.Scope.DeferredCall, arg.Expr, arg.Start, arg.Count, *api.LoadConfigToProc(cfg))
+	if err != nil {
+		return err
+	}
+	out.Variable = api.ConvertVar(v)
+	return nil
+}
+
 type SetIn struct {
 	Scope  api.EvalScope
 	Symbol string
@@ -553,6 +666,69 @@ func (s *RPCServer) Set(arg SetIn, out *SetOut) error {
 	return s.debugger.SetVariableInScope(arg.Scope.GoroutineID, arg.Scope.Frame, arg.Scope.DeferredCall, arg.Symbol, arg.Value)
 }
 
+type SetRegisterIn struct {
+	// ThreadID is the thread whose register is changed, if it is 0 the
+	// current thread is used.
+	ThreadID int
+	Register string
+	Value    string
+}
+
+type SetRegisterOut struct {
+	// Warning is set when the change could leave the thread in an
+	// inconsistent state, for example when the program counter or the stack
+	// pointer are changed.
+	Warning string
+}
+
+// SetRegister changes the value of a register. Register is either the name
+// of a register or, to change a single lane of a vector register, the name
+// of the register followed by the type of its lanes and the index of the
+// lane, for example XMM0.uint64[1] or V0.f32[3]. Value is evaluated in the
+// scope of the topmost frame of the thread and must be a number.
+func (s *RPCServer) SetRegister(arg SetRegisterIn, out *SetRegisterOut) error {
+	if arg.ThreadID == 0 {
+		state, err := s.debugger.State(false)
+		if err != nil {
+			return err
+		}
+		if state.CurrentThread == nil {
+			return errors.New("no current thread")
+		}
+		arg.ThreadID = state.CurrentThread.ID
+	}
+	var err error
+	out.Warning, err = s.debugger.SetRegister(arg.ThreadID, arg.Register, arg.Value)
+	return err
+}
+
+type JumpIn struct {
+	// Loc is the destination, a location specification that must resolve to
+	// a statement of the function of the topmost frame of the current
+	// goroutine.
+	Loc                 string
+	SubstitutePathRules [][2]string
+}
+
+type JumpOut struct {
+	State api.DebuggerState
+}
+
+// Jump moves the program counter of the current goroutine to Loc, without
+// executing any code. The destination must be in the same function and at
+// the start of a statement, the stack of the goroutine is not changed.
+func (s *RPCServer) Jump(arg JumpIn, out *JumpOut) error {
+	if err := s.debugger.Jump(arg.Loc, arg.SubstitutePathRules); err != nil {
+		return err
+	}
+	st, err := s.debugger.State(false)
+	if err != nil {
+		return err
+	}
+	out.State = *st
+	return nil
+}
+
 type ListSourcesIn struct {
 	Filter string
 }
@@ -607,12 +783,39 @@ func (s *RPCServer) ListTypes(arg ListTypesIn, out *ListTypesOut) error {
 	return nil
 }
 
+// FindSymbolsIn holds the arguments of FindSymbols
+type FindSymbolsIn struct {
+	Query string
+	// Limit is the maximum number of symbols returned, zero means no limit.
+	Limit int
+}
+
+// FindSymbolsOut holds the return values of FindSymbols
+type FindSymbolsOut struct {
+	Symbols []api.Symbol
+}
+
+// FindSymbols searches functions, types and source files for names
+// matching Query, a fuzzy query: a name matches if it contains all the
+// characters of Query in the same order, ignoring case. Symbols are sorted
+// by decreasing score, exact matches and matches of the last component of
+// a name are ranked first.
+func (s *RPCServer) FindSymbols(arg FindSymbolsIn, out *FindSymbolsOut) error {
+	out.Symbols = s.debugger.FindSymbols(arg.Query, arg.Limit)
+	return nil
+}
+
 type ListGoroutinesIn struct {
 	Start int
 	Count int
 
 	Filters []api.ListGoroutinesFilter
 	api.GoroutineGroupingOptions
+
+	// If StacktraceDepth is greater than zero the stacktraces of the
+	// returned goroutines, up to StacktraceDepth frames, are returned in
+	// Stacktraces.
+	StacktraceDepth int
 }
 
 type ListGoroutinesOut struct {
@@ -620,6 +823,10 @@ type ListGoroutinesOut struct {
 	Nextg         int
 	Groups        []api.GoroutineGroup
 	TooManyGroups bool
+
+	// Stacktraces[i] is the stacktrace of Goroutines[i], only returned if
+	// StacktraceDepth was specified.
+	Stacktraces [][]api.Stackframe
 }
 
 // ListGoroutines lists all goroutines.
@@ -647,6 +854,12 @@ type ListGoroutinesOut struct {
 // be grouped with the specified criterion.
 // If the value of arg.GroupBy is GoroutineLabel goroutines will
 // be grouped by the value of the label with key GroupByKey.
+//
+// If arg.StacktraceDepth is greater than zero the stacktraces of the
+// returned goroutines are returned as well, this is faster than calling
+// Stacktrace for each goroutine. Clients listing the stacktraces of many
+// goroutines should use a small Count so that results are returned
+// incrementally.
 // For each group a maximum of MaxExamples example goroutines are
 // returned, as well as the total number of goroutines in the group.
 func (s *RPCServer) ListGoroutines(arg ListGoroutinesIn, out *ListGoroutinesOut) error {
@@ -659,9 +872,17 @@ func (s *RPCServer) ListGoroutines(arg ListGoroutinesIn, out *ListGoroutinesOut)
 	}
 	gs = s.debugger.FilterGoroutines(gs, arg.Filters)
 	gs, out.Groups, out.TooManyGroups = s.debugger.GroupGoroutines(gs, &arg.GoroutineGroupingOptions)
+	if arg.StacktraceDepth > 0 {
+		out.Stacktraces, err = s.debugger.GoroutinesStacktraces(gs, arg.StacktraceDepth, 0)
+		if err != nil {
+			return err
+		}
+	}
+	waits := s.debugger.GoroutinesWaitDurations(gs)
 	s.debugger.LockTarget()
 	defer s.debugger.UnlockTarget()
 	out.Goroutines = api.ConvertGoroutines(s.debugger.Target(), gs)
+	setWaitDurations(out.Goroutines, waits)
 	out.Nextg = nextg
 	return nil
 }
@@ -721,6 +942,9 @@ type DisassembleIn struct {
 	Scope          api.EvalScope
 	StartPC, EndPC uint64
 	Flavour        api.AssemblyFlavour
+	// Raw disassembles the range StartPC-EndPC even if it does not belong
+	// to any function, see Disassemble.
+	Raw bool
 }
 
 type DisassembleOut struct {
@@ -734,9 +958,16 @@ type DisassembleOut struct {
 // Scope is used to mark the instruction the specified goroutine is stopped at.
 //
 // Disassemble will also try to calculate the destination address of an absolute indirect CALL if it happens to be the instruction the selected goroutine is stopped at.
+//
+// If Raw is set the range StartPC-EndPC is disassembled even if it is not part of any function (for example JIT generated code or PLT entries), disassembly stops at the first address that can not be read.
 func (c *RPCServer) Disassemble(arg DisassembleIn, out *DisassembleOut) error {
+	var insts []proc.AsmInstruction
 	var err error
-	insts, err := c.debugger.Disassemble(arg.Scope.GoroutineID, arg.StartPC, arg.EndPC)
+	if arg.Raw {
+		insts, err = c.debugger.DisassembleRaw(arg.Scope.GoroutineID, arg.StartPC, arg.EndPC)
+	} else {
+		insts, err = c.debugger.Disassemble(arg.Scope.GoroutineID, arg.StartPC, arg.EndPC)
+	}
 	if err != nil {
 		return err
 	}
@@ -805,6 +1036,71 @@ func (s *RPCServer) ClearCheckpoint(arg ClearCheckpointIn, out *ClearCheckpointO
 	return s.debugger.ClearCheckpoint(arg.ID)
 }
 
+type AddBookmarkIn struct {
+	Name string
+}
+
+type AddBookmarkOut struct {
+	Bookmark api.Bookmark
+}
+
+// AddBookmark records the current position of the recording under the
+// given name.
+func (s *RPCServer) AddBookmark(arg AddBookmarkIn, out *AddBookmarkOut) error {
+	bm, err := s.debugger.AddBookmark(arg.Name)
+	if err != nil {
+		return err
+	}
+	out.Bookmark = api.Bookmark(bm)
+	return nil
+}
+
+type ListBookmarksIn struct {
+}
+
+type ListBookmarksOut struct {
+	Bookmarks []api.Bookmark
+}
+
+// ListBookmarks returns the list of bookmarks sorted by their position in
+// the recording.
+func (s *RPCServer) ListBookmarks(arg ListBookmarksIn, out *ListBookmarksOut) error {
+	bms, err := s.debugger.Bookmarks()
+	if err != nil {
+		return err
+	}
+	out.Bookmarks = make([]api.Bookmark, len(bms))
+	for i := range bms {
+		out.Bookmarks[i] = api.Bookmark(bms[i])
+	}
+	return nil
+}
+
+type GotoBookmarkIn struct {
+	Name string
+}
+
+type GotoBookmarkOut struct {
+}
+
+// GotoBookmark restarts the recording from the position of the named
+// bookmark.
+func (s *RPCServer) GotoBookmark(arg GotoBookmarkIn, out *GotoBookmarkOut) error {
+	return s.debugger.GotoBookmark(arg.Name)
+}
+
+type ClearBookmarkIn struct {
+	Name string
+}
+
+type ClearBookmarkOut struct {
+}
+
+// ClearBookmark removes the named bookmark.
+func (s *RPCServer) ClearBookmark(arg ClearBookmarkIn, out *ClearBookmarkOut) error {
+	return s.debugger.ClearBookmark(arg.Name)
+}
+
 type IsMulticlientIn struct {
 }
 
@@ -934,6 +1230,284 @@ func (s *RPCServer) ExamineMemory(arg ExamineMemoryIn, out *ExaminedMemoryOut) e
 	return nil
 }
 
+// SearchMemoryIn holds the arguments of SearchMemory
+type SearchMemoryIn struct {
+	Pattern []byte
+	// Start and End restrict the search to the interval [Start, End), if
+	// End is zero all readable memory is searched.
+	Start, End uint64
+	// Max is the maximum number of matches returned, zero means no limit.
+	Max int
+}
+
+// SearchMemoryOut holds the return values of SearchMemory
+type SearchMemoryOut struct {
+	Matches []api.MemorySearchMatch
+	// Unreadable are the parts of the memory mappings that could not be
+	// read and were skipped.
+	Unreadable []api.MemoryMapEntry
+}
+
+// SearchMemory scans the readable memory of the target process for
+// occurrences of Pattern, returning the address of each occurrence along
+// with the memory mapping containing it.
+func (s *RPCServer) SearchMemory(arg SearchMemoryIn, out *SearchMemoryOut) error {
+	matches, unreadable, err := s.debugger.SearchMemory(arg.Pattern, arg.Start, arg.End, arg.Max)
+	if err != nil {
+		return err
+	}
+	out.Matches = make([]api.MemorySearchMatch, len(matches))
+	for i := range matches {
+		out.Matches[i] = api.MemorySearchMatch{Addr: matches[i].Addr, Mapping: api.ConvertMemoryMapEntry(&matches[i].Mapping)}
+	}
+	out.Unreadable = make([]api.MemoryMapEntry, len(unreadable))
+	for i := range unreadable {
+		out.Unreadable[i] = api.ConvertMemoryMapEntry(&unreadable[i])
+	}
+	return nil
+}
+
+// FindReferencesIn holds the arguments of FindReferences
+type FindReferencesIn struct {
+	Scope api.EvalScope
+	Expr  string
+	// Max is the maximum number of references returned, zero means no
+	// limit.
+	Max int
+}
+
+// FindReferencesOut holds the return values of FindReferences
+type FindReferencesOut struct {
+	// Addr and Size describe the referenced object.
+	Addr, Size uint64
+	References []api.ObjectReference
+}
+
+// FindReferences evaluates Expr and searches goroutine stacks, package
+// variables and the heap for pointers to the object it refers to.
+//
+// If Expr evaluates to a pointer, map, channel, slice or string the object
+// it points to is used, otherwise the value of Expr itself. If the object
+// is allocated on the heap the whole heap object containing it is used.
+// For references found in stacks and package variables the name of the
+// variable and the path of the field containing the pointer is returned;
+// heap objects are untyped and are described by their address and size.
+func (s *RPCServer) FindReferences(arg FindReferencesIn, out *FindReferencesOut) error {
+	addr, size, refs, err := s.debugger.FindReferences(arg.Scope.GoroutineID, arg.Scope.Frame, arg.Scope.DeferredCall, arg.Expr, arg.Max)
+	if err != nil {
+		return err
+	}
+	out.Addr, out.Size = addr, size
+	out.References = make([]api.ObjectReference, len(refs))
+	for i := range refs {
+		out.References[i] = api.ConvertReference(&refs[i])
+	}
+	return nil
+}
+
+// ListHeapObjectsIn holds the arguments of ListHeapObjects
+type ListHeapObjectsIn struct {
+	// Type is the name of the type of the objects to list.
+	Type string
+	// Max is the maximum number of objects returned, zero means no limit.
+	Max int
+	Cfg api.LoadConfig
+}
+
+// ListHeapObjectsOut holds the return values of ListHeapObjects
+type ListHeapObjectsOut struct {
+	Objects []api.Variable
+}
+
+// ListHeapObjects lists the live heap objects of the specified type,
+// sorted by address.
+//
+// The type of heap objects is inferred by following typed pointers
+// starting from the local variables of all goroutines and from package
+// variables: objects only reachable through unsafe.Pointer values, maps or
+// channels are not listed.
+func (s *RPCServer) ListHeapObjects(arg ListHeapObjectsIn, out *ListHeapObjectsOut) error {
+	objs, err := s.debugger.HeapObjects(arg.Type, arg.Max, *api.LoadConfigToProc(&arg.Cfg))
+	if err != nil {
+		return err
+	}
+	out.Objects = api.ConvertVars(objs)
+	return nil
+}
+
+// FindDeadlocksIn holds the arguments of FindDeadlocks
+type FindDeadlocksIn struct {
+}
+
+// FindDeadlocksOut holds the return values of FindDeadlocks
+type FindDeadlocksOut struct {
+	// Deadlocks contains one element for each group of goroutines that
+	// are waiting on each other.
+	Deadlocks [][]api.DeadlockedGoroutine
+}
+
+// FindDeadlocks builds a wait-for graph of the goroutines blocked on
+// channels, sync.Mutex, sync.RWMutex and sync.WaitGroup and returns the
+// groups of goroutines that can not make progress.
+//
+// A goroutine is considered able to wake a blocked goroutine if its local
+// variables contain a pointer to the object the blocked goroutine is
+// waiting on. A blocked goroutine is deadlocked if all goroutines that can
+// wake it are deadlocked as well. This is a heuristic: pointers reached
+// through other heap objects are not followed.
+func (s *RPCServer) FindDeadlocks(arg FindDeadlocksIn, out *FindDeadlocksOut) error {
+	deadlocks, err := s.debugger.FindDeadlocks()
+	if err != nil {
+		return err
+	}
+	out.Deadlocks = make([][]api.DeadlockedGoroutine, len(deadlocks))
+	for i := range deadlocks {
+		gs := make([]*proc.G, len(deadlocks[i]))
+		for j := range deadlocks[i] {
+			gs[j] = deadlocks[i][j].G
+		}
+		waits := s.debugger.GoroutinesWaitDurations(gs)
+		out.Deadlocks[i] = make([]api.DeadlockedGoroutine, len(deadlocks[i]))
+		for j := range deadlocks[i] {
+			out.Deadlocks[i][j] = api.ConvertBlockedGoroutine(s.debugger.Target(), deadlocks[i][j])
+			out.Deadlocks[i][j].Goroutine.WaitDuration = waits[j]
+		}
+	}
+	return nil
+}
+
+type GetRuntimeMetricsIn struct {
+}
+
+type GetRuntimeMetricsOut struct {
+	Metrics api.RuntimeMetrics
+}
+
+// GetRuntimeMetrics returns statistics kept by the runtime of the target:
+// the size of the heap, the number of GC cycles, the number of goroutines
+// and the distribution of scheduling latencies. They are read from the
+// memory of the target, without calling any function.
+func (s *RPCServer) GetRuntimeMetrics(arg GetRuntimeMetricsIn, out *GetRuntimeMetricsOut) error {
+	m, err := s.debugger.RuntimeMetrics()
+	if err != nil {
+		return err
+	}
+	out.Metrics = *api.ConvertRuntimeMetrics(m)
+	return nil
+}
+
+// ChanInfoIn holds the arguments of ChanInfo
+type ChanInfoIn struct {
+	Scope api.EvalScope
+	Expr  string
+	// Cfg is the configuration used to load the elements of the buffer of
+	// the channel.
+	Cfg api.LoadConfig
+}
+
+// ChanInfoOut holds the return values of ChanInfo
+type ChanInfoOut struct {
+	Chan api.ChanInfo
+}
+
+// ChanInfo evaluates Expr, which must be a channel, and returns its length,
+// capacity, whether it is closed, the contents of its buffer and the
+// goroutines blocked sending to it or receiving from it.
+func (s *RPCServer) ChanInfo(arg ChanInfoIn, out *ChanInfoOut) error {
+	typ, ci, err := s.debugger.ChanInfo(arg.Scope.GoroutineID, arg.Scope.Frame, arg.Scope.DeferredCall, arg.Expr, *api.LoadConfigToProc(&arg.Cfg))
+	if err != nil {
+		return err
+	}
+	out.Chan = *api.ConvertChanInfo(s.debugger.Target(), typ, ci)
+	setWaitDurations(out.Chan.Senders, s.debugger.GoroutinesWaitDurations(ci.Senders))
+	setWaitDurations(out.Chan.Receivers, s.debugger.GoroutinesWaitDurations(ci.Receivers))
+	return nil
+}
+
+// MutexInfoIn holds the arguments of MutexInfo
+type MutexInfoIn struct {
+	Scope api.EvalScope
+	Expr  string
+}
+
+// MutexInfoOut holds the return values of MutexInfo
+type MutexInfoOut struct {
+	Mutex api.MutexInfo
+}
+
+// MutexInfo evaluates Expr, which must be a sync.Mutex, a sync.RWMutex or
+// a pointer to one of them, and returns whether it is locked, the
+// goroutines waiting to lock it and the goroutines that possibly hold it.
+//
+// The runtime does not record which goroutine holds a mutex, possible
+// holders are the goroutines that aren't waiting on the mutex and whose
+// local variables contain a pointer to it (or to the heap object containing
+// it).
+func (s *RPCServer) MutexInfo(arg MutexInfoIn, out *MutexInfoOut) error {
+	typ, mi, err := s.debugger.MutexInfo(arg.Scope.GoroutineID, arg.Scope.Frame, arg.Scope.DeferredCall, arg.Expr)
+	if err != nil {
+		return err
+	}
+	out.Mutex = *api.ConvertMutexInfo(s.debugger.Target(), typ, mi)
+	setWaitDurations(out.Mutex.Waiters, s.debugger.GoroutinesWaitDurations(mi.Waiters))
+	setWaitDurations(out.Mutex.ReadWaiters, s.debugger.GoroutinesWaitDurations(mi.ReadWaiters))
+	setWaitDurations(out.Mutex.Holders, s.debugger.GoroutinesWaitDurations(mi.Holders))
+	return nil
+}
+
+// setWaitDurations sets the WaitDuration field of each goroutine, waits
+// must be computed by the debugger while holding the target lock.
+func setWaitDurations(goroutines []*api.Goroutine, waits []time.Duration) {
+	for i := range goroutines {
+		goroutines[i].WaitDuration = waits[i]
+	}
+}
+
+// ContextChainIn holds the arguments of ContextChain
+type ContextChainIn struct {
+	Scope api.EvalScope
+	Expr  string
+	// Cfg is the configuration used to load keys, values and errors.
+	Cfg api.LoadConfig
+}
+
+// ContextChainOut holds the return values of ContextChain
+type ContextChainOut struct {
+	Layers []api.ContextLayer
+}
+
+// ContextChain evaluates Expr, which must be a context.Context, and returns
+// its layers starting from the value of Expr and following the parent of
+// each layer: their kind, the deadline, whether they have been canceled and
+// the key/value pairs they store.
+func (s *RPCServer) ContextChain(arg ContextChainIn, out *ContextChainOut) error {
+	layers, err := s.debugger.ContextChain(arg.Scope.GoroutineID, arg.Scope.Frame, arg.Scope.DeferredCall, arg.Expr, *api.LoadConfigToProc(&arg.Cfg))
+	if err != nil {
+		return err
+	}
+	out.Layers = api.ConvertContextChain(layers)
+	return nil
+}
+
+type GetGCStateIn struct {
+}
+
+type GetGCStateOut struct {
+	State api.GCState
+}
+
+// GetGCState returns the state of the garbage collector of the target: its
+// phase, the size of the heap, the heap goal, the estimated heap size that
+// will trigger the next cycle and the pause of the last cycle.
+func (s *RPCServer) GetGCState(arg GetGCStateIn, out *GetGCStateOut) error {
+	st, err := s.debugger.GCState()
+	if err != nil {
+		return err
+	}
+	out.State = *api.ConvertGCState(st)
+	return nil
+}
+
 type StopRecordingIn struct {
 }
 
@@ -953,6 +1527,10 @@ func (s *RPCServer) StopRecording(arg StopRecordingIn, cb service.RPCCallback) {
 
 type DumpStartIn struct {
 	Destination string
+	// Native writes the core dump in the format native to the operating
+	// system of the target (a minidump on windows, a Mach-O core file on
+	// macOS) instead of ELF.
+	Native bool
 }
 
 type DumpStartOut struct {
@@ -961,7 +1539,7 @@ type DumpStartOut struct {
 
 // DumpStart starts a core dump to arg.Destination.
 func (s *RPCServer) DumpStart(arg DumpStartIn, out *DumpStartOut) error {
-	err := s.debugger.DumpStart(arg.Destination)
+	err := s.debugger.DumpStart(arg.Destination, arg.Native)
 	if err != nil {
 		return err
 	}
@@ -996,6 +1574,88 @@ func (s *RPCServer) DumpCancel(arg DumpCancelIn, out *DumpCancelOut) error {
 	return s.debugger.DumpCancel()
 }
 
+type DumpHeapIn struct {
+	Destination string
+	// Format is the format of the output file, "pprof" or "dot".
+	Format string
+}
+
+type DumpHeapOut struct {
+	// Objects is the number of heap objects written and Bytes the memory
+	// they use.
+	Objects int
+	Bytes   uint64
+}
+
+// DumpHeap writes the graph of the live heap objects of the target, with
+// their type, size and the references between them, to arg.Destination.
+//
+// With the "pprof" format the output is a pprof heap profile where the
+// stack of each sample is the shortest path of references keeping the
+// objects alive, with the "dot" format it is a Graphviz DOT graph.
+// The type of heap objects is inferred like ListHeapObjects does.
+func (s *RPCServer) DumpHeap(arg DumpHeapIn, out *DumpHeapOut) error {
+	var err error
+	out.Objects, out.Bytes, err = s.debugger.DumpHeap(arg.Destination, arg.Format)
+	return err
+}
+
+type ProfileCPUIn struct {
+	// GoroutineID is the goroutine used to call the functions of
+	// runtime/pprof, the selected goroutine is used if it is 0.
+	GoroutineID int
+	Duration    time.Duration
+}
+
+type ProfileCPUOut struct {
+	// Profile is the CPU profile, in the format written by runtime/pprof.
+	Profile []byte
+}
+
+// ProfileCPU resumes the target for arg.Duration while its CPU profiler is
+// running and returns the profile collected.
+//
+// The profiler is started and stopped by calling runtime/pprof.StartCPUProfile
+// and runtime/pprof.StopCPUProfile on the target, which must import
+// runtime/pprof and be stopped at a point where function calls are
+// allowed. The target is stopped again once the duration expires.
+// There is no fallback for targets that do not import runtime/pprof: the Go
+// runtime only writes CPU profiles through it.
+func (s *RPCServer) ProfileCPU(arg ProfileCPUIn, cb service.RPCCallback) {
+	profile, err := s.debugger.ProfileCPU(arg.GoroutineID, arg.Duration, cb.SetupDoneChan())
+	if err != nil {
+		cb.Return(nil, err)
+		return
+	}
+	cb.Return(ProfileCPUOut{Profile: profile}, nil)
+}
+
+type ProfileTraceIn struct {
+	// GoroutineID is the goroutine used to call the functions of
+	// runtime/trace, the selected goroutine is used if it is 0.
+	GoroutineID int
+	Duration    time.Duration
+}
+
+type ProfileTraceOut struct {
+	// Trace is the execution trace, in the format written by runtime/trace.
+	Trace []byte
+}
+
+// ProfileTrace resumes the target for arg.Duration while its execution
+// tracer is running and returns the trace collected.
+//
+// The tracer is started and stopped by calling runtime/trace.Start and
+// runtime/trace.Stop on the target, see ProfileCPU.
+func (s *RPCServer) ProfileTrace(arg ProfileTraceIn, cb service.RPCCallback) {
+	trace, err := s.debugger.ProfileTrace(arg.GoroutineID, arg.Duration, cb.SetupDoneChan())
+	if err != nil {
+		cb.Return(nil, err)
+		return
+	}
+	cb.Return(ProfileTraceOut{Trace: trace}, nil)
+}
+
 type CreateWatchpointIn struct {
 	Scope api.EvalScope
 	Expr  string
@@ -1023,3 +1683,607 @@ func (s *RPCServer) BuildID(arg BuildIDIn, out *BuildIDOut) error {
 	out.BuildID = s.debugger.BuildID()
 	return nil
 }
+
+// defaultStreamChunkSize is the number of items sent in each chunk by
+// streaming calls when ChunkSize is not specified.
+const defaultStreamChunkSize = 50
+
+func streamChunkSize(chunkSize, n int) int {
+	if chunkSize <= 0 {
+		chunkSize = defaultStreamChunkSize
+	}
+	if chunkSize > n {
+		return n
+	}
+	return chunkSize
+}
+
+// StreamOut is the output of streaming calls, returned after all chunks
+// have been sent.
+type StreamOut struct {
+	// Count is the number of items sent.
+	Count int
+	// Canceled is true if the call was canceled by the client.
+	Canceled bool
+}
+
+type StreamStacktraceIn struct {
+	StacktraceIn
+	// ChunkSize is the maximum number of frames in each chunk.
+	ChunkSize int
+}
+
+type StreamStacktraceChunk struct {
+	Locations []api.Stackframe
+}
+
+// StreamStacktrace is the streaming variant of Stacktrace, the frames are
+// sent as StreamStacktraceChunk values, ChunkSize frames at a time.
+func (s *RPCServer) StreamStacktrace(arg StreamStacktraceIn, cb service.RPCStream) {
+	close(cb.SetupDoneChan())
+	cfg := arg.Cfg
+	if cfg == nil && arg.Full {
+		cfg = &api.LoadConfig{FollowPointers: true, MaxVariableRecurse: 1, MaxStringLen: 64, MaxArrayValues: 64, MaxStructFields: -1}
+	}
+	if arg.Defers {
+		arg.Opts |= api.StacktraceReadDefers
+	}
+	rawlocs, err := s.debugger.Stacktrace(arg.Id, arg.Depth, arg.Opts)
+	if err != nil {
+		cb.Return(nil, err)
+		return
+	}
+	var out StreamOut
+	for len(rawlocs) > 0 {
+		n := streamChunkSize(arg.ChunkSize, len(rawlocs))
+		locs, err := s.debugger.ConvertStacktraceFrames(rawlocs, n, api.LoadConfigToProc(cfg))
+		if err != nil {
+			cb.Return(nil, err)
+			return
+		}
+		rawlocs = rawlocs[n:]
+		if !cb.Send(&StreamStacktraceChunk{Locations: locs}) {
+			out.Canceled = true
+			break
+		}
+		out.Count += n
+	}
+	cb.Return(out, nil)
+}
+
+type StreamGoroutinesIn struct {
+	Start int
+	// Count, if greater than zero, is the maximum number of goroutines
+	// sent.
+	Count int
+
+	Filters []api.ListGoroutinesFilter
+
+	// If StacktraceDepth is greater than zero the stacktraces of the
+	// goroutines, up to StacktraceDepth frames, are sent in Stacktraces.
+	StacktraceDepth int
+
+	// ChunkSize is the maximum number of goroutines in each chunk.
+	ChunkSize int
+}
+
+type StreamGoroutinesChunk struct {
+	Goroutines []*api.Goroutine
+
+	// Stacktraces[i] is the stacktrace of Goroutines[i], only sent if
+	// StacktraceDepth was specified.
+	Stacktraces [][]api.Stackframe
+}
+
+// StreamGoroutines is the streaming variant of ListGoroutines, the
+// goroutines matching arg.Filters are sent as StreamGoroutinesChunk values
+// as they are read, ChunkSize goroutines at a time. Grouping is not
+// supported.
+func (s *RPCServer) StreamGoroutines(arg StreamGoroutinesIn, cb service.RPCStream) {
+	close(cb.SetupDoneChan())
+	var out StreamOut
+	start := arg.Start
+	for start >= 0 && (arg.Count <= 0 || out.Count < arg.Count) {
+		n := arg.ChunkSize
+		if n <= 0 {
+			n = defaultStreamChunkSize
+		}
+		if arg.Count > 0 && arg.Count-out.Count < n {
+			n = arg.Count - out.Count
+		}
+		gs, nextg, err := s.debugger.Goroutines(start, n)
+		if err != nil {
+			cb.Return(nil, err)
+			return
+		}
+		start = nextg
+		gs = s.debugger.FilterGoroutines(gs, arg.Filters)
+		if len(gs) == 0 {
+			continue
+		}
+		chunk := &StreamGoroutinesChunk{}
+		if arg.StacktraceDepth > 0 {
+			chunk.Stacktraces, err = s.debugger.GoroutinesStacktraces(gs, arg.StacktraceDepth, 0)
+			if err != nil {
+				cb.Return(nil, err)
+				return
+			}
+		}
+		waits := s.debugger.GoroutinesWaitDurations(gs)
+		s.debugger.LockTarget()
+		chunk.Goroutines = api.ConvertGoroutines(s.debugger.Target(), gs)
+		s.debugger.UnlockTarget()
+		setWaitDurations(chunk.Goroutines, waits)
+		if !cb.Send(chunk) {
+			out.Canceled = true
+			break
+		}
+		out.Count += len(gs)
+	}
+	cb.Return(out, nil)
+}
+
+type StreamFunctionsIn struct {
+	Filter string
+	// ChunkSize is the maximum number of functions in each chunk.
+	ChunkSize int
+}
+
+type StreamFunctionsChunk struct {
+	Funcs []string
+}
+
+// StreamFunctions is the streaming variant of ListFunctions, the functions
+// are sent as StreamFunctionsChunk values, ChunkSize functions at a time.
+func (s *RPCServer) StreamFunctions(arg StreamFunctionsIn, cb service.RPCStream) {
+	close(cb.SetupDoneChan())
+	fns, err := s.debugger.Functions(arg.Filter)
+	if err != nil {
+		cb.Return(nil, err)
+		return
+	}
+	var out StreamOut
+	for len(fns) > 0 {
+		n := streamChunkSize(arg.ChunkSize, len(fns))
+		if !cb.Send(&StreamFunctionsChunk{Funcs: fns[:n]}) {
+			out.Canceled = true
+			break
+		}
+		fns = fns[n:]
+		out.Count += n
+	}
+	cb.Return(out, nil)
+}
+
+type StreamVarsIn struct {
+	Scope api.EvalScope
+	Cfg   api.LoadConfig
+	// Args selects the arguments of the function instead of its local
+	// variables.
+	Args bool
+	// ChunkSize is the maximum number of variables in each chunk.
+	ChunkSize int
+}
+
+type StreamVarsChunk struct {
+	Variables []api.Variable
+}
+
+// StreamVars is the streaming variant of ListLocalVars and
+// ListFunctionArgs, the variables are sent as StreamVarsChunk values,
+// ChunkSize variables at a time.
+func (s *RPCServer) StreamVars(arg StreamVarsIn, cb service.RPCStream) {
+	close(cb.SetupDoneChan())
+	list := s.debugger.LocalVariables
+	if arg.Args {
+		list = s.debugger.FunctionArguments
+	}
+	vars, err := list(arg.Scope.GoroutineID, arg.Scope.Frame, arg.Scope.DeferredCall, *api.LoadConfigToProc(&arg.Cfg))
+	if err != nil {
+		cb.Return(nil, err)
+		return
+	}
+	var out StreamOut
+	for len(vars) > 0 {
+		n := streamChunkSize(arg.ChunkSize, len(vars))
+		if !cb.Send(&StreamVarsChunk{Variables: api.ConvertVars(vars[:n])}) {
+			out.Canceled = true
+			break
+		}
+		vars = vars[n:]
+		out.Count += n
+	}
+	cb.Return(out, nil)
+}
+
+// eventsBufferSize is the number of events that are queued for a
+// subscriber before they are dropped.
+const eventsBufferSize = 100
+
+// sessionTimeout is how long the events of a resumable session are kept
+// after the connection of its client is lost.
+const sessionTimeout = 10 * time.Minute
+
+type SubscribeIn struct {
+	// Session, if not empty, is the id of a resumable session, chosen by
+	// the client. If the connection is lost the events are queued until
+	// Subscribe is called again with the same id, by a new connection,
+	// for up to 10 minutes. The session ends when EndSession is called.
+	Session string
+}
+
+// Subscribe sends the events of the debugger (the target being resumed or
+// stopping, breakpoints being created, changed or cleared, the process
+// exiting, a new process being attached) as api.Event chunks, including
+// events caused by other clients, until the call is canceled.
+// Events are dropped if the client does not keep up with them.
+//
+// When a session is resumed the events queued while the client was
+// disconnected are sent first, followed by an EventSessionResumed event
+// with the current state of the debugger.
+func (s *RPCServer) Subscribe(arg SubscribeIn, cb service.RPCStream) {
+	if arg.Session != "" {
+		s.subscribeSession(arg.Session, cb)
+		return
+	}
+	events := make(chan *api.Event, eventsBufferSize)
+	unsubscribe := s.debugger.Subscribe(func(ev *api.Event) {
+		select {
+		case events <- ev:
+		default:
+		}
+	})
+	defer unsubscribe()
+	// requests received after Subscribe on the same connection are
+	// guaranteed to see the subscription
+	close(cb.SetupDoneChan())
+	var out StreamOut
+	for {
+		select {
+		case ev := <-events:
+			if !cb.Send(ev) {
+				out.Canceled = true
+				cb.Return(out, nil)
+				return
+			}
+			out.Count++
+		case <-cb.Canceled():
+			out.Canceled = true
+			cb.Return(out, nil)
+			return
+		}
+	}
+}
+
+// session is the state of a resumable session, see SubscribeIn.
+type session struct {
+	mu          sync.Mutex
+	events      []*api.Event
+	dropped     int
+	unsubscribe func()
+	// wake receives a value when events are queued.
+	wake chan struct{}
+	// detach is closed to stop the Subscribe call serving the session, when
+	// it is resumed by another connection or ended.
+	detach chan struct{}
+	expire *time.Timer
+}
+
+func (sess *session) push(ev *api.Event) {
+	sess.mu.Lock()
+	if len(sess.events) >= eventsBufferSize {
+		sess.events = sess.events[1:]
+		sess.dropped++
+	}
+	sess.events = append(sess.events, ev)
+	sess.mu.Unlock()
+	select {
+	case sess.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pop returns the first queued event, or nil.
+func (sess *session) pop() *api.Event {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if len(sess.events) == 0 {
+		return nil
+	}
+	ev := sess.events[0]
+	sess.events = sess.events[1:]
+	return ev
+}
+
+// unpop puts back an event that could not be sent.
+func (sess *session) unpop(ev *api.Event) {
+	sess.mu.Lock()
+	sess.events = append([]*api.Event{ev}, sess.events...)
+	sess.mu.Unlock()
+}
+
+// attachSession makes the session id served by a new Subscribe call,
+// creating it if needed, resumed is true if the session already existed.
+func (s *RPCServer) attachSession(id string) (sess *session, resumed bool) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	if s.sessions == nil {
+		s.sessions = make(map[string]*session)
+	}
+	sess = s.sessions[id]
+	if sess == nil {
+		sess = &session{wake: make(chan struct{}, 1)}
+		sess.unsubscribe = s.debugger.Subscribe(sess.push)
+		s.sessions[id] = sess
+	} else {
+		resumed = true
+		if sess.expire != nil {
+			sess.expire.Stop()
+			sess.expire = nil
+		}
+		if sess.detach != nil {
+			close(sess.detach)
+		}
+	}
+	sess.detach = make(chan struct{})
+	return sess, resumed
+}
+
+// endSession removes the session id, returning false if it does not exist.
+func (s *RPCServer) endSession(id string) bool {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	sess := s.sessions[id]
+	if sess == nil {
+		return false
+	}
+	delete(s.sessions, id)
+	sess.unsubscribe()
+	if sess.expire != nil {
+		sess.expire.Stop()
+	}
+	if sess.detach != nil {
+		close(sess.detach)
+		sess.detach = nil
+	}
+	return true
+}
+
+// disconnectSession is called when the connection serving the session id
+// is lost, the session is kept for sessionTimeout.
+func (s *RPCServer) disconnectSession(id string, sess *session, detach chan struct{}) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	if s.sessions[id] != sess || sess.detach != detach {
+		// ended or resumed by another connection
+		return
+	}
+	sess.detach = nil
+	sess.expire = time.AfterFunc(sessionTimeout, func() {
+		s.sessionsMu.Lock()
+		defer s.sessionsMu.Unlock()
+		if s.sessions[id] == sess && sess.detach == nil {
+			delete(s.sessions, id)
+			sess.unsubscribe()
+		}
+	})
+	if s.config.HaltOnDisconnect && s.debugger.IsRunning() {
+		go s.debugger.Command(&api.DebuggerCommand{Name: api.Halt}, nil)
+	}
+}
+
+func (s *RPCServer) subscribeSession(id string, cb service.RPCStream) {
+	sess, resumed := s.attachSession(id)
+	s.sessionsMu.Lock()
+	detach := sess.detach
+	s.sessionsMu.Unlock()
+	close(cb.SetupDoneChan())
+
+	var out StreamOut
+	finish := func() {
+		if cb.Disconnected() {
+			s.disconnectSession(id, sess, detach)
+		} else {
+			select {
+			case <-detach:
+			default:
+				// canceled by the client
+				s.endSession(id)
+			}
+		}
+		out.Canceled = true
+		cb.Return(out, nil)
+	}
+
+	for {
+		ev := sess.pop()
+		if ev == nil {
+			if resumed {
+				resumed = false
+				ev = &api.Event{Kind: api.EventSessionResumed}
+				state, err := s.debugger.State(true)
+				if err != nil {
+					ev.Err = err.Error()
+				}
+				ev.State = state
+				sess.mu.Lock()
+				ev.Dropped, sess.dropped = sess.dropped, 0
+				sess.mu.Unlock()
+			}
+		}
+		if ev != nil {
+			if !cb.Send(ev) {
+				if ev.Kind != api.EventSessionResumed {
+					sess.unpop(ev)
+				}
+				select {
+				case <-cb.Canceled():
+				case <-detach:
+				}
+				finish()
+				return
+			}
+			out.Count++
+			continue
+		}
+		select {
+		case <-sess.wake:
+		case <-detach:
+			finish()
+			return
+		case <-cb.Canceled():
+			finish()
+			return
+		}
+	}
+}
+
+type EndSessionIn struct {
+	Session string
+}
+
+type EndSessionOut struct {
+}
+
+// EndSession ends the resumable session Session (see SubscribeIn), the
+// Subscribe call serving it returns.
+func (s *RPCServer) EndSession(arg EndSessionIn, out *EndSessionOut) error {
+	if !s.endSession(arg.Session) {
+		return fmt.Errorf("unknown session %q", arg.Session)
+	}
+	return nil
+}
+
+type StreamTTYIn struct {
+}
+
+type StreamTTYChunk struct {
+	Data []byte
+}
+
+// StreamTTY sends the output of the target, written to its pseudo-terminal
+// (see the --pty option), as StreamTTYChunk values until the call is
+// canceled. Output is dropped if the client does not keep up with it.
+func (s *RPCServer) StreamTTY(arg StreamTTYIn, cb service.RPCStream) {
+	chunks := make(chan []byte, eventsBufferSize)
+	unsubscribe, err := s.debugger.SubscribeTTY(func(data []byte) {
+		select {
+		case chunks <- data:
+		default:
+		}
+	})
+	if err != nil {
+		cb.Return(nil, err)
+		return
+	}
+	defer unsubscribe()
+	close(cb.SetupDoneChan())
+	var out StreamOut
+	for {
+		select {
+		case data := <-chunks:
+			if !cb.Send(&StreamTTYChunk{Data: data}) {
+				out.Canceled = true
+				cb.Return(out, nil)
+				return
+			}
+			out.Count++
+		case <-cb.Canceled():
+			out.Canceled = true
+			cb.Return(out, nil)
+			return
+		}
+	}
+}
+
+type WriteTTYIn struct {
+	Data []byte
+}
+
+type WriteTTYOut struct {
+}
+
+// WriteTTY writes Data to the pseudo-terminal of the target (see the --pty
+// option), as if it was typed by the user.
+func (s *RPCServer) WriteTTY(arg WriteTTYIn, out *WriteTTYOut) error {
+	return s.debugger.WriteTTY(arg.Data)
+}
+
+type ResizeTTYIn struct {
+	Rows, Cols int
+}
+
+type ResizeTTYOut struct {
+}
+
+// ResizeTTY changes the size of the pseudo-terminal of the target (see the
+// --pty option), clients should call it when the size of their own
+// terminal changes.
+func (s *RPCServer) ResizeTTY(arg ResizeTTYIn, out *ResizeTTYOut) error {
+	return s.debugger.ResizeTTY(arg.Rows, arg.Cols)
+}
+
+type ListTargetsIn struct {
+}
+
+type ListTargetsOut struct {
+	Targets []api.Target
+}
+
+// ListTargets returns the processes being debugged. There is more than one
+// when the debugger attached to the children of the process, see
+// FollowExec. The current target is the one with the pid returned by
+// ProcessPid.
+func (s *RPCServer) ListTargets(arg ListTargetsIn, out *ListTargetsOut) error {
+	tgts := s.debugger.Targets()
+	s.debugger.LockTarget()
+	defer s.debugger.UnlockTarget()
+	out.Targets = make([]api.Target, 0, len(tgts))
+	for _, tgt := range tgts {
+		if _, err := tgt.Valid(); err != nil {
+			continue
+		}
+		out.Targets = append(out.Targets, *api.ConvertTarget(tgt))
+	}
+	return nil
+}
+
+type FollowExecIn struct {
+	Enable bool
+	// Regex and Exclude are regular expressions matched against the path of
+	// the program executed by a child. Empty expressions are ignored.
+	Regex   string
+	Exclude string
+}
+
+type FollowExecOut struct {
+}
+
+// FollowExec enables or disables following the children of the processes
+// being debugged. While it is enabled every child that executes a program
+// matching Regex and not matching Exclude is attached and resumed, as is
+// every child that forks without executing a program if the program of its
+// parent matches them. The target it was spawned from stays stopped until
+// the child exits.
+// The current target can be changed with the switchTarget command.
+//
+// Only supported on linux with the native backend.
+func (s *RPCServer) FollowExec(arg FollowExecIn, out *FollowExecOut) error {
+	return s.debugger.FollowExec(arg.Enable, arg.Regex, arg.Exclude)
+}
+
+type FollowExecEnabledIn struct {
+}
+
+type FollowExecEnabledOut struct {
+	Enabled bool
+	Regex   string
+	Exclude string
+}
+
+// FollowExecEnabled returns true if following children is enabled, see
+// FollowExec.
+func (s *RPCServer) FollowExecEnabled(arg FollowExecEnabledIn, out *FollowExecEnabledOut) error {
+	out.Enabled, out.Regex, out.Exclude = s.debugger.FollowExecEnabled()
+	return nil
+}
@@ -9,3 +9,21 @@ type RPCCallback interface {
 	// receive other requests.
 	SetupDoneChan() chan struct{}
 }
+
+// RPCStream is used by streaming RPC methods to send their result in
+// chunks, before returning.
+type RPCStream interface {
+	RPCCallback
+
+	// Send sends a chunk of the result to the client. It returns false if
+	// the client canceled the call, the method should then stop and return.
+	Send(chunk interface{}) bool
+
+	// Canceled returns a channel that is closed when the client cancels the
+	// call or disconnects.
+	Canceled() <-chan struct{}
+
+	// Disconnected returns true if the call was canceled because the
+	// connection to the client was lost, rather than by the client.
+	Disconnected() bool
+}
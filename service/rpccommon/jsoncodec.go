@@ -0,0 +1,133 @@
+package rpccommon
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/rpc"
+	"sync"
+)
+
+// jsonServerCodec is a rpc.ServerCodec for JSON-RPC 1.0, like the one
+// returned by jsonrpc.NewServerCodec, that can also send the chunks of the
+// result of streaming calls as responses with the id of the call, before its
+// final response.
+type jsonServerCodec struct {
+	dec *json.Decoder // for reading JSON values
+	enc *json.Encoder // for writing JSON values
+	c   io.Closer
+
+	// temporary work space
+	req serverRequest
+
+	// JSON-RPC clients can use arbitrary json values as request IDs, the
+	// codec replaces them with sequence numbers and pending maps them back
+	// to the original IDs.
+	mutex   sync.Mutex // protects seq, pending
+	seq     uint64
+	pending map[uint64]*json.RawMessage
+}
+
+type serverRequest struct {
+	Method string           `json:"method"`
+	Params *json.RawMessage `json:"params"`
+	Id     *json.RawMessage `json:"id"`
+}
+
+type serverResponse struct {
+	Id     *json.RawMessage `json:"id"`
+	Result interface{}      `json:"result"`
+	Error  interface{}      `json:"error"`
+}
+
+func newJSONServerCodec(conn io.ReadWriteCloser) *jsonServerCodec {
+	return &jsonServerCodec{
+		dec:     json.NewDecoder(conn),
+		enc:     json.NewEncoder(conn),
+		c:       conn,
+		pending: make(map[uint64]*json.RawMessage),
+	}
+}
+
+func (c *jsonServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	c.req = serverRequest{}
+	if err := c.dec.Decode(&c.req); err != nil {
+		return err
+	}
+	r.ServiceMethod = c.req.Method
+
+	c.mutex.Lock()
+	c.seq++
+	c.pending[c.seq] = c.req.Id
+	c.req.Id = nil
+	r.Seq = c.seq
+	c.mutex.Unlock()
+
+	return nil
+}
+
+// id returns the id, sent by the client, of the request seq.
+func (c *jsonServerCodec) id(seq uint64) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if b := c.pending[seq]; b != nil {
+		return string(*b)
+	}
+	return ""
+}
+
+var errMissingParams = errors.New("jsonrpc: request body missing params")
+
+func (c *jsonServerCodec) ReadRequestBody(x interface{}) error {
+	if x == nil {
+		return nil
+	}
+	if c.req.Params == nil {
+		return errMissingParams
+	}
+	// JSON params is array value, RPC params is struct.
+	var params [1]interface{}
+	params[0] = x
+	return json.Unmarshal(*c.req.Params, &params)
+}
+
+var null = json.RawMessage([]byte("null"))
+
+func (c *jsonServerCodec) WriteResponse(r *rpc.Response, x interface{}) error {
+	return c.write(r, x, true)
+}
+
+// WriteChunk writes a chunk of the result of the call r.Seq, without
+// completing the call.
+func (c *jsonServerCodec) WriteChunk(r *rpc.Response, x interface{}) error {
+	return c.write(r, x, false)
+}
+
+func (c *jsonServerCodec) write(r *rpc.Response, x interface{}, final bool) error {
+	c.mutex.Lock()
+	b, ok := c.pending[r.Seq]
+	if !ok {
+		c.mutex.Unlock()
+		return errors.New("invalid sequence number in response")
+	}
+	if final {
+		delete(c.pending, r.Seq)
+	}
+	c.mutex.Unlock()
+
+	if b == nil {
+		// Invalid request so no id. Use JSON null.
+		b = &null
+	}
+	resp := serverResponse{Id: b}
+	if r.Error == "" {
+		resp.Result = x
+	} else {
+		resp.Error = r.Error
+	}
+	return c.enc.Encode(resp)
+}
+
+func (c *jsonServerCodec) Close() error {
+	return c.c.Close()
+}
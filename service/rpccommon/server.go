@@ -8,10 +8,10 @@ import (
 	"io"
 	"net"
 	"net/rpc"
-	"net/rpc/jsonrpc"
 	"os"
 	"reflect"
 	"runtime"
+	"strconv"
 	"sync"
 	"unicode"
 	"unicode/utf8"
@@ -23,6 +23,7 @@ import (
 	"github.com/go-delve/delve/service/dap"
 	"github.com/go-delve/delve/service/debugger"
 	"github.com/go-delve/delve/service/internal/sameuser"
+	"github.com/go-delve/delve/service/internal/websocket"
 	"github.com/go-delve/delve/service/rpc1"
 	"github.com/go-delve/delve/service/rpc2"
 	"github.com/sirupsen/logrus"
@@ -51,12 +52,65 @@ type ServerImpl struct {
 type RPCCallback struct {
 	s         *ServerImpl
 	sending   *sync.Mutex
-	codec     rpc.ServerCodec
+	codec     *jsonServerCodec
 	req       rpc.Request
 	setupDone chan struct{}
+
+	// streams, streamID and canceled are only set for streaming calls.
+	streams  *streamSet
+	streamID string
+	canceled chan struct{}
+}
+
+var _ service.RPCStream = &RPCCallback{}
+
+// cancelStreamMethod is the method used by clients to cancel a streaming
+// call. It is handled by the connection rather than by a server since it
+// refers to a call made on the same connection.
+const cancelStreamMethod = "RPCServer.CancelStream"
+
+// streamSet is the set of streaming calls in progress on a connection,
+// indexed by the id of their request.
+type streamSet struct {
+	mu sync.Mutex
+	m  map[string]chan struct{}
+	// lost is set when the streaming calls are canceled because the
+	// connection was closed.
+	lost bool
+}
+
+func (ss *streamSet) add(id string) chan struct{} {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ch := make(chan struct{})
+	ss.m[id] = ch
+	return ch
+}
+
+// remove removes id from the set, closing its channel if cancel is true.
+func (ss *streamSet) remove(id string, cancel bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ch, ok := ss.m[id]
+	if !ok {
+		return
+	}
+	delete(ss.m, id)
+	if cancel {
+		close(ch)
+	}
 }
 
-var _ service.RPCCallback = &RPCCallback{}
+// cancelAll cancels all streaming calls, when the connection is closed.
+func (ss *streamSet) cancelAll() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.lost = true
+	for id, ch := range ss.m {
+		delete(ss.m, id)
+		close(ch)
+	}
+}
 
 // RPCServer implements the RPC method calls common to all versions of the API.
 type RPCServer struct {
@@ -69,6 +123,7 @@ type methodType struct {
 	ArgType     reflect.Type
 	ReplyType   reflect.Type
 	Synchronous bool
+	Stream      bool
 }
 
 // NewServer creates a new RPCServer.
@@ -113,7 +168,7 @@ func (s *ServerImpl) Run() error {
 	if s.config.APIVersion < 2 {
 		s.config.APIVersion = 1
 	}
-	if s.config.APIVersion > 2 {
+	if s.config.APIVersion > api.MaxAPIVersion {
 		return fmt.Errorf("unknown API version")
 	}
 
@@ -128,7 +183,7 @@ func (s *ServerImpl) Run() error {
 
 	rpcServer := &RPCServer{s}
 
-	s.methodMaps = make([]map[string]*methodType, 2)
+	s.methodMaps = make([]map[string]*methodType, api.MaxAPIVersion)
 
 	s.methodMaps[0] = map[string]*methodType{}
 	s.methodMaps[1] = map[string]*methodType{}
@@ -179,11 +234,30 @@ func (s *ServerImpl) serveConnectionDemux(c io.ReadWriteCloser) {
 		s.log.Warnf("error determining new connection protocol: %v", err)
 		return
 	}
-	if b[0] == 'C' { // C is for DAP's Content-Length
+	switch b[0] {
+	case 'C': // C is for DAP's Content-Length
 		s.log.Debugf("serving DAP on new connection")
 		ds := dap.NewSession(conn, &dap.Config{Config: s.config, StopTriggered: s.stopChan}, s.debugger)
 		go ds.ServeDAPCodec()
-	} else {
+	case 'G': // G is for the GET request of the WebSocket handshake
+		// The Host header is not checked for TCP connections made to an
+		// address that isn't a loopback address, on which the server can
+		// have any name.
+		loopback := true
+		if nc, ok := c.(net.Conn); ok {
+			if tcpaddr, ok := nc.LocalAddr().(*net.TCPAddr); ok {
+				loopback = tcpaddr.IP.IsLoopback()
+			}
+		}
+		wsconn, err := websocket.Accept(c, conn.Reader, websocket.CheckOrigin(s.config.AllowedOrigins, loopback))
+		if err != nil {
+			s.log.Warnf("error accepting WebSocket connection: %v", err)
+			c.Close()
+			return
+		}
+		s.log.Debugf("serving JSON-RPC over WebSocket on new connection")
+		go s.serveJSONCodec(wsconn)
+	default:
 		s.log.Debugf("serving JSON-RPC on new connection")
 		go s.serveJSONCodec(conn)
 	}
@@ -212,9 +286,10 @@ func isExportedOrBuiltinType(t reflect.Type) bool {
 // Fills methods map with the methods of receiver that should be made
 // available through the RPC interface.
 // These are all the public methods of rcvr that have one of those
-// two signatures:
+// three signatures:
 //  func (rcvr ReceiverType) Method(in InputType, out *ReplyType) error
 //  func (rcvr ReceiverType) Method(in InputType, cb service.RPCCallback)
+//  func (rcvr ReceiverType) Method(in InputType, cb service.RPCStream)
 func suitableMethods(rcvr interface{}, methods map[string]*methodType, log *logrus.Entry) {
 	typ := reflect.TypeOf(rcvr)
 	rcvrv := reflect.ValueOf(rcvr)
@@ -244,7 +319,8 @@ func suitableMethods(rcvr interface{}, methods map[string]*methodType, log *logr
 		}
 
 		replyType := mtype.In(2)
-		synchronous := replyType.String() != "service.RPCCallback"
+		stream := replyType.String() == "service.RPCStream"
+		synchronous := replyType.String() != "service.RPCCallback" && !stream
 
 		if synchronous {
 			// Second arg must be a pointer.
@@ -273,7 +349,7 @@ func suitableMethods(rcvr interface{}, methods map[string]*methodType, log *logr
 			log.Warn("method", mname, "has wrong number of outs:", mtype.NumOut())
 			continue
 		}
-		methods[sname+"."+mname] = &methodType{method: method, ArgType: argType, ReplyType: replyType, Synchronous: synchronous, Rcvr: rcvrv}
+		methods[sname+"."+mname] = &methodType{method: method, ArgType: argType, ReplyType: replyType, Synchronous: synchronous, Stream: stream, Rcvr: rcvrv}
 	}
 }
 
@@ -285,7 +361,9 @@ func (s *ServerImpl) serveJSONCodec(conn io.ReadWriteCloser) {
 	}()
 
 	sending := new(sync.Mutex)
-	codec := jsonrpc.NewServerCodec(conn)
+	streams := &streamSet{m: make(map[string]chan struct{})}
+	defer streams.cancelAll()
+	codec := newJSONServerCodec(conn)
 	var req rpc.Request
 	var resp rpc.Response
 	for {
@@ -298,6 +376,17 @@ func (s *ServerImpl) serveJSONCodec(conn io.ReadWriteCloser) {
 			break
 		}
 
+		if req.ServiceMethod == cancelStreamMethod {
+			var args api.CancelStreamIn
+			if err = codec.ReadRequestBody(&args); err != nil {
+				return
+			}
+			s.log.Debugf("<- %s(%d)", req.ServiceMethod, args.Seq)
+			streams.remove(strconv.FormatUint(args.Seq, 10), true)
+			s.sendResponse(sending, &req, &rpc.Response{}, &api.CancelStreamOut{}, codec, "")
+			continue
+		}
+
 		mtype, ok := s.methodMaps[s.config.APIVersion-1][req.ServiceMethod]
 		if !ok {
 			s.log.Errorf("rpc: can't find method %s", req.ServiceMethod)
@@ -362,7 +451,12 @@ func (s *ServerImpl) serveJSONCodec(conn io.ReadWriteCloser) {
 				s.log.Debugf("(async %d) <- %s(%T%s)", req.Seq, req.ServiceMethod, argv.Interface(), argvbytes)
 			}
 			function := mtype.method.Func
-			ctl := &RPCCallback{s, sending, codec, req, make(chan struct{})}
+			ctl := &RPCCallback{s: s, sending: sending, codec: codec, req: req, setupDone: make(chan struct{})}
+			if mtype.Stream {
+				ctl.streams = streams
+				ctl.streamID = codec.id(req.Seq)
+				ctl.canceled = streams.add(ctl.streamID)
+			}
 			go func() {
 				defer func() {
 					if ierr := recover(); ierr != nil {
@@ -408,6 +502,9 @@ func (cb *RPCCallback) Return(out interface{}, err error) {
 	if err != nil {
 		errmsg = err.Error()
 	}
+	if cb.streams != nil {
+		cb.streams.remove(cb.streamID, false)
+	}
 	var resp rpc.Response
 	if logflags.RPC() {
 		outbytes, _ := json.Marshal(out)
@@ -416,10 +513,44 @@ func (cb *RPCCallback) Return(out interface{}, err error) {
 	cb.s.sendResponse(cb.sending, &cb.req, &resp, out, cb.codec, errmsg)
 }
 
+// Send sends a chunk of the result of a streaming call, as a response with
+// the same id as the call. Returns false if the call was canceled.
+func (cb *RPCCallback) Send(chunk interface{}) bool {
+	if logflags.RPC() {
+		chunkbytes, _ := json.Marshal(chunk)
+		cb.s.log.Debugf("(async %d) -> chunk %T%s", cb.req.Seq, chunk, chunkbytes)
+	}
+	resp := rpc.Response{ServiceMethod: cb.req.ServiceMethod, Seq: cb.req.Seq}
+	cb.sending.Lock()
+	defer cb.sending.Unlock()
+	// checked while holding the sending lock so that no chunk is sent after
+	// the response to CancelStream
+	select {
+	case <-cb.canceled:
+		return false
+	default:
+	}
+	if err := cb.codec.WriteChunk(&resp, &api.StreamChunk{Chunk: chunk}); err != nil {
+		cb.s.log.Error("writing response:", err)
+		return false
+	}
+	return true
+}
+
 func (cb *RPCCallback) SetupDoneChan() chan struct{} {
 	return cb.setupDone
 }
 
+func (cb *RPCCallback) Canceled() <-chan struct{} {
+	return cb.canceled
+}
+
+func (cb *RPCCallback) Disconnected() bool {
+	cb.streams.mu.Lock()
+	defer cb.streams.mu.Unlock()
+	return cb.streams.lost
+}
+
 // GetVersion returns the version of delve as well as the API version
 // currently served.
 func (s *RPCServer) GetVersion(args api.GetVersionIn, out *api.GetVersionOut) error {
@@ -433,13 +564,24 @@ func (s *RPCServer) SetApiVersion(args api.SetAPIVersionIn, out *api.SetAPIVersi
 	if args.APIVersion < 2 {
 		args.APIVersion = 1
 	}
-	if args.APIVersion > 2 {
+	if args.APIVersion > api.MaxAPIVersion {
 		return fmt.Errorf("unknown API version")
 	}
 	s.s.config.APIVersion = args.APIVersion
 	return nil
 }
 
+// Capabilities returns the optional features supported by the debugger
+// with the current target and backend, it is available in all versions of
+// the API.
+func (s *RPCServer) Capabilities(args api.CapabilitiesIn, out *api.CapabilitiesOut) error {
+	out.Capabilities = s.s.debugger.Capabilities()
+	for i := 1; i <= api.MaxAPIVersion; i++ {
+		out.APIVersions = append(out.APIVersions, i)
+	}
+	return nil
+}
+
 type internalError struct {
 	Err   interface{}
 	Stack []internalErrorFrame
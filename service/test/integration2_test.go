@@ -1,6 +1,10 @@
 package service_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -1177,6 +1181,71 @@ func TestClientServer_EvalVariable(t *testing.T) {
 	})
 }
 
+func TestClientServer_EvalMany(t *testing.T) {
+	withTestClient2("testvariables", t, func(c service.Client) {
+		state := <-c.Continue()
+		assertNoError(state.Err, t, "Continue()")
+
+		vars, err := c.EvalMany(api.EvalScope{GoroutineID: -1}, []string{"a1", "nonexistent", "a2"}, normalLoadConfig)
+		assertNoError(err, t, "EvalMany")
+		if len(vars) != 3 {
+			t.Fatalf("wrong number of variables: %d", len(vars))
+		}
+		if vars[0].Value != "foofoofoofoofoofoo" || vars[0].Unreadable != "" {
+			t.Errorf("wrong value for a1: %s", vars[0].SinglelineString())
+		}
+		if vars[1].Name != "nonexistent" || vars[1].Unreadable == "" {
+			t.Errorf("expected unreadable variable for nonexistent: %#v", vars[1])
+		}
+		if vars[2].Value != "6" || vars[2].Unreadable != "" {
+			t.Errorf("wrong value for a2: %s", vars[2].SinglelineString())
+		}
+
+		_, err = c.EvalMany(api.EvalScope{GoroutineID: -1, Frame: 1000}, []string{"a1"}, normalLoadConfig)
+		if err == nil {
+			t.Errorf("expected error for invalid frame")
+		}
+	})
+}
+
+func TestClientServer_ListVariableChildren(t *testing.T) {
+	protest.AllowRecording(t)
+	withTestClient2("testvariables2", t, func(c service.Client) {
+		state := <-c.Continue()
+		assertNoError(state.Err, t, "Continue()")
+
+		scope := api.EvalScope{GoroutineID: -1}
+		const pageSize = 10
+		keys := map[string]bool{}
+		var total int64 = -1
+		for start := 0; total < 0 || int64(start) < total; start += pageSize {
+			page, err := c.ListVariableChildren(scope, "m1", start, pageSize, normalLoadConfig)
+			assertNoError(err, t, fmt.Sprintf("ListVariableChildren(m1, %d)", start))
+			total = page.Len
+			if len(page.Children) == 0 || len(page.Children) > 2*pageSize {
+				t.Fatalf("wrong number of children at %d: %d", start, len(page.Children)/2)
+			}
+			for i := 0; i < len(page.Children); i += 2 {
+				keys[page.Children[i].Value] = true
+			}
+		}
+		if int64(len(keys)) != total {
+			t.Errorf("wrong number of distinct keys %d, expected %d", len(keys), total)
+		}
+
+		page, err := c.ListVariableChildren(scope, "longslice", 95, pageSize, normalLoadConfig)
+		assertNoError(err, t, "ListVariableChildren(longslice)")
+		if page.Len != 100 || len(page.Children) != 5 {
+			t.Errorf("wrong page of longslice: len %d, %d children", page.Len, len(page.Children))
+		}
+
+		_, err = c.ListVariableChildren(scope, "i1", 0, pageSize, normalLoadConfig)
+		if err == nil {
+			t.Errorf("ListVariableChildren(i1) did not return an error")
+		}
+	})
+}
+
 func TestClientServer_SetVariable(t *testing.T) {
 	withTestClient2("testvariables", t, func(c service.Client) {
 		state := <-c.Continue()
@@ -1202,6 +1271,95 @@ func TestClientServer_SetVariable(t *testing.T) {
 	})
 }
 
+func TestClientServer_RestartWatchpoint(t *testing.T) {
+	if runtime.GOOS == "freebsd" || runtime.GOOS == "windows" || runtime.GOARCH == "386" {
+		t.Skip("watchpoints not supported")
+	}
+	withTestClient2("databpcountstest", t, func(c service.Client) {
+		_, err := c.CreateBreakpoint(&api.Breakpoint{FunctionName: "main.main", Line: -1})
+		assertNoError(err, t, "CreateBreakpoint")
+		state := <-c.Continue()
+		assertNoError(state.Err, t, "Continue")
+		wp, err := c.CreateWatchpoint(api.EvalScope{GoroutineID: -1}, "globalvar1", api.WatchWrite)
+		assertNoError(err, t, "CreateWatchpoint")
+		if wp.WatchFunction != "main.main" {
+			t.Errorf("wrong WatchFunction %q", wp.WatchFunction)
+		}
+
+		discarded, err := c.Restart(false)
+		assertNoError(err, t, "Restart")
+		if len(discarded) != 0 {
+			t.Fatalf("breakpoints discarded by restart: %#v", discarded)
+		}
+		bp, err := c.GetBreakpoint(wp.ID)
+		assertNoError(err, t, "GetBreakpoint")
+		if !bp.WatchPending || bp.WatchExpr != "globalvar1" {
+			t.Fatalf("watchpoint not pending after restart: %#v", bp)
+		}
+
+		// main.main recreates the watchpoint.
+		state = <-c.Continue()
+		assertNoError(state.Err, t, "Continue")
+		if state.CurrentThread.Breakpoint == nil || state.CurrentThread.Breakpoint.ID == wp.ID {
+			t.Fatalf("expected to stop on the breakpoint on main.main: %#v", state.CurrentThread.Breakpoint)
+		}
+		state = <-c.Continue()
+		assertNoError(state.Err, t, "Continue")
+		if state.CurrentThread.Breakpoint == nil || state.CurrentThread.Breakpoint.ID != wp.ID {
+			t.Fatalf("expected to stop on the watchpoint: %#v", state.CurrentThread.Breakpoint)
+		}
+		bp, err = c.GetBreakpoint(wp.ID)
+		assertNoError(err, t, "GetBreakpoint")
+		if bp.WatchPending || bp.Addr == 0 {
+			t.Fatalf("watchpoint not recreated: %#v", bp)
+		}
+	})
+}
+
+func TestClientServer_StopCache(t *testing.T) {
+	withTestClient2("testvariables", t, func(c service.Client) {
+		state := <-c.Continue()
+		assertNoError(state.Err, t, "Continue()")
+
+		eval := func() string {
+			a2, err := c.EvalVariable(api.EvalScope{GoroutineID: -1}, "a2", normalLoadConfig)
+			assertNoError(err, t, "EvalVariable(a2)")
+			return a2.Value
+		}
+		old := eval()
+		if eval() != old {
+			t.Fatalf("value of a2 changed while stopped")
+		}
+		assertNoError(c.SetVariable(api.EvalScope{GoroutineID: -1}, "a2", "8"), t, "SetVariable()")
+		if v := eval(); v != "8" {
+			t.Fatalf("wrong value of a2 after SetVariable: %s (was %s)", v, old)
+		}
+
+		stack := func() []api.Stackframe {
+			frames, err := c.Stacktrace(-1, 10, 0, nil)
+			assertNoError(err, t, "Stacktrace()")
+			return frames
+		}
+		mainStack := stack()
+		if !reflect.DeepEqual(stack(), mainStack) {
+			t.Fatalf("stacktrace changed while stopped")
+		}
+		gs, _, err := c.ListGoroutines(0, 0)
+		assertNoError(err, t, "ListGoroutines()")
+		for _, g := range gs {
+			if g.ID == state.SelectedGoroutine.ID {
+				continue
+			}
+			_, err := c.SwitchGoroutine(g.ID)
+			assertNoError(err, t, "SwitchGoroutine()")
+			if reflect.DeepEqual(stack(), mainStack) {
+				t.Fatalf("stacktrace of goroutine %d is the stacktrace of goroutine %d", g.ID, state.SelectedGoroutine.ID)
+			}
+			break
+		}
+	})
+}
+
 func TestClientServer_FullStacktrace(t *testing.T) {
 	protest.AllowRecording(t)
 	if runtime.GOOS == "darwin" && runtime.GOARCH == "arm64" {
@@ -1635,6 +1793,43 @@ func TestTypesCommand(t *testing.T) {
 	})
 }
 
+func TestFindSymbols(t *testing.T) {
+	protest.AllowRecording(t)
+	withTestClient2("testvariables2", t, func(c service.Client) {
+		syms, err := c.FindSymbols("main.astruct", 0)
+		assertNoError(err, t, "FindSymbols(\"main.astruct\")")
+		if len(syms) == 0 || syms[0].Kind != api.TypeSymbol || syms[0].Name != "main.astruct" {
+			t.Fatalf("expected main.astruct type first, got %v", syms)
+		}
+		for i := 1; i < len(syms); i++ {
+			if syms[i].Score > syms[i-1].Score {
+				t.Fatalf("symbols not sorted by score: %v", syms)
+			}
+		}
+
+		syms, err = c.FindSymbols("TESTVARIABLES2.GO", 0)
+		assertNoError(err, t, "FindSymbols(\"TESTVARIABLES2.GO\")")
+		if len(syms) == 0 || syms[0].Kind != api.SourceSymbol || filepath.Base(syms[0].Name) != "testvariables2.go" {
+			t.Fatalf("expected testvariables2.go source first, got %v", syms)
+		}
+
+		syms, err = c.FindSymbols("mnmain", 3)
+		assertNoError(err, t, "FindSymbols(\"mnmain\")")
+		if len(syms) != 3 {
+			t.Fatalf("expected 3 symbols, got %v", syms)
+		}
+		found := false
+		for _, sym := range syms {
+			if sym.Kind == api.FunctionSymbol && sym.Name == "main.main" {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("main.main not found in %v", syms)
+		}
+	})
+}
+
 func TestIssue406(t *testing.T) {
 	protest.AllowRecording(t)
 	withTestClient2("issue406", t, func(c service.Client) {
@@ -2120,6 +2315,112 @@ func TestForceStopWhileContinue(t *testing.T) {
 	<-serverStopped                   // Stop() didn't block on detach because we halted first
 }
 
+func TestClientServer_ResumeSession(t *testing.T) {
+	if testBackend == "rr" {
+		t.Skip("recording not allowed for TestClientServer_ResumeSession")
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't start listener: %s\n", err)
+	}
+	serverDone := make(chan struct{})
+	go func() {
+		defer close(serverDone)
+		defer listener.Close()
+		disconnectChan := make(chan struct{})
+		server := rpccommon.NewServer(&service.Config{
+			Listener:         listener,
+			ProcessArgs:      []string{protest.BuildFixture("loopprog", 0).Path},
+			AcceptMulti:      true,
+			HaltOnDisconnect: true,
+			DisconnectChan:   disconnectChan,
+			Debugger: debugger.Config{
+				Backend: testBackend,
+			},
+		})
+		if err := server.Run(); err != nil {
+			panic(err)
+		}
+		<-disconnectChan
+		server.Stop()
+	}()
+
+	waitEvent := func(events <-chan *api.Event, kind api.EventKind) *api.Event {
+		for {
+			select {
+			case ev := <-events:
+				if ev.Kind == kind {
+					return ev
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatalf("timed out waiting for %s event", kind)
+			}
+		}
+	}
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assertNoError(err, t, "Dial")
+	client := rpc2.NewClientFromConn(conn)
+	events := make(chan *api.Event, 100)
+	go client.SubscribeSession("test", func(ev *api.Event) bool {
+		events <- ev
+		return true
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	contch := client.Continue()
+	waitEvent(events, api.EventResumed)
+
+	// the connection is lost while the target is running, the server must
+	// halt it and queue the stop
+	conn.Close()
+	if state := <-contch; state.Err == nil {
+		t.Fatalf("continue did not fail after the connection was closed: %#v", state)
+	}
+	client2 := rpc2.NewClient(listener.Addr().String())
+	for start := time.Now(); ; time.Sleep(100 * time.Millisecond) {
+		state, err := client2.GetStateNonBlocking()
+		assertNoError(err, t, "GetStateNonBlocking")
+		if !state.Running {
+			break
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("target not halted after the connection was lost")
+		}
+	}
+	client2.Disconnect(false)
+
+	conn, err = net.Dial("tcp", listener.Addr().String())
+	assertNoError(err, t, "Dial")
+	assertNoError(client.Reconnect(conn), t, "Reconnect")
+	events = make(chan *api.Event, 100)
+	done := make(chan error)
+	go func() {
+		done <- client.SubscribeSession("test", func(ev *api.Event) bool {
+			events <- ev
+			return true
+		})
+	}()
+	waitEvent(events, api.EventStopped)
+	ev := waitEvent(events, api.EventSessionResumed)
+	if ev.Err != "" || ev.State == nil {
+		t.Fatalf("bad resumed event %#v", ev)
+	}
+
+	if ev.State.Running || ev.State.Exited {
+		t.Fatalf("target not stopped after resuming the session: %#v", ev.State)
+	}
+
+	assertNoError(client.EndSession("test"), t, "EndSession")
+	assertNoError(<-done, t, "SubscribeSession")
+	if err := client.EndSession("test"); err == nil {
+		t.Error("session still exists after EndSession")
+	}
+
+	client.Detach(true)
+	<-serverDone
+}
+
 func TestClientServerFunctionCall(t *testing.T) {
 	protest.MustSupportFunctionCalls(t, testBackend)
 	withTestClient2("fncall", t, func(c service.Client) {
@@ -2636,6 +2937,52 @@ func TestGoroutinesGrouping(t *testing.T) {
 	})
 }
 
+func TestGoroutinesLeaks(t *testing.T) {
+	withTestClient2("goroutineleak", t, func(c service.Client) {
+		state := <-c.Continue()
+		assertNoError(state.Err, t, "Continue")
+		_, ggrp, _, _, err := c.ListGoroutinesWithFilter(0, 0, nil, &api.GoroutineGroupingOptions{GroupBy: api.GoroutineLeak, GroupByKey: "1s", MaxGroupMembers: 5, MaxGroups: 10})
+		assertNoError(err, t, "ListGoroutinesWithFilter (leaks)")
+		t.Logf("%#v\n", ggrp)
+		if len(ggrp) == 0 {
+			t.Fatal("no groups returned")
+		}
+		if !strings.Contains(ggrp[0].Name, "possible leak") || !strings.Contains(ggrp[0].Name, "chan receive") {
+			t.Errorf("first group is not a possible leak: %q", ggrp[0].Name)
+		}
+		if ggrp[0].Total != 10 {
+			t.Errorf("wrong number of leaked goroutines: %d (expected 10)", ggrp[0].Total)
+		}
+	})
+}
+
+func TestFindDeadlocks(t *testing.T) {
+	withTestClient2("deadlock", t, func(c service.Client) {
+		state := <-c.Continue()
+		assertNoError(state.Err, t, "Continue")
+		deadlocks, err := c.FindDeadlocks()
+		assertNoError(err, t, "FindDeadlocks")
+		for i := range deadlocks {
+			for _, dg := range deadlocks[i] {
+				t.Logf("%d: goroutine %d %s objects %v wakers %v", i, dg.Goroutine.ID, dg.Goroutine.StartLoc.Function.Name(), dg.Objects, dg.Wakers)
+			}
+		}
+		if len(deadlocks) != 2 {
+			t.Fatalf("wrong number of deadlocks: %d (expected 2)", len(deadlocks))
+		}
+		kinds := map[string]bool{}
+		for i := range deadlocks {
+			if len(deadlocks[i]) != 2 {
+				t.Errorf("wrong number of goroutines in deadlock %d: %d (expected 2)", i, len(deadlocks[i]))
+			}
+			kinds[deadlocks[i][0].Objects[0].Kind] = true
+		}
+		if !kinds["chan"] || !kinds["sema"] {
+			t.Errorf("expected a deadlock on channels and one on mutexes, got %v", kinds)
+		}
+	})
+}
+
 func TestLongStringArg(t *testing.T) {
 	// Test the ability to load more elements of a string argument, this could
 	// be broken if registerized variables are not handled correctly.
@@ -2767,3 +3114,361 @@ func TestRestartRewindAfterEnd(t *testing.T) {
 		}
 	})
 }
+
+func TestClientServer_Stream(t *testing.T) {
+	withTestClient2("continuetestprog", t, func(c service.Client) {
+		client := c.(*rpc2.RPCClient)
+		funcs, err := c.ListFunctions("main\\.")
+		assertNoError(err, t, "ListFunctions")
+
+		var streamed []string
+		chunks := 0
+		err = client.StreamFunctions("main\\.", 1, func(fns []string) bool {
+			if len(fns) != 1 {
+				t.Errorf("wrong chunk size %d", len(fns))
+			}
+			streamed = append(streamed, fns...)
+			chunks++
+			return true
+		})
+		assertNoError(err, t, "StreamFunctions")
+		if !reflect.DeepEqual(streamed, funcs) {
+			t.Errorf("mismatched functions %v, expected %v", streamed, funcs)
+		}
+		if chunks != len(funcs) {
+			t.Errorf("wrong number of chunks %d, expected %d", chunks, len(funcs))
+		}
+
+		// Cancel the call after the first chunk, the connection must remain
+		// usable.
+		chunks = 0
+		err = client.StreamFunctions("", 1, func(fns []string) bool {
+			chunks++
+			return false
+		})
+		assertNoError(err, t, "StreamFunctions (canceled)")
+		if chunks != 1 {
+			t.Errorf("chunks received after cancel: %d", chunks)
+		}
+		funcs2, err := c.ListFunctions("main\\.")
+		assertNoError(err, t, "ListFunctions after cancel")
+		if !reflect.DeepEqual(funcs2, funcs) {
+			t.Errorf("mismatched functions after cancel %v, expected %v", funcs2, funcs)
+		}
+
+		err = client.StreamFunctions("(", 1, func(fns []string) bool { return true })
+		if err == nil {
+			t.Errorf("StreamFunctions with an invalid filter did not return an error")
+		}
+	})
+}
+
+func TestClientServer_StreamCancel(t *testing.T) {
+	// Uses the JSON-RPC protocol directly since RPCClient discards the
+	// chunks received after a call is canceled.
+	conn, _ := startServer("continuetestprog", 0, t, [3]string{})
+	defer conn.Close()
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+
+	type response struct {
+		Id     uint64
+		Result json.RawMessage
+		Error  interface{}
+	}
+	call := func(id uint64, method string, args interface{}) {
+		t.Helper()
+		err := enc.Encode(map[string]interface{}{"method": "RPCServer." + method, "params": []interface{}{args}, "id": id})
+		assertNoError(err, t, "Encode")
+	}
+	read := func() response {
+		t.Helper()
+		var resp response
+		assertNoError(dec.Decode(&resp), t, "Decode")
+		if resp.Error != nil {
+			t.Fatalf("error response %#v", resp)
+		}
+		return resp
+	}
+	isChunk := func(resp response) bool {
+		var chunk struct{ Chunk json.RawMessage }
+		return json.Unmarshal(resp.Result, &chunk) == nil && len(chunk.Chunk) > 0
+	}
+
+	call(0, "SetApiVersion", api.SetAPIVersionIn{APIVersion: 2})
+	read()
+
+	// the id of the streaming call is different from the sequence number
+	// assigned by the server to the request
+	const streamID = 10
+	call(streamID, "StreamFunctions", rpc2.StreamFunctionsIn{ChunkSize: 1})
+	if resp := read(); resp.Id != streamID || !isChunk(resp) {
+		t.Fatalf("expected a chunk of call %d, got %#v", streamID, resp)
+	}
+	call(streamID+1, "CancelStream", api.CancelStreamIn{Seq: streamID})
+
+	canceled, done := false, false
+	for !canceled || !done {
+		resp := read()
+		switch {
+		case resp.Id == streamID+1:
+			canceled = true
+		case resp.Id == streamID && isChunk(resp):
+			if canceled {
+				t.Fatal("chunk received after the call was canceled")
+			}
+		case resp.Id == streamID:
+			var out rpc2.StreamOut
+			assertNoError(json.Unmarshal(resp.Result, &out), t, "Unmarshal")
+			if !out.Canceled {
+				t.Fatalf("call not canceled: %#v", out)
+			}
+			done = true
+		default:
+			t.Fatalf("unexpected response %#v", resp)
+		}
+	}
+
+	call(streamID+2, "Detach", rpc2.DetachIn{Kill: true})
+	read()
+}
+
+func TestClientServer_Subscribe(t *testing.T) {
+	withTestClient2("continuetestprog", t, func(c service.Client) {
+		client := c.(*rpc2.RPCClient)
+		events := make(chan *api.Event, 10)
+		done := make(chan error)
+		go func() {
+			done <- client.Subscribe(func(ev *api.Event) bool {
+				events <- ev
+				return ev.Kind != api.EventBreakpointCleared
+			})
+		}()
+		time.Sleep(100 * time.Millisecond)
+
+		bp, err := c.CreateBreakpoint(&api.Breakpoint{FunctionName: "main.sayhi"})
+		assertNoError(err, t, "CreateBreakpoint")
+		_, err = c.ClearBreakpoint(bp.ID)
+		assertNoError(err, t, "ClearBreakpoint")
+
+		for _, kind := range []api.EventKind{api.EventBreakpointCreated, api.EventBreakpointCleared} {
+			select {
+			case ev := <-events:
+				if ev.Kind != kind || ev.Breakpoint == nil || ev.Breakpoint.ID != bp.ID {
+					t.Errorf("got event %#v, expected %s of breakpoint %d", ev, kind, bp.ID)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for %s event", kind)
+			}
+		}
+		assertNoError(<-done, t, "Subscribe")
+	})
+}
+
+func TestClientServer_Capabilities(t *testing.T) {
+	withTestClient2("continuetestprog", t, func(c service.Client) {
+		client := c.(*rpc2.RPCClient)
+		caps, err := client.Capabilities()
+		assertNoError(err, t, "Capabilities")
+		t.Logf("%#v", caps)
+		if !reflect.DeepEqual(caps.APIVersions, []int{1, 2}) {
+			t.Errorf("wrong API versions %v", caps.APIVersions)
+		}
+		if caps.Backend == "" {
+			t.Error("missing backend")
+		}
+		if caps.ReverseExecution != (testBackend == "rr") {
+			t.Errorf("wrong ReverseExecution %v for backend %s", caps.ReverseExecution, testBackend)
+		}
+		if !caps.Restart || !caps.StreamingCalls {
+			t.Errorf("missing capabilities %#v", caps)
+		}
+		if caps.Watchpoints && runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+			t.Errorf("watchpoints reported as supported on %s", runtime.GOARCH)
+		}
+	})
+}
+
+func TestClientServer_FollowExec(t *testing.T) {
+	if runtime.GOOS != "linux" || testBackend != "native" {
+		t.Skip("follow exec is only supported on linux with the native backend")
+	}
+	withTestClient2Extended("spawnexec", t, 0, [3]string{}, func(c service.Client, fixture protest.Fixture) {
+		assertNoError(c.FollowExec(true, "", ""), t, "FollowExec")
+		enabled, _, _, err := c.FollowExecEnabled()
+		assertNoError(err, t, "FollowExecEnabled")
+		if !enabled {
+			t.Fatal("follow exec not enabled")
+		}
+		parentPid := c.ProcessPid()
+		_, err = c.CreateBreakpoint(&api.Breakpoint{FunctionName: "main.child"})
+		assertNoError(err, t, "CreateBreakpoint (child)")
+		bp, err := c.CreateBreakpoint(&api.Breakpoint{FunctionName: "main.main", Line: 9, TargetPid: parentPid})
+		assertNoError(err, t, "CreateBreakpoint (parent)")
+		if bp.TargetPid != parentPid {
+			t.Errorf("wrong TargetPid %d, expected %d", bp.TargetPid, parentPid)
+		}
+
+		state := <-c.Continue()
+		assertNoError(state.Err, t, "Continue")
+		if state.Pid == parentPid || state.CurrentThread.Function.Name() != "main.child" {
+			t.Fatalf("expected to stop in main.child in the child, got process %d %s:%d", state.Pid, state.CurrentThread.File, state.CurrentThread.Line)
+		}
+		childPid := state.Pid
+
+		tgts, err := c.ListTargets()
+		assertNoError(err, t, "ListTargets")
+		if len(tgts) != 2 || tgts[0].Pid != parentPid || tgts[1].Pid != childPid {
+			t.Fatalf("wrong targets %#v", tgts)
+		}
+		if tgts[1].Path != fixture.Path {
+			t.Errorf("wrong path of the child %q, expected %q", tgts[1].Path, fixture.Path)
+		}
+
+		_, err = c.SwitchTarget(parentPid)
+		assertNoError(err, t, "SwitchTarget (parent)")
+		if pid := c.ProcessPid(); pid != parentPid {
+			t.Errorf("wrong pid after SwitchTarget %d, expected %d", pid, parentPid)
+		}
+		_, err = c.SwitchTarget(childPid)
+		assertNoError(err, t, "SwitchTarget (child)")
+
+		// the child exits and the parent is resumed.
+		state = <-c.Continue()
+		assertNoError(state.Err, t, "Continue")
+		if state.Pid != parentPid || state.CurrentThread.Function.Name() != "main.main" {
+			t.Fatalf("expected to stop in main.main in the parent, got process %d %s:%d", state.Pid, state.CurrentThread.File, state.CurrentThread.Line)
+		}
+		tgts, err = c.ListTargets()
+		assertNoError(err, t, "ListTargets")
+		if len(tgts) != 1 {
+			t.Errorf("wrong targets after the child exited %#v", tgts)
+		}
+
+		state = <-c.Continue()
+		if !state.Exited || state.ExitStatus != 0 || state.Pid != parentPid {
+			t.Errorf("expected the parent to exit with status 0, got %#v", state)
+		}
+	})
+}
+
+func TestClientServer_EvalDisplayForget(t *testing.T) {
+	// An expression that was not evaluated at the previous stop must not
+	// report changes against an older value.
+	withTestClient2("testnextprog", t, func(c service.Client) {
+		_, err := c.CreateBreakpoint(&api.Breakpoint{FunctionName: "main.sleepytime", Line: -1})
+		assertNoError(err, t, "CreateBreakpoint")
+		state := <-c.Continue()
+		assertNoError(state.Err, t, "Continue")
+		scope := api.EvalScope{GoroutineID: -1, Frame: 1}
+		v, _, err := c.EvalDisplay(scope, "i", normalLoadConfig)
+		assertNoError(err, t, "EvalDisplay")
+		if v.Value != "0" {
+			t.Fatalf("wrong value of i at the first stop: %s", v.Value)
+		}
+		_, err = c.Next()
+		assertNoError(err, t, "Next")
+		state = <-c.Continue()
+		assertNoError(state.Err, t, "Continue")
+		v, changes, err := c.EvalDisplay(scope, "i", normalLoadConfig)
+		assertNoError(err, t, "EvalDisplay")
+		if v.Value != "1" {
+			t.Fatalf("wrong value of i at the third stop: %s", v.Value)
+		}
+		if len(changes) != 0 {
+			t.Errorf("expression not evaluated at the previous stop reported changes: %v", changes)
+		}
+	})
+}
+
+// pprofStrings returns the string table of the CPU profile buf, written by
+// runtime/pprof as a gzip compressed profile.proto message.
+func pprofStrings(t *testing.T, buf []byte) []string {
+	t.Helper()
+	r, err := gzip.NewReader(bytes.NewReader(buf))
+	assertNoError(err, t, "gzip.NewReader")
+	buf, err = ioutil.ReadAll(r)
+	assertNoError(err, t, "ReadAll")
+
+	varint := func() uint64 {
+		v, n := binary.Uvarint(buf)
+		if n <= 0 {
+			t.Fatalf("malformed varint in profile")
+		}
+		buf = buf[n:]
+		return v
+	}
+
+	var strs []string
+	for len(buf) > 0 {
+		key := varint()
+		switch key & 7 {
+		case 0: // varint
+			varint()
+		case 1: // 64-bit
+			buf = buf[8:]
+		case 2: // length-delimited
+			n := varint()
+			if n > uint64(len(buf)) {
+				t.Fatalf("malformed field in profile")
+			}
+			if key>>3 == 6 { // Profile.string_table
+				strs = append(strs, string(buf[:n]))
+			}
+			buf = buf[n:]
+		case 5: // 32-bit
+			buf = buf[4:]
+		default:
+			t.Fatalf("unknown wire type %d in profile", key&7)
+		}
+	}
+	return strs
+}
+
+func TestClientServer_ProfileCPU(t *testing.T) {
+	protest.MustSupportFunctionCalls(t, testBackend)
+	withTestClient2("profileprog", t, func(c service.Client) {
+		bp, err := c.CreateBreakpoint(&api.Breakpoint{FunctionName: "main.spin", Line: -1})
+		assertNoError(err, t, "CreateBreakpoint")
+		state := <-c.Continue()
+		assertNoError(state.Err, t, "Continue")
+		_, err = c.ClearBreakpoint(bp.ID)
+		assertNoError(err, t, "ClearBreakpoint")
+
+		profile, err := c.ProfileCPU(0, time.Second)
+		assertNoError(err, t, "ProfileCPU")
+		strs := pprofStrings(t, profile)
+		found := map[string]bool{}
+		for _, s := range strs {
+			found[s] = true
+		}
+		for _, s := range []string{"samples", "cpu", "nanoseconds", "main.spin"} {
+			if !found[s] {
+				t.Errorf("%q not found in the profile strings %q", s, strs)
+			}
+		}
+	})
+}
+
+func TestClientServer_ProfileTrace(t *testing.T) {
+	protest.MustSupportFunctionCalls(t, testBackend)
+	withTestClient2("profileprog", t, func(c service.Client) {
+		bp, err := c.CreateBreakpoint(&api.Breakpoint{FunctionName: "main.spin", Line: -1})
+		assertNoError(err, t, "CreateBreakpoint")
+		state := <-c.Continue()
+		assertNoError(state.Err, t, "Continue")
+		_, err = c.ClearBreakpoint(bp.ID)
+		assertNoError(err, t, "ClearBreakpoint")
+
+		trace, err := c.ProfileTrace(0, time.Second)
+		assertNoError(err, t, "ProfileTrace")
+		// execution traces start with a 16 bytes header: "go 1.xx trace"
+		// followed by zeroes.
+		if len(trace) <= 16 {
+			t.Fatalf("execution trace too short: %q", trace)
+		}
+		if hdr := trace[:16]; !bytes.HasPrefix(hdr, []byte("go 1.")) || !bytes.Contains(hdr, []byte(" trace\x00")) {
+			t.Fatalf("wrong header for execution trace %q", hdr)
+		}
+	})
+}
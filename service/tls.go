@@ -0,0 +1,239 @@
+package service
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-delve/delve/pkg/logflags"
+)
+
+// TLSConfig describes the TLS configuration and the authentication of the
+// connections between a debug server and its clients.
+type TLSConfig struct {
+	// CertFile and KeyFile are the certificate and private key presented to
+	// the other end of the connection: the certificate of the server, or the
+	// client certificate used for client authentication.
+	CertFile string
+	KeyFile  string
+
+	// CAFile is the certificate authority used to verify the certificate of
+	// the other end. When set on the server clients must present a
+	// certificate signed by it; clients use it instead of the system roots.
+	CAFile string
+
+	// Token, if not empty, is the bearer token that clients must send to
+	// be accepted by the server.
+	Token string
+}
+
+// authTimeout is the time a client has to complete the TLS handshake and
+// authenticate after connecting.
+const authTimeout = 10 * time.Second
+
+// authHeader is the line sent by clients to authenticate with a token,
+// before the messages of the protocol. It is formatted as a header of the
+// base protocol of DAP.
+const authHeader = "Authorization: Bearer "
+
+// maxAuthLineLen is the maximum length of the authentication line.
+const maxAuthLineLen = 4096
+
+// NewTLSListener returns a listener that accepts TLS connections on l,
+// using the server certificate of cfg. If cfg has a CA certificate clients
+// must present a certificate signed by it, if cfg has a token clients must
+// send it before any other message.
+func NewTLSListener(l net.Listener, cfg *TLSConfig) (net.Listener, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("a TLS certificate and key are required to accept TLS connections")
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load TLS certificate: %v", err)
+	}
+	tlsConf := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.CAFile != "" {
+		tlsConf.ClientCAs, err = loadCertPool(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return newAuthListener(tls.NewListener(l, tlsConf), cfg.Token), nil
+}
+
+// DialTLS connects to the debug server listening at addr over TLS and
+// authenticates with the client certificate and the token of cfg.
+func DialTLS(addr string, cfg *TLSConfig) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConf := &tls.Config{
+		ServerName: host,
+		MinVersion: tls.VersionTLS12,
+	}
+	if cfg.CAFile != "" {
+		tlsConf.RootCAs, err = loadCertPool(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load TLS client certificate: %v", err)
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+	conn, err := tls.Dial("tcp", addr, tlsConf)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Token != "" {
+		if _, err := fmt.Fprintf(conn, "%s%s\r\n", authHeader, cfg.Token); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not load CA certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(buf) {
+		return nil, fmt.Errorf("could not load CA certificate: no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// authListener is a net.Listener that only accepts TLS connections that
+// complete the handshake and, if token is set, authenticate with token.
+// Each connection is authenticated in its own goroutine so that clients
+// that are slow to authenticate do not delay the others.
+type authListener struct {
+	net.Listener
+	token string
+
+	conns     chan net.Conn
+	errc      chan error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newAuthListener(l net.Listener, token string) *authListener {
+	al := &authListener{
+		Listener: l,
+		token:    token,
+		conns:    make(chan net.Conn),
+		errc:     make(chan error, 1),
+		done:     make(chan struct{}),
+	}
+	go al.acceptLoop()
+	return al
+}
+
+func (l *authListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			l.errc <- err
+			return
+		}
+		go l.authenticate(conn)
+	}
+}
+
+// authenticate completes the TLS handshake of conn and checks its token,
+// then hands it to Accept. Connections that fail are closed.
+func (l *authListener) authenticate(conn net.Conn) {
+	err := handshake(conn)
+	if err == nil && l.token != "" {
+		err = checkToken(conn, l.token)
+	}
+	if err != nil {
+		logflags.RPCLogger().Errorf("rejected connection from %s: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+// Accept waits for a connection that completed the TLS handshake and
+// authenticated.
+func (l *authListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case err := <-l.errc:
+		// Keep returning the error to later calls.
+		l.errc <- err
+		return nil, err
+	}
+}
+
+// Close closes the listener and the connections that are being
+// authenticated.
+func (l *authListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// handshake runs the TLS handshake of conn, if it is a TLS connection.
+func handshake(conn net.Conn) error {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	tlsConn.SetDeadline(time.Now().Add(authTimeout))
+	defer tlsConn.SetDeadline(time.Time{})
+	return tlsConn.Handshake()
+}
+
+// checkToken reads the authentication line from conn and checks that it
+// contains token. The line is read one byte at a time so that no message
+// following it is consumed.
+func checkToken(conn net.Conn, token string) error {
+	conn.SetReadDeadline(time.Now().Add(authTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			return err
+		}
+		if buf[0] == '\n' {
+			break
+		}
+		line = append(line, buf[0])
+		if len(line) > maxAuthLineLen {
+			return errors.New("authentication line too long")
+		}
+	}
+	got := strings.TrimSuffix(string(line), "\r")
+	if !strings.HasPrefix(got, authHeader) {
+		return errors.New("missing authentication token")
+	}
+	if subtle.ConstantTimeCompare([]byte(got[len(authHeader):]), []byte(token)) != 1 {
+		return errors.New("wrong authentication token")
+	}
+	return nil
+}
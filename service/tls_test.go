@@ -0,0 +1,160 @@
+package service
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert creates a certificate signed by parent (self-signed if parent is
+// nil) and writes it and its key to dir. Returns the paths of the files, the
+// certificate and the key.
+func writeCert(t *testing.T, dir, name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (string, string, *x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, cert, key
+}
+
+func TestTLSListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dlv-tls-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	caFile, _, ca, caKey := writeCert(t, dir, "ca", true, nil, nil)
+	serverCert, serverKey, _, _ := writeCert(t, dir, "server", false, ca, caKey)
+	clientCert, clientKey, _, _ := writeCert(t, dir, "client", false, ca, caKey)
+	otherCert, otherKey, _, _ := writeCert(t, dir, "other", false, nil, nil)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err = NewTLSListener(l, &TLSConfig{CertFile: serverCert, KeyFile: serverKey, CAFile: caFile, Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// The server echoes the first line received on accepted connections.
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, err := bufio.NewReader(conn).ReadString('\n')
+				if err == nil {
+					conn.Write([]byte(line))
+				}
+			}()
+		}
+	}()
+
+	echo := func(cfg *TLSConfig) error {
+		conn, err := DialTLS(l.Addr().String(), cfg)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		if _, err := conn.Write([]byte("hello\n")); err != nil {
+			return err
+		}
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if line != "hello\n" {
+			t.Errorf("got %q, want %q", line, "hello\n")
+		}
+		return nil
+	}
+
+	if err := echo(&TLSConfig{CertFile: clientCert, KeyFile: clientKey, CAFile: caFile, Token: "secret"}); err != nil {
+		t.Errorf("authenticated client: %v", err)
+	}
+
+	// Clients that connect without completing the handshake, or without
+	// authenticating, do not delay the others.
+	idle, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+	idleTLS, err := DialTLS(l.Addr().String(), &TLSConfig{CertFile: clientCert, KeyFile: clientKey, CAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idleTLS.Close()
+	start := time.Now()
+	if err := echo(&TLSConfig{CertFile: clientCert, KeyFile: clientKey, CAFile: caFile, Token: "secret"}); err != nil {
+		t.Errorf("authenticated client after idle clients: %v", err)
+	}
+	if d := time.Since(start); d >= authTimeout {
+		t.Errorf("authenticated client delayed by idle clients for %v", d)
+	}
+	if err := echo(&TLSConfig{CertFile: clientCert, KeyFile: clientKey, CAFile: caFile, Token: "wrong"}); err == nil {
+		t.Errorf("client with the wrong token was accepted")
+	}
+	if err := echo(&TLSConfig{CertFile: otherCert, KeyFile: otherKey, CAFile: caFile, Token: "secret"}); err == nil {
+		t.Errorf("client with an untrusted certificate was accepted")
+	}
+	if err := echo(&TLSConfig{CertFile: clientCert, KeyFile: clientKey, Token: "secret"}); err == nil {
+		t.Errorf("client accepted an untrusted server certificate")
+	}
+
+	if _, err := NewTLSListener(l, &TLSConfig{Token: "secret"}); err == nil {
+		t.Errorf("listener without a certificate was created")
+	}
+}